@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"encoding/json"
+
+	"github.com/rohanthewiz/rweb"
+	"github.com/rohanthewiz/serr"
+)
+
+// DefaultAccountUpdate names the account to make the default
+type DefaultAccountUpdate struct {
+	Account string `json:"account"`
+}
+
+// ListAccountsHandler returns the stored Claude accounts with when their tokens expire and
+// whether they refresh, without the tokens
+func ListAccountsHandler(c rweb.Context) error {
+	accounts, err := storage.ListAccounts()
+	if err != nil {
+		return c.WriteError(err, 500)
+	}
+	return c.WriteJSON(accounts)
+}
+
+// SetDefaultAccountHandler makes an account the one used by sessions without their own
+func SetDefaultAccountHandler(c rweb.Context) error {
+	var update DefaultAccountUpdate
+	if err := json.Unmarshal(c.Request().Body(), &update); err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid request body"), 400)
+	}
+
+	if err := storage.SetDefaultAccount(update.Account); err != nil {
+		return c.WriteError(err, 404)
+	}
+	return c.WriteJSON(update)
+}
+
+// RemoveAccountHandler logs out of one account. Sessions using it go back to the default.
+func RemoveAccountHandler(c rweb.Context) error {
+	if err := storage.RemoveAccount(c.Request().Param("name")); err != nil {
+		return c.WriteError(err, 404)
+	}
+	return c.WriteJSON(map[string]string{"status": "removed"})
+}
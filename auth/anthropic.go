@@ -46,8 +46,13 @@ type PKCEChallenge struct {
 	Verifier  string
 	Challenge string
 	Method    string
+	Account   string // The account being logged in, the default one when empty
 }
 
+// tokenClient makes the token requests; a refresh holds up the requests waiting for it,
+// so it mustn't hang
+var tokenClient = &http.Client{Timeout: 30 * time.Second}
+
 // Storage for PKCE challenges (in-memory) and tokens (persistent)
 var (
 	pkceStore = sync.Map{}
@@ -102,11 +107,16 @@ func AnthropicAuthorizeHandler(c rweb.Context) error {
 	}
 	state := base64.RawURLEncoding.EncodeToString(stateBytes)
 
-	// Store PKCE verifier for later use
+	// Store PKCE verifier for later use, with the account it logs in
+	params, _ := url.ParseQuery(c.Request().Query())
+	pkce.Account, err = accountName(params.Get("account"))
+	if err != nil {
+		return c.WriteJSON(map[string]string{"error": err.Error()})
+	}
 	pkceStore.Store(state, pkce)
 
 	// Build authorization URL
-	params := url.Values{
+	authParams := url.Values{
 		"response_type":         {"code"},
 		"client_id":             {clientID},
 		"redirect_uri":          {redirectURI},
@@ -116,7 +126,7 @@ func AnthropicAuthorizeHandler(c rweb.Context) error {
 		"code_challenge_method": {pkce.Method},
 	}
 
-	authURL := fmt.Sprintf("%s?%s", authorizeURL, params.Encode())
+	authURL := fmt.Sprintf("%s?%s", authorizeURL, authParams.Encode())
 
 	// Redirect to Anthropic's OAuth page
 	return c.Redirect(302, authURL)
@@ -151,7 +161,7 @@ func AnthropicCallbackHandler(c rweb.Context) error {
 	}
 
 	// Store tokens persistently
-	if err := storage.SaveAnthropicTokens(tokens); err != nil {
+	if err := storage.SaveAccount(pkce.Account, tokens); err != nil {
 		logger.LogErr(err, "failed to save tokens")
 		return c.WriteJSON(map[string]string{"error": "failed to save tokens"})
 	}
@@ -204,7 +214,7 @@ func exchangeCodeForTokens(code, verifier string) (*TokenResponse, error) {
 
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := tokenClient.Do(req)
 	if err != nil {
 		return nil, serr.Wrap(err, "failed to make token request")
 	}
@@ -230,30 +240,16 @@ func exchangeCodeForTokens(code, verifier string) (*TokenResponse, error) {
 	return &tokens, nil
 }
 
-// AnthropicRefreshHandler refreshes the access token
+// AnthropicRefreshHandler refreshes the access token of the account named by the account
+// query parameter, or of the default account
 func AnthropicRefreshHandler(c rweb.Context) error {
-	// Get current tokens from storage
-	storedTokens, err := storage.GetAnthropicTokens()
-	if err != nil {
-		logger.LogErr(err, "failed to get tokens")
-		return c.WriteJSON(map[string]string{"error": "no tokens found"})
-	}
-
-	tokens := &storedTokens.TokenResponse
+	params, _ := url.ParseQuery(c.Request().Query())
 
-	// Refresh the token
-	newTokens, err := refreshToken(tokens.RefreshToken)
-	if err != nil {
+	if _, err := refreshAccount(params.Get("account"), 0); err != nil {
 		logger.LogErr(err, "failed to refresh token")
 		return c.WriteJSON(map[string]string{"error": "failed to refresh token"})
 	}
 
-	// Update stored tokens
-	if err := storage.SaveAnthropicTokens(newTokens); err != nil {
-		logger.LogErr(err, "failed to save refreshed tokens")
-		return c.WriteJSON(map[string]string{"error": "failed to save tokens"})
-	}
-
 	return c.WriteJSON(map[string]string{"status": "token refreshed"})
 }
 
@@ -272,7 +268,7 @@ func refreshToken(refreshToken string) (*TokenResponse, error) {
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := tokenClient.Do(req)
 	if err != nil {
 		return nil, serr.Wrap(err, "failed to make refresh request")
 	}
@@ -298,29 +294,113 @@ func refreshToken(refreshToken string) (*TokenResponse, error) {
 	return &tokens, nil
 }
 
-// GetAccessToken returns a valid access token, refreshing if necessary
+// Tokens are refreshed this long before they expire when they are asked for, and a little
+// earlier by the background refresher
+const (
+	refreshBuffer      = 5 * time.Minute
+	refreshAheadBuffer = 15 * time.Minute
+)
+
+// refreshMu serializes refreshes: a refresh token works once, so a second refresh of the
+// same account must see the first's tokens
+var refreshMu sync.Mutex
+
+// refreshFailed is told when an account's refresh fails after succeeding the last time
+var refreshFailed func(account string, err error)
+
+// OnRefreshFailed sets what is told when an account's token can no longer be refreshed,
+// once per failure rather than on each retry
+func OnRefreshFailed(handler func(account string, err error)) {
+	refreshFailed = handler
+}
+
+// GetAccessToken returns a valid access token of the default account, refreshing if necessary
 func GetAccessToken() (string, error) {
-	storedTokens, err := storage.GetAnthropicTokens()
+	return GetAccountAccessToken("")
+}
+
+// GetAccountAccessToken returns a valid access token of the named account, or of the
+// default account for "", refreshing if necessary
+func GetAccountAccessToken(account string) (string, error) {
+	name, storedTokens, err := storage.GetAccount(account)
 	if err != nil {
 		return "", err
 	}
 
-	tokens := &storedTokens.TokenResponse
+	// Check if token is expired or about to expire
+	if time.Now().Add(refreshBuffer).Before(storedTokens.ExpiresAt) {
+		return storedTokens.AccessToken, nil
+	}
+	return refreshAccount(name, refreshBuffer)
+}
+
+// refreshAccount refreshes an account's token when it expires within ahead, which another
+// refresh may have seen to first, or whatever its expiry when ahead is 0. It returns the
+// access token to use: when the refresh fails, the failure is recorded for the UI and,
+// unless forced, the old token is used while it lasts.
+func refreshAccount(account string, ahead time.Duration) (string, error) {
+	refreshMu.Lock()
+	defer refreshMu.Unlock()
+
+	name, storedTokens, err := storage.GetAccount(account)
+	if err != nil {
+		return "", err
+	}
+	if ahead > 0 && time.Now().Add(ahead).Before(storedTokens.ExpiresAt) {
+		return storedTokens.AccessToken, nil
+	}
 
-	// Check if token is expired or about to expire (5 minutes buffer)
-	if time.Now().Add(5 * time.Minute).After(tokens.ExpiresAt) {
-		// Refresh the token
-		newTokens, err := refreshToken(tokens.RefreshToken)
-		if err != nil {
-			return "", serr.Wrap(err, "failed to refresh token")
+	newTokens, err := refreshToken(storedTokens.RefreshToken)
+	if err != nil {
+		if saveErr := storage.SetRefreshError(name, err.Error()); saveErr != nil {
+			logger.LogErr(saveErr, "failed to record token refresh error", "account", name)
+		}
+		if storedTokens.RefreshError == "" && refreshFailed != nil {
+			refreshFailed(name, err)
+		}
+		if ahead > 0 && time.Now().Before(storedTokens.ExpiresAt) {
+			logger.LogErr(err, "failed to refresh token, using it until it expires", "account", name)
+			return storedTokens.AccessToken, nil
 		}
+		return "", serr.Wrap(err, fmt.Sprintf("failed to refresh the token of Claude account %q - please log in to it again", name))
+	}
 
-		// Update stored tokens
-		if err := storage.SaveAnthropicTokens(newTokens); err != nil {
-			return "", serr.Wrap(err, "failed to save refreshed tokens")
+	// The refresh token is kept when a refresh doesn't rotate it
+	if newTokens.RefreshToken == "" {
+		newTokens.RefreshToken = storedTokens.RefreshToken
+	}
+	if err := storage.SaveAccount(name, newTokens); err != nil {
+		return "", serr.Wrap(err, "failed to save refreshed tokens")
+	}
+	return newTokens.AccessToken, nil
+}
+
+// RefreshExpiring refreshes the accounts whose tokens expire soon, so that requests seldom
+// wait for a refresh and failures show before a token stops working
+func RefreshExpiring() {
+	accounts, err := storage.ListAccounts()
+	if err != nil {
+		logger.LogErr(err, "failed to list accounts to refresh")
+		return
+	}
+
+	for _, account := range accounts {
+		if account.Status == "expired" || time.Now().Add(refreshAheadBuffer).Before(account.ExpiresAt) {
+			continue
+		}
+		if _, err := refreshAccount(account.Name, refreshAheadBuffer); err != nil {
+			logger.LogErr(err, "failed to refresh token", "account", account.Name)
 		}
-		tokens = newTokens
 	}
+}
+
+// ListAccounts describes the stored Claude accounts, without their tokens
+func ListAccounts() ([]AccountInfo, error) {
+	return storage.ListAccounts()
+}
 
-	return tokens.AccessToken, nil
+// HasAccount reports whether a Claude account of that name is stored
+func HasAccount(name string) bool {
+	_, _, err := storage.GetAccount(name)
+	return err == nil
 }
@@ -10,7 +10,8 @@ import (
 
 // ExchangeRequest represents the request to exchange code for tokens
 type ExchangeRequest struct {
-	Code string `json:"code"`
+	Code    string `json:"code"`
+	Account string `json:"account,omitempty"` // The account to log in, the default one when empty
 }
 
 // AnthropicExchangeHandler handles the code exchange
@@ -21,6 +22,10 @@ func AnthropicExchangeHandler(c rweb.Context) error {
 	if err := json.Unmarshal(body, &req); err != nil {
 		return c.WriteJSON(map[string]string{"error": "invalid request"})
 	}
+	account, err := accountName(req.Account)
+	if err != nil {
+		return c.WriteJSON(map[string]string{"error": err.Error()})
+	}
 
 	// The code from Anthropic contains both code and state separated by #
 	parts := strings.Split(req.Code, "#")
@@ -57,7 +62,7 @@ func AnthropicExchangeHandler(c rweb.Context) error {
 	}
 
 	// Store tokens persistently
-	if err := storage.SaveAnthropicTokens(tokens); err != nil {
+	if err := storage.SaveAccount(account, tokens); err != nil {
 		logger.LogErr(err, "failed to save tokens")
 		return c.WriteJSON(map[string]string{"error": "failed to save tokens"})
 	}
@@ -7,7 +7,7 @@ import (
 
 // LogoutHandler handles user logout by removing stored tokens
 func LogoutHandler(c rweb.Context) error {
-	// Remove Anthropic tokens from storage
+	// Remove the tokens of all accounts from storage
 	if err := storage.RemoveAnthropicTokens(); err != nil {
 		logger.LogErr(err, "failed to remove tokens during logout")
 		// Continue with logout even if token removal fails
@@ -2,8 +2,13 @@ package auth
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/rohanthewiz/serr"
@@ -34,17 +39,50 @@ func NewTokenStorage() (*TokenStorage, error) {
 	}, nil
 }
 
+// DefaultAccountName names the account logged in without a name, and the single account
+// of auth files from before accounts had names
+const DefaultAccountName = "default"
+
+// accountNamePattern is what account names may be made of
+var accountNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9 _.@-]{0,63}$`)
+
+// accountName checks the name of an account being logged in, returning it trimmed. ""
+// stands for the default account.
+func accountName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name != "" && !accountNamePattern.MatchString(name) {
+		return "", serr.New("account names are up to 64 letters, digits, spaces and _.@- characters")
+	}
+	return name, nil
+}
+
 // AuthData represents the stored authentication data
 type AuthData struct {
-	Anthropic *StoredTokens `json:"anthropic,omitempty"`
+	Anthropic      *StoredTokens            `json:"anthropic,omitempty"` // The single account of older auth files, moved to Accounts on load
+	Accounts       map[string]*StoredTokens `json:"accounts,omitempty"`  // Claude accounts by name
+	DefaultAccount string                   `json:"default_account,omitempty"`
 }
 
 // StoredTokens represents stored OAuth tokens
 type StoredTokens struct {
 	TokenResponse
-	UpdatedAt time.Time `json:"updated_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	RefreshError string    `json:"refresh_error,omitempty"` // Why the last refresh failed, until one succeeds
+}
+
+// AccountInfo describes a stored account without its tokens
+type AccountInfo struct {
+	Name         string    `json:"name"`
+	Default      bool      `json:"default"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	Status       string    `json:"status"` // "ok", "refresh_failed" while the token still works, or "expired"
+	RefreshError string    `json:"refresh_error,omitempty"`
+	UpdatedAt    time.Time `json:"updated_at"`
 }
 
+// storageMu serializes the changes to the auth file, each a load, change and save
+var storageMu sync.Mutex
+
 // Load reads tokens from persistent storage
 func (ts *TokenStorage) Load() (*AuthData, error) {
 	data, err := os.ReadFile(ts.filePath)
@@ -61,6 +99,20 @@ func (ts *TokenStorage) Load() (*AuthData, error) {
 		return nil, serr.Wrap(err, "failed to parse auth data")
 	}
 
+	// Older files hold a single account
+	if authData.Anthropic != nil {
+		if authData.Accounts == nil {
+			authData.Accounts = make(map[string]*StoredTokens)
+		}
+		if _, ok := authData.Accounts[DefaultAccountName]; !ok {
+			authData.Accounts[DefaultAccountName] = authData.Anthropic
+		}
+		if authData.DefaultAccount == "" {
+			authData.DefaultAccount = DefaultAccountName
+		}
+		authData.Anthropic = nil
+	}
+
 	return &authData, nil
 }
 
@@ -79,43 +131,163 @@ func (ts *TokenStorage) Save(authData *AuthData) error {
 	return nil
 }
 
-// GetAnthropicTokens retrieves Anthropic tokens from storage
-func (ts *TokenStorage) GetAnthropicTokens() (*StoredTokens, error) {
+// update loads the auth data, lets change modify it and saves it
+func (ts *TokenStorage) update(change func(authData *AuthData) error) error {
+	storageMu.Lock()
+	defer storageMu.Unlock()
+
 	authData, err := ts.Load()
 	if err != nil {
-		return nil, err
+		return err
 	}
-
-	if authData.Anthropic == nil {
-		return nil, serr.New("no Anthropic tokens found - please authenticate first")
+	if err := change(authData); err != nil {
+		return err
 	}
-
-	return authData.Anthropic, nil
+	return ts.Save(authData)
 }
 
-// SaveAnthropicTokens saves Anthropic tokens to storage
-func (ts *TokenStorage) SaveAnthropicTokens(tokens *TokenResponse) error {
+// GetAccount retrieves an account's tokens and its name, the default account's for ""
+func (ts *TokenStorage) GetAccount(name string) (string, *StoredTokens, error) {
 	authData, err := ts.Load()
 	if err != nil {
-		return err
+		return "", nil, err
 	}
 
-	authData.Anthropic = &StoredTokens{
-		TokenResponse: *tokens,
-		UpdatedAt:     time.Now(),
+	if name == "" {
+		name = authData.DefaultAccount
+	}
+	tokens := authData.Accounts[name]
+	if tokens == nil {
+		if len(authData.Accounts) == 0 {
+			return "", nil, serr.New("no Anthropic tokens found - please authenticate first")
+		}
+		return "", nil, serr.New(fmt.Sprintf("no Claude account named %q", name))
 	}
 
-	return ts.Save(authData)
+	return name, tokens, nil
 }
 
-// RemoveAnthropicTokens removes Anthropic tokens from storage
+// GetAnthropicTokens retrieves the default account's tokens from storage
+func (ts *TokenStorage) GetAnthropicTokens() (*StoredTokens, error) {
+	_, tokens, err := ts.GetAccount("")
+	return tokens, err
+}
+
+// SaveAccount saves an account's tokens, clearing its refresh error. The first account
+// saved becomes the default.
+func (ts *TokenStorage) SaveAccount(name string, tokens *TokenResponse) error {
+	return ts.update(func(authData *AuthData) error {
+		if name == "" {
+			name = authData.DefaultAccount
+		}
+		if name == "" {
+			name = DefaultAccountName
+		}
+		if authData.Accounts == nil {
+			authData.Accounts = make(map[string]*StoredTokens)
+		}
+
+		authData.Accounts[name] = &StoredTokens{
+			TokenResponse: *tokens,
+			UpdatedAt:     time.Now(),
+		}
+		if authData.Accounts[authData.DefaultAccount] == nil {
+			authData.DefaultAccount = name
+		}
+		return nil
+	})
+}
+
+// SaveAnthropicTokens saves the default account's tokens to storage
+func (ts *TokenStorage) SaveAnthropicTokens(tokens *TokenResponse) error {
+	return ts.SaveAccount("", tokens)
+}
+
+// SetRefreshError records why refreshing an account's token failed
+func (ts *TokenStorage) SetRefreshError(name, message string) error {
+	return ts.update(func(authData *AuthData) error {
+		if tokens := authData.Accounts[name]; tokens != nil {
+			tokens.RefreshError = message
+		}
+		return nil
+	})
+}
+
+// SetDefaultAccount makes an account the one used by sessions without their own
+func (ts *TokenStorage) SetDefaultAccount(name string) error {
+	return ts.update(func(authData *AuthData) error {
+		if authData.Accounts[name] == nil {
+			return serr.New(fmt.Sprintf("no Claude account named %q", name))
+		}
+		authData.DefaultAccount = name
+		return nil
+	})
+}
+
+// RemoveAccount removes an account's tokens. When it was the default, the first remaining
+// account by name becomes the default.
+func (ts *TokenStorage) RemoveAccount(name string) error {
+	return ts.update(func(authData *AuthData) error {
+		if authData.Accounts[name] == nil {
+			return serr.New(fmt.Sprintf("no Claude account named %q", name))
+		}
+		delete(authData.Accounts, name)
+
+		if authData.DefaultAccount == name {
+			authData.DefaultAccount = ""
+			if names := sortedAccountNames(authData); len(names) > 0 {
+				authData.DefaultAccount = names[0]
+			}
+		}
+		return nil
+	})
+}
+
+// RemoveAnthropicTokens removes all accounts' tokens from storage
 func (ts *TokenStorage) RemoveAnthropicTokens() error {
+	return ts.update(func(authData *AuthData) error {
+		authData.Accounts = nil
+		authData.DefaultAccount = ""
+		return nil
+	})
+}
+
+// ListAccounts describes the stored accounts, by name
+func (ts *TokenStorage) ListAccounts() ([]AccountInfo, error) {
 	authData, err := ts.Load()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	authData.Anthropic = nil
+	accounts := make([]AccountInfo, 0, len(authData.Accounts))
+	for _, name := range sortedAccountNames(authData) {
+		tokens := authData.Accounts[name]
+		status := "ok"
+		switch {
+		case time.Now().After(tokens.ExpiresAt) && (tokens.RefreshError != "" || tokens.RefreshToken == ""):
+			status = "expired"
+		case tokens.RefreshError != "":
+			status = "refresh_failed"
+		}
 
-	return ts.Save(authData)
+		accounts = append(accounts, AccountInfo{
+			Name:         name,
+			Default:      name == authData.DefaultAccount,
+			ExpiresAt:    tokens.ExpiresAt,
+			Status:       status,
+			RefreshError: tokens.RefreshError,
+			UpdatedAt:    tokens.UpdatedAt,
+		})
+	}
+	return accounts, nil
+}
+
+// sortedAccountNames returns the names of the stored accounts, sorted
+func sortedAccountNames(authData *AuthData) []string {
+	names := make([]string, 0, len(authData.Accounts))
+	for name := range authData.Accounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
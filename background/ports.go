@@ -0,0 +1,115 @@
+package background
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	portScanFast     = time.Second     // How often ports are checked while a process starts up
+	portScanSlow     = 5 * time.Second // How often they are checked afterwards
+	portScanFastSpan = 30 * time.Second
+)
+
+// Local addresses dev servers print when they are ready, e.g. "http://localhost:5173/"
+// or "Listening on 0.0.0.0:8080", and "port 3000" phrasing
+var (
+	localAddrPattern  = regexp.MustCompile(`(?i)(?:localhost|127\.0\.0\.1|0\.0\.0\.0|\[::1?\]):(\d{2,5})\b`)
+	portPhrasePattern = regexp.MustCompile(`(?i)\bport\s+:?(\d{2,5})\b`)
+)
+
+// PreviewPath is the path on the rcode server that proxies to a process's web server
+func PreviewPath(id int) string {
+	return fmt.Sprintf("/preview/%d/", id)
+}
+
+// detectPorts returns the TCP ports a process listens on. Where the process's sockets
+// can't be inspected, ports mentioned in its output that accept connections are used.
+func detectPorts(pid int, output *logBuffer) []int {
+	ports := groupListeningPorts(pid)
+	if len(ports) == 0 {
+		lines, _ := output.tail(200)
+		for _, port := range mentionedPorts(lines) {
+			if portOpen(port) {
+				ports = append(ports, port)
+			}
+		}
+	}
+	slices.Sort(ports)
+	return slices.Compact(ports)
+}
+
+// mentionedPorts returns the local ports mentioned in output lines
+func mentionedPorts(lines []string) []int {
+	var ports []int
+	for _, line := range lines {
+		for _, pattern := range []*regexp.Regexp{localAddrPattern, portPhrasePattern} {
+			for _, m := range pattern.FindAllStringSubmatch(line, -1) {
+				if port, err := strconv.Atoi(m[1]); err == nil && port > 0 && port < 65536 {
+					ports = append(ports, port)
+				}
+			}
+		}
+	}
+	return ports
+}
+
+// portOpen reports whether something accepts connections on the local port
+func portOpen(port int) bool {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort("localhost", strconv.Itoa(port)), 300*time.Millisecond)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// watchPorts keeps a process's ports up to date until it exits
+func (s *Supervisor) watchPorts(p *process) {
+	started := time.Now()
+	for {
+		interval := portScanSlow
+		if time.Since(started) < portScanFastSpan {
+			interval = portScanFast
+		}
+
+		select {
+		case <-p.done:
+			return
+		case <-time.After(interval):
+		}
+
+		ports := detectPorts(p.PID, p.output)
+
+		s.mu.Lock()
+		if p.Status != StatusRunning || slices.Equal(ports, p.Ports) {
+			s.mu.Unlock()
+			continue
+		}
+		p.Ports = ports
+		onPorts := s.onPorts
+		state := p.Process
+		s.mu.Unlock()
+
+		if onPorts != nil {
+			onPorts(state)
+		}
+	}
+}
+
+// WaitForPort waits up to d for a process to listen on a port, returning its ports.
+// It returns early if the process exits.
+func (s *Supervisor) WaitForPort(id int, d time.Duration) []int {
+	deadline := time.Now().Add(d)
+	for {
+		proc, err := s.Get(id)
+		if err != nil || len(proc.Ports) > 0 || proc.Status != StatusRunning || time.Now().After(deadline) {
+			return proc.Ports
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
@@ -0,0 +1,100 @@
+//go:build linux
+
+package background
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// groupListeningPorts returns the TCP ports listened on by the processes in the
+// process group led by pid, found through /proc
+func groupListeningPorts(pid int) []int {
+	inodes := make(map[string]bool)
+
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil {
+			continue
+		}
+		if processGroup(entry.Name()) != pid {
+			continue
+		}
+		fds, err := os.ReadDir(filepath.Join("/proc", entry.Name(), "fd"))
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join("/proc", entry.Name(), "fd", fd.Name()))
+			if err == nil && strings.HasPrefix(link, "socket:[") {
+				inodes[strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]")] = true
+			}
+		}
+	}
+	if len(inodes) == 0 {
+		return nil
+	}
+
+	var ports []int
+	for _, table := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		ports = append(ports, listeningSocketPorts(table, inodes)...)
+	}
+	return ports
+}
+
+// processGroup returns the process group of a /proc entry, or 0 if it can't be read
+func processGroup(pid string) int {
+	data, err := os.ReadFile(filepath.Join("/proc", pid, "stat"))
+	if err != nil {
+		return 0
+	}
+	// The command name is in parentheses and may contain spaces, so fields are counted after it
+	stat := string(data)
+	end := strings.LastIndexByte(stat, ')')
+	if end < 0 {
+		return 0
+	}
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 3 {
+		return 0
+	}
+	pgid, _ := strconv.Atoi(fields[2])
+	return pgid
+}
+
+// listeningSocketPorts returns the local ports of the listening sockets in a
+// /proc/net table whose inodes are in inodes
+func listeningSocketPorts(table string, inodes map[string]bool) []int {
+	f, err := os.Open(table)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	const stateListen = "0A"
+
+	var ports []int
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // Header
+	for scanner.Scan() {
+		// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || fields[3] != stateListen || !inodes[fields[9]] {
+			continue
+		}
+		_, hexPort, ok := strings.Cut(fields[1], ":")
+		if !ok {
+			continue
+		}
+		if port, err := strconv.ParseInt(hexPort, 16, 32); err == nil {
+			ports = append(ports, int(port))
+		}
+	}
+	return ports
+}
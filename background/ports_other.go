@@ -0,0 +1,8 @@
+//go:build !linux
+
+package background
+
+// groupListeningPorts is not available without /proc; ports are found from the process's output instead
+func groupListeningPorts(pid int) []int {
+	return nil
+}
@@ -0,0 +1,27 @@
+//go:build !windows
+
+package background
+
+import (
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// configureProcessGroup runs cmd in its own process group, so that stopping it
+// also stops the servers and watchers it started
+func configureProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	// Don't wait forever on output pipes held open by orphaned descendants
+	cmd.WaitDelay = 2 * time.Second
+}
+
+// terminateGroup asks the process group to exit
+func terminateGroup(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+}
+
+// killGroup kills the process group
+func killGroup(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,24 @@
+//go:build windows
+
+package background
+
+import (
+	"os/exec"
+	"time"
+)
+
+// configureProcessGroup limits how long an ended command may hold its output pipes.
+// Windows has no process groups to signal, so only the command itself is stopped.
+func configureProcessGroup(cmd *exec.Cmd) {
+	cmd.WaitDelay = 2 * time.Second
+}
+
+// terminateGroup ends the process; Windows has no signal asking it to exit
+func terminateGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
+
+// killGroup kills the process
+func killGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
@@ -0,0 +1,373 @@
+package background
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/serr"
+)
+
+const (
+	maxRunning   = 10   // Processes that may run at once
+	maxExited    = 20   // Exited processes kept so their logs can still be read
+	maxLogLines  = 2000 // Lines of output kept per process
+	maxLineBytes = 4096 // Longer lines are cut
+	stopGrace    = 5 * time.Second
+)
+
+// Process states
+const (
+	StatusRunning = "running"
+	StatusExited  = "exited"  // Exited by itself
+	StatusStopped = "stopped" // Stopped by stop_process or shutdown
+)
+
+// Process is the state of a long-running command started by the supervisor
+type Process struct {
+	ID        int        `json:"id"`
+	Name      string     `json:"name"`
+	Command   string     `json:"command"`
+	Dir       string     `json:"dir"`
+	SessionID string     `json:"session_id,omitempty"`
+	PID       int        `json:"pid"`
+	StartedAt time.Time  `json:"started_at"`
+	Status    string     `json:"status"`
+	ExitCode  int        `json:"exit_code"`
+	ExitedAt  *time.Time `json:"exited_at,omitempty"`
+	Ports     []int      `json:"ports,omitempty"` // TCP ports the process listens on
+}
+
+// process is a supervised command. Its Process fields are guarded by the supervisor's lock.
+type process struct {
+	Process
+
+	cmd      *exec.Cmd
+	done     chan struct{}
+	stopping bool
+	output   *logBuffer
+}
+
+// logBuffer keeps the most recent lines of a process's output
+type logBuffer struct {
+	mu      sync.Mutex
+	lines   []string // Complete lines, oldest first
+	partial []byte   // Output after the last newline
+	total   int      // Lines ever written
+}
+
+// Supervisor starts, tracks and stops background processes
+type Supervisor struct {
+	mu        sync.Mutex
+	processes map[int]*process
+	nextID    int
+	onExit    func(p Process)
+	onPorts   func(p Process)
+}
+
+// NewSupervisor creates an empty supervisor
+func NewSupervisor() *Supervisor {
+	return &Supervisor{processes: make(map[int]*process), nextID: 1}
+}
+
+// OnExit sets a function called after any process exits
+func (s *Supervisor) OnExit(fn func(p Process)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onExit = fn
+}
+
+// OnPorts sets a function called when a running process's listening ports change
+func (s *Supervisor) OnPorts(fn func(p Process)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onPorts = fn
+}
+
+// Start runs command with bash in dir, capturing its combined output.
+// An empty name defaults to the command's first word.
+func (s *Supervisor) Start(sessionID, name, command, dir string) (Process, error) {
+	command = strings.TrimSpace(command)
+	if command == "" {
+		return Process{}, serr.New("command is required")
+	}
+	if name == "" {
+		name = strings.Fields(command)[0]
+	}
+	if dir == "" {
+		if wd, err := os.Getwd(); err == nil {
+			dir = wd
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	running := 0
+	for _, p := range s.processes {
+		if p.Status == StatusRunning {
+			running++
+		}
+	}
+	if running >= maxRunning {
+		return Process{}, serr.New(fmt.Sprintf("%d background processes are already running; stop one first", running))
+	}
+
+	p := &process{
+		Process: Process{
+			ID:        s.nextID,
+			Name:      name,
+			Command:   command,
+			Dir:       dir,
+			SessionID: sessionID,
+			Status:    StatusRunning,
+		},
+		done:   make(chan struct{}),
+		output: &logBuffer{},
+	}
+
+	cmd := exec.Command("bash", "-c", command)
+	cmd.Dir = dir
+	cmd.Stdout = p.output
+	cmd.Stderr = p.output
+	configureProcessGroup(cmd)
+
+	if err := cmd.Start(); err != nil {
+		return Process{}, serr.Wrap(err, "failed to start process")
+	}
+
+	s.nextID++
+	p.cmd = cmd
+	p.PID = cmd.Process.Pid
+	p.StartedAt = time.Now()
+	s.processes[p.ID] = p
+	s.pruneExited()
+
+	go s.wait(p)
+	go s.watchPorts(p)
+
+	logger.Info("Started background process", "process_id", p.ID, "session_id", sessionID, "name", name, "pid", p.PID)
+	return p.Process, nil
+}
+
+// wait records the process's exit
+func (s *Supervisor) wait(p *process) {
+	err := p.cmd.Wait()
+	p.output.flush()
+
+	now := time.Now()
+	s.mu.Lock()
+	p.ExitedAt = &now
+	p.ExitCode = p.cmd.ProcessState.ExitCode()
+	p.Ports = nil
+	if p.stopping {
+		p.Status = StatusStopped
+	} else {
+		p.Status = StatusExited
+	}
+	onExit := s.onExit
+	state := p.Process
+	s.mu.Unlock()
+
+	close(p.done)
+	logger.Info("Background process exited", "process_id", state.ID, "session_id", state.SessionID, "name", state.Name, "status", state.Status, "exit_code", state.ExitCode)
+	if err != nil && state.Status == StatusExited {
+		logger.LogErr(err, "background process failed", "process_id", state.ID)
+	}
+	if onExit != nil {
+		onExit(state)
+	}
+}
+
+// pruneExited drops the oldest exited processes beyond maxExited. The caller holds s.mu.
+func (s *Supervisor) pruneExited() {
+	var exited []*process
+	for _, p := range s.processes {
+		if p.Status != StatusRunning {
+			exited = append(exited, p)
+		}
+	}
+	if len(exited) <= maxExited {
+		return
+	}
+	sort.Slice(exited, func(i, j int) bool { return exited[i].ID < exited[j].ID })
+	for _, p := range exited[:len(exited)-maxExited] {
+		delete(s.processes, p.ID)
+	}
+}
+
+// Get returns a copy of a process's current state
+func (s *Supervisor) Get(id int) (Process, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.processes[id]
+	if !ok {
+		return Process{}, serr.New(fmt.Sprintf("no background process with id %d", id))
+	}
+	return p.Process, nil
+}
+
+// List returns copies of all processes, oldest first
+func (s *Supervisor) List() []Process {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]Process, 0, len(s.processes))
+	for _, p := range s.processes {
+		list = append(list, p.Process)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// Logs returns up to the last n lines of a process's output, and how many lines it has written in all
+func (s *Supervisor) Logs(id, n int) ([]string, int, error) {
+	s.mu.Lock()
+	p, ok := s.processes[id]
+	s.mu.Unlock()
+	if !ok {
+		return nil, 0, serr.New(fmt.Sprintf("no background process with id %d", id))
+	}
+	lines, total := p.output.tail(n)
+	return lines, total, nil
+}
+
+// Wait waits up to d for a process to exit, reporting whether it did
+func (s *Supervisor) Wait(id int, d time.Duration) bool {
+	s.mu.Lock()
+	p, ok := s.processes[id]
+	s.mu.Unlock()
+	if !ok {
+		return true
+	}
+	select {
+	case <-p.done:
+		return true
+	case <-time.After(d):
+		return false
+	}
+}
+
+// Stop ends a process and everything it started, asking politely first
+// and killing it if it is still running after a grace period
+func (s *Supervisor) Stop(id int) (Process, error) {
+	s.mu.Lock()
+	p, ok := s.processes[id]
+	if !ok {
+		s.mu.Unlock()
+		return Process{}, serr.New(fmt.Sprintf("no background process with id %d", id))
+	}
+	if p.Status != StatusRunning {
+		state := p.Process
+		s.mu.Unlock()
+		return state, nil
+	}
+	p.stopping = true
+	s.mu.Unlock()
+
+	if err := terminateGroup(p.cmd); err != nil {
+		logger.LogErr(err, "failed to signal background process", "process_id", id)
+	}
+
+	select {
+	case <-p.done:
+	case <-time.After(stopGrace):
+		if err := killGroup(p.cmd); err != nil {
+			return Process{}, serr.Wrap(err, fmt.Sprintf("failed to kill background process %d", id))
+		}
+		<-p.done
+	}
+	// Children that outlived the command would keep ports and files open
+	_ = killGroup(p.cmd)
+
+	return s.Get(id)
+}
+
+// StopAll stops every running process, for server shutdown
+func (s *Supervisor) StopAll() {
+	var wg sync.WaitGroup
+	for _, p := range s.List() {
+		if p.Status != StatusRunning {
+			continue
+		}
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			if _, err := s.Stop(id); err != nil {
+				logger.LogErr(err, "failed to stop background process", "process_id", id)
+			}
+		}(p.ID)
+	}
+	wg.Wait()
+}
+
+// Color and cursor control sequences, which dev servers often print
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]`)
+
+// Write collects output into lines, so the buffer can be a command's stdout and stderr
+func (b *logBuffer) Write(data []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.partial = append(b.partial, data...)
+	for {
+		i := bytes.IndexByte(b.partial, '\n')
+		if i < 0 {
+			break
+		}
+		b.addLine(string(b.partial[:i]))
+		b.partial = b.partial[i+1:]
+	}
+	if len(b.partial) > maxLineBytes {
+		b.addLine(string(b.partial))
+		b.partial = nil
+	}
+	return len(data), nil
+}
+
+// flush keeps output left without a trailing newline
+func (b *logBuffer) flush() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.partial) > 0 {
+		b.addLine(string(b.partial))
+		b.partial = nil
+	}
+}
+
+// addLine appends a cleaned-up line, dropping the oldest beyond maxLogLines. The caller holds b.mu.
+func (b *logBuffer) addLine(line string) {
+	line = ansiPattern.ReplaceAllString(strings.TrimRight(line, "\r"), "")
+	if len(line) > maxLineBytes {
+		line = line[:maxLineBytes] + "..."
+	}
+	b.lines = append(b.lines, line)
+	// Trim in batches rather than copying the buffer for every line
+	if len(b.lines) > maxLogLines+maxLogLines/4 {
+		b.lines = append([]string(nil), b.lines[len(b.lines)-maxLogLines:]...)
+	}
+	b.total++
+}
+
+// tail returns up to the last n lines and the number of lines ever written
+func (b *logBuffer) tail(n int) ([]string, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	lines := b.lines
+	if n <= 0 || n > maxLogLines {
+		n = maxLogLines
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return append([]string(nil), lines...), b.total
+}
@@ -0,0 +1,122 @@
+// Package client is a Go client for the API of an rcode server. The types and methods in
+// api.gen.go are generated from the server's routes, along with openapi.yaml; run
+// go generate ./client after changing the routes in web/openapi.go.
+package client
+
+//go:generate go run gen.go
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// Client calls the API of an rcode server
+type Client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// New creates a client for the server at baseURL, e.g. http://localhost:8000. The token
+// is an API token from /api/tokens; it is required in multi-user mode.
+func New(baseURL, token string) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
+		http:    &http.Client{}, // No timeout, as a message runs until the model is done
+	}
+}
+
+// BaseURL returns the URL of the server
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// StatusError is an error reply from the server
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s (%d)", e.Message, e.StatusCode)
+}
+
+// do sends a request with an optional JSON body, and decodes the JSON reply into out if it isn't nil
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return serr.Wrap(err, "failed to marshal request")
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	req, err := c.newRequest(ctx, method, path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return serr.Wrap(err, "request failed", "path", path)
+	}
+	defer resp.Body.Close()
+	// SendMessage answers a queued message with 202 Accepted
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return responseError(resp)
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return serr.Wrap(err, "failed to decode response", "path", path)
+	}
+	return nil
+}
+
+func (c *Client) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to create request")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return req, nil
+}
+
+// responseError turns an error reply into a StatusError, with the server's message
+func responseError(resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	text := strings.TrimSpace(string(msg))
+	if text == "" {
+		text = resp.Status
+	}
+	return &StatusError{StatusCode: resp.StatusCode, Message: text}
+}
+
+// pathEscape escapes a path parameter. Slashes are kept, for file paths.
+func pathEscape(p string) string {
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
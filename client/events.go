@@ -0,0 +1,54 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// Event is an event from the server's SSE stream, e.g. message_delta or permission_request
+type Event struct {
+	Type      string          `json:"type"`
+	SessionID string          `json:"sessionId"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// StreamEvents calls onEvent with the server's events until ctx is done or the stream ends.
+// The stream isn't part of the OpenAPI spec, so this is written by hand.
+func (c *Client) StreamEvents(ctx context.Context, onEvent func(Event)) error {
+	req, err := c.newRequest(ctx, http.MethodGet, "/events", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return serr.Wrap(err, "failed to connect to the event stream")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024) // Events can carry whole diffs
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var event Event
+		if err := json.Unmarshal([]byte(data), &event); err == nil {
+			onEvent(event)
+		}
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return serr.Wrap(err, "event stream failed")
+	}
+	return serr.New("event stream closed")
+}
@@ -0,0 +1,38 @@
+//go:build ignore
+
+// gen.go writes api.gen.go and ../openapi.yaml from the server's documented routes
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+
+	"rcode/openapi"
+	"rcode/web"
+
+	"gopkg.in/yaml.v3"
+)
+
+func main() {
+	spec := web.OpenAPI()
+
+	src, err := openapi.GenerateClient(spec, "client", "client/gen.go")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("api.gen.go", src, 0644); err != nil {
+		log.Fatal(err)
+	}
+
+	var doc bytes.Buffer
+	doc.WriteString("# Generated by client/gen.go from the routes in web/openapi.go; DO NOT EDIT.\n")
+	enc := yaml.NewEncoder(&doc)
+	enc.SetIndent(2)
+	if err := enc.Encode(spec); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("../openapi.yaml", doc.Bytes(), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
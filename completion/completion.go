@@ -0,0 +1,21 @@
+// Package completion is what the packages that put a single prompt to a language model
+// share: planning, code review, commit messages and test generation. The web layer, which
+// owns the model client, supplies the Client.
+package completion
+
+import "strings"
+
+// Client sends a single prompt to a language model and returns its text reply
+type Client interface {
+	Complete(prompt string) (string, error)
+}
+
+// ExtractJSONObject returns the outermost JSON object in s, ignoring surrounding prose or code fences
+func ExtractJSONObject(s string) string {
+	start := strings.Index(s, "{")
+	end := strings.LastIndex(s, "}")
+	if start < 0 || end < start {
+		return ""
+	}
+	return s[start : end+1]
+}
@@ -0,0 +1,24 @@
+package completion
+
+import "testing"
+
+func TestExtractJSONObject(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply string
+		want  string
+	}{
+		{name: "bare", reply: `{"a": 1}`, want: `{"a": 1}`},
+		{name: "code fence", reply: "```json\n{\"a\": {\"b\": 2}}\n```", want: `{"a": {"b": 2}}`},
+		{name: "prose around", reply: `Here it is: {"a": 1}. Anything else?`, want: `{"a": 1}`},
+		{name: "no object", reply: "I can't do that", want: ""},
+		{name: "braces reversed", reply: "} {", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtractJSONObject(tt.reply); got != tt.want {
+				t.Errorf("ExtractJSONObject(%q) = %q, want %q", tt.reply, got, tt.want)
+			}
+		})
+	}
+}
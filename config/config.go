@@ -1,9 +1,13 @@
 package config
 
 import (
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 )
 
 const (
@@ -11,26 +15,126 @@ const (
 	defaultAnthropicAPIURL = "https://api.anthropic.com/v1/messages"
 )
 
-// Config holds application configuration
+// Config holds application configuration. Its JSON names are the settings' names in
+// config files, except that the API URL is set with msg_proxy and diagnostics_wait
+// is given in seconds.
 type Config struct {
-	AnthropicAPIURL string
+	AnthropicAPIURL string `json:"anthropic_api_url"`
 	// TLS configuration
-	TLSEnabled  bool
-	TLSPort     string
-	TLSCertFile string
-	TLSKeyFile  string
+	TLSEnabled  bool   `json:"tls_enabled"`
+	TLSPort     string `json:"tls_port"`
+	TLSCertFile string `json:"tls_cert"`
+	TLSKeyFile  string `json:"tls_key"`
 	// Custom tool configuration
-	CustomToolsEnabled bool
-	CustomToolsPaths   []string // Directories to search for custom tools
-	CustomToolsConfig  string   // Path to custom tools config file
+	CustomToolsEnabled bool     `json:"custom_tools_enabled"`
+	CustomToolsPaths   []string `json:"custom_tools_paths"`  // Directories to search for custom tools
+	CustomToolsConfig  string   `json:"custom_tools_config"` // Path to custom tools config file
+	// Task planning configuration
+	PlanAutoResume bool `json:"plan_auto_resume"` // Resume plans interrupted by a restart instead of leaving them paused
+	// Semantic search configuration
+	EmbeddingsProvider string `json:"embeddings_provider"` // "api" for a model, "hash" for word hashing, or "" (default) for none
+	EmbeddingsURL      string `json:"embeddings_url"`      // OpenAI-compatible embeddings endpoint, used by the "api" provider
+	EmbeddingsModel    string `json:"embeddings_model"`    // Model name sent to the embeddings endpoint
+	EmbeddingsAPIKey   string `json:"embeddings_api_key"`  // Bearer token for the embeddings endpoint, if it needs one
+	// Project scan configuration
+	ScanMaxFiles    int   `json:"scan_max_files"`     // Files included in the project context before the scan stops; 0 for no limit
+	ScanMaxFileSize int64 `json:"scan_max_file_size"` // Code files larger than this many bytes are not read; 0 for no limit
+	// Context packing configuration
+	ContextTokenBudget int `json:"context_token_budget"` // Tokens of relevant project files sent with each session; 0 disables packing
+	// Tool hooks configuration
+	HooksConfig string `json:"hooks_config"` // Path to the user's hooks file; projects add their own in .rcode/hooks.json
+	// Parallel tools configuration
+	ParallelTools int `json:"parallel_tools"` // Read-only tool calls of one reply run at once; 1 runs every call in turn
+	// Tool loop configuration
+	MaxToolRounds   int `json:"max_tool_rounds"`   // Rounds of tool calls a turn makes before it stops; 0 for no limit
+	ToolRepeatLimit int `json:"tool_repeat_limit"` // Rounds in a row making the same calls that stop a turn; 0 never stops
+	// Redaction configuration
+	Redact bool `json:"redact"` // Mask secrets in requests to the model, tool results and stored messages
+	// Sensitive path configuration
+	SensitivePaths []string `json:"sensitive_paths"` // Globs of files read_file, the search tools, mentions and the explorer won't read
+	SensitiveAllow []string `json:"sensitive_allow"` // Globs of files read as usual though SensitivePaths match them
+	// Auto-accept mode configuration
+	AutoMaxFiles int   `json:"auto_max_files"` // Files a turn may change in auto-accept mode; 0 for no limit
+	AutoMaxBytes int64 `json:"auto_max_bytes"` // Bytes a turn may write in auto-accept mode; 0 for no limit
+	// Tool result configuration
+	ToolResultMax int `json:"tool_result_max"` // Bytes of a tool result sent to the model; longer results are truncated and kept whole as an artifact
+	// Artifact configuration
+	MaxArtifactSize   int64 `json:"max_artifact_size"`       // Largest file, in bytes, publish_artifact stores
+	ArtifactRetention int   `json:"artifact_retention_days"` // Days artifacts are kept; 0 keeps them as long as their session
+	// Tool limits configuration
+	ToolLimitsConfig string `json:"tool_limits_config"` // Path to the user's tool limits file; projects add their own in .rcode/tool_limits.json
+	// HTTP tools configuration
+	HTTPToolsConfig string `json:"http_tools_config"` // Path to the user's HTTP tools file; projects add their own in .rcode/http_tools.json
+	// Database connections configuration
+	DatabasesConfig string `json:"databases_config"` // Path to the user's database connections file, for db_query; projects add their own in .rcode/databases.json
+	// Scaffolding configuration
+	TemplatesDir string `json:"templates_dir"` // Directory of the user's templates, for generate; projects add their own in .rcode/templates
+	// Format-on-write configuration
+	FormatOnWrite bool `json:"format_on_write"` // Run the language's formatter on files after the write tools change them
+	LintOnWrite   bool `json:"lint_on_write"`   // Also run the language's linter and report its problems
+	// Diagnostics configuration
+	DiagnosticsEnabled bool          `json:"diagnostics"`      // Build or lint the project after tools change files and report errors to the model
+	DiagnosticsWait    time.Duration `json:"diagnostics_wait"` // How long to wait for the checks before the model's next turn
+	// File transfer configuration
+	MaxUploadSize   int64 `json:"max_upload_size"`   // Largest upload request, in bytes, accepted by the file explorer
+	MaxDownloadSize int64 `json:"max_download_size"` // Largest total of file bytes zipped for a directory download
+	// Forge (GitHub/GitLab) configuration
+	GitHubToken  string `json:"github_token"`   // Token for the GitHub API, used by the pull request and issue tools
+	GitHubAPIURL string `json:"github_api_url"` // GitHub API root; set for GitHub Enterprise
+	GitLabToken  string `json:"gitlab_token"`   // Token for the GitLab API
+	GitLabURL    string `json:"gitlab_url"`     // GitLab instance; set for self-hosted GitLab
+	// Multi-user configuration
+	MultiUser  bool `json:"multi_user"`  // Require local accounts and keep each user's sessions private; read at startup
+	LoginHours int  `json:"login_hours"` // How long a sign-in lasts
+	// Messages proxy configuration
+	ProxyDailyTokens int `json:"proxy_daily_tokens"` // Tokens requests through /v1/messages may use each day; 0 for no limit
+	// Request scheduling configuration
+	RequestConcurrency int `json:"request_concurrency"` // Model requests in flight at once, across sessions; read at startup
+	// Model fallback configuration
+	ModelFallbacks []string `json:"model_fallbacks"` // Models, by alias or ID, each falling back to the next when the API is overloaded
+	// Model backend configuration
+	ModelBackends map[string]string `json:"model_backends"` // Where each model's requests go, by model ID, part of one or *: anthropic, bedrock or vertex, with :id for the backend's own model ID
+	BedrockRegion string            `json:"bedrock_region"` // AWS region of the Bedrock backend
+	VertexProject string            `json:"vertex_project"` // Google Cloud project of the Vertex AI backend
+	VertexRegion  string            `json:"vertex_region"`  // Google Cloud region of the Vertex AI backend, or global
+	LocalURL      string            `json:"local_url"`      // OpenAI-compatible API of the local model server, Ollama's or llama.cpp's
+	LocalModels   []string          `json:"local_models"`   // Models of the local server, offered in the model selector
+	// Context window configuration
+	ContextWindows map[string]int `json:"context_windows"` // Context windows in tokens, by model ID, part of one or *, for models whose default is wrong
+	// Cost estimation configuration
+	CostEstimate string  `json:"cost_estimate"` // How a turn's input tokens are estimated before it is sent: local, api (count_tokens) or off
+	ConfirmCost  float64 `json:"confirm_cost"`  // Dollars of input above which a turn waits for the user to confirm it; 0 never asks
+	DailyBudget  float64 `json:"daily_budget"`  // Dollars a day of model usage; crossing half, 80% and all of it notifies; 0 for none
+	// Notification configuration
+	NotifyWebhook string   `json:"notify_webhook"` // URL notifications are POSTed to as JSON
+	NotifySlack   string   `json:"notify_slack"`   // Slack incoming webhook URL notifications are posted to
+	NotifyEvents  []string `json:"notify_events"`  // Events that notify: plan_completed, plan_failed, permission_request, budget
+	// Tool audit log configuration
+	AuditRetentionDays int `json:"audit_retention_days"` // Days tool calls are kept in the audit log; 0 keeps them
+	AuditOutputDays    int `json:"audit_output_days"`    // Days the tools' output is kept with them; 0 keeps it as long as the call
+	// Tracing configuration
+	TracingEndpoint string `json:"tracing_endpoint"` // OTLP/HTTP traces endpoint spans are exported to; empty turns tracing off; read at startup
+	// Database configuration
+	DBBackend string `json:"db_backend"` // Database engine the data is kept in, duckdb or postgres; read at startup
+	DBURL     string `json:"db_url"`     // Where the database is: for duckdb the file, empty for the default, for postgres a postgres:// URL; read at startup
+	// Backup configuration
+	BackupDir   string `json:"backup_dir"`   // Where backups are kept; empty for backups/ beside the database
+	BackupHours int    `json:"backup_hours"` // Hours between scheduled backups; 0 turns them off
+	BackupKeep  int    `json:"backup_keep"`  // Backups kept, the oldest being deleted after each new one
 }
 
-// globalConfig holds the application configuration instance
-var globalConfig *Config
+// globalConfig holds the application configuration instance. It is replaced,
+// not changed, when the config files change.
+var globalConfig atomic.Pointer[Config]
 
-// Initialize sets up the configuration from environment variables
+// Initialize sets up the configuration from environment variables and config files
 func Initialize() {
-	globalConfig = &Config{
+	globalConfig.Store(load())
+}
+
+// newConfig creates a config from the current settings. The caller holds loadMu.
+func newConfig() *Config {
+	return &Config{
 		AnthropicAPIURL:    getAnthropicAPIURL(),
 		TLSEnabled:         getTLSEnabled(),
 		TLSPort:            getTLSPort(),
@@ -39,21 +143,113 @@ func Initialize() {
 		CustomToolsEnabled: getCustomToolsEnabled(),
 		CustomToolsPaths:   getCustomToolsPaths(),
 		CustomToolsConfig:  getCustomToolsConfig(),
+		PlanAutoResume:     getPlanAutoResume(),
+		EmbeddingsProvider: getEmbeddingsProvider(),
+		EmbeddingsURL:      getEmbeddingsURL(),
+		EmbeddingsModel:    setting("RCODE_EMBEDDINGS_MODEL"),
+		EmbeddingsAPIKey:   setting("RCODE_EMBEDDINGS_API_KEY"),
+		ScanMaxFiles:       getScanMaxFiles(),
+		ScanMaxFileSize:    getScanMaxFileSize(),
+		ContextTokenBudget: getContextTokenBudget(),
+		HooksConfig:        getHooksConfig(),
+		HTTPToolsConfig:    getHTTPToolsConfig(),
+		DatabasesConfig:    getDatabasesConfig(),
+		TemplatesDir:       getTemplatesDir(),
+		ToolLimitsConfig:   getToolLimitsConfig(),
+		ParallelTools:      getParallelTools(),
+		MaxToolRounds:      getToolLoopLimit("RCODE_MAX_TOOL_ROUNDS", 50),
+		ToolRepeatLimit:    getToolLoopLimit("RCODE_TOOL_REPEAT_LIMIT", 3),
+		AutoMaxFiles:       getToolLoopLimit("RCODE_AUTO_MAX_FILES", 20),
+		Redact:             setting("RCODE_REDACT") != "false",
+		SensitivePaths:     getGlobList("RCODE_SENSITIVE_PATHS", []string{".env*", "id_rsa", "*.pem"}),
+		SensitiveAllow:     getGlobList("RCODE_SENSITIVE_ALLOW", []string{".env.example", ".env.sample", ".env.template"}),
+		AutoMaxBytes:       int64(getToolLoopLimit("RCODE_AUTO_MAX_BYTES", 1<<20)),
+		ToolResultMax:      int(getSizeLimit("RCODE_TOOL_RESULT_MAX", 30000)),
+		MaxArtifactSize:    getSizeLimit("RCODE_MAX_ARTIFACT_SIZE", 50<<20),
+		ArtifactRetention:  getDays("RCODE_ARTIFACT_RETENTION_DAYS"),
+		FormatOnWrite:      setting("RCODE_FORMAT_ON_WRITE") == "true",
+		LintOnWrite:        setting("RCODE_LINT_ON_WRITE") == "true",
+		DiagnosticsEnabled: setting("RCODE_DIAGNOSTICS") != "false",
+		DiagnosticsWait:    getDiagnosticsWait(),
+		MaxUploadSize:      getSizeLimit("RCODE_MAX_UPLOAD_SIZE", 50<<20),
+		MaxDownloadSize:    getSizeLimit("RCODE_MAX_DOWNLOAD_SIZE", 500<<20),
+		GitHubToken:        getFirstEnv("RCODE_GITHUB_TOKEN", "GITHUB_TOKEN"),
+		GitHubAPIURL:       getEnvDefault("RCODE_GITHUB_API_URL", "https://api.github.com"),
+		GitLabToken:        getFirstEnv("RCODE_GITLAB_TOKEN", "GITLAB_TOKEN"),
+		GitLabURL:          getEnvDefault("RCODE_GITLAB_URL", "https://gitlab.com"),
+		MultiUser:          setting("RCODE_MULTI_USER") == "true",
+		LoginHours:         getLoginHours(),
+		ProxyDailyTokens:   getProxyDailyTokens(),
+		RequestConcurrency: getRequestConcurrency(),
+		ModelFallbacks:     getModelFallbacks(),
+		ModelBackends:      getModelBackends(),
+		BedrockRegion:      getRegion("us-east-1", "RCODE_BEDROCK_REGION", "AWS_REGION", "AWS_DEFAULT_REGION"),
+		VertexProject:      getFirstEnv("RCODE_VERTEX_PROJECT", "ANTHROPIC_VERTEX_PROJECT_ID", "GOOGLE_CLOUD_PROJECT"),
+		VertexRegion:       getRegion("us-east5", "RCODE_VERTEX_REGION", "CLOUD_ML_REGION"),
+		LocalURL:           getEnvDefault("RCODE_LOCAL_URL", "http://localhost:11434/v1"),
+		LocalModels:        getLocalModels(),
+		ContextWindows:     getContextWindows(),
+		CostEstimate:       getCostEstimate(),
+		ConfirmCost:        getConfirmCost(),
+		DailyBudget:        getDailyBudget(),
+		NotifyWebhook:      setting("RCODE_NOTIFY_WEBHOOK"),
+		NotifySlack:        setting("RCODE_NOTIFY_SLACK"),
+		NotifyEvents:       getNotifyEvents(),
+		AuditRetentionDays: getDays("RCODE_AUDIT_RETENTION_DAYS"),
+		AuditOutputDays:    getDays("RCODE_AUDIT_OUTPUT_DAYS"),
+		TracingEndpoint:    getTracingEndpoint(),
+		DBBackend:          getEnvDefault("RCODE_DB_BACKEND", "duckdb"),
+		DBURL:              setting("RCODE_DB_URL"),
+		BackupDir:          setting("RCODE_BACKUP_DIR"),
+		BackupHours:        getBackupHours(),
+		BackupKeep:         getBackupKeep(),
 	}
 }
 
-// Get returns the global configuration instance
+// Get returns the global configuration instance. Callers that read a setting as they use it,
+// rather than keeping the config, see changes to the config files.
 func Get() *Config {
-	if globalConfig == nil {
-		Initialize()
+	if cfg := globalConfig.Load(); cfg != nil {
+		return cfg
+	}
+	Initialize()
+	return globalConfig.Load()
+}
+
+// Redacted returns a copy of the config with its tokens and keys masked, for display
+func (c Config) Redacted() Config {
+	for _, secret := range []*string{&c.EmbeddingsAPIKey, &c.GitHubToken, &c.GitLabToken, &c.NotifyWebhook, &c.NotifySlack} {
+		if *secret != "" {
+			*secret = "********"
+		}
+	}
+	c.CustomToolsPaths = append([]string(nil), c.CustomToolsPaths...)
+	c.ModelFallbacks = append([]string(nil), c.ModelFallbacks...)
+	backends := make(map[string]string, len(c.ModelBackends))
+	for model, backend := range c.ModelBackends {
+		backends[model] = backend
+	}
+	c.ModelBackends = backends
+	c.LocalModels = append([]string(nil), c.LocalModels...)
+	windows := make(map[string]int, len(c.ContextWindows))
+	for model, tokens := range c.ContextWindows {
+		windows[model] = tokens
+	}
+	c.ContextWindows = windows
+	c.NotifyEvents = append([]string(nil), c.NotifyEvents...)
+	if u, err := url.Parse(c.DBURL); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), "********")
+			c.DBURL = u.String()
+		}
 	}
-	return globalConfig
+	return c
 }
 
-// getAnthropicAPIURL returns the API URL from environment or default
+// getAnthropicAPIURL returns the API URL from settings or default
 func getAnthropicAPIURL() string {
 	// Check for MSG_PROXY environment variable
-	if proxyURL := os.Getenv("MSG_PROXY"); proxyURL != "" {
+	if proxyURL := setting("MSG_PROXY"); proxyURL != "" {
 		// If MSG_PROXY is set, append the messages endpoint
 		return proxyURL + "/v1/messages"
 	}
@@ -61,38 +257,38 @@ func getAnthropicAPIURL() string {
 	return defaultAnthropicAPIURL
 }
 
-// getTLSEnabled returns whether TLS is enabled from environment
+// getTLSEnabled returns whether TLS is enabled from settings
 func getTLSEnabled() bool {
-	return os.Getenv("RCODE_TLS_ENABLED") == "true"
+	return setting("RCODE_TLS_ENABLED") == "true"
 }
 
-// getTLSPort returns the TLS port from environment or default
+// getTLSPort returns the TLS port from settings or default
 func getTLSPort() string {
-	if port := os.Getenv("RCODE_TLS_PORT"); port != "" {
+	if port := setting("RCODE_TLS_PORT"); port != "" {
 		return port
 	}
 	return ":8443" // Default HTTPS port for non-privileged
 }
 
-// getTLSCertFile returns the certificate file path from environment or default
+// getTLSCertFile returns the certificate file path from settings or default
 func getTLSCertFile() string {
-	if cert := os.Getenv("RCODE_TLS_CERT"); cert != "" {
+	if cert := setting("RCODE_TLS_CERT"); cert != "" {
 		return cert
 	}
 	return "certs/localhost.crt" // Default certificate path
 }
 
-// getTLSKeyFile returns the key file path from environment or default
+// getTLSKeyFile returns the key file path from settings or default
 func getTLSKeyFile() string {
-	if key := os.Getenv("RCODE_TLS_KEY"); key != "" {
+	if key := setting("RCODE_TLS_KEY"); key != "" {
 		return key
 	}
 	return "certs/localhost.key" // Default key path
 }
 
-// getCustomToolsEnabled returns whether custom tools are enabled from environment
+// getCustomToolsEnabled returns whether custom tools are enabled from settings
 func getCustomToolsEnabled() bool {
-	return os.Getenv("RCODE_CUSTOM_TOOLS_ENABLED") == "true"
+	return setting("RCODE_CUSTOM_TOOLS_ENABLED") == "true"
 }
 
 // getCustomToolsPaths returns the directories to search for custom tools
@@ -102,7 +298,7 @@ func getCustomToolsPaths() []string {
 		"/usr/local/lib/rcode/tools",
 	}
 
-	if envPaths := os.Getenv("RCODE_CUSTOM_TOOLS_PATHS"); envPaths != "" {
+	if envPaths := setting("RCODE_CUSTOM_TOOLS_PATHS"); envPaths != "" {
 		paths = append(paths, strings.Split(envPaths, ":")...)
 	}
 
@@ -111,8 +307,338 @@ func getCustomToolsPaths() []string {
 
 // getCustomToolsConfig returns the path to custom tools config file
 func getCustomToolsConfig() string {
-	if config := os.Getenv("RCODE_CUSTOM_TOOLS_CONFIG"); config != "" {
+	if config := setting("RCODE_CUSTOM_TOOLS_CONFIG"); config != "" {
 		return config
 	}
 	return filepath.Join(os.Getenv("HOME"), ".rcode", "tools.json")
 }
+
+// getPlanAutoResume returns whether interrupted plans resume automatically on startup
+func getPlanAutoResume() bool {
+	return setting("RCODE_PLAN_AUTO_RESUME") == "true"
+}
+
+// getEmbeddingsProvider returns which embedder backs semantic search. There is none
+// by default; "api" uses a model, such as one run by Ollama.
+func getEmbeddingsProvider() string {
+	return setting("RCODE_EMBEDDINGS_PROVIDER")
+}
+
+// getEmbeddingsURL returns the embeddings endpoint from settings or default.
+// The default is Ollama's OpenAI-compatible endpoint, for running a local model.
+func getEmbeddingsURL() string {
+	if url := setting("RCODE_EMBEDDINGS_URL"); url != "" {
+		return url
+	}
+	return "http://localhost:11434/v1/embeddings"
+}
+
+// getScanMaxFiles returns the project scan file limit from settings or default
+func getScanMaxFiles() int {
+	if limit, err := strconv.Atoi(setting("RCODE_SCAN_MAX_FILES")); err == nil && limit >= 0 {
+		return limit
+	}
+	return 20000
+}
+
+// getScanMaxFileSize returns the largest code file, in bytes, read by a project scan
+func getScanMaxFileSize() int64 {
+	if limit, err := strconv.ParseInt(setting("RCODE_SCAN_MAX_FILE_SIZE"), 10, 64); err == nil && limit >= 0 {
+		return limit
+	}
+	return 1 << 20 // 1MB
+}
+
+// getContextTokenBudget returns the token budget for packed project context from settings or default
+func getContextTokenBudget() int {
+	if budget, err := strconv.Atoi(setting("RCODE_CONTEXT_TOKEN_BUDGET")); err == nil && budget >= 0 {
+		return budget
+	}
+	return 8000
+}
+
+// getHooksConfig returns the path to the user's tool hooks file
+func getHooksConfig() string {
+	if config := setting("RCODE_HOOKS_CONFIG"); config != "" {
+		return config
+	}
+	return filepath.Join(os.Getenv("HOME"), ".rcode", "hooks.json")
+}
+
+// getHTTPToolsConfig returns the path to the user's HTTP tools file
+func getHTTPToolsConfig() string {
+	if config := setting("RCODE_HTTP_TOOLS_CONFIG"); config != "" {
+		return config
+	}
+	return filepath.Join(os.Getenv("HOME"), ".rcode", "http_tools.json")
+}
+
+// getDatabasesConfig returns the path to the user's database connections file
+func getDatabasesConfig() string {
+	if config := setting("RCODE_DATABASES_CONFIG"); config != "" {
+		return config
+	}
+	return filepath.Join(os.Getenv("HOME"), ".rcode", "databases.json")
+}
+
+// getTemplatesDir returns the directory of the user's templates
+func getTemplatesDir() string {
+	if dir := setting("RCODE_TEMPLATES_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.Getenv("HOME"), ".rcode", "templates")
+}
+
+// getToolLimitsConfig returns the path to the user's tool limits file
+func getToolLimitsConfig() string {
+	if config := setting("RCODE_TOOL_LIMITS_CONFIG"); config != "" {
+		return config
+	}
+	return filepath.Join(os.Getenv("HOME"), ".rcode", "tool_limits.json")
+}
+
+// getDiagnosticsWait returns how long, in seconds, to wait for diagnostics from settings or default
+func getDiagnosticsWait() time.Duration {
+	if seconds, err := strconv.Atoi(setting("RCODE_DIAGNOSTICS_WAIT")); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return 30 * time.Second
+}
+
+// getLoginHours returns how many hours a sign-in lasts, a week by default
+func getLoginHours() int {
+	if hours, err := strconv.Atoi(setting("RCODE_LOGIN_HOURS")); err == nil && hours > 0 {
+		return hours
+	}
+	return 7 * 24
+}
+
+// getProxyDailyTokens returns the daily token budget of the messages proxy, none by default
+func getProxyDailyTokens() int {
+	if tokens, err := strconv.Atoi(setting("RCODE_PROXY_DAILY_TOKENS")); err == nil && tokens > 0 {
+		return tokens
+	}
+	return 0
+}
+
+// getRequestConcurrency returns how many model requests may be in flight at once, 4 by default
+func getRequestConcurrency() int {
+	if n, err := strconv.Atoi(setting("RCODE_REQUEST_CONCURRENCY")); err == nil && n > 0 {
+		return n
+	}
+	return 4
+}
+
+// getParallelTools returns how many read-only tool calls of a reply may run at once, 4 by default
+func getParallelTools() int {
+	if n, err := strconv.Atoi(setting("RCODE_PARALLEL_TOOLS")); err == nil && n > 0 {
+		return n
+	}
+	return 4
+}
+
+// getToolLoopLimit returns a limit on a turn's tool calls from settings or default; 0 turns it off
+func getToolLoopLimit(name string, defaultLimit int) int {
+	if n, err := strconv.Atoi(setting(name)); err == nil && n >= 0 {
+		return n
+	}
+	return defaultLimit
+}
+
+// getCostEstimate returns how turns' costs are estimated, locally by default
+func getCostEstimate() string {
+	switch value := setting("RCODE_COST_ESTIMATE"); value {
+	case "api", "off":
+		return value
+	default:
+		return "local"
+	}
+}
+
+// getConfirmCost returns the input cost, in dollars, above which a turn is confirmed first;
+// 0, the default, never asks
+func getConfirmCost() float64 {
+	if cost, err := strconv.ParseFloat(setting("RCODE_CONFIRM_COST"), 64); err == nil && cost > 0 {
+		return cost
+	}
+	return 0
+}
+
+// getDailyBudget returns the dollars of model usage budgeted for a day; 0, the default, is none
+func getDailyBudget() float64 {
+	if budget, err := strconv.ParseFloat(setting("RCODE_DAILY_BUDGET"), 64); err == nil && budget > 0 {
+		return budget
+	}
+	return 0
+}
+
+// getNotifyEvents returns the events that notify, all of them by default. "none" turns
+// notifications off.
+func getNotifyEvents() []string {
+	value := setting("RCODE_NOTIFY_EVENTS")
+	switch value {
+	case "":
+		return []string{"plan_completed", "plan_failed", "permission_request", "budget"}
+	case "none":
+		return nil
+	}
+
+	var events []string
+	for _, event := range strings.Split(value, ",") {
+		if event = strings.TrimSpace(event); event != "" {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// getBackupHours returns the hours between scheduled backups, a day by default. 0 turns
+// them off.
+func getBackupHours() int {
+	if hours, err := strconv.Atoi(setting("RCODE_BACKUP_HOURS")); err == nil && hours >= 0 {
+		return hours
+	}
+	return 24
+}
+
+// getBackupKeep returns how many backups are kept, a week's worth of daily ones by default
+func getBackupKeep() int {
+	if n, err := strconv.Atoi(setting("RCODE_BACKUP_KEEP")); err == nil && n > 0 {
+		return n
+	}
+	return 7
+}
+
+// getModelFallbacks returns the model fallback chain, Opus to Sonnet to Haiku by default.
+// "none" turns fallbacks off.
+func getModelFallbacks() []string {
+	value := setting("RCODE_MODEL_FALLBACKS")
+	switch value {
+	case "":
+		return []string{"opus", "sonnet", "haiku"}
+	case "none":
+		return nil
+	}
+
+	var models []string
+	for _, model := range strings.Split(value, ",") {
+		if model = strings.TrimSpace(model); model != "" {
+			models = append(models, model)
+		}
+	}
+	return models
+}
+
+// getModelBackends returns the backends set for models, from a comma-separated list of
+// model=backend, e.g. "opus=vertex,*=bedrock"
+func getModelBackends() map[string]string {
+	backends := make(map[string]string)
+	for _, entry := range strings.Split(setting("RCODE_MODEL_BACKENDS"), ",") {
+		model, backend, ok := strings.Cut(entry, "=")
+		if model, backend = strings.TrimSpace(model), strings.TrimSpace(backend); ok && model != "" && backend != "" {
+			backends[model] = backend
+		}
+	}
+	return backends
+}
+
+// getLocalModels returns the models of the local server, from a comma-separated list
+func getLocalModels() []string {
+	var models []string
+	for _, model := range strings.Split(setting("RCODE_LOCAL_MODELS"), ",") {
+		if model = strings.TrimSpace(model); model != "" {
+			models = append(models, model)
+		}
+	}
+	return models
+}
+
+// getContextWindows returns the context windows set for models, from a comma-separated list
+// of model=tokens, e.g. "qwen2.5-coder=32768"
+func getContextWindows() map[string]int {
+	windows := make(map[string]int)
+	for _, entry := range strings.Split(setting("RCODE_CONTEXT_WINDOWS"), ",") {
+		model, tokens, ok := strings.Cut(entry, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(tokens))
+		if model = strings.TrimSpace(model); ok && model != "" && err == nil && n > 0 {
+			windows[model] = n
+		}
+	}
+	return windows
+}
+
+// getGlobList returns a comma-separated list of path globs from settings or defaults; "none" for none
+func getGlobList(name string, defaults []string) []string {
+	value := setting(name)
+	switch value {
+	case "":
+		return defaults
+	case "none":
+		return nil
+	}
+
+	var globs []string
+	for _, glob := range strings.Split(value, ",") {
+		if glob = strings.TrimSpace(glob); glob != "" {
+			globs = append(globs, glob)
+		}
+	}
+	return globs
+}
+
+// getTracingEndpoint returns where spans are exported: RCODE_OTLP_ENDPOINT or the standard
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT as it is, or the traces path of OTEL_EXPORTER_OTLP_ENDPOINT
+func getTracingEndpoint() string {
+	if endpoint := setting("RCODE_OTLP_ENDPOINT"); endpoint != "" {
+		return endpoint
+	}
+	if endpoint := setting("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); endpoint != "" {
+		return endpoint
+	}
+	if endpoint := setting("OTEL_EXPORTER_OTLP_ENDPOINT"); endpoint != "" {
+		return strings.TrimSuffix(endpoint, "/") + "/v1/traces"
+	}
+	return ""
+}
+
+// getDays returns a number of days from the named setting, 0 when it is unset
+func getDays(name string) int {
+	if days, err := strconv.Atoi(setting(name)); err == nil && days > 0 {
+		return days
+	}
+	return 0
+}
+
+// getSizeLimit returns a byte limit from the named setting or the default
+func getSizeLimit(name string, defaultLimit int64) int64 {
+	if limit, err := strconv.ParseInt(setting(name), 10, 64); err == nil && limit > 0 {
+		return limit
+	}
+	return defaultLimit
+}
+
+// getFirstEnv returns the first of the named settings that is set
+func getFirstEnv(names ...string) string {
+	for _, name := range names {
+		if value := setting(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// getRegion returns the first of the named region settings that is set, or the default
+func getRegion(defaultRegion string, names ...string) string {
+	if region := getFirstEnv(names...); region != "" {
+		return region
+	}
+	return defaultRegion
+}
+
+// getEnvDefault returns the named setting, without a trailing slash, or the default
+func getEnvDefault(name, defaultValue string) string {
+	if value := setting(name); value != "" {
+		return strings.TrimRight(value, "/")
+	}
+	return defaultValue
+}
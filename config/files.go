@@ -0,0 +1,214 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/serr"
+)
+
+// Where a setting's value came from, lowest precedence first. Settings from none of
+// these have their default value.
+const (
+	SourceUser    = "user"
+	SourceProject = "project"
+	SourceEnv     = "env"
+)
+
+// reloadInterval is how often the config files are checked for changes
+const reloadInterval = 2 * time.Second
+
+// File is a config file that settings are read from
+type File struct {
+	Path    string    `json:"path"`
+	Source  string    `json:"source"` // SourceUser or SourceProject
+	Exists  bool      `json:"exists"`
+	ModTime time.Time `json:"mod_time,omitempty"`
+	Error   string    `json:"error,omitempty"` // Why the file's settings are not applied
+	Unknown []string  `json:"unknown,omitempty"`
+
+	size   int64
+	values map[string]string
+}
+
+var (
+	loadMu sync.Mutex
+	files  []*File // The user file, then the project file
+	// sources records where each setting of the current config came from. It is only
+	// written while a config is built, under loadMu.
+	sources map[string]string
+)
+
+// UserConfigPath returns the user's config file, ~/.config/rcode/config.toml
+// or under $XDG_CONFIG_HOME when that is set
+func UserConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		dir = filepath.Join(os.Getenv("HOME"), ".config")
+	}
+	return filepath.Join(dir, "rcode", "config.toml")
+}
+
+// ProjectConfigPath returns the project's config file, .rcode/config.toml in the working directory
+func ProjectConfigPath() string {
+	workDir, err := os.Getwd()
+	if err != nil {
+		workDir = "."
+	}
+	return filepath.Join(workDir, ".rcode", "config.toml")
+}
+
+// Files returns the config files and whether they were applied
+func Files() []File {
+	loadMu.Lock()
+	defer loadMu.Unlock()
+
+	list := make([]File, len(files))
+	for i, f := range files {
+		list[i] = *f
+	}
+	return list
+}
+
+// Sources returns where each setting of the current config came from, by setting name.
+// Settings not listed have their default value.
+func Sources() map[string]string {
+	loadMu.Lock()
+	defer loadMu.Unlock()
+
+	copied := make(map[string]string, len(sources))
+	for name, source := range sources {
+		copied[name] = source
+	}
+	return copied
+}
+
+// readFile reads a config file. A missing file has no settings; a file that can't be
+// parsed keeps the settings it had, so a half-saved edit doesn't undo the others.
+func readFile(f *File) {
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			f.Error = err.Error()
+		} else {
+			f.Error = ""
+		}
+		f.Exists, f.ModTime, f.size, f.values = false, time.Time{}, 0, nil
+		return
+	}
+	f.Exists, f.ModTime, f.size = true, info.ModTime(), info.Size()
+
+	data, err := os.ReadFile(f.Path)
+	if err == nil {
+		var values map[string]string
+		if values, err = parseTOML(string(data)); err == nil {
+			f.values, f.Error = values, ""
+			return
+		}
+	}
+	f.Error = err.Error()
+	logger.LogErr(serr.Wrap(err, "invalid config file"), "keeping its previous settings", "path", f.Path)
+}
+
+// changed reports whether a config file was written, created or removed since it was read
+func (f *File) changed() bool {
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		return f.Exists
+	}
+	return !f.Exists || !info.ModTime().Equal(f.ModTime) || info.Size() != f.size
+}
+
+// setting returns a setting by its environment variable name. The environment wins,
+// then the project's config file, then the user's, where the setting's name is the
+// variable's in lower case without the RCODE_ prefix, e.g. tls_enabled for RCODE_TLS_ENABLED.
+// It is only called while a config is built, under loadMu.
+func setting(envName string) string {
+	name := strings.ToLower(strings.TrimPrefix(envName, "RCODE_"))
+	if value := os.Getenv(envName); value != "" {
+		sources[name] = SourceEnv
+		return value
+	}
+	for i := len(files) - 1; i >= 0; i-- {
+		if value, ok := files[i].values[name]; ok {
+			sources[name] = files[i].Source
+			return value
+		}
+	}
+	return ""
+}
+
+// load reads the config files and builds the config from them and the environment
+func load() *Config {
+	loadMu.Lock()
+	defer loadMu.Unlock()
+
+	if files == nil {
+		files = []*File{
+			{Path: UserConfigPath(), Source: SourceUser},
+			{Path: ProjectConfigPath(), Source: SourceProject},
+		}
+	}
+	for _, f := range files {
+		readFile(f)
+	}
+	return build()
+}
+
+// build creates the config from the files already read. The caller holds loadMu.
+func build() *Config {
+	sources = make(map[string]string)
+	cfg := newConfig()
+
+	// Every setting is looked up on each build, and found values are recorded in sources,
+	// so a file's names that are missing there were never read and are most likely typos
+	for _, f := range files {
+		f.Unknown = nil
+		for name := range f.values {
+			if _, used := sources[name]; !used {
+				f.Unknown = append(f.Unknown, name)
+			}
+		}
+		slices.Sort(f.Unknown)
+		if len(f.Unknown) > 0 {
+			logger.Warn("Unknown settings in config file", "path", f.Path, "settings", strings.Join(f.Unknown, ", "))
+		}
+	}
+	return cfg
+}
+
+// WatchFiles reloads the config when a config file changes, so settings that are read
+// as they are used, such as the context token budget, apply without a restart.
+// Files are polled, as rcode doesn't depend on a file notification library.
+func WatchFiles() {
+	go func() {
+		for range time.Tick(reloadInterval) {
+			if reloadIfChanged() {
+				logger.Info("Reloaded configuration")
+			}
+		}
+	}()
+}
+
+// reloadIfChanged rebuilds the config if a config file changed, reporting whether it did
+func reloadIfChanged() bool {
+	loadMu.Lock()
+	defer loadMu.Unlock()
+
+	changed := false
+	for _, f := range files {
+		if f.changed() {
+			readFile(f)
+			changed = true
+		}
+	}
+	if changed {
+		globalConfig.Store(build())
+	}
+	return changed
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// parseTOML reads the subset of TOML used by config files: comments, [table] headers,
+// and key = value pairs whose value is a string, integer, float, boolean or a one-line
+// array of those. Keys are flattened into setting names, so tls_enabled can also be
+// written as enabled under [tls]. Array items are joined with ':', like path lists.
+func parseTOML(data string) (map[string]string, error) {
+	values := make(map[string]string)
+	table := ""
+
+	for i, line := range strings.Split(data, "\n") {
+		lineNum := i + 1
+		line = strings.TrimSpace(stripComment(line))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if strings.HasPrefix(line, "[[") || !strings.HasSuffix(line, "]") {
+				return nil, serr.New(fmt.Sprintf("line %d: unsupported table header %s", lineNum, line))
+			}
+			table = settingName(strings.Trim(line, "[] "))
+			continue
+		}
+
+		key, raw, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, serr.New(fmt.Sprintf("line %d: expected key = value", lineNum))
+		}
+		name := settingName(key)
+		if name == "" {
+			return nil, serr.New(fmt.Sprintf("line %d: missing key", lineNum))
+		}
+		if table != "" {
+			name = table + "_" + name
+		}
+
+		value, err := parseTOMLValue(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, serr.New(fmt.Sprintf("line %d: %s: %s", lineNum, name, err.Error()))
+		}
+		if _, dup := values[name]; dup {
+			return nil, serr.New(fmt.Sprintf("line %d: %s is set twice", lineNum, name))
+		}
+		values[name] = value
+	}
+
+	return values, nil
+}
+
+// settingName normalizes a key, e.g. "Embeddings.Provider" or "embeddings-provider", to embeddings_provider
+func settingName(key string) string {
+	key = strings.ToLower(strings.Trim(strings.TrimSpace(key), `"'`))
+	return strings.NewReplacer(".", "_", "-", "_", " ", "").Replace(key)
+}
+
+// stripComment removes a # comment that is not inside a string
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// parseTOMLValue returns a value as the string the setting would have in an environment variable
+func parseTOMLValue(raw string) (string, error) {
+	switch {
+	case raw == "":
+		return "", serr.New("missing value")
+	case strings.HasPrefix(raw, `"""`) || strings.HasPrefix(raw, "'''"):
+		return "", serr.New("multi-line strings are not supported")
+	case raw[0] == '"':
+		s, err := strconv.Unquote(raw)
+		if err != nil {
+			return "", serr.New("invalid string " + raw)
+		}
+		return s, nil
+	case raw[0] == '\'':
+		if len(raw) < 2 || raw[len(raw)-1] != '\'' || strings.Contains(raw[1:len(raw)-1], "'") {
+			return "", serr.New("invalid string " + raw)
+		}
+		return raw[1 : len(raw)-1], nil
+	case raw[0] == '[':
+		if !strings.HasSuffix(raw, "]") {
+			return "", serr.New("arrays must be on one line")
+		}
+		var items []string
+		for _, item := range splitArray(raw[1 : len(raw)-1]) {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			value, err := parseTOMLValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, value)
+		}
+		return strings.Join(items, ":"), nil
+	case raw == "true" || raw == "false":
+		return raw, nil
+	}
+
+	number := strings.ReplaceAll(raw, "_", "")
+	if n, err := strconv.ParseInt(number, 0, 64); err == nil {
+		return strconv.FormatInt(n, 10), nil
+	}
+	if _, err := strconv.ParseFloat(number, 64); err == nil {
+		return number, nil
+	}
+	return "", serr.New("unsupported value " + raw)
+}
+
+// splitArray splits array items on commas outside strings
+func splitArray(s string) []string {
+	var items []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			items = append(items, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, s[start:])
+}
@@ -0,0 +1,327 @@
+package context
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DependencyGraph is the file-level import graph of a project, built from scanner metadata.
+// Edges only join files inside the project; other imports are kept per file as external.
+type DependencyGraph struct {
+	root       string
+	imports    map[string][]string // File -> project files it imports
+	importedBy map[string][]string // File -> project files that import it
+	external   map[string][]string // File -> imports that do not resolve to a project file
+}
+
+// graphResolver maps import strings to project files
+type graphResolver struct {
+	root       string
+	goModule   string              // Module path from go.mod, if any
+	files      map[string]bool     // Every file in the tree
+	goPackages map[string][]string // Directory -> non-test Go files
+	javaFiles  []string
+}
+
+// buildDependencyGraph resolves the imports recorded for each code file in ctx
+func (s *ProjectScanner) buildDependencyGraph(ctx *ProjectContext) *DependencyGraph {
+	graph := &DependencyGraph{
+		root:       ctx.RootPath,
+		imports:    make(map[string][]string),
+		importedBy: make(map[string][]string),
+		external:   make(map[string][]string),
+	}
+	if ctx.FileTree == nil {
+		return graph
+	}
+
+	resolver := &graphResolver{
+		root:       ctx.RootPath,
+		goModule:   readGoModulePath(ctx.RootPath),
+		files:      make(map[string]bool),
+		goPackages: make(map[string][]string),
+	}
+
+	var codeFiles []*FileNode
+	s.walkFileTree(ctx.FileTree, func(node *FileNode) {
+		if node.IsDir {
+			return
+		}
+		resolver.files[node.Path] = true
+		switch filepath.Ext(node.Path) {
+		case ".go":
+			if !strings.HasSuffix(node.Path, "_test.go") {
+				dir := filepath.Dir(node.Path)
+				resolver.goPackages[dir] = append(resolver.goPackages[dir], node.Path)
+			}
+		case ".java":
+			resolver.javaFiles = append(resolver.javaFiles, node.Path)
+		}
+		if len(node.Metadata.Imports) > 0 {
+			codeFiles = append(codeFiles, node)
+		}
+	})
+
+	for _, node := range codeFiles {
+		seen := make(map[string]bool)
+		for _, imp := range node.Metadata.Imports {
+			targets := resolver.resolve(node.Path, node.Language, imp)
+			if len(targets) == 0 {
+				graph.external[node.Path] = appendUnique(graph.external[node.Path], imp)
+				continue
+			}
+			for _, target := range targets {
+				if target == node.Path || seen[target] {
+					continue
+				}
+				seen[target] = true
+				graph.imports[node.Path] = append(graph.imports[node.Path], target)
+				graph.importedBy[target] = append(graph.importedBy[target], node.Path)
+			}
+		}
+	}
+
+	for _, edges := range []map[string][]string{graph.imports, graph.importedBy} {
+		for _, files := range edges {
+			sort.Strings(files)
+		}
+	}
+
+	return graph
+}
+
+// Dependencies returns the project files that path imports
+func (g *DependencyGraph) Dependencies(path string) []string {
+	return g.imports[g.normalize(path)]
+}
+
+// Dependents returns the project files that import path
+func (g *DependencyGraph) Dependents(path string) []string {
+	return g.importedBy[g.normalize(path)]
+}
+
+// ExternalImports returns the imports of path that are not project files, such as third-party packages
+func (g *DependencyGraph) ExternalImports(path string) []string {
+	return g.external[g.normalize(path)]
+}
+
+// Contains reports whether path has any edges in the graph
+func (g *DependencyGraph) Contains(path string) bool {
+	path = g.normalize(path)
+	return len(g.imports[path]) > 0 || len(g.importedBy[path]) > 0 || len(g.external[path]) > 0
+}
+
+// Distances returns the number of import hops from the nearest of the given files to every file
+// within maxDepth, following edges in both directions. The given files themselves are at distance 0.
+func (g *DependencyGraph) Distances(from []string, maxDepth int) map[string]int {
+	distances := make(map[string]int)
+	var frontier []string
+	for _, path := range from {
+		path = g.normalize(path)
+		if _, ok := distances[path]; !ok {
+			distances[path] = 0
+			frontier = append(frontier, path)
+		}
+	}
+
+	for depth := 1; depth <= maxDepth && len(frontier) > 0; depth++ {
+		var next []string
+		for _, path := range frontier {
+			for _, edges := range [][]string{g.imports[path], g.importedBy[path]} {
+				for _, neighbor := range edges {
+					if _, ok := distances[neighbor]; !ok {
+						distances[neighbor] = depth
+						next = append(next, neighbor)
+					}
+				}
+			}
+		}
+		frontier = next
+	}
+
+	return distances
+}
+
+// normalize makes a path absolute against the project root, matching the paths in the file tree
+func (g *DependencyGraph) normalize(path string) string {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(g.root, path)
+	}
+	return filepath.Clean(path)
+}
+
+// resolve returns the project files an import refers to, or nil if it is external
+func (r *graphResolver) resolve(from, language, imp string) []string {
+	switch language {
+	case "go":
+		return r.resolveGo(imp)
+	case "javascript", "typescript":
+		return r.resolveJS(from, imp)
+	case "python":
+		return r.resolvePython(from, imp)
+	case "java":
+		return r.resolveJava(imp)
+	case "rust":
+		return r.resolveRust(from, imp)
+	}
+	return nil
+}
+
+// resolveGo maps an import path within the module to the files of that package
+func (r *graphResolver) resolveGo(imp string) []string {
+	if r.goModule == "" {
+		return nil
+	}
+	if imp != r.goModule && !strings.HasPrefix(imp, r.goModule+"/") {
+		return nil
+	}
+	rel := strings.TrimPrefix(strings.TrimPrefix(imp, r.goModule), "/")
+	return r.goPackages[filepath.Join(r.root, filepath.FromSlash(rel))]
+}
+
+// resolveJS maps a relative module specifier to a file, trying the usual extensions and index files
+func (r *graphResolver) resolveJS(from, imp string) []string {
+	if !strings.HasPrefix(imp, ".") {
+		return nil // Package import
+	}
+
+	base := filepath.Join(filepath.Dir(from), filepath.FromSlash(imp))
+	exts := []string{".ts", ".tsx", ".js", ".jsx", ".mjs", ".cjs"}
+	candidates := []string{base}
+	for _, ext := range exts {
+		candidates = append(candidates, base+ext)
+	}
+	for _, ext := range exts {
+		candidates = append(candidates, filepath.Join(base, "index"+ext))
+	}
+	return r.first(candidates)
+}
+
+// resolvePython maps a dotted module name, absolute or relative, to a module file or package __init__.py
+func (r *graphResolver) resolvePython(from, imp string) []string {
+	imp = strings.TrimSpace(strings.SplitN(imp, " as ", 2)[0])
+
+	var bases []string
+	if strings.HasPrefix(imp, ".") {
+		// Each leading dot beyond the first climbs one package
+		dots := len(imp) - len(strings.TrimLeft(imp, "."))
+		dir := filepath.Dir(from)
+		for i := 1; i < dots; i++ {
+			dir = filepath.Dir(dir)
+		}
+		bases = []string{dir}
+		imp = imp[dots:]
+	} else {
+		bases = []string{r.root, filepath.Join(r.root, "src")}
+	}
+
+	rel := filepath.FromSlash(strings.ReplaceAll(imp, ".", "/"))
+	var candidates []string
+	for _, base := range bases {
+		modPath := filepath.Join(base, rel)
+		candidates = append(candidates, modPath+".py", filepath.Join(modPath, "__init__.py"))
+	}
+	return r.first(candidates)
+}
+
+// resolveJava maps a class import, or a package wildcard import, to source files
+func (r *graphResolver) resolveJava(imp string) []string {
+	imp = strings.TrimSpace(strings.TrimPrefix(imp, "static "))
+	rel := string(filepath.Separator) + filepath.FromSlash(strings.ReplaceAll(imp, ".", "/"))
+
+	var matches []string
+	if strings.HasSuffix(rel, string(filepath.Separator)+"*") {
+		dir := strings.TrimSuffix(rel, "*")
+		for _, file := range r.javaFiles {
+			if strings.HasSuffix(filepath.Dir(file)+string(filepath.Separator), dir) {
+				matches = append(matches, file)
+			}
+		}
+		return matches
+	}
+
+	// Static imports name a member; try the enclosing class as well
+	for _, candidate := range []string{rel + ".java", filepath.Dir(rel) + ".java"} {
+		for _, file := range r.javaFiles {
+			if strings.HasSuffix(file, candidate) {
+				matches = append(matches, file)
+			}
+		}
+		if len(matches) > 0 {
+			return matches
+		}
+	}
+	return nil
+}
+
+// resolveRust maps a crate::, self:: or super:: path to the module file it names
+func (r *graphResolver) resolveRust(from, imp string) []string {
+	imp = strings.TrimSpace(strings.SplitN(imp, "::{", 2)[0])
+	segments := strings.Split(imp, "::")
+	if len(segments) < 2 {
+		return nil
+	}
+
+	var dir string
+	switch segments[0] {
+	case "crate":
+		dir = filepath.Join(r.root, "src")
+	case "self":
+		dir = filepath.Dir(from)
+	case "super":
+		dir = filepath.Dir(filepath.Dir(from))
+	default:
+		return nil // External crate
+	}
+
+	// The path may end in an item rather than a module, so try the longest module path first
+	segments = segments[1:]
+	for n := len(segments); n > 0; n-- {
+		modPath := filepath.Join(append([]string{dir}, segments[:n]...)...)
+		if found := r.first([]string{modPath + ".rs", filepath.Join(modPath, "mod.rs")}); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// first returns the first candidate that is a project file
+func (r *graphResolver) first(candidates []string) []string {
+	for _, candidate := range candidates {
+		if r.files[candidate] {
+			return []string{candidate}
+		}
+	}
+	return nil
+}
+
+// readGoModulePath returns the module path declared in rootPath's go.mod
+func readGoModulePath(rootPath string) string {
+	file, err := os.Open(filepath.Join(rootPath, "go.mod"))
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "module ") {
+			return strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "module ")), "\"")
+		}
+	}
+	return ""
+}
+
+// appendUnique appends value to list if it is not already present
+func appendUnique(list []string, value string) []string {
+	for _, v := range list {
+		if v == value {
+			return list
+		}
+	}
+	return append(list, value)
+}
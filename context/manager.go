@@ -151,6 +151,30 @@ func (m *Manager) GetRelevantContext(task string) (*TaskContext, error) {
 	return taskCtx, nil
 }
 
+// PackContext selects the files most relevant to task and packs them into a context block of at most budget tokens.
+// When scope is set, the files are chosen from that directory only.
+func (m *Manager) PackContext(task string, budget int, scope string) (*PackedContext, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.context == nil {
+		return nil, serr.New("no project context available")
+	}
+
+	taskCtx := &TaskContext{
+		Task:     task,
+		MaxFiles: packFilesConsidered,
+		Scope:    scope,
+	}
+
+	files, err := m.prioritizer.Prioritize(m.context, taskCtx)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to prioritize files")
+	}
+
+	return NewContextPacker(budget).Pack(m.context, files, taskCtx.SearchTerms), nil
+}
+
 // GetRecentChanges returns recent file changes
 func (m *Manager) GetRecentChanges(limit int) []FileChange {
 	m.mu.RLock()
@@ -260,6 +284,42 @@ func (m *Manager) IsInitialized() bool {
 	return m.context != nil
 }
 
+// SetSemanticScorer enables embedding-based scoring when prioritizing files
+func (m *Manager) SetSemanticScorer(scorer SemanticScorer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prioritizer.SetSemanticScorer(scorer)
+}
+
+// CodeFiles returns the paths of all code files in the scanned project
+func (m *Manager) CodeFiles() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.context == nil {
+		return nil
+	}
+
+	files := make([]string, 0)
+	m.scanner.walkFileTree(m.context.FileTree, func(node *FileNode) {
+		if !node.IsDir && isCodeFile(node.Path) {
+			files = append(files, node.Path)
+		}
+	})
+	return files
+}
+
+// DependencyGraph returns the import graph of the scanned project, or nil before a scan
+func (m *Manager) DependencyGraph() *DependencyGraph {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.context == nil {
+		return nil
+	}
+	return m.context.Graph
+}
+
 // GetProjectRoot returns the project root path
 func (m *Manager) GetProjectRoot() string {
 	m.mu.RLock()
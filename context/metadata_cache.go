@@ -0,0 +1,60 @@
+package context
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cachedMetadata is a file's extracted metadata, valid while its modification time and size are unchanged
+type cachedMetadata struct {
+	modTime  time.Time
+	size     int64
+	metadata FileMetadata
+}
+
+// metadataCache holds file metadata across scans, so a rescan only reads files that changed.
+// It is shared by all scanners since a new context manager is created for most requests.
+var metadataCache = struct {
+	sync.RWMutex
+	files map[string]cachedMetadata
+}{files: make(map[string]cachedMetadata)}
+
+// cachedFileMetadata returns the cached metadata for path if the file is unchanged
+func cachedFileMetadata(path string, modTime time.Time, size int64) (FileMetadata, bool) {
+	metadataCache.RLock()
+	defer metadataCache.RUnlock()
+
+	cached, ok := metadataCache.files[path]
+	if !ok || cached.size != size || !cached.modTime.Equal(modTime) {
+		return FileMetadata{}, false
+	}
+	return cached.metadata, true
+}
+
+// cacheFileMetadata stores the metadata extracted from path
+func cacheFileMetadata(path string, modTime time.Time, size int64, metadata FileMetadata) {
+	metadataCache.Lock()
+	defer metadataCache.Unlock()
+
+	metadataCache.files[path] = cachedMetadata{
+		modTime:  modTime,
+		size:     size,
+		metadata: metadata,
+	}
+}
+
+// pruneMetadataCache drops cached files under rootPath that were not seen by the latest scan
+func pruneMetadataCache(rootPath string, seen map[string]bool) {
+	prefix := rootPath + string(filepath.Separator)
+
+	metadataCache.Lock()
+	defer metadataCache.Unlock()
+
+	for path := range metadataCache.files {
+		if strings.HasPrefix(path, prefix) && !seen[path] {
+			delete(metadataCache.files, path)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package context
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	packFilesConsidered = 20  // Prioritized files the packer looks at
+	packMinFileTokens   = 100 // Smaller leftovers are not worth a file section
+	packMaxFileShare    = 0.4 // Most of the budget one file may take
+	packContextLines    = 8   // Lines kept either side of a relevant line
+	packMaxFileBytes    = 1 << 20
+)
+
+const packHeader = "The project files below were selected as relevant to the task. " +
+	"Long files are trimmed to the regions that matched. They show the files when the session started; " +
+	"read a file again before editing it.\n\n"
+
+// PackedContext is a block of prioritized project files fitted to a token budget
+type PackedContext struct {
+	Content string       `json:"content"`
+	Tokens  int          `json:"tokens"`
+	Budget  int          `json:"budget"`
+	Files   []PackedFile `json:"files"`
+}
+
+// PackedFile describes how much of a file made it into a PackedContext
+type PackedFile struct {
+	Path    string      `json:"path"` // Relative to the project root
+	Ranges  []LineRange `json:"ranges"`
+	Lines   int         `json:"lines"` // Lines in the whole file
+	Tokens  int         `json:"tokens"`
+	Trimmed bool        `json:"trimmed"`
+}
+
+// LineRange is an inclusive, 1-based range of lines
+type LineRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// ContextPacker selects prioritized files and trims them to fit a token budget
+type ContextPacker struct {
+	budget int
+}
+
+// NewContextPacker creates a packer that keeps packed context within budget tokens
+func NewContextPacker(budget int) *ContextPacker {
+	return &ContextPacker{budget: budget}
+}
+
+// Pack assembles a context block from files, in priority order.
+// Each file is included whole if it fits, otherwise trimmed to the regions around lines
+// that mention the keywords, otherwise cut to its opening lines.
+func (p *ContextPacker) Pack(ctx *ProjectContext, files []string, keywords []string) *PackedContext {
+	packed := &PackedContext{Budget: p.budget, Files: make([]PackedFile, 0)}
+
+	var sb strings.Builder
+	sb.WriteString(packHeader)
+	remaining := p.budget - CountTokens(packHeader)
+	maxFileTokens := int(float64(p.budget) * packMaxFileShare)
+
+	for _, path := range files {
+		if remaining < packMinFileTokens {
+			break
+		}
+
+		node := findFileNode(ctx.FileTree, path)
+		if node == nil || node.IsDir || isBinaryFile(path) || node.Size > packMaxFileBytes {
+			continue
+		}
+		content, err := os.ReadFile(path)
+		if err != nil || len(content) == 0 || !utf8.Valid(content) {
+			continue
+		}
+
+		rel, err := filepath.Rel(ctx.RootPath, path)
+		if err != nil {
+			rel = path
+		}
+
+		fileBudget := remaining
+		if fileBudget > maxFileTokens {
+			fileBudget = maxFileTokens
+		}
+
+		section, file := p.packFile(rel, node.Language, string(content), keywords, fileBudget)
+		if section == "" {
+			continue
+		}
+
+		sb.WriteString(section)
+		remaining -= file.Tokens
+		packed.Files = append(packed.Files, file)
+	}
+
+	if len(packed.Files) == 0 {
+		return packed
+	}
+
+	packed.Content = sb.String()
+	packed.Tokens = CountTokens(packed.Content)
+	return packed
+}
+
+// packFile formats the parts of one file that fit within budget tokens
+func (p *ContextPacker) packFile(rel, language, content string, keywords []string, budget int) (string, PackedFile) {
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	file := PackedFile{Path: rel, Lines: len(lines)}
+
+	// Whole file
+	whole := []LineRange{{Start: 1, End: len(lines)}}
+	section := formatPackedSection(rel, language, lines, whole)
+	if tokens := CountTokens(section); tokens <= budget {
+		file.Ranges, file.Tokens = whole, tokens
+		return section, file
+	}
+
+	// Token cost of each line, so candidate ranges can be priced without re-encoding
+	lineTokens := make([]int, len(lines))
+	for i, line := range lines {
+		lineTokens[i] = CountTokens(line + "\n")
+	}
+	overhead := CountTokens(formatPackedSection(rel, language, nil, nil))
+
+	ranges := p.relevantRanges(lines, keywords, lineTokens, budget-overhead)
+	if len(ranges) == 0 {
+		ranges = headRange(lineTokens, budget-overhead)
+	}
+	if len(ranges) == 0 {
+		return "", file
+	}
+
+	section = formatPackedSection(rel, language, lines, ranges)
+	file.Ranges, file.Tokens, file.Trimmed = ranges, CountTokens(section), true
+	return section, file
+}
+
+// relevantRanges picks the regions around lines mentioning a keyword, densest first,
+// until budget is used up. The chosen ranges are returned in file order.
+func (p *ContextPacker) relevantRanges(lines, keywords []string, lineTokens []int, budget int) []LineRange {
+	if len(keywords) == 0 {
+		return nil
+	}
+
+	type region struct {
+		LineRange
+		matches int
+	}
+	var regions []region
+	for i, line := range lines {
+		lower := strings.ToLower(line)
+		matched := false
+		for _, keyword := range keywords {
+			if strings.Contains(lower, strings.ToLower(keyword)) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+
+		start, end := i+1-packContextLines, i+1+packContextLines
+		if start < 1 {
+			start = 1
+		}
+		if end > len(lines) {
+			end = len(lines)
+		}
+
+		// Merge with the previous region if they touch
+		if n := len(regions); n > 0 && start <= regions[n-1].End+1 {
+			regions[n-1].End = end
+			regions[n-1].matches++
+			continue
+		}
+		regions = append(regions, region{LineRange: LineRange{Start: start, End: end}, matches: 1})
+	}
+
+	sort.SliceStable(regions, func(i, j int) bool {
+		return regions[i].matches > regions[j].matches
+	})
+
+	// Each region costs its lines plus an omission marker
+	var chosen []LineRange
+	used := 0
+	for _, r := range regions {
+		cost := rangeTokens(lineTokens, r.LineRange) + 10
+		if used+cost > budget {
+			continue
+		}
+		used += cost
+		chosen = append(chosen, r.LineRange)
+	}
+
+	sort.Slice(chosen, func(i, j int) bool {
+		return chosen[i].Start < chosen[j].Start
+	})
+	return chosen
+}
+
+// headRange returns the longest run of opening lines that fits within budget
+func headRange(lineTokens []int, budget int) []LineRange {
+	used, end := 10, 0 // Allow for the omission marker
+	for end < len(lineTokens) && used+lineTokens[end] <= budget {
+		used += lineTokens[end]
+		end++
+	}
+	if end == 0 {
+		return nil
+	}
+	return []LineRange{{Start: 1, End: end}}
+}
+
+// rangeTokens sums the token costs of the lines in r
+func rangeTokens(lineTokens []int, r LineRange) int {
+	total := 0
+	for i := r.Start - 1; i < r.End && i < len(lineTokens); i++ {
+		total += lineTokens[i]
+	}
+	return total
+}
+
+// formatPackedSection renders the given ranges of a file as a fenced code block,
+// marking the lines left out
+func formatPackedSection(rel, language string, lines []string, ranges []LineRange) string {
+	var sb strings.Builder
+	sb.WriteString("### " + rel)
+	if len(ranges) > 0 && !(len(ranges) == 1 && ranges[0].Start == 1 && ranges[0].End == len(lines)) {
+		parts := make([]string, len(ranges))
+		for i, r := range ranges {
+			parts[i] = fmt.Sprintf("%d-%d", r.Start, r.End)
+		}
+		sb.WriteString(fmt.Sprintf(" (lines %s of %d)", strings.Join(parts, ", "), len(lines)))
+	}
+	sb.WriteString("\n```" + language + "\n")
+
+	next := 1
+	for _, r := range ranges {
+		if r.Start > next {
+			sb.WriteString(fmt.Sprintf("... (lines %d-%d omitted)\n", next, r.Start-1))
+		}
+		for _, line := range lines[r.Start-1 : r.End] {
+			sb.WriteString(line + "\n")
+		}
+		next = r.End + 1
+	}
+	if len(ranges) > 0 && next <= len(lines) {
+		sb.WriteString(fmt.Sprintf("... (lines %d-%d omitted)\n", next, len(lines)))
+	}
+
+	sb.WriteString("```\n\n")
+	return sb.String()
+}
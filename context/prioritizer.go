@@ -7,9 +7,16 @@ import (
 	"strings"
 	"time"
 
+	"github.com/rohanthewiz/logger"
 	"github.com/rohanthewiz/serr"
 )
 
+// SemanticScorer rates files by how closely their content matches a task in meaning.
+// Scores are keyed by file path and range up to 1 for a perfect match.
+type SemanticScorer interface {
+	ScoreFiles(task string) (map[string]float64, error)
+}
+
 // FilePrioritizer prioritizes files based on relevance to a task
 type FilePrioritizer struct {
 	// Weights for different scoring factors
@@ -21,7 +28,13 @@ type FilePrioritizer struct {
 		fileType       float64
 		imports        float64
 		size           float64
+		semantic       float64
+		docs           float64
+		graph          float64
 	}
+
+	// Optional embedding-based scoring, combined with the keyword scores
+	semanticScorer SemanticScorer
 }
 
 // NewFilePrioritizer creates a new file prioritizer with default weights
@@ -36,10 +49,19 @@ func NewFilePrioritizer() *FilePrioritizer {
 	p.weights.fileType = 1.5
 	p.weights.imports = 1.5
 	p.weights.size = -0.5 // Negative weight for size (prefer smaller files)
+	p.weights.semantic = 4.0
+	p.weights.docs = 1.0
+	p.weights.graph = 2.5
 	
 	return p
 }
 
+// SetSemanticScorer enables embedding-based scoring alongside keyword matching. The
+// scorer should rank by meaning, from a model's embeddings, as it is weighted heavily.
+func (p *FilePrioritizer) SetSemanticScorer(scorer SemanticScorer) {
+	p.semanticScorer = scorer
+}
+
 // Prioritize returns a prioritized list of files for a given task
 func (p *FilePrioritizer) Prioritize(ctx *ProjectContext, taskCtx *TaskContext) ([]string, error) {
 	if ctx == nil || ctx.FileTree == nil {
@@ -49,10 +71,31 @@ func (p *FilePrioritizer) Prioritize(ctx *ProjectContext, taskCtx *TaskContext)
 	// Extract keywords from task
 	keywords := p.extractKeywords(taskCtx.Task)
 	taskCtx.SearchTerms = keywords
+	if taskCtx.FileScores == nil {
+		taskCtx.FileScores = make(map[string]float64)
+	}
+
+	// Semantic scores are optional; fall back to keyword scoring alone without them
+	var semanticScores map[string]float64
+	if p.semanticScorer != nil {
+		var err error
+		if semanticScores, err = p.semanticScorer.ScoreFiles(taskCtx.Task); err != nil {
+			logger.Warn("Semantic file scoring failed", "error", err.Error())
+		}
+	}
+
+	// Whole-project scores added to each file's own score
+	boosts := make(map[string]float64)
+	for path, score := range semanticScores {
+		boosts[path] += score * p.weights.semantic
+	}
+	for path, score := range p.scoreGraphProximity(ctx) {
+		boosts[path] += score * p.weights.graph
+	}
 
 	// Score all files
 	fileScores := make(map[string]float64)
-	p.scoreFileTree(ctx.FileTree, ctx, taskCtx, keywords, fileScores)
+	p.scoreFileTree(ctx.FileTree, ctx, taskCtx, keywords, boosts, fileScores)
 
 	// Sort files by score
 	type scoredFile struct {
@@ -83,14 +126,19 @@ func (p *FilePrioritizer) Prioritize(ctx *ProjectContext, taskCtx *TaskContext)
 }
 
 // scoreFileTree recursively scores files in the tree
-func (p *FilePrioritizer) scoreFileTree(node *FileNode, ctx *ProjectContext, taskCtx *TaskContext, keywords []string, scores map[string]float64) {
+func (p *FilePrioritizer) scoreFileTree(node *FileNode, ctx *ProjectContext, taskCtx *TaskContext, keywords []string, boosts, scores map[string]float64) {
 	if node == nil {
 		return
 	}
+	// A session scoped to a package only gets its files; directories above it are passed through
+	if scope := taskCtx.Scope; scope != "" && node.Path != scope && !strings.HasPrefix(node.Path, scope+string(filepath.Separator)) &&
+		!(node.IsDir && strings.HasPrefix(scope, node.Path+string(filepath.Separator))) {
+		return
+	}
 
 	// Score this file if it's not a directory
 	if !node.IsDir {
-		score := p.scoreFile(node, ctx, taskCtx, keywords)
+		score := p.scoreFile(node, ctx, taskCtx, keywords) + boosts[node.Path]
 		if score > 0 {
 			scores[node.Path] = score
 		}
@@ -99,11 +147,41 @@ func (p *FilePrioritizer) scoreFileTree(node *FileNode, ctx *ProjectContext, tas
 	// Recurse into children
 	if node.Children != nil {
 		for _, child := range node.Children {
-			p.scoreFileTree(child, ctx, taskCtx, keywords, scores)
+			p.scoreFileTree(child, ctx, taskCtx, keywords, boosts, scores)
 		}
 	}
 }
 
+// scoreGraphProximity scores files by how few imports separate them from the files being worked on:
+// modified files and the most recently used ones. Direct imports and importers score 1, files two hops away 0.5.
+func (p *FilePrioritizer) scoreGraphProximity(ctx *ProjectContext) map[string]float64 {
+	if ctx.Graph == nil {
+		return nil
+	}
+
+	focus := make([]string, 0, len(ctx.ModifiedFiles)+5)
+	for path := range ctx.ModifiedFiles {
+		focus = append(focus, path)
+	}
+	for i, path := range ctx.RecentFiles {
+		if i >= 5 {
+			break
+		}
+		focus = append(focus, path)
+	}
+	if len(focus) == 0 {
+		return nil
+	}
+
+	scores := make(map[string]float64)
+	for path, distance := range ctx.Graph.Distances(focus, 2) {
+		if distance > 0 { // The focus files already score as recently used or modified
+			scores[path] = 1.0 / float64(distance)
+		}
+	}
+	return scores
+}
+
 // scoreFile calculates the relevance score for a single file
 func (p *FilePrioritizer) scoreFile(node *FileNode, ctx *ProjectContext, taskCtx *TaskContext, keywords []string) float64 {
 	score := 0.0
@@ -159,6 +237,12 @@ func (p *FilePrioritizer) scoreFile(node *FileNode, ctx *ProjectContext, taskCtx
 		score += exportScore * 1.2 // Slight boost for public API
 	}
 
+	// Doc comment relevance (only available from syntax-aware extraction)
+	if len(node.Metadata.Symbols) > 0 {
+		docScore := p.scoreDocs(node.Metadata.Symbols, keywords)
+		score += docScore * p.weights.docs
+	}
+
 	// Size penalty (prefer smaller files)
 	if node.Size > 0 {
 		sizePenalty := math.Log10(float64(node.Size)) / 10.0
@@ -362,6 +446,24 @@ func (p *FilePrioritizer) scoreExports(exports []string, keywords []string) floa
 	return score
 }
 
+// scoreDocs counts the keywords that appear in the doc comments of a file's symbols
+func (p *FilePrioritizer) scoreDocs(symbols []Symbol, keywords []string) float64 {
+	matched := make(map[string]bool)
+	for _, sym := range symbols {
+		if sym.Doc == "" {
+			continue
+		}
+		doc := strings.ToLower(sym.Doc)
+		for _, keyword := range keywords {
+			if !matched[keyword] && strings.Contains(doc, strings.ToLower(keyword)) {
+				matched[keyword] = true
+			}
+		}
+	}
+
+	return float64(len(matched))
+}
+
 // extractKeywords extracts relevant keywords from a task description
 func (p *FilePrioritizer) extractKeywords(task string) []string {
 	// Enhanced NLP-based keyword extraction
@@ -0,0 +1,331 @@
+package context
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Limits on what is kept of the build files, so large ones don't crowd the context prompt
+const (
+	maxMakeTargets  = 30
+	maxRecipeLines  = 3
+	maxRuntimeFiles = 5 // Dockerfiles read from the project root
+)
+
+// Names compose files go by, in the order docker compose looks for them
+var composeFileNames = []string{"compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"}
+
+// makeTargetPattern matches a rule's line: its targets, then a colon that isn't an assignment's
+var makeTargetPattern = regexp.MustCompile(`^([A-Za-z0-9_.\-/ ]+?)\s*::?(?:[^=]|$)`)
+
+// parseRuntime reads how the project is built and run from its Dockerfiles, compose file
+// and Makefile at the root
+func (s *ProjectScanner) parseRuntime(ctx *ProjectContext) {
+	root := ctx.RootPath
+
+	dockerfiles, _ := filepath.Glob(filepath.Join(root, "*Dockerfile*"))
+	sort.Strings(dockerfiles)
+	for _, path := range dockerfiles {
+		if len(ctx.Runtime.Dockerfiles) == maxRuntimeFiles {
+			break
+		}
+		if info, ok := parseDockerfile(path); ok {
+			info.Path = filepath.Base(path)
+			ctx.Runtime.Dockerfiles = append(ctx.Runtime.Dockerfiles, info)
+		}
+	}
+
+	for _, name := range composeFileNames {
+		if services, err := parseComposeFile(filepath.Join(root, name)); err == nil {
+			ctx.Runtime.ComposeFile = name
+			ctx.Runtime.Services = services
+			break
+		}
+	}
+
+	for _, name := range []string{"GNUmakefile", "makefile", "Makefile"} {
+		if targets, err := parseMakefile(filepath.Join(root, name)); err == nil {
+			ctx.Runtime.MakeTargets = targets
+			break
+		}
+	}
+}
+
+// parseDockerfile reads a Dockerfile's stages, reporting whether it is one
+func parseDockerfile(path string) (DockerfileInfo, bool) {
+	var info DockerfileInfo
+	file, err := os.Open(path)
+	if err != nil {
+		return info, false
+	}
+	defer file.Close()
+	if stat, err := file.Stat(); err != nil || stat.IsDir() {
+		return info, false
+	}
+
+	var entrypoint, cmd string
+	var instruction strings.Builder
+	handle := func(line string) {
+		keyword, args, _ := strings.Cut(strings.TrimSpace(line), " ")
+		args = strings.TrimSpace(args)
+		switch strings.ToUpper(keyword) {
+		case "FROM":
+			image := strings.Fields(args)
+			for len(image) > 0 && strings.HasPrefix(image[0], "--") {
+				image = image[1:] // --platform=...
+			}
+			if len(image) > 0 {
+				info.BaseImages = append(info.BaseImages, image[0])
+			}
+			// Each stage starts afresh; what the final one sets is what runs
+			info.ExposedPorts, info.WorkDir, entrypoint, cmd = nil, "", "", ""
+		case "EXPOSE":
+			info.ExposedPorts = append(info.ExposedPorts, strings.Fields(args)...)
+		case "WORKDIR":
+			info.WorkDir = args
+		case "ENTRYPOINT":
+			entrypoint = dockerCommand(args)
+		case "CMD":
+			cmd = dockerCommand(args)
+		}
+	}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if continued, ok := strings.CutSuffix(line, "\\"); ok {
+			instruction.WriteString(continued + " ")
+			continue
+		}
+		instruction.WriteString(line)
+		handle(instruction.String())
+		instruction.Reset()
+	}
+	if instruction.Len() > 0 {
+		handle(instruction.String())
+	}
+	if len(info.BaseImages) == 0 {
+		return info, false
+	}
+
+	info.Command = strings.TrimSpace(entrypoint + " " + cmd)
+	return info, true
+}
+
+// dockerCommand turns the exec form of a command, ["./app", "serve"], into the shell form
+func dockerCommand(args string) string {
+	var parts []string
+	if strings.HasPrefix(args, "[") && json.Unmarshal([]byte(args), &parts) == nil {
+		return strings.Join(parts, " ")
+	}
+	return args
+}
+
+// parseComposeFile reads the services of a docker-compose file
+func parseComposeFile(path string) ([]ComposeService, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var compose struct {
+		Services map[string]struct {
+			Image     string      `yaml:"image"`
+			Build     interface{} `yaml:"build"`      // A context, or a map with one
+			Ports     []yaml.Node `yaml:"ports"`      // Short "8000:8000" or long syntax
+			DependsOn interface{} `yaml:"depends_on"` // A list, or a map of conditions
+			Command   interface{} `yaml:"command"`    // A string or a list
+		} `yaml:"services"`
+	}
+	if err := yaml.Unmarshal(data, &compose); err != nil {
+		return nil, err
+	}
+
+	services := make([]ComposeService, 0, len(compose.Services))
+	for name, spec := range compose.Services {
+		service := ComposeService{Name: name, Image: spec.Image, Command: composeCommand(spec.Command)}
+		switch build := spec.Build.(type) {
+		case string:
+			service.Build = build
+		case map[string]interface{}:
+			service.Build, _ = build["context"].(string)
+			if service.Build == "" {
+				service.Build = "."
+			}
+		}
+		for _, port := range spec.Ports {
+			if port.Kind == yaml.ScalarNode {
+				service.Ports = append(service.Ports, port.Value)
+				continue
+			}
+			var long struct {
+				Target    interface{} `yaml:"target"`
+				Published interface{} `yaml:"published"`
+			}
+			if port.Decode(&long) == nil && long.Target != nil {
+				if long.Published != nil {
+					service.Ports = append(service.Ports, fmt.Sprintf("%v:%v", long.Published, long.Target))
+				} else {
+					service.Ports = append(service.Ports, fmt.Sprint(long.Target))
+				}
+			}
+		}
+		switch dependsOn := spec.DependsOn.(type) {
+		case []interface{}:
+			for _, dep := range dependsOn {
+				service.DependsOn = append(service.DependsOn, fmt.Sprint(dep))
+			}
+		case map[string]interface{}:
+			for dep := range dependsOn {
+				service.DependsOn = append(service.DependsOn, dep)
+			}
+			sort.Strings(service.DependsOn)
+		}
+		services = append(services, service)
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	return services, nil
+}
+
+// composeCommand joins a service's command given as a list
+func composeCommand(command interface{}) string {
+	switch command := command.(type) {
+	case string:
+		return command
+	case []interface{}:
+		parts := make([]string, 0, len(command))
+		for _, part := range command {
+			parts = append(parts, fmt.Sprint(part))
+		}
+		return strings.Join(parts, " ")
+	}
+	return ""
+}
+
+// parseMakefile reads the Makefile's targets with their descriptions and first commands.
+// Special targets, pattern rules and file targets with extensions are left out.
+func parseMakefile(path string) ([]MakeTarget, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var targets []MakeTarget
+	seen := make(map[string]bool)
+	var comment string // The comment above the current line
+	var current []int  // The targets of the rule being read, which share its recipe
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "\t") {
+			recipe := strings.TrimSpace(line)
+			if recipe == "" || strings.HasPrefix(recipe, "#") {
+				continue
+			}
+			for _, i := range current {
+				if len(targets[i].Recipe) < maxRecipeLines {
+					targets[i].Recipe = append(targets[i].Recipe, strings.TrimLeft(recipe, "@-+"))
+				}
+			}
+			continue
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if text, ok := strings.CutPrefix(trimmed, "#"); ok {
+			comment = strings.TrimSpace(strings.TrimLeft(text, "#"))
+			continue
+		}
+		current = current[:0]
+		match := makeTargetPattern.FindStringSubmatch(line)
+		if match == nil || strings.Contains(line, "::=") {
+			comment = ""
+			continue
+		}
+
+		// "build: deps ## Build the binary", the convention of self-documenting Makefiles
+		description := comment
+		if _, help, ok := strings.Cut(line, "##"); ok {
+			description = strings.TrimSpace(help)
+		}
+		comment = ""
+		for _, name := range strings.Fields(match[1]) {
+			if strings.HasPrefix(name, ".") || strings.Contains(name, "%") || strings.Contains(name, "/") ||
+				filepath.Ext(name) != "" || seen[name] || len(targets) == maxMakeTargets {
+				continue
+			}
+			seen[name] = true
+			targets = append(targets, MakeTarget{Name: name, Description: description})
+			current = append(current, len(targets)-1)
+		}
+	}
+	return targets, scanner.Err()
+}
+
+// Summary describes the runtime in a few lines for the model: the make targets to build and
+// run with, the Docker images and the compose services. It is "" when there is none.
+func (r ProjectRuntime) Summary() string {
+	var lines []string
+	if len(r.MakeTargets) > 0 {
+		targets := make([]string, 0, len(r.MakeTargets))
+		for _, target := range r.MakeTargets {
+			entry := target.Name
+			switch {
+			case target.Description != "":
+				entry += " (" + target.Description + ")"
+			case len(target.Recipe) > 0:
+				entry += " (" + strings.Join(target.Recipe, "; ") + ")"
+			}
+			targets = append(targets, entry)
+		}
+		lines = append(lines, "Make targets, the project's own commands to build, test and run it: "+strings.Join(targets, ", "))
+	}
+
+	for _, dockerfile := range r.Dockerfiles {
+		line := fmt.Sprintf("%s builds from %s", dockerfile.Path, strings.Join(dockerfile.BaseImages, ", then "))
+		if len(dockerfile.ExposedPorts) > 0 {
+			line += ", exposes " + strings.Join(dockerfile.ExposedPorts, ", ")
+		}
+		if dockerfile.Command != "" {
+			line += ", runs " + dockerfile.Command
+		}
+		lines = append(lines, line)
+	}
+
+	if len(r.Services) > 0 {
+		services := make([]string, 0, len(r.Services))
+		for _, service := range r.Services {
+			var details []string
+			switch {
+			case service.Build != "":
+				details = append(details, "built from "+service.Build)
+			case service.Image != "":
+				details = append(details, service.Image)
+			}
+			if len(service.Ports) > 0 {
+				details = append(details, "ports "+strings.Join(service.Ports, ", "))
+			}
+			if len(service.DependsOn) > 0 {
+				details = append(details, "needs "+strings.Join(service.DependsOn, ", "))
+			}
+			entry := service.Name
+			if len(details) > 0 {
+				entry += " (" + strings.Join(details, "; ") + ")"
+			}
+			services = append(services, entry)
+		}
+		lines = append(lines, fmt.Sprintf("%s services, run with docker compose: %s", r.ComposeFile, strings.Join(services, ", ")))
+	}
+	return strings.Join(lines, "\n")
+}
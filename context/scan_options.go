@@ -0,0 +1,58 @@
+package context
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Files scanned between progress reports
+const scanProgressInterval = 500
+
+// ScanOptions limit how much work a project scan does on large repositories
+type ScanOptions struct {
+	MaxFiles    int   // Stop adding files to the tree after this many; 0 means no limit
+	MaxFileSize int64 // Files larger than this are listed but not read; 0 means no limit
+	Workers     int   // Files read in parallel when extracting metadata
+	// Progress, if set, is called periodically while metadata is extracted and once when the scan is done.
+	// It may be called from several goroutines.
+	Progress func(ScanProgress)
+}
+
+// ScanProgress reports how far a scan has got
+type ScanProgress struct {
+	RootPath   string `json:"root_path"`
+	FilesFound int    `json:"files_found"` // Files in the tree
+	CodeFiles  int    `json:"code_files"`  // Code files whose metadata is being extracted
+	Processed  int    `json:"processed"`   // Code files done so far
+	CacheHits  int    `json:"cache_hits"`  // Code files unchanged since an earlier scan
+	Truncated  bool   `json:"truncated"`   // MaxFiles was reached
+	Done       bool   `json:"done"`
+}
+
+// DefaultScanOptions returns limits suitable for most repositories
+func DefaultScanOptions() ScanOptions {
+	return ScanOptions{
+		MaxFiles:    20000,
+		MaxFileSize: 1 << 20, // 1MB
+		Workers:     runtime.NumCPU(),
+	}
+}
+
+var (
+	defaultScanOptionsMu sync.RWMutex
+	defaultScanOptions   = DefaultScanOptions()
+)
+
+// SetDefaultScanOptions sets the options used by scanners created after the call
+func SetDefaultScanOptions(opts ScanOptions) {
+	defaultScanOptionsMu.Lock()
+	defer defaultScanOptionsMu.Unlock()
+	defaultScanOptions = opts
+}
+
+// getDefaultScanOptions returns the options for a new scanner
+func getDefaultScanOptions() ScanOptions {
+	defaultScanOptionsMu.RLock()
+	defer defaultScanOptionsMu.RUnlock()
+	return defaultScanOptions
+}
@@ -2,19 +2,39 @@ package context
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"rcode/ignore"
+
 	"github.com/rohanthewiz/serr"
 )
 
 // ProjectScanner scans projects to detect language, framework, and structure
 type ProjectScanner struct {
 	ignorePatterns []string
+	ignore         *ignore.Matcher // Built from ignorePatterns and the project's ignore files on each scan
+	options        ScanOptions
+}
+
+// scanState tracks the files found while building a file tree
+type scanState struct {
+	files     int
+	truncated bool
+	codeFiles []*FileNode // Code files whose metadata is still to be extracted
+	seen      map[string]bool
+}
+
+func newScanState() *scanState {
+	return &scanState{seen: make(map[string]bool)}
 }
 
 // NewProjectScanner creates a new project scanner
@@ -25,9 +45,15 @@ func NewProjectScanner() *ProjectScanner {
 			"__pycache__", ".pytest_cache", "dist", "build",
 			"target", ".idea", ".vscode", "*.pyc", "*.pyo",
 		},
+		options: getDefaultScanOptions(),
 	}
 }
 
+// SetOptions replaces the scanner's limits and progress callback
+func (s *ProjectScanner) SetOptions(opts ScanOptions) {
+	s.options = opts
+}
+
 // Scan analyzes a project directory and returns context
 func (s *ProjectScanner) Scan(rootPath string) (*ProjectContext, error) {
 	absPath, err := filepath.Abs(rootPath)
@@ -44,20 +70,29 @@ func (s *ProjectScanner) Scan(rootPath string) (*ProjectContext, error) {
 		},
 	}
 
+	// Ignore files follow .gitignore rules, including those in subdirectories
+	s.ignore = ignore.NewMatcher(absPath, s.ignorePatterns...)
+
 	// Detect language and framework from config files
 	if err := s.detectProjectType(ctx); err != nil {
 		return nil, serr.Wrap(err, "failed to detect project type")
 	}
 
-	// Load ignore patterns from .gitignore
-	s.loadGitignore(absPath)
-
 	// Build file tree
-	fileTree, err := s.buildFileTree(absPath, absPath)
+	state := newScanState()
+	fileTree, err := s.buildFileTree(absPath, absPath, state)
 	if err != nil {
 		return nil, serr.Wrap(err, "failed to build file tree")
 	}
 	ctx.FileTree = fileTree
+	ctx.Statistics.Truncated = state.truncated
+
+	// Read code files for line counts, imports and symbols
+	ctx.Statistics.SkippedLargeFiles = s.extractAllMetadata(absPath, state)
+	pruneMetadataCache(absPath, state.seen)
+
+	// Link files through their imports
+	ctx.Graph = s.buildDependencyGraph(ctx)
 
 	// Detect project patterns
 	ctx.Patterns = s.detectPatterns(ctx)
@@ -68,22 +103,48 @@ func (s *ProjectScanner) Scan(rootPath string) (*ProjectContext, error) {
 	return ctx, nil
 }
 
-// detectProjectType detects the primary language and framework
+// DetectLanguage returns the primary language of the project at rootPath as Scan detects
+// it, without scanning the rest of the project. It is "" when there is none.
+func DetectLanguage(rootPath string) string {
+	absPath, err := filepath.Abs(rootPath)
+	if err != nil {
+		return ""
+	}
+	s := NewProjectScanner()
+	s.ignore = ignore.NewMatcher(absPath, s.ignorePatterns...)
+	ctx := &ProjectContext{RootPath: absPath}
+	s.detectLanguage(ctx)
+	return ctx.Language
+}
+
+// detectProjectType detects the primary language and framework, how the project is built
+// and run, and the packages of a monorepo
 func (s *ProjectScanner) detectProjectType(ctx *ProjectContext) error {
+	// How the project is built and run, whatever its language
+	s.parseRuntime(ctx)
+
+	s.detectLanguage(ctx)
+	s.detectWorkspace(ctx)
+	return nil
+}
+
+// detectLanguage detects the language and framework of the directory at ctx.RootPath from
+// its manifest, else from its files' extensions
+func (s *ProjectScanner) detectLanguage(ctx *ProjectContext) {
 	rootPath := ctx.RootPath
 
 	// Check for Go
 	if _, err := os.Stat(filepath.Join(rootPath, "go.mod")); err == nil {
 		ctx.Language = "go"
 		s.parseGoMod(ctx)
-		return nil
+		return
 	}
 
 	// Check for Node.js/JavaScript/TypeScript
 	if _, err := os.Stat(filepath.Join(rootPath, "package.json")); err == nil {
 		ctx.Language = "javascript"
 		s.parsePackageJSON(ctx)
-		return nil
+		return
 	}
 
 	// Check for Python
@@ -91,7 +152,7 @@ func (s *ProjectScanner) detectProjectType(ctx *ProjectContext) error {
 		if _, err := os.Stat(filepath.Join(rootPath, file)); err == nil {
 			ctx.Language = "python"
 			s.parsePythonDeps(ctx, file)
-			return nil
+			return
 		}
 	}
 
@@ -99,24 +160,23 @@ func (s *ProjectScanner) detectProjectType(ctx *ProjectContext) error {
 	if _, err := os.Stat(filepath.Join(rootPath, "Cargo.toml")); err == nil {
 		ctx.Language = "rust"
 		// TODO: Parse Cargo.toml
-		return nil
+		return
 	}
 
 	// Check for Java
 	if _, err := os.Stat(filepath.Join(rootPath, "pom.xml")); err == nil {
 		ctx.Language = "java"
 		ctx.Framework = "maven"
-		return nil
+		return
 	}
 	if _, err := os.Stat(filepath.Join(rootPath, "build.gradle")); err == nil {
 		ctx.Language = "java"
 		ctx.Framework = "gradle"
-		return nil
+		return
 	}
 
 	// Default: try to detect from file extensions
 	s.detectFromExtensions(ctx)
-	return nil
 }
 
 // parseGoMod parses go.mod file for dependencies
@@ -294,7 +354,16 @@ func (s *ProjectScanner) detectFromExtensions(ctx *ProjectContext) {
 	extCounts := make(map[string]int)
 
 	filepath.Walk(ctx.RootPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil || info.IsDir() {
+		if err != nil {
+			return nil
+		}
+		if path != ctx.RootPath && s.shouldIgnore(path, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
 			return nil
 		}
 
@@ -394,8 +463,9 @@ func (s *ProjectScanner) findRelevantFilesWithRipgrep(rootPath string) ([]string
 	return allFiles, nil
 }
 
-// buildFileTree builds the file tree structure
-func (s *ProjectScanner) buildFileTree(rootPath, currentPath string) (*FileNode, error) {
+// buildFileTree builds the file tree structure.
+// Code files are collected in state for extractAllMetadata rather than read here.
+func (s *ProjectScanner) buildFileTree(rootPath, currentPath string, state *scanState) (*FileNode, error) {
 	info, err := os.Stat(currentPath)
 	if err != nil {
 		return nil, err
@@ -423,15 +493,19 @@ func (s *ProjectScanner) buildFileTree(rootPath, currentPath string) (*FileNode,
 		}
 
 		for _, entry := range entries {
+			if state.truncated {
+				break
+			}
+
 			name := entry.Name()
-			
-			// Skip ignored patterns
-			if s.shouldIgnore(name) {
+			childPath := filepath.Join(currentPath, name)
+
+			// Skip ignored paths
+			if s.shouldIgnore(childPath, entry.IsDir()) {
 				continue
 			}
 
-			childPath := filepath.Join(currentPath, name)
-			child, err := s.buildFileTree(rootPath, childPath)
+			child, err := s.buildFileTree(rootPath, childPath, state)
 			if err != nil {
 				continue // Skip problematic entries
 			}
@@ -439,29 +513,124 @@ func (s *ProjectScanner) buildFileTree(rootPath, currentPath string) (*FileNode,
 			node.Children[name] = child
 		}
 	} else {
+		if s.options.MaxFiles > 0 && state.files >= s.options.MaxFiles {
+			state.truncated = true
+			return nil, serr.New("file limit reached")
+		}
+		state.files++
+		state.seen[currentPath] = true
+
 		// Detect file language
 		node.Language = s.detectFileLanguage(currentPath)
 		
-		// For code files, extract metadata
+		// Code files get their metadata once the tree is built
 		if isCodeFile(currentPath) {
-			node.Metadata = s.extractFileMetadata(currentPath)
+			state.codeFiles = append(state.codeFiles, node)
 		}
 	}
 
 	return node, nil
 }
 
-// shouldIgnore checks if a path should be ignored
-func (s *ProjectScanner) shouldIgnore(name string) bool {
-	for _, pattern := range s.ignorePatterns {
-		if matched, _ := filepath.Match(pattern, name); matched {
-			return true
-		}
-		if pattern == name {
-			return true
+// extractAllMetadata fills in the metadata of the code files found by buildFileTree,
+// reading up to options.Workers files at a time and reusing cached metadata for unchanged files.
+// It returns the number of files skipped for exceeding options.MaxFileSize.
+func (s *ProjectScanner) extractAllMetadata(rootPath string, state *scanState) int {
+	workers := s.options.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	var processed, cacheHits, skipped int64
+	progress := func(done bool) {
+		if s.options.Progress == nil {
+			return
 		}
+		s.options.Progress(ScanProgress{
+			RootPath:   rootPath,
+			FilesFound: state.files,
+			CodeFiles:  len(state.codeFiles),
+			Processed:  int(atomic.LoadInt64(&processed)),
+			CacheHits:  int(atomic.LoadInt64(&cacheHits)),
+			Truncated:  state.truncated,
+			Done:       done,
+		})
+	}
+
+	nodes := make(chan *FileNode)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for node := range nodes {
+				if s.tooLarge(node) {
+					atomic.AddInt64(&skipped, 1)
+				}
+
+				var hit bool
+				node.Metadata, hit = s.fileMetadata(node)
+				if hit {
+					atomic.AddInt64(&cacheHits, 1)
+				}
+
+				if n := atomic.AddInt64(&processed, 1); n%scanProgressInterval == 0 {
+					progress(false)
+				}
+			}
+		}()
+	}
+
+	for _, node := range state.codeFiles {
+		nodes <- node
 	}
-	return false
+	close(nodes)
+	wg.Wait()
+
+	progress(true)
+	return int(skipped)
+}
+
+// tooLarge reports whether a file exceeds options.MaxFileSize
+func (s *ProjectScanner) tooLarge(node *FileNode) bool {
+	return s.options.MaxFileSize > 0 && node.Size > s.options.MaxFileSize
+}
+
+// fileMetadata returns a code file's metadata from the cache, or extracts and caches it.
+// Files that are too large are not read and get only the flags from their name.
+// The second result reports whether the cache was used.
+func (s *ProjectScanner) fileMetadata(node *FileNode) (FileMetadata, bool) {
+	if s.tooLarge(node) {
+		return s.fileFlags(node.Path), false
+	}
+
+	if metadata, ok := cachedFileMetadata(node.Path, node.Modified, node.Size); ok {
+		return metadata, true
+	}
+
+	metadata := s.extractFileMetadata(node.Path)
+	cacheFileMetadata(node.Path, node.Modified, node.Size, metadata)
+	return metadata, false
+}
+
+// FileSymbols returns the declarations in a code file, scanned or not, using the cached
+// metadata while the file is unchanged
+func (s *ProjectScanner) FileSymbols(path string) ([]Symbol, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to read file", "path", path)
+	}
+	metadata, _ := s.fileMetadata(&FileNode{Path: path, Size: info.Size(), Modified: info.ModTime()})
+	return metadata.Symbols, nil
+}
+
+// shouldIgnore checks if a path should be ignored. Only the path itself is checked,
+// as ignored directories are never descended into.
+func (s *ProjectScanner) shouldIgnore(path string, isDir bool) bool {
+	if s.ignore == nil {
+		return false
+	}
+	return s.ignore.MatchEntry(path, isDir)
 }
 
 // extractGoMetadata extracts Go-specific metadata
@@ -818,8 +987,8 @@ func (s *ProjectScanner) detectFileLanguage(path string) string {
 	return ""
 }
 
-// extractFileMetadata extracts metadata from a code file
-func (s *ProjectScanner) extractFileMetadata(path string) FileMetadata {
+// fileFlags returns the metadata that can be worked out from a file's name alone
+func (s *ProjectScanner) fileFlags(path string) FileMetadata {
 	metadata := FileMetadata{
 		Imports:   make([]string, 0),
 		Exports:   make([]string, 0),
@@ -843,22 +1012,38 @@ func (s *ProjectScanner) extractFileMetadata(path string) FileMetadata {
 	metadata.IsDocumentation = ext == ".md" || ext == ".rst" || 
 		ext == ".txt" || strings.HasPrefix(basename, "README")
 
+	return metadata
+}
+
+// extractFileMetadata extracts metadata from a code file
+func (s *ProjectScanner) extractFileMetadata(path string) FileMetadata {
+	metadata := s.fileFlags(path)
+
 	// Read file and extract metadata based on language
-	file, err := os.Open(path)
+	content, err := os.ReadFile(path)
 	if err != nil {
 		return metadata
 	}
-	defer file.Close()
+	metadata.Lines = bytes.Count(content, []byte("\n"))
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		metadata.Lines++
+	}
 
 	// Detect language
 	lang := s.detectFileLanguage(path)
+
+	// Prefer a real parser; fall back to line matching if there is none or parsing fails
+	if extract, ok := syntaxExtractors[lang]; ok {
+		parsed := metadata
+		if err := extract(path, content, &parsed); err == nil {
+			return parsed
+		}
+	}
 	
-	scanner := bufio.NewScanner(file)
-	lines := 0
+	scanner := bufio.NewScanner(bytes.NewReader(content))
 	inImportBlock := false // For Go multi-line imports
 	
 	for scanner.Scan() {
-		lines++
 		line := scanner.Text()
 		trimmed := strings.TrimSpace(line)
 		
@@ -900,35 +1085,16 @@ func (s *ProjectScanner) extractFileMetadata(path string) FileMetadata {
 		}
 	}
 	
-	metadata.Lines = lines
 	return metadata
 }
 
-// loadGitignore loads patterns from .gitignore
-func (s *ProjectScanner) loadGitignore(rootPath string) {
-	gitignorePath := filepath.Join(rootPath, ".gitignore")
-	file, err := os.Open(gitignorePath)
-	if err != nil {
-		return
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line != "" && !strings.HasPrefix(line, "#") {
-			s.ignorePatterns = append(s.ignorePatterns, line)
-		}
-	}
-}
-
 // detectPatterns detects common project patterns
 func (s *ProjectScanner) detectPatterns(ctx *ProjectContext) ProjectPatterns {
 	patterns := ProjectPatterns{
 		SourceDirs:     make([]string, 0),
 		TestDirs:       make([]string, 0),
 		ConfigFiles:    make([]string, 0),
-		IgnorePatterns: s.ignorePatterns,
+		IgnorePatterns: s.ignore.Patterns(),
 		BuildArtifacts: make([]string, 0),
 	}
 
@@ -995,8 +1161,10 @@ func (s *ProjectScanner) calculateStats(ctx *ProjectContext) {
 	})
 
 	// Sort by size and keep top 10
+	sort.Slice(allFiles, func(i, j int) bool {
+		return allFiles[i].Size > allFiles[j].Size
+	})
 	if len(allFiles) > 10 {
-		// Simple selection of top 10
 		stats.LargestFiles = allFiles[:10]
 	} else {
 		stats.LargestFiles = allFiles
@@ -1030,15 +1198,22 @@ func (s *ProjectScanner) RefreshFile(ctx *ProjectContext, path string) error {
 	}
 
 	// Rebuild just this file's node
-	newNode, err := s.buildFileTree(ctx.RootPath, path)
+	state := newScanState()
+	newNode, err := s.buildFileTree(ctx.RootPath, path, state)
 	if err != nil {
 		return serr.Wrap(err, "failed to refresh file")
 	}
+	for _, node := range state.codeFiles {
+		node.Metadata, _ = s.fileMetadata(node)
+	}
 
 	// Update in parent's children
 	filename := filepath.Base(path)
 	parentNode.Children[filename] = newNode
 
+	// The file's imports may have changed
+	ctx.Graph = s.buildDependencyGraph(ctx)
+
 	return nil
 }
 
@@ -0,0 +1,49 @@
+package context
+
+import (
+	"strings"
+)
+
+// Longest doc comment kept per symbol; the opening sentences carry most of the meaning
+const maxSymbolDocLen = 300
+
+// syntaxExtractor parses a whole file and fills in its imports, symbols and doc comments.
+// It returns an error if the file cannot be parsed, so the line-based extractors can be used instead.
+type syntaxExtractor func(path string, content []byte, metadata *FileMetadata) error
+
+// syntaxExtractors maps a language, as returned by detectFileLanguage, to its parser-based extractor.
+// Go is always parsed with go/parser; other languages are added by the tree-sitter build (see syntax_treesitter.go).
+var syntaxExtractors = map[string]syntaxExtractor{
+	"go": extractGoSyntax,
+}
+
+// addSymbol records a declaration in the metadata's symbol, function, class and export lists
+func addSymbol(metadata *FileMetadata, sym Symbol) {
+	sym.Doc = truncateDoc(sym.Doc)
+	metadata.Symbols = append(metadata.Symbols, sym)
+
+	switch sym.Kind {
+	case "function", "method":
+		metadata.Functions = append(metadata.Functions, sym.Name)
+	case "class", "struct", "interface", "enum", "trait":
+		metadata.Classes = append(metadata.Classes, sym.Name)
+	}
+
+	if sym.Exported {
+		metadata.Exports = append(metadata.Exports, sym.Name)
+	}
+}
+
+// truncateDoc collapses whitespace in a doc comment and limits its length
+func truncateDoc(doc string) string {
+	doc = strings.Join(strings.Fields(doc), " ")
+	if len(doc) <= maxSymbolDocLen {
+		return doc
+	}
+
+	cut := strings.LastIndex(doc[:maxSymbolDocLen], " ")
+	if cut <= 0 {
+		cut = maxSymbolDocLen
+	}
+	return doc[:cut] + "..."
+}
@@ -0,0 +1,122 @@
+package context
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// extractGoSyntax extracts Go imports, declarations and doc comments with go/parser
+func extractGoSyntax(path string, content []byte, metadata *FileMetadata) error {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, content, parser.ParseComments)
+	if file == nil {
+		return serr.Wrap(err, "failed to parse Go file")
+	}
+	// A file with syntax errors still yields the declarations parsed before the error
+
+	for _, imp := range file.Imports {
+		if importPath, err := strconv.Unquote(imp.Path.Value); err == nil {
+			metadata.Imports = append(metadata.Imports, importPath)
+		}
+	}
+
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			kind, receiver := "function", ""
+			if d.Recv != nil {
+				kind = "method"
+				if len(d.Recv.List) > 0 {
+					receiver = goReceiver(d.Recv.List[0].Type)
+				}
+			}
+			addSymbol(metadata, Symbol{
+				Name:     d.Name.Name,
+				Kind:     kind,
+				Line:     fset.Position(d.Pos()).Line,
+				EndLine:  fset.Position(d.End()).Line,
+				Receiver: receiver,
+				Exported: d.Name.IsExported(),
+				Doc:      d.Doc.Text(),
+			})
+
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				// A lone spec is documented on the declaration: "// Foo does x\ntype Foo struct"
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					addSymbol(metadata, Symbol{
+						Name:     s.Name.Name,
+						Kind:     goTypeKind(s),
+						Line:     fset.Position(s.Pos()).Line,
+						Exported: s.Name.IsExported(),
+						Doc:      specDoc(s.Doc, d),
+					})
+
+				case *ast.ValueSpec:
+					kind := "var"
+					if d.Tok == token.CONST {
+						kind = "const"
+					}
+					for _, name := range s.Names {
+						if name.Name == "_" {
+							continue
+						}
+						addSymbol(metadata, Symbol{
+							Name:     name.Name,
+							Kind:     kind,
+							Line:     fset.Position(name.Pos()).Line,
+							Exported: name.IsExported(),
+							Doc:      specDoc(s.Doc, d),
+						})
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// goReceiver names a method's receiver type, without its type parameters: "*Store", "List"
+func goReceiver(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.StarExpr:
+		return "*" + goReceiver(e.X)
+	case *ast.IndexExpr:
+		return goReceiver(e.X)
+	case *ast.IndexListExpr:
+		return goReceiver(e.X)
+	case *ast.Ident:
+		return e.Name
+	default:
+		return ""
+	}
+}
+
+// goTypeKind names the kind of a Go type declaration
+func goTypeKind(spec *ast.TypeSpec) string {
+	switch spec.Type.(type) {
+	case *ast.StructType:
+		return "struct"
+	case *ast.InterfaceType:
+		return "interface"
+	default:
+		return "type"
+	}
+}
+
+// specDoc returns a spec's own doc comment, or its declaration's if the declaration holds only that spec
+func specDoc(doc *ast.CommentGroup, decl *ast.GenDecl) string {
+	if doc != nil {
+		return doc.Text()
+	}
+	if len(decl.Specs) == 1 {
+		return decl.Doc.Text()
+	}
+	return ""
+}
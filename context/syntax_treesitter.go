@@ -0,0 +1,303 @@
+//go:build treesitter
+
+// Tree-sitter extraction for JavaScript, TypeScript, Python, Java and Rust.
+// The grammars are C code compiled with cgo, which adds noticeably to build time,
+// so this is opt-in with: go build -tags treesitter
+// Without the tag these languages use the line-based extractors in scanner.go.
+
+package context
+
+import (
+	gocontext "context"
+	"path/filepath"
+	"strings"
+
+	"github.com/rohanthewiz/serr"
+	sitter "github.com/smacker/go-tree-sitter"
+	"github.com/smacker/go-tree-sitter/java"
+	"github.com/smacker/go-tree-sitter/javascript"
+	"github.com/smacker/go-tree-sitter/python"
+	"github.com/smacker/go-tree-sitter/rust"
+	"github.com/smacker/go-tree-sitter/typescript/tsx"
+	"github.com/smacker/go-tree-sitter/typescript/typescript"
+)
+
+// treeSitterGrammar pairs a grammar with the query that finds its imports and declarations.
+// Queries use these captures: @import for an imported module, @def for a whole declaration,
+// and one of @function, @method, @class, @interface or @type for the declaration's name.
+type treeSitterGrammar struct {
+	language *sitter.Language
+	query    string
+	exported func(def *sitter.Node, name string, content []byte) bool
+	doc      func(def *sitter.Node, content []byte) string
+}
+
+const jsQuery = `
+(import_statement source: (string) @import)
+(function_declaration name: (identifier) @function) @def
+(class_declaration name: (identifier) @class) @def
+(method_definition name: (property_identifier) @method) @def
+(lexical_declaration (variable_declarator name: (identifier) @function value: (arrow_function))) @def
+`
+
+const tsQuery = `
+(import_statement source: (string) @import)
+(function_declaration name: (identifier) @function) @def
+(class_declaration name: (type_identifier) @class) @def
+(abstract_class_declaration name: (type_identifier) @class) @def
+(interface_declaration name: (type_identifier) @interface) @def
+(type_alias_declaration name: (type_identifier) @type) @def
+(method_definition name: (property_identifier) @method) @def
+(lexical_declaration (variable_declarator name: (identifier) @function value: (arrow_function))) @def
+`
+
+const pythonQuery = `
+(import_statement name: (dotted_name) @import)
+(import_statement name: (aliased_import name: (dotted_name) @import))
+(import_from_statement module_name: (dotted_name) @import)
+(import_from_statement module_name: (relative_import) @import)
+(function_definition name: (identifier) @function) @def
+(class_definition name: (identifier) @class) @def
+`
+
+const javaQuery = `
+(import_declaration (scoped_identifier) @import)
+(class_declaration name: (identifier) @class) @def
+(interface_declaration name: (identifier) @interface) @def
+(enum_declaration name: (identifier) @class) @def
+(method_declaration name: (identifier) @method) @def
+`
+
+const rustQuery = `
+(use_declaration argument: (_) @import)
+(function_item name: (identifier) @function) @def
+(struct_item name: (type_identifier) @class) @def
+(enum_item name: (type_identifier) @class) @def
+(trait_item name: (type_identifier) @interface) @def
+(type_item name: (type_identifier) @type) @def
+`
+
+func init() {
+	js := &treeSitterGrammar{language: javascript.GetLanguage(), query: jsQuery, exported: jsExported, doc: commentDoc}
+	ts := &treeSitterGrammar{language: typescript.GetLanguage(), query: tsQuery, exported: jsExported, doc: commentDoc}
+	tsxGrammar := &treeSitterGrammar{language: tsx.GetLanguage(), query: tsQuery, exported: jsExported, doc: commentDoc}
+
+	syntaxExtractors["javascript"] = js.extractFile // The JavaScript grammar includes JSX
+	syntaxExtractors["typescript"] = func(path string, content []byte, metadata *FileMetadata) error {
+		if strings.EqualFold(filepath.Ext(path), ".tsx") {
+			return tsxGrammar.extract(content, metadata)
+		}
+		return ts.extract(content, metadata)
+	}
+	syntaxExtractors["python"] = (&treeSitterGrammar{
+		language: python.GetLanguage(), query: pythonQuery, exported: pythonExported, doc: pythonDocstring,
+	}).extractFile
+	syntaxExtractors["java"] = (&treeSitterGrammar{
+		language: java.GetLanguage(), query: javaQuery, exported: javaExported, doc: javadoc,
+	}).extractFile
+	syntaxExtractors["rust"] = (&treeSitterGrammar{
+		language: rust.GetLanguage(), query: rustQuery, exported: rustExported, doc: rustDoc,
+	}).extractFile
+}
+
+func (g *treeSitterGrammar) extractFile(_ string, content []byte, metadata *FileMetadata) error {
+	return g.extract(content, metadata)
+}
+
+// extract parses content and records the imports and declarations matched by the grammar's query
+func (g *treeSitterGrammar) extract(content []byte, metadata *FileMetadata) error {
+	parser := sitter.NewParser()
+	defer parser.Close()
+	parser.SetLanguage(g.language)
+
+	tree, err := parser.ParseCtx(gocontext.Background(), nil, content)
+	if err != nil {
+		return serr.Wrap(err, "failed to parse file")
+	}
+	defer tree.Close()
+
+	query, err := sitter.NewQuery([]byte(g.query), g.language)
+	if err != nil {
+		return serr.Wrap(err, "invalid tree-sitter query")
+	}
+	defer query.Close()
+
+	cursor := sitter.NewQueryCursor()
+	defer cursor.Close()
+	cursor.Exec(query, tree.RootNode())
+
+	for {
+		match, ok := cursor.NextMatch()
+		if !ok {
+			break
+		}
+
+		var def, name *sitter.Node
+		var kind string
+		for _, capture := range match.Captures {
+			switch captureName := query.CaptureNameForId(capture.Index); captureName {
+			case "import":
+				imp := strings.Trim(capture.Node.Content(content), "\"'`")
+				metadata.Imports = append(metadata.Imports, imp)
+			case "def":
+				def = capture.Node
+			default:
+				name = capture.Node
+				kind = captureName
+			}
+		}
+		if def == nil || name == nil {
+			continue
+		}
+
+		// Methods are functions nested in a class or impl
+		if kind == "function" && hasAncestor(def, "class_definition", "impl_item") {
+			kind = "method"
+		}
+
+		symbolName := name.Content(content)
+		addSymbol(metadata, Symbol{
+			Name:     symbolName,
+			Kind:     kind,
+			Line:     int(def.StartPoint().Row) + 1,
+			Exported: g.exported(def, symbolName, content),
+			Doc:      g.doc(def, content),
+		})
+	}
+
+	return nil
+}
+
+// hasAncestor reports whether any ancestor of node has one of the given types
+func hasAncestor(node *sitter.Node, types ...string) bool {
+	for parent := node.Parent(); parent != nil; parent = parent.Parent() {
+		for _, t := range types {
+			if parent.Type() == t {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// jsExported reports whether a declaration is part of an export statement
+func jsExported(def *sitter.Node, _ string, _ []byte) bool {
+	return hasAncestor(def, "export_statement")
+}
+
+// pythonExported treats module-level names without a leading underscore as public
+func pythonExported(def *sitter.Node, name string, _ []byte) bool {
+	if strings.HasPrefix(name, "_") {
+		return false
+	}
+	parent := def.Parent()
+	if parent != nil && parent.Type() == "decorated_definition" {
+		parent = parent.Parent()
+	}
+	return parent != nil && parent.Type() == "module"
+}
+
+// javaExported reports whether a declaration has the public modifier
+func javaExported(def *sitter.Node, _ string, content []byte) bool {
+	for i := 0; i < int(def.NamedChildCount()); i++ {
+		child := def.NamedChild(i)
+		if child.Type() == "modifiers" {
+			return strings.Contains(child.Content(content), "public")
+		}
+	}
+	return false
+}
+
+// rustExported reports whether a declaration has a pub visibility modifier
+func rustExported(def *sitter.Node, _ string, _ []byte) bool {
+	for i := 0; i < int(def.NamedChildCount()); i++ {
+		if def.NamedChild(i).Type() == "visibility_modifier" {
+			return true
+		}
+	}
+	return false
+}
+
+// leadingComments returns the comments directly above node, joined in source order.
+// Comments separated from the declaration, or from each other, by a blank line are not included.
+func leadingComments(node *sitter.Node, content []byte, keep func(text string) bool) string {
+	// Exported JS/TS declarations are documented above the export statement
+	if parent := node.Parent(); parent != nil && parent.Type() == "export_statement" {
+		node = parent
+	}
+
+	var comments []string
+	line := node.StartPoint().Row
+	for prev := node.PrevSibling(); prev != nil; prev = prev.PrevSibling() {
+		if !strings.Contains(prev.Type(), "comment") || prev.EndPoint().Row+1 < line {
+			break
+		}
+		text := prev.Content(content)
+		if !keep(text) {
+			break
+		}
+		comments = append([]string{text}, comments...)
+		line = prev.StartPoint().Row
+	}
+	return stripCommentMarkers(strings.Join(comments, "\n"))
+}
+
+// commentDoc returns the comment block above a JavaScript or TypeScript declaration
+func commentDoc(def *sitter.Node, content []byte) string {
+	return leadingComments(def, content, func(string) bool { return true })
+}
+
+// javadoc returns the /** */ comment above a Java declaration
+func javadoc(def *sitter.Node, content []byte) string {
+	return leadingComments(def, content, func(text string) bool {
+		return strings.HasPrefix(text, "/**")
+	})
+}
+
+// rustDoc returns the /// or /** */ doc comments above a Rust item
+func rustDoc(def *sitter.Node, content []byte) string {
+	return leadingComments(def, content, func(text string) bool {
+		return strings.HasPrefix(text, "///") || strings.HasPrefix(text, "/**")
+	})
+}
+
+// pythonDocstring returns the string literal that opens a function or class body
+func pythonDocstring(def *sitter.Node, content []byte) string {
+	body := def.ChildByFieldName("body")
+	if body == nil || body.NamedChildCount() == 0 {
+		return ""
+	}
+
+	first := body.NamedChild(0)
+	if first.Type() != "expression_statement" || first.NamedChildCount() == 0 {
+		return ""
+	}
+
+	str := first.NamedChild(0)
+	if str.Type() != "string" {
+		return ""
+	}
+
+	text := str.Content(content)
+	for _, quote := range []string{`"""`, `'''`, `"`, `'`} {
+		if strings.HasPrefix(text, quote) && strings.HasSuffix(text, quote) && len(text) >= 2*len(quote) {
+			return strings.TrimSpace(text[len(quote) : len(text)-len(quote)])
+		}
+	}
+	return text
+}
+
+// stripCommentMarkers removes //, ///, /*, */ and leading * from comment text
+func stripCommentMarkers(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		line = strings.TrimPrefix(line, "/**")
+		line = strings.TrimPrefix(line, "/*")
+		line = strings.TrimSuffix(line, "*/")
+		line = strings.TrimLeft(line, "/")
+		line = strings.TrimPrefix(line, "*")
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
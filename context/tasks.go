@@ -0,0 +1,251 @@
+package context
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Names a justfile goes by, in the order just looks for them
+var justfileNames = []string{"justfile", "Justfile", ".justfile"}
+
+// Lock files telling which package manager runs a project's scripts
+var lockFiles = []struct{ name, runner string }{
+	{"pnpm-lock.yaml", "pnpm"},
+	{"yarn.lock", "yarn"},
+	{"bun.lockb", "bun"},
+	{"bun.lock", "bun"},
+	{"package-lock.json", "npm"},
+}
+
+// justNamePattern matches a recipe's name, after @ for a quiet one
+var justNamePattern = regexp.MustCompile(`^@?([A-Za-z_][A-Za-z0-9_-]*)$`)
+
+// DiscoverTasks returns the commands the project in dir defines for itself: the targets of
+// its Makefile, the recipes of its justfile and the scripts of its package.json, in that
+// order. The files are read afresh, so tasks added since the scan are found.
+func DiscoverTasks(dir string) []ProjectTask {
+	var tasks []ProjectTask
+
+	for _, name := range []string{"GNUmakefile", "makefile", "Makefile"} {
+		targets, err := parseMakefile(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		for _, target := range targets {
+			tasks = append(tasks, ProjectTask{Name: target.Name, Runner: "make", Source: name,
+				Description: target.Description, Commands: target.Recipe, Run: "make " + target.Name})
+		}
+		break
+	}
+
+	for _, name := range justfileNames {
+		if recipes, err := parseJustfile(filepath.Join(dir, name)); err == nil {
+			for i := range recipes {
+				recipes[i].Source = name
+			}
+			tasks = append(tasks, recipes...)
+			break
+		}
+	}
+
+	return append(tasks, packageScripts(dir)...)
+}
+
+// parseJustfile reads the public recipes of a justfile with their parameters, descriptions
+// and first commands. Recipes starting with _ or marked [private] are left out.
+func parseJustfile(path string) ([]ProjectTask, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var recipes []ProjectTask
+	var comment string // The comment above the current line, or the doc attribute
+	private := false   // The attributes above the current line include [private]
+	current := -1      // The recipe whose body is being read
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			command := strings.TrimSpace(line)
+			if current >= 0 && command != "" && !strings.HasPrefix(command, "#") &&
+				len(recipes[current].Commands) < maxRecipeLines {
+				recipes[current].Commands = append(recipes[current].Commands, strings.TrimLeft(command, "@-"))
+			}
+			continue
+		}
+
+		current = -1
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			comment, private = "", false
+			continue
+		case strings.HasPrefix(trimmed, "#"):
+			comment = strings.TrimSpace(strings.TrimLeft(trimmed, "#"))
+			continue
+		case strings.HasPrefix(trimmed, "["):
+			// Attributes: [private], [doc('Build it')], [group('ci'), no-cd]
+			for _, attribute := range splitJustAttributes(strings.Trim(trimmed, "[]")) {
+				name, value, _ := strings.Cut(attribute, "(")
+				switch strings.TrimSpace(name) {
+				case "private":
+					private = true
+				case "doc":
+					comment = strings.Trim(strings.TrimSpace(strings.TrimSuffix(value, ")")), `"'`)
+				}
+			}
+			continue
+		}
+
+		// "test target='debug' *flags: build", but not "version := '1.0'" or "set shell := ['bash']"
+		colon := justHeaderColon(line)
+		if colon < 0 || strings.HasPrefix(line[colon+1:], "=") {
+			comment, private = "", false
+			continue
+		}
+		fields := splitJustFields(line[:colon])
+		match := justNamePattern.FindStringSubmatch(fields[0])
+		if match == nil || strings.HasPrefix(match[1], "_") || private {
+			comment, private = "", false
+			continue
+		}
+
+		recipes = append(recipes, ProjectTask{Name: match[1], Runner: "just", Description: comment,
+			Params: fields[1:], Run: "just " + match[1]})
+		current = len(recipes) - 1
+		comment, private = "", false
+	}
+	return recipes, scanner.Err()
+}
+
+// justHeaderColon returns the index of the colon ending a recipe's name and parameters,
+// outside the quotes of default values; -1 when the line has none
+func justHeaderColon(line string) int {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == ':':
+			return i
+		}
+	}
+	return -1
+}
+
+// splitJustFields splits a recipe's header into its name and parameters, keeping quoted
+// default values whole: "deploy env='dev stage'" gives deploy and env='dev stage'
+func splitJustFields(header string) []string {
+	fields := []string{""}
+	var quote rune
+	for _, r := range header {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == ' ' || r == '\t':
+			if fields[len(fields)-1] != "" {
+				fields = append(fields, "")
+			}
+			continue
+		}
+		fields[len(fields)-1] += string(r)
+	}
+	if len(fields) > 1 && fields[len(fields)-1] == "" {
+		fields = fields[:len(fields)-1]
+	}
+	return fields
+}
+
+// splitJustAttributes splits a line of attributes at the commas outside their arguments
+func splitJustAttributes(attributes string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range attributes {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, attributes[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, attributes[start:])
+}
+
+// packageScripts returns the scripts of the package.json in dir, by name. The pre and post
+// scripts that run around another are left out, as running that one runs them.
+func packageScripts(dir string) []ProjectTask {
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return nil
+	}
+	var pkg struct {
+		Scripts        map[string]string `json:"scripts"`
+		PackageManager string            `json:"packageManager"` // e.g. pnpm@9.1.0
+	}
+	if json.Unmarshal(data, &pkg) != nil || len(pkg.Scripts) == 0 {
+		return nil
+	}
+
+	runner, _, _ := strings.Cut(pkg.PackageManager, "@")
+	if runner == "" {
+		runner = packageManager(dir)
+	}
+
+	names := make([]string, 0, len(pkg.Scripts))
+	for name := range pkg.Scripts {
+		hook, isPre := strings.CutPrefix(name, "pre")
+		if !isPre {
+			hook, _ = strings.CutPrefix(name, "post")
+		}
+		if _, hooked := pkg.Scripts[hook]; hook != name && hooked {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	scripts := make([]ProjectTask, 0, len(names))
+	for _, name := range names {
+		scripts = append(scripts, ProjectTask{Name: name, Runner: runner, Source: "package.json",
+			Commands: []string{pkg.Scripts[name]}, Run: runner + " run " + name})
+	}
+	return scripts
+}
+
+// packageManager returns the package manager whose lock file is in dir or the directories
+// above it, up to the repository's root; npm when there is none
+func packageManager(dir string) string {
+	for {
+		for _, lock := range lockFiles {
+			if _, err := os.Stat(filepath.Join(dir, lock.name)); err == nil {
+				return lock.runner
+			}
+		}
+		parent := filepath.Dir(dir)
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil || parent == dir {
+			return "npm"
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,39 @@
+package context
+
+import (
+	"sync"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+	"github.com/rohanthewiz/logger"
+)
+
+// Anthropic does not publish Claude's tokenizer. cl100k_base counts code within a few
+// percent of it, which is close enough for budgeting as long as budgets leave some headroom.
+const tokenizerEncoding = "cl100k_base"
+
+var (
+	tokenizerOnce sync.Once
+	tokenizer     *tiktoken.Tiktoken // nil if the encoding failed to load
+)
+
+// CountTokens returns the number of tokens in text.
+// If the tokenizer is unavailable it falls back to the WindowOptimizer's estimate.
+func CountTokens(text string) int {
+	tokenizerOnce.Do(func() {
+		// The offline loader embeds the encoding, so no download is needed at runtime
+		tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
+
+		enc, err := tiktoken.GetEncoding(tokenizerEncoding)
+		if err != nil {
+			logger.LogErr(err, "failed to load tokenizer, estimating token counts")
+			return
+		}
+		tokenizer = enc
+	})
+
+	if tokenizer == nil {
+		return NewWindowOptimizer().estimateTokensFromContent(text)
+	}
+	return len(tokenizer.EncodeOrdinary(text))
+}
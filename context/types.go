@@ -15,6 +15,10 @@ type ProjectContext struct {
 	ModifiedFiles map[string]time.Time     `json:"modified_files"`
 	Patterns      ProjectPatterns          `json:"patterns"`
 	Statistics    ProjectStats             `json:"statistics"`
+	Runtime       ProjectRuntime           `json:"runtime"` // From the Dockerfile, compose file and Makefile
+	Workspaces    []string                 `json:"workspaces,omitempty"` // Files declaring a monorepo's packages, e.g. go.work
+	Packages      []WorkspacePackage       `json:"packages,omitempty"`   // The monorepo's packages, by path
+	Graph         *DependencyGraph         `json:"-"` // Import graph between project files
 }
 
 // Dependency represents a project dependency
@@ -24,6 +28,61 @@ type Dependency struct {
 	Type    string `json:"type"` // e.g., "go_module", "npm_package", "pip_package"
 }
 
+// WorkspacePackage is a package of a monorepo, with the language and framework detected in it
+type WorkspacePackage struct {
+	Name      string `json:"name"` // From its manifest, else its directory's name
+	Path      string `json:"path"` // Relative to the project root, with slashes
+	Language  string `json:"language"`
+	Framework string `json:"framework,omitempty"`
+}
+
+// ProjectRuntime describes how the project is built and run, from its Dockerfile,
+// docker-compose.yml and Makefile
+type ProjectRuntime struct {
+	Dockerfiles []DockerfileInfo `json:"dockerfiles,omitempty"`
+	Services    []ComposeService `json:"services,omitempty"`    // Services of the compose file
+	ComposeFile string           `json:"compose_file,omitempty"`
+	MakeTargets []MakeTarget     `json:"make_targets,omitempty"` // In the order of the Makefile
+}
+
+// DockerfileInfo is what a Dockerfile builds: its final stage's image, ports and command
+type DockerfileInfo struct {
+	Path         string   `json:"path"`
+	BaseImages   []string `json:"base_images"` // The FROM of each stage, the final one last
+	ExposedPorts []string `json:"exposed_ports,omitempty"`
+	WorkDir      string   `json:"work_dir,omitempty"`
+	Command      string   `json:"command,omitempty"` // The ENTRYPOINT and CMD run
+}
+
+// ComposeService is a service of a docker-compose file
+type ComposeService struct {
+	Name      string   `json:"name"`
+	Image     string   `json:"image,omitempty"`
+	Build     string   `json:"build,omitempty"` // The build context, when it is built from the project
+	Ports     []string `json:"ports,omitempty"` // As published:container
+	DependsOn []string `json:"depends_on,omitempty"`
+	Command   string   `json:"command,omitempty"`
+}
+
+// MakeTarget is a target of the Makefile
+type MakeTarget struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"` // From a ## comment after it, or a comment above it
+	Recipe      []string `json:"recipe,omitempty"`      // Its first commands
+}
+
+// ProjectTask is a command the project defines for itself: a Makefile target, a justfile
+// recipe or a package.json script
+type ProjectTask struct {
+	Name        string   `json:"name"`
+	Runner      string   `json:"runner"` // make, just, or the package manager: npm, pnpm, yarn or bun
+	Source      string   `json:"source"` // The file defining it
+	Description string   `json:"description,omitempty"`
+	Params      []string `json:"params,omitempty"`   // A just recipe's parameters, as the justfile declares them
+	Commands    []string `json:"commands,omitempty"` // Its first commands, or the script
+	Run         string   `json:"run"`                // The command line running it: make test, npm run build
+}
+
 // FileNode represents a file or directory in the project tree
 type FileNode struct {
 	Name     string               `json:"name"`
@@ -46,6 +105,18 @@ type FileMetadata struct {
 	IsTest        bool     `json:"is_test"`
 	IsConfig      bool     `json:"is_config"`
 	IsDocumentation bool   `json:"is_documentation"`
+	Symbols       []Symbol `json:"symbols,omitempty"`
+}
+
+// Symbol is a declaration found by a syntax-aware extractor
+type Symbol struct {
+	Name     string `json:"name"`
+	Kind     string `json:"kind"` // e.g., "function", "method", "class", "interface", "type"
+	Line     int    `json:"line"`
+	EndLine  int    `json:"end_line,omitempty"` // Last line of the declaration, when the parser knows it
+	Receiver string `json:"receiver,omitempty"` // A method's receiver type, such as *Store
+	Exported bool   `json:"exported"`
+	Doc      string `json:"doc,omitempty"` // Leading doc comment or docstring, truncated
 }
 
 // ProjectPatterns contains detected project patterns
@@ -64,6 +135,8 @@ type ProjectStats struct {
 	TotalLines      int            `json:"total_lines"`
 	FilesByLanguage map[string]int `json:"files_by_language"`
 	LargestFiles    []FileInfo     `json:"largest_files"`
+	Truncated       bool           `json:"truncated"`           // The scan stopped at its file limit
+	SkippedLargeFiles int          `json:"skipped_large_files"` // Code files too large to read; not in TotalLines
 }
 
 // FileInfo represents basic file information
@@ -100,6 +173,7 @@ type TaskContext struct {
 	SearchTerms   []string      `json:"search_terms"`
 	FileScores    map[string]float64 `json:"file_scores"`
 	MaxFiles      int           `json:"max_files"`
+	Scope         string        `json:"scope,omitempty"` // Directory the files are chosen from; "" for the whole project
 }
 
 // ContextWindow represents the current context window
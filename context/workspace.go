@@ -0,0 +1,300 @@
+package context
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Bounds on the packages a monorepo's workspaces declare
+const (
+	maxWorkspacePackages = 200
+	maxWorkspaceDepth    = 4 // Directories below the root a ** pattern or Nx looks in
+)
+
+// detectWorkspace finds the packages of a monorepo from its workspace files: go.work,
+// pnpm-workspace.yaml, the workspaces of package.json (npm, Yarn and Turborepo use these),
+// a Cargo workspace and the project.json files of Nx. Each package gets its own language
+// and framework.
+func (s *ProjectScanner) detectWorkspace(ctx *ProjectContext) {
+	root := ctx.RootPath
+	dirs := make(map[string]bool) // Package directories, relative to root
+	found := func(workspace string, packages []string) {
+		if len(packages) == 0 {
+			return
+		}
+		ctx.Workspaces = append(ctx.Workspaces, workspace)
+		for _, dir := range packages {
+			dirs[dir] = true
+		}
+	}
+
+	found("go.work", goWorkModules(root))
+	if pnpm, err := os.ReadFile(filepath.Join(root, "pnpm-workspace.yaml")); err == nil {
+		var workspace struct {
+			Packages []string `yaml:"packages"`
+		}
+		if yaml.Unmarshal(pnpm, &workspace) == nil {
+			found("pnpm-workspace.yaml", s.expandWorkspace(root, workspace.Packages, "package.json"))
+		}
+	}
+	found("package.json", s.expandWorkspace(root, packageJSONWorkspaces(root), "package.json"))
+	found("Cargo.toml", s.expandWorkspace(root, cargoWorkspaceMembers(root), "Cargo.toml"))
+	if _, err := os.Stat(filepath.Join(root, "nx.json")); err == nil {
+		found("nx.json", s.findManifests(root, "", "project.json"))
+	}
+	if _, err := os.Stat(filepath.Join(root, "turbo.json")); err == nil && len(dirs) > 0 {
+		ctx.Workspaces = append(ctx.Workspaces, "turbo.json") // Turborepo runs the package manager's workspaces
+	}
+
+	paths := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		if dir != "." && dir != "" {
+			paths = append(paths, dir)
+		}
+	}
+	sort.Strings(paths)
+	if len(paths) > maxWorkspacePackages {
+		paths = paths[:maxWorkspacePackages]
+	}
+
+	for _, dir := range paths {
+		pkgCtx := &ProjectContext{RootPath: filepath.Join(root, filepath.FromSlash(dir))}
+		s.detectLanguage(pkgCtx)
+		ctx.Packages = append(ctx.Packages, WorkspacePackage{
+			Name:      packageName(pkgCtx.RootPath),
+			Path:      dir,
+			Language:  pkgCtx.Language,
+			Framework: pkgCtx.Framework,
+		})
+	}
+}
+
+// goWorkModules returns the module directories a go.work uses
+func goWorkModules(root string) []string {
+	file, err := os.Open(filepath.Join(root, "go.work"))
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var modules []string
+	inUse := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "//")
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "use (":
+			inUse = true
+		case inUse && line == ")":
+			inUse = false
+		case inUse && line != "":
+			modules = append(modules, cleanWorkspacePath(strings.Trim(line, `"`)))
+		case strings.HasPrefix(line, "use "):
+			modules = append(modules, cleanWorkspacePath(strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "use ")), `"`)))
+		}
+	}
+	return modules
+}
+
+// packageJSONWorkspaces returns the workspace patterns of the root package.json: a list,
+// or Yarn's object with one
+func packageJSONWorkspaces(root string) []string {
+	data, err := os.ReadFile(filepath.Join(root, "package.json"))
+	if err != nil {
+		return nil
+	}
+	var pkg struct {
+		Workspaces json.RawMessage `json:"workspaces"`
+	}
+	if json.Unmarshal(data, &pkg) != nil || len(pkg.Workspaces) == 0 {
+		return nil
+	}
+	var patterns []string
+	if json.Unmarshal(pkg.Workspaces, &patterns) == nil {
+		return patterns
+	}
+	var yarn struct {
+		Packages []string `json:"packages"`
+	}
+	json.Unmarshal(pkg.Workspaces, &yarn)
+	return yarn.Packages
+}
+
+// cargoWorkspaceMembers returns the members of the [workspace] of the root Cargo.toml, with
+// its excludes as ! patterns
+func cargoWorkspaceMembers(root string) []string {
+	data, err := os.ReadFile(filepath.Join(root, "Cargo.toml"))
+	if err != nil {
+		return nil
+	}
+
+	var members []string
+	var key string // The array being read, members or exclude
+	inWorkspace := false
+	for _, line := range strings.Split(string(data), "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") && key == "" {
+			inWorkspace = line == "[workspace]"
+			continue
+		}
+		if !inWorkspace {
+			continue
+		}
+		if key == "" {
+			name, value, ok := strings.Cut(line, "=")
+			name = strings.TrimSpace(name)
+			if !ok || (name != "members" && name != "exclude") {
+				continue
+			}
+			key, line = name, strings.TrimPrefix(strings.TrimSpace(value), "[")
+		}
+		line, closed := strings.CutSuffix(strings.TrimSpace(line), "]")
+		for _, item := range strings.Split(line, ",") {
+			if item = strings.Trim(strings.TrimSpace(item), `"'`); item != "" {
+				if key == "exclude" {
+					item = "!" + item
+				}
+				members = append(members, item)
+			}
+		}
+		if closed {
+			key = ""
+		}
+	}
+	return members
+}
+
+// expandWorkspace returns the directories, relative to root, that the patterns match and
+// that hold the manifest. Patterns starting with ! leave directories out.
+func (s *ProjectScanner) expandWorkspace(root string, patterns []string, manifest string) []string {
+	var dirs, excludes []string
+	for _, pattern := range patterns {
+		if exclude, ok := strings.CutPrefix(pattern, "!"); ok {
+			excludes = append(excludes, cleanWorkspacePath(exclude))
+			continue
+		}
+		pattern = cleanWorkspacePath(pattern)
+		if prefix, _, ok := strings.Cut(pattern, "**"); ok {
+			dirs = append(dirs, s.findManifests(root, strings.TrimSuffix(prefix, "/"), manifest)...)
+			continue
+		}
+		matches, _ := filepath.Glob(filepath.Join(root, filepath.FromSlash(pattern)))
+		for _, match := range matches {
+			if _, err := os.Stat(filepath.Join(match, manifest)); err == nil {
+				if rel, err := filepath.Rel(root, match); err == nil {
+					dirs = append(dirs, filepath.ToSlash(rel))
+				}
+			}
+		}
+	}
+
+	kept := dirs[:0]
+	for _, dir := range dirs {
+		if !slices.ContainsFunc(excludes, func(exclude string) bool { return workspaceExcludes(exclude, dir) }) {
+			kept = append(kept, dir)
+		}
+	}
+	return kept
+}
+
+// workspaceExcludes reports whether an exclude pattern leaves a directory out: it matches
+// the directory, or ends in /** and the directory is below what comes before
+func workspaceExcludes(exclude, dir string) bool {
+	if under, ok := strings.CutSuffix(exclude, "/**"); ok {
+		return dir == under || strings.HasPrefix(dir, under+"/")
+	}
+	matched, _ := path.Match(exclude, dir)
+	return matched
+}
+
+// findManifests returns the directories below root/under, to maxWorkspaceDepth, that hold
+// the manifest, leaving out ignored directories such as node_modules
+func (s *ProjectScanner) findManifests(root, under, manifest string) []string {
+	var dirs []string
+	start := filepath.Join(root, filepath.FromSlash(under))
+	filepath.WalkDir(start, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, p)
+		if p != start && (s.shouldIgnore(p, true) || strings.Count(filepath.ToSlash(rel), "/") >= maxWorkspaceDepth) {
+			return filepath.SkipDir
+		}
+		if _, err := os.Stat(filepath.Join(p, manifest)); err == nil && rel != "." {
+			dirs = append(dirs, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	return dirs
+}
+
+// cleanWorkspacePath turns a workspace's path or pattern into a clean, slash-separated one
+// relative to the root: "./apps/web/" becomes "apps/web"
+func cleanWorkspacePath(p string) string {
+	return path.Clean(strings.TrimPrefix(filepath.ToSlash(strings.TrimSpace(p)), "./"))
+}
+
+// packageName returns a package's name from its manifest: the name of package.json,
+// project.json or Cargo.toml's [package], or the module of go.mod. It is the directory's
+// name when there is none.
+func packageName(dir string) string {
+	for _, manifest := range []string{"package.json", "project.json"} {
+		if data, err := os.ReadFile(filepath.Join(dir, manifest)); err == nil {
+			var pkg struct {
+				Name string `json:"name"`
+			}
+			if json.Unmarshal(data, &pkg) == nil && pkg.Name != "" {
+				return pkg.Name
+			}
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "go.mod")); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if module, ok := strings.CutPrefix(strings.TrimSpace(line), "module "); ok {
+				return strings.Trim(strings.TrimSpace(module), `"`)
+			}
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "Cargo.toml")); err == nil {
+		inPackage := false
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if strings.HasPrefix(line, "[") {
+				inPackage = line == "[package]"
+				continue
+			}
+			if name, value, ok := strings.Cut(line, "="); ok && inPackage && strings.TrimSpace(name) == "name" {
+				return strings.Trim(strings.TrimSpace(value), `"'`)
+			}
+		}
+	}
+	return filepath.Base(dir)
+}
+
+// FindPackage returns the package with the path or name, nil when the project has none.
+// A path may be given with ./ or a trailing slash.
+func (ctx *ProjectContext) FindPackage(pathOrName string) *WorkspacePackage {
+	clean := cleanWorkspacePath(pathOrName)
+	for i := range ctx.Packages {
+		if ctx.Packages[i].Path == clean {
+			return &ctx.Packages[i]
+		}
+	}
+	for i := range ctx.Packages {
+		if ctx.Packages[i].Name == pathOrName {
+			return &ctx.Packages[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package db
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// API token scopes, from least to most access
+const (
+	ScopeRead  = "read"  // GET requests only
+	ScopeTools = "tools" // Anything a user can do in the browser, but not admin endpoints or managing tokens
+	ScopeAdmin = "admin" // Everything the token's user can do
+)
+
+// APITokenPrefix starts every API token, so they are easy to spot in scripts and logs
+const APITokenPrefix = "rcode_"
+
+// APIToken lets scripts and CI call the API with an Authorization header instead of signing in
+type APIToken struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"` // The start of the token, to tell tokens apart
+	Scope      string     `json:"scope"`
+	UserID     int        `json:"user_id,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// ValidScope reports whether scope is one of the API token scopes
+func ValidScope(scope string) bool {
+	return scope == ScopeRead || scope == ScopeTools || scope == ScopeAdmin
+}
+
+// CreateAPIToken issues a token for a user, or for nobody in single-user mode. It returns
+// the token itself, which is only shown this once, and a duration of 0 never expires.
+func (db *DB) CreateAPIToken(name, scope string, userID int, duration time.Duration) (string, *APIToken, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", nil, serr.New("a token needs a name")
+	}
+	if !ValidScope(scope) {
+		return "", nil, serr.New(fmt.Sprintf("invalid scope %q; use %s, %s or %s", scope, ScopeRead, ScopeTools, ScopeAdmin))
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, serr.Wrap(err, "failed to generate API token")
+	}
+	token := APITokenPrefix + hex.EncodeToString(raw)
+
+	apiToken := &APIToken{Name: name, Prefix: token[:len(APITokenPrefix)+8], Scope: scope, UserID: userID}
+	var expires any // NULL unless the token expires
+	if duration > 0 {
+		expiresAt := time.Now().Add(duration)
+		apiToken.ExpiresAt = &expiresAt
+		expires = expiresAt
+	}
+
+	err := db.WriteRow(`
+		INSERT INTO api_tokens (name, token_hash, prefix, scope, user_id, expires_at)
+		VALUES (?, ?, ?, ?, NULLIF(?, 0), ?)
+		RETURNING id, created_at
+	`, []interface{}{apiToken.Name, hashToken(token), apiToken.Prefix, scope, userID, expires}, &apiToken.ID, &apiToken.CreatedAt)
+	if err != nil {
+		return "", nil, serr.Wrap(err, "failed to create API token")
+	}
+	return token, apiToken, nil
+}
+
+// GetAPIToken returns the token a request presented and notes its use, or nil if it is unknown or expired
+func (db *DB) GetAPIToken(token string) (*APIToken, error) {
+	var apiToken APIToken
+	var userID sql.NullInt64
+	err := db.QueryRow(`
+		SELECT id, name, prefix, scope, user_id, created_at, expires_at
+		FROM api_tokens
+		WHERE token_hash = ? AND (expires_at IS NULL OR expires_at > ?)
+	`, hashToken(token), time.Now()).Scan(&apiToken.ID, &apiToken.Name, &apiToken.Prefix, &apiToken.Scope,
+		&userID, &apiToken.CreatedAt, &apiToken.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to get API token")
+	}
+	apiToken.UserID = int(userID.Int64)
+
+	now := time.Now()
+	if _, err := db.Exec("UPDATE api_tokens SET last_used_at = ? WHERE id = ?", now, apiToken.ID); err != nil {
+		return nil, serr.Wrap(err, "failed to record API token use")
+	}
+	apiToken.LastUsedAt = &now
+	return &apiToken, nil
+}
+
+// ListAPITokens returns a user's tokens, or those issued in single-user mode for user 0, newest first
+func (db *DB) ListAPITokens(userID int) ([]*APIToken, error) {
+	rows, err := db.Query(`
+		SELECT id, name, prefix, scope, created_at, expires_at, last_used_at
+		FROM api_tokens
+		WHERE COALESCE(user_id, 0) = ?
+		ORDER BY id DESC
+	`, userID)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to list API tokens")
+	}
+	defer rows.Close()
+
+	tokens := make([]*APIToken, 0)
+	for rows.Next() {
+		apiToken := APIToken{UserID: userID}
+		if err := rows.Scan(&apiToken.ID, &apiToken.Name, &apiToken.Prefix, &apiToken.Scope,
+			&apiToken.CreatedAt, &apiToken.ExpiresAt, &apiToken.LastUsedAt); err != nil {
+			return nil, serr.Wrap(err, "failed to scan API token row")
+		}
+		tokens = append(tokens, &apiToken)
+	}
+	return tokens, rows.Err()
+}
+
+// DeleteAPIToken revokes one of a user's tokens
+func (db *DB) DeleteAPIToken(id, userID int) error {
+	result, err := db.Exec("DELETE FROM api_tokens WHERE id = ? AND COALESCE(user_id, 0) = ?", id, userID)
+	if err != nil {
+		return serr.Wrap(err, "failed to delete API token")
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return serr.New(fmt.Sprintf("API token %d not found", id))
+	}
+	return nil
+}
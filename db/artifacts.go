@@ -0,0 +1,179 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// Artifact kinds describe what an artifact holds
+const (
+	ArtifactKindToolOutput = "tool_output" // The full output of a tool call that was truncated for the model
+	ArtifactKindReport     = "report"      // Coverage, benchmark, lint and other reports
+	ArtifactKindDocs       = "docs"        // Generated documentation
+	ArtifactKindBuild      = "build"       // Build outputs, such as binaries and archives
+	ArtifactKindOther      = "other"
+)
+
+// ValidArtifactKind reports whether kind is one of the known artifact kinds
+func ValidArtifactKind(kind string) bool {
+	switch kind {
+	case ArtifactKindToolOutput, ArtifactKindReport, ArtifactKindDocs, ArtifactKindBuild, ArtifactKindOther:
+		return true
+	}
+	return false
+}
+
+// Artifact is a file kept for a session, published by a tool or plan or kept from a tool's output
+type Artifact struct {
+	ID          int       `json:"id"`
+	SessionID   string    `json:"session_id"`
+	PlanID      string    `json:"plan_id,omitempty"` // The plan whose step published it
+	Name        string    `json:"name"`
+	Kind        string    `json:"kind"`
+	Description string    `json:"description,omitempty"`
+	ToolUseID   string    `json:"tool_use_id,omitempty"`
+	ContentType string    `json:"content_type"`
+	Content     []byte    `json:"-"`
+	Size        int       `json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ArtifactFilter selects artifacts. Empty fields don't filter.
+type ArtifactFilter struct {
+	SessionID string
+	PlanID    string
+	Kind      string
+}
+
+// artifactColumns are the columns scanned by scanArtifact, which leaves out the content
+const artifactColumns = "id, session_id, plan_id, name, kind, description, tool_use_id, content_type, size, created_at"
+
+// CreateArtifact stores an artifact, setting its ID, size and creation time
+func (db *DB) CreateArtifact(artifact *Artifact) error {
+	if artifact.Kind == "" {
+		artifact.Kind = ArtifactKindOther
+	}
+	if !ValidArtifactKind(artifact.Kind) {
+		return serr.New("unknown artifact kind: " + artifact.Kind)
+	}
+	if artifact.ContentType == "" {
+		artifact.ContentType = "application/octet-stream"
+	}
+	artifact.Size = len(artifact.Content)
+
+	err := db.WriteRow(`
+		INSERT INTO artifacts (session_id, plan_id, name, kind, description, tool_use_id, content_type, content, size)
+		VALUES (?, NULLIF(?, ''), ?, ?, NULLIF(?, ''), NULLIF(?, ''), ?, ?, ?)
+		RETURNING id, created_at
+	`, []interface{}{artifact.SessionID, artifact.PlanID, artifact.Name, artifact.Kind, artifact.Description,
+		artifact.ToolUseID, artifact.ContentType, artifact.Content, artifact.Size}, &artifact.ID, &artifact.CreatedAt)
+	if err != nil {
+		return serr.Wrap(err, "failed to create artifact")
+	}
+	return nil
+}
+
+// GetArtifact returns a session's artifact with its content, or nil if the session has none with the ID
+func (db *DB) GetArtifact(sessionID string, id int) (*Artifact, error) {
+	row := db.QueryRow(`
+		SELECT `+artifactColumns+`, content
+		FROM artifacts
+		WHERE id = ? AND session_id = ?
+	`, id, sessionID)
+
+	artifact, err := scanArtifact(row.Scan, new([]byte))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to get artifact")
+	}
+	return artifact, nil
+}
+
+// ListArtifacts returns the artifacts a filter selects, newest first, without their content
+func (db *DB) ListArtifacts(filter ArtifactFilter) ([]*Artifact, error) {
+	query := "SELECT " + artifactColumns + " FROM artifacts WHERE 1 = 1"
+	var args []interface{}
+	if filter.SessionID != "" {
+		query += " AND session_id = ?"
+		args = append(args, filter.SessionID)
+	}
+	if filter.PlanID != "" {
+		query += " AND plan_id = ?"
+		args = append(args, filter.PlanID)
+	}
+	if filter.Kind != "" {
+		query += " AND kind = ?"
+		args = append(args, filter.Kind)
+	}
+	query += " ORDER BY id DESC"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to list artifacts")
+	}
+	defer rows.Close()
+
+	artifacts := []*Artifact{}
+	for rows.Next() {
+		artifact, err := scanArtifact(rows.Scan, nil)
+		if err != nil {
+			return nil, serr.Wrap(err, "failed to scan artifact")
+		}
+		artifacts = append(artifacts, artifact)
+	}
+	return artifacts, rows.Err()
+}
+
+// scanArtifact reads the artifactColumns, and the content when content isn't nil
+func scanArtifact(scan func(dest ...interface{}) error, content *[]byte) (*Artifact, error) {
+	artifact := &Artifact{}
+	var planID, description, toolUseID sql.NullString
+	dest := []interface{}{&artifact.ID, &artifact.SessionID, &planID, &artifact.Name, &artifact.Kind, &description,
+		&toolUseID, &artifact.ContentType, &artifact.Size, &artifact.CreatedAt}
+	if content != nil {
+		dest = append(dest, content)
+	}
+	if err := scan(dest...); err != nil {
+		return nil, err
+	}
+
+	artifact.PlanID = planID.String
+	artifact.Description = description.String
+	artifact.ToolUseID = toolUseID.String
+	if content != nil {
+		artifact.Content = *content
+	}
+	return artifact, nil
+}
+
+// DeleteArtifact removes a session's artifact, reporting whether it had one with the ID
+func (db *DB) DeleteArtifact(sessionID string, id int) (bool, error) {
+	result, err := db.Exec("DELETE FROM artifacts WHERE id = ? AND session_id = ?", id, sessionID)
+	if err != nil {
+		return false, serr.Wrap(err, "failed to delete artifact")
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// PruneArtifacts deletes the artifacts created before the given time, returning how many
+func (db *DB) PruneArtifacts(before time.Time) (int64, error) {
+	result, err := db.Exec("DELETE FROM artifacts WHERE created_at < ?", before)
+	if err != nil {
+		return 0, serr.Wrap(err, "failed to delete old artifacts")
+	}
+	deleted, _ := result.RowsAffected()
+	return deleted, nil
+}
+
+// DeleteSessionArtifacts removes a session's artifacts
+func (db *DB) DeleteSessionArtifacts(sessionID string) error {
+	if _, err := db.Exec("DELETE FROM artifacts WHERE session_id = ?", sessionID); err != nil {
+		return serr.Wrap(err, "failed to delete artifacts")
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"database/sql"
+	"io/fs"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// Backend is a database engine rcode can keep its data in, chosen with RCODE_DB_BACKEND:
+// DuckDB, the default, or Postgres. The queries in this package and the migrations are
+// written in the SQL both take, with ? placeholders; ARRAY[...] literals, array_to_string
+// and = ANY rather than DuckDB's list functions, and VARCHAR casts of JSON columns that
+// are compared, as Postgres has no equality for JSON.
+type Backend interface {
+	// Name is the value of RCODE_DB_BACKEND that selects the backend
+	Name() string
+	// Open connects to the database at url, RCODE_DB_URL, or at the backend's default when
+	// url is empty. It returns where it connected, for the log.
+	Open(url string) (conn *sql.DB, location string, err error)
+	// Migrations holds the backend's migrations/*.sql files, see migrationFiles
+	Migrations() fs.FS
+	// IsBusy reports whether err is the engine refusing work another transaction or
+	// process is in the way of, which is worth retrying shortly
+	IsBusy(err error) bool
+	// Backup writes a consistent copy of the database at location to the file dest. It is
+	// called while no write runs.
+	Backup(conn *sql.DB, location, dest string) error
+	// Verify checks that a copy Backup wrote can be read in full, and returns the latest
+	// migration applied to it
+	Verify(path string) (schemaVersion int, err error)
+	// StageRestore sets a verified copy aside to replace the database at location the next
+	// time Open opens it, as the database can't be replaced while it is open
+	StageRestore(location, src string) error
+}
+
+var (
+	backends   = make(map[string]Backend)
+	backendsMu sync.Mutex
+)
+
+// RegisterBackend makes a backend available to RCODE_DB_BACKEND, from its file's init
+func RegisterBackend(backend Backend) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	backends[backend.Name()] = backend
+}
+
+// backendNamed returns the registered backend with the name, duckdb when it is empty
+func backendNamed(name string) (Backend, error) {
+	if name == "" {
+		name = DefaultBackend
+	}
+
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	if backend, ok := backends[name]; ok {
+		return backend, nil
+	}
+
+	names := make([]string, 0, len(backends))
+	for n := range backends {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return nil, serr.New("database backend " + name + " isn't available in this build; use " + strings.Join(names, " or "))
+}
@@ -0,0 +1,207 @@
+package db
+
+import (
+	"database/sql"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	_ "github.com/marcboeker/go-duckdb/v2"
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/serr"
+)
+
+const (
+	// DefaultBackend is the backend used when RCODE_DB_BACKEND is unset
+	DefaultBackend = "duckdb"
+
+	// restoreSuffix names a backup staged beside the database, put in its place when it is next opened
+	restoreSuffix = ".restore"
+)
+
+func init() {
+	RegisterBackend(duckdbBackend{})
+}
+
+// duckdbBackend keeps the data in an embedded DuckDB file, by default
+// ~/.local/share/rcode/rcode.db. Only one process can have the file open.
+type duckdbBackend struct{}
+
+func (duckdbBackend) Name() string { return "duckdb" }
+
+func (duckdbBackend) Open(url string) (*sql.DB, string, error) {
+	dbPath := url
+	if dbPath == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, "", serr.Wrap(err, "failed to get home directory")
+		}
+		dbPath = filepath.Join(homeDir, ".local", "share", "rcode", "rcode.db")
+	}
+
+	// Create directory if it doesn't exist
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0700); err != nil {
+		return nil, "", serr.Wrap(err, "failed to create data directory")
+	}
+	if err := applyStagedRestore(dbPath); err != nil {
+		return nil, "", err
+	}
+
+	conn, err := sql.Open("duckdb", dbPath)
+	if err != nil {
+		return nil, "", serr.Wrap(err, "failed to open database")
+	}
+
+	// Test connection
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, "", serr.Wrap(err, "failed to ping database")
+	}
+	return conn, dbPath, nil
+}
+
+func (duckdbBackend) Migrations() fs.FS { return migrationFiles }
+
+// IsBusy reports whether err is DuckDB refusing a write that conflicted with another
+// transaction, or refusing to open a file another process has open
+func (duckdbBackend) IsBusy(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "conflict on") || strings.Contains(msg, "write-write conflict") ||
+		strings.Contains(msg, "could not set lock")
+}
+
+// Backup copies the database file to dest. Writes are held back meanwhile, so the file and
+// its write-ahead log don't change; the copy's log is then folded into it, leaving one file.
+func (duckdbBackend) Backup(conn *sql.DB, location, dest string) error {
+	// Fold the log into the file first when no transaction is in the way; it is copied otherwise
+	if _, err := conn.Exec("CHECKPOINT"); err != nil {
+		logger.Debug("Backing up without a checkpoint", "error", err.Error())
+	}
+	if err := copyFile(location, dest); err != nil {
+		return err
+	}
+	if _, err := os.Stat(location + ".wal"); err == nil {
+		if err := copyFile(location+".wal", dest+".wal"); err != nil {
+			return err
+		}
+	}
+
+	copyConn, err := sql.Open("duckdb", dest)
+	if err != nil {
+		return serr.Wrap(err, "failed to open backup")
+	}
+	_, err = copyConn.Exec("CHECKPOINT")
+	if closeErr := copyConn.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return serr.Wrap(err, "failed to checkpoint backup")
+	}
+	_ = os.Remove(dest + ".wal")
+	return nil
+}
+
+// Verify opens the backup read-only and reads every row of every table, which fails on a
+// block whose checksum doesn't match, and returns the latest migration applied to it
+func (duckdbBackend) Verify(path string) (int, error) {
+	conn, err := sql.Open("duckdb", path+"?access_mode=read_only")
+	if err != nil {
+		return 0, serr.Wrap(err, "failed to open backup")
+	}
+	defer conn.Close()
+
+	rows, err := conn.Query(`
+		SELECT table_name FROM information_schema.tables
+		WHERE table_schema = 'main' AND table_type = 'BASE TABLE'
+	`)
+	if err != nil {
+		return 0, serr.Wrap(err, "failed to list the backup's tables")
+	}
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			rows.Close()
+			return 0, serr.Wrap(err, "failed to list the backup's tables")
+		}
+		tables = append(tables, table)
+	}
+	rows.Close()
+
+	for _, table := range tables {
+		// The table as a row value makes DuckDB read all of its columns
+		var n int64
+		quoted := `"` + strings.ReplaceAll(table, `"`, `""`) + `"`
+		if err := conn.QueryRow("SELECT COUNT(*) FROM " + quoted + " t WHERE t IS NOT NULL").Scan(&n); err != nil {
+			return 0, serr.Wrap(err, "failed to read table "+table+" of the backup")
+		}
+	}
+
+	var version int
+	if err := conn.QueryRow("SELECT COALESCE(MAX(version), 0) FROM migrations").Scan(&version); err != nil {
+		return 0, serr.Wrap(err, "failed to read the backup's migrations")
+	}
+	return version, nil
+}
+
+// StageRestore copies src beside the database, for Open to put in its place
+func (duckdbBackend) StageRestore(location, src string) error {
+	tmp := location + restoreSuffix + ".tmp"
+	if err := copyFile(src, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, location+restoreSuffix); err != nil {
+		os.Remove(tmp)
+		return serr.Wrap(err, "failed to stage restore")
+	}
+	return nil
+}
+
+// applyStagedRestore puts a copy StageRestore left in place of the database, keeping the
+// database it replaces, and its log, as .pre-restore
+func applyStagedRestore(location string) error {
+	staged := location + restoreSuffix
+	if _, err := os.Stat(staged); err != nil {
+		return nil
+	}
+
+	kept := location + ".pre-restore"
+	os.Remove(kept)
+	os.Remove(kept + ".wal")
+	if err := os.Rename(location, kept); err != nil && !os.IsNotExist(err) {
+		return serr.Wrap(err, "failed to set aside the database being restored over")
+	}
+	if err := os.Rename(location+".wal", kept+".wal"); err != nil && !os.IsNotExist(err) {
+		return serr.Wrap(err, "failed to set aside the database's log")
+	}
+	if err := os.Rename(staged, location); err != nil {
+		return serr.Wrap(err, "failed to restore database")
+	}
+	logger.Warn("Restored the database from a backup", "path", location, "previous", kept)
+	return nil
+}
+
+// copyFile copies src to dest and syncs it to disk
+func copyFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return serr.Wrap(err, "failed to open "+src)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return serr.Wrap(err, "failed to create "+dest)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return serr.Wrap(err, "failed to copy "+src)
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return serr.Wrap(err, "failed to sync "+dest)
+	}
+	return out.Close()
+}
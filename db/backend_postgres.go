@@ -0,0 +1,377 @@
+package db
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"io/fs"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/serr"
+)
+
+// Postgres error codes worth retrying: a serialization failure, a deadlock, a lock that
+// wasn't available and a server that is starting up
+var postgresBusyCodes = []string{"40001", "40P01", "55P03", "57P03"}
+
+// cosineSimilarityFunction gives Postgres DuckDB's list_cosine_similarity, which
+// SearchChunks ranks code chunks by
+const cosineSimilarityFunction = `
+	CREATE OR REPLACE FUNCTION list_cosine_similarity(a REAL[], b REAL[]) RETURNS DOUBLE PRECISION
+	LANGUAGE SQL IMMUTABLE AS $$
+		SELECT SUM(x::DOUBLE PRECISION * y) / NULLIF(SQRT(SUM(x::DOUBLE PRECISION * x)) * SQRT(SUM(y::DOUBLE PRECISION * y)), 0)
+		FROM unnest(a, b) AS t(x, y)
+	$$
+`
+
+func init() {
+	RegisterBackend(&postgresBackend{})
+}
+
+// postgresBackend keeps the data in a Postgres database, for several rcode servers or a
+// database managed apart from rcode. RCODE_DB_URL names it as a postgres:// URL. Backups
+// are taken with pg_dump and restored with pg_restore, which must be installed.
+type postgresBackend struct {
+	url string // RCODE_DB_URL, with its password, for pg_dump
+}
+
+func (*postgresBackend) Name() string { return "postgres" }
+
+func (b *postgresBackend) Open(dsn string) (*sql.DB, string, error) {
+	u, err := url.Parse(dsn)
+	if dsn == "" || err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") {
+		return nil, "", serr.New("the postgres backend needs RCODE_DB_URL set to a postgres:// URL, e.g. postgres://rcode@localhost:5432/rcode")
+	}
+	location := u.Redacted()
+
+	if err := applyStagedPostgresRestore(dsn, location); err != nil {
+		return nil, "", err
+	}
+
+	connector, err := newPostgresConnector(stdlib.GetDefaultDriver(), dsn)
+	if err != nil {
+		return nil, "", err
+	}
+	conn := sql.OpenDB(connector)
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, "", serr.Wrap(err, "failed to connect to database")
+	}
+	if _, err := conn.Exec(cosineSimilarityFunction); err != nil {
+		conn.Close()
+		return nil, "", serr.Wrap(err, "failed to create list_cosine_similarity")
+	}
+	b.url = dsn
+	return conn, location, nil
+}
+
+func (*postgresBackend) Migrations() fs.FS { return migrationFiles }
+
+// IsBusy reports whether err is Postgres failing a transaction that conflicted with another,
+// or not yet taking connections
+func (*postgresBackend) IsBusy(err error) bool {
+	msg := err.Error()
+	for _, code := range postgresBusyCodes {
+		if strings.Contains(msg, "SQLSTATE "+code) {
+			return true
+		}
+	}
+	return false
+}
+
+// Backup dumps the database to dest with pg_dump, in its custom format. pg_dump reads
+// from one snapshot, so the dump is consistent whatever writes run meanwhile.
+func (b *postgresBackend) Backup(_ *sql.DB, _, dest string) error {
+	if _, err := runPostgresClient("pg_dump", b.url, "--format=custom", "--no-owner", "--file="+dest); err != nil {
+		return serr.Wrap(err, "failed to dump database")
+	}
+	return nil
+}
+
+// Verify reads the whole dump with pg_restore, which fails on a damaged one, and returns
+// the latest migration of the migrations table it holds
+func (*postgresBackend) Verify(path string) (int, error) {
+	if _, err := runPostgresClient("pg_restore", "", "--file="+os.DevNull, path); err != nil {
+		return 0, serr.Wrap(err, "failed to read the backup")
+	}
+	data, err := runPostgresClient("pg_restore", "", "--data-only", "--table=migrations", "--file=-", path)
+	if err != nil {
+		return 0, serr.Wrap(err, "failed to read the backup's migrations")
+	}
+	return dumpedMigrationVersion(string(data))
+}
+
+// StageRestore copies src to rcode's data directory, for Open to restore before it next
+// connects to the database at location
+func (*postgresBackend) StageRestore(location, src string) error {
+	staged, err := postgresStagePath(location)
+	if err != nil {
+		return err
+	}
+	tmp := staged + ".tmp"
+	if err := copyFile(src, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, staged); err != nil {
+		os.Remove(tmp)
+		return serr.Wrap(err, "failed to stage restore")
+	}
+	return nil
+}
+
+// postgresStagePath returns where a restore of the database at location is staged: rcode's
+// data directory, under a name the location is hashed into, so a restore is only applied
+// to the database it was staged for
+func postgresStagePath(location string) (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", serr.Wrap(err, "failed to get home directory")
+	}
+	dir := filepath.Join(homeDir, ".local", "share", "rcode")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", serr.Wrap(err, "failed to create data directory")
+	}
+	sum := sha256.Sum256([]byte(location))
+	return filepath.Join(dir, "postgres-"+hex.EncodeToString(sum[:6])+restoreSuffix), nil
+}
+
+// applyStagedPostgresRestore restores a dump StageRestore left for the database, in one
+// transaction, after dumping the database it replaces beside it as .pre-restore
+func applyStagedPostgresRestore(dsn, location string) error {
+	staged, err := postgresStagePath(location)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(staged); err != nil {
+		return nil
+	}
+
+	kept := strings.TrimSuffix(staged, restoreSuffix) + ".pre-restore"
+	if _, err := runPostgresClient("pg_dump", dsn, "--format=custom", "--no-owner", "--file="+kept); err != nil {
+		return serr.Wrap(err, "failed to set aside the database being restored over")
+	}
+	if _, err := runPostgresClient("pg_restore", dsn, "--clean", "--if-exists", "--no-owner", "--single-transaction", staged); err != nil {
+		return serr.Wrap(err, "failed to restore database")
+	}
+	if err := os.Remove(staged); err != nil {
+		return serr.Wrap(err, "failed to remove the restored backup")
+	}
+	logger.Warn("Restored the database from a backup", "database", location, "previous", kept)
+	return nil
+}
+
+// runPostgresClient runs pg_dump or pg_restore and returns its output. A dsn is passed as
+// --dbname with its password in the environment, so it doesn't show in the process list.
+func runPostgresClient(client, dsn string, args ...string) ([]byte, error) {
+	path, err := exec.LookPath(client)
+	if err != nil {
+		return nil, serr.New(client + " is needed for backups of a Postgres database, and is not installed")
+	}
+
+	env := os.Environ()
+	if dsn != "" {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return nil, serr.Wrap(err, "invalid database URL")
+		}
+		if password, ok := u.User.Password(); ok {
+			env = append(env, "PGPASSWORD="+password)
+			u.User = url.User(u.User.Username())
+		}
+		args = append([]string{"--dbname=" + u.String()}, args...)
+	}
+
+	cmd := exec.Command(path, args...)
+	cmd.Env = env
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, serr.Wrap(err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
+
+// dumpedMigrationVersion returns the latest version in the COPY data of the migrations
+// table that pg_restore --data-only writes out
+func dumpedMigrationVersion(script string) (int, error) {
+	version, copying, found := 0, false, false
+	scanner := bufio.NewScanner(strings.NewReader(script))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "COPY ") && strings.Contains(line, "migrations"):
+			copying, found = true, true
+		case !copying:
+		case line == `\.`:
+			copying = false
+		default:
+			field, _, _ := strings.Cut(line, "\t")
+			v, err := strconv.Atoi(field)
+			if err != nil {
+				return 0, serr.New("unexpected row in the backup's migrations: " + line)
+			}
+			version = max(version, v)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, serr.Wrap(err, "failed to read the backup's migrations")
+	}
+	if !found {
+		return 0, serr.New("the backup has no migrations table")
+	}
+	return version, nil
+}
+
+// postgresConnector opens connections through the Postgres driver that take the queries'
+// ? placeholders, see postgresQuery
+type postgresConnector struct {
+	driver.Connector
+}
+
+// newPostgresConnector returns a connector to the database at dsn through the driver
+func newPostgresConnector(d driver.Driver, dsn string) (driver.Connector, error) {
+	dc, ok := d.(driver.DriverContext)
+	if !ok {
+		return nil, serr.New("the Postgres driver can't open connectors")
+	}
+	connector, err := dc.OpenConnector(dsn)
+	if err != nil {
+		return nil, serr.Wrap(err, "invalid database URL")
+	}
+	return postgresConnector{connector}, nil
+}
+
+func (c postgresConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return postgresConn{conn}, nil
+}
+
+// postgresConn is a connection of the Postgres driver whose queries have their ?
+// placeholders numbered. What else the driver's connection does is passed on to it.
+type postgresConn struct {
+	driver.Conn
+}
+
+func (c postgresConn) Prepare(query string) (driver.Stmt, error) {
+	return c.Conn.Prepare(postgresQuery(query))
+}
+
+func (c postgresConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return p.PrepareContext(ctx, postgresQuery(query))
+	}
+	return c.Prepare(query)
+}
+
+func (c postgresConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if e, ok := c.Conn.(driver.ExecerContext); ok {
+		return e.ExecContext(ctx, postgresQuery(query), args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c postgresConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if q, ok := c.Conn.(driver.QueryerContext); ok {
+		return q.QueryContext(ctx, postgresQuery(query), args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c postgresConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin() // The driver has nothing newer
+}
+
+// CheckNamedValue lets the driver take the arguments it converts itself, such as slices
+// for array columns
+func (c postgresConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+func (c postgresConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c postgresConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c postgresConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+// postgresQuery numbers the ? placeholders of a query as Postgres's $1, $2..., leaving
+// those in string literals, quoted identifiers and comments alone
+func postgresQuery(query string) string {
+	if !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 16)
+	n := 0
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(query[i+1:], c)
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+2])
+			i += end + 1
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end])
+			i += end - 1
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+4])
+			i += end + 3
+		case c == '?':
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
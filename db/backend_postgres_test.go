@@ -0,0 +1,155 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestPostgresQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{name: "no placeholders", query: "SELECT 1", want: "SELECT 1"},
+		{name: "numbered in order", query: "UPDATE t SET a = ?, b = ? WHERE id = ?", want: "UPDATE t SET a = $1, b = $2 WHERE id = $3"},
+		{name: "cast", query: "SELECT ?::JSON", want: "SELECT $1::JSON"},
+		{name: "string literal", query: "SELECT '?' WHERE a = ?", want: "SELECT '?' WHERE a = $1"},
+		{name: "escaped quote", query: "SELECT 'it''s ?' WHERE a = ?", want: "SELECT 'it''s ?' WHERE a = $1"},
+		{name: "quoted identifier", query: `SELECT "a?" FROM t WHERE b = ?`, want: `SELECT "a?" FROM t WHERE b = $1`},
+		{name: "line comment", query: "SELECT ? -- why?\nWHERE a = ?", want: "SELECT $1 -- why?\nWHERE a = $2"},
+		{name: "block comment", query: "SELECT /* ? */ ?", want: "SELECT /* ? */ $1"},
+		{name: "unterminated literal", query: "SELECT ?, 'a?", want: "SELECT $1, 'a?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := postgresQuery(tt.query); got != tt.want {
+				t.Errorf("postgresQuery(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDumpedMigrationVersion(t *testing.T) {
+	script := "--\n-- PostgreSQL database dump\n--\n\n" +
+		"COPY public.migrations (version, name, applied_at) FROM stdin;\n" +
+		"1\tinitial\t2025-01-01 00:00:00\n" +
+		"34\tadd_coverage\t2025-06-01 00:00:00\n" +
+		"12\tadd_tasks\t2025-03-01 00:00:00\n" +
+		"\\.\n\n"
+	version, err := dumpedMigrationVersion(script)
+	if err != nil {
+		t.Fatalf("dumpedMigrationVersion: %v", err)
+	}
+	if version != 34 {
+		t.Errorf("version = %d, want 34", version)
+	}
+
+	if _, err := dumpedMigrationVersion("--\n-- PostgreSQL database dump\n--\n"); err == nil {
+		t.Error("a dump without a migrations table was accepted")
+	}
+}
+
+func TestPostgresIsBusy(t *testing.T) {
+	b := &postgresBackend{}
+	if !b.IsBusy(errors.New("ERROR: could not serialize access (SQLSTATE 40001)")) {
+		t.Error("a serialization failure is not busy")
+	}
+	if b.IsBusy(errors.New(`ERROR: relation "x" does not exist (SQLSTATE 42P01)`)) {
+		t.Error("a missing relation is busy")
+	}
+}
+
+// TestPostgresConn runs the queries through the connection wrapper, which numbers their
+// placeholders as Postgres wants, over DuckDB, which takes $n placeholders too
+func TestPostgresConn(t *testing.T) {
+	duck, err := sql.Open("duckdb", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer duck.Close()
+	connector, err := newPostgresConnector(duck.Driver(), "")
+	if err != nil {
+		t.Fatalf("newPostgresConnector: %v", err)
+	}
+	db := &DB{conn: sql.OpenDB(connector), backend: duckdbBackend{}}
+	defer db.Close()
+
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	prompts := []string{"Be brief", "Say what's changed"}
+	session, err := db.CreateSession(SessionOptions{Title: "wrapped", InitialPrompts: prompts})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	got, err := db.GetSession(session.ID)
+	if err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+	if len(got.InitialPrompts) != len(prompts) || got.InitialPrompts[1] != prompts[1] {
+		t.Errorf("InitialPrompts = %q, want %q", got.InitialPrompts, prompts)
+	}
+	found, err := db.SearchSessions(prompts[0])
+	if err != nil {
+		t.Fatalf("SearchSessions: %v", err)
+	}
+	if len(found) != 1 || found[0].ID != session.ID {
+		t.Errorf("SearchSessions(%q) found %d sessions, want the one created", prompts[0], len(found))
+	}
+
+	chunks := []CodeChunk{
+		{FileHash: "h", Model: "m", StartLine: 1, EndLine: 5, Content: "near", Embedding: []float32{1, 0}},
+		{FileHash: "h", Model: "m", StartLine: 6, EndLine: 9, Content: "far", Embedding: []float32{0, 1}},
+	}
+	if err := db.ReplaceFileChunks("/repo", "main.go", "m", chunks); err != nil {
+		t.Fatalf("ReplaceFileChunks: %v", err)
+	}
+	matches, err := db.SearchChunks("/repo", "m", []float32{0.9, 0.1}, 2)
+	if err != nil {
+		t.Fatalf("SearchChunks: %v", err)
+	}
+	if len(matches) != 2 || matches[0].Content != "near" {
+		t.Errorf("SearchChunks = %+v, want near first", matches)
+	}
+}
+
+// TestPostgresMigrations runs the migrations up and back down on the Postgres database at
+// RCODE_TEST_PG_URL, which should be one kept for the tests as its tables are dropped
+func TestPostgresMigrations(t *testing.T) {
+	url := os.Getenv("RCODE_TEST_PG_URL")
+	if url == "" {
+		t.Skip("RCODE_TEST_PG_URL isn't set")
+	}
+	backend := &postgresBackend{}
+	conn, _, err := backend.Open(url)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	db := &DB{conn: conn, backend: backend}
+	defer db.Close()
+
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	defer func() {
+		if err := db.MigrateDown(0); err != nil {
+			t.Errorf("MigrateDown: %v", err)
+		}
+	}()
+
+	session, err := db.CreateSession(SessionOptions{Title: "on postgres", InitialPrompts: []string{"Be brief"}})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	found, err := db.SearchSessions("postgres")
+	if err != nil {
+		t.Fatalf("SearchSessions: %v", err)
+	}
+	if len(found) != 1 || found[0].ID != session.ID {
+		t.Errorf("SearchSessions found %d sessions, want the one created", len(found))
+	}
+}
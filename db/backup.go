@@ -0,0 +1,51 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// Location returns where the database is, for duckdb the file
+func (db *DB) Location() string {
+	return db.location
+}
+
+// BackupTo writes a consistent copy of the database to the file path, holding writes back
+// until it is done
+func (db *DB) BackupTo(path string) error {
+	db.writeMu.Lock()
+	defer db.writeMu.Unlock()
+	if err := db.backend.Backup(db.conn, db.location, path); err != nil {
+		return serr.Wrap(err, "failed to back up database")
+	}
+	return nil
+}
+
+// VerifyBackup reads all of a copy BackupTo wrote, failing if any of it is damaged or it was
+// migrated past the migrations this build knows. It returns the copy's migration version.
+func (db *DB) VerifyBackup(path string) (int, error) {
+	version, err := db.backend.Verify(path)
+	if err != nil {
+		return 0, serr.Wrap(err, "backup failed verification")
+	}
+
+	migrations, err := loadMigrations(db.backend.Migrations())
+	if err != nil {
+		return 0, err
+	}
+	if n := len(migrations); n > 0 && version > migrations[n-1].Version {
+		return 0, serr.New(fmt.Sprintf("backup was migrated to version %d by a newer rcode; this one knows up to %d",
+			version, migrations[n-1].Version))
+	}
+	return version, nil
+}
+
+// StageRestore sets a verified copy aside to replace the database when rcode next starts, as
+// it can't be replaced while open. The database it replaces is kept beside it as .pre-restore.
+func (db *DB) StageRestore(path string) error {
+	if err := db.backend.StageRestore(db.location, path); err != nil {
+		return serr.Wrap(err, "failed to stage restore")
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"time"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// BenchmarkRun is a run of the bench tool, kept for its session so later runs are
+// compared with it. Output holds the results in Go's benchmark format.
+type BenchmarkRun struct {
+	ID        int       `json:"id"`
+	SessionID string    `json:"session_id"`
+	Label     string    `json:"label,omitempty"`
+	Packages  string    `json:"packages,omitempty"`
+	Bench     string    `json:"bench,omitempty"`
+	Command   string    `json:"command,omitempty"`
+	Output    string    `json:"output"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AddBenchmarkRun stores a run, setting its ID and time
+func (db *DB) AddBenchmarkRun(run *BenchmarkRun) error {
+	err := db.WriteRow(`
+		INSERT INTO session_benchmarks (session_id, label, packages, bench, command, output)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING id, created_at
+	`, []interface{}{run.SessionID, run.Label, run.Packages, run.Bench, run.Command, run.Output}, &run.ID, &run.CreatedAt)
+	if err != nil {
+		return serr.Wrap(err, "failed to add benchmark run")
+	}
+	return nil
+}
+
+// GetBenchmarkRuns returns a session's benchmark runs, oldest first
+func (db *DB) GetBenchmarkRuns(sessionID string) ([]*BenchmarkRun, error) {
+	rows, err := db.Query(`
+		SELECT id, session_id, label, packages, bench, command, output, created_at
+		FROM session_benchmarks WHERE session_id = ? ORDER BY id
+	`, sessionID)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to get benchmark runs")
+	}
+	defer rows.Close()
+
+	runs := []*BenchmarkRun{}
+	for rows.Next() {
+		run := &BenchmarkRun{}
+		if err := rows.Scan(&run.ID, &run.SessionID, &run.Label, &run.Packages, &run.Bench, &run.Command,
+			&run.Output, &run.CreatedAt); err != nil {
+			return nil, serr.Wrap(err, "failed to scan benchmark run")
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// DeleteSessionBenchmarkRuns removes a session's benchmark runs
+func (db *DB) DeleteSessionBenchmarkRuns(sessionID string) error {
+	if _, err := db.Exec("DELETE FROM session_benchmarks WHERE session_id = ?", sessionID); err != nil {
+		return serr.Wrap(err, "failed to delete benchmark runs")
+	}
+	return nil
+}
@@ -89,21 +89,6 @@ func (db *DB) CompactSessionMessages(sessionID string, opts CompactionOptions) (
 	// Calculate token count after compaction
 	tokenCountAfter := len(summary) / 4 // Rough approximation
 
-	// Begin transaction
-	tx, err := db.conn.Begin()
-	if err != nil {
-		return nil, serr.Wrap(err, "failed to begin transaction")
-	}
-	defer tx.Rollback()
-
-	// Archive original messages
-	for _, msg := range messagesToCompact {
-		err = archiveMessage(tx, msg, 0) // We'll update compaction_id later
-		if err != nil {
-			return nil, serr.Wrap(err, "failed to archive message")
-		}
-	}
-
 	// Create compacted message record
 	compactedMsg := &CompactedMessage{
 		SessionID:          sessionID,
@@ -123,15 +108,15 @@ func (db *DB) CompactSessionMessages(sessionID string, opts CompactionOptions) (
 		return nil, serr.Wrap(err, "failed to marshal metadata")
 	}
 
-	// Convert message IDs to array literal for DuckDB
-	idsArray := "["
+	// Convert message IDs to array literal
+	idsArray := "ARRAY["
 	for i, id := range messageIDs {
 		if i > 0 {
 			idsArray += ", "
 		}
 		idsArray += fmt.Sprintf("%d", id)
 	}
-	idsArray += "]"
+	idsArray += "]::INTEGER[]"
 
 	query := `
 		INSERT INTO compacted_messages 
@@ -140,56 +125,63 @@ func (db *DB) CompactSessionMessages(sessionID string, opts CompactionOptions) (
 		VALUES (?, ?, ` + idsArray + `, ?, ?, ?, ?, ?::JSON)
 	`
 
-	result, err := tx.Exec(query, sessionID, summary,
-		messagesToCompact[0].ID, messagesToCompact[len(messagesToCompact)-1].ID,
-		tokenCountBefore, tokenCountAfter, string(metadataJSON))
-	if err != nil {
-		return nil, serr.Wrap(err, "failed to insert compacted message")
-	}
-	_ = result
+	err = db.Transaction(func(tx *sql.Tx) error {
+		// Archive original messages
+		for _, msg := range messagesToCompact {
+			err := archiveMessage(tx, msg, 0) // We'll update compaction_id later
+			if err != nil {
+				return serr.Wrap(err, "failed to archive message")
+			}
+		}
 
-	// Get the inserted ID
-	var compactionID int
-	err = tx.QueryRow("SELECT currval('compacted_messages_id_seq')").Scan(&compactionID)
-	if err != nil {
-		return nil, serr.Wrap(err, "failed to get compaction ID")
-	}
-	compactedMsg.ID = compactionID
-
-	// Update archived messages with compaction_id
-	_, err = tx.Exec(`
-		UPDATE archived_messages 
-		SET compaction_id = ? 
-		WHERE session_id = ? AND id >= ? AND id <= ?`,
-		compactionID, sessionID, messagesToCompact[0].ID, messagesToCompact[len(messagesToCompact)-1].ID)
-	if err != nil {
-		return nil, serr.Wrap(err, "failed to update archived messages")
-	}
+		_, err := tx.Exec(query, sessionID, summary,
+			messagesToCompact[0].ID, messagesToCompact[len(messagesToCompact)-1].ID,
+			tokenCountBefore, tokenCountAfter, string(metadataJSON))
+		if err != nil {
+			return serr.Wrap(err, "failed to insert compacted message")
+		}
 
-	// Delete original messages from messages table
-	_, err = tx.Exec(`
-		DELETE FROM messages 
-		WHERE session_id = ? AND id >= ? AND id <= ?`,
-		sessionID, messagesToCompact[0].ID, messagesToCompact[len(messagesToCompact)-1].ID)
-	if err != nil {
-		return nil, serr.Wrap(err, "failed to delete original messages")
-	}
+		// Get the inserted ID
+		var compactionID int
+		err = tx.QueryRow("SELECT currval('compacted_messages_id_seq')").Scan(&compactionID)
+		if err != nil {
+			return serr.Wrap(err, "failed to get compaction ID")
+		}
+		compactedMsg.ID = compactionID
+
+		// Update archived messages with compaction_id
+		_, err = tx.Exec(`
+			UPDATE archived_messages 
+			SET compaction_id = ? 
+			WHERE session_id = ? AND id >= ? AND id <= ?`,
+			compactionID, sessionID, messagesToCompact[0].ID, messagesToCompact[len(messagesToCompact)-1].ID)
+		if err != nil {
+			return serr.Wrap(err, "failed to update archived messages")
+		}
 
-	// Update session metadata
-	_, err = tx.Exec(`
-		UPDATE sessions 
-		SET last_compacted_at = CURRENT_TIMESTAMP,
-		    compaction_metadata = ?::JSON
-		WHERE id = ?`,
-		string(metadataJSON), sessionID)
-	if err != nil {
-		return nil, serr.Wrap(err, "failed to update session")
-	}
+		// Delete original messages from messages table
+		_, err = tx.Exec(`
+			DELETE FROM messages 
+			WHERE session_id = ? AND id >= ? AND id <= ?`,
+			sessionID, messagesToCompact[0].ID, messagesToCompact[len(messagesToCompact)-1].ID)
+		if err != nil {
+			return serr.Wrap(err, "failed to delete original messages")
+		}
 
-	// Commit transaction
-	err = tx.Commit()
+		// Update session metadata
+		_, err = tx.Exec(`
+			UPDATE sessions 
+			SET last_compacted_at = CURRENT_TIMESTAMP,
+			    compaction_metadata = ?::JSON
+			WHERE id = ?`,
+			string(metadataJSON), sessionID)
+		if err != nil {
+			return serr.Wrap(err, "failed to update session")
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, serr.Wrap(err, "failed to commit transaction")
+		return nil, err
 	}
 
 	logger.Info("Compacted session messages",
@@ -330,7 +322,7 @@ func archiveMessage(tx *sql.Tx, msg *Message, compactionID int) error {
 func (db *DB) GetCompactedMessages(sessionID string) ([]*CompactedMessage, error) {
 	query := `
 		SELECT id, session_id, summary, 
-		       list_aggregate(original_message_ids, 'string_agg', ',') as ids,
+		       array_to_string(original_message_ids, ',') as ids,
 		       start_message_id, end_message_id,
 		       token_count_before, token_count_after, 
 		       compacted_at, metadata::VARCHAR
@@ -399,11 +391,10 @@ func (db *DB) GetMessagesWithCompaction(sessionID string) ([]providers.ChatMessa
 
 	// If no compacted messages, return regular messages as-is
 	if len(compactedMessages) == 0 {
-		result := make([]providers.ChatMessage, len(regularMessages))
-		for i, msg := range regularMessages {
-			result[i] = providers.ChatMessage{
-				Role:    msg.Role,
-				Content: msg.Content,
+		result := make([]providers.ChatMessage, 0, len(regularMessages))
+		for _, msg := range regularMessages {
+			if chatMsg, ok := conversationMessage(msg); ok {
+				result = append(result, chatMsg)
 			}
 		}
 		return result, nil
@@ -426,10 +417,9 @@ func (db *DB) GetMessagesWithCompaction(sessionID string) ([]providers.ChatMessa
 		}
 
 		// Add the regular message
-		result = append(result, providers.ChatMessage{
-			Role:    msg.Role,
-			Content: msg.Content,
-		})
+		if chatMsg, ok := conversationMessage(msg); ok {
+			result = append(result, chatMsg)
+		}
 	}
 
 	// Add any remaining compacted messages at the end
@@ -444,69 +434,96 @@ func (db *DB) GetMessagesWithCompaction(sessionID string) ([]providers.ChatMessa
 	return result, nil
 }
 
+// conversationMessage returns a stored message as it goes in the conversation. A reply still
+// being streamed is left out. An interrupted one keeps the text it got to, with a note that
+// it stopped there and of the tools it had called, whose results were never added; it is
+// left out when it has no text.
+func conversationMessage(msg *Message) (providers.ChatMessage, bool) {
+	chatMsg := providers.ChatMessage{Role: msg.Role, Content: msg.Content, Metadata: msg.Metadata}
+	switch msg.Status {
+	case MessagePartial:
+		return chatMsg, false
+	case MessageInterrupted:
+		text, _ := msg.Content.(string)
+		if strings.TrimSpace(text) == "" {
+			return chatMsg, false
+		}
+		note := "[This reply was interrupted before it finished"
+		var tools []string
+		if toolUses, ok := msg.Metadata["partialToolUses"].([]interface{}); ok {
+			for _, toolUse := range toolUses {
+				if m, ok := toolUse.(map[string]interface{}); ok {
+					if name, ok := m["name"].(string); ok {
+						tools = append(tools, name)
+					}
+				}
+			}
+		}
+		if len(tools) > 0 {
+			note += "; it had called " + strings.Join(tools, ", ") + ", whose results were lost"
+		}
+		chatMsg.Content = text + "\n\n" + note + "]"
+		chatMsg.Metadata = map[string]interface{}{"interrupted": true}
+	}
+	return chatMsg, true
+}
+
 // RestoreCompactedMessages restores archived messages from a compaction
 func (db *DB) RestoreCompactedMessages(sessionID string, compactionID int) error {
-	// Begin transaction
-	tx, err := db.conn.Begin()
-	if err != nil {
-		return serr.Wrap(err, "failed to begin transaction")
-	}
-	defer tx.Rollback()
+	err := db.Transaction(func(tx *sql.Tx) error {
+		// Get archived messages
+		query := `
+			SELECT id, session_id, role, content::VARCHAR, created_at, model, token_usage::VARCHAR
+			FROM archived_messages
+			WHERE session_id = ? AND compaction_id = ?
+			ORDER BY id ASC
+		`
 
-	// Get archived messages
-	query := `
-		SELECT id, session_id, role, content::VARCHAR, created_at, model, token_usage::VARCHAR
-		FROM archived_messages
-		WHERE session_id = ? AND compaction_id = ?
-		ORDER BY id ASC
-	`
+		rows, err := tx.Query(query, sessionID, compactionID)
+		if err != nil {
+			return serr.Wrap(err, "failed to query archived messages")
+		}
+		defer rows.Close()
+
+		// Restore each message
+		for rows.Next() {
+			var id int
+			var sessionID, role, contentJSON string
+			var createdAt time.Time
+			var model, usageJSON sql.NullString
+
+			err := rows.Scan(&id, &sessionID, &role, &contentJSON, &createdAt, &model, &usageJSON)
+			if err != nil {
+				return serr.Wrap(err, "failed to scan archived message")
+			}
 
-	rows, err := tx.Query(query, sessionID, compactionID)
-	if err != nil {
-		return serr.Wrap(err, "failed to query archived messages")
-	}
-	defer rows.Close()
+			// Insert back into messages table
+			insertQuery := `
+				INSERT INTO messages (id, session_id, role, content, created_at, model, token_usage)
+				VALUES (?, ?, ?, ?::JSON, ?, ?, ?::JSON)
+			`
 
-	// Restore each message
-	for rows.Next() {
-		var id int
-		var sessionID, role, contentJSON string
-		var createdAt time.Time
-		var model, usageJSON sql.NullString
+			_, err = tx.Exec(insertQuery, id, sessionID, role, contentJSON, createdAt, model, usageJSON)
+			if err != nil {
+				return serr.Wrap(err, "failed to restore message")
+			}
+		}
 
-		err := rows.Scan(&id, &sessionID, &role, &contentJSON, &createdAt, &model, &usageJSON)
+		// Delete the compacted message record
+		_, err = tx.Exec("DELETE FROM compacted_messages WHERE id = ?", compactionID)
 		if err != nil {
-			return serr.Wrap(err, "failed to scan archived message")
+			return serr.Wrap(err, "failed to delete compacted message")
 		}
 
-		// Insert back into messages table
-		insertQuery := `
-			INSERT INTO messages (id, session_id, role, content, created_at, model, token_usage)
-			VALUES (?, ?, ?, ?::JSON, ?, ?, ?::JSON)
-		`
-
-		_, err = tx.Exec(insertQuery, id, sessionID, role, contentJSON, createdAt, model, usageJSON)
+		// Delete archived messages
+		_, err = tx.Exec("DELETE FROM archived_messages WHERE compaction_id = ?", compactionID)
 		if err != nil {
-			return serr.Wrap(err, "failed to restore message")
+			return serr.Wrap(err, "failed to delete archived messages")
 		}
-	}
-
-	// Delete the compacted message record
-	_, err = tx.Exec("DELETE FROM compacted_messages WHERE id = ?", compactionID)
-	if err != nil {
-		return serr.Wrap(err, "failed to delete compacted message")
-	}
-
-	// Delete archived messages
-	_, err = tx.Exec("DELETE FROM archived_messages WHERE compaction_id = ?", compactionID)
-	if err != nil {
-		return serr.Wrap(err, "failed to delete archived messages")
-	}
-
-	// Commit transaction
-	err = tx.Commit()
+		return nil
+	})
 	if err != nil {
-		return serr.Wrap(err, "failed to commit transaction")
+		return err
 	}
 
 	logger.Info("Restored compacted messages", "session_id", sessionID, "compaction_id", compactionID)
@@ -3,72 +3,111 @@ package db
 import (
 	"database/sql"
 	"fmt"
-	"os"
-	"path/filepath"
+	"sync"
+	"time"
 
-	_ "github.com/marcboeker/go-duckdb/v2"
 	"github.com/rohanthewiz/logger"
 	"github.com/rohanthewiz/serr"
+	"rcode/config"
 )
 
-// DB represents the database connection
+const (
+	maxOpenConns = 16 // Connections handlers read through at once
+
+	busyRetries = 5                     // Retries of a write that conflicted with another
+	busyBackoff = 20 * time.Millisecond // Wait before the first retry, doubled for each after
+	openRetries = 10                    // Retries of opening the database while another process holds it
+	openBackoff = 300 * time.Millisecond
+)
+
+// DB represents the database connection. Reads go through a pool of connections, while
+// writes take turns: DuckDB fails a transaction that conflicts with another rather than
+// waiting for it, so writes are serialized and retried when they conflict all the same.
 type DB struct {
-	conn *sql.DB
-	path string
+	conn     *sql.DB
+	backend  Backend
+	location string // Where the backend opened the database, for duckdb the file
+	writeMu  sync.Mutex
 }
 
 // singleton instance
-var instance *DB
-var taskPlanDB *TaskPlanDB
+var (
+	instance   *DB
+	instanceMu sync.Mutex
+	taskPlanDB *TaskPlanDB
+)
 
-// GetDB returns the database instance, creating it if necessary
+// GetDB returns the database instance, opening it and applying pending migrations if necessary
 func GetDB() (*DB, error) {
+	instanceMu.Lock()
+	defer instanceMu.Unlock()
 	if instance != nil {
 		return instance, nil
 	}
 
-	// Get database path
-	homeDir, err := os.UserHomeDir()
+	db, err := Open()
 	if err != nil {
-		return nil, serr.Wrap(err, "failed to get home directory")
+		return nil, err
 	}
 
-	dataDir := filepath.Join(homeDir, ".local", "share", "rcode")
-
-	// Create directory if it doesn't exist
-	if err := os.MkdirAll(dataDir, 0700); err != nil {
-		return nil, serr.Wrap(err, "failed to create data directory")
+	// Run migrations
+	if err := db.Migrate(); err != nil {
+		db.Close()
+		return nil, serr.Wrap(err, "failed to run migrations")
 	}
 
-	dbPath := filepath.Join(dataDir, "rcode.db")
+	instance = db
+	return instance, nil
+}
 
-	// Open database connection
-	conn, err := sql.Open("duckdb", dbPath)
+// Open opens the database RCODE_DB_BACKEND and RCODE_DB_URL choose, without applying
+// migrations, for managing them. Use GetDB otherwise.
+func Open() (*DB, error) {
+	cfg := config.Get()
+	backend, err := backendNamed(cfg.DBBackend)
 	if err != nil {
-		return nil, serr.Wrap(err, "failed to open database")
+		return nil, err
 	}
 
-	// Test connection
-	if err := conn.Ping(); err != nil {
-		return nil, serr.Wrap(err, "failed to ping database")
+	// Open database connection, waiting a little for a process that is closing the database
+	// to let go of it
+	conn, location, err := backend.Open(cfg.DBURL)
+	for i := 0; i < openRetries && err != nil && backend.IsBusy(err); i++ {
+		if i == 0 {
+			logger.Warn("Database is in use, waiting for it", "backend", backend.Name())
+		}
+		time.Sleep(openBackoff)
+		conn, location, err = backend.Open(cfg.DBURL)
 	}
-
-	instance = &DB{
-		conn: conn,
-		path: dbPath,
+	if err != nil {
+		return nil, err
 	}
+	conn.SetMaxOpenConns(maxOpenConns)
+	conn.SetMaxIdleConns(maxOpenConns)
 
-	logger.Info("Database connected", "path", dbPath)
+	logger.Info("Database connected", "backend", backend.Name(), "path", location)
+	return &DB{conn: conn, backend: backend, location: location}, nil
+}
 
-	// Run migrations
-	if err := instance.Migrate(); err != nil {
-		return nil, serr.Wrap(err, "failed to run migrations")
+// write runs fn, which writes to the database, while no other write runs, and runs it again
+// if it fails because it conflicted with another transaction. fn must be safe to repeat.
+func (db *DB) write(fn func() error) error {
+	db.writeMu.Lock()
+	defer db.writeMu.Unlock()
+
+	backoff := busyBackoff
+	err := fn()
+	for i := 0; i < busyRetries && err != nil && db.backend.IsBusy(err); i++ {
+		logger.Debug("Database write conflicted, retrying", "attempt", i+1, "error", err.Error())
+		time.Sleep(backoff)
+		backoff *= 2
+		err = fn()
 	}
-
-	return instance, nil
+	return err
 }
 
-// Conn returns the underlying database connection
+// Conn returns the underlying database connection. Write through Exec, WriteRow or
+// Transaction rather than through it, so the write takes its turn.
 func (db *DB) Conn() *sql.DB {
 	return db.conn
 }
@@ -81,30 +120,34 @@ func (db *DB) Close() error {
 	return nil
 }
 
-// Transaction executes a function within a database transaction
+// Transaction executes a function within a database transaction. Like other writes, it
+// waits for its turn and is retried when it conflicts, so fn may run more than once; it
+// must write through tx only, as writing through db would wait on itself.
 func (db *DB) Transaction(fn func(*sql.Tx) error) error {
-	tx, err := db.conn.Begin()
-	if err != nil {
-		return serr.Wrap(err, "failed to begin transaction")
-	}
+	return db.write(func() error {
+		tx, err := db.conn.Begin()
+		if err != nil {
+			return serr.Wrap(err, "failed to begin transaction")
+		}
 
-	defer func() {
-		if p := recover(); p != nil {
+		defer func() {
+			if p := recover(); p != nil {
+				tx.Rollback()
+				panic(p) // re-throw panic after rollback
+			}
+		}()
+
+		if err := fn(tx); err != nil {
 			tx.Rollback()
-			panic(p) // re-throw panic after rollback
+			return err
 		}
-	}()
-
-	if err := fn(tx); err != nil {
-		tx.Rollback()
-		return err
-	}
 
-	if err := tx.Commit(); err != nil {
-		return serr.Wrap(err, "failed to commit transaction")
-	}
+		if err := tx.Commit(); err != nil {
+			return serr.Wrap(err, "failed to commit transaction")
+		}
 
-	return nil
+		return nil
+	})
 }
 
 // Query executes a query that returns rows
@@ -121,9 +164,22 @@ func (db *DB) QueryRow(query string, args ...interface{}) *sql.Row {
 	return db.conn.QueryRow(query, args...)
 }
 
+// WriteRow executes a write that returns a single row, such as an INSERT ... RETURNING,
+// and scans the row into dest
+func (db *DB) WriteRow(query string, args []interface{}, dest ...interface{}) error {
+	return db.write(func() error {
+		return db.conn.QueryRow(query, args...).Scan(dest...)
+	})
+}
+
 // Exec executes a query that doesn't return rows
 func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
-	result, err := db.conn.Exec(query, args...)
+	var result sql.Result
+	err := db.write(func() error {
+		var err error
+		result, err = db.conn.Exec(query, args...)
+		return err
+	})
 	if err != nil {
 		return nil, serr.Wrap(err, fmt.Sprintf("exec failed: %s", query))
 	}
@@ -0,0 +1,22 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+)
+
+// newTestDB returns a migrated in-memory DuckDB database, closed when the test ends
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+	conn, err := sql.Open("duckdb", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetMaxOpenConns(1) // Each connection to "" would be a database of its own
+	db := &DB{conn: conn, backend: duckdbBackend{}}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	return db
+}
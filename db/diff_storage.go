@@ -64,7 +64,7 @@ func (db *DB) SaveDiffSnapshot(snapshot *DiffSnapshot) (int64, error) {
 	`
 
 	var id int64
-	err := db.QueryRow(query,
+	err := db.WriteRow(query, []interface{}{
 		snapshot.SessionID,
 		snapshot.FilePath,
 		snapshot.Content,
@@ -72,7 +72,7 @@ func (db *DB) SaveDiffSnapshot(snapshot *DiffSnapshot) (int64, error) {
 		snapshot.CreatedAt,
 		nullableString(snapshot.ToolExecutionID),
 		nullableString(snapshot.ToolName),
-	).Scan(&id)
+	}, &id)
 
 	if err != nil {
 		return 0, serr.Wrap(err, "failed to save diff snapshot")
@@ -133,7 +133,7 @@ func (db *DB) SaveDiff(diff *Diff) (int64, error) {
 	`
 
 	var id int64
-	err := db.QueryRow(query,
+	err := db.WriteRow(query, []interface{}{
 		diff.SessionID,
 		diff.FilePath,
 		nullableInt64(diff.BeforeSnapshotID),
@@ -142,7 +142,7 @@ func (db *DB) SaveDiff(diff *Diff) (int64, error) {
 		diff.CreatedAt,
 		nullableString(diff.ToolExecutionID),
 		diff.IsApplied,
-	).Scan(&id)
+	}, &id)
 
 	if err != nil {
 		return 0, serr.Wrap(err, "failed to save diff")
@@ -0,0 +1,117 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// CodeChunk is a span of lines from a project file with its embedding
+type CodeChunk struct {
+	ID          int       `json:"id"`
+	ProjectRoot string    `json:"project_root"`
+	FilePath    string    `json:"file_path"`
+	FileHash    string    `json:"file_hash"`
+	Model       string    `json:"model"`
+	StartLine   int       `json:"start_line"`
+	EndLine     int       `json:"end_line"`
+	Content     string    `json:"content"`
+	Embedding   []float32 `json:"-"`
+	IndexedAt   time.Time `json:"indexed_at"`
+}
+
+// ChunkMatch is a code chunk ranked by cosine similarity to a query embedding
+type ChunkMatch struct {
+	CodeChunk
+	Similarity float64 `json:"similarity"`
+}
+
+// GetIndexedFileHashes returns the file hash recorded for each file indexed with model
+func (db *DB) GetIndexedFileHashes(projectRoot, model string) (map[string]string, error) {
+	rows, err := db.Query(`
+		SELECT DISTINCT file_path, file_hash
+		FROM code_chunks
+		WHERE project_root = ? AND model = ?
+	`, projectRoot, model)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to query indexed files")
+	}
+	defer rows.Close()
+
+	hashes := make(map[string]string)
+	for rows.Next() {
+		var path, hash string
+		if err := rows.Scan(&path, &hash); err != nil {
+			return nil, serr.Wrap(err, "failed to scan indexed file")
+		}
+		hashes[path] = hash
+	}
+
+	return hashes, rows.Err()
+}
+
+// ReplaceFileChunks replaces the chunks stored for a file and model with chunks
+func (db *DB) ReplaceFileChunks(projectRoot, filePath, model string, chunks []CodeChunk) error {
+	return db.Transaction(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`
+			DELETE FROM code_chunks WHERE project_root = ? AND file_path = ? AND model = ?
+		`, projectRoot, filePath, model); err != nil {
+			return serr.Wrap(err, "failed to delete old chunks")
+		}
+
+		for _, chunk := range chunks {
+			if _, err := tx.Exec(`
+				INSERT INTO code_chunks (project_root, file_path, file_hash, model, start_line, end_line, content, embedding)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			`, projectRoot, filePath, chunk.FileHash, model, chunk.StartLine, chunk.EndLine,
+				chunk.Content, chunk.Embedding); err != nil {
+				return serr.Wrap(err, "failed to insert chunk")
+			}
+		}
+
+		return nil
+	})
+}
+
+// DeleteFileChunks removes every chunk stored for a file, across all models
+func (db *DB) DeleteFileChunks(projectRoot, filePath string) error {
+	if _, err := db.Exec(`
+		DELETE FROM code_chunks WHERE project_root = ? AND file_path = ?
+	`, projectRoot, filePath); err != nil {
+		return serr.Wrap(err, "failed to delete chunks")
+	}
+	return nil
+}
+
+// SearchChunks returns the chunks embedded with model that are most similar to vector
+func (db *DB) SearchChunks(projectRoot, model string, vector []float32, limit int) ([]ChunkMatch, error) {
+	rows, err := db.Query(`
+		SELECT id, file_path, file_hash, start_line, end_line, content, indexed_at,
+		       list_cosine_similarity(embedding, ?::REAL[]) AS similarity
+		FROM code_chunks
+		WHERE project_root = ? AND model = ?
+		ORDER BY similarity DESC
+		LIMIT ?
+	`, vector, projectRoot, model, limit)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to search chunks")
+	}
+	defer rows.Close()
+
+	matches := make([]ChunkMatch, 0, limit)
+	for rows.Next() {
+		var m ChunkMatch
+		var similarity sql.NullFloat64
+		if err := rows.Scan(&m.ID, &m.FilePath, &m.FileHash, &m.StartLine, &m.EndLine,
+			&m.Content, &m.IndexedAt, &similarity); err != nil {
+			return nil, serr.Wrap(err, "failed to scan chunk")
+		}
+		m.ProjectRoot = projectRoot
+		m.Model = model
+		m.Similarity = similarity.Float64 // NULL when either vector has zero magnitude
+		matches = append(matches, m)
+	}
+
+	return matches, rows.Err()
+}
@@ -36,7 +36,7 @@ func (db *DB) TrackFileAccess(sessionID, filePath, accessType string) error {
 		return serr.Wrap(err, "failed to track file access")
 	}
 	
-	logger.Info("File access tracked", "session", sessionID, "file", filePath, "type", accessType)
+	logger.Info("File access tracked", "session_id", sessionID, "path", filePath, "type", accessType)
 	return nil
 }
 
@@ -20,6 +20,8 @@ type InitialPrompt struct {
 	PermissionTemplate  map[string]interface{} `json:"permission_template,omitempty"`
 	IsActive            bool                   `json:"is_active"`
 	IsDefault           bool                   `json:"is_default"`
+	Tags                []string               `json:"tags"`
+	Source              string                 `json:"source,omitempty"` // The .rcode/prompts file of a project prompt
 	CreatedAt           time.Time              `json:"created_at"`
 	UpdatedAt           time.Time              `json:"updated_at"`
 }
@@ -39,14 +41,21 @@ func (db *DB) CreateInitialPrompt(prompt *InitialPrompt) error {
 		permTemplateJSON = []byte("{}")
 	}
 
+	prompt.Tags = NormalizeTags(prompt.Tags)
+	tagsJSON, err := json.Marshal(prompt.Tags)
+	if err != nil {
+		return serr.Wrap(err, "failed to serialize tags")
+	}
+
 	// Insert the prompt
-	err := db.QueryRow(`
-		INSERT INTO initial_prompts (name, description, content, includes_permissions, permission_template, is_active, is_default)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
+	err = db.WriteRow(`
+		INSERT INTO initial_prompts (name, description, content, includes_permissions, permission_template, is_active, is_default,
+			tags, source)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?::JSON, NULLIF(?, ''))
 		RETURNING id, created_at, updated_at
-	`, prompt.Name, prompt.Description, prompt.Content, prompt.IncludesPermissions,
-		permTemplateJSON, prompt.IsActive, prompt.IsDefault).
-		Scan(&prompt.ID, &prompt.CreatedAt, &prompt.UpdatedAt)
+	`, []interface{}{prompt.Name, prompt.Description, prompt.Content, prompt.IncludesPermissions,
+		permTemplateJSON, prompt.IsActive, prompt.IsDefault, string(tagsJSON), prompt.Source},
+		&prompt.ID, &prompt.CreatedAt, &prompt.UpdatedAt)
 
 	if err != nil {
 		return serr.Wrap(err, "failed to create initial prompt")
@@ -59,15 +68,16 @@ func (db *DB) CreateInitialPrompt(prompt *InitialPrompt) error {
 func (db *DB) GetInitialPrompt(id int) (*InitialPrompt, error) {
 	prompt := &InitialPrompt{}
 	var permTemplateJSON duckdb.Composite[map[string]interface{}]
+	var tagsJSON string
 
 	err := db.QueryRow(`
 		SELECT id, name, description, content, includes_permissions, permission_template, 
-		       is_active, is_default, created_at, updated_at
+		       is_active, is_default, created_at, updated_at, COALESCE(tags::VARCHAR, '[]'), COALESCE(source, '')
 		FROM initial_prompts
 		WHERE id = ?
 	`, id).Scan(&prompt.ID, &prompt.Name, &prompt.Description, &prompt.Content,
 		&prompt.IncludesPermissions, &permTemplateJSON, &prompt.IsActive,
-		&prompt.IsDefault, &prompt.CreatedAt, &prompt.UpdatedAt)
+		&prompt.IsDefault, &prompt.CreatedAt, &prompt.UpdatedAt, &tagsJSON, &prompt.Source)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -80,6 +90,7 @@ func (db *DB) GetInitialPrompt(id int) (*InitialPrompt, error) {
 	if permTemplateJSON.Get() != nil {
 		prompt.PermissionTemplate = permTemplateJSON.Get()
 	}
+	prompt.Tags = decodeTags(tagsJSON)
 
 	return prompt, nil
 }
@@ -88,7 +99,7 @@ func (db *DB) GetInitialPrompt(id int) (*InitialPrompt, error) {
 func (db *DB) GetAllInitialPrompts(activeOnly bool) ([]*InitialPrompt, error) {
 	query := `
 		SELECT id, name, description, content, includes_permissions, permission_template, 
-		       is_active, is_default, created_at, updated_at
+		       is_active, is_default, created_at, updated_at, COALESCE(tags::VARCHAR, '[]'), COALESCE(source, '')
 		FROM initial_prompts
 	`
 	if activeOnly {
@@ -106,10 +117,11 @@ func (db *DB) GetAllInitialPrompts(activeOnly bool) ([]*InitialPrompt, error) {
 	for rows.Next() {
 		prompt := &InitialPrompt{}
 		var permTemplateJSON duckdb.Composite[map[string]interface{}]
+		var tagsJSON string
 
 		err := rows.Scan(&prompt.ID, &prompt.Name, &prompt.Description, &prompt.Content,
 			&prompt.IncludesPermissions, &permTemplateJSON, &prompt.IsActive,
-			&prompt.IsDefault, &prompt.CreatedAt, &prompt.UpdatedAt)
+			&prompt.IsDefault, &prompt.CreatedAt, &prompt.UpdatedAt, &tagsJSON, &prompt.Source)
 		if err != nil {
 			return nil, serr.Wrap(err, "failed to scan initial prompt")
 		}
@@ -118,6 +130,7 @@ func (db *DB) GetAllInitialPrompts(activeOnly bool) ([]*InitialPrompt, error) {
 		if permTemplateJSON.Get() != nil {
 			prompt.PermissionTemplate = permTemplateJSON.Get()
 		}
+		prompt.Tags = decodeTags(tagsJSON)
 
 		prompts = append(prompts, prompt)
 	}
@@ -151,14 +164,20 @@ func (db *DB) UpdateInitialPrompt(prompt *InitialPrompt) error {
 		permTemplateJSON = []byte("{}")
 	}
 
+	prompt.Tags = NormalizeTags(prompt.Tags)
+	tagsJSON, err := json.Marshal(prompt.Tags)
+	if err != nil {
+		return serr.Wrap(err, "failed to serialize tags")
+	}
+
 	// Update the prompt using UPDATE statement
 	result, err := db.Exec(`
 		UPDATE initial_prompts 
 		SET name = ?, description = ?, content = ?, includes_permissions = ?,
-		    permission_template = ?, is_active = ?, is_default = ?, updated_at = ?
+		    permission_template = ?, is_active = ?, is_default = ?, tags = ?::JSON, updated_at = ?
 		WHERE id = ?
 	`, prompt.Name, prompt.Description, prompt.Content, prompt.IncludesPermissions,
-		permTemplateJSON, prompt.IsActive, prompt.IsDefault, time.Now(), prompt.ID)
+		permTemplateJSON, prompt.IsActive, prompt.IsDefault, string(tagsJSON), time.Now(), prompt.ID)
 
 	if err != nil {
 		return serr.Wrap(err, "failed to update initial prompt", "table", "initial_prompts")
@@ -181,8 +200,14 @@ func (db *DB) UpdateInitialPrompt(prompt *InitialPrompt) error {
 	return nil
 }
 
-// DeleteInitialPrompt deletes an initial prompt
+// DeleteInitialPrompt deletes an initial prompt. Sessions it was applied to keep its text.
 func (db *DB) DeleteInitialPrompt(id int) error {
+	// The sessions' links to it would keep it from being deleted. They are removed first, on
+	// their own, as DuckDB checks foreign keys without seeing a transaction's own deletes.
+	if _, err := db.Exec("DELETE FROM session_initial_prompts WHERE prompt_id = ?", id); err != nil {
+		return serr.Wrap(err, "failed to unlink initial prompt from sessions")
+	}
+
 	result, err := db.Exec("DELETE FROM initial_prompts WHERE id = ?", id)
 	if err != nil {
 		return serr.Wrap(err, "failed to delete initial prompt")
@@ -209,7 +234,8 @@ func (db *DB) GetDefaultInitialPrompts() ([]*InitialPrompt, error) {
 func (db *DB) GetSessionInitialPrompts(sessionID string) ([]*InitialPrompt, error) {
 	query := `
 		SELECT ip.id, ip.name, ip.description, ip.content, ip.includes_permissions, 
-		       ip.permission_template, ip.is_active, ip.is_default, ip.created_at, ip.updated_at
+		       ip.permission_template, ip.is_active, ip.is_default, ip.created_at, ip.updated_at,
+		       COALESCE(ip.tags::VARCHAR, '[]'), COALESCE(ip.source, '')
 		FROM initial_prompts ip
 		JOIN session_initial_prompts sip ON ip.id = sip.prompt_id
 		WHERE sip.session_id = ?
@@ -226,10 +252,11 @@ func (db *DB) GetSessionInitialPrompts(sessionID string) ([]*InitialPrompt, erro
 	for rows.Next() {
 		prompt := &InitialPrompt{}
 		var permTemplateJSON duckdb.Composite[map[string]interface{}]
+		var tagsJSON string
 
 		err := rows.Scan(&prompt.ID, &prompt.Name, &prompt.Description, &prompt.Content,
 			&prompt.IncludesPermissions, &permTemplateJSON, &prompt.IsActive,
-			&prompt.IsDefault, &prompt.CreatedAt, &prompt.UpdatedAt)
+			&prompt.IsDefault, &prompt.CreatedAt, &prompt.UpdatedAt, &tagsJSON, &prompt.Source)
 		if err != nil {
 			return nil, serr.Wrap(err, "failed to scan initial prompt")
 		}
@@ -238,6 +265,7 @@ func (db *DB) GetSessionInitialPrompts(sessionID string) ([]*InitialPrompt, erro
 		if permTemplateJSON.Get() != nil {
 			prompt.PermissionTemplate = permTemplateJSON.Get()
 		}
+		prompt.Tags = decodeTags(tagsJSON)
 
 		prompts = append(prompts, prompt)
 	}
@@ -273,7 +301,7 @@ func (db *DB) AssociatePromptsWithSession(sessionID string, promptIDs []int) err
 func (db *DB) getInitialPromptsByCondition(condition string) ([]*InitialPrompt, error) {
 	query := `
 		SELECT id, name, description, content, includes_permissions, permission_template, 
-		       is_active, is_default, created_at, updated_at
+		       is_active, is_default, created_at, updated_at, COALESCE(tags::VARCHAR, '[]'), COALESCE(source, '')
 		FROM initial_prompts
 	` + condition + " ORDER BY name ASC"
 
@@ -287,10 +315,11 @@ func (db *DB) getInitialPromptsByCondition(condition string) ([]*InitialPrompt,
 	for rows.Next() {
 		prompt := &InitialPrompt{}
 		var permTemplateJSON duckdb.Composite[map[string]interface{}]
+		var tagsJSON string
 
 		err := rows.Scan(&prompt.ID, &prompt.Name, &prompt.Description, &prompt.Content,
 			&prompt.IncludesPermissions, &permTemplateJSON, &prompt.IsActive,
-			&prompt.IsDefault, &prompt.CreatedAt, &prompt.UpdatedAt)
+			&prompt.IsDefault, &prompt.CreatedAt, &prompt.UpdatedAt, &tagsJSON, &prompt.Source)
 		if err != nil {
 			return nil, serr.Wrap(err, "failed to scan initial prompt")
 		}
@@ -299,6 +328,7 @@ func (db *DB) getInitialPromptsByCondition(condition string) ([]*InitialPrompt,
 		if permTemplateJSON.Get() != nil {
 			prompt.PermissionTemplate = permTemplateJSON.Get()
 		}
+		prompt.Tags = decodeTags(tagsJSON)
 
 		prompts = append(prompts, prompt)
 	}
@@ -0,0 +1,276 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// Memory categories describe what kind of fact a memory holds
+const (
+	MemoryCategoryConvention = "convention"
+	MemoryCategoryDecision   = "decision"
+	MemoryCategoryGotcha     = "gotcha"
+	MemoryCategoryNote       = "note"
+)
+
+const (
+	defaultMemoryLimit = 10
+	maxMemoryScan      = 500 // Upper bound on memories scored per recall
+)
+
+// ProjectMemory is a durable fact about a project, shared by all of its sessions
+type ProjectMemory struct {
+	ID              int        `json:"id"`
+	ProjectRoot     string     `json:"project_root"`
+	Category        string     `json:"category"`
+	Content         string     `json:"content"`
+	Tags            []string   `json:"tags"`
+	SourceSessionID string     `json:"source_session_id,omitempty"`
+	RecallCount     int        `json:"recall_count"`
+	LastRecalledAt  *time.Time `json:"last_recalled_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// ValidMemoryCategory reports whether category is one of the known memory categories
+func ValidMemoryCategory(category string) bool {
+	switch category {
+	case MemoryCategoryConvention, MemoryCategoryDecision, MemoryCategoryGotcha, MemoryCategoryNote:
+		return true
+	}
+	return false
+}
+
+// SaveMemory stores a memory for its project. If the project already holds a memory
+// with the same content, that memory is refreshed instead of adding a duplicate.
+func (db *DB) SaveMemory(memory *ProjectMemory) error {
+	memory.Content = strings.TrimSpace(memory.Content)
+	if memory.Content == "" {
+		return serr.New("memory content is required")
+	}
+	if memory.Category == "" {
+		memory.Category = MemoryCategoryNote
+	}
+	if !ValidMemoryCategory(memory.Category) {
+		return serr.New("invalid memory category: " + memory.Category)
+	}
+	if memory.Tags == nil {
+		memory.Tags = []string{}
+	}
+
+	tagsJSON, err := json.Marshal(memory.Tags)
+	if err != nil {
+		return serr.Wrap(err, "failed to marshal memory tags")
+	}
+
+	var existingID int
+	err = db.QueryRow(`
+		SELECT id FROM project_memories WHERE project_root = ? AND content = ?
+	`, memory.ProjectRoot, memory.Content).Scan(&existingID)
+	if err != nil && err != sql.ErrNoRows {
+		return serr.Wrap(err, "failed to check for existing memory")
+	}
+
+	if err == nil {
+		err = db.WriteRow(`
+			UPDATE project_memories
+			SET category = ?, tags = ?::JSON, updated_at = CURRENT_TIMESTAMP
+			WHERE id = ?
+			RETURNING id, created_at, updated_at
+		`, []interface{}{memory.Category, string(tagsJSON), existingID},
+			&memory.ID, &memory.CreatedAt, &memory.UpdatedAt)
+		if err != nil {
+			return serr.Wrap(err, "failed to update memory")
+		}
+		return nil
+	}
+
+	err = db.WriteRow(`
+		INSERT INTO project_memories (project_root, category, content, tags, source_session_id)
+		VALUES (?, ?, ?, ?::JSON, ?)
+		RETURNING id, created_at, updated_at
+	`, []interface{}{memory.ProjectRoot, memory.Category, memory.Content, string(tagsJSON), memory.SourceSessionID},
+		&memory.ID, &memory.CreatedAt, &memory.UpdatedAt)
+	if err != nil {
+		return serr.Wrap(err, "failed to save memory")
+	}
+
+	return nil
+}
+
+// SearchMemories returns the project's memories that best match the query, optionally
+// limited to one category, and counts them as recalled. An empty query returns the
+// most frequently recalled memories.
+func (db *DB) SearchMemories(projectRoot, query, category string, limit int) ([]*ProjectMemory, error) {
+	if limit <= 0 {
+		limit = defaultMemoryLimit
+	}
+
+	memories, err := db.ListMemories(projectRoot, category)
+	if err != nil {
+		return nil, err
+	}
+
+	terms := strings.Fields(strings.ToLower(query))
+	if len(terms) > 0 {
+		scores := make(map[int]float64, len(memories))
+		matched := memories[:0]
+		for _, m := range memories {
+			if score := scoreMemory(m, terms); score > 0 {
+				scores[m.ID] = score
+				matched = append(matched, m)
+			}
+		}
+		memories = matched
+		sort.SliceStable(memories, func(i, j int) bool {
+			return scores[memories[i].ID] > scores[memories[j].ID]
+		})
+	}
+
+	if len(memories) > limit {
+		memories = memories[:limit]
+	}
+
+	if err := db.markMemoriesRecalled(memories); err != nil {
+		return nil, err
+	}
+
+	return memories, nil
+}
+
+// TopMemories returns the memories most worth injecting into a new session:
+// the most frequently recalled first, then the most recently updated
+func (db *DB) TopMemories(projectRoot string, limit int) ([]*ProjectMemory, error) {
+	if limit <= 0 {
+		limit = defaultMemoryLimit
+	}
+
+	memories, err := db.ListMemories(projectRoot, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(memories) > limit {
+		memories = memories[:limit]
+	}
+	return memories, nil
+}
+
+// ListMemories returns a project's memories, optionally limited to one category,
+// most frequently recalled first
+func (db *DB) ListMemories(projectRoot, category string) ([]*ProjectMemory, error) {
+	query := `
+		SELECT id, project_root, category, content, tags::VARCHAR, COALESCE(source_session_id, ''),
+		       recall_count, last_recalled_at, created_at, updated_at
+		FROM project_memories
+		WHERE project_root = ?`
+	args := []interface{}{projectRoot}
+	if category != "" {
+		query += " AND category = ?"
+		args = append(args, category)
+	}
+	query += " ORDER BY recall_count DESC, updated_at DESC LIMIT ?"
+	args = append(args, maxMemoryScan)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to query memories")
+	}
+	defer rows.Close()
+
+	memories := make([]*ProjectMemory, 0)
+	for rows.Next() {
+		m := &ProjectMemory{}
+		var tagsJSON sql.NullString
+		var lastRecalled sql.NullTime
+		if err := rows.Scan(&m.ID, &m.ProjectRoot, &m.Category, &m.Content, &tagsJSON, &m.SourceSessionID,
+			&m.RecallCount, &lastRecalled, &m.CreatedAt, &m.UpdatedAt); err != nil {
+			return nil, serr.Wrap(err, "failed to scan memory")
+		}
+
+		m.Tags = []string{}
+		if tagsJSON.Valid && tagsJSON.String != "" {
+			if err := json.Unmarshal([]byte(tagsJSON.String), &m.Tags); err != nil {
+				return nil, serr.Wrap(err, "failed to unmarshal memory tags")
+			}
+		}
+		if lastRecalled.Valid {
+			m.LastRecalledAt = &lastRecalled.Time
+		}
+
+		memories = append(memories, m)
+	}
+
+	return memories, rows.Err()
+}
+
+// DeleteMemory removes a memory
+func (db *DB) DeleteMemory(id int) error {
+	result, err := db.Exec("DELETE FROM project_memories WHERE id = ?", id)
+	if err != nil {
+		return serr.Wrap(err, "failed to delete memory")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return serr.Wrap(err, "failed to get rows affected")
+	}
+
+	if rowsAffected == 0 {
+		return serr.New("memory not found")
+	}
+
+	return nil
+}
+
+// markMemoriesRecalled bumps the recall count of memories returned to the assistant,
+// so frequently useful memories rank first in new sessions
+func (db *DB) markMemoriesRecalled(memories []*ProjectMemory) error {
+	now := time.Now()
+	for _, m := range memories {
+		if _, err := db.Exec(`
+			UPDATE project_memories
+			SET recall_count = recall_count + 1, last_recalled_at = ?
+			WHERE id = ?
+		`, now, m.ID); err != nil {
+			return serr.Wrap(err, "failed to update memory recall count")
+		}
+		m.RecallCount++
+		m.LastRecalledAt = &now
+	}
+	return nil
+}
+
+// scoreMemory rates how well a memory matches lowercased query terms.
+// Tag and category matches count more than content matches.
+func scoreMemory(m *ProjectMemory, terms []string) float64 {
+	content := strings.ToLower(m.Content)
+
+	var score float64
+	for _, term := range terms {
+		if strings.Contains(content, term) {
+			score += 1
+		}
+		for _, tag := range m.Tags {
+			if strings.Contains(strings.ToLower(tag), term) {
+				score += 2
+				break
+			}
+		}
+		if m.Category == term {
+			score += 1
+		}
+	}
+
+	// Break ties towards memories that have proven useful
+	if score > 0 {
+		score += math.Log1p(float64(m.RecallCount)) * 0.1
+	}
+	return score
+}
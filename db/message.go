@@ -8,37 +8,76 @@ import (
 	"github.com/rohanthewiz/logger"
 	"github.com/rohanthewiz/serr"
 	"rcode/providers"
+	"rcode/redact"
 )
 
 // Message represents a chat message in the database
 type Message struct {
-	ID         int              `json:"id"`
-	SessionID  string           `json:"session_id"`
-	Role       string           `json:"role"`
-	Content    interface{}      `json:"content"`
-	CreatedAt  time.Time        `json:"created_at"`
-	Model      string           `json:"model,omitempty"`
-	TokenUsage *providers.Usage `json:"token_usage,omitempty"`
+	ID         int                    `json:"id"`
+	SessionID  string                 `json:"session_id"`
+	Role       string                 `json:"role"`
+	Content    interface{}            `json:"content"`
+	CreatedAt  time.Time              `json:"created_at"`
+	Model      string                 `json:"model,omitempty"`
+	TokenUsage *providers.Usage       `json:"token_usage,omitempty"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+	Status     string                 `json:"status,omitempty"` // MessagePartial or MessageInterrupted; empty when complete
 }
 
-// AddMessageWithID adds a message to a session and returns the message ID
-func (db *DB) AddMessageWithID(sessionID string, msg providers.ChatMessage, model string, usage *providers.Usage) (*int, error) {
-	// Convert content to JSON
+// Statuses of assistant replies that are not complete
+const (
+	MessagePartial     = "partial"     // Still being streamed, or the server stopped while it was
+	MessageInterrupted = "interrupted" // The stream never finished
+)
+
+// marshalMessageMetadata returns message metadata as JSON, or "null" if there is none
+func marshalMessageMetadata(metadata map[string]interface{}) (string, error) {
+	if len(metadata) == 0 {
+		return "null", nil
+	}
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return "", serr.Wrap(err, "failed to marshal message metadata")
+	}
+	return string(metadataJSON), nil
+}
+
+// marshalMessage returns a message's content, with its secrets masked, token usage and
+// metadata as JSON
+func marshalMessage(msg providers.ChatMessage, usage *providers.Usage) (content, usageJSON, metadata string, err error) {
 	contentJSON, err := json.Marshal(msg.Content)
 	if err != nil {
-		return nil, serr.Wrap(err, "failed to marshal message content")
+		return "", "", "", serr.Wrap(err, "failed to marshal message content")
 	}
+	contentJSON = redact.Default().JSON(contentJSON)
 
 	// Convert token usage to JSON if present
-	var usageJSONStr string
+	usageJSON = "null"
 	if usage != nil {
-		usageJSON, err := json.Marshal(usage)
+		data, err := json.Marshal(usage)
 		if err != nil {
-			return nil, serr.Wrap(err, "failed to marshal token usage")
+			return "", "", "", serr.Wrap(err, "failed to marshal token usage")
 		}
-		usageJSONStr = string(usageJSON)
-	} else {
-		usageJSONStr = "null"
+		usageJSON = string(data)
+	}
+
+	metadata, err = marshalMessageMetadata(msg.Metadata)
+	if err != nil {
+		return "", "", "", err
+	}
+	return string(contentJSON), usageJSON, metadata, nil
+}
+
+// AddMessageWithID adds a message to a session and returns the message ID
+func (db *DB) AddMessageWithID(sessionID string, msg providers.ChatMessage, model string, usage *providers.Usage) (*int, error) {
+	return db.addMessage(sessionID, msg, model, usage, "")
+}
+
+// addMessage adds a message with a status, empty for a complete message
+func (db *DB) addMessage(sessionID string, msg providers.ChatMessage, model string, usage *providers.Usage, status string) (*int, error) {
+	contentJSON, usageJSONStr, metadataJSONStr, err := marshalMessage(msg, usage)
+	if err != nil {
+		return nil, err
 	}
 
 	// Handle empty model
@@ -47,21 +86,16 @@ func (db *DB) AddMessageWithID(sessionID string, msg providers.ChatMessage, mode
 	}
 
 	query := `
-		INSERT INTO messages (session_id, role, content, model, token_usage, created_at)
-		VALUES (?, ?, ?::JSON, NULLIF(?, 'null'), ?::JSON, CURRENT_TIMESTAMP)
+		INSERT INTO messages (session_id, role, content, model, token_usage, metadata, status, created_at)
+		VALUES (?, ?, ?::JSON, NULLIF(?, 'null'), ?::JSON, ?::JSON, NULLIF(?, ''), CURRENT_TIMESTAMP)
+		RETURNING id
 	`
 
-	result, err := db.Exec(query, sessionID, msg.Role, string(contentJSON), model, usageJSONStr)
-	if err != nil {
-		return nil, serr.Wrap(err, "failed to add message")
-	}
-	_ = result // Suppress unused variable warning
-
-	// Get the last insert ID using DuckDB's method
+	// RETURNING rather than currval, which another connection's insert may have moved on
 	var messageID int
-	err = db.QueryRow("SELECT currval('messages_id_seq')").Scan(&messageID)
+	err = db.WriteRow(query, []interface{}{sessionID, msg.Role, contentJSON, model, usageJSONStr, metadataJSONStr, status}, &messageID)
 	if err != nil {
-		return nil, serr.Wrap(err, "failed to get message ID")
+		return nil, serr.Wrap(err, "failed to add message")
 	}
 
 	// Update session's updated_at timestamp
@@ -74,6 +108,67 @@ func (db *DB) AddMessageWithID(sessionID string, msg providers.ChatMessage, mode
 	return &messageID, nil
 }
 
+// SavePartialMessage stores the part of an assistant reply streamed so far, so it outlives
+// the server stopping mid-stream: its text as the content, and the tool calls it has begun,
+// which can't go to the model without their results, in the metadata. The first save adds
+// the message and returns its ID; pass the ID to later saves of the reply to update it.
+func (db *DB) SavePartialMessage(sessionID string, messageID *int, text string, toolUses []interface{}, model string) (*int, error) {
+	msg := providers.ChatMessage{Role: "assistant", Content: text}
+	if len(toolUses) > 0 {
+		msg.Metadata = map[string]interface{}{"partialToolUses": toolUses}
+	}
+	if messageID == nil {
+		return db.addMessage(sessionID, msg, model, nil, MessagePartial)
+	}
+	return messageID, db.updateMessage(*messageID, msg, model, nil, MessagePartial)
+}
+
+// FinalizeMessage replaces a partial reply with the complete one
+func (db *DB) FinalizeMessage(messageID int, msg providers.ChatMessage, model string, usage *providers.Usage) error {
+	return db.updateMessage(messageID, msg, model, usage, "")
+}
+
+// InterruptMessage marks a partial reply whose stream failed as interrupted
+func (db *DB) InterruptMessage(messageID int) error {
+	_, err := db.Exec("UPDATE messages SET status = ? WHERE id = ? AND status = ?", MessageInterrupted, messageID, MessagePartial)
+	if err != nil {
+		return serr.Wrap(err, "failed to mark message interrupted")
+	}
+	return nil
+}
+
+// MarkInterruptedMessages marks the replies still partial, which were being streamed when
+// the server stopped, as interrupted. Call it at startup, before any reply is streamed.
+func (db *DB) MarkInterruptedMessages() (int64, error) {
+	result, err := db.Exec("UPDATE messages SET status = ? WHERE status = ?", MessageInterrupted, MessagePartial)
+	if err != nil {
+		return 0, serr.Wrap(err, "failed to mark interrupted messages")
+	}
+	n, _ := result.RowsAffected()
+	return n, nil
+}
+
+// updateMessage replaces a message's content, model, usage, metadata and status
+func (db *DB) updateMessage(messageID int, msg providers.ChatMessage, model string, usage *providers.Usage, status string) error {
+	contentJSON, usageJSON, metadataJSON, err := marshalMessage(msg, usage)
+	if err != nil {
+		return err
+	}
+	if model == "" {
+		model = "null"
+	}
+
+	_, err = db.Exec(`
+		UPDATE messages
+		SET content = ?::JSON, model = NULLIF(?, 'null'), token_usage = ?::JSON, metadata = ?::JSON, status = NULLIF(?, '')
+		WHERE id = ?
+	`, contentJSON, model, usageJSON, metadataJSON, status, messageID)
+	if err != nil {
+		return serr.Wrap(err, "failed to update message")
+	}
+	return nil
+}
+
 // AddMessage adds a message to a session (wrapper for backward compatibility)
 func (db *DB) AddMessage(sessionID string, msg providers.ChatMessage, model string, usage *providers.Usage) error {
 	_, err := db.AddMessageWithID(sessionID, msg, model, usage)
@@ -129,7 +224,7 @@ func (db *DB) GetMessages(sessionID string) ([]providers.ChatMessage, error) {
 // GetMessagesWithMetadata retrieves messages with full metadata
 func (db *DB) GetMessagesWithMetadata(sessionID string) ([]*Message, error) {
 	query := `
-		SELECT id, session_id, role, content::VARCHAR, created_at, model, token_usage::VARCHAR
+		SELECT id, session_id, role, content::VARCHAR, created_at, model, token_usage::VARCHAR, metadata::VARCHAR, status
 		FROM messages
 		WHERE session_id = ?
 		ORDER BY created_at ASC
@@ -147,6 +242,8 @@ func (db *DB) GetMessagesWithMetadata(sessionID string) ([]*Message, error) {
 		var contentJSON string
 		var model sql.NullString
 		var usageJSON sql.NullString
+		var metadataJSON sql.NullString
+		var status sql.NullString
 
 		err := rows.Scan(
 			&msg.ID,
@@ -156,6 +253,8 @@ func (db *DB) GetMessagesWithMetadata(sessionID string) ([]*Message, error) {
 			&msg.CreatedAt,
 			&model,
 			&usageJSON,
+			&metadataJSON,
+			&status,
 		)
 		if err != nil {
 			return nil, serr.Wrap(err, "failed to scan message row")
@@ -173,6 +272,7 @@ func (db *DB) GetMessagesWithMetadata(sessionID string) ([]*Message, error) {
 		if model.Valid {
 			msg.Model = model.String
 		}
+		msg.Status = status.String
 
 		// Parse token usage if present
 		if usageJSON.Valid && usageJSON.String != "" {
@@ -182,12 +282,27 @@ func (db *DB) GetMessagesWithMetadata(sessionID string) ([]*Message, error) {
 			}
 		}
 
+		// Parse metadata if present
+		if metadataJSON.Valid && metadataJSON.String != "" {
+			if err := json.Unmarshal([]byte(metadataJSON.String), &msg.Metadata); err != nil {
+				logger.LogErr(err, "failed to parse message metadata", "message_id", msg.ID)
+			}
+		}
+
 		messages = append(messages, &msg)
 	}
 
 	return messages, nil
 }
 
+// DeleteMessage deletes a message, such as a user's that wasn't sent after all
+func (db *DB) DeleteMessage(messageID int) error {
+	if _, err := db.Exec("DELETE FROM messages WHERE id = ?", messageID); err != nil {
+		return serr.Wrap(err, "failed to delete message")
+	}
+	return nil
+}
+
 // DeleteMessagesBySession deletes all messages for a session
 func (db *DB) DeleteMessagesBySession(sessionID string) error {
 	_, err := db.Exec("DELETE FROM messages WHERE session_id = ?", sessionID)
@@ -0,0 +1,10 @@
+-- The migrations table stays: it records what is applied
+DROP TABLE IF EXISTS tool_usage;
+DROP TABLE IF EXISTS tool_permissions;
+DROP TABLE IF EXISTS messages;
+DROP TABLE IF EXISTS sessions;
+
+-- Added by migration 2
+DROP SEQUENCE IF EXISTS tool_usage_id_seq;
+DROP SEQUENCE IF EXISTS tool_permissions_id_seq;
+DROP SEQUENCE IF EXISTS messages_id_seq;
@@ -0,0 +1,57 @@
+-- Create sessions table
+CREATE TABLE IF NOT EXISTS sessions (
+	id TEXT PRIMARY KEY,
+	title TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	initial_prompts TEXT[],
+	model_preference TEXT,
+	metadata JSON
+);
+
+-- Create messages table
+CREATE TABLE IF NOT EXISTS messages (
+	id INTEGER PRIMARY KEY,
+	session_id TEXT NOT NULL,
+	role TEXT NOT NULL,
+	content JSON NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	model TEXT,
+	token_usage JSON,
+	FOREIGN KEY (session_id) REFERENCES sessions(id)
+);
+CREATE INDEX IF NOT EXISTS idx_messages_session ON messages(session_id);
+
+-- Create tool_permissions table
+CREATE TABLE IF NOT EXISTS tool_permissions (
+	id INTEGER PRIMARY KEY,
+	session_id TEXT NOT NULL,
+	tool_name TEXT NOT NULL,
+	permission_type TEXT NOT NULL CHECK (permission_type IN ('allowed', 'denied', 'ask')),
+	granted_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	expires_at TIMESTAMP,
+	scope JSON,
+	FOREIGN KEY (session_id) REFERENCES sessions(id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tool_permissions_session_tool ON tool_permissions(session_id, tool_name);
+
+-- Create tool_usage table
+CREATE TABLE IF NOT EXISTS tool_usage (
+	id INTEGER PRIMARY KEY,
+	session_id TEXT NOT NULL,
+	tool_name TEXT NOT NULL,
+	input JSON NOT NULL,
+	output TEXT,
+	executed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	duration_ms INTEGER,
+	error TEXT,
+	FOREIGN KEY (session_id) REFERENCES sessions(id)
+);
+CREATE INDEX IF NOT EXISTS idx_tool_usage_session ON tool_usage(session_id);
+
+-- Create migrations table
+CREATE TABLE IF NOT EXISTS migrations (
+	version INTEGER PRIMARY KEY,
+	description TEXT NOT NULL,
+	applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
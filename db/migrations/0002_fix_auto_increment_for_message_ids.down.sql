@@ -0,0 +1,2 @@
+-- The tables keep their auto-increment, which migration 1 needed all along; undoing
+-- migration 1 drops them and their sequences
@@ -0,0 +1,50 @@
+-- Drop and recreate messages table with proper auto-increment
+DROP TABLE IF EXISTS messages;
+
+-- Create sequence for messages
+CREATE SEQUENCE IF NOT EXISTS messages_id_seq;
+
+-- Recreate messages table with sequence
+CREATE TABLE messages (
+	id INTEGER PRIMARY KEY DEFAULT nextval('messages_id_seq'),
+	session_id TEXT NOT NULL,
+	role TEXT NOT NULL,
+	content JSON NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	model TEXT,
+	token_usage JSON,
+	FOREIGN KEY (session_id) REFERENCES sessions(id)
+);
+CREATE INDEX idx_messages_session ON messages(session_id);
+
+-- Also fix tool_permissions and tool_usage tables
+DROP TABLE IF EXISTS tool_permissions;
+DROP TABLE IF EXISTS tool_usage;
+
+CREATE SEQUENCE IF NOT EXISTS tool_permissions_id_seq;
+CREATE SEQUENCE IF NOT EXISTS tool_usage_id_seq;
+
+CREATE TABLE tool_permissions (
+	id INTEGER PRIMARY KEY DEFAULT nextval('tool_permissions_id_seq'),
+	session_id TEXT NOT NULL,
+	tool_name TEXT NOT NULL,
+	permission_type TEXT NOT NULL CHECK (permission_type IN ('allowed', 'denied', 'ask')),
+	granted_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	expires_at TIMESTAMP,
+	scope JSON,
+	FOREIGN KEY (session_id) REFERENCES sessions(id)
+);
+CREATE UNIQUE INDEX idx_tool_permissions_session_tool ON tool_permissions(session_id, tool_name);
+
+CREATE TABLE tool_usage (
+	id INTEGER PRIMARY KEY DEFAULT nextval('tool_usage_id_seq'),
+	session_id TEXT NOT NULL,
+	tool_name TEXT NOT NULL,
+	input JSON NOT NULL,
+	output TEXT,
+	executed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	duration_ms INTEGER,
+	error TEXT,
+	FOREIGN KEY (session_id) REFERENCES sessions(id)
+);
+CREATE INDEX idx_tool_usage_session ON tool_usage(session_id);
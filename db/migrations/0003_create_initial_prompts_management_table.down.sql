@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS session_initial_prompts;
+DROP TABLE IF EXISTS initial_prompts;
+DROP SEQUENCE IF EXISTS initial_prompts_id_seq;
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS task_logs;
+DROP TABLE IF EXISTS task_metrics;
+DROP TABLE IF EXISTS file_snapshots;
+DROP TABLE IF EXISTS task_executions;
+DROP TABLE IF EXISTS task_plans;
+DROP SEQUENCE IF EXISTS task_logs_id_seq;
+DROP SEQUENCE IF EXISTS file_snapshots_id_seq;
+DROP SEQUENCE IF EXISTS task_executions_id_seq;
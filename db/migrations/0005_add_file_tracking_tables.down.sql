@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS session_files;
+DROP TABLE IF EXISTS file_access;
+DROP SEQUENCE IF EXISTS file_access_id_seq; -- Added by migration 7
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS diff_preferences;
+DROP TABLE IF EXISTS diff_views;
+DROP TABLE IF EXISTS diffs;
+DROP TABLE IF EXISTS diff_snapshots;
+DROP SEQUENCE IF EXISTS diffs_id_seq;
+DROP SEQUENCE IF EXISTS diff_snapshots_id_seq;
@@ -0,0 +1,2 @@
+-- file_access keeps its auto-increment, which migration 5 needed all along; undoing
+-- migration 5 drops the table and its sequence
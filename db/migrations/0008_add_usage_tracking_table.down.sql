@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS usage_tracking;
+DROP SEQUENCE IF EXISTS usage_tracking_id_seq;
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS archived_messages;
+DROP TABLE IF EXISTS compacted_messages;
+DROP SEQUENCE IF EXISTS compacted_messages_id_seq;
+
+-- The compaction columns stay on sessions: DuckDB can't alter a table other tables
+-- reference, and migration 9 adds them only if they are missing
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_messages_created;
+DROP INDEX IF EXISTS idx_messages_session_role;
+DROP INDEX IF EXISTS idx_file_access_session_path;
+DROP INDEX IF EXISTS idx_diffs_session_path;
@@ -0,0 +1,10 @@
+-- Support recency-ordered scans of message content
+CREATE INDEX IF NOT EXISTS idx_messages_created ON messages(created_at);
+CREATE INDEX IF NOT EXISTS idx_messages_session_role ON messages(session_id, role);
+
+-- Support lookups of touched file paths per session
+CREATE INDEX IF NOT EXISTS idx_file_access_session_path ON file_access(session_id, file_path);
+CREATE INDEX IF NOT EXISTS idx_diffs_session_path ON diffs(session_id, file_path);
+
+-- Note: sessions columns are not indexed since DuckDB rewrites updated indexed
+-- rows as delete+insert, which trips the foreign keys referencing sessions
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS task_templates;
+DROP SEQUENCE IF EXISTS task_templates_id_seq;
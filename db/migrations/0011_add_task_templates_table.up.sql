@@ -0,0 +1,13 @@
+CREATE SEQUENCE IF NOT EXISTS task_templates_id_seq;
+
+CREATE TABLE IF NOT EXISTS task_templates (
+	id INTEGER PRIMARY KEY DEFAULT nextval('task_templates_id_seq'),
+	name TEXT NOT NULL UNIQUE,
+	description TEXT,
+	category TEXT,
+	steps JSON NOT NULL,
+	variables JSON,
+	is_builtin BOOLEAN DEFAULT false,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
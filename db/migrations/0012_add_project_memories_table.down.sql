@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS project_memories;
+DROP SEQUENCE IF EXISTS project_memories_id_seq;
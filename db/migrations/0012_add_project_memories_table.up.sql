@@ -0,0 +1,17 @@
+-- Durable facts about a project, kept across sessions
+-- No foreign key on source_session_id: memories outlive the session that created them
+CREATE SEQUENCE IF NOT EXISTS project_memories_id_seq;
+
+CREATE TABLE IF NOT EXISTS project_memories (
+	id INTEGER PRIMARY KEY DEFAULT nextval('project_memories_id_seq'),
+	project_root TEXT NOT NULL,
+	category TEXT NOT NULL,
+	content TEXT NOT NULL,
+	tags JSON,
+	source_session_id TEXT,
+	recall_count INTEGER NOT NULL DEFAULT 0,
+	last_recalled_at TIMESTAMP,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_project_memories_root ON project_memories(project_root);
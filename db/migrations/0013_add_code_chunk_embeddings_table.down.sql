@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS code_chunks;
+DROP SEQUENCE IF EXISTS code_chunks_id_seq;
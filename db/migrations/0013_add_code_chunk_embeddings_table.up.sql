@@ -0,0 +1,17 @@
+-- Embedded file chunks for semantic code search, one set per embedding model
+CREATE SEQUENCE IF NOT EXISTS code_chunks_id_seq;
+
+CREATE TABLE IF NOT EXISTS code_chunks (
+	id INTEGER PRIMARY KEY DEFAULT nextval('code_chunks_id_seq'),
+	project_root TEXT NOT NULL,
+	file_path TEXT NOT NULL,
+	file_hash TEXT NOT NULL, -- Hash of the whole file, to detect files needing reindexing
+	model TEXT NOT NULL,
+	start_line INTEGER NOT NULL,
+	end_line INTEGER NOT NULL,
+	content TEXT NOT NULL,
+	embedding REAL[] NOT NULL,
+	indexed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_code_chunks_root_model ON code_chunks(project_root, model);
+CREATE INDEX IF NOT EXISTS idx_code_chunks_file ON code_chunks(project_root, file_path);
@@ -0,0 +1,11 @@
+-- Project files packed for a session's first task, sent with each of its requests
+-- No foreign key, so updates to sessions are not blocked; rows are removed by DeleteSession
+CREATE TABLE IF NOT EXISTS session_context (
+	session_id TEXT PRIMARY KEY,
+	task TEXT NOT NULL,
+	content TEXT NOT NULL,
+	tokens INTEGER NOT NULL,
+	budget INTEGER NOT NULL,
+	files JSON,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,2 @@
+-- metadata stays on messages: DuckDB can't alter a table other tables reference, and
+-- migration 15 adds it only if it is missing. Older versions ignore it.
@@ -0,0 +1,2 @@
+-- Attachments sent with a message (images, @file mentions), needed to rebuild later requests
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS metadata JSON;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS review_findings;
+DROP TABLE IF EXISTS code_reviews;
+DROP SEQUENCE IF EXISTS review_findings_id_seq;
+DROP SEQUENCE IF EXISTS code_reviews_id_seq;
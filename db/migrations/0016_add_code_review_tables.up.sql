@@ -0,0 +1,33 @@
+-- Model reviews of a diff range, and what each one found
+-- No foreign key on review_id, so reviews can be updated; findings are removed by DeleteReview
+CREATE SEQUENCE IF NOT EXISTS code_reviews_id_seq;
+CREATE SEQUENCE IF NOT EXISTS review_findings_id_seq;
+
+CREATE TABLE IF NOT EXISTS code_reviews (
+	id INTEGER PRIMARY KEY DEFAULT nextval('code_reviews_id_seq'),
+	project_root TEXT NOT NULL,
+	base_ref TEXT NOT NULL,
+	head_ref TEXT NOT NULL,
+	status TEXT NOT NULL, -- running, completed, failed
+	summary TEXT,
+	error TEXT,
+	files_reviewed INTEGER NOT NULL DEFAULT 0,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	completed_at TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_code_reviews_root ON code_reviews(project_root);
+
+CREATE TABLE IF NOT EXISTS review_findings (
+	id INTEGER PRIMARY KEY DEFAULT nextval('review_findings_id_seq'),
+	review_id INTEGER NOT NULL,
+	file_path TEXT NOT NULL,
+	line INTEGER NOT NULL,
+	end_line INTEGER NOT NULL,
+	severity TEXT NOT NULL, -- critical, major, minor, info
+	title TEXT NOT NULL,
+	message TEXT NOT NULL,
+	suggestion TEXT,
+	resolved BOOLEAN NOT NULL DEFAULT false,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_review_findings_review ON review_findings(review_id);
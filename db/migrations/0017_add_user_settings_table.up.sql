@@ -0,0 +1,6 @@
+-- The user's preferences, e.g. default model and editor options, under the key 'preferences'
+CREATE TABLE IF NOT EXISTS user_settings (
+	key TEXT PRIMARY KEY,
+	value JSON NOT NULL,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
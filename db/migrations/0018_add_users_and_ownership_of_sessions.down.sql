@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS user_logins;
+DROP TABLE IF EXISTS users;
+DROP SEQUENCE IF EXISTS users_id_seq;
+
+-- user_id stays on sessions, task_plans and tool_permissions: DuckDB can't alter a table
+-- other tables reference or one with indexes, and migration 18 adds it only if it is missing
@@ -0,0 +1,25 @@
+-- Local accounts, used when RCODE_MULTI_USER is set
+CREATE SEQUENCE IF NOT EXISTS users_id_seq;
+CREATE TABLE IF NOT EXISTS users (
+	id INTEGER PRIMARY KEY DEFAULT nextval('users_id_seq'),
+	username TEXT NOT NULL UNIQUE,
+	password_hash TEXT NOT NULL,
+	is_admin BOOLEAN NOT NULL DEFAULT false,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Signed-in browsers; only a hash of each login token is stored
+-- No foreign key, so users can be updated; logins are removed by DeleteUser
+CREATE TABLE IF NOT EXISTS user_logins (
+	token_hash TEXT PRIMARY KEY,
+	user_id INTEGER NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	expires_at TIMESTAMP NOT NULL
+);
+
+-- The user who owns each session and what was created in it. NULL for rows
+-- created in single-user mode, which only admins can reach in multi-user mode.
+-- Not indexed, see migration 10.
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS user_id INTEGER;
+ALTER TABLE task_plans ADD COLUMN IF NOT EXISTS user_id INTEGER;
+ALTER TABLE tool_permissions ADD COLUMN IF NOT EXISTS user_id INTEGER;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS api_tokens;
+DROP SEQUENCE IF EXISTS api_tokens_id_seq;
@@ -0,0 +1,13 @@
+-- Tokens for scripts and CI; only a hash of each token is stored
+CREATE SEQUENCE IF NOT EXISTS api_tokens_id_seq;
+CREATE TABLE IF NOT EXISTS api_tokens (
+	id INTEGER PRIMARY KEY DEFAULT nextval('api_tokens_id_seq'),
+	name TEXT NOT NULL,
+	token_hash TEXT NOT NULL,
+	prefix TEXT NOT NULL,
+	scope TEXT NOT NULL,
+	user_id INTEGER, -- NULL for tokens issued in single-user mode
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	expires_at TIMESTAMP,
+	last_used_at TIMESTAMP
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS proxy_usage;
+DROP SEQUENCE IF EXISTS proxy_usage_id_seq;
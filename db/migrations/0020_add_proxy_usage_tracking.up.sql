@@ -0,0 +1,11 @@
+-- Tokens used through the /v1/messages proxy, which has no sessions
+CREATE SEQUENCE IF NOT EXISTS proxy_usage_id_seq;
+CREATE TABLE IF NOT EXISTS proxy_usage (
+	id INTEGER PRIMARY KEY DEFAULT nextval('proxy_usage_id_seq'),
+	client TEXT NOT NULL, -- The API token or user the request came from, or 'local'
+	model TEXT NOT NULL,
+	input_tokens INTEGER NOT NULL DEFAULT 0,
+	output_tokens INTEGER NOT NULL DEFAULT 0,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_proxy_usage_created ON proxy_usage(created_at);
@@ -0,0 +1,2 @@
+-- requested_model stays on usage_tracking: DuckDB can't drop a column from an indexed
+-- table, and migration 21 adds it only if it is missing. Older versions ignore it.
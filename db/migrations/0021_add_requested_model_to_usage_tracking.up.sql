@@ -0,0 +1,3 @@
+-- The model a message was asked of; model is the one that served it, which
+-- differs when the request fell back to another model
+ALTER TABLE usage_tracking ADD COLUMN IF NOT EXISTS requested_model TEXT;
@@ -0,0 +1,3 @@
+-- status stays on messages: DuckDB can't alter a table other tables reference, and
+-- migration 22 adds it only if it is missing. Older versions ignore it, showing the
+-- replies it marks as if they were complete.
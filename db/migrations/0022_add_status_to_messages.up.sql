@@ -0,0 +1,3 @@
+-- 'partial' while a reply is being streamed, 'interrupted' when the stream never
+-- finished; NULL for complete messages
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS status TEXT;
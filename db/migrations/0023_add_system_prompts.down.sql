@@ -0,0 +1,3 @@
+-- sessions.system_prompt_id is kept, as DuckDB can't drop a column of a table others reference
+DROP TABLE IF EXISTS system_prompts;
+DROP SEQUENCE IF EXISTS system_prompts_id_seq;
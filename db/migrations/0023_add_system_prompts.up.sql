@@ -0,0 +1,18 @@
+-- System prompts, added after rcode's own first line of every request's system prompt.
+-- The default applies to sessions without one of their own.
+CREATE SEQUENCE IF NOT EXISTS system_prompts_id_seq;
+CREATE TABLE IF NOT EXISTS system_prompts (
+	id INTEGER PRIMARY KEY DEFAULT nextval('system_prompts_id_seq'),
+	name TEXT NOT NULL UNIQUE,
+	description TEXT,
+	content TEXT NOT NULL, -- May use {project_language} and the other template variables
+	is_default BOOLEAN NOT NULL DEFAULT false,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- The session's system prompt in place of the default; NULL for the default
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS system_prompt_id INTEGER;
+
+INSERT INTO system_prompts (name, description, content) VALUES
+('project_aware', 'Tells the model about the project', 'You are working in {project_name}, a {project_language} project at {project_root}. Follow the conventions of the code around what you change. Today is {date}.');
@@ -0,0 +1,3 @@
+-- tags and source stay on initial_prompts: DuckDB can't drop a column of a table others
+-- reference, and migration 24 adds them only if they are missing. Older versions ignore
+-- them, showing project prompts as ordinary ones.
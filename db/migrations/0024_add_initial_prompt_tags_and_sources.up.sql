@@ -0,0 +1,6 @@
+-- Tags for finding, filtering and exporting prompts, as a JSON array of strings
+ALTER TABLE initial_prompts ADD COLUMN IF NOT EXISTS tags JSON;
+
+-- The file under .rcode/prompts a project prompt is read from, relative to the project;
+-- NULL for prompts made in rcode
+ALTER TABLE initial_prompts ADD COLUMN IF NOT EXISTS source TEXT;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS artifacts;
+DROP SEQUENCE IF EXISTS artifacts_id_seq;
@@ -0,0 +1,16 @@
+-- Files kept for a session, such as the full output of a tool call too large to send to the model
+-- No foreign key, so updates to sessions are not blocked; rows are removed by DeleteSession
+CREATE SEQUENCE IF NOT EXISTS artifacts_id_seq;
+
+CREATE TABLE IF NOT EXISTS artifacts (
+	id INTEGER PRIMARY KEY DEFAULT nextval('artifacts_id_seq'),
+	session_id TEXT NOT NULL,
+	name TEXT NOT NULL,
+	kind TEXT NOT NULL DEFAULT 'tool_output',
+	tool_use_id TEXT,
+	content_type TEXT NOT NULL DEFAULT 'text/plain',
+	content BYTEA NOT NULL,
+	size INTEGER NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_artifacts_session ON artifacts(session_id);
@@ -0,0 +1,3 @@
+-- plan_id and description stay on artifacts: DuckDB can't drop a column from an indexed
+-- table, and migration 26 adds them only if they are missing. Older versions ignore them.
+DROP INDEX IF EXISTS idx_artifacts_created_at;
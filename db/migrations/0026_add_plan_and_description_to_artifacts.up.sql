@@ -0,0 +1,5 @@
+-- Artifacts published by tools and plans: what they hold, and the plan that published them
+ALTER TABLE artifacts ADD COLUMN IF NOT EXISTS plan_id TEXT;
+ALTER TABLE artifacts ADD COLUMN IF NOT EXISTS description TEXT;
+-- Retention deletes artifacts by age
+CREATE INDEX IF NOT EXISTS idx_artifacts_created_at ON artifacts(created_at);
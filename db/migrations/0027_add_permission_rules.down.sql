@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS permission_rules;
+DROP SEQUENCE IF EXISTS permission_rules_id_seq;
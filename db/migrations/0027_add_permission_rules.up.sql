@@ -0,0 +1,16 @@
+-- Rules deciding a tool's permission by its arguments, for one session; a user's own rules are kept in their settings
+-- No foreign key, so updates to sessions are not blocked; rows are removed by DeleteSession
+CREATE SEQUENCE IF NOT EXISTS permission_rules_id_seq;
+
+CREATE TABLE IF NOT EXISTS permission_rules (
+	id INTEGER PRIMARY KEY DEFAULT nextval('permission_rules_id_seq'),
+	session_id TEXT NOT NULL,
+	position INTEGER NOT NULL,
+	tool TEXT NOT NULL,
+	argument TEXT NOT NULL,
+	glob_pattern TEXT,
+	regex_pattern TEXT,
+	action TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_permission_rules_session ON permission_rules(session_id);
@@ -0,0 +1,3 @@
+-- read_only stays on sessions: DuckDB can't alter a table other tables reference, and
+-- migration 28 adds it only if it is missing. Older versions ignore it, letting the
+-- session's tools change the project again.
@@ -0,0 +1,2 @@
+-- Read-only sessions deny the tools that change the project and ask before shell commands
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS read_only BOOLEAN DEFAULT false;
@@ -0,0 +1,3 @@
+-- auto_accept stays on sessions: DuckDB can't alter a table other tables reference, and
+-- migration 29 adds it only if it is missing. Older versions ignore it, asking before
+-- tools again.
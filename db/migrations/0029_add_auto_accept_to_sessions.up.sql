@@ -0,0 +1,2 @@
+-- Auto-accept sessions run tools without asking, within limits on what a turn may change
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS auto_accept BOOLEAN DEFAULT false;
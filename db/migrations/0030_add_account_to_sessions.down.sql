@@ -0,0 +1,3 @@
+-- account stays on sessions: DuckDB can't alter a table other tables reference, and
+-- migration 30 adds it only if it is missing. Older versions ignore it, using the single
+-- account they know.
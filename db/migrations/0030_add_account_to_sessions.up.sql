@@ -0,0 +1,2 @@
+-- The Claude account a session's requests are made with; NULL for the default account
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS account VARCHAR;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS project_recaps;
+DROP SEQUENCE IF EXISTS project_recaps_id_seq;
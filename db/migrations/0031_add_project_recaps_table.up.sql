@@ -0,0 +1,13 @@
+-- Recaps of closed sessions, offered to the next session on the same project
+-- No foreign key on session_id: a recap outlives the session it recaps
+CREATE SEQUENCE IF NOT EXISTS project_recaps_id_seq;
+
+CREATE TABLE IF NOT EXISTS project_recaps (
+	id INTEGER PRIMARY KEY DEFAULT nextval('project_recaps_id_seq'),
+	project_root TEXT NOT NULL,
+	session_id TEXT NOT NULL,
+	session_title TEXT,
+	content TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_project_recaps_root ON project_recaps(project_root);
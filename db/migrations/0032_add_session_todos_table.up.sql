@@ -0,0 +1,10 @@
+-- The task list the model keeps for a session with the todo_write tool, in order
+-- No foreign key, so updates to sessions are not blocked; rows are removed by DeleteSession
+CREATE TABLE IF NOT EXISTS session_todos (
+	session_id TEXT NOT NULL,
+	position INTEGER NOT NULL,
+	content TEXT NOT NULL,
+	status TEXT NOT NULL,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (session_id, position)
+);
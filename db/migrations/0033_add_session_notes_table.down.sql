@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS session_notes;
+DROP SEQUENCE IF EXISTS session_notes_id_seq;
@@ -0,0 +1,14 @@
+-- Notes kept for a session, by the model with the add_note tool and by the user in the
+-- notes panel, apart from its messages. Those marked in_context are sent with every request.
+-- No foreign key, so updates to sessions are not blocked; rows are removed by DeleteSession
+CREATE SEQUENCE IF NOT EXISTS session_notes_id_seq;
+
+CREATE TABLE IF NOT EXISTS session_notes (
+	id INTEGER PRIMARY KEY DEFAULT nextval('session_notes_id_seq'),
+	session_id TEXT NOT NULL,
+	content TEXT NOT NULL,
+	in_context BOOLEAN NOT NULL DEFAULT FALSE,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_session_notes_session ON session_notes(session_id);
@@ -0,0 +1,2 @@
+ALTER TABLE task_metrics DROP COLUMN IF EXISTS coverage_before;
+ALTER TABLE task_metrics DROP COLUMN IF EXISTS coverage_after;
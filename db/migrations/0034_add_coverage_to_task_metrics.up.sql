@@ -0,0 +1,4 @@
+-- Test coverage a plan's steps measured, first and last, in percent of statements; null
+-- for plans that don't measure it
+ALTER TABLE task_metrics ADD COLUMN IF NOT EXISTS coverage_before DOUBLE PRECISION;
+ALTER TABLE task_metrics ADD COLUMN IF NOT EXISTS coverage_after DOUBLE PRECISION;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS session_benchmarks;
+DROP SEQUENCE IF EXISTS session_benchmarks_id_seq;
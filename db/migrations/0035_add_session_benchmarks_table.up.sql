@@ -0,0 +1,16 @@
+-- Benchmark runs of the bench tool, kept for a session so later runs are compared with them.
+-- output holds the results in Go's benchmark format.
+-- No foreign key, so updates to sessions are not blocked; rows are removed by DeleteSession
+CREATE SEQUENCE IF NOT EXISTS session_benchmarks_id_seq;
+
+CREATE TABLE IF NOT EXISTS session_benchmarks (
+	id INTEGER PRIMARY KEY DEFAULT nextval('session_benchmarks_id_seq'),
+	session_id TEXT NOT NULL,
+	label TEXT NOT NULL DEFAULT '',
+	packages TEXT NOT NULL DEFAULT '',
+	bench TEXT NOT NULL DEFAULT '',
+	command TEXT NOT NULL DEFAULT '',
+	output TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_session_benchmarks_session ON session_benchmarks(session_id);
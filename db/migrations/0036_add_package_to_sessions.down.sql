@@ -0,0 +1,3 @@
+-- package_path stays on sessions: DuckDB can't alter a table other tables reference, and
+-- migration 36 adds it only if it is missing. Older versions ignore it, giving the session
+-- the whole repository again.
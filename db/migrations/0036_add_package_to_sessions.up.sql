@@ -0,0 +1,2 @@
+-- The monorepo package a session is scoped to, relative to the project root; NULL for the whole repository
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS package_path VARCHAR;
@@ -0,0 +1,2 @@
+-- user_id stays on project_recaps: DuckDB can't alter a table with indexes, and migration 37
+-- adds it only if it is missing. Older versions ignore it, offering every recap to every user.
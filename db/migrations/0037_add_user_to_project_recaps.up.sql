@@ -0,0 +1,7 @@
+-- The user who owns the session a recap was written for; NULL for recaps of sessions
+-- without an owner. Recaps written before take their session's owner.
+ALTER TABLE project_recaps ADD COLUMN IF NOT EXISTS user_id INTEGER;
+
+UPDATE project_recaps
+SET user_id = (SELECT s.user_id FROM sessions s WHERE s.id = project_recaps.session_id)
+WHERE user_id IS NULL;
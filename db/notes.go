@@ -0,0 +1,129 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// SessionNote is a note kept for a session apart from its messages: a decision, or a
+// command's output worth keeping. Notes in context are sent with every request.
+type SessionNote struct {
+	ID        int       `json:"id"`
+	SessionID string    `json:"session_id"`
+	Content   string    `json:"content"`
+	InContext bool      `json:"in_context"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// noteColumns are the columns scanned by scanNote
+const noteColumns = "id, session_id, content, in_context, created_at, updated_at"
+
+// AddNote stores a note, setting its ID and times
+func (db *DB) AddNote(note *SessionNote) error {
+	note.Content = strings.TrimSpace(note.Content)
+	if note.Content == "" {
+		return serr.New("note content is required")
+	}
+
+	err := db.WriteRow(`
+		INSERT INTO session_notes (session_id, content, in_context)
+		VALUES (?, ?, ?)
+		RETURNING id, created_at, updated_at
+	`, []interface{}{note.SessionID, note.Content, note.InContext}, &note.ID, &note.CreatedAt, &note.UpdatedAt)
+	if err != nil {
+		return serr.Wrap(err, "failed to add note")
+	}
+	return nil
+}
+
+// GetNote returns a session's note, or nil if the session has none with the ID
+func (db *DB) GetNote(sessionID string, id int) (*SessionNote, error) {
+	row := db.QueryRow("SELECT "+noteColumns+" FROM session_notes WHERE id = ? AND session_id = ?", id, sessionID)
+
+	note, err := scanNote(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to get note")
+	}
+	return note, nil
+}
+
+// GetNotes returns a session's notes, oldest first. With inContextOnly, only those sent
+// with every request are returned.
+func (db *DB) GetNotes(sessionID string, inContextOnly bool) ([]*SessionNote, error) {
+	query := "SELECT " + noteColumns + " FROM session_notes WHERE session_id = ?"
+	if inContextOnly {
+		query += " AND in_context"
+	}
+	rows, err := db.Query(query+" ORDER BY id", sessionID)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to get notes")
+	}
+	defer rows.Close()
+
+	notes := []*SessionNote{}
+	for rows.Next() {
+		note, err := scanNote(rows.Scan)
+		if err != nil {
+			return nil, serr.Wrap(err, "failed to scan note")
+		}
+		notes = append(notes, note)
+	}
+	return notes, rows.Err()
+}
+
+// UpdateNote changes a note's content and whether it is in context, setting its update
+// time. It reports whether the session had a note with its ID.
+func (db *DB) UpdateNote(note *SessionNote) (bool, error) {
+	note.Content = strings.TrimSpace(note.Content)
+	if note.Content == "" {
+		return false, serr.New("note content is required")
+	}
+
+	err := db.WriteRow(`
+		UPDATE session_notes
+		SET content = ?, in_context = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND session_id = ?
+		RETURNING updated_at
+	`, []interface{}{note.Content, note.InContext, note.ID, note.SessionID}, &note.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, serr.Wrap(err, "failed to update note")
+	}
+	return true, nil
+}
+
+// DeleteNote removes a session's note, reporting whether it had one with the ID
+func (db *DB) DeleteNote(sessionID string, id int) (bool, error) {
+	result, err := db.Exec("DELETE FROM session_notes WHERE id = ? AND session_id = ?", id, sessionID)
+	if err != nil {
+		return false, serr.Wrap(err, "failed to delete note")
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// DeleteSessionNotes removes a session's notes
+func (db *DB) DeleteSessionNotes(sessionID string) error {
+	if _, err := db.Exec("DELETE FROM session_notes WHERE session_id = ?", sessionID); err != nil {
+		return serr.Wrap(err, "failed to delete notes")
+	}
+	return nil
+}
+
+// scanNote reads a note's noteColumns
+func scanNote(scan func(dest ...interface{}) error) (*SessionNote, error) {
+	note := &SessionNote{}
+	if err := scan(&note.ID, &note.SessionID, &note.Content, &note.InContext, &note.CreatedAt, &note.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return note, nil
+}
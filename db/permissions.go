@@ -3,6 +3,8 @@ package db
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
+	"regexp"
 	"time"
 
 	"github.com/rohanthewiz/logger"
@@ -26,6 +28,36 @@ type PermissionScope struct {
 	CustomRules map[string]interface{} `json:"custom_rules,omitempty"`
 }
 
+// PermissionRule decides the permission of a tool's calls whose argument matches its
+// pattern, ahead of the tool's own permission. Sessions have rules, and so do users, in
+// their settings, for all their sessions.
+type PermissionRule struct {
+	Tool     string         `json:"tool"`            // Tool name
+	Argument string         `json:"argument"`        // Input field matched, such as path or command
+	Glob     string         `json:"glob,omitempty"`  // Glob the argument matches, ** crossing directories; paths are relative to the project
+	Regex    string         `json:"regex,omitempty"` // Regular expression found in the argument
+	Action   PermissionType `json:"action"`          // What a matching call gets: allowed, denied or ask
+}
+
+// Validate checks that a rule names a tool and argument, has one pattern and a known action
+func (r PermissionRule) Validate() error {
+	if r.Tool == "" || r.Argument == "" {
+		return serr.New("a permission rule needs a tool and an argument")
+	}
+	if (r.Glob == "") == (r.Regex == "") {
+		return serr.New(fmt.Sprintf("permission rule for %s: set either glob or regex", r.Tool))
+	}
+	if r.Regex != "" {
+		if _, err := regexp.Compile(r.Regex); err != nil {
+			return serr.Wrap(err, fmt.Sprintf("permission rule for %s: invalid regex", r.Tool))
+		}
+	}
+	if r.Action != PermissionAllowed && r.Action != PermissionDenied && r.Action != PermissionAsk {
+		return serr.New(fmt.Sprintf("permission rule for %s: action must be allowed, denied or ask, not %q", r.Tool, r.Action))
+	}
+	return nil
+}
+
 // ToolPermission represents a tool permission in the database
 type ToolPermission struct {
 	ID             int              `json:"id"`
@@ -64,8 +96,8 @@ func (db *DB) SetToolPermission(sessionID, toolName string, permType PermissionT
 
 	if expiresAt != nil && scopeJSON != nil {
 		query = `
-			INSERT INTO tool_permissions (session_id, tool_name, permission_type, granted_at, expires_at, scope)
-			VALUES (?, ?, ?, ?, ?, ?::JSON)
+			INSERT INTO tool_permissions (session_id, tool_name, permission_type, granted_at, expires_at, scope, user_id)
+			VALUES (?, ?, ?, ?, ?, ?::JSON, (SELECT user_id FROM sessions WHERE id = ?))
 			ON CONFLICT (session_id, tool_name) 
 			DO UPDATE SET 
 				permission_type = EXCLUDED.permission_type,
@@ -76,8 +108,8 @@ func (db *DB) SetToolPermission(sessionID, toolName string, permType PermissionT
 		args = []interface{}{sessionID, toolName, string(permType), grantedAt, *expiresAt, string(scopeJSON)}
 	} else if expiresAt != nil {
 		query = `
-			INSERT INTO tool_permissions (session_id, tool_name, permission_type, granted_at, expires_at, scope)
-			VALUES (?, ?, ?, ?, ?, NULL)
+			INSERT INTO tool_permissions (session_id, tool_name, permission_type, granted_at, expires_at, scope, user_id)
+			VALUES (?, ?, ?, ?, ?, NULL, (SELECT user_id FROM sessions WHERE id = ?))
 			ON CONFLICT (session_id, tool_name) 
 			DO UPDATE SET 
 				permission_type = EXCLUDED.permission_type,
@@ -88,8 +120,8 @@ func (db *DB) SetToolPermission(sessionID, toolName string, permType PermissionT
 		args = []interface{}{sessionID, toolName, string(permType), grantedAt, *expiresAt}
 	} else if scopeJSON != nil {
 		query = `
-			INSERT INTO tool_permissions (session_id, tool_name, permission_type, granted_at, expires_at, scope)
-			VALUES (?, ?, ?, ?, NULL, ?::JSON)
+			INSERT INTO tool_permissions (session_id, tool_name, permission_type, granted_at, expires_at, scope, user_id)
+			VALUES (?, ?, ?, ?, NULL, ?::JSON, (SELECT user_id FROM sessions WHERE id = ?))
 			ON CONFLICT (session_id, tool_name) 
 			DO UPDATE SET 
 				permission_type = EXCLUDED.permission_type,
@@ -100,8 +132,8 @@ func (db *DB) SetToolPermission(sessionID, toolName string, permType PermissionT
 		args = []interface{}{sessionID, toolName, string(permType), grantedAt, string(scopeJSON)}
 	} else {
 		query = `
-			INSERT INTO tool_permissions (session_id, tool_name, permission_type, granted_at, expires_at, scope)
-			VALUES (?, ?, ?, ?, NULL, NULL)
+			INSERT INTO tool_permissions (session_id, tool_name, permission_type, granted_at, expires_at, scope, user_id)
+			VALUES (?, ?, ?, ?, NULL, NULL, (SELECT user_id FROM sessions WHERE id = ?))
 			ON CONFLICT (session_id, tool_name) 
 			DO UPDATE SET 
 				permission_type = EXCLUDED.permission_type,
@@ -111,6 +143,7 @@ func (db *DB) SetToolPermission(sessionID, toolName string, permType PermissionT
 		`
 		args = []interface{}{sessionID, toolName, string(permType), grantedAt}
 	}
+	args = append(args, sessionID) // The permission belongs to the session's owner
 
 	_, err = db.Exec(query, args...)
 	if err != nil {
@@ -252,6 +285,68 @@ func (db *DB) RevokeToolPermission(sessionID, toolName string) error {
 	return nil
 }
 
+// GetPermissionRules returns a session's permission rules, in order
+func (db *DB) GetPermissionRules(sessionID string) ([]PermissionRule, error) {
+	rows, err := db.Query(`
+		SELECT tool, argument, COALESCE(glob_pattern, ''), COALESCE(regex_pattern, ''), action
+		FROM permission_rules
+		WHERE session_id = ?
+		ORDER BY position
+	`, sessionID)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to get permission rules")
+	}
+	defer rows.Close()
+
+	rules := []PermissionRule{}
+	for rows.Next() {
+		var rule PermissionRule
+		if err := rows.Scan(&rule.Tool, &rule.Argument, &rule.Glob, &rule.Regex, &rule.Action); err != nil {
+			return nil, serr.Wrap(err, "failed to scan permission rule")
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// SetPermissionRules validates a session's permission rules and replaces those it had
+func (db *DB) SetPermissionRules(sessionID string, rules []PermissionRule) error {
+	for _, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+	}
+
+	err := db.Transaction(func(tx *sql.Tx) error {
+		if _, err := tx.Exec("DELETE FROM permission_rules WHERE session_id = ?", sessionID); err != nil {
+			return serr.Wrap(err, "failed to clear permission rules")
+		}
+		for i, rule := range rules {
+			if _, err := tx.Exec(`
+				INSERT INTO permission_rules (session_id, position, tool, argument, glob_pattern, regex_pattern, action)
+				VALUES (?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), ?)
+			`, sessionID, i, rule.Tool, rule.Argument, rule.Glob, rule.Regex, string(rule.Action)); err != nil {
+				return serr.Wrap(err, "failed to add permission rule")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Set permission rules", "session_id", sessionID, "count", len(rules))
+	return nil
+}
+
+// DeleteSessionPermissionRules removes a session's permission rules
+func (db *DB) DeleteSessionPermissionRules(sessionID string) error {
+	if _, err := db.Exec("DELETE FROM permission_rules WHERE session_id = ?", sessionID); err != nil {
+		return serr.Wrap(err, "failed to delete permission rules")
+	}
+	return nil
+}
+
 // CleanupExpiredPermissions removes expired permissions
 func (db *DB) CleanupExpiredPermissions() error {
 	result, err := db.Exec(
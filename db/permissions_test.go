@@ -0,0 +1,31 @@
+package db
+
+import "testing"
+
+func TestPermissionRuleValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  PermissionRule
+		valid bool
+	}{
+		{"glob", PermissionRule{Tool: "write_file", Argument: "path", Glob: "src/**", Action: PermissionAllowed}, true},
+		{"regex", PermissionRule{Tool: "bash", Argument: "command", Regex: `^git status\b`, Action: PermissionDenied}, true},
+		{"ask", PermissionRule{Tool: "bash", Argument: "command", Regex: "rm", Action: PermissionAsk}, true},
+		{"no tool", PermissionRule{Argument: "path", Glob: "**", Action: PermissionAllowed}, false},
+		{"no argument", PermissionRule{Tool: "write_file", Glob: "**", Action: PermissionAllowed}, false},
+		{"no pattern", PermissionRule{Tool: "write_file", Argument: "path", Action: PermissionAllowed}, false},
+		{"both patterns", PermissionRule{Tool: "write_file", Argument: "path", Glob: "**", Regex: ".", Action: PermissionAllowed}, false},
+		{"invalid regex", PermissionRule{Tool: "bash", Argument: "command", Regex: "(", Action: PermissionDenied}, false},
+		{"no action", PermissionRule{Tool: "write_file", Argument: "path", Glob: "**"}, false},
+		{"unknown action", PermissionRule{Tool: "write_file", Argument: "path", Glob: "**", Action: "allow"}, false},
+	}
+	for _, tt := range tests {
+		err := tt.rule.Validate()
+		if tt.valid && err != nil {
+			t.Errorf("%s: Validate = %v, want nil", tt.name, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("%s: Validate = nil, want an error", tt.name)
+		}
+	}
+}
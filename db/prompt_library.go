@@ -0,0 +1,209 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/serr"
+)
+
+// PromptImport reports what importing a set of initial prompts did, by prompt name
+type PromptImport struct {
+	Created []string `json:"created"`
+	Updated []string `json:"updated"`
+	Skipped []string `json:"skipped"` // Named like a project prompt, which only its file changes
+}
+
+// NormalizeTags lowercases and trims tags, dropping empty and repeated ones
+func NormalizeTags(tags []string) []string {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized
+}
+
+// decodeTags reads the tags column, logging rather than failing on a malformed value
+func decodeTags(tagsJSON string) []string {
+	tags := []string{}
+	if err := json.Unmarshal([]byte(tagsJSON), &tags); err != nil {
+		logger.LogErr(err, "failed to decode prompt tags")
+	}
+	return tags
+}
+
+// promptsByName reads the initial prompts within tx, by name
+func promptsByName(tx *sql.Tx) (map[string]*InitialPrompt, error) {
+	rows, err := tx.Query(`
+		SELECT id, name, COALESCE(description, ''), content, COALESCE(includes_permissions, false),
+		       COALESCE(permission_template::VARCHAR, '{}'), COALESCE(is_active, true), COALESCE(is_default, false),
+		       COALESCE(tags::VARCHAR, '[]'), COALESCE(source, '')
+		FROM initial_prompts
+	`)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to query initial prompts")
+	}
+	defer rows.Close()
+
+	prompts := make(map[string]*InitialPrompt)
+	for rows.Next() {
+		prompt := &InitialPrompt{}
+		var permTemplateJSON, tagsJSON string
+		if err := rows.Scan(&prompt.ID, &prompt.Name, &prompt.Description, &prompt.Content, &prompt.IncludesPermissions,
+			&permTemplateJSON, &prompt.IsActive, &prompt.IsDefault, &tagsJSON, &prompt.Source); err != nil {
+			return nil, serr.Wrap(err, "failed to scan initial prompt")
+		}
+		if err := json.Unmarshal([]byte(permTemplateJSON), &prompt.PermissionTemplate); err != nil {
+			return nil, serr.Wrap(err, "failed to decode permission template")
+		}
+		prompt.Tags = decodeTags(tagsJSON)
+		prompts[prompt.Name] = prompt
+	}
+	return prompts, rows.Err()
+}
+
+// samePrompt reports whether writing b over a would change anything
+func samePrompt(a, b *InitialPrompt) bool {
+	if len(a.PermissionTemplate) == 0 && len(b.PermissionTemplate) == 0 {
+		a.PermissionTemplate, b.PermissionTemplate = nil, nil
+	}
+	return a.Description == b.Description && a.Content == b.Content && a.IncludesPermissions == b.IncludesPermissions &&
+		a.IsActive == b.IsActive && a.IsDefault == b.IsDefault && a.Source == b.Source &&
+		reflect.DeepEqual(a.Tags, b.Tags) && reflect.DeepEqual(a.PermissionTemplate, b.PermissionTemplate)
+}
+
+// writePrompt inserts prompt within tx, or updates existing, its row of the same name
+func writePrompt(tx *sql.Tx, prompt, existing *InitialPrompt) error {
+	permTemplateJSON := []byte("{}")
+	if len(prompt.PermissionTemplate) > 0 {
+		var err error
+		if permTemplateJSON, err = json.Marshal(prompt.PermissionTemplate); err != nil {
+			return serr.Wrap(err, "failed to serialize permission template")
+		}
+	}
+	tagsJSON, err := json.Marshal(prompt.Tags)
+	if err != nil {
+		return serr.Wrap(err, "failed to serialize tags")
+	}
+
+	if existing == nil {
+		_, err = tx.Exec(`
+			INSERT INTO initial_prompts (name, description, content, includes_permissions, permission_template,
+				is_active, is_default, tags, source)
+			VALUES (?, ?, ?, ?, ?::JSON, ?, ?, ?::JSON, NULLIF(?, ''))
+		`, prompt.Name, prompt.Description, prompt.Content, prompt.IncludesPermissions, string(permTemplateJSON),
+			prompt.IsActive, prompt.IsDefault, string(tagsJSON), prompt.Source)
+	} else {
+		_, err = tx.Exec(`
+			UPDATE initial_prompts
+			SET description = ?, content = ?, includes_permissions = ?, permission_template = ?::JSON,
+			    is_active = ?, is_default = ?, tags = ?::JSON, source = NULLIF(?, ''), updated_at = CURRENT_TIMESTAMP
+			WHERE id = ?
+		`, prompt.Description, prompt.Content, prompt.IncludesPermissions, string(permTemplateJSON),
+			prompt.IsActive, prompt.IsDefault, string(tagsJSON), prompt.Source, existing.ID)
+	}
+	if err != nil {
+		return serr.Wrap(err, "failed to save initial prompt "+prompt.Name)
+	}
+	return nil
+}
+
+// SyncProjectPrompts makes the project prompts in the library those read from the project's
+// .rcode/prompts files, each with its Source set. Project prompts no longer in a file, or
+// left by another project, are deactivated rather than deleted, as sessions may link to them.
+// A prompt named like one made in rcode is skipped.
+func (db *DB) SyncProjectPrompts(prompts []*InitialPrompt) error {
+	return db.Transaction(func(tx *sql.Tx) error {
+		existing, err := promptsByName(tx)
+		if err != nil {
+			return err
+		}
+
+		synced := make(map[string]bool)
+		for _, prompt := range prompts {
+			prompt.Tags = NormalizeTags(prompt.Tags)
+			current := existing[prompt.Name]
+			if current != nil && current.Source == "" {
+				logger.Warn("Project prompt has the name of a prompt made in rcode, skipping",
+					"prompt", prompt.Name, "file", prompt.Source)
+				continue
+			}
+			synced[prompt.Name] = true
+			if current != nil && samePrompt(current, prompt) {
+				continue
+			}
+			if err := writePrompt(tx, prompt, current); err != nil {
+				return err
+			}
+		}
+
+		for name, prompt := range existing {
+			if prompt.Source == "" || synced[name] || !prompt.IsActive {
+				continue
+			}
+			if _, err := tx.Exec("UPDATE initial_prompts SET is_active = false, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+				prompt.ID); err != nil {
+				return serr.Wrap(err, "failed to deactivate project prompt "+name)
+			}
+		}
+		return nil
+	})
+}
+
+// ImportInitialPrompts adds prompts to the library, replacing those of the same name.
+// Of prompts repeating a name, the last is imported.
+func (db *DB) ImportInitialPrompts(prompts []*InitialPrompt) (*PromptImport, error) {
+	byName := make(map[string]int)
+	var unique []*InitialPrompt
+	for _, prompt := range prompts {
+		if i, ok := byName[prompt.Name]; ok {
+			unique[i] = prompt
+			continue
+		}
+		byName[prompt.Name] = len(unique)
+		unique = append(unique, prompt)
+	}
+	prompts = unique
+
+	var result *PromptImport
+	err := db.Transaction(func(tx *sql.Tx) error {
+		result = &PromptImport{Created: []string{}, Updated: []string{}, Skipped: []string{}}
+		existing, err := promptsByName(tx)
+		if err != nil {
+			return err
+		}
+
+		for _, prompt := range prompts {
+			prompt.Tags = NormalizeTags(prompt.Tags)
+			prompt.Source = ""
+			current := existing[prompt.Name]
+			switch {
+			case current != nil && current.Source != "":
+				result.Skipped = append(result.Skipped, prompt.Name)
+				continue
+			case current == nil:
+				result.Created = append(result.Created, prompt.Name)
+			case samePrompt(current, prompt):
+				continue
+			default:
+				result.Updated = append(result.Updated, prompt.Name)
+			}
+			if err := writePrompt(tx, prompt, current); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,118 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// defaultRecapLimit is the number of recaps listed when no limit is given
+const defaultRecapLimit = 10
+
+// ProjectRecap is a summary of a closed session, its key changes, open TODOs and
+// decisions, offered to the next session on the same project
+type ProjectRecap struct {
+	ID           int       `json:"id"`
+	ProjectRoot  string    `json:"project_root"`
+	SessionID    string    `json:"session_id"`
+	SessionTitle string    `json:"session_title,omitempty"`
+	UserID       int       `json:"user_id,omitempty"` // Owner of the session in multi-user mode; 0 for none
+	Content      string    `json:"content"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// SaveRecap stores a session's recap, replacing the one written when it was last closed
+func (db *DB) SaveRecap(recap *ProjectRecap) error {
+	recap.Content = strings.TrimSpace(recap.Content)
+	if recap.Content == "" {
+		return serr.New("recap content is required")
+	}
+
+	if _, err := db.Exec("DELETE FROM project_recaps WHERE session_id = ?", recap.SessionID); err != nil {
+		return serr.Wrap(err, "failed to replace the session's recap")
+	}
+
+	err := db.WriteRow(`
+		INSERT INTO project_recaps (project_root, session_id, session_title, content, user_id)
+		VALUES (?, ?, NULLIF(?, ''), ?, NULLIF(?, 0))
+		RETURNING id, created_at
+	`, []interface{}{recap.ProjectRoot, recap.SessionID, recap.SessionTitle, recap.Content, recap.UserID},
+		&recap.ID, &recap.CreatedAt)
+	if err != nil {
+		return serr.Wrap(err, "failed to save recap")
+	}
+
+	return nil
+}
+
+// GetRecap returns a recap by ID
+func (db *DB) GetRecap(id int) (*ProjectRecap, error) {
+	recap := &ProjectRecap{}
+	err := db.QueryRow(`
+		SELECT id, project_root, session_id, COALESCE(session_title, ''), content, created_at, COALESCE(user_id, 0)
+		FROM project_recaps
+		WHERE id = ?
+	`, id).Scan(&recap.ID, &recap.ProjectRoot, &recap.SessionID, &recap.SessionTitle, &recap.Content, &recap.CreatedAt,
+		&recap.UserID)
+	if err == sql.ErrNoRows {
+		return nil, serr.New("recap not found")
+	}
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to get recap")
+	}
+
+	return recap, nil
+}
+
+// ListRecaps returns a user's recaps on a project, or those of sessions without an owner
+// for user 0, the latest first
+func (db *DB) ListRecaps(projectRoot string, userID, limit int) ([]*ProjectRecap, error) {
+	if limit <= 0 {
+		limit = defaultRecapLimit
+	}
+
+	rows, err := db.Query(`
+		SELECT id, project_root, session_id, COALESCE(session_title, ''), content, created_at
+		FROM project_recaps
+		WHERE project_root = ? AND COALESCE(user_id, 0) = ?
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?
+	`, projectRoot, userID, limit)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to query recaps")
+	}
+	defer rows.Close()
+
+	recaps := make([]*ProjectRecap, 0)
+	for rows.Next() {
+		recap := &ProjectRecap{UserID: userID}
+		if err := rows.Scan(&recap.ID, &recap.ProjectRoot, &recap.SessionID, &recap.SessionTitle,
+			&recap.Content, &recap.CreatedAt); err != nil {
+			return nil, serr.Wrap(err, "failed to scan recap")
+		}
+		recaps = append(recaps, recap)
+	}
+
+	return recaps, rows.Err()
+}
+
+// DeleteRecap removes one of a user's recaps
+func (db *DB) DeleteRecap(id, userID int) error {
+	result, err := db.Exec("DELETE FROM project_recaps WHERE id = ? AND COALESCE(user_id, 0) = ?", id, userID)
+	if err != nil {
+		return serr.Wrap(err, "failed to delete recap")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return serr.Wrap(err, "failed to get rows affected")
+	}
+
+	if rowsAffected == 0 {
+		return serr.New("recap not found")
+	}
+
+	return nil
+}
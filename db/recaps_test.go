@@ -0,0 +1,40 @@
+package db
+
+import "testing"
+
+func TestRecapsByUser(t *testing.T) {
+	db := newTestDB(t)
+
+	mine := &ProjectRecap{ProjectRoot: "/repo", SessionID: "s1", Content: "mine", UserID: 1}
+	theirs := &ProjectRecap{ProjectRoot: "/repo", SessionID: "s2", Content: "theirs", UserID: 2}
+	unowned := &ProjectRecap{ProjectRoot: "/repo", SessionID: "s3", Content: "unowned"}
+	for _, recap := range []*ProjectRecap{mine, theirs, unowned} {
+		if err := db.SaveRecap(recap); err != nil {
+			t.Fatalf("SaveRecap: %v", err)
+		}
+	}
+
+	for userID, want := range map[int]*ProjectRecap{1: mine, 2: theirs, 0: unowned} {
+		recaps, err := db.ListRecaps("/repo", userID, 0)
+		if err != nil {
+			t.Fatalf("ListRecaps: %v", err)
+		}
+		if len(recaps) != 1 || recaps[0].ID != want.ID || recaps[0].UserID != userID {
+			t.Errorf("ListRecaps for user %d = %+v, want only %q", userID, recaps, want.Content)
+		}
+	}
+
+	if owner, err := db.RecapOwner(theirs.ID); err != nil || owner != 2 {
+		t.Errorf("RecapOwner = %d, %v, want 2", owner, err)
+	}
+	if got, err := db.GetRecap(mine.ID); err != nil || got.UserID != 1 {
+		t.Errorf("GetRecap = %+v, %v, want user 1", got, err)
+	}
+
+	if err := db.DeleteRecap(theirs.ID, 1); err == nil {
+		t.Error("a user deleted another user's recap")
+	}
+	if err := db.DeleteRecap(theirs.ID, 2); err != nil {
+		t.Errorf("DeleteRecap by its owner: %v", err)
+	}
+}
@@ -0,0 +1,225 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// Review statuses
+const (
+	ReviewStatusRunning   = "running"
+	ReviewStatusCompleted = "completed"
+	ReviewStatusFailed    = "failed"
+)
+
+// Finding severities, from most to least serious
+const (
+	SeverityCritical = "critical"
+	SeverityMajor    = "major"
+	SeverityMinor    = "minor"
+	SeverityInfo     = "info"
+)
+
+// CodeReview is a model review of the changes between two refs
+type CodeReview struct {
+	ID            int        `json:"id"`
+	ProjectRoot   string     `json:"project_root"`
+	BaseRef       string     `json:"base_ref"`
+	HeadRef       string     `json:"head_ref"`
+	Status        string     `json:"status"`
+	Summary       string     `json:"summary,omitempty"`
+	Error         string     `json:"error,omitempty"`
+	FilesReviewed int        `json:"files_reviewed"`
+	FindingCount  int        `json:"finding_count"`
+	CreatedAt     time.Time  `json:"created_at"`
+	CompletedAt   *time.Time `json:"completed_at,omitempty"`
+}
+
+// ReviewFinding is one issue raised by a review, anchored to lines of the new file
+type ReviewFinding struct {
+	ID         int       `json:"id"`
+	ReviewID   int       `json:"review_id"`
+	FilePath   string    `json:"file_path"`
+	Line       int       `json:"line"`
+	EndLine    int       `json:"end_line"`
+	Severity   string    `json:"severity"`
+	Title      string    `json:"title"`
+	Message    string    `json:"message"`
+	Suggestion string    `json:"suggestion,omitempty"`
+	Resolved   bool      `json:"resolved"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ValidSeverity reports whether severity is one of the known finding severities
+func ValidSeverity(severity string) bool {
+	switch severity {
+	case SeverityCritical, SeverityMajor, SeverityMinor, SeverityInfo:
+		return true
+	}
+	return false
+}
+
+// CreateReview records a new review as running
+func (db *DB) CreateReview(review *CodeReview) error {
+	review.Status = ReviewStatusRunning
+	err := db.WriteRow(`
+		INSERT INTO code_reviews (project_root, base_ref, head_ref, status)
+		VALUES (?, ?, ?, ?)
+		RETURNING id, created_at
+	`, []interface{}{review.ProjectRoot, review.BaseRef, review.HeadRef, review.Status},
+		&review.ID, &review.CreatedAt)
+	if err != nil {
+		return serr.Wrap(err, "failed to create review")
+	}
+	return nil
+}
+
+// FinishReview stores a review's outcome. A non-empty errMsg marks it failed.
+func (db *DB) FinishReview(id int, summary string, filesReviewed int, errMsg string) error {
+	status := ReviewStatusCompleted
+	if errMsg != "" {
+		status = ReviewStatusFailed
+	}
+	if _, err := db.Exec(`
+		UPDATE code_reviews
+		SET status = ?, summary = ?, error = ?, files_reviewed = ?, completed_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, status, summary, errMsg, filesReviewed, id); err != nil {
+		return serr.Wrap(err, "failed to finish review")
+	}
+	return nil
+}
+
+// AddReviewFindings stores findings for a review
+func (db *DB) AddReviewFindings(reviewID int, findings []ReviewFinding) error {
+	return db.Transaction(func(tx *sql.Tx) error {
+		for _, f := range findings {
+			if _, err := tx.Exec(`
+				INSERT INTO review_findings (review_id, file_path, line, end_line, severity, title, message, suggestion)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			`, reviewID, f.FilePath, f.Line, f.EndLine, f.Severity, f.Title, f.Message, f.Suggestion); err != nil {
+				return serr.Wrap(err, "failed to insert review finding")
+			}
+		}
+		return nil
+	})
+}
+
+const reviewColumns = `
+	r.id, r.project_root, r.base_ref, r.head_ref, r.status, r.summary, r.error,
+	r.files_reviewed, r.created_at, r.completed_at,
+	(SELECT COUNT(*) FROM review_findings f WHERE f.review_id = r.id) AS finding_count
+`
+
+// scanReview reads a row selected with reviewColumns
+func scanReview(row interface{ Scan(...interface{}) error }) (*CodeReview, error) {
+	var r CodeReview
+	var summary, errMsg sql.NullString
+	var completedAt sql.NullTime
+	if err := row.Scan(&r.ID, &r.ProjectRoot, &r.BaseRef, &r.HeadRef, &r.Status, &summary, &errMsg,
+		&r.FilesReviewed, &r.CreatedAt, &completedAt, &r.FindingCount); err != nil {
+		return nil, err
+	}
+	r.Summary = summary.String
+	r.Error = errMsg.String
+	if completedAt.Valid {
+		r.CompletedAt = &completedAt.Time
+	}
+	return &r, nil
+}
+
+// GetReview returns a review by ID
+func (db *DB) GetReview(id int) (*CodeReview, error) {
+	review, err := scanReview(db.QueryRow(`SELECT `+reviewColumns+` FROM code_reviews r WHERE r.id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, serr.New("review not found")
+	}
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to get review")
+	}
+	return review, nil
+}
+
+// ListReviews returns a project's reviews, newest first
+func (db *DB) ListReviews(projectRoot string, limit int) ([]*CodeReview, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	rows, err := db.Query(`
+		SELECT `+reviewColumns+`
+		FROM code_reviews r
+		WHERE r.project_root = ?
+		ORDER BY r.created_at DESC, r.id DESC
+		LIMIT ?
+	`, projectRoot, limit)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to query reviews")
+	}
+	defer rows.Close()
+
+	reviews := []*CodeReview{}
+	for rows.Next() {
+		review, err := scanReview(rows)
+		if err != nil {
+			return nil, serr.Wrap(err, "failed to scan review")
+		}
+		reviews = append(reviews, review)
+	}
+	return reviews, rows.Err()
+}
+
+// ListReviewFindings returns a review's findings ordered by file and line
+func (db *DB) ListReviewFindings(reviewID int) ([]ReviewFinding, error) {
+	rows, err := db.Query(`
+		SELECT id, review_id, file_path, line, end_line, severity, title, message, suggestion, resolved, created_at
+		FROM review_findings
+		WHERE review_id = ?
+		ORDER BY file_path, line, id
+	`, reviewID)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to query review findings")
+	}
+	defer rows.Close()
+
+	findings := []ReviewFinding{}
+	for rows.Next() {
+		var f ReviewFinding
+		var suggestion sql.NullString
+		if err := rows.Scan(&f.ID, &f.ReviewID, &f.FilePath, &f.Line, &f.EndLine, &f.Severity,
+			&f.Title, &f.Message, &suggestion, &f.Resolved, &f.CreatedAt); err != nil {
+			return nil, serr.Wrap(err, "failed to scan review finding")
+		}
+		f.Suggestion = suggestion.String
+		findings = append(findings, f)
+	}
+	return findings, rows.Err()
+}
+
+// SetFindingResolved marks a review finding resolved or open again
+func (db *DB) SetFindingResolved(reviewID, findingID int, resolved bool) error {
+	result, err := db.Exec(`
+		UPDATE review_findings SET resolved = ? WHERE id = ? AND review_id = ?
+	`, resolved, findingID, reviewID)
+	if err != nil {
+		return serr.Wrap(err, "failed to update review finding")
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return serr.New("review finding not found")
+	}
+	return nil
+}
+
+// DeleteReview removes a review and its findings
+func (db *DB) DeleteReview(id int) error {
+	return db.Transaction(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM review_findings WHERE review_id = ?`, id); err != nil {
+			return serr.Wrap(err, "failed to delete review findings")
+		}
+		if _, err := tx.Exec(`DELETE FROM code_reviews WHERE id = ?`, id); err != nil {
+			return serr.Wrap(err, "failed to delete review")
+		}
+		return nil
+	})
+}
@@ -0,0 +1,340 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// Search scopes restrict which sources a conversation search looks at
+const (
+	SearchScopeAll     = "all"
+	SearchScopeContent = "content"
+	SearchScopeTool    = "tool"
+	SearchScopeFile    = "file"
+)
+
+const (
+	defaultSearchLimit   = 20
+	maxSearchMessageScan = 1000 // Upper bound on candidate messages pulled per query
+	maxHitsPerSession    = 5
+	snippetRadius        = 60 // Bytes of context kept on each side of a match
+)
+
+// Relative weights of each hit source when ranking sessions
+var searchHitWeights = map[string]float64{
+	"title":   5,
+	"file":    3,
+	"tool":    2,
+	"message": 1,
+}
+
+// likeEscaper escapes the LIKE wildcards in a query, and the backslash the queries
+// name as their ESCAPE character, so that the query matches as typed
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// SearchOptions represents options for searching conversations
+type SearchOptions struct {
+	Query string `json:"query"`
+	Scope string `json:"scope"` // "all", "content", "tool" or "file"
+	Limit int    `json:"limit"`
+	User  *User  `json:"-"` // In multi-user mode, only sessions this user can access are returned
+}
+
+// SearchHit is a single place within a session where the query matched
+type SearchHit struct {
+	Source      string `json:"source"` // "title", "message", "tool" or "file"
+	MessageID   int    `json:"message_id,omitempty"`
+	Role        string `json:"role,omitempty"`
+	Snippet     string `json:"snippet"`
+	Highlighted string `json:"highlighted"` // HTML-escaped snippet with matches wrapped in <mark>
+}
+
+// SessionSearchResult represents a ranked session matching a search
+type SessionSearchResult struct {
+	Session  *Session    `json:"session"`
+	Score    float64     `json:"score"`
+	HitCount int         `json:"hit_count"`
+	Hits     []SearchHit `json:"hits"`
+}
+
+// SearchConversations finds sessions whose title, message content, tool calls
+// or touched file paths match the query, ranked by relevance and recency
+func (db *DB) SearchConversations(opts SearchOptions) ([]*SessionSearchResult, error) {
+	term := strings.TrimSpace(opts.Query)
+	if term == "" {
+		return nil, serr.New("search query is required")
+	}
+
+	scope := opts.Scope
+	if scope == "" {
+		scope = SearchScopeAll
+	}
+	switch scope {
+	case SearchScopeAll, SearchScopeContent, SearchScopeTool, SearchScopeFile:
+	default:
+		return nil, serr.New(fmt.Sprintf("invalid search scope: %s", scope))
+	}
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+
+	pattern := "%" + likeEscaper.Replace(term) + "%"
+	results := make(map[string]*SessionSearchResult)
+	seenSources := make(map[string]bool)
+
+	addHit := func(sessionID string, hit SearchHit) {
+		res, ok := results[sessionID]
+		if !ok {
+			res = &SessionSearchResult{}
+			results[sessionID] = res
+		}
+
+		// The first hit of each source counts fully; repeats add diminishing weight
+		weight := searchHitWeights[hit.Source]
+		key := sessionID + "|" + hit.Source
+		if seenSources[key] {
+			weight *= 0.25
+		}
+		seenSources[key] = true
+
+		res.Score += weight
+		res.HitCount++
+		if len(res.Hits) < maxHitsPerSession {
+			res.Hits = append(res.Hits, hit)
+		}
+	}
+
+	if scope == SearchScopeAll {
+		rows, err := db.Query(`SELECT id, title FROM sessions WHERE title ILIKE ? ESCAPE '\'`, pattern)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var id, title string
+			if err := rows.Scan(&id, &title); err != nil {
+				rows.Close()
+				return nil, serr.Wrap(err, "failed to scan session title")
+			}
+			snippet, highlighted := buildSnippet(title, term)
+			addHit(id, SearchHit{Source: "title", Snippet: snippet, Highlighted: highlighted})
+		}
+		rows.Close()
+	}
+
+	if scope != SearchScopeFile {
+		rows, err := db.Query(`
+			SELECT id, session_id, role, content::VARCHAR
+			FROM messages
+			WHERE content::VARCHAR ILIKE ? ESCAPE '\'
+			ORDER BY created_at DESC
+			LIMIT ?
+		`, pattern, maxSearchMessageScan)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var msgID int
+			var sessionID, role, contentJSON string
+			if err := rows.Scan(&msgID, &sessionID, &role, &contentJSON); err != nil {
+				rows.Close()
+				return nil, serr.Wrap(err, "failed to scan message row")
+			}
+
+			text, toolNames := extractSearchableContent(contentJSON)
+
+			if scope == SearchScopeAll || scope == SearchScopeContent {
+				if snippet, highlighted := buildSnippet(text, term); snippet != "" {
+					addHit(sessionID, SearchHit{
+						Source: "message", MessageID: msgID, Role: role,
+						Snippet: snippet, Highlighted: highlighted,
+					})
+				}
+			}
+
+			if scope == SearchScopeAll || scope == SearchScopeTool {
+				for _, name := range toolNames {
+					if snippet, highlighted := buildSnippet(name, term); snippet != "" {
+						addHit(sessionID, SearchHit{
+							Source: "tool", MessageID: msgID, Role: role,
+							Snippet: snippet, Highlighted: highlighted,
+						})
+					}
+				}
+			}
+		}
+		rows.Close()
+	}
+
+	if scope == SearchScopeAll || scope == SearchScopeFile {
+		rows, err := db.Query(`
+			SELECT session_id, file_path FROM file_access WHERE file_path ILIKE ? ESCAPE '\'
+			UNION
+			SELECT session_id, file_path FROM diffs WHERE file_path ILIKE ? ESCAPE '\'
+		`, pattern, pattern)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var sessionID, filePath string
+			if err := rows.Scan(&sessionID, &filePath); err != nil {
+				rows.Close()
+				return nil, serr.Wrap(err, "failed to scan file path row")
+			}
+			snippet, highlighted := buildSnippet(filePath, term)
+			addHit(sessionID, SearchHit{Source: "file", Snippet: snippet, Highlighted: highlighted})
+		}
+		rows.Close()
+	}
+
+	// Attach session details, dropping hits for sessions that no longer exist
+	ranked := make([]*SessionSearchResult, 0, len(results))
+	now := time.Now()
+	for sessionID, res := range results {
+		session, err := db.GetSession(sessionID)
+		if err != nil {
+			return nil, err
+		}
+		if session == nil || (opts.User != nil && !opts.User.CanAccess(session.UserID)) {
+			continue
+		}
+		res.Session = session
+
+		// Favor recently active sessions when relevance is otherwise similar
+		days := now.Sub(session.UpdatedAt).Hours() / 24
+		res.Score += 1 / (1 + math.Max(days, 0))
+
+		ranked = append(ranked, res)
+	}
+
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].Session.UpdatedAt.After(ranked[j].Session.UpdatedAt)
+	})
+
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+
+	return ranked, nil
+}
+
+// extractSearchableContent returns the human-readable text of a stored message
+// along with the names of any tools it invoked
+func extractSearchableContent(contentJSON string) (string, []string) {
+	var content interface{}
+	if err := json.Unmarshal([]byte(contentJSON), &content); err != nil {
+		return contentJSON, nil
+	}
+
+	var parts []string
+	var toolNames []string
+
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch val := v.(type) {
+		case string:
+			parts = append(parts, val)
+		case []interface{}:
+			for _, item := range val {
+				walk(item)
+			}
+		case map[string]interface{}:
+			switch val["type"] {
+			case "text":
+				if text, ok := val["text"].(string); ok {
+					parts = append(parts, text)
+				}
+			case "tool_use":
+				if name, ok := val["name"].(string); ok {
+					toolNames = append(toolNames, name)
+				}
+			case "tool_result":
+				walk(val["content"])
+			}
+		}
+	}
+	walk(content)
+
+	return strings.Join(parts, "\n"), toolNames
+}
+
+// buildSnippet cuts a window of text around the first case-insensitive match of term.
+// It returns the plain snippet and an HTML-escaped copy with every match wrapped in <mark>.
+// Both are empty when there is no match.
+func buildSnippet(text, term string) (string, string) {
+	lowerText := strings.ToLower(text)
+	lowerTerm := strings.ToLower(term)
+
+	// Lowercasing can change byte lengths for some runes; only trust offsets when it didn't
+	if len(lowerText) != len(text) {
+		lowerText = text
+		lowerTerm = term
+	}
+
+	idx := strings.Index(lowerText, lowerTerm)
+	if idx < 0 || lowerTerm == "" {
+		return "", ""
+	}
+
+	start := idx - snippetRadius
+	if start < 0 {
+		start = 0
+	}
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start--
+	}
+	end := idx + len(lowerTerm) + snippetRadius
+	if end > len(text) {
+		end = len(text)
+	}
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end++
+	}
+
+	snippet := text[start:end]
+	lowerSnippet := lowerText[start:end]
+
+	var highlighted strings.Builder
+	if start > 0 {
+		highlighted.WriteString("…")
+	}
+	pos := 0
+	for {
+		i := strings.Index(lowerSnippet[pos:], lowerTerm)
+		if i < 0 {
+			break
+		}
+		matchStart := pos + i
+		matchEnd := matchStart + len(lowerTerm)
+		highlighted.WriteString(html.EscapeString(snippet[pos:matchStart]))
+		highlighted.WriteString("<mark>")
+		highlighted.WriteString(html.EscapeString(snippet[matchStart:matchEnd]))
+		highlighted.WriteString("</mark>")
+		pos = matchEnd
+	}
+	highlighted.WriteString(html.EscapeString(snippet[pos:]))
+	if end < len(text) {
+		highlighted.WriteString("…")
+	}
+
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(text) {
+		snippet += "…"
+	}
+
+	return snippet, highlighted.String()
+}
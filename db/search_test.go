@@ -0,0 +1,66 @@
+package db
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestSearchConversationsEscapesWildcards(t *testing.T) {
+	db := newTestDB(t)
+
+	titles := []string{"100% coverage", "100 percent", "snake_case names", "snakeXcase names", `C:\temp\build`, `C:/temp/build`}
+	for _, title := range titles {
+		if _, err := db.CreateSession(SessionOptions{Title: title}); err != nil {
+			t.Fatalf("CreateSession(%q): %v", title, err)
+		}
+	}
+	fileSession, err := db.CreateSession(SessionOptions{Title: "file session"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"src/user_test.go", "src/userXtest.go"} {
+		if err := db.TrackFileAccess(fileSession.ID, path, "read"); err != nil {
+			t.Fatalf("TrackFileAccess(%q): %v", path, err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		scope string
+		want  []string // Titles of the sessions found
+	}{
+		{"100%", SearchScopeAll, []string{"100% coverage"}},
+		{"%", SearchScopeAll, []string{"100% coverage"}},
+		{"snake_case", SearchScopeAll, []string{"snake_case names"}},
+		{"_", SearchScopeAll, []string{"file session", "snake_case names"}},
+		{`\temp`, SearchScopeAll, []string{`C:\temp\build`}},
+		{`\`, SearchScopeAll, []string{`C:\temp\build`}},
+		{"user_test", SearchScopeFile, []string{"file session"}},
+		{"temp", SearchScopeAll, []string{`C:/temp/build`, `C:\temp\build`}},
+	}
+	for _, tt := range tests {
+		results, err := db.SearchConversations(SearchOptions{Query: tt.query, Scope: tt.scope})
+		if err != nil {
+			t.Errorf("SearchConversations(%q): %v", tt.query, err)
+			continue
+		}
+		var got []string
+		for _, result := range results {
+			got = append(got, result.Session.Title)
+		}
+		sort.Strings(got)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("SearchConversations(%q) found %q, want %q", tt.query, got, tt.want)
+		}
+	}
+
+	// The file search matches the path as typed, so only one of the two paths is a hit
+	results, err := db.SearchConversations(SearchOptions{Query: "user_test", Scope: SearchScopeFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].HitCount != 1 {
+		t.Fatalf("SearchConversations(user_test) = %d sessions, want 1 with 1 hit", len(results))
+	}
+}
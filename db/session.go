@@ -21,6 +21,11 @@ type Session struct {
 	InitialPrompts  []string  `json:"initial_prompts"`
 	ModelPreference string    `json:"model_preference,omitempty"`
 	Metadata        JSONMap   `json:"metadata,omitempty"`
+	UserID          int       `json:"user_id,omitempty"` // Owner in multi-user mode; 0 for none
+	ReadOnly        bool      `json:"read_only"`         // Tools that change the project are denied, shell commands ask
+	AutoAccept      bool      `json:"auto_accept"`       // Tools run without asking, within limits on what a turn changes
+	Account         string    `json:"account,omitempty"` // Claude account the session's requests use; "" for the default
+	Package         string    `json:"package,omitempty"` // Monorepo package the session is scoped to, by path; "" for the whole repository
 }
 
 // JSONMap is a helper type for JSON columns
@@ -58,12 +63,15 @@ type SessionOptions struct {
 	InitialPromptIDs []int // IDs of managed prompts to use
 	ModelPreference  string
 	Metadata         JSONMap
+	AutoCompact      bool
+	CompactThreshold int // Tokens before auto-compaction; 0 for the table's default
+	UserID           int // Owner in multi-user mode; 0 for none
 }
 
 // CreateSession creates a new session in the database
 func (db *DB) CreateSession(opts SessionOptions) (*Session, error) {
 	now := time.Now()
-	id := fmt.Sprintf("session-%d", now.Unix())
+	id := fmt.Sprintf("session-%d", now.UnixNano()) // Headless runs from scripts can start several sessions a second
 
 	// Set default title if not provided
 	if opts.Title == "" {
@@ -135,10 +143,10 @@ func (db *DB) CreateSession(opts SessionOptions) (*Session, error) {
 		return nil, serr.Wrap(err, "failed to marshal metadata")
 	}
 
-	// Build array literal for DuckDB
+	// Build array literal
 	var promptsArray string
 	if len(finalPrompts) == 0 {
-		promptsArray = "[]"
+		promptsArray = "ARRAY[]::TEXT[]"
 	} else {
 		quotedPrompts := make([]string, len(finalPrompts))
 		for i, p := range finalPrompts {
@@ -146,16 +154,17 @@ func (db *DB) CreateSession(opts SessionOptions) (*Session, error) {
 			escaped := strings.ReplaceAll(p, "'", "''")
 			quotedPrompts[i] = "'" + escaped + "'"
 		}
-		promptsArray = "[" + strings.Join(quotedPrompts, ", ") + "]"
+		promptsArray = "ARRAY[" + strings.Join(quotedPrompts, ", ") + "]::TEXT[]"
 	}
 
 	// Use direct array literal
 	query := `
-		INSERT INTO sessions (id, title, created_at, updated_at, initial_prompts, model_preference, metadata)
-		VALUES (?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, ` + promptsArray + `, ?, ?::JSON)
+		INSERT INTO sessions (id, title, created_at, updated_at, initial_prompts, model_preference, metadata,
+			auto_compact_enabled, compact_threshold, user_id)
+		VALUES (?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, ` + promptsArray + `, ?, ?::JSON, ?, COALESCE(NULLIF(?, 0), 50000), NULLIF(?, 0))
 	`
 
-	_, err = db.Exec(query, id, opts.Title, opts.ModelPreference, string(metadataJSON))
+	_, err = db.Exec(query, id, opts.Title, opts.ModelPreference, string(metadataJSON), opts.AutoCompact, opts.CompactThreshold, opts.UserID)
 	if err != nil {
 		return nil, serr.Wrap(err, "failed to create session")
 	}
@@ -176,9 +185,10 @@ func (db *DB) CreateSession(opts SessionOptions) (*Session, error) {
 		InitialPrompts:  finalPrompts,
 		ModelPreference: opts.ModelPreference,
 		Metadata:        opts.Metadata,
+		UserID:          opts.UserID,
 	}
 
-	logger.Info("Created session", "id", id, "title", opts.Title)
+	logger.Info("Created session", "session_id", id, "title", opts.Title)
 	return session, nil
 }
 
@@ -186,8 +196,8 @@ func (db *DB) CreateSession(opts SessionOptions) (*Session, error) {
 func (db *DB) GetSession(id string) (*Session, error) {
 	query := `
 		SELECT id, title, created_at, updated_at, 
-		       list_aggregate(initial_prompts, 'string_agg', '|||') as prompts,
-		       model_preference, metadata
+		       array_to_string(initial_prompts, '|||') as prompts,
+		       model_preference, metadata, COALESCE(user_id, 0), COALESCE(read_only, false), COALESCE(auto_accept, false), COALESCE(account, ''), COALESCE(package_path, '')
 		FROM sessions
 		WHERE id = ?
 	`
@@ -205,6 +215,11 @@ func (db *DB) GetSession(id string) (*Session, error) {
 		&promptsStr,
 		&modelPref,
 		&metadataJSON,
+		&session.UserID,
+		&session.ReadOnly,
+		&session.AutoAccept,
+		&session.Account,
+		&session.Package,
 	)
 
 	if err == sql.ErrNoRows {
@@ -239,8 +254,8 @@ func (db *DB) GetSession(id string) (*Session, error) {
 func (db *DB) ListSessions() ([]*Session, error) {
 	query := `
 		SELECT id, title, created_at, updated_at,
-		       list_aggregate(initial_prompts, 'string_agg', '|||') as prompts,
-		       model_preference, metadata
+		       array_to_string(initial_prompts, '|||') as prompts,
+		       model_preference, metadata, COALESCE(user_id, 0), COALESCE(read_only, false), COALESCE(auto_accept, false), COALESCE(account, ''), COALESCE(package_path, '')
 		FROM sessions
 		ORDER BY updated_at DESC
 	`
@@ -266,6 +281,11 @@ func (db *DB) ListSessions() ([]*Session, error) {
 			&promptsStr,
 			&modelPref,
 			&metadataJSON,
+			&session.UserID,
+			&session.ReadOnly,
+			&session.AutoAccept,
+			&session.Account,
+			&session.Package,
 		)
 		if err != nil {
 			return nil, serr.Wrap(err, "failed to scan session row")
@@ -322,12 +342,141 @@ func (db *DB) UpdateSession(id string, title string, metadata JSONMap) error {
 		return serr.New("session not found")
 	}
 
-	logger.Info("Updated session", "id", id, "title", title)
+	logger.Info("Updated session", "session_id", id, "title", title)
+	return nil
+}
+
+// SetSessionReadOnly turns a session's read-only mode on or off. Turning it on turns
+// auto-accept mode off.
+func (db *DB) SetSessionReadOnly(id string, readOnly bool) error {
+	result, err := db.Exec(`
+		UPDATE sessions SET read_only = ?, auto_accept = COALESCE(auto_accept, false) AND NOT ?
+		WHERE id = ?
+	`, readOnly, readOnly, id)
+	if err != nil {
+		return serr.Wrap(err, "failed to update session read-only mode")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return serr.Wrap(err, "failed to get rows affected")
+	}
+	if rowsAffected == 0 {
+		return serr.New("session not found")
+	}
+
+	logger.Info("Updated session read-only mode", "session_id", id, "read_only", readOnly)
+	return nil
+}
+
+// SetSessionAutoAccept turns a session's auto-accept mode on or off. Turning it on turns
+// read-only mode off.
+func (db *DB) SetSessionAutoAccept(id string, autoAccept bool) error {
+	result, err := db.Exec(`
+		UPDATE sessions SET auto_accept = ?, read_only = COALESCE(read_only, false) AND NOT ?
+		WHERE id = ?
+	`, autoAccept, autoAccept, id)
+	if err != nil {
+		return serr.Wrap(err, "failed to update session auto-accept mode")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return serr.Wrap(err, "failed to get rows affected")
+	}
+	if rowsAffected == 0 {
+		return serr.New("session not found")
+	}
+
+	logger.Info("Updated session auto-accept mode", "session_id", id, "auto_accept", autoAccept)
+	return nil
+}
+
+// SetSessionAccount sets the Claude account a session's requests use, "" for the default
+func (db *DB) SetSessionAccount(id string, account string) error {
+	result, err := db.Exec(`UPDATE sessions SET account = NULLIF(?, '') WHERE id = ?`, account, id)
+	if err != nil {
+		return serr.Wrap(err, "failed to update session account")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return serr.Wrap(err, "failed to get rows affected")
+	}
+	if rowsAffected == 0 {
+		return serr.New("session not found")
+	}
+
+	logger.Info("Updated session account", "session_id", id, "account", account)
+	return nil
+}
+
+// SetSessionPackage scopes a session to a monorepo package by its path, "" for the whole repository
+func (db *DB) SetSessionPackage(id string, packagePath string) error {
+	result, err := db.Exec(`UPDATE sessions SET package_path = NULLIF(?, '') WHERE id = ?`, packagePath, id)
+	if err != nil {
+		return serr.Wrap(err, "failed to update session package")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return serr.Wrap(err, "failed to get rows affected")
+	}
+	if rowsAffected == 0 {
+		return serr.New("session not found")
+	}
+
+	logger.Info("Updated session package", "session_id", id, "package", packagePath)
+	return nil
+}
+
+// UpdateSessionModel sets the model a session uses when a message does not name one
+func (db *DB) UpdateSessionModel(id string, model string) error {
+	result, err := db.Exec(`
+		UPDATE sessions
+		SET model_preference = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, model, id)
+	if err != nil {
+		return serr.Wrap(err, "failed to update session model")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return serr.Wrap(err, "failed to get rows affected")
+	}
+	if rowsAffected == 0 {
+		return serr.New("session not found")
+	}
+
+	logger.Info("Updated session model", "session_id", id, "model", model)
 	return nil
 }
 
 // DeleteSession deletes a session and all its messages
 func (db *DB) DeleteSession(id string) error {
+	if err := db.DeleteSessionContext(id); err != nil {
+		return err
+	}
+	if err := db.DeleteSessionToolUsage(id); err != nil {
+		return err
+	}
+	if err := db.DeleteSessionArtifacts(id); err != nil {
+		return err
+	}
+	if err := db.DeleteSessionPermissionRules(id); err != nil {
+		return err
+	}
+	if err := db.DeleteSessionTodos(id); err != nil {
+		return err
+	}
+	if err := db.DeleteSessionNotes(id); err != nil {
+		return err
+	}
+	if err := db.DeleteSessionBenchmarkRuns(id); err != nil {
+		return err
+	}
+
 	result, err := db.Exec("DELETE FROM sessions WHERE id = ?", id)
 	if err != nil {
 		return serr.Wrap(err, "failed to delete session")
@@ -342,26 +491,147 @@ func (db *DB) DeleteSession(id string) error {
 		return serr.New("session not found")
 	}
 
-	logger.Info("Deleted session", "id", id)
+	logger.Info("Deleted session", "session_id", id)
 	return nil
 }
 
+// ForkSession creates a new session that copies the source session's messages
+// up to and including uptoIndex (0-based, in creation order).
+// A negative uptoIndex copies every message.
+func (db *DB) ForkSession(sourceID string, uptoIndex int, title string) (*Session, error) {
+	source, err := db.GetSession(sourceID)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to get source session")
+	}
+	if source == nil {
+		return nil, serr.New("session not found")
+	}
+
+	messages, err := db.GetMessagesWithMetadata(sourceID)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to get source messages")
+	}
+
+	if uptoIndex >= len(messages) {
+		return nil, serr.New(fmt.Sprintf("message index %d out of range (session has %d messages)",
+			uptoIndex, len(messages)))
+	}
+	if uptoIndex < 0 {
+		uptoIndex = len(messages) - 1
+	}
+
+	if title == "" {
+		title = source.Title + " (fork)"
+	}
+
+	// Record lineage so the UI can link back to the original thread
+	metadata := make(JSONMap)
+	for k, v := range source.Metadata {
+		metadata[k] = v
+	}
+	metadata["forked_from"] = sourceID
+	metadata["forked_at_message"] = uptoIndex
+
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to marshal metadata")
+	}
+
+	now := time.Now()
+	// Nanosecond precision avoids colliding with the source when forking right after creation
+	id := fmt.Sprintf("session-%d", now.UnixNano())
+
+	err = db.Transaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			INSERT INTO sessions (id, title, created_at, updated_at, initial_prompts, model_preference, metadata, user_id, system_prompt_id)
+			SELECT ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, initial_prompts, model_preference, ?::JSON, user_id, system_prompt_id
+			FROM sessions WHERE id = ?
+		`, id, title, string(metadataJSON), sourceID)
+		if err != nil {
+			return serr.Wrap(err, "failed to create forked session")
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO session_initial_prompts (session_id, prompt_id)
+			SELECT ?, prompt_id FROM session_initial_prompts WHERE session_id = ?
+		`, id, sourceID)
+		if err != nil {
+			return serr.Wrap(err, "failed to copy session prompts")
+		}
+
+		for _, msg := range messages[:uptoIndex+1] {
+			contentJSON, err := json.Marshal(msg.Content)
+			if err != nil {
+				return serr.Wrap(err, "failed to marshal message content")
+			}
+
+			usageJSONStr := "null"
+			if msg.TokenUsage != nil {
+				usageJSON, err := json.Marshal(msg.TokenUsage)
+				if err != nil {
+					return serr.Wrap(err, "failed to marshal token usage")
+				}
+				usageJSONStr = string(usageJSON)
+			}
+
+			metadataJSONStr, err := marshalMessageMetadata(msg.Metadata)
+			if err != nil {
+				return err
+			}
+
+			// A reply still being streamed in the source won't be finished in the fork
+			status := msg.Status
+			if status == MessagePartial {
+				status = MessageInterrupted
+			}
+
+			// Keep the original timestamps so message ordering is preserved
+			_, err = tx.Exec(`
+				INSERT INTO messages (session_id, role, content, model, token_usage, metadata, status, created_at)
+				VALUES (?, ?, ?::JSON, NULLIF(?, ''), ?::JSON, ?::JSON, NULLIF(?, ''), ?)
+			`, id, msg.Role, string(contentJSON), msg.Model, usageJSONStr, metadataJSONStr, status, msg.CreatedAt)
+			if err != nil {
+				return serr.Wrap(err, "failed to copy message")
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	session := &Session{
+		ID:              id,
+		Title:           title,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+		InitialPrompts:  source.InitialPrompts,
+		ModelPreference: source.ModelPreference,
+		Metadata:        metadata,
+		UserID:          source.UserID,
+	}
+
+	logger.Info("Forked session", "source_session_id", sourceID, "session_id", id, "messages", uptoIndex+1)
+	return session, nil
+}
+
 // SearchSessions searches sessions by content
 func (db *DB) SearchSessions(searchTerm string) ([]*Session, error) {
 	// Search in session titles and message content
 	query := `
 		SELECT DISTINCT s.id, s.title, s.created_at, s.updated_at,
-		       list_aggregate(s.initial_prompts, 'string_agg', '|||') as prompts,
-		       s.model_preference, s.metadata
+		       array_to_string(s.initial_prompts, '|||') as prompts,
+		       s.model_preference, s.metadata::VARCHAR
 		FROM sessions s
 		LEFT JOIN messages m ON s.id = m.session_id
-		WHERE s.title ILIKE ? 
-		   OR m.content::TEXT ILIKE ?
-		   OR list_contains(s.initial_prompts, ?)
+		WHERE s.title ILIKE ? ESCAPE '\' 
+		   OR m.content::TEXT ILIKE ? ESCAPE '\'
+		   OR ? = ANY(s.initial_prompts)
 		ORDER BY s.updated_at DESC
 	`
 
-	searchPattern := "%" + searchTerm + "%"
+	searchPattern := "%" + likeEscaper.Replace(searchTerm) + "%"
 	rows, err := db.Query(query, searchPattern, searchPattern, searchTerm)
 	if err != nil {
 		return nil, err
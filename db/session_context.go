@@ -0,0 +1,76 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// SessionContext is the block of project files packed for a session.
+// It is built once, for the session's first task, so every request in the session
+// sends the same block and it can be served from the prompt cache.
+type SessionContext struct {
+	SessionID string    `json:"session_id"`
+	Task      string    `json:"task"`
+	Content   string    `json:"content"` // Empty if no files fit or none were relevant
+	Tokens    int       `json:"tokens"`
+	Budget    int       `json:"budget"`
+	Files     []string  `json:"files"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SaveSessionContext stores the packed context for a session, replacing any earlier one
+func (db *DB) SaveSessionContext(sc *SessionContext) error {
+	filesJSON, err := json.Marshal(sc.Files)
+	if err != nil {
+		return serr.Wrap(err, "failed to marshal context files")
+	}
+
+	if err := db.DeleteSessionContext(sc.SessionID); err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO session_context (session_id, task, content, tokens, budget, files)
+		VALUES (?, ?, ?, ?, ?, ?::JSON)
+	`, sc.SessionID, sc.Task, sc.Content, sc.Tokens, sc.Budget, string(filesJSON))
+	if err != nil {
+		return serr.Wrap(err, "failed to save session context")
+	}
+	return nil
+}
+
+// GetSessionContext returns the packed context for a session, or nil if none has been built
+func (db *DB) GetSessionContext(sessionID string) (*SessionContext, error) {
+	sc := &SessionContext{SessionID: sessionID}
+	var filesJSON sql.NullString
+
+	err := db.QueryRow(`
+		SELECT task, content, tokens, budget, files::VARCHAR, created_at
+		FROM session_context
+		WHERE session_id = ?
+	`, sessionID).Scan(&sc.Task, &sc.Content, &sc.Tokens, &sc.Budget, &filesJSON, &sc.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to get session context")
+	}
+
+	if filesJSON.Valid {
+		if err := json.Unmarshal([]byte(filesJSON.String), &sc.Files); err != nil {
+			return nil, serr.Wrap(err, "failed to unmarshal context files")
+		}
+	}
+	return sc, nil
+}
+
+// DeleteSessionContext removes a session's packed context, so it is rebuilt for the next message
+func (db *DB) DeleteSessionContext(sessionID string) error {
+	if _, err := db.Exec("DELETE FROM session_context WHERE session_id = ?", sessionID); err != nil {
+		return serr.Wrap(err, "failed to delete session context")
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// UserSettings are the user's preferences, stored in the database so they follow
+// the user across browsers
+type UserSettings struct {
+	DefaultModel       string                    `json:"default_model"`       // Model selected for new chats; empty for the UI's default
+	Theme              string                    `json:"theme"`               // "dark" or "light"
+	AutoCompact        bool                      `json:"auto_compact"`        // Enable auto-compaction for new sessions
+	CompactThreshold   int                       `json:"compact_threshold"`   // Tokens before a new session is auto-compacted
+	PermissionDefaults map[string]PermissionType `json:"permission_defaults"` // Tool permissions given to new sessions
+	PermissionRules    []PermissionRule          `json:"permission_rules"`    // Rules deciding tool calls by their arguments, in every session
+	SessionRecaps      bool                      `json:"session_recaps"`      // Recap a session when a new one is started, and offer the recap to it
+	Editor             EditorSettings            `json:"editor"`
+	UpdatedAt          *time.Time                `json:"updated_at,omitempty"`
+}
+
+// EditorSettings are options for the file editor and diff viewer
+type EditorSettings struct {
+	FontSize int  `json:"font_size"`
+	TabSize  int  `json:"tab_size"`
+	WordWrap bool `json:"word_wrap"`
+	Minimap  bool `json:"minimap"`
+}
+
+// DefaultUserSettings returns the settings used until the user changes them
+func DefaultUserSettings() UserSettings {
+	return UserSettings{
+		Theme:              "dark",
+		CompactThreshold:   50000, // The sessions table's default
+		PermissionDefaults: map[string]PermissionType{},
+		PermissionRules:    []PermissionRule{},
+		Editor: EditorSettings{
+			FontSize: 14,
+			TabSize:  4,
+			WordWrap: true,
+		},
+	}
+}
+
+// Validate checks that settings are in range
+func (s UserSettings) Validate() error {
+	if s.Theme != "dark" && s.Theme != "light" {
+		return serr.New(fmt.Sprintf("theme must be dark or light, not %q", s.Theme))
+	}
+	if s.CompactThreshold < 1000 {
+		return serr.New("compact_threshold must be at least 1000 tokens")
+	}
+	for tool, perm := range s.PermissionDefaults {
+		if perm != PermissionAllowed && perm != PermissionDenied && perm != PermissionAsk {
+			return serr.New(fmt.Sprintf("permission for %s must be allowed, denied or ask, not %q", tool, perm))
+		}
+	}
+	for _, rule := range s.PermissionRules {
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+	}
+	if s.Editor.FontSize < 8 || s.Editor.FontSize > 32 {
+		return serr.New("editor font_size must be between 8 and 32")
+	}
+	if s.Editor.TabSize < 1 || s.Editor.TabSize > 8 {
+		return serr.New("editor tab_size must be between 1 and 8")
+	}
+	return nil
+}
+
+// settingsKey is the user_settings key of a user's preferences. In single-user mode,
+// where userID is 0, it is 'preferences'.
+func settingsKey(userID int) string {
+	if userID == 0 {
+		return "preferences"
+	}
+	return fmt.Sprintf("preferences:%d", userID)
+}
+
+// GetUserSettings returns a user's stored settings, with defaults for those never saved
+func (db *DB) GetUserSettings(userID int) (UserSettings, error) {
+	settings := DefaultUserSettings()
+
+	var value string
+	var updatedAt time.Time
+	err := db.QueryRow("SELECT value::VARCHAR, updated_at FROM user_settings WHERE key = ?", settingsKey(userID)).Scan(&value, &updatedAt)
+	if err == sql.ErrNoRows {
+		return settings, nil
+	}
+	if err != nil {
+		return settings, serr.Wrap(err, "failed to get user settings")
+	}
+
+	// Stored settings are laid over the defaults, so settings added later get their default
+	if err := json.Unmarshal([]byte(value), &settings); err != nil {
+		return DefaultUserSettings(), serr.Wrap(err, "failed to unmarshal user settings")
+	}
+	if settings.PermissionDefaults == nil {
+		settings.PermissionDefaults = map[string]PermissionType{}
+	}
+	if settings.PermissionRules == nil {
+		settings.PermissionRules = []PermissionRule{}
+	}
+	settings.UpdatedAt = &updatedAt
+	return settings, nil
+}
+
+// SaveUserSettings validates and stores a user's settings
+func (db *DB) SaveUserSettings(userID int, settings UserSettings) (UserSettings, error) {
+	if err := settings.Validate(); err != nil {
+		return settings, err
+	}
+
+	settings.UpdatedAt = nil
+	value, err := json.Marshal(settings)
+	if err != nil {
+		return settings, serr.Wrap(err, "failed to marshal user settings")
+	}
+
+	now := time.Now()
+	if _, err := db.Exec(`
+		INSERT INTO user_settings (key, value, updated_at)
+		VALUES (?, ?::JSON, ?)
+		ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_at = EXCLUDED.updated_at
+	`, settingsKey(userID), string(value), now); err != nil {
+		return settings, serr.Wrap(err, "failed to save user settings")
+	}
+
+	settings.UpdatedAt = &now
+	return settings, nil
+}
@@ -0,0 +1,208 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/serr"
+)
+
+// SystemPrompt is instructions sent in the system prompt of every request of the sessions
+// using it, after the line identifying rcode. The default is used by sessions without one
+// of their own. Content may use template variables such as {project_language}.
+type SystemPrompt struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Content     string    `json:"content"`
+	IsDefault   bool      `json:"is_default"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+const systemPromptColumns = "id, name, COALESCE(description, ''), content, is_default, created_at, updated_at"
+
+func scanSystemPrompt(row interface{ Scan(...interface{}) error }) (*SystemPrompt, error) {
+	prompt := &SystemPrompt{}
+	err := row.Scan(&prompt.ID, &prompt.Name, &prompt.Description, &prompt.Content,
+		&prompt.IsDefault, &prompt.CreatedAt, &prompt.UpdatedAt)
+	return prompt, err
+}
+
+// CreateSystemPrompt creates a system prompt. When it is the default, the previous default
+// stops being one.
+func (db *DB) CreateSystemPrompt(prompt *SystemPrompt) error {
+	err := db.Transaction(func(tx *sql.Tx) error {
+		if prompt.IsDefault {
+			if _, err := tx.Exec("UPDATE system_prompts SET is_default = false WHERE is_default = true"); err != nil {
+				return serr.Wrap(err, "failed to clear the default system prompt")
+			}
+		}
+		return tx.QueryRow(`
+			INSERT INTO system_prompts (name, description, content, is_default)
+			VALUES (?, ?, ?, ?)
+			RETURNING id, created_at, updated_at
+		`, prompt.Name, prompt.Description, prompt.Content, prompt.IsDefault).
+			Scan(&prompt.ID, &prompt.CreatedAt, &prompt.UpdatedAt)
+	})
+	if err != nil {
+		return serr.Wrap(err, "failed to create system prompt")
+	}
+	return nil
+}
+
+// GetSystemPrompt retrieves a system prompt by ID
+func (db *DB) GetSystemPrompt(id int) (*SystemPrompt, error) {
+	prompt, err := scanSystemPrompt(db.QueryRow("SELECT "+systemPromptColumns+" FROM system_prompts WHERE id = ?", id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, serr.New("system prompt not found")
+		}
+		return nil, serr.Wrap(err, "failed to get system prompt")
+	}
+	return prompt, nil
+}
+
+// GetSystemPromptByName retrieves a system prompt by name, nil when there is none
+func (db *DB) GetSystemPromptByName(name string) (*SystemPrompt, error) {
+	prompt, err := scanSystemPrompt(db.QueryRow("SELECT "+systemPromptColumns+" FROM system_prompts WHERE name = ?", name))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, serr.Wrap(err, "failed to get system prompt")
+	}
+	return prompt, nil
+}
+
+// GetSystemPrompts lists the system prompts, the default first
+func (db *DB) GetSystemPrompts() ([]*SystemPrompt, error) {
+	rows, err := db.Query("SELECT " + systemPromptColumns + " FROM system_prompts ORDER BY is_default DESC, name ASC")
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to query system prompts")
+	}
+	defer rows.Close()
+
+	var prompts []*SystemPrompt
+	for rows.Next() {
+		prompt, err := scanSystemPrompt(rows)
+		if err != nil {
+			return nil, serr.Wrap(err, "failed to scan system prompt")
+		}
+		prompts = append(prompts, prompt)
+	}
+	return prompts, rows.Err()
+}
+
+// UpdateSystemPrompt updates a system prompt. When it becomes the default, the previous
+// default stops being one.
+func (db *DB) UpdateSystemPrompt(prompt *SystemPrompt) error {
+	err := db.Transaction(func(tx *sql.Tx) error {
+		if prompt.IsDefault {
+			if _, err := tx.Exec("UPDATE system_prompts SET is_default = false WHERE is_default = true AND id <> ?", prompt.ID); err != nil {
+				return serr.Wrap(err, "failed to clear the default system prompt")
+			}
+		}
+		err := tx.QueryRow(`
+			UPDATE system_prompts
+			SET name = ?, description = ?, content = ?, is_default = ?, updated_at = CURRENT_TIMESTAMP
+			WHERE id = ?
+			RETURNING updated_at
+		`, prompt.Name, prompt.Description, prompt.Content, prompt.IsDefault, prompt.ID).Scan(&prompt.UpdatedAt)
+		if err == sql.ErrNoRows {
+			return serr.New("system prompt not found")
+		}
+		return err
+	})
+	if err != nil {
+		return serr.Wrap(err, "failed to update system prompt")
+	}
+	return nil
+}
+
+// DeleteSystemPrompt deletes a system prompt. The sessions using it go back to the default.
+func (db *DB) DeleteSystemPrompt(id int) error {
+	return db.Transaction(func(tx *sql.Tx) error {
+		if _, err := tx.Exec("UPDATE sessions SET system_prompt_id = NULL WHERE system_prompt_id = ?", id); err != nil {
+			return serr.Wrap(err, "failed to clear the system prompt from sessions")
+		}
+		result, err := tx.Exec("DELETE FROM system_prompts WHERE id = ?", id)
+		if err != nil {
+			return serr.Wrap(err, "failed to delete system prompt")
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return serr.Wrap(err, "failed to get rows affected")
+		}
+		if rowsAffected == 0 {
+			return serr.New("system prompt not found")
+		}
+		return nil
+	})
+}
+
+// GetSessionSystemPrompt returns the session's system prompt, or the default when the
+// session has none of its own. The prompt is nil when there is no default either.
+func (db *DB) GetSessionSystemPrompt(sessionID string) (*SystemPrompt, error) {
+	prompt, err := scanSystemPrompt(db.QueryRow(`
+		SELECT `+systemPromptColumns+` FROM system_prompts
+		WHERE id = (SELECT system_prompt_id FROM sessions WHERE id = ?)
+		   OR (is_default = true AND NOT EXISTS (
+		       SELECT 1 FROM sessions WHERE id = ? AND system_prompt_id IS NOT NULL))
+		ORDER BY is_default ASC
+		LIMIT 1
+	`, sessionID, sessionID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, serr.Wrap(err, "failed to get session system prompt")
+	}
+	return prompt, nil
+}
+
+// GetSessionSystemPromptID returns the ID of the session's own system prompt, nil when it
+// uses the default
+func (db *DB) GetSessionSystemPromptID(sessionID string) (*int, error) {
+	var id sql.NullInt64
+	err := db.QueryRow("SELECT system_prompt_id FROM sessions WHERE id = ?", sessionID).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, serr.New("session not found")
+		}
+		return nil, serr.Wrap(err, "failed to get session system prompt")
+	}
+	if !id.Valid {
+		return nil, nil
+	}
+	promptID := int(id.Int64)
+	return &promptID, nil
+}
+
+// SetSessionSystemPrompt sets the session's own system prompt, or with nil, has it use the default
+func (db *DB) SetSessionSystemPrompt(sessionID string, promptID *int) error {
+	var id interface{} // The driver can't bind a pointer
+	if promptID != nil {
+		id = *promptID
+	}
+	result, err := db.Exec(`
+		UPDATE sessions
+		SET system_prompt_id = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, id, sessionID)
+	if err != nil {
+		return serr.Wrap(err, "failed to set session system prompt")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return serr.Wrap(err, "failed to get rows affected")
+	}
+	if rowsAffected == 0 {
+		return serr.New("session not found")
+	}
+
+	logger.Info("Set session system prompt", "session_id", sessionID, "default", promptID == nil)
+	return nil
+}
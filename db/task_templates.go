@@ -0,0 +1,168 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/serr"
+)
+
+// TaskTemplate represents a stored, reusable task plan template.
+// Steps and Variables hold the JSON of the planner's template types.
+type TaskTemplate struct {
+	ID          int             `json:"id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Category    string          `json:"category"`
+	Steps       json.RawMessage `json:"steps"`
+	Variables   json.RawMessage `json:"variables"`
+	IsBuiltin   bool            `json:"is_builtin"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+const taskTemplateColumns = `id, name, COALESCE(description, ''), COALESCE(category, ''),
+	steps::VARCHAR, COALESCE(variables::VARCHAR, '[]'), is_builtin, created_at, updated_at`
+
+// CreateTaskTemplate stores a new task template
+func (db *DB) CreateTaskTemplate(tmpl *TaskTemplate) error {
+	variables := tmpl.Variables
+	if len(variables) == 0 {
+		variables = json.RawMessage("[]")
+	}
+
+	err := db.WriteRow(`
+		INSERT INTO task_templates (name, description, category, steps, variables, is_builtin)
+		VALUES (?, ?, ?, ?::JSON, ?::JSON, ?)
+		RETURNING id, created_at, updated_at
+	`, []interface{}{tmpl.Name, tmpl.Description, tmpl.Category, string(tmpl.Steps), string(variables), tmpl.IsBuiltin},
+		&tmpl.ID, &tmpl.CreatedAt, &tmpl.UpdatedAt)
+	if err != nil {
+		return serr.Wrap(err, "failed to create task template")
+	}
+
+	tmpl.Variables = variables
+	return nil
+}
+
+// GetTaskTemplate retrieves a task template by ID
+func (db *DB) GetTaskTemplate(id int) (*TaskTemplate, error) {
+	tmpl, err := scanTaskTemplate(db.QueryRow(
+		"SELECT "+taskTemplateColumns+" FROM task_templates WHERE id = ?", id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, serr.New("task template not found")
+		}
+		return nil, serr.Wrap(err, "failed to get task template")
+	}
+	return tmpl, nil
+}
+
+// GetTaskTemplateByName retrieves a task template by its unique name, returning nil if absent
+func (db *DB) GetTaskTemplateByName(name string) (*TaskTemplate, error) {
+	tmpl, err := scanTaskTemplate(db.QueryRow(
+		"SELECT "+taskTemplateColumns+" FROM task_templates WHERE name = ?", name))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, serr.Wrap(err, "failed to get task template")
+	}
+	return tmpl, nil
+}
+
+// ListTaskTemplates returns all task templates, optionally filtered by category
+func (db *DB) ListTaskTemplates(category string) ([]*TaskTemplate, error) {
+	query := "SELECT " + taskTemplateColumns + " FROM task_templates"
+	var args []interface{}
+	if category != "" {
+		query += " WHERE category = ?"
+		args = append(args, category)
+	}
+	query += " ORDER BY is_builtin DESC, name ASC"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to query task templates")
+	}
+	defer rows.Close()
+
+	templates := make([]*TaskTemplate, 0)
+	for rows.Next() {
+		tmpl, err := scanTaskTemplate(rows)
+		if err != nil {
+			return nil, serr.Wrap(err, "failed to scan task template")
+		}
+		templates = append(templates, tmpl)
+	}
+
+	return templates, nil
+}
+
+// UpdateTaskTemplate updates a user-defined task template. Built-in templates are read-only.
+func (db *DB) UpdateTaskTemplate(tmpl *TaskTemplate) error {
+	existing, err := db.GetTaskTemplate(tmpl.ID)
+	if err != nil {
+		return err
+	}
+	if existing.IsBuiltin {
+		return serr.New("built-in task templates cannot be modified")
+	}
+
+	variables := tmpl.Variables
+	if len(variables) == 0 {
+		variables = json.RawMessage("[]")
+	}
+
+	now := time.Now()
+	_, err = db.Exec(`
+		UPDATE task_templates
+		SET name = ?, description = ?, category = ?, steps = ?::JSON, variables = ?::JSON, updated_at = ?
+		WHERE id = ?
+	`, tmpl.Name, tmpl.Description, tmpl.Category, string(tmpl.Steps), string(variables), now, tmpl.ID)
+	if err != nil {
+		return serr.Wrap(err, "failed to update task template", "table", "task_templates")
+	}
+
+	tmpl.Variables = variables
+	tmpl.IsBuiltin = false
+	tmpl.CreatedAt = existing.CreatedAt
+	tmpl.UpdatedAt = now
+
+	logger.Debug("Updated task template", "id", tmpl.ID, "name", tmpl.Name)
+	return nil
+}
+
+// DeleteTaskTemplate deletes a user-defined task template. Built-in templates are read-only.
+func (db *DB) DeleteTaskTemplate(id int) error {
+	existing, err := db.GetTaskTemplate(id)
+	if err != nil {
+		return err
+	}
+	if existing.IsBuiltin {
+		return serr.New("built-in task templates cannot be deleted")
+	}
+
+	if _, err := db.Exec("DELETE FROM task_templates WHERE id = ?", id); err != nil {
+		return serr.Wrap(err, "failed to delete task template")
+	}
+	return nil
+}
+
+// scanTaskTemplate scans a row selected with taskTemplateColumns
+func scanTaskTemplate(row interface{ Scan(...interface{}) error }) (*TaskTemplate, error) {
+	tmpl := &TaskTemplate{}
+	var stepsJSON, variablesJSON string
+
+	err := row.Scan(&tmpl.ID, &tmpl.Name, &tmpl.Description, &tmpl.Category,
+		&stepsJSON, &variablesJSON, &tmpl.IsBuiltin, &tmpl.CreatedAt, &tmpl.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl.Steps = json.RawMessage(stepsJSON)
+	tmpl.Variables = json.RawMessage(variablesJSON)
+	return tmpl, nil
+}
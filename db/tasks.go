@@ -3,6 +3,8 @@ package db
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -37,8 +39,8 @@ func (t *TaskPlanDB) SavePlan(plan *TaskPlan) error {
 	}
 	
 	query := `
-		INSERT INTO task_plans (id, session_id, description, status, steps, context, checkpoints)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO task_plans (id, session_id, description, status, steps, context, checkpoints, user_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, (SELECT user_id FROM sessions WHERE id = ?))
 		ON CONFLICT(id) DO UPDATE SET
 			status = excluded.status,
 			steps = excluded.steps,
@@ -49,7 +51,7 @@ func (t *TaskPlanDB) SavePlan(plan *TaskPlan) error {
 	`
 	
 	_, err = t.db.Exec(query, plan.ID, plan.SessionID, plan.Description, string(plan.Status),
-		string(stepsJSON), string(contextJSON), string(checkpointsJSON))
+		string(stepsJSON), string(contextJSON), string(checkpointsJSON), plan.SessionID)
 	
 	return serr.Wrap(err, "failed to save plan")
 }
@@ -229,52 +231,100 @@ func (t *TaskPlanDB) GetSessionPlansWithFilter(sessionID, status, search string,
 	return plans, total, nil
 }
 
-// DeletePlan deletes a plan and all related data
-func (t *TaskPlanDB) DeletePlan(planID string) error {
-	// Use a transaction to ensure all related data is deleted
-	tx, err := t.db.Conn().Begin()
-	if err != nil {
-		return serr.Wrap(err, "failed to start transaction")
-	}
-	defer tx.Rollback()
-	
-	// Delete in order to respect foreign key constraints
-	// Delete logs
-	_, err = tx.Exec("DELETE FROM task_logs WHERE plan_id = ?", planID)
-	if err != nil {
-		return serr.Wrap(err, "failed to delete logs")
-	}
-	
-	// Delete metrics
-	_, err = tx.Exec("DELETE FROM task_metrics WHERE plan_id = ?", planID)
-	if err != nil {
-		return serr.Wrap(err, "failed to delete metrics")
-	}
-	
-	// Delete file snapshots
-	_, err = tx.Exec("DELETE FROM file_snapshots WHERE plan_id = ?", planID)
-	if err != nil {
-		return serr.Wrap(err, "failed to delete snapshots")
+// GetPlansByStatus retrieves plans across all sessions in any of the given statuses
+func (t *TaskPlanDB) GetPlansByStatus(statuses ...PlanStatus) ([]*TaskPlan, error) {
+	if len(statuses) == 0 {
+		return nil, nil
 	}
-	
-	// Delete executions
-	_, err = tx.Exec("DELETE FROM task_executions WHERE plan_id = ?", planID)
-	if err != nil {
-		return serr.Wrap(err, "failed to delete executions")
+
+	placeholders := make([]string, len(statuses))
+	args := make([]interface{}, len(statuses))
+	for i, status := range statuses {
+		placeholders[i] = "?"
+		args[i] = string(status)
 	}
-	
-	// Finally, delete the plan itself
-	_, err = tx.Exec("DELETE FROM task_plans WHERE id = ?", planID)
+
+	query := fmt.Sprintf(`
+		SELECT id, session_id, description, status, steps, context, checkpoints,
+		       created_at, updated_at, completed_at
+		FROM task_plans
+		WHERE status IN (%s)
+		ORDER BY updated_at ASC
+	`, strings.Join(placeholders, ", "))
+
+	rows, err := t.db.Query(query, args...)
 	if err != nil {
-		return serr.Wrap(err, "failed to delete plan")
+		return nil, serr.Wrap(err, "failed to query plans by status")
 	}
-	
-	// Commit the transaction
-	if err := tx.Commit(); err != nil {
-		return serr.Wrap(err, "failed to commit transaction")
+	defer rows.Close()
+
+	var plans []*TaskPlan
+	for rows.Next() {
+		var plan TaskPlan
+		var stepsJSON, contextJSON, checkpointsJSON string
+		var completedAt sql.NullTime
+		var status string
+
+		if err := rows.Scan(
+			&plan.ID, &plan.SessionID, &plan.Description, &status,
+			&stepsJSON, &contextJSON, &checkpointsJSON,
+			&plan.CreatedAt, &plan.UpdatedAt, &completedAt,
+		); err != nil {
+			return nil, serr.Wrap(err, "failed to scan plan")
+		}
+		plan.Status = PlanStatus(status)
+
+		if completedAt.Valid {
+			plan.CompletedAt = &completedAt.Time
+		}
+
+		plan.Steps = json.RawMessage(stepsJSON)
+		plan.Context = json.RawMessage(contextJSON)
+		plan.Checkpoints = json.RawMessage(checkpointsJSON)
+
+		plans = append(plans, &plan)
 	}
-	
-	return nil
+
+	return plans, rows.Err()
+}
+
+// DeletePlan deletes a plan and all related data
+func (t *TaskPlanDB) DeletePlan(planID string) error {
+	// Use a transaction to ensure all related data is deleted
+	return t.db.Transaction(func(tx *sql.Tx) error {
+		// Delete in order to respect foreign key constraints
+		// Delete logs
+		_, err := tx.Exec("DELETE FROM task_logs WHERE plan_id = ?", planID)
+		if err != nil {
+			return serr.Wrap(err, "failed to delete logs")
+		}
+
+		// Delete metrics
+		_, err = tx.Exec("DELETE FROM task_metrics WHERE plan_id = ?", planID)
+		if err != nil {
+			return serr.Wrap(err, "failed to delete metrics")
+		}
+
+		// Delete file snapshots
+		_, err = tx.Exec("DELETE FROM file_snapshots WHERE plan_id = ?", planID)
+		if err != nil {
+			return serr.Wrap(err, "failed to delete snapshots")
+		}
+
+		// Delete executions
+		_, err = tx.Exec("DELETE FROM task_executions WHERE plan_id = ?", planID)
+		if err != nil {
+			return serr.Wrap(err, "failed to delete executions")
+		}
+
+		// Finally, delete the plan itself
+		_, err = tx.Exec("DELETE FROM task_plans WHERE id = ?", planID)
+		if err != nil {
+			return serr.Wrap(err, "failed to delete plan")
+		}
+
+		return nil
+	})
 }
 
 // SaveExecution saves step execution result
@@ -457,8 +507,8 @@ func (t *TaskPlanDB) SaveMetrics(metrics *TaskMetrics) error {
 		INSERT INTO task_metrics (
 			plan_id, total_steps, completed_steps, failed_steps, skipped_steps,
 			total_duration_ms, avg_step_duration_ms, total_retries,
-			context_files_used, tools_used
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			context_files_used, tools_used, coverage_before, coverage_after
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(plan_id) DO UPDATE SET
 			total_steps = excluded.total_steps,
 			completed_steps = excluded.completed_steps,
@@ -469,13 +519,15 @@ func (t *TaskPlanDB) SaveMetrics(metrics *TaskMetrics) error {
 			total_retries = excluded.total_retries,
 			context_files_used = excluded.context_files_used,
 			tools_used = excluded.tools_used,
+			coverage_before = excluded.coverage_before,
+			coverage_after = excluded.coverage_after,
 			updated_at = CURRENT_TIMESTAMP
 	`
 	
 	_, err = t.db.Exec(query, metrics.PlanID, metrics.TotalSteps, metrics.CompletedSteps,
 		metrics.FailedSteps, metrics.SkippedSteps, metrics.TotalDurationMs,
 		metrics.AvgStepDurationMs, metrics.TotalRetries, metrics.ContextFilesUsed,
-		string(toolsJSON))
+		string(toolsJSON), metrics.CoverageBefore, metrics.CoverageAfter)
 	
 	return serr.Wrap(err, "failed to save metrics")
 }
@@ -488,7 +540,7 @@ func (t *TaskPlanDB) GetMetrics(planID string) (*TaskMetrics, error) {
 	query := `
 		SELECT plan_id, total_steps, completed_steps, failed_steps, skipped_steps,
 		       total_duration_ms, avg_step_duration_ms, total_retries,
-		       context_files_used, tools_used, updated_at
+		       context_files_used, tools_used, coverage_before, coverage_after, updated_at
 		FROM task_metrics
 		WHERE plan_id = ?
 	`
@@ -497,7 +549,7 @@ func (t *TaskPlanDB) GetMetrics(planID string) (*TaskMetrics, error) {
 		&metrics.PlanID, &metrics.TotalSteps, &metrics.CompletedSteps,
 		&metrics.FailedSteps, &metrics.SkippedSteps, &metrics.TotalDurationMs,
 		&metrics.AvgStepDurationMs, &metrics.TotalRetries, &metrics.ContextFilesUsed,
-		&toolsJSON, &metrics.UpdatedAt,
+		&toolsJSON, &metrics.CoverageBefore, &metrics.CoverageAfter, &metrics.UpdatedAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -622,6 +674,8 @@ type TaskMetrics struct {
 	TotalRetries      int                    `json:"total_retries"`
 	ContextFilesUsed  int                    `json:"context_files_used"`
 	ToolsUsed         map[string]int         `json:"tools_used"`
+	CoverageBefore    *float64               `json:"coverage_before,omitempty"` // Test coverage the plan measured first, in percent
+	CoverageAfter     *float64               `json:"coverage_after,omitempty"`  // and last
 	UpdatedAt         time.Time              `json:"updated_at"`
 }
 
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/serr"
+)
+
+// Statuses of a todo
+const (
+	TodoPending    = "pending"
+	TodoInProgress = "in_progress"
+	TodoCompleted  = "completed"
+)
+
+// Todo is an item of the task list the model keeps for a session
+type Todo struct {
+	Content string `json:"content"`
+	Status  string `json:"status"` // pending, in_progress or completed
+}
+
+// ValidTodoStatus reports whether status is one of the todo statuses
+func ValidTodoStatus(status string) bool {
+	switch status {
+	case TodoPending, TodoInProgress, TodoCompleted:
+		return true
+	}
+	return false
+}
+
+// GetTodos returns a session's task list, in order
+func (db *DB) GetTodos(sessionID string) ([]Todo, error) {
+	rows, err := db.Query(`
+		SELECT content, status
+		FROM session_todos
+		WHERE session_id = ?
+		ORDER BY position
+	`, sessionID)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to get todos")
+	}
+	defer rows.Close()
+
+	todos := []Todo{}
+	for rows.Next() {
+		var todo Todo
+		if err := rows.Scan(&todo.Content, &todo.Status); err != nil {
+			return nil, serr.Wrap(err, "failed to scan todo")
+		}
+		todos = append(todos, todo)
+	}
+	return todos, rows.Err()
+}
+
+// SetTodos replaces a session's task list
+func (db *DB) SetTodos(sessionID string, todos []Todo) error {
+	for _, todo := range todos {
+		if !ValidTodoStatus(todo.Status) {
+			return serr.New("invalid todo status: " + todo.Status)
+		}
+	}
+
+	err := db.Transaction(func(tx *sql.Tx) error {
+		if _, err := tx.Exec("DELETE FROM session_todos WHERE session_id = ?", sessionID); err != nil {
+			return serr.Wrap(err, "failed to clear todos")
+		}
+		for i, todo := range todos {
+			if _, err := tx.Exec(`
+				INSERT INTO session_todos (session_id, position, content, status)
+				VALUES (?, ?, ?, ?)
+			`, sessionID, i, todo.Content, todo.Status); err != nil {
+				return serr.Wrap(err, "failed to add todo")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Debug("Set todos", "session_id", sessionID, "count", len(todos))
+	return nil
+}
+
+// DeleteSessionTodos removes a session's task list
+func (db *DB) DeleteSessionTodos(sessionID string) error {
+	if _, err := db.Exec("DELETE FROM session_todos WHERE session_id = ?", sessionID); err != nil {
+		return serr.Wrap(err, "failed to delete todos")
+	}
+	return nil
+}
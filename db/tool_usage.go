@@ -0,0 +1,165 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"rcode/redact"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// ToolUsage records one tool call, or one terminal session, made in a chat session
+type ToolUsage struct {
+	ID         int                    `json:"id"`
+	SessionID  string                 `json:"session_id"`
+	ToolName   string                 `json:"tool_name"`
+	Input      map[string]interface{} `json:"input"`
+	Output     string                 `json:"output,omitempty"`
+	ExecutedAt time.Time              `json:"executed_at"`
+	DurationMs int                    `json:"duration_ms"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+// LogToolUsage records a tool call for auditing. Input keys starting with an
+// underscore are internal and are not stored.
+func (db *DB) LogToolUsage(sessionID, toolName string, input map[string]interface{}, output string, durationMs int, toolErr error) error {
+	stored := make(map[string]interface{}, len(input))
+	for k, v := range input {
+		if !strings.HasPrefix(k, "_") {
+			stored[k] = v
+		}
+	}
+	inputJSON, err := json.Marshal(stored)
+	if err != nil {
+		return serr.Wrap(err, "failed to marshal tool input")
+	}
+	inputJSON = redact.Default().JSON(inputJSON)
+
+	var errMsg sql.NullString
+	if toolErr != nil {
+		errMsg = sql.NullString{String: toolErr.Error(), Valid: true}
+	}
+
+	if _, err := db.Exec(`
+		INSERT INTO tool_usage (session_id, tool_name, input, output, duration_ms, error)
+		VALUES (?, ?, ?::JSON, ?, ?, ?)
+	`, sessionID, toolName, string(inputJSON), output, durationMs, errMsg); err != nil {
+		return serr.Wrap(err, "failed to log tool usage")
+	}
+	return nil
+}
+
+// ToolUsageFilter selects tool usage records for the audit log. Empty fields don't filter.
+type ToolUsageFilter struct {
+	SessionID string
+	ToolName  string
+	Status    string    // "success" or "error"
+	Since     time.Time // Executed at or after
+	Until     time.Time // Executed before
+	Limit     int       // 0 for no limit
+	Offset    int
+}
+
+// ListToolUsage returns a session's recorded tool calls, newest first, optionally for one tool
+func (db *DB) ListToolUsage(sessionID, toolName string, limit int) ([]ToolUsage, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	return db.QueryToolUsage(ToolUsageFilter{SessionID: sessionID, ToolName: toolName, Limit: limit})
+}
+
+// QueryToolUsage returns the recorded tool calls a filter selects, newest first
+func (db *DB) QueryToolUsage(filter ToolUsageFilter) ([]ToolUsage, error) {
+	query := `
+		SELECT id, session_id, tool_name, input::VARCHAR, output, executed_at, duration_ms, error
+		FROM tool_usage
+		WHERE 1 = 1`
+	var args []interface{}
+	if filter.SessionID != "" {
+		query += " AND session_id = ?"
+		args = append(args, filter.SessionID)
+	}
+	if filter.ToolName != "" {
+		query += " AND tool_name = ?"
+		args = append(args, filter.ToolName)
+	}
+	switch filter.Status {
+	case "":
+	case "success":
+		query += " AND error IS NULL"
+	case "error":
+		query += " AND error IS NOT NULL"
+	default:
+		return nil, serr.New("unknown tool usage status " + filter.Status)
+	}
+	if !filter.Since.IsZero() {
+		query += " AND executed_at >= ?"
+		args = append(args, filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query += " AND executed_at < ?"
+		args = append(args, filter.Until)
+	}
+	query += " ORDER BY executed_at DESC, id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, filter.Limit, filter.Offset)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to query tool usage")
+	}
+	defer rows.Close()
+
+	usages := []ToolUsage{}
+	for rows.Next() {
+		var u ToolUsage
+		var inputJSON string
+		var output, errMsg sql.NullString
+		var durationMs sql.NullInt64
+		if err := rows.Scan(&u.ID, &u.SessionID, &u.ToolName, &inputJSON, &output, &u.ExecutedAt, &durationMs, &errMsg); err != nil {
+			return nil, serr.Wrap(err, "failed to scan tool usage")
+		}
+		if err := json.Unmarshal([]byte(inputJSON), &u.Input); err != nil {
+			return nil, serr.Wrap(err, "failed to unmarshal tool input")
+		}
+		u.Output = output.String
+		u.DurationMs = int(durationMs.Int64)
+		u.Error = errMsg.String
+		usages = append(usages, u)
+	}
+	return usages, rows.Err()
+}
+
+// PruneToolUsage applies the audit log's retention: it deletes the records of tool calls
+// made before entriesBefore, and clears the output of those made before outputsBefore.
+// A zero time skips that step.
+func (db *DB) PruneToolUsage(entriesBefore, outputsBefore time.Time) (deleted int64, cleared int64, err error) {
+	if !entriesBefore.IsZero() {
+		result, err := db.Exec("DELETE FROM tool_usage WHERE executed_at < ?", entriesBefore)
+		if err != nil {
+			return 0, 0, serr.Wrap(err, "failed to delete old tool usage")
+		}
+		deleted, _ = result.RowsAffected()
+	}
+	if !outputsBefore.IsZero() {
+		result, err := db.Exec("UPDATE tool_usage SET output = NULL WHERE executed_at < ? AND output IS NOT NULL", outputsBefore)
+		if err != nil {
+			return deleted, 0, serr.Wrap(err, "failed to clear old tool output")
+		}
+		cleared, _ = result.RowsAffected()
+	}
+	return deleted, cleared, nil
+}
+
+// DeleteSessionToolUsage removes a session's tool usage records
+func (db *DB) DeleteSessionToolUsage(sessionID string) error {
+	if _, err := db.Exec("DELETE FROM tool_usage WHERE session_id = ?", sessionID); err != nil {
+		return serr.Wrap(err, "failed to delete tool usage")
+	}
+	return nil
+}
@@ -21,43 +21,9 @@ type UsageRecord struct {
 	CreatedAt     time.Time                `json:"created_at"`
 }
 
-// CreateUsageTable creates the usage tracking table if it doesn't exist
-func (db *DB) CreateUsageTable() error {
-	query := `
-		CREATE TABLE IF NOT EXISTS usage_tracking (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			session_id TEXT NOT NULL,
-			message_id INTEGER,
-			model TEXT NOT NULL,
-			input_tokens INTEGER NOT NULL DEFAULT 0,
-			output_tokens INTEGER NOT NULL DEFAULT 0,
-			rate_limits JSON,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (session_id) REFERENCES sessions(id),
-			FOREIGN KEY (message_id) REFERENCES messages(id)
-		)
-	`
-	_, err := db.conn.Exec(query)
-	if err != nil {
-		return serr.Wrap(err, "failed to create usage_tracking table")
-	}
-
-	// Create index for faster queries
-	_, err = db.conn.Exec(`CREATE INDEX IF NOT EXISTS idx_usage_session ON usage_tracking(session_id)`)
-	if err != nil {
-		return serr.Wrap(err, "failed to create usage index")
-	}
-
-	_, err = db.conn.Exec(`CREATE INDEX IF NOT EXISTS idx_usage_created ON usage_tracking(created_at)`)
-	if err != nil {
-		return serr.Wrap(err, "failed to create usage timestamp index")
-	}
-
-	return nil
-}
-
-// RecordUsage records token usage and rate limit information
-func (db *DB) RecordUsage(sessionID string, messageID *int, model string, usage *providers.Usage, rateLimits *providers.RateLimitInfo) error {
+// RecordUsage records token usage and rate limit information. model served the message,
+// in place of requestedModel when the request fell back to it.
+func (db *DB) RecordUsage(sessionID string, messageID *int, model, requestedModel string, usage *providers.Usage, rateLimits *providers.RateLimitInfo) error {
 	if usage == nil {
 		return nil // Nothing to record
 	}
@@ -79,10 +45,10 @@ func (db *DB) RecordUsage(sessionID string, messageID *int, model string, usage
 	}
 
 	query := `
-		INSERT INTO usage_tracking (session_id, message_id, model, input_tokens, output_tokens, rate_limits)
-		VALUES (?, ?, ?, ?, ?, ?)
+		INSERT INTO usage_tracking (session_id, message_id, model, requested_model, input_tokens, output_tokens, rate_limits)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
 	`
-	_, err := db.conn.Exec(query, sessionID, msgID, model, usage.InputTokens, usage.OutputTokens, rateLimitsJSON)
+	_, err := db.Exec(query, sessionID, msgID, model, requestedModel, usage.InputTokens, usage.OutputTokens, rateLimitsJSON)
 	if err != nil {
 		return serr.Wrap(err, "failed to record usage")
 	}
@@ -110,7 +76,7 @@ func (db *DB) GetSessionUsage(sessionID string) (totalInput int, totalOutput int
 	query = `
 		SELECT rate_limits
 		FROM usage_tracking
-		WHERE session_id = ? AND rate_limits IS NOT NULL AND rate_limits != 'null'
+		WHERE session_id = ? AND rate_limits IS NOT NULL AND rate_limits::VARCHAR != 'null'
 		ORDER BY created_at DESC
 		LIMIT 1
 	`
@@ -130,15 +96,26 @@ func (db *DB) GetSessionUsage(sessionID string) (totalInput int, totalOutput int
 	return totalInput, totalOutput, latestRateLimits, nil
 }
 
+// ModelUsage is the usage of one model
+type ModelUsage struct {
+	Input     int
+	Output    int
+	Fallbacks int // Messages the model served in place of the model they were asked of
+}
+
+// fallbackCount counts a usage row that was served by a fallback model
+const fallbackCount = `COUNT(CASE WHEN requested_model IS NOT NULL AND requested_model != '' AND requested_model != model THEN 1 END)`
+
 // GetDailyUsage gets usage statistics for today
-func (db *DB) GetDailyUsage() (map[string]struct{ Input, Output int }, error) {
+func (db *DB) GetDailyUsage() (map[string]ModelUsage, error) {
 	query := `
 		SELECT 
 			model,
 			COALESCE(SUM(input_tokens), 0) as total_input,
-			COALESCE(SUM(output_tokens), 0) as total_output
+			COALESCE(SUM(output_tokens), 0) as total_output,
+			` + fallbackCount + ` as fallbacks
 		FROM usage_tracking
-		WHERE DATE(created_at) = DATE('now')
+		WHERE CAST(created_at AS DATE) = CURRENT_DATE
 		GROUP BY model
 	`
 
@@ -148,27 +125,28 @@ func (db *DB) GetDailyUsage() (map[string]struct{ Input, Output int }, error) {
 	}
 	defer rows.Close()
 
-	usage := make(map[string]struct{ Input, Output int })
+	usage := make(map[string]ModelUsage)
 	for rows.Next() {
 		var model string
-		var input, output int
-		if err := rows.Scan(&model, &input, &output); err != nil {
+		var modelUsage ModelUsage
+		if err := rows.Scan(&model, &modelUsage.Input, &modelUsage.Output, &modelUsage.Fallbacks); err != nil {
 			return nil, serr.Wrap(err, "failed to scan usage row")
 		}
-		usage[model] = struct{ Input, Output int }{Input: input, Output: output}
+		usage[model] = modelUsage
 	}
 
 	return usage, nil
 }
 
 // GetGlobalUsage gets total usage across all sessions
-func (db *DB) GetGlobalUsage() (map[string]struct{ Input, Output int }, *providers.RateLimitInfo, error) {
+func (db *DB) GetGlobalUsage() (map[string]ModelUsage, *providers.RateLimitInfo, error) {
 	// Get total usage by model
 	query := `
 		SELECT 
 			model,
 			COALESCE(SUM(input_tokens), 0) as total_input,
-			COALESCE(SUM(output_tokens), 0) as total_output
+			COALESCE(SUM(output_tokens), 0) as total_output,
+			` + fallbackCount + ` as fallbacks
 		FROM usage_tracking
 		GROUP BY model
 	`
@@ -179,14 +157,14 @@ func (db *DB) GetGlobalUsage() (map[string]struct{ Input, Output int }, *provide
 	}
 	defer rows.Close()
 
-	usage := make(map[string]struct{ Input, Output int })
+	usage := make(map[string]ModelUsage)
 	for rows.Next() {
 		var model string
-		var input, output int
-		if err := rows.Scan(&model, &input, &output); err != nil {
+		var modelUsage ModelUsage
+		if err := rows.Scan(&model, &modelUsage.Input, &modelUsage.Output, &modelUsage.Fallbacks); err != nil {
 			return nil, nil, serr.Wrap(err, "failed to scan usage row")
 		}
-		usage[model] = struct{ Input, Output int }{Input: input, Output: output}
+		usage[model] = modelUsage
 	}
 
 	// Get latest rate limits
@@ -194,7 +172,7 @@ func (db *DB) GetGlobalUsage() (map[string]struct{ Input, Output int }, *provide
 	query = `
 		SELECT rate_limits
 		FROM usage_tracking
-		WHERE rate_limits IS NOT NULL AND rate_limits != 'null'
+		WHERE rate_limits IS NOT NULL AND rate_limits::VARCHAR != 'null'
 		ORDER BY created_at DESC
 		LIMIT 1
 	`
@@ -214,3 +192,33 @@ func (db *DB) GetGlobalUsage() (map[string]struct{ Input, Output int }, *provide
 
 	return usage, latestRateLimits, nil
 }
+
+// RecordProxyUsage records the tokens a request through the messages proxy used
+func (db *DB) RecordProxyUsage(client, model string, usage *providers.Usage) error {
+	if usage == nil {
+		return nil
+	}
+	_, err := db.Exec(`
+		INSERT INTO proxy_usage (client, model, input_tokens, output_tokens, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, client, model, usage.InputTokens, usage.OutputTokens, time.Now())
+	if err != nil {
+		return serr.Wrap(err, "failed to record proxy usage")
+	}
+	return nil
+}
+
+// GetProxyUsageSince gets the tokens used through the messages proxy since a time
+func (db *DB) GetProxyUsageSince(since time.Time) (totalInput int, totalOutput int, err error) {
+	err = db.conn.QueryRow(`
+		SELECT
+			COALESCE(SUM(input_tokens), 0),
+			COALESCE(SUM(output_tokens), 0)
+		FROM proxy_usage
+		WHERE created_at >= ?
+	`, since).Scan(&totalInput, &totalOutput)
+	if err != nil {
+		return 0, 0, serr.Wrap(err, "failed to get proxy usage")
+	}
+	return totalInput, totalOutput, nil
+}
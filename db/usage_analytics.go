@@ -0,0 +1,167 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// Dimensions usage can be grouped by
+const (
+	UsageByDay     = "day"
+	UsageByModel   = "model"
+	UsageBySession = "session"
+	UsageByTool    = "tool" // The replies that called each tool; a reply calling several counts toward each
+)
+
+// UsageFilter selects the usage that analytics cover
+type UsageFilter struct {
+	Since time.Time // Zero for no lower bound
+	Until time.Time // Zero for no upper bound; exclusive
+	User  *User     // In multi-user mode, only usage of the sessions this user can access
+}
+
+// UsageGroup is the usage of a group of messages with one model, which their cost depends on
+type UsageGroup struct {
+	Key       string // The day (YYYY-MM-DD), model, session ID or tool name
+	Title     string // The session's title, when grouped by session
+	Model     string
+	Input     int
+	Output    int
+	Messages  int
+	Fallbacks int
+}
+
+// GetUsageGroups returns the usage filter selects, grouped by one of the UsageBy dimensions
+// and by model
+func (db *DB) GetUsageGroups(by string, filter UsageFilter) ([]UsageGroup, error) {
+	var conditions []string
+	var args []interface{}
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, "u.created_at >= ?")
+		args = append(args, filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		conditions = append(conditions, "u.created_at < ?")
+		args = append(args, filter.Until)
+	}
+	if filter.User != nil {
+		// As User.CanAccess
+		conditions = append(conditions, "(s.user_id = ? OR (COALESCE(s.user_id, 0) = 0 AND ?))")
+		args = append(args, filter.User.ID, filter.User.IsAdmin)
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	if by == UsageByTool {
+		return db.getToolUsageGroups(where, args)
+	}
+
+	var key, title string
+	switch by {
+	case UsageByDay:
+		key, title = "CAST(CAST(u.created_at AS DATE) AS VARCHAR)", "''"
+	case UsageByModel:
+		key, title = "u.model", "''"
+	case UsageBySession:
+		key, title = "u.session_id", "MAX(s.title)"
+	default:
+		return nil, serr.New(fmt.Sprintf("usage can't be grouped by %q", by))
+	}
+
+	rows, err := db.conn.Query(`
+		SELECT `+key+` AS key, `+title+`, u.model,
+			COALESCE(SUM(u.input_tokens), 0), COALESCE(SUM(u.output_tokens), 0),
+			COUNT(*), `+fallbackCount+`
+		FROM usage_tracking u
+		JOIN sessions s ON s.id = u.session_id
+		`+where+`
+		GROUP BY key, u.model
+		ORDER BY key
+	`, args...)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to get usage by "+by)
+	}
+	defer rows.Close()
+
+	groups := []UsageGroup{}
+	for rows.Next() {
+		var g UsageGroup
+		if err := rows.Scan(&g.Key, &g.Title, &g.Model, &g.Input, &g.Output, &g.Messages, &g.Fallbacks); err != nil {
+			return nil, serr.Wrap(err, "failed to scan usage group")
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+// getToolUsageGroups groups the usage of the replies that called tools by the tools they
+// called, which are read from the replies' content
+func (db *DB) getToolUsageGroups(where string, args []interface{}) ([]UsageGroup, error) {
+	rows, err := db.conn.Query(`
+		SELECT CAST(m.content AS VARCHAR), u.model, u.input_tokens, u.output_tokens,
+			COALESCE(u.requested_model, '') NOT IN ('', u.model)
+		FROM usage_tracking u
+		JOIN sessions s ON s.id = u.session_id
+		JOIN messages m ON m.id = u.message_id
+		`+where, args...)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to get usage by tool")
+	}
+	defer rows.Close()
+
+	type groupKey struct{ tool, model string }
+	byTool := make(map[groupKey]*UsageGroup)
+	var order []groupKey
+	for rows.Next() {
+		var content, model string
+		var input, output int
+		var fellBack bool
+		if err := rows.Scan(&content, &model, &input, &output, &fellBack); err != nil {
+			return nil, serr.Wrap(err, "failed to scan usage row")
+		}
+
+		var blocks []struct {
+			Type string `json:"type"`
+			Name string `json:"name"`
+		}
+		if json.Unmarshal([]byte(content), &blocks) != nil {
+			continue // Text-only content is a string
+		}
+		seen := make(map[string]bool)
+		for _, block := range blocks {
+			if block.Type != "tool_use" || block.Name == "" || seen[block.Name] {
+				continue
+			}
+			seen[block.Name] = true
+
+			k := groupKey{block.Name, model}
+			g := byTool[k]
+			if g == nil {
+				g = &UsageGroup{Key: block.Name, Model: model}
+				byTool[k] = g
+				order = append(order, k)
+			}
+			g.Input += input
+			g.Output += output
+			g.Messages++
+			if fellBack {
+				g.Fallbacks++
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, serr.Wrap(err, "failed to read usage rows")
+	}
+
+	groups := make([]UsageGroup, 0, len(order))
+	for _, k := range order {
+		groups = append(groups, *byTool[k])
+	}
+	return groups, nil
+}
@@ -0,0 +1,279 @@
+package db
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// User is a local account, used when rcode runs in multi-user mode
+type User struct {
+	ID        int       `json:"id"`
+	Username  string    `json:"username"`
+	IsAdmin   bool      `json:"is_admin"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CanAccess reports whether the user may reach data owned by ownerID. Data without
+// an owner was created before multi-user mode was turned on and belongs to the admins.
+func (u *User) CanAccess(ownerID int) bool {
+	return ownerID == u.ID || (ownerID == 0 && u.IsAdmin)
+}
+
+// ErrInvalidLogin is returned for an unknown username or a wrong password, without saying which
+var ErrInvalidLogin = errors.New("invalid username or password")
+
+const (
+	minPasswordLength  = 8
+	passwordIterations = 600000 // OWASP's recommendation for PBKDF2-SHA256
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{1,31}$`)
+
+// CountUsers returns the number of accounts
+func (db *DB) CountUsers() (int, error) {
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count); err != nil {
+		return 0, serr.Wrap(err, "failed to count users")
+	}
+	return count, nil
+}
+
+// CreateUser adds an account
+func (db *DB) CreateUser(username, password string, isAdmin bool) (*User, error) {
+	username = strings.TrimSpace(username)
+	if !usernamePattern.MatchString(username) {
+		return nil, serr.New("username must be 2 to 32 letters, digits, '.', '_' or '-'")
+	}
+	if len(password) < minPasswordLength {
+		return nil, serr.New(fmt.Sprintf("password must be at least %d characters", minPasswordLength))
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	var exists bool
+	if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE lower(username) = lower(?))", username).Scan(&exists); err != nil {
+		return nil, serr.Wrap(err, "failed to check username")
+	}
+	if exists {
+		return nil, serr.New(fmt.Sprintf("user %s already exists", username))
+	}
+
+	user := &User{Username: username, IsAdmin: isAdmin}
+	err = db.WriteRow(`
+		INSERT INTO users (username, password_hash, is_admin)
+		VALUES (?, ?, ?)
+		RETURNING id, created_at
+	`, []interface{}{username, hash, isAdmin}, &user.ID, &user.CreatedAt)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to create user")
+	}
+	return user, nil
+}
+
+// AuthenticateUser returns the user whose username and password these are
+func (db *DB) AuthenticateUser(username, password string) (*User, error) {
+	var user User
+	var hash string
+	err := db.QueryRow(`
+		SELECT id, username, is_admin, created_at, password_hash
+		FROM users WHERE lower(username) = lower(?)
+	`, strings.TrimSpace(username)).Scan(&user.ID, &user.Username, &user.IsAdmin, &user.CreatedAt, &hash)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidLogin
+	}
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to get user")
+	}
+	if !checkPassword(hash, password) {
+		return nil, ErrInvalidLogin
+	}
+	return &user, nil
+}
+
+// GetUser returns a user by ID, or nil if there is none
+func (db *DB) GetUser(id int) (*User, error) {
+	var user User
+	err := db.QueryRow("SELECT id, username, is_admin, created_at FROM users WHERE id = ?", id).
+		Scan(&user.ID, &user.Username, &user.IsAdmin, &user.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to get user")
+	}
+	return &user, nil
+}
+
+// ListUsers returns the accounts in the order they were created
+func (db *DB) ListUsers() ([]*User, error) {
+	rows, err := db.Query("SELECT id, username, is_admin, created_at FROM users ORDER BY id")
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to list users")
+	}
+	defer rows.Close()
+
+	users := make([]*User, 0)
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(&user.ID, &user.Username, &user.IsAdmin, &user.CreatedAt); err != nil {
+			return nil, serr.Wrap(err, "failed to scan user row")
+		}
+		users = append(users, &user)
+	}
+	return users, rows.Err()
+}
+
+// DeleteUser removes an account, signs it out and revokes its API tokens. Its sessions
+// are kept, and are left to the admins like sessions without an owner.
+func (db *DB) DeleteUser(id int) error {
+	if _, err := db.Exec("DELETE FROM user_logins WHERE user_id = ?", id); err != nil {
+		return serr.Wrap(err, "failed to delete user logins")
+	}
+	if _, err := db.Exec("DELETE FROM api_tokens WHERE user_id = ?", id); err != nil {
+		return serr.Wrap(err, "failed to delete user API tokens")
+	}
+	for _, table := range []string{"sessions", "task_plans", "tool_permissions", "project_recaps"} {
+		if _, err := db.Exec("UPDATE "+table+" SET user_id = NULL WHERE user_id = ?", id); err != nil {
+			return serr.Wrap(err, "failed to release the user's "+table)
+		}
+	}
+	result, err := db.Exec("DELETE FROM users WHERE id = ?", id)
+	if err != nil {
+		return serr.Wrap(err, "failed to delete user")
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return serr.New(fmt.Sprintf("user %d not found", id))
+	}
+	return nil
+}
+
+// CreateLogin signs a user in, returning the token the browser keeps
+func (db *DB) CreateLogin(userID int, duration time.Duration) (string, time.Time, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", time.Time{}, serr.Wrap(err, "failed to generate login token")
+	}
+	token := hex.EncodeToString(raw)
+	expiresAt := time.Now().Add(duration)
+
+	// Expired logins are cleared out as new ones are made
+	if _, err := db.Exec("DELETE FROM user_logins WHERE expires_at < ?", time.Now()); err != nil {
+		return "", time.Time{}, serr.Wrap(err, "failed to delete expired logins")
+	}
+	if _, err := db.Exec("INSERT INTO user_logins (token_hash, user_id, expires_at) VALUES (?, ?, ?)",
+		hashToken(token), userID, expiresAt); err != nil {
+		return "", time.Time{}, serr.Wrap(err, "failed to create login")
+	}
+	return token, expiresAt, nil
+}
+
+// GetLoginUser returns the user signed in with a token, or nil if the token is unknown or expired
+func (db *DB) GetLoginUser(token string) (*User, error) {
+	var user User
+	err := db.QueryRow(`
+		SELECT u.id, u.username, u.is_admin, u.created_at
+		FROM user_logins l JOIN users u ON u.id = l.user_id
+		WHERE l.token_hash = ? AND l.expires_at > ?
+	`, hashToken(token), time.Now()).Scan(&user.ID, &user.Username, &user.IsAdmin, &user.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to get login")
+	}
+	return &user, nil
+}
+
+// DeleteLogin signs out the browser holding a token
+func (db *DB) DeleteLogin(token string) error {
+	if _, err := db.Exec("DELETE FROM user_logins WHERE token_hash = ?", hashToken(token)); err != nil {
+		return serr.Wrap(err, "failed to delete login")
+	}
+	return nil
+}
+
+// SessionOwner returns the ID of the user who owns a session, or 0 if it has no owner
+func (db *DB) SessionOwner(sessionID string) (int, error) {
+	return db.owner("SELECT user_id FROM sessions WHERE id = ?", sessionID)
+}
+
+// PlanOwner returns the ID of the user who owns a task plan, or 0 if it has no owner
+func (db *DB) PlanOwner(planID string) (int, error) {
+	return db.owner("SELECT user_id FROM task_plans WHERE id = ?", planID)
+}
+
+// DiffOwner returns the ID of the user who owns the session a diff was made in, or 0 if it has no owner
+func (db *DB) DiffOwner(diffID int64) (int, error) {
+	return db.owner("SELECT s.user_id FROM diffs d JOIN sessions s ON s.id = d.session_id WHERE d.id = ?", diffID)
+}
+
+// RecapOwner returns the ID of the user who owns the session a recap was written for, or 0 if it has no owner
+func (db *DB) RecapOwner(recapID int) (int, error) {
+	return db.owner("SELECT user_id FROM project_recaps WHERE id = ?", recapID)
+}
+
+func (db *DB) owner(query string, id any) (int, error) {
+	var userID sql.NullInt64
+	err := db.QueryRow(query, id).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, serr.New(fmt.Sprintf("%v not found", id))
+	}
+	if err != nil {
+		return 0, serr.Wrap(err, "failed to get owner")
+	}
+	return int(userID.Int64), nil
+}
+
+// hashPassword returns a salted PBKDF2 hash as "pbkdf2-sha256$iterations$salt$key"
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", serr.Wrap(err, "failed to generate salt")
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordIterations, 32)
+	if err != nil {
+		return "", serr.Wrap(err, "failed to hash password")
+	}
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", passwordIterations, hex.EncodeToString(salt), hex.EncodeToString(key)), nil
+}
+
+// checkPassword reports whether a password matches a hash from hashPassword
+func checkPassword(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return false
+	}
+	salt, err1 := hex.DecodeString(parts[2])
+	want, err2 := hex.DecodeString(parts[3])
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(want))
+	return err == nil && subtle.ConstantTimeCompare(key, want) == 1
+}
+
+// hashToken returns the hash a login token is stored under, so a copy of the
+// database doesn't hold tokens that would sign someone in
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,26 @@
+package db
+
+import "testing"
+
+func TestCanAccess(t *testing.T) {
+	user := &User{ID: 2}
+	admin := &User{ID: 1, IsAdmin: true}
+	tests := []struct {
+		name    string
+		user    *User
+		ownerID int
+		want    bool
+	}{
+		{"own data", user, 2, true},
+		{"another's data", user, 3, false},
+		{"unowned data", user, 0, false},
+		{"admin, unowned data", admin, 0, true},
+		{"admin, own data", admin, 1, true},
+		{"admin, another's data", admin, 2, false},
+	}
+	for _, tt := range tests {
+		if got := tt.user.CanAccess(tt.ownerID); got != tt.want {
+			t.Errorf("%s: CanAccess(%d) = %v, want %v", tt.name, tt.ownerID, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FormatUnified renders diff hunks as a unified diff, as produced by `diff -u`.
+// fromLabel and toLabel name the before and after sides in the file header.
+func FormatUnified(hunks []DiffHunk, fromLabel, toLabel string) string {
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("--- %s\n", fromLabel))
+	sb.WriteString(fmt.Sprintf("+++ %s\n", toLabel))
+
+	for _, hunk := range hunks {
+		sb.WriteString(fmt.Sprintf("@@ -%s +%s @@\n",
+			unifiedRange(hunk.OldStart, hunk.OldLines),
+			unifiedRange(hunk.NewStart, hunk.NewLines)))
+
+		for _, line := range hunk.Lines {
+			switch line.Type {
+			case "add":
+				sb.WriteString("+")
+			case "delete":
+				sb.WriteString("-")
+			default:
+				sb.WriteString(" ")
+			}
+			sb.WriteString(line.Content)
+			sb.WriteString("\n")
+		}
+	}
+
+	return sb.String()
+}
+
+// unifiedRange formats a hunk range, omitting the line count when it is 1
+func unifiedRange(start, lines int) string {
+	if lines == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, lines)
+}
@@ -0,0 +1,116 @@
+package embeddings
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// Texts sent per embeddings request
+const apiBatchSize = 32
+
+// APIEmbedder calls an OpenAI-compatible /embeddings endpoint. This covers hosted
+// providers as well as local model servers such as Ollama.
+type APIEmbedder struct {
+	url        string
+	model      string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// NewAPIEmbedder creates an embedder for the endpoint at url
+func NewAPIEmbedder(url, model, apiKey string) *APIEmbedder {
+	return &APIEmbedder{
+		url:        url,
+		model:      model,
+		apiKey:     apiKey,
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// Model identifies the endpoint's model
+func (e *APIEmbedder) Model() string {
+	return "api:" + e.model
+}
+
+type embeddingsRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type embeddingsResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// Embed requests embeddings in batches
+func (e *APIEmbedder) Embed(texts []string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += apiBatchSize {
+		end := start + apiBatchSize
+		if end > len(texts) {
+			end = len(texts)
+		}
+
+		batch, err := e.embedBatch(texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+func (e *APIEmbedder) embedBatch(texts []string) ([][]float32, error) {
+	body, err := json.Marshal(embeddingsRequest{Model: e.model, Input: texts})
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to marshal embeddings request")
+	}
+
+	req, err := http.NewRequest("POST", e.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to create embeddings request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.apiKey)
+	}
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, serr.Wrap(err, "embeddings request failed")
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to read embeddings response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, serr.New(fmt.Sprintf("embeddings endpoint returned %d: %s", resp.StatusCode, string(respBody)))
+	}
+
+	var parsed embeddingsResponse
+	if err := json.Unmarshal(respBody, &parsed); err != nil {
+		return nil, serr.Wrap(err, "failed to parse embeddings response")
+	}
+	if len(parsed.Data) != len(texts) {
+		return nil, serr.New(fmt.Sprintf("embeddings endpoint returned %d vectors for %d inputs", len(parsed.Data), len(texts)))
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, d := range parsed.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			return nil, serr.New(fmt.Sprintf("embeddings response has out of range index %d", d.Index))
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	return vectors, nil
+}
@@ -0,0 +1,40 @@
+package embeddings
+
+import (
+	"strings"
+)
+
+const (
+	chunkLines   = 60 // Lines per chunk
+	chunkOverlap = 10 // Lines shared with the previous chunk, so code at a boundary stays whole in one chunk
+)
+
+// Chunk is a span of lines from a file, numbered from 1
+type Chunk struct {
+	StartLine int
+	EndLine   int
+	Content   string
+}
+
+// ChunkFile splits file content into overlapping chunks of lines, skipping blank chunks
+func ChunkFile(content string) []Chunk {
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+
+	chunks := make([]Chunk, 0, len(lines)/(chunkLines-chunkOverlap)+1)
+	for start := 0; start < len(lines); start += chunkLines - chunkOverlap {
+		end := start + chunkLines
+		if end > len(lines) {
+			end = len(lines)
+		}
+
+		text := strings.Join(lines[start:end], "\n")
+		if strings.TrimSpace(text) != "" {
+			chunks = append(chunks, Chunk{StartLine: start + 1, EndLine: end, Content: text})
+		}
+
+		if end == len(lines) {
+			break
+		}
+	}
+	return chunks
+}
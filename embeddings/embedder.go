@@ -0,0 +1,40 @@
+package embeddings
+
+import (
+	"rcode/config"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// Embedder turns text into vectors whose cosine similarity reflects semantic similarity
+type Embedder interface {
+	// Embed returns one vector per input text, in order
+	Embed(texts []string) ([][]float32, error)
+	// Model identifies the embedding space, so vectors from different models are never compared
+	Model() string
+}
+
+// NewEmbedder creates the embedder selected by the configuration, or returns nil when
+// embeddings are off, as they are unless a provider is chosen
+func NewEmbedder(cfg *config.Config) (Embedder, error) {
+	switch cfg.EmbeddingsProvider {
+	case "", "none":
+		return nil, nil
+	case "hash", "local": // local is its former name
+		return NewHashEmbedder(), nil
+	case "api":
+		if cfg.EmbeddingsModel == "" {
+			return nil, serr.New("RCODE_EMBEDDINGS_MODEL is required for the api embeddings provider")
+		}
+		return NewAPIEmbedder(cfg.EmbeddingsURL, cfg.EmbeddingsModel, cfg.EmbeddingsAPIKey), nil
+	default:
+		return nil, serr.New("unknown embeddings provider: " + cfg.EmbeddingsProvider)
+	}
+}
+
+// Semantic reports whether the embedder's vectors reflect meaning, as a model's do, rather
+// than shared words, as HashEmbedder's do. Only semantic embedders rank files for a task.
+func Semantic(embedder Embedder) bool {
+	_, hashed := embedder.(*HashEmbedder)
+	return !hashed
+}
@@ -0,0 +1,119 @@
+package embeddings
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+const hashDimensions = 512
+
+// HashEmbedder embeds text in-process by hashing identifier tokens into a fixed
+// number of dimensions: a bag of words. It needs no model download or network access,
+// but it captures shared vocabulary (including the parts of camelCase and snake_case
+// names), not meaning, so it isn't semantic; see Semantic.
+type HashEmbedder struct {
+	dimensions int
+}
+
+// NewHashEmbedder creates a hashing embedder
+func NewHashEmbedder() *HashEmbedder {
+	return &HashEmbedder{dimensions: hashDimensions}
+}
+
+// Model identifies the hashing scheme. It keeps the name from when this was the
+// "local" provider, so files indexed then needn't be embedded again.
+func (e *HashEmbedder) Model() string {
+	return fmt.Sprintf("local-hash-%d", e.dimensions)
+}
+
+// Embed hashes each text into a normalized term-frequency vector
+func (e *HashEmbedder) Embed(texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = e.embed(text)
+	}
+	return vectors, nil
+}
+
+func (e *HashEmbedder) embed(text string) []float32 {
+	counts := make(map[string]int)
+	for _, token := range tokenize(text) {
+		counts[token]++
+	}
+
+	vector := make([]float32, e.dimensions)
+	for token, count := range counts {
+		h := fnv.New32a()
+		h.Write([]byte(token))
+		sum := h.Sum32()
+
+		// The hash's top bit picks the sign so colliding tokens tend to cancel out
+		weight := float32(1 + math.Log(float64(count)))
+		if sum&(1<<31) != 0 {
+			weight = -weight
+		}
+		vector[sum%uint32(e.dimensions)] += weight
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	if norm > 0 {
+		scale := float32(1 / math.Sqrt(norm))
+		for i := range vector {
+			vector[i] *= scale
+		}
+	}
+
+	return vector
+}
+
+// tokenize splits text into lowercase words, breaking identifiers at case changes,
+// underscores and digits. Whole identifiers are kept alongside their parts.
+func tokenize(text string) []string {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+
+	tokens := make([]string, 0, len(words)*2)
+	for _, word := range words {
+		parts := splitIdentifier(word)
+		if len(parts) > 1 {
+			tokens = append(tokens, strings.ToLower(strings.Trim(word, "_")))
+		}
+		for _, part := range parts {
+			if len(part) > 1 {
+				tokens = append(tokens, strings.ToLower(part))
+			}
+		}
+	}
+	return tokens
+}
+
+// splitIdentifier breaks fooBarBaz, foo_bar and HTTPServer into their words
+func splitIdentifier(word string) []string {
+	var parts []string
+	runes := []rune(word)
+	start := 0
+	for i := 1; i <= len(runes); i++ {
+		if i < len(runes) {
+			prev, cur := runes[i-1], runes[i]
+			boundary := cur == '_' || prev == '_' ||
+				(unicode.IsLower(prev) && unicode.IsUpper(cur)) ||
+				(unicode.IsDigit(prev) != unicode.IsDigit(cur)) ||
+				(i+1 < len(runes) && unicode.IsUpper(prev) && unicode.IsUpper(cur) && unicode.IsLower(runes[i+1]))
+			if !boundary {
+				continue
+			}
+		}
+		if part := strings.Trim(string(runes[start:i]), "_"); part != "" {
+			parts = append(parts, part)
+		}
+		start = i
+	}
+	return parts
+}
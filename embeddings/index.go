@@ -0,0 +1,223 @@
+package embeddings
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"rcode/db"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/serr"
+)
+
+const (
+	maxIndexFileSize = 512 * 1024       // Larger files are usually generated or data
+	refreshInterval  = 30 * time.Second // Minimum time between automatic refreshes
+	fileScoreChunks  = 50               // Chunks considered when scoring files for a task
+)
+
+// Store persists embedded chunks. It is implemented by *db.DB.
+type Store interface {
+	GetIndexedFileHashes(projectRoot, model string) (map[string]string, error)
+	ReplaceFileChunks(projectRoot, filePath, model string, chunks []db.CodeChunk) error
+	DeleteFileChunks(projectRoot, filePath string) error
+	SearchChunks(projectRoot, model string, vector []float32, limit int) ([]db.ChunkMatch, error)
+}
+
+// RefreshStats summarizes an index refresh
+type RefreshStats struct {
+	Indexed   int           `json:"indexed"`   // Files embedded because they were new or changed
+	Unchanged int           `json:"unchanged"` // Files already up to date
+	Removed   int           `json:"removed"`   // Files dropped from the index
+	Chunks    int           `json:"chunks"`    // Chunks embedded
+	Duration  time.Duration `json:"duration"`
+}
+
+// Index keeps embeddings of a project's files up to date and searches them
+type Index struct {
+	store       Store
+	embedder    Embedder
+	projectRoot string
+	files       func() []string // Lists the files to index
+
+	mu          sync.Mutex // Serializes refreshes
+	lastRefresh time.Time
+}
+
+// NewIndex creates an index of the files listed by files
+func NewIndex(store Store, embedder Embedder, projectRoot string, files func() []string) *Index {
+	return &Index{
+		store:       store,
+		embedder:    embedder,
+		projectRoot: projectRoot,
+		files:       files,
+	}
+}
+
+// Semantic reports whether the index's embedder captures meaning, see Semantic
+func (ix *Index) Semantic() bool {
+	return Semantic(ix.embedder)
+}
+
+// Refresh embeds new and changed files and drops deleted ones. Unchanged files,
+// identified by content hash, are not re-embedded.
+func (ix *Index) Refresh() (RefreshStats, error) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	return ix.refresh()
+}
+
+// RefreshIfStale refreshes the index unless it was refreshed recently
+func (ix *Index) RefreshIfStale() error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	if time.Since(ix.lastRefresh) < refreshInterval {
+		return nil
+	}
+	_, err := ix.refresh()
+	return err
+}
+
+func (ix *Index) refresh() (RefreshStats, error) {
+	start := time.Now()
+	var stats RefreshStats
+	model := ix.embedder.Model()
+
+	indexed, err := ix.store.GetIndexedFileHashes(ix.projectRoot, model)
+	if err != nil {
+		return stats, err
+	}
+
+	seen := make(map[string]bool)
+	for _, path := range ix.files() {
+		seen[path] = true
+
+		content, ok := readIndexableFile(path)
+		if !ok {
+			continue
+		}
+
+		sum := sha256.Sum256(content)
+		hash := hex.EncodeToString(sum[:])
+		if indexed[path] == hash {
+			stats.Unchanged++
+			continue
+		}
+
+		n, err := ix.indexFile(path, hash, string(content))
+		if err != nil {
+			logger.Warn("Failed to index file for semantic search", "path", path, "error", err.Error())
+			continue
+		}
+		stats.Indexed++
+		stats.Chunks += n
+	}
+
+	for path := range indexed {
+		if seen[path] {
+			continue
+		}
+		if err := ix.store.DeleteFileChunks(ix.projectRoot, path); err != nil {
+			return stats, err
+		}
+		stats.Removed++
+	}
+
+	ix.lastRefresh = time.Now()
+	stats.Duration = time.Since(start)
+	if stats.Indexed > 0 || stats.Removed > 0 {
+		logger.Info("Semantic index refreshed", "model", model, "indexed", stats.Indexed,
+			"removed", stats.Removed, "chunks", stats.Chunks, "duration", stats.Duration.String())
+	}
+	return stats, nil
+}
+
+// indexFile embeds a file's chunks and replaces its stored chunks
+func (ix *Index) indexFile(path, hash, content string) (int, error) {
+	chunks := ChunkFile(content)
+	if len(chunks) == 0 {
+		return 0, ix.store.ReplaceFileChunks(ix.projectRoot, path, ix.embedder.Model(), nil)
+	}
+
+	// Prefix each chunk with its path, since file names carry much of the meaning of code
+	rel := ix.relativePath(path)
+	texts := make([]string, len(chunks))
+	for i, c := range chunks {
+		texts[i] = rel + "\n" + c.Content
+	}
+
+	vectors, err := ix.embedder.Embed(texts)
+	if err != nil {
+		return 0, serr.Wrap(err, "failed to embed chunks")
+	}
+
+	dbChunks := make([]db.CodeChunk, len(chunks))
+	for i, c := range chunks {
+		dbChunks[i] = db.CodeChunk{
+			FileHash:  hash,
+			StartLine: c.StartLine,
+			EndLine:   c.EndLine,
+			Content:   c.Content,
+			Embedding: vectors[i],
+		}
+	}
+
+	if err := ix.store.ReplaceFileChunks(ix.projectRoot, path, ix.embedder.Model(), dbChunks); err != nil {
+		return 0, err
+	}
+	return len(chunks), nil
+}
+
+// Search returns the chunks most similar to the query
+func (ix *Index) Search(query string, limit int) ([]db.ChunkMatch, error) {
+	vectors, err := ix.embedder.Embed([]string{query})
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to embed query")
+	}
+	return ix.store.SearchChunks(ix.projectRoot, ix.embedder.Model(), vectors[0], limit)
+}
+
+// ScoreFiles rates files by the similarity of their best matching chunk to the task.
+// It only reads the index, so it is safe to call while the file list is locked.
+func (ix *Index) ScoreFiles(task string) (map[string]float64, error) {
+	matches, err := ix.Search(task, fileScoreChunks)
+	if err != nil {
+		return nil, err
+	}
+
+	scores := make(map[string]float64)
+	for _, m := range matches {
+		if m.Similarity > scores[m.FilePath] {
+			scores[m.FilePath] = m.Similarity
+		}
+	}
+	return scores, nil
+}
+
+// relativePath returns path relative to the project root where possible
+func (ix *Index) relativePath(path string) string {
+	if rel, err := filepath.Rel(ix.projectRoot, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+// readIndexableFile reads a file if it is small enough and looks like text
+func readIndexableFile(path string) ([]byte, bool) {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() || info.Size() > maxIndexFileSize {
+		return nil, false
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil || !utf8.Valid(content) {
+		return nil, false
+	}
+	return content, true
+}
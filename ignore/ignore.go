@@ -0,0 +1,298 @@
+// Package ignore decides which files in a project are ignored, following the rules of
+// .gitignore: negation, directory-only patterns, anchoring, ** and nested ignore files.
+package ignore
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// IgnoreFiles are read in every directory, in this order; later rules win
+var IgnoreFiles = []string{".gitignore", ".rcodeIgnore"}
+
+// rule is one pattern line of an ignore file
+type rule struct {
+	pattern string // As written, for reporting
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// Matcher matches paths under a root against default patterns, the repository's
+// .git/info/exclude, and the ignore files in the root and its subdirectories.
+// It is safe for concurrent use.
+type Matcher struct {
+	root     string
+	defaults []rule
+
+	mu    sync.RWMutex
+	rules map[string][]rule // Rules of each directory's ignore files, keyed by slash path relative to root
+}
+
+// NewMatcher creates a matcher for root. Defaults apply as though they came first in the
+// root's .gitignore, so the project can re-include them with negated patterns.
+func NewMatcher(root string, defaults ...string) *Matcher {
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+
+	m := &Matcher{root: root, rules: make(map[string][]rule)}
+	for _, line := range defaults {
+		if r, ok := parseRule(line, ""); ok {
+			m.defaults = append(m.defaults, r)
+		}
+	}
+	if lines, err := readLines(filepath.Join(root, ".git", "info", "exclude")); err == nil {
+		for _, line := range lines {
+			if r, ok := parseRule(line, ""); ok {
+				m.defaults = append(m.defaults, r)
+			}
+		}
+	}
+	return m
+}
+
+// ForPath returns a matcher rooted at the repository containing path: the nearest
+// directory at or above it holding .git, or path itself when there is none
+func ForPath(path string, defaults ...string) *Matcher {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return NewMatcher(path, defaults...)
+	}
+	if info, err := os.Stat(abs); err == nil && !info.IsDir() {
+		abs = filepath.Dir(abs)
+	}
+
+	for dir := abs; ; {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return NewMatcher(dir, defaults...)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return NewMatcher(abs, defaults...)
+		}
+		dir = parent
+	}
+}
+
+// Root returns the directory the matcher's patterns are relative to
+func (m *Matcher) Root() string {
+	return m.root
+}
+
+// Patterns returns the default patterns and those of the root's ignore files
+func (m *Matcher) Patterns() []string {
+	var patterns []string
+	for _, r := range append(append([]rule{}, m.defaults...), m.dirRules("")...) {
+		patterns = append(patterns, r.pattern)
+	}
+	return patterns
+}
+
+// Match reports whether path, absolute or relative to the root, is ignored.
+// A path inside an ignored directory is ignored too, as in git. Paths outside the root
+// are never ignored.
+func (m *Matcher) Match(path string, isDir bool) bool {
+	rel, ok := m.relative(path)
+	if !ok || rel == "" {
+		return false
+	}
+
+	parts := strings.Split(rel, "/")
+	for i := 1; i < len(parts); i++ {
+		if m.matchOne(parts[:i], true) {
+			return true
+		}
+	}
+	return m.matchOne(parts, isDir)
+}
+
+// MatchEntry is Match for callers that walk the tree themselves and skip ignored
+// directories: it checks only the path, not its parents
+func (m *Matcher) MatchEntry(path string, isDir bool) bool {
+	rel, ok := m.relative(path)
+	if !ok || rel == "" {
+		return false
+	}
+	return m.matchOne(strings.Split(rel, "/"), isDir)
+}
+
+// relative converts path to a slash path relative to the root
+func (m *Matcher) relative(path string) (string, bool) {
+	if filepath.IsAbs(path) {
+		rel, err := filepath.Rel(m.root, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", false
+		}
+		path = rel
+	}
+	path = filepath.ToSlash(filepath.Clean(path))
+	if path == "." {
+		return "", true
+	}
+	return path, true
+}
+
+// matchOne applies the rules that can see the path made of parts; the last matching rule wins.
+// Rules from deeper ignore files come later, so they take precedence.
+func (m *Matcher) matchOne(parts []string, isDir bool) bool {
+	if parts[len(parts)-1] == ".git" {
+		return true
+	}
+
+	ignored := false
+	apply := func(rules []rule, rel string) {
+		for _, r := range rules {
+			if r.dirOnly && !isDir {
+				continue
+			}
+			if r.re.MatchString(rel) {
+				ignored = !r.negate
+			}
+		}
+	}
+
+	rel := strings.Join(parts, "/")
+	apply(m.defaults, rel)
+	for i := 0; i < len(parts); i++ {
+		dir := strings.Join(parts[:i], "/")
+		apply(m.dirRules(dir), strings.Join(parts[i:], "/"))
+	}
+	return ignored
+}
+
+// dirRules returns the rules of the ignore files in dir, reading them the first time
+func (m *Matcher) dirRules(dir string) []rule {
+	m.mu.RLock()
+	rules, ok := m.rules[dir]
+	m.mu.RUnlock()
+	if ok {
+		return rules
+	}
+
+	for _, name := range IgnoreFiles {
+		lines, err := readLines(filepath.Join(m.root, filepath.FromSlash(dir), name))
+		if err != nil {
+			continue
+		}
+		for _, line := range lines {
+			if r, ok := parseRule(line, dir); ok {
+				rules = append(rules, r)
+			}
+		}
+	}
+
+	m.mu.Lock()
+	m.rules[dir] = rules
+	m.mu.Unlock()
+	return rules
+}
+
+// readLines returns the lines of a file
+func readLines(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+// parseRule parses one line of an ignore file. Comments and blank lines give no rule.
+func parseRule(line, dir string) (rule, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	// Trailing spaces are ignored unless escaped
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule{}, false
+	}
+
+	r := rule{pattern: line}
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return rule{}, false
+	}
+
+	// A slash at the start or middle anchors the pattern to the ignore file's directory;
+	// otherwise it matches a name at any depth
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	expr := globToRegexp(line)
+	if !anchored {
+		expr = "(?:.*/)?" + expr
+	}
+	re, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return rule{}, false
+	}
+	r.re = re
+	return r, true
+}
+
+// globToRegexp translates a gitignore glob. * and ? stay within a path segment,
+// a leading **/ matches any directories, /** at the end matches everything inside,
+// and /**/ matches zero or more directories.
+func globToRegexp(glob string) string {
+	var sb strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case c == '*' && strings.HasPrefix(glob[i:], "**") && (i == 0 || glob[i-1] == '/'):
+			rest := glob[i+2:]
+			switch {
+			case rest == "":
+				sb.WriteString(".*")
+			case strings.HasPrefix(rest, "/"):
+				sb.WriteString("(?:.*/)?")
+				i++ // The slash is part of the match
+			default:
+				sb.WriteString("[^/]*")
+			}
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			sb.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return sb.String()
+}
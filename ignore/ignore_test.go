@@ -0,0 +1,125 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		".gitignore":     "# build output\nbuild/\n*.me\n!keep.me\n**/*.log\n/top.txt\ndocs/*.md\n",
+		"sub/.gitignore": "local.txt\n!important.log\n/only-here.txt\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m := NewMatcher(root, "node_modules/", "!vendor/")
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		// Directory-only patterns
+		{"build", true, true},
+		{"build", false, false},
+		{"src/build", true, true},
+		{"build/out.bin", false, true},
+
+		// Negation
+		{"drop.me", false, true},
+		{"keep.me", false, false},
+		{"sub/keep.me", false, false},
+
+		// ** matches any directories, or none
+		{"a.log", false, true},
+		{"sub/deep/b.log", false, true},
+
+		// A leading or middle slash anchors the pattern to the ignore file's directory
+		{"top.txt", false, true},
+		{"sub/top.txt", false, false},
+		{"docs/a.md", false, true},
+		{"docs/x/a.md", false, false},
+		{"sub/docs/a.md", false, false},
+
+		// Nested ignore files apply below their directory, and win over the root's
+		{"sub/local.txt", false, true},
+		{"sub/deep/local.txt", false, true},
+		{"local.txt", false, false},
+		{"sub/important.log", false, false},
+		{"important.log", false, true},
+		{"sub/only-here.txt", false, true},
+		{"sub/deep/only-here.txt", false, false},
+
+		// Defaults, and what is always or never ignored
+		{"web/node_modules", true, true},
+		{"vendor", true, false},
+		{".git", true, true},
+		{"main.go", false, false},
+		{filepath.Join(root, "sub", "local.txt"), false, true},
+		{filepath.Join(filepath.Dir(root), "build"), true, false},
+	}
+	for _, tt := range tests {
+		if got := m.Match(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("Match(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestMatchEntry(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, ".gitignore"), []byte("build/\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m := NewMatcher(root)
+	if !m.MatchEntry("build", true) {
+		t.Error("MatchEntry(build) = false, want true")
+	}
+	// Walkers skip an ignored directory, so its entries are checked on their own
+	if m.MatchEntry("build/out.bin", false) {
+		t.Error("MatchEntry(build/out.bin) = true, want false")
+	}
+}
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		line  string
+		ok    bool
+		match string
+		want  bool
+	}{
+		{line: "", ok: false},
+		{line: "# comment", ok: false},
+		{line: "/", ok: false},
+		{line: `\#notes`, ok: true, match: "#notes", want: true},
+		{line: `\!bang`, ok: true, match: "!bang", want: true},
+		{line: "trailing.txt   ", ok: true, match: "trailing.txt", want: true},
+		{line: "file?.go", ok: true, match: "file1.go", want: true},
+		{line: "file?.go", ok: true, match: "file10.go", want: false},
+		{line: "[!a]bc", ok: true, match: "xbc", want: true},
+		{line: "[!a]bc", ok: true, match: "abc", want: false},
+		{line: "logs/**", ok: true, match: "logs/a/b.txt", want: true},
+		{line: "a/**/b", ok: true, match: "a/b", want: true},
+		{line: "a/**/b", ok: true, match: "a/x/y/b", want: true},
+		{line: "*.go", ok: true, match: "pkg/main.go", want: true},
+		{line: "*.go", ok: true, match: "pkg/main.go/x", want: false},
+	}
+	for _, tt := range tests {
+		r, ok := parseRule(tt.line, "")
+		if ok != tt.ok {
+			t.Errorf("parseRule(%q) ok = %v, want %v", tt.line, ok, tt.ok)
+			continue
+		}
+		if ok && r.re.MatchString(tt.match) != tt.want {
+			t.Errorf("parseRule(%q) matches %q = %v, want %v", tt.line, tt.match, !tt.want, tt.want)
+		}
+	}
+}
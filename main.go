@@ -1,13 +1,19 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"rcode/platform/logging"
 	"rcode/platform/shutdown"
+	"strings"
 	"time"
 
 	"rcode/config"
 	"rcode/db"
+	"rcode/tui"
 	"rcode/web"
 
 	"github.com/rohanthewiz/logger"
@@ -15,11 +21,61 @@ import (
 )
 
 func main() {
-	// Initialize configuration
+	// "rcode tui" is a terminal client for a running server
+	if len(os.Args) > 1 && os.Args[1] == "tui" {
+		os.Exit(tui.Main(os.Args[2:]))
+	}
+
+	// "rcode migrate" lists, applies or undoes the database's migrations
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+
+	// With -p, run one prompt without the web UI, for scripts and CI
+	prompt := flag.String("p", "", `Run a prompt without the web UI, print the response and exit ("-" reads it from stdin)`)
+	output := flag.String("output", "text", "Output of -p: text, or json for one JSON event per line")
+	model := flag.String("model", "", "Model for -p (defaults to the one in your settings)")
+	allowTools := flag.Bool("allow-tools", false, "With -p, run tools that would ask for permission instead of refusing them")
+	logLevel := flag.String("log-level", "", "Lowest level logged: debug, info, warn or error (defaults to debug, or warn with -p)")
+	logFormat := flag.String("log-format", "text", "Log format: text, or json for one JSON object per line")
+	flag.Parse()
+
+	headless := *prompt != ""
+	if headless {
+		if *output != "text" && *output != "json" {
+			fmt.Fprintf(os.Stderr, "Invalid --output %q; use text or json\n", *output)
+			os.Exit(web.ExitUsage)
+		}
+		if *prompt == "-" {
+			input, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to read the prompt from stdin: %v\n", err)
+				os.Exit(web.ExitUsage)
+			}
+			*prompt = strings.TrimSpace(string(input))
+			if *prompt == "" {
+				fmt.Fprintln(os.Stderr, "The prompt on stdin is empty")
+				os.Exit(web.ExitUsage)
+			}
+		}
+	}
+
+	// Initialize configuration, and reload it when the config files change
 	config.Initialize()
+	config.WatchFiles()
 	cfg := config.Get()
 
-	logger.SetLogLevel("debug")
+	// Headless runs keep stderr quiet, as their output is for scripts
+	if *logLevel == "" {
+		*logLevel = "debug"
+		if headless {
+			*logLevel = "warn"
+		}
+	}
+	if err := logging.Setup(*logLevel, *logFormat); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(web.ExitUsage)
+	}
 
 	// Log API endpoint configuration
 	if cfg.AnthropicAPIURL != "https://api.anthropic.com/v1/messages" {
@@ -36,6 +92,9 @@ func main() {
 	done := make(chan struct{}) // done channel will signal when shutdown complete
 	shutdown.InitShutdownService(done)
 
+	// Export spans of requests and turns when an OTLP endpoint is configured
+	web.InitTracing()
+
 	// Initialize database
 	database, err := db.GetDB()
 	if err != nil {
@@ -65,9 +124,30 @@ func main() {
 	}
 	logger.Info("File explorer initialized successfully")
 
+	// Apply project scan limits before anything scans the project
+	web.InitContextScanning()
+
 	// Initialize file change notifier for SSE broadcasts
 	web.InitFileChangeNotifier()
 
+	// Let the git_commit_message tool and the commit dialog ask the model for messages
+	web.InitCommitMessageModel()
+
+	// Let the artifact tools read and publish artifacts
+	web.InitArtifactStore()
+
+	// Let the todo tools keep each session's task list
+	web.InitTodoStore()
+
+	// Let the note tools keep each session's notes
+	web.InitNoteStore()
+
+	// Let the bench tool keep each session's benchmark runs
+	web.InitBenchStore()
+
+	// Let the security_scan tool file remediation plans
+	web.InitPlanFiler()
+
 	// Initialize diff service for diff visualization
 	web.InitDiffService()
 	logger.Info("Diff service initialized successfully")
@@ -76,6 +156,62 @@ func main() {
 	web.InitDiffBroadcaster()
 	logger.Info("Diff broadcaster initialized successfully")
 
+	// Seed built-in task templates
+	if err := web.InitTaskTemplates(); err != nil {
+		logger.LogErr(err, "Failed to seed built-in task templates")
+	}
+
+	// Close open terminals on shutdown so their recordings are stored
+	web.InitTerminals()
+
+	// Load the custom tool plugins, and reload them when the plugin directories change
+	web.InitPluginWatcher()
+
+	// Stop background processes started by the model on shutdown, and report their ports to the UI
+	web.InitBackgroundProcesses()
+
+	// Report sessions' model requests that wait for the API's rate limits to the UI
+	web.InitRequestScheduler()
+
+	// Mark replies that were being streamed when the server last stopped as interrupted
+	if err := web.RecoverInterruptedReplies(); err != nil {
+		logger.LogErr(err, "Failed to recover interrupted replies")
+	}
+
+	if headless {
+		code := web.RunHeadless(web.HeadlessOptions{
+			Prompt:     *prompt,
+			Model:      *model,
+			JSON:       *output == "json",
+			AllowTools: *allowTools,
+		}, os.Stdout)
+
+		// Run the shutdown hooks, which stop the run's background processes and close the database
+		shutdown.Shutdown()
+		<-done
+		os.Exit(code)
+	}
+
+	// Pause or resume plans interrupted by the last shutdown
+	if err := web.RecoverInterruptedPlans(); err != nil {
+		logger.LogErr(err, "Failed to recover interrupted plans")
+	}
+
+	// Embed new and changed project files for semantic search
+	web.InitSemanticIndex()
+
+	// Apply the tool audit log's retention settings
+	web.InitAuditRetention()
+
+	// Delete artifacts older than RCODE_ARTIFACT_RETENTION_DAYS
+	web.InitArtifactRetention()
+
+	// Back up the database and .rcode state on RCODE_BACKUP_HOURS' schedule
+	web.InitBackups()
+
+	// Refresh the Claude accounts' tokens before they expire
+	web.InitAccountRefresh()
+
 	go func() {
 		serverOpts := rweb.ServerOptions{
 			Address: ":8000",
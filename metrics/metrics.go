@@ -0,0 +1,218 @@
+// Package metrics keeps counters, histograms and gauges, and writes them in Prometheus'
+// text exposition format for the /metrics endpoint.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// metric is one family of series
+type metric interface {
+	write(w io.Writer)
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []metric
+)
+
+// register adds a metric to those Write writes
+func register(m metric) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, m)
+}
+
+// Write writes every registered metric in the text exposition format, in the order they
+// were created
+func Write(w io.Writer) {
+	registryMu.Lock()
+	metrics := append([]metric(nil), registry...)
+	registryMu.Unlock()
+
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+// desc is what every kind of metric has
+type desc struct {
+	name   string
+	help   string
+	labels []string
+}
+
+func (d desc) header(w io.Writer, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, d.help, d.name, kind)
+}
+
+// key joins label values into a map key
+func (d desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metric %s takes %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// labelPairs formats a series' labels, with extra pairs after them
+func (d desc) labelPairs(key string, extra ...string) string {
+	var pairs []string
+	if len(d.labels) > 0 {
+		for i, value := range strings.Split(key, "\xff") {
+			pairs = append(pairs, d.labels[i]+"="+strconv.Quote(value))
+		}
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+"="+strconv.Quote(extra[i+1]))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// sortedKeys returns a map's keys in order, so series are written in a stable order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// CounterVec is a counter for each combination of label values
+type CounterVec struct {
+	desc
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// NewCounterVec creates and registers a counter with the given labels
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{desc: desc{name, help, labels}, values: make(map[string]float64)}
+	register(c)
+	return c
+}
+
+// Inc adds one to the series with the label values
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v, which must not be negative, to the series with the label values
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	key := c.key(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key] += v
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.header(w, "counter")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.labelPairs(key), formatValue(c.values[key]))
+	}
+}
+
+// HistogramVec is a histogram for each combination of label values
+type HistogramVec struct {
+	desc
+	buckets []float64 // Upper bounds, ascending; +Inf is implied
+	mu      sync.Mutex
+	series  map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64 // Observations in each bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// DefaultBuckets suit durations in seconds, from 5ms to 10s
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// NewHistogramVec creates and registers a histogram with the given buckets and labels
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{
+		desc:    desc{name, help, labels},
+		buckets: append([]float64(nil), buckets...),
+		series:  make(map[string]*histogram),
+	}
+	sort.Float64s(h.buckets)
+	register(h)
+	return h
+}
+
+// Observe adds an observation to the series with the label values
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	key := h.key(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.series[key]
+	if s == nil {
+		s = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += v
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.header(w, "histogram")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(key, "le", formatValue(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(key, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labelPairs(key), formatValue(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labelPairs(key), s.count)
+	}
+}
+
+// GaugeFunc is a gauge whose value is read when the metrics are written
+type GaugeFunc struct {
+	desc
+	fn func() float64
+}
+
+// NewGaugeFunc creates and registers a gauge that calls fn for its value
+func NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	g := &GaugeFunc{desc: desc{name: name, help: help}, fn: fn}
+	register(g)
+	return g
+}
+
+func (g *GaugeFunc) write(w io.Writer) {
+	g.header(w, "gauge")
+	fmt.Fprintf(w, "%s %s\n", g.name, formatValue(g.fn()))
+}
+
+// formatValue formats a sample value as the exposition format expects
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,25 @@
+package metrics
+
+// The metrics RCode keeps. The SSE client gauge is registered by the web package, which
+// owns the hub.
+var (
+	HTTPRequests = NewCounterVec("rcode_http_requests_total",
+		"HTTP requests served, by method and status code.", "method", "code")
+	HTTPRequestDuration = NewHistogramVec("rcode_http_request_duration_seconds",
+		"Time to serve HTTP requests, by method.", DefaultBuckets, "method")
+
+	ModelRequests = NewCounterVec("rcode_model_requests_total",
+		"Requests to the model API, by model and status code (\"error\" when no reply came).", "model", "code")
+	StreamDuration = NewHistogramVec("rcode_model_stream_duration_seconds",
+		"Time from sending a streamed model request to the end of its reply, by model.",
+		[]float64{.5, 1, 2.5, 5, 10, 20, 30, 60, 120, 300}, "model")
+	Tokens = NewCounterVec("rcode_tokens_total",
+		"Tokens used, by model, direction (input or output) and source (session or proxy).", "model", "type", "source")
+
+	ToolDuration = NewHistogramVec("rcode_tool_duration_seconds",
+		"Time to execute tools the model called, by tool and status (success or error).",
+		[]float64{.005, .01, .05, .1, .5, 1, 5, 10, 30, 60, 300}, "tool", "status")
+
+	Plans = NewCounterVec("rcode_plans_total",
+		"Task plan executions finished, by status (completed, failed or cancelled).", "status")
+)
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"rcode/db"
+	"rcode/platform/logging"
+)
+
+const migrateUsage = `Usage: rcode migrate [status | up | down <version>]
+
+  status          List the migrations and whether each is applied (the default)
+  up              Apply the pending migrations, as rcode does when it starts
+  down <version>  Undo the applied migrations after version, latest first
+
+Stop rcode first: the database can only be open in one process.
+`
+
+// runMigrate is "rcode migrate", which manages the database's migrations
+func runMigrate(args []string) int {
+	flags := flag.NewFlagSet("rcode migrate", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, migrateUsage) }
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	args = flags.Args()
+
+	command := "status"
+	if len(args) > 0 {
+		command = args[0]
+	}
+	target := -1
+	switch {
+	case command == "down" && len(args) == 2:
+		version, err := strconv.Atoi(args[1])
+		if err != nil || version < 0 {
+			fmt.Fprintln(os.Stderr, "The version to go down to must be a number of 0 or more")
+			return 2
+		}
+		target = version
+	case (command == "status" || command == "up") && len(args) <= 1:
+	default:
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	_ = logging.Setup("info", "text")
+	database, err := db.Open()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error: "+err.Error())
+		return 1
+	}
+	defer database.Close()
+
+	switch command {
+	case "up":
+		err = database.Migrate()
+	case "down":
+		err = database.MigrateDown(target)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error: "+err.Error())
+		return 1
+	}
+
+	states, err := database.MigrationStatus()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error: "+err.Error())
+		return 1
+	}
+	out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(out, "VERSION\tAPPLIED\tDESCRIPTION")
+	for _, state := range states {
+		applied := "pending"
+		if state.AppliedAt != nil {
+			applied = state.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		description := state.Description
+		if !state.Reversible {
+			description += " (can't be undone)"
+		}
+		fmt.Fprintf(out, "%d\t%s\t%s\n", state.Version, applied, description)
+	}
+	out.Flush()
+	return 0
+}
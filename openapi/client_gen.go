@@ -0,0 +1,316 @@
+package openapi
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// GenerateClient writes Go source for a client of the spec's operations: a type for each
+// component schema and a method on Client for each operation. The package must provide
+// Client, with a do method that sends a request and decodes its JSON reply, and pathEscape.
+func GenerateClient(spec *Spec, pkg, generator string) ([]byte, error) {
+	g := &clientGen{spec: spec}
+
+	names := make([]string, 0, len(spec.Components.Schemas))
+	for name := range spec.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		g.writeType(name, spec.Components.Schemas[name])
+	}
+
+	for _, op := range spec.operations() {
+		g.writeOperation(op.path, op.method, op.Operation)
+	}
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by %s; DO NOT EDIT.\n\npackage %s\n\nimport (\n", generator, pkg)
+	body := g.b.String()
+	for _, imp := range []struct{ name, use string }{
+		{"context", "context."},
+		{"fmt", "fmt."},
+		{"net/url", "url."},
+		{"time", "time."},
+	} {
+		if strings.Contains(body, imp.use) {
+			fmt.Fprintf(&src, "\t%q\n", imp.name)
+		}
+	}
+	src.WriteString(")\n")
+	src.WriteString(body)
+
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		return nil, serr.Wrap(err, "generated client doesn't compile")
+	}
+	return formatted, nil
+}
+
+type specOperation struct {
+	*Operation
+	path   string
+	method string
+}
+
+// operations returns the spec's operations, sorted by ID
+func (s *Spec) operations() []specOperation {
+	var ops []specOperation
+	for p, methods := range s.Paths {
+		for method, op := range methods {
+			ops = append(ops, specOperation{Operation: op, path: p, method: strings.ToUpper(method)})
+		}
+	}
+	sort.Slice(ops, func(i, j int) bool { return ops[i].OperationID < ops[j].OperationID })
+	return ops
+}
+
+type clientGen struct {
+	spec *Spec
+	b    strings.Builder
+}
+
+func (g *clientGen) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.b, format, args...)
+}
+
+func (g *clientGen) writeType(name string, schema *Schema) {
+	g.printf("\n// %s is the %s schema of the API\n", name, name)
+	g.printf("type %s %s\n", name, g.goType(schema, false))
+}
+
+// goType returns the Go type of a schema. Fields use pointers for structs and nullable
+// values, so they can be left out.
+func (g *clientGen) goType(schema *Schema, field bool) string {
+	if ref := schema.RefName(); ref != "" {
+		if field {
+			return "*" + ref
+		}
+		return ref
+	}
+
+	var t string
+	switch schema.Type {
+	case "string":
+		switch schema.Format {
+		case "date-time":
+			t = "time.Time"
+		case "byte":
+			t = "[]byte"
+		default:
+			t = "string"
+		}
+	case "integer":
+		t = "int"
+		if schema.Format == "int64" {
+			t = "int64"
+		}
+	case "number":
+		t = "float64"
+	case "boolean":
+		t = "bool"
+	case "array":
+		return "[]" + g.goType(schema.Items, false)
+	case "object":
+		if len(schema.Properties) > 0 {
+			return g.structType(schema)
+		}
+		elem := &Schema{}
+		if schema.AdditionalProperties != nil {
+			elem = schema.AdditionalProperties
+		}
+		return "map[string]" + g.goType(elem, false)
+	default:
+		return "interface{}"
+	}
+
+	if field && schema.Nullable {
+		return "*" + t
+	}
+	return t
+}
+
+func (g *clientGen) structType(schema *Schema) string {
+	props := make([]string, 0, len(schema.Properties))
+	for name := range schema.Properties {
+		props = append(props, name)
+	}
+	sort.Strings(props)
+
+	var b strings.Builder
+	b.WriteString("struct {\n")
+	for _, name := range props {
+		t := g.goType(schema.Properties[name], true)
+		tag := name
+		if strings.HasPrefix(t, "*") || strings.HasPrefix(t, "[]") || strings.HasPrefix(t, "map[") || t == "interface{}" {
+			tag += ",omitempty"
+		}
+		fmt.Fprintf(&b, "\t%s %s `json:%q`\n", exportedName(name), t, tag)
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+func (g *clientGen) writeOperation(specPath, method string, op *Operation) {
+	name := op.OperationID
+
+	args := []string{"ctx context.Context"}
+	var query []Parameter
+	for _, param := range op.Parameters {
+		if param.In == "path" {
+			args = append(args, paramName(param.Name)+" string")
+		} else {
+			query = append(query, param)
+		}
+	}
+
+	if len(query) > 0 {
+		g.printf("\n// %sParams are the query parameters of %s\n", name, name)
+		g.printf("type %sParams struct {\n", name)
+		for _, param := range query {
+			if param.Description != "" {
+				g.printf("\t// %s\n", param.Description)
+			}
+			g.printf("\t%s %s\n", exportedName(param.Name), g.goType(param.Schema, false))
+		}
+		g.printf("}\n")
+		args = append(args, "params *"+name+"Params")
+	}
+
+	bodyArg := "nil"
+	if op.RequestBody != nil {
+		args = append(args, "body "+g.goType(op.RequestBody.Content["application/json"].Schema, false))
+		bodyArg = "body"
+	}
+
+	// The path, with its parameters escaped
+	var pathExpr []string
+	for _, segment := range strings.Split(specPath, "/") {
+		if strings.HasPrefix(segment, "{") {
+			pathExpr = append(pathExpr, `"/"`, "pathEscape("+paramName(strings.Trim(segment, "{}"))+")")
+		} else if segment != "" {
+			pathExpr = append(pathExpr, fmt.Sprintf("%q", "/"+segment))
+		}
+	}
+	pathCode := strings.ReplaceAll(strings.Join(pathExpr, " + "), `" + "`, "")
+
+	if op.Summary != "" {
+		g.printf("\n// %s %s\n", name, lowerFirst(op.Summary))
+	} else {
+		g.printf("\n")
+	}
+
+	var out *Schema
+	if resp := op.Responses["200"]; resp != nil && resp.Content["application/json"].Schema != nil {
+		out = resp.Content["application/json"].Schema
+	}
+	if out == nil {
+		g.printf("func (c *Client) %s(%s) error {\n", name, strings.Join(args, ", "))
+	} else {
+		result := g.goType(out, false)
+		if out.Ref != "" {
+			result = "*" + result
+		}
+		g.printf("func (c *Client) %s(%s) (%s, error) {\n", name, strings.Join(args, ", "), result)
+	}
+
+	queryArg := "nil"
+	if len(query) > 0 {
+		queryArg = "query"
+		g.printf("\tquery := url.Values{}\n\tif params != nil {\n")
+		for _, param := range query {
+			field := "params." + exportedName(param.Name)
+			value := "fmt.Sprint(" + field + ")"
+			switch g.goType(param.Schema, false) {
+			case "bool":
+				g.printf("\t\tif %s {\n", field)
+			case "string":
+				g.printf("\t\tif %s != \"\" {\n", field)
+				value = field
+			default:
+				g.printf("\t\tif %s != 0 {\n", field)
+			}
+			g.printf("\t\t\tquery.Set(%q, %s)\n\t\t}\n", param.Name, value)
+		}
+		g.printf("\t}\n")
+	}
+
+	call := fmt.Sprintf("c.do(ctx, %q, %s, %s, %s", method, pathCode, queryArg, bodyArg)
+	switch {
+	case out == nil:
+		g.printf("\treturn %s, nil)\n}\n", call)
+	case out.Ref != "":
+		g.printf("\tvar out %s\n", g.goType(out, false))
+		g.printf("\tif err := %s, &out); err != nil {\n\t\treturn nil, err\n\t}\n", call)
+		g.printf("\treturn &out, nil\n}\n")
+	default:
+		g.printf("\tvar out %s\n", g.goType(out, false))
+		g.printf("\terr := %s, &out)\n", call)
+		g.printf("\treturn out, err\n}\n")
+	}
+}
+
+// initialisms are written in capitals in Go names
+var initialisms = map[string]bool{"id": true, "url": true, "api": true, "http": true, "json": true, "llm": true, "uri": true}
+
+// words splits a JSON name such as "plan_id" or "sessionId" into its words
+func words(name string) []string {
+	var result []string
+	var word []rune
+	for _, r := range name {
+		switch {
+		case r == '_' || r == '-' || r == ' ':
+			if len(word) > 0 {
+				result = append(result, string(word))
+			}
+			word = nil
+		case unicode.IsUpper(r) && len(word) > 0 && !unicode.IsUpper(word[len(word)-1]):
+			result = append(result, string(word))
+			word = []rune{r}
+		default:
+			word = append(word, r)
+		}
+	}
+	if len(word) > 0 {
+		result = append(result, string(word))
+	}
+	return result
+}
+
+// exportedName turns a JSON name into an exported Go name, e.g. "plan_id" into PlanID
+func exportedName(name string) string {
+	var b strings.Builder
+	for _, word := range words(name) {
+		lower := strings.ToLower(word)
+		if initialisms[lower] {
+			b.WriteString(strings.ToUpper(word))
+		} else if initialisms[strings.TrimSuffix(lower, "s")] {
+			b.WriteString(strings.ToUpper(word[:len(word)-1]) + "s") // e.g. IDs
+		} else {
+			b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	return b.String()
+}
+
+// paramName turns a parameter name into a Go identifier, e.g. "stepId" into stepID
+func paramName(name string) string {
+	ws := words(name)
+	if len(ws) == 0 {
+		return "param"
+	}
+	return strings.ToLower(ws[0]) + exportedName(strings.Join(ws[1:], "_"))
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
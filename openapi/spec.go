@@ -0,0 +1,286 @@
+// Package openapi builds an OpenAPI 3 description of the server's API from its routes and
+// the Go types they read and write, and generates a Go client from that description.
+package openapi
+
+import (
+	"encoding/json"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Spec is an OpenAPI 3.0 document
+type Spec struct {
+	OpenAPI    string                           `json:"openapi" yaml:"openapi"`
+	Info       Info                             `json:"info" yaml:"info"`
+	Tags       []Tag                            `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Security   []map[string][]string            `json:"security,omitempty" yaml:"security,omitempty"`
+	Paths      map[string]map[string]*Operation `json:"paths" yaml:"paths"`
+	Components Components                       `json:"components" yaml:"components"`
+
+	types map[reflect.Type]string // Component name of each struct type seen
+}
+
+type Info struct {
+	Title       string `json:"title" yaml:"title"`
+	Version     string `json:"version" yaml:"version"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+type Tag struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas" yaml:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty" yaml:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type        string `json:"type" yaml:"type"`
+	Scheme      string `json:"scheme,omitempty" yaml:"scheme,omitempty"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// Operation is one method of a path
+type Operation struct {
+	OperationID string               `json:"operationId" yaml:"operationId"`
+	Summary     string               `json:"summary,omitempty" yaml:"summary,omitempty"`
+	Tags        []string             `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Parameters  []Parameter          `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty" yaml:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses" yaml:"responses"`
+}
+
+// Parameter is a path or query parameter
+type Parameter struct {
+	Name        string  `json:"name" yaml:"name"`
+	In          string  `json:"in" yaml:"in"` // "path" or "query"
+	Description string  `json:"description,omitempty" yaml:"description,omitempty"`
+	Required    bool    `json:"required,omitempty" yaml:"required,omitempty"`
+	Schema      *Schema `json:"schema" yaml:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty" yaml:"required,omitempty"`
+	Content  map[string]MediaType `json:"content" yaml:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description" yaml:"description"`
+	Content     map[string]MediaType `json:"content,omitempty" yaml:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema" yaml:"schema"`
+}
+
+// Schema describes a JSON value. The empty schema allows any value.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty" yaml:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty" yaml:"type,omitempty"`
+	Format               string             `json:"format,omitempty" yaml:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty" yaml:"nullable,omitempty"`
+	Items                *Schema            `json:"items,omitempty" yaml:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty" yaml:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty" yaml:"additionalProperties,omitempty"`
+}
+
+// RefName returns the component a schema refers to, or "" if it isn't a reference
+func (s *Schema) RefName() string {
+	return strings.TrimPrefix(s.Ref, "#/components/schemas/")
+}
+
+// Route is an endpoint to describe
+type Route struct {
+	Method    string
+	Path      string // rweb syntax: ":name" for a parameter and "*" for the rest of the path
+	Tag       string
+	Operation string // The operationId, also the method name in the generated client
+	Summary   string
+	Query     []Parameter
+	Request   interface{} // A value of the JSON body's type, or nil for none
+	Response  interface{} // A value of the JSON reply's type, or nil for none
+}
+
+// New creates a spec with no paths yet
+func New(info Info) *Spec {
+	return &Spec{
+		OpenAPI: "3.0.3",
+		Info:    info,
+		Paths:   map[string]map[string]*Operation{},
+		Components: Components{
+			Schemas: map[string]*Schema{},
+		},
+		types: map[reflect.Type]string{},
+	}
+}
+
+// AddRoute describes a route, with schemas for its request and response types
+func (s *Spec) AddRoute(route Route) {
+	p, params := specPath(route.Path)
+	op := &Operation{
+		OperationID: route.Operation,
+		Summary:     route.Summary,
+		Parameters:  append(params, route.Query...),
+		Responses: map[string]*Response{
+			"default": {
+				Description: "The error, as text",
+				Content:     map[string]MediaType{"text/plain": {Schema: &Schema{Type: "string"}}},
+			},
+		},
+	}
+	if route.Tag != "" {
+		op.Tags = []string{route.Tag}
+	}
+
+	if route.Request != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{"application/json": {Schema: s.SchemaOf(route.Request)}},
+		}
+	}
+
+	ok := &Response{Description: "OK"}
+	if route.Response != nil {
+		ok.Content = map[string]MediaType{"application/json": {Schema: s.SchemaOf(route.Response)}}
+	}
+	op.Responses["200"] = ok
+
+	if s.Paths[p] == nil {
+		s.Paths[p] = map[string]*Operation{}
+	}
+	s.Paths[p][strings.ToLower(route.Method)] = op
+}
+
+// specPath turns an rweb route path into an OpenAPI path and its parameters
+func specPath(routePath string) (string, []Parameter) {
+	var params []Parameter
+	segments := strings.Split(routePath, "/")
+	for i, segment := range segments {
+		name := ""
+		switch {
+		case strings.HasPrefix(segment, ":"):
+			name = segment[1:]
+		case segment == "*":
+			name = "path"
+		default:
+			continue
+		}
+		segments[i] = "{" + name + "}"
+		params = append(params, Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+	}
+	return strings.Join(segments, "/"), params
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// SchemaOf returns the schema of a value's type, adding the structs it uses to the components
+func (s *Spec) SchemaOf(v interface{}) *Schema {
+	return s.schemaFor(reflect.TypeOf(v))
+}
+
+func (s *Spec) schemaFor(t reflect.Type) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case rawMessageType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := s.schemaFor(t.Elem())
+		if schema.Ref == "" {
+			schema.Nullable = true
+		}
+		return schema
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"} // encoding/json writes []byte as base64
+		}
+		return &Schema{Type: "array", Items: s.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.objectSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + s.component(t)}
+	}
+	return &Schema{} // Interfaces, and anything encoding/json decides at run time
+}
+
+// component adds a struct type to the components if it isn't there yet, and returns its name
+func (s *Spec) component(t reflect.Type) string {
+	if name, ok := s.types[t]; ok {
+		return name
+	}
+
+	name := t.Name()
+	if _, taken := s.Components.Schemas[name]; taken {
+		// Another package's type of the same name, e.g. planner.Session and db.Session
+		pkg := path.Base(t.PkgPath())
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+
+	// Named before the properties are built, as a type can contain itself
+	s.types[t] = name
+	s.Components.Schemas[name] = &Schema{}
+	*s.Components.Schemas[name] = *s.objectSchema(t)
+	return name
+}
+
+// objectSchema describes a struct's fields as encoding/json writes them
+func (s *Spec) objectSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	s.addFields(schema, t)
+	return schema
+}
+
+func (s *Spec) addFields(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		// Embedded structs without a name of their own add their fields to the parent's
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				s.addFields(schema, embedded)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		schema.Properties[name] = s.schemaFor(field.Type)
+	}
+}
@@ -0,0 +1,72 @@
+package planner
+
+import (
+	"fmt"
+)
+
+// ApprovalFunc asks a user whether a flagged step may run. It blocks until they answer.
+type ApprovalFunc func(task *TaskPlanner, step *TaskStep) (bool, error)
+
+// Tools whose steps require approval by default since their effects are hard to undo
+var approvalRequiredTools = map[string]bool{
+	"remove":   true,
+	"git_push": true,
+}
+
+// flagApprovalSteps marks steps using high-risk tools as requiring approval
+func flagApprovalSteps(steps []TaskStep) {
+	for i := range steps {
+		if approvalRequiredTools[steps[i].Tool] {
+			steps[i].RequiresApproval = true
+		}
+	}
+}
+
+// SetApprovalFunc sets the callback used to approve flagged steps during execution
+func (p *Planner) SetApprovalFunc(fn ApprovalFunc) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.approvalFunc = fn
+}
+
+// hasApprovalGates reports whether any step of the plan must be approved before running
+func hasApprovalGates(task *TaskPlanner) bool {
+	for _, step := range task.Steps {
+		if step.RequiresApproval {
+			return true
+		}
+	}
+	return false
+}
+
+// awaitApproval pauses the plan until the flagged step is approved or denied
+func (p *Planner) awaitApproval(task *TaskPlanner, step *TaskStep) bool {
+	p.mu.Lock()
+	approve := p.approvalFunc
+	task.Status = TaskStatusPaused
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		if task.Status == TaskStatusPaused {
+			task.Status = TaskStatusExecuting
+		}
+		p.mu.Unlock()
+	}()
+
+	if approve == nil {
+		p.logWarning(task.ID, step.ID, "Step requires approval but no approver is configured")
+		return false
+	}
+
+	p.logInfo(task.ID, step.ID, fmt.Sprintf("Waiting for approval to run %s", step.Tool))
+	approved, err := approve(task, step)
+	if err != nil {
+		p.logError(task.ID, step.ID, "Approval request failed", err.Error())
+		return false
+	}
+	if !approved {
+		p.logInfo(task.ID, step.ID, "Step denied by user")
+	}
+	return approved
+}
@@ -0,0 +1,129 @@
+package planner
+
+import (
+	"os"
+	"time"
+
+	"rcode/tools"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// How long a cancelled step's tool may take to stop before its changes are undone
+const stepStopGrace = 5 * time.Second
+
+// Tools whose partial effects can be undone by restoring the single file they target
+var fileRestorableTools = map[string]bool{
+	"write_file":       true,
+	"edit_file":        true,
+	"smart_edit":       true,
+	"replace_snippet":  true,
+	"resolve_conflict": true,
+}
+
+// stepUndo holds the state of the file a step targets, captured before the step runs
+type stepUndo struct {
+	path    string
+	content []byte
+	mode    os.FileMode
+	existed bool
+}
+
+// captureStepFile saves the file a step is about to modify, or returns nil if the
+// step's effects cannot be undone this way
+func (p *Planner) captureStepFile(step *TaskStep, taskCtx *TaskContext) *stepUndo {
+	if !fileRestorableTools[step.Tool] {
+		return nil
+	}
+
+	params := p.executor.prepareParams(step.Params, taskCtx)
+	path, _ := params["path"].(string)
+	if path == "" {
+		return nil
+	}
+
+	expanded, err := tools.ExpandPath(path)
+	if err != nil {
+		return nil
+	}
+
+	undo := &stepUndo{path: expanded}
+	info, err := os.Stat(expanded)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil
+		}
+		return undo
+	}
+
+	content, err := os.ReadFile(expanded)
+	if err != nil {
+		return nil
+	}
+	undo.content = content
+	undo.mode = info.Mode().Perm()
+	undo.existed = true
+	return undo
+}
+
+// restore puts the file back as it was before the step ran
+func (u *stepUndo) restore() error {
+	if !u.existed {
+		if err := os.Remove(u.path); err != nil && !os.IsNotExist(err) {
+			return serr.Wrap(err, "failed to remove file created by step")
+		}
+		return nil
+	}
+
+	if err := os.WriteFile(u.path, u.content, u.mode); err != nil {
+		return serr.Wrap(err, "failed to restore file")
+	}
+	return nil
+}
+
+// cancelStep marks a step interrupted by cancellation as failed and undoes its file changes
+func (p *Planner) cancelStep(task *TaskPlanner, step *TaskStep, undo *stepUndo) error {
+	endTime := time.Now()
+	step.EndTime = &endTime
+	step.Status = StepStatusFailed
+
+	retries := 0
+	if step.Result != nil {
+		retries = step.Result.Retries
+	}
+	step.Result = &StepResult{Success: false, Error: "step cancelled", Retries: retries}
+
+	if undo != nil {
+		if err := undo.restore(); err != nil {
+			p.logWarning(task.ID, step.ID, "Failed to roll back cancelled step: "+err.Error())
+		} else {
+			p.logInfo(task.ID, step.ID, "Rolled back changes to "+undo.path)
+		}
+	}
+
+	if p.metricsCollector != nil {
+		p.metricsCollector.EndStepExecution(task.ID, step.ID, false, serr.New("step cancelled"))
+	}
+
+	return serr.New("step cancelled")
+}
+
+// finishCancelled records the end of a plan stopped by CancelPlan
+func (p *Planner) finishCancelled(task *TaskPlanner) error {
+	p.mu.Lock()
+	task.Status = TaskStatusCancelled
+	endTime := time.Now()
+	task.EndTime = &endTime
+	p.mu.Unlock()
+
+	if p.metricsCollector != nil {
+		p.metricsCollector.EndPlanExecution(task.ID)
+	}
+
+	if err := p.saveProgress(task); err != nil {
+		p.logWarning(task.ID, "", "Failed to save final state: "+err.Error())
+	}
+
+	p.logInfo(task.ID, "", "Task execution stopped by cancellation")
+	return serr.New("plan cancelled")
+}
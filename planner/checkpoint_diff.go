@@ -0,0 +1,118 @@
+package planner
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"rcode/diff"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// Change status of a file relative to its checkpoint snapshot
+const (
+	FileChangeUnchanged = "unchanged"
+	FileChangeModified  = "modified"
+	FileChangeDeleted   = "deleted"
+)
+
+// CheckpointFileDiff compares a snapshotted file with its current content.
+// Path, before, after and stats follow the shape the UI diff viewer renders.
+type CheckpointFileDiff struct {
+	Path    string          `json:"path"`
+	Status  string          `json:"status"`
+	Before  string          `json:"before"` // Content at the checkpoint
+	After   string          `json:"after"`  // Current content
+	Hunks   []diff.DiffHunk `json:"hunks"`
+	Unified string          `json:"unified"`
+	Stats   CheckpointStats `json:"stats"`
+}
+
+// CheckpointStats counts the lines a rollback would change
+type CheckpointStats struct {
+	Additions int `json:"additions"`
+	Deletions int `json:"deletions"`
+}
+
+// CheckpointDiff lists every file snapshotted at a checkpoint with its changes since
+type CheckpointDiff struct {
+	PlanID       string               `json:"plan_id"`
+	CheckpointID string               `json:"checkpoint_id"`
+	Files        []CheckpointFileDiff `json:"files"`
+	ChangedFiles int                  `json:"changed_files"`
+	Stats        CheckpointStats      `json:"stats"`
+	GeneratedAt  time.Time            `json:"generated_at"`
+}
+
+// DiffCheckpoint compares the files snapshotted at a checkpoint with their current content
+func (sm *SnapshotManager) DiffCheckpoint(planID, checkpointID string) (*CheckpointDiff, error) {
+	snapshots, err := sm.store.GetSnapshots(checkpointID)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to get snapshots")
+	}
+
+	result := &CheckpointDiff{
+		PlanID:       planID,
+		CheckpointID: checkpointID,
+		Files:        make([]CheckpointFileDiff, 0, len(snapshots)),
+		GeneratedAt:  time.Now(),
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].FilePath < snapshots[j].FilePath
+	})
+
+	differ := diff.NewDiffService()
+	for _, snapshot := range snapshots {
+		if snapshot.PlanID != "" && snapshot.PlanID != planID {
+			continue
+		}
+
+		fileDiff := CheckpointFileDiff{
+			Path:   snapshot.FilePath,
+			Status: FileChangeModified,
+			Before: snapshot.Content,
+		}
+
+		current, err := os.ReadFile(snapshot.FilePath)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				return nil, serr.Wrap(err, fmt.Sprintf("failed to read %s", snapshot.FilePath))
+			}
+			fileDiff.Status = FileChangeDeleted
+		} else {
+			fileDiff.After = string(current)
+		}
+
+		preview, err := differ.GeneratePreview(fileDiff.Before, fileDiff.After, snapshot.FilePath)
+		if err != nil {
+			return nil, serr.Wrap(err, fmt.Sprintf("failed to diff %s", snapshot.FilePath))
+		}
+
+		fileDiff.Hunks = preview.Hunks
+		fileDiff.Stats = CheckpointStats{Additions: preview.Stats.Added, Deletions: preview.Stats.Deleted}
+		if fileDiff.Status != FileChangeDeleted && len(preview.Hunks) == 0 {
+			fileDiff.Status = FileChangeUnchanged
+		}
+		if fileDiff.Hunks == nil {
+			fileDiff.Hunks = make([]diff.DiffHunk, 0)
+		}
+
+		toLabel := "b/" + snapshot.FilePath + " (current)"
+		if fileDiff.Status == FileChangeDeleted {
+			toLabel = "/dev/null"
+		}
+		fileDiff.Unified = diff.FormatUnified(preview.Hunks, "a/"+snapshot.FilePath+" (checkpoint)", toLabel)
+
+		if fileDiff.Status != FileChangeUnchanged {
+			result.ChangedFiles++
+		}
+		result.Stats.Additions += fileDiff.Stats.Additions
+		result.Stats.Deletions += fileDiff.Stats.Deletions
+		result.Files = append(result.Files, fileDiff)
+	}
+
+	return result, nil
+}
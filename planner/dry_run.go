@@ -0,0 +1,331 @@
+package planner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// StepPreview describes what a single step would do if the plan were executed
+type StepPreview struct {
+	StepID        string                 `json:"step_id"`
+	Description   string                 `json:"description"`
+	Tool          string                 `json:"tool"`
+	Params        map[string]interface{} `json:"params"` // Parameters after variable substitution
+	Action        string                 `json:"action"` // Human readable summary
+	FilesRead     []string               `json:"files_read,omitempty"`
+	FilesWritten  []string               `json:"files_written,omitempty"`
+	FilesDeleted  []string               `json:"files_deleted,omitempty"`
+	Commands      []string               `json:"commands,omitempty"`
+	GitOperations []string               `json:"git_operations,omitempty"`
+	Destructive   bool                   `json:"destructive"`
+	Valid         bool                   `json:"valid"`
+	Error         string                 `json:"error,omitempty"`
+	Warnings      []string               `json:"warnings,omitempty"`
+}
+
+// DryRunReport is a preview of a whole plan, produced without running any tool
+type DryRunReport struct {
+	PlanID        string        `json:"plan_id"`
+	Description   string        `json:"description"`
+	Steps         []StepPreview `json:"steps"`
+	FilesWritten  []string      `json:"files_written"`
+	FilesDeleted  []string      `json:"files_deleted"`
+	Commands      []string      `json:"commands"`
+	GitOperations []string      `json:"git_operations"`
+	Destructive   bool          `json:"destructive"` // True if any step deletes data or rewrites remote history
+	Valid         bool          `json:"valid"`       // False if any step would fail validation
+	GeneratedAt   time.Time     `json:"generated_at"`
+}
+
+// Git operations that modify the repository or a remote
+var mutatingGitTools = map[string]bool{
+	"git_add": true, "git_commit": true, "git_push": true, "git_pull": true,
+	"git_checkout": true, "git_merge": true, "git_branch": true, "git_remote": true,
+	"git_fetch": true, "git_tag": true, "git_cherry_pick": true, "git_revert": true,
+}
+
+// DryRunPlan previews every step of a loaded plan without executing anything
+func (p *Planner) DryRunPlan(taskID string) (*DryRunReport, error) {
+	p.mu.RLock()
+	task, exists := p.tasks[taskID]
+	p.mu.RUnlock()
+
+	if !exists {
+		return nil, serr.New("task not found")
+	}
+
+	report := p.executor.PreviewPlan(task)
+	p.logInfo(taskID, "", fmt.Sprintf("Dry run previewed %d steps", len(report.Steps)))
+	return report, nil
+}
+
+// PreviewPlan walks the steps of a plan in order, resolving parameters and
+// describing the side effects of each tool call
+func (e *StepExecutor) PreviewPlan(task *TaskPlanner) *DryRunReport {
+	report := &DryRunReport{
+		PlanID:      task.ID,
+		Description: task.Description,
+		Steps:       make([]StepPreview, 0, len(task.Steps)),
+		Valid:       true,
+		GeneratedAt: time.Now(),
+	}
+
+	ctx := task.Context
+	if ctx == nil {
+		ctx = &TaskContext{Variables: make(map[string]interface{})}
+	}
+
+	written := make(map[string]bool)
+	deleted := make(map[string]bool)
+	invalid := make(map[string]bool)
+
+	for i := range task.Steps {
+		step := &task.Steps[i]
+		preview := e.PreviewStep(step, ctx)
+
+		for _, dep := range step.Dependencies {
+			if invalid[dep] {
+				preview.Warnings = append(preview.Warnings,
+					fmt.Sprintf("depends on step %s, which would fail", dep))
+			}
+		}
+
+		// Flag files an earlier step in this plan already touched
+		for _, f := range preview.FilesWritten {
+			if deleted[f] {
+				preview.Warnings = append(preview.Warnings, fmt.Sprintf("%s was removed by an earlier step", f))
+			}
+			written[f] = true
+		}
+		for _, f := range preview.FilesDeleted {
+			deleted[f] = true
+		}
+
+		if !preview.Valid {
+			invalid[step.ID] = true
+			report.Valid = false
+		}
+		if preview.Destructive {
+			report.Destructive = true
+		}
+		report.Commands = append(report.Commands, preview.Commands...)
+		report.GitOperations = append(report.GitOperations, preview.GitOperations...)
+		report.Steps = append(report.Steps, preview)
+	}
+
+	report.FilesWritten = sortedKeys(written)
+	report.FilesDeleted = sortedKeys(deleted)
+	if report.Commands == nil {
+		report.Commands = make([]string, 0)
+	}
+	if report.GitOperations == nil {
+		report.GitOperations = make([]string, 0)
+	}
+
+	return report
+}
+
+// PreviewStep describes what a step would do without running its tool
+func (e *StepExecutor) PreviewStep(step *TaskStep, ctx *TaskContext) StepPreview {
+	params := e.prepareParams(step.Params, ctx)
+	preview := StepPreview{
+		StepID:      step.ID,
+		Description: step.Description,
+		Tool:        step.Tool,
+		Params:      params,
+		Valid:       true,
+	}
+
+	if err := e.ValidateStep(&TaskStep{ID: step.ID, Tool: step.Tool, Params: params}); err != nil {
+		preview.Valid = false
+		preview.Error = err.Error()
+	}
+
+	// Placeholders left after substitution will be passed to the tool literally
+	for key, value := range params {
+		if s, ok := value.(string); ok && strings.Contains(s, "${") {
+			preview.Warnings = append(preview.Warnings, fmt.Sprintf("parameter %s has unresolved variable: %s", key, s))
+		}
+	}
+
+	path := previewString(params, "path")
+	switch step.Tool {
+	case "read_file", "list_dir", "tree", "search", "ripgrep":
+		preview.Action = fmt.Sprintf("Read %s", orDefault(path, "the working directory"))
+		if path != "" {
+			preview.FilesRead = []string{path}
+		}
+
+	case "write_file":
+		content := previewString(params, "content")
+		verb := "Create"
+		if fileExists(path, ctx) {
+			verb = "Overwrite"
+		}
+		preview.Action = fmt.Sprintf("%s %s (%d bytes)", verb, path, len(content))
+		preview.FilesWritten = []string{path}
+
+	case "edit_file", "smart_edit":
+		preview.Action = fmt.Sprintf("Edit %s", path)
+		if mode := previewString(params, "mode"); mode != "" {
+			preview.Action += fmt.Sprintf(" (%s mode)", mode)
+		}
+		if !fileExists(path, ctx) {
+			preview.Warnings = append(preview.Warnings, fmt.Sprintf("%s does not exist yet", path))
+		}
+		preview.FilesWritten = []string{path}
+
+	case "resolve_conflict":
+		preview.Action = fmt.Sprintf("Resolve conflicts in %s", path)
+		preview.FilesWritten = []string{path}
+
+	case "make_dir":
+		preview.Action = fmt.Sprintf("Create directory %s", path)
+		preview.FilesWritten = []string{path}
+
+	case "remove":
+		preview.Action = fmt.Sprintf("Remove %s", path)
+		if recursive, _ := params["recursive"].(bool); recursive {
+			preview.Action += " recursively"
+		}
+		preview.FilesDeleted = []string{path}
+		preview.Destructive = true
+
+	case "move":
+		src, dst := previewString(params, "source"), previewString(params, "destination")
+		preview.Action = fmt.Sprintf("Move %s to %s", src, dst)
+		preview.FilesDeleted = []string{src}
+		preview.FilesWritten = []string{dst}
+
+	case "bash":
+		cmd := previewString(params, "command")
+		preview.Action = fmt.Sprintf("Run command: %s", cmd)
+		preview.Commands = []string{cmd}
+
+	case "run_background":
+		cmd := previewString(params, "command")
+		preview.Action = fmt.Sprintf("Start in the background: %s", cmd)
+		preview.Commands = []string{cmd}
+
+	case "stop_process":
+		preview.Action = fmt.Sprintf("Stop background process %v", params["id"])
+
+	case "list_processes":
+		preview.Action = "List background processes"
+
+	case "web_fetch":
+		preview.Action = fmt.Sprintf("Fetch %s", previewString(params, "url"))
+
+	case "web_search":
+		preview.Action = fmt.Sprintf("Search the web for %q", previewString(params, "query"))
+
+	case "create_pull_request":
+		preview.Action = fmt.Sprintf("Open pull request %q", previewString(params, "title"))
+		if head := previewString(params, "head"); head != "" {
+			preview.Action += " from " + head
+		}
+
+	case "comment_pull_request":
+		preview.Action = fmt.Sprintf("Comment on pull request #%v", params["number"])
+
+	case "list_issues":
+		preview.Action = "List issues"
+
+	case "get_issue":
+		preview.Action = fmt.Sprintf("Read issue #%v", params["number"])
+
+	default:
+		if strings.HasPrefix(step.Tool, "git_") {
+			op := describeGitOperation(step.Tool, params)
+			preview.Action = op
+			commits, _ := params["commit"].(bool)
+			if mutatingGitTools[step.Tool] || (step.Tool == "git_commit_message" && commits) {
+				preview.GitOperations = []string{op}
+			}
+			force, _ := params["force"].(bool)
+			if step.Tool == "git_push" && force {
+				preview.Destructive = true
+			}
+			action := previewString(params, "action")
+			if (step.Tool == "git_remote" || step.Tool == "git_tag") && (action == "remove" || action == "delete") {
+				preview.Destructive = true
+			}
+		} else {
+			preview.Action = fmt.Sprintf("Run tool %s with %s", step.Tool, e.formatParams(params))
+		}
+	}
+
+	return preview
+}
+
+// describeGitOperation renders a git tool call as the command it roughly corresponds to
+func describeGitOperation(tool string, params map[string]interface{}) string {
+	parts := []string{"git", strings.ReplaceAll(strings.TrimPrefix(tool, "git_"), "_", "-")}
+
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		if k != "path" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		switch v := params[k].(type) {
+		case bool:
+			if v {
+				parts = append(parts, "--"+strings.ReplaceAll(k, "_", "-"))
+			}
+		case string:
+			if k == "message" {
+				v = fmt.Sprintf("%q", v)
+			}
+			parts = append(parts, v)
+		default:
+			parts = append(parts, fmt.Sprintf("%v", v))
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func previewString(params map[string]interface{}, key string) string {
+	s, _ := params[key].(string)
+	return s
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// fileExists reports whether path exists, resolving it against the plan's working directory
+func fileExists(path string, ctx *TaskContext) bool {
+	if path == "" {
+		return false
+	}
+	if !filepath.IsAbs(path) && ctx != nil && ctx.WorkingDirectory != "" {
+		path = filepath.Join(ctx.WorkingDirectory, path)
+	}
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		if k != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -1,6 +1,7 @@
 package planner
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -30,7 +31,12 @@ func NewStepExecutorWithRegistry(registry *tools.Registry) *StepExecutor {
 }
 
 // Execute executes a single step
-func (e *StepExecutor) Execute(step *TaskStep, context *TaskContext) (*StepResult, error) {
+func (e *StepExecutor) Execute(step *TaskStep, taskCtx *TaskContext) (*StepResult, error) {
+	return e.ExecuteContext(context.Background(), step, taskCtx)
+}
+
+// ExecuteContext executes a single step, interrupting the tool if ctx is cancelled
+func (e *StepExecutor) ExecuteContext(ctx context.Context, step *TaskStep, taskCtx *TaskContext) (*StepResult, error) {
 	startTime := time.Now()
 	
 	result := &StepResult{
@@ -53,7 +59,7 @@ func (e *StepExecutor) Execute(step *TaskStep, context *TaskContext) (*StepResul
 	}
 
 	// Prepare parameters with variable substitution
-	params := e.prepareParams(step.Params, context)
+	params := e.prepareParams(step.Params, taskCtx)
 
 	// Create tool use request
 	toolUse := tools.ToolUse{
@@ -64,7 +70,7 @@ func (e *StepExecutor) Execute(step *TaskStep, context *TaskContext) (*StepResul
 	}
 
 	// Execute the tool
-	toolResult, err := e.toolRegistry.Execute(toolUse)
+	toolResult, err := e.toolRegistry.ExecuteContext(ctx, toolUse)
 	if err != nil {
 		result.Error = err.Error()
 		return result, err
@@ -101,6 +107,14 @@ func (e *StepExecutor) prepareParams(params map[string]interface{}, context *Tas
 		}
 	}
 
+	// Internal inputs, which tools such as publish_artifact read
+	if context.SessionID != "" {
+		prepared["_sessionId"] = context.SessionID
+	}
+	if context.PlanID != "" {
+		prepared["_planId"] = context.PlanID
+	}
+
 	return prepared
 }
 
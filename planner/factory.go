@@ -1,6 +1,8 @@
 package planner
 
 import (
+	"context"
+
 	"rcode/db"
 )
 
@@ -18,13 +20,7 @@ func NewPlannerFactory() *PlannerFactory {
 
 // CreatePlanner creates a new planner instance with proper initialization
 func (f *PlannerFactory) CreatePlanner(options PlannerOptions) *Planner {
-	// Create analyzer with context support if available
-	var analyzer *TaskAnalyzer
-	if options.ContextManager != nil {
-		analyzer = NewTaskAnalyzerWithContext(options.ContextManager)
-	} else {
-		analyzer = NewTaskAnalyzer()
-	}
+	analyzer := newStepAnalyzer(options)
 
 	stepExecutor := NewStepExecutor()
 	metricsCollector := NewMetricsCollector()
@@ -38,6 +34,7 @@ func (f *PlannerFactory) CreatePlanner(options PlannerOptions) *Planner {
 		options:          options,
 		contextManager:   options.ContextManager,
 		metricsCollector: metricsCollector,
+		cancels:          make(map[string]context.CancelFunc),
 	}
 
 	// Initialize parallel executor if concurrent steps are enabled
@@ -17,6 +17,7 @@ type GitOperation struct {
 	PrevBranch  string                 `json:"prev_branch"`  // Previous branch (for checkout)
 	RemoteName  string                 `json:"remote_name"`  // Remote name (for push)
 	MergeCommit string                 `json:"merge_commit"` // For merge operations
+	Tag         string                 `json:"tag"`          // Tag created (for tag operations)
 	Timestamp   time.Time              `json:"timestamp"`
 	StepID      string                 `json:"step_id"`
 	Params      map[string]interface{} `json:"params"` // Original parameters
@@ -89,6 +90,12 @@ func (grm *GitRollbackManager) TrackGitOperation(step *TaskStep, result *StepRes
 		if createBranch, ok := step.Params["create"].(string); ok {
 			operation.Branch = createBranch
 		}
+
+	case "git_tag":
+		// Track tag creation
+		if getParamString(step.Params, "action", "list") == "create" {
+			operation.Tag = getParamString(step.Params, "name", "")
+		}
 	}
 
 	grm.operations = append(grm.operations, operation)
@@ -133,6 +140,8 @@ func (grm *GitRollbackManager) rollbackOperation(op GitOperation) error {
 		return grm.rollbackCheckout(op)
 	case "git_branch":
 		return grm.rollbackBranch(op)
+	case "git_tag":
+		return grm.rollbackTag(op)
 	default:
 		// Other Git operations don't need rollback or are read-only
 		return nil
@@ -249,6 +258,21 @@ func (grm *GitRollbackManager) rollbackBranch(op GitOperation) error {
 	return nil
 }
 
+// rollbackTag deletes a tag created by the operation
+func (grm *GitRollbackManager) rollbackTag(op GitOperation) error {
+	if op.Tag == "" {
+		return nil
+	}
+
+	cmd := exec.Command("git", "tag", "--delete", op.Tag)
+	cmd.Dir = grm.workDir
+	if err := cmd.Run(); err != nil {
+		return serr.New(fmt.Sprintf("could not delete tag %s", op.Tag))
+	}
+
+	return nil
+}
+
 // Helper functions
 
 func (grm *GitRollbackManager) wasCommitPushed(commitHash string) bool {
@@ -0,0 +1,138 @@
+package planner
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"rcode/db"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// planStore is the part of db.TaskPlanDB used to journal plan progress
+type planStore interface {
+	SavePlan(plan *db.TaskPlan) error
+	SaveExecution(planID, stepID string, result *db.StepResult) error
+}
+
+// RecoveryAction describes how an interrupted plan was recovered
+type RecoveryAction string
+
+const (
+	RecoveryResumed RecoveryAction = "resumed" // Execution should continue from the first unfinished step
+	RecoveryPaused  RecoveryAction = "paused"  // Left paused for the user to review and resume
+)
+
+// journalStep records the outcome of a step and the plan state after it,
+// so a plan can be recovered if the server stops mid-execution
+func (p *Planner) journalStep(task *TaskPlanner, step *TaskStep) {
+	store, ok := p.dbStore.(planStore)
+	if !ok {
+		return
+	}
+
+	if step.Result != nil {
+		result := &db.StepResult{
+			Success:  step.Result.Success,
+			Output:   step.Result.Output,
+			Error:    step.Result.Error,
+			Duration: step.Result.Duration,
+			Retries:  step.Result.Retries,
+		}
+		if err := store.SaveExecution(task.ID, step.ID, result); err != nil {
+			p.logWarning(task.ID, step.ID, "Failed to journal step result: "+err.Error())
+		}
+	}
+
+	if err := p.saveProgress(task); err != nil {
+		p.logWarning(task.ID, step.ID, "Failed to save progress: "+err.Error())
+	}
+}
+
+// saveProgress saves the current task progress to the database
+func (p *Planner) saveProgress(task *TaskPlanner) error {
+	store, ok := p.dbStore.(planStore)
+	if !ok {
+		return nil // No database configured, skip saving
+	}
+
+	dbPlan := &db.TaskPlan{
+		ID:          task.ID,
+		SessionID:   task.SessionID,
+		Description: task.Description,
+		Status:      db.PlanStatus(task.Status),
+		CreatedAt:   task.CreatedAt,
+		UpdatedAt:   time.Now(),
+		CompletedAt: task.CompletedAt,
+	}
+
+	var err error
+	if dbPlan.Steps, err = json.Marshal(task.Steps); err != nil {
+		return serr.Wrap(err, "failed to marshal steps")
+	}
+	if dbPlan.Context, err = json.Marshal(task.Context); err != nil {
+		return serr.Wrap(err, "failed to marshal context")
+	}
+	if dbPlan.Checkpoints, err = json.Marshal(task.Checkpoints); err != nil {
+		return serr.Wrap(err, "failed to marshal checkpoints")
+	}
+
+	return store.SavePlan(dbPlan)
+}
+
+// PrepareResume positions a reloaded plan at its first unfinished step and resets
+// steps that were running or between retries when execution stopped.
+// It returns the step that had already started, whose effects may be partially applied.
+func (t *TaskPlanner) PrepareResume() *TaskStep {
+	t.CurrentStep = len(t.Steps)
+	for i := range t.Steps {
+		if t.Steps[i].Status != StepStatusCompleted && t.Steps[i].Status != StepStatusSkipped {
+			t.CurrentStep = i
+			break
+		}
+	}
+
+	var interrupted *TaskStep
+	for i := t.CurrentStep; i < len(t.Steps); i++ {
+		step := &t.Steps[i]
+		switch step.Status {
+		case StepStatusRunning, StepStatusRetrying, StepStatusFailed:
+			if interrupted == nil && step.StartTime != nil {
+				interrupted = step
+			}
+			step.Status = StepStatusPending
+		}
+	}
+
+	return interrupted
+}
+
+// RecoverPlan reloads a plan that was executing when the server stopped. The plan is
+// resumed only if autoResume is set and no non-retryable step was cut off mid-run;
+// otherwise it is paused so the user can review it before resuming.
+func (p *Planner) RecoverPlan(task *TaskPlanner, autoResume bool) (RecoveryAction, error) {
+	interrupted := task.PrepareResume()
+	task.Status = TaskStatusPaused
+
+	if err := p.LoadPlan(task); err != nil {
+		return "", err
+	}
+
+	action := RecoveryPaused
+	if autoResume && (interrupted == nil || interrupted.Retryable) {
+		action = RecoveryResumed
+	}
+
+	msg := fmt.Sprintf("Recovered interrupted plan at step %d of %d", task.CurrentStep+1, len(task.Steps))
+	if interrupted != nil {
+		msg += fmt.Sprintf(", step %s was cut off mid-run", interrupted.ID)
+	}
+	p.logWarning(task.ID, "", fmt.Sprintf("%s (%s)", msg, action))
+
+	if err := p.saveProgress(task); err != nil {
+		return "", serr.Wrap(err, "failed to save recovered plan")
+	}
+
+	return action, nil
+}
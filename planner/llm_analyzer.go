@@ -0,0 +1,270 @@
+package planner
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"rcode/completion"
+	"rcode/tools"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/serr"
+)
+
+// StepAnalyzer turns a task description into executable steps
+type StepAnalyzer interface {
+	AnalyzeTask(description string) ([]TaskStep, error)
+}
+
+// LLMTaskAnalyzer asks a model to decompose a task into steps and falls back
+// to the heuristic analyzer when the model fails or returns an invalid plan
+type LLMTaskAnalyzer struct {
+	client         completion.Client
+	fallback       StepAnalyzer
+	executor       *StepExecutor
+	toolRegistry   *tools.Registry
+	contextManager interface{} // Will be *context.Manager but avoid import cycle
+	maxSteps       int
+}
+
+// llmPlan is the JSON document the model must return
+type llmPlan struct {
+	Steps []llmStep `json:"steps"`
+}
+
+type llmStep struct {
+	ID           string                 `json:"id"`
+	Description  string                 `json:"description"`
+	Tool         string                 `json:"tool"`
+	Params       map[string]interface{} `json:"params"`
+	Dependencies []string               `json:"dependencies"`
+}
+
+// Schema of the model's reply, included in the prompt
+const llmPlanSchema = `{
+  "type": "object",
+  "required": ["steps"],
+  "properties": {
+    "steps": {
+      "type": "array",
+      "minItems": 1,
+      "items": {
+        "type": "object",
+        "required": ["id", "description", "tool", "params"],
+        "properties": {
+          "id": {"type": "string"},
+          "description": {"type": "string"},
+          "tool": {"type": "string"},
+          "params": {"type": "object"},
+          "dependencies": {"type": "array", "items": {"type": "string"}}
+        }
+      }
+    }
+  }
+}`
+
+// NewLLMTaskAnalyzer creates a model-backed analyzer that uses fallback when the model cannot produce a valid plan
+func NewLLMTaskAnalyzer(client completion.Client, fallback StepAnalyzer, registry *tools.Registry, contextManager interface{}, maxSteps int) *LLMTaskAnalyzer {
+	return &LLMTaskAnalyzer{
+		client:         client,
+		fallback:       fallback,
+		executor:       NewStepExecutorWithRegistry(registry),
+		toolRegistry:   registry,
+		contextManager: contextManager,
+		maxSteps:       maxSteps,
+	}
+}
+
+// AnalyzeTask asks the model for a plan, falling back to the heuristic analyzer on failure
+func (a *LLMTaskAnalyzer) AnalyzeTask(description string) ([]TaskStep, error) {
+	steps, err := a.analyzeWithModel(description)
+	if err == nil {
+		return steps, nil
+	}
+
+	logger.Warn("LLM task analysis failed, falling back to heuristic analyzer", "error", err.Error())
+	if a.fallback == nil {
+		return nil, err
+	}
+	return a.fallback.AnalyzeTask(description)
+}
+
+func (a *LLMTaskAnalyzer) analyzeWithModel(description string) ([]TaskStep, error) {
+	if a.client == nil {
+		return nil, serr.New("no model client configured")
+	}
+
+	reply, err := a.client.Complete(a.buildPrompt(description))
+	if err != nil {
+		return nil, serr.Wrap(err, "model request failed")
+	}
+
+	return a.parseSteps(reply)
+}
+
+// buildPrompt describes the available tools, the project and the required reply format
+func (a *LLMTaskAnalyzer) buildPrompt(description string) string {
+	var sb strings.Builder
+
+	sb.WriteString("Break the following software engineering task into a sequence of tool calls.\n\n")
+	sb.WriteString("Task: ")
+	sb.WriteString(description)
+	sb.WriteString("\n\n")
+
+	if projectInfo := a.projectContext(description); projectInfo != "" {
+		sb.WriteString("Project context:\n")
+		sb.WriteString(projectInfo)
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString("Available tools:\n")
+	for _, tool := range a.toolRegistry.GetTools() {
+		sb.WriteString(fmt.Sprintf("- %s: %s\n", tool.Name, tool.Description))
+		if props, ok := tool.InputSchema["properties"].(map[string]interface{}); ok && len(props) > 0 {
+			required := make(map[string]bool)
+			if req, ok := tool.InputSchema["required"].([]string); ok {
+				for _, r := range req {
+					required[r] = true
+				}
+			}
+			names := make([]string, 0, len(props))
+			for name := range props {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				paramType := ""
+				if prop, ok := props[name].(map[string]interface{}); ok {
+					paramType, _ = prop["type"].(string)
+				}
+				suffix := ""
+				if required[name] {
+					suffix = ", required"
+				}
+				sb.WriteString(fmt.Sprintf("    %s (%s%s)\n", name, paramType, suffix))
+			}
+		}
+	}
+
+	sb.WriteString("\nRules:\n")
+	sb.WriteString("- Use only the tools listed above, with their parameter names.\n")
+	sb.WriteString("- Step IDs must be unique. A step may only depend on steps listed before it.\n")
+	if a.maxSteps > 0 {
+		sb.WriteString(fmt.Sprintf("- Use at most %d steps.\n", a.maxSteps))
+	}
+	sb.WriteString("- Reply with a single JSON object matching this schema and nothing else:\n")
+	sb.WriteString(llmPlanSchema)
+	sb.WriteString("\n")
+
+	return sb.String()
+}
+
+// projectContext summarizes the project using whatever the context manager supports
+func (a *LLMTaskAnalyzer) projectContext(description string) string {
+	if a.contextManager == nil {
+		return ""
+	}
+
+	var sb strings.Builder
+
+	if rooted, ok := a.contextManager.(interface{ GetProjectRoot() string }); ok {
+		if root := rooted.GetProjectRoot(); root != "" {
+			sb.WriteString(fmt.Sprintf("Root: %s\n", root))
+		}
+	}
+
+	if prioritizer, ok := a.contextManager.(interface {
+		PrioritizeFiles(string) ([]string, error)
+	}); ok {
+		if files, err := prioritizer.PrioritizeFiles(description); err == nil && len(files) > 0 {
+			if len(files) > 10 {
+				files = files[:10]
+			}
+			sb.WriteString("Relevant files:\n")
+			for _, f := range files {
+				sb.WriteString(fmt.Sprintf("  %s\n", f))
+			}
+		}
+	}
+
+	return sb.String()
+}
+
+// parseSteps extracts the JSON plan from the model's reply and validates it
+func (a *LLMTaskAnalyzer) parseSteps(reply string) ([]TaskStep, error) {
+	raw := completion.ExtractJSONObject(reply)
+	if raw == "" {
+		return nil, serr.New("model reply contains no JSON object")
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(raw))
+	decoder.DisallowUnknownFields()
+
+	var plan llmPlan
+	if err := decoder.Decode(&plan); err != nil {
+		return nil, serr.Wrap(err, "model reply does not match plan schema")
+	}
+
+	if len(plan.Steps) == 0 {
+		return nil, serr.New("model returned no steps")
+	}
+	if a.maxSteps > 0 && len(plan.Steps) > a.maxSteps {
+		return nil, serr.New(fmt.Sprintf("model returned %d steps, exceeds maximum of %d", len(plan.Steps), a.maxSteps))
+	}
+
+	steps := make([]TaskStep, 0, len(plan.Steps))
+	for i, s := range plan.Steps {
+		if strings.TrimSpace(s.ID) == "" {
+			return nil, serr.New(fmt.Sprintf("step %d has no id", i+1))
+		}
+		if strings.TrimSpace(s.Description) == "" {
+			return nil, serr.New(fmt.Sprintf("step %s has no description", s.ID))
+		}
+
+		step := TaskStep{
+			ID:           s.ID,
+			Description:  s.Description,
+			Tool:         s.Tool,
+			Params:       s.Params,
+			Dependencies: s.Dependencies,
+			Retryable:    true,
+			MaxRetries:   3,
+			Status:       StepStatusPending,
+		}
+		if step.Params == nil {
+			step.Params = make(map[string]interface{})
+		}
+		if step.Dependencies == nil {
+			step.Dependencies = make([]string, 0)
+		}
+
+		if err := a.executor.ValidateStep(&step); err != nil {
+			return nil, serr.Wrap(err, fmt.Sprintf("step %s is invalid", s.ID))
+		}
+		steps = append(steps, step)
+	}
+
+	if err := ValidateStepDependencies(steps); err != nil {
+		return nil, err
+	}
+
+	return steps, nil
+}
+
+// newStepAnalyzer builds the analyzer configured by options: the heuristic analyzer,
+// with context support if available, wrapped by the model analyzer when a client is set
+func newStepAnalyzer(options PlannerOptions) StepAnalyzer {
+	var heuristic *TaskAnalyzer
+	if options.ContextManager != nil {
+		heuristic = NewTaskAnalyzerWithContext(options.ContextManager)
+	} else {
+		heuristic = NewTaskAnalyzer()
+	}
+
+	if options.ModelClient == nil {
+		return heuristic
+	}
+	return NewLLMTaskAnalyzer(options.ModelClient, heuristic, tools.DefaultRegistry(), options.ContextManager, options.MaxSteps)
+}
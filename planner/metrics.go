@@ -6,6 +6,8 @@ import (
 	"sync"
 	"time"
 
+	"rcode/db"
+
 	"github.com/rohanthewiz/serr"
 )
 
@@ -21,9 +23,18 @@ type ExecutionMetrics struct {
 	StartTime      time.Time              `json:"start_time"`
 	EndTime        *time.Time             `json:"end_time,omitempty"`
 	ParallelInfo   *ParallelExecutionInfo `json:"parallel_info,omitempty"`
+	Coverage       *CoverageMetric        `json:"coverage,omitempty"` // Set when steps measure test coverage
 	mu             sync.RWMutex
 }
 
+// CoverageMetric is the test coverage a plan's steps measured, first and last, in percent
+// of statements
+type CoverageMetric struct {
+	Before float64 `json:"before"`
+	After  float64 `json:"after"`
+	Delta  float64 `json:"delta"` // Percentage points gained
+}
+
 // StepMetric tracks metrics for a single step execution
 type StepMetric struct {
 	StepID        string        `json:"step_id"`
@@ -215,6 +226,28 @@ func (mc *MetricsCollector) RecordFileModification(planID, stepID string, files
 	return nil
 }
 
+// RecordCoverage records the coverage a step measured, before and after any tests it wrote.
+// The plan's metric runs from the first coverage measured to the last.
+func (mc *MetricsCollector) RecordCoverage(planID string, before, after float64) error {
+	mc.mu.RLock()
+	metrics, exists := mc.metrics[planID]
+	mc.mu.RUnlock()
+
+	if !exists {
+		return serr.New("metrics not found for plan")
+	}
+
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+
+	if metrics.Coverage == nil {
+		metrics.Coverage = &CoverageMetric{Before: before}
+	}
+	metrics.Coverage.After = after
+	metrics.Coverage.Delta = after - metrics.Coverage.Before
+	return nil
+}
+
 // EndPlanExecution ends tracking for a plan and calculates final metrics
 func (mc *MetricsCollector) EndPlanExecution(planID string) (*ExecutionMetrics, error) {
 	mc.mu.Lock()
@@ -296,6 +329,10 @@ func (mc *MetricsCollector) GetMetrics(planID string) (*ExecutionMetrics, error)
 		stepCopy := *v
 		metricsCopy.StepMetrics[k] = &stepCopy
 	}
+	if metrics.Coverage != nil {
+		coverageCopy := *metrics.Coverage
+		metricsCopy.Coverage = &coverageCopy
+	}
 	metrics.mu.RUnlock()
 
 	return &metricsCopy, nil
@@ -316,15 +353,45 @@ func (mc *MetricsCollector) saveMetrics(planID string, metrics *ExecutionMetrics
 
 	// Use type assertion to call SaveMetrics method
 	type dbInterface interface {
-		SaveMetrics(planID string, metrics interface{}) error
+		SaveMetrics(metrics *db.TaskMetrics) error
 	}
 
-	db, ok := mc.dbStore.(dbInterface)
+	store, ok := mc.dbStore.(dbInterface)
 	if !ok {
 		return nil // Database doesn't support SaveMetrics
 	}
 
-	return db.SaveMetrics(planID, metrics)
+	return store.SaveMetrics(toTaskMetrics(planID, metrics))
+}
+
+// toTaskMetrics summarizes a plan's metrics for the database
+func toTaskMetrics(planID string, metrics *ExecutionMetrics) *db.TaskMetrics {
+	metrics.mu.RLock()
+	defer metrics.mu.RUnlock()
+
+	record := &db.TaskMetrics{
+		PlanID:          planID,
+		TotalSteps:      metrics.TotalSteps,
+		CompletedSteps:  metrics.CompletedSteps,
+		FailedSteps:     metrics.FailedSteps,
+		SkippedSteps:    metrics.SkippedSteps,
+		TotalDurationMs: metrics.TotalDuration.Milliseconds(),
+		ToolsUsed:       make(map[string]int),
+	}
+	var stepDuration time.Duration
+	for _, step := range metrics.StepMetrics {
+		stepDuration += step.Duration
+		record.TotalRetries += step.RetryCount
+		record.ToolsUsed[step.Tool]++
+	}
+	if len(metrics.StepMetrics) > 0 {
+		record.AvgStepDurationMs = (stepDuration / time.Duration(len(metrics.StepMetrics))).Milliseconds()
+	}
+	if metrics.Coverage != nil {
+		before, after := metrics.Coverage.Before, metrics.Coverage.After
+		record.CoverageBefore, record.CoverageAfter = &before, &after
+	}
+	return record
 }
 
 // GenerateMetricsReport generates a human-readable metrics report
@@ -345,6 +412,13 @@ func GenerateMetricsReport(metrics *ExecutionMetrics) string {
 		report += fmt.Sprintf("Critical Path: %v\n", metrics.ParallelInfo.CriticalPath)
 	}
 
+	if metrics.Coverage != nil {
+		report += fmt.Sprintf("\n=== Test Coverage ===\n")
+		report += fmt.Sprintf("Before: %.1f%%\n", metrics.Coverage.Before)
+		report += fmt.Sprintf("After: %.1f%%\n", metrics.Coverage.After)
+		report += fmt.Sprintf("Delta: %+.1f points\n", metrics.Coverage.Delta)
+	}
+
 	report += fmt.Sprintf("\n=== Step Details ===\n")
 	var totalMemoryDelta int64
 	var totalBytesWritten int64
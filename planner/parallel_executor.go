@@ -1,6 +1,7 @@
 package planner
 
 import (
+	"context"
 	"fmt"
 	"sync"
 
@@ -36,14 +37,29 @@ type DependencyGraph struct {
 	mu        sync.RWMutex
 }
 
+// StepDone is called as each step finishes, from the worker that ran it
+type StepDone func(stepID string, result *StepResult)
+
 // ExecuteSteps executes multiple steps in parallel while respecting dependencies
-func (pe *ParallelExecutor) ExecuteSteps(steps []TaskStep, context *TaskContext) (map[string]*StepResult, error) {
+func (pe *ParallelExecutor) ExecuteSteps(steps []TaskStep, taskCtx *TaskContext) (map[string]*StepResult, error) {
+	return pe.ExecuteStepsContext(context.Background(), steps, taskCtx, nil)
+}
+
+// ExecuteStepsContext executes steps in parallel until they finish, one fails or ctx is cancelled.
+// Cancellation interrupts running steps and prevents new ones from starting. Steps already
+// completed or skipped are not run again. onDone, if set, is called as each step finishes.
+func (pe *ParallelExecutor) ExecuteStepsContext(ctx context.Context, steps []TaskStep, taskCtx *TaskContext, onDone StepDone) (map[string]*StepResult, error) {
 	if len(steps) == 0 {
 		return make(map[string]*StepResult), nil
 	}
 
 	// Build dependency graph
 	graph := pe.buildDependencyGraph(steps)
+	for _, step := range steps {
+		if step.Status == StepStatusCompleted || step.Status == StepStatusSkipped {
+			pe.markCompleted(graph, step.ID)
+		}
+	}
 
 	// Channel to track results
 	results := make(map[string]*StepResult)
@@ -65,7 +81,10 @@ func (pe *ParallelExecutor) ExecuteSteps(steps []TaskStep, context *TaskContext)
 		return nil, serr.New("no steps are ready to execute - check for circular dependencies")
 	}
 
-	// Process steps until all are completed or an error occurs
+	// Steps already handed to a worker; ready steps stay ready until they complete
+	started := make(map[string]bool)
+
+	// Process steps until all are completed, an error occurs or execution is cancelled
 	for {
 		select {
 		case err := <-errChan:
@@ -73,6 +92,10 @@ func (pe *ParallelExecutor) ExecuteSteps(steps []TaskStep, context *TaskContext)
 			wg.Wait()
 			return results, err
 
+		case <-ctx.Done():
+			wg.Wait()
+			return results, ctx.Err()
+
 		default:
 			// Get ready steps
 			graph.mu.RLock()
@@ -86,6 +109,10 @@ func (pe *ParallelExecutor) ExecuteSteps(steps []TaskStep, context *TaskContext)
 
 			// Execute ready steps
 			for _, step := range readySteps {
+				if started[step.ID] {
+					continue
+				}
+				started[step.ID] = true
 				wg.Add(1)
 
 				go func(s TaskStep) {
@@ -95,13 +122,17 @@ func (pe *ParallelExecutor) ExecuteSteps(steps []TaskStep, context *TaskContext)
 					semaphore <- struct{}{}
 					defer func() { <-semaphore }()
 
+					if ctx.Err() != nil {
+						return
+					}
+
 					logger.Info("Executing step in parallel",
 						"step_id", s.ID,
 						"description", s.Description,
 						"tool", s.Tool)
 
 					// Execute the step
-					result, err := pe.executor.Execute(&s, context)
+					result, err := pe.executor.ExecuteContext(ctx, &s, taskCtx)
 					if err != nil {
 						result = &StepResult{
 							Success: false,
@@ -118,6 +149,9 @@ func (pe *ParallelExecutor) ExecuteSteps(steps []TaskStep, context *TaskContext)
 					resultsMu.Lock()
 					results[s.ID] = result
 					resultsMu.Unlock()
+					if onDone != nil {
+						onDone(s.ID, result)
+					}
 
 					// Mark as completed
 					pe.markCompleted(graph, s.ID)
@@ -130,12 +164,10 @@ func (pe *ParallelExecutor) ExecuteSteps(steps []TaskStep, context *TaskContext)
 				}(step)
 			}
 
-			// Wait for signal or check periodically
-			if len(readySteps) == 0 {
-				select {
-				case <-checkReady:
-					// A step completed, check for newly ready steps
-				}
+			// Wait for a step to complete before looking for newly ready steps
+			select {
+			case <-checkReady:
+			case <-ctx.Done():
 			}
 
 			// Find newly ready steps
@@ -1,7 +1,7 @@
 package planner
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"strings"
 	"sync"
@@ -9,6 +9,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/rohanthewiz/serr"
+	"rcode/tools"
 )
 
 // Planner handles task planning and execution
@@ -17,26 +18,23 @@ type Planner struct {
 	tasks            map[string]*TaskPlanner
 	executor         *StepExecutor
 	parallelExecutor *ParallelExecutor
-	analyzer         *TaskAnalyzer
+	analyzer         StepAnalyzer
 	templates        map[string]*TaskTemplate
 	logs             map[string][]ExecutionLog
+	logMu            sync.Mutex // Guards logs separately so logging is safe while mu is held
 	options          PlannerOptions
 	snapshotManager  *SnapshotManager
 	contextManager   interface{} // Will be *context.Manager but avoid import cycle
 	dbStore          interface{} // Will be *db.TaskPlanDB but avoid import cycle
 	metricsCollector *MetricsCollector
 	gitRollback      map[string]*GitRollbackManager // Per-task Git rollback managers
+	approvalFunc     ApprovalFunc                   // Asks the user before running flagged steps
+	cancels          map[string]context.CancelFunc  // Stops the plans currently executing
 }
 
 // NewPlanner creates a new task planner
 func NewPlanner(options PlannerOptions) *Planner {
-	// Create analyzer with context support if available
-	var analyzer *TaskAnalyzer
-	if options.ContextManager != nil {
-		analyzer = NewTaskAnalyzerWithContext(options.ContextManager)
-	} else {
-		analyzer = NewTaskAnalyzer()
-	}
+	analyzer := newStepAnalyzer(options)
 
 	stepExecutor := NewStepExecutor()
 
@@ -50,6 +48,7 @@ func NewPlanner(options PlannerOptions) *Planner {
 		contextManager:   options.ContextManager,
 		metricsCollector: NewMetricsCollector(),
 		gitRollback:      make(map[string]*GitRollbackManager),
+		cancels:          make(map[string]context.CancelFunc),
 	}
 
 	// Initialize parallel executor if concurrent steps are enabled
@@ -64,19 +63,20 @@ func NewPlanner(options PlannerOptions) *Planner {
 
 // CreatePlan creates a new task plan from a description
 func (p *Planner) CreatePlan(description string) (*TaskPlanner, error) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-
-	// Analyze the task description
+	// Analyze the task description before locking, since the analyzer may call a model
 	steps, err := p.analyzer.AnalyzeTask(description)
 	if err != nil {
 		return nil, serr.Wrap(err, "failed to analyze task")
 	}
 
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	if len(steps) > p.options.MaxSteps {
 		return nil, serr.New(fmt.Sprintf("task requires %d steps, exceeds maximum of %d",
 			len(steps), p.options.MaxSteps))
 	}
+	flagApprovalSteps(steps)
 
 	// Create task planner
 	task := &TaskPlanner{
@@ -116,8 +116,19 @@ func (p *Planner) ExecutePlan(taskID string) error {
 	}
 
 	task.Status = TaskStatusExecuting
+	task.Context.SessionID = task.SessionID
+	task.Context.PlanID = task.ID
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancels[task.ID] = cancel
 	p.mu.Unlock()
 
+	defer func() {
+		p.mu.Lock()
+		delete(p.cancels, task.ID)
+		p.mu.Unlock()
+		cancel()
+	}()
+
 	// Start metrics collection
 	if p.metricsCollector != nil {
 		p.metricsCollector.StartPlanExecution(task.ID, len(task.Steps))
@@ -125,13 +136,23 @@ func (p *Planner) ExecutePlan(taskID string) error {
 
 	// Check if we can use parallel execution
 	if p.parallelExecutor != nil && p.shouldUseParallelExecution(task) {
-		return p.executeParallel(task)
+		return p.executeParallel(ctx, task)
 	}
 
 	// Sequential execution
+	approvedSteps := make(map[string]bool)
 	for task.CurrentStep < len(task.Steps) {
+		if ctx.Err() != nil {
+			return p.finishCancelled(task)
+		}
 		step := &task.Steps[task.CurrentStep]
 
+		// Steps a parallel run finished before the plan stopped are done already
+		if step.Status == StepStatusCompleted {
+			task.CurrentStep++
+			continue
+		}
+
 		// Check if we should create a checkpoint
 		if p.options.EnableCheckpoints &&
 			task.CurrentStep > 0 &&
@@ -148,12 +169,32 @@ func (p *Planner) ExecutePlan(taskID string) error {
 			if p.metricsCollector != nil {
 				p.metricsCollector.RecordStepSkipped(task.ID, step.ID)
 			}
+			p.journalStep(task, step)
 			task.CurrentStep++
 			continue
 		}
 
-		// Execute step
-		if err := p.executeStep(task, step); err != nil {
+		// Pause for approval at flagged steps; denied steps are skipped along with their dependents
+		if step.RequiresApproval && !approvedSteps[step.ID] {
+			if !p.awaitApproval(task, step) {
+				step.Status = StepStatusSkipped
+				if p.metricsCollector != nil {
+					p.metricsCollector.RecordStepSkipped(task.ID, step.ID)
+				}
+				p.journalStep(task, step)
+				task.CurrentStep++
+				continue
+			}
+			approvedSteps[step.ID] = true
+		}
+
+		// Execute step, journaling every attempt
+		err := p.executeStep(ctx, task, step)
+		p.journalStep(task, step)
+		if err != nil {
+			if ctx.Err() != nil {
+				return p.finishCancelled(task)
+			}
 			if step.Retryable && step.Result.Retries < step.MaxRetries {
 				// Retry the step
 				p.logWarning(task.ID, step.ID, fmt.Sprintf("Step failed, retrying (%d/%d)",
@@ -180,17 +221,14 @@ func (p *Planner) ExecutePlan(taskID string) error {
 			p.mu.Lock()
 			p.mu.Unlock()
 
+			if saveErr := p.saveProgress(task); saveErr != nil {
+				p.logWarning(task.ID, "", "Failed to save final state: "+saveErr.Error())
+			}
+
 			return serr.Wrap(err, fmt.Sprintf("step %s failed", step.ID))
 		}
 
 		task.CurrentStep++
-
-		// Save progress periodically
-		if task.CurrentStep%3 == 0 {
-			if err := p.saveProgress(task); err != nil {
-				p.logWarning(task.ID, "", "Failed to save progress: "+err.Error())
-			}
-		}
 	}
 
 	// Task completed successfully
@@ -199,6 +237,14 @@ func (p *Planner) ExecutePlan(taskID string) error {
 	task.EndTime = &endTime
 	task.CompletedAt = &endTime
 
+	// End metrics collection
+	if p.metricsCollector != nil {
+		metrics, _ := p.metricsCollector.EndPlanExecution(task.ID)
+		if metrics != nil {
+			p.logInfo(task.ID, "", GenerateMetricsReport(metrics))
+		}
+	}
+
 	p.mu.Lock()
 	p.mu.Unlock()
 
@@ -211,8 +257,9 @@ func (p *Planner) ExecutePlan(taskID string) error {
 	return nil
 }
 
-// executeStep executes a single step
-func (p *Planner) executeStep(task *TaskPlanner, step *TaskStep) error {
+// executeStep executes a single step. Cancelling ctx interrupts the step's tool
+// and undoes its file changes where possible.
+func (p *Planner) executeStep(ctx context.Context, task *TaskPlanner, step *TaskStep) error {
 	startTime := time.Now()
 	step.StartTime = &startTime
 	step.Status = StepStatusRunning
@@ -227,10 +274,16 @@ func (p *Planner) executeStep(task *TaskPlanner, step *TaskStep) error {
 		}
 	}
 
+	// Remember the file this step targets so it can be restored if the step is cancelled
+	undo := p.captureStepFile(step, task.Context)
+
 	// Execute with timeout
+	stepCtx, cancel := context.WithTimeout(ctx, p.options.TimeoutPerStep)
+	defer cancel()
+
 	done := make(chan error, 1)
 	go func() {
-		result, err := p.executor.Execute(step, task.Context)
+		result, err := p.executor.ExecuteContext(stepCtx, step, task.Context)
 		if err != nil {
 			done <- err
 			return
@@ -239,12 +292,16 @@ func (p *Planner) executeStep(task *TaskPlanner, step *TaskStep) error {
 		done <- nil
 	}()
 
-	// Wait for completion or timeout
+	// Wait for completion, cancellation or timeout
 	select {
 	case err := <-done:
 		endTime := time.Now()
 		step.EndTime = &endTime
 
+		if err != nil && ctx.Err() != nil {
+			return p.cancelStep(task, step, undo)
+		}
+
 		if err != nil {
 			step.Status = StepStatusFailed
 			if step.Result == nil {
@@ -295,6 +352,13 @@ func (p *Planner) executeStep(task *TaskPlanner, step *TaskStep) error {
 			p.metricsCollector.RecordFileModification(task.ID, step.ID, []string{}, bytesWritten)
 		}
 
+		// Steps that measure test coverage report it as a plan metric
+		if output, ok := step.Result.Output.(string); ok && p.metricsCollector != nil {
+			if before, after, ok := tools.ParseCoverageResult(output); ok {
+				p.metricsCollector.RecordCoverage(task.ID, before, after)
+			}
+		}
+
 		// End step metrics
 		if p.metricsCollector != nil {
 			p.metricsCollector.EndStepExecution(task.ID, step.ID, true, nil)
@@ -302,7 +366,16 @@ func (p *Planner) executeStep(task *TaskPlanner, step *TaskStep) error {
 
 		return nil
 
-	case <-time.After(p.options.TimeoutPerStep):
+	case <-stepCtx.Done():
+		if ctx.Err() != nil {
+			// Give the tool a moment to stop before undoing what it changed
+			select {
+			case <-done:
+			case <-time.After(stepStopGrace):
+			}
+			return p.cancelStep(task, step, undo)
+		}
+
 		endTime := time.Now()
 		step.EndTime = &endTime
 		step.Status = StepStatusFailed
@@ -388,6 +461,11 @@ func (p *Planner) CancelPlan(taskID string) error {
 	endTime := time.Now()
 	task.EndTime = &endTime
 
+	// Interrupt the running step, if the plan is executing
+	if cancel, running := p.cancels[taskID]; running {
+		cancel()
+	}
+
 	p.logInfo(taskID, "", "Task cancelled")
 	return nil
 }
@@ -622,40 +700,51 @@ func (p *Planner) CreatePlanFromTemplate(templateName string, variables map[stri
 		return nil, serr.New("template not found")
 	}
 
-	// Validate required variables
+	// Fill in defaults and validate required variables
+	declared := make(map[string]bool, len(template.Variables))
+	vars := make(map[string]interface{}, len(variables))
+	for name, value := range variables {
+		vars[name] = value
+	}
 	for _, varDef := range template.Variables {
-		if varDef.Required {
-			if _, ok := variables[varDef.Name]; !ok {
-				return nil, serr.New(fmt.Sprintf("required variable %s not provided", varDef.Name))
-			}
+		declared[varDef.Name] = true
+		if _, ok := vars[varDef.Name]; ok {
+			continue
+		}
+		if varDef.DefaultValue != nil {
+			vars[varDef.Name] = varDef.DefaultValue
+		} else if varDef.Required {
+			return nil, serr.New(fmt.Sprintf("required variable %s not provided", varDef.Name))
 		}
 	}
 
 	// Build description
-	description := template.Description
-	for name, value := range variables {
-		description = strings.ReplaceAll(description, "${"+name+"}", fmt.Sprintf("%v", value))
-	}
+	description := interpolateVariables(template.Description, vars)
 
 	// Convert template steps to task steps
 	steps := make([]TaskStep, 0, len(template.Steps))
 	for _, tmplStep := range template.Steps {
 		step := TaskStep{
 			ID:           tmplStep.ID,
-			Description:  tmplStep.Description,
+			Description:  interpolateVariables(tmplStep.Description, vars),
 			Tool:         tmplStep.Tool,
 			Params:       make(map[string]interface{}),
-			Dependencies: make([]string, 0),
+			Dependencies: append(make([]string, 0, len(tmplStep.Dependencies)), tmplStep.Dependencies...),
 			Retryable:    true,
 			MaxRetries:   p.options.MaxRetries,
 			Status:       StepStatusPending,
 		}
 
-		// Map parameters
-		for paramName, varName := range tmplStep.ParamMapping {
-			if value, ok := variables[varName]; ok {
-				step.Params[paramName] = value
+		// Map parameters - a declared variable name passes the value through unchanged,
+		// anything else is a literal that may contain ${var} placeholders
+		for paramName, mapping := range tmplStep.ParamMapping {
+			if declared[mapping] {
+				if value, ok := vars[mapping]; ok {
+					step.Params[paramName] = value
+				}
+				continue
 			}
+			step.Params[paramName] = interpolateVariables(mapping, vars)
 		}
 
 		// TODO: Handle conditions and branching
@@ -676,6 +765,7 @@ func (p *Planner) CreatePlanWithSteps(description string, steps []TaskStep) (*Ta
 		return nil, serr.New(fmt.Sprintf("task requires %d steps, exceeds maximum of %d",
 			len(steps), p.options.MaxSteps))
 	}
+	flagApprovalSteps(steps)
 
 	task := &TaskPlanner{
 		ID:          uuid.New().String(),
@@ -722,8 +812,8 @@ func (p *Planner) addLog(taskID, level, stepID, message string, details interfac
 		Details:   details,
 	}
 
-	p.mu.Lock()
-	defer p.mu.Unlock()
+	p.logMu.Lock()
+	defer p.logMu.Unlock()
 
 	if _, exists := p.logs[taskID]; !exists {
 		p.logs[taskID] = make([]ExecutionLog, 0)
@@ -733,8 +823,8 @@ func (p *Planner) addLog(taskID, level, stepID, message string, details interfac
 
 // GetLogs returns logs for a task
 func (p *Planner) GetLogs(taskID string) ([]ExecutionLog, error) {
-	p.mu.RLock()
-	defer p.mu.RUnlock()
+	p.logMu.Lock()
+	defer p.logMu.Unlock()
 
 	logs, exists := p.logs[taskID]
 	if !exists {
@@ -773,49 +863,6 @@ func (p *Planner) SetDatabaseStore(store interface{}) {
 	p.dbStore = store
 }
 
-// saveProgress saves the current task progress to the database
-func (p *Planner) saveProgress(task *TaskPlanner) error {
-	if p.dbStore == nil {
-		return nil // No database configured, skip saving
-	}
-
-	// Use type assertion to call SavePlan method
-	type dbInterface interface {
-		SavePlan(plan interface{}) error
-	}
-
-	_, ok := p.dbStore.(dbInterface)
-	if !ok {
-		return nil // Database doesn't support SavePlan
-	}
-
-	// Create a simple map representation
-	planData := map[string]interface{}{
-		"ID":          task.ID,
-		"SessionID":   task.SessionID,
-		"Description": task.Description,
-		"Status":      string(task.Status),
-		"CreatedAt":   task.CreatedAt,
-		"UpdatedAt":   time.Now(),
-		"CompletedAt": task.CompletedAt,
-	}
-
-	// Marshal complex fields
-	if stepsJSON, err := json.Marshal(task.Steps); err == nil {
-		planData["Steps"] = stepsJSON
-	}
-	if contextJSON, err := json.Marshal(task.Context); err == nil {
-		planData["Context"] = contextJSON
-	}
-	if checkpointsJSON, err := json.Marshal(task.Checkpoints); err == nil {
-		planData["Checkpoints"] = checkpointsJSON
-	}
-
-	// For now, just log that we would save
-	p.logInfo(task.ID, "", "Progress saved to database")
-	return nil
-}
-
 // shouldUseParallelExecution determines if a task can benefit from parallel execution
 func (p *Planner) shouldUseParallelExecution(task *TaskPlanner) bool {
 	// Don't use parallel execution if we're resuming from a checkpoint
@@ -823,6 +870,11 @@ func (p *Planner) shouldUseParallelExecution(task *TaskPlanner) bool {
 		return false
 	}
 
+	// Approval gates pause execution step by step
+	if hasApprovalGates(task) {
+		return false
+	}
+
 	// Check if any steps have dependencies
 	hasDependencies := false
 	for _, step := range task.Steps {
@@ -844,7 +896,7 @@ func (p *Planner) shouldUseParallelExecution(task *TaskPlanner) bool {
 }
 
 // executeParallel executes a task plan using parallel execution
-func (p *Planner) executeParallel(task *TaskPlanner) error {
+func (p *Planner) executeParallel(ctx context.Context, task *TaskPlanner) error {
 	p.logInfo(task.ID, "", "Using parallel execution strategy")
 
 	// Analyze parallelizability
@@ -852,28 +904,43 @@ func (p *Planner) executeParallel(task *TaskPlanner) error {
 	p.logInfo(task.ID, "", fmt.Sprintf("Parallel analysis: max parallelism=%d, estimated speedup=%.2fx",
 		analysis.MaxParallelism, analysis.EstimatedSpeedup))
 
-	// Execute all steps in parallel
-	results, err := p.parallelExecutor.ExecuteSteps(task.Steps, task.Context)
+	// Execute all steps in parallel, journaling each as it finishes so a plan stopped
+	// midway is recovered without running finished steps again. The executor works on a
+	// copy of the steps, as the journal updates the plan's own.
+	var journalMu sync.Mutex
+	steps := append([]TaskStep(nil), task.Steps...)
+	results, err := p.parallelExecutor.ExecuteStepsContext(ctx, steps, task.Context, func(stepID string, result *StepResult) {
+		journalMu.Lock()
+		defer journalMu.Unlock()
+		if step := p.applyParallelResult(task, stepID, result); step != nil {
+			p.journalStep(task, step)
+		}
+	})
+	if ctx.Err() != nil {
+		p.applyParallelResults(task, results)
+		return p.finishCancelled(task)
+	}
 	if err != nil {
+		p.applyParallelResults(task, results)
 		task.Status = TaskStatusFailed
 		endTime := time.Now()
 		task.EndTime = &endTime
-		return err
-	}
 
-	// Update step results
-	for i := range task.Steps {
-		step := &task.Steps[i]
-		if result, exists := results[step.ID]; exists {
-			step.Result = result
-			if result.Success {
-				step.Status = StepStatusCompleted
-			} else {
-				step.Status = StepStatusFailed
+		if p.metricsCollector != nil {
+			metrics, _ := p.metricsCollector.EndPlanExecution(task.ID)
+			if metrics != nil {
+				p.logInfo(task.ID, "", GenerateMetricsReport(metrics))
 			}
 		}
+		if saveErr := p.saveProgress(task); saveErr != nil {
+			p.logWarning(task.ID, "", "Failed to save final state: "+saveErr.Error())
+		}
+		return err
 	}
 
+	// Update step results
+	p.applyParallelResults(task, results)
+
 	// Task completed
 	task.Status = TaskStatusCompleted
 	endTime := time.Now()
@@ -890,6 +957,31 @@ func (p *Planner) executeParallel(task *TaskPlanner) error {
 	return nil
 }
 
+// applyParallelResults copies the results of parallel execution onto the plan's steps
+func (p *Planner) applyParallelResults(task *TaskPlanner, results map[string]*StepResult) {
+	for stepID, result := range results {
+		p.applyParallelResult(task, stepID, result)
+	}
+}
+
+// applyParallelResult copies a step's result onto the plan's step, and returns the step
+func (p *Planner) applyParallelResult(task *TaskPlanner, stepID string, result *StepResult) *TaskStep {
+	for i := range task.Steps {
+		step := &task.Steps[i]
+		if step.ID != stepID {
+			continue
+		}
+		step.Result = result
+		if result.Success {
+			step.Status = StepStatusCompleted
+		} else {
+			step.Status = StepStatusFailed
+		}
+		return step
+	}
+	return nil
+}
+
 // AnalyzeParallelizability exposes parallel analysis for a task
 func (p *Planner) AnalyzeParallelizability(taskID string) (*ParallelAnalysis, error) {
 	p.mu.RLock()
@@ -0,0 +1,216 @@
+package planner
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/rohanthewiz/serr"
+)
+
+// StepEdit describes changes to a single step of a pending plan.
+// Nil fields are left unchanged; Params and Dependencies replace the existing values.
+type StepEdit struct {
+	Description      *string                `json:"description,omitempty"`
+	Tool             *string                `json:"tool,omitempty"`
+	Params           map[string]interface{} `json:"params,omitempty"`
+	Dependencies     []string               `json:"dependencies,omitempty"`
+	Retryable        *bool                  `json:"retryable,omitempty"`
+	MaxRetries       *int                   `json:"max_retries,omitempty"`
+	RequiresApproval *bool                  `json:"requires_approval,omitempty"`
+}
+
+// ensureEditable rejects edits once a plan has started running
+func (t *TaskPlanner) ensureEditable() error {
+	if t.Status != TaskStatusPending {
+		return serr.New(fmt.Sprintf("plan steps can only be edited while pending, plan is %s", t.Status))
+	}
+	return nil
+}
+
+// stepIndex returns the position of a step, or -1 if absent
+func (t *TaskPlanner) stepIndex(stepID string) int {
+	for i := range t.Steps {
+		if t.Steps[i].ID == stepID {
+			return i
+		}
+	}
+	return -1
+}
+
+// ReorderSteps rearranges the steps of a pending plan. order must list every step ID exactly once.
+func (t *TaskPlanner) ReorderSteps(order []string) error {
+	if err := t.ensureEditable(); err != nil {
+		return err
+	}
+	if len(order) != len(t.Steps) {
+		return serr.New(fmt.Sprintf("order lists %d steps, plan has %d", len(order), len(t.Steps)))
+	}
+
+	reordered := make([]TaskStep, 0, len(t.Steps))
+	seen := make(map[string]bool, len(order))
+	for _, id := range order {
+		if seen[id] {
+			return serr.New(fmt.Sprintf("step %s listed more than once", id))
+		}
+		seen[id] = true
+
+		idx := t.stepIndex(id)
+		if idx < 0 {
+			return serr.New(fmt.Sprintf("step %s not found", id))
+		}
+		reordered = append(reordered, t.Steps[idx])
+	}
+
+	if err := ValidateStepDependencies(reordered); err != nil {
+		return err
+	}
+
+	t.Steps = reordered
+	return nil
+}
+
+// UpdateStep applies an edit to a single step of a pending plan and returns the updated step
+func (t *TaskPlanner) UpdateStep(stepID string, edit StepEdit) (*TaskStep, error) {
+	if err := t.ensureEditable(); err != nil {
+		return nil, err
+	}
+
+	idx := t.stepIndex(stepID)
+	if idx < 0 {
+		return nil, serr.New(fmt.Sprintf("step %s not found", stepID))
+	}
+
+	// Work on a copy so a failed validation leaves the plan untouched
+	step := t.Steps[idx]
+	if edit.Description != nil {
+		step.Description = *edit.Description
+	}
+	if edit.Tool != nil {
+		step.Tool = *edit.Tool
+		if approvalRequiredTools[step.Tool] && edit.RequiresApproval == nil {
+			step.RequiresApproval = true
+		}
+	}
+	if edit.Params != nil {
+		step.Params = edit.Params
+	}
+	if edit.Dependencies != nil {
+		step.Dependencies = edit.Dependencies
+	}
+	if edit.Retryable != nil {
+		step.Retryable = *edit.Retryable
+	}
+	if edit.RequiresApproval != nil {
+		step.RequiresApproval = *edit.RequiresApproval
+	}
+	if edit.MaxRetries != nil {
+		if *edit.MaxRetries < 0 {
+			return nil, serr.New("max_retries cannot be negative")
+		}
+		step.MaxRetries = *edit.MaxRetries
+	}
+
+	steps := make([]TaskStep, len(t.Steps))
+	copy(steps, t.Steps)
+	steps[idx] = step
+	if err := ValidateStepDependencies(steps); err != nil {
+		return nil, err
+	}
+
+	t.Steps = steps
+	return &t.Steps[idx], nil
+}
+
+// InsertStep adds a step to a pending plan at position, or appends it when position is nil.
+// A missing step ID is generated and the step is reset to pending.
+func (t *TaskPlanner) InsertStep(step TaskStep, position *int) (*TaskStep, error) {
+	if err := t.ensureEditable(); err != nil {
+		return nil, err
+	}
+
+	pos := len(t.Steps)
+	if position != nil {
+		pos = *position
+	}
+	if pos < 0 || pos > len(t.Steps) {
+		return nil, serr.New(fmt.Sprintf("position %d out of range", pos))
+	}
+
+	if step.ID == "" {
+		step.ID = fmt.Sprintf("step_%d_%s", len(t.Steps)+1, uuid.New().String()[:8])
+	}
+	if step.Params == nil {
+		step.Params = make(map[string]interface{})
+	}
+	if step.Dependencies == nil {
+		step.Dependencies = make([]string, 0)
+	}
+	if approvalRequiredTools[step.Tool] {
+		step.RequiresApproval = true
+	}
+	step.Status = StepStatusPending
+	step.Result = nil
+	step.StartTime = nil
+	step.EndTime = nil
+
+	steps := make([]TaskStep, 0, len(t.Steps)+1)
+	steps = append(steps, t.Steps[:pos]...)
+	steps = append(steps, step)
+	steps = append(steps, t.Steps[pos:]...)
+	if err := ValidateStepDependencies(steps); err != nil {
+		return nil, err
+	}
+
+	t.Steps = steps
+	return &t.Steps[pos], nil
+}
+
+// RemoveStep deletes a step from a pending plan. Steps that depend on it must be edited first.
+func (t *TaskPlanner) RemoveStep(stepID string) error {
+	if err := t.ensureEditable(); err != nil {
+		return err
+	}
+
+	idx := t.stepIndex(stepID)
+	if idx < 0 {
+		return serr.New(fmt.Sprintf("step %s not found", stepID))
+	}
+
+	for _, other := range t.Steps {
+		if contains(other.Dependencies, stepID) {
+			return serr.New(fmt.Sprintf("step %s is a dependency of step %s", stepID, other.ID))
+		}
+	}
+
+	t.Steps = append(t.Steps[:idx], t.Steps[idx+1:]...)
+	return nil
+}
+
+// ValidateStepDependencies checks step IDs are unique and that every dependency
+// refers to a step earlier in the plan, as sequential execution requires
+func ValidateStepDependencies(steps []TaskStep) error {
+	position := make(map[string]int, len(steps))
+	for i, step := range steps {
+		if step.ID == "" {
+			return serr.New(fmt.Sprintf("step at position %d has no ID", i))
+		}
+		if _, dup := position[step.ID]; dup {
+			return serr.New(fmt.Sprintf("duplicate step ID: %s", step.ID))
+		}
+		position[step.ID] = i
+	}
+
+	for i, step := range steps {
+		for _, dep := range step.Dependencies {
+			depPos, ok := position[dep]
+			if !ok {
+				return serr.New(fmt.Sprintf("step %s depends on unknown step %s", step.ID, dep))
+			}
+			if depPos >= i {
+				return serr.New(fmt.Sprintf("step %s must come after its dependency %s", step.ID, dep))
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,296 @@
+package planner
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// Template categories used by the built-in templates
+const (
+	TemplateCategoryFeature     = "feature"
+	TemplateCategoryTesting     = "testing"
+	TemplateCategoryMaintenance = "maintenance"
+)
+
+var templateVarPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Validate checks that a template is well formed before it is stored or loaded
+func (t *TaskTemplate) Validate() error {
+	if t.Name == "" {
+		return serr.New("template name is required")
+	}
+	if len(t.Steps) == 0 {
+		return serr.New("template must have at least one step")
+	}
+
+	varNames := make(map[string]bool, len(t.Variables))
+	for _, v := range t.Variables {
+		if v.Name == "" {
+			return serr.New("template variable name is required")
+		}
+		if varNames[v.Name] {
+			return serr.New(fmt.Sprintf("duplicate template variable: %s", v.Name))
+		}
+		varNames[v.Name] = true
+	}
+
+	stepIDs := make(map[string]bool, len(t.Steps))
+	for _, step := range t.Steps {
+		if step.ID == "" {
+			return serr.New("template step id is required")
+		}
+		if stepIDs[step.ID] {
+			return serr.New(fmt.Sprintf("duplicate template step id: %s", step.ID))
+		}
+		if step.Tool == "" {
+			return serr.New(fmt.Sprintf("template step %s has no tool", step.ID))
+		}
+		stepIDs[step.ID] = true
+	}
+
+	for _, step := range t.Steps {
+		for _, dep := range step.Dependencies {
+			if !stepIDs[dep] {
+				return serr.New(fmt.Sprintf("template step %s depends on unknown step %s", step.ID, dep))
+			}
+		}
+	}
+
+	return nil
+}
+
+// interpolateVariables replaces ${name} placeholders with their values.
+// Unknown placeholders are left untouched so missing optional variables are visible.
+func interpolateVariables(s string, vars map[string]interface{}) string {
+	return templateVarPattern.ReplaceAllStringFunc(s, func(match string) string {
+		name := templateVarPattern.FindStringSubmatch(match)[1]
+		if value, ok := vars[name]; ok {
+			return fmt.Sprintf("%v", value)
+		}
+		return match
+	})
+}
+
+// BuiltinTemplates returns the templates shipped with rcode
+func BuiltinTemplates() []*TaskTemplate {
+	return []*TaskTemplate{
+		{
+			Name:        "add_endpoint",
+			Description: "Add ${method} ${route} endpoint handled by ${handler_name}",
+			Category:    TemplateCategoryFeature,
+			Variables: []VariableDefinition{
+				{Name: "route", Type: "string", Description: "Route path, e.g. /api/widgets/:id", Required: true},
+				{Name: "handler_name", Type: "string", Description: "Name of the handler function", Required: true},
+				{Name: "handler_file", Type: "string", Description: "File to create for the handler", Required: true},
+				{Name: "method", Type: "string", Description: "Router method (Get, Post, Put, Delete)", DefaultValue: "Get"},
+				{Name: "package", Type: "string", Description: "Go package of the handler file", DefaultValue: "web"},
+				{Name: "routes_file", Type: "string", Description: "File where routes are registered", DefaultValue: "web/routes.go"},
+				{Name: "routes_anchor", Type: "string", Description: "Line in the routes file after which to register the route", DefaultValue: "// API endpoints"},
+			},
+			Steps: []TaskStepTemplate{
+				{
+					ID:           "review_routes",
+					Description:  "Review existing routes in ${routes_file}",
+					Tool:         "read_file",
+					ParamMapping: map[string]string{"path": "routes_file"},
+				},
+				{
+					ID:          "create_handler",
+					Description: "Create ${handler_name} in ${handler_file}",
+					Tool:        "write_file",
+					ParamMapping: map[string]string{
+						"path": "handler_file",
+						"content": "package ${package}\n\nimport (\n\t\"github.com/rohanthewiz/rweb\"\n)\n\n" +
+							"// ${handler_name} handles ${method} ${route}\n" +
+							"func ${handler_name}(c rweb.Context) error {\n\treturn c.WriteJSON(map[string]interface{}{\"status\": \"ok\"})\n}\n",
+					},
+				},
+				{
+					ID:          "register_route",
+					Description: "Register ${route} in ${routes_file}",
+					Tool:        "smart_edit",
+					ParamMapping: map[string]string{
+						"path":        "routes_file",
+						"mode":        "replace",
+						"pattern":     "${routes_anchor}",
+						"replacement": "${routes_anchor}\n\ts.${method}(\"${route}\", ${handler_name})",
+					},
+					Dependencies: []string{"review_routes", "create_handler"},
+				},
+				{
+					ID:           "build",
+					Description:  "Build and vet the project",
+					Tool:         "bash",
+					ParamMapping: map[string]string{"command": "go build ./... && go vet ./..."},
+					Dependencies: []string{"register_route"},
+				},
+			},
+		},
+		{
+			Name:        "write_tests",
+			Description: "Write tests for ${subject} in ${source_file}",
+			Category:    TemplateCategoryTesting,
+			Variables: []VariableDefinition{
+				{Name: "source_file", Type: "string", Description: "File containing the code under test", Required: true},
+				{Name: "subject", Type: "string", Description: "Name of the function or method under test", Required: true},
+				{Name: "package_path", Type: "string", Description: "Package of the source file, as go test takes it", DefaultValue: "./..."},
+			},
+			Steps: []TaskStepTemplate{
+				{
+					ID:           "read_source",
+					Description:  "Read ${source_file}",
+					Tool:         "read_file",
+					ParamMapping: map[string]string{"path": "source_file"},
+				},
+				{
+					ID:          "find_existing_tests",
+					Description: "Look for existing tests of ${subject}",
+					Tool:        "ripgrep",
+					ParamMapping: map[string]string{
+						"pattern": "func Test${subject}",
+						"glob":    "*_test.go",
+					},
+				},
+				{
+					// generate_tests writes tests for the parts of the subject the tests don't
+					// cover yet, keeping only those that pass, in a file of their own
+					ID:          "write_test_file",
+					Description: "Write tests for what ${subject} has uncovered",
+					Tool:        "generate_tests",
+					ParamMapping: map[string]string{
+						"packages": "package_path",
+						"file":     "source_file",
+						"function": "subject",
+					},
+					Dependencies: []string{"read_source", "find_existing_tests"},
+				},
+				{
+					ID:           "run_tests",
+					Description:  "Run the tests of ${package_path} and measure their coverage",
+					Tool:         "test_coverage",
+					ParamMapping: map[string]string{"packages": "package_path"},
+					Dependencies: []string{"write_test_file"},
+				},
+			},
+		},
+		{
+			Name:        "improve_coverage",
+			Description: "Raise the test coverage of ${packages}",
+			Category:    TemplateCategoryTesting,
+			Variables: []VariableDefinition{
+				{Name: "packages", Type: "string", Description: "Packages to cover, as go test takes them", DefaultValue: "./..."},
+				{Name: "functions", Type: "integer", Description: "Functions to write tests for in each round", DefaultValue: 5},
+				{Name: "rounds", Type: "integer", Description: "Rounds of writing tests and measuring", DefaultValue: 3},
+			},
+			Steps: []TaskStepTemplate{
+				{
+					ID:           "measure_coverage",
+					Description:  "Measure the coverage of ${packages}",
+					Tool:         "test_coverage",
+					ParamMapping: map[string]string{"packages": "packages"},
+				},
+				{
+					ID:          "generate_tests",
+					Description: "Write tests for the least covered functions, up to ${rounds} rounds of ${functions}",
+					Tool:        "generate_tests",
+					ParamMapping: map[string]string{
+						"packages":  "packages",
+						"functions": "functions",
+						"rounds":    "rounds",
+					},
+					Dependencies: []string{"measure_coverage"},
+				},
+				{
+					ID:           "remeasure_coverage",
+					Description:  "Measure the coverage of ${packages} again",
+					Tool:         "test_coverage",
+					ParamMapping: map[string]string{"packages": "packages"},
+					Dependencies: []string{"generate_tests"},
+				},
+				{
+					ID:           "review_changes",
+					Description:  "Review the test files written",
+					Tool:         "git_status",
+					ParamMapping: map[string]string{},
+					Dependencies: []string{"remeasure_coverage"},
+				},
+			},
+		},
+		{
+			Name:        "bump_dependency",
+			Description: "Bump ${module} to ${version}",
+			Category:    TemplateCategoryMaintenance,
+			Variables: []VariableDefinition{
+				{Name: "module", Type: "string", Description: "Module path, e.g. github.com/google/uuid", Required: true},
+				{Name: "version", Type: "string", Description: "Target version", DefaultValue: "latest"},
+			},
+			Steps: []TaskStepTemplate{
+				{
+					ID:           "current_version",
+					Description:  "Show the current version of ${module}",
+					Tool:         "bash",
+					ParamMapping: map[string]string{"command": "go list -m ${module}"},
+				},
+				{
+					ID:           "update",
+					Description:  "Update ${module} to ${version}",
+					Tool:         "bash",
+					ParamMapping: map[string]string{"command": "go get ${module}@${version} && go mod tidy"},
+					Dependencies: []string{"current_version"},
+				},
+				{
+					ID:           "build_and_test",
+					Description:  "Build and test against the new version",
+					Tool:         "bash",
+					ParamMapping: map[string]string{"command": "go build ./... && go test ./..."},
+					Dependencies: []string{"update"},
+				},
+				{
+					ID:           "review_changes",
+					Description:  "Review module file changes",
+					Tool:         "git_diff",
+					ParamMapping: map[string]string{"file": "go.mod"},
+					Dependencies: []string{"build_and_test"},
+				},
+			},
+		},
+		{
+			Name:        "update_dependencies",
+			Description: "Update dependencies (${dependencies}), keeping the updates the tests pass with",
+			Category:    TemplateCategoryMaintenance,
+			Variables: []VariableDefinition{
+				{Name: "dependencies", Type: "string", Description: "Modules or packages to update, or all for every outdated direct dependency", DefaultValue: "all"},
+				{Name: "ecosystem", Type: "string", Description: "go or npm, when the project has both and only one is to be updated"},
+				{Name: "test_command", Type: "string", Description: "Command checking each update, instead of go build and go test or npm test"},
+			},
+			Steps: []TaskStepTemplate{
+				{
+					ID:           "list_outdated",
+					Description:  "List the outdated dependencies",
+					Tool:         "outdated_dependencies",
+					ParamMapping: map[string]string{"ecosystem": "ecosystem"},
+				},
+				{
+					ID:          "update",
+					Description: "Update ${dependencies} one at a time, running the tests after each",
+					Tool:        "update_dependency",
+					ParamMapping: map[string]string{
+						"dependencies": "dependencies",
+						"ecosystem":    "ecosystem",
+						"test_command": "test_command",
+					},
+					Dependencies: []string{"list_outdated"},
+				},
+				{
+					ID:           "review_changes",
+					Description:  "Review the changes to the manifests",
+					Tool:         "git_diff",
+					ParamMapping: map[string]string{},
+					Dependencies: []string{"update"},
+				},
+			},
+		},
+	}
+}
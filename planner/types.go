@@ -2,6 +2,8 @@ package planner
 
 import (
 	"time"
+
+	"rcode/completion"
 )
 
 // TaskPlanner manages multi-step task execution
@@ -23,17 +25,18 @@ type TaskPlanner struct {
 
 // TaskStep represents a single step in a task plan
 type TaskStep struct {
-	ID           string                 `json:"id"`
-	Description  string                 `json:"description"`
-	Tool         string                 `json:"tool"`
-	Params       map[string]interface{} `json:"params"`
-	Dependencies []string               `json:"dependencies"`
-	Retryable    bool                   `json:"retryable"`
-	MaxRetries   int                    `json:"max_retries"`
-	Status       StepStatus             `json:"status"`
-	Result       *StepResult            `json:"result,omitempty"`
-	StartTime    *time.Time             `json:"start_time,omitempty"`
-	EndTime      *time.Time             `json:"end_time,omitempty"`
+	ID               string                 `json:"id"`
+	Description      string                 `json:"description"`
+	Tool             string                 `json:"tool"`
+	Params           map[string]interface{} `json:"params"`
+	Dependencies     []string               `json:"dependencies"`
+	Retryable        bool                   `json:"retryable"`
+	MaxRetries       int                    `json:"max_retries"`
+	RequiresApproval bool                   `json:"requires_approval,omitempty"` // Pause for user approval before running
+	Status           StepStatus             `json:"status"`
+	Result           *StepResult            `json:"result,omitempty"`
+	StartTime        *time.Time             `json:"start_time,omitempty"`
+	EndTime          *time.Time             `json:"end_time,omitempty"`
 }
 
 // StepResult contains the result of executing a step
@@ -61,6 +64,8 @@ type TaskContext struct {
 	Variables        map[string]interface{} `json:"variables"`
 	Files            []string               `json:"files"`
 	ModifiedFiles    []string               `json:"modified_files"`
+	SessionID        string                 `json:"-"` // Passed to the steps' tools, as in a chat, so they know whose work it is
+	PlanID           string                 `json:"-"`
 }
 
 // TaskState represents the state of a task at a checkpoint
@@ -105,6 +110,7 @@ type PlannerOptions struct {
 	MaxConcurrentSteps int
 	CheckpointInterval int
 	ContextManager     interface{} // Will be *context.Manager but avoid import cycle
+	ModelClient        completion.Client // When set, tasks are decomposed by the model with heuristic fallback
 }
 
 // DefaultPlannerOptions returns default planner options
@@ -133,6 +139,7 @@ type TaskStepTemplate struct {
 	Description  string                 `json:"description"`
 	Tool         string                 `json:"tool"`
 	ParamMapping map[string]string      `json:"param_mapping"` // Maps template vars to tool params
+	Dependencies []string               `json:"dependencies,omitempty"`
 	Conditions   []StepCondition        `json:"conditions"`
 	OnSuccess    []string               `json:"on_success"` // Next steps on success
 	OnFailure    []string               `json:"on_failure"` // Next steps on failure
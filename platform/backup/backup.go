@@ -0,0 +1,407 @@
+// Package backup snapshots the database and the .rcode state directories into a backups
+// directory, and restores them, checking each backup against its manifest first.
+//
+// A backup is a directory named for when it was taken, holding the database copy, an archive
+// of the state directories and manifest.json, which records the files' sizes and SHA-256 sums
+// and the database's migration version. It is written under a temporary name and renamed
+// once complete, so an interrupted backup is never listed.
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/serr"
+	"rcode/config"
+	"rcode/db"
+)
+
+const (
+	manifestFile = "manifest.json"
+	databaseFile = "rcode.db"
+	stateFile    = "state.tar.gz"
+	namePrefix   = "rcode-"
+	nameLayout   = "20060102-150405"
+	tmpPrefix    = ".tmp-"
+)
+
+// Manifest describes a backup
+type Manifest struct {
+	Name          string    `json:"name"`
+	CreatedAt     time.Time `json:"created_at"`
+	Backend       string    `json:"backend"`
+	SchemaVersion int       `json:"schema_version"` // Latest migration applied to the database copy
+	StateDirs     []string  `json:"state_dirs"`     // Names of the state directories archived, see StateDirs
+	Files         []File    `json:"files"`
+}
+
+// File is a file of a backup, with what it must still be to be restored
+type File struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// StateDirs returns the .rcode directories backed up with the database, by the name they
+// are archived under: the user's ~/.rcode and the project's ./.rcode
+func StateDirs() map[string]string {
+	dirs := make(map[string]string)
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs["home"] = filepath.Join(home, ".rcode")
+	}
+	if wd, err := os.Getwd(); err == nil {
+		dirs["project"] = filepath.Join(wd, ".rcode")
+	}
+	return dirs
+}
+
+// Create backs up the database and the state directories into a new directory in dir
+func Create(database *db.DB, dir string, stateDirs map[string]string) (*Manifest, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, serr.Wrap(err, "failed to create backup directory")
+	}
+
+	now := time.Now()
+	name := namePrefix + now.UTC().Format(nameLayout)
+	if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+		return nil, serr.New("a backup named " + name + " already exists")
+	}
+	tmp := filepath.Join(dir, tmpPrefix+name)
+	if err := os.RemoveAll(tmp); err != nil {
+		return nil, serr.Wrap(err, "failed to clear an earlier attempt at the backup")
+	}
+	if err := os.Mkdir(tmp, 0700); err != nil {
+		return nil, serr.Wrap(err, "failed to create backup")
+	}
+	complete := false
+	defer func() {
+		if !complete {
+			os.RemoveAll(tmp)
+		}
+	}()
+
+	if err := database.BackupTo(filepath.Join(tmp, databaseFile)); err != nil {
+		return nil, err
+	}
+	version, err := database.VerifyBackup(filepath.Join(tmp, databaseFile))
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{Name: name, CreatedAt: now, Backend: config.Get().DBBackend, SchemaVersion: version}
+	if err := archiveState(filepath.Join(tmp, stateFile), stateDirs); err != nil {
+		return nil, err
+	}
+	for key := range stateDirs {
+		manifest.StateDirs = append(manifest.StateDirs, key)
+	}
+	sort.Strings(manifest.StateDirs)
+
+	for _, file := range []string{databaseFile, stateFile} {
+		f, err := describeFile(filepath.Join(tmp, file))
+		if err != nil {
+			return nil, err
+		}
+		manifest.Files = append(manifest.Files, f)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to encode backup manifest")
+	}
+	if err := os.WriteFile(filepath.Join(tmp, manifestFile), data, 0600); err != nil {
+		return nil, serr.Wrap(err, "failed to write backup manifest")
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, name)); err != nil {
+		return nil, serr.Wrap(err, "failed to complete backup")
+	}
+	complete = true
+
+	logger.Info("Backed up database and state", "backup", name, "dir", dir, "schema_version", version)
+	return manifest, nil
+}
+
+// List returns the backups in dir, newest first
+func List(dir string) ([]Manifest, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []Manifest{}, nil
+	}
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to list backups")
+	}
+
+	manifests := []Manifest{}
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), namePrefix) {
+			continue
+		}
+		manifest, err := readManifest(dir, entry.Name())
+		if err != nil {
+			logger.Warn("Skipping unreadable backup", "backup", entry.Name(), "error", err.Error())
+			continue
+		}
+		manifests = append(manifests, *manifest)
+	}
+	sort.Slice(manifests, func(i, j int) bool { return manifests[i].CreatedAt.After(manifests[j].CreatedAt) })
+	return manifests, nil
+}
+
+// Verify checks a backup's files against its manifest and reads all of its database copy,
+// failing if anything has changed or is damaged, or it is newer than this rcode
+func Verify(database *db.DB, dir, name string) (*Manifest, error) {
+	manifest, err := readManifest(dir, name)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.Backend != "" && manifest.Backend != config.Get().DBBackend {
+		return nil, serr.New("backup is of a " + manifest.Backend + " database, not " + config.Get().DBBackend)
+	}
+
+	listed := make(map[string]bool)
+	for _, want := range manifest.Files {
+		listed[want.Name] = true
+	}
+	if !listed[databaseFile] || !listed[stateFile] {
+		return nil, serr.New("backup manifest doesn't list the database and state archive")
+	}
+
+	for _, want := range manifest.Files {
+		if want.Name != filepath.Base(want.Name) {
+			return nil, serr.New("backup manifest lists a file outside the backup: " + want.Name)
+		}
+		got, err := describeFile(filepath.Join(dir, name, want.Name))
+		if err != nil {
+			return nil, err
+		}
+		if got.Size != want.Size || got.SHA256 != want.SHA256 {
+			return nil, serr.New("backup file " + want.Name + " doesn't match its checksum")
+		}
+	}
+
+	if _, err := database.VerifyBackup(filepath.Join(dir, name, databaseFile)); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// Restore verifies a backup and stages its database to replace the current one when rcode
+// next starts. With withState, the state directories' files are restored now, over the
+// current ones; files added since the backup are left.
+func Restore(database *db.DB, dir, name string, stateDirs map[string]string, withState bool) (*Manifest, error) {
+	manifest, err := Verify(database, dir, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if withState {
+		if err := extractState(filepath.Join(dir, name, stateFile), stateDirs); err != nil {
+			return nil, err
+		}
+	}
+	if err := database.StageRestore(filepath.Join(dir, name, databaseFile)); err != nil {
+		return nil, err
+	}
+
+	logger.Warn("Restore staged; restart rcode to finish it", "backup", name, "state", withState)
+	return manifest, nil
+}
+
+// Prune deletes all but the newest keep backups in dir, and returns how many it deleted
+func Prune(dir string, keep int) (int, error) {
+	manifests, err := List(dir)
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	for i := keep; i < len(manifests); i++ {
+		if err := os.RemoveAll(filepath.Join(dir, manifests[i].Name)); err != nil {
+			return deleted, serr.Wrap(err, "failed to delete backup "+manifests[i].Name)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// readManifest reads the manifest of the backup named name in dir
+func readManifest(dir, name string) (*Manifest, error) {
+	if name != filepath.Base(name) || !strings.HasPrefix(name, namePrefix) {
+		return nil, serr.New("invalid backup name: " + name)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, name, manifestFile))
+	if os.IsNotExist(err) {
+		return nil, serr.New("backup " + name + " not found")
+	}
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to read backup manifest")
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, serr.Wrap(err, "failed to parse backup manifest")
+	}
+	if manifest.Name != name {
+		return nil, serr.New("backup " + name + " has the manifest of " + manifest.Name)
+	}
+	return &manifest, nil
+}
+
+// describeFile returns the size and SHA-256 of the file at path
+func describeFile(path string) (File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return File{}, serr.Wrap(err, "failed to open backup file")
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return File{}, serr.Wrap(err, "failed to read backup file")
+	}
+	return File{Name: filepath.Base(path), Size: size, SHA256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+// archiveState writes the state directories' files to a gzipped tar at path, each under
+// its directory's name. Directories that don't exist are left out.
+func archiveState(path string, stateDirs map[string]string) error {
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return serr.Wrap(err, "failed to create state archive")
+	}
+	defer out.Close()
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+
+	keys := make([]string, 0, len(stateDirs))
+	for key := range stateDirs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		root := stateDirs[key]
+		err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) && p == root {
+					return filepath.SkipDir
+				}
+				return err
+			}
+			// Only regular files and directories; sockets and links aren't state
+			if !d.IsDir() && !d.Type().IsRegular() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(root, p)
+			if err != nil {
+				return err
+			}
+			header, err := tar.FileInfoHeader(info, "")
+			if err != nil {
+				return err
+			}
+			header.Name = filepath.ToSlash(filepath.Join(key, rel))
+			if d.IsDir() {
+				header.Name += "/"
+			}
+			if err := tw.WriteHeader(header); err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			f, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, err = io.Copy(tw, f)
+			return err
+		})
+		if err != nil {
+			return serr.Wrap(err, "failed to archive "+root)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return serr.Wrap(err, "failed to write state archive")
+	}
+	if err := gz.Close(); err != nil {
+		return serr.Wrap(err, "failed to write state archive")
+	}
+	if err := out.Sync(); err != nil {
+		return serr.Wrap(err, "failed to sync state archive")
+	}
+	return nil
+}
+
+// extractState writes the files of a state archive back into the state directories.
+// Entries for a directory not in stateDirs, or that would land outside it, are refused.
+func extractState(path string, stateDirs map[string]string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return serr.Wrap(err, "failed to open state archive")
+	}
+	defer in.Close()
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		return serr.Wrap(err, "failed to read state archive")
+	}
+	tr := tar.NewReader(gz)
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return serr.Wrap(err, "failed to read state archive")
+		}
+
+		key, rel, _ := strings.Cut(strings.TrimSuffix(header.Name, "/"), "/")
+		root, ok := stateDirs[key]
+		if !ok {
+			return serr.New("state archive has an entry for an unknown directory: " + header.Name)
+		}
+		target := filepath.Join(root, filepath.FromSlash(rel))
+		if target != root && !strings.HasPrefix(target, root+string(filepath.Separator)) {
+			return serr.New("state archive entry is outside its directory: " + header.Name)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0700); err != nil {
+				return serr.Wrap(err, "failed to restore "+target)
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+				return serr.Wrap(err, "failed to restore "+target)
+			}
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode)&0777)
+			if err != nil {
+				return serr.Wrap(err, "failed to restore "+target)
+			}
+			_, err = io.Copy(out, tr)
+			if closeErr := out.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return serr.Wrap(err, "failed to restore "+target)
+			}
+		}
+	}
+}
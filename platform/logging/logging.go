@@ -0,0 +1,126 @@
+// Package logging sets the logger's level and format, and keeps its recent entries in
+// memory so they can be read back, for one session or request, when debugging from the UI.
+package logging
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/serr"
+	"github.com/sirupsen/logrus"
+)
+
+// bufferSize is how many of the latest entries are kept
+const bufferSize = 5000
+
+// Entry is a log entry as it was written
+type Entry struct {
+	Seq     int64             `json:"seq"` // Increases with each entry, to ask for those after it
+	Time    time.Time         `json:"time"`
+	Level   string            `json:"level"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+// levels orders the levels entries can be filtered by
+var levels = map[string]int{"trace": 0, "debug": 1, "info": 2, "warn": 3, "error": 4, "fatal": 5, "panic": 6}
+
+// Setup sets the lowest level logged (debug, info, warn or error) and the format (text, or
+// json for one JSON object per line), and starts keeping entries for Tail
+func Setup(level, format string) error {
+	switch level {
+	case "debug", "info", "warn", "error":
+	default:
+		return serr.New("log level must be debug, info, warn or error: " + level)
+	}
+	if format != "text" && format != "json" {
+		return serr.New("log format must be text or json: " + format)
+	}
+	logger.SetLogLevel(level)
+	logger.SetLogFormat(format)
+	hookOnce.Do(func() { logrus.AddHook(buffer) })
+	return nil
+}
+
+var (
+	buffer   = &ringHook{entries: make([]Entry, 0, bufferSize)}
+	hookOnce sync.Once
+)
+
+// ringHook keeps the latest entries, overwriting the oldest
+type ringHook struct {
+	mu      sync.Mutex
+	entries []Entry
+	next    int // Where the next entry goes once the buffer is full
+	seq     int64
+}
+
+func (h *ringHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *ringHook) Fire(e *logrus.Entry) error {
+	level := e.Level.String()
+	if level == "warning" {
+		level = "warn"
+	}
+	var fields map[string]string
+	if len(e.Data) > 0 {
+		fields = make(map[string]string, len(e.Data))
+		for key, value := range e.Data {
+			fields[key] = fmt.Sprint(value)
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.seq++
+	entry := Entry{Seq: h.seq, Time: e.Time, Level: level, Message: e.Message, Fields: fields}
+	if len(h.entries) < bufferSize {
+		h.entries = append(h.entries, entry)
+		return nil
+	}
+	h.entries[h.next] = entry
+	h.next = (h.next + 1) % bufferSize
+	return nil
+}
+
+// Filter selects entries for Tail. Empty fields select everything.
+type Filter struct {
+	SessionID string // Entries with this session_id
+	RequestID string // Entries with this request_id
+	Level     string // Entries at this level or above
+	After     int64  // Entries with a higher Seq, to follow the log
+	Limit     int    // The latest this many of those selected; 0 for all that are kept
+}
+
+// Tail returns the latest kept entries the filter selects, oldest first
+func Tail(filter Filter) []Entry {
+	minLevel := levels[filter.Level]
+
+	buffer.mu.Lock()
+	ordered := make([]Entry, 0, len(buffer.entries))
+	ordered = append(ordered, buffer.entries[buffer.next:]...)
+	ordered = append(ordered, buffer.entries[:buffer.next]...)
+	buffer.mu.Unlock()
+
+	var selected []Entry
+	for _, e := range ordered {
+		if e.Seq <= filter.After || levels[e.Level] < minLevel {
+			continue
+		}
+		if filter.SessionID != "" && e.Fields["session_id"] != filter.SessionID {
+			continue
+		}
+		if filter.RequestID != "" && e.Fields["request_id"] != filter.RequestID {
+			continue
+		}
+		selected = append(selected, e)
+	}
+	if filter.Limit > 0 && len(selected) > filter.Limit {
+		selected = selected[len(selected)-filter.Limit:]
+	}
+	return selected
+}
@@ -1,7 +1,6 @@
 package shutdown
 
 import (
-	"fmt"
 	"log"
 	"os"
 	"os/signal"
@@ -23,18 +22,20 @@ type shutdownHooks struct {
 
 var hooks shutdownHooks
 
+// sigChan receives the signal to shut down, from the OS or from Shutdown
+var sigChan = make(chan os.Signal, 1)
+
 func RegisterHook(fn HookFunc) {
 	hooks.lock.Lock()
 	defer hooks.lock.Unlock()
 	hooks.Hooks = append(hooks.Hooks, fn)
-	fmt.Printf("Registered shutdown hook: #%d\n", len(hooks.Hooks))
+	log.Printf("Registered shutdown hook: #%d", len(hooks.Hooks))
 }
 
 // InitShutdownService initializes the shutdown service, so things can shutdown gracefully
 // It will close the done channel to allow the app to shutdown
 func InitShutdownService(done chan struct{}) {
 	// Setup shutdown signal handling
-	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
 	// Go handle shutdown signal
@@ -88,3 +89,11 @@ func InitShutdownService(done chan struct{}) {
 
 	return
 }
+
+// Shutdown starts a graceful shutdown, as SIGTERM would, for when the app finishes its work on its own
+func Shutdown() {
+	select {
+	case sigChan <- syscall.SIGTERM:
+	default: // A shutdown is already underway
+	}
+}
@@ -2,20 +2,20 @@ package providers
 
 import (
 	"bufio"
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/rohanthewiz/logger"
 	"github.com/rohanthewiz/serr"
-	"rcode/auth"
-	"rcode/config"
 	contextpkg "rcode/context"
+	"rcode/metrics"
+	"rcode/redact"
 	"rcode/tools"
 )
 
@@ -85,8 +85,23 @@ type CreateMessageRequest struct {
 	Messages  []Message   `json:"messages"`
 	MaxTokens int         `json:"max_tokens"`
 	Stream    bool        `json:"stream"`
-	System    string      `json:"system,omitempty"`
+	System    interface{} `json:"system,omitempty"` // A string, or []SystemBlock to mark blocks for caching
 	Tools     interface{} `json:"tools,omitempty"`
+	SessionID string      `json:"-"` // Not sent; the session told when the request waits for rate limits
+	Retries   int         `json:"-"` // Not sent; how often the retrying senders retry, 5 times when 0
+	Account   string      `json:"-"` // Not sent; the Claude account to send it with, the default one when ""
+}
+
+// SystemBlock is one text block of a system prompt given as a list
+type SystemBlock struct {
+	Type         string        `json:"type"` // "text"
+	Text         string        `json:"text"`
+	CacheControl *CacheControl `json:"cache_control,omitempty"`
+}
+
+// CacheControl marks the end of a prompt prefix for the API to cache
+type CacheControl struct {
+	Type string `json:"type"` // "ephemeral"
 }
 
 // CreateMessageResponse represents the response from creating a message
@@ -132,6 +147,7 @@ type StreamEvent struct {
 	Message json.RawMessage `json:"message,omitempty"`
 	Delta   json.RawMessage `json:"delta,omitempty"`
 	Index   int             `json:"index,omitempty"`
+	Usage   json.RawMessage `json:"usage,omitempty"` // A message_delta's usage so far
 }
 
 // CreateMessageWithImage creates a message with both text and image content
@@ -177,50 +193,51 @@ func CreateTextMessage(role string, text string) Message {
 
 // SendMessage sends a message to Claude and returns the response
 func (c *AnthropicClient) SendMessage(request CreateMessageRequest) (*CreateMessageResponse, error) {
-	// Get access token
-	accessToken, err := auth.GetAccessToken()
+	// Find where the model's requests go
+	backend, err := modelBackend(request.Model)
 	if err != nil {
-		return nil, serr.Wrap(err, "failed to get access token")
+		return nil, err
 	}
 
-	// Marshal request
+	// Marshal request, masking the secrets in it
 	requestBody, err := json.Marshal(request)
 	if err != nil {
 		return nil, serr.Wrap(err, "failed to marshal request")
 	}
-
-	// Get API URL from config
-	apiURL := config.Get().AnthropicAPIURL
+	requestBody = redact.Default().JSON(requestBody)
 
 	// Log the request for debugging
-	logger.Info("Anthropic API Request ->" + string(requestBody))
-	logger.Info("Using model: " + request.Model)
-	logger.Info("API URL", "url", apiURL)
+	logger.Debug("Anthropic API request", "session_id", request.SessionID, "body", string(requestBody))
 
-	// Create HTTP request
-	req, err := http.NewRequest("POST", apiURL, bytes.NewReader(requestBody))
+	// Create HTTP request with the backend's URL and credentials
+	req, err := backend.newRequest(context.Background(), requestBody, false, request.Account)
 	if err != nil {
-		return nil, serr.Wrap(err, "failed to create request")
+		return nil, err
 	}
-
-	// Set headers
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-	req.Header.Set("anthropic-beta", anthropicBeta)
-	req.Header.Set("anthropic-version", anthropicVersion)
+	logger.Info("API URL", "url", req.URL.String())
 
 	// Log headers and model for debugging
 	logger.Info("Request details",
+		"session_id", request.SessionID,
 		"model", request.Model,
-		"anthropic-beta", anthropicBeta,
-		"anthropic-version", anthropicVersion)
+		"backend", backend.name(),
+		"anthropic-beta", req.Header.Get("anthropic-beta"),
+		"anthropic-version", req.Header.Get("anthropic-version"))
 
-	// Send request
+	// Wait for the request's turn, then send it
+	release, err := AnthropicScheduler().Acquire(context.Background(), request.SessionID)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to wait for the request's turn")
+	}
+	defer release()
 	resp, err := c.httpClient.Do(req)
+	countModelRequest(request.Model, resp)
 	if err != nil {
 		return nil, serr.Wrap(err, "failed to send request")
 	}
 	defer resp.Body.Close()
+	AnthropicScheduler().Observe(resp.StatusCode, resp.Header)
+	backend.adaptResponse(resp)
 
 	// Read response
 	body, err := io.ReadAll(resp.Body)
@@ -265,10 +282,19 @@ func (c *AnthropicClient) SendMessage(request CreateMessageRequest) (*CreateMess
 	// Extract rate limit headers
 	response.RateLimits = extractRateLimitHeaders(resp.Header)
 
+	// Learn how the model counts tokens, for the estimates of later requests
+	var counted struct {
+		Usage json.RawMessage `json:"usage"`
+	}
+	if json.Unmarshal(body, &counted) == nil {
+		observeInputTokens(request, counted.Usage)
+	}
+
 	// Log the model from the response
-	logger.Info("API Response model", "model", response.Model)
+	logger.Info("API Response model", "session_id", request.SessionID, "model", response.Model)
 	if response.RateLimits != nil {
 		logger.Info("Rate limits",
+			"session_id", request.SessionID,
 			"requests_remaining", response.RateLimits.RequestsRemaining,
 			"input_tokens_remaining", response.RateLimits.InputTokensRemaining,
 			"output_tokens_remaining", response.RateLimits.OutputTokensRemaining)
@@ -288,6 +314,9 @@ func (c *AnthropicClient) SendMessageWithRetry(request CreateMessageRequest) (*C
 		Jitter:          true,
 		RetryableErrors: tools.IsRetryableError,
 	}
+	if request.Retries > 0 {
+		retryPolicy.MaxAttempts = request.Retries
+	}
 
 	var response *CreateMessageResponse
 
@@ -304,8 +333,8 @@ func (c *AnthropicClient) SendMessageWithRetry(request CreateMessageRequest) (*C
 	if result.LastError != nil {
 		// Log retry details if we had retries
 		if result.Attempts > 1 {
-			logger.LogErr(result.LastError,
-				fmt.Sprintf("Failed to send message after %d attempts", result.Attempts))
+			logger.LogErr(result.LastError, "failed to send message after retries",
+				"attempts", result.Attempts, "session_id", request.SessionID)
 		}
 		return nil, result.LastError
 	}
@@ -313,6 +342,7 @@ func (c *AnthropicClient) SendMessageWithRetry(request CreateMessageRequest) (*C
 	// Log successful retry if needed
 	if result.Attempts > 1 {
 		logger.Info("Message sent successfully after retries",
+			"session_id", request.SessionID,
 			"attempts", result.Attempts,
 			"duration", result.TotalDuration)
 	}
@@ -325,40 +355,40 @@ func (c *AnthropicClient) StreamMessage(request CreateMessageRequest, onEvent fu
 	// Ensure streaming is enabled
 	request.Stream = true
 
-	// Get access token
-	accessToken, err := auth.GetAccessToken()
+	// Find where the model's requests go
+	backend, err := modelBackend(request.Model)
 	if err != nil {
-		return nil, serr.Wrap(err, "failed to get access token")
+		return nil, err
 	}
 
-	// Marshal request
+	// Marshal request, masking the secrets in it
 	requestBody, err := json.Marshal(request)
 	if err != nil {
 		return nil, serr.Wrap(err, "failed to marshal request")
 	}
+	requestBody = redact.Default().JSON(requestBody)
 
-	// Get API URL from config
-	apiURL := config.Get().AnthropicAPIURL
-
-	// Create HTTP request
-	req, err := http.NewRequest("POST", apiURL, bytes.NewReader(requestBody))
+	// Create HTTP request with the backend's URL and credentials
+	req, err := backend.newRequest(context.Background(), requestBody, true, request.Account)
 	if err != nil {
-		return nil, serr.Wrap(err, "failed to create request")
+		return nil, err
 	}
 
-	// Set headers
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-	req.Header.Set("anthropic-beta", anthropicBeta)
-	req.Header.Set("anthropic-version", anthropicVersion)
-	req.Header.Set("Accept", "text/event-stream")
-
-	// Send request
+	// Wait for the request's turn, then send it; the turn lasts until the stream ends
+	release, err := AnthropicScheduler().Acquire(context.Background(), request.SessionID)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to wait for the request's turn")
+	}
+	defer release()
+	start := time.Now()
 	resp, err := c.httpClient.Do(req)
+	countModelRequest(request.Model, resp)
 	if err != nil {
 		return nil, serr.Wrap(err, "failed to send request")
 	}
 	defer resp.Body.Close()
+	AnthropicScheduler().Observe(resp.StatusCode, resp.Header)
+	backend.adaptResponse(resp)
 
 	// Extract rate limit headers
 	rateLimits := extractRateLimitHeaders(resp.Header)
@@ -390,6 +420,10 @@ func (c *AnthropicClient) StreamMessage(request CreateMessageRequest, onEvent fu
 		}
 	}
 
+	defer func() {
+		metrics.StreamDuration.Observe(time.Since(start).Seconds(), request.Model)
+	}()
+
 	// Read SSE stream with proper buffering
 	scanner := bufio.NewScanner(resp.Body)
 	var currentEvent strings.Builder
@@ -414,7 +448,7 @@ func (c *AnthropicClient) StreamMessage(request CreateMessageRequest, onEvent fu
 				Type string `json:"type"`
 			}
 			if err := json.Unmarshal([]byte(eventData), &typeCheck); err != nil {
-				logger.LogErr(err, "failed to unmarshal SSE event type: "+eventData)
+				logger.LogErr(err, "failed to unmarshal SSE event type", "event", eventData)
 				continue
 			}
 
@@ -450,10 +484,23 @@ func (c *AnthropicClient) StreamMessage(request CreateMessageRequest, onEvent fu
 			// Parse as regular StreamEvent
 			var event StreamEvent
 			if err := json.Unmarshal([]byte(eventData), &event); err != nil {
-				logger.LogErr(err, "failed to parse event: "+eventData)
+				logger.LogErr(err, "failed to parse event", "event", eventData)
 				continue
 			}
 
+			// Learn how the model counts tokens, for the estimates of later requests
+			switch event.Type {
+			case "message_start":
+				var started struct {
+					Usage json.RawMessage `json:"usage"`
+				}
+				if json.Unmarshal(event.Message, &started) == nil {
+					observeInputTokens(request, started.Usage)
+				}
+			case "message_delta":
+				observeInputTokens(request, event.Usage)
+			}
+
 			if err := onEvent(event); err != nil {
 				return rateLimits, serr.Wrap(err, "error in event handler")
 			}
@@ -478,6 +525,9 @@ func (c *AnthropicClient) StreamMessageWithRetry(request CreateMessageRequest, o
 		Jitter:          true,
 		RetryableErrors: tools.IsRetryableError,
 	}
+	if request.Retries > 0 {
+		retryPolicy.MaxAttempts = request.Retries
+	}
 
 	var rateLimits *RateLimitInfo
 	operation := func(ctx context.Context) error {
@@ -490,8 +540,8 @@ func (c *AnthropicClient) StreamMessageWithRetry(request CreateMessageRequest, o
 	if result.LastError != nil {
 		// Log retry details if we had retries
 		if result.Attempts > 1 {
-			logger.LogErr(result.LastError,
-				fmt.Sprintf("Failed to stream message after %d attempts", result.Attempts))
+			logger.LogErr(result.LastError, "failed to stream message after retries",
+				"attempts", result.Attempts, "session_id", request.SessionID)
 		}
 		return rateLimits, result.LastError
 	}
@@ -499,6 +549,7 @@ func (c *AnthropicClient) StreamMessageWithRetry(request CreateMessageRequest, o
 	// Log successful retry if needed
 	if result.Attempts > 1 {
 		logger.Info("Message streamed successfully after retries",
+			"session_id", request.SessionID,
 			"attempts", result.Attempts,
 			"duration", result.TotalDuration)
 	}
@@ -506,8 +557,55 @@ func (c *AnthropicClient) StreamMessageWithRetry(request CreateMessageRequest, o
 	return rateLimits, nil
 }
 
-// ConvertToAPIMessages converts internal messages to API format
-func ConvertToAPIMessages(messages []ChatMessage) []Message {
+// Forward sends a request body as it is to its model's backend with RCode's credentials,
+// for clients that build their own requests. The response is returned unread, whatever its
+// status; the caller closes its body, which ends the request's turn with the scheduler.
+func (c *AnthropicClient) Forward(requestBody []byte, stream bool) (*http.Response, error) {
+	var sent struct {
+		Model string `json:"model"`
+	}
+	_ = json.Unmarshal(requestBody, &sent)
+	backend, err := modelBackend(sent.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	requestBody = redact.Default().JSON(requestBody)
+	req, err := backend.newRequest(context.Background(), requestBody, stream, "")
+	if err != nil {
+		return nil, err
+	}
+
+	release, err := AnthropicScheduler().Acquire(req.Context(), "")
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to wait for the request's turn")
+	}
+	resp, err := c.httpClient.Do(req)
+	countModelRequest(sent.Model, resp)
+	if err != nil {
+		release()
+		return nil, serr.Wrap(err, "failed to send request")
+	}
+	AnthropicScheduler().Observe(resp.StatusCode, resp.Header)
+	backend.adaptResponse(resp)
+	resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// countModelRequest counts a request to the model API by its reply's status code, or
+// "error" when there was no reply
+func countModelRequest(model string, resp *http.Response) {
+	code := "error"
+	if resp != nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	metrics.ModelRequests.Inc(model, code)
+}
+
+// ConvertToAPIMessages converts internal messages to API format, leaving out the oldest when
+// they don't fit in the context window of the request's model beside its system prompt,
+// tools and max_tokens; see fitContext. Set the request's other fields first.
+func ConvertToAPIMessages(messages []ChatMessage, request CreateMessageRequest) []Message {
 	apiMessages := make([]Message, len(messages))
 	for i, msg := range messages {
 		// Check if message has images in metadata
@@ -522,13 +620,24 @@ func ConvertToAPIMessages(messages []ChatMessage) []Message {
 			}
 		}
 
+		// Files mentioned with @path, read when the message was sent
+		mentions := fileMentionsFromMetadata(msg.Metadata)
+
 		// Handle different content types
 		switch content := msg.Content.(type) {
 		case string:
-			if hasImages && len(images) > 0 {
-				// Create message with text and images
+			if (hasImages && len(images) > 0) || len(mentions) > 0 {
+				// Create message with file, text and image blocks
 				contents := []interface{}{}
 
+				// Add mentioned files ahead of the text that refers to them
+				for _, mention := range mentions {
+					contents = append(contents, TextContent{
+						Type: "text",
+						Text: mention.Block(),
+					})
+				}
+
 				// Add text content
 				if content != "" {
 					contents = append(contents, TextContent{
@@ -577,7 +686,53 @@ func ConvertToAPIMessages(messages []ChatMessage) []Message {
 			}
 		}
 	}
-	return apiMessages
+	return fitContext(apiMessages, request)
+}
+
+// FileMention is a file referenced as @path in a user message, with its content at the time the message was sent
+type FileMention struct {
+	Path       string `json:"path"` // Relative to the project root
+	StartLine  int    `json:"startLine,omitempty"`
+	EndLine    int    `json:"endLine,omitempty"`
+	TotalLines int    `json:"totalLines,omitempty"`
+	Content    string `json:"content,omitempty"`
+	Truncated  bool   `json:"truncated,omitempty"`
+	Error      string `json:"error,omitempty"` // Why the file could not be included
+}
+
+// Block renders the mention as a context block for the model
+func (m FileMention) Block() string {
+	if m.Error != "" {
+		return fmt.Sprintf("<file path=%q error=%q />", m.Path, m.Error)
+	}
+
+	attrs := fmt.Sprintf("path=%q", m.Path)
+	if m.StartLine > 0 {
+		attrs += fmt.Sprintf(" lines=\"%d-%d\" total_lines=\"%d\"", m.StartLine, m.EndLine, m.TotalLines)
+	}
+	if m.Truncated {
+		attrs += ` truncated="true"`
+	}
+	return fmt.Sprintf("<file %s>\n%s</file>", attrs, m.Content)
+}
+
+// fileMentionsFromMetadata reads the "fileMentions" stored with a message.
+// Metadata loaded from the database holds them as generic JSON, so they are decoded through JSON either way.
+func fileMentionsFromMetadata(metadata map[string]interface{}) []FileMention {
+	raw, ok := metadata["fileMentions"]
+	if !ok || raw == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil
+	}
+	var mentions []FileMention
+	if err := json.Unmarshal(data, &mentions); err != nil {
+		return nil
+	}
+	return mentions
 }
 
 // ChatMessage represents an internal chat message
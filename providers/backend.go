@@ -0,0 +1,165 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/rohanthewiz/serr"
+	"rcode/auth"
+	"rcode/config"
+)
+
+// Backends a model's requests can go to, set per model with RCODE_MODEL_BACKENDS
+const (
+	BackendAnthropic = "anthropic"
+	BackendBedrock   = "bedrock"
+	BackendVertex    = "vertex"
+	BackendLocal     = "local"
+)
+
+// backend sends messages requests to a model: Claude on the Anthropic API with RCode's login,
+// or on AWS Bedrock or Google Vertex AI, for those who can't reach api.anthropic.com, or a
+// model of a local server
+type backend interface {
+	// name returns the backend's name, one of the Backend constants
+	name() string
+	// newRequest returns the HTTP request sending body, a messages request in the Anthropic
+	// API's format, with the backend's URL, credentials and changes to the body
+	newRequest(ctx context.Context, body []byte, stream bool, account string) (*http.Request, error)
+	// adaptResponse changes a reply to the Anthropic API's format where the backend's differs
+	adaptResponse(resp *http.Response)
+}
+
+// modelDatePattern finds the release date ending a model ID
+var modelDatePattern = regexp.MustCompile(`-(\d{8})$`)
+
+// modelBackend returns the backend a model's requests go to: the local server for the models
+// of RCODE_LOCAL_MODELS, else the one RCODE_MODEL_BACKENDS sets for its ID, else for the
+// longest part of its ID, else for *; the Anthropic API when none is set. A backend may
+// name its own ID for the model after a colon, e.g.
+// bedrock:us.anthropic.claude-sonnet-4-20250514-v1:0 or local:qwen2.5-coder:14b.
+func modelBackend(model string) (backend, error) {
+	for _, local := range config.Get().LocalModels {
+		if model == local {
+			return &localBackend{modelID: model}, nil
+		}
+	}
+
+	setting, _ := modelSetting(config.Get().ModelBackends, model)
+	name, modelID, _ := strings.Cut(setting, ":")
+
+	switch strings.ToLower(name) {
+	case "", BackendAnthropic:
+		return anthropicBackend{}, nil
+	case BackendBedrock:
+		if modelID == "" {
+			modelID = "anthropic." + model + "-v1:0"
+		}
+		return &bedrockBackend{region: config.Get().BedrockRegion, modelID: modelID}, nil
+	case BackendVertex:
+		if modelID == "" {
+			modelID = modelDatePattern.ReplaceAllString(model, "@$1")
+		}
+		cfg := config.Get()
+		if cfg.VertexProject == "" {
+			return nil, serr.New("the Vertex AI backend needs a project: set RCODE_VERTEX_PROJECT")
+		}
+		return &vertexBackend{project: cfg.VertexProject, region: cfg.VertexRegion, modelID: modelID}, nil
+	case BackendLocal:
+		if modelID == "" {
+			modelID = model
+		}
+		return &localBackend{modelID: modelID}, nil
+	}
+	return nil, serr.New(fmt.Sprintf("unknown backend %q for model %s: use anthropic, bedrock, vertex or local", name, model))
+}
+
+// IsLocalModel reports whether a model's requests go to the local model server
+func IsLocalModel(model string) bool {
+	backend, err := modelBackend(model)
+	return err == nil && backend.name() == BackendLocal
+}
+
+// NeedsClaudeLogin reports whether some models' requests go to the Anthropic API, which
+// needs a Claude account; not when RCODE_MODEL_BACKENDS sends them all elsewhere
+func NeedsClaudeLogin() bool {
+	backends := config.Get().ModelBackends
+	fallback, _, _ := strings.Cut(backends["*"], ":")
+	if fallback == "" || strings.EqualFold(fallback, BackendAnthropic) {
+		return true
+	}
+	for _, setting := range backends {
+		if name, _, _ := strings.Cut(setting, ":"); strings.EqualFold(name, BackendAnthropic) {
+			return true
+		}
+	}
+	return false
+}
+
+// modelSetting returns the setting of a per-model list, like RCODE_MODEL_BACKENDS, that
+// applies to a model: the one for its ID, else for the longest part of its ID, else for *
+func modelSetting[V any](settings map[string]V, model string) (V, bool) {
+	if setting, ok := settings[model]; ok {
+		return setting, true
+	}
+	best := ""
+	for key := range settings {
+		if key != "*" && strings.Contains(model, key) && len(key) > len(best) {
+			best = key
+		}
+	}
+	if best != "" {
+		return settings[best], true
+	}
+	setting, ok := settings["*"]
+	return setting, ok
+}
+
+// rewriteBody returns a messages request body without the fields named by drop and with
+// those of set, which are JSON values
+func rewriteBody(body []byte, drop []string, set map[string]string) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, serr.Wrap(err, "failed to parse request")
+	}
+	for _, name := range drop {
+		delete(fields, name)
+	}
+	for name, value := range set {
+		fields[name] = json.RawMessage(value)
+	}
+	return json.Marshal(fields)
+}
+
+// anthropicBackend sends requests to the Anthropic API, or MSG_PROXY, with the access token
+// of the request's Claude account
+type anthropicBackend struct{}
+
+func (anthropicBackend) name() string { return BackendAnthropic }
+
+func (anthropicBackend) newRequest(ctx context.Context, body []byte, stream bool, account string) (*http.Request, error) {
+	accessToken, err := auth.GetAccountAccessToken(account)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to get access token")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", config.Get().AnthropicAPIURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to create request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("anthropic-beta", anthropicBeta)
+	req.Header.Set("anthropic-version", anthropicVersion)
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+	return req, nil
+}
+
+func (anthropicBackend) adaptResponse(*http.Response) {}
@@ -0,0 +1,385 @@
+package providers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rohanthewiz/serr"
+)
+
+const (
+	bedrockVersion = "bedrock-2023-05-31" // The anthropic_version Bedrock takes
+	bedrockService = "bedrock"            // The service requests are signed for
+
+	// maxEventMessageSize bounds an event stream message; Bedrock's are far smaller
+	maxEventMessageSize = 16 << 20
+)
+
+// bedrockBackend sends requests to Claude on AWS Bedrock, signed with the AWS credentials
+// of the environment or ~/.aws/credentials, or with a Bedrock API key
+type bedrockBackend struct {
+	region  string
+	modelID string // Bedrock's ID of the model, or an inference profile's
+}
+
+func (b *bedrockBackend) name() string { return BackendBedrock }
+
+func (b *bedrockBackend) newRequest(ctx context.Context, body []byte, stream bool, account string) (*http.Request, error) {
+	body, err := rewriteBody(body, []string{"model", "stream"}, map[string]string{"anthropic_version": `"` + bedrockVersion + `"`})
+	if err != nil {
+		return nil, err
+	}
+
+	action := "invoke"
+	if stream {
+		action = "invoke-with-response-stream"
+	}
+	endpoint := &url.URL{
+		Scheme:  "https",
+		Host:    fmt.Sprintf("bedrock-runtime.%s.amazonaws.com", b.region),
+		Path:    "/model/" + b.modelID + "/" + action,
+		RawPath: "/model/" + awsEscape(b.modelID) + "/" + action,
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to create request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if stream {
+		req.Header.Set("Accept", "application/vnd.amazon.eventstream")
+	} else {
+		req.Header.Set("Accept", "application/json")
+	}
+
+	// A Bedrock API key stands in for signing
+	if apiKey := os.Getenv("AWS_BEARER_TOKEN_BEDROCK"); apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+		return req, nil
+	}
+	creds, err := loadAWSCredentials()
+	if err != nil {
+		return nil, err
+	}
+	signAWSRequest(req, body, creds, b.region, bedrockService, time.Now())
+	return req, nil
+}
+
+// adaptResponse turns a streamed reply, an AWS event stream of chunks, into the Anthropic
+// API's server-sent events
+func (b *bedrockBackend) adaptResponse(resp *http.Response) {
+	if resp.StatusCode == http.StatusOK && strings.HasPrefix(resp.Header.Get("Content-Type"), "application/vnd.amazon.eventstream") {
+		resp.Body = &eventStreamSSE{body: resp.Body}
+		resp.Header.Set("Content-Type", "text/event-stream")
+		resp.Header.Del("Content-Length")
+	}
+}
+
+// awsCredentials are the keys requests are signed with
+type awsCredentials struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+}
+
+// loadAWSCredentials returns the AWS credentials of the environment, or those of the
+// AWS_PROFILE, or default, profile of the shared credentials file
+func loadAWSCredentials() (awsCredentials, error) {
+	if id, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"); id != "" && secret != "" {
+		return awsCredentials{accessKeyID: id, secretAccessKey: secret, sessionToken: os.Getenv("AWS_SESSION_TOKEN")}, nil
+	}
+
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return awsCredentials{}, serr.Wrap(err, "failed to get home directory")
+		}
+		path = filepath.Join(home, ".aws", "credentials")
+	}
+	profile := os.Getenv("AWS_PROFILE")
+	if profile == "" {
+		profile = "default"
+	}
+
+	values, err := readINISection(path, profile)
+	if err != nil {
+		return awsCredentials{}, serr.Wrap(err, "no AWS credentials: set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, "+
+			"a profile in ~/.aws/credentials, or a Bedrock API key in AWS_BEARER_TOKEN_BEDROCK")
+	}
+	creds := awsCredentials{
+		accessKeyID:     values["aws_access_key_id"],
+		secretAccessKey: values["aws_secret_access_key"],
+		sessionToken:    values["aws_session_token"],
+	}
+	if creds.accessKeyID == "" || creds.secretAccessKey == "" {
+		return awsCredentials{}, serr.New(fmt.Sprintf("AWS profile %q in %s has no access keys", profile, path))
+	}
+	return creds, nil
+}
+
+// readINISection returns the key = value pairs of a section of an INI file
+func readINISection(path, section string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values := make(map[string]string)
+	found, inSection := false, false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			inSection = strings.TrimSpace(line[1:len(line)-1]) == section
+			found = found || inSection
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok && inSection {
+			values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, serr.New(fmt.Sprintf("no [%s] section in %s", section, path))
+	}
+	return values, nil
+}
+
+// signAWSRequest signs a request with AWS Signature Version 4
+func signAWSRequest(req *http.Request, body []byte, creds awsCredentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+	}
+
+	// Sign the host, the content type and the x-amz headers
+	headers := map[string]string{"host": req.URL.Host}
+	if contentType := req.Header.Get("Content-Type"); contentType != "" {
+		headers["content-type"] = contentType
+	}
+	for name, values := range req.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	// Outside S3, the path's segments are escaped again for signing
+	segments := strings.Split(req.URL.EscapedPath(), "/")
+	for i, segment := range segments {
+		segments[i] = awsEscape(segment)
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		strings.Join(segments, "/"),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+creds.secretAccessKey), date)
+	for _, part := range []string{region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.accessKeyID, scope, signedHeaders, signature))
+}
+
+// awsEscape escapes all but the unreserved characters, as AWS does in paths
+func awsEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// eventStreamSSE reads Bedrock's streamed reply, an AWS event stream whose messages each
+// hold one of the Anthropic API's events, as the Anthropic API's server-sent events
+type eventStreamSSE struct {
+	body io.ReadCloser
+	buf  bytes.Buffer // Events converted but not yet read
+	err  error        // Why the stream ended, io.EOF when it ended well
+}
+
+func (r *eventStreamSSE) Read(p []byte) (int, error) {
+	for r.buf.Len() == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.err = r.next()
+	}
+	return r.buf.Read(p)
+}
+
+func (r *eventStreamSSE) Close() error {
+	return r.body.Close()
+}
+
+// next reads the stream's next message into buf as a server-sent event
+func (r *eventStreamSSE) next() error {
+	var prelude [12]byte
+	if _, err := io.ReadFull(r.body, prelude[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return serr.New("event stream ended in a message")
+		}
+		return err
+	}
+	total := binary.BigEndian.Uint32(prelude[0:4])
+	headersLength := binary.BigEndian.Uint32(prelude[4:8])
+	if crc32.ChecksumIEEE(prelude[:8]) != binary.BigEndian.Uint32(prelude[8:12]) ||
+		total < 16+headersLength || total > maxEventMessageSize {
+		return serr.New("invalid event stream message")
+	}
+
+	rest := make([]byte, total-12)
+	if _, err := io.ReadFull(r.body, rest); err != nil {
+		return serr.Wrap(err, "event stream ended in a message")
+	}
+	checksum := crc32.NewIEEE()
+	checksum.Write(prelude[:])
+	checksum.Write(rest[:len(rest)-4])
+	if checksum.Sum32() != binary.BigEndian.Uint32(rest[len(rest)-4:]) {
+		return serr.New("event stream message failed its checksum")
+	}
+
+	headers, err := parseEventHeaders(rest[:headersLength])
+	if err != nil {
+		return err
+	}
+	payload := rest[headersLength : len(rest)-4]
+
+	switch headers[":message-type"] {
+	case "event":
+		// A chunk holds an event of the Anthropic API, base64 encoded
+		var chunk struct {
+			Bytes []byte `json:"bytes"`
+		}
+		if err := json.Unmarshal(payload, &chunk); err != nil || len(chunk.Bytes) == 0 {
+			return nil
+		}
+		var event struct {
+			Type string `json:"type"`
+		}
+		_ = json.Unmarshal(chunk.Bytes, &event)
+		fmt.Fprintf(&r.buf, "event: %s\ndata: %s\n\n", event.Type, chunk.Bytes)
+	case "exception", "error":
+		errorType := headers[":exception-type"]
+		if errorType == "" {
+			errorType = headers[":error-code"]
+		}
+		var body struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(payload, &body) != nil || body.Message == "" {
+			body.Message = headers[":error-message"]
+		}
+		data, _ := json.Marshal(map[string]interface{}{
+			"type":  "error",
+			"error": map[string]string{"type": errorType, "message": body.Message},
+		})
+		fmt.Fprintf(&r.buf, "event: error\ndata: %s\n\n", data)
+	}
+	return nil
+}
+
+// eventHeaderSizes are the sizes of the values of the fixed-size header types of event
+// stream messages: true, false, byte, short, int, long, timestamp and uuid
+var eventHeaderSizes = map[byte]int{0: 0, 1: 0, 2: 1, 3: 2, 4: 4, 5: 8, 8: 8, 9: 16}
+
+// parseEventHeaders returns the string headers of an event stream message, skipping the
+// others
+func parseEventHeaders(data []byte) (map[string]string, error) {
+	headers := make(map[string]string)
+	for len(data) > 0 {
+		nameLength := int(data[0])
+		if len(data) < 2+nameLength {
+			return nil, errInvalidEventHeaders()
+		}
+		name := string(data[1 : 1+nameLength])
+		valueType := data[1+nameLength]
+		data = data[2+nameLength:]
+
+		if size, ok := eventHeaderSizes[valueType]; ok {
+			if len(data) < size {
+				return nil, errInvalidEventHeaders()
+			}
+			data = data[size:]
+			continue
+		}
+		// Byte arrays and strings, after their length
+		if (valueType != 6 && valueType != 7) || len(data) < 2 {
+			return nil, errInvalidEventHeaders()
+		}
+		length := int(binary.BigEndian.Uint16(data[:2]))
+		if len(data) < 2+length {
+			return nil, errInvalidEventHeaders()
+		}
+		if valueType == 7 {
+			headers[name] = string(data[2 : 2+length])
+		}
+		data = data[2+length:]
+	}
+	return headers, nil
+}
+
+func errInvalidEventHeaders() error {
+	return serr.New("invalid event stream headers")
+}
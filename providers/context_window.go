@@ -0,0 +1,259 @@
+package providers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rohanthewiz/logger"
+	"rcode/config"
+)
+
+// Context windows, in tokens, of the models RCODE_CONTEXT_WINDOWS doesn't set
+const (
+	claudeContextWindow = 200000
+	localContextWindow  = 8192 // A common context length of local servers, often smaller than the model's
+)
+
+const (
+	// contextMargin is the part of a context window left unused, for the token estimates' error
+	contextMargin = 0.1
+	// maxTrimSummary bounds the summary that stands in for the messages trimmed, in characters
+	maxTrimSummary = 2000
+	// trimRequestExcerpt bounds each request the summary quotes, in characters
+	trimRequestExcerpt = 200
+)
+
+// ContextWindow returns a model's context window in tokens: the one RCODE_CONTEXT_WINDOWS
+// sets for its ID, else for the longest part of its ID, else for *; else Claude's, or
+// localContextWindow for a local model
+func ContextWindow(model string) int {
+	if tokens, ok := modelSetting(config.Get().ContextWindows, model); ok {
+		return tokens
+	}
+	if IsLocalModel(model) {
+		return localContextWindow
+	}
+	return claudeContextWindow
+}
+
+// messageBudget returns the tokens a request's messages may take: its model's context window
+// less a margin, the reply's max_tokens, and its system prompt and tools
+func messageBudget(request CreateMessageRequest) int {
+	window := int(float64(ContextWindow(request.Model)) * (1 - contextMargin))
+	overhead := estimateTokens(request.Model, jsonLength(request.System)+jsonLength(request.Tools))
+	return window - request.MaxTokens - overhead
+}
+
+// fitContext returns the messages that fit in a request's context window. When they don't
+// all fit, the oldest are left out, from the start of a user turn, and a summary of them
+// is put at the start of the first message kept. The latest message is always kept, cut
+// when it alone doesn't fit.
+func fitContext(messages []Message, request CreateMessageRequest) []Message {
+	budget := messageBudget(request)
+	sizes := make([]int, len(messages))
+	total := 0
+	for i, msg := range messages {
+		chars, images := messageSize(msg)
+		sizes[i] = estimateTokens(request.Model, chars) + images*imageTokens
+		total += sizes[i]
+	}
+	if total <= budget || len(messages) == 0 {
+		return messages
+	}
+
+	// Leave out the oldest messages until the rest and the summary fit. The first kept
+	// starts a user turn, as the API requires.
+	reserve := estimateTokens(request.Model, maxTrimSummary)
+	start := 0
+	for start < len(messages)-1 && (total+reserve > budget || messages[start].Role != "user") {
+		total -= sizes[start]
+		start++
+	}
+	kept := append([]Message(nil), messages[start:]...)
+	if start > 0 {
+		total += reserve
+		blocks := []interface{}{TextContent{Type: "text", Text: trimSummary(messages[:start])}}
+		kept[0] = Message{Role: kept[0].Role, Content: append(blocks, orphanedResultsAsText(kept[0].Content)...)}
+		logger.Info("Trimmed conversation to fit the model's context window",
+			"session_id", request.SessionID, "model", request.Model, "context_window", ContextWindow(request.Model),
+			"messages_left_out", start, "messages_kept", len(kept))
+	}
+
+	if over := total - budget; over > 0 {
+		cut := int(float64(over)/tokensPerChar(request.Model)) + 1
+		kept[len(kept)-1] = shortenMessage(kept[len(kept)-1], cut)
+		logger.Warn("Cut the latest message to fit the model's context window",
+			"session_id", request.SessionID, "model", request.Model, "budget", budget, "chars_cut", cut)
+	}
+	return kept
+}
+
+// contentBlocksOf returns a message's content as a new list of blocks
+func contentBlocksOf(content interface{}) []interface{} {
+	switch c := content.(type) {
+	case string:
+		return []interface{}{TextContent{Type: "text", Text: c}}
+	case []TextContent:
+		blocks := make([]interface{}, 0, len(c))
+		for _, block := range c {
+			blocks = append(blocks, block)
+		}
+		return blocks
+	case []interface{}:
+		return append([]interface{}(nil), c...)
+	}
+	return []interface{}{content}
+}
+
+// orphanedResultsAsText returns a message's content as blocks, with tool results as text:
+// their calls were left out, and the API takes a tool result only after its call
+func orphanedResultsAsText(content interface{}) []interface{} {
+	blocks := contentBlocksOf(content)
+	for i, block := range blocks {
+		if result, ok := block.(map[string]interface{}); ok && result["type"] == "tool_result" {
+			blocks[i] = TextContent{Type: "text", Text: "Result of an earlier tool call:\n" + toolResultContent(result["content"])}
+		}
+	}
+	return blocks
+}
+
+// shortenMessage cuts at least cut characters from the end of a message's longest text or
+// tool result, noting the cut
+func shortenMessage(msg Message, cut int) Message {
+	blocks := contentBlocksOf(msg.Content)
+	longest, longestLen := -1, 0
+	for i, block := range blocks {
+		if text, ok := blockText(block); ok && len(text) > longestLen {
+			longest, longestLen = i, len(text)
+		}
+	}
+	if longest < 0 {
+		return msg
+	}
+
+	text, _ := blockText(blocks[longest])
+	note := "\n[... the rest was cut to fit the model's context window]"
+	keep := len(text) - cut - len(note)
+	if keep < 0 {
+		keep = 0
+	}
+	for keep > 0 && !utf8.RuneStart(text[keep]) {
+		keep--
+	}
+	text = text[:keep] + note
+
+	switch b := blocks[longest].(type) {
+	case TextContent:
+		b.Text = text
+		blocks[longest] = b
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(b))
+		for key, value := range b {
+			result[key] = value
+		}
+		if result["type"] == "tool_result" {
+			result["content"] = text
+		} else {
+			result["text"] = text
+		}
+		blocks[longest] = result
+	}
+	return Message{Role: msg.Role, Content: blocks}
+}
+
+// blockText returns the text of a text block or of a tool result whose content is text
+func blockText(block interface{}) (string, bool) {
+	switch b := block.(type) {
+	case TextContent:
+		return b.Text, true
+	case map[string]interface{}:
+		switch b["type"] {
+		case "text":
+			text, ok := b["text"].(string)
+			return text, ok
+		case "tool_result":
+			text, ok := b["content"].(string)
+			return text, ok
+		}
+	}
+	return "", false
+}
+
+// toolResultContent returns the text of a tool result's content, a string or text blocks
+func toolResultContent(content interface{}) string {
+	switch c := content.(type) {
+	case string:
+		return c
+	case []interface{}:
+		var texts []string
+		for _, block := range c {
+			if text, ok := block.(map[string]interface{}); ok && text["type"] == "text" {
+				texts = append(texts, fmt.Sprint(text["text"]))
+			}
+		}
+		return strings.Join(texts, "\n")
+	}
+	return fmt.Sprint(content)
+}
+
+// trimSummary returns the note that stands in for the messages left out: the user's requests
+// among them, the latest first to go when they are too long, and the tools called
+func trimSummary(messages []Message) string {
+	var requests []string
+	calls := make(map[string]int)
+	for _, msg := range messages {
+		for _, block := range orphanedResultsAsText(msg.Content) {
+			switch b := block.(type) {
+			case TextContent:
+				// Mentioned files and tool results aren't requests
+				if msg.Role == "user" && !strings.HasPrefix(b.Text, "<file ") && !strings.HasPrefix(b.Text, "Result of an earlier tool call") {
+					requests = append(requests, excerpt(b.Text))
+				}
+			case map[string]interface{}:
+				if b["type"] == "tool_use" {
+					calls[fmt.Sprint(b["name"])]++
+				}
+			}
+		}
+	}
+
+	summary := fmt.Sprintf("[The start of this conversation, %d messages, was left out to fit the model's context window.", len(messages))
+	if len(calls) > 0 {
+		names := make([]string, 0, len(calls))
+		for name, count := range calls {
+			names = append(names, fmt.Sprintf("%s (%d)", name, count))
+		}
+		sort.Strings(names)
+		summary += " Tools called in it: " + strings.Join(names, ", ") + "."
+	}
+	if len(requests) == 0 {
+		return summary + "]"
+	}
+
+	// Keep the latest requests that fit
+	summary += " The user's requests in it:"
+	first := len(requests)
+	length := len(summary)
+	for first > 0 && length+len(requests[first-1])+3 <= maxTrimSummary {
+		first--
+		length += len(requests[first]) + 3
+	}
+	if first > 0 {
+		summary += fmt.Sprintf("\n- (%d earlier)", first)
+	}
+	for _, request := range requests[first:] {
+		summary += "\n- " + request
+	}
+	return summary + "]"
+}
+
+// excerpt returns the start of a text on one line, cut at trimRequestExcerpt characters
+func excerpt(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if runes := []rune(text); len(runes) > trimRequestExcerpt {
+		return string(runes[:trimRequestExcerpt]) + "..."
+	}
+	return text
+}
@@ -0,0 +1,480 @@
+package providers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/rohanthewiz/serr"
+	"rcode/config"
+)
+
+// localBackend sends requests to a local model server, Ollama or llama.cpp's, through its
+// OpenAI-compatible chat completions API. Tools are offered through toolShimPrompt, so
+// models without tool use of their own can call them too.
+type localBackend struct {
+	modelID string    // The local server's name of the model, e.g. qwen2.5-coder:14b
+	shim    *toolShim // Reads the tool calls of the reply to the request made
+}
+
+func (l *localBackend) name() string { return BackendLocal }
+
+// localRequest is the part of a messages request a local model is sent
+type localRequest struct {
+	System   json.RawMessage `json:"system"`
+	Messages []struct {
+		Role    string          `json:"role"`
+		Content json.RawMessage `json:"content"`
+	} `json:"messages"`
+	Tools         []toolDefinition `json:"tools"`
+	MaxTokens     int              `json:"max_tokens"`
+	Temperature   *float64         `json:"temperature"`
+	StopSequences []string         `json:"stop_sequences"`
+}
+
+// localBlock is a content block of a messages request
+type localBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text"`
+	Name      string          `json:"name"`  // A tool call's
+	ID        string          `json:"id"`    // A tool call's
+	Input     json.RawMessage `json:"input"` // A tool call's
+	ToolUseID string          `json:"tool_use_id"`
+	Content   json.RawMessage `json:"content"` // A tool result's, a string or blocks
+	IsError   bool            `json:"is_error"`
+	Source    *ImageSource    `json:"source"`
+}
+
+// chatRequest is a request of the chat completions API
+type chatRequest struct {
+	Model         string         `json:"model"`
+	Messages      []chatMessage  `json:"messages"`
+	MaxTokens     int            `json:"max_tokens,omitempty"`
+	Temperature   *float64       `json:"temperature,omitempty"`
+	Stop          []string       `json:"stop,omitempty"`
+	Stream        bool           `json:"stream"`
+	StreamOptions *streamOptions `json:"stream_options,omitempty"`
+}
+
+// streamOptions asks for the usage of a streamed reply, in its last chunk
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// chatMessage is a message of the chat completions API; its content is a string, or
+// chatParts when it has images
+type chatMessage struct {
+	Role    string      `json:"role"`
+	Content interface{} `json:"content"`
+}
+
+// chatPart is a text or image part of a chat message
+type chatPart struct {
+	Type     string    `json:"type"` // text or image_url
+	Text     string    `json:"text,omitempty"`
+	ImageURL *imageURL `json:"image_url,omitempty"`
+}
+
+// imageURL holds an image as a data URL
+type imageURL struct {
+	URL string `json:"url"`
+}
+
+// chatUsage is the token usage of a chat completion
+type chatUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// chatResponse is a chat completion, or a chunk of a streamed one
+type chatResponse struct {
+	ID      string `json:"id"`
+	Model   string `json:"model"`
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *chatUsage     `json:"usage"`
+	Error *localAPIError `json:"error"`
+}
+
+// localAPIError is an error a local server reports in a stream
+type localAPIError struct {
+	Message string `json:"message"`
+}
+
+func (l *localBackend) newRequest(ctx context.Context, body []byte, stream bool, account string) (*http.Request, error) {
+	var request localRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, serr.Wrap(err, "failed to parse request")
+	}
+
+	chat := chatRequest{
+		Model:       l.modelID,
+		MaxTokens:   request.MaxTokens,
+		Temperature: request.Temperature,
+		Stop:        request.StopSequences,
+		Stream:      stream,
+	}
+	if stream {
+		chat.StreamOptions = &streamOptions{IncludeUsage: true}
+	}
+
+	system := blocksText(contentBlocks(request.System))
+	l.shim = newToolShim(request.Tools)
+	if len(request.Tools) > 0 {
+		system = strings.TrimSpace(system + "\n\n" + toolShimPrompt(request.Tools))
+		// The model stops after a call, to wait for its result
+		chat.Stop = append(chat.Stop, toolCallClose)
+	}
+	if system != "" {
+		chat.Messages = append(chat.Messages, chatMessage{Role: "system", Content: system})
+	}
+
+	toolNames := make(map[string]string) // Tool call IDs to the tools called
+	for _, message := range request.Messages {
+		var parts []chatPart
+		for _, block := range contentBlocks(message.Content) {
+			switch block.Type {
+			case "text":
+				parts = append(parts, chatPart{Type: "text", Text: block.Text})
+			case "image":
+				if block.Source != nil && block.Source.Type == "base64" {
+					parts = append(parts, imagePart(block.Source))
+				}
+			case "tool_use":
+				toolNames[block.ID] = block.Name
+				parts = append(parts, chatPart{Type: "text", Text: toolCallText(block.Name, block.Input)})
+			case "tool_result":
+				results := contentBlocks(block.Content)
+				parts = append(parts, chatPart{Type: "text", Text: toolResultText(toolNames[block.ToolUseID], blocksText(results), block.IsError)})
+				for _, result := range results {
+					if result.Type == "image" && result.Source != nil && result.Source.Type == "base64" {
+						parts = append(parts, imagePart(result.Source))
+					}
+				}
+			}
+		}
+		chat.Messages = append(chat.Messages, chatMessage{Role: message.Role, Content: chatContent(parts)})
+	}
+
+	data, err := json.Marshal(chat)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to marshal request")
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", config.Get().LocalURL+"/chat/completions", bytes.NewReader(data))
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to create request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+	return req, nil
+}
+
+// contentBlocks returns the blocks of a message's content, which may be a plain string
+func contentBlocks(content json.RawMessage) []localBlock {
+	var text string
+	if json.Unmarshal(content, &text) == nil {
+		if text == "" {
+			return nil
+		}
+		return []localBlock{{Type: "text", Text: text}}
+	}
+	var blocks []localBlock
+	_ = json.Unmarshal(content, &blocks)
+	return blocks
+}
+
+// blocksText returns the text of a message's text blocks
+func blocksText(blocks []localBlock) string {
+	var texts []string
+	for _, block := range blocks {
+		if block.Type == "text" && block.Text != "" {
+			texts = append(texts, block.Text)
+		}
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+// imagePart returns an image as a chat message part
+func imagePart(source *ImageSource) chatPart {
+	return chatPart{Type: "image_url", ImageURL: &imageURL{URL: "data:" + source.MediaType + ";base64," + source.Data}}
+}
+
+// chatContent returns a chat message's content: its text, or its parts when it has images
+func chatContent(parts []chatPart) interface{} {
+	var texts []string
+	for _, part := range parts {
+		if part.Type != "text" {
+			return parts
+		}
+		texts = append(texts, part.Text)
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+// adaptResponse turns the local server's reply into the Anthropic API's: a completion into
+// a message, a streamed one into the API's server-sent events, and an error into its shape
+func (l *localBackend) adaptResponse(resp *http.Response) {
+	if l.shim == nil {
+		l.shim = newToolShim(nil)
+	}
+	if resp.StatusCode == http.StatusOK && strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		resp.Body = &chatStreamSSE{body: resp.Body, scanner: bufio.NewScanner(resp.Body), model: l.modelID, shim: l.shim}
+		resp.Header.Del("Content-Length")
+		return
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		body = nil
+	}
+	if resp.StatusCode == http.StatusOK {
+		body = l.message(body)
+	} else {
+		body = localErrorBody(resp.StatusCode, body)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.Header.Set("Content-Type", "application/json")
+	resp.Header.Del("Content-Length")
+}
+
+// message returns a chat completion as a message of the Anthropic API
+func (l *localBackend) message(body []byte) []byte {
+	var completion chatResponse
+	if err := json.Unmarshal(body, &completion); err != nil || len(completion.Choices) == 0 {
+		return body
+	}
+
+	choice := completion.Choices[0]
+	text := l.shim.write(choice.Message.Content)
+	rest, calls := l.shim.finish()
+	text += rest
+	if len(calls) > 0 {
+		text = strings.TrimRight(text, " \t\r\n")
+	}
+
+	content := []Content{}
+	if text != "" {
+		content = append(content, Content{Type: "text", Text: text})
+	}
+	for _, call := range calls {
+		content = append(content, Content{Type: "tool_use", ID: call.ID, Name: call.Name, Input: call.Input})
+	}
+	usage := Usage{}
+	if completion.Usage != nil {
+		usage = Usage{InputTokens: completion.Usage.PromptTokens, OutputTokens: completion.Usage.CompletionTokens}
+	}
+	data, _ := json.Marshal(map[string]interface{}{
+		"id":            completion.ID,
+		"type":          "message",
+		"role":          "assistant",
+		"model":         l.modelID,
+		"content":       content,
+		"stop_reason":   localStopReason(choice.FinishReason, calls),
+		"stop_sequence": nil,
+		"usage":         usage,
+	})
+	return data
+}
+
+// localStopReason returns the Anthropic API's stop reason for a completion's finish reason
+func localStopReason(finishReason string, calls []shimCall) string {
+	switch {
+	case len(calls) > 0:
+		return "tool_use"
+	case finishReason == "length":
+		return "max_tokens"
+	}
+	return "end_turn"
+}
+
+// localErrorBody returns a local server's error reply in the Anthropic API's shape.
+// Ollama's error is a string; llama.cpp's and the OpenAI API's an object.
+func localErrorBody(status int, body []byte) []byte {
+	message := strings.TrimSpace(string(body))
+	var reply struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(body, &reply) == nil && len(reply.Error) > 0 {
+		var text string
+		var object localAPIError
+		if json.Unmarshal(reply.Error, &text) == nil {
+			message = text
+		} else if json.Unmarshal(reply.Error, &object) == nil && object.Message != "" {
+			message = object.Message
+		}
+	}
+	if message == "" {
+		message = http.StatusText(status)
+	}
+
+	errorType := "api_error"
+	switch status {
+	case http.StatusBadRequest:
+		errorType = "invalid_request_error"
+	case http.StatusNotFound:
+		errorType = "not_found_error"
+	case http.StatusTooManyRequests:
+		errorType = "rate_limit_error"
+	}
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":  "error",
+		"error": map[string]string{"type": errorType, "message": "local model server: " + message},
+	})
+	return data
+}
+
+// chatStreamSSE reads a local server's streamed completion, chunks of the chat completions
+// API, as the Anthropic API's server-sent events. Text is passed on as it arrives; tool
+// calls are sent as tool_use blocks once the reply ends.
+type chatStreamSSE struct {
+	body    io.ReadCloser
+	scanner *bufio.Scanner
+	model   string
+	shim    *toolShim
+	buf     bytes.Buffer // Events converted but not yet read
+	err     error        // Why the stream ended, io.EOF when it ended well
+
+	started      bool // message_start was sent
+	textOpen     bool // The text block was started
+	finishReason string
+	usage        Usage
+}
+
+func (r *chatStreamSSE) Read(p []byte) (int, error) {
+	for r.buf.Len() == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.err = r.next()
+	}
+	return r.buf.Read(p)
+}
+
+func (r *chatStreamSSE) Close() error {
+	return r.body.Close()
+}
+
+// next converts the stream's next chunk into buf, and the end of the reply when the
+// stream ends
+func (r *chatStreamSSE) next() error {
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return serr.Wrap(err, "failed to read local model stream")
+		}
+		r.end()
+		return io.EOF
+	}
+	data, ok := strings.CutPrefix(r.scanner.Text(), "data:")
+	if !ok {
+		return nil
+	}
+	data = strings.TrimSpace(data)
+	if data == "[DONE]" {
+		r.end()
+		return io.EOF
+	}
+
+	var chunk chatResponse
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return nil
+	}
+	if chunk.Error != nil {
+		r.event("error", map[string]interface{}{
+			"error": map[string]string{"type": "api_error", "message": "local model server: " + chunk.Error.Message},
+		})
+		return nil
+	}
+	r.start(chunk.ID)
+	if chunk.Usage != nil {
+		r.usage = Usage{InputTokens: chunk.Usage.PromptTokens, OutputTokens: chunk.Usage.CompletionTokens}
+	}
+	if len(chunk.Choices) > 0 {
+		if reason := chunk.Choices[0].FinishReason; reason != "" {
+			r.finishReason = reason
+		}
+		r.text(r.shim.write(chunk.Choices[0].Delta.Content))
+	}
+	return nil
+}
+
+// start sends message_start, once
+func (r *chatStreamSSE) start(id string) {
+	if r.started {
+		return
+	}
+	r.started = true
+	r.event("message_start", map[string]interface{}{
+		"message": map[string]interface{}{
+			"id": id, "type": "message", "role": "assistant", "model": r.model,
+			"content": []Content{}, "stop_reason": nil, "stop_sequence": nil, "usage": Usage{},
+		},
+	})
+}
+
+// text sends text of the reply, in the first block
+func (r *chatStreamSSE) text(text string) {
+	if text == "" {
+		return
+	}
+	if !r.textOpen {
+		r.textOpen = true
+		r.event("content_block_start", map[string]interface{}{
+			"index": 0, "content_block": map[string]string{"type": "text", "text": ""},
+		})
+	}
+	r.event("content_block_delta", map[string]interface{}{
+		"index": 0, "delta": map[string]string{"type": "text_delta", "text": text},
+	})
+}
+
+// end sends the rest of the reply's text, its tool calls and the end of the message
+func (r *chatStreamSSE) end() {
+	r.start("")
+	rest, calls := r.shim.finish()
+	r.text(rest)
+
+	index := 0
+	if r.textOpen {
+		r.event("content_block_stop", map[string]interface{}{"index": index})
+		index++
+	}
+	for _, call := range calls {
+		r.event("content_block_start", map[string]interface{}{
+			"index":         index,
+			"content_block": map[string]interface{}{"type": "tool_use", "id": call.ID, "name": call.Name, "input": map[string]interface{}{}},
+		})
+		r.event("content_block_delta", map[string]interface{}{
+			"index": index, "delta": map[string]string{"type": "input_json_delta", "partial_json": string(call.Input)},
+		})
+		r.event("content_block_stop", map[string]interface{}{"index": index})
+		index++
+	}
+
+	r.event("message_delta", map[string]interface{}{
+		"delta": map[string]interface{}{"stop_reason": localStopReason(r.finishReason, calls), "stop_sequence": nil},
+		"usage": r.usage,
+	})
+	r.event("message_stop", map[string]interface{}{})
+}
+
+// event adds a server-sent event of the given type to buf
+func (r *chatStreamSSE) event(eventType string, fields map[string]interface{}) {
+	fields["type"] = eventType
+	data, _ := json.Marshal(fields)
+	fmt.Fprintf(&r.buf, "event: %s\ndata: %s\n\n", eventType, data)
+}
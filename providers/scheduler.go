@@ -0,0 +1,165 @@
+package providers
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rohanthewiz/logger"
+	"rcode/config"
+)
+
+// Backoff after 429s without a Retry-After header: it doubles with each one in a row
+const (
+	schedulerInitialBackoff = 2 * time.Second
+	schedulerMaxBackoff     = 2 * time.Minute
+)
+
+// RequestScheduler queues the requests to one provider's API, so that concurrent sessions
+// don't stampede it. It limits how many requests are in flight, and holds new ones back
+// while the rate limit headers of earlier replies say a limit is used up, or after a 429.
+type RequestScheduler struct {
+	slots chan struct{} // One for each request in flight
+
+	mu           sync.Mutex
+	blockedUntil time.Time
+	reason       string // Why requests are held back
+	backoffs     int    // 429s in a row
+	onWait       func(sessionID string, wait time.Duration, reason string)
+}
+
+// NewRequestScheduler creates a scheduler that lets maxConcurrent requests run at once
+func NewRequestScheduler(maxConcurrent int) *RequestScheduler {
+	return &RequestScheduler{slots: make(chan struct{}, max(maxConcurrent, 1))}
+}
+
+var (
+	anthropicScheduler     *RequestScheduler
+	anthropicSchedulerOnce sync.Once
+)
+
+// AnthropicScheduler returns the scheduler of requests to the Anthropic API, shared by
+// every client. Its concurrency is read from the config when it is first used.
+func AnthropicScheduler() *RequestScheduler {
+	anthropicSchedulerOnce.Do(func() {
+		anthropicScheduler = NewRequestScheduler(config.Get().RequestConcurrency)
+	})
+	return anthropicScheduler
+}
+
+// OnWait sets a function called when a session's request is held back by the rate limits
+func (s *RequestScheduler) OnWait(fn func(sessionID string, wait time.Duration, reason string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onWait = fn
+}
+
+// Acquire waits for the request's turn and returns a function that ends it, to call once
+// the reply has been read. sessionID, which may be empty, is who is told about waits.
+func (s *RequestScheduler) Acquire(ctx context.Context, sessionID string) (func(), error) {
+	select {
+	case s.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	release := func() { <-s.slots }
+
+	for {
+		wait, reason, onWait := s.holdBack()
+		if wait <= 0 {
+			return release, nil
+		}
+		// Requests held back together shouldn't all leave together
+		wait += time.Duration(rand.Int63n(int64(wait/10) + int64(250*time.Millisecond)))
+
+		logger.Info("Request waiting for rate limits", "session_id", sessionID, "wait", wait.String(), "reason", reason)
+		if onWait != nil && sessionID != "" {
+			onWait(sessionID, wait, reason)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			release()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// holdBack returns how long requests must still wait, and why
+func (s *RequestScheduler) holdBack() (time.Duration, string, func(string, time.Duration, string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return time.Until(s.blockedUntil), s.reason, s.onWait
+}
+
+// Observe updates the schedule from a reply's status and headers
+func (s *RequestScheduler) Observe(status int, headers http.Header) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if status == http.StatusTooManyRequests {
+		s.backoffs++
+		wait := retryAfter(headers)
+		if wait <= 0 {
+			wait = min(schedulerInitialBackoff<<min(s.backoffs-1, 10), schedulerMaxBackoff)
+		}
+		s.block(now.Add(wait), "rate limited by the API")
+		return
+	}
+	if status < 400 {
+		s.backoffs = 0
+	}
+
+	// A limit with nothing remaining holds requests until it resets
+	for _, limit := range []struct{ name, reason string }{
+		{"requests", "request limit reached"},
+		{"tokens", "token limit reached"},
+		{"input-tokens", "input token limit reached"},
+		{"output-tokens", "output token limit reached"},
+	} {
+		if headers.Get("anthropic-ratelimit-"+limit.name+"-remaining") != "0" {
+			continue
+		}
+		if reset, err := time.Parse(time.RFC3339, headers.Get("anthropic-ratelimit-"+limit.name+"-reset")); err == nil && reset.After(now) {
+			s.block(reset, limit.reason)
+		}
+	}
+}
+
+// block holds requests back until a time, unless they already are for longer. The caller holds mu.
+func (s *RequestScheduler) block(until time.Time, reason string) {
+	if until.After(s.blockedUntil) {
+		s.blockedUntil = until
+		s.reason = reason
+	}
+}
+
+// retryAfter returns the wait a Retry-After header asks for, in seconds, or 0 without one
+func retryAfter(headers http.Header) time.Duration {
+	seconds, err := strconv.Atoi(headers.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// releaseOnClose ends a request's turn when its reply's body is closed
+type releaseOnClose struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (r *releaseOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
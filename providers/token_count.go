@@ -0,0 +1,203 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/rohanthewiz/serr"
+	"rcode/auth"
+	"rcode/config"
+	"rcode/redact"
+)
+
+const (
+	charsPerToken = 4    // Roughly, for English text and code
+	imageTokens   = 1600 // What the API charges for an image at the largest size it keeps
+)
+
+// countTokensRequest is the part of a message request the count_tokens endpoint takes
+type countTokensRequest struct {
+	Model    string      `json:"model"`
+	Messages []Message   `json:"messages"`
+	System   interface{} `json:"system,omitempty"`
+	Tools    interface{} `json:"tools,omitempty"`
+}
+
+// CountTokens asks the API how many input tokens the request would use. Only the Anthropic
+// API counts them; models on other backends are estimated.
+func (c *AnthropicClient) CountTokens(ctx context.Context, request CreateMessageRequest) (int, error) {
+	if backend, err := modelBackend(request.Model); err != nil || backend.name() != BackendAnthropic {
+		return 0, serr.New("counting tokens needs the Anthropic API backend")
+	}
+
+	accessToken, err := auth.GetAccountAccessToken(request.Account)
+	if err != nil {
+		return 0, serr.Wrap(err, "failed to get access token")
+	}
+
+	requestBody, err := json.Marshal(countTokensRequest{
+		Model: request.Model, Messages: request.Messages, System: request.System, Tools: request.Tools,
+	})
+	if err != nil {
+		return 0, serr.Wrap(err, "failed to marshal request")
+	}
+	requestBody = redact.Default().JSON(requestBody)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", config.Get().AnthropicAPIURL+"/count_tokens", bytes.NewReader(requestBody))
+	if err != nil {
+		return 0, serr.Wrap(err, "failed to create request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("anthropic-beta", anthropicBeta)
+	req.Header.Set("anthropic-version", anthropicVersion)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, serr.Wrap(err, "failed to send request")
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, serr.Wrap(err, "failed to read response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, serr.New(fmt.Sprintf("API error: %d - %s", resp.StatusCode, string(body)))
+	}
+
+	var counted struct {
+		InputTokens int `json:"input_tokens"`
+	}
+	if err := json.Unmarshal(body, &counted); err != nil {
+		return 0, serr.Wrap(err, "failed to parse response")
+	}
+	return counted.InputTokens, nil
+}
+
+// EstimateTokens estimates the input tokens of a request without the API, from the length
+// of its text, as the API's tokenizer isn't published, at the tokens per character learned
+// for its model. Images count as imageTokens each.
+func EstimateTokens(request CreateMessageRequest) int {
+	chars, images := requestSize(request)
+	return estimateTokens(request.Model, chars) + images*imageTokens
+}
+
+// estimateTokens returns the tokens a model's text of the given length likely takes
+func estimateTokens(model string, chars int) int {
+	return int(float64(chars) * tokensPerChar(model))
+}
+
+// requestSize returns the characters of a request's text and the number of its images
+func requestSize(request CreateMessageRequest) (chars, images int) {
+	chars = jsonLength(request.System) + jsonLength(request.Tools)
+	for _, msg := range request.Messages {
+		msgChars, msgImages := messageSize(msg)
+		chars += msgChars
+		images += msgImages
+	}
+	return chars, images
+}
+
+// messageSize returns the characters of a message's text and the number of its images
+func messageSize(msg Message) (chars, images int) {
+	switch content := msg.Content.(type) {
+	case string:
+		chars += len(content)
+	case []TextContent:
+		for _, block := range content {
+			chars += len(block.Text)
+		}
+	case []interface{}:
+		for _, block := range content {
+			switch b := block.(type) {
+			case TextContent:
+				chars += len(b.Text)
+			case ImageContent:
+				images++
+			case map[string]interface{}:
+				if b["type"] == "image" {
+					images++
+				} else {
+					chars += jsonLength(b)
+				}
+			default:
+				chars += jsonLength(b)
+			}
+		}
+	default:
+		chars += jsonLength(content)
+	}
+	return chars, images
+}
+
+// Bounds of the tokens per character learned for a model, and the smallest request and the
+// weight of each new count it is learned from
+const (
+	minTokensPerChar   = 0.1
+	maxTokensPerChar   = 0.7
+	minCalibrationSize = 2000 // Characters
+	calibrationWeight  = 0.3
+)
+
+// tokenRatios are the tokens per character each model's requests were counted at, learned
+// from the input tokens of their replies
+var tokenRatios = struct {
+	sync.Mutex
+	byModel map[string]float64
+}{byModel: make(map[string]float64)}
+
+// tokensPerChar returns the tokens per character learned for a model, or 1/charsPerToken
+func tokensPerChar(model string) float64 {
+	tokenRatios.Lock()
+	defer tokenRatios.Unlock()
+	if ratio, ok := tokenRatios.byModel[model]; ok {
+		return ratio
+	}
+	return 1 / float64(charsPerToken)
+}
+
+// observeInputTokens learns a model's tokens per character from the usage a reply reports
+// for a request, counting the tokens read from and written to the prompt cache too
+func observeInputTokens(request CreateMessageRequest, usage json.RawMessage) {
+	var counted struct {
+		InputTokens              int `json:"input_tokens"`
+		CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+		CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+	}
+	if len(usage) == 0 || json.Unmarshal(usage, &counted) != nil {
+		return
+	}
+	chars, images := requestSize(request)
+	tokens := counted.InputTokens + counted.CacheCreationInputTokens + counted.CacheReadInputTokens - images*imageTokens
+	if chars < minCalibrationSize || tokens <= 0 {
+		return
+	}
+	ratio := float64(tokens) / float64(chars)
+	if ratio < minTokensPerChar || ratio > maxTokensPerChar {
+		return
+	}
+
+	tokenRatios.Lock()
+	defer tokenRatios.Unlock()
+	if old, ok := tokenRatios.byModel[request.Model]; ok {
+		ratio = old*(1-calibrationWeight) + ratio*calibrationWeight
+	}
+	tokenRatios.byModel[request.Model] = ratio
+}
+
+// jsonLength returns the length of v as JSON, or 0 when it is nil
+func jsonLength(v interface{}) int {
+	if v == nil {
+		return 0
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}
@@ -0,0 +1,224 @@
+package providers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// A local model without tool use of its own writes a tool call between these tags
+const (
+	toolCallOpen  = "<tool_call>"
+	toolCallClose = "</tool_call>"
+)
+
+// toolDefinition is a tool of a messages request
+type toolDefinition struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+// toolShimPrompt returns the instructions that let a model without tool use of its own call
+// tools, ReAct style: the tools with their input schemas, and how to write a call and read
+// its result
+func toolShimPrompt(tools []toolDefinition) string {
+	var b strings.Builder
+	b.WriteString("# Tools\n\nYou can call these tools:\n")
+	for _, tool := range tools {
+		fmt.Fprintf(&b, "\n## %s\n", tool.Name)
+		if tool.Description != "" {
+			b.WriteString(strings.TrimSpace(tool.Description) + "\n")
+		}
+		if len(tool.InputSchema) > 0 {
+			fmt.Fprintf(&b, "Input schema: %s\n", tool.InputSchema)
+		}
+	}
+	b.WriteString(`
+# Calling tools
+
+Work step by step. Think about what to do next, then either call one tool or give your final answer. To call a tool, write the call in this format and stop:
+
+` + toolCallOpen + `
+{"name": "tool_name", "input": {"parameter": "value"}}
+` + toolCallClose + `
+
+The input must be JSON matching the tool's input schema. The result comes back in the next message as <tool_result name="tool_name">...</tool_result>; read it before deciding the next step. Call only the tools listed, one at a time, and never write a tool result yourself. When you need no more tools, answer without a tool call.`)
+	return b.String()
+}
+
+// toolCallText returns a tool call of an earlier reply as the model writes one
+func toolCallText(name string, input json.RawMessage) string {
+	if len(input) == 0 {
+		input = json.RawMessage("{}")
+	}
+	call, _ := json.Marshal(struct {
+		Name  string          `json:"name"`
+		Input json.RawMessage `json:"input"`
+	}{name, input})
+	return toolCallOpen + "\n" + string(call) + "\n" + toolCallClose
+}
+
+// toolResultText returns a tool's result as the model reads it
+func toolResultText(name, result string, isError bool) string {
+	attrs := fmt.Sprintf("name=%q", name)
+	if isError {
+		attrs += ` error="true"`
+	}
+	return fmt.Sprintf("<tool_result %s>\n%s\n</tool_result>", attrs, result)
+}
+
+// shimCall is a tool call read from a local model's reply
+type shimCall struct {
+	ID    string
+	Name  string
+	Input json.RawMessage
+}
+
+// toolShim takes the tool calls out of a local model's reply as it arrives, giving out the
+// rest as text. A reply without tools is all text.
+type toolShim struct {
+	tools map[string]bool // Names of the request's tools, nil when it has none
+	reply strings.Builder
+	sent  int // Bytes of the reply given out as text
+}
+
+// newToolShim returns the shim of a reply to a request with tools
+func newToolShim(tools []toolDefinition) *toolShim {
+	shim := &toolShim{}
+	if len(tools) > 0 {
+		shim.tools = make(map[string]bool, len(tools))
+		for _, tool := range tools {
+			shim.tools[tool.Name] = true
+		}
+	}
+	return shim
+}
+
+// write adds text the model wrote and returns what of the reply can now be shown as text:
+// all of it up to a tool call, or to where one may be starting
+func (s *toolShim) write(text string) string {
+	s.reply.WriteString(text)
+	reply := s.reply.String()
+	end := len(reply)
+	if s.tools != nil {
+		end = s.textEnd(reply)
+	}
+	if end <= s.sent {
+		return ""
+	}
+	out := reply[s.sent:end]
+	s.sent = end
+	return out
+}
+
+// textEnd returns where a reply's text may end: at its tool call, before an ending that may
+// start one, or at its start while the whole reply may be a call without the tags
+func (s *toolShim) textEnd(reply string) int {
+	if i := strings.Index(reply, toolCallOpen); i >= 0 {
+		return i
+	}
+	if s.sent == 0 && mayBeBareCall(reply) {
+		return 0
+	}
+	for n := len(toolCallOpen) - 1; n > 0; n-- {
+		if strings.HasSuffix(reply, toolCallOpen[:n]) {
+			return len(reply) - n
+		}
+	}
+	return len(reply)
+}
+
+// mayBeBareCall reports whether a reply so far may be nothing but a tool call's JSON, in a
+// code fence or not, as some models write one
+func mayBeBareCall(reply string) bool {
+	trimmed := strings.TrimSpace(reply)
+	return strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "```") || strings.HasPrefix("```", trimmed)
+}
+
+// finish returns the text of the reply not yet given out and the reply's tool calls. A call
+// that can't be read is given out as text, for the user to see what the model wrote.
+func (s *toolShim) finish() (string, []shimCall) {
+	reply := s.reply.String()
+	if s.tools == nil {
+		return reply[s.sent:], nil
+	}
+
+	text := reply
+	var calls []shimCall
+	if i := strings.Index(reply, toolCallOpen); i >= 0 {
+		text = reply[:i]
+		// What follows a call is the model writing its result, which it was asked not to
+		for _, part := range strings.Split(reply[i+len(toolCallOpen):], toolCallOpen) {
+			part, _, _ = strings.Cut(part, toolCallClose)
+			if call, ok := parseToolCall(part); ok {
+				calls = append(calls, call)
+			} else {
+				text += toolCallOpen + part
+			}
+		}
+	} else if s.sent == 0 {
+		if call, ok := parseToolCall(reply); ok && s.tools[call.Name] {
+			text = ""
+			calls = append(calls, call)
+		}
+	}
+
+	rest := ""
+	if len(text) > s.sent {
+		rest = text[s.sent:]
+	}
+	if len(calls) > 0 {
+		rest = strings.TrimRight(rest, " \t\r\n")
+	}
+	s.sent = len(reply)
+	return rest, calls
+}
+
+// parseToolCall reads a tool call's JSON as models write it: in a code fence or not, with
+// the input under input, arguments or parameters, as an object or a JSON string
+func parseToolCall(text string) (shimCall, bool) {
+	start, end := strings.Index(text, "{"), strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		return shimCall{}, false
+	}
+	var call struct {
+		Name       string          `json:"name"`
+		Input      json.RawMessage `json:"input"`
+		Arguments  json.RawMessage `json:"arguments"`
+		Parameters json.RawMessage `json:"parameters"`
+	}
+	if err := json.Unmarshal([]byte(text[start:end+1]), &call); err != nil || call.Name == "" {
+		return shimCall{}, false
+	}
+
+	input := call.Input
+	if len(input) == 0 {
+		input = call.Arguments
+	}
+	if len(input) == 0 {
+		input = call.Parameters
+	}
+	var encoded string
+	if json.Unmarshal(input, &encoded) == nil {
+		input = json.RawMessage(encoded)
+	}
+	if len(input) == 0 || string(input) == "null" {
+		input = json.RawMessage("{}")
+	}
+	var object map[string]interface{}
+	if json.Unmarshal(input, &object) != nil {
+		return shimCall{}, false
+	}
+	compact, _ := json.Marshal(object)
+	return shimCall{ID: newToolUseID(), Name: call.Name, Input: compact}, true
+}
+
+// newToolUseID returns an ID for a tool call the shim read, in the form of the API's
+func newToolUseID() string {
+	id := make([]byte, 12)
+	_, _ = rand.Read(id)
+	return "toolu_" + hex.EncodeToString(id)
+}
@@ -0,0 +1,249 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rohanthewiz/serr"
+)
+
+const (
+	vertexVersion  = "vertex-2023-10-16" // The anthropic_version Vertex AI takes
+	googleScope    = "https://www.googleapis.com/auth/cloud-platform"
+	googleTokenURL = "https://oauth2.googleapis.com/token"
+	// googleMetadataTokenURL gives the token of the service account of a Google Cloud machine
+	googleMetadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+// vertexBackend sends requests to Claude on Google Vertex AI, with a token from the
+// Application Default Credentials
+type vertexBackend struct {
+	project string
+	region  string
+	modelID string // Vertex AI's ID of the model, e.g. claude-sonnet-4@20250514
+}
+
+func (v *vertexBackend) name() string { return BackendVertex }
+
+func (v *vertexBackend) newRequest(ctx context.Context, body []byte, stream bool, account string) (*http.Request, error) {
+	body, err := rewriteBody(body, []string{"model"}, map[string]string{"anthropic_version": `"` + vertexVersion + `"`})
+	if err != nil {
+		return nil, err
+	}
+
+	host := v.region + "-aiplatform.googleapis.com"
+	if v.region == "global" {
+		host = "aiplatform.googleapis.com"
+	}
+	method := "rawPredict"
+	if stream {
+		method = "streamRawPredict"
+	}
+	endpoint := fmt.Sprintf("https://%s/v1/projects/%s/locations/%s/publishers/anthropic/models/%s:%s",
+		host, url.PathEscape(v.project), url.PathEscape(v.region), url.PathEscape(v.modelID), method)
+
+	token, err := googleAccessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to create request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+	return req, nil
+}
+
+// adaptResponse leaves replies alone: Vertex AI streams the Anthropic API's events
+func (v *vertexBackend) adaptResponse(*http.Response) {}
+
+// googleCredentials is the part of an Application Default Credentials file rcode uses: a
+// user's login by gcloud, or a service account's key
+type googleCredentials struct {
+	Type         string `json:"type"` // authorized_user or service_account
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// googleToken is the cached access token of the Application Default Credentials
+var googleToken struct {
+	sync.Mutex
+	value   string
+	expires time.Time
+}
+
+// googleHTTPClient gets Google tokens; a token request holds up the requests waiting for it
+var googleHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// googleAccessToken returns an access token of the Application Default Credentials: the
+// file GOOGLE_APPLICATION_CREDENTIALS names, or gcloud's, or the service account of the
+// Google Cloud machine rcode runs on
+func googleAccessToken(ctx context.Context) (string, error) {
+	googleToken.Lock()
+	defer googleToken.Unlock()
+	if googleToken.value != "" && time.Now().Add(time.Minute).Before(googleToken.expires) {
+		return googleToken.value, nil
+	}
+
+	var req *http.Request
+	creds, err := loadGoogleCredentials()
+	switch {
+	case err != nil:
+		return "", err
+	case creds == nil:
+		req, err = http.NewRequestWithContext(ctx, "GET", googleMetadataTokenURL, nil)
+		if err == nil {
+			req.Header.Set("Metadata-Flavor", "Google")
+		}
+	case creds.Type == "authorized_user":
+		req, err = googleTokenRequest(ctx, googleTokenURL, url.Values{
+			"grant_type":    {"refresh_token"},
+			"client_id":     {creds.ClientID},
+			"client_secret": {creds.ClientSecret},
+			"refresh_token": {creds.RefreshToken},
+		})
+	case creds.Type == "service_account":
+		tokenURL := creds.TokenURI
+		if tokenURL == "" {
+			tokenURL = googleTokenURL
+		}
+		var assertion string
+		assertion, err = serviceAccountAssertion(creds, tokenURL)
+		if err == nil {
+			req, err = googleTokenRequest(ctx, tokenURL, url.Values{
+				"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+				"assertion":  {assertion},
+			})
+		}
+	default:
+		return "", serr.New(fmt.Sprintf("unsupported Google credentials type %q: use a user login or a service account key", creds.Type))
+	}
+	if err != nil {
+		return "", serr.Wrap(err, "failed to create Google token request")
+	}
+
+	resp, err := googleHTTPClient.Do(req)
+	if err != nil {
+		return "", serr.Wrap(err, "failed to get a Google access token; log in with gcloud auth application-default login")
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", serr.Wrap(err, "failed to read Google token response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", serr.New(fmt.Sprintf("Google token request failed: %d - %s", resp.StatusCode, string(body)))
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil || token.AccessToken == "" {
+		return "", serr.New("invalid Google token response")
+	}
+	googleToken.value = token.AccessToken
+	googleToken.expires = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	return token.AccessToken, nil
+}
+
+// loadGoogleCredentials reads the Application Default Credentials file, returning nil when
+// there is none, for the metadata server to be asked
+func loadGoogleCredentials() (*googleCredentials, error) {
+	path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if path == "" {
+		configDir := os.Getenv("CLOUDSDK_CONFIG")
+		if configDir == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil, nil
+			}
+			configDir = filepath.Join(home, ".config", "gcloud")
+		}
+		path = filepath.Join(configDir, "application_default_credentials.json")
+		if _, err := os.Stat(path); err != nil {
+			return nil, nil
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to read Google credentials", "path", path)
+	}
+	var creds googleCredentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, serr.Wrap(err, "invalid Google credentials", "path", path)
+	}
+	return &creds, nil
+}
+
+// googleTokenRequest returns a POST of a form to a token endpoint
+func googleTokenRequest(ctx context.Context, tokenURL string, form url.Values) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req, nil
+}
+
+// serviceAccountAssertion returns the signed JWT a service account exchanges for a token
+func serviceAccountAssertion(creds *googleCredentials, tokenURL string) (string, error) {
+	block, _ := pem.Decode([]byte(creds.PrivateKey))
+	if block == nil {
+		return "", serr.New("the service account's private key isn't PEM")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return "", serr.Wrap(err, "failed to parse the service account's private key")
+		}
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return "", serr.New("the service account's private key isn't RSA")
+	}
+
+	now := time.Now()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": creds.PrivateKeyID})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   creds.ClientEmail,
+		"scope": googleScope,
+		"aud":   tokenURL,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", serr.Wrap(err, "failed to sign the service account's assertion")
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
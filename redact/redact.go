@@ -0,0 +1,364 @@
+// Package redact masks secrets in text bound for the model or the database: API keys and
+// tokens by their known formats, values assigned to secret-looking names when they look
+// random, the values of the project's .env files and the project's own patterns.
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"rcode/config"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/serr"
+)
+
+// PatternsFile is the project's file of its own patterns, relative to the project
+const PatternsFile = ".rcode/redact.json"
+
+// Values assigned to secret-looking names are masked when they are at least this long and
+// have at least this many bits of entropy per character
+const (
+	minAssignedLength  = 12
+	minAssignedEntropy = 3.0
+)
+
+// .env values are masked when they are at least this long, mix letters and digits and have
+// at least this many bits of entropy per character, so hosts, user names and environment
+// names such as localhost, postgres or production are left alone
+const (
+	minEnvValueLength = 8
+	minEnvEntropy     = 2.5
+)
+
+// Pattern is a project's own secret format. When the regex has a group, only the first
+// group's text is masked.
+type Pattern struct {
+	Name  string `json:"name"`
+	Regex string `json:"regex"`
+}
+
+// patternsFile is the layout of PatternsFile
+type patternsFile struct {
+	Patterns []Pattern `json:"patterns"`
+}
+
+// rule masks the text a regex matches, or its first group's
+type rule struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// builtinRules find secrets by their providers' formats
+var builtinRules = []rule{
+	{"private key", regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)},
+	{"anthropic key", regexp.MustCompile(`\bsk-ant-[A-Za-z0-9_-]{20,}`)},
+	{"openai key", regexp.MustCompile(`\bsk-(?:proj-)?[A-Za-z0-9_-]{32,}`)},
+	{"aws access key", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"github token", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})`)},
+	{"gitlab token", regexp.MustCompile(`\bglpat-[A-Za-z0-9_-]{20,}`)},
+	{"slack token", regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{"stripe key", regexp.MustCompile(`\b[rs]k_(?:live|test)_[A-Za-z0-9]{16,}`)},
+	{"google api key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}`)},
+	{"jwt", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+}
+
+// assignmentPattern finds values assigned to secret-looking names, in code, config and
+// shell, capturing the value
+var assignmentPattern = regexp.MustCompile(`(?i)[\w.-]*(?:api[_-]?key|secret|token|passw(?:or)?d|credential|auth)[\w.-]*["']?\s*[:=]\s*["']?([^\s"'\x60,;]+)`)
+
+// literal is a .env value, masked wherever it appears
+type literal struct {
+	value string
+	name  string
+}
+
+// Redactor masks secrets in text. A nil Redactor masks nothing.
+type Redactor struct {
+	rules    []rule
+	literals []literal // Longest first, so a value containing another is masked whole
+}
+
+// Load returns a redactor for the project at root: the built-in rules, the patterns of
+// its PatternsFile and the values of its .env files. When the PatternsFile is invalid,
+// the error says why and the redactor returned goes without its patterns.
+func Load(root string) (*Redactor, error) {
+	r := &Redactor{rules: builtinRules}
+	for _, envPath := range envFiles(root) {
+		r.literals = append(r.literals, readEnvValues(envPath)...)
+	}
+	sort.SliceStable(r.literals, func(i, j int) bool { return len(r.literals[i].value) > len(r.literals[j].value) })
+
+	rules, err := loadPatterns(filepath.Join(root, PatternsFile))
+	if err != nil {
+		return r, err
+	}
+	r.rules = append(append([]rule(nil), builtinRules...), rules...)
+	return r, nil
+}
+
+// loadPatterns reads a patterns file's rules; a file that doesn't exist has none
+func loadPatterns(path string) ([]rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, serr.Wrap(err, "failed to read redaction patterns", "path", path)
+	}
+
+	var file patternsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, serr.Wrap(err, "invalid redaction patterns file", "path", path)
+	}
+	rules := make([]rule, 0, len(file.Patterns))
+	for _, p := range file.Patterns {
+		re, err := regexp.Compile(p.Regex)
+		if err != nil {
+			return nil, serr.Wrap(err, fmt.Sprintf("invalid redaction pattern %q", p.Name), "path", path)
+		}
+		name := p.Name
+		if name == "" {
+			name = "secret"
+		}
+		rules = append(rules, rule{name: name, re: re})
+	}
+	return rules, nil
+}
+
+// envFiles returns the project's .env files, leaving out examples, which hold no secrets
+func envFiles(root string) []string {
+	matches, _ := filepath.Glob(filepath.Join(root, ".env*"))
+	var files []string
+	for _, path := range matches {
+		name := filepath.Base(path)
+		if strings.HasSuffix(name, ".example") || strings.HasSuffix(name, ".sample") || strings.HasSuffix(name, ".template") {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// readEnvValues returns the values of a .env file's KEY=VALUE lines that look like secrets
+func readEnvValues(path string) []literal {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		logger.LogErr(err, "failed to read env file for redaction", "path", path)
+		return nil
+	}
+
+	var values []literal
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		if isRandom(value, minEnvValueLength, minEnvEntropy) {
+			values = append(values, literal{value: value, name: strings.TrimSpace(key)})
+		}
+	}
+	return values
+}
+
+// String returns s with its secrets masked as [REDACTED:name]
+func (r *Redactor) String(s string) string {
+	if r == nil || s == "" {
+		return s
+	}
+
+	for _, lit := range r.literals {
+		if strings.Contains(s, lit.value) {
+			s = strings.ReplaceAll(s, lit.value, mask(lit.name))
+		}
+	}
+	for _, rl := range r.rules {
+		s = replaceMatches(rl.re, s, func(string) string { return mask(rl.name) })
+	}
+	return replaceMatches(assignmentPattern, s, func(value string) string {
+		if looksRandom(value) {
+			return mask("secret")
+		}
+		return value
+	})
+}
+
+// replaceMatches replaces the first group of each match of re, or the whole match when re
+// has no groups, with what replace returns for it
+func replaceMatches(re *regexp.Regexp, s string, replace func(string) string) string {
+	matches := re.FindAllStringSubmatchIndex(s, -1)
+	if matches == nil {
+		return s
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if len(m) >= 4 && m[2] >= 0 {
+			start, end = m[2], m[3]
+		}
+		b.WriteString(s[last:start])
+		b.WriteString(replace(s[start:end]))
+		last = end
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// mask is what a secret is replaced with
+func mask(name string) string {
+	return "[REDACTED:" + name + "]"
+}
+
+// looksRandom reports whether a value assigned to a secret-looking name is likely a secret
+// rather than code: long enough, mixing letters and digits, and high in entropy
+func looksRandom(value string) bool {
+	return !strings.HasPrefix(value, "[REDACTED:") && isRandom(value, minAssignedLength, minAssignedEntropy)
+}
+
+// isRandom reports whether a value is at least minLength long, mixes letters and digits and
+// has at least minEntropy bits of entropy per character
+func isRandom(value string, minLength int, minEntropy float64) bool {
+	if len(value) < minLength {
+		return false
+	}
+	if !strings.ContainsAny(value, "0123456789") || !strings.ContainsAny(strings.ToLower(value), "abcdefghijklmnopqrstuvwxyz") {
+		return false
+	}
+	return entropy(value) >= minEntropy
+}
+
+// entropy returns a string's Shannon entropy, in bits per character
+func entropy(s string) float64 {
+	counts := make(map[rune]int)
+	total := 0
+	for _, c := range s {
+		counts[c]++
+		total++
+	}
+	bits := 0.0
+	for _, n := range counts {
+		p := float64(n) / float64(total)
+		bits -= p * math.Log2(p)
+	}
+	return bits
+}
+
+// JSON returns a JSON document with the secrets in its strings masked. A document that
+// isn't JSON, or has nothing to mask, is returned as it is.
+func (r *Redactor) JSON(data []byte) []byte {
+	if r == nil || len(data) == 0 {
+		return data
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return data
+	}
+
+	doc, changed := r.value(doc)
+	if !changed {
+		return data
+	}
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(doc); err != nil {
+		return data
+	}
+	return bytes.TrimRight(out.Bytes(), "\n")
+}
+
+// value masks the strings in a decoded JSON value, reporting whether any changed
+func (r *Redactor) value(v interface{}) (interface{}, bool) {
+	switch v := v.(type) {
+	case string:
+		masked := r.String(v)
+		return masked, masked != v
+	case []interface{}:
+		changed := false
+		for i, item := range v {
+			var itemChanged bool
+			v[i], itemChanged = r.value(item)
+			changed = changed || itemChanged
+		}
+		return v, changed
+	case map[string]interface{}:
+		changed := false
+		for key, item := range v {
+			var itemChanged bool
+			v[key], itemChanged = r.value(item)
+			changed = changed || itemChanged
+		}
+		return v, changed
+	}
+	return v, false
+}
+
+// current is the redactor of the working directory's project, loaded again when its
+// sources change
+var current struct {
+	sync.Mutex
+	root     string
+	stamp    string
+	redactor *Redactor
+}
+
+// Default returns the redactor of the project rcode runs in, or nil when RCODE_REDACT
+// turns redaction off. An invalid PatternsFile is logged and left out.
+func Default() *Redactor {
+	if !config.Get().Redact {
+		return nil
+	}
+	root, err := os.Getwd()
+	if err != nil {
+		root = "."
+	}
+	stamp := sourcesStamp(root)
+
+	current.Lock()
+	defer current.Unlock()
+	if current.redactor != nil && current.root == root && current.stamp == stamp {
+		return current.redactor
+	}
+
+	redactor, err := Load(root)
+	if err != nil {
+		logger.LogErr(err, "failed to load the project's redaction patterns, using the built-in ones")
+	}
+	current.root, current.stamp, current.redactor = root, stamp, redactor
+	return redactor
+}
+
+// sourcesStamp identifies the versions of a project's PatternsFile and .env files, to
+// tell when they change
+func sourcesStamp(root string) string {
+	var b strings.Builder
+	for _, path := range append(envFiles(root), filepath.Join(root, PatternsFile)) {
+		if info, err := os.Stat(path); err == nil {
+			fmt.Fprintf(&b, "%s:%d:%d;", path, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return b.String()
+}
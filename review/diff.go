@@ -0,0 +1,197 @@
+package review
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Largest diff text sent to the model in one request. Files bigger than this are
+// split between hunks; a single hunk over the limit is truncated.
+const maxChunkBytes = 40000
+
+// FileDiff is the part of a unified diff that changes one file
+type FileDiff struct {
+	Path  string
+	Hunks []Hunk
+}
+
+// Hunk is one @@ section of a file diff with lines annotated by new-file line number
+type Hunk struct {
+	Header string
+	Lines  []string
+}
+
+// Chunk is a group of file diffs reviewed in one model request
+type Chunk struct {
+	Files []FileDiff
+}
+
+// ParseDiff splits the output of git diff into per-file hunks. Deleted and binary
+// files are skipped since there are no new lines to comment on. Each added or
+// context line is prefixed with its line number in the new file, so the model
+// can anchor findings to real lines.
+func ParseDiff(diff string) []FileDiff {
+	var files []FileDiff
+	var current *FileDiff
+	var hunk *Hunk
+	newLine := 0
+
+	flush := func() {
+		if current != nil && hunk != nil {
+			current.Hunks = append(current.Hunks, *hunk)
+		}
+		hunk = nil
+	}
+	finishFile := func() {
+		flush()
+		if current != nil && current.Path != "" && len(current.Hunks) > 0 {
+			files = append(files, *current)
+		}
+		current = nil
+	}
+
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			finishFile()
+			current = &FileDiff{}
+		case current == nil:
+			continue
+		case hunk == nil && strings.HasPrefix(line, "+++ "):
+			path := strings.TrimPrefix(line, "+++ ")
+			if path == "/dev/null" {
+				current.Path = "" // Deleted file
+			} else {
+				current.Path = strings.TrimPrefix(path, "b/")
+			}
+		case strings.HasPrefix(line, "@@"):
+			flush()
+			newLine = hunkNewStart(line)
+			hunk = &Hunk{Header: line}
+		case hunk == nil:
+			continue // Extended headers, "Binary files differ" and the --- line
+		case strings.HasPrefix(line, "+"):
+			hunk.Lines = append(hunk.Lines, fmt.Sprintf("%5d +%s", newLine, line[1:]))
+			newLine++
+		case strings.HasPrefix(line, "-"):
+			hunk.Lines = append(hunk.Lines, "      -"+line[1:])
+		case strings.HasPrefix(line, " "):
+			hunk.Lines = append(hunk.Lines, fmt.Sprintf("%5d  %s", newLine, line[1:]))
+			newLine++
+		case strings.HasPrefix(line, `\`):
+			hunk.Lines = append(hunk.Lines, "       "+line) // No newline at end of file
+		}
+	}
+	finishFile()
+
+	return files
+}
+
+// hunkNewStart returns the new-file start line from a header like "@@ -10,7 +12,8 @@"
+func hunkNewStart(header string) int {
+	fields := strings.Fields(header)
+	for _, field := range fields[1:] {
+		if strings.HasPrefix(field, "+") {
+			start, _, _ := strings.Cut(field[1:], ",")
+			if n, err := strconv.Atoi(start); err == nil {
+				return n
+			}
+		}
+	}
+	return 1
+}
+
+// size is the length of the hunk's text
+func (h Hunk) size() int {
+	n := len(h.Header) + 1
+	for _, line := range h.Lines {
+		n += len(line) + 1
+	}
+	return n
+}
+
+// ChunkFiles groups file diffs into chunks of at most maxChunkBytes, keeping
+// each file whole when it fits and otherwise splitting it between hunks
+func ChunkFiles(files []FileDiff) []Chunk {
+	var chunks []Chunk
+	var current Chunk
+	size := 0
+
+	add := func(file FileDiff, fileSize int) {
+		if size > 0 && size+fileSize > maxChunkBytes {
+			chunks = append(chunks, current)
+			current = Chunk{}
+			size = 0
+		}
+		current.Files = append(current.Files, file)
+		size += fileSize
+	}
+
+	for _, file := range files {
+		part := FileDiff{Path: file.Path}
+		partSize := 0
+		for _, hunk := range file.Hunks {
+			if hunk.size() > maxChunkBytes {
+				hunk = truncateHunk(hunk)
+			}
+			if partSize > 0 && partSize+hunk.size() > maxChunkBytes {
+				add(part, partSize)
+				part = FileDiff{Path: file.Path}
+				partSize = 0
+			}
+			part.Hunks = append(part.Hunks, hunk)
+			partSize += hunk.size()
+		}
+		add(part, partSize)
+	}
+	if len(current.Files) > 0 {
+		chunks = append(chunks, current)
+	}
+
+	return chunks
+}
+
+// truncateHunk drops the end of a hunk too large for one request
+func truncateHunk(hunk Hunk) Hunk {
+	truncated := Hunk{Header: hunk.Header}
+	size := len(hunk.Header) + 1
+	for _, line := range hunk.Lines {
+		if size+len(line)+1 > maxChunkBytes-100 {
+			truncated.Lines = append(truncated.Lines, "       ... (hunk truncated)")
+			break
+		}
+		truncated.Lines = append(truncated.Lines, line)
+		size += len(line) + 1
+	}
+	return truncated
+}
+
+// Paths returns the files in the chunk
+func (c Chunk) Paths() []string {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, file := range c.Files {
+		if !seen[file.Path] {
+			seen[file.Path] = true
+			paths = append(paths, file.Path)
+		}
+	}
+	return paths
+}
+
+// String renders the chunk as annotated diff text for the prompt
+func (c Chunk) String() string {
+	var sb strings.Builder
+	for _, file := range c.Files {
+		sb.WriteString("=== " + file.Path + "\n")
+		for _, hunk := range file.Hunks {
+			sb.WriteString(hunk.Header + "\n")
+			for _, line := range hunk.Lines {
+				sb.WriteString(line + "\n")
+			}
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
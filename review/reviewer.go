@@ -0,0 +1,239 @@
+package review
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"rcode/completion"
+	"rcode/db"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/serr"
+)
+
+// StagedRef is the head ref that reviews the changes staged in the index
+const StagedRef = "STAGED"
+
+// Store persists reviews and their findings. It is implemented by *db.DB.
+type Store interface {
+	CreateReview(review *db.CodeReview) error
+	FinishReview(id int, summary string, filesReviewed int, errMsg string) error
+	AddReviewFindings(reviewID int, findings []db.ReviewFinding) error
+}
+
+// Progress reports that chunk done of total has been reviewed
+type Progress func(done, total int, findings []db.ReviewFinding)
+
+// Reviewer runs model reviews of a repository's changes
+type Reviewer struct {
+	store       Store
+	model       completion.Client
+	projectRoot string
+}
+
+// NewReviewer creates a reviewer for the repository at projectRoot
+func NewReviewer(store Store, model completion.Client, projectRoot string) *Reviewer {
+	return &Reviewer{store: store, model: model, projectRoot: projectRoot}
+}
+
+// modelReply is the JSON document the model must return for each chunk
+type modelReply struct {
+	Summary  string         `json:"summary"`
+	Findings []modelFinding `json:"findings"`
+}
+
+type modelFinding struct {
+	File       string `json:"file"`
+	Line       int    `json:"line"`
+	EndLine    int    `json:"end_line"`
+	Severity   string `json:"severity"`
+	Title      string `json:"title"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion"`
+}
+
+// Start resolves the refs, records the review and collects the diff. The returned
+// review is running; call Run with the diff to review it.
+// An empty base means the default branch and an empty head means HEAD;
+// a head of StagedRef reviews the staged changes against HEAD.
+func (r *Reviewer) Start(base, head string) (*db.CodeReview, string, error) {
+	var diff string
+	var err error
+
+	if head == StagedRef {
+		base = "HEAD"
+		diff, err = r.git("diff", "--cached", "--no-color", "--no-ext-diff")
+	} else {
+		if head == "" {
+			head = "HEAD"
+		}
+		if base == "" {
+			if base, err = r.defaultBranch(); err != nil {
+				return nil, "", err
+			}
+		}
+		// Three dots compare head with where it forked from base, like a pull request
+		diff, err = r.git("diff", "--no-color", "--no-ext-diff", base+"..."+head)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if strings.TrimSpace(diff) == "" {
+		return nil, "", serr.New(fmt.Sprintf("no changes between %s and %s", base, head))
+	}
+
+	review := &db.CodeReview{ProjectRoot: r.projectRoot, BaseRef: base, HeadRef: head}
+	if err := r.store.CreateReview(review); err != nil {
+		return nil, "", err
+	}
+	return review, diff, nil
+}
+
+// Run reviews the diff chunk by chunk, storing findings as they arrive, and records
+// the outcome on the review. A chunk the model fails on fails the whole review.
+func (r *Reviewer) Run(review *db.CodeReview, diff string, progress Progress) error {
+	files := ParseDiff(diff)
+	chunks := ChunkFiles(files)
+
+	var summaries []string
+	for i, chunk := range chunks {
+		summary, findings, err := r.reviewChunk(review, chunk)
+		if err != nil {
+			err = serr.Wrap(err, fmt.Sprintf("failed to review chunk %d of %d", i+1, len(chunks)))
+			if ferr := r.store.FinishReview(review.ID, strings.Join(summaries, "\n\n"), len(files), err.Error()); ferr != nil {
+				logger.LogErr(ferr, "failed to record review failure")
+			}
+			return err
+		}
+
+		if len(findings) > 0 {
+			if err := r.store.AddReviewFindings(review.ID, findings); err != nil {
+				return err
+			}
+		}
+		if summary != "" {
+			summaries = append(summaries, summary)
+		}
+		if progress != nil {
+			progress(i+1, len(chunks), findings)
+		}
+	}
+
+	return r.store.FinishReview(review.ID, strings.Join(summaries, "\n\n"), len(files), "")
+}
+
+// reviewChunk asks the model to review one chunk and validates its findings
+func (r *Reviewer) reviewChunk(review *db.CodeReview, chunk Chunk) (string, []db.ReviewFinding, error) {
+	reply, err := r.model.Complete(reviewPrompt(review, chunk))
+	if err != nil {
+		return "", nil, serr.Wrap(err, "model request failed")
+	}
+
+	raw := completion.ExtractJSONObject(reply)
+	if raw == "" {
+		return "", nil, serr.New("model reply contains no JSON object")
+	}
+	var parsed modelReply
+	if err := json.Unmarshal([]byte(raw), &parsed); err != nil {
+		return "", nil, serr.Wrap(err, "model reply does not match review schema")
+	}
+
+	paths := make(map[string]bool)
+	for _, path := range chunk.Paths() {
+		paths[path] = true
+	}
+
+	findings := make([]db.ReviewFinding, 0, len(parsed.Findings))
+	for _, f := range parsed.Findings {
+		if !paths[f.File] || strings.TrimSpace(f.Message) == "" {
+			continue // Findings must point at a file in this chunk
+		}
+
+		finding := db.ReviewFinding{
+			ReviewID:   review.ID,
+			FilePath:   f.File,
+			Line:       max(f.Line, 1),
+			EndLine:    f.EndLine,
+			Severity:   strings.ToLower(strings.TrimSpace(f.Severity)),
+			Title:      strings.TrimSpace(f.Title),
+			Message:    strings.TrimSpace(f.Message),
+			Suggestion: strings.TrimSpace(f.Suggestion),
+		}
+		if finding.EndLine < finding.Line {
+			finding.EndLine = finding.Line
+		}
+		if !db.ValidSeverity(finding.Severity) {
+			finding.Severity = db.SeverityInfo
+		}
+		if finding.Title == "" {
+			finding.Title = firstLine(finding.Message)
+		}
+		findings = append(findings, finding)
+	}
+
+	return strings.TrimSpace(parsed.Summary), findings, nil
+}
+
+// reviewPrompt asks for structured findings on the chunk's changes
+func reviewPrompt(review *db.CodeReview, chunk Chunk) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("Review the following changes (%s against %s) as an experienced code reviewer.\n\n", review.HeadRef, review.BaseRef))
+	sb.WriteString("Each file starts with a === line. Added lines are marked + and unchanged lines have no mark;\n")
+	sb.WriteString("both are prefixed with their line number in the new file. Removed lines are marked - and have no number.\n\n")
+	sb.WriteString("Rules:\n")
+	sb.WriteString("- Report bugs, security problems, error handling gaps, race conditions and unclear or risky code in the changed lines\n")
+	sb.WriteString("- Do not report formatting or matters of taste, and do not praise the code\n")
+	sb.WriteString("- line and end_line are new-file line numbers from the diff; file is the path after ===\n")
+	sb.WriteString("- severity is critical (breaks or endangers production), major (a real bug), minor (worth fixing) or info (a note)\n")
+	sb.WriteString("- suggestion is the fix, as replacement code or a short instruction; leave it empty if there is none\n")
+	sb.WriteString("- summary is one or two sentences on these changes overall\n")
+	sb.WriteString("- Reply with a single JSON object and nothing else, in this form:\n")
+	sb.WriteString(`{"summary": "...", "findings": [{"file": "path", "line": 12, "end_line": 14, "severity": "major", "title": "...", "message": "...", "suggestion": "..."}]}`)
+	sb.WriteString("\n\nChanges:\n\n")
+	sb.WriteString(chunk.String())
+
+	return sb.String()
+}
+
+// defaultBranch returns the branch the remote's HEAD points at, falling back to main or master
+func (r *Reviewer) defaultBranch() (string, error) {
+	if ref, err := r.git("symbolic-ref", "--short", "refs/remotes/origin/HEAD"); err == nil {
+		if ref = strings.TrimSpace(ref); ref != "" {
+			return ref, nil
+		}
+	}
+	for _, branch := range []string{"main", "master"} {
+		if _, err := r.git("rev-parse", "--verify", "--quiet", branch); err == nil {
+			return branch, nil
+		}
+	}
+	return "", serr.New("could not find a default branch; set the base to review against")
+}
+
+// git runs a read-only git command in the project root
+func (r *Reviewer) git(args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.projectRoot
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", serr.Wrap(err, fmt.Sprintf("git %s failed: %s", args[0], strings.TrimSpace(stderr.String())))
+	}
+	return stdout.String(), nil
+}
+
+// firstLine returns the first line of s, shortened for use as a title
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	if len(line) > 80 {
+		line = line[:77] + "..."
+	}
+	return line
+}
@@ -0,0 +1,382 @@
+// Package scaffold renders project templates: sets of files, named and filled in from
+// variables, that start a new part of a project. Templates are built in, or kept in the
+// user's and the project's template directories.
+package scaffold
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// ManifestFile describes a template; the template's other files are rendered
+const ManifestFile = "template.json"
+
+// TemplateSuffix is dropped from the names of rendered files, so a template's Go files
+// aren't built as part of the project holding them
+const TemplateSuffix = ".tmpl"
+
+// builtinFS holds the templates shipped with rcode, one directory each
+//
+//go:embed templates
+var builtinFS embed.FS
+
+// templateName is what a template may be called
+var templateName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// variableName is what a variable may be called, so templates can use it as {{.name}}
+var variableName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+// Template is a set of files rendered with variables into a new part of a project
+type Template struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Variables   []Variable `json:"variables"`
+	Source      string     `json:"-"` // "builtin", or the directory the template was loaded from
+
+	files fs.FS // The template's directory
+}
+
+// Variable is a value a template is rendered with
+type Variable struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Default     string `json:"default,omitempty"` // Rendered with the variables before it, so it may use them
+	Required    bool   `json:"required,omitempty"`
+}
+
+// File is a rendered file of a template
+type File struct {
+	Path    string // Slash-separated, relative to where the template is generated
+	Content string
+}
+
+// funcs are the functions templates use to change the case of names
+var funcs = template.FuncMap{
+	"lower":  strings.ToLower,
+	"upper":  strings.ToUpper,
+	"pascal": pascalCase,
+	"camel":  camelCase,
+	"snake":  func(s string) string { return joinWords(s, "_") },
+	"kebab":  func(s string) string { return joinWords(s, "-") },
+}
+
+// Builtin returns the templates shipped with rcode
+func Builtin() []*Template {
+	sub, err := fs.Sub(builtinFS, "templates")
+	if err != nil {
+		panic(err) // The directory is embedded
+	}
+	templates, err := loadDir(sub, "builtin")
+	if err != nil {
+		panic(err) // Checked when they were written
+	}
+	return templates
+}
+
+// Load returns the built-in templates, then those in dirs, each a directory holding a
+// directory per template. A template in a later directory replaces one of the same name.
+// Directories that don't exist are skipped.
+func Load(dirs ...string) ([]*Template, error) {
+	templates := Builtin()
+	index := make(map[string]int, len(templates))
+	for i, t := range templates {
+		index[t.Name] = i
+	}
+
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			continue
+		}
+		loaded, err := loadDir(os.DirFS(dir), dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range loaded {
+			if i, ok := index[t.Name]; ok {
+				templates[i] = t
+				continue
+			}
+			index[t.Name] = len(templates)
+			templates = append(templates, t)
+		}
+	}
+	return templates, nil
+}
+
+// Find returns the template with the name, or nil
+func Find(templates []*Template, name string) *Template {
+	for _, t := range templates {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// loadDir reads the templates in a directory, sorted by name. Subdirectories without a
+// manifest are skipped.
+func loadDir(dir fs.FS, source string) ([]*Template, error) {
+	entries, err := fs.ReadDir(dir, ".")
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to read templates directory", "dir", source)
+	}
+
+	var templates []*Template
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		data, err := fs.ReadFile(dir, path.Join(entry.Name(), ManifestFile))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, serr.Wrap(err, "failed to read template manifest", "template", entry.Name())
+		}
+
+		t := &Template{Name: entry.Name()}
+		if err := json.Unmarshal(data, t); err != nil {
+			return nil, serr.Wrap(err, "invalid template manifest", "template", entry.Name(), "dir", source)
+		}
+		t.Source = source
+		if t.files, err = fs.Sub(dir, entry.Name()); err != nil {
+			return nil, serr.Wrap(err, "failed to open template", "template", entry.Name())
+		}
+		if err := t.validate(); err != nil {
+			return nil, serr.Wrap(err, "invalid template", "dir", source)
+		}
+		templates = append(templates, t)
+	}
+
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	return templates, nil
+}
+
+// validate checks the manifest's names and that every file parses
+func (t *Template) validate() error {
+	if !templateName.MatchString(t.Name) {
+		return serr.New(fmt.Sprintf("template %q: name must be 1 to 64 lowercase letters, digits, _ or -", t.Name))
+	}
+
+	names := make(map[string]bool, len(t.Variables))
+	for _, v := range t.Variables {
+		if !variableName.MatchString(v.Name) {
+			return serr.New(fmt.Sprintf("template %s: variable %q must be a letter followed by letters, digits or _", t.Name, v.Name))
+		}
+		if names[v.Name] {
+			return serr.New(fmt.Sprintf("template %s: duplicate variable %s", t.Name, v.Name))
+		}
+		names[v.Name] = true
+	}
+
+	return t.walk(func(name, text string) error {
+		if _, err := parse(name, name); err != nil {
+			return serr.New(fmt.Sprintf("template %s: file name %s: %v", t.Name, name, err))
+		}
+		if _, err := parse(name, text); err != nil {
+			return serr.New(fmt.Sprintf("template %s: %v", t.Name, err))
+		}
+		return nil
+	})
+}
+
+// Render fills in the variables' defaults, checks the required ones are set, and renders
+// every file's name and content. A file whose name renders empty is left out, so a
+// template can make files optional.
+func (t *Template) Render(values map[string]string) ([]File, error) {
+	vars, err := t.resolve(values)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []File
+	seen := make(map[string]bool)
+	err = t.walk(func(name, text string) error {
+		rendered, err := execute(name, name, vars)
+		if err != nil {
+			return serr.New(fmt.Sprintf("file name %s: %v", name, err))
+		}
+		rendered = strings.TrimSuffix(strings.TrimSpace(rendered), TemplateSuffix)
+		if rendered == "" || strings.HasSuffix(rendered, "/") {
+			return nil
+		}
+		clean := path.Clean(rendered)
+		if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") || strings.Contains(clean, "/../") {
+			return serr.New(fmt.Sprintf("file name %s renders as %s, outside the directory generated into", name, rendered))
+		}
+		if seen[clean] {
+			return serr.New(fmt.Sprintf("more than one file renders as %s", clean))
+		}
+		seen[clean] = true
+
+		content, err := execute(name, text, vars)
+		if err != nil {
+			return serr.New(fmt.Sprintf("%s: %v", name, err))
+		}
+		files = append(files, File{Path: clean, Content: content})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, serr.New("template " + t.Name + " renders no files with these variables")
+	}
+	return files, nil
+}
+
+// resolve returns the variables to render with: the values given, and the defaults of
+// those not given. Values for variables the template doesn't have are refused, as they
+// are most likely misspelled.
+func (t *Template) resolve(values map[string]string) (map[string]string, error) {
+	known := make(map[string]bool, len(t.Variables))
+	for _, v := range t.Variables {
+		known[v.Name] = true
+	}
+	var unknown []string
+	for name := range values {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, serr.New(fmt.Sprintf("template %s has no variable %s; its variables are %s",
+			t.Name, strings.Join(unknown, ", "), strings.Join(t.variableNames(), ", ")))
+	}
+
+	vars := make(map[string]string, len(t.Variables))
+	var missing []string
+	for _, v := range t.Variables {
+		value := strings.TrimSpace(values[v.Name])
+		if value == "" && v.Default != "" {
+			var err error
+			if value, err = execute(v.Name, v.Default, vars); err != nil {
+				return nil, serr.New(fmt.Sprintf("default of %s: %v", v.Name, err))
+			}
+		}
+		if value == "" && v.Required {
+			missing = append(missing, v.Name)
+		}
+		vars[v.Name] = value
+	}
+	if len(missing) > 0 {
+		return nil, serr.New(fmt.Sprintf("template %s needs %s", t.Name, strings.Join(missing, ", ")))
+	}
+	return vars, nil
+}
+
+// variableNames returns the names of the template's variables
+func (t *Template) variableNames() []string {
+	names := make([]string, 0, len(t.Variables))
+	for _, v := range t.Variables {
+		names = append(names, v.Name)
+	}
+	return names
+}
+
+// walk calls fn with the slash-separated name and content of each of the template's
+// files, the manifest aside, in name order
+func (t *Template) walk(fn func(name, text string) error) error {
+	return fs.WalkDir(t.files, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || name == ManifestFile {
+			return nil
+		}
+		data, err := fs.ReadFile(t.files, name)
+		if err != nil {
+			return serr.Wrap(err, "failed to read template file", "template", t.Name, "file", name)
+		}
+		return fn(name, string(data))
+	})
+}
+
+// parse parses text as a template that fails on variables that aren't set
+func parse(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(funcs).Option("missingkey=error").Parse(text)
+}
+
+// execute renders text with the variables
+func execute(name, text string, vars map[string]string) (string, error) {
+	tmpl, err := parse(name, text)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, vars); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// words splits a name into its words, at spaces, punctuation and changes of case:
+// "HTTPServer" and "http-server" are both "http", "server"
+func words(s string) []string {
+	var result []string
+	var current []rune
+	runes := []rune(s)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if len(current) > 0 {
+				result = append(result, string(current))
+				current = nil
+			}
+			continue
+		}
+		if unicode.IsUpper(r) && len(current) > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				result = append(result, string(current))
+				current = nil
+			}
+		}
+		current = append(current, unicode.ToLower(r))
+	}
+	if len(current) > 0 {
+		result = append(result, string(current))
+	}
+	return result
+}
+
+// joinWords returns the name's words joined by sep: snake_case or kebab-case
+func joinWords(s, sep string) string {
+	return strings.Join(words(s), sep)
+}
+
+// pascalCase returns the name as PascalCase
+func pascalCase(s string) string {
+	var sb strings.Builder
+	for _, word := range words(s) {
+		runes := []rune(word)
+		sb.WriteRune(unicode.ToUpper(runes[0]))
+		sb.WriteString(string(runes[1:]))
+	}
+	return sb.String()
+}
+
+// camelCase returns the name as camelCase
+func camelCase(s string) string {
+	pascal := []rune(pascalCase(s))
+	if len(pascal) == 0 {
+		return ""
+	}
+	pascal[0] = unicode.ToLower(pascal[0])
+	return string(pascal)
+}
@@ -0,0 +1,332 @@
+package terminal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/serr"
+)
+
+const (
+	scrollbackBytes = 64 * 1024   // Output replayed to a client that attaches late
+	maxRecordBytes  = 1024 * 1024 // Output kept in a terminal's recording
+	subscriberCap   = 256         // Output chunks buffered per client
+	defaultCols     = 80
+	defaultRows     = 24
+)
+
+// Recording is the audit record of a terminal, produced when it exits
+type Recording struct {
+	TerminalID string
+	SessionID  string
+	Shell      string
+	Dir        string
+	StartedAt  time.Time
+	Duration   time.Duration
+	Output     string // Output with escape sequences removed
+	Truncated  bool   // Output exceeded maxRecordBytes and its start was dropped
+	InputBytes int
+	ExitError  error
+}
+
+// Recorder stores terminal recordings
+type Recorder func(rec Recording)
+
+// Terminal is an interactive shell running in a pseudo-terminal
+type Terminal struct {
+	ID        string    `json:"id"`
+	SessionID string    `json:"session_id"`
+	Shell     string    `json:"shell"`
+	Dir       string    `json:"dir"`
+	StartedAt time.Time `json:"started_at"`
+
+	pty  *os.File
+	cmd  *exec.Cmd
+	done chan struct{}
+
+	mu          sync.Mutex
+	subscribers map[chan []byte]bool
+	scrollback  []byte
+	record      []byte
+	truncated   bool
+	inputBytes  int
+	exited      bool
+}
+
+// Manager owns the running terminals
+type Manager struct {
+	recorder Recorder
+
+	mu        sync.Mutex
+	terminals map[string]*Terminal
+}
+
+// NewManager creates a terminal manager that hands each finished terminal's recording to recorder
+func NewManager(recorder Recorder) *Manager {
+	return &Manager{
+		recorder:  recorder,
+		terminals: make(map[string]*Terminal),
+	}
+}
+
+// Start runs the user's shell in dir, in a terminal of the given size
+func (m *Manager) Start(sessionID, dir string, cols, rows int) (*Terminal, error) {
+	if cols <= 0 || rows <= 0 {
+		cols, rows = defaultCols, defaultRows
+	}
+
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+
+	cmd := exec.Command(shell)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "TERM=xterm-256color")
+
+	pty, err := startInPTY(cmd, cols, rows)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to start terminal")
+	}
+
+	t := &Terminal{
+		ID:          uuid.New().String(),
+		SessionID:   sessionID,
+		Shell:       shell,
+		Dir:         dir,
+		StartedAt:   time.Now(),
+		pty:         pty,
+		cmd:         cmd,
+		done:        make(chan struct{}),
+		subscribers: make(map[chan []byte]bool),
+	}
+
+	m.mu.Lock()
+	m.terminals[t.ID] = t
+	m.mu.Unlock()
+
+	go m.run(t)
+
+	logger.Info("Started terminal", "terminal_id", t.ID, "session_id", sessionID, "shell", shell)
+	return t, nil
+}
+
+// run copies the terminal's output to its clients until the shell exits
+func (m *Manager) run(t *Terminal) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := t.pty.Read(buf)
+		if n > 0 {
+			t.broadcast(buf[:n])
+		}
+		if err != nil {
+			break // EIO once the shell and its children have exited
+		}
+	}
+
+	exitErr := t.cmd.Wait()
+	t.pty.Close()
+
+	m.mu.Lock()
+	delete(m.terminals, t.ID)
+	m.mu.Unlock()
+
+	t.mu.Lock()
+	t.exited = true
+	for ch := range t.subscribers {
+		close(ch)
+	}
+	t.subscribers = nil
+	rec := Recording{
+		TerminalID: t.ID,
+		SessionID:  t.SessionID,
+		Shell:      t.Shell,
+		Dir:        t.Dir,
+		StartedAt:  t.StartedAt,
+		Duration:   time.Since(t.StartedAt),
+		Output:     stripEscapes(string(t.record)),
+		Truncated:  t.truncated,
+		InputBytes: t.inputBytes,
+		ExitError:  exitErr,
+	}
+	t.mu.Unlock()
+
+	logger.Info("Terminal exited", "terminal_id", t.ID, "duration", rec.Duration.Round(time.Second).String())
+	if m.recorder != nil {
+		m.recorder(rec)
+	}
+	close(t.done)
+}
+
+// broadcast sends output to every client and appends it to the scrollback and recording
+func (t *Terminal) broadcast(p []byte) {
+	chunk := append([]byte(nil), p...)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.scrollback = appendCapped(t.scrollback, chunk, scrollbackBytes)
+	if len(t.record)+len(chunk) > maxRecordBytes {
+		t.truncated = true
+	}
+	t.record = appendCapped(t.record, chunk, maxRecordBytes)
+
+	for ch := range t.subscribers {
+		select {
+		case ch <- chunk:
+		default:
+			// A client that falls this far behind misses output rather than stalling the shell
+		}
+	}
+}
+
+// appendCapped appends p to buf, dropping the oldest bytes beyond limit
+func appendCapped(buf, p []byte, limit int) []byte {
+	buf = append(buf, p...)
+	if len(buf) > limit {
+		buf = append([]byte(nil), buf[len(buf)-limit:]...)
+	}
+	return buf
+}
+
+// Subscribe returns the recent output and a channel of further output, closed when the
+// shell exits. Call cancel when the client goes away.
+func (t *Terminal) Subscribe() (scrollback []byte, output <-chan []byte, cancel func()) {
+	ch := make(chan []byte, subscriberCap)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	scrollback = append([]byte(nil), t.scrollback...)
+	if t.exited {
+		close(ch)
+		return scrollback, ch, func() {}
+	}
+	t.subscribers[ch] = true
+
+	return scrollback, ch, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if t.subscribers[ch] {
+			delete(t.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Write sends input to the shell
+func (t *Terminal) Write(p []byte) error {
+	t.mu.Lock()
+	t.inputBytes += len(p)
+	t.mu.Unlock()
+
+	if _, err := t.pty.Write(p); err != nil {
+		return serr.Wrap(err, "failed to write to terminal")
+	}
+	return nil
+}
+
+// Resize changes the terminal's size
+func (t *Terminal) Resize(cols, rows int) error {
+	if cols <= 0 || rows <= 0 {
+		return serr.New("cols and rows must be positive")
+	}
+	if err := setSize(t.pty, cols, rows); err != nil {
+		return serr.Wrap(err, "failed to resize terminal")
+	}
+	return nil
+}
+
+// Done is closed when the shell has exited
+func (t *Terminal) Done() <-chan struct{} {
+	return t.done
+}
+
+// Get returns a running terminal
+func (m *Manager) Get(id string) (*Terminal, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.terminals[id]
+	if !ok {
+		return nil, serr.New("terminal not found")
+	}
+	return t, nil
+}
+
+// List returns the session's running terminals, oldest first
+func (m *Manager) List(sessionID string) []*Terminal {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	terminals := []*Terminal{}
+	for _, t := range m.terminals {
+		if t.SessionID == sessionID {
+			terminals = append(terminals, t)
+		}
+	}
+	sort.Slice(terminals, func(i, j int) bool {
+		return terminals[i].StartedAt.Before(terminals[j].StartedAt)
+	})
+	return terminals
+}
+
+// Close hangs up a terminal, ending its shell, and waits for its recording to be stored
+func (m *Manager) Close(id string) error {
+	t, err := m.Get(id)
+	if err != nil {
+		return err
+	}
+
+	// Hang up the shell, as closing a terminal window would
+	if err := t.cmd.Process.Signal(syscall.SIGHUP); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		logger.LogErr(err, "failed to hang up terminal", "terminal_id", id)
+	}
+
+	select {
+	case <-t.done:
+	case <-time.After(3 * time.Second):
+		if err := t.cmd.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+			return serr.Wrap(err, fmt.Sprintf("failed to kill terminal %s", id))
+		}
+		t.pty.Close() // Background jobs may still hold the terminal open
+		<-t.done
+	}
+	return nil
+}
+
+// CloseAll ends every terminal, for server shutdown
+func (m *Manager) CloseAll() {
+	m.mu.Lock()
+	ids := make([]string, 0, len(m.terminals))
+	for id := range m.terminals {
+		ids = append(ids, id)
+	}
+	m.mu.Unlock()
+
+	for _, id := range ids {
+		if err := m.Close(id); err != nil {
+			logger.LogErr(err, "failed to close terminal", "terminal_id", id)
+		}
+	}
+}
+
+// Control sequences: CSI (colors, cursor movement), OSC (titles) and two-byte escapes
+var escapePattern = regexp.MustCompile(`\x1b\[[0-9;?<=>!]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[@-Z\\-_]`)
+
+// stripEscapes removes terminal control sequences so a recording reads as plain text
+func stripEscapes(s string) string {
+	s = escapePattern.ReplaceAllString(s, "")
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.ReplaceAll(s, "\r", "")
+}
@@ -0,0 +1,45 @@
+package terminal
+
+import (
+	"bytes"
+	"os"
+	"syscall"
+	"unsafe"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// ioctl requests from <sys/ttycom.h>
+const (
+	tiocPtyGrant = 0x20007454 // TIOCPTYGRANT
+	tiocPtyUnlk  = 0x20007452 // TIOCPTYUNLK
+	tiocPtyGname = 0x40807453 // TIOCPTYGNAME
+)
+
+// openPTY opens a new pseudo-terminal pair and returns its controlling side and the path of its terminal device
+func openPTY() (*os.File, string, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, "", serr.Wrap(err, "failed to open /dev/ptmx")
+	}
+
+	if err := ioctl(master.Fd(), tiocPtyGrant, 0); err != nil {
+		master.Close()
+		return nil, "", serr.Wrap(err, "failed to grant pty")
+	}
+	if err := ioctl(master.Fd(), tiocPtyUnlk, 0); err != nil {
+		master.Close()
+		return nil, "", serr.Wrap(err, "failed to unlock pty")
+	}
+
+	name := make([]byte, 128)
+	if err := ioctl(master.Fd(), tiocPtyGname, uintptr(unsafe.Pointer(&name[0]))); err != nil {
+		master.Close()
+		return nil, "", serr.Wrap(err, "failed to get pty name")
+	}
+	if i := bytes.IndexByte(name, 0); i >= 0 {
+		name = name[:i]
+	}
+
+	return master, string(name), nil
+}
@@ -0,0 +1,32 @@
+package terminal
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// openPTY opens a new pseudo-terminal pair and returns its controlling side and the path of its terminal device
+func openPTY() (*os.File, string, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, "", serr.Wrap(err, "failed to open /dev/ptmx")
+	}
+
+	var n uint32
+	if err := ioctl(master.Fd(), syscall.TIOCGPTN, uintptr(unsafe.Pointer(&n))); err != nil {
+		master.Close()
+		return nil, "", serr.Wrap(err, "failed to get pty number")
+	}
+
+	var unlock int32
+	if err := ioctl(master.Fd(), syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); err != nil {
+		master.Close()
+		return nil, "", serr.Wrap(err, "failed to unlock pty")
+	}
+
+	return master, fmt.Sprintf("/dev/pts/%d", n), nil
+}
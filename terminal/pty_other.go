@@ -0,0 +1,20 @@
+//go:build !linux && !darwin
+
+package terminal
+
+import (
+	"os"
+	"os/exec"
+
+	"github.com/rohanthewiz/serr"
+)
+
+func setSize(pty *os.File, cols, rows int) error {
+	return errUnsupported
+}
+
+func startInPTY(cmd *exec.Cmd, cols, rows int) (*os.File, error) {
+	return nil, errUnsupported
+}
+
+var errUnsupported = serr.New("terminals are only supported on Linux and macOS")
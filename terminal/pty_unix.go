@@ -0,0 +1,61 @@
+//go:build linux || darwin
+
+package terminal
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+// winsize mirrors struct winsize from <sys/ioctl.h>
+type winsize struct {
+	Rows uint16
+	Cols uint16
+	X    uint16
+	Y    uint16
+}
+
+func ioctl(fd, request, arg uintptr) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, arg); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// setSize sets the window size of the terminal, which the shell reads on SIGWINCH
+func setSize(pty *os.File, cols, rows int) error {
+	ws := winsize{Rows: uint16(rows), Cols: uint16(cols)}
+	return ioctl(pty.Fd(), syscall.TIOCSWINSZ, uintptr(unsafe.Pointer(&ws)))
+}
+
+// startInPTY runs cmd as a session leader with the pty's terminal as its controlling terminal
+func startInPTY(cmd *exec.Cmd, cols, rows int) (*os.File, error) {
+	master, ttyPath, err := openPTY()
+	if err != nil {
+		return nil, err
+	}
+	if err := setSize(master, cols, rows); err != nil {
+		master.Close()
+		return nil, err
+	}
+
+	tty, err := os.OpenFile(ttyPath, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, err
+	}
+	defer tty.Close() // The child holds its own copies
+
+	cmd.Stdin = tty
+	cmd.Stdout = tty
+	cmd.Stderr = tty
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+
+	if err := cmd.Start(); err != nil {
+		master.Close()
+		return nil, err
+	}
+	return master, nil
+}
@@ -0,0 +1,470 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// hunkHeader matches "@@ -start[,count] +start[,count] @@"
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// ApplyPatchTool applies a unified diff that may span several files, all or nothing
+type ApplyPatchTool struct{}
+
+// GetDefinition returns the tool definition for the AI
+func (t *ApplyPatchTool) GetDefinition() Tool {
+	return Tool{
+		Name: "apply_patch",
+		Description: "Apply a unified diff (as from `git diff` or `diff -u`) that may change several files in one call. " +
+			"Use /dev/null as the old file to create a file and as the new file to delete one. " +
+			"Every hunk is checked against the current file contents first; if any hunk does not match, no file is changed.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"patch": map[string]interface{}{
+					"type":        "string",
+					"description": "The unified diff, with ---/+++ file headers and @@ hunks for each file",
+				},
+				"dry_run": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Only check that the patch applies, without changing any file (default: false)",
+				},
+			},
+			"required": []string{"patch"},
+		},
+	}
+}
+
+// filePatch holds the hunks for one file of a patch
+type filePatch struct {
+	oldPath string // "" when the patch creates the file
+	newPath string // "" when the patch deletes the file
+	hunks   []patchHunk
+}
+
+// path returns the file the patch changes
+func (fp *filePatch) path() string {
+	if fp.newPath != "" {
+		return fp.newPath
+	}
+	return fp.oldPath
+}
+
+// patchHunk is one @@ section of a file patch
+type patchHunk struct {
+	oldStart int
+	oldLines []string // Context and removed lines
+	newLines []string // Context and added lines
+	added    int
+	removed  int
+	oldNoEOL bool // The old side ends without a newline
+	newNoEOL bool // The new side ends without a newline
+}
+
+// patchedFile is the outcome of applying a file patch in memory
+type patchedFile struct {
+	patch    *filePatch
+	oldPath  string // Resolved paths
+	newPath  string
+	content  string
+	existed  bool
+	mode     os.FileMode
+	original []byte
+	added    int
+	removed  int
+}
+
+// Execute validates every hunk, then writes all files, restoring them if any write fails
+func (t *ApplyPatchTool) Execute(input map[string]interface{}) (string, error) {
+	patch, ok := GetString(input, "patch")
+	if !ok || strings.TrimSpace(patch) == "" {
+		return "", serr.New("patch is required")
+	}
+	dryRun, _ := input["dry_run"].(bool)
+
+	patches, err := parsePatch(patch)
+	if err != nil {
+		return "", serr.Wrap(err, "invalid patch")
+	}
+
+	// Work out every file's new content before touching the disk
+	files := make([]*patchedFile, 0, len(patches))
+	seen := make(map[string]bool)
+	for _, fp := range patches {
+		file, err := preparePatchedFile(fp)
+		if err != nil {
+			return "", serr.New("patch not applied, no files were changed: " + err.Error())
+		}
+		paths := []string{file.oldPath}
+		if file.newPath != file.oldPath {
+			paths = append(paths, file.newPath)
+		}
+		for _, path := range paths {
+			if path == "" {
+				continue
+			}
+			if seen[path] {
+				return "", serr.New("patch not applied: " + path + " appears more than once")
+			}
+			seen[path] = true
+		}
+		files = append(files, file)
+	}
+
+	if !dryRun {
+		if err := writePatchedFiles(files); err != nil {
+			return "", err
+		}
+	}
+
+	return patchSummary(files, dryRun), nil
+}
+
+// PatchPaths returns the files a unified diff changes, or nil if it cannot be parsed
+func PatchPaths(patch string) []string {
+	patches, err := parsePatch(patch)
+	if err != nil {
+		return nil
+	}
+	paths := make([]string, 0, len(patches))
+	for _, fp := range patches {
+		paths = append(paths, fp.path())
+	}
+	return paths
+}
+
+// parsePatch splits a unified diff into per-file patches. Hunk line counts are not
+// trusted, since hand-written patches often get them wrong; a hunk runs until the next header.
+func parsePatch(patch string) ([]*filePatch, error) {
+	lines := strings.Split(strings.ReplaceAll(patch, "\r\n", "\n"), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	var patches []*filePatch
+	var current *filePatch
+	var hunk *patchHunk
+	var lastOp byte
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		// A file header is a --- line followed by a +++ line
+		if strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ") {
+			current = &filePatch{
+				oldPath: patchHeaderPath(line[4:]),
+				newPath: patchHeaderPath(lines[i+1][4:]),
+			}
+			stripGitPrefixes(current)
+			if current.oldPath == "" && current.newPath == "" {
+				return nil, serr.New(fmt.Sprintf("line %d: file header names no file", i+1))
+			}
+			patches = append(patches, current)
+			hunk = nil
+			i++
+			continue
+		}
+
+		if m := hunkHeader.FindStringSubmatch(line); m != nil {
+			if current == nil {
+				return nil, serr.New(fmt.Sprintf("line %d: hunk before any ---/+++ file header", i+1))
+			}
+			start, _ := strconv.Atoi(m[1])
+			current.hunks = append(current.hunks, patchHunk{oldStart: start})
+			hunk = &current.hunks[len(current.hunks)-1]
+			lastOp = 0
+			continue
+		}
+
+		if hunk == nil {
+			continue // git metadata such as "diff --git" and "index" lines
+		}
+
+		switch {
+		case strings.HasPrefix(line, `\`): // "\ No newline at end of file"
+			switch lastOp {
+			case '-':
+				hunk.oldNoEOL = true
+			case '+':
+				hunk.newNoEOL = true
+			case ' ':
+				hunk.oldNoEOL, hunk.newNoEOL = true, true
+			}
+		case line == "" || line[0] == ' ':
+			// Blank context lines often lose their leading space
+			text := ""
+			if line != "" {
+				text = line[1:]
+			}
+			hunk.oldLines = append(hunk.oldLines, text)
+			hunk.newLines = append(hunk.newLines, text)
+			lastOp = ' '
+		case line[0] == '-':
+			hunk.oldLines = append(hunk.oldLines, line[1:])
+			hunk.removed++
+			lastOp = '-'
+		case line[0] == '+':
+			hunk.newLines = append(hunk.newLines, line[1:])
+			hunk.added++
+			lastOp = '+'
+		default:
+			hunk = nil // Trailing text after the last hunk
+		}
+	}
+
+	if len(patches) == 0 {
+		return nil, serr.New("no ---/+++ file headers found")
+	}
+	for _, fp := range patches {
+		if len(fp.hunks) == 0 {
+			return nil, serr.New("no hunks for " + fp.path())
+		}
+	}
+	return patches, nil
+}
+
+// patchHeaderPath extracts the path from a ---/+++ header, dropping any timestamp.
+// /dev/null becomes "".
+func patchHeaderPath(header string) string {
+	path := header
+	if i := strings.Index(path, "\t"); i >= 0 {
+		path = path[:i]
+	}
+	path = strings.TrimSpace(path)
+	if path == "/dev/null" {
+		return ""
+	}
+	return path
+}
+
+// stripGitPrefixes removes the a/ and b/ prefixes of git diffs, when every path named has one
+func stripGitPrefixes(fp *filePatch) {
+	if (fp.oldPath != "" && !strings.HasPrefix(fp.oldPath, "a/")) || (fp.newPath != "" && !strings.HasPrefix(fp.newPath, "b/")) {
+		return
+	}
+	fp.oldPath = strings.TrimPrefix(fp.oldPath, "a/")
+	fp.newPath = strings.TrimPrefix(fp.newPath, "b/")
+}
+
+// preparePatchedFile applies a file patch to the current file contents in memory
+func preparePatchedFile(fp *filePatch) (*patchedFile, error) {
+	file := &patchedFile{patch: fp, mode: 0644}
+
+	var err error
+	if fp.oldPath != "" {
+		if file.oldPath, err = ExpandPath(fp.oldPath); err != nil {
+			return nil, serr.Wrap(err, "failed to expand path")
+		}
+	}
+	if fp.newPath != "" {
+		if file.newPath, err = ExpandPath(fp.newPath); err != nil {
+			return nil, serr.Wrap(err, "failed to expand path")
+		}
+	}
+
+	var original string
+	if file.oldPath != "" {
+		info, err := os.Stat(file.oldPath)
+		if err != nil {
+			return nil, serr.New(fmt.Sprintf("%s: %v", fp.oldPath, err))
+		}
+		data, err := os.ReadFile(file.oldPath)
+		if err != nil {
+			return nil, serr.New(fmt.Sprintf("%s: %v", fp.oldPath, err))
+		}
+		file.existed, file.mode, file.original, original = true, info.Mode().Perm(), data, string(data)
+	}
+	// A file the patch creates or moves to mustn't exist, or it would be overwritten and, were
+	// the patch rolled back, removed
+	if file.newPath != "" && file.newPath != file.oldPath {
+		if _, err := os.Stat(file.newPath); err == nil {
+			if file.oldPath == "" {
+				return nil, serr.New(fp.newPath + " already exists; the patch creates it")
+			}
+			return nil, serr.New(fmt.Sprintf("%s already exists; the patch moves %s there", fp.newPath, fp.oldPath))
+		}
+	}
+
+	lines := strings.Split(original, "\n")
+	endsWithNewline := strings.HasSuffix(original, "\n")
+	if original == "" || endsWithNewline {
+		lines = lines[:len(lines)-1]
+	}
+
+	var out []string
+	pos := 0
+	for i, hunk := range fp.hunks {
+		at, err := findHunk(lines, pos, hunk)
+		if err != nil {
+			return nil, serr.New(fmt.Sprintf("hunk %d of %s: %s", i+1, fp.path(), err.Error()))
+		}
+		out = append(out, lines[pos:at]...)
+		out = append(out, hunk.newLines...)
+		pos = at + len(hunk.oldLines)
+
+		file.added += hunk.added
+		file.removed += hunk.removed
+		if pos == len(lines) {
+			endsWithNewline = !hunk.newNoEOL
+		}
+	}
+	out = append(out, lines[pos:]...)
+
+	if file.newPath == "" {
+		if len(out) > 0 {
+			return nil, serr.New(fp.oldPath + " is deleted by the patch but would not be empty")
+		}
+		return file, nil
+	}
+
+	file.content = strings.Join(out, "\n")
+	if len(out) > 0 && endsWithNewline {
+		file.content += "\n"
+	}
+	return file, nil
+}
+
+// findHunk returns where the hunk's old lines start in lines, looking first at the line
+// the header gives and then ever further away, but never before pos. Lines that differ
+// only in trailing whitespace are accepted when there is no exact match.
+func findHunk(lines []string, pos int, hunk patchHunk) (int, error) {
+	expected := hunk.oldStart - 1
+	if len(hunk.oldLines) == 0 {
+		expected = hunk.oldStart // Pure insertions name the line they follow
+	}
+	if expected < pos {
+		expected = pos
+	}
+	last := len(lines) - len(hunk.oldLines)
+	if expected > last {
+		expected = last
+	}
+
+	for _, trim := range []bool{false, true} {
+		for offset := 0; expected-offset >= pos || expected+offset <= last; offset++ {
+			for _, at := range []int{expected - offset, expected + offset} {
+				if at >= pos && at <= last && hunkMatches(lines[at:], hunk.oldLines, trim) {
+					return at, nil
+				}
+			}
+		}
+	}
+
+	if len(hunk.oldLines) == 0 || expected < pos {
+		return 0, serr.New("does not fit the current file")
+	}
+	for i, want := range hunk.oldLines {
+		if expected+i >= len(lines) {
+			return 0, serr.New(fmt.Sprintf("expects line %d %q but the file has only %d lines", expected+i+1, want, len(lines)))
+		}
+		if got := lines[expected+i]; got != want {
+			return 0, serr.New(fmt.Sprintf("does not match the current file; line %d is %q, the patch expects %q", expected+i+1, got, want))
+		}
+	}
+	return 0, serr.New("does not match the current file")
+}
+
+// hunkMatches reports whether lines starts with want
+func hunkMatches(lines, want []string, trim bool) bool {
+	if len(lines) < len(want) {
+		return false
+	}
+	for i := range want {
+		got, exp := lines[i], want[i]
+		if trim {
+			got, exp = strings.TrimRight(got, " \t"), strings.TrimRight(exp, " \t")
+		}
+		if got != exp {
+			return false
+		}
+	}
+	return true
+}
+
+// writePatchedFiles writes every file, undoing the earlier writes if one fails
+func writePatchedFiles(files []*patchedFile) error {
+	var done []*patchedFile
+	for _, file := range files {
+		if err := writePatchedFile(file); err != nil {
+			for i := len(done) - 1; i >= 0; i-- {
+				restorePatchedFile(done[i])
+			}
+			restorePatchedFile(file)
+			return WrapFileSystemError(serr.New("patch not applied, changes were rolled back: " + err.Error()))
+		}
+		done = append(done, file)
+	}
+
+	for _, file := range files {
+		switch {
+		case file.newPath == "":
+			NotifyFileChange(file.oldPath, "deleted")
+		case !file.existed:
+			NotifyFileChange(file.newPath, "created")
+		default:
+			NotifyFileChange(file.newPath, "modified")
+		}
+	}
+	return nil
+}
+
+// writePatchedFile writes, moves or deletes one file
+func writePatchedFile(file *patchedFile) error {
+	if file.newPath == "" {
+		return os.Remove(file.oldPath)
+	}
+	if err := os.MkdirAll(filepath.Dir(file.newPath), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(file.newPath, []byte(file.content), file.mode); err != nil {
+		return err
+	}
+	if file.oldPath != "" && file.oldPath != file.newPath {
+		return os.Remove(file.oldPath)
+	}
+	return nil
+}
+
+// restorePatchedFile puts a file back the way it was before the patch
+func restorePatchedFile(file *patchedFile) {
+	if file.newPath != "" && file.newPath != file.oldPath {
+		os.Remove(file.newPath)
+	}
+	if file.existed {
+		os.WriteFile(file.oldPath, file.original, file.mode)
+	}
+}
+
+// patchSummary lists the files changed, with line counts
+func patchSummary(files []*patchedFile, dryRun bool) string {
+	added, removed := 0, 0
+	var sb strings.Builder
+	for _, file := range files {
+		added += file.added
+		removed += file.removed
+
+		fp := file.patch
+		switch {
+		case fp.newPath == "":
+			sb.WriteString(fmt.Sprintf("  D %s (-%d)\n", fp.oldPath, file.removed))
+		case fp.oldPath == "":
+			sb.WriteString(fmt.Sprintf("  A %s (+%d)\n", fp.newPath, file.added))
+		case fp.oldPath != fp.newPath:
+			sb.WriteString(fmt.Sprintf("  R %s -> %s (+%d -%d)\n", fp.oldPath, fp.newPath, file.added, file.removed))
+		default:
+			sb.WriteString(fmt.Sprintf("  M %s (+%d -%d)\n", fp.newPath, file.added, file.removed))
+		}
+	}
+
+	verb := "Applied patch to"
+	if dryRun {
+		verb = "Patch applies cleanly to"
+	}
+	return fmt.Sprintf("%s %d file(s) (+%d -%d)\n%s", verb, len(files), added, removed, strings.TrimRight(sb.String(), "\n"))
+}
@@ -0,0 +1,214 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		patch   string
+		want    []filePatch
+		wantErr string
+	}{
+		{
+			name: "git diff with prefixes and metadata",
+			patch: `diff --git a/main.go b/main.go
+index 83db48f..bf269f4 100644
+--- a/main.go
++++ b/main.go
+@@ -1,3 +1,3 @@
+ package main
+-var x = 1
++var x = 2
+
+`,
+			want: []filePatch{{oldPath: "main.go", newPath: "main.go", hunks: []patchHunk{{
+				oldStart: 1,
+				oldLines: []string{"package main", "var x = 1", ""},
+				newLines: []string{"package main", "var x = 2", ""},
+				added:    1, removed: 1,
+			}}}},
+		},
+		{
+			name: "new file from /dev/null, with a timestamp",
+			patch: `--- /dev/null
++++ notes.txt	2024-01-02 10:00:00
+@@ -0,0 +1,2 @@
++one
++two
+`,
+			want: []filePatch{{newPath: "notes.txt", hunks: []patchHunk{{
+				newLines: []string{"one", "two"},
+				added:    2,
+			}}}},
+		},
+		{
+			name: "deleted file keeps paths without prefixes as given",
+			patch: `--- old/a.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-gone
+`,
+			want: []filePatch{{oldPath: "old/a.txt", hunks: []patchHunk{{
+				oldStart: 1,
+				oldLines: []string{"gone"},
+				removed:  1,
+			}}}},
+		},
+		{
+			name: "no newline at end of file",
+			patch: `--- a.txt
++++ a.txt
+@@ -1 +1 @@
+-old
+\ No newline at end of file
++new
+`,
+			want: []filePatch{{oldPath: "a.txt", newPath: "a.txt", hunks: []patchHunk{{
+				oldStart: 1,
+				oldLines: []string{"old"},
+				newLines: []string{"new"},
+				added:    1, removed: 1,
+				oldNoEOL: true,
+			}}}},
+		},
+		{
+			name: "two hunks and two files",
+			patch: `--- a.txt
++++ a.txt
+@@ -1,1 +1,1 @@
+-a
++A
+@@ -10,1 +10,1 @@
+-b
++B
+--- c.txt
++++ c.txt
+@@ -5 +5 @@
+-c
++C
+`,
+			want: []filePatch{
+				{oldPath: "a.txt", newPath: "a.txt", hunks: []patchHunk{
+					{oldStart: 1, oldLines: []string{"a"}, newLines: []string{"A"}, added: 1, removed: 1},
+					{oldStart: 10, oldLines: []string{"b"}, newLines: []string{"B"}, added: 1, removed: 1},
+				}},
+				{oldPath: "c.txt", newPath: "c.txt", hunks: []patchHunk{
+					{oldStart: 5, oldLines: []string{"c"}, newLines: []string{"C"}, added: 1, removed: 1},
+				}},
+			},
+		},
+		{name: "no headers", patch: "just text\n", wantErr: "no ---/+++ file headers"},
+		{name: "hunk before header", patch: "@@ -1 +1 @@\n-a\n+b\n", wantErr: "hunk before any"},
+		{name: "header without hunks", patch: "--- a.txt\n+++ a.txt\n", wantErr: "no hunks for a.txt"},
+		{name: "header naming no file", patch: "--- /dev/null\n+++ /dev/null\n", wantErr: "names no file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePatch(tt.patch)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsePatch: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d file patches, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if !reflect.DeepEqual(*got[i], tt.want[i]) {
+					t.Errorf("file patch %d:\ngot  %+v\nwant %+v", i, *got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFindHunk(t *testing.T) {
+	lines := []string{"a", "b", "c  ", "d", "b", "c", "e"}
+	tests := []struct {
+		name    string
+		pos     int
+		hunk    patchHunk
+		want    int
+		wantErr string
+	}{
+		{name: "at the line the header gives", hunk: patchHunk{oldStart: 5, oldLines: []string{"b", "c"}}, want: 4},
+		{name: "drifted from the header", hunk: patchHunk{oldStart: 1, oldLines: []string{"d", "b"}}, want: 3},
+		{name: "exact match preferred to a whitespace one", hunk: patchHunk{oldStart: 2, oldLines: []string{"b", "c"}}, want: 4},
+		{name: "trailing whitespace tolerated", hunk: patchHunk{oldStart: 2, oldLines: []string{"c", "d"}}, want: 2},
+		{name: "never before pos", pos: 5, hunk: patchHunk{oldStart: 2, oldLines: []string{"b"}}, wantErr: `line 6 is "c", the patch expects "b"`},
+		{name: "pure insertion follows the line named", hunk: patchHunk{oldStart: 3}, want: 3},
+		{name: "mismatch names the line", hunk: patchHunk{oldStart: 1, oldLines: []string{"a", "x"}}, wantErr: `line 2 is "b", the patch expects "x"`},
+		{name: "past the end", hunk: patchHunk{oldStart: 7, oldLines: []string{"e", "f"}}, wantErr: "does not match the current file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findHunk(lines, tt.pos, tt.hunk)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("findHunk: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("findHunk = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyPatchRenameOntoExistingFile(t *testing.T) {
+	dir := t.TempDir()
+	oldPath, newPath := filepath.Join(dir, "old.txt"), filepath.Join(dir, "new.txt")
+	if err := os.WriteFile(oldPath, []byte("one\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(newPath, []byte("keep me\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	patch := "--- " + oldPath + "\n+++ " + newPath + "\n@@ -1 +1 @@\n-one\n+two\n"
+	_, err := (&ApplyPatchTool{}).Execute(map[string]interface{}{"patch": patch})
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("error = %v, want the rename refused", err)
+	}
+
+	for path, want := range map[string]string{oldPath: "one\n", newPath: "keep me\n"} {
+		if got, _ := os.ReadFile(path); string(got) != want {
+			t.Errorf("%s = %q, want %q", filepath.Base(path), got, want)
+		}
+	}
+}
+
+func TestApplyPatchRenameAndEdit(t *testing.T) {
+	dir := t.TempDir()
+	oldPath, newPath := filepath.Join(dir, "old.txt"), filepath.Join(dir, "sub", "new.txt")
+	if err := os.WriteFile(oldPath, []byte("one\ntwo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	patch := "--- " + oldPath + "\n+++ " + newPath + "\n@@ -1,2 +1,2 @@\n one\n-two\n+three\n"
+	if _, err := (&ApplyPatchTool{}).Execute(map[string]interface{}{"patch": patch}); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if _, err := os.Stat(oldPath); !os.IsNotExist(err) {
+		t.Errorf("old.txt still exists after the rename")
+	}
+	if got, _ := os.ReadFile(newPath); string(got) != "one\nthree\n" {
+		t.Errorf("new.txt = %q", got)
+	}
+}
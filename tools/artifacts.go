@@ -0,0 +1,289 @@
+package tools
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"rcode/config"
+
+	"github.com/rohanthewiz/serr"
+)
+
+const defaultArtifactLines = 400 // Lines read_artifact returns when no limit is given
+
+// Artifact kinds publish_artifact accepts; tool outputs are kept by the chat itself
+var publishedArtifactKinds = []string{"report", "docs", "build", "other"}
+
+// Artifact is a file kept for a session, with the session and plan that published it
+type Artifact struct {
+	SessionID   string
+	PlanID      string
+	Name        string
+	Kind        string
+	Description string
+	ContentType string
+	Content     []byte
+}
+
+// ArtifactStore keeps the artifacts the artifact tools read and publish.
+// It is implemented by the web layer, which owns the database.
+type ArtifactStore interface {
+	// ReadArtifact returns the content of the session's artifact, or an error if it has none with the ID
+	ReadArtifact(sessionID string, id int) (string, error)
+	// PublishArtifact stores an artifact and returns its ID
+	PublishArtifact(artifact Artifact) (int, error)
+}
+
+// Store the artifact tools use
+var artifactStore ArtifactStore
+
+// SetArtifactStore sets the store the artifact tools use
+func SetArtifactStore(store ArtifactStore) {
+	artifactStore = store
+}
+
+// ReadArtifactTool reads an artifact, such as the full output of a tool call that was truncated for the model
+type ReadArtifactTool struct{}
+
+// GetDefinition returns the tool definition
+func (t *ReadArtifactTool) GetDefinition() Tool {
+	return Tool{
+		Name: "read_artifact",
+		Description: "Read an artifact of this session, such as the full output of a tool call whose result was truncated. " +
+			"Returns numbered lines; use offset and limit to page through it, or pattern to find the lines you need.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"id": map[string]interface{}{
+					"type":        "integer",
+					"description": "The artifact's ID, as given in the truncated result",
+				},
+				"offset": map[string]interface{}{
+					"type":        "integer",
+					"description": "Line number to start at (default: 1)",
+				},
+				"limit": map[string]interface{}{
+					"type":        "integer",
+					"description": fmt.Sprintf("Number of lines to return (default: %d)", defaultArtifactLines),
+				},
+				"pattern": map[string]interface{}{
+					"type":        "string",
+					"description": "Regular expression; only lines matching it are returned",
+				},
+			},
+			"required": []string{"id"},
+		},
+	}
+}
+
+// Execute returns a range of the artifact's lines, or those matching the pattern
+func (t *ReadArtifactTool) Execute(input map[string]interface{}) (string, error) {
+	if artifactStore == nil {
+		return "", NewPermanentError(serr.New("artifacts are not available"), "no store")
+	}
+
+	id, ok := GetInt(input, "id")
+	if !ok {
+		return "", serr.New("id parameter is required")
+	}
+	sessionID, _ := GetString(input, "_sessionId")
+
+	content, err := artifactStore.ReadArtifact(sessionID, id)
+	if err != nil {
+		return "", NewPermanentError(err, "artifact not found")
+	}
+
+	offset, _ := GetInt(input, "offset")
+	if offset < 1 {
+		offset = 1
+	}
+	limit, _ := GetInt(input, "limit")
+	if limit < 1 {
+		limit = defaultArtifactLines
+	}
+
+	if pattern, _ := GetString(input, "pattern"); pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return "", NewPermanentError(serr.Wrap(err, "invalid pattern"), "invalid pattern")
+		}
+		return grepArtifact(content, re, offset, limit), nil
+	}
+
+	return readLineRange(strings.NewReader(content), offset, limit)
+}
+
+// grepArtifact returns up to limit lines from offset on that match re
+func grepArtifact(content string, re *regexp.Regexp, offset, limit int) string {
+	var sb strings.Builder
+	matches, last := 0, 0
+	more := false
+
+	_ = scanLines(strings.NewReader(content), func(n int, line string) {
+		if n < offset || more || !re.MatchString(line) {
+			return
+		}
+		if matches == limit || sb.Len()+len(line) > maxReadLength {
+			more = true
+			return
+		}
+		if sb.Len() > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(fmt.Sprintf("%d\t%s", n, line))
+		matches++
+		last = n
+	})
+
+	if matches == 0 {
+		return fmt.Sprintf("[No lines from line %d match %q]", offset, re.String())
+	}
+	if more {
+		sb.WriteString(fmt.Sprintf("\n\n[%d matching lines shown; continue with offset=%d]", matches, last+1))
+	}
+	return sb.String()
+}
+
+// PublishArtifactTool keeps a file, such as a coverage report or build output, as an
+// artifact of the session that the user can download
+type PublishArtifactTool struct{}
+
+// GetDefinition returns the tool definition
+func (t *PublishArtifactTool) GetDefinition() Tool {
+	return Tool{
+		Name: "publish_artifact",
+		Description: "Publish a generated file, such as a coverage report, generated docs or a build output, as an artifact " +
+			"the user can download from this session. Give the path of a file, or the content to publish.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"path": map[string]interface{}{
+					"type":        "string",
+					"description": "The file to publish",
+				},
+				"content": map[string]interface{}{
+					"type":        "string",
+					"description": "Text to publish instead of a file",
+				},
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The artifact's file name (default: the file's name; required with content)",
+				},
+				"kind": map[string]interface{}{
+					"type":        "string",
+					"description": "What the artifact is (default: other)",
+					"enum":        publishedArtifactKinds,
+				},
+				"description": map[string]interface{}{
+					"type":        "string",
+					"description": "What the artifact holds, shown with it",
+				},
+			},
+		},
+	}
+}
+
+// Execute reads the file or content and stores it as an artifact of the session
+func (t *PublishArtifactTool) Execute(input map[string]interface{}) (string, error) {
+	if artifactStore == nil {
+		return "", NewPermanentError(serr.New("artifacts are not available"), "no store")
+	}
+	sessionID, _ := GetString(input, "_sessionId")
+	if sessionID == "" {
+		return "", NewPermanentError(serr.New("artifacts can only be published in a session"), "no session")
+	}
+
+	artifact := Artifact{SessionID: sessionID, Kind: "other"}
+	artifact.PlanID, _ = GetString(input, "_planId")
+	artifact.Name, _ = GetString(input, "name")
+	artifact.Description, _ = GetString(input, "description")
+	if kind, _ := GetString(input, "kind"); kind != "" {
+		if !slices.Contains(publishedArtifactKinds, kind) {
+			return "", NewPermanentError(serr.New(fmt.Sprintf("kind must be one of %s", strings.Join(publishedArtifactKinds, ", "))), "invalid kind")
+		}
+		artifact.Kind = kind
+	}
+
+	maxSize := config.Get().MaxArtifactSize
+	path, _ := GetString(input, "path")
+	content, hasContent := GetString(input, "content")
+	switch {
+	case path != "" && hasContent:
+		return "", NewPermanentError(serr.New("give either path or content, not both"), "invalid input")
+	case path != "":
+		expandedPath, err := ExpandPath(path)
+		if err != nil {
+			return "", serr.Wrap(err, "failed to expand path")
+		}
+		info, err := os.Stat(expandedPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return "", NewPermanentError(serr.New(fmt.Sprintf("File not found: %s", path)), "file not found")
+			}
+			return "", WrapFileSystemError(serr.Wrap(err, fmt.Sprintf("Failed to read file: %s", path)))
+		}
+		if info.IsDir() {
+			return "", NewPermanentError(serr.New(fmt.Sprintf("%s is a directory; archive it with bash first", path)), "is a directory")
+		}
+		if info.Size() > maxSize {
+			return "", NewPermanentError(serr.New(fmt.Sprintf("%s is %d bytes, more than the %d bytes an artifact may hold", path, info.Size(), maxSize)), "too large")
+		}
+		if artifact.Content, err = os.ReadFile(expandedPath); err != nil {
+			return "", WrapFileSystemError(serr.Wrap(err, fmt.Sprintf("Failed to read file: %s", path)))
+		}
+		if artifact.Name == "" {
+			artifact.Name = filepath.Base(expandedPath)
+		}
+	case hasContent:
+		if artifact.Name == "" {
+			return "", NewPermanentError(serr.New("name is required when publishing content"), "invalid input")
+		}
+		if int64(len(content)) > maxSize {
+			return "", NewPermanentError(serr.New(fmt.Sprintf("content is %d bytes, more than the %d bytes an artifact may hold", len(content), maxSize)), "too large")
+		}
+		artifact.Content = []byte(content)
+	default:
+		return "", NewPermanentError(serr.New("path or content is required"), "invalid input")
+	}
+
+	artifact.ContentType = mime.TypeByExtension(filepath.Ext(artifact.Name))
+	if artifact.ContentType == "" {
+		artifact.ContentType = http.DetectContentType(artifact.Content)
+	}
+
+	id, err := artifactStore.PublishArtifact(artifact)
+	if err != nil {
+		return "", serr.Wrap(err, "failed to publish artifact")
+	}
+	return fmt.Sprintf("Published %s as artifact %d (%d bytes, %s)", artifact.Name, id, len(artifact.Content), artifact.ContentType), nil
+}
+
+// TruncateOutput keeps the start and end of an output longer than limit bytes, cutting at
+// line breaks, with note in place of what was left out. The note's size is not counted.
+func TruncateOutput(output string, limit int, note func(lines, bytes int) string) string {
+	if len(output) <= limit {
+		return output
+	}
+
+	// The start usually says what happened and the end how it finished
+	headSize := limit * 2 / 3
+	head := output[:headSize]
+	if i := strings.LastIndexByte(head, '\n'); i > 0 {
+		head = head[:i+1]
+	}
+	tail := output[len(output)-(limit-headSize):]
+	if i := strings.IndexByte(tail, '\n'); i >= 0 && i < len(tail)-1 {
+		tail = tail[i+1:]
+	}
+
+	omitted := output[len(head) : len(output)-len(tail)]
+	// A cut that found no line break may split a character
+	head, tail = strings.ToValidUTF8(head, ""), strings.ToValidUTF8(tail, "")
+	return head + "\n" + note(strings.Count(omitted, "\n"), len(omitted)) + "\n\n" + tail
+}
@@ -0,0 +1,279 @@
+package tools
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"rcode/background"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// backgroundProcesses supervises the processes started by run_background. They outlive
+// the tool call and the turn, and are stopped by stop_process or at server shutdown.
+var backgroundProcesses = background.NewSupervisor()
+
+// BackgroundProcesses returns the supervisor of the model's background processes
+func BackgroundProcesses() *background.Supervisor {
+	return backgroundProcesses
+}
+
+// RunBackgroundTool starts a long-running command, such as a dev server, in the background
+type RunBackgroundTool struct{}
+
+// GetDefinition returns the tool definition for starting a background process
+func (t *RunBackgroundTool) GetDefinition() Tool {
+	return Tool{
+		Name:        "run_background",
+		Description: "Start a long-running command in the background, such as a dev server, file watcher or database, and keep it running across turns. Returns the process id and its first output. Use list_processes to read its recent logs and stop_process to end it. Use bash for commands that finish.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"command": map[string]interface{}{
+					"type":        "string",
+					"description": "The bash command to run, e.g. 'npm run dev'",
+				},
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "Short name for the process (defaults to the command's first word)",
+				},
+				"wait": map[string]interface{}{
+					"type":        "integer",
+					"description": "Seconds to wait before returning the first output, to catch startup errors (default: 3, max: 30)",
+				},
+			},
+			"required": []string{"command"},
+		},
+	}
+}
+
+// Execute starts the process and reports how it started
+func (t *RunBackgroundTool) Execute(input map[string]interface{}) (string, error) {
+	command, ok := GetString(input, "command")
+	if !ok || strings.TrimSpace(command) == "" {
+		return "", NewPermanentError(serr.New("command parameter is required"), "invalid parameters")
+	}
+	name, _ := GetString(input, "name")
+	sessionID, _ := GetString(input, "_sessionId")
+
+	wait := 3
+	if w, ok := GetInt(input, "wait"); ok {
+		wait = min(max(w, 0), 30)
+	}
+
+	proc, err := backgroundProcesses.Start(sessionID, name, command, "")
+	if err != nil {
+		return "", NewPermanentError(err, "process not started")
+	}
+
+	exited := backgroundProcesses.Wait(proc.ID, time.Duration(wait)*time.Second)
+	if !exited && wait > 0 {
+		// Ports are checked every second, so give a server that just came up one more check
+		backgroundProcesses.WaitForPort(proc.ID, time.Second)
+	}
+	if proc, err = backgroundProcesses.Get(proc.ID); err != nil {
+		return "", err
+	}
+	lines, _, _ := backgroundProcesses.Logs(proc.ID, 50)
+
+	var sb strings.Builder
+	if exited {
+		sb.WriteString(fmt.Sprintf("Process %d (%s) exited with code %d right after starting.\n", proc.ID, proc.Name, proc.ExitCode))
+		sb.WriteString("For commands that finish, use bash instead.\n")
+	} else {
+		sb.WriteString(fmt.Sprintf("Started process %d (%s), pid %d, in %s\n", proc.ID, proc.Name, proc.PID, proc.Dir))
+		sb.WriteString(fmt.Sprintf("It keeps running; read its logs with list_processes (id %d) and end it with stop_process.\n", proc.ID))
+		if len(proc.Ports) > 0 {
+			sb.WriteString(describeProcessPorts(proc) + "\n")
+		}
+	}
+	writeProcessOutput(&sb, lines, fmt.Sprintf("Output after %ds", wait))
+
+	return sb.String(), nil
+}
+
+// ListProcessesTool lists the background processes, or shows one process's recent output
+type ListProcessesTool struct{}
+
+// GetDefinition returns the tool definition for listing background processes
+func (t *ListProcessesTool) GetDefinition() Tool {
+	return Tool{
+		Name:        "list_processes",
+		Description: "List the background processes started with run_background and their status. Give an id to read that process's recent output instead, e.g. to check a dev server's logs after a change.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"id": map[string]interface{}{
+					"type":        "integer",
+					"description": "Process id to show the recent output of",
+				},
+				"lines": map[string]interface{}{
+					"type":        "integer",
+					"description": "Number of output lines to show for a process (default: 50, max: 500)",
+				},
+				"filter": map[string]interface{}{
+					"type":        "string",
+					"description": "Only show output lines containing this text (case-insensitive)",
+				},
+			},
+		},
+	}
+}
+
+// Execute lists the processes or shows one process's output
+func (t *ListProcessesTool) Execute(input map[string]interface{}) (string, error) {
+	id, ok := GetInt(input, "id")
+	if !ok {
+		return listBackgroundProcesses(), nil
+	}
+
+	proc, err := backgroundProcesses.Get(id)
+	if err != nil {
+		return "", NewPermanentError(err, "unknown process")
+	}
+
+	n := 50
+	if l, ok := GetInt(input, "lines"); ok && l > 0 {
+		n = min(l, 500)
+	}
+	filter, _ := GetString(input, "filter")
+
+	// Filter over the whole buffer, so matches further back are still found
+	lines, total, err := backgroundProcesses.Logs(id, 0)
+	if err != nil {
+		return "", err
+	}
+	if filter != "" {
+		var matched []string
+		for _, line := range lines {
+			if strings.Contains(strings.ToLower(line), strings.ToLower(filter)) {
+				matched = append(matched, line)
+			}
+		}
+		lines = matched
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+
+	var sb strings.Builder
+	sb.WriteString(describeBackgroundProcess(proc) + "\n")
+	sb.WriteString(fmt.Sprintf("Command: %s\n", proc.Command))
+	heading := fmt.Sprintf("Last %d of %d lines", len(lines), total)
+	if filter != "" {
+		heading = fmt.Sprintf("Last %d lines matching %q", len(lines), filter)
+	}
+	writeProcessOutput(&sb, lines, heading)
+
+	return sb.String(), nil
+}
+
+// listBackgroundProcesses describes every process, one per line
+func listBackgroundProcesses() string {
+	procs := backgroundProcesses.List()
+	if len(procs) == 0 {
+		return "No background processes. Start one with run_background."
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%d background processes:\n", len(procs)))
+	for _, proc := range procs {
+		sb.WriteString(describeBackgroundProcess(proc) + "\n")
+		sb.WriteString(fmt.Sprintf("    %s\n", proc.Command))
+	}
+	return sb.String()
+}
+
+// describeBackgroundProcess summarizes a process's identity and state on one line
+func describeBackgroundProcess(proc background.Process) string {
+	desc := fmt.Sprintf("[%d] %s - %s", proc.ID, proc.Name, proc.Status)
+	switch proc.Status {
+	case background.StatusRunning:
+		desc += fmt.Sprintf(" for %s (pid %d)", time.Since(proc.StartedAt).Round(time.Second), proc.PID)
+		if len(proc.Ports) > 0 {
+			desc += "; " + describeProcessPorts(proc)
+		}
+	default:
+		desc += fmt.Sprintf(" with code %d", proc.ExitCode)
+		if proc.ExitedAt != nil {
+			desc += fmt.Sprintf(", %s ago", time.Since(*proc.ExitedAt).Round(time.Second))
+		}
+	}
+	return desc
+}
+
+// describeProcessPorts names the ports a process listens on and where the user can preview it
+func describeProcessPorts(proc background.Process) string {
+	ports := make([]string, len(proc.Ports))
+	for i, port := range proc.Ports {
+		ports[i] = strconv.Itoa(port)
+	}
+	label := "port"
+	if len(ports) > 1 {
+		label = "ports"
+	}
+	return fmt.Sprintf("listening on %s %s, previewed in rcode at %s", label, strings.Join(ports, ", "), background.PreviewPath(proc.ID))
+}
+
+// writeProcessOutput writes output lines under a heading
+func writeProcessOutput(sb *strings.Builder, lines []string, heading string) {
+	if len(lines) == 0 {
+		sb.WriteString("\n(no output yet)\n")
+		return
+	}
+	sb.WriteString(fmt.Sprintf("\n%s:\n", heading))
+	for _, line := range lines {
+		sb.WriteString(line + "\n")
+	}
+}
+
+// StopProcessTool ends a background process
+type StopProcessTool struct{}
+
+// GetDefinition returns the tool definition for stopping a background process
+func (t *StopProcessTool) GetDefinition() Tool {
+	return Tool{
+		Name:        "stop_process",
+		Description: "Stop a background process started with run_background, along with any processes it started. It is asked to exit first and killed if it is still running after 5 seconds.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"id": map[string]interface{}{
+					"type":        "integer",
+					"description": "Process id from run_background or list_processes",
+				},
+			},
+			"required": []string{"id"},
+		},
+	}
+}
+
+// Execute stops the process
+func (t *StopProcessTool) Execute(input map[string]interface{}) (string, error) {
+	id, ok := GetInt(input, "id")
+	if !ok {
+		return "", NewPermanentError(serr.New("id parameter is required"), "invalid parameters")
+	}
+
+	before, err := backgroundProcesses.Get(id)
+	if err != nil {
+		return "", NewPermanentError(err, "unknown process")
+	}
+	if before.Status != background.StatusRunning {
+		return fmt.Sprintf("Process %d (%s) is not running: %s", id, before.Name, describeBackgroundProcess(before)), nil
+	}
+
+	proc, err := backgroundProcesses.Stop(id)
+	if err != nil {
+		return "", err
+	}
+
+	lines, _, _ := backgroundProcesses.Logs(id, 10)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Stopped process %d (%s) after %s.\n", proc.ID, proc.Name, proc.ExitedAt.Sub(proc.StartedAt).Round(time.Second)))
+	writeProcessOutput(&sb, lines, "Last output")
+	return sb.String(), nil
+}
@@ -37,6 +37,11 @@ func (t *BashTool) GetDefinition() Tool {
 
 // Execute runs the bash command and returns the output
 func (t *BashTool) Execute(input map[string]interface{}) (string, error) {
+	return t.ExecuteContext(context.Background(), input)
+}
+
+// ExecuteContext runs the bash command, killing it and any child processes if ctx is cancelled
+func (t *BashTool) ExecuteContext(parent context.Context, input map[string]interface{}) (string, error) {
 	command, ok := GetString(input, "command")
 	if !ok || command == "" {
 		return "", serr.New("command is required")
@@ -49,15 +54,21 @@ func (t *BashTool) Execute(input map[string]interface{}) (string, error) {
 	}
 
 	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Millisecond)
+	ctx, cancel := context.WithTimeout(parent, time.Duration(timeout)*time.Millisecond)
 	defer cancel()
 
 	// Create command
 	cmd := exec.CommandContext(ctx, "bash", "-c", command)
+	configureProcessGroup(cmd)
 
 	// Run command and capture output
 	output, err := cmd.CombinedOutput()
 
+	// Handle cancellation by the caller
+	if parent.Err() == context.Canceled {
+		return string(output), serr.New("Command cancelled")
+	}
+
 	// Handle timeout
 	if ctx.Err() == context.DeadlineExceeded {
 		// Timeout errors could be retryable if the system is just slow
@@ -74,8 +85,9 @@ func (t *BashTool) Execute(input map[string]interface{}) (string, error) {
 		}
 	}
 
-	// Truncate if too long
-	const maxLength = 30000
+	// Truncate if too long. The chat keeps longer outputs whole as an artifact and
+	// sends the model part of them, so this only guards against runaway output.
+	const maxLength = 1 << 20
 	if len(result) > maxLength {
 		result = result[:maxLength] + "\n\n[Output truncated...]"
 	}
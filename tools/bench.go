@@ -0,0 +1,326 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// Bounds on a benchmark run
+const (
+	benchTimeout      = 20 * time.Minute
+	defaultBenchCount = 5 // Four runs of each side are the fewest that can show a change
+	maxBenchCount     = 20
+)
+
+// BenchRun is a run of the bench tool, kept for the session to compare later runs with
+type BenchRun struct {
+	ID        int
+	Label     string
+	Packages  string
+	Bench     string // The -bench pattern
+	Command   string // Run instead of go test, when set
+	Output    string // The results, with the goos, goarch, pkg and cpu lines
+	CreatedAt time.Time
+}
+
+// BenchStore keeps each session's benchmark runs.
+// It is implemented by the web layer, which owns the database.
+type BenchStore interface {
+	// SaveBenchRun stores a run, setting its ID and time
+	SaveBenchRun(sessionID string, run *BenchRun) error
+	// ReadBenchRuns returns the session's runs, oldest first
+	ReadBenchRuns(sessionID string) ([]BenchRun, error)
+}
+
+// Store the bench tool keeps its runs in
+var benchStore BenchStore
+
+// SetBenchStore sets the store the bench tool keeps its runs in
+func SetBenchStore(store BenchStore) {
+	benchStore = store
+}
+
+// BenchTool runs a project's benchmarks and compares them with an earlier run of the session
+type BenchTool struct{}
+
+// GetDefinition returns the tool definition
+func (t *BenchTool) GetDefinition() Tool {
+	return Tool{
+		Name: "bench",
+		Description: "Run Go benchmarks (go test -bench, with -benchmem) several times and compare them, benchstat style, " +
+			"with an earlier run of this session: the median of each metric, its variation, the change, and whether " +
+			"it is significant (p < 0.05) or noise (~). Runs are kept for the session, so run once before a change " +
+			"as the baseline and again after it. Without a baseline, a run is compared with the last one of the same " +
+			"packages and pattern.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"packages": map[string]interface{}{
+					"type":        "string",
+					"description": "The packages to benchmark, as go test takes them (default: ./...)",
+				},
+				"bench": map[string]interface{}{
+					"type":        "string",
+					"description": "Regular expression selecting the benchmarks (default: . for all)",
+				},
+				"count": map[string]interface{}{
+					"type":        "integer",
+					"description": fmt.Sprintf("Runs of each benchmark (default: %d, at most %d); fewer than 4 can't show a significant change", defaultBenchCount, maxBenchCount),
+				},
+				"benchtime": map[string]interface{}{
+					"type":        "string",
+					"description": "Time or iterations for each run, as -benchtime takes them: 2s, 1000x",
+				},
+				"command": map[string]interface{}{
+					"type":        "string",
+					"description": "A command run instead of go test, whose output is in Go's benchmark format, for benchmarks go test can't run directly",
+				},
+				"label": map[string]interface{}{
+					"type":        "string",
+					"description": "A name for this run to compare later runs with, e.g. baseline",
+				},
+				"baseline": map[string]interface{}{
+					"type":        "string",
+					"description": "The run to compare with: its label or number, or none",
+				},
+				"max_regression": map[string]interface{}{
+					"type":        "number",
+					"description": "Fail when a benchmark gets significantly worse than the baseline by more than this percentage",
+				},
+			},
+		},
+	}
+}
+
+// Execute runs the benchmarks
+func (t *BenchTool) Execute(input map[string]interface{}) (string, error) {
+	return t.ExecuteContext(context.Background(), input)
+}
+
+// ExecuteContext runs the benchmarks, stopping them if ctx is cancelled
+func (t *BenchTool) ExecuteContext(ctx context.Context, input map[string]interface{}) (string, error) {
+	run := &BenchRun{}
+	run.Packages, _ = GetString(input, "packages")
+	run.Bench, _ = GetString(input, "bench")
+	run.Command, _ = GetString(input, "command")
+	run.Label, _ = GetString(input, "label")
+	run.Packages, run.Bench = strings.TrimSpace(run.Packages), strings.TrimSpace(run.Bench)
+	run.Command, run.Label = strings.TrimSpace(run.Command), strings.TrimSpace(run.Label)
+	if run.Packages == "" && run.Command == "" {
+		run.Packages = "./..."
+	}
+	if run.Bench == "" && run.Command == "" {
+		run.Bench = "."
+	}
+	baseline, _ := GetString(input, "baseline")
+	benchtime, _ := GetString(input, "benchtime")
+	count := boundedInt(input, "count", defaultBenchCount, maxBenchCount)
+
+	maxRegression := -1.0
+	if value, ok := input["max_regression"]; ok {
+		switch v := value.(type) {
+		case float64:
+			maxRegression = v
+		case int:
+			maxRegression = float64(v)
+		case string:
+			if f, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(v), "%"), 64); err == nil {
+				maxRegression = f
+			}
+		}
+	}
+
+	// Find the baseline first, so a wrong one is reported before the benchmarks run
+	sessionID, _ := GetString(input, "_sessionId")
+	var runs []BenchRun
+	if benchStore != nil && sessionID != "" {
+		var err error
+		if runs, err = benchStore.ReadBenchRuns(sessionID); err != nil {
+			return "", serr.Wrap(err, "failed to read the session's benchmark runs")
+		}
+	}
+	base, err := findBaseline(runs, run, baseline)
+	if err != nil {
+		return "", err
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", serr.Wrap(err, "failed to get working directory")
+	}
+	output, err := runBenchmarks(ctx, dir, run, count, strings.TrimSpace(benchtime))
+	if err != nil {
+		return "", err
+	}
+	results := parseBenchmarks(output)
+	if len(results.names) == 0 {
+		what := fmt.Sprintf("no benchmarks in %s matched %q", run.Packages, run.Bench)
+		if run.Command != "" {
+			what = "the command printed no benchmark results in Go's format"
+		}
+		return "", NewPermanentError(serr.New(what+":\n"+testFailure(output)), "no benchmarks")
+	}
+	run.Output = benchLines(output)
+
+	saved := false
+	if benchStore != nil && sessionID != "" {
+		if err := benchStore.SaveBenchRun(sessionID, run); err != nil {
+			return "", serr.Wrap(err, "failed to save the benchmark run")
+		}
+		saved = true
+	}
+
+	var sb strings.Builder
+	name := benchRunName(run, len(runs)+1)
+	fmt.Fprintf(&sb, "Benchmarks of %s", benchTarget(run))
+	if run.Command == "" {
+		fmt.Fprintf(&sb, " (%d runs each)", count)
+	}
+	if saved {
+		fmt.Fprintf(&sb, ", saved as %s", name)
+	}
+
+	if base == nil {
+		if saved {
+			sb.WriteString("; later runs compare with it")
+		}
+		sb.WriteString("\n\n" + benchTable(results))
+		return sb.String(), nil
+	}
+
+	baseName := benchRunName(base, baseNumber(runs, base))
+	comparison := compareBenchmarks(parseBenchmarks(base.Output), results)
+	fmt.Fprintf(&sb, ", compared with %s from %s\n", baseName, base.CreatedAt.Local().Format("15:04 Jan 2"))
+	if len(comparison.units) == 0 {
+		sb.WriteString("\nNone of the benchmarks are in both runs\n\n" + benchTable(results))
+		return sb.String(), nil
+	}
+	for _, unit := range comparison.units {
+		sb.WriteString(comparison.tally(unit) + "\n")
+	}
+	sb.WriteString("\n" + comparison.table(baseName, name))
+
+	if maxRegression >= 0 {
+		if found := comparison.regressions(maxRegression / 100); len(found) > 0 {
+			return "", NewPermanentError(serr.New(fmt.Sprintf("%d regressions over %.4g%%: %s\n\n%s",
+				len(found), maxRegression, strings.Join(found, ", "), sb.String())), "regression")
+		}
+	}
+	return sb.String(), nil
+}
+
+// findBaseline returns the run named by baseline, a label or number, or when it is empty
+// the session's last run of the same benchmarks. "none" compares with nothing.
+func findBaseline(runs []BenchRun, run *BenchRun, baseline string) (*BenchRun, error) {
+	baseline = strings.TrimSpace(baseline)
+	switch {
+	case strings.EqualFold(baseline, "none"):
+		return nil, nil
+	case baseline == "":
+		for i := len(runs) - 1; i >= 0; i-- {
+			if runs[i].Packages == run.Packages && runs[i].Bench == run.Bench && runs[i].Command == run.Command {
+				return &runs[i], nil
+			}
+		}
+		return nil, nil
+	}
+
+	for i := len(runs) - 1; i >= 0; i-- {
+		if runs[i].Label == baseline {
+			return &runs[i], nil
+		}
+	}
+	if n, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(baseline), "run ")); err == nil && n >= 1 && n <= len(runs) {
+		return &runs[n-1], nil
+	}
+
+	if len(runs) == 0 {
+		return nil, NewPermanentError(serr.New(fmt.Sprintf("there is no baseline %q: the session has no benchmark runs yet", baseline)), "unknown baseline")
+	}
+	names := make([]string, 0, len(runs))
+	for i := range runs {
+		names = append(names, fmt.Sprintf("%s of %s", benchRunName(&runs[i], i+1), benchTarget(&runs[i])))
+	}
+	return nil, NewPermanentError(serr.New(fmt.Sprintf("there is no baseline %q; the session's runs are: %s",
+		baseline, strings.Join(names, ", "))), "unknown baseline")
+}
+
+// runBenchmarks runs the benchmarks in the module holding dir, or run's command in dir,
+// returning their output
+func runBenchmarks(ctx context.Context, dir string, run *BenchRun, count int, benchtime string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, benchTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if run.Command != "" {
+		cmd = exec.CommandContext(ctx, "bash", "-c", run.Command)
+		cmd.Dir = dir
+	} else {
+		root, _, err := goModule(dir)
+		if err != nil {
+			return "", NewPermanentError(serr.Wrap(err, "use command to run benchmarks outside a Go module"), "not a Go module")
+		}
+		args := []string{"test", "-run=^$", "-bench=" + run.Bench, "-benchmem", "-count=" + strconv.Itoa(count)}
+		if benchtime != "" {
+			args = append(args, "-benchtime="+benchtime)
+		}
+		cmd = exec.CommandContext(ctx, "go", append(args, strings.Fields(run.Packages)...)...)
+		cmd.Dir = root
+	}
+	configureProcessGroup(cmd)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	if err := cmd.Run(); err != nil {
+		switch {
+		case ctx.Err() == context.DeadlineExceeded:
+			return "", NewPermanentError(serr.New(fmt.Sprintf("the benchmarks ran over %s and were stopped; "+
+				"select fewer with bench or lower count", benchTimeout)), "timeout")
+		case ctx.Err() != nil:
+			return "", serr.New("Benchmark run cancelled")
+		}
+		if _, isExit := err.(*exec.ExitError); !isExit {
+			return "", serr.Wrap(err, "failed to run the benchmarks")
+		}
+		return "", NewPermanentError(serr.New("the benchmarks failed:\n"+testFailure(output.String())), "benchmarks fail")
+	}
+	return output.String(), nil
+}
+
+// benchRunName names a run by its number in the session, and label when it has one
+func benchRunName(run *BenchRun, number int) string {
+	if run.Label != "" {
+		return fmt.Sprintf("run %d %q", number, run.Label)
+	}
+	return fmt.Sprintf("run %d", number)
+}
+
+// baseNumber is a stored run's number in the session
+func baseNumber(runs []BenchRun, base *BenchRun) int {
+	for i := range runs {
+		if &runs[i] == base {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// benchTarget describes what a run benchmarked
+func benchTarget(run *BenchRun) string {
+	if run.Command != "" {
+		return "`" + run.Command + "`"
+	}
+	if run.Bench != "." {
+		return fmt.Sprintf("%s matching %q", run.Packages, run.Bench)
+	}
+	return run.Packages
+}
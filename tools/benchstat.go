@@ -0,0 +1,503 @@
+package tools
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+	"unicode/utf8"
+)
+
+// benchAlpha is the p-value under which a difference between two runs is reported as a change
+const benchAlpha = 0.05
+
+// benchSet is the results of a benchmark run, as samples of each benchmark's metrics
+type benchSet struct {
+	names   []string                        // In the order they first appear
+	units   []string                        // In the order they first appear
+	samples map[string]map[string][]float64 // Benchmark: unit: a value per run
+}
+
+// parseBenchmarks reads output in Go's benchmark format: "BenchmarkName-8  1000  123 ns/op
+// 48 B/op  2 allocs/op", under "pkg:" lines that name the package. A benchmark is named
+// after its package when the output has more than one.
+func parseBenchmarks(output string) *benchSet {
+	type result struct {
+		pkg, name string
+		metrics   [][2]string // Value, unit
+	}
+	var results []result
+	packages := make(map[string]bool)
+
+	pkg := ""
+	for _, line := range strings.Split(output, "\n") {
+		if value, ok := strings.CutPrefix(line, "pkg:"); ok {
+			pkg = strings.TrimSpace(value)
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 4 || len(fields)%2 != 0 || !isBenchmarkName(fields[0]) {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[1]); err != nil {
+			continue // The iterations
+		}
+		r := result{pkg: pkg, name: strings.TrimPrefix(fields[0], "Benchmark")}
+		for i := 2; i < len(fields); i += 2 {
+			r.metrics = append(r.metrics, [2]string{fields[i], fields[i+1]})
+		}
+		results = append(results, r)
+		packages[pkg] = true
+	}
+
+	set := &benchSet{samples: make(map[string]map[string][]float64)}
+	for _, r := range results {
+		name := r.name
+		if len(packages) > 1 && r.pkg != "" {
+			name = path.Base(r.pkg) + "." + name
+		}
+		if set.samples[name] == nil {
+			set.samples[name] = make(map[string][]float64)
+			set.names = append(set.names, name)
+		}
+		for _, metric := range r.metrics {
+			value, err := strconv.ParseFloat(metric[0], 64)
+			if err != nil {
+				continue
+			}
+			unit := metric[1]
+			if !containsString(set.units, unit) {
+				set.units = append(set.units, unit)
+			}
+			set.samples[name][unit] = append(set.samples[name][unit], value)
+		}
+	}
+	return set
+}
+
+// isBenchmarkName reports whether a field names a benchmark: "Benchmark" alone or followed by
+// anything but a lower-case letter, as go test requires
+func isBenchmarkName(field string) bool {
+	rest, ok := strings.CutPrefix(field, "Benchmark")
+	if !ok {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(rest)
+	return rest == "" || !unicode.IsLower(r)
+}
+
+// benchLines keeps the lines of benchmark output worth storing: the results and the lines
+// describing the machine and package they ran on
+func benchLines(output string) string {
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch {
+		case isBenchmarkName(fields[0]) && len(fields) >= 4,
+			strings.HasPrefix(line, "goos:"), strings.HasPrefix(line, "goarch:"),
+			strings.HasPrefix(line, "pkg:"), strings.HasPrefix(line, "cpu:"):
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// benchStats summarizes a benchmark metric's samples
+type benchStats struct {
+	center float64 // The median, outliers left out
+	spread float64 // The largest difference from the center, as a fraction of it
+	n      int
+}
+
+// summarizeSamples returns the median of the samples and their spread around it, leaving
+// out the outliers beyond 1.5 interquartile ranges, as benchstat does
+func summarizeSamples(values []float64) benchStats {
+	if len(values) == 0 {
+		return benchStats{}
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	q1, q3 := quantile(sorted, 0.25), quantile(sorted, 0.75)
+	low, high := q1-1.5*(q3-q1), q3+1.5*(q3-q1)
+	kept := sorted[:0:0]
+	for _, v := range sorted {
+		if v >= low && v <= high {
+			kept = append(kept, v)
+		}
+	}
+
+	stats := benchStats{center: quantile(kept, 0.5), n: len(values)}
+	if stats.center != 0 {
+		for _, v := range kept {
+			stats.spread = math.Max(stats.spread, math.Abs(v-stats.center)/math.Abs(stats.center))
+		}
+	}
+	return stats
+}
+
+// quantile interpolates the q quantile of sorted values
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	pos := q * float64(len(sorted)-1)
+	i := int(pos)
+	if i+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[i] + (pos-float64(i))*(sorted[i+1]-sorted[i])
+}
+
+// mannWhitneyP is the two-sided p-value of the Mann-Whitney U test that x and y come from
+// the same distribution. It is exact for samples without ties, and uses the normal
+// approximation with a tie correction otherwise.
+func mannWhitneyP(x, y []float64) float64 {
+	n1, n2 := len(x), len(y)
+	if n1 == 0 || n2 == 0 {
+		return 1
+	}
+
+	type sample struct {
+		value float64
+		fromX bool
+	}
+	all := make([]sample, 0, n1+n2)
+	for _, v := range x {
+		all = append(all, sample{v, true})
+	}
+	for _, v := range y {
+		all = append(all, sample{v, false})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].value < all[j].value })
+
+	// Rank the samples, giving tied ones the mean of their ranks
+	rankSumX, tieTerm, ties := 0.0, 0.0, false
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j].value == all[i].value {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if all[k].fromX {
+				rankSumX += rank
+			}
+		}
+		if t := float64(j - i); t > 1 {
+			ties = true
+			tieTerm += t*t*t - t
+		}
+		i = j
+	}
+	u := rankSumX - float64(n1*(n1+1))/2
+
+	if !ties && n1 <= 50 && n2 <= 50 {
+		return exactMannWhitneyP(n1, n2, int(u))
+	}
+
+	n := float64(n1 + n2)
+	mean := float64(n1*n2) / 2
+	variance := float64(n1*n2) / 12 * ((n + 1) - tieTerm/(n*(n-1)))
+	if variance <= 0 {
+		return 1
+	}
+	z := (math.Abs(u-mean) - 0.5) / math.Sqrt(variance)
+	if z < 0 {
+		return 1
+	}
+	return math.Min(1, math.Erfc(z/math.Sqrt2))
+}
+
+// exactMannWhitneyP is the two-sided p-value of U from the distribution of U for samples of
+// n1 and n2 values without ties, counted over the orderings of the samples
+func exactMannWhitneyP(n1, n2, u int) float64 {
+	// counts[j][k] is the number of orderings of i values of x and j of y giving U = k,
+	// built up a value of x at a time
+	maxU := n1 * n2
+	counts := make([][]float64, n2+1)
+	for j := range counts {
+		counts[j] = make([]float64, maxU+1)
+		counts[j][0] = 1
+	}
+	for i := 1; i <= n1; i++ {
+		next := make([][]float64, n2+1)
+		for j := range next {
+			next[j] = make([]float64, maxU+1)
+			for k := 0; k <= maxU; k++ {
+				// The largest value is from x, beating all j values of y, or from y
+				if k >= j {
+					next[j][k] += counts[j][k-j]
+				}
+				if j > 0 {
+					next[j][k] += next[j-1][k]
+				}
+			}
+		}
+		counts = next
+	}
+
+	dist := counts[n2]
+	total, below, above := 0.0, 0.0, 0.0
+	for k, c := range dist {
+		total += c
+		if k <= u {
+			below += c
+		}
+		if k >= u {
+			above += c
+		}
+	}
+	return math.Min(1, 2*math.Min(below, above)/total)
+}
+
+// benchDelta compares a benchmark metric across two runs
+type benchDelta struct {
+	name     string
+	old, new benchStats
+	change   float64 // (new - old) / old
+	p        float64
+}
+
+// significant reports whether the change is more than noise
+func (d benchDelta) significant() bool {
+	return d.p < benchAlpha && d.change != 0
+}
+
+// benchComparison compares a run's metrics, unit by unit, with a baseline's
+type benchComparison struct {
+	units   []string
+	deltas  map[string][]benchDelta // Unit: benchmarks in both runs
+	geomean map[string][2]float64   // Unit: old, new
+	onlyOld []string                // Benchmarks in the baseline alone
+	onlyNew []string                // Benchmarks in the new run alone
+}
+
+// compareBenchmarks compares the benchmarks in both runs, in the new run's order
+func compareBenchmarks(old, new *benchSet) *benchComparison {
+	c := &benchComparison{deltas: make(map[string][]benchDelta), geomean: make(map[string][2]float64)}
+	for _, name := range old.names {
+		if new.samples[name] == nil {
+			c.onlyOld = append(c.onlyOld, name)
+		}
+	}
+
+	for _, unit := range new.units {
+		logOld, logNew, n := 0.0, 0.0, 0
+		for _, name := range new.names {
+			if old.samples[name] == nil {
+				continue
+			}
+			oldValues, newValues := old.samples[name][unit], new.samples[name][unit]
+			if len(oldValues) == 0 || len(newValues) == 0 {
+				continue
+			}
+			d := benchDelta{name: name, old: summarizeSamples(oldValues), new: summarizeSamples(newValues),
+				p: mannWhitneyP(oldValues, newValues)}
+			switch {
+			case d.old.center != 0:
+				d.change = (d.new.center - d.old.center) / d.old.center
+			case d.new.center != 0:
+				d.change = math.Inf(int(math.Copysign(1, d.new.center))) // From nothing, as allocations can be
+			}
+			c.deltas[unit] = append(c.deltas[unit], d)
+			if d.old.center > 0 && d.new.center > 0 {
+				logOld += math.Log(d.old.center)
+				logNew += math.Log(d.new.center)
+				n++
+			}
+		}
+		if len(c.deltas[unit]) == 0 {
+			continue
+		}
+		c.units = append(c.units, unit)
+		if n > 1 {
+			c.geomean[unit] = [2]float64{math.Exp(logOld / float64(n)), math.Exp(logNew / float64(n))}
+		}
+	}
+
+	for _, name := range new.names {
+		if old.samples[name] == nil {
+			c.onlyNew = append(c.onlyNew, name)
+		}
+	}
+	return c
+}
+
+// higherIsBetter reports whether a larger value of the unit is an improvement, as for MB/s
+func higherIsBetter(unit string) bool {
+	return strings.HasSuffix(unit, "/s")
+}
+
+// worse reports whether a change of the unit is a regression
+func worse(unit string, change float64) bool {
+	if higherIsBetter(unit) {
+		return change < 0
+	}
+	return change > 0
+}
+
+// regressions lists the significant changes for the worse by more than limit, a fraction
+func (c *benchComparison) regressions(limit float64) []string {
+	var found []string
+	for _, unit := range c.units {
+		for _, d := range c.deltas[unit] {
+			if d.significant() && worse(unit, d.change) && math.Abs(d.change) > limit {
+				found = append(found, fmt.Sprintf("%s %s %+.2f%%", d.name, benchMetricName(unit), 100*d.change))
+			}
+		}
+	}
+	return found
+}
+
+// tally sums up a unit's changes: better, worse and unchanged benchmarks
+func (c *benchComparison) tally(unit string) string {
+	better, worseCount, same := 0, 0, 0
+	for _, d := range c.deltas[unit] {
+		switch {
+		case !d.significant():
+			same++
+		case worse(unit, d.change):
+			worseCount++
+		default:
+			better++
+		}
+	}
+	words := [2]string{"better", "worse"}
+	if unit == "ns/op" {
+		words = [2]string{"faster", "slower"}
+	}
+	s := fmt.Sprintf("%s: %d %s, %d %s, %d unchanged", benchMetricName(unit), better, words[0], worseCount, words[1], same)
+	if g, ok := c.geomean[unit]; ok && g[0] != 0 {
+		s += fmt.Sprintf("; geomean %+.2f%%", 100*(g[1]-g[0])/g[0])
+	}
+	return s
+}
+
+// table lays out the comparison like benchstat, a section per unit
+func (c *benchComparison) table(oldLabel, newLabel string) string {
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 4, 2, ' ', 0)
+	for i, unit := range c.units {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\tdelta\n", benchMetricName(unit), oldLabel, newLabel)
+		for _, d := range c.deltas[unit] {
+			delta := "~"
+			if d.significant() {
+				delta = fmt.Sprintf("%+.2f%%", 100*d.change)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s (p=%.3f n=%d+%d)\n", d.name, formatBenchStats(unit, d.old),
+				formatBenchStats(unit, d.new), delta, d.p, d.old.n, d.new.n)
+		}
+		if g, ok := c.geomean[unit]; ok && g[0] != 0 {
+			fmt.Fprintf(w, "[Geo mean]\t%s\t%s\t%+.2f%%\n", formatBenchValue(unit, g[0]), formatBenchValue(unit, g[1]),
+				100*(g[1]-g[0])/g[0])
+		}
+	}
+	w.Flush()
+
+	if len(c.onlyNew) > 0 {
+		fmt.Fprintf(&sb, "\nOnly in %s: %s\n", newLabel, strings.Join(c.onlyNew, ", "))
+	}
+	if len(c.onlyOld) > 0 {
+		fmt.Fprintf(&sb, "\nOnly in %s: %s\n", oldLabel, strings.Join(c.onlyOld, ", "))
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// benchTable lays out a run's results alone, a section per unit
+func benchTable(set *benchSet) string {
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 4, 2, ' ', 0)
+	for i, unit := range set.units {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s\n", benchMetricName(unit))
+		for _, name := range set.names {
+			if values := set.samples[name][unit]; len(values) > 0 {
+				fmt.Fprintf(w, "%s\t%s (n=%d)\n", name, formatBenchStats(unit, summarizeSamples(values)), len(values))
+			}
+		}
+	}
+	w.Flush()
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// benchMetricName is what benchstat calls a unit's metric
+func benchMetricName(unit string) string {
+	switch unit {
+	case "ns/op":
+		return "time/op"
+	case "B/op":
+		return "alloc/op"
+	case "MB/s":
+		return "speed"
+	}
+	return unit
+}
+
+// formatBenchStats shows a metric's center and spread: "1.25µs ± 3%"
+func formatBenchStats(unit string, s benchStats) string {
+	return fmt.Sprintf("%s ± %.0f%%", formatBenchValue(unit, s.center), 100*s.spread)
+}
+
+// formatBenchValue shows a value of the unit to three significant digits, scaled
+func formatBenchValue(unit string, v float64) string {
+	switch unit {
+	case "ns/op":
+		switch a := math.Abs(v); {
+		case a >= 1e9:
+			return threeDigits(v/1e9) + "s"
+		case a >= 1e6:
+			return threeDigits(v/1e6) + "ms"
+		case a >= 1e3:
+			return threeDigits(v/1e3) + "µs"
+		}
+		return threeDigits(v) + "ns"
+	case "B/op":
+		switch a := math.Abs(v); {
+		case a >= 1<<30:
+			return threeDigits(v/(1<<30)) + "GiB"
+		case a >= 1<<20:
+			return threeDigits(v/(1<<20)) + "MiB"
+		case a >= 1<<10:
+			return threeDigits(v/(1<<10)) + "KiB"
+		}
+		return threeDigits(v) + "B"
+	case "MB/s":
+		return threeDigits(v) + "MB/s"
+	}
+	switch a := math.Abs(v); {
+	case a >= 1e9:
+		return threeDigits(v/1e9) + "G"
+	case a >= 1e6:
+		return threeDigits(v/1e6) + "M"
+	case a >= 1e3:
+		return threeDigits(v/1e3) + "k"
+	}
+	return threeDigits(v)
+}
+
+// threeDigits shows a value under a thousand to three significant digits
+func threeDigits(v float64) string {
+	switch a := math.Abs(v); {
+	case a >= 100 || a == 0:
+		return strconv.FormatFloat(v, 'f', 0, 64)
+	case a >= 10:
+		return strconv.FormatFloat(v, 'f', 1, 64)
+	case a >= 1:
+		return strconv.FormatFloat(v, 'f', 2, 64)
+	}
+	return strconv.FormatFloat(v, 'g', 3, 64)
+}
@@ -0,0 +1,186 @@
+package tools
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestSummarizeSamples(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   benchStats
+	}{
+		{name: "none", values: nil, want: benchStats{}},
+		{name: "one", values: []float64{7}, want: benchStats{center: 7, n: 1}},
+		{name: "odd count", values: []float64{102, 98, 100}, want: benchStats{center: 100, spread: 0.02, n: 3}},
+		{name: "even count interpolated", values: []float64{10, 20, 30, 40}, want: benchStats{center: 25, spread: 0.6, n: 4}},
+		{name: "outlier left out", values: []float64{100, 101, 99, 100, 500}, want: benchStats{center: 100, spread: 0.01, n: 5}},
+		{name: "all zero", values: []float64{0, 0, 0}, want: benchStats{center: 0, n: 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := summarizeSamples(tt.values)
+			if got.n != tt.want.n || !near(got.center, tt.want.center) || !near(got.spread, tt.want.spread) {
+				t.Errorf("summarizeSamples(%v) = %+v, want %+v", tt.values, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMannWhitneyP(t *testing.T) {
+	tests := []struct {
+		name string
+		x, y []float64
+		want float64
+	}{
+		{name: "separated, 3 and 3", x: []float64{1, 2, 3}, y: []float64{4, 5, 6}, want: 0.1},
+		{name: "separated, 5 and 5", x: []float64{1, 2, 3, 4, 5}, y: []float64{6, 7, 8, 9, 10}, want: 2.0 / 252},
+		{name: "order doesn't matter", x: []float64{5, 3, 1, 4, 2}, y: []float64{10, 8, 6, 9, 7}, want: 2.0 / 252},
+		{name: "interleaved", x: []float64{1, 3, 5}, y: []float64{2, 4, 6}, want: 0.7},
+		{name: "ties, normal approximation", x: []float64{1, 2, 3, 4, 5}, y: []float64{3, 4, 5, 6, 7}, want: 0.1138},
+		{name: "all tied", x: []float64{1, 1, 1}, y: []float64{1, 1, 1}, want: 1},
+		{name: "empty", x: nil, y: []float64{1, 2}, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mannWhitneyP(tt.x, tt.y); math.Abs(got-tt.want) > 1e-3 {
+				t.Errorf("mannWhitneyP(%v, %v) = %.4f, want %.4f", tt.x, tt.y, got, tt.want)
+			}
+			if got, reversed := mannWhitneyP(tt.x, tt.y), mannWhitneyP(tt.y, tt.x); !near(got, reversed) {
+				t.Errorf("mannWhitneyP is %.4f one way and %.4f the other", got, reversed)
+			}
+		})
+	}
+}
+
+func TestCompareBenchmarks(t *testing.T) {
+	old := parseBenchmarks(`goos: linux
+pkg: example.com/app
+BenchmarkParse-8    1000    100 ns/op    0 B/op
+BenchmarkParse-8    1000    101 ns/op    0 B/op
+BenchmarkParse-8    1000    102 ns/op    0 B/op
+BenchmarkParse-8    1000    103 ns/op    0 B/op
+BenchmarkParse-8    1000    104 ns/op    0 B/op
+BenchmarkEncode-8   500     200 ns/op    64 B/op
+BenchmarkEncode-8   500     210 ns/op    64 B/op
+BenchmarkEncode-8   500     190 ns/op    64 B/op
+BenchmarkRemoved-8  10      5 ns/op      0 B/op
+`)
+	new := parseBenchmarks(`pkg: example.com/app
+BenchmarkParse-8    1000    110 ns/op    16 B/op
+BenchmarkParse-8    1000    111 ns/op    16 B/op
+BenchmarkParse-8    1000    112 ns/op    16 B/op
+BenchmarkParse-8    1000    113 ns/op    16 B/op
+BenchmarkParse-8    1000    114 ns/op    16 B/op
+BenchmarkEncode-8   500     205 ns/op    64 B/op
+BenchmarkEncode-8   500     195 ns/op    64 B/op
+BenchmarkEncode-8   500     200 ns/op    64 B/op
+BenchmarkAdded-8    10      5 ns/op      0 B/op
+`)
+	c := compareBenchmarks(old, new)
+
+	if !reflect.DeepEqual(c.units, []string{"ns/op", "B/op"}) {
+		t.Errorf("units = %q", c.units)
+	}
+	if !reflect.DeepEqual(c.onlyOld, []string{"Removed-8"}) || !reflect.DeepEqual(c.onlyNew, []string{"Added-8"}) {
+		t.Errorf("onlyOld = %q, onlyNew = %q", c.onlyOld, c.onlyNew)
+	}
+
+	tests := []struct {
+		unit, name  string
+		change      float64
+		p           float64
+		significant bool
+	}{
+		{unit: "ns/op", name: "Parse-8", change: 10.0 / 102, p: 2.0 / 252, significant: true},
+		{unit: "ns/op", name: "Encode-8", change: 0, p: 1, significant: false},
+		{unit: "B/op", name: "Parse-8", change: math.Inf(1), p: 0.0040, significant: true},
+		{unit: "B/op", name: "Encode-8", change: 0, p: 1, significant: false},
+	}
+	for _, tt := range tests {
+		var d *benchDelta
+		for i := range c.deltas[tt.unit] {
+			if c.deltas[tt.unit][i].name == tt.name {
+				d = &c.deltas[tt.unit][i]
+			}
+		}
+		if d == nil {
+			t.Errorf("no %s delta for %s", tt.unit, tt.name)
+			continue
+		}
+		if !near(d.change, tt.change) || math.Abs(d.p-tt.p) > 1e-3 || d.significant() != tt.significant {
+			t.Errorf("%s %s: change %.4f p %.4f significant %v, want %.4f, %.4f, %v",
+				tt.name, tt.unit, d.change, d.p, d.significant(), tt.change, tt.p, tt.significant)
+		}
+	}
+
+	// The geomean covers benchmarks with positive centers in both runs
+	g := c.geomean["ns/op"]
+	if want := [2]float64{math.Sqrt(102 * 200), math.Sqrt(112 * 200)}; !near(g[0], want[0]) || !near(g[1], want[1]) {
+		t.Errorf("ns/op geomean = %v, want %v", g, want)
+	}
+	if _, ok := c.geomean["B/op"]; ok {
+		t.Error("B/op has a geomean with one benchmark above zero")
+	}
+
+	if got := c.regressions(0.05); !reflect.DeepEqual(got, []string{"Parse-8 time/op +9.80%", "Parse-8 alloc/op +Inf%"}) {
+		t.Errorf("regressions(0.05) = %q", got)
+	}
+	if got := c.regressions(0.10); !reflect.DeepEqual(got, []string{"Parse-8 alloc/op +Inf%"}) {
+		t.Errorf("regressions(0.10) = %q", got)
+	}
+	if got, want := c.tally("ns/op"), "time/op: 0 faster, 1 slower, 1 unchanged; geomean +4.79%"; got != want {
+		t.Errorf("tally = %q, want %q", got, want)
+	}
+}
+
+func TestWorse(t *testing.T) {
+	tests := []struct {
+		unit   string
+		change float64
+		want   bool
+	}{
+		{"ns/op", 0.1, true},
+		{"ns/op", -0.1, false},
+		{"B/op", 0.1, true},
+		{"MB/s", 0.1, false},
+		{"MB/s", -0.1, true},
+	}
+	for _, tt := range tests {
+		if got := worse(tt.unit, tt.change); got != tt.want {
+			t.Errorf("worse(%q, %v) = %v, want %v", tt.unit, tt.change, got, tt.want)
+		}
+	}
+}
+
+func TestFormatBenchValue(t *testing.T) {
+	tests := []struct {
+		unit string
+		v    float64
+		want string
+	}{
+		{"ns/op", 12.345, "12.3ns"},
+		{"ns/op", 1250, "1.25µs"},
+		{"ns/op", 3.5e9, "3.50s"},
+		{"B/op", 2048, "2.00KiB"},
+		{"B/op", 0, "0B"},
+		{"MB/s", 153.2, "153MB/s"},
+		{"allocs/op", 0.5, "0.5"},
+		{"allocs/op", 12000, "12.0k"},
+	}
+	for _, tt := range tests {
+		if got := formatBenchValue(tt.unit, tt.v); got != tt.want {
+			t.Errorf("formatBenchValue(%q, %v) = %q, want %q", tt.unit, tt.v, got, tt.want)
+		}
+	}
+}
+
+// near reports whether a and b are equal to within rounding
+func near(a, b float64) bool {
+	if math.IsInf(a, 0) || math.IsInf(b, 0) {
+		return a == b
+	}
+	return math.Abs(a-b) <= 1e-9*math.Max(1, math.Abs(b))
+}
@@ -0,0 +1,166 @@
+package tools
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"rcode/completion"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// Largest part of the staged diff sent to the model; the stat summary covers the rest
+const maxCommitDiffBytes = 60000
+
+// Model used to write commit messages
+var commitMessageModel completion.Client
+
+// SetCommitMessageModel sets the model that writes commit messages
+func SetCommitMessageModel(model completion.Client) {
+	commitMessageModel = model
+}
+
+// GenerateCommitMessage asks the model for a Conventional Commits message describing the
+// changes staged in the repository at path. hint, if given, tells the model what the change is for.
+func GenerateCommitMessage(path, hint string) (string, error) {
+	if commitMessageModel == nil {
+		return "", NewPermanentError(serr.New("commit message generation is not available"), "no model")
+	}
+
+	diff, err := runGitOutput(path, "diff", "--cached", "--no-color", "--no-ext-diff")
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(diff) == "" {
+		return "", NewPermanentError(serr.New("No changes staged for commit. Use git_add first"), "no changes")
+	}
+	stat, _ := runGitOutput(path, "diff", "--cached", "--stat", "--no-color")
+	recent, _ := runGitOutput(path, "log", "-10", "--format=%s")
+
+	reply, err := commitMessageModel.Complete(commitMessagePrompt(diff, stat, recent, hint))
+	if err != nil {
+		return "", NewRetryableError(serr.Wrap(err, "failed to generate commit message"), "model error")
+	}
+
+	message := cleanCommitMessage(reply)
+	if message == "" {
+		return "", NewRetryableError(serr.New("model returned an empty commit message"), "model error")
+	}
+	return message, nil
+}
+
+// commitMessagePrompt asks for a message in the Conventional Commits format,
+// showing recent subjects so the model follows the repository's scopes
+func commitMessagePrompt(diff, stat, recent, hint string) string {
+	var sb strings.Builder
+
+	sb.WriteString("Write a git commit message for the staged changes below, following the Conventional Commits format:\n\n")
+	sb.WriteString("<type>(<optional scope>): <description>\n\n<optional body>\n\n")
+	sb.WriteString("Rules:\n")
+	sb.WriteString("- type is one of feat, fix, docs, style, refactor, perf, test, build, ci, chore, revert\n")
+	sb.WriteString("- The subject line is imperative, lower case after the colon, has no trailing period and is at most 72 characters\n")
+	sb.WriteString("- Add a body, wrapped at 72 characters, only when the change needs explaining; say what changed and why, not how\n")
+	sb.WriteString("- Mark breaking changes with ! after the type or scope and a BREAKING CHANGE: footer\n")
+	sb.WriteString("- Reply with the commit message only, without quotes, code fences or commentary\n\n")
+
+	if hint = strings.TrimSpace(hint); hint != "" {
+		sb.WriteString("The author describes the change as: " + hint + "\n\n")
+	}
+	if recent = strings.TrimSpace(recent); recent != "" {
+		sb.WriteString("Recent commit subjects in this repository:\n" + recent + "\n\n")
+	}
+
+	sb.WriteString("Files changed:\n" + stat + "\n")
+	if len(diff) > maxCommitDiffBytes {
+		diff = diff[:maxCommitDiffBytes] + "\n... (diff truncated)"
+	}
+	sb.WriteString("Diff:\n" + diff)
+
+	return sb.String()
+}
+
+// cleanCommitMessage strips code fences and surrounding whitespace from the model's reply
+func cleanCommitMessage(reply string) string {
+	message := strings.TrimSpace(reply)
+	if strings.HasPrefix(message, "```") {
+		if nl := strings.Index(message, "\n"); nl >= 0 {
+			message = message[nl+1:]
+		}
+		message = strings.TrimSuffix(strings.TrimSpace(message), "```")
+	}
+	return strings.TrimSpace(message)
+}
+
+// runGitOutput runs a read-only git command and returns its output
+func runGitOutput(path string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = path
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		errMsg := stderr.String()
+		if strings.Contains(errMsg, "not a git repository") {
+			return "", NewPermanentError(serr.New(fmt.Sprintf("Not a git repository: %s", path)), "invalid repository")
+		}
+		return "", WrapFileSystemError(serr.Wrap(err, fmt.Sprintf("git %s failed: %s", args[0], errMsg)))
+	}
+	return stdout.String(), nil
+}
+
+// GitCommitMessageTool writes a commit message for the staged changes and can commit with it
+type GitCommitMessageTool struct{}
+
+// GetDefinition returns the tool definition for generating a commit message
+func (t *GitCommitMessageTool) GetDefinition() Tool {
+	return Tool{
+		Name:        "git_commit_message",
+		Description: "Generate a Conventional Commits message for the staged changes, and optionally commit with it",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"path": map[string]interface{}{
+					"type":        "string",
+					"description": "Repository path (defaults to current directory)",
+				},
+				"hint": map[string]interface{}{
+					"type":        "string",
+					"description": "What the change is for, to guide the message",
+				},
+				"commit": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Commit the staged changes with the generated message",
+				},
+			},
+			"required": []string{},
+		},
+	}
+}
+
+// Execute generates the message, committing with it if asked
+func (t *GitCommitMessageTool) Execute(input map[string]interface{}) (string, error) {
+	path, ok := GetString(input, "path")
+	if !ok || path == "" {
+		path = "."
+	}
+	hint, _ := GetString(input, "hint")
+
+	message, err := GenerateCommitMessage(path, hint)
+	if err != nil {
+		return "", err
+	}
+
+	if commit, ok := input["commit"].(bool); !ok || !commit {
+		return message, nil
+	}
+
+	result, err := (&GitCommitTool{}).Execute(map[string]interface{}{"path": path, "message": message})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Committed with message:\n%s\n\n%s", message, result), nil
+}
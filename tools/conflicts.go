@@ -0,0 +1,385 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// Conflict markers written by git into conflicted files
+const (
+	conflictStart = "<<<<<<<"
+	conflictBase  = "|||||||"
+	conflictSep   = "======="
+	conflictEnd   = ">>>>>>>"
+)
+
+// ConflictHunk is one conflicted region of a file, between <<<<<<< and >>>>>>>
+type ConflictHunk struct {
+	Number      int      // 1-based position in the file
+	StartLine   int      // Line of the <<<<<<< marker
+	EndLine     int      // Line of the >>>>>>> marker
+	OursLabel   string   // Text after <<<<<<<, usually HEAD
+	TheirsLabel string   // Text after >>>>>>>, usually the merged branch or commit
+	Ours        []string // Lines from the current branch
+	Base        []string // Lines from the common ancestor, present with merge.conflictStyle=diff3
+	Theirs      []string // Lines from the branch or commit being applied
+	HasBase     bool
+}
+
+// ConflictResolution says how to resolve one hunk
+type ConflictResolution struct {
+	Hunk    int    // Hunk number from ParseConflicts
+	Choice  string // ours, theirs, both, base or custom
+	Content string // Replacement text when Choice is custom
+}
+
+// ParseConflicts finds the conflict hunks in content. Unterminated markers are reported as an error
+// rather than guessed at, since a resolution written over them would lose lines.
+func ParseConflicts(content string) ([]ConflictHunk, error) {
+	var hunks []ConflictHunk
+	var hunk *ConflictHunk
+	section := ""
+
+	for i, line := range strings.Split(content, "\n") {
+		lineNum := i + 1
+		switch {
+		case strings.HasPrefix(line, conflictStart) && hunk == nil:
+			hunk = &ConflictHunk{
+				Number:    len(hunks) + 1,
+				StartLine: lineNum,
+				OursLabel: strings.TrimSpace(strings.TrimPrefix(line, conflictStart)),
+			}
+			section = "ours"
+		case hunk == nil:
+			continue
+		case strings.HasPrefix(line, conflictBase) && section == "ours":
+			hunk.HasBase = true
+			section = "base"
+		case strings.TrimSuffix(line, "\r") == conflictSep && (section == "ours" || section == "base"):
+			section = "theirs"
+		case strings.HasPrefix(line, conflictEnd) && section == "theirs":
+			hunk.EndLine = lineNum
+			hunk.TheirsLabel = strings.TrimSpace(strings.TrimPrefix(line, conflictEnd))
+			hunks = append(hunks, *hunk)
+			hunk = nil
+		case section == "ours":
+			hunk.Ours = append(hunk.Ours, line)
+		case section == "base":
+			hunk.Base = append(hunk.Base, line)
+		default:
+			hunk.Theirs = append(hunk.Theirs, line)
+		}
+	}
+
+	if hunk != nil {
+		return nil, serr.New(fmt.Sprintf("conflict starting at line %d has no closing %s marker", hunk.StartLine, conflictEnd))
+	}
+	return hunks, nil
+}
+
+// ResolveConflicts replaces the hunks named in resolutions with the chosen text and returns the
+// new content along with the number of hunks still unresolved
+func ResolveConflicts(content string, resolutions []ConflictResolution) (string, int, error) {
+	hunks, err := ParseConflicts(content)
+	if err != nil {
+		return "", 0, err
+	}
+	if len(hunks) == 0 {
+		return "", 0, serr.New("no conflict markers found")
+	}
+
+	replacements := make(map[int][]string, len(resolutions))
+	for _, r := range resolutions {
+		if r.Hunk < 1 || r.Hunk > len(hunks) {
+			return "", 0, serr.New(fmt.Sprintf("hunk %d does not exist; the file has %d conflict hunk(s)", r.Hunk, len(hunks)))
+		}
+		if _, dup := replacements[r.Hunk]; dup {
+			return "", 0, serr.New(fmt.Sprintf("hunk %d is resolved more than once", r.Hunk))
+		}
+
+		hunk := hunks[r.Hunk-1]
+		switch r.Choice {
+		case "ours":
+			replacements[r.Hunk] = hunk.Ours
+		case "theirs":
+			replacements[r.Hunk] = hunk.Theirs
+		case "both":
+			replacements[r.Hunk] = append(append([]string{}, hunk.Ours...), hunk.Theirs...)
+		case "base":
+			if !hunk.HasBase {
+				return "", 0, serr.New(fmt.Sprintf("hunk %d has no base section; set merge.conflictStyle to diff3 to record it", r.Hunk))
+			}
+			replacements[r.Hunk] = hunk.Base
+		case "custom":
+			if r.Content == "" {
+				replacements[r.Hunk] = []string{}
+			} else {
+				replacements[r.Hunk] = strings.Split(strings.TrimSuffix(r.Content, "\n"), "\n")
+			}
+		default:
+			return "", 0, serr.New(fmt.Sprintf("unknown choice '%s' for hunk %d; use ours, theirs, both, base or custom", r.Choice, r.Hunk))
+		}
+	}
+
+	lines := strings.Split(content, "\n")
+	var result []string
+	next := 0 // Index into lines of the first line not yet copied
+	for _, hunk := range hunks {
+		replacement, ok := replacements[hunk.Number]
+		if !ok {
+			continue
+		}
+		result = append(result, lines[next:hunk.StartLine-1]...)
+		result = append(result, replacement...)
+		next = hunk.EndLine
+	}
+	result = append(result, lines[next:]...)
+
+	return strings.Join(result, "\n"), len(hunks) - len(replacements), nil
+}
+
+// formatConflictHunk writes a hunk with each side labeled for the model
+func formatConflictHunk(sb *strings.Builder, hunk ConflictHunk) {
+	sb.WriteString(fmt.Sprintf("Hunk %d (lines %d-%d)\n", hunk.Number, hunk.StartLine, hunk.EndLine))
+	writeSide := func(name, label string, lines []string) {
+		if label != "" {
+			name += " (" + label + ")"
+		}
+		sb.WriteString("--- " + name + ":\n")
+		for _, line := range lines {
+			sb.WriteString(line + "\n")
+		}
+	}
+	writeSide("ours", hunk.OursLabel, hunk.Ours)
+	if hunk.HasBase {
+		writeSide("base", "", hunk.Base)
+	}
+	writeSide("theirs", hunk.TheirsLabel, hunk.Theirs)
+}
+
+// GitConflictsTool shows the conflicts left by a merge, pull, rebase, cherry-pick or revert
+type GitConflictsTool struct{}
+
+// GetDefinition returns the tool definition for listing conflicts
+func (t *GitConflictsTool) GetDefinition() Tool {
+	return Tool{
+		Name:        "git_conflicts",
+		Description: "List conflicted files after a merge, pull, cherry-pick or revert, showing each conflict hunk with both sides. Resolve hunks with resolve_conflict.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"path": map[string]interface{}{
+					"type":        "string",
+					"description": "Repository path (defaults to current directory)",
+				},
+				"file": map[string]interface{}{
+					"type":        "string",
+					"description": "Only show this file, relative to the repository root",
+				},
+			},
+			"required": []string{},
+		},
+	}
+}
+
+// Execute lists the conflicted files and their hunks
+func (t *GitConflictsTool) Execute(input map[string]interface{}) (string, error) {
+	path, ok := GetString(input, "path")
+	if !ok || path == "" {
+		path = "."
+	}
+	file, _ := GetString(input, "file")
+
+	root, err := runGitOutput(path, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	root = strings.TrimSpace(root)
+
+	output, err := runGitOutput(root, "diff", "--name-only", "--diff-filter=U")
+	if err != nil {
+		return "", err
+	}
+	var files []string
+	for _, f := range strings.Split(output, "\n") {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	if file != "" {
+		file = filepath.ToSlash(filepath.Clean(file))
+		if !containsString(files, file) {
+			return "", NewPermanentError(serr.New(fmt.Sprintf("%s has no unresolved conflicts", file)), "no conflicts")
+		}
+		files = []string{file}
+	}
+	if len(files) == 0 {
+		return "No conflicted files", nil
+	}
+	sort.Strings(files)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%d conflicted file(s)\n", len(files)))
+	for _, f := range files {
+		sb.WriteString(fmt.Sprintf("\n=== %s\n", filepath.Join(root, f)))
+
+		content, err := os.ReadFile(filepath.Join(root, f))
+		if err != nil {
+			// Deleted on one side: there are no markers, only a choice of keeping or removing the file
+			sb.WriteString("File is deleted in the working tree; resolve with git_add to keep the deletion or restore it with git_checkout\n")
+			continue
+		}
+		hunks, err := ParseConflicts(string(content))
+		if err != nil {
+			sb.WriteString(err.Error() + "\n")
+			continue
+		}
+		if len(hunks) == 0 {
+			sb.WriteString("No conflict markers left; stage the file with git_add to mark it resolved\n")
+			continue
+		}
+		for _, hunk := range hunks {
+			formatConflictHunk(&sb, hunk)
+		}
+	}
+
+	sb.WriteString("\nResolve hunks with resolve_conflict, choosing ours, theirs, both, base or custom content for each.")
+	return sb.String(), nil
+}
+
+// ResolveConflictTool applies resolutions to the conflict hunks of a file
+type ResolveConflictTool struct{}
+
+// GetDefinition returns the tool definition for resolving conflicts
+func (t *ResolveConflictTool) GetDefinition() Tool {
+	return Tool{
+		Name: "resolve_conflict",
+		Description: "Resolve conflict hunks in a file by hunk number (as shown by git_conflicts), keeping ours, theirs, both, " +
+			"the base version, or custom content that combines them. The file is staged once no conflicts remain.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"path": map[string]interface{}{
+					"type":        "string",
+					"description": "The conflicted file",
+				},
+				"resolutions": map[string]interface{}{
+					"type":        "array",
+					"description": "How to resolve each hunk; hunks not listed are left as they are",
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"hunk": map[string]interface{}{
+								"type":        "integer",
+								"description": "Hunk number from git_conflicts",
+							},
+							"choice": map[string]interface{}{
+								"type":        "string",
+								"enum":        []string{"ours", "theirs", "both", "base", "custom"},
+								"description": "Which side to keep; both keeps ours then theirs",
+							},
+							"content": map[string]interface{}{
+								"type":        "string",
+								"description": "Replacement text for the hunk when choice is custom",
+							},
+						},
+						"required": []string{"hunk", "choice"},
+					},
+				},
+				"stage": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Stage the file with git add once it has no conflicts left (default: true)",
+				},
+			},
+			"required": []string{"path", "resolutions"},
+		},
+	}
+}
+
+// ConflictResolutionsFromInput reads the resolutions parameter of resolve_conflict
+func ConflictResolutionsFromInput(input map[string]interface{}) ([]ConflictResolution, error) {
+	list, ok := input["resolutions"].([]interface{})
+	if !ok || len(list) == 0 {
+		return nil, serr.New("resolutions parameter is required")
+	}
+
+	resolutions := make([]ConflictResolution, 0, len(list))
+	for i, item := range list {
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, serr.New(fmt.Sprintf("resolution %d must be an object", i+1))
+		}
+		hunk, ok := GetInt(entry, "hunk")
+		if !ok {
+			return nil, serr.New(fmt.Sprintf("resolution %d has no hunk number", i+1))
+		}
+		choice, _ := GetString(entry, "choice")
+		content, _ := GetString(entry, "content")
+		resolutions = append(resolutions, ConflictResolution{Hunk: hunk, Choice: choice, Content: content})
+	}
+	return resolutions, nil
+}
+
+// Execute writes the resolved file and stages it when fully resolved
+func (t *ResolveConflictTool) Execute(input map[string]interface{}) (string, error) {
+	path, ok := GetString(input, "path")
+	if !ok || path == "" {
+		return "", NewPermanentError(serr.New("path parameter is required"), "invalid parameters")
+	}
+	resolutions, err := ConflictResolutionsFromInput(input)
+	if err != nil {
+		return "", NewPermanentError(err, "invalid parameters")
+	}
+	stage := true
+	if s, ok := input["stage"].(bool); ok {
+		stage = s
+	}
+
+	expandedPath, err := ExpandPath(path)
+	if err != nil {
+		return "", serr.Wrap(err, "failed to expand path")
+	}
+
+	info, err := os.Stat(expandedPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", NewPermanentError(serr.New("file not found: "+path), "file not found")
+		}
+		return "", WrapFileSystemError(serr.Wrap(err, "failed to stat file"))
+	}
+	content, err := os.ReadFile(expandedPath)
+	if err != nil {
+		return "", WrapFileSystemError(serr.Wrap(err, "failed to read file"))
+	}
+
+	resolved, remaining, err := ResolveConflicts(string(content), resolutions)
+	if err != nil {
+		return "", NewPermanentError(err, "conflict not resolved")
+	}
+
+	if err := os.WriteFile(expandedPath, []byte(resolved), info.Mode().Perm()); err != nil {
+		if os.IsPermission(err) {
+			return "", NewPermanentError(serr.Wrap(err, fmt.Sprintf("Permission denied writing file: %s", path)), "permission denied")
+		}
+		return "", WrapFileSystemError(serr.Wrap(err, fmt.Sprintf("Failed to write file: %s", path)))
+	}
+	NotifyFileChange(path, "modified")
+
+	result := fmt.Sprintf("Resolved %d conflict hunk(s) in %s", len(resolutions), path)
+	if remaining > 0 {
+		return result + fmt.Sprintf("; %d hunk(s) remain. Run git_conflicts to see their new numbers", remaining), nil
+	}
+	if !stage {
+		return result + "; no conflicts remain. Stage it with git_add to mark it resolved", nil
+	}
+
+	if _, err := runGitOutput(filepath.Dir(expandedPath), "add", "--", filepath.Base(expandedPath)); err != nil {
+		return "", serr.Wrap(err, result+", but staging it failed")
+	}
+	return result + "; no conflicts remain and the file is staged", nil
+}
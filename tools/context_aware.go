@@ -7,12 +7,15 @@ import (
 
 	"github.com/rohanthewiz/serr"
 	"rcode/context"
+	"rcode/redact"
 )
 
 // ContextAwareExecutor wraps tool execution with context awareness
 type ContextAwareExecutor struct {
 	registry       *Registry
 	contextManager *context.Manager
+	hooks          *HookRunner
+	formatter      *FormatPipeline
 }
 
 // NewContextAwareExecutor creates a new context-aware executor
@@ -23,17 +26,52 @@ func NewContextAwareExecutor(registry *Registry, contextManager *context.Manager
 	}
 }
 
+// SetHooks sets the hooks that run before and after each tool
+func (e *ContextAwareExecutor) SetHooks(hooks *HookRunner) {
+	e.hooks = hooks
+}
+
+// SetFormatPipeline sets the pipeline that formats files after the write tools
+func (e *ContextAwareExecutor) SetFormatPipeline(formatter *FormatPipeline) {
+	e.formatter = formatter
+}
+
 // Execute runs a tool with context awareness
 func (e *ContextAwareExecutor) Execute(toolUse ToolUse) (*ToolResult, error) {
+	// Pre-tool hooks may refuse the call
+	if e.hooks != nil {
+		if err := e.hooks.RunPre(toolUse); err != nil {
+			return &ToolResult{
+				Type:      "tool_result",
+				ToolUseID: toolUse.ID,
+				Content:   err.Error(),
+			}, err
+		}
+	}
+
 	// Pre-execution context updates
 	e.preExecute(toolUse)
 
 	// Execute the tool
 	result, err := e.registry.Execute(toolUse)
 
+	// Bring written files into the project's style before anything else sees them
+	if e.formatter != nil && err == nil {
+		result = e.formatter.Apply(toolUse, result)
+	}
+
 	// Post-execution context updates
 	e.postExecute(toolUse, result, err)
 
+	if e.hooks != nil {
+		result = e.hooks.RunPost(toolUse, result, err)
+	}
+
+	// Secrets in the output go no further: not to the model, the pages or the database
+	if result != nil {
+		result.Content = redact.Default().String(result.Content)
+	}
+
 	return result, err
 }
 
@@ -291,6 +329,12 @@ func (e *ContextAwareExecutor) EnhanceToolParams(toolName string, params map[str
 			}
 		}
 
+	case "security_scan":
+		// Run the scanner of the project's language
+		if _, hasLanguage := enhanced["language"]; !hasLanguage && ctx.Language != "" {
+			enhanced["language"] = ctx.Language
+		}
+
 	case "bash":
 		// Add context-aware command suggestions
 		if cmd, ok := enhanced["command"].(string); ok {
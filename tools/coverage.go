@@ -0,0 +1,375 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	rcontext "rcode/context"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// Bounds on a coverage run
+const (
+	coverageTimeout      = 10 * time.Minute
+	defaultCoverageLimit = 30   // Functions listed as not fully covered
+	maxCoverageFailure   = 4000 // Bytes of failing test output returned
+)
+
+// coverageLine is the first line of test_coverage's and generate_tests' results, which the
+// planner reads to record coverage as a plan metric: "Coverage: 41.2% ..." or
+// "Coverage: 41.2% → 58.9% ..."
+var coverageLine = regexp.MustCompile(`^Coverage: ([0-9.]+)%(?: → ([0-9.]+)%)?`)
+
+// ParseCoverageResult returns the coverage a test_coverage or generate_tests result reports,
+// before and after; they are the same for test_coverage
+func ParseCoverageResult(result string) (before, after float64, ok bool) {
+	m := coverageLine.FindStringSubmatch(result)
+	if m == nil {
+		return 0, 0, false
+	}
+	before, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	after = before
+	if m[2] != "" {
+		if after, err = strconv.ParseFloat(m[2], 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	return before, after, true
+}
+
+// CoverageTool measures the statement coverage of a Go module's tests, by package and function
+type CoverageTool struct{}
+
+// functionCoverage is how much of a function the tests run
+type functionCoverage struct {
+	file       string // Absolute
+	name       string // "Get", or "(*Store).Get" for a method
+	line       int
+	endLine    int
+	statements int
+	covered    int
+}
+
+// coverageReport is the coverage of a run of the tests
+type coverageReport struct {
+	root       string
+	packages   string
+	statements int
+	covered    int
+	byPackage  map[string][2]int // Import path: statements, covered
+	functions  []*functionCoverage
+}
+
+// GetDefinition returns the tool definition
+func (t *CoverageTool) GetDefinition() Tool {
+	return Tool{
+		Name: "test_coverage",
+		Description: "Run a Go module's tests with coverage and report the share of statements they run, overall, by " +
+			"package, and for each function not fully covered, least covered first. Use it to find the code that " +
+			"needs tests, and again afterwards to measure the gain. The tests must pass.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"packages": map[string]interface{}{
+					"type":        "string",
+					"description": "The packages to test, as go test takes them (default: ./...)",
+				},
+				"limit": map[string]interface{}{
+					"type":        "integer",
+					"description": fmt.Sprintf("Functions to list that aren't fully covered (default: %d)", defaultCoverageLimit),
+				},
+			},
+		},
+	}
+}
+
+// Execute measures the coverage
+func (t *CoverageTool) Execute(input map[string]interface{}) (string, error) {
+	return t.ExecuteContext(context.Background(), input)
+}
+
+// ExecuteContext measures the coverage, stopping the tests if ctx is cancelled
+func (t *CoverageTool) ExecuteContext(ctx context.Context, input map[string]interface{}) (string, error) {
+	packages, _ := GetString(input, "packages")
+	limit := defaultCoverageLimit
+	if n, ok := GetInt(input, "limit"); ok && n >= 0 {
+		limit = n
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", serr.Wrap(err, "failed to get working directory")
+	}
+	report, failure, err := measureCoverage(ctx, dir, packages)
+	if err != nil {
+		return "", err
+	}
+	if failure != "" {
+		return "", NewPermanentError(serr.New("the tests fail, so coverage can't be measured; fix them first:\n"+failure), "tests fail")
+	}
+	return report.summary(limit), nil
+}
+
+// measureCoverage runs the tests of packages in the module holding dir with a coverage
+// profile. When they fail, it returns their output instead of a report.
+func measureCoverage(ctx context.Context, dir, packages string) (*coverageReport, string, error) {
+	root, modulePath, err := goModule(dir)
+	if err != nil {
+		return nil, "", NewPermanentError(err, "not a Go module")
+	}
+	if strings.TrimSpace(packages) == "" {
+		packages = "./..."
+	}
+
+	profile, err := os.CreateTemp("", "rcode-coverage-*.out")
+	if err != nil {
+		return nil, "", serr.Wrap(err, "failed to create coverage profile")
+	}
+	profile.Close()
+	defer os.Remove(profile.Name())
+
+	ctx, cancel := context.WithTimeout(ctx, coverageTimeout)
+	defer cancel()
+
+	args := append([]string{"test", "-covermode=set", "-coverprofile=" + profile.Name()}, strings.Fields(packages)...)
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = root
+	configureProcessGroup(cmd)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	if err := cmd.Run(); err != nil {
+		switch {
+		case ctx.Err() == context.DeadlineExceeded:
+			return nil, "", NewPermanentError(serr.New(fmt.Sprintf("the tests ran over %s and were stopped", coverageTimeout)), "timeout")
+		case ctx.Err() != nil:
+			return nil, "", serr.New("Coverage run cancelled")
+		}
+		if _, isExit := err.(*exec.ExitError); !isExit {
+			return nil, "", serr.Wrap(err, "failed to run go test")
+		}
+		return nil, testFailure(output.String()), nil
+	}
+
+	report, err := parseCoverProfile(profile.Name(), root, modulePath)
+	if err != nil {
+		return nil, "", err
+	}
+	report.packages = packages
+	return report, "", nil
+}
+
+// testFailure keeps the end of failing test output, where go test reports what failed
+func testFailure(output string) string {
+	output = strings.TrimSpace(output)
+	if len(output) > maxCoverageFailure {
+		output = "..." + output[len(output)-maxCoverageFailure:]
+	}
+	return output
+}
+
+// coverBlock is a block of statements in a coverage profile
+type coverBlock struct {
+	startLine  int
+	statements int
+	covered    bool
+}
+
+// parseCoverProfile reads a coverage profile, attributing its blocks to the functions the
+// context scanner finds in each file
+func parseCoverProfile(profilePath, root, modulePath string) (*coverageReport, error) {
+	file, err := os.Open(profilePath)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to read coverage profile")
+	}
+	defer file.Close()
+
+	// A block is listed once per test binary that includes its package; any run covers it
+	blocks := make(map[string]map[string]*coverBlock) // Import path of the file: position: block
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "mode:") {
+			continue
+		}
+		// name.go:12.34,15.2 3 1
+		fields := strings.Fields(line)
+		colon := strings.LastIndex(line, ":")
+		if len(fields) != 3 || colon < 0 {
+			return nil, serr.New("invalid coverage profile line: " + line)
+		}
+		name, position := line[:colon], strings.Fields(line[colon+1:])[0]
+		startLine, _ := strconv.Atoi(position[:strings.Index(position, ".")])
+		statements, _ := strconv.Atoi(fields[1])
+		count, _ := strconv.Atoi(fields[2])
+
+		if blocks[name] == nil {
+			blocks[name] = make(map[string]*coverBlock)
+		}
+		block := blocks[name][position]
+		if block == nil {
+			block = &coverBlock{startLine: startLine, statements: statements}
+			blocks[name][position] = block
+		}
+		block.covered = block.covered || count > 0
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, serr.Wrap(err, "failed to read coverage profile")
+	}
+
+	report := &coverageReport{root: root, byPackage: make(map[string][2]int)}
+	symbols := rcontext.NewProjectScanner()
+	for name, fileBlocks := range blocks {
+		functions := fileFunctions(symbols, coveredFilePath(name, root, modulePath))
+		pkg := report.byPackage[path.Dir(name)]
+		for _, block := range fileBlocks {
+			pkg[0] += block.statements
+			report.statements += block.statements
+			if block.covered {
+				pkg[1] += block.statements
+				report.covered += block.statements
+			}
+			for _, fn := range functions {
+				if block.startLine >= fn.line && block.startLine <= fn.endLine {
+					fn.statements += block.statements
+					if block.covered {
+						fn.covered += block.statements
+					}
+					break
+				}
+			}
+		}
+		report.byPackage[path.Dir(name)] = pkg
+		for _, fn := range functions {
+			if fn.statements > 0 {
+				report.functions = append(report.functions, fn)
+			}
+		}
+	}
+	return report, nil
+}
+
+// coveredFilePath returns where a file named in a coverage profile by its import path is
+func coveredFilePath(name, root, modulePath string) string {
+	if rel, ok := strings.CutPrefix(name, modulePath+"/"); ok {
+		return filepath.Join(root, filepath.FromSlash(rel))
+	}
+	return name // Outside the module, so left unresolved
+}
+
+// fileFunctions returns the functions and methods declared in a Go file
+func fileFunctions(scanner *rcontext.ProjectScanner, file string) []*functionCoverage {
+	symbols, err := scanner.FileSymbols(file)
+	if err != nil {
+		return nil
+	}
+	var functions []*functionCoverage
+	for _, sym := range symbols {
+		if (sym.Kind != "function" && sym.Kind != "method") || sym.EndLine == 0 {
+			continue
+		}
+		name := sym.Name
+		if sym.Receiver != "" {
+			name = "(" + sym.Receiver + ")." + sym.Name
+		}
+		functions = append(functions, &functionCoverage{file: file, name: name, line: sym.Line, endLine: sym.EndLine})
+	}
+	return functions
+}
+
+// percent is covered as a share of statements, 0 when there are none
+func percent(covered, statements int) float64 {
+	if statements == 0 {
+		return 0
+	}
+	return 100 * float64(covered) / float64(statements)
+}
+
+// percent is the report's overall coverage
+func (r *coverageReport) percent() float64 {
+	return percent(r.covered, r.statements)
+}
+
+// uncovered returns the functions not fully covered: least covered first, then those with
+// the most statements left to cover
+func (r *coverageReport) uncovered() []*functionCoverage {
+	var functions []*functionCoverage
+	for _, fn := range r.functions {
+		if fn.covered < fn.statements {
+			functions = append(functions, fn)
+		}
+	}
+	sort.SliceStable(functions, func(i, j int) bool {
+		a, b := functions[i], functions[j]
+		if pa, pb := percent(a.covered, a.statements), percent(b.covered, b.statements); pa != pb {
+			return pa < pb
+		}
+		if ua, ub := a.statements-a.covered, b.statements-b.covered; ua != ub {
+			return ua > ub
+		}
+		if a.file != b.file {
+			return a.file < b.file
+		}
+		return a.line < b.line
+	})
+	return functions
+}
+
+// label names a function by its file, line and name, relative to the module root
+func (r *coverageReport) label(fn *functionCoverage) string {
+	rel, err := filepath.Rel(r.root, fn.file)
+	if err != nil {
+		rel = fn.file
+	}
+	return fmt.Sprintf("%s:%d %s", filepath.ToSlash(rel), fn.line, fn.name)
+}
+
+// summary lists the coverage overall and by package, then up to limit functions not fully covered
+func (r *coverageReport) summary(limit int) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Coverage: %.1f%% of statements (%d/%d) in %s\n", r.percent(), r.covered, r.statements, r.packages)
+
+	pkgs := make([]string, 0, len(r.byPackage))
+	for pkg := range r.byPackage {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+	if len(pkgs) > 1 {
+		sb.WriteString("\nBy package:\n")
+		for _, pkg := range pkgs {
+			counts := r.byPackage[pkg]
+			fmt.Fprintf(&sb, "  %s %.1f%% (%d/%d)\n", pkg, percent(counts[1], counts[0]), counts[1], counts[0])
+		}
+	}
+
+	uncovered := r.uncovered()
+	if len(uncovered) == 0 {
+		sb.WriteString("\nEvery function is fully covered.")
+		return sb.String()
+	}
+	fmt.Fprintf(&sb, "\nFunctions not fully covered (%d of %d), least covered first:\n", len(uncovered), len(r.functions))
+	for i, fn := range uncovered {
+		if i == limit {
+			fmt.Fprintf(&sb, "  ... %d more\n", len(uncovered)-limit)
+			break
+		}
+		fmt.Fprintf(&sb, "  %s %.1f%% (%d/%d)\n", r.label(fn), percent(fn.covered, fn.statements), fn.covered, fn.statements)
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
@@ -0,0 +1,515 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// Bounds on a database query
+const (
+	defaultQueryTimeout = 30 * time.Second
+	defaultQueryRows    = 100
+	maxQueryRows        = 1000
+	maxQueryCell        = 200 // Characters of a value shown
+)
+
+// databaseName is what a connection may be called
+var databaseName = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// readOnlyStatements are the statements a read-only connection runs, by their first word
+var readOnlyStatements = map[string]bool{
+	"SELECT": true, "WITH": true, "EXPLAIN": true, "SHOW": true, "DESCRIBE": true,
+	"DESC": true, "VALUES": true, "TABLE": true, "PRAGMA": true,
+}
+
+// Database is a connection the db_query tool can query, declared in a config file
+type Database struct {
+	Name        string `json:"name"`
+	Driver      string `json:"driver"`                // postgres, mysql or sqlite
+	DSN         string `json:"dsn"`                   // A postgres:// or mysql:// URL, or a SQLite file; ${VAR} is replaced from the environment
+	Description string `json:"description,omitempty"` // What the database holds, for the model
+	AllowWrites bool   `json:"allow_writes,omitempty"`
+	MaxRows     int    `json:"max_rows,omitempty"` // Rows returned by default (default: 100)
+	Timeout     int    `json:"timeout,omitempty"`  // Seconds (default: 30)
+}
+
+// databasesFile is the layout of a database connections config file
+type databasesFile struct {
+	Databases []Database `json:"databases"`
+}
+
+// databaseClients are the command-line clients that run each driver's queries
+var databaseClients = map[string]string{
+	"postgres": "psql",
+	"mysql":    "mysql",
+	"sqlite":   "sqlite3",
+}
+
+// LoadDatabases reads database connections from the given config files, skipping files that
+// do not exist. A connection in a later file replaces one of the same name in an earlier file.
+func LoadDatabases(paths ...string) ([]Database, error) {
+	var databases []Database
+	index := make(map[string]int)
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, serr.Wrap(err, "failed to read databases file", "path", path)
+		}
+
+		var file databasesFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, serr.Wrap(err, "invalid databases file", "path", path)
+		}
+
+		for i, database := range file.Databases {
+			if !databaseName.MatchString(database.Name) {
+				return nil, serr.New(fmt.Sprintf("database %d in %s: name must be 1 to 64 letters, digits, _ or -", i+1, path))
+			}
+			if _, ok := databaseClients[database.Driver]; !ok {
+				return nil, serr.New(fmt.Sprintf("database %q in %s: driver must be postgres, mysql or sqlite", database.Name, path))
+			}
+			if database.DSN == "" {
+				return nil, serr.New(fmt.Sprintf("database %q in %s: set dsn, the database's URL or file", database.Name, path))
+			}
+
+			if j, ok := index[database.Name]; ok {
+				databases[j] = database
+				continue
+			}
+			index[database.Name] = len(databases)
+			databases = append(databases, database)
+		}
+	}
+
+	return databases, nil
+}
+
+// RegisterDatabaseTool adds the db_query tool to the registry when there are databases to
+// query. SQLite files are found from projectRoot.
+func RegisterDatabaseTool(registry *Registry, databases []Database, projectRoot string) {
+	if len(databases) == 0 {
+		return
+	}
+	tool := &DatabaseQueryTool{databases: databases, projectRoot: projectRoot}
+	registry.Register(tool.GetDefinition(), tool)
+}
+
+// DatabaseQueryTool runs SQL against the project's databases with their command-line clients
+type DatabaseQueryTool struct {
+	databases   []Database
+	projectRoot string
+}
+
+// GetDefinition returns the tool definition, listing the databases
+func (t *DatabaseQueryTool) GetDefinition() Tool {
+	names := make([]string, 0, len(t.databases))
+	var list strings.Builder
+	for _, database := range t.databases {
+		names = append(names, database.Name)
+		access := "read-only"
+		if database.AllowWrites {
+			access = "read-write"
+		}
+		fmt.Fprintf(&list, "\n- %s (%s, %s)", database.Name, database.Driver, access)
+		if database.Description != "" {
+			list.WriteString(": " + database.Description)
+		}
+	}
+
+	return Tool{
+		Name: "db_query",
+		Description: "Run one SQL statement against one of the project's databases and return the rows as a table. Use it to " +
+			"inspect schemas and data while working on code that uses them: information_schema (postgres, mysql) or " +
+			"sqlite_schema (sqlite) describes the tables. Read-only databases only run SELECT, WITH, EXPLAIN, SHOW, DESCRIBE, " +
+			"VALUES, TABLE and PRAGMA. Rows beyond max_rows are left out, so add a LIMIT or ORDER BY to get the ones you want. " +
+			"Set explain to see the query plan instead of running the query. The databases:" + list.String(),
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"database": map[string]interface{}{
+					"type":        "string",
+					"description": "The database to query (default: the only one, when there is one)",
+					"enum":        names,
+				},
+				"query": map[string]interface{}{
+					"type":        "string",
+					"description": "One SQL statement, in the database's dialect",
+				},
+				"explain": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Return the query plan instead of running the query",
+				},
+				"max_rows": map[string]interface{}{
+					"type":        "integer",
+					"description": fmt.Sprintf("Rows to return (default: the database's, or %d; at most %d)", defaultQueryRows, maxQueryRows),
+				},
+			},
+			"required": []string{"query"},
+		},
+	}
+}
+
+// Execute runs the query
+func (t *DatabaseQueryTool) Execute(input map[string]interface{}) (string, error) {
+	return t.ExecuteContext(context.Background(), input)
+}
+
+// ExecuteContext runs the query, stopping the client once it has returned max_rows rows
+func (t *DatabaseQueryTool) ExecuteContext(parent context.Context, input map[string]interface{}) (string, error) {
+	database, err := t.database(input)
+	if err != nil {
+		return "", NewPermanentError(err, "unknown database")
+	}
+
+	query, _ := GetString(input, "query")
+	query = strings.TrimRight(strings.TrimSpace(query), "; \t\r\n")
+	if query == "" {
+		return "", NewPermanentError(serr.New("query parameter is required"), "missing query")
+	}
+	if hasSeveralStatements(query) {
+		return "", NewPermanentError(serr.New("run one statement at a time"), "several statements")
+	}
+	if explain, _ := GetBool(input, "explain"); explain {
+		query = explainPrefix(database.Driver) + query
+	}
+	if !database.AllowWrites && !readOnlyStatements[firstWord(query)] {
+		return "", NewPermanentError(serr.New(fmt.Sprintf("%s is read-only: it only runs SELECT, WITH, EXPLAIN, SHOW, DESCRIBE, "+
+			"VALUES, TABLE and PRAGMA statements", database.Name)), "write to read-only database")
+	}
+
+	maxRows := defaultQueryRows
+	if database.MaxRows > 0 {
+		maxRows = database.MaxRows
+	}
+	if n, ok := GetInt(input, "max_rows"); ok && n > 0 {
+		maxRows = n
+	}
+	if maxRows > maxQueryRows {
+		maxRows = maxQueryRows
+	}
+
+	timeout := defaultQueryTimeout
+	if database.Timeout > 0 {
+		timeout = time.Duration(database.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	cmd, err := t.command(ctx, database, query)
+	if err != nil {
+		return "", NewPermanentError(err, "client not available")
+	}
+	configureProcessGroup(cmd)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", serr.Wrap(err, "failed to read the query's output")
+	}
+	var stderr snippetOutput
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return "", serr.Wrap(err, "failed to start "+databaseClients[database.Driver])
+	}
+
+	// Stop the client once there are more rows than are shown
+	header, rows, more, readErr := readQueryRows(database.Driver, stdout, maxRows)
+	if more {
+		cancel()
+	}
+	io.Copy(io.Discard, stdout)
+	err = cmd.Wait()
+
+	switch {
+	case parent.Err() == context.Canceled:
+		return "", serr.New("Query cancelled")
+	case ctx.Err() == context.DeadlineExceeded && !more:
+		return "", serr.New(fmt.Sprintf("The query timed out after %s", timeout))
+	case err != nil && !more:
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+		return "", NewPermanentError(serr.New(message), "query failed")
+	case readErr != nil:
+		return "", serr.Wrap(readErr, "failed to read the query's rows")
+	}
+
+	return formatQueryRows(header, rows, more), nil
+}
+
+// database returns the database the input names, or the only one
+func (t *DatabaseQueryTool) database(input map[string]interface{}) (Database, error) {
+	name, _ := GetString(input, "database")
+	if name == "" && len(t.databases) == 1 {
+		return t.databases[0], nil
+	}
+	names := make([]string, 0, len(t.databases))
+	for _, database := range t.databases {
+		if database.Name == name {
+			return database, nil
+		}
+		names = append(names, database.Name)
+	}
+	sort.Strings(names)
+	return Database{}, serr.New(fmt.Sprintf("no database named %q; use one of %s", name, strings.Join(names, ", ")))
+}
+
+// command returns the client command that runs query on the database. Passwords go in
+// the environment, so they don't show in the process list, and read-only connections
+// are read-only on the server's side too.
+func (t *DatabaseQueryTool) command(ctx context.Context, database Database, query string) (*exec.Cmd, error) {
+	client := databaseClients[database.Driver]
+	path, err := exec.LookPath(client)
+	if err != nil {
+		return nil, serr.New(fmt.Sprintf("%s queries need %s, which is not installed", database.Driver, client))
+	}
+	dsn := os.ExpandEnv(database.DSN)
+	env := os.Environ()
+
+	var args []string
+	switch database.Driver {
+	case "postgres":
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return nil, serr.New(fmt.Sprintf("the dsn of %s is not a postgres:// URL", database.Name))
+		}
+		if password, ok := u.User.Password(); ok {
+			env = append(env, "PGPASSWORD="+password)
+			u.User = url.User(u.User.Username())
+		}
+		if !database.AllowWrites {
+			env = append(env, "PGOPTIONS=-c default_transaction_read_only=on")
+		}
+		args = []string{"--csv", "-X", "-q", "-v", "ON_ERROR_STOP=1", "-d", u.String(), "-c", query}
+
+	case "mysql":
+		u, err := url.Parse(dsn)
+		if err != nil || u.Host == "" {
+			return nil, serr.New(fmt.Sprintf("the dsn of %s is not a mysql:// URL", database.Name))
+		}
+		if password, ok := u.User.Password(); ok {
+			env = append(env, "MYSQL_PWD="+password)
+		}
+		args = []string{"--no-defaults", "--batch", "-h", u.Hostname()}
+		if port := u.Port(); port != "" {
+			args = append(args, "-P", port)
+		}
+		if user := u.User.Username(); user != "" {
+			args = append(args, "-u", user)
+		}
+		if name := strings.TrimPrefix(u.Path, "/"); name != "" {
+			args = append(args, "-D", name)
+		}
+		if !database.AllowWrites {
+			query = "SET SESSION TRANSACTION READ ONLY; " + query
+		}
+		args = append(args, "-e", query)
+
+	case "sqlite":
+		file := dsn
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(t.projectRoot, file)
+		}
+		if _, err := os.Stat(file); err != nil {
+			return nil, serr.New(fmt.Sprintf("the SQLite file of %s, %s, can't be opened", database.Name, database.DSN))
+		}
+		args = []string{"-bail", "-csv", "-header"}
+		if !database.AllowWrites {
+			args = append(args, "-readonly")
+		}
+		args = append(args, file, query)
+	}
+
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Dir = t.projectRoot
+	cmd.Env = env
+	return cmd, nil
+}
+
+// readQueryRows reads a client's output: the column names, then up to maxRows rows, and
+// whether there were more
+func readQueryRows(driver string, r io.Reader, maxRows int) (header []string, rows [][]string, more bool, err error) {
+	next := csvRecords(r)
+	if driver == "mysql" {
+		next = mysqlRecords(r)
+	}
+
+	header, err = next()
+	if err == io.EOF {
+		return nil, nil, false, nil
+	}
+	for err == nil {
+		var row []string
+		if row, err = next(); err != nil {
+			break
+		}
+		if len(rows) == maxRows {
+			return header, rows, true, nil
+		}
+		rows = append(rows, row)
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	return header, rows, false, err
+}
+
+// csvRecords reads the CSV that psql and sqlite3 write
+func csvRecords(r io.Reader) func() ([]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	return reader.Read
+}
+
+// mysqlRecords reads the tab-separated lines mysql writes in batch mode, with tabs,
+// newlines and backslashes in values escaped
+func mysqlRecords(r io.Reader) func() ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	unescape := strings.NewReplacer(`\t`, "\t", `\n`, "\n", `\0`, "\x00", `\\`, `\`)
+	return func() ([]string, error) {
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return nil, err
+			}
+			return nil, io.EOF
+		}
+		fields := strings.Split(scanner.Text(), "\t")
+		for i, field := range fields {
+			fields[i] = unescape.Replace(field)
+		}
+		return fields, nil
+	}
+}
+
+// formatQueryRows returns the rows as a markdown table, each value on one line and cut at
+// maxQueryCell characters
+func formatQueryRows(header []string, rows [][]string, more bool) string {
+	if len(header) == 0 {
+		return "The statement ran and returned no rows"
+	}
+
+	var sb strings.Builder
+	switch {
+	case more:
+		fmt.Fprintf(&sb, "The first %d rows; more were left out:\n\n", len(rows))
+	case len(rows) == 1:
+		sb.WriteString("1 row:\n\n")
+	default:
+		fmt.Fprintf(&sb, "%d rows:\n\n", len(rows))
+	}
+
+	writeRow := func(values []string) {
+		sb.WriteString("|")
+		for _, value := range values {
+			sb.WriteString(" " + queryCell(value) + " |")
+		}
+		sb.WriteString("\n")
+	}
+	writeRow(header)
+	sb.WriteString(strings.Repeat("| --- ", len(header)) + "|\n")
+	for _, row := range rows {
+		writeRow(row)
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// queryCell returns a value for a table cell
+func queryCell(value string) string {
+	value = strings.NewReplacer("\r\n", `\n`, "\n", `\n`, "|", `\|`).Replace(value)
+	if runes := []rune(value); len(runes) > maxQueryCell {
+		value = string(runes[:maxQueryCell]) + "..."
+	}
+	return value
+}
+
+// explainPrefix returns what is put before a query to get its plan
+func explainPrefix(driver string) string {
+	if driver == "sqlite" {
+		return "EXPLAIN QUERY PLAN "
+	}
+	return "EXPLAIN "
+}
+
+// firstWord returns a statement's first word in upper case, past any comments
+func firstWord(query string) string {
+	query = stripSQLComments(query)
+	fields := strings.FieldsFunc(query, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '('
+	})
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToUpper(fields[0])
+}
+
+// stripSQLComments removes the -- and /* */ comments before a statement
+func stripSQLComments(query string) string {
+	for {
+		query = strings.TrimSpace(query)
+		switch {
+		case strings.HasPrefix(query, "--"):
+			if i := strings.Index(query, "\n"); i >= 0 {
+				query = query[i+1:]
+				continue
+			}
+			return ""
+		case strings.HasPrefix(query, "/*"):
+			if i := strings.Index(query, "*/"); i >= 0 {
+				query = query[i+2:]
+				continue
+			}
+			return ""
+		}
+		return query
+	}
+}
+
+// hasSeveralStatements reports whether a query holds a semicolon outside its strings,
+// quoted names and comments
+func hasSeveralStatements(query string) bool {
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '-' && i+1 < len(query) && query[i+1] == '-':
+			if j := strings.IndexByte(query[i:], '\n'); j >= 0 {
+				i += j
+			} else {
+				i = len(query)
+			}
+		case c == '/' && i+1 < len(query) && query[i+1] == '*':
+			if j := strings.Index(query[i+2:], "*/"); j >= 0 {
+				i += j + 3
+			} else {
+				i = len(query)
+			}
+		case c == ';':
+			return true
+		}
+	}
+	return false
+}
@@ -21,6 +21,24 @@ func DefaultRegistry() *Registry {
 	bashTool := &BashTool{}
 	registry.Register(bashTool.GetDefinition(), bashTool)
 
+	// Register run_task tool, for the commands the project defines in its Makefile, justfile and package.json
+	runTaskTool := &RunTaskTool{}
+	registry.Register(runTaskTool.GetDefinition(), runTaskTool)
+
+	// Register run_snippet tool, for trying out code away from the project
+	runSnippetTool := &RunSnippetTool{}
+	registry.Register(runSnippetTool.GetDefinition(), runSnippetTool)
+
+	// Register background process tools for dev servers and other long-running commands
+	runBackgroundTool := &RunBackgroundTool{}
+	registry.Register(runBackgroundTool.GetDefinition(), runBackgroundTool)
+
+	listProcessesTool := &ListProcessesTool{}
+	registry.Register(listProcessesTool.GetDefinition(), listProcessesTool)
+
+	stopProcessTool := &StopProcessTool{}
+	registry.Register(stopProcessTool.GetDefinition(), stopProcessTool)
+
 	// Register edit file tool
 	editTool := &EditFileTool{}
 	registry.Register(editTool.GetDefinition(), editTool)
@@ -30,6 +48,14 @@ func DefaultRegistry() *Registry {
 	smartEditTool := &SmartEditTool{}
 	registry.Register(smartEditTool.GetDefinition(), smartEditTool)
 
+	// Register snippet replacement tool, which finds its target by content rather than line numbers
+	replaceSnippetTool := &ReplaceSnippetTool{}
+	registry.Register(replaceSnippetTool.GetDefinition(), replaceSnippetTool)
+
+	// Register apply patch tool for changes spanning several files
+	applyPatchTool := &ApplyPatchTool{}
+	registry.Register(applyPatchTool.GetDefinition(), applyPatchTool)
+
 	// Register search tool
 	searchTool := &SearchTool{}
 	registry.Register(searchTool.GetDefinition(), searchTool)
@@ -55,6 +81,35 @@ func DefaultRegistry() *Registry {
 	moveTool := &MoveTool{}
 	registry.Register(moveTool.GetDefinition(), moveTool)
 
+	// Register test coverage tools: measuring it, and writing tests for the functions it misses
+	coverageTool := &CoverageTool{}
+	registry.Register(coverageTool.GetDefinition(), coverageTool)
+
+	generateTestsTool := &GenerateTestsTool{}
+	registry.Register(generateTestsTool.GetDefinition(), generateTestsTool)
+
+	// Register the benchmark tool, which compares runs kept for the session
+	benchTool := &BenchTool{}
+	registry.Register(benchTool.GetDefinition(), benchTool)
+
+	// Register dependency tools: listing outdated ones, and updating them with the tests as a check
+	outdatedDependenciesTool := &OutdatedDependenciesTool{}
+	registry.Register(outdatedDependenciesTool.GetDefinition(), outdatedDependenciesTool)
+
+	updateDependencyTool := &UpdateDependencyTool{}
+	registry.Register(updateDependencyTool.GetDefinition(), updateDependencyTool)
+
+	// Register the vulnerability scanner, which can file a plan fixing what it finds
+	securityScanTool := &SecurityScanTool{}
+	registry.Register(securityScanTool.GetDefinition(), securityScanTool)
+
+	// Register refactoring tools, which work out every file a rename or move changes
+	renameSymbolTool := &RenameSymbolTool{}
+	registry.Register(renameSymbolTool.GetDefinition(), renameSymbolTool)
+
+	movePackageTool := &MovePackageTool{}
+	registry.Register(movePackageTool.GetDefinition(), movePackageTool)
+
 	// Register git tools
 	gitStatusTool := &GitStatusTool{}
 	registry.Register(gitStatusTool.GetDefinition(), gitStatusTool)
@@ -86,6 +141,43 @@ func DefaultRegistry() *Registry {
 	gitMergeTool := &GitMergeTool{}
 	registry.Register(gitMergeTool.GetDefinition(), gitMergeTool)
 
+	gitRemoteTool := &GitRemoteTool{}
+	registry.Register(gitRemoteTool.GetDefinition(), gitRemoteTool)
+
+	gitFetchTool := &GitFetchTool{}
+	registry.Register(gitFetchTool.GetDefinition(), gitFetchTool)
+
+	gitTagTool := &GitTagTool{}
+	registry.Register(gitTagTool.GetDefinition(), gitTagTool)
+
+	gitCherryPickTool := &GitCherryPickTool{}
+	registry.Register(gitCherryPickTool.GetDefinition(), gitCherryPickTool)
+
+	gitRevertTool := &GitRevertTool{}
+	registry.Register(gitRevertTool.GetDefinition(), gitRevertTool)
+
+	gitCommitMessageTool := &GitCommitMessageTool{}
+	registry.Register(gitCommitMessageTool.GetDefinition(), gitCommitMessageTool)
+
+	gitConflictsTool := &GitConflictsTool{}
+	registry.Register(gitConflictsTool.GetDefinition(), gitConflictsTool)
+
+	resolveConflictTool := &ResolveConflictTool{}
+	registry.Register(resolveConflictTool.GetDefinition(), resolveConflictTool)
+
+	// Register GitHub/GitLab tools
+	createPullRequestTool := &CreatePullRequestTool{}
+	registry.Register(createPullRequestTool.GetDefinition(), createPullRequestTool)
+
+	listIssuesTool := &ListIssuesTool{}
+	registry.Register(listIssuesTool.GetDefinition(), listIssuesTool)
+
+	getIssueTool := &GetIssueTool{}
+	registry.Register(getIssueTool.GetDefinition(), getIssueTool)
+
+	commentPullRequestTool := &CommentPullRequestTool{}
+	registry.Register(commentPullRequestTool.GetDefinition(), commentPullRequestTool)
+
 	// Register web search tool
 	webSearchTool := &WebSearchTool{}
 	registry.Register(webSearchTool.GetDefinition(), webSearchTool)
@@ -98,24 +190,42 @@ func DefaultRegistry() *Registry {
 	clipboardTool := &ClipboardPasteTool{}
 	registry.Register(clipboardTool.GetDefinition(), clipboardTool)
 
+	// Register artifact tools, for truncated tool outputs and published reports and builds
+	readArtifactTool := &ReadArtifactTool{}
+	registry.Register(readArtifactTool.GetDefinition(), readArtifactTool)
+
+	publishArtifactTool := &PublishArtifactTool{}
+	registry.Register(publishArtifactTool.GetDefinition(), publishArtifactTool)
+
+	// Register the todo tools, for the session's task list
+	todoWriteTool := &TodoWriteTool{}
+	registry.Register(todoWriteTool.GetDefinition(), todoWriteTool)
+
+	todoReadTool := &TodoReadTool{}
+	registry.Register(todoReadTool.GetDefinition(), todoReadTool)
+
+	// Register the note tools, for the session's scratchpad
+	addNoteTool := &AddNoteTool{}
+	registry.Register(addNoteTool.GetDefinition(), addNoteTool)
+
+	readNotesTool := &ReadNotesTool{}
+	registry.Register(readNotesTool.GetDefinition(), readNotesTool)
+
 	return registry
 }
 
-// DefaultRegistryWithPlugins creates a registry with default tools and plugins
+// DefaultRegistryWithPlugins creates a registry with default tools and plugins. The plugins
+// are loaded once and shared; WatchPlugins keeps them in line with the plugin directories.
 func DefaultRegistryWithPlugins(projectRoot string) (*Registry, error) {
 	registry := DefaultRegistry()
 
-	// Load custom tools if enabled
-	cfg := config.Get()
-	if cfg.CustomToolsEnabled {
-		loader := NewPluginLoader(cfg.CustomToolsPaths)
-		if err := loader.LoadPlugins(); err != nil {
-			logger.LogErr(err, "failed to load custom tool plugins")
+	// Add custom tools if enabled
+	if config.Get().CustomToolsEnabled {
+		customPluginsMu.Lock()
+		defer customPluginsMu.Unlock()
+		if err := loadedCustomPlugins().RegisterWithRegistry(registry, projectRoot); err != nil {
+			logger.LogErr(err, "failed to register custom tools")
 			// Continue with built-in tools only
-		} else {
-			if err := loader.RegisterWithRegistry(registry, projectRoot); err != nil {
-				logger.LogErr(err, "failed to register custom tools")
-			}
 		}
 	}
 
@@ -0,0 +1,690 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// Bounds on dependency commands
+const (
+	dependencyTimeout     = 5 * time.Minute  // Listing, or updating one dependency
+	dependencyTestTimeout = 15 * time.Minute // The tests run after an update
+)
+
+// npmPlaceholderTest is the test script npm init writes, which always fails
+const npmPlaceholderTest = `echo "Error: no test specified" && exit 1`
+
+// dependencyListSeparators splits a list of dependencies given as one string
+var dependencyListSeparators = regexp.MustCompile(`[\s,]+`)
+
+// Ecosystems of the dependency tools
+const (
+	ecosystemGo  = "go"
+	ecosystemNpm = "npm"
+)
+
+// projectEcosystems returns the dependency managers the project in dir uses, with the
+// directory each works in: the Go module holding dir, and dir's package.json. Asking for one
+// it doesn't use is an error.
+func projectEcosystems(dir, only string) (map[string]string, error) {
+	found := make(map[string]string)
+	if root, _, err := goModule(dir); err == nil {
+		found[ecosystemGo] = root
+	}
+	if _, err := os.Stat(filepath.Join(dir, "package.json")); err == nil {
+		found[ecosystemNpm] = dir
+	}
+
+	switch only = strings.ToLower(strings.TrimSpace(only)); only {
+	case "":
+		if len(found) == 0 {
+			return nil, NewPermanentError(serr.New("the project has no go.mod or package.json"), "no dependencies")
+		}
+		return found, nil
+	case ecosystemGo, ecosystemNpm:
+		if found[only] == "" {
+			return nil, NewPermanentError(serr.New(fmt.Sprintf("the project doesn't use %s", only)), "no dependencies")
+		}
+		return map[string]string{only: found[only]}, nil
+	}
+	return nil, NewPermanentError(serr.New(fmt.Sprintf("unknown ecosystem %q; use go or npm", only)), "invalid ecosystem")
+}
+
+// runDependencyCommand runs a command in dir, returning its output; a failed command's
+// output comes with an *exec.ExitError
+func runDependencyCommand(ctx context.Context, dir string, timeout time.Duration, name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	configureProcessGroup(cmd)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	err := cmd.Run()
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		return output.String(), NewPermanentError(serr.New(fmt.Sprintf("%s ran over %s and was stopped", name, timeout)), "timeout")
+	case ctx.Err() != nil:
+		return output.String(), serr.New("Dependency command cancelled")
+	}
+	return output.String(), err
+}
+
+// outdatedDependency is a dependency with a newer version, or one deprecated
+type outdatedDependency struct {
+	ecosystem  string
+	name       string
+	current    string
+	update     string // The version "all" updates to: Go's newest, npm's newest in range; "" for none
+	latest     string // npm's newest, when past the range package.json allows
+	indirect   bool
+	dev        bool
+	deprecated string
+}
+
+// listOutdated returns the outdated dependencies of an ecosystem, in the order its tool lists them
+func listOutdated(ctx context.Context, ecosystem, dir string, includeIndirect bool) ([]outdatedDependency, error) {
+	if ecosystem == ecosystemGo {
+		return listOutdatedGo(ctx, dir, includeIndirect)
+	}
+	return listOutdatedNpm(ctx, dir)
+}
+
+// listOutdatedGo asks go list for the modules with newer versions of the same major version.
+// This looks the versions up, through GOPROXY.
+func listOutdatedGo(ctx context.Context, root string, includeIndirect bool) ([]outdatedDependency, error) {
+	output, err := runDependencyCommand(ctx, root, dependencyTimeout, "go", "list", "-m", "-u", "-json", "all")
+	if err != nil {
+		if _, isExit := err.(*exec.ExitError); isExit {
+			return nil, NewPermanentError(serr.New("go list failed:\n"+testFailure(output)), "go list failed")
+		}
+		return nil, err
+	}
+
+	var outdated []outdatedDependency
+	decoder := json.NewDecoder(strings.NewReader(output))
+	for decoder.More() {
+		var module struct {
+			Path       string
+			Version    string
+			Main       bool
+			Indirect   bool
+			Deprecated string
+			Update     *struct{ Version string }
+			Replace    *struct{ Path string }
+		}
+		if err := decoder.Decode(&module); err != nil {
+			return nil, serr.Wrap(err, "failed to read go list output")
+		}
+		if module.Main || module.Replace != nil || (module.Indirect && !includeIndirect) {
+			continue
+		}
+		dep := outdatedDependency{ecosystem: ecosystemGo, name: module.Path, current: module.Version,
+			indirect: module.Indirect, deprecated: module.Deprecated}
+		if module.Update != nil {
+			dep.update = module.Update.Version
+		}
+		if dep.update != "" || dep.deprecated != "" {
+			outdated = append(outdated, dep)
+		}
+	}
+	return outdated, nil
+}
+
+// listOutdatedNpm asks npm outdated for the direct dependencies with newer versions. It
+// exits with 1 when there are some, so the JSON is read whatever the exit status.
+func listOutdatedNpm(ctx context.Context, dir string) ([]outdatedDependency, error) {
+	output, err := runDependencyCommand(ctx, dir, dependencyTimeout, "npm", "outdated", "--json", "--long")
+	if _, isExit := err.(*exec.ExitError); err != nil && !isExit {
+		return nil, err
+	}
+
+	start := strings.Index(output, "{")
+	if start < 0 {
+		if err != nil {
+			return nil, NewPermanentError(serr.New("npm outdated failed:\n"+testFailure(output)), "npm outdated failed")
+		}
+		return nil, nil
+	}
+	var packages map[string]json.RawMessage
+	if err := json.NewDecoder(strings.NewReader(output[start:])).Decode(&packages); err != nil {
+		return nil, serr.Wrap(err, "failed to read npm outdated output")
+	}
+
+	var outdated []outdatedDependency
+	for _, name := range sortedKeys(packages) {
+		var pkg struct {
+			Current string `json:"current"`
+			Wanted  string `json:"wanted"`
+			Latest  string `json:"latest"`
+			Type    string `json:"type"`
+		}
+		if json.Unmarshal(packages[name], &pkg) != nil {
+			continue // Listed once per workspace, which isn't supported
+		}
+		dep := outdatedDependency{ecosystem: ecosystemNpm, name: name, current: pkg.Current, dev: pkg.Type == "devDependencies"}
+		if dep.current == "" {
+			dep.current = "not installed"
+		}
+		if pkg.Wanted != "" && pkg.Wanted != pkg.Current {
+			dep.update = pkg.Wanted
+		}
+		if pkg.Latest != "" && pkg.Latest != pkg.Wanted {
+			dep.latest = pkg.Latest
+		}
+		if dep.update != "" || dep.latest != "" {
+			outdated = append(outdated, dep)
+		}
+	}
+	return outdated, nil
+}
+
+// sortedKeys returns a map's keys in order
+func sortedKeys(m map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// OutdatedDependenciesTool lists the dependencies of the project with newer versions
+type OutdatedDependenciesTool struct{}
+
+// GetDefinition returns the tool definition
+func (t *OutdatedDependenciesTool) GetDefinition() Tool {
+	return Tool{
+		Name: "outdated_dependencies",
+		Description: "List the project's dependencies with newer versions: Go modules (go list -m -u) and npm packages " +
+			"(npm outdated), with the version each would update to and deprecated modules. Go updates stay within the " +
+			"major version; npm updates stay within the range in package.json, with the latest version shown when it " +
+			"is past it. Update them with update_dependency.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"ecosystem": map[string]interface{}{
+					"type":        "string",
+					"enum":        []string{ecosystemGo, ecosystemNpm},
+					"description": "Only list Go modules or npm packages (default: both that the project uses)",
+				},
+				"include_indirect": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Include Go modules required only by other modules (default: false)",
+				},
+			},
+		},
+	}
+}
+
+// Execute lists the outdated dependencies
+func (t *OutdatedDependenciesTool) Execute(input map[string]interface{}) (string, error) {
+	return t.ExecuteContext(context.Background(), input)
+}
+
+// ExecuteContext lists the outdated dependencies, stopping if ctx is cancelled
+func (t *OutdatedDependenciesTool) ExecuteContext(ctx context.Context, input map[string]interface{}) (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", serr.Wrap(err, "failed to get working directory")
+	}
+	only, _ := GetString(input, "ecosystem")
+	ecosystems, err := projectEcosystems(dir, only)
+	if err != nil {
+		return "", err
+	}
+	includeIndirect, _ := GetBool(input, "include_indirect")
+
+	var sections []string
+	counts := []string{}
+	for _, ecosystem := range []string{ecosystemGo, ecosystemNpm} {
+		root, ok := ecosystems[ecosystem]
+		if !ok {
+			continue
+		}
+		outdated, err := listOutdated(ctx, ecosystem, root, includeIndirect)
+		if err != nil {
+			return "", err
+		}
+		counts = append(counts, dependencyCount(ecosystem, len(outdated)))
+		if len(outdated) > 0 {
+			sections = append(sections, outdatedSection(ecosystem, outdated))
+		}
+	}
+
+	if len(sections) == 0 {
+		return fmt.Sprintf("All dependencies are up to date (%s)", strings.Join(counts, ", ")), nil
+	}
+	return fmt.Sprintf("Outdated dependencies: %s\n\n%s\n\nUpdate them with update_dependency; \"all\" takes the updates shown with →",
+		strings.Join(counts, ", "), strings.Join(sections, "\n\n")), nil
+}
+
+// dependencyCount counts an ecosystem's dependencies: "2 Go modules", "1 npm package"
+func dependencyCount(ecosystem string, n int) string {
+	noun := "Go module"
+	if ecosystem == ecosystemNpm {
+		noun = "npm package"
+	}
+	if n != 1 {
+		noun += "s"
+	}
+	return fmt.Sprintf("%d %s", n, noun)
+}
+
+// outdatedSection lays out an ecosystem's outdated dependencies, a line each
+func outdatedSection(ecosystem string, outdated []outdatedDependency) string {
+	var sb strings.Builder
+	if ecosystem == ecosystemGo {
+		sb.WriteString("Go modules (go.mod):\n")
+	} else {
+		sb.WriteString("npm packages (package.json):\n")
+	}
+
+	w := tabwriter.NewWriter(&sb, 0, 4, 2, ' ', 0)
+	for _, dep := range outdated {
+		versions := dep.current
+		if dep.update != "" {
+			versions += " → " + dep.update
+		}
+		var notes []string
+		if dep.indirect {
+			notes = append(notes, "indirect")
+		}
+		if dep.dev {
+			notes = append(notes, "dev")
+		}
+		if dep.latest != "" {
+			if dep.update == "" {
+				notes = append(notes, "latest "+dep.latest+", held back by its range in package.json")
+			} else {
+				notes = append(notes, "latest "+dep.latest)
+			}
+		}
+		if dep.deprecated != "" {
+			notes = append(notes, "deprecated: "+dep.deprecated)
+		}
+		line := "  " + dep.name + "\t" + versions
+		if len(notes) > 0 {
+			line += "\t(" + strings.Join(notes, "; ") + ")"
+		}
+		fmt.Fprintln(w, line)
+	}
+	w.Flush()
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// UpdateDependencyTool updates dependencies one at a time, running the tests after each
+type UpdateDependencyTool struct{}
+
+// dependencyUpdate is what came of updating a dependency
+type dependencyUpdate struct {
+	ecosystem string
+	name      string
+	from, to  string
+	tests     string // The command run, "" for none
+	failure   string // Why the update was undone or broke the tests, with their output
+	broken    bool   // The tests failed
+	reverted  bool
+}
+
+// GetDefinition returns the tool definition
+func (t *UpdateDependencyTool) GetDefinition() Tool {
+	return Tool{
+		Name: "update_dependency",
+		Description: "Update Go modules (go get, then go mod tidy) or npm packages (npm install), one at a time, running " +
+			"the project's tests after each: go build and go test, or the npm test script. An update that breaks the " +
+			"build or tests is undone, restoring go.mod and go.sum or package.json and its lock file, and the failures " +
+			"are reported. \"all\" updates every outdated direct dependency, staying within Go major versions and " +
+			"package.json ranges, as outdated_dependencies lists them.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"dependencies": map[string]interface{}{
+					"type":        "string",
+					"description": "The module or package to update, several separated by spaces or commas, or all. A name may give its version: react@18.3.1",
+				},
+				"version": map[string]interface{}{
+					"type":        "string",
+					"description": "The version to update one dependency to (default: latest)",
+				},
+				"ecosystem": map[string]interface{}{
+					"type":        "string",
+					"enum":        []string{ecosystemGo, ecosystemNpm},
+					"description": "Whether the dependencies are Go modules or npm packages, when the project has both (default: found from go.mod and package.json)",
+				},
+				"test_command": map[string]interface{}{
+					"type":        "string",
+					"description": "The command checking an update, instead of go build and go test or npm test",
+				},
+				"keep_failing": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Keep an update that breaks the tests, to fix the code for it (default: false, undo it)",
+				},
+			},
+			"required": []string{"dependencies"},
+		},
+	}
+}
+
+// Execute updates the dependencies
+func (t *UpdateDependencyTool) Execute(input map[string]interface{}) (string, error) {
+	return t.ExecuteContext(context.Background(), input)
+}
+
+// ExecuteContext updates the dependencies, undoing the update under way if ctx is cancelled
+func (t *UpdateDependencyTool) ExecuteContext(ctx context.Context, input map[string]interface{}) (string, error) {
+	list, _ := GetString(input, "dependencies")
+	names := dependencyListSeparators.Split(strings.TrimSpace(list), -1)
+	if len(names) == 0 || names[0] == "" {
+		return "", NewPermanentError(serr.New("dependencies parameter is required"), "missing dependencies")
+	}
+	version, _ := GetString(input, "version")
+	version = strings.TrimSpace(version)
+	if version != "" && len(names) > 1 {
+		return "", NewPermanentError(serr.New("version applies to one dependency; give the others theirs as name@version"), "ambiguous version")
+	}
+	testCommand, _ := GetString(input, "test_command")
+	keepFailing, _ := GetBool(input, "keep_failing")
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", serr.Wrap(err, "failed to get working directory")
+	}
+	only, _ := GetString(input, "ecosystem")
+	ecosystems, err := projectEcosystems(dir, only)
+	if err != nil {
+		return "", err
+	}
+
+	// Work out what to update, as ecosystem, name and version
+	var targets []outdatedDependency
+	if len(names) == 1 && strings.EqualFold(names[0], "all") {
+		for _, ecosystem := range []string{ecosystemGo, ecosystemNpm} {
+			if root, ok := ecosystems[ecosystem]; ok {
+				outdated, err := listOutdated(ctx, ecosystem, root, false)
+				if err != nil {
+					return "", err
+				}
+				for _, dep := range outdated {
+					if dep.update != "" {
+						targets = append(targets, dep)
+					}
+				}
+			}
+		}
+		if len(targets) == 0 {
+			return "All dependencies are up to date", nil
+		}
+	} else {
+		for _, name := range names {
+			target := outdatedDependency{name: name, update: version}
+			if at := strings.LastIndex(name, "@"); at > 0 {
+				target.name, target.update = name[:at], name[at+1:]
+			}
+			if target.update == "" {
+				target.update = "latest"
+			}
+			target.ecosystem = dependencyEcosystem(ecosystems, target.name)
+			targets = append(targets, target)
+		}
+	}
+
+	var updates []*dependencyUpdate
+	for _, target := range targets {
+		if ctx.Err() != nil {
+			break
+		}
+		root := ecosystems[target.ecosystem]
+		update, err := updateDependency(ctx, root, target, testCommand, keepFailing)
+		if err != nil {
+			return "", err
+		}
+		updates = append(updates, update)
+	}
+	return dependencyUpdateSummary(updates, keepFailing, ctx.Err() != nil)
+}
+
+// dependencyEcosystem picks the ecosystem a named dependency belongs to: the project's only
+// one, or npm when package.json lists it
+func dependencyEcosystem(ecosystems map[string]string, name string) string {
+	if len(ecosystems) == 1 {
+		for ecosystem := range ecosystems {
+			return ecosystem
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(ecosystems[ecosystemNpm], "package.json"))
+	if err == nil {
+		var manifest map[string]json.RawMessage
+		if json.Unmarshal(data, &manifest) == nil {
+			for _, section := range []string{"dependencies", "devDependencies", "optionalDependencies", "peerDependencies"} {
+				var deps map[string]string
+				if json.Unmarshal(manifest[section], &deps) == nil && deps[name] != "" {
+					return ecosystemNpm
+				}
+			}
+		}
+	}
+	return ecosystemGo
+}
+
+// updateDependency updates one dependency in root and runs the tests, undoing the update
+// when it fails or breaks them unless keepFailing
+func updateDependency(ctx context.Context, root string, target outdatedDependency, testCommand string, keepFailing bool) (*dependencyUpdate, error) {
+	update := &dependencyUpdate{ecosystem: target.ecosystem, name: target.name}
+	manifests := []string{"go.mod", "go.sum"}
+	if target.ecosystem == ecosystemNpm {
+		manifests = []string{"package.json", "package-lock.json", "npm-shrinkwrap.json"}
+	}
+	previous := make(map[string][]byte, len(manifests))
+	for _, name := range manifests {
+		path := filepath.Join(root, name)
+		if content, err := os.ReadFile(path); err == nil {
+			previous[path] = content
+		} else {
+			previous[path] = nil
+		}
+	}
+	undo := func() {
+		for path, content := range previous {
+			if content != nil {
+				os.WriteFile(path, content, 0644)
+			} else {
+				os.Remove(path)
+			}
+		}
+		if target.ecosystem == ecosystemNpm {
+			// Bring node_modules back in line with the restored lock file
+			runDependencyCommand(context.Background(), root, dependencyTimeout, "npm", "install")
+		}
+		update.reverted = true
+	}
+
+	update.from = dependencyVersion(ctx, root, target.ecosystem, target.name)
+	var output string
+	var err error
+	if target.ecosystem == ecosystemGo {
+		output, err = runDependencyCommand(ctx, root, dependencyTimeout, "go", "get", target.name+"@"+target.update)
+		if err == nil {
+			var tidy string
+			tidy, err = runDependencyCommand(ctx, root, dependencyTimeout, "go", "mod", "tidy")
+			output += tidy
+		}
+	} else {
+		output, err = runDependencyCommand(ctx, root, dependencyTimeout, "npm", "install", target.name+"@"+target.update)
+	}
+	if err != nil {
+		undo()
+		if _, isExit := err.(*exec.ExitError); !isExit {
+			if ctx.Err() != nil {
+				return update, nil
+			}
+			if _, permanent := err.(*PermanentError); !permanent {
+				return nil, serr.Wrap(err, "failed to update "+target.name)
+			}
+		}
+		update.failure = "couldn't be updated:\n" + testFailure(output)
+		return update, nil
+	}
+
+	update.to = dependencyVersion(ctx, root, target.ecosystem, target.name)
+	if update.to == update.from {
+		return update, nil
+	}
+	defer func() {
+		if !update.reverted {
+			for path := range previous {
+				NotifyFileChange(path, "modify")
+			}
+		}
+	}()
+
+	update.tests = testCommand
+	if update.tests == "" {
+		update.tests = defaultTestCommand(root, target.ecosystem)
+	}
+	if update.tests == "" {
+		return update, nil
+	}
+	output, err = runDependencyCommand(ctx, root, dependencyTestTimeout, "bash", "-c", update.tests)
+	if err != nil {
+		if ctx.Err() != nil {
+			undo()
+			return update, nil
+		}
+		update.broken = true
+		update.failure = testFailure(output)
+		if _, isExit := err.(*exec.ExitError); !isExit {
+			update.failure = err.Error()
+		}
+		if !keepFailing {
+			undo()
+		}
+	}
+	return update, nil
+}
+
+// dependencyVersion returns the version of a dependency in use, or "" when it isn't
+func dependencyVersion(ctx context.Context, root, ecosystem, name string) string {
+	if ecosystem == ecosystemGo {
+		output, err := runDependencyCommand(ctx, root, dependencyTimeout, "go", "list", "-m", "-f", "{{.Version}}", name)
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(output)
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, "node_modules", filepath.FromSlash(name), "package.json"))
+	if err != nil {
+		return ""
+	}
+	var pkg struct {
+		Version string `json:"version"`
+	}
+	json.Unmarshal(data, &pkg)
+	return pkg.Version
+}
+
+// defaultTestCommand is the command checking an update: building and testing a Go module, or
+// npm's test script, else its build script. It is "" when an npm project has neither.
+func defaultTestCommand(root, ecosystem string) string {
+	if ecosystem == ecosystemGo {
+		return "go build ./... && go test ./..."
+	}
+	data, err := os.ReadFile(filepath.Join(root, "package.json"))
+	if err != nil {
+		return ""
+	}
+	var manifest struct {
+		Scripts map[string]string `json:"scripts"`
+	}
+	json.Unmarshal(data, &manifest)
+	if test := manifest.Scripts["test"]; test != "" && test != npmPlaceholderTest {
+		return "npm test"
+	}
+	if manifest.Scripts["build"] != "" {
+		return "npm run build"
+	}
+	return ""
+}
+
+// dependencyUpdateSummary reports the updates, a line each with the failures' output under
+// them. Any failure makes it an error, so a plan step stops on breakage.
+func dependencyUpdateSummary(updates []*dependencyUpdate, keepFailing, cancelled bool) (string, error) {
+	updated, failed := 0, 0
+	var lines []string
+	for _, u := range updates {
+		switch {
+		case u.failure == "" && u.to != "" && u.to == u.from:
+			lines = append(lines, fmt.Sprintf("- %s is already at %s", u.name, u.from))
+		case u.failure == "" && u.reverted:
+			lines = append(lines, fmt.Sprintf("- %s: cancelled, undone", u.name))
+		case u.failure == "":
+			updated++
+			line := fmt.Sprintf("✓ %s %s", u.name, dependencyVersions(u))
+			if u.tests != "" {
+				line += fmt.Sprintf(", `%s` passes", u.tests)
+			} else {
+				line += ", not tested: package.json has no test or build script"
+			}
+			lines = append(lines, line)
+		case u.broken:
+			failed++
+			line := fmt.Sprintf("✗ %s %s breaks `%s`", u.name, dependencyVersions(u), u.tests)
+			if u.reverted {
+				line += ", undone"
+			} else {
+				updated++
+				line += ", kept to be fixed"
+			}
+			lines = append(lines, line+":\n"+indentLines(u.failure, "    "))
+		default:
+			failed++
+			lines = append(lines, fmt.Sprintf("✗ %s %s", u.name, u.failure))
+		}
+	}
+
+	summary := fmt.Sprintf("Updated %d of %d dependencies", updated, len(updates))
+	if failed > 0 {
+		summary += fmt.Sprintf("; %d failed", failed)
+	}
+	if cancelled {
+		summary += "; cancelled"
+	}
+	result := summary + "\n\n" + strings.Join(lines, "\n")
+	if failed > 0 {
+		return "", NewPermanentError(serr.New(result), "update failed")
+	}
+	return result, nil
+}
+
+// dependencyVersions shows an update's versions: "v1.2.0 → v1.4.1"
+func dependencyVersions(u *dependencyUpdate) string {
+	from := u.from
+	if from == "" {
+		from = "(none)"
+	}
+	if u.to == "" {
+		return from
+	}
+	return from + " → " + u.to
+}
+
+// indentLines indents each line of s
+func indentLines(s, indent string) string {
+	return indent + strings.ReplaceAll(strings.TrimRight(s, "\n"), "\n", "\n"+indent)
+}
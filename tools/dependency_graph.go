@@ -0,0 +1,176 @@
+package tools
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/rohanthewiz/serr"
+	"rcode/context"
+)
+
+// Deepest transitive lookup the dependency_graph tool allows
+const maxGraphDepth = 3
+
+// DependencyGraphTool answers which project files a file imports and which import it
+type DependencyGraphTool struct {
+	ContextManager *context.Manager
+}
+
+// GetDefinition returns the tool definition
+func (t *DependencyGraphTool) GetDefinition() Tool {
+	return Tool{
+		Name: "dependency_graph",
+		Description: "Show the import relationships of a project file: which project files it depends on, " +
+			"which files import it, and its external imports. Use before changing a file to find the code affected.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"path": map[string]interface{}{
+					"type":        "string",
+					"description": "The file to look up, relative to the project root or absolute",
+				},
+				"direction": map[string]interface{}{
+					"type":        "string",
+					"enum":        []string{"dependencies", "dependents", "both"},
+					"description": "dependencies: what the file imports; dependents: who imports the file (default: both)",
+					"default":     "both",
+				},
+				"depth": map[string]interface{}{
+					"type":        "integer",
+					"description": "Follow imports transitively up to this many hops (default: 1, max: 3)",
+					"default":     1,
+				},
+			},
+			"required": []string{"path"},
+		},
+	}
+}
+
+// Execute looks up the file in the project's dependency graph
+func (t *DependencyGraphTool) Execute(input map[string]interface{}) (string, error) {
+	if t.ContextManager == nil {
+		return "", serr.New("project context is not available")
+	}
+	graph := t.ContextManager.DependencyGraph()
+	if graph == nil {
+		return "", serr.New("project has not been scanned yet")
+	}
+
+	path, ok := GetString(input, "path")
+	if !ok || path == "" {
+		return "", serr.New("path is required")
+	}
+	path, err := ExpandPath(path)
+	if err != nil {
+		return "", serr.Wrap(err, "failed to expand path")
+	}
+
+	direction := "both"
+	if d, ok := GetString(input, "direction"); ok && d != "" {
+		direction = d
+	}
+	if direction != "dependencies" && direction != "dependents" && direction != "both" {
+		return "", serr.New("direction must be dependencies, dependents or both")
+	}
+
+	depth := 1
+	if d, ok := GetInt(input, "depth"); ok && d > 0 {
+		depth = d
+		if depth > maxGraphDepth {
+			depth = maxGraphDepth
+		}
+	}
+
+	root := t.ContextManager.GetProjectRoot()
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	if !graph.Contains(path) {
+		return fmt.Sprintf("%s has no recorded imports or importers. "+
+			"It may not be a code file, or the project may need rescanning.", relativeTo(root, path)), nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(relativeTo(root, path) + "\n")
+
+	if direction != "dependents" {
+		writeGraphSection(&sb, "Depends on", root, walkGraph(path, depth, graph.Dependencies))
+
+		if external := graph.ExternalImports(path); len(external) > 0 {
+			sb.WriteString(fmt.Sprintf("\nExternal imports (%d):\n", len(external)))
+			for _, imp := range external {
+				sb.WriteString("  " + imp + "\n")
+			}
+		}
+	}
+
+	if direction != "dependencies" {
+		writeGraphSection(&sb, "Imported by", root, walkGraph(path, depth, graph.Dependents))
+	}
+
+	return sb.String(), nil
+}
+
+// graphEntry is a file reached while walking the graph, with its distance from the start
+type graphEntry struct {
+	path string
+	hops int
+}
+
+// walkGraph follows next from start, breadth first, up to depth hops
+func walkGraph(start string, depth int, next func(string) []string) []graphEntry {
+	seen := map[string]bool{start: true}
+	var entries []graphEntry
+	frontier := []string{start}
+
+	for hops := 1; hops <= depth && len(frontier) > 0; hops++ {
+		var following []string
+		for _, path := range frontier {
+			for _, neighbor := range next(path) {
+				if !seen[neighbor] {
+					seen[neighbor] = true
+					entries = append(entries, graphEntry{path: neighbor, hops: hops})
+					following = append(following, neighbor)
+				}
+			}
+		}
+		frontier = following
+	}
+
+	return entries
+}
+
+// writeGraphSection lists the files reached in one direction
+func writeGraphSection(sb *strings.Builder, title, root string, entries []graphEntry) {
+	if len(entries) == 0 {
+		sb.WriteString(fmt.Sprintf("\n%s: no project files\n", title))
+		return
+	}
+
+	sb.WriteString(fmt.Sprintf("\n%s (%d project files):\n", title, len(entries)))
+	for _, entry := range entries {
+		line := "  " + relativeTo(root, entry.path)
+		if entry.hops > 1 {
+			line += fmt.Sprintf(" (%d hops)", entry.hops)
+		}
+		sb.WriteString(line + "\n")
+	}
+}
+
+// relativeTo shortens path to be relative to root when it is inside it
+func relativeTo(root, path string) string {
+	if root == "" {
+		return path
+	}
+	if rel, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+// RegisterDependencyGraphTool adds the dependency_graph tool backed by the given context manager
+func RegisterDependencyGraphTool(registry *Registry, contextManager *context.Manager) {
+	graphTool := &DependencyGraphTool{ContextManager: contextManager}
+	registry.Register(graphTool.GetDefinition(), graphTool)
+}
@@ -0,0 +1,284 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/serr"
+)
+
+const (
+	defaultDiagnosticTimeout = 2 * time.Minute
+	maxDiagnosticOutput      = 8192 // Bytes of a failing check's output reported to the model
+)
+
+// DiagnosticCheck is a command, such as a build or linter, whose failure output
+// describes problems in the project
+type DiagnosticCheck struct {
+	Name       string   `json:"name"`
+	Command    string   `json:"command"`              // Run with sh in the project root
+	Extensions []string `json:"extensions,omitempty"` // Run only when files with these extensions change; empty for any file
+	Timeout    int      `json:"timeout,omitempty"`    // Seconds (default: 120)
+}
+
+// diagnosticsFile is the layout of a project's .rcode/diagnostics.json
+type diagnosticsFile struct {
+	Checks []DiagnosticCheck `json:"checks"`
+}
+
+// DiagnosticsCollector runs the project's checks in the background after tools modify files,
+// and reports what changed in their results so the model can fix its own errors
+type DiagnosticsCollector struct {
+	projectRoot string
+	checks      []DiagnosticCheck
+
+	mu       sync.Mutex
+	pending  map[string]bool // Checks to run once the current run finishes
+	running  bool
+	done     chan struct{}     // Closed when the running checks, and any queued after them, finish
+	results  map[string]string // Output of each check's last run; "" when it passed
+	reported map[string]string // Results the model has been told about
+}
+
+// NewDiagnosticsCollector creates a collector for the checks detected in projectRoot
+// and those listed in configPath, which may not exist
+func NewDiagnosticsCollector(projectRoot, configPath string) (*DiagnosticsCollector, error) {
+	checks := DetectDiagnosticChecks(projectRoot)
+
+	data, err := os.ReadFile(configPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, serr.Wrap(err, "failed to read diagnostics file", "path", configPath)
+	}
+	if err == nil {
+		var file diagnosticsFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, serr.Wrap(err, "invalid diagnostics file", "path", configPath)
+		}
+		for i, check := range file.Checks {
+			if check.Command == "" {
+				return nil, serr.New(fmt.Sprintf("check %d in %s has no command", i+1, configPath))
+			}
+			if check.Name == "" {
+				check.Name = check.Command
+			}
+			checks = append(checks, check)
+		}
+	}
+
+	return &DiagnosticsCollector{
+		projectRoot: projectRoot,
+		checks:      checks,
+		pending:     make(map[string]bool),
+		results:     make(map[string]string),
+		reported:    make(map[string]string),
+	}, nil
+}
+
+// DetectDiagnosticChecks returns the compile checks for the kinds of project found in projectRoot
+func DetectDiagnosticChecks(projectRoot string) []DiagnosticCheck {
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(projectRoot, name))
+		return err == nil
+	}
+
+	var checks []DiagnosticCheck
+	if exists("go.mod") {
+		checks = append(checks, DiagnosticCheck{Name: "go build", Command: "go build ./...", Extensions: []string{".go"}})
+	}
+	if exists("tsconfig.json") {
+		tsc := ""
+		if exists(filepath.Join("node_modules", ".bin", "tsc")) {
+			tsc = filepath.Join("node_modules", ".bin", "tsc")
+		} else if _, err := exec.LookPath("tsc"); err == nil {
+			tsc = "tsc"
+		}
+		if tsc != "" {
+			checks = append(checks, DiagnosticCheck{Name: "tsc", Command: tsc + " --noEmit", Extensions: []string{".ts", ".tsx"}})
+		}
+	}
+	return checks
+}
+
+// Len returns the number of checks
+func (d *DiagnosticsCollector) Len() int {
+	return len(d.checks)
+}
+
+// TrackToolUse starts the checks affected by a successful tool call that modified files
+func (d *DiagnosticsCollector) TrackToolUse(toolUse ToolUse) {
+	var path string
+	switch toolUse.Name {
+	case "write_file", "edit_file", "smart_edit", "replace_snippet", "resolve_conflict", "remove", "rename_symbol":
+		path, _ = GetString(toolUse.Input, "path")
+	case "move":
+		path, _ = GetString(toolUse.Input, "destination")
+	case "move_package":
+		// The package's Go files moved, and those importing it changed
+		if destination, _ := GetString(toolUse.Input, "destination"); destination != "" {
+			path = filepath.Join(destination, "*.go")
+		}
+	case "apply_patch":
+		patch, _ := GetString(toolUse.Input, "patch")
+		for _, path := range PatchPaths(patch) {
+			d.Trigger(path)
+		}
+		return
+	case "generate":
+		for _, path := range GeneratePaths(toolUse.Input, d.projectRoot) {
+			d.Trigger(path)
+		}
+		return
+	default:
+		return
+	}
+	if path != "" {
+		d.Trigger(path)
+	}
+}
+
+// Trigger queues the checks that apply to a changed file and starts running them
+// in the background if they are not already running
+func (d *DiagnosticsCollector) Trigger(path string) {
+	ext := strings.ToLower(filepath.Ext(path))
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, check := range d.checks {
+		if len(check.Extensions) == 0 || containsString(check.Extensions, ext) {
+			d.pending[check.Name] = true
+		}
+	}
+	if len(d.pending) == 0 || d.running {
+		return
+	}
+
+	d.running = true
+	d.done = make(chan struct{})
+	go d.runPending(d.done)
+}
+
+// runPending runs queued checks until none are left, then closes done
+func (d *DiagnosticsCollector) runPending(done chan struct{}) {
+	for {
+		d.mu.Lock()
+		var batch []DiagnosticCheck
+		for _, check := range d.checks {
+			if d.pending[check.Name] {
+				batch = append(batch, check)
+			}
+		}
+		d.pending = make(map[string]bool)
+		if len(batch) == 0 {
+			d.running = false
+			d.mu.Unlock()
+			close(done)
+			return
+		}
+		d.mu.Unlock()
+
+		for _, check := range batch {
+			output := d.run(check)
+			d.mu.Lock()
+			d.results[check.Name] = output
+			d.mu.Unlock()
+		}
+	}
+}
+
+// run executes one check and returns its output if it failed, or "" if it passed
+func (d *DiagnosticsCollector) run(check DiagnosticCheck) string {
+	timeout := defaultDiagnosticTimeout
+	if check.Timeout > 0 {
+		timeout = time.Duration(check.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", check.Command)
+	cmd.Dir = d.projectRoot
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Sprintf("timed out after %s", timeout)
+	}
+	if err == nil {
+		return ""
+	}
+
+	logger.Debug("Diagnostic check failed", "check", check.Name, "error", err.Error())
+	text := strings.TrimSpace(string(output))
+	if text == "" {
+		text = err.Error()
+	}
+	if len(text) > maxDiagnosticOutput {
+		text = text[:maxDiagnosticOutput] + "\n... (truncated)"
+	}
+	return text
+}
+
+// Collect waits up to wait for running checks, then reports the results the model has not
+// seen yet: new or changed failures, and checks that pass again. It returns "" when there is
+// nothing new. Checks still running are reported by a later call.
+func (d *DiagnosticsCollector) Collect(wait time.Duration) string {
+	d.mu.Lock()
+	done := d.done
+	running := d.running
+	d.mu.Unlock()
+
+	if running {
+		select {
+		case <-done:
+		case <-time.After(wait):
+			logger.Info("Diagnostics still running, reporting later")
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	names := make([]string, 0, len(d.results))
+	for name := range d.results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		output := d.results[name]
+		previous, seen := d.reported[name]
+		if output == previous && (seen || output == "") {
+			continue
+		}
+		d.reported[name] = output
+
+		if output == "" {
+			sb.WriteString(fmt.Sprintf("`%s` passes now.\n", d.command(name)))
+		} else {
+			sb.WriteString(fmt.Sprintf("`%s` failed after your changes:\n```\n%s\n```\n", d.command(name), output))
+		}
+	}
+
+	if sb.Len() == 0 {
+		return ""
+	}
+	return "<diagnostics>\n" + sb.String() + "</diagnostics>"
+}
+
+// command returns the command line of the named check
+func (d *DiagnosticsCollector) command(name string) string {
+	for _, check := range d.checks {
+		if check.Name == name {
+			return check.Command
+		}
+	}
+	return name
+}
@@ -165,7 +165,7 @@ func (r *EnhancedRegistry) RegisterWithValidation(tool Tool, executor Executor)
 	if tool.InputSchema != nil {
 		// The validator already has default rules, but we can enhance them
 		// based on the tool's schema if needed
-		logger.Debug("Registered tool with validation: " + tool.Name)
+		logger.Debug("Registered tool with validation", "tool", tool.Name)
 	}
 }
 
@@ -292,6 +292,15 @@ func DefaultEnhancedRegistry() *EnhancedRegistry {
 	bashTool := &BashTool{}
 	registry.RegisterWithValidation(bashTool.GetDefinition(), bashTool)
 
+	runBackgroundTool := &RunBackgroundTool{}
+	registry.RegisterWithValidation(runBackgroundTool.GetDefinition(), runBackgroundTool)
+
+	listProcessesTool := &ListProcessesTool{}
+	registry.RegisterWithValidation(listProcessesTool.GetDefinition(), listProcessesTool)
+
+	stopProcessTool := &StopProcessTool{}
+	registry.RegisterWithValidation(stopProcessTool.GetDefinition(), stopProcessTool)
+
 	editTool := &EditFileTool{}
 	registry.RegisterWithValidation(editTool.GetDefinition(), editTool)
 
@@ -345,6 +354,43 @@ func DefaultEnhancedRegistry() *EnhancedRegistry {
 	gitMergeTool := &GitMergeTool{}
 	registry.RegisterWithValidation(gitMergeTool.GetDefinition(), gitMergeTool)
 
+	gitRemoteTool := &GitRemoteTool{}
+	registry.RegisterWithValidation(gitRemoteTool.GetDefinition(), gitRemoteTool)
+
+	gitFetchTool := &GitFetchTool{}
+	registry.RegisterWithValidation(gitFetchTool.GetDefinition(), gitFetchTool)
+
+	gitTagTool := &GitTagTool{}
+	registry.RegisterWithValidation(gitTagTool.GetDefinition(), gitTagTool)
+
+	gitCherryPickTool := &GitCherryPickTool{}
+	registry.RegisterWithValidation(gitCherryPickTool.GetDefinition(), gitCherryPickTool)
+
+	gitRevertTool := &GitRevertTool{}
+	registry.RegisterWithValidation(gitRevertTool.GetDefinition(), gitRevertTool)
+
+	gitCommitMessageTool := &GitCommitMessageTool{}
+	registry.RegisterWithValidation(gitCommitMessageTool.GetDefinition(), gitCommitMessageTool)
+
+	gitConflictsTool := &GitConflictsTool{}
+	registry.RegisterWithValidation(gitConflictsTool.GetDefinition(), gitConflictsTool)
+
+	resolveConflictTool := &ResolveConflictTool{}
+	registry.RegisterWithValidation(resolveConflictTool.GetDefinition(), resolveConflictTool)
+
+	// Register GitHub/GitLab tools
+	createPullRequestTool := &CreatePullRequestTool{}
+	registry.RegisterWithValidation(createPullRequestTool.GetDefinition(), createPullRequestTool)
+
+	listIssuesTool := &ListIssuesTool{}
+	registry.RegisterWithValidation(listIssuesTool.GetDefinition(), listIssuesTool)
+
+	getIssueTool := &GetIssueTool{}
+	registry.RegisterWithValidation(getIssueTool.GetDefinition(), getIssueTool)
+
+	commentPullRequestTool := &CommentPullRequestTool{}
+	registry.RegisterWithValidation(commentPullRequestTool.GetDefinition(), commentPullRequestTool)
+
 	// Web tools
 	webSearchTool := &WebSearchTool{}
 	registry.RegisterWithValidation(webSearchTool.GetDefinition(), webSearchTool)
@@ -355,7 +401,7 @@ func DefaultEnhancedRegistry() *EnhancedRegistry {
 	// Add default hooks
 	registry.AddBeforeExecuteHook(func(toolName string, params map[string]interface{}) error {
 		// Log tool execution
-		logger.Debug("Executing tool: " + toolName)
+		logger.Debug("Executing tool", "tool", toolName)
 		return nil
 	})
 
@@ -384,7 +430,10 @@ func DefaultEnhancedRegistry() *EnhancedRegistry {
 	registry.SetToolRetryPolicy("git_push", NetworkRetryPolicy)
 	registry.SetToolRetryPolicy("git_pull", NetworkRetryPolicy)
 	registry.SetToolRetryPolicy("git_fetch", NetworkRetryPolicy)
+	registry.SetToolRetryPolicy("git_tag", NetworkRetryPolicy)
 	registry.SetToolRetryPolicy("git_clone", NetworkRetryPolicy)
+	registry.SetToolRetryPolicy("list_issues", NetworkRetryPolicy)
+	registry.SetToolRetryPolicy("get_issue", NetworkRetryPolicy)
 
 	// File system tools get lighter retry
 	registry.SetToolRetryPolicy("read_file", FileSystemRetryPolicy)
@@ -400,6 +449,9 @@ func DefaultEnhancedRegistry() *EnhancedRegistry {
 	registry.SetToolRetryPolicy("git_diff", FileSystemRetryPolicy)
 	registry.SetToolRetryPolicy("git_add", FileSystemRetryPolicy)
 	registry.SetToolRetryPolicy("git_commit", FileSystemRetryPolicy)
+	registry.SetToolRetryPolicy("git_cherry_pick", FileSystemRetryPolicy)
+	registry.SetToolRetryPolicy("git_revert", FileSystemRetryPolicy)
+	registry.SetToolRetryPolicy("git_conflicts", FileSystemRetryPolicy)
 
 	// Bash commands don't retry by default (could be destructive)
 	// But users can configure specific retry if needed
@@ -1,7 +1,12 @@
 package tools
 
 import (
+	"context"
 	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -319,3 +324,176 @@ func TestRetryPolicyConfiguration(t *testing.T) {
 		}
 	}
 }
+
+// projectToolLimits loads tool limits as a session does, with the project's
+// .rcode/tool_limits.json holding the given JSON and no user file
+func projectToolLimits(t *testing.T, limitsJSON string) *ToolLimits {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".rcode"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	projectFile := filepath.Join(dir, ".rcode", "tool_limits.json")
+	if err := os.WriteFile(projectFile, []byte(limitsJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+	limits, err := LoadToolLimits(filepath.Join(dir, "missing.json"), projectFile)
+	if err != nil {
+		t.Fatalf("LoadToolLimits: %v", err)
+	}
+	return limits
+}
+
+// blockingTool runs until its context is done or it is released, counting its calls
+type blockingTool struct {
+	calls   atomic.Int32
+	started chan string // Receives each call's session as it starts
+	release chan struct{}
+}
+
+func (b *blockingTool) Execute(input map[string]interface{}) (string, error) {
+	return b.ExecuteContext(context.Background(), input)
+}
+
+func (b *blockingTool) ExecuteContext(ctx context.Context, input map[string]interface{}) (string, error) {
+	b.calls.Add(1)
+	if b.started != nil {
+		sessionID, _ := GetString(input, "_sessionId")
+		b.started <- sessionID
+	}
+	select {
+	case <-b.release:
+		return "done", nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// TestToolLimitTimeout tests that a call is stopped at its tool's timeout and not retried
+func TestToolLimitTimeout(t *testing.T) {
+	registry := NewEnhancedRegistry()
+	tool := &blockingTool{release: make(chan struct{})}
+	registry.RegisterWithValidation(Tool{Name: "slow_tool", InputSchema: map[string]interface{}{"type": "object"}}, tool)
+	registry.SetLimits(projectToolLimits(t, `{"tools": {"slow_tool": {"timeout": 1, "retries": 3}}}`))
+
+	start := time.Now()
+	result, err := registry.Execute(ToolUse{ID: "timeout-1", Name: "slow_tool", Input: map[string]interface{}{}})
+	elapsed := time.Since(start)
+
+	var timeout *toolTimeoutError
+	if !errors.As(err, &timeout) {
+		t.Fatalf("Expected a timeout error, got %v", err)
+	}
+	if result == nil || !strings.Contains(result.Content, "slow_tool timed out after 1s") {
+		t.Errorf("Expected the result to report the timeout, got %+v", result)
+	}
+	if elapsed < time.Second || elapsed > 3*time.Second {
+		t.Errorf("Expected the call to stop after about 1s, took %v", elapsed)
+	}
+	if calls := tool.calls.Load(); calls != 1 {
+		t.Errorf("Expected a timed out call not to be retried, got %d calls", calls)
+	}
+}
+
+// TestToolLimitRetries tests the retry counts set in .rcode/tool_limits.json
+func TestToolLimitRetries(t *testing.T) {
+	defaultPolicy := NetworkRetryPolicy
+	NetworkRetryPolicy.InitialDelay = time.Millisecond
+	NetworkRetryPolicy.Jitter = false
+	t.Cleanup(func() { NetworkRetryPolicy = defaultPolicy })
+
+	limitsJSON := `{"tools": {"flaky_*": {"retries": 1}, "flaky_fetch": {"retries": 3}, "flaky_write": {"retries": 0}}}`
+	tests := []struct {
+		name      string
+		tool      string
+		failUntil int
+		err       error
+		wantCalls int
+		wantOK    bool
+	}{
+		{"tool's own count", "flaky_fetch", 100, NewRetryableError(errors.New("connection reset"), "network"), 4, false},
+		{"succeeds on a retry", "flaky_fetch", 2, NewRetryableError(errors.New("connection reset"), "network"), 3, true},
+		{"pattern's count", "flaky_read", 100, NewRetryableError(errors.New("connection reset"), "network"), 2, false},
+		{"no retries", "flaky_write", 100, NewRetryableError(errors.New("connection reset"), "network"), 1, false},
+		{"permanent error", "flaky_fetch", 100, NewPermanentError(errors.New("not found"), "missing"), 1, false},
+		{"built-in count", "other_tool", 100, NewRetryableError(errors.New("connection reset"), "network"), 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := NewEnhancedRegistry()
+			mockTool := &MockTool{name: tt.tool, failUntil: tt.failUntil, failWithErr: tt.err}
+			registry.RegisterWithValidation(mockTool.GetDefinition(), mockTool)
+			registry.SetLimits(projectToolLimits(t, limitsJSON))
+
+			_, err := registry.Execute(ToolUse{ID: "retry-" + tt.tool, Name: tt.tool, Input: map[string]interface{}{}})
+			if tt.wantOK && err != nil {
+				t.Errorf("Expected success, got %v", err)
+			}
+			if !tt.wantOK && err == nil {
+				t.Error("Expected an error")
+			}
+			if mockTool.executions != tt.wantCalls {
+				t.Errorf("Expected %d calls, got %d", tt.wantCalls, mockTool.executions)
+			}
+		})
+	}
+}
+
+// TestToolLimitConcurrency tests that a session's calls wait for a free slot, while
+// another session's don't
+func TestToolLimitConcurrency(t *testing.T) {
+	registry := NewEnhancedRegistry()
+	tool := &blockingTool{started: make(chan string, 3), release: make(chan struct{})}
+	registry.RegisterWithValidation(Tool{Name: "one_at_a_time", InputSchema: map[string]interface{}{"type": "object"}}, tool)
+	registry.SetLimits(projectToolLimits(t, `{"tools": {"one_at_a_time": {"concurrency": 1}}}`))
+
+	done := make(chan error, 3)
+	execute := func(sessionID string) {
+		_, err := registry.ExecuteContext(context.Background(), ToolUse{
+			Name:  "one_at_a_time",
+			Input: map[string]interface{}{"_sessionId": sessionID},
+		})
+		done <- err
+	}
+	waitStarted := func(want string) {
+		t.Helper()
+		select {
+		case sessionID := <-tool.started:
+			if sessionID != want {
+				t.Fatalf("Expected a call in %s to start, got one in %s", want, sessionID)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Expected a call in %s to start", want)
+		}
+	}
+
+	go execute("session-a")
+	waitStarted("session-a")
+
+	// The session's second call waits, but another session's call runs
+	go execute("session-a")
+	go execute("session-b")
+	waitStarted("session-b")
+	select {
+	case sessionID := <-tool.started:
+		t.Fatalf("Expected the second call in session-a to wait, but one in %s started", sessionID)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Finishing the calls frees the slot for the waiting one
+	tool.release <- struct{}{}
+	tool.release <- struct{}{}
+	waitStarted("session-a")
+	tool.release <- struct{}{}
+	for i := 0; i < 3; i++ {
+		if err := <-done; err != nil {
+			t.Errorf("Expected the calls to succeed, got %v", err)
+		}
+	}
+
+	toolSemaphoresMu.Lock()
+	defer toolSemaphoresMu.Unlock()
+	if len(toolSemaphores) != 0 {
+		t.Errorf("Expected the semaphores to be dropped once the calls finish, %d remain", len(toolSemaphores))
+	}
+}
@@ -0,0 +1,524 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"rcode/config"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// ForgeItem is an issue or pull (merge) request on a forge
+type ForgeItem struct {
+	Number    int
+	Title     string
+	State     string
+	Author    string
+	URL       string
+	Body      string
+	Labels    []string
+	CreatedAt string
+	Comments  int
+}
+
+// ForgeComment is a comment on an issue or pull request
+type ForgeComment struct {
+	Author    string
+	Body      string
+	CreatedAt string
+}
+
+// PullRequestOptions describes a pull request to open
+type PullRequestOptions struct {
+	Title string
+	Body  string
+	Head  string // Branch with the changes
+	Base  string // Branch to merge into
+	Draft bool
+}
+
+// Forge is the API of a code hosting service for one repository.
+// GitHub and GitLab are supported; on GitLab, pull requests are merge requests.
+type Forge interface {
+	Name() string
+	DefaultBranch() (string, error)
+	CreatePullRequest(opts PullRequestOptions) (*ForgeItem, error)
+	ListIssues(state string, labels []string, limit int) ([]ForgeItem, error)
+	GetIssue(number int) (*ForgeItem, []ForgeComment, error)
+	CommentOnPullRequest(number int, body string) (string, error)
+}
+
+// ForgeForRepo returns the forge hosting the repository at path, found from the remote's URL.
+// repo, as "owner/name" (or "group/subgroup/name" on GitLab), overrides the repository on that host.
+func ForgeForRepo(path, remote, repo string) (Forge, error) {
+	if remote == "" {
+		remote = "origin"
+	}
+
+	cmd := exec.Command("git", "remote", "get-url", remote)
+	cmd.Dir = path
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		errMsg := stderr.String()
+		if strings.Contains(errMsg, "not a git repository") {
+			return nil, NewPermanentError(serr.New(fmt.Sprintf("Not a git repository: %s", path)), "invalid repository")
+		}
+		return nil, NewPermanentError(serr.New(fmt.Sprintf("Unknown remote '%s': %s", remote, strings.TrimSpace(errMsg))), "unknown remote")
+	}
+
+	host, project, err := parseRemoteURL(strings.TrimSpace(stdout.String()))
+	if err != nil {
+		return nil, NewPermanentError(err, "unsupported remote")
+	}
+	if repo != "" {
+		project = strings.Trim(repo, "/")
+	}
+
+	cfg := config.Get()
+	switch {
+	case host == forgeHost(cfg.GitHubAPIURL):
+		if cfg.GitHubToken == "" {
+			return nil, NewPermanentError(serr.New("No GitHub token configured; set RCODE_GITHUB_TOKEN or GITHUB_TOKEN"), "missing token")
+		}
+		return newGitHubForge(cfg.GitHubAPIURL, cfg.GitHubToken, project), nil
+	case host == forgeHost(cfg.GitLabURL) || strings.Contains(host, "gitlab"):
+		if cfg.GitLabToken == "" {
+			return nil, NewPermanentError(serr.New("No GitLab token configured; set RCODE_GITLAB_TOKEN or GITLAB_TOKEN"), "missing token")
+		}
+		baseURL := cfg.GitLabURL
+		if host != forgeHost(cfg.GitLabURL) {
+			baseURL = "https://" + host
+		}
+		return newGitLabForge(baseURL+"/api/v4", cfg.GitLabToken, project), nil
+	default:
+		return nil, NewPermanentError(serr.New(fmt.Sprintf("Remote host %s is not a known GitHub or GitLab host; set RCODE_GITHUB_API_URL or RCODE_GITLAB_URL for self-hosted instances", host)), "unsupported remote")
+	}
+}
+
+// forgeHost returns the host serving repositories for an API or instance URL
+func forgeHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	if u.Hostname() == "api.github.com" {
+		return "github.com"
+	}
+	return u.Hostname()
+}
+
+// parseRemoteURL splits a remote URL, in URL or scp-like (git@host:owner/repo) form,
+// into its host and repository path
+func parseRemoteURL(raw string) (string, string, error) {
+	var host, path string
+	if strings.Contains(raw, "://") {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return "", "", serr.Wrap(err, "invalid remote URL")
+		}
+		host, path = u.Hostname(), u.Path
+	} else if at := strings.Index(raw, ":"); at > 0 {
+		host, path = raw[:at], raw[at+1:]
+		if i := strings.LastIndex(host, "@"); i >= 0 {
+			host = host[i+1:]
+		}
+	}
+
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	if host == "" || !strings.Contains(path, "/") {
+		return "", "", serr.New(fmt.Sprintf("Remote URL %s does not name a hosted repository", raw))
+	}
+	return host, path, nil
+}
+
+// forgeClient makes authenticated JSON requests to a forge API
+type forgeClient struct {
+	name    string
+	baseURL string
+	headers map[string]string
+	http    *http.Client
+}
+
+// do sends a request and decodes the JSON response into out, when out is not nil
+func (c *forgeClient) do(method, path string, query url.Values, body, out interface{}) error {
+	reqURL := c.baseURL + path
+	if len(query) > 0 {
+		reqURL += "?" + query.Encode()
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return NewPermanentError(serr.Wrap(err, "failed to encode request"), "invalid request")
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, reqURL, reqBody)
+	if err != nil {
+		return NewPermanentError(serr.Wrap(err, "failed to create request"), "invalid request")
+	}
+	req.Header.Set("User-Agent", "RCode")
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range c.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return WrapNetworkError(serr.Wrap(err, fmt.Sprintf("%s request failed", c.name)))
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return WrapNetworkError(serr.Wrap(err, "failed to read response body"))
+	}
+	if resp.StatusCode >= 400 {
+		return c.statusError(resp, respBody)
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return NewPermanentError(serr.Wrap(err, fmt.Sprintf("unexpected %s response", c.name)), "invalid response")
+		}
+	}
+	return nil
+}
+
+// statusError classifies a failed API response, including the forge's own error message
+func (c *forgeClient) statusError(resp *http.Response, body []byte) error {
+	msg := fmt.Sprintf("%s API error: %s", c.name, resp.Status)
+	var apiErr struct {
+		Message interface{}   `json:"message"`
+		Error   string        `json:"error"`
+		Errors  []interface{} `json:"errors"` // Objects with a message on GitHub, strings elsewhere
+	}
+	if json.Unmarshal(body, &apiErr) == nil {
+		if apiErr.Message != nil {
+			msg += fmt.Sprintf(": %v", apiErr.Message)
+		} else if apiErr.Error != "" {
+			msg += ": " + apiErr.Error
+		}
+		for _, e := range apiErr.Errors {
+			if detail, ok := e.(map[string]interface{}); ok && detail["message"] != nil {
+				e = detail["message"]
+			}
+			msg += fmt.Sprintf("; %v", e)
+		}
+	}
+	httpErr := serr.New(msg)
+
+	switch {
+	case resp.StatusCode == 429 || (resp.StatusCode == 403 && resp.Header.Get("X-RateLimit-Remaining") == "0"):
+		retryAfter := 60
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			retryAfter = seconds
+		}
+		return NewRateLimitError(httpErr, retryAfter)
+	case resp.StatusCode == 401 || resp.StatusCode == 403:
+		return NewPermanentError(serr.New(msg+" (check that the token is valid and has access to the repository)"), "unauthorized")
+	case resp.StatusCode == 404:
+		return NewPermanentError(httpErr, "not found")
+	case resp.StatusCode >= 500:
+		return NewRetryableError(httpErr, "server error")
+	default:
+		return NewPermanentError(httpErr, "client error")
+	}
+}
+
+// newForgeHTTPClient returns the HTTP client used for forge requests
+func newForgeHTTPClient() *http.Client {
+	return &http.Client{Timeout: 30 * time.Second}
+}
+
+// gitHubForge talks to the GitHub REST API
+type gitHubForge struct {
+	client *forgeClient
+	repo   string // owner/name
+}
+
+func newGitHubForge(apiURL, token, repo string) *gitHubForge {
+	return &gitHubForge{
+		client: &forgeClient{
+			name:    "GitHub",
+			baseURL: strings.TrimRight(apiURL, "/"),
+			headers: map[string]string{
+				"Authorization":        "Bearer " + token,
+				"Accept":               "application/vnd.github+json",
+				"X-GitHub-Api-Version": "2022-11-28",
+			},
+			http: newForgeHTTPClient(),
+		},
+		repo: repo,
+	}
+}
+
+// gitHubIssue is an issue or pull request as GitHub returns it
+type gitHubIssue struct {
+	Number  int    `json:"number"`
+	Title   string `json:"title"`
+	State   string `json:"state"`
+	HTMLURL string `json:"html_url"`
+	Body    string `json:"body"`
+	User    struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	Labels []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+	CreatedAt   string      `json:"created_at"`
+	Comments    int         `json:"comments"`
+	PullRequest interface{} `json:"pull_request"`
+}
+
+func (i gitHubIssue) item() ForgeItem {
+	item := ForgeItem{
+		Number: i.Number, Title: i.Title, State: i.State, Author: i.User.Login,
+		URL: i.HTMLURL, Body: i.Body, CreatedAt: i.CreatedAt, Comments: i.Comments,
+	}
+	for _, label := range i.Labels {
+		item.Labels = append(item.Labels, label.Name)
+	}
+	return item
+}
+
+func (g *gitHubForge) Name() string {
+	return "GitHub"
+}
+
+func (g *gitHubForge) DefaultBranch() (string, error) {
+	var repo struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if err := g.client.do("GET", "/repos/"+g.repo, nil, nil, &repo); err != nil {
+		return "", err
+	}
+	return repo.DefaultBranch, nil
+}
+
+func (g *gitHubForge) CreatePullRequest(opts PullRequestOptions) (*ForgeItem, error) {
+	body := map[string]interface{}{
+		"title": opts.Title,
+		"body":  opts.Body,
+		"head":  opts.Head,
+		"base":  opts.Base,
+		"draft": opts.Draft,
+	}
+	var pr gitHubIssue
+	if err := g.client.do("POST", "/repos/"+g.repo+"/pulls", nil, body, &pr); err != nil {
+		return nil, err
+	}
+	item := pr.item()
+	return &item, nil
+}
+
+func (g *gitHubForge) ListIssues(state string, labels []string, limit int) ([]ForgeItem, error) {
+	query := url.Values{"state": {state}, "per_page": {strconv.Itoa(limit)}}
+	if len(labels) > 0 {
+		query.Set("labels", strings.Join(labels, ","))
+	}
+
+	var issues []gitHubIssue
+	if err := g.client.do("GET", "/repos/"+g.repo+"/issues", query, nil, &issues); err != nil {
+		return nil, err
+	}
+
+	var items []ForgeItem
+	for _, issue := range issues {
+		// The issues endpoint includes pull requests
+		if issue.PullRequest == nil {
+			items = append(items, issue.item())
+		}
+	}
+	return items, nil
+}
+
+func (g *gitHubForge) GetIssue(number int) (*ForgeItem, []ForgeComment, error) {
+	var issue gitHubIssue
+	path := fmt.Sprintf("/repos/%s/issues/%d", g.repo, number)
+	if err := g.client.do("GET", path, nil, nil, &issue); err != nil {
+		return nil, nil, err
+	}
+
+	var comments []struct {
+		Body string `json:"body"`
+		User struct {
+			Login string `json:"login"`
+		} `json:"user"`
+		CreatedAt string `json:"created_at"`
+	}
+	if err := g.client.do("GET", path+"/comments", url.Values{"per_page": {"100"}}, nil, &comments); err != nil {
+		return nil, nil, err
+	}
+
+	item := issue.item()
+	var result []ForgeComment
+	for _, c := range comments {
+		result = append(result, ForgeComment{Author: c.User.Login, Body: c.Body, CreatedAt: c.CreatedAt})
+	}
+	return &item, result, nil
+}
+
+func (g *gitHubForge) CommentOnPullRequest(number int, body string) (string, error) {
+	var comment struct {
+		HTMLURL string `json:"html_url"`
+	}
+	// Pull requests share the issue comment thread
+	path := fmt.Sprintf("/repos/%s/issues/%d/comments", g.repo, number)
+	if err := g.client.do("POST", path, nil, map[string]string{"body": body}, &comment); err != nil {
+		return "", err
+	}
+	return comment.HTMLURL, nil
+}
+
+// gitLabForge talks to the GitLab REST API
+type gitLabForge struct {
+	client  *forgeClient
+	project string // Escaped project path, used as its ID
+}
+
+func newGitLabForge(apiURL, token, project string) *gitLabForge {
+	return &gitLabForge{
+		client: &forgeClient{
+			name:    "GitLab",
+			baseURL: strings.TrimRight(apiURL, "/"),
+			headers: map[string]string{"PRIVATE-TOKEN": token},
+			http:    newForgeHTTPClient(),
+		},
+		project: url.PathEscape(project),
+	}
+}
+
+// gitLabIssue is an issue or merge request as GitLab returns it
+type gitLabIssue struct {
+	IID         int    `json:"iid"`
+	Title       string `json:"title"`
+	State       string `json:"state"`
+	WebURL      string `json:"web_url"`
+	Description string `json:"description"`
+	Author      struct {
+		Username string `json:"username"`
+	} `json:"author"`
+	Labels         []string `json:"labels"`
+	CreatedAt      string   `json:"created_at"`
+	UserNotesCount int      `json:"user_notes_count"`
+}
+
+func (i gitLabIssue) item() ForgeItem {
+	return ForgeItem{
+		Number: i.IID, Title: i.Title, State: i.State, Author: i.Author.Username, URL: i.WebURL,
+		Body: i.Description, Labels: i.Labels, CreatedAt: i.CreatedAt, Comments: i.UserNotesCount,
+	}
+}
+
+func (g *gitLabForge) Name() string {
+	return "GitLab"
+}
+
+func (g *gitLabForge) DefaultBranch() (string, error) {
+	var project struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if err := g.client.do("GET", "/projects/"+g.project, nil, nil, &project); err != nil {
+		return "", err
+	}
+	return project.DefaultBranch, nil
+}
+
+func (g *gitLabForge) CreatePullRequest(opts PullRequestOptions) (*ForgeItem, error) {
+	title := opts.Title
+	if opts.Draft && !strings.HasPrefix(title, "Draft:") {
+		title = "Draft: " + title
+	}
+	body := map[string]interface{}{
+		"title":         title,
+		"description":   opts.Body,
+		"source_branch": opts.Head,
+		"target_branch": opts.Base,
+	}
+	var mr gitLabIssue
+	if err := g.client.do("POST", "/projects/"+g.project+"/merge_requests", nil, body, &mr); err != nil {
+		return nil, err
+	}
+	item := mr.item()
+	return &item, nil
+}
+
+func (g *gitLabForge) ListIssues(state string, labels []string, limit int) ([]ForgeItem, error) {
+	// GitLab calls open issues "opened"
+	if state == "open" {
+		state = "opened"
+	}
+	query := url.Values{"state": {state}, "per_page": {strconv.Itoa(limit)}}
+	if state == "all" {
+		query.Del("state")
+	}
+	if len(labels) > 0 {
+		query.Set("labels", strings.Join(labels, ","))
+	}
+
+	var issues []gitLabIssue
+	if err := g.client.do("GET", "/projects/"+g.project+"/issues", query, nil, &issues); err != nil {
+		return nil, err
+	}
+
+	items := make([]ForgeItem, len(issues))
+	for i, issue := range issues {
+		items[i] = issue.item()
+	}
+	return items, nil
+}
+
+func (g *gitLabForge) GetIssue(number int) (*ForgeItem, []ForgeComment, error) {
+	var issue gitLabIssue
+	path := fmt.Sprintf("/projects/%s/issues/%d", g.project, number)
+	if err := g.client.do("GET", path, nil, nil, &issue); err != nil {
+		return nil, nil, err
+	}
+
+	var notes []struct {
+		Body   string `json:"body"`
+		System bool   `json:"system"`
+		Author struct {
+			Username string `json:"username"`
+		} `json:"author"`
+		CreatedAt string `json:"created_at"`
+	}
+	query := url.Values{"per_page": {"100"}, "sort": {"asc"}}
+	if err := g.client.do("GET", path+"/notes", query, nil, &notes); err != nil {
+		return nil, nil, err
+	}
+
+	item := issue.item()
+	var result []ForgeComment
+	for _, note := range notes {
+		// System notes record events such as label changes, not discussion
+		if !note.System {
+			result = append(result, ForgeComment{Author: note.Author.Username, Body: note.Body, CreatedAt: note.CreatedAt})
+		}
+	}
+	return &item, result, nil
+}
+
+func (g *gitLabForge) CommentOnPullRequest(number int, body string) (string, error) {
+	// Notes have no page of their own, so there is no URL to return
+	path := fmt.Sprintf("/projects/%s/merge_requests/%d/notes", g.project, number)
+	return "", g.client.do("POST", path, nil, map[string]string{"body": body}, nil)
+}
@@ -0,0 +1,332 @@
+package tools
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// forgeRepoProperties are the schema properties that pick the repository on the forge
+func forgeRepoProperties(properties map[string]interface{}) map[string]interface{} {
+	properties["path"] = map[string]interface{}{
+		"type":        "string",
+		"description": "Repository path (defaults to current directory)",
+	}
+	properties["remote"] = map[string]interface{}{
+		"type":        "string",
+		"description": "Remote whose URL identifies the GitHub or GitLab repository (defaults to 'origin')",
+	}
+	properties["repo"] = map[string]interface{}{
+		"type":        "string",
+		"description": "Repository on the remote's host as 'owner/name', when it differs from the remote's",
+	}
+	return properties
+}
+
+// forgeFromInput returns the forge for the repository described by the tool input
+func forgeFromInput(input map[string]interface{}) (Forge, string, error) {
+	path, ok := GetString(input, "path")
+	if !ok || path == "" {
+		path = "."
+	}
+	remote, _ := GetString(input, "remote")
+	repo, _ := GetString(input, "repo")
+
+	forge, err := ForgeForRepo(path, remote, repo)
+	return forge, path, err
+}
+
+// formatForgeItem writes an issue or pull request heading and its details
+func formatForgeItem(sb *strings.Builder, item ForgeItem) {
+	sb.WriteString(fmt.Sprintf("#%d %s [%s]\n", item.Number, item.Title, item.State))
+	sb.WriteString(fmt.Sprintf("Author: %s  Created: %s  Comments: %d\n", item.Author, formatForgeTime(item.CreatedAt), item.Comments))
+	if len(item.Labels) > 0 {
+		sb.WriteString("Labels: " + strings.Join(item.Labels, ", ") + "\n")
+	}
+	sb.WriteString(item.URL + "\n")
+}
+
+// formatForgeTime shortens an API timestamp to minutes, leaving other text as it is
+func formatForgeTime(timestamp string) string {
+	if t, err := time.Parse(time.RFC3339, timestamp); err == nil {
+		return t.UTC().Format("2006-01-02 15:04")
+	}
+	if timestamp == "" {
+		return "unknown"
+	}
+	return timestamp
+}
+
+// CreatePullRequestTool opens a pull request (a merge request on GitLab)
+type CreatePullRequestTool struct{}
+
+// GetDefinition returns the tool definition for creating a pull request
+func (t *CreatePullRequestTool) GetDefinition() Tool {
+	return Tool{
+		Name:        "create_pull_request",
+		Description: "Open a pull request on GitHub (or a merge request on GitLab) for a pushed branch. Push the branch with git_push first.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": forgeRepoProperties(map[string]interface{}{
+				"title": map[string]interface{}{
+					"type":        "string",
+					"description": "Pull request title",
+				},
+				"body": map[string]interface{}{
+					"type":        "string",
+					"description": "Pull request description (Markdown)",
+				},
+				"head": map[string]interface{}{
+					"type":        "string",
+					"description": "Branch with the changes (defaults to the current branch)",
+				},
+				"base": map[string]interface{}{
+					"type":        "string",
+					"description": "Branch to merge into (defaults to the repository's default branch)",
+				},
+				"draft": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Open the pull request as a draft",
+				},
+			}),
+			"required": []string{"title"},
+		},
+	}
+}
+
+// Execute opens the pull request
+func (t *CreatePullRequestTool) Execute(input map[string]interface{}) (string, error) {
+	title, ok := GetString(input, "title")
+	if !ok || strings.TrimSpace(title) == "" {
+		return "", NewPermanentError(serr.New("title parameter is required"), "invalid parameters")
+	}
+
+	forge, path, err := forgeFromInput(input)
+	if err != nil {
+		return "", err
+	}
+
+	opts := PullRequestOptions{Title: title}
+	opts.Body, _ = GetString(input, "body")
+	opts.Draft, _ = input["draft"].(bool)
+
+	opts.Head, _ = GetString(input, "head")
+	if opts.Head == "" {
+		cmd := exec.Command("git", "branch", "--show-current")
+		cmd.Dir = path
+
+		var stdout bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Run()
+		opts.Head = strings.TrimSpace(stdout.String())
+		if opts.Head == "" {
+			return "", NewPermanentError(serr.New("Not on a branch; set head to the branch to open the pull request for"), "invalid parameters")
+		}
+	}
+
+	opts.Base, _ = GetString(input, "base")
+	if opts.Base == "" {
+		// Errors keep their retry classification, so they are returned as they are
+		if opts.Base, err = forge.DefaultBranch(); err != nil {
+			return "", err
+		}
+	}
+	if opts.Base == opts.Head {
+		return "", NewPermanentError(serr.New(fmt.Sprintf("head and base are both '%s'; open the pull request from a feature branch", opts.Head)), "invalid parameters")
+	}
+
+	pr, err := forge.CreatePullRequest(opts)
+	if err != nil {
+		return "", err
+	}
+
+	kind := "pull request"
+	if forge.Name() == "GitLab" {
+		kind = "merge request"
+	}
+	return fmt.Sprintf("Opened %s #%d on %s: %s\n%s -> %s\n%s", kind, pr.Number, forge.Name(), pr.Title, opts.Head, opts.Base, pr.URL), nil
+}
+
+// ListIssuesTool lists a repository's issues
+type ListIssuesTool struct{}
+
+// GetDefinition returns the tool definition for listing issues
+func (t *ListIssuesTool) GetDefinition() Tool {
+	return Tool{
+		Name:        "list_issues",
+		Description: "List the issues of the repository's GitHub or GitLab project",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": forgeRepoProperties(map[string]interface{}{
+				"state": map[string]interface{}{
+					"type":        "string",
+					"enum":        []string{"open", "closed", "all"},
+					"description": "Which issues to list (defaults to 'open')",
+				},
+				"labels": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string"},
+					"description": "Only list issues with all of these labels",
+				},
+				"limit": map[string]interface{}{
+					"type":        "integer",
+					"description": "Maximum number of issues (default 20, max 100)",
+				},
+			}),
+			"required": []string{},
+		},
+	}
+}
+
+// Execute lists the issues
+func (t *ListIssuesTool) Execute(input map[string]interface{}) (string, error) {
+	state, _ := GetString(input, "state")
+	switch state {
+	case "":
+		state = "open"
+	case "open", "closed", "all":
+	default:
+		return "", NewPermanentError(serr.New(fmt.Sprintf("Unknown state '%s'; use open, closed or all", state)), "invalid parameters")
+	}
+
+	var labels []string
+	if list, ok := input["labels"].([]interface{}); ok {
+		for _, l := range list {
+			if label, ok := l.(string); ok && label != "" {
+				labels = append(labels, label)
+			}
+		}
+	}
+
+	limit := 20
+	if l, ok := GetInt(input, "limit"); ok && l > 0 {
+		limit = min(l, 100)
+	}
+
+	forge, _, err := forgeFromInput(input)
+	if err != nil {
+		return "", err
+	}
+
+	issues, err := forge.ListIssues(state, labels, limit)
+	if err != nil {
+		return "", err
+	}
+	if len(issues) == 0 {
+		return fmt.Sprintf("No %s issues found", state), nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%d %s issues on %s:\n\n", len(issues), state, forge.Name()))
+	for _, issue := range issues {
+		formatForgeItem(&sb, issue)
+		sb.WriteString("\n")
+	}
+	return sb.String(), nil
+}
+
+// GetIssueTool reads one issue with its comments
+type GetIssueTool struct{}
+
+// GetDefinition returns the tool definition for reading an issue
+func (t *GetIssueTool) GetDefinition() Tool {
+	return Tool{
+		Name:        "get_issue",
+		Description: "Read an issue of the repository's GitHub or GitLab project, with its description and comments",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": forgeRepoProperties(map[string]interface{}{
+				"number": map[string]interface{}{
+					"type":        "integer",
+					"description": "Issue number",
+				},
+			}),
+			"required": []string{"number"},
+		},
+	}
+}
+
+// Execute reads the issue
+func (t *GetIssueTool) Execute(input map[string]interface{}) (string, error) {
+	number, ok := GetInt(input, "number")
+	if !ok || number < 1 {
+		return "", NewPermanentError(serr.New("number parameter is required"), "invalid parameters")
+	}
+
+	forge, _, err := forgeFromInput(input)
+	if err != nil {
+		return "", err
+	}
+
+	issue, comments, err := forge.GetIssue(number)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	formatForgeItem(&sb, *issue)
+	if body := strings.TrimSpace(issue.Body); body != "" {
+		sb.WriteString("\n" + body + "\n")
+	}
+	for _, comment := range comments {
+		sb.WriteString(fmt.Sprintf("\n--- %s (%s)\n%s\n", comment.Author, formatForgeTime(comment.CreatedAt), strings.TrimSpace(comment.Body)))
+	}
+	return sb.String(), nil
+}
+
+// CommentPullRequestTool posts a comment on a pull request
+type CommentPullRequestTool struct{}
+
+// GetDefinition returns the tool definition for commenting on a pull request
+func (t *CommentPullRequestTool) GetDefinition() Tool {
+	return Tool{
+		Name:        "comment_pull_request",
+		Description: "Post a comment on a pull request on GitHub (or a merge request on GitLab)",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": forgeRepoProperties(map[string]interface{}{
+				"number": map[string]interface{}{
+					"type":        "integer",
+					"description": "Pull request (or merge request) number",
+				},
+				"body": map[string]interface{}{
+					"type":        "string",
+					"description": "Comment text (Markdown)",
+				},
+			}),
+			"required": []string{"number", "body"},
+		},
+	}
+}
+
+// Execute posts the comment
+func (t *CommentPullRequestTool) Execute(input map[string]interface{}) (string, error) {
+	number, ok := GetInt(input, "number")
+	if !ok || number < 1 {
+		return "", NewPermanentError(serr.New("number parameter is required"), "invalid parameters")
+	}
+	body, ok := GetString(input, "body")
+	if !ok || strings.TrimSpace(body) == "" {
+		return "", NewPermanentError(serr.New("body parameter is required"), "invalid parameters")
+	}
+
+	forge, _, err := forgeFromInput(input)
+	if err != nil {
+		return "", err
+	}
+
+	commentURL, err := forge.CommentOnPullRequest(number, body)
+	if err != nil {
+		return "", err
+	}
+
+	result := fmt.Sprintf("Commented on #%d on %s", number, forge.Name())
+	if commentURL != "" {
+		result += "\n" + commentURL
+	}
+	return result, nil
+}
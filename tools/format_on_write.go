@@ -0,0 +1,217 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rohanthewiz/logger"
+	"rcode/diff"
+)
+
+const (
+	formatTimeout   = 30 * time.Second
+	maxFormatOutput = 4096 // Bytes of formatter or linter output reported back
+)
+
+// formatTools are the tools whose written files are formatted afterwards
+var formatTools = map[string]bool{
+	"write_file":      true,
+	"edit_file":       true,
+	"smart_edit":      true,
+	"replace_snippet": true,
+	"apply_patch":     true,
+	"generate":        true,
+}
+
+// FormatCommand is an external formatter or linter for a set of file extensions.
+// Args may contain {file}, replaced by the absolute path of the written file.
+// Commands run in the file's directory.
+type FormatCommand struct {
+	Name       string
+	Extensions []string
+	Command    string
+	Args       []string
+}
+
+// defaultFormatters rewrite files in place; the first one installed for an extension is used
+var defaultFormatters = []FormatCommand{
+	{Name: "goimports", Extensions: []string{".go"}, Command: "goimports", Args: []string{"-w", "{file}"}},
+	{Name: "gofmt", Extensions: []string{".go"}, Command: "gofmt", Args: []string{"-w", "{file}"}},
+	{Name: "prettier", Extensions: []string{".js", ".jsx", ".mjs", ".cjs", ".ts", ".tsx", ".css", ".scss", ".less",
+		".json", ".md", ".html", ".vue", ".yaml", ".yml"}, Command: "prettier", Args: []string{"--write", "{file}"}},
+	{Name: "black", Extensions: []string{".py"}, Command: "black", Args: []string{"-q", "{file}"}},
+	{Name: "rustfmt", Extensions: []string{".rs"}, Command: "rustfmt", Args: []string{"{file}"}},
+	{Name: "shfmt", Extensions: []string{".sh", ".bash"}, Command: "shfmt", Args: []string{"-w", "{file}"}},
+}
+
+// defaultLinters check files without changing them; the first one installed for an extension is used
+var defaultLinters = []FormatCommand{
+	{Name: "go vet", Extensions: []string{".go"}, Command: "go", Args: []string{"vet", "."}},
+	{Name: "eslint", Extensions: []string{".js", ".jsx", ".mjs", ".cjs", ".ts", ".tsx"}, Command: "eslint", Args: []string{"{file}"}},
+	{Name: "ruff", Extensions: []string{".py"}, Command: "ruff", Args: []string{"check", "{file}"}},
+}
+
+// FormatPipeline formats, and optionally lints, files after the write tools change them.
+// What the formatter changed is reported as a diff so the model sees the project's style.
+type FormatPipeline struct {
+	projectRoot string
+	lint        bool
+	formatters  []FormatCommand
+	linters     []FormatCommand
+}
+
+// NewFormatPipeline creates a pipeline with the default formatters, and linters when lint is set
+func NewFormatPipeline(projectRoot string, lint bool) *FormatPipeline {
+	return &FormatPipeline{
+		projectRoot: projectRoot,
+		lint:        lint,
+		formatters:  defaultFormatters,
+		linters:     defaultLinters,
+	}
+}
+
+// Apply formats the files written by toolUse and appends what changed, and any lint
+// problems, to the result. Other tools and failed calls are left alone.
+func (p *FormatPipeline) Apply(toolUse ToolUse, result *ToolResult) *ToolResult {
+	if result == nil || !formatTools[toolUse.Name] {
+		return result
+	}
+
+	var paths []string
+	if toolUse.Name == "apply_patch" {
+		patch, _ := GetString(toolUse.Input, "patch")
+		paths = PatchPaths(patch)
+	} else if toolUse.Name == "generate" {
+		paths = GeneratePaths(toolUse.Input, p.projectRoot)
+	} else if path, ok := GetString(toolUse.Input, "path"); ok && path != "" {
+		paths = append(paths, path)
+	}
+
+	for _, path := range paths {
+		if expanded, err := ExpandPath(path); err == nil {
+			path = expanded
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(p.projectRoot, path)
+		}
+		if _, err := os.Stat(path); err != nil {
+			continue // Deleted by the tool
+		}
+
+		if note := p.Run(path); note != "" {
+			result.Content += "\n\n" + note
+		}
+	}
+	return result
+}
+
+// Run formats and lints path, returning a report for the model, or "" when there is nothing to say
+func (p *FormatPipeline) Run(path string) string {
+	var notes []string
+
+	if formatter, bin := p.find(p.formatters, path); formatter != nil {
+		if note := p.format(*formatter, bin, path); note != "" {
+			notes = append(notes, note)
+		}
+	}
+
+	if p.lint {
+		if linter, bin := p.find(p.linters, path); linter != nil {
+			if output, err := p.run(*linter, bin, path); err != nil {
+				notes = append(notes, fmt.Sprintf("[%s reported problems]\n```\n%s\n```", linter.Name, output))
+			}
+		}
+	}
+
+	return strings.Join(notes, "\n\n")
+}
+
+// format runs formatter on path and describes the changes it made
+func (p *FormatPipeline) format(formatter FormatCommand, bin, path string) string {
+	before, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+
+	if output, err := p.run(formatter, bin, path); err != nil {
+		if output == "" {
+			output = err.Error()
+		}
+		return fmt.Sprintf("[%s failed]\n```\n%s\n```", formatter.Name, output)
+	}
+
+	after, err := os.ReadFile(path)
+	if err != nil || string(after) == string(before) {
+		return ""
+	}
+
+	preview, err := diff.NewDiffService().GeneratePreview(string(before), string(after), path)
+	if err != nil {
+		logger.LogErr(err, "failed to diff formatted file", "path", path)
+		return fmt.Sprintf("[Reformatted by %s]", formatter.Name)
+	}
+	name := filepath.Base(path)
+	unified := diff.FormatUnified(preview.Hunks, name, name+" (formatted)")
+	return fmt.Sprintf("[Reformatted by %s]\n```diff\n%s```", formatter.Name, truncateFormatOutput(unified))
+}
+
+// run executes a formatter or linter on path and returns its output
+func (p *FormatPipeline) run(command FormatCommand, bin, path string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), formatTimeout)
+	defer cancel()
+
+	args := make([]string, len(command.Args))
+	for i, arg := range command.Args {
+		args[i] = strings.ReplaceAll(arg, "{file}", path)
+	}
+
+	cmd := exec.CommandContext(ctx, bin, args...)
+	cmd.Dir = filepath.Dir(path)
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Sprintf("timed out after %s", formatTimeout), ctx.Err()
+	}
+	return truncateFormatOutput(strings.TrimSpace(string(output))), err
+}
+
+// find returns the first command for path's extension that is installed, with its binary.
+// Binaries in the project's node_modules/.bin are preferred over those on the PATH.
+func (p *FormatPipeline) find(commands []FormatCommand, path string) (*FormatCommand, string) {
+	ext := strings.ToLower(filepath.Ext(path))
+	for i, command := range commands {
+		if !containsString(command.Extensions, ext) {
+			continue
+		}
+		local := filepath.Join(p.projectRoot, "node_modules", ".bin", command.Command)
+		if info, err := os.Stat(local); err == nil && !info.IsDir() {
+			return &commands[i], local
+		}
+		if bin, err := exec.LookPath(command.Command); err == nil {
+			return &commands[i], bin
+		}
+	}
+	return nil, ""
+}
+
+// containsString reports whether list holds s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// truncateFormatOutput keeps the start of long output
+func truncateFormatOutput(output string) string {
+	if len(output) > maxFormatOutput {
+		return output[:maxFormatOutput] + "\n... (truncated)\n"
+	}
+	return output
+}
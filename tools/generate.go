@@ -0,0 +1,284 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"rcode/config"
+	"rcode/scaffold"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// LoadTemplates returns the templates generate can create: the built-in ones, then the
+// user's, then the project's .rcode/templates, a later template replacing one of the same name
+func LoadTemplates(projectRoot string) ([]*scaffold.Template, error) {
+	return scaffold.Load(config.Get().TemplatesDir, filepath.Join(projectRoot, ".rcode", "templates"))
+}
+
+// RegisterGenerateTool adds generate, for the templates given, to the registry
+func RegisterGenerateTool(registry *Registry, templates []*scaffold.Template, projectRoot string) {
+	if len(templates) == 0 {
+		return
+	}
+	tool := &GenerateTool{templates: templates, projectRoot: projectRoot}
+	registry.Register(tool.GetDefinition(), tool)
+}
+
+// GenerateTool creates the files of a project template, all or nothing
+type GenerateTool struct {
+	templates   []*scaffold.Template
+	projectRoot string
+}
+
+// generatedFile is a template's rendered file, and what was there before
+type generatedFile struct {
+	rel      string // As the template names it, relative to the directory generated into
+	path     string // Resolved path
+	content  string
+	existed  bool
+	mode     os.FileMode
+	original []byte
+}
+
+// GetDefinition returns the tool definition, listing the templates and their variables
+func (t *GenerateTool) GetDefinition() Tool {
+	names := make([]string, 0, len(t.templates))
+	var list strings.Builder
+	for _, tmpl := range t.templates {
+		names = append(names, tmpl.Name)
+		fmt.Fprintf(&list, "\n- %s: %s", tmpl.Name, tmpl.Description)
+		for _, v := range tmpl.Variables {
+			fmt.Fprintf(&list, "\n  - %s", v.Name)
+			switch {
+			case v.Required:
+				list.WriteString(" (required)")
+			case v.Default != "":
+				fmt.Fprintf(&list, " (default: %s)", v.Default)
+			}
+			if v.Description != "" {
+				list.WriteString(": " + v.Description)
+			}
+		}
+	}
+
+	return Tool{
+		Name: "generate",
+		Description: "Create the files of a project template in one call, filled in from its variables, to start a new " +
+			"service, command or component the way the project starts them. Every file is rendered and checked before any " +
+			"is written; if one already exists and overwrite is not set, or a write fails, no file is changed. Set dry_run " +
+			"to see the files without writing them. Edit the generated files afterwards as usual. The templates:" + list.String(),
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"template": map[string]interface{}{
+					"type":        "string",
+					"description": "The template to generate",
+					"enum":        names,
+				},
+				"variables": map[string]interface{}{
+					"type":                 "object",
+					"description":          "The template's variables, by name",
+					"additionalProperties": map[string]interface{}{"type": "string"},
+				},
+				"directory": map[string]interface{}{
+					"type":        "string",
+					"description": "Directory to generate into (default: the project root)",
+				},
+				"overwrite": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Replace files that already exist (default: false)",
+				},
+				"dry_run": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Return the rendered files without writing them (default: false)",
+				},
+			},
+			"required": []string{"template"},
+		},
+	}
+}
+
+// Execute renders the template, then writes every file, restoring them if any write fails
+func (t *GenerateTool) Execute(input map[string]interface{}) (string, error) {
+	tmpl, dir, files, err := renderGenerate(t.templates, t.projectRoot, input)
+	if err != nil {
+		return "", err
+	}
+	overwrite, _ := GetBool(input, "overwrite")
+	if err := readReplacedFiles(dir, files, overwrite); err != nil {
+		return "", err
+	}
+
+	if dryRun, _ := GetBool(input, "dry_run"); dryRun {
+		return generateSummary(tmpl, dir, files, true), nil
+	}
+	if err := writeGeneratedFiles(files); err != nil {
+		return "", err
+	}
+	return generateSummary(tmpl, dir, files, false), nil
+}
+
+// GeneratePaths returns the files a generate call writes, or nil if it can't be rendered
+func GeneratePaths(input map[string]interface{}, projectRoot string) []string {
+	templates, err := LoadTemplates(projectRoot)
+	if err != nil {
+		return nil
+	}
+	_, _, files, err := renderGenerate(templates, projectRoot, input)
+	if err != nil {
+		return nil
+	}
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		paths = append(paths, file.path)
+	}
+	return paths
+}
+
+// renderGenerate renders the call's template, returning it, the directory it is generated
+// into and its files
+func renderGenerate(templates []*scaffold.Template, projectRoot string, input map[string]interface{}) (*scaffold.Template, string, []*generatedFile, error) {
+	name, _ := GetString(input, "template")
+	tmpl := scaffold.Find(templates, name)
+	if tmpl == nil {
+		names := make([]string, 0, len(templates))
+		for _, candidate := range templates {
+			names = append(names, candidate.Name)
+		}
+		return nil, "", nil, NewPermanentError(serr.New(fmt.Sprintf("unknown template %q; the templates are %s",
+			name, strings.Join(names, ", "))), "unknown template")
+	}
+
+	values := make(map[string]string)
+	if vars, ok := input["variables"].(map[string]interface{}); ok {
+		for key, value := range vars {
+			if value != nil {
+				values[key] = fmt.Sprint(value)
+			}
+		}
+	}
+	rendered, err := tmpl.Render(values)
+	if err != nil {
+		return nil, "", nil, NewPermanentError(err, "template not rendered")
+	}
+
+	dir, _ := GetString(input, "directory")
+	if dir, err = ExpandPath(dir); err != nil {
+		return nil, "", nil, serr.Wrap(err, "failed to expand path")
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(projectRoot, dir)
+	}
+
+	files := make([]*generatedFile, 0, len(rendered))
+	for _, r := range rendered {
+		files = append(files, &generatedFile{rel: r.Path, path: filepath.Join(dir, filepath.FromSlash(r.Path)), content: r.Content, mode: 0644})
+	}
+	return tmpl, dir, files, nil
+}
+
+// readReplacedFiles keeps the content of the files that already exist, to restore them if
+// a write fails. They are refused unless the call overwrites them.
+func readReplacedFiles(dir string, files []*generatedFile, overwrite bool) error {
+	var existing []string
+	for _, file := range files {
+		info, err := os.Stat(file.path)
+		if err != nil {
+			continue
+		}
+		if info.IsDir() {
+			return NewPermanentError(serr.New(file.rel+" is a directory; no files were generated"), "path is a directory")
+		}
+		if !overwrite {
+			existing = append(existing, file.rel)
+			continue
+		}
+		if file.original, err = os.ReadFile(file.path); err != nil {
+			return WrapFileSystemError(serr.Wrap(err, "failed to read file", "path", file.path))
+		}
+		file.existed, file.mode = true, info.Mode().Perm()
+	}
+	if len(existing) > 0 {
+		verb := "exists"
+		if len(existing) > 1 {
+			verb = "exist"
+		}
+		return NewPermanentError(serr.New(fmt.Sprintf("no files were generated: %s already %s in %s; "+
+			"generate elsewhere, or set overwrite to replace them", strings.Join(existing, ", "), verb, dir)), "files exist")
+	}
+	return nil
+}
+
+// writeGeneratedFiles writes every file, undoing the earlier writes if one fails
+func writeGeneratedFiles(files []*generatedFile) error {
+	var done []*generatedFile
+	for _, file := range files {
+		err := os.MkdirAll(filepath.Dir(file.path), 0755)
+		if err == nil {
+			err = os.WriteFile(file.path, []byte(file.content), file.mode)
+		}
+		if err != nil {
+			for i := len(done) - 1; i >= 0; i-- {
+				restoreGeneratedFile(done[i])
+			}
+			restoreGeneratedFile(file)
+			return WrapFileSystemError(serr.New("no files were generated, changes were rolled back: " + err.Error()))
+		}
+		done = append(done, file)
+	}
+
+	for _, file := range files {
+		if file.existed {
+			NotifyFileChange(file.path, "modified")
+		} else {
+			NotifyFileChange(file.path, "created")
+		}
+	}
+	return nil
+}
+
+// restoreGeneratedFile puts a file back the way it was before the template was generated
+func restoreGeneratedFile(file *generatedFile) {
+	if file.existed {
+		os.WriteFile(file.path, file.original, file.mode)
+	} else {
+		os.Remove(file.path)
+	}
+}
+
+// generateSummary lists the files written, with line counts. A dry run shows their content.
+func generateSummary(tmpl *scaffold.Template, dir string, files []*generatedFile, dryRun bool) string {
+	sorted := append([]*generatedFile(nil), files...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].rel < sorted[j].rel })
+
+	total := 0
+	var sb strings.Builder
+	for _, file := range sorted {
+		lines := strings.Count(file.content, "\n")
+		if file.content != "" && !strings.HasSuffix(file.content, "\n") {
+			lines++
+		}
+		total += lines
+
+		status := "A"
+		if file.existed {
+			status = "M"
+		}
+		fmt.Fprintf(&sb, "  %s %s (+%d)\n", status, file.rel, lines)
+	}
+
+	if dryRun {
+		for _, file := range sorted {
+			fmt.Fprintf(&sb, "\n--- %s\n%s", file.rel, file.content)
+			if !strings.HasSuffix(file.content, "\n") {
+				sb.WriteString("\n")
+			}
+		}
+		return fmt.Sprintf("Would generate %d file(s) from %s in %s (+%d)\n%s", len(files), tmpl.Name, dir, total, strings.TrimRight(sb.String(), "\n"))
+	}
+	return fmt.Sprintf("Generated %d file(s) from %s in %s (+%d)\n%s", len(files), tmpl.Name, dir, total, strings.TrimRight(sb.String(), "\n"))
+}
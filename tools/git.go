@@ -3,6 +3,7 @@ package tools
 import (
 	"bytes"
 	"fmt"
+	"os"
 	"os/exec"
 	"strings"
 
@@ -609,6 +610,19 @@ func (t *GitCommitTool) Execute(input map[string]interface{}) (string, error) {
 	return result, nil
 }
 
+// gitNetworkError classifies the stderr of a git command that talks to a remote.
+// Connection and authentication failures are retryable; other errors return nil.
+func gitNetworkError(errMsg string, operation string) error {
+	if strings.Contains(errMsg, "Could not read from remote repository") {
+		return NewRetryableError(serr.New("Failed to connect to remote repository. Check your authentication and network connection"), "network error")
+	}
+	if strings.Contains(errMsg, "Connection refused") || strings.Contains(errMsg, "Connection timed out") ||
+		strings.Contains(errMsg, "Could not resolve host") || strings.Contains(errMsg, "Network is unreachable") {
+		return NewRetryableError(serr.New(fmt.Sprintf("Network error during %s: %s", operation, errMsg)), "network error")
+	}
+	return nil
+}
+
 // GitPushTool implements git push functionality
 type GitPushTool struct{}
 
@@ -727,12 +741,8 @@ func (t *GitPushTool) Execute(input map[string]interface{}) (string, error) {
 		if strings.Contains(errMsg, "not a git repository") {
 			return "", NewPermanentError(serr.New(fmt.Sprintf("Not a git repository: %s", path)), "invalid repository")
 		}
-		if strings.Contains(errMsg, "Could not read from remote repository") {
-			return "", NewRetryableError(serr.New("Failed to connect to remote repository. Check your authentication and network connection"), "network error")
-		}
-		if strings.Contains(errMsg, "Connection refused") || strings.Contains(errMsg, "Connection timed out") ||
-			strings.Contains(errMsg, "Could not resolve host") || strings.Contains(errMsg, "Network is unreachable") {
-			return "", NewRetryableError(serr.New(fmt.Sprintf("Network error during push: %s", errMsg)), "network error")
+		if netErr := gitNetworkError(errMsg, "push"); netErr != nil {
+			return "", netErr
 		}
 		if strings.Contains(errMsg, "failed to push") || strings.Contains(errMsg, "rejected") {
 			// Include the full error for push failures as they often contain important info
@@ -870,19 +880,16 @@ func (t *GitPullTool) Execute(input map[string]interface{}) (string, error) {
 		if strings.Contains(errMsg, "not a git repository") {
 			return "", NewPermanentError(serr.New(fmt.Sprintf("Not a git repository: %s", path)), "invalid repository")
 		}
-		if strings.Contains(errMsg, "Could not read from remote repository") {
-			return "", NewRetryableError(serr.New("Failed to connect to remote repository. Check your authentication and network connection"), "network error")
-		}
-		if strings.Contains(errMsg, "Connection refused") || strings.Contains(errMsg, "Connection timed out") ||
-			strings.Contains(errMsg, "Could not resolve host") || strings.Contains(errMsg, "Network is unreachable") {
-			return "", NewRetryableError(serr.New(fmt.Sprintf("Network error during pull: %s", errMsg)), "network error")
+		if netErr := gitNetworkError(errMsg, "pull"); netErr != nil {
+			return "", netErr
 		}
-		if strings.Contains(errMsg, "Automatic merge failed") {
+		// git reports conflicts on stdout
+		if strings.Contains(errMsg+stdout.String(), "Automatic merge failed") {
 			// Merge conflict - provide helpful information
 			conflictInfo := "\n\nMERGE CONFLICT detected!\n"
 			conflictInfo += "You need to:\n"
-			conflictInfo += "1. Resolve conflicts in the affected files\n"
-			conflictInfo += "2. Stage the resolved files with git_add\n"
+			conflictInfo += "1. Review the conflicts with git_conflicts and resolve them with resolve_conflict\n"
+			conflictInfo += "2. Stage any files resolved by hand with git_add (resolve_conflict stages the files it finishes)\n"
 			conflictInfo += "3. Complete the merge with git_commit\n\n"
 			conflictInfo += "Affected files:\n"
 
@@ -1286,12 +1293,13 @@ func (t *GitMergeTool) Execute(input map[string]interface{}) (string, error) {
 		if strings.Contains(errMsg, "not something we can merge") {
 			return "", NewPermanentError(serr.New(fmt.Sprintf("Cannot merge: '%s' is not a valid branch or commit", branch)), "invalid branch")
 		}
-		if strings.Contains(errMsg, "Automatic merge failed") || strings.Contains(errMsg, "CONFLICT") {
+		// git reports conflicts on stdout
+		if output := errMsg + stdout.String(); strings.Contains(output, "Automatic merge failed") || strings.Contains(output, "CONFLICT") {
 			// Merge conflict - provide helpful information
 			conflictInfo := fmt.Sprintf("MERGE CONFLICT while merging '%s' into '%s'\n\n", branch, currentBranch)
 			conflictInfo += "You need to:\n"
-			conflictInfo += "1. Resolve conflicts in the affected files\n"
-			conflictInfo += "2. Stage the resolved files with git_add\n"
+			conflictInfo += "1. Review the conflicts with git_conflicts and resolve them with resolve_conflict\n"
+			conflictInfo += "2. Stage any files resolved by hand with git_add (resolve_conflict stages the files it finishes)\n"
 			conflictInfo += "3. Complete the merge with git_merge --continue\n"
 			conflictInfo += "   (or abort with git_merge --abort)\n\n"
 			conflictInfo += "Conflicted files:\n"
@@ -1346,3 +1354,759 @@ func (t *GitMergeTool) Execute(input map[string]interface{}) (string, error) {
 
 	return result, nil
 }
+
+// GitRemoteTool implements git remote functionality
+type GitRemoteTool struct{}
+
+// GetDefinition returns the tool definition for git remote
+func (t *GitRemoteTool) GetDefinition() Tool {
+	return Tool{
+		Name:        "git_remote",
+		Description: "List, add, remove or rename remotes, or change a remote's URL",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"path": map[string]interface{}{
+					"type":        "string",
+					"description": "Repository path (defaults to current directory)",
+				},
+				"action": map[string]interface{}{
+					"type":        "string",
+					"enum":        []string{"list", "add", "remove", "rename", "set_url"},
+					"description": "What to do (defaults to 'list')",
+				},
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "Remote name (required for add, remove, rename and set_url)",
+				},
+				"url": map[string]interface{}{
+					"type":        "string",
+					"description": "Remote URL (required for add and set_url)",
+				},
+				"new_name": map[string]interface{}{
+					"type":        "string",
+					"description": "New remote name (required for rename)",
+				},
+			},
+			"required": []string{},
+		},
+	}
+}
+
+// Execute runs git remote command
+func (t *GitRemoteTool) Execute(input map[string]interface{}) (string, error) {
+	path, ok := GetString(input, "path")
+	if !ok || path == "" {
+		path = "."
+	}
+
+	action, _ := GetString(input, "action")
+	if action == "" {
+		action = "list"
+	}
+	name, _ := GetString(input, "name")
+	url, _ := GetString(input, "url")
+	newName, _ := GetString(input, "new_name")
+
+	// Build git command
+	var args []string
+	var done string
+	switch action {
+	case "list":
+		args = []string{"remote", "-v"}
+	case "add":
+		if name == "" || url == "" {
+			return "", NewPermanentError(serr.New("name and url are required to add a remote"), "invalid parameters")
+		}
+		args = []string{"remote", "add", name, url}
+		done = fmt.Sprintf("Added remote '%s' (%s)", name, url)
+	case "remove":
+		if name == "" {
+			return "", NewPermanentError(serr.New("name is required to remove a remote"), "invalid parameters")
+		}
+		args = []string{"remote", "remove", name}
+		done = fmt.Sprintf("Removed remote '%s' and its remote-tracking branches", name)
+	case "rename":
+		if name == "" || newName == "" {
+			return "", NewPermanentError(serr.New("name and new_name are required to rename a remote"), "invalid parameters")
+		}
+		args = []string{"remote", "rename", name, newName}
+		done = fmt.Sprintf("Renamed remote '%s' to '%s'", name, newName)
+	case "set_url":
+		if name == "" || url == "" {
+			return "", NewPermanentError(serr.New("name and url are required to set a remote's URL"), "invalid parameters")
+		}
+		args = []string{"remote", "set-url", name, url}
+		done = fmt.Sprintf("Set URL of remote '%s' to %s", name, url)
+	default:
+		return "", NewPermanentError(serr.New(fmt.Sprintf("Unknown action '%s'; use list, add, remove, rename or set_url", action)), "invalid parameters")
+	}
+
+	// Execute git command
+	cmd := exec.Command("git", args...)
+	cmd.Dir = path
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		errMsg := stderr.String()
+		if strings.Contains(errMsg, "not a git repository") {
+			return "", NewPermanentError(serr.New(fmt.Sprintf("Not a git repository: %s", path)), "invalid repository")
+		}
+		if strings.Contains(errMsg, "already exists") {
+			return "", NewPermanentError(serr.New(fmt.Sprintf("Remote already exists: %s", strings.TrimSpace(errMsg))), "remote exists")
+		}
+		if strings.Contains(errMsg, "No such remote") {
+			return "", NewPermanentError(serr.New(fmt.Sprintf("No such remote: '%s'", name)), "unknown remote")
+		}
+		return "", WrapFileSystemError(serr.Wrap(err, fmt.Sprintf("Git remote failed: %s", errMsg)))
+	}
+
+	if action == "list" {
+		if stdout.Len() == 0 {
+			return "No remotes configured", nil
+		}
+		return stdout.String(), nil
+	}
+
+	// Show the remotes as they now are
+	listCmd := exec.Command("git", "remote", "-v")
+	listCmd.Dir = path
+
+	var listOut bytes.Buffer
+	listCmd.Stdout = &listOut
+	if listCmd.Run() == nil && listOut.Len() > 0 {
+		done += "\n\nRemotes:\n" + listOut.String()
+	}
+
+	return done, nil
+}
+
+// GitFetchTool implements git fetch functionality
+type GitFetchTool struct{}
+
+// GetDefinition returns the tool definition for git fetch
+func (t *GitFetchTool) GetDefinition() Tool {
+	return Tool{
+		Name:        "git_fetch",
+		Description: "Download objects and refs from another repository without changing the working tree",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"path": map[string]interface{}{
+					"type":        "string",
+					"description": "Repository path (defaults to current directory)",
+				},
+				"remote": map[string]interface{}{
+					"type":        "string",
+					"description": "Remote name (defaults to 'origin')",
+				},
+				"refspecs": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string"},
+					"description": "Refs to fetch, e.g. ['main'] or ['refs/heads/feature:refs/remotes/origin/feature'] (defaults to the remote's configured refspecs)",
+				},
+				"all": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Fetch all remotes",
+				},
+				"prune": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Remove remote-tracking refs that no longer exist on the remote",
+				},
+				"tags": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Fetch all tags",
+				},
+				"dry_run": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Show what would be fetched without changing anything",
+				},
+			},
+			"required": []string{},
+		},
+	}
+}
+
+// Execute runs git fetch command
+func (t *GitFetchTool) Execute(input map[string]interface{}) (string, error) {
+	path, ok := GetString(input, "path")
+	if !ok || path == "" {
+		path = "."
+	}
+
+	// Build git command
+	args := []string{"fetch"}
+
+	if prune, ok := input["prune"].(bool); ok && prune {
+		args = append(args, "--prune")
+	}
+
+	if tags, ok := input["tags"].(bool); ok && tags {
+		args = append(args, "--tags")
+	}
+
+	if dryRun, ok := input["dry_run"].(bool); ok && dryRun {
+		args = append(args, "--dry-run")
+	}
+
+	var refspecs []string
+	if specs, ok := input["refspecs"].([]interface{}); ok {
+		for _, spec := range specs {
+			if specStr, ok := spec.(string); ok && specStr != "" {
+				refspecs = append(refspecs, specStr)
+			}
+		}
+	}
+
+	// Handle remote and refspecs
+	if all, ok := input["all"].(bool); ok && all {
+		if len(refspecs) > 0 {
+			return "", NewPermanentError(serr.New("refspecs cannot be used when fetching all remotes"), "invalid parameters")
+		}
+		args = append(args, "--all")
+	} else {
+		remote, ok := GetString(input, "remote")
+		if !ok || remote == "" {
+			remote = "origin"
+		}
+		args = append(args, remote)
+		args = append(args, refspecs...)
+	}
+
+	// Execute git command
+	cmd := exec.Command("git", args...)
+	cmd.Dir = path
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		errMsg := stderr.String()
+		if strings.Contains(errMsg, "not a git repository") {
+			return "", NewPermanentError(serr.New(fmt.Sprintf("Not a git repository: %s", path)), "invalid repository")
+		}
+		if strings.Contains(errMsg, "does not appear to be a git repository") {
+			return "", NewPermanentError(serr.New(fmt.Sprintf("Unknown remote: %s", strings.TrimSpace(errMsg))), "unknown remote")
+		}
+		if netErr := gitNetworkError(errMsg, "fetch"); netErr != nil {
+			return "", netErr
+		}
+		if strings.Contains(errMsg, "couldn't find remote ref") || strings.Contains(errMsg, "invalid refspec") {
+			return "", NewPermanentError(serr.New(fmt.Sprintf("Fetch failed: %s", errMsg)), "invalid refspec")
+		}
+		// Default to retryable for unknown errors as they might be transient
+		return "", NewRetryableError(serr.Wrap(err, fmt.Sprintf("Git fetch failed: %s", errMsg)), "unknown error")
+	}
+
+	// Git reports fetched refs on stderr
+	result := stdout.String()
+	if stderr.String() != "" {
+		result += stderr.String()
+	}
+
+	if result == "" {
+		result = "Already up to date; nothing new to fetch."
+	}
+
+	return result, nil
+}
+
+// GitTagTool implements git tag functionality
+type GitTagTool struct{}
+
+// GetDefinition returns the tool definition for git tag
+func (t *GitTagTool) GetDefinition() Tool {
+	return Tool{
+		Name:        "git_tag",
+		Description: "List, create, delete or push tags. Tags with a message are annotated; without one they are lightweight.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"path": map[string]interface{}{
+					"type":        "string",
+					"description": "Repository path (defaults to current directory)",
+				},
+				"action": map[string]interface{}{
+					"type":        "string",
+					"enum":        []string{"list", "create", "delete", "push"},
+					"description": "What to do (defaults to 'list')",
+				},
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "Tag name, e.g. 'v1.2.0' (required for create and delete; for push, omit to push all tags)",
+				},
+				"message": map[string]interface{}{
+					"type":        "string",
+					"description": "Message for an annotated tag",
+				},
+				"target": map[string]interface{}{
+					"type":        "string",
+					"description": "Commit or ref to tag (defaults to HEAD)",
+				},
+				"pattern": map[string]interface{}{
+					"type":        "string",
+					"description": "Only list tags matching this pattern, e.g. 'v1.*'",
+				},
+				"force": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Replace an existing tag on create, or overwrite the remote tag on push",
+				},
+				"remote": map[string]interface{}{
+					"type":        "string",
+					"description": "Remote for push, or to also delete the tag from on delete (push defaults to 'origin')",
+				},
+			},
+			"required": []string{},
+		},
+	}
+}
+
+// Execute runs git tag command
+func (t *GitTagTool) Execute(input map[string]interface{}) (string, error) {
+	path, ok := GetString(input, "path")
+	if !ok || path == "" {
+		path = "."
+	}
+
+	action, _ := GetString(input, "action")
+	if action == "" {
+		action = "list"
+	}
+	name, _ := GetString(input, "name")
+	remote, _ := GetString(input, "remote")
+	force, _ := input["force"].(bool)
+
+	switch action {
+	case "list":
+		// Newest versions first
+		args := []string{"tag", "--list", "--sort=-version:refname", "--format=%(refname:short)\t%(objecttype)\t%(contents:subject)"}
+		if pattern, ok := GetString(input, "pattern"); ok && pattern != "" {
+			args = append(args, pattern)
+		}
+		out, err := runGitTag(path, args)
+		if err != nil {
+			return "", err
+		}
+		if strings.TrimSpace(out) == "" {
+			return "No tags found", nil
+		}
+
+		var result strings.Builder
+		for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+			parts := strings.SplitN(line, "\t", 3)
+			if len(parts) < 3 {
+				result.WriteString(line + "\n")
+				continue
+			}
+			if parts[1] == "tag" {
+				result.WriteString(fmt.Sprintf("%s (annotated) %s\n", parts[0], parts[2]))
+			} else {
+				result.WriteString(parts[0] + "\n")
+			}
+		}
+		return result.String(), nil
+
+	case "create":
+		if name == "" {
+			return "", NewPermanentError(serr.New("name is required to create a tag"), "invalid parameters")
+		}
+		args := []string{"tag"}
+		kind := "lightweight"
+		if message, ok := GetString(input, "message"); ok && message != "" {
+			args = append(args, "-a", "-m", message)
+			kind = "annotated"
+		}
+		if force {
+			args = append(args, "--force")
+		}
+		args = append(args, name)
+		if target, ok := GetString(input, "target"); ok && target != "" {
+			args = append(args, target)
+		}
+		if _, err := runGitTag(path, args); err != nil {
+			return "", err
+		}
+
+		result := fmt.Sprintf("Created %s tag '%s'", kind, name)
+		showCmd := exec.Command("git", "log", "-1", "--oneline", name)
+		showCmd.Dir = path
+
+		var showOut bytes.Buffer
+		showCmd.Stdout = &showOut
+		if showCmd.Run() == nil {
+			result += " at " + showOut.String()
+		}
+		return result, nil
+
+	case "delete":
+		if name == "" {
+			return "", NewPermanentError(serr.New("name is required to delete a tag"), "invalid parameters")
+		}
+		if _, err := runGitTag(path, []string{"tag", "--delete", name}); err != nil {
+			return "", err
+		}
+		result := fmt.Sprintf("Deleted tag '%s'", name)
+		if remote != "" {
+			out, err := runGitTagPush(path, []string{"push", remote, "--delete", "refs/tags/" + name})
+			if err != nil {
+				return "", serr.Wrap(err, result+" locally, but not from "+remote)
+			}
+			result += fmt.Sprintf(" locally and from %s\n%s", remote, out)
+		}
+		return result, nil
+
+	case "push":
+		if remote == "" {
+			remote = "origin"
+		}
+		args := []string{"push", remote}
+		if force {
+			args = append(args, "--force")
+		}
+		if name != "" {
+			args = append(args, "refs/tags/"+name)
+		} else {
+			args = append(args, "--tags")
+		}
+		out, err := runGitTagPush(path, args)
+		if err != nil {
+			return "", err
+		}
+		if out == "" {
+			out = "Tags pushed successfully"
+		}
+		return out, nil
+
+	default:
+		return "", NewPermanentError(serr.New(fmt.Sprintf("Unknown action '%s'; use list, create, delete or push", action)), "invalid parameters")
+	}
+}
+
+// runGitTag runs a local git tag command and returns its output
+func runGitTag(path string, args []string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = path
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		errMsg := stderr.String()
+		if strings.Contains(errMsg, "not a git repository") {
+			return "", NewPermanentError(serr.New(fmt.Sprintf("Not a git repository: %s", path)), "invalid repository")
+		}
+		if strings.Contains(errMsg, "already exists") {
+			return "", NewPermanentError(serr.New(fmt.Sprintf("Tag already exists: %s. Set force to replace it", strings.TrimSpace(errMsg))), "tag exists")
+		}
+		if strings.Contains(errMsg, "not found") {
+			return "", NewPermanentError(serr.New(fmt.Sprintf("Tag not found: %s", strings.TrimSpace(errMsg))), "unknown tag")
+		}
+		if strings.Contains(errMsg, "Failed to resolve") || strings.Contains(errMsg, "not a valid tag name") {
+			return "", NewPermanentError(serr.New(fmt.Sprintf("Invalid tag or target: %s", strings.TrimSpace(errMsg))), "invalid parameters")
+		}
+		return "", WrapFileSystemError(serr.Wrap(err, fmt.Sprintf("Git tag failed: %s", errMsg)))
+	}
+
+	return stdout.String(), nil
+}
+
+// runGitTagPush pushes or deletes tags on a remote, with push's network error handling
+func runGitTagPush(path string, args []string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = path
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		errMsg := stderr.String()
+		if strings.Contains(errMsg, "not a git repository") {
+			return "", NewPermanentError(serr.New(fmt.Sprintf("Not a git repository: %s", path)), "invalid repository")
+		}
+		if strings.Contains(errMsg, "does not appear to be a git repository") {
+			return "", NewPermanentError(serr.New(fmt.Sprintf("Unknown remote: %s", strings.TrimSpace(errMsg))), "unknown remote")
+		}
+		if netErr := gitNetworkError(errMsg, "tag push"); netErr != nil {
+			return "", netErr
+		}
+		if strings.Contains(errMsg, "rejected") || strings.Contains(errMsg, "failed to push") || strings.Contains(errMsg, "remote ref does not exist") {
+			return "", NewPermanentError(serr.New(fmt.Sprintf("Push failed: %s", errMsg)), "push rejected")
+		}
+		return "", NewRetryableError(serr.Wrap(err, fmt.Sprintf("Git push failed: %s", errMsg)), "unknown error")
+	}
+
+	// Git reports pushed refs on stderr
+	return stdout.String() + stderr.String(), nil
+}
+
+// GitCherryPickTool implements git cherry-pick functionality
+type GitCherryPickTool struct{}
+
+// GetDefinition returns the tool definition for git cherry-pick
+func (t *GitCherryPickTool) GetDefinition() Tool {
+	return Tool{
+		Name:        "git_cherry_pick",
+		Description: "Apply the changes of existing commits onto the current branch, e.g. to backport a fix",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"path": map[string]interface{}{
+					"type":        "string",
+					"description": "Repository path (defaults to current directory)",
+				},
+				"commits": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string"},
+					"description": "Commits or ranges to apply, oldest first (e.g. ['abc123', 'v1.0..fix-branch']). Required unless continuing, skipping or aborting",
+				},
+				"mainline": map[string]interface{}{
+					"type":        "integer",
+					"description": "Parent number (starting from 1) to diff against when picking a merge commit",
+				},
+				"no_commit": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Apply the changes to the working tree and index without committing",
+				},
+				"record_origin": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Append '(cherry picked from commit ...)' to each commit message",
+				},
+				"abort": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Abort the cherry-pick in progress and return to the previous state",
+				},
+				"continue": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Continue after resolving conflicts",
+				},
+				"skip": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Skip the commit that stopped the cherry-pick and go on with the rest",
+				},
+			},
+			"required": []string{},
+		},
+	}
+}
+
+// Execute runs git cherry-pick command
+func (t *GitCherryPickTool) Execute(input map[string]interface{}) (string, error) {
+	var options []string
+	if record, ok := input["record_origin"].(bool); ok && record {
+		options = append(options, "-x")
+	}
+	return runGitSequencer(input, "cherry-pick", options)
+}
+
+// GitRevertTool implements git revert functionality
+type GitRevertTool struct{}
+
+// GetDefinition returns the tool definition for git revert
+func (t *GitRevertTool) GetDefinition() Tool {
+	return Tool{
+		Name:        "git_revert",
+		Description: "Create commits that undo the changes of existing commits, e.g. to back out a bad change that was already pushed",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"path": map[string]interface{}{
+					"type":        "string",
+					"description": "Repository path (defaults to current directory)",
+				},
+				"commits": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string"},
+					"description": "Commits or ranges to revert, e.g. ['abc123'] or ['HEAD~3..HEAD']. Required unless continuing, skipping or aborting",
+				},
+				"mainline": map[string]interface{}{
+					"type":        "integer",
+					"description": "Parent number (starting from 1) to keep when reverting a merge commit",
+				},
+				"no_commit": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Undo the changes in the working tree and index without committing",
+				},
+				"abort": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Abort the revert in progress and return to the previous state",
+				},
+				"continue": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Continue after resolving conflicts",
+				},
+				"skip": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Skip the commit that stopped the revert and go on with the rest",
+				},
+			},
+			"required": []string{},
+		},
+	}
+}
+
+// Execute runs git revert command
+func (t *GitRevertTool) Execute(input map[string]interface{}) (string, error) {
+	return runGitSequencer(input, "revert", nil)
+}
+
+// runGitSequencer runs cherry-pick or revert, which share their flags, their
+// --continue/--skip/--abort flow and the way they stop on conflicts
+func runGitSequencer(input map[string]interface{}, command string, options []string) (string, error) {
+	path, ok := GetString(input, "path")
+	if !ok || path == "" {
+		path = "."
+	}
+
+	var args []string
+	control := ""
+	for _, flag := range []string{"abort", "continue", "skip"} {
+		if set, ok := input[flag].(bool); ok && set {
+			if control != "" {
+				return "", NewPermanentError(serr.New("Use only one of 'abort', 'continue' and 'skip'"), "invalid parameters")
+			}
+			control = flag
+		}
+	}
+
+	var commits []string
+	if control != "" {
+		args = []string{command, "--" + control}
+	} else {
+		if list, ok := input["commits"].([]interface{}); ok {
+			for _, c := range list {
+				if commit, ok := c.(string); ok && commit != "" {
+					commits = append(commits, commit)
+				}
+			}
+		}
+		if len(commits) == 0 {
+			return "", NewPermanentError(serr.New(fmt.Sprintf("commits parameter is required for %s", command)), "invalid parameters")
+		}
+
+		args = append([]string{command}, options...)
+		if command == "revert" {
+			args = append(args, "--no-edit")
+		}
+		if mainline, ok := GetInt(input, "mainline"); ok && mainline > 0 {
+			args = append(args, "-m", fmt.Sprint(mainline))
+		}
+		if noCommit, ok := input["no_commit"].(bool); ok && noCommit {
+			args = append(args, "--no-commit")
+		}
+		args = append(args, commits...)
+	}
+
+	// Remember where HEAD was so the new commits can be listed
+	headCmd := exec.Command("git", "rev-parse", "HEAD")
+	headCmd.Dir = path
+
+	var headOut bytes.Buffer
+	headCmd.Stdout = &headOut
+	headCmd.Run()
+	startHead := strings.TrimSpace(headOut.String())
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = path
+	// Keep the recorded messages rather than opening an editor on --continue
+	cmd.Env = append(os.Environ(), "GIT_EDITOR=true")
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		errMsg := stderr.String()
+		output := stdout.String() + errMsg
+		if strings.Contains(errMsg, "not a git repository") {
+			return "", NewPermanentError(serr.New(fmt.Sprintf("Not a git repository: %s", path)), "invalid repository")
+		}
+		if strings.Contains(errMsg, "no cherry-pick or revert in progress") {
+			return "", NewPermanentError(serr.New(fmt.Sprintf("No %s in progress to %s", command, control)), "nothing in progress")
+		}
+		if strings.Contains(errMsg, "cherry-pick or revert is already in progress") || strings.Contains(errMsg, "is already in progress") {
+			return "", NewPermanentError(serr.New(fmt.Sprintf("A %s is already in progress. Resolve it and use 'continue', or use 'abort'", command)), "operation in progress")
+		}
+		if strings.Contains(errMsg, "bad revision") || strings.Contains(errMsg, "bad object") || strings.Contains(errMsg, "unknown revision") {
+			return "", NewPermanentError(serr.New(fmt.Sprintf("Unknown commit: %s", strings.TrimSpace(errMsg))), "invalid commit")
+		}
+		if strings.Contains(errMsg, "is a merge but no -m option was given") {
+			return "", NewPermanentError(serr.New("Commit is a merge; set 'mainline' to the parent number to use (usually 1)"), "merge commit")
+		}
+		if strings.Contains(errMsg, "your local changes would be overwritten") || strings.Contains(errMsg, "Please commit your changes or stash them") {
+			return "", NewPermanentError(serr.New(fmt.Sprintf("Cannot %s: uncommitted changes would be overwritten. Commit or stash them first", command)), "dirty working tree")
+		}
+		if strings.Contains(output, "CONFLICT") || strings.Contains(errMsg, "could not apply") || strings.Contains(errMsg, "could not revert") ||
+			strings.Contains(errMsg, "you need to resolve your current index first") || strings.Contains(errMsg, "Committing is not possible") {
+			tool := "git_" + strings.ReplaceAll(command, "-", "_")
+			conflictInfo := fmt.Sprintf("%s CONFLICT\n\n", strings.ToUpper(command))
+			if stopped := gitSequencerStoppedAt(errMsg); stopped != "" {
+				conflictInfo += "Stopped at: " + stopped + "\n\n"
+			}
+			conflictInfo += "You need to:\n"
+			conflictInfo += "1. Review the conflicts with git_conflicts and resolve them with resolve_conflict\n"
+			conflictInfo += "2. Stage any files resolved by hand with git_add (resolve_conflict stages the files it finishes)\n"
+			conflictInfo += fmt.Sprintf("3. Complete the %s with %s --continue\n", command, tool)
+			conflictInfo += fmt.Sprintf("   (or skip this commit with %s --skip, or abort with %s --abort)\n\n", tool, tool)
+			conflictInfo += "Conflicted files:\n"
+
+			// Get conflict status
+			statusCmd := exec.Command("git", "status", "--short")
+			statusCmd.Dir = path
+			var statusOut bytes.Buffer
+			statusCmd.Stdout = &statusOut
+			if statusCmd.Run() == nil {
+				conflictInfo += statusOut.String()
+			}
+
+			return "", NewPermanentError(serr.New(conflictInfo), command+" conflict")
+		}
+		if strings.Contains(output, "is now empty") || strings.Contains(output, "nothing to commit") {
+			return "", NewPermanentError(serr.New(fmt.Sprintf("The %s would make an empty commit; its changes are already present. Use 'skip' to move on\n%s", command, output)), "empty commit")
+		}
+		return "", WrapFileSystemError(serr.Wrap(err, fmt.Sprintf("Git %s failed: %s", command, errMsg)))
+	}
+
+	if control == "abort" {
+		return fmt.Sprintf("%s aborted successfully", strings.ToUpper(command[:1])+command[1:]), nil
+	}
+
+	result := strings.TrimSpace(stdout.String() + stderr.String())
+
+	// List the commits that were created
+	if startHead != "" {
+		logCmd := exec.Command("git", "log", "--oneline", startHead+"..HEAD")
+		logCmd.Dir = path
+
+		var logOut bytes.Buffer
+		logCmd.Stdout = &logOut
+		if logCmd.Run() == nil && logOut.Len() > 0 {
+			result += "\n\nNew commits:\n" + logOut.String()
+		}
+	}
+
+	if noCommit, ok := input["no_commit"].(bool); ok && noCommit && control == "" {
+		result += "\n\nChanges are staged but not committed"
+	}
+
+	return strings.TrimSpace(result), nil
+}
+
+// gitSequencerStoppedAt returns the commit cherry-pick or revert stopped at, from its error output
+func gitSequencerStoppedAt(errMsg string) string {
+	for _, line := range strings.Split(errMsg, "\n") {
+		for _, prefix := range []string{"error: could not apply ", "error: could not revert "} {
+			if strings.HasPrefix(line, prefix) {
+				return strings.TrimSpace(strings.TrimPrefix(line, prefix))
+			}
+		}
+	}
+	return ""
+}
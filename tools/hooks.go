@@ -0,0 +1,344 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/serr"
+)
+
+// Hook events
+const (
+	HookPreTool  = "pre_tool"  // Before a tool runs; a failing hook blocks the tool
+	HookPostTool = "post_tool" // After a tool runs; a failing hook's output is added to the result
+)
+
+const (
+	defaultHookTimeout = 30 * time.Second
+	maxHookOutput      = 4096 // Bytes of hook output kept for block reasons and notes
+)
+
+// Hook is a shell command or webhook that runs before or after matching tools.
+// Commands receive the payload as JSON on stdin, webhooks as a JSON POST body.
+type Hook struct {
+	Name    string            `json:"name,omitempty"`
+	Event   string            `json:"event"`           // HookPreTool or HookPostTool
+	Tools   []string          `json:"tools,omitempty"` // Tool names or glob patterns; empty matches every tool
+	Paths   []string          `json:"paths,omitempty"` // Only fire when the tool's path matches one of these globs
+	Command string            `json:"command,omitempty"`
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"` // Sent with webhook requests
+	Timeout int               `json:"timeout,omitempty"` // Seconds (default: 30)
+}
+
+// HookPayload describes the tool call a hook fires for
+type HookPayload struct {
+	Event       string                 `json:"event"`
+	Tool        string                 `json:"tool"`
+	Input       map[string]interface{} `json:"input"`
+	Path        string                 `json:"path,omitempty"` // The file the tool works on, if any
+	SessionID   string                 `json:"session_id,omitempty"`
+	ProjectRoot string                 `json:"project_root"`
+	Output      string                 `json:"output,omitempty"` // Post-tool only
+	Error       string                 `json:"error,omitempty"`  // Post-tool only
+}
+
+// HookRunner runs the hooks that match each tool call
+type HookRunner struct {
+	hooks       []Hook
+	projectRoot string
+	client      *http.Client
+}
+
+// hooksFile is the layout of a hooks config file
+type hooksFile struct {
+	Hooks []Hook `json:"hooks"`
+}
+
+// LoadHooks reads hooks from the given config files, skipping files that do not exist.
+// Hooks from every file are kept, in order.
+func LoadHooks(projectRoot string, paths ...string) (*HookRunner, error) {
+	runner := &HookRunner{projectRoot: projectRoot, client: &http.Client{}}
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, serr.Wrap(err, "failed to read hooks file", "path", path)
+		}
+
+		var file hooksFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, serr.Wrap(err, "invalid hooks file", "path", path)
+		}
+
+		for i, hook := range file.Hooks {
+			if hook.Event != HookPreTool && hook.Event != HookPostTool {
+				return nil, serr.New(fmt.Sprintf("hook %d in %s: event must be %s or %s", i+1, path, HookPreTool, HookPostTool))
+			}
+			if (hook.Command == "") == (hook.URL == "") {
+				return nil, serr.New(fmt.Sprintf("hook %d in %s: set either command or url", i+1, path))
+			}
+			if hook.Name == "" {
+				hook.Name = fmt.Sprintf("%s hook %d", filepath.Base(path), i+1)
+			}
+			runner.hooks = append(runner.hooks, hook)
+		}
+	}
+
+	return runner, nil
+}
+
+// Len returns the number of hooks loaded
+func (r *HookRunner) Len() int {
+	return len(r.hooks)
+}
+
+// RunPre fires the pre-tool hooks for toolUse. It returns an error, holding the hook's
+// output as the reason, if any hook refuses the call.
+func (r *HookRunner) RunPre(toolUse ToolUse) error {
+	payload := r.payload(HookPreTool, toolUse)
+	for _, hook := range r.matching(payload) {
+		if output, err := r.run(hook, payload); err != nil {
+			reason := strings.TrimSpace(output)
+			if reason == "" {
+				reason = err.Error()
+			}
+			logger.Info("Hook blocked tool", "hook", hook.Name, "tool", toolUse.Name)
+			return serr.New(fmt.Sprintf("Blocked by hook %q: %s", hook.Name, reason))
+		}
+	}
+	return nil
+}
+
+// RunPost fires the post-tool hooks for toolUse. Failing hooks do not undo the tool;
+// their output is appended to the result so the model can act on it.
+func (r *HookRunner) RunPost(toolUse ToolUse, result *ToolResult, toolErr error) *ToolResult {
+	payload := r.payload(HookPostTool, toolUse)
+	if result != nil {
+		payload.Output = result.Content
+	}
+	if toolErr != nil {
+		payload.Error = toolErr.Error()
+	}
+
+	for _, hook := range r.matching(payload) {
+		output, err := r.run(hook, payload)
+		if err == nil || result == nil {
+			continue
+		}
+		note := strings.TrimSpace(output)
+		if note == "" {
+			note = err.Error()
+		}
+		result.Content += fmt.Sprintf("\n\n[Hook %q failed: %s]", hook.Name, note)
+	}
+	return result
+}
+
+// payload describes toolUse for hooks, leaving out internal inputs
+func (r *HookRunner) payload(event string, toolUse ToolUse) HookPayload {
+	input := make(map[string]interface{}, len(toolUse.Input))
+	for k, v := range toolUse.Input {
+		if !strings.HasPrefix(k, "_") {
+			input[k] = v
+		}
+	}
+	sessionID, _ := GetString(toolUse.Input, "_sessionId")
+
+	path, ok := GetString(toolUse.Input, "path")
+	if !ok {
+		path, _ = GetString(toolUse.Input, "file_path")
+	}
+	if path != "" {
+		if expanded, err := ExpandPath(path); err == nil {
+			path = expanded
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(r.projectRoot, path)
+		}
+	}
+
+	return HookPayload{
+		Event:       event,
+		Tool:        toolUse.Name,
+		Input:       input,
+		Path:        path,
+		SessionID:   sessionID,
+		ProjectRoot: r.projectRoot,
+	}
+}
+
+// matching returns the hooks for the payload's event, tool and path
+func (r *HookRunner) matching(payload HookPayload) []Hook {
+	var hooks []Hook
+	for _, hook := range r.hooks {
+		if hook.Event != payload.Event || !matchesAny(hook.Tools, payload.Tool, false) {
+			continue
+		}
+		if len(hook.Paths) > 0 {
+			if payload.Path == "" {
+				continue
+			}
+			rel, err := filepath.Rel(r.projectRoot, payload.Path)
+			if err != nil || strings.HasPrefix(rel, "..") {
+				rel = payload.Path
+			}
+			if !matchesAny(hook.Paths, filepath.ToSlash(rel), true) {
+				continue
+			}
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks
+}
+
+// run executes one hook and returns its output
+func (r *HookRunner) run(hook Hook, payload HookPayload) (string, error) {
+	timeout := defaultHookTimeout
+	if hook.Timeout > 0 {
+		timeout = time.Duration(hook.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", serr.Wrap(err, "failed to marshal hook payload")
+	}
+
+	var output string
+	if hook.Command != "" {
+		output, err = r.runCommand(ctx, hook, payload, body)
+	} else {
+		output, err = r.callWebhook(ctx, hook, body)
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		err = serr.New(fmt.Sprintf("timed out after %s", timeout))
+	}
+	if err != nil {
+		logger.LogErr(err, "hook failed", "hook", hook.Name, "tool", payload.Tool)
+	}
+	return output, err
+}
+
+// runCommand runs a command hook with sh in the project root.
+// Besides the JSON on stdin, the tool and path are set in RCODE_TOOL and RCODE_FILE_PATH.
+func (r *HookRunner) runCommand(ctx context.Context, hook Hook, payload HookPayload, body []byte) (string, error) {
+	cmd := exec.CommandContext(ctx, "sh", "-c", hook.Command)
+	cmd.Dir = r.projectRoot
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"RCODE_HOOK_EVENT="+payload.Event,
+		"RCODE_TOOL="+payload.Tool,
+		"RCODE_FILE_PATH="+payload.Path,
+		"RCODE_SESSION_ID="+payload.SessionID,
+	)
+
+	output, err := cmd.CombinedOutput()
+	return truncateHookOutput(string(output)), err
+}
+
+// callWebhook POSTs the payload to a webhook hook. Any status other than 2xx counts as failure.
+func (r *HookRunner) callWebhook(ctx context.Context, hook Hook, body []byte) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return "", serr.Wrap(err, "invalid webhook url")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range hook.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", serr.Wrap(err, "webhook request failed")
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxHookOutput))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return string(respBody), serr.New(fmt.Sprintf("webhook returned %s", resp.Status))
+	}
+	return string(respBody), nil
+}
+
+// matchesAny reports whether value matches one of the glob patterns; no patterns match everything.
+// Path patterns support ** for any number of directories, and patterns without a slash
+// also match the file name alone.
+func matchesAny(patterns []string, value string, isPath bool) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if !isPath {
+			if ok, _ := filepath.Match(pattern, value); ok {
+				return true
+			}
+			continue
+		}
+		re := globToRegexp(pattern)
+		if re.MatchString(value) {
+			return true
+		}
+		if !strings.Contains(pattern, "/") && re.MatchString(filepath.Base(value)) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesPathGlob reports whether a path, relative to the project, matches a glob the way
+// hooks' paths do
+func MatchesPathGlob(pattern, path string) bool {
+	return matchesAny([]string{pattern}, path, true)
+}
+
+// globToRegexp converts a path glob, where ** crosses directories, into an anchored regexp
+func globToRegexp(pattern string) *regexp.Regexp {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+				if i+1 < len(pattern) && pattern[i+1] == '/' {
+					i++
+					sb.WriteString("(?:.*/)?")
+				} else {
+					sb.WriteString(".*")
+				}
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.MustCompile(sb.String())
+}
+
+// truncateHookOutput keeps the start of long hook output
+func truncateHookOutput(output string) string {
+	if len(output) > maxHookOutput {
+		return output[:maxHookOutput] + "\n... (truncated)"
+	}
+	return output
+}
@@ -0,0 +1,173 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/serr"
+)
+
+const (
+	defaultHTTPToolTimeout = 60 * time.Second
+	maxHTTPToolOutput      = 100 << 10 // Bytes of an endpoint's response returned to the model
+)
+
+// httpToolName is what the API accepts as a tool name
+var httpToolName = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// HTTPTool is a tool defined in a config file rather than in Go: calling it POSTs the
+// tool's input, as JSON, to an endpoint and returns the endpoint's response to the model
+type HTTPTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"input_schema,omitempty"` // JSON schema of the input; any object when unset
+	URL         string                 `json:"url"`
+	Headers     map[string]string      `json:"headers,omitempty"` // Sent with each call, such as Authorization; ${VAR} is replaced from the environment
+	Timeout     int                    `json:"timeout,omitempty"` // Seconds (default: 60)
+}
+
+// httpToolsFile is the layout of an HTTP tools config file
+type httpToolsFile struct {
+	Tools []HTTPTool `json:"tools"`
+}
+
+// LoadHTTPTools reads HTTP tools from the given config files, skipping files that do not
+// exist. A tool in a later file replaces one of the same name in an earlier file.
+func LoadHTTPTools(paths ...string) ([]HTTPTool, error) {
+	var httpTools []HTTPTool
+	index := make(map[string]int)
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, serr.Wrap(err, "failed to read HTTP tools file", "path", path)
+		}
+
+		var file httpToolsFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, serr.Wrap(err, "invalid HTTP tools file", "path", path)
+		}
+
+		for i, tool := range file.Tools {
+			if !httpToolName.MatchString(tool.Name) {
+				return nil, serr.New(fmt.Sprintf("HTTP tool %d in %s: name must be 1 to 64 letters, digits, _ or -", i+1, path))
+			}
+			if tool.Description == "" {
+				return nil, serr.New(fmt.Sprintf("HTTP tool %q in %s: set a description, which tells the model what it does", tool.Name, path))
+			}
+			if u, err := url.Parse(tool.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return nil, serr.New(fmt.Sprintf("HTTP tool %q in %s: url must be an http or https URL", tool.Name, path))
+			}
+			if tool.InputSchema == nil {
+				tool.InputSchema = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+			}
+
+			if j, ok := index[tool.Name]; ok {
+				httpTools[j] = tool
+				continue
+			}
+			index[tool.Name] = len(httpTools)
+			httpTools = append(httpTools, tool)
+		}
+	}
+
+	return httpTools, nil
+}
+
+// RegisterHTTPTools adds the HTTP tools to the registry. A tool named like one already in
+// it, such as a built-in tool, is skipped rather than replacing it.
+func RegisterHTTPTools(registry *Registry, httpTools []HTTPTool) {
+	client := &http.Client{}
+	for _, tool := range httpTools {
+		if _, exists := registry.tools[tool.Name]; exists {
+			logger.Warn("Skipping HTTP tool named like a registered tool", "tool", tool.Name)
+			continue
+		}
+		registry.Register(Tool{
+			Name:        tool.Name,
+			Description: tool.Description,
+			InputSchema: tool.InputSchema,
+		}, &httpToolExecutor{tool: tool, client: client})
+	}
+}
+
+// httpToolExecutor runs an HTTP tool by calling its endpoint
+type httpToolExecutor struct {
+	tool   HTTPTool
+	client *http.Client
+}
+
+// Execute calls the endpoint without a context to interrupt it
+func (e *httpToolExecutor) Execute(input map[string]interface{}) (string, error) {
+	return e.ExecuteContext(context.Background(), input)
+}
+
+// ExecuteContext POSTs the input, leaving out internal inputs, to the tool's endpoint and
+// returns the response body. Any status other than 2xx is an error holding the body.
+func (e *httpToolExecutor) ExecuteContext(ctx context.Context, input map[string]interface{}) (string, error) {
+	timeout := defaultHTTPToolTimeout
+	if e.tool.Timeout > 0 {
+		timeout = time.Duration(e.tool.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	payload := make(map[string]interface{}, len(input))
+	for k, v := range input {
+		if !strings.HasPrefix(k, "_") {
+			payload[k] = v
+		}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", serr.Wrap(err, "failed to marshal tool input")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.tool.URL, bytes.NewReader(body))
+	if err != nil {
+		return "", serr.Wrap(err, "invalid tool url")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.tool.Headers {
+		req.Header.Set(k, os.ExpandEnv(v))
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", serr.New(fmt.Sprintf("%s timed out after %s", e.tool.Name, timeout))
+		}
+		return "", serr.Wrap(err, "request to "+e.tool.Name+" endpoint failed")
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPToolOutput+1))
+	if err != nil {
+		return "", serr.Wrap(err, "failed to read "+e.tool.Name+" response")
+	}
+	output := string(respBody)
+	if len(respBody) > maxHTTPToolOutput {
+		output = string(respBody[:maxHTTPToolOutput]) + fmt.Sprintf("\n\n[Response truncated to %d bytes]", maxHTTPToolOutput)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", serr.New(fmt.Sprintf("%s endpoint returned %s: %s", e.tool.Name, resp.Status, strings.TrimSpace(output)))
+	}
+	if strings.TrimSpace(output) == "" {
+		return fmt.Sprintf("%s endpoint returned %s with no content", e.tool.Name, resp.Status), nil
+	}
+	return output, nil
+}
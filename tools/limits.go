@@ -0,0 +1,190 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// ToolLimit bounds a tool's calls. Unset fields take their value from a less specific
+// limit, so a file can change one without repeating the others.
+type ToolLimit struct {
+	Timeout     *int `json:"timeout,omitempty"`     // Seconds a call may run, over all attempts; 0 for no limit
+	Retries     *int `json:"retries,omitempty"`     // Further attempts after an error IsRetryableError accepts
+	Concurrency *int `json:"concurrency,omitempty"` // Calls that may run at once in a session; 0 for no limit
+}
+
+// ResolvedLimit is the limit a tool's calls run under
+type ResolvedLimit struct {
+	Timeout     time.Duration `json:"timeout"`
+	Retries     int           `json:"retries"`
+	Concurrency int           `json:"concurrency"`
+}
+
+// toolLimitsFile is the layout of a tool limits config file: limits by tool name or
+// glob pattern, such as "web_*" or "*"
+type toolLimitsFile struct {
+	Tools map[string]ToolLimit `json:"tools"`
+}
+
+// ToolLimits holds the limits of every tool, most general first
+type ToolLimits struct {
+	patterns []string
+	limits   []ToolLimit
+}
+
+func intPtr(n int) *int { return &n }
+
+// defaultToolLimits apply before any file's. Network tools retry, as their errors are often
+// passing; others don't, as a tool that changes files may have done part of its work.
+var defaultToolLimits = toolLimitsFile{Tools: map[string]ToolLimit{
+	"*":          {Timeout: intPtr(600), Retries: intPtr(0), Concurrency: intPtr(0)},
+	"bash":       {Concurrency: intPtr(1)},
+	"web_fetch":  {Retries: intPtr(2)},
+	"web_search": {Retries: intPtr(2)},
+}}
+
+// DefaultToolLimits returns the built-in limits
+func DefaultToolLimits() *ToolLimits {
+	limits := &ToolLimits{}
+	limits.add(defaultToolLimits)
+	return limits
+}
+
+// LoadToolLimits reads tool limits from the given config files over the built-in ones,
+// skipping files that do not exist. A later file's limits win over an earlier file's.
+func LoadToolLimits(paths ...string) (*ToolLimits, error) {
+	limits := DefaultToolLimits()
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, serr.Wrap(err, "failed to read tool limits file", "path", path)
+		}
+
+		var file toolLimitsFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, serr.Wrap(err, "invalid tool limits file", "path", path)
+		}
+		for pattern, limit := range file.Tools {
+			for _, n := range []*int{limit.Timeout, limit.Retries, limit.Concurrency} {
+				if n != nil && *n < 0 {
+					return nil, serr.New(fmt.Sprintf("tool limit %q in %s: values must be 0 or more", pattern, path))
+				}
+			}
+		}
+		limits.add(file)
+	}
+
+	return limits, nil
+}
+
+// add appends a file's limits, patterns before tool names so that names are more specific
+func (l *ToolLimits) add(file toolLimitsFile) {
+	patterns := make([]string, 0, len(file.Tools))
+	for pattern := range file.Tools {
+		patterns = append(patterns, pattern)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		iGlob, jGlob := strings.ContainsAny(patterns[i], "*?["), strings.ContainsAny(patterns[j], "*?[")
+		if iGlob != jGlob {
+			return iGlob
+		}
+		if patterns[i] == "*" || patterns[j] == "*" {
+			return patterns[i] == "*" && patterns[j] != "*"
+		}
+		return patterns[i] < patterns[j]
+	})
+	for _, pattern := range patterns {
+		l.patterns = append(l.patterns, pattern)
+		l.limits = append(l.limits, file.Tools[pattern])
+	}
+}
+
+// For returns the limit of a tool's calls
+func (l *ToolLimits) For(toolName string) ResolvedLimit {
+	var resolved ResolvedLimit
+	for i, pattern := range l.patterns {
+		if !matchesAny([]string{pattern}, toolName, false) {
+			continue
+		}
+		limit := l.limits[i]
+		if limit.Timeout != nil {
+			resolved.Timeout = time.Duration(*limit.Timeout) * time.Second
+		}
+		if limit.Retries != nil {
+			resolved.Retries = *limit.Retries
+		}
+		if limit.Concurrency != nil {
+			resolved.Concurrency = *limit.Concurrency
+		}
+	}
+	return resolved
+}
+
+// toolTimeoutError is a call stopped by its tool's timeout, which isn't retried
+type toolTimeoutError struct {
+	tool    string
+	timeout time.Duration
+}
+
+func (e *toolTimeoutError) Error() string {
+	return fmt.Sprintf("%s timed out after %s", e.tool, e.timeout)
+}
+
+// toolSemaphore bounds the calls of one tool in one session
+type toolSemaphore struct {
+	slots chan struct{}
+	users int // Calls holding or waiting for a slot; the semaphore is dropped at 0
+}
+
+var (
+	toolSemaphores   = make(map[string]*toolSemaphore)
+	toolSemaphoresMu sync.Mutex
+)
+
+// acquireToolSlot waits for one of the limit's slots for the tool in the session, and
+// returns the function that frees it. Registries are built for each message, so the
+// slots are shared by all of them.
+func acquireToolSlot(ctx context.Context, sessionID, toolName string, limit int) (func(), error) {
+	key := sessionID + "\x00" + toolName
+
+	toolSemaphoresMu.Lock()
+	sem := toolSemaphores[key]
+	if sem == nil {
+		sem = &toolSemaphore{slots: make(chan struct{}, limit)}
+		toolSemaphores[key] = sem
+	}
+	sem.users++
+	toolSemaphoresMu.Unlock()
+
+	done := func() {
+		toolSemaphoresMu.Lock()
+		defer toolSemaphoresMu.Unlock()
+		sem.users--
+		if sem.users == 0 {
+			delete(toolSemaphores, key)
+		}
+	}
+
+	select {
+	case sem.slots <- struct{}{}:
+		return func() {
+			<-sem.slots
+			done()
+		}, nil
+	case <-ctx.Done():
+		done()
+		return nil, ctx.Err()
+	}
+}
@@ -2,18 +2,26 @@ package tools
 
 import (
 	"context"
+	"os"
 	"path/filepath"
 	"plugin"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/rohanthewiz/logger"
 	"github.com/rohanthewiz/serr"
+	"rcode/config"
 )
 
+// pluginPollInterval is how often the plugin directories are checked for changes
+const pluginPollInterval = 2 * time.Second
+
 // PluginLoader handles loading custom tools
 type PluginLoader struct {
 	searchPaths   []string
 	loadedPlugins map[string]*LoadedPlugin
+	failed        map[string]pluginStamp // Files that failed to load, not retried until they change
 }
 
 // LoadedPlugin represents a loaded plugin instance
@@ -22,6 +30,13 @@ type LoadedPlugin struct {
 	Plugin   ToolPlugin
 	Metadata PluginMetadata
 	Enabled  bool
+	stamp    pluginStamp // The file when it was loaded, or when its change was last reported
+}
+
+// pluginStamp identifies a version of a plugin file
+type pluginStamp struct {
+	modTime time.Time
+	size    int64
 }
 
 // NewPluginLoader creates a new plugin loader
@@ -29,31 +44,108 @@ func NewPluginLoader(searchPaths []string) *PluginLoader {
 	return &PluginLoader{
 		searchPaths:   searchPaths,
 		loadedPlugins: make(map[string]*LoadedPlugin),
+		failed:        make(map[string]pluginStamp),
 	}
 }
 
-// LoadPlugins discovers and loads all plugins from search paths
-func (pl *PluginLoader) LoadPlugins() error {
+// pluginFiles returns the plugin files (compiled Go plugins, *.so) in the search paths
+func (pl *PluginLoader) pluginFiles() map[string]pluginStamp {
+	files := make(map[string]pluginStamp)
 	for _, searchPath := range pl.searchPaths {
-		// Find .so files (compiled Go plugins)
 		matches, err := filepath.Glob(filepath.Join(searchPath, "*.so"))
 		if err != nil {
 			logger.LogErr(err, "failed to search for plugins", "path", searchPath)
 			continue
 		}
-
 		for _, pluginPath := range matches {
-			if err := pl.loadPlugin(pluginPath); err != nil {
-				logger.LogErr(err, "failed to load plugin", "path", pluginPath)
-				// Continue loading other plugins
+			info, err := os.Stat(pluginPath)
+			if err != nil {
+				continue
 			}
+			files[pluginPath] = pluginStamp{modTime: info.ModTime(), size: info.Size()}
+		}
+	}
+	return files
+}
+
+// LoadPlugins discovers and loads all plugins from search paths
+func (pl *PluginLoader) LoadPlugins() error {
+	for pluginPath, stamp := range pl.pluginFiles() {
+		if err := pl.loadPlugin(pluginPath, stamp); err != nil {
+			logger.LogErr(err, "failed to load plugin", "path", pluginPath)
+			pl.failed[pluginPath] = stamp
+			// Continue loading other plugins
 		}
 	}
 	return nil
 }
 
+// Reload brings the loaded plugins in line with the search paths, reporting whether the
+// tools changed: plugins whose files were removed are cleaned up and dropped, and new files
+// are loaded. Go can't unload a plugin, and opening a rebuilt file at the same path returns
+// the plugin already loaded from it, so a changed file is only reported; copy the rebuilt
+// plugin to a new file name, and remove the old one, to replace it without a restart.
+func (pl *PluginLoader) Reload() bool {
+	files := pl.pluginFiles()
+	changed := false
+
+	loadedPaths := make(map[string]bool, len(pl.loadedPlugins))
+	for name, loadedPlugin := range pl.loadedPlugins {
+		stamp, ok := files[loadedPlugin.Path]
+		if !ok {
+			if err := loadedPlugin.Plugin.Cleanup(); err != nil {
+				logger.LogErr(err, "failed to clean up removed plugin", "name", name)
+			}
+			delete(pl.loadedPlugins, name)
+			logger.Info("Removed custom tool plugin", "name", name, "path", loadedPlugin.Path)
+			changed = true
+			continue
+		}
+		loadedPaths[loadedPlugin.Path] = true
+		if stamp != loadedPlugin.stamp {
+			loadedPlugin.stamp = stamp
+			logger.Warn("Custom tool plugin changed; rcode keeps the version it loaded until it restarts, or the plugin is copied to a new file name",
+				"name", name, "path", loadedPlugin.Path)
+		}
+	}
+
+	for pluginPath, stamp := range files {
+		if loadedPaths[pluginPath] {
+			continue
+		}
+		if failedStamp, ok := pl.failed[pluginPath]; ok && failedStamp == stamp {
+			continue
+		}
+		if err := pl.loadPlugin(pluginPath, stamp); err != nil {
+			logger.LogErr(err, "failed to load plugin", "path", pluginPath)
+			pl.failed[pluginPath] = stamp
+			continue
+		}
+		delete(pl.failed, pluginPath)
+		changed = true
+	}
+	for pluginPath := range pl.failed {
+		if _, ok := files[pluginPath]; !ok {
+			delete(pl.failed, pluginPath)
+		}
+	}
+	return changed
+}
+
+// ToolNames returns the names of the enabled plugins' tools, sorted
+func (pl *PluginLoader) ToolNames() []string {
+	names := make([]string, 0, len(pl.loadedPlugins))
+	for _, loadedPlugin := range pl.loadedPlugins {
+		if loadedPlugin.Enabled {
+			names = append(names, loadedPlugin.Plugin.GetDefinition().Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 // loadPlugin loads a single plugin
-func (pl *PluginLoader) loadPlugin(path string) error {
+func (pl *PluginLoader) loadPlugin(path string, stamp pluginStamp) error {
 	// Load the Go plugin
 	p, err := plugin.Open(path)
 	if err != nil {
@@ -78,7 +170,12 @@ func (pl *PluginLoader) loadPlugin(path string) error {
 		return serr.Wrap(err, "plugin missing 'Metadata' symbol")
 	}
 
+	// Lookup returns a pointer to the variable, so a plugin declaring Metadata as a
+	// pointer, as the template does, gives a pointer to that pointer
 	metadata, ok := metaSym.(*PluginMetadata)
+	if metaPtr, isPtr := metaSym.(**PluginMetadata); isPtr && *metaPtr != nil {
+		metadata, ok = *metaPtr, true
+	}
 	if !ok {
 		return serr.New("plugin 'Metadata' is not of type *PluginMetadata")
 	}
@@ -94,6 +191,7 @@ func (pl *PluginLoader) loadPlugin(path string) error {
 		Plugin:   toolPlugin,
 		Metadata: *metadata,
 		Enabled:  true,
+		stamp:    stamp,
 	}
 
 	logger.Info("Loaded custom tool plugin",
@@ -146,3 +244,46 @@ func (a *PluginExecutorAdapter) Execute(input map[string]interface{}) (string, e
 	// Execute the plugin
 	return a.plugin.Execute(ctx, input)
 }
+
+var (
+	// customPlugins are the plugins loaded from the configured search paths, shared by the
+	// sessions' registries and kept up to date by WatchPlugins
+	customPlugins   *PluginLoader
+	customPluginsMu sync.Mutex
+)
+
+// loadedCustomPlugins returns the shared plugins, loading them on first use. It is called
+// under customPluginsMu.
+func loadedCustomPlugins() *PluginLoader {
+	if customPlugins == nil {
+		customPlugins = NewPluginLoader(config.Get().CustomToolsPaths)
+		if err := customPlugins.LoadPlugins(); err != nil {
+			logger.LogErr(err, "failed to load custom tool plugins")
+		}
+	}
+	return customPlugins
+}
+
+// WatchPlugins reloads the custom tool plugins when files are added to or removed from the
+// plugin directories, and calls onChange with the plugins' tool names after each reload.
+// Registries built afterwards, such as those for the sessions' next messages, have the
+// new tools. The directories are polled, as config.WatchFiles polls the config files.
+func WatchPlugins(onChange func(toolNames []string)) {
+	customPluginsMu.Lock()
+	loadedCustomPlugins()
+	customPluginsMu.Unlock()
+
+	go func() {
+		for range time.Tick(pluginPollInterval) {
+			customPluginsMu.Lock()
+			changed := customPlugins.Reload()
+			names := customPlugins.ToolNames()
+			customPluginsMu.Unlock()
+
+			if changed {
+				logger.Info("Reloaded custom tool plugins", "tools", len(names))
+				onChange(names)
+			}
+		}
+	}()
+}
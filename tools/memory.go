@@ -0,0 +1,182 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// Memory is a durable fact about the project that persists across sessions
+type Memory struct {
+	ID       int
+	Category string // "convention", "decision", "gotcha" or "note"
+	Content  string
+	Tags     []string
+}
+
+// MemoryStore persists project memories.
+// It is implemented by the web layer, since this package cannot import db.
+type MemoryStore interface {
+	Remember(memory Memory, sessionID string) (int, error)
+	Recall(query, category string, limit int) ([]Memory, error)
+}
+
+var memoryCategories = []string{"convention", "decision", "gotcha", "note"}
+
+// RememberTool stores a durable fact about the project
+type RememberTool struct {
+	Store MemoryStore
+}
+
+// GetDefinition returns the tool definition
+func (t *RememberTool) GetDefinition() Tool {
+	return Tool{
+		Name: "remember",
+		Description: "Store a durable fact about this project (a convention, decision or gotcha) so it is " +
+			"available in future sessions. Keep each memory to one self-contained fact.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"content": map[string]interface{}{
+					"type":        "string",
+					"description": "The fact to remember",
+				},
+				"category": map[string]interface{}{
+					"type":        "string",
+					"description": "Kind of fact (default: note)",
+					"enum":        memoryCategories,
+				},
+				"tags": map[string]interface{}{
+					"type":        "array",
+					"description": "Keywords that help recall this memory, e.g. package or feature names",
+					"items":       map[string]interface{}{"type": "string"},
+				},
+			},
+			"required": []string{"content"},
+		},
+	}
+}
+
+// Execute stores the memory
+func (t *RememberTool) Execute(input map[string]interface{}) (string, error) {
+	if t.Store == nil {
+		return "", serr.New("project memory is not available")
+	}
+
+	content, ok := GetString(input, "content")
+	if !ok || strings.TrimSpace(content) == "" {
+		return "", serr.New("content parameter is required")
+	}
+
+	category, _ := GetString(input, "category")
+	sessionID, _ := GetString(input, "_sessionId")
+
+	memory := Memory{
+		Category: category,
+		Content:  content,
+		Tags:     getStringList(input, "tags"),
+	}
+
+	id, err := t.Store.Remember(memory, sessionID)
+	if err != nil {
+		return "", serr.Wrap(err, "failed to store memory")
+	}
+
+	return fmt.Sprintf("Remembered (memory #%d)", id), nil
+}
+
+// RecallTool retrieves stored facts about the project
+type RecallTool struct {
+	Store MemoryStore
+}
+
+// GetDefinition returns the tool definition
+func (t *RecallTool) GetDefinition() Tool {
+	return Tool{
+		Name:        "recall",
+		Description: "Search facts previously stored about this project with the remember tool",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"query": map[string]interface{}{
+					"type":        "string",
+					"description": "Keywords to search for. Leave empty to list the most useful memories",
+				},
+				"category": map[string]interface{}{
+					"type":        "string",
+					"description": "Only return memories of this kind",
+					"enum":        memoryCategories,
+				},
+				"limit": map[string]interface{}{
+					"type":        "integer",
+					"description": "Maximum number of memories to return (default: 10)",
+					"default":     10,
+				},
+			},
+		},
+	}
+}
+
+// Execute searches the stored memories
+func (t *RecallTool) Execute(input map[string]interface{}) (string, error) {
+	if t.Store == nil {
+		return "", serr.New("project memory is not available")
+	}
+
+	query, _ := GetString(input, "query")
+	category, _ := GetString(input, "category")
+	limit := 10
+	if l, ok := GetInt(input, "limit"); ok && l > 0 {
+		limit = l
+	}
+
+	memories, err := t.Store.Recall(query, category, limit)
+	if err != nil {
+		return "", serr.Wrap(err, "failed to recall memories")
+	}
+
+	if len(memories) == 0 {
+		return "No matching memories found", nil
+	}
+
+	return FormatMemories(memories), nil
+}
+
+// FormatMemories renders memories as a markdown list
+func FormatMemories(memories []Memory) string {
+	var sb strings.Builder
+	for _, m := range memories {
+		sb.WriteString(fmt.Sprintf("- [%s] %s", m.Category, m.Content))
+		if len(m.Tags) > 0 {
+			sb.WriteString(fmt.Sprintf(" (tags: %s)", strings.Join(m.Tags, ", ")))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// RegisterMemoryTools adds the remember and recall tools backed by store
+func RegisterMemoryTools(registry *Registry, store MemoryStore) {
+	rememberTool := &RememberTool{Store: store}
+	registry.Register(rememberTool.GetDefinition(), rememberTool)
+
+	recallTool := &RecallTool{Store: store}
+	registry.Register(recallTool.GetDefinition(), recallTool)
+}
+
+// getStringList reads a string array parameter, skipping non-string and empty entries
+func getStringList(input map[string]interface{}, key string) []string {
+	items, ok := input[key].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok && strings.TrimSpace(s) != "" {
+			list = append(list, strings.TrimSpace(s))
+		}
+	}
+	return list
+}
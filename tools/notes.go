@@ -0,0 +1,128 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// maxNoteLength bounds a note, in characters
+const maxNoteLength = 10000
+
+// Note is a note kept for a session apart from its messages
+type Note struct {
+	ID        int
+	Content   string
+	InContext bool // Sent with every request, as the user chose
+}
+
+// NoteStore keeps each session's notes.
+// It is implemented by the web layer, which owns the database and tells the pages.
+type NoteStore interface {
+	// AddNote appends a note to the session's notes, returning its ID
+	AddNote(sessionID, content string) (int, error)
+	// ReadNotes returns the session's notes, oldest first
+	ReadNotes(sessionID string) ([]Note, error)
+}
+
+// Store the note tools use
+var noteStore NoteStore
+
+// SetNoteStore sets the store the note tools use
+func SetNoteStore(store NoteStore) {
+	noteStore = store
+}
+
+// AddNoteTool appends a note to the session's notes, which the user sees and edits in the notes panel
+type AddNoteTool struct{}
+
+// GetDefinition returns the tool definition
+func (t *AddNoteTool) GetDefinition() Tool {
+	return Tool{
+		Name: "add_note",
+		Description: "Add a note to this session's scratchpad: a design decision, a finding, or a command's output worth " +
+			"keeping. Notes are kept apart from the conversation, so they are still there when it is trimmed or compacted; " +
+			"read them back with read_notes. The user sees them in the notes panel, can edit them, and chooses which are " +
+			"sent with every request. Keep each note to one self-contained point.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"content": map[string]interface{}{
+					"type":        "string",
+					"description": "The note, in markdown",
+				},
+			},
+			"required": []string{"content"},
+		},
+	}
+}
+
+// Execute stores the note
+func (t *AddNoteTool) Execute(input map[string]interface{}) (string, error) {
+	if noteStore == nil {
+		return "", NewPermanentError(serr.New("notes are not available"), "no store")
+	}
+	sessionID, _ := GetString(input, "_sessionId")
+	if sessionID == "" {
+		return "", NewPermanentError(serr.New("notes can only be kept in a session"), "no session")
+	}
+
+	content, _ := GetString(input, "content")
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return "", NewPermanentError(serr.New("content parameter is required"), "missing content")
+	}
+	if n := len([]rune(content)); n > maxNoteLength {
+		return "", NewPermanentError(serr.New(fmt.Sprintf("the note is %d characters, over the %d a note can hold; "+
+			"keep what is worth remembering, or split it", n, maxNoteLength)), "note too long")
+	}
+
+	id, err := noteStore.AddNote(sessionID, content)
+	if err != nil {
+		return "", serr.Wrap(err, "failed to add the note")
+	}
+	return fmt.Sprintf("Added note %d", id), nil
+}
+
+// ReadNotesTool returns the session's notes
+type ReadNotesTool struct{}
+
+// GetDefinition returns the tool definition
+func (t *ReadNotesTool) GetDefinition() Tool {
+	return Tool{
+		Name:        "read_notes",
+		Description: "Read this session's notes, added with add_note or by the user, as they stand after the user's edits",
+		InputSchema: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{},
+		},
+	}
+}
+
+// Execute returns the notes, each under its ID
+func (t *ReadNotesTool) Execute(input map[string]interface{}) (string, error) {
+	if noteStore == nil {
+		return "", NewPermanentError(serr.New("notes are not available"), "no store")
+	}
+	sessionID, _ := GetString(input, "_sessionId")
+
+	notes, err := noteStore.ReadNotes(sessionID)
+	if err != nil {
+		return "", serr.Wrap(err, "failed to read the notes")
+	}
+	if len(notes) == 0 {
+		return "There are no notes", nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d notes:\n", len(notes))
+	for _, note := range notes {
+		fmt.Fprintf(&sb, "\n### Note %d", note.ID)
+		if note.InContext {
+			sb.WriteString(" (in context)")
+		}
+		sb.WriteString("\n" + note.Content + "\n")
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+
+package tools
+
+import (
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// configureProcessGroup runs cmd in its own process group so that cancelling
+// the command also kills any child processes it started
+func configureProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		if cmd.Process == nil {
+			return nil
+		}
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	// Don't wait forever on output pipes held open by orphaned descendants
+	cmd.WaitDelay = 2 * time.Second
+}
@@ -0,0 +1,14 @@
+//go:build windows
+
+package tools
+
+import (
+	"os/exec"
+	"time"
+)
+
+// configureProcessGroup limits how long a cancelled command may hold its output pipes.
+// Windows has no process groups to signal, so only the command itself is killed.
+func configureProcessGroup(cmd *exec.Cmd) {
+	cmd.WaitDelay = 2 * time.Second
+}
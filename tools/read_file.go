@@ -1,23 +1,42 @@
 package tools
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"math"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/rohanthewiz/serr"
 )
 
+const (
+	maxReadLength    = 30000     // Characters of file content returned by one read
+	maxLineLength    = 2000      // Longer lines, as in minified bundles, are cut
+	previewHeadLines = 60        // Lines shown from the start of a file too large to return whole
+	previewTailLines = 40        // Lines shown from its end
+	maxByteRange     = 16 * 1024 // Bytes returned by one byte range read
+	binarySniffSize  = 8000      // Bytes examined to decide whether a file is binary
+	binaryDumpSize   = 256       // Bytes of a binary file shown when no range is given
+)
+
 // ReadFileTool implements file reading functionality
 type ReadFileTool struct{}
 
 // GetDefinition returns the tool definition for the AI
 func (t *ReadFileTool) GetDefinition() Tool {
 	return Tool{
-		Name:        "read_file",
-		Description: "Read the contents of a file at the specified path",
+		Name: "read_file",
+		Description: "Read the contents of a file at the specified path, with line numbers. " +
+			"Large files return a preview of their start and end; use offset and limit to page through them. " +
+			"Binary files are shown as a hex dump; use byte_offset and byte_length to inspect part of one.",
 		InputSchema: map[string]interface{}{
 			"type": "object",
 			"properties": map[string]interface{}{
@@ -25,6 +44,22 @@ func (t *ReadFileTool) GetDefinition() Tool {
 					"type":        "string",
 					"description": "The path to the file to read",
 				},
+				"offset": map[string]interface{}{
+					"type":        "integer",
+					"description": "Line number to start reading from (1-based)",
+				},
+				"limit": map[string]interface{}{
+					"type":        "integer",
+					"description": "Maximum number of lines to read",
+				},
+				"byte_offset": map[string]interface{}{
+					"type":        "integer",
+					"description": "Byte position to start reading from (0-based); use instead of offset for binary or single-line files",
+				},
+				"byte_length": map[string]interface{}{
+					"type":        "integer",
+					"description": fmt.Sprintf("Number of bytes to read from byte_offset (default and maximum: %d)", maxByteRange),
+				},
 			},
 			"required": []string{"path"},
 		},
@@ -90,9 +125,11 @@ func (t *ReadFileTool) Execute(input map[string]interface{}) (string, error) {
 	if err != nil {
 		return "", serr.Wrap(err, "failed to expand path")
 	}
+	if err := CheckSensitivePath(expandedPath); err != nil {
+		return "", err
+	}
 
-	// Read the file
-	content, err := os.ReadFile(expandedPath)
+	file, err := os.Open(expandedPath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			// File not found is permanent - the file doesn't exist
@@ -105,11 +142,24 @@ func (t *ReadFileTool) Execute(input map[string]interface{}) (string, error) {
 		// Other errors might be temporary (file locked, system resources, etc)
 		return "", WrapFileSystemError(serr.Wrap(err, fmt.Sprintf("Failed to read file: %s", path)))
 	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", WrapFileSystemError(serr.Wrap(err, fmt.Sprintf("Failed to read file: %s", path)))
+	}
+	if info.IsDir() {
+		return "", NewPermanentError(serr.New(fmt.Sprintf("%s is a directory; use list_dir or tree", path)), "is a directory")
+	}
 
 	// Check if the file is an image
 	if isImageFile(expandedPath) {
 		// For images, return as base64 encoded data with metadata
 		// This allows the AI to "see" the image content
+		content, err := io.ReadAll(file)
+		if err != nil {
+			return "", WrapFileSystemError(serr.Wrap(err, fmt.Sprintf("Failed to read file: %s", path)))
+		}
 		result := FileResult{
 			Type:      "image",
 			Content:   base64.StdEncoding.EncodeToString(content),
@@ -130,20 +180,211 @@ func (t *ReadFileTool) Execute(input map[string]interface{}) (string, error) {
 			filepath.Base(expandedPath), result.MediaType, len(content)), nil
 	}
 
-	// For text files, proceed as before with line numbers
-	lines := strings.Split(string(content), "\n")
-	numberedLines := make([]string, len(lines))
-	for i, line := range lines {
-		numberedLines[i] = fmt.Sprintf("%d\t%s", i+1, line)
+	// Look at the start of the file to tell text from binary
+	sniff := make([]byte, binarySniffSize)
+	n, err := io.ReadFull(file, sniff)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", WrapFileSystemError(serr.Wrap(err, fmt.Sprintf("Failed to read file: %s", path)))
+	}
+	sniff = sniff[:n]
+	binary := isBinary(sniff)
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", WrapFileSystemError(serr.Wrap(err, fmt.Sprintf("Failed to read file: %s", path)))
+	}
+
+	byteOffset, hasByteOffset := GetInt(input, "byte_offset")
+	byteLength, hasByteLength := GetInt(input, "byte_length")
+	if hasByteOffset || hasByteLength {
+		return readByteRange(file, info.Size(), byteOffset, byteLength, binary)
 	}
 
-	result := strings.Join(numberedLines, "\n")
+	if binary {
+		dump := sniff
+		if len(dump) > binaryDumpSize {
+			dump = dump[:binaryDumpSize]
+		}
+		return fmt.Sprintf("[Binary file %s: %d bytes, %s. First %d bytes below; use byte_offset and byte_length to see more]\n%s",
+			path, info.Size(), http.DetectContentType(sniff), len(dump), hex.Dump(dump)), nil
+	}
 
-	// Truncate if too long (similar to TypeScript version)
-	const maxLength = 30000
-	if len(result) > maxLength {
-		result = result[:maxLength] + "\n\n[Content truncated...]"
+	offset, hasOffset := GetInt(input, "offset")
+	limit, hasLimit := GetInt(input, "limit")
+	if hasOffset || hasLimit {
+		if offset < 1 {
+			offset = 1
+		}
+		if !hasLimit || limit < 1 {
+			limit = math.MaxInt32
+		}
+		return readLineRange(file, offset, limit)
 	}
 
-	return result, nil
+	return readWholeFile(file, path, info.Size())
+}
+
+// readLineRange returns limit lines from offset, stopping early if the output grows too long
+func readLineRange(file io.Reader, offset, limit int) (string, error) {
+	var sb strings.Builder
+	last, total := 0, 0
+	full := false
+
+	err := scanLines(file, func(n int, line string) {
+		total = n
+		if n < offset || n >= offset+limit || full {
+			return
+		}
+		if sb.Len()+len(line) > maxReadLength && last >= offset {
+			full = true
+			return
+		}
+		if sb.Len() > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(fmt.Sprintf("%d\t%s", n, line))
+		last = n
+	})
+	if err != nil {
+		return "", WrapFileSystemError(serr.Wrap(err, "Failed to read file"))
+	}
+
+	if last == 0 {
+		return fmt.Sprintf("[No lines from line %d; the file has %d lines]", offset, total), nil
+	}
+	if offset > 1 || last < total {
+		next := ""
+		if last < total {
+			next = fmt.Sprintf("; continue with offset=%d", last+1)
+		}
+		sb.WriteString(fmt.Sprintf("\n\n[Lines %d-%d of %d%s]", offset, last, total, next))
+	}
+	return sb.String(), nil
+}
+
+// readWholeFile returns every line of a file, or, when that would be too long,
+// its first and last lines with a notice on how to read the rest
+func readWholeFile(file io.Reader, path string, size int64) (string, error) {
+	var lines []string
+	length := 0
+	tooLong := false
+	var tail []string // The last previewTailLines lines, once the file proves too long
+
+	total := 0
+	err := scanLines(file, func(n int, line string) {
+		total = n
+		numbered := fmt.Sprintf("%d\t%s", n, line)
+		if !tooLong {
+			lines = append(lines, numbered)
+			length += len(numbered) + 1
+			if length <= maxReadLength {
+				return
+			}
+			tooLong = true
+			if len(lines) > previewHeadLines {
+				tail = append(tail, lines[previewHeadLines:]...)
+				lines = lines[:previewHeadLines]
+			}
+		} else {
+			tail = append(tail, numbered)
+		}
+		if len(tail) > previewTailLines {
+			tail = tail[len(tail)-previewTailLines:]
+		}
+	})
+	if err != nil {
+		return "", WrapFileSystemError(serr.Wrap(err, fmt.Sprintf("Failed to read file: %s", path)))
+	}
+
+	if !tooLong {
+		return strings.Join(lines, "\n"), nil
+	}
+
+	// Keep the preview itself within bounds when the lines are long
+	head := strings.Join(lines, "\n")
+	if len(head) > maxReadLength/2 {
+		head = strings.ToValidUTF8(head[:maxReadLength/2], "") + "\n..."
+	}
+	end := strings.Join(tail, "\n")
+	if len(end) > maxReadLength/2 {
+		end = "...\n" + strings.ToValidUTF8(end[len(end)-maxReadLength/2:], "")
+	}
+
+	return fmt.Sprintf("[%s is too large to show whole: %d lines, %d bytes. Showing the first %d and last %d lines; read other parts with offset and limit]\n\n%s\n\n[... lines %d-%d omitted ...]\n\n%s",
+		path, total, size, len(lines), len(tail), head, len(lines)+1, total-len(tail), end), nil
+}
+
+// readByteRange returns length bytes from offset, as text or, for binary files, a hex dump
+func readByteRange(file io.ReadSeeker, size int64, offset, length int, binary bool) (string, error) {
+	if offset < 0 || int64(offset) > size {
+		return "", NewPermanentError(serr.New(fmt.Sprintf("byte_offset %d is outside the file (%d bytes)", offset, size)), "invalid byte range")
+	}
+	if length <= 0 || length > maxByteRange {
+		length = maxByteRange
+	}
+
+	if _, err := file.Seek(int64(offset), io.SeekStart); err != nil {
+		return "", WrapFileSystemError(serr.Wrap(err, "Failed to seek in file"))
+	}
+	buf := make([]byte, length)
+	n, err := io.ReadFull(file, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", WrapFileSystemError(serr.Wrap(err, "Failed to read file"))
+	}
+	buf = buf[:n]
+
+	header := fmt.Sprintf("[Bytes %d-%d of %d]", offset, offset+n, size)
+	if binary {
+		return header + "\n" + hex.Dump(buf), nil
+	}
+	return header + "\n" + strings.ToValidUTF8(string(buf), "\uFFFD"), nil
+}
+
+// scanLines calls fn with each line of r, numbered from 1, cutting lines longer than maxLineLength
+func scanLines(r io.Reader, fn func(n int, line string)) error {
+	reader := bufio.NewReaderSize(r, 64*1024)
+	for n := 1; ; n++ {
+		var line []byte
+		extra := 0
+		for {
+			part, isPrefix, err := reader.ReadLine()
+			if err == io.EOF {
+				if len(line) == 0 && extra == 0 {
+					return nil
+				}
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if room := maxLineLength - len(line); room > 0 {
+				if len(part) > room {
+					extra += len(part) - room
+					part = part[:room]
+				}
+				line = append(line, part...)
+			} else {
+				extra += len(part)
+			}
+			if !isPrefix {
+				break
+			}
+		}
+
+		text := string(line)
+		if extra > 0 {
+			text = strings.ToValidUTF8(text, "") + fmt.Sprintf(" ... [%d more bytes on this line]", extra)
+		}
+		fn(n, text)
+	}
+}
+
+// isBinary reports whether data, the start of a file, looks like something other than text
+func isBinary(data []byte) bool {
+	if bytes.IndexByte(data, 0) >= 0 {
+		return true
+	}
+	// A multi-byte character may be cut off at the end of the sample
+	for i := 0; i < utf8.UTFMax && len(data) > 0 && !utf8.Valid(data); i++ {
+		data = data[:len(data)-1]
+	}
+	return !utf8.Valid(data)
 }
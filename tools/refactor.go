@@ -0,0 +1,785 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/rohanthewiz/serr"
+	"golang.org/x/tools/go/packages"
+)
+
+// refactorTimeout bounds loading and type-checking the module
+const refactorTimeout = 2 * time.Minute
+
+// maxRefactorErrors is how many build errors a refused refactoring reports
+const maxRefactorErrors = 10
+
+// refactoredFile is a Go file a refactoring rewrites
+type refactoredFile struct {
+	path     string // Where the file is written
+	original []byte
+	content  []byte
+	mode     os.FileMode
+	edits    int
+}
+
+// textEdit replaces the bytes from start to end of a file
+type textEdit struct {
+	start, end int
+	text       string
+}
+
+// RenameSymbolTool renames a Go identifier and every reference to it across the module
+type RenameSymbolTool struct{}
+
+// GetDefinition returns the tool definition
+func (t *RenameSymbolTool) GetDefinition() Tool {
+	return Tool{
+		Name: "rename_symbol",
+		Description: "Rename a Go identifier - a function, type, method, field, variable or constant - and every reference " +
+			"to it in the module, tests included. The module is type-checked, so only references to that declaration change, " +
+			"not other identifiers with the same name. The renamed module is type-checked again before anything is written: " +
+			"if the new name clashes with another, or a type would no longer implement an interface it needs to, nothing " +
+			"changes and the errors are returned. Doc comments starting with the name are renamed too, other comments are " +
+			"not. Prefer this to editing each reference.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"path": map[string]interface{}{
+					"type":        "string",
+					"description": "A Go file where the identifier is declared or used",
+				},
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The identifier's current name",
+				},
+				"line": map[string]interface{}{
+					"type":        "integer",
+					"description": "The line of the file it is on, when the file has different identifiers of that name",
+				},
+				"new_name": map[string]interface{}{
+					"type":        "string",
+					"description": "The new name",
+				},
+				"dry_run": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Only report the references that would change (default: false)",
+				},
+			},
+			"required": []string{"path", "name", "new_name"},
+		},
+	}
+}
+
+// Execute renames the identifier
+func (t *RenameSymbolTool) Execute(input map[string]interface{}) (string, error) {
+	return t.ExecuteContext(context.Background(), input)
+}
+
+// ExecuteContext renames the identifier, stopping the type-checking if ctx is cancelled
+func (t *RenameSymbolTool) ExecuteContext(parent context.Context, input map[string]interface{}) (string, error) {
+	path, _ := GetString(input, "path")
+	name, _ := GetString(input, "name")
+	newName, _ := GetString(input, "new_name")
+	if path == "" || name == "" || newName == "" {
+		return "", NewPermanentError(serr.New("path, name and new_name are required"), "missing parameters")
+	}
+	if !token.IsIdentifier(newName) {
+		return "", NewPermanentError(serr.New(fmt.Sprintf("%q is not a Go identifier", newName)), "invalid name")
+	}
+	if newName == name {
+		return "", NewPermanentError(serr.New("new_name is the current name"), "same name")
+	}
+	line, _ := GetInt(input, "line")
+
+	expanded, err := ExpandPath(path)
+	if err != nil {
+		return "", serr.Wrap(err, "failed to expand path")
+	}
+	if expanded, err = filepath.Abs(expanded); err != nil {
+		return "", serr.Wrap(err, "failed to resolve path")
+	}
+	root, _, err := goModule(filepath.Dir(expanded))
+	if err != nil {
+		return "", NewPermanentError(err, "not in a Go module")
+	}
+
+	ctx, cancel := context.WithTimeout(parent, refactorTimeout)
+	defer cancel()
+
+	pkgs, err := loadModulePackages(ctx, root, nil)
+	if err != nil {
+		return "", err
+	}
+	target, err := findRenameTarget(pkgs, expanded, name, line)
+	if err != nil {
+		return "", NewPermanentError(err, "identifier not found")
+	}
+
+	edits := renameEdits(pkgs, root, target, newName)
+	files, err := applyEdits(edits)
+	if err != nil {
+		return "", err
+	}
+
+	// The renamed module must build as well as it did
+	overlay := make(map[string][]byte, len(files))
+	for _, file := range files {
+		overlay[file.path] = file.content
+	}
+	renamed, err := loadModulePackages(ctx, root, overlay)
+	if err != nil {
+		return "", err
+	}
+	if introduced := newPackageErrors(pkgs, renamed); len(introduced) > 0 {
+		return "", NewPermanentError(serr.New(fmt.Sprintf("%s was not renamed: the module would not build:\n%s",
+			name, strings.Join(introduced, "\n"))), "rename breaks the build")
+	}
+
+	changes := 0
+	for _, file := range files {
+		changes += file.edits
+	}
+	summary := refactorFileList(root, files)
+	if dryRun, _ := GetBool(input, "dry_run"); dryRun {
+		return fmt.Sprintf("Would rename %s to %s: %d changes in %d file(s)\n%s", name, newName, changes, len(files), summary), nil
+	}
+
+	if err := writeRefactoredFiles(files); err != nil {
+		return "", err
+	}
+	for _, file := range files {
+		NotifyFileChange(file.path, "modified")
+	}
+	return fmt.Sprintf("Renamed %s to %s: %d changes in %d file(s)\n%s", name, newName, changes, len(files), summary), nil
+}
+
+// MovePackageTool moves a Go package's directory within its module, rewriting the
+// import paths that name it
+type MovePackageTool struct{}
+
+// GetDefinition returns the tool definition
+func (t *MovePackageTool) GetDefinition() Tool {
+	return Tool{
+		Name: "move_package",
+		Description: "Move a Go package's directory, with its subpackages, to another directory of the module, and rewrite " +
+			"every import of them in the module. When the package is named after its directory, it is renamed after the new " +
+			"one, and the files importing it are changed to use the new name. Import paths outside Go files, in scripts or " +
+			"docs, are not changed. Use move for files that stay in their package.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"source": map[string]interface{}{
+					"type":        "string",
+					"description": "The package's directory",
+				},
+				"destination": map[string]interface{}{
+					"type":        "string",
+					"description": "The directory to move it to, which must not exist yet",
+				},
+				"dry_run": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Only report the files that would change (default: false)",
+				},
+			},
+			"required": []string{"source", "destination"},
+		},
+	}
+}
+
+// Execute moves the package
+func (t *MovePackageTool) Execute(input map[string]interface{}) (string, error) {
+	source, _ := GetString(input, "source")
+	destination, _ := GetString(input, "destination")
+	if source == "" || destination == "" {
+		return "", NewPermanentError(serr.New("source and destination are required"), "missing parameters")
+	}
+
+	src, err := absPath(source)
+	if err != nil {
+		return "", err
+	}
+	dst, err := absPath(destination)
+	if err != nil {
+		return "", err
+	}
+
+	info, err := os.Stat(src)
+	if err != nil || !info.IsDir() {
+		return "", NewPermanentError(serr.New(fmt.Sprintf("%s is not a directory", source)), "source not found")
+	}
+	if _, err := os.Stat(dst); err == nil {
+		return "", NewPermanentError(serr.New(fmt.Sprintf("%s already exists", destination)), "destination exists")
+	}
+	if _, err := os.Stat(filepath.Join(src, "go.mod")); err == nil {
+		return "", NewPermanentError(serr.New(fmt.Sprintf("%s is a module of its own, not a package", source)), "source is a module")
+	}
+
+	root, modulePath, err := goModule(src)
+	if err != nil {
+		return "", NewPermanentError(err, "not in a Go module")
+	}
+	relSrc, err := filepath.Rel(root, src)
+	if err != nil || relSrc == "." {
+		return "", NewPermanentError(serr.New("the module's root can't be moved"), "source is the module root")
+	}
+	relDst, err := filepath.Rel(root, dst)
+	if err != nil || relDst == "." || strings.HasPrefix(relDst, "..") {
+		return "", NewPermanentError(serr.New(fmt.Sprintf("%s is outside the module at %s", destination, root)), "destination outside module")
+	}
+	if relDst == relSrc || strings.HasPrefix(relDst, relSrc+string(filepath.Separator)) {
+		return "", NewPermanentError(serr.New("a package can't be moved into itself"), "destination inside source")
+	}
+
+	oldImport := modulePath + "/" + filepath.ToSlash(relSrc)
+	newImport := modulePath + "/" + filepath.ToSlash(relDst)
+
+	oldName, err := goPackageName(src)
+	if err != nil {
+		return "", NewPermanentError(err, "not a Go package")
+	}
+	newName := oldName
+	if base := filepath.Base(dst); oldName == filepath.Base(src) && oldName != "main" && token.IsIdentifier(base) {
+		newName = base
+	}
+
+	files, moved, err := movePackageEdits(root, src, oldImport, newImport, oldName, newName)
+	if err != nil {
+		return "", err
+	}
+
+	heading := fmt.Sprintf("package %s to %s", oldImport, newImport)
+	if newName != oldName {
+		heading += fmt.Sprintf(", renamed from %s to %s", oldName, newName)
+	}
+	if dryRun, _ := GetBool(input, "dry_run"); dryRun {
+		return fmt.Sprintf("Would move %s: %d file(s) moved, %d file(s) rewritten\n%s", heading, moved, len(files),
+			refactorFileList(root, files)), nil
+	}
+
+	// Move the directory, then write the rewritten files where they now are
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return "", WrapFileSystemError(serr.Wrap(err, "failed to create destination's parent"))
+	}
+	if err := os.Rename(src, dst); err != nil {
+		return "", WrapFileSystemError(serr.Wrap(err, fmt.Sprintf("failed to move %s to %s", source, destination)))
+	}
+	for _, file := range files {
+		if rel, err := filepath.Rel(src, file.path); err == nil && !strings.HasPrefix(rel, "..") {
+			file.path = filepath.Join(dst, rel)
+		}
+	}
+	if err := writeRefactoredFiles(files); err != nil {
+		os.Rename(dst, src)
+		return "", err
+	}
+
+	NotifyFileChange(src, "deleted")
+	NotifyFileChange(dst, "created")
+	for _, file := range files {
+		NotifyFileChange(file.path, "modified")
+	}
+	return fmt.Sprintf("Moved %s: %d file(s) moved, %d file(s) rewritten\n%s", heading, moved, len(files),
+		refactorFileList(root, files)), nil
+}
+
+// absPath expands and resolves a path
+func absPath(path string) (string, error) {
+	expanded, err := ExpandPath(path)
+	if err != nil {
+		return "", serr.Wrap(err, "failed to expand path")
+	}
+	abs, err := filepath.Abs(expanded)
+	if err != nil {
+		return "", serr.Wrap(err, "failed to resolve path")
+	}
+	return abs, nil
+}
+
+// goModule returns the root directory and path of the Go module holding dir
+func goModule(dir string) (string, string, error) {
+	for current := dir; ; current = filepath.Dir(current) {
+		if file, err := os.Open(filepath.Join(current, "go.mod")); err == nil {
+			defer file.Close()
+			scanner := bufio.NewScanner(file)
+			for scanner.Scan() {
+				line := strings.TrimSpace(scanner.Text())
+				if strings.HasPrefix(line, "module ") {
+					return current, strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "module ")), "\""), nil
+				}
+			}
+			return "", "", serr.New(current + "/go.mod declares no module")
+		}
+		if parent := filepath.Dir(current); parent == current {
+			return "", "", serr.New(fmt.Sprintf("%s is not in a Go module: no go.mod above it", dir))
+		}
+	}
+}
+
+// loadModulePackages type-checks every package of the module, with its tests. Files in
+// overlay are checked with their content there instead of on disk.
+func loadModulePackages(ctx context.Context, root string, overlay map[string][]byte) ([]*packages.Package, error) {
+	cfg := &packages.Config{
+		Context: ctx,
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedSyntax | packages.NeedTypes |
+			packages.NeedTypesInfo | packages.NeedImports | packages.NeedDeps,
+		Dir:     root,
+		Tests:   true,
+		Overlay: overlay,
+	}
+	pkgs, err := packages.Load(cfg, "./...")
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, serr.New("type-checking the module took too long or was cancelled")
+		}
+		return nil, serr.Wrap(err, "failed to load the module's packages")
+	}
+	return pkgs, nil
+}
+
+// objectKey identifies a declaration across packages: the same object may be loaded once
+// for a package and again for its test variant
+func objectKey(fset *token.FileSet, obj types.Object) string {
+	pos := fset.Position(obj.Pos())
+	return fmt.Sprintf("%s:%d:%d:%s", pos.Filename, pos.Line, pos.Column, obj.Name())
+}
+
+// renameTarget is the declaration a rename changes
+type renameTarget struct {
+	key      string
+	name     string
+	typeName bool // A type, whose embedded fields are renamed with it
+}
+
+// findRenameTarget finds the one declaration that identifiers called name in the file,
+// on the line if given, refer to
+func findRenameTarget(pkgs []*packages.Package, path, name string, line int) (*renameTarget, error) {
+	found := make(map[string]*renameTarget)
+	var lines []int
+	for _, pkg := range pkgs {
+		for ident, obj := range identObjects(pkg) {
+			if ident.Name != name || obj == nil {
+				continue
+			}
+			pos := pkg.Fset.Position(ident.Pos())
+			if pos.Filename != path || (line > 0 && pos.Line != line) {
+				continue
+			}
+			if err := renameable(obj); err != nil {
+				return nil, err
+			}
+			key := objectKey(pkg.Fset, obj)
+			if _, ok := found[key]; !ok {
+				_, isType := obj.(*types.TypeName)
+				found[key] = &renameTarget{key: key, name: obj.Name(), typeName: isType}
+				lines = append(lines, pos.Line)
+			}
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		if line > 0 {
+			return nil, serr.New(fmt.Sprintf("no identifier %s on line %d of %s", name, line, path))
+		}
+		return nil, serr.New(fmt.Sprintf("no identifier %s in %s, or the file isn't part of the module's packages", name, path))
+	case 1:
+		for _, target := range found {
+			return target, nil
+		}
+	}
+	sort.Ints(lines)
+	return nil, serr.New(fmt.Sprintf("%s names %d different declarations in %s; give the line of the one to rename (%s)",
+		name, len(found), path, strings.Trim(fmt.Sprint(lines), "[]")))
+}
+
+// renameable reports why an object can't be renamed, or nil
+func renameable(obj types.Object) error {
+	switch obj.(type) {
+	case *types.PkgName:
+		return serr.New(obj.Name() + " is an imported package's name; rename the import with an edit")
+	case *types.Label:
+		return serr.New(obj.Name() + " is a label; rename it with an edit")
+	}
+	if obj.Pkg() == nil {
+		return serr.New(obj.Name() + " is predeclared and can't be renamed")
+	}
+	return nil
+}
+
+// identObjects returns the objects the package's identifiers declare or refer to
+func identObjects(pkg *packages.Package) map[*ast.Ident]types.Object {
+	if pkg.TypesInfo == nil {
+		return nil
+	}
+	objects := make(map[*ast.Ident]types.Object, len(pkg.TypesInfo.Defs)+len(pkg.TypesInfo.Uses))
+	for ident, obj := range pkg.TypesInfo.Defs {
+		objects[ident] = obj
+	}
+	for ident, obj := range pkg.TypesInfo.Uses {
+		if _, ok := objects[ident]; !ok || obj != nil {
+			objects[ident] = obj
+		}
+	}
+	return objects
+}
+
+// renameEdits returns the edits renaming every identifier in the module that refers to the
+// target, by file. Files outside it, like the generated test mains, are left alone.
+func renameEdits(pkgs []*packages.Package, root string, target *renameTarget, newName string) map[string][]textEdit {
+	keys := map[string]bool{target.key: true}
+
+	// A type's embedded fields are named after it, so they are renamed too
+	if target.typeName {
+		for _, pkg := range pkgs {
+			if pkg.TypesInfo == nil {
+				continue
+			}
+			for _, obj := range pkg.TypesInfo.Defs {
+				field, ok := obj.(*types.Var)
+				if !ok || !field.Embedded() {
+					continue
+				}
+				fieldType := field.Type()
+				if pointer, ok := fieldType.(*types.Pointer); ok {
+					fieldType = pointer.Elem()
+				}
+				if named, ok := fieldType.(*types.Named); ok && objectKey(pkg.Fset, named.Obj()) == target.key {
+					keys[objectKey(pkg.Fset, field)] = true
+				}
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	edits := make(map[string][]textEdit)
+	for _, pkg := range pkgs {
+		for ident, obj := range identObjects(pkg) {
+			if obj == nil || !keys[objectKey(pkg.Fset, obj)] {
+				continue
+			}
+			pos := pkg.Fset.Position(ident.Pos())
+			at := fmt.Sprintf("%s:%d", pos.Filename, pos.Offset)
+			if seen[at] || !strings.HasPrefix(pos.Filename, root+string(filepath.Separator)) {
+				continue
+			}
+			seen[at] = true
+			edits[pos.Filename] = append(edits[pos.Filename], textEdit{start: pos.Offset, end: pos.Offset + len(ident.Name), text: newName})
+		}
+	}
+
+	// Doc comments start with the name they document
+	documented := make(map[string]bool)
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Syntax {
+			filename := pkg.Fset.Position(file.Pos()).Filename
+			if len(edits[filename]) == 0 || documented[filename] {
+				continue
+			}
+			documented[filename] = true
+			edits[filename] = append(edits[filename], docEdits(pkg.Fset, file, seen, target.name, newName)...)
+		}
+	}
+	return edits
+}
+
+// docEdits renames the doc comments starting with the old name of the declarations renamed,
+// which renamed holds by file and offset
+func docEdits(fset *token.FileSet, file *ast.File, renamed map[string]bool, oldName, newName string) []textEdit {
+	var edits []textEdit
+	check := func(doc *ast.CommentGroup, ident *ast.Ident) {
+		if doc == nil || ident.Name != oldName {
+			return
+		}
+		pos := fset.Position(ident.Pos())
+		if !renamed[fmt.Sprintf("%s:%d", pos.Filename, pos.Offset)] {
+			return
+		}
+		text := doc.List[0].Text
+		if !strings.HasPrefix(text, "// "+oldName) {
+			return
+		}
+		if rest := text[len("// "+oldName):]; rest != "" && (rest[0] == '_' || unicode.IsLetter(rune(rest[0])) || unicode.IsDigit(rune(rest[0]))) {
+			return
+		}
+		start := fset.Position(doc.List[0].Pos()).Offset + len("// ")
+		edits = append(edits, textEdit{start: start, end: start + len(oldName), text: newName})
+	}
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch d := n.(type) {
+		case *ast.FuncDecl:
+			check(d.Doc, d.Name)
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					check(specDoc(s.Doc, d), s.Name)
+				case *ast.ValueSpec:
+					for _, name := range s.Names {
+						check(specDoc(s.Doc, d), name)
+					}
+				}
+			}
+		case *ast.Field:
+			for _, name := range d.Names {
+				check(d.Doc, name)
+			}
+		}
+		return true
+	})
+	return edits
+}
+
+// specDoc returns a spec's doc comment, or its declaration's when it is the only spec
+func specDoc(doc *ast.CommentGroup, decl *ast.GenDecl) *ast.CommentGroup {
+	if doc == nil && len(decl.Specs) == 1 {
+		return decl.Doc
+	}
+	return doc
+}
+
+// newPackageErrors returns the errors in after that before didn't have, ignoring positions
+func newPackageErrors(before, after []*packages.Package) []string {
+	existing := make(map[string]int)
+	for _, pkg := range before {
+		for _, err := range pkg.Errors {
+			existing[err.Msg]++
+		}
+	}
+
+	var introduced []string
+	seen := make(map[string]bool)
+	for _, pkg := range after {
+		for _, err := range pkg.Errors {
+			if existing[err.Msg] > 0 {
+				existing[err.Msg]--
+				continue
+			}
+			text := err.Error()
+			if seen[text] {
+				continue
+			}
+			seen[text] = true
+			introduced = append(introduced, text)
+		}
+	}
+	if len(introduced) > maxRefactorErrors {
+		introduced = append(introduced[:maxRefactorErrors], fmt.Sprintf("... and %d more", len(introduced)-maxRefactorErrors))
+	}
+	return introduced
+}
+
+// goPackageName returns the name of the package in dir, from its first file that isn't
+// an external test
+func goPackageName(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", serr.Wrap(err, "failed to read directory")
+	}
+	fset := token.NewFileSet()
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filepath.Join(dir, entry.Name()), nil, parser.PackageClauseOnly)
+		if err != nil {
+			continue
+		}
+		if name := file.Name.Name; !strings.HasSuffix(name, "_test") {
+			return name, nil
+		}
+	}
+	return "", serr.New(fmt.Sprintf("%s has no Go package", dir))
+}
+
+// movePackageEdits rewrites the module's Go files for a package moved from oldImport to
+// newImport: the imports of it and its subpackages, the package clauses when it is renamed,
+// and the names files use for it. It returns the rewritten files, at their current paths,
+// and how many Go files the move takes with it.
+func movePackageEdits(root, src, oldImport, newImport, oldName, newName string) ([]*refactoredFile, int, error) {
+	edits := make(map[string][]textEdit)
+	moved := 0
+	fset := token.NewFileSet()
+
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			name := entry.Name()
+			if path != root && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "vendor" || name == "testdata") {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil && path != root {
+				return filepath.SkipDir // A module of its own
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") {
+			return nil
+		}
+		if rel, err := filepath.Rel(src, path); err == nil && !strings.HasPrefix(rel, "..") {
+			moved++
+		}
+
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if file == nil {
+			return nil // Not Go that parses; left alone
+		}
+		inPackage := filepath.Dir(path) == src
+		fileEdits := importEdits(fset, file, err == nil, oldImport, newImport, oldName, newName)
+		if inPackage && newName != oldName {
+			switch file.Name.Name {
+			case oldName:
+				fileEdits = append(fileEdits, identEdit(fset, file.Name, newName))
+			case oldName + "_test":
+				fileEdits = append(fileEdits, identEdit(fset, file.Name, newName+"_test"))
+			}
+		}
+		if len(fileEdits) > 0 {
+			edits[path] = fileEdits
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, WrapFileSystemError(serr.Wrap(err, "failed to read the module's files"))
+	}
+
+	files, err := applyEdits(edits)
+	return files, moved, err
+}
+
+// importEdits rewrites a file's imports of the moved package and its subpackages. When
+// the package is renamed, the file's references to it use the new name, or keep the old
+// one through an import name if the new one is taken in the file.
+func importEdits(fset *token.FileSet, file *ast.File, parsed bool, oldImport, newImport, oldName, newName string) []textEdit {
+	var edits []textEdit
+	renameRefs := false
+	for _, spec := range file.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil || (path != oldImport && !strings.HasPrefix(path, oldImport+"/")) {
+			continue
+		}
+		start := fset.Position(spec.Path.Pos()).Offset
+		edits = append(edits, textEdit{start: start, end: start + len(spec.Path.Value), text: strconv.Quote(newImport + path[len(oldImport):])})
+
+		if path != oldImport || spec.Name != nil || newName == oldName {
+			continue
+		}
+		if !parsed || identInFile(file, newName) {
+			edits = append(edits, textEdit{start: start, end: start, text: oldName + " "})
+			continue
+		}
+		renameRefs = true
+	}
+
+	if renameRefs {
+		ast.Inspect(file, func(n ast.Node) bool {
+			if sel, ok := n.(*ast.SelectorExpr); ok {
+				// A qualifier is left unresolved by the parser; a local variable of the name isn't
+				if x, ok := sel.X.(*ast.Ident); ok && x.Name == oldName && x.Obj == nil {
+					edits = append(edits, identEdit(fset, x, newName))
+				}
+			}
+			return true
+		})
+	}
+	return edits
+}
+
+// identInFile reports whether the file has an identifier of the name
+func identInFile(file *ast.File, name string) bool {
+	found := false
+	ast.Inspect(file, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok && ident.Name == name {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// identEdit replaces an identifier
+func identEdit(fset *token.FileSet, ident *ast.Ident, text string) textEdit {
+	start := fset.Position(ident.Pos()).Offset
+	return textEdit{start: start, end: start + len(ident.Name), text: text}
+}
+
+// applyEdits returns each file with its edits made, in path order. Files that were
+// gofmt-formatted are formatted again, as a longer name can change their alignment.
+func applyEdits(edits map[string][]textEdit) ([]*refactoredFile, error) {
+	paths := make([]string, 0, len(edits))
+	for path := range edits {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	files := make([]*refactoredFile, 0, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, WrapFileSystemError(serr.Wrap(err, "failed to read file", "path", path))
+		}
+		original, err := os.ReadFile(path)
+		if err != nil {
+			return nil, WrapFileSystemError(serr.Wrap(err, "failed to read file", "path", path))
+		}
+
+		fileEdits := edits[path]
+		sort.Slice(fileEdits, func(i, j int) bool { return fileEdits[i].start > fileEdits[j].start })
+		content := append([]byte(nil), original...)
+		for _, edit := range fileEdits {
+			content = append(content[:edit.start], append([]byte(edit.text), content[edit.end:]...)...)
+		}
+		if formatted, err := format.Source(original); err == nil && bytes.Equal(formatted, original) {
+			if formatted, err := format.Source(content); err == nil {
+				content = formatted
+			}
+		}
+
+		files = append(files, &refactoredFile{path: path, original: original, content: content, mode: info.Mode().Perm(), edits: len(fileEdits)})
+	}
+	return files, nil
+}
+
+// writeRefactoredFiles writes every file, putting back the earlier ones if one fails
+func writeRefactoredFiles(files []*refactoredFile) error {
+	for i, file := range files {
+		if err := os.WriteFile(file.path, file.content, file.mode); err != nil {
+			for _, done := range files[:i+1] {
+				os.WriteFile(done.path, done.original, done.mode)
+			}
+			return WrapFileSystemError(serr.New("nothing was changed, the files written were put back: " + err.Error()))
+		}
+	}
+	return nil
+}
+
+// refactorFileList lists the rewritten files relative to the module, with their edits
+func refactorFileList(root string, files []*refactoredFile) string {
+	var sb strings.Builder
+	for _, file := range files {
+		rel, err := filepath.Rel(root, file.path)
+		if err != nil {
+			rel = file.path
+		}
+		fmt.Fprintf(&sb, "  M %s (%d)\n", rel, file.edits)
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
@@ -0,0 +1,307 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// ReplaceSnippetTool replaces a snippet of code found by its content rather than its line numbers,
+// so edits keep working after earlier edits shift the file
+type ReplaceSnippetTool struct{}
+
+// GetDefinition returns the tool definition for the AI
+func (t *ReplaceSnippetTool) GetDefinition() Tool {
+	return Tool{
+		Name: "replace_snippet",
+		Description: "Replace a snippet of a file, located by its text instead of line numbers. " +
+			"old_text must match exactly one place in the file; include a few surrounding lines to make it unique. " +
+			"Differences in indentation and spacing are tolerated when there is no exact match.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"path": map[string]interface{}{
+					"type":        "string",
+					"description": "The path to the file to edit",
+				},
+				"old_text": map[string]interface{}{
+					"type":        "string",
+					"description": "The existing text to replace, copied from the file",
+				},
+				"new_text": map[string]interface{}{
+					"type":        "string",
+					"description": "The replacement text. Use an empty string to delete old_text.",
+				},
+				"replace_all": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Replace every match instead of requiring a unique one (default: false)",
+				},
+			},
+			"required": []string{"path", "old_text", "new_text"},
+		},
+	}
+}
+
+// SnippetReplacement is the outcome of ReplaceSnippet
+type SnippetReplacement struct {
+	Content string // The file content after the replacement
+	Lines   []int  // The line each replaced match started at
+	Fuzzy   bool   // Matched with whitespace differences ignored
+}
+
+// Execute replaces the snippet in the file
+func (t *ReplaceSnippetTool) Execute(input map[string]interface{}) (string, error) {
+	path, ok := GetString(input, "path")
+	if !ok || path == "" {
+		return "", serr.New("path is required")
+	}
+	oldText, ok := GetString(input, "old_text")
+	if !ok {
+		return "", serr.New("old_text is required")
+	}
+	newText, ok := GetString(input, "new_text")
+	if !ok {
+		return "", serr.New("new_text is required")
+	}
+	replaceAll, _ := input["replace_all"].(bool)
+
+	expandedPath, err := ExpandPath(path)
+	if err != nil {
+		return "", serr.Wrap(err, "failed to expand path")
+	}
+
+	info, err := os.Stat(expandedPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", NewPermanentError(serr.New("file not found: "+path), "file not found")
+		}
+		return "", WrapFileSystemError(serr.Wrap(err, "failed to stat file"))
+	}
+	content, err := os.ReadFile(expandedPath)
+	if err != nil {
+		return "", WrapFileSystemError(serr.Wrap(err, "failed to read file"))
+	}
+
+	replacement, err := ReplaceSnippet(string(content), oldText, newText, replaceAll)
+	if err != nil {
+		return "", NewPermanentError(err, "snippet not replaced")
+	}
+
+	if err := os.WriteFile(expandedPath, []byte(replacement.Content), info.Mode().Perm()); err != nil {
+		if os.IsPermission(err) {
+			return "", NewPermanentError(serr.Wrap(err, fmt.Sprintf("Permission denied writing file: %s", path)), "permission denied")
+		}
+		return "", WrapFileSystemError(serr.Wrap(err, fmt.Sprintf("Failed to write file: %s", path)))
+	}
+	NotifyFileChange(path, "modified")
+
+	lines := make([]string, len(replacement.Lines))
+	for i, line := range replacement.Lines {
+		lines[i] = fmt.Sprint(line)
+	}
+	result := fmt.Sprintf("Replaced %d occurrence(s) in %s at line %s", len(replacement.Lines), path, strings.Join(lines, ", "))
+	if replacement.Fuzzy {
+		result += " (matched ignoring whitespace differences; the new text was re-indented to fit)"
+	}
+	return result, nil
+}
+
+// ReplaceSnippet replaces oldText in content with newText. An exact match is tried first,
+// then a line by line match that ignores differences in whitespace. Unless replaceAll is set,
+// more than one match is an error, so an edit never lands in the wrong place.
+func ReplaceSnippet(content, oldText, newText string, replaceAll bool) (*SnippetReplacement, error) {
+	if strings.TrimSpace(oldText) == "" {
+		return nil, serr.New("old_text is empty")
+	}
+	if oldText == newText {
+		return nil, serr.New("old_text and new_text are the same")
+	}
+
+	// Exact matches
+	var offsets []int
+	for start := 0; ; {
+		i := strings.Index(content[start:], oldText)
+		if i < 0 {
+			break
+		}
+		offsets = append(offsets, start+i)
+		start += i + len(oldText)
+	}
+	if len(offsets) > 0 {
+		lines := make([]int, len(offsets))
+		for i, offset := range offsets {
+			lines[i] = strings.Count(content[:offset], "\n") + 1
+		}
+		if len(offsets) > 1 && !replaceAll {
+			return nil, ambiguousSnippetError(lines)
+		}
+		return &SnippetReplacement{Content: strings.ReplaceAll(content, oldText, newText), Lines: lines}, nil
+	}
+
+	return replaceSnippetFuzzy(content, oldText, newText, replaceAll)
+}
+
+// replaceSnippetFuzzy matches whole lines with runs of whitespace treated as equal
+// and leading and trailing whitespace ignored, then re-indents the new text to fit
+func replaceSnippetFuzzy(content, oldText, newText string, replaceAll bool) (*SnippetReplacement, error) {
+	fileLines := strings.Split(content, "\n")
+	oldLines := trimBlankLines(strings.Split(oldText, "\n"))
+	newLines := trimBlankLines(strings.Split(newText, "\n"))
+
+	normFile := make([]string, len(fileLines))
+	for i, line := range fileLines {
+		normFile[i] = normalizeWhitespace(line)
+	}
+	normOld := make([]string, len(oldLines))
+	for i, line := range oldLines {
+		normOld[i] = normalizeWhitespace(line)
+	}
+
+	var matches []int
+	for i := 0; i+len(normOld) <= len(normFile); i++ {
+		if linesEqual(normFile[i:i+len(normOld)], normOld) {
+			matches = append(matches, i)
+			i += len(normOld) - 1
+		}
+	}
+
+	if len(matches) == 0 {
+		return nil, snippetNotFoundError(normFile, normOld, fileLines, oldLines)
+	}
+	lines := make([]int, len(matches))
+	for i, match := range matches {
+		lines[i] = match + 1
+	}
+	if len(matches) > 1 && !replaceAll {
+		return nil, ambiguousSnippetError(lines)
+	}
+
+	// Replace from the end so earlier matches keep their positions
+	for m := len(matches) - 1; m >= 0; m-- {
+		at := matches[m]
+		replacement := reindent(newLines, oldLines, fileLines[at:at+len(oldLines)])
+		updated := append([]string{}, fileLines[:at]...)
+		updated = append(updated, replacement...)
+		fileLines = append(updated, fileLines[at+len(oldLines):]...)
+	}
+
+	return &SnippetReplacement{Content: strings.Join(fileLines, "\n"), Lines: lines, Fuzzy: true}, nil
+}
+
+// ambiguousSnippetError reports the lines a non-unique snippet matches
+func ambiguousSnippetError(lines []int) error {
+	where := make([]string, len(lines))
+	for i, line := range lines {
+		where[i] = fmt.Sprint(line)
+	}
+	return serr.New(fmt.Sprintf("old_text matches %d places (lines %s); include more surrounding lines so it matches only one, or set replace_all",
+		len(lines), strings.Join(where, ", ")))
+}
+
+// snippetNotFoundError points at the place in the file that comes closest to the snippet
+func snippetNotFoundError(normFile, normOld, fileLines, oldLines []string) error {
+	bestAt, bestLen := -1, 0
+	for i := range normFile {
+		n := 0
+		for n < len(normOld) && i+n < len(normFile) && normFile[i+n] == normOld[n] {
+			n++
+		}
+		if n > bestLen {
+			bestAt, bestLen = i, n
+		}
+	}
+
+	if bestAt < 0 {
+		return serr.New(fmt.Sprintf("old_text was not found; its first line %q is not in the file. Read the file again and copy the text exactly",
+			strings.TrimSpace(oldLines[0])))
+	}
+	if bestAt+bestLen >= len(fileLines) {
+		return serr.New(fmt.Sprintf("old_text was not found; the closest match starts at line %d but the file ends after %d matching lines",
+			bestAt+1, bestLen))
+	}
+	return serr.New(fmt.Sprintf("old_text was not found; the closest match starts at line %d, but line %d is %q where old_text has %q",
+		bestAt+1, bestAt+bestLen+1, strings.TrimSpace(fileLines[bestAt+bestLen]), strings.TrimSpace(oldLines[bestLen])))
+}
+
+// trimBlankLines drops blank lines from the start and end of lines
+func trimBlankLines(lines []string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// normalizeWhitespace trims a line and collapses its runs of whitespace to single spaces
+func normalizeWhitespace(line string) string {
+	return strings.Join(strings.Fields(line), " ")
+}
+
+// leadingWhitespace returns the indentation of line
+func leadingWhitespace(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+// reindent moves new lines from the indentation of the snippet as written to that of the file.
+// Each indentation used by oldLines maps to the one on the matching file line; a deeper one
+// keeps what it adds to the longest of those it starts with, and other lines are shifted by
+// the difference at the first line.
+func reindent(newLines, oldLines, fileLines []string) []string {
+	indents := make(map[string]string)
+	for i, line := range oldLines {
+		if strings.TrimSpace(line) != "" {
+			if _, ok := indents[leadingWhitespace(line)]; !ok {
+				indents[leadingWhitespace(line)] = leadingWhitespace(fileLines[i])
+			}
+		}
+	}
+	from, to := leadingWhitespace(oldLines[0]), leadingWhitespace(fileLines[0])
+
+	out := make([]string, len(newLines))
+	for i, line := range newLines {
+		indent := leadingWhitespace(line)
+		mapped, ok := indents[indent]
+		prefix := longestIndent(indents, indent)
+		switch {
+		case strings.TrimSpace(line) == "":
+			out[i] = ""
+		case ok:
+			out[i] = mapped + line[len(indent):]
+		case prefix != "":
+			out[i] = indents[prefix] + line[len(prefix):]
+		case strings.HasPrefix(line, from):
+			out[i] = to + line[len(from):]
+		default:
+			out[i] = line
+		}
+	}
+	return out
+}
+
+// longestIndent returns the longest non-empty indentation in indents that indent starts with
+func longestIndent(indents map[string]string, indent string) string {
+	longest := ""
+	for prefix := range indents {
+		if len(prefix) > len(longest) && strings.HasPrefix(indent, prefix) {
+			longest = prefix
+		}
+	}
+	return longest
+}
+
+// linesEqual reports whether a and b hold the same lines
+func linesEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
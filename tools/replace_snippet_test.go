@@ -0,0 +1,144 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReplaceSnippet(t *testing.T) {
+	const file = "func a() {\n\tx := 1\n\treturn x\n}\n\nfunc b() {\n\tx := 1\n\treturn x + 1\n}\n"
+
+	tests := []struct {
+		name       string
+		content    string
+		oldText    string
+		newText    string
+		replaceAll bool
+		want       *SnippetReplacement
+		wantErr    string
+	}{
+		{
+			name:    "unique exact match",
+			content: file,
+			oldText: "\treturn x + 1",
+			newText: "\treturn x + 2",
+			want:    &SnippetReplacement{Content: strings.Replace(file, "x + 1", "x + 2", 1), Lines: []int{8}},
+		},
+		{
+			name:    "multiple exact matches refused",
+			content: file,
+			oldText: "\tx := 1",
+			newText: "\tx := 2",
+			wantErr: "old_text matches 2 places (lines 2, 7)",
+		},
+		{
+			name:       "multiple exact matches with replace_all",
+			content:    file,
+			oldText:    "\tx := 1",
+			newText:    "\tx := 2",
+			replaceAll: true,
+			want:       &SnippetReplacement{Content: strings.ReplaceAll(file, "x := 1", "x := 2"), Lines: []int{2, 7}},
+		},
+		{
+			name:    "indentation drift",
+			content: file,
+			oldText: "func b() {\n    x := 1\n    return x + 1\n}",
+			newText: "func b() {\n    x := 1\n    if x > 0 {\n        return x\n    }\n    return x + 1\n}",
+			want: &SnippetReplacement{
+				Content: strings.Replace(file, "\treturn x + 1", "\tif x > 0 {\n\t    return x\n\t}\n\treturn x + 1", 1),
+				Lines:   []int{6},
+				Fuzzy:   true,
+			},
+		},
+		{
+			name:    "inner spacing and surrounding blank lines ignored",
+			content: "if a  &&  b {\n\tdo()\n}\n",
+			oldText: "\n\nif a && b {\n\tdo()\n}\n\n",
+			newText: "if a || b {\n\tdo()\n}",
+			want:    &SnippetReplacement{Content: "if a || b {\n\tdo()\n}\n", Lines: []int{1}, Fuzzy: true},
+		},
+		{
+			name:    "multiple whitespace-drift matches refused",
+			content: file,
+			oldText: "  x := 1",
+			newText: "  x := 2",
+			wantErr: "old_text matches 2 places (lines 2, 7)",
+		},
+		{
+			name:    "not found points at the closest match",
+			content: file,
+			oldText: "func b() {\n\tx := 1\n\treturn x + 3\n}",
+			newText: "",
+			wantErr: `the closest match starts at line 6, but line 8 is "return x + 1" where old_text has "return x + 3"`,
+		},
+		{
+			name:    "first line not in the file",
+			content: file,
+			oldText: "func c() {",
+			newText: "func d() {",
+			wantErr: `its first line "func c() {" is not in the file`,
+		},
+		{
+			name:    "empty old text",
+			content: file,
+			oldText: " \n ",
+			newText: "x",
+			wantErr: "old_text is empty",
+		},
+		{
+			name:    "no change",
+			content: file,
+			oldText: "x := 1",
+			newText: "x := 1",
+			wantErr: "old_text and new_text are the same",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReplaceSnippet(tt.content, tt.oldText, tt.newText, tt.replaceAll)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReplaceSnippet: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReplaceSnippet =\n%#v\nwant\n%#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplaceSnippetToolLeavesFileOnRefusal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.go")
+	content := "a := 1\nb := 1\n"
+	if err := os.WriteFile(path, []byte(content), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	tool := &ReplaceSnippetTool{}
+	if _, err := tool.Execute(map[string]interface{}{"path": path, "old_text": ":= 1", "new_text": ":= 2"}); err == nil {
+		t.Fatal("an ambiguous snippet was replaced")
+	} else if _, ok := err.(*PermanentError); !ok {
+		t.Errorf("error %v is not permanent", err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != content {
+		t.Errorf("file changed to %q", got)
+	}
+
+	if _, err := tool.Execute(map[string]interface{}{"path": path, "old_text": "b := 1", "new_text": "b := 2"}); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "a := 1\nb := 2\n" {
+		t.Errorf("file = %q", got)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0640 {
+		t.Errorf("mode = %v, want 0640", info.Mode().Perm())
+	}
+}
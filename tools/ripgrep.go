@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
 	"os/exec"
 	"strconv"
 	"strings"
@@ -140,6 +141,11 @@ func (t *RipgrepTool) Execute(input map[string]interface{}) (string, error) {
 		return "", serr.Wrap(err, "failed to expand path")
 	}
 	searchPath = expandedPath
+	if info, err := os.Stat(searchPath); err == nil && !info.IsDir() {
+		if err := CheckSensitivePath(searchPath); err != nil {
+			return "", err
+		}
+	}
 
 	outputMode, _ := GetString(input, "output_mode")
 	if outputMode == "" {
@@ -253,6 +259,9 @@ func (t *RipgrepTool) buildArgs(input map[string]interface{}, pattern, searchPat
 		args = append(args, "--multiline", "--multiline-dotall")
 	}
 
+	// Leave out sensitive files
+	args = append(args, sensitiveRipgrepGlobs()...)
+
 	// Add pattern and path
 	args = append(args, pattern)
 	args = append(args, searchPath)
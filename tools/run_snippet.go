@@ -0,0 +1,221 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/build"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// Bounds on a snippet's run
+const (
+	defaultSnippetTimeout = 30 * time.Second
+	maxSnippetTimeout     = 120 * time.Second
+	maxSnippetLength      = 100000    // Characters of code
+	maxSnippetOutput      = 64 * 1024 // Bytes kept of stdout, and of stderr
+)
+
+// snippetLanguage is how run_snippet runs a language: the file the snippet is written to,
+// and the commands that run it, the first installed being used
+type snippetLanguage struct {
+	name     string
+	file     string
+	commands [][]string
+}
+
+// snippetLanguages are the languages run_snippet runs
+var snippetLanguages = map[string]snippetLanguage{
+	"go":         {name: "Go", file: "main.go", commands: [][]string{{"go", "run", "main.go"}}},
+	"python":     {name: "Python", file: "main.py", commands: [][]string{{"python3", "main.py"}, {"python", "main.py"}}},
+	"javascript": {name: "JavaScript", file: "main.js", commands: [][]string{{"node", "main.js"}}},
+}
+
+// snippetEnvVars are the variables a snippet keeps from rcode's environment. The others,
+// API keys and tokens among them, are left out.
+var snippetEnvVars = []string{"PATH", "LANG", "LC_ALL", "TZ", "GOROOT", "GOPATH", "GOMODCACHE", "GOCACHE",
+	"SYSTEMROOT", "WINDIR", "COMSPEC", "PATHEXT"}
+
+// RunSnippetTool runs a short Go, Python or JavaScript program in a temporary directory
+type RunSnippetTool struct{}
+
+// GetDefinition returns the tool definition
+func (t *RunSnippetTool) GetDefinition() Tool {
+	return Tool{
+		Name: "run_snippet",
+		Description: "Run a short Go, Python or JavaScript program and return its stdout, stderr and exit code. Use it to check " +
+			"a computation, a regular expression or how a library call behaves, without touching the project: the program " +
+			"runs in an empty temporary directory, removed afterwards, with none of rcode's secrets in its environment, and " +
+			"is killed at the timeout. Go code is a whole program, package main with func main, using the standard library. " +
+			"Print what you want to see.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"language": map[string]interface{}{
+					"type":        "string",
+					"description": "The snippet's language",
+					"enum":        []string{"go", "python", "javascript"},
+				},
+				"code": map[string]interface{}{
+					"type":        "string",
+					"description": "The program to run",
+				},
+				"timeout": map[string]interface{}{
+					"type":        "integer",
+					"description": fmt.Sprintf("Seconds the program may run (default: %d, at most %d)", int(defaultSnippetTimeout.Seconds()), int(maxSnippetTimeout.Seconds())),
+				},
+			},
+			"required": []string{"language", "code"},
+		},
+	}
+}
+
+// Execute runs the snippet
+func (t *RunSnippetTool) Execute(input map[string]interface{}) (string, error) {
+	return t.ExecuteContext(context.Background(), input)
+}
+
+// ExecuteContext runs the snippet, killing it and any processes it started if ctx is cancelled
+func (t *RunSnippetTool) ExecuteContext(parent context.Context, input map[string]interface{}) (string, error) {
+	code, _ := GetString(input, "code")
+	if strings.TrimSpace(code) == "" {
+		return "", NewPermanentError(serr.New("code parameter is required"), "missing code")
+	}
+	if n := len([]rune(code)); n > maxSnippetLength {
+		return "", NewPermanentError(serr.New(fmt.Sprintf("the snippet is %d characters, over the %d allowed", n, maxSnippetLength)), "snippet too long")
+	}
+	language, _ := GetString(input, "language")
+	lang, ok := snippetLanguages[strings.ToLower(language)]
+	if !ok {
+		return "", NewPermanentError(serr.New(fmt.Sprintf("unknown language %q; use go, python or javascript", language)), "unknown language")
+	}
+	command, err := lang.command()
+	if err != nil {
+		return "", NewPermanentError(err, "runtime not installed")
+	}
+
+	timeout := defaultSnippetTimeout
+	if seconds, ok := GetInt(input, "timeout"); ok && seconds > 0 {
+		timeout = time.Duration(seconds) * time.Second
+	}
+	if timeout > maxSnippetTimeout {
+		timeout = maxSnippetTimeout
+	}
+
+	dir, err := os.MkdirTemp("", "rcode-snippet-")
+	if err != nil {
+		return "", serr.Wrap(err, "failed to create the snippet's directory")
+	}
+	defer os.RemoveAll(dir)
+	if err := os.WriteFile(filepath.Join(dir, lang.file), []byte(code), 0600); err != nil {
+		return "", serr.Wrap(err, "failed to write the snippet")
+	}
+
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Dir = dir
+	cmd.Env = snippetEnv(dir)
+	configureProcessGroup(cmd)
+	var stdout, stderr snippetOutput
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	start := time.Now()
+	err = cmd.Run()
+	elapsed := time.Since(start).Round(10 * time.Millisecond)
+
+	if parent.Err() == context.Canceled {
+		return "", serr.New("Snippet cancelled")
+	}
+
+	var status string
+	switch exitErr, isExit := err.(*exec.ExitError); {
+	case ctx.Err() == context.DeadlineExceeded:
+		status = fmt.Sprintf("Timed out after %s and was killed", timeout)
+	case isExit:
+		status = fmt.Sprintf("Exit code %d, in %s", exitErr.ExitCode(), elapsed)
+	case err != nil:
+		return "", serr.Wrap(err, "failed to run the snippet")
+	default:
+		status = fmt.Sprintf("Exit code 0, in %s", elapsed)
+	}
+
+	result := lang.name + " snippet: " + status
+	if stdout.buf.Len() == 0 && stderr.buf.Len() == 0 {
+		return result + "\n\n(no output)", nil
+	}
+	if stdout.buf.Len() > 0 {
+		result += "\n\nstdout:\n" + stdout.String()
+	}
+	if stderr.buf.Len() > 0 {
+		result += "\n\nstderr:\n" + stderr.String()
+	}
+	return result, nil
+}
+
+// command returns the first of the language's commands that is installed
+func (l snippetLanguage) command() ([]string, error) {
+	var names []string
+	for _, command := range l.commands {
+		if path, err := exec.LookPath(command[0]); err == nil {
+			return append([]string{path}, command[1:]...), nil
+		}
+		names = append(names, command[0])
+	}
+	return nil, serr.New(fmt.Sprintf("%s snippets can't run: %s is not installed", l.name, strings.Join(names, " or ")))
+}
+
+// snippetEnv returns a snippet's environment: the snippetEnvVars that are set, with its
+// directory as home and temporary directory. Go uses the user's build cache, so the
+// standard library isn't built again, and doesn't download modules or toolchains.
+func snippetEnv(dir string) []string {
+	env := []string{"HOME=" + dir, "USERPROFILE=" + dir, "TMPDIR=" + dir, "TMP=" + dir, "TEMP=" + dir,
+		"GOPROXY=off", "GOTOOLCHAIN=local", "GOFLAGS=", "PYTHONDONTWRITEBYTECODE=1"}
+	for _, name := range snippetEnvVars {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	if os.Getenv("GOPATH") == "" {
+		env = append(env, "GOPATH="+build.Default.GOPATH)
+	}
+	if os.Getenv("GOCACHE") == "" {
+		if cache, err := os.UserCacheDir(); err == nil {
+			env = append(env, "GOCACHE="+filepath.Join(cache, "go-build"))
+		}
+	}
+	return env
+}
+
+// snippetOutput keeps the first maxSnippetOutput bytes of a snippet's stream, counting the rest
+type snippetOutput struct {
+	buf     bytes.Buffer
+	dropped int
+}
+
+func (o *snippetOutput) Write(p []byte) (int, error) {
+	room := maxSnippetOutput - o.buf.Len()
+	if len(p) <= room {
+		return o.buf.Write(p)
+	}
+	o.buf.Write(p[:room])
+	o.dropped += len(p) - room
+	return len(p), nil
+}
+
+// String returns the output kept, trimmed, noting what was left out
+func (o *snippetOutput) String() string {
+	text := strings.TrimRight(o.buf.String(), "\n\r")
+	if o.dropped > 0 {
+		text += fmt.Sprintf("\n[... %d more bytes left out]", o.dropped)
+	}
+	return text
+}
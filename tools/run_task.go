@@ -0,0 +1,231 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	rcontext "rcode/context"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// Bounds on a task's run
+const (
+	defaultTaskTimeout = 10 * time.Minute
+	maxTaskFailure     = 8000 // Bytes kept of the end of a failed task's output
+	maxTaskOutput      = 1 << 20
+)
+
+// RunTaskTool runs the commands a project defines for itself, its Makefile targets, justfile
+// recipes and package.json scripts, so the model uses them rather than guessing raw commands
+type RunTaskTool struct{}
+
+// GetDefinition returns the tool definition
+func (t *RunTaskTool) GetDefinition() Tool {
+	return Tool{
+		Name: "run_task",
+		Description: "Run a task the project defines for itself: a Makefile target, a justfile recipe or a package.json " +
+			"script, with make, just or the project's package manager. Prefer these to raw shell commands for building, " +
+			"testing, linting and running the project, as they are how its developers do it. Without task, list the " +
+			"tasks with what they run.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"task": map[string]interface{}{
+					"type":        "string",
+					"description": "The task to run, by name: test, build, lint:fix. Leave out to list the tasks",
+				},
+				"runner": map[string]interface{}{
+					"type":        "string",
+					"description": "make, just or the package manager (npm, pnpm, yarn, bun), when more than one defines the task",
+				},
+				"args": map[string]interface{}{
+					"type":        "string",
+					"description": "Arguments for the task: variables for make (VERBOSE=1), a just recipe's parameters, or arguments passed on to a script",
+				},
+				"dir": map[string]interface{}{
+					"type":        "string",
+					"description": "Directory whose tasks to list or run, e.g. a monorepo package's (default: the project root)",
+				},
+				"timeout": map[string]interface{}{
+					"type":        "integer",
+					"description": fmt.Sprintf("Timeout in milliseconds (default: %d)", defaultTaskTimeout.Milliseconds()),
+				},
+			},
+		},
+	}
+}
+
+// Execute lists the project's tasks or runs one
+func (t *RunTaskTool) Execute(input map[string]interface{}) (string, error) {
+	return t.ExecuteContext(context.Background(), input)
+}
+
+// ExecuteContext lists the project's tasks or runs one, stopping it if ctx is cancelled
+func (t *RunTaskTool) ExecuteContext(parent context.Context, input map[string]interface{}) (string, error) {
+	name, _ := GetString(input, "task")
+	runner, _ := GetString(input, "runner")
+	args, _ := GetString(input, "args")
+	name, runner, args = strings.TrimSpace(name), strings.TrimSpace(runner), strings.TrimSpace(args)
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", serr.Wrap(err, "failed to get working directory")
+	}
+	if sub, _ := GetString(input, "dir"); strings.TrimSpace(sub) != "" {
+		if sub, err = ExpandPath(strings.TrimSpace(sub)); err != nil {
+			return "", err
+		}
+		if !filepath.IsAbs(sub) {
+			sub = filepath.Join(dir, sub)
+		}
+		if info, err := os.Stat(sub); err != nil || !info.IsDir() {
+			return "", NewPermanentError(serr.New(fmt.Sprintf("%s is not a directory", sub)), "no such directory")
+		}
+		dir = sub
+	}
+
+	tasks := rcontext.DiscoverTasks(dir)
+	if len(tasks) == 0 {
+		return "", NewPermanentError(serr.New(fmt.Sprintf("%s has no Makefile, justfile or package.json scripts", dir)), "no tasks")
+	}
+	if name == "" {
+		noun := "tasks"
+		if len(tasks) == 1 {
+			noun = "task"
+		}
+		return fmt.Sprintf("%d %s in %s\n\n%s", len(tasks), noun, dir, taskList(tasks)), nil
+	}
+
+	task, err := findTask(tasks, name, runner)
+	if err != nil {
+		return "", err
+	}
+	if _, err := exec.LookPath(task.Runner); err != nil {
+		what := fmt.Sprintf("%s isn't installed, so %s of %s can't run", task.Runner, task.Name, task.Source)
+		if len(task.Commands) > 0 {
+			what += "; its first commands are: " + strings.Join(task.Commands, "; ")
+		}
+		return "", NewPermanentError(serr.New(what), "runner not installed")
+	}
+	command := taskCommand(task, args)
+
+	timeout := defaultTaskTimeout
+	if ms, ok := GetInt(input, "timeout"); ok && ms > 0 {
+		timeout = time.Duration(ms) * time.Millisecond
+	}
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "bash", "-c", command)
+	cmd.Dir = dir
+	configureProcessGroup(cmd)
+	start := time.Now()
+	output, err := cmd.CombinedOutput()
+	elapsed := time.Since(start).Round(100 * time.Millisecond)
+
+	switch {
+	case parent.Err() != nil:
+		return "", serr.New("Task cancelled")
+	case ctx.Err() == context.DeadlineExceeded:
+		return "", NewPermanentError(serr.New(fmt.Sprintf("%s ran over %s and was stopped; raise timeout for a longer task:\n%s",
+			command, timeout, taskFailure(string(output)))), "timeout")
+	case err != nil:
+		exitErr, isExit := err.(*exec.ExitError)
+		if !isExit {
+			return "", serr.Wrap(err, "failed to run "+command)
+		}
+		return "", NewPermanentError(serr.New(fmt.Sprintf("%s (%s) failed with exit code %d after %s:\n%s",
+			command, task.Source, exitErr.ExitCode(), elapsed, taskFailure(string(output)))), "task failed")
+	}
+
+	result := strings.TrimRight(string(output), "\n\r")
+	if len(result) > maxTaskOutput {
+		result = result[:maxTaskOutput] + "\n\n[Output truncated...]"
+	}
+	return fmt.Sprintf("Ran %s (%s) in %s\n\n%s", command, task.Source, elapsed, result), nil
+}
+
+// findTask returns the task with the name, from the runner when it is given. A name more
+// than one file defines needs the runner.
+func findTask(tasks []rcontext.ProjectTask, name, runner string) (rcontext.ProjectTask, error) {
+	var found []rcontext.ProjectTask
+	for _, task := range tasks {
+		if task.Name == name && (runner == "" || task.Runner == runner || task.Source == runner) {
+			found = append(found, task)
+		}
+	}
+
+	switch len(found) {
+	case 1:
+		return found[0], nil
+	case 0:
+		names := make([]string, 0, len(tasks))
+		for _, task := range tasks {
+			names = append(names, fmt.Sprintf("%s (%s)", task.Name, task.Runner))
+		}
+		what := fmt.Sprintf("there is no task %q", name)
+		if runner != "" {
+			what = fmt.Sprintf("%s doesn't define a task %q", runner, name)
+		}
+		return rcontext.ProjectTask{}, NewPermanentError(serr.New(what+"; the tasks are: "+strings.Join(names, ", ")), "unknown task")
+	}
+
+	sources := make([]string, 0, len(found))
+	for _, task := range found {
+		sources = append(sources, fmt.Sprintf("%s (runner %s)", task.Source, task.Runner))
+	}
+	return rcontext.ProjectTask{}, NewPermanentError(serr.New(fmt.Sprintf("%q is defined in %s; give the runner of the one to run",
+		name, strings.Join(sources, ", "))), "ambiguous task")
+}
+
+// taskCommand returns the command line running the task with args. npm needs -- before
+// arguments for the script; the other package managers pass them on as they are.
+func taskCommand(task rcontext.ProjectTask, args string) string {
+	switch {
+	case args == "":
+		return task.Run
+	case task.Runner == "npm":
+		return task.Run + " -- " + args
+	}
+	return task.Run + " " + args
+}
+
+// taskList lists the tasks by the file defining them, with their descriptions, or the
+// commands they run when they have none
+func taskList(tasks []rcontext.ProjectTask) string {
+	var sb strings.Builder
+	source := ""
+	for _, task := range tasks {
+		if task.Source != source {
+			if source != "" {
+				sb.WriteString("\n")
+			}
+			source = task.Source
+			fmt.Fprintf(&sb, "%s (%s):\n", source, task.Runner)
+		}
+		sb.WriteString("  " + strings.Join(append([]string{task.Name}, task.Params...), " "))
+		switch {
+		case task.Description != "":
+			sb.WriteString(" - " + task.Description)
+		case len(task.Commands) > 0:
+			sb.WriteString(" - " + strings.Join(task.Commands, "; "))
+		}
+		sb.WriteString("\n")
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// taskFailure keeps the end of a failed task's output, where the error usually is
+func taskFailure(output string) string {
+	output = strings.TrimSpace(output)
+	if len(output) > maxTaskFailure {
+		output = "..." + output[len(output)-maxTaskFailure:]
+	}
+	return output
+}
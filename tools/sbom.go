@@ -0,0 +1,586 @@
+package tools
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"rcode/config"
+	"rcode/context"
+
+	"github.com/google/uuid"
+	"github.com/rohanthewiz/serr"
+	"golang.org/x/mod/module"
+)
+
+// SBOM formats the sbom tool writes
+const (
+	sbomCycloneDX = "cyclonedx"
+	sbomSPDX      = "spdx"
+)
+
+// Licenses that require sharing the source of changes or of works built on them
+var copyleftLicenses = []string{"GPL-", "LGPL-", "AGPL-", "MPL-", "EPL-", "CDDL-", "EUPL-", "OSL-"}
+
+// spdxIDPattern matches a single SPDX license identifier, such as MIT or Apache-2.0
+var spdxIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9.+-]*$`)
+
+// sbomComponent is a dependency in the SBOM, with its license as found in its files
+type sbomComponent struct {
+	ecosystem string // golang, npm or pypi, as in package URLs
+	name      string
+	version   string
+	license   string // An SPDX identifier or expression, a license's name, or "" when none was found
+	purl      string
+}
+
+// SBOMTool writes a software bill of materials of the project's dependencies
+type SBOMTool struct {
+	ContextManager *context.Manager
+}
+
+// GetDefinition returns the tool definition
+func (t *SBOMTool) GetDefinition() Tool {
+	return Tool{
+		Name: "sbom",
+		Description: "Generate a software bill of materials (SBOM) of the project's dependencies, as CycloneDX or SPDX JSON, " +
+			"and publish it as an artifact of the session. Each dependency is listed with its version, package URL and " +
+			"license, read from the module cache, node_modules or the virtualenv. Returns a summary of the licenses, " +
+			"naming the copyleft ones and the dependencies with no license found.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"format": map[string]interface{}{
+					"type":        "string",
+					"enum":        []string{sbomCycloneDX, sbomSPDX},
+					"description": "The SBOM format: CycloneDX 1.5 or SPDX 2.3 (default: cyclonedx)",
+				},
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The artifact's file name (default: sbom.cdx.json or sbom.spdx.json)",
+				},
+			},
+		},
+	}
+}
+
+// Execute builds the SBOM from the project context and publishes it
+func (t *SBOMTool) Execute(input map[string]interface{}) (string, error) {
+	if artifactStore == nil {
+		return "", NewPermanentError(serr.New("artifacts are not available"), "no store")
+	}
+	sessionID, _ := GetString(input, "_sessionId")
+	if sessionID == "" {
+		return "", NewPermanentError(serr.New("an SBOM can only be published in a session"), "no session")
+	}
+	if t.ContextManager == nil {
+		return "", serr.New("project context is not available")
+	}
+	format, _ := GetString(input, "format")
+	if format == "" {
+		format = sbomCycloneDX
+	}
+	if format != sbomCycloneDX && format != sbomSPDX {
+		return "", NewPermanentError(serr.New("format must be cyclonedx or spdx"), "invalid format")
+	}
+
+	project := t.ContextManager.GetContext()
+	if project == nil {
+		dir, err := os.Getwd()
+		if err != nil {
+			return "", serr.Wrap(err, "failed to get working directory")
+		}
+		if project, err = t.ContextManager.ScanProject(dir); err != nil {
+			return "", err
+		}
+	}
+	if len(project.Dependencies) == 0 {
+		return "", NewPermanentError(serr.New("no dependencies found: the SBOM is built from go.mod, package.json or requirements.txt"), "no dependencies")
+	}
+
+	rootName := filepath.Base(project.RootPath)
+	if _, modulePath, err := goModule(project.RootPath); err == nil && project.Language == "go" {
+		rootName = modulePath
+	}
+	components := sbomComponents(project)
+
+	var document interface{}
+	name, _ := GetString(input, "name")
+	if format == sbomSPDX {
+		document = spdxDocument(rootName, components)
+		if name == "" {
+			name = "sbom.spdx.json"
+		}
+	} else {
+		document = cycloneDXDocument(rootName, components)
+		if name == "" {
+			name = "sbom.cdx.json"
+		}
+	}
+	content, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return "", serr.Wrap(err, "failed to encode SBOM")
+	}
+	if int64(len(content)) > config.Get().MaxArtifactSize {
+		return "", NewPermanentError(serr.New(fmt.Sprintf("the SBOM is %d bytes, more than the %d bytes an artifact may hold",
+			len(content), config.Get().MaxArtifactSize)), "too large")
+	}
+
+	artifact := Artifact{SessionID: sessionID, Name: name, Kind: "report", ContentType: "application/json", Content: content,
+		Description: fmt.Sprintf("%s SBOM of %s: %d dependencies", sbomFormatName(format), rootName, len(components))}
+	artifact.PlanID, _ = GetString(input, "_planId")
+	id, err := artifactStore.PublishArtifact(artifact)
+	if err != nil {
+		return "", serr.Wrap(err, "failed to publish SBOM")
+	}
+
+	return fmt.Sprintf("Published the %s SBOM of %s, %d dependencies, as artifact %d (%s, %d bytes)\n%s",
+		sbomFormatName(format), rootName, len(components), id, name, len(content), licenseSummary(project.Language, components)), nil
+}
+
+// sbomFormatName names a format with the version written
+func sbomFormatName(format string) string {
+	if format == sbomSPDX {
+		return "SPDX 2.3"
+	}
+	return "CycloneDX 1.5"
+}
+
+// sbomComponents turns the project's dependencies into components, with their licenses
+func sbomComponents(project *context.ProjectContext) []sbomComponent {
+	goModCache := ""
+	components := make([]sbomComponent, 0, len(project.Dependencies))
+	for _, dep := range project.Dependencies {
+		c := sbomComponent{name: dep.Name, version: dep.Version}
+		switch dep.Type {
+		case "go_module":
+			if goModCache == "" {
+				goModCache = goModuleCache()
+			}
+			c.ecosystem = "golang"
+			c.license = goModuleLicense(goModCache, dep.Name, dep.Version)
+		case "npm_package":
+			c.ecosystem = "npm"
+			c.version, c.license = npmPackageLicense(project.RootPath, dep.Name, dep.Version)
+		case "pip_package":
+			if strings.HasPrefix(c.name, "-") {
+				continue // Options such as -r other.txt
+			}
+			// Requirement lines can carry version ranges, extras and markers
+			if i := strings.IndexAny(c.name, "<>=!~[; "); i >= 0 {
+				c.name = c.name[:i]
+			}
+			c.ecosystem = "pypi"
+			c.license = pythonPackageLicense(project.RootPath, c.name)
+		default:
+			c.ecosystem = "generic"
+		}
+		c.purl = packageURL(c.ecosystem, c.name, c.version)
+		components = append(components, c)
+	}
+	sort.Slice(components, func(i, j int) bool { return components[i].purl < components[j].purl })
+	return components
+}
+
+// packageURL returns a dependency's package URL, as in pkg:golang/github.com/google/uuid@v1.6.0
+func packageURL(ecosystem, name, version string) string {
+	if ecosystem == "pypi" {
+		name = strings.ReplaceAll(strings.ToLower(name), "_", "-")
+	}
+	segments := strings.Split(name, "/")
+	for i, segment := range segments {
+		segments[i] = strings.ReplaceAll(url.PathEscape(segment), "@", "%40") // npm scopes are encoded
+	}
+	purl := "pkg:" + ecosystem + "/" + strings.Join(segments, "/")
+	if version != "" {
+		purl += "@" + url.PathEscape(version)
+	}
+	return purl
+}
+
+// goModuleCache returns the directory Go keeps downloaded modules in
+func goModuleCache() string {
+	if dir := os.Getenv("GOMODCACHE"); dir != "" {
+		return dir
+	}
+	output, err := exec.Command("go", "env", "GOMODCACHE").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// goModuleLicense reads the license of a module in the module cache, "" when it isn't downloaded
+func goModuleLicense(modCache, path, version string) string {
+	escapedPath, err := module.EscapePath(path)
+	if err != nil || modCache == "" {
+		return ""
+	}
+	escapedVersion, err := module.EscapeVersion(version)
+	if err != nil {
+		return ""
+	}
+	return licenseInDir(filepath.Join(modCache, escapedPath+"@"+escapedVersion))
+}
+
+// licenseInDir identifies the license in a directory's LICENSE, COPYING or similar file
+func licenseInDir(dir string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	for _, entry := range entries {
+		upper := strings.ToUpper(entry.Name())
+		if entry.IsDir() || !(strings.HasPrefix(upper, "LICENSE") || strings.HasPrefix(upper, "LICENCE") || strings.HasPrefix(upper, "COPYING")) {
+			continue
+		}
+		text, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		if license := identifyLicense(string(text)); license != "" {
+			return license
+		}
+	}
+	return ""
+}
+
+// identifyLicense recognizes the common licenses by their text, returning its SPDX identifier
+func identifyLicense(text string) string {
+	text = strings.Join(strings.Fields(text), " ") // Line breaks fall anywhere in the phrases
+	has := func(phrase string) bool { return strings.Contains(strings.ToLower(text), strings.ToLower(phrase)) }
+	orLater := func(id string) string {
+		if has("any later version") {
+			return id + "-or-later"
+		}
+		return id + "-only"
+	}
+
+	switch {
+	case has("GNU AFFERO GENERAL PUBLIC LICENSE"):
+		return orLater("AGPL-3.0")
+	case has("GNU LESSER GENERAL PUBLIC LICENSE") && has("Version 3"):
+		return orLater("LGPL-3.0")
+	case has("GNU LESSER GENERAL PUBLIC LICENSE") || has("GNU LIBRARY GENERAL PUBLIC LICENSE"):
+		return orLater("LGPL-2.1")
+	case has("GNU GENERAL PUBLIC LICENSE") && has("Version 3"):
+		return orLater("GPL-3.0")
+	case has("GNU GENERAL PUBLIC LICENSE"):
+		return orLater("GPL-2.0")
+	case has("Mozilla Public License") && has("2.0"):
+		return "MPL-2.0"
+	case has("Eclipse Public License") && has("2.0"):
+		return "EPL-2.0"
+	case has("Apache License") && has("Version 2.0"):
+		return "Apache-2.0"
+	case has("Permission is hereby granted, free of charge"):
+		return "MIT"
+	case has("Permission to use, copy, modify, and/or distribute this software for any purpose"):
+		return "ISC"
+	case has("Redistribution and use in source and binary forms") && has("Neither the name"):
+		return "BSD-3-Clause"
+	case has("Redistribution and use in source and binary forms"):
+		return "BSD-2-Clause"
+	case has("This is free and unencumbered software released into the public domain"):
+		return "Unlicense"
+	case has("CC0 1.0 Universal"):
+		return "CC0-1.0"
+	}
+	return ""
+}
+
+// npmPackageLicense returns the installed version of a package and its license from its
+// package.json in node_modules, or the range wanted when it isn't installed
+func npmPackageLicense(root, name, wanted string) (string, string) {
+	data, err := os.ReadFile(filepath.Join(root, "node_modules", filepath.FromSlash(name), "package.json"))
+	if err != nil {
+		return wanted, ""
+	}
+	var pkg struct {
+		Version  string          `json:"version"`
+		License  json.RawMessage `json:"license"`
+		Licenses []struct {
+			Type string `json:"type"`
+		} `json:"licenses"`
+	}
+	if json.Unmarshal(data, &pkg) != nil {
+		return wanted, ""
+	}
+	version := pkg.Version
+	if version == "" {
+		version = wanted
+	}
+
+	var license string
+	var old struct {
+		Type string `json:"type"`
+	}
+	if json.Unmarshal(pkg.License, &license) != nil && json.Unmarshal(pkg.License, &old) == nil {
+		license = old.Type // The object form packages used before SPDX expressions
+	}
+	if license == "" && len(pkg.Licenses) > 0 {
+		types := make([]string, 0, len(pkg.Licenses))
+		for _, l := range pkg.Licenses {
+			types = append(types, l.Type)
+		}
+		license = strings.Join(types, " OR ")
+	}
+	if strings.HasPrefix(license, "SEE LICENSE IN") {
+		if found := licenseInDir(filepath.Join(root, "node_modules", filepath.FromSlash(name))); found != "" {
+			license = found
+		}
+	}
+	return version, license
+}
+
+// Licenses the trove classifiers of Python packages name, by SPDX identifier
+var pythonLicenseClassifiers = map[string]string{
+	"MIT License":                                   "MIT",
+	"Apache Software License":                       "Apache-2.0",
+	"BSD License":                                   "BSD-3-Clause",
+	"ISC License (ISCL)":                            "ISC",
+	"Mozilla Public License 2.0 (MPL 2.0)":          "MPL-2.0",
+	"GNU General Public License v2 (GPLv2)":         "GPL-2.0-only",
+	"GNU General Public License v3 (GPLv3)":         "GPL-3.0-only",
+	"GNU Lesser General Public License v3 (LGPLv3)": "LGPL-3.0-only",
+	"Python Software Foundation License":            "PSF-2.0",
+	"The Unlicense (Unlicense)":                     "Unlicense",
+}
+
+// pythonPackageLicense reads a package's license from its metadata in the project's
+// virtualenv, "" when it isn't installed there
+func pythonPackageLicense(root, name string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+
+	for _, venv := range []string{".venv", "venv", "env"} {
+		infos, _ := filepath.Glob(filepath.Join(root, venv, "lib", "python*", "site-packages", "*.dist-info"))
+		for _, info := range infos {
+			distName, _, _ := strings.Cut(strings.TrimSuffix(filepath.Base(info), ".dist-info"), "-")
+			if strings.ToLower(strings.NewReplacer("-", "_", ".", "_").Replace(distName)) == normalized {
+				return pythonMetadataLicense(filepath.Join(info, "METADATA"))
+			}
+		}
+	}
+	return ""
+}
+
+// pythonMetadataLicense reads the license from a package's METADATA: its License-Expression,
+// else its license classifier, else its License field when that is short enough to be a name
+func pythonMetadataLicense(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	var classifier, field string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			break // The headers end where the description starts
+		}
+		key, value, _ := strings.Cut(line, ": ")
+		switch key {
+		case "License-Expression":
+			return strings.TrimSpace(value)
+		case "Classifier":
+			if name, ok := strings.CutPrefix(value, "License :: OSI Approved :: "); ok && classifier == "" {
+				classifier = pythonLicenseClassifiers[name]
+				if classifier == "" {
+					classifier = name
+				}
+			}
+		case "License":
+			field = strings.TrimSpace(value)
+		}
+	}
+	switch {
+	case classifier != "":
+		return classifier
+	case field != "" && len(field) < 40 && field != "UNKNOWN":
+		return field
+	}
+	return ""
+}
+
+// isCopyleft reports whether a license obliges sharing source: a copyleft license, or an
+// expression that has one in each of its alternatives, as MIT OR GPL-3.0-only lets MIT be chosen
+func isCopyleft(license string) bool {
+	for _, alternative := range strings.Split(strings.Trim(license, "()"), " OR ") {
+		copyleft := false
+		for _, prefix := range copyleftLicenses {
+			for _, id := range strings.FieldsFunc(alternative, func(r rune) bool { return r == ' ' || r == '(' || r == ')' }) {
+				copyleft = copyleft || strings.HasPrefix(id, prefix)
+			}
+		}
+		if !copyleft {
+			return false
+		}
+	}
+	return true
+}
+
+// licenseSummary counts the components by license and names those needing a look: the
+// copyleft ones and those with no license found
+func licenseSummary(language string, components []sbomComponent) string {
+	counts := make(map[string]int)
+	var copyleft, unknown []string
+	for _, c := range components {
+		license := c.license
+		if license == "" {
+			license = "unknown"
+			unknown = append(unknown, c.name)
+		} else if isCopyleft(license) {
+			copyleft = append(copyleft, fmt.Sprintf("%s (%s)", c.name, license))
+		}
+		counts[license]++
+	}
+
+	licenses := make([]string, 0, len(counts))
+	for license := range counts {
+		licenses = append(licenses, license)
+	}
+	sort.Slice(licenses, func(i, j int) bool {
+		if counts[licenses[i]] != counts[licenses[j]] {
+			return counts[licenses[i]] > counts[licenses[j]]
+		}
+		return licenses[i] < licenses[j]
+	})
+	tally := make([]string, 0, len(licenses))
+	for _, license := range licenses {
+		tally = append(tally, fmt.Sprintf("%d %s", counts[license], license))
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Licenses: " + strings.Join(tally, ", "))
+	if len(copyleft) > 0 {
+		sb.WriteString("\nCopyleft: " + strings.Join(copyleft, ", "))
+	}
+	if len(unknown) > 0 {
+		sb.WriteString("\nNo license found: " + strings.Join(unknown, ", "))
+		switch language {
+		case "go":
+			sb.WriteString("\n(Licenses are read from the module cache; go mod download fetches the modules missing)")
+		case "javascript", "typescript":
+			sb.WriteString("\n(Licenses are read from node_modules; npm install fetches the packages missing)")
+		case "python":
+			sb.WriteString("\n(Licenses are read from the project's .venv or venv)")
+		}
+	}
+	return sb.String()
+}
+
+// cycloneDXDocument builds a CycloneDX 1.5 JSON BOM with the project as its subject
+func cycloneDXDocument(rootName string, components []sbomComponent) map[string]interface{} {
+	rootRef := "root:" + rootName
+	entries := make([]map[string]interface{}, 0, len(components))
+	refs := make([]string, 0, len(components))
+	for _, c := range components {
+		entry := map[string]interface{}{
+			"type":    "library",
+			"bom-ref": c.purl,
+			"name":    c.name,
+			"version": c.version,
+			"purl":    c.purl,
+		}
+		switch {
+		case c.license == "":
+		case strings.Contains(c.license, " OR ") || strings.Contains(c.license, " AND ") || strings.Contains(c.license, " WITH "):
+			entry["licenses"] = []interface{}{map[string]interface{}{"expression": c.license}}
+		case spdxIDPattern.MatchString(c.license):
+			entry["licenses"] = []interface{}{map[string]interface{}{"license": map[string]interface{}{"id": c.license}}}
+		default:
+			entry["licenses"] = []interface{}{map[string]interface{}{"license": map[string]interface{}{"name": c.license}}}
+		}
+		entries = append(entries, entry)
+		refs = append(refs, c.purl)
+	}
+
+	return map[string]interface{}{
+		"bomFormat":    "CycloneDX",
+		"specVersion":  "1.5",
+		"serialNumber": "urn:uuid:" + uuid.New().String(),
+		"version":      1,
+		"metadata": map[string]interface{}{
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+			"tools": map[string]interface{}{
+				"components": []interface{}{map[string]interface{}{"type": "application", "name": "rcode"}},
+			},
+			"component": map[string]interface{}{"type": "application", "bom-ref": rootRef, "name": rootName},
+		},
+		"components":   entries,
+		"dependencies": []interface{}{map[string]interface{}{"ref": rootRef, "dependsOn": refs}},
+	}
+}
+
+// spdxDocument builds an SPDX 2.3 JSON document describing the project and its dependencies.
+// Licenses that aren't SPDX identifiers or expressions are left as NOASSERTION.
+func spdxDocument(rootName string, components []sbomComponent) map[string]interface{} {
+	rootID := "SPDXRef-Package-root"
+	packages := []interface{}{map[string]interface{}{
+		"name":             rootName,
+		"SPDXID":           rootID,
+		"downloadLocation": "NOASSERTION",
+		"filesAnalyzed":    false,
+		"licenseConcluded": "NOASSERTION",
+		"licenseDeclared":  "NOASSERTION",
+	}}
+	relationships := []interface{}{map[string]interface{}{
+		"spdxElementId": "SPDXRef-DOCUMENT", "relationshipType": "DESCRIBES", "relatedSpdxElement": rootID,
+	}}
+
+	for i, c := range components {
+		id := fmt.Sprintf("SPDXRef-Package-%d", i+1)
+		declared := "NOASSERTION"
+		if c.license != "" && !strings.ContainsAny(c.license, ",;") && spdxIDPattern.MatchString(strings.NewReplacer(" OR ", "", " AND ", "", " WITH ", "", "(", "", ")", "").Replace(c.license)) {
+			declared = c.license
+		}
+		pkg := map[string]interface{}{
+			"name":             c.name,
+			"SPDXID":           id,
+			"downloadLocation": "NOASSERTION",
+			"filesAnalyzed":    false,
+			"licenseConcluded": "NOASSERTION",
+			"licenseDeclared":  declared,
+			"externalRefs": []interface{}{map[string]interface{}{
+				"referenceCategory": "PACKAGE-MANAGER", "referenceType": "purl", "referenceLocator": c.purl,
+			}},
+		}
+		if c.version != "" {
+			pkg["versionInfo"] = c.version
+		}
+		packages = append(packages, pkg)
+		relationships = append(relationships, map[string]interface{}{
+			"spdxElementId": rootID, "relationshipType": "DEPENDS_ON", "relatedSpdxElement": id,
+		})
+	}
+
+	return map[string]interface{}{
+		"spdxVersion":       "SPDX-2.3",
+		"dataLicense":       "CC0-1.0",
+		"SPDXID":            "SPDXRef-DOCUMENT",
+		"name":              rootName,
+		"documentNamespace": "https://spdx.org/spdxdocs/" + url.PathEscape(rootName) + "-" + uuid.New().String(),
+		"creationInfo": map[string]interface{}{
+			"created":  time.Now().UTC().Format(time.RFC3339),
+			"creators": []string{"Tool: rcode"},
+		},
+		"packages":      packages,
+		"relationships": relationships,
+	}
+}
+
+// RegisterSBOMTool adds the sbom tool backed by the given context manager
+func RegisterSBOMTool(registry *Registry, contextManager *context.Manager) {
+	sbomTool := &SBOMTool{ContextManager: contextManager}
+	registry.Register(sbomTool.GetDefinition(), sbomTool)
+}
@@ -9,6 +9,8 @@ import (
 	"sort"
 	"strings"
 
+	"rcode/ignore"
+
 	"github.com/rohanthewiz/serr"
 )
 
@@ -127,14 +129,21 @@ func (t *SearchTool) Execute(input map[string]interface{}) (string, error) {
 	var searchedFiles int
 
 	if info.IsDir() {
-		// Search in directory
+		// Search in directory, skipping what the repository's ignore files exclude
+		ignored := ignore.ForPath(searchPath)
 		err = filepath.Walk(searchPath, func(path string, info os.FileInfo, err error) error {
 			if err != nil {
 				return nil // Skip files we can't access
 			}
+			if absPath, _ := filepath.Abs(path); path != searchPath && ignored.MatchEntry(absPath, info.IsDir()) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
 
-			// Skip directories and binary files
-			if info.IsDir() || isBinaryFile(path) {
+			// Skip directories, binary files and sensitive files
+			if info.IsDir() || isBinaryFile(path) || IsSensitivePath(path) {
 				return nil
 			}
 
@@ -167,6 +176,9 @@ func (t *SearchTool) Execute(input map[string]interface{}) (string, error) {
 		}
 	} else {
 		// Search in single file
+		if err := CheckSensitivePath(searchPath); err != nil {
+			return "", err
+		}
 		results, err = searchInFile(searchPath, regex, contextLines)
 		if err != nil {
 			return "", serr.Wrap(err, "Error searching file")
@@ -0,0 +1,634 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	rcontext "rcode/context"
+
+	"github.com/rohanthewiz/serr"
+	"golang.org/x/mod/semver"
+)
+
+// Bounds on a vulnerability scan
+const (
+	securityScanTimeout  = 10 * time.Minute
+	defaultSecurityFixes = 5 // Dependencies a remediation plan updates
+	maxSecurityFixes     = 20
+)
+
+// Severities of findings, most severe first. Go's vulnerability database gives none, so Go
+// findings are rated by how the code reaches them: high when it calls the vulnerable
+// function, moderate when it imports the package only, low when it only requires the module.
+var securitySeverities = []string{"critical", "high", "moderate", "unknown", "low", "info"}
+
+// severityRank orders severities, the most severe highest
+func severityRank(severity string) int {
+	for i, s := range securitySeverities {
+		if s == severity {
+			return len(securitySeverities) - i
+		}
+	}
+	return 0
+}
+
+// PlanStep is a step of a plan a tool files for the user to review and run
+type PlanStep struct {
+	ID           string
+	Description  string
+	Tool         string
+	Params       map[string]interface{}
+	Dependencies []string
+}
+
+// PlanFiler saves plans for a session, which the user runs from the plan panel.
+// It is implemented by the web layer, which owns the planner and the database.
+type PlanFiler interface {
+	// FilePlan saves a plan of steps for the session, returning its ID
+	FilePlan(sessionID, description string, steps []PlanStep) (string, error)
+}
+
+// Filer of the plans tools propose
+var planFiler PlanFiler
+
+// SetPlanFiler sets the filer of the plans tools propose
+func SetPlanFiler(filer PlanFiler) {
+	planFiler = filer
+}
+
+// vulnFinding is a known vulnerability in a dependency of the project
+type vulnFinding struct {
+	ecosystem  string // go, npm or pip
+	id         string // GO-2024-2687, GHSA-..., PYSEC-...
+	aliases    []string
+	pkg        string // The vulnerable module or package
+	version    string // Installed, or the vulnerable range for npm
+	severity   string
+	summary    string
+	fixPackage string // The dependency to update to fix it: pkg, or npm's direct dependency pulling it in
+	fixVersion string // "" when there is no fix yet
+	location   string // Where the code calls it, for Go
+}
+
+// SecurityScanTool runs the vulnerability scanner of the project's language
+type SecurityScanTool struct{}
+
+// GetDefinition returns the tool definition
+func (t *SecurityScanTool) GetDefinition() Tool {
+	return Tool{
+		Name: "security_scan",
+		Description: "Scan the project's dependencies for known vulnerabilities with the scanner of its language: " +
+			"govulncheck for Go, npm audit for JavaScript and TypeScript, pip-audit for Python. Reports each finding " +
+			"with its severity, the vulnerable package and version, and the version fixing it, most severe first. " +
+			"With file_plan, also files a plan updating the dependencies behind the most severe findings, for the " +
+			"user to review and run.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"language": map[string]interface{}{
+					"type":        "string",
+					"enum":        []string{"go", "javascript", "typescript", "python"},
+					"description": "The scanner to run, by language (default: the project's)",
+				},
+				"packages": map[string]interface{}{
+					"type":        "string",
+					"description": "The Go packages govulncheck scans (default: ./...)",
+				},
+				"min_severity": map[string]interface{}{
+					"type":        "string",
+					"enum":        []string{"low", "moderate", "high", "critical"},
+					"description": "Leave out findings less severe (default: low, all of them)",
+				},
+				"file_plan": map[string]interface{}{
+					"type":        "boolean",
+					"description": "File a plan updating the dependencies behind the most severe findings (default: false)",
+				},
+				"max_fixes": map[string]interface{}{
+					"type":        "integer",
+					"description": fmt.Sprintf("Dependencies the plan updates, most severe first (default: %d)", defaultSecurityFixes),
+				},
+			},
+		},
+	}
+}
+
+// Execute runs the scan
+func (t *SecurityScanTool) Execute(input map[string]interface{}) (string, error) {
+	return t.ExecuteContext(context.Background(), input)
+}
+
+// ExecuteContext runs the scan, stopping the scanner if ctx is cancelled
+func (t *SecurityScanTool) ExecuteContext(ctx context.Context, input map[string]interface{}) (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", serr.Wrap(err, "failed to get working directory")
+	}
+	language, _ := GetString(input, "language")
+	language = strings.ToLower(strings.TrimSpace(language))
+	if language == "" {
+		language = rcontext.DetectLanguage(dir)
+	}
+	minSeverity, _ := GetString(input, "min_severity")
+	if minSeverity == "" {
+		minSeverity = "low"
+	}
+	if severityRank(minSeverity) == 0 {
+		return "", NewPermanentError(serr.New(fmt.Sprintf("unknown severity %q; use low, moderate, high or critical", minSeverity)), "invalid severity")
+	}
+
+	var scanner string
+	var findings []*vulnFinding
+	switch language {
+	case "go":
+		scanner = "govulncheck"
+		packages, _ := GetString(input, "packages")
+		findings, err = scanGo(ctx, dir, packages)
+	case "javascript", "typescript":
+		scanner = "npm audit"
+		findings, err = scanNpm(ctx, dir)
+	case "python":
+		scanner = "pip-audit"
+		findings, err = scanPython(ctx, dir)
+	case "":
+		return "", NewPermanentError(serr.New("the project's language isn't known; give language"), "unknown language")
+	default:
+		return "", NewPermanentError(serr.New(fmt.Sprintf("there is no vulnerability scanner for %s projects; "+
+			"Go, JavaScript, TypeScript and Python are supported", language)), "unsupported language")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	kept := findings[:0]
+	for _, f := range findings {
+		// Unrated findings can't be judged less severe, so they are kept
+		if f.severity == "unknown" || severityRank(f.severity) >= severityRank(minSeverity) {
+			kept = append(kept, f)
+		}
+	}
+	findings = kept
+	sort.SliceStable(findings, func(i, j int) bool {
+		if a, b := severityRank(findings[i].severity), severityRank(findings[j].severity); a != b {
+			return a > b
+		}
+		return findings[i].id < findings[j].id
+	})
+
+	result := securityReport(scanner, findings, minSeverity)
+	if fileIt, _ := GetBool(input, "file_plan"); fileIt && len(findings) > 0 {
+		note, err := fileRemediationPlan(input, language, minSeverity, findings)
+		if err != nil {
+			return "", err
+		}
+		result += "\n\n" + note
+	}
+	return result, nil
+}
+
+// runScanner runs a scanner in dir, returning its output. A scanner that isn't installed is
+// reported with how to install it; one exiting with an error is left to its caller, as
+// some exit with one when they find vulnerabilities.
+func runScanner(ctx context.Context, dir, install, name string, args ...string) ([]byte, error) {
+	if _, err := exec.LookPath(name); err != nil {
+		return nil, NewPermanentError(serr.New(fmt.Sprintf("%s isn't installed; install it with %s", name, install)), "scanner missing")
+	}
+	ctx, cancel := context.WithTimeout(ctx, securityScanTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	configureProcessGroup(cmd)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		return nil, NewPermanentError(serr.New(fmt.Sprintf("%s ran over %s and was stopped", name, securityScanTimeout)), "timeout")
+	case ctx.Err() != nil:
+		return nil, serr.New("Vulnerability scan cancelled")
+	}
+	if _, isExit := err.(*exec.ExitError); err != nil && !isExit {
+		return nil, serr.Wrap(err, "failed to run "+name)
+	}
+	if err != nil && len(strings.TrimSpace(string(output))) == 0 {
+		return nil, NewPermanentError(serr.New(name+" failed:\n"+testFailure(stderr.String())), "scan failed")
+	}
+	return output, nil
+}
+
+// scanGo runs govulncheck over the packages of the module holding dir
+func scanGo(ctx context.Context, dir, packages string) ([]*vulnFinding, error) {
+	root, _, err := goModule(dir)
+	if err != nil {
+		return nil, NewPermanentError(err, "not a Go module")
+	}
+	if strings.TrimSpace(packages) == "" {
+		packages = "./..."
+	}
+	output, err := runScanner(ctx, root, "go install golang.org/x/vuln/cmd/govulncheck@latest",
+		"govulncheck", append([]string{"-json"}, strings.Fields(packages)...)...)
+	if err != nil {
+		return nil, err
+	}
+	return parseGovulncheck(output, root)
+}
+
+// govulnFrame is a frame of a govulncheck finding's trace, from the vulnerable symbol to the
+// project's code
+type govulnFrame struct {
+	Module   string `json:"module"`
+	Version  string `json:"version"`
+	Package  string `json:"package"`
+	Function string `json:"function"`
+	Receiver string `json:"receiver"`
+	Position *struct {
+		Filename string `json:"filename"`
+		Line     int    `json:"line"`
+	} `json:"position"`
+}
+
+// parseGovulncheck reads govulncheck's JSON messages. A vulnerability has a finding for each
+// level the code reaches it at, module, package and function, and is rated by the deepest.
+func parseGovulncheck(output []byte, root string) ([]*vulnFinding, error) {
+	type osvEntry struct {
+		ID      string   `json:"id"`
+		Aliases []string `json:"aliases"`
+		Summary string   `json:"summary"`
+		Details string   `json:"details"`
+	}
+	entries := make(map[string]osvEntry)
+	byID := make(map[string]*vulnFinding)
+	var order []string
+
+	decoder := json.NewDecoder(strings.NewReader(string(output)))
+	for decoder.More() {
+		var message struct {
+			OSV     *osvEntry `json:"osv"`
+			Finding *struct {
+				OSV          string        `json:"osv"`
+				FixedVersion string        `json:"fixed_version"`
+				Trace        []govulnFrame `json:"trace"`
+			} `json:"finding"`
+		}
+		if err := decoder.Decode(&message); err != nil {
+			return nil, serr.Wrap(err, "failed to read govulncheck output")
+		}
+		if message.OSV != nil {
+			entries[message.OSV.ID] = *message.OSV
+		}
+		finding := message.Finding
+		if finding == nil || len(finding.Trace) == 0 {
+			continue
+		}
+
+		vulnerable := finding.Trace[0]
+		severity := "low"
+		switch {
+		case vulnerable.Function != "":
+			severity = "high"
+		case vulnerable.Package != "":
+			severity = "moderate"
+		}
+
+		f := byID[finding.OSV]
+		if f == nil {
+			f = &vulnFinding{ecosystem: ecosystemGo, id: finding.OSV, pkg: vulnerable.Module, version: vulnerable.Version,
+				fixPackage: vulnerable.Module, fixVersion: finding.FixedVersion}
+			byID[finding.OSV] = f
+			order = append(order, finding.OSV)
+		}
+		if severityRank(severity) > severityRank(f.severity) {
+			f.severity = severity
+		}
+		if vulnerable.Function != "" && f.location == "" {
+			f.location = govulnCaller(finding.Trace, root)
+		}
+	}
+
+	findings := make([]*vulnFinding, 0, len(order))
+	for _, id := range order {
+		f := byID[id]
+		if entry, ok := entries[id]; ok {
+			f.aliases = entry.Aliases
+			f.summary = entry.Summary
+			if f.summary == "" {
+				f.summary, _, _ = strings.Cut(entry.Details, "\n")
+			}
+		}
+		findings = append(findings, f)
+	}
+	return findings, nil
+}
+
+// govulnCaller returns where the project's code starts a trace to a vulnerable function:
+// "web/server.go:42 in server.Start"
+func govulnCaller(trace []govulnFrame, root string) string {
+	frame := trace[len(trace)-1]
+	location := path.Base(frame.Package) + "." + frame.Function
+	if frame.Receiver != "" {
+		location = path.Base(frame.Package) + "." + strings.TrimPrefix(frame.Receiver, "*") + "." + frame.Function
+	}
+	if frame.Position == nil || frame.Position.Filename == "" {
+		return location
+	}
+	file := frame.Position.Filename
+	if rel, err := filepath.Rel(root, file); err == nil && !strings.HasPrefix(rel, "..") {
+		file = filepath.ToSlash(rel)
+	}
+	return fmt.Sprintf("%s:%d in %s", file, frame.Position.Line, location)
+}
+
+// scanNpm runs npm audit in dir, which needs a lock file
+func scanNpm(ctx context.Context, dir string) ([]*vulnFinding, error) {
+	output, err := runScanner(ctx, dir, "Node.js", "npm", "audit", "--json")
+	if err != nil {
+		return nil, err
+	}
+	return parseNpmAudit(output)
+}
+
+// parseNpmAudit reads npm audit's JSON report. Each vulnerable package lists the advisories
+// against it in via, next to the packages it is vulnerable through, which are left out.
+func parseNpmAudit(output []byte) ([]*vulnFinding, error) {
+	var report struct {
+		Error *struct {
+			Summary string `json:"summary"`
+		} `json:"error"`
+		Vulnerabilities map[string]struct {
+			Name         string            `json:"name"`
+			Range        string            `json:"range"`
+			Via          []json.RawMessage `json:"via"`
+			FixAvailable json.RawMessage   `json:"fixAvailable"`
+		} `json:"vulnerabilities"`
+	}
+	if err := json.Unmarshal(output, &report); err != nil {
+		return nil, serr.Wrap(err, "failed to read npm audit output")
+	}
+	if report.Error != nil {
+		return nil, NewPermanentError(serr.New("npm audit failed: "+report.Error.Summary), "scan failed")
+	}
+
+	var findings []*vulnFinding
+	seen := make(map[string]bool)
+	names := make([]string, 0, len(report.Vulnerabilities))
+	for name := range report.Vulnerabilities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		vuln := report.Vulnerabilities[name]
+		fixPackage, fixVersion := name, ""
+		var fix struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		}
+		if json.Unmarshal(vuln.FixAvailable, &fix) == nil && fix.Name != "" {
+			fixPackage, fixVersion = fix.Name, fix.Version
+		}
+
+		for _, raw := range vuln.Via {
+			var advisory struct {
+				Name     string `json:"name"`
+				Title    string `json:"title"`
+				URL      string `json:"url"`
+				Severity string `json:"severity"`
+				Range    string `json:"range"`
+			}
+			if json.Unmarshal(raw, &advisory) != nil || advisory.URL == "" {
+				continue // Vulnerable through another package, reported under it
+			}
+			id := path.Base(advisory.URL)
+			if seen[id+"\x00"+advisory.Name] {
+				continue
+			}
+			seen[id+"\x00"+advisory.Name] = true
+			findings = append(findings, &vulnFinding{ecosystem: ecosystemNpm, id: id, pkg: advisory.Name,
+				version: advisory.Range, severity: advisory.Severity, summary: advisory.Title,
+				fixPackage: fixPackage, fixVersion: fixVersion})
+		}
+	}
+	return findings, nil
+}
+
+// scanPython runs pip-audit on the project's requirements.txt, or its environment
+func scanPython(ctx context.Context, dir string) ([]*vulnFinding, error) {
+	args := []string{"-f", "json", "--progress-spinner", "off"}
+	if _, err := os.Stat(filepath.Join(dir, "requirements.txt")); err == nil {
+		args = append(args, "-r", "requirements.txt")
+	}
+	output, err := runScanner(ctx, dir, "pip install pip-audit", "pip-audit", args...)
+	if err != nil {
+		return nil, err
+	}
+	return parsePipAudit(output)
+}
+
+// parsePipAudit reads pip-audit's JSON report: an object with the dependencies, or a list
+// of them from older versions. Its advisories carry no severity.
+func parsePipAudit(output []byte) ([]*vulnFinding, error) {
+	type dependency struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+		Vulns   []struct {
+			ID          string   `json:"id"`
+			FixVersions []string `json:"fix_versions"`
+			Aliases     []string `json:"aliases"`
+			Description string   `json:"description"`
+		} `json:"vulns"`
+	}
+	var report struct {
+		Dependencies []dependency `json:"dependencies"`
+	}
+	if err := json.Unmarshal(output, &report); err != nil {
+		if err := json.Unmarshal(output, &report.Dependencies); err != nil {
+			return nil, serr.Wrap(err, "failed to read pip-audit output")
+		}
+	}
+
+	var findings []*vulnFinding
+	for _, dep := range report.Dependencies {
+		for _, vuln := range dep.Vulns {
+			summary, _, _ := strings.Cut(strings.TrimSpace(vuln.Description), "\n")
+			f := &vulnFinding{ecosystem: "pip", id: vuln.ID, aliases: vuln.Aliases, pkg: dep.Name, version: dep.Version,
+				severity: "unknown", summary: summary, fixPackage: dep.Name}
+			for _, v := range vuln.FixVersions {
+				if f.fixVersion == "" || compareVersions(v, f.fixVersion) < 0 {
+					f.fixVersion = v // The first version with the fix
+				}
+			}
+			findings = append(findings, f)
+		}
+	}
+	return findings, nil
+}
+
+// compareVersions orders versions as semver does, with or without the v, falling back to
+// comparing them as text
+func compareVersions(a, b string) int {
+	va, vb := "v"+strings.TrimPrefix(a, "v"), "v"+strings.TrimPrefix(b, "v")
+	if semver.IsValid(va) && semver.IsValid(vb) {
+		return semver.Compare(va, vb)
+	}
+	return strings.Compare(a, b)
+}
+
+// securityReport lists the findings, most severe first
+func securityReport(scanner string, findings []*vulnFinding, minSeverity string) string {
+	if len(findings) == 0 {
+		if minSeverity != "low" {
+			return fmt.Sprintf("No vulnerabilities of %s severity or above found by %s", minSeverity, scanner)
+		}
+		return "No vulnerabilities found by " + scanner
+	}
+
+	counts := make(map[string]int)
+	for _, f := range findings {
+		counts[f.severity]++
+	}
+	var tally []string
+	for _, severity := range securitySeverities {
+		if counts[severity] > 0 {
+			tally = append(tally, fmt.Sprintf("%d %s", counts[severity], severity))
+		}
+	}
+
+	var sb strings.Builder
+	noun := "vulnerabilities"
+	if len(findings) == 1 {
+		noun = "vulnerability"
+	}
+	fmt.Fprintf(&sb, "%d %s found by %s: %s\n", len(findings), noun, scanner, strings.Join(tally, ", "))
+	for _, f := range findings {
+		id := f.id
+		if len(f.aliases) > 0 {
+			id += " (" + strings.Join(f.aliases, ", ") + ")"
+		}
+		fmt.Fprintf(&sb, "\n[%s] %s in %s", f.severity, id, f.pkg)
+		if f.version != "" {
+			sb.WriteString(" " + f.version)
+		}
+		if f.summary != "" {
+			sb.WriteString("\n  " + f.summary)
+		}
+		switch {
+		case f.fixVersion == "":
+			sb.WriteString("\n  No fixed version yet")
+		case f.fixPackage != f.pkg:
+			fmt.Fprintf(&sb, "\n  Fixed by updating %s to %s", f.fixPackage, f.fixVersion)
+		default:
+			fmt.Fprintf(&sb, "\n  Fixed in %s", f.fixVersion)
+		}
+		if f.location != "" {
+			sb.WriteString("\n  Called from " + f.location)
+		}
+		sb.WriteString("\n")
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// vulnFix is an update of a dependency fixing findings
+type vulnFix struct {
+	pkg      string
+	version  string // The highest any of its findings needs
+	severity string // The most severe of them
+	ids      []string
+}
+
+// fileRemediationPlan files a plan updating the dependencies behind the most severe
+// findings, one after another as they change the same manifests, then scanning again
+func fileRemediationPlan(input map[string]interface{}, language, minSeverity string, findings []*vulnFinding) (string, error) {
+	sessionID, _ := GetString(input, "_sessionId")
+	if planFiler == nil || sessionID == "" {
+		return "", NewPermanentError(serr.New("plans can only be filed in a session"), "no session")
+	}
+
+	fixes := make(map[string]*vulnFix)
+	var order []string
+	var unfixed []string
+	for _, f := range findings {
+		if f.fixVersion == "" {
+			unfixed = append(unfixed, f.id)
+			continue
+		}
+		fix := fixes[f.fixPackage]
+		if fix == nil {
+			fix = &vulnFix{pkg: f.fixPackage, severity: f.severity}
+			fixes[f.fixPackage] = fix
+			order = append(order, f.fixPackage) // Findings come most severe first
+		}
+		if fix.version == "" || compareVersions(f.fixVersion, fix.version) > 0 {
+			fix.version = f.fixVersion
+		}
+		fix.ids = append(fix.ids, f.id)
+	}
+	if len(order) == 0 {
+		return "No plan filed: none of the findings has a fix yet", nil
+	}
+	if max := boundedInt(input, "max_fixes", defaultSecurityFixes, maxSecurityFixes); len(order) > max {
+		order = order[:max]
+	}
+
+	var steps []PlanStep
+	var updated []string
+	for i, pkg := range order {
+		fix := fixes[pkg]
+		step := PlanStep{
+			ID:          fmt.Sprintf("fix_%d", i+1),
+			Description: fmt.Sprintf("Update %s to %s, fixing %s (%s)", fix.pkg, fix.version, strings.Join(fix.ids, ", "), fix.severity),
+		}
+		switch language {
+		case "go":
+			step.Tool = "update_dependency"
+			step.Params = map[string]interface{}{"dependencies": fix.pkg + "@" + fix.version, "ecosystem": ecosystemGo}
+			if fix.pkg == "stdlib" || fix.pkg == "toolchain" {
+				// The standard library is fixed by a newer Go
+				goVersion := "go" + strings.TrimPrefix(fix.version, "v")
+				step.Description = fmt.Sprintf("Build with %s, fixing %s (%s)", goVersion, strings.Join(fix.ids, ", "), fix.severity)
+				step.Tool = "bash"
+				step.Params = map[string]interface{}{"command": "go get toolchain@" + goVersion + " && go build ./... && go test ./..."}
+				updated = append(updated, "Go "+strings.TrimPrefix(fix.version, "v"))
+			}
+		case "python":
+			step.Tool = "bash"
+			step.Params = map[string]interface{}{"command": fmt.Sprintf("python -m pip install '%s==%s'", fix.pkg, fix.version)}
+			step.Description += "; pin it in the requirements too"
+		default:
+			step.Tool = "update_dependency"
+			step.Params = map[string]interface{}{"dependencies": fix.pkg + "@" + fix.version, "ecosystem": ecosystemNpm}
+		}
+		if i > 0 {
+			step.Dependencies = []string{steps[i-1].ID}
+		}
+		steps = append(steps, step)
+		if len(updated) == i {
+			updated = append(updated, fix.pkg+" "+fix.version)
+		}
+	}
+	steps = append(steps, PlanStep{
+		ID:           "rescan",
+		Description:  "Scan for vulnerabilities again",
+		Tool:         "security_scan",
+		Params:       map[string]interface{}{"language": language, "min_severity": minSeverity},
+		Dependencies: []string{steps[len(steps)-1].ID},
+	})
+
+	description := "Fix vulnerabilities by updating " + strings.Join(updated, ", ")
+	planID, err := planFiler.FilePlan(sessionID, description, steps)
+	if err != nil {
+		return "", serr.Wrap(err, "failed to file the remediation plan")
+	}
+
+	note := fmt.Sprintf("Filed plan %s to update %s; the user can review and run it from the plan panel", planID, strings.Join(updated, ", "))
+	if len(unfixed) > 0 {
+		note += fmt.Sprintf(". No fix yet for %s", strings.Join(unfixed, ", "))
+	}
+	return note, nil
+}
@@ -0,0 +1,115 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// Lines of each matching chunk included in semantic search results
+const semanticSnippetLines = 15
+
+// CodeMatch is a span of a file matched by semantic search
+type CodeMatch struct {
+	Path      string
+	StartLine int
+	EndLine   int
+	Score     float64 // Cosine similarity to the query
+	Content   string
+}
+
+// CodeSearcher finds code by meaning rather than exact text.
+// It is implemented by the web layer, since this package cannot import db.
+type CodeSearcher interface {
+	SearchCode(query string, limit int) ([]CodeMatch, error)
+}
+
+// SemanticSearchTool searches the project's embeddings index
+type SemanticSearchTool struct {
+	Searcher CodeSearcher
+	// WordsOnly is set when the index's vectors are hashed words rather than a model's,
+	// so matches share words with the query
+	WordsOnly bool
+}
+
+// GetDefinition returns the tool definition
+func (t *SemanticSearchTool) GetDefinition() Tool {
+	description := "Find code related to a natural language description, even when it shares no exact " +
+		"words with the query. Use search or ripgrep instead for exact names or patterns."
+	if t.WordsOnly {
+		description = "Find code that shares the most words with a description, counting the parts of " +
+			"camelCase and snake_case names. Use search or ripgrep instead for exact names or patterns."
+	}
+	return Tool{
+		Name:        "semantic_search",
+		Description: description,
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"query": map[string]interface{}{
+					"type":        "string",
+					"description": "What the code does, e.g. \"retry failed HTTP requests with backoff\"",
+				},
+				"limit": map[string]interface{}{
+					"type":        "integer",
+					"description": "Maximum number of results (default: 10, max: 50)",
+					"default":     10,
+				},
+			},
+			"required": []string{"query"},
+		},
+	}
+}
+
+// Execute runs the semantic search
+func (t *SemanticSearchTool) Execute(input map[string]interface{}) (string, error) {
+	if t.Searcher == nil {
+		return "", serr.New("semantic search is not available")
+	}
+
+	query, ok := GetString(input, "query")
+	if !ok || strings.TrimSpace(query) == "" {
+		return "", serr.New("query parameter is required")
+	}
+
+	limit := 10
+	if l, ok := GetInt(input, "limit"); ok && l > 0 {
+		limit = l
+		if limit > 50 {
+			limit = 50
+		}
+	}
+
+	matches, err := t.Searcher.SearchCode(query, limit)
+	if err != nil {
+		return "", serr.Wrap(err, "semantic search failed")
+	}
+
+	if len(matches) == 0 {
+		return "No matching code found", nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Found %d matches for: %s\n\n", len(matches), query))
+	for _, m := range matches {
+		sb.WriteString(fmt.Sprintf("%s:%d-%d (similarity %.2f)\n", m.Path, m.StartLine, m.EndLine, m.Score))
+
+		lines := strings.Split(m.Content, "\n")
+		if len(lines) > semanticSnippetLines {
+			lines = append(lines[:semanticSnippetLines], "...")
+		}
+		sb.WriteString("```\n")
+		sb.WriteString(strings.Join(lines, "\n"))
+		sb.WriteString("\n```\n\n")
+	}
+
+	return sb.String(), nil
+}
+
+// RegisterSemanticSearchTool adds the semantic_search tool backed by searcher, whose
+// index holds hashed words rather than a model's embeddings when wordsOnly is set
+func RegisterSemanticSearchTool(registry *Registry, searcher CodeSearcher, wordsOnly bool) {
+	semanticTool := &SemanticSearchTool{Searcher: searcher, WordsOnly: wordsOnly}
+	registry.Register(semanticTool.GetDefinition(), semanticTool)
+}
@@ -0,0 +1,66 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"rcode/config"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// IsSensitivePath reports whether a file is kept from the conversation: its path, relative
+// to the project, or its name matches one of RCODE_SENSITIVE_PATHS' globs and none of
+// RCODE_SENSITIVE_ALLOW's
+func IsSensitivePath(path string) bool {
+	cfg := config.Get()
+	if len(cfg.SensitivePaths) == 0 {
+		return false
+	}
+
+	rel := sensitiveMatchPath(path)
+	if !matchesAny(cfg.SensitivePaths, rel, true) {
+		return false
+	}
+	return len(cfg.SensitiveAllow) == 0 || !matchesAny(cfg.SensitiveAllow, rel, true)
+}
+
+// CheckSensitivePath returns an error naming the file when IsSensitivePath keeps it from
+// being read
+func CheckSensitivePath(path string) error {
+	if !IsSensitivePath(path) {
+		return nil
+	}
+	return NewPermanentError(serr.New(fmt.Sprintf(
+		"%s is a sensitive file and can't be read; the user can allow it with RCODE_SENSITIVE_ALLOW", path)),
+		"sensitive file")
+}
+
+// sensitiveMatchPath returns a path as the globs see it: relative to the project when it
+// is under the project, and with slashes
+func sensitiveMatchPath(path string) string {
+	if expanded, err := ExpandPath(path); err == nil && expanded != "" {
+		path = expanded
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+		if root, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(root, abs); err == nil && !strings.HasPrefix(rel, "..") {
+				path = rel
+			}
+		}
+	}
+	return filepath.ToSlash(filepath.Clean(path))
+}
+
+// sensitiveRipgrepGlobs returns ripgrep arguments leaving out the files RCODE_SENSITIVE_PATHS
+// matches. Ripgrep can't add back the allowed ones, so they are left out too.
+func sensitiveRipgrepGlobs() []string {
+	var args []string
+	for _, glob := range config.Get().SensitivePaths {
+		args = append(args, "--glob", "!"+glob)
+	}
+	return args
+}
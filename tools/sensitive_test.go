@@ -0,0 +1,79 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"rcode/config"
+)
+
+// setSensitiveConfig sets the sensitive path settings for a test, "" for the defaults
+func setSensitiveConfig(t *testing.T, paths, allow string) {
+	t.Helper()
+	t.Cleanup(config.Initialize) // Runs after t.Setenv restores the environment
+	t.Setenv("RCODE_SENSITIVE_PATHS", paths)
+	t.Setenv("RCODE_SENSITIVE_ALLOW", allow)
+	config.Initialize()
+}
+
+func TestIsSensitivePath(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		paths, allow string // RCODE_SENSITIVE_PATHS and RCODE_SENSITIVE_ALLOW
+		path         string
+		want         bool
+	}{
+		{"default env", "", "", ".env", true},
+		{"default env variant", "", "", ".env.local", true},
+		{"default env in a directory", "", "", "deploy/.env.production", true},
+		{"default env absolute", "", "", filepath.Join(wd, ".env"), true},
+		{"default env dot dot", "", "", "src/../.env", true},
+		{"default example", "", "", ".env.example", false},
+		{"default template in a directory", "", "", "deploy/.env.template", false},
+		{"default key", "", "", "keys/id_rsa", true},
+		{"default pem", "", "", "certs/server.pem", true},
+		{"default source", "", "", "main.go", false},
+		{"default lookalike", "", "", "environment.go", false},
+
+		{"allow one", "", ".env.local", ".env.local", false},
+		{"allow one keeps others", "", ".env.local", ".env", true},
+		{"allow replaces the defaults", "", ".env.local", ".env.example", true},
+		{"allow env star", "", ".env*", ".env", false},
+		{"allow env star variant", "", ".env*", "deploy/.env.production", false},
+		{"allow env star keeps keys", "", ".env*", "keys/id_rsa", true},
+		{"allow list", "", ".env.test, *.pem", "certs/server.pem", false},
+		{"allow none", "", "none", ".env.example", true},
+
+		{"paths", "secrets/**", "", "secrets/db/password.txt", true},
+		{"paths replace the defaults", "secrets/**", "", ".env", false},
+		{"paths none", "none", "", ".env", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setSensitiveConfig(t, tt.paths, tt.allow)
+			if got := IsSensitivePath(tt.path); got != tt.want {
+				t.Errorf("IsSensitivePath(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckSensitivePath(t *testing.T) {
+	setSensitiveConfig(t, "", "")
+	if err := CheckSensitivePath("README.md"); err != nil {
+		t.Errorf("CheckSensitivePath(README.md) = %v, want nil", err)
+	}
+	err := CheckSensitivePath(".env")
+	if err == nil {
+		t.Fatal("CheckSensitivePath(.env) = nil, want an error")
+	}
+	if IsRetryableError(err) {
+		t.Errorf("CheckSensitivePath(.env) = %v, want a permanent error", err)
+	}
+}
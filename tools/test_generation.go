@@ -0,0 +1,493 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/format"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"rcode/completion"
+	rcontext "rcode/context"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// Bounds on generate_tests
+const (
+	defaultTestRounds        = 3
+	maxTestRounds            = 10
+	defaultFunctionsPerRound = 5
+	maxFunctionsPerRound     = 20
+	maxTestFixes             = 2     // Times the model may fix a test file that fails before it is discarded
+	maxTestPromptSource      = 60000 // Bytes of the source file sent to the model
+	maxTestPromptExample     = 12000 // Bytes of an existing test file sent as an example of the package's style
+	maxTestPromptNames       = 300   // Names the package declares, listed so the tests don't redeclare them
+)
+
+// Model used to write tests
+var testGenerationModel completion.Client
+
+// SetTestGenerationModel sets the model that writes tests for generate_tests
+func SetTestGenerationModel(model completion.Client) {
+	testGenerationModel = model
+}
+
+// GenerateTestsTool writes tests for the functions a Go module's tests cover least, round
+// after round, keeping the test files that pass
+type GenerateTestsTool struct{}
+
+// generatedTest is a test file written for a source file's functions
+type generatedTest struct {
+	path    string
+	source  string
+	existed bool   // Before generate_tests wrote it
+	kept    bool   // Passes, and stays
+	reason  string // Why it was discarded
+}
+
+// GetDefinition returns the tool definition
+func (t *GenerateTestsTool) GetDefinition() Tool {
+	return Tool{
+		Name: "generate_tests",
+		Description: "Raise a Go module's test coverage: measure it, then in each round write tests for the functions " +
+			"covered least, in a <file>_coverage_test.go next to their source, and measure again. A test file that " +
+			"doesn't compile or fails is sent back for fixing a few times, then discarded, so the suite keeps passing. " +
+			"Stops after the rounds, when every function is covered, or when a round gains nothing. Reports the " +
+			"coverage before and after. Review the tests it keeps: they check what the code does, not what it should do. " +
+			"Set file, and function, to write tests for those alone.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"packages": map[string]interface{}{
+					"type":        "string",
+					"description": "The packages to measure and write tests for, as go test takes them (default: ./...)",
+				},
+				"functions": map[string]interface{}{
+					"type":        "integer",
+					"description": fmt.Sprintf("Functions to write tests for in each round (default: %d, at most %d)", defaultFunctionsPerRound, maxFunctionsPerRound),
+				},
+				"rounds": map[string]interface{}{
+					"type":        "integer",
+					"description": fmt.Sprintf("Rounds of writing tests and measuring (default: %d, at most %d)", defaultTestRounds, maxTestRounds),
+				},
+				"file": map[string]interface{}{
+					"type":        "string",
+					"description": "Only write tests for the functions in this source file",
+				},
+				"function": map[string]interface{}{
+					"type":        "string",
+					"description": "Only write tests for the function or method of this name, e.g. Parse or Get for (*Store).Get",
+				},
+			},
+		},
+	}
+}
+
+// Execute writes the tests
+func (t *GenerateTestsTool) Execute(input map[string]interface{}) (string, error) {
+	return t.ExecuteContext(context.Background(), input)
+}
+
+// ExecuteContext writes the tests, stopping after the file in progress if ctx is cancelled
+func (t *GenerateTestsTool) ExecuteContext(ctx context.Context, input map[string]interface{}) (string, error) {
+	if testGenerationModel == nil {
+		return "", NewPermanentError(serr.New("test generation is not available"), "no model")
+	}
+	packages, _ := GetString(input, "packages")
+	perRound := boundedInt(input, "functions", defaultFunctionsPerRound, maxFunctionsPerRound)
+	rounds := boundedInt(input, "rounds", defaultTestRounds, maxTestRounds)
+	function, _ := GetString(input, "function")
+	file, _ := GetString(input, "file")
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", serr.Wrap(err, "failed to get working directory")
+	}
+	if file != "" {
+		if file, err = ExpandPath(file); err == nil {
+			file, err = filepath.Abs(file) // Coverage names files by absolute path
+		}
+		if err != nil {
+			return "", serr.Wrap(err, "failed to resolve file")
+		}
+	}
+	baseline, failure, err := measureCoverage(ctx, dir, packages)
+	if err != nil {
+		return "", err
+	}
+	if failure != "" {
+		return "", NewPermanentError(serr.New("the tests fail, so coverage can't be measured; fix them first:\n"+failure), "tests fail")
+	}
+
+	current := baseline
+	tried := make(map[string]bool)
+	tests := make(map[string]*generatedTest) // By path, across rounds
+	var order []*generatedTest
+	var log []string
+	stopped := ""
+
+	for round := 1; round <= rounds && stopped == ""; round++ {
+		var targets []*functionCoverage
+		for _, fn := range current.uncovered() {
+			if len(targets) == perRound {
+				break
+			}
+			if !testable(fn) || (file != "" && fn.file != file) || (function != "" && !namesFunction(fn, function)) {
+				continue
+			}
+			if label := current.label(fn); !tried[label] {
+				tried[label] = true
+				targets = append(targets, fn)
+			}
+		}
+		if len(targets) == 0 {
+			if round == 1 {
+				stopped = "every function is covered, or has had tests written for it"
+			}
+			break
+		}
+
+		// One test file per source file
+		bySource := make(map[string][]*functionCoverage)
+		var sources []string
+		for _, fn := range targets {
+			if bySource[fn.file] == nil {
+				sources = append(sources, fn.file)
+			}
+			bySource[fn.file] = append(bySource[fn.file], fn)
+		}
+		for _, source := range sources {
+			if ctx.Err() != nil {
+				stopped = "cancelled"
+				break
+			}
+			test := tests[coverageTestPath(source)]
+			if test == nil {
+				test = &generatedTest{path: coverageTestPath(source), source: source}
+				_, err := os.Stat(test.path)
+				test.existed = err == nil
+				tests[test.path] = test
+				order = append(order, test)
+			}
+			if err := writeCoverageTest(ctx, current.root, test, bySource[source]); err != nil {
+				if !anyKept(order) {
+					return "", err
+				}
+				stopped = "tests could not be written: " + err.Error()
+				break
+			}
+		}
+
+		// Measured even when the round stopped early, for the files it kept
+		next, failure, err := measureCoverage(ctx, dir, packages)
+		if err != nil || failure != "" {
+			if stopped == "" {
+				// Each file passed its package's tests, so a test is flaky
+				stopped = "coverage could not be measured again"
+			}
+			break
+		}
+		log = append(log, fmt.Sprintf("Round %d: tests for %d function(s) in %d file(s), coverage %.1f%% → %.1f%%",
+			round, len(targets), len(sources), current.percent(), next.percent()))
+		if next.covered <= current.covered && stopped == "" {
+			stopped = "the round covered no more statements"
+		}
+		current = next
+	}
+
+	for _, test := range order {
+		switch {
+		case test.kept && test.existed:
+			NotifyFileChange(test.path, "modified")
+		case test.kept:
+			NotifyFileChange(test.path, "created")
+		}
+	}
+	return testGenerationSummary(baseline, current, log, stopped, order), nil
+}
+
+// testable reports whether a test can call the function: main and init can't be
+func testable(fn *functionCoverage) bool {
+	return fn.name != "main" && fn.name != "init"
+}
+
+// namesFunction reports whether name is the function's, or its method name without the receiver
+func namesFunction(fn *functionCoverage, name string) bool {
+	return fn.name == name || strings.HasSuffix(fn.name, ")."+name)
+}
+
+// anyKept reports whether any of the test files are kept
+func anyKept(tests []*generatedTest) bool {
+	for _, test := range tests {
+		if test.kept {
+			return true
+		}
+	}
+	return false
+}
+
+// boundedInt returns an integer input, its default when it is missing or not positive, and
+// at most max
+func boundedInt(input map[string]interface{}, key string, def, max int) int {
+	n, ok := GetInt(input, key)
+	if !ok || n <= 0 {
+		n = def
+	}
+	if n > max {
+		n = max
+	}
+	return n
+}
+
+// coverageTestPath is the test file generate_tests writes for a source file
+func coverageTestPath(source string) string {
+	return strings.TrimSuffix(source, ".go") + "_coverage_test.go"
+}
+
+// writeCoverageTest asks the model for tests of a source file's functions and writes them,
+// sending failures back for fixing. A file that still fails is put back the way it was
+// before, which may be the tests an earlier round kept.
+func writeCoverageTest(ctx context.Context, root string, test *generatedTest, functions []*functionCoverage) error {
+	pkgDir := filepath.Dir(test.source)
+	pkgName, err := goPackageName(pkgDir)
+	if err != nil {
+		return err
+	}
+	current, err := os.ReadFile(test.path)
+	if err != nil && !os.IsNotExist(err) {
+		return WrapFileSystemError(serr.Wrap(err, "failed to read test file", "path", test.path))
+	}
+
+	prompt, err := testGenerationPrompt(root, pkgName, test, functions, current)
+	if err != nil {
+		return err
+	}
+	for attempt := 0; attempt <= maxTestFixes; attempt++ {
+		reply, err := testGenerationModel.Complete(prompt)
+		if err != nil {
+			restoreCoverageTest(test, current)
+			test.reason = "the model didn't reply"
+			return NewRetryableError(serr.Wrap(err, "failed to generate tests"), "model error")
+		}
+		content := []byte(cleanCommitMessage(reply) + "\n")
+		if formatted, err := format.Source(content); err == nil {
+			content = formatted
+		}
+		if err := os.WriteFile(test.path, content, 0644); err != nil {
+			return WrapFileSystemError(serr.Wrap(err, "failed to write test file", "path", test.path))
+		}
+
+		output, passed, err := runPackageTests(ctx, root, pkgDir)
+		if err != nil {
+			restoreCoverageTest(test, current)
+			return err
+		}
+		if passed {
+			test.kept = true
+			return nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		test.reason = "the tests fail"
+		if strings.Contains(output, "[build failed]") || strings.Contains(output, "[setup failed]") {
+			test.reason = "it doesn't compile"
+		}
+		prompt = fmt.Sprintf("%s\n\nThe file you wrote:\n%s\nfails `go test`:\n%s\n\n"+
+			"Reply with the whole corrected file. Where a test expects something the code doesn't do, change the test "+
+			"to what the code does, or drop it.", prompt, content, testFailure(output))
+	}
+
+	restoreCoverageTest(test, current)
+	if ctx.Err() != nil {
+		test.reason = "cancelled"
+	}
+	return nil
+}
+
+// restoreCoverageTest puts a test file back as it was before this round wrote it
+func restoreCoverageTest(test *generatedTest, previous []byte) {
+	if previous != nil {
+		os.WriteFile(test.path, previous, 0644)
+		return
+	}
+	os.Remove(test.path)
+}
+
+// runPackageTests runs the tests of the package in dir, reporting whether they pass
+func runPackageTests(ctx context.Context, root, dir string) (string, bool, error) {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return "", false, serr.Wrap(err, "failed to resolve package")
+	}
+	ctx, cancel := context.WithTimeout(ctx, coverageTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "go", "test", "-count=1", "./"+filepath.ToSlash(rel))
+	cmd.Dir = root
+	configureProcessGroup(cmd)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err = cmd.Run()
+	if _, isExit := err.(*exec.ExitError); err != nil && !isExit && ctx.Err() == nil {
+		return "", false, serr.Wrap(err, "failed to run go test")
+	}
+	return output.String(), err == nil, nil
+}
+
+// testGenerationPrompt asks for a test file covering the functions, showing their source,
+// the names the package already declares, and an existing test file as an example
+func testGenerationPrompt(root, pkgName string, test *generatedTest, functions []*functionCoverage, current []byte) (string, error) {
+	source, err := os.ReadFile(test.source)
+	if err != nil {
+		return "", WrapFileSystemError(serr.Wrap(err, "failed to read source file", "path", test.source))
+	}
+	rel := func(path string) string {
+		if r, err := filepath.Rel(root, path); err == nil {
+			return filepath.ToSlash(r)
+		}
+		return path
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Write Go tests for the functions below from %s, which the tests don't fully cover yet.\n\n", rel(test.source))
+	sb.WriteString("Rules:\n")
+	fmt.Fprintf(&sb, "- Reply with the whole of %s only, in package %s so unexported functions can be called, "+
+		"without code fences or commentary\n", filepath.Base(test.path), pkgName)
+	sb.WriteString("- Use the standard library and the modules go.mod already requires, nothing else\n")
+	sb.WriteString("- Test what the code does now; the tests must pass against it\n")
+	sb.WriteString("- Prefer table-driven tests; use t.TempDir for files; no network, sleeps or reliance on the environment\n")
+	sb.WriteString("- Don't redeclare the names the package already declares, listed below\n\n")
+
+	sb.WriteString("Functions to cover:\n")
+	for _, fn := range functions {
+		fmt.Fprintf(&sb, "- %s:%d %s, %d of %d statements covered\n", rel(fn.file), fn.line, fn.name, fn.covered, fn.statements)
+	}
+
+	if names := packageNames(filepath.Dir(test.source)); len(names) > 0 {
+		sb.WriteString("\nNames the package declares:\n" + strings.Join(names, ", ") + "\n")
+	}
+	if gomod, err := os.ReadFile(filepath.Join(root, "go.mod")); err == nil {
+		fmt.Fprintf(&sb, "\ngo.mod:\n%s\n", gomod)
+	}
+
+	fmt.Fprintf(&sb, "\n%s:\n%s\n", rel(test.source), truncateBytes(source, maxTestPromptSource))
+	if current != nil {
+		fmt.Fprintf(&sb, "\n%s already holds the tests below. Keep them, and add to them:\n%s\n", filepath.Base(test.path), current)
+	} else if example, name := exampleTest(test.source); example != nil {
+		fmt.Fprintf(&sb, "\n%s, an existing test file to follow the style of:\n%s\n", name, truncateBytes(example, maxTestPromptExample))
+	}
+	return sb.String(), nil
+}
+
+// packageNames returns the names declared in the Go files of dir, tests included, sorted
+func packageNames(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	scanner := rcontext.NewProjectScanner()
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".go") {
+			continue
+		}
+		symbols, err := scanner.FileSymbols(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		for _, sym := range symbols {
+			if sym.Receiver == "" { // Methods can't clash with package-level names
+				seen[sym.Name] = true
+			}
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) > maxTestPromptNames {
+		names = append(names[:maxTestPromptNames], "...")
+	}
+	return names
+}
+
+// exampleTest returns the source file's own test file, or another in its directory that
+// generate_tests didn't write
+func exampleTest(source string) ([]byte, string) {
+	candidates := []string{strings.TrimSuffix(source, ".go") + "_test.go"}
+	if matches, err := filepath.Glob(filepath.Join(filepath.Dir(source), "*_test.go")); err == nil {
+		candidates = append(candidates, matches...)
+	}
+	for _, candidate := range candidates {
+		if strings.HasSuffix(candidate, "_coverage_test.go") {
+			continue
+		}
+		if data, err := os.ReadFile(candidate); err == nil {
+			return data, filepath.Base(candidate)
+		}
+	}
+	return nil, ""
+}
+
+// truncateBytes keeps the first n bytes of data, marking the cut
+func truncateBytes(data []byte, n int) string {
+	if len(data) <= n {
+		return string(data)
+	}
+	return string(data[:n]) + "\n// ... (truncated)"
+}
+
+// testGenerationSummary reports the coverage before and after, each round, the test files
+// kept and discarded, and the functions still not fully covered
+func testGenerationSummary(before, after *coverageReport, log []string, stopped string, tests []*generatedTest) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Coverage: %.1f%% → %.1f%% (%+.1f points) in %s\n", before.percent(), after.percent(),
+		after.percent()-before.percent(), before.packages)
+	for _, line := range log {
+		sb.WriteString(line + "\n")
+	}
+	if stopped != "" {
+		sb.WriteString("Stopped: " + stopped + "\n")
+	}
+
+	var kept, discarded []string
+	for _, test := range tests {
+		rel, _ := filepath.Rel(after.root, test.path)
+		rel = filepath.ToSlash(rel)
+		switch {
+		case test.kept && test.existed:
+			kept = append(kept, "  M "+rel)
+		case test.kept:
+			kept = append(kept, "  A "+rel)
+		default:
+			discarded = append(discarded, fmt.Sprintf("  %s (%s)", rel, test.reason))
+		}
+	}
+	if len(kept) > 0 {
+		sb.WriteString("\nTest files written:\n" + strings.Join(kept, "\n") + "\n")
+	}
+	if len(discarded) > 0 {
+		sb.WriteString("\nDiscarded, after they failed to be fixed:\n" + strings.Join(discarded, "\n") + "\n")
+	}
+
+	uncovered := after.uncovered()
+	if len(uncovered) > 0 {
+		fmt.Fprintf(&sb, "\nStill not fully covered: %d function(s)", len(uncovered))
+		for i, fn := range uncovered {
+			if i == 10 {
+				sb.WriteString("\n  ...")
+				break
+			}
+			fmt.Fprintf(&sb, "\n  %s %.1f%%", after.label(fn), percent(fn.covered, fn.statements))
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
@@ -0,0 +1,24 @@
+package tools
+
+import "testing"
+
+func TestNamesFunction(t *testing.T) {
+	tests := []struct {
+		fn   string
+		name string
+		want bool
+	}{
+		{fn: "Parse", name: "Parse", want: true},
+		{fn: "(*Store).Get", name: "Get", want: true},
+		{fn: "(Store).Get", name: "Get", want: true},
+		{fn: "(*Store).Get", name: "(*Store).Get", want: true},
+		{fn: "GetAll", name: "Get", want: false},
+		{fn: "(*Store).GetAll", name: "Get", want: false},
+		{fn: "(*Getter).Run", name: "Get", want: false},
+	}
+	for _, tt := range tests {
+		if got := namesFunction(&functionCoverage{name: tt.fn}, tt.name); got != tt.want {
+			t.Errorf("namesFunction(%q, %q) = %v, want %v", tt.fn, tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rohanthewiz/serr"
+)
+
+// maxTodos bounds a session's task list
+const maxTodos = 50
+
+// Todo statuses, in the order work moves through them
+var todoStatuses = []string{"pending", "in_progress", "completed"}
+
+// Todo is an item of a session's task list
+type Todo struct {
+	Content string
+	Status  string // pending, in_progress or completed
+}
+
+// TodoStore keeps each session's task list.
+// It is implemented by the web layer, which owns the database and tells the pages.
+type TodoStore interface {
+	// ReadTodos returns the session's task list, in order
+	ReadTodos(sessionID string) ([]Todo, error)
+	// WriteTodos replaces the session's task list
+	WriteTodos(sessionID string, todos []Todo) error
+}
+
+// Store the todo tools use
+var todoStore TodoStore
+
+// SetTodoStore sets the store the todo tools use
+func SetTodoStore(store TodoStore) {
+	todoStore = store
+}
+
+// TodoWriteTool replaces the session's task list, which the user sees as a checklist
+type TodoWriteTool struct{}
+
+// GetDefinition returns the tool definition
+func (t *TodoWriteTool) GetDefinition() Tool {
+	return Tool{
+		Name: "todo_write",
+		Description: "Write the task list for this session, shown to the user as a checklist. Use it for work of three or more " +
+			"steps, or when the user gives several tasks: list the steps, mark one in_progress before starting it, and mark it " +
+			"completed as soon as it is done, before starting the next. Each call replaces the whole list, so send every item " +
+			"with its current status. Skip it for a single simple task.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"todos": map[string]interface{}{
+					"type":        "array",
+					"description": "The whole task list, in order",
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"content": map[string]interface{}{
+								"type":        "string",
+								"description": "The task, in a short imperative sentence",
+							},
+							"status": map[string]interface{}{
+								"type":        "string",
+								"description": "At most one task is in_progress at a time",
+								"enum":        todoStatuses,
+							},
+						},
+						"required": []string{"content", "status"},
+					},
+				},
+			},
+			"required": []string{"todos"},
+		},
+	}
+}
+
+// Execute validates and stores the task list
+func (t *TodoWriteTool) Execute(input map[string]interface{}) (string, error) {
+	if todoStore == nil {
+		return "", NewPermanentError(serr.New("the task list is not available"), "no store")
+	}
+	sessionID, _ := GetString(input, "_sessionId")
+	if sessionID == "" {
+		return "", NewPermanentError(serr.New("the task list can only be kept in a session"), "no session")
+	}
+
+	todos, err := parseTodos(input["todos"])
+	if err != nil {
+		return "", NewPermanentError(err, "invalid todos")
+	}
+	if err := todoStore.WriteTodos(sessionID, todos); err != nil {
+		return "", serr.Wrap(err, "failed to write the task list")
+	}
+
+	return "Task list updated: " + todoProgress(todos) + "\n\n" + FormatTodos(todos), nil
+}
+
+// TodoReadTool returns the session's task list
+type TodoReadTool struct{}
+
+// GetDefinition returns the tool definition
+func (t *TodoReadTool) GetDefinition() Tool {
+	return Tool{
+		Name:        "todo_read",
+		Description: "Read the task list of this session, as last written with todo_write, to see what is done and what is left",
+		InputSchema: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{},
+		},
+	}
+}
+
+// Execute returns the task list as a checklist
+func (t *TodoReadTool) Execute(input map[string]interface{}) (string, error) {
+	if todoStore == nil {
+		return "", NewPermanentError(serr.New("the task list is not available"), "no store")
+	}
+	sessionID, _ := GetString(input, "_sessionId")
+
+	todos, err := todoStore.ReadTodos(sessionID)
+	if err != nil {
+		return "", serr.Wrap(err, "failed to read the task list")
+	}
+	if len(todos) == 0 {
+		return "The task list is empty", nil
+	}
+
+	return todoProgress(todos) + "\n\n" + FormatTodos(todos), nil
+}
+
+// parseTodos reads the todos parameter, checking each item and that at most one is in progress
+func parseTodos(value interface{}) ([]Todo, error) {
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, serr.New("todos parameter is required, as an array of {content, status}")
+	}
+	if len(items) > maxTodos {
+		return nil, serr.New(fmt.Sprintf("the task list can hold %d items; merge or drop some", maxTodos))
+	}
+
+	todos := make([]Todo, 0, len(items))
+	inProgress := 0
+	for i, item := range items {
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, serr.New(fmt.Sprintf("todo %d must be an object with content and status", i+1))
+		}
+		content, _ := GetString(fields, "content")
+		content = strings.TrimSpace(content)
+		if content == "" {
+			return nil, serr.New(fmt.Sprintf("todo %d has no content", i+1))
+		}
+		status, _ := GetString(fields, "status")
+		if status == "" {
+			status = "pending"
+		}
+		if !isTodoStatus(status) {
+			return nil, serr.New(fmt.Sprintf("todo %d has status %q; use pending, in_progress or completed", i+1, status))
+		}
+		if status == "in_progress" {
+			inProgress++
+		}
+		todos = append(todos, Todo{Content: content, Status: status})
+	}
+	if inProgress > 1 {
+		return nil, serr.New(fmt.Sprintf("%d todos are in_progress; work on one at a time", inProgress))
+	}
+	return todos, nil
+}
+
+func isTodoStatus(status string) bool {
+	for _, s := range todoStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// todoProgress counts the completed todos, e.g. "2 of 5 completed"
+func todoProgress(todos []Todo) string {
+	completed := 0
+	for _, todo := range todos {
+		if todo.Status == "completed" {
+			completed++
+		}
+	}
+	return fmt.Sprintf("%d of %d completed", completed, len(todos))
+}
+
+// FormatTodos renders a task list as a markdown checklist, marking the task in progress
+func FormatTodos(todos []Todo) string {
+	var sb strings.Builder
+	for _, todo := range todos {
+		switch todo.Status {
+		case "completed":
+			sb.WriteString("- [x] " + todo.Content)
+		case "in_progress":
+			sb.WriteString("- [ ] " + todo.Content + " (in progress)")
+		default:
+			sb.WriteString("- [ ] " + todo.Content)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
@@ -1,7 +1,12 @@
 package tools
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/rohanthewiz/logger"
 )
 
 // Tool represents a tool that can be used by the AI
@@ -31,10 +36,16 @@ type Executor interface {
 	Execute(input map[string]interface{}) (string, error)
 }
 
+// ContextExecutor is implemented by tools that can be interrupted through a context
+type ContextExecutor interface {
+	ExecuteContext(ctx context.Context, input map[string]interface{}) (string, error)
+}
+
 // Registry holds all available tools
 type Registry struct {
 	tools     map[string]Tool
 	executors map[string]Executor
+	limits    *ToolLimits // The built-in limits when nil
 }
 
 // NewRegistry creates a new tool registry
@@ -51,6 +62,11 @@ func (r *Registry) Register(tool Tool, executor Executor) {
 	r.executors[tool.Name] = executor
 }
 
+// SetLimits sets the timeouts, retries and concurrency the tools' calls run under
+func (r *Registry) SetLimits(limits *ToolLimits) {
+	r.limits = limits
+}
+
 // GetTools returns all registered tools
 func (r *Registry) GetTools() []Tool {
 	tools := make([]Tool, 0, len(r.tools))
@@ -62,12 +78,18 @@ func (r *Registry) GetTools() []Tool {
 
 // Execute runs a tool and returns the result
 func (r *Registry) Execute(toolUse ToolUse) (*ToolResult, error) {
+	return r.ExecuteContext(context.Background(), toolUse)
+}
+
+// ExecuteContext runs a tool, passing ctx to tools that support cancellation.
+// Other tools are not started once ctx is done, but otherwise run to completion.
+func (r *Registry) ExecuteContext(ctx context.Context, toolUse ToolUse) (*ToolResult, error) {
 	executor, exists := r.executors[toolUse.Name]
 	if !exists {
 		return nil, &ToolError{Message: "Unknown tool: " + toolUse.Name}
 	}
 
-	result, err := executor.Execute(toolUse.Input)
+	result, err := r.run(ctx, executor, toolUse)
 	if err != nil {
 		// Return both the error result and the error itself
 		// This allows the enhanced registry to handle retries
@@ -85,6 +107,96 @@ func (r *Registry) Execute(toolUse ToolUse) (*ToolResult, error) {
 	}, nil
 }
 
+// builtinLimits are the limits of registries without their own
+var builtinLimits = DefaultToolLimits()
+
+// run calls the executor under the tool's limit: it waits while the session already has
+// as many calls of the tool running as the limit allows, retries with backoff after
+// retryable errors, and stops the call at the timeout
+func (r *Registry) run(ctx context.Context, executor Executor, toolUse ToolUse) (string, error) {
+	limits := r.limits
+	if limits == nil {
+		limits = builtinLimits
+	}
+	limit := limits.For(toolUse.Name)
+
+	if limit.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, limit.Timeout, &toolTimeoutError{tool: toolUse.Name, timeout: limit.Timeout})
+		defer cancel()
+	}
+
+	if limit.Concurrency > 0 {
+		sessionID, _ := GetString(toolUse.Input, "_sessionId")
+		release, err := acquireToolSlot(ctx, sessionID, toolUse.Name, limit.Concurrency)
+		if err != nil {
+			return "", context.Cause(ctx)
+		}
+		defer release()
+	}
+
+	if limit.Retries == 0 {
+		return callExecutor(ctx, executor, toolUse.Input)
+	}
+
+	var output string
+	policy := NetworkRetryPolicy
+	policy.MaxAttempts = limit.Retries
+	policy.RetryableErrors = func(err error) bool {
+		var timeout *toolTimeoutError
+		return ctx.Err() == nil && !errors.As(err, &timeout) && IsRetryableError(err)
+	}
+	retried := Retry(ctx, policy, func(ctx context.Context) error {
+		var err error
+		output, err = callExecutor(ctx, executor, toolUse.Input)
+		return err
+	})
+	if retried.Attempts > 1 {
+		logger.Info("Retried tool", "tool", toolUse.Name, "attempts", retried.Attempts, "success", retried.Success)
+	}
+	if retried.LastError != nil && ctx.Err() != nil {
+		return "", context.Cause(ctx)
+	}
+	return output, retried.LastError
+}
+
+// callExecutor runs one attempt of a call. Tools that take a context are stopped when ctx
+// is done; others can't be, so the call returns and the tool finishes in the background.
+func callExecutor(ctx context.Context, executor Executor, input map[string]interface{}) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", context.Cause(ctx)
+	}
+	if ctxExecutor, ok := executor.(ContextExecutor); ok {
+		output, err := ctxExecutor.ExecuteContext(ctx, input)
+		if err != nil && ctx.Err() != nil {
+			return output, context.Cause(ctx)
+		}
+		return output, err
+	}
+
+	type outcome struct {
+		output string
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		// A panic here would stop the server rather than fail the request
+		defer func() {
+			if p := recover(); p != nil {
+				done <- outcome{err: fmt.Errorf("tool panicked: %v", p)}
+			}
+		}()
+		output, err := executor.Execute(input)
+		done <- outcome{output, err}
+	}()
+	select {
+	case o := <-done:
+		return o.output, o.err
+	case <-ctx.Done():
+		return "", context.Cause(ctx)
+	}
+}
+
 // ToolError represents a tool execution error
 type ToolError struct {
 	Message string
@@ -178,6 +178,24 @@ func (v *ToolValidator) initializeDefaultRules() {
 		},
 	}
 
+	// Background processes get the same command checks as bash
+	v.rules["run_background"] = ValidationRules{
+		RequiredParams: []string{"command"},
+		ParamRules: map[string]ParamRule{
+			"command": {
+				Type:      "string",
+				MinLength: 1,
+				MaxLength: 10000,
+			},
+			"wait": {
+				Type:     "integer",
+				MinValue: 0,
+				MaxValue: 30,
+			},
+		},
+		CustomRules: v.rules["bash"].CustomRules,
+	}
+
 	// Directory operations
 	v.rules["list_dir"] = ValidationRules{
 		ParamRules: map[string]ParamRule{
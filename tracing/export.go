@@ -0,0 +1,256 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/serr"
+)
+
+const (
+	queueSize     = 4096            // Finished spans waiting for export; more are dropped
+	batchSize     = 512             // Most spans sent in one request
+	flushInterval = 5 * time.Second // Longest a finished span waits for export
+)
+
+// exporter sends finished spans to a collector in batches
+type exporter struct {
+	endpoint string
+	service  string
+	client   *http.Client
+	spans    chan *Span
+	stop     chan struct{}
+	done     chan struct{}
+	dropped  atomic.Int64
+}
+
+var (
+	active   atomic.Pointer[exporter]
+	shutOnce sync.Once
+)
+
+func current() *exporter {
+	return active.Load()
+}
+
+// Init starts exporting spans to the OTLP/HTTP traces endpoint, e.g.
+// http://localhost:4318/v1/traces, naming the service that recorded them. An empty
+// endpoint leaves tracing off.
+func Init(endpoint, service string) {
+	if endpoint == "" {
+		return
+	}
+	e := &exporter{
+		endpoint: endpoint,
+		service:  service,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		spans:    make(chan *Span, queueSize),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if !active.CompareAndSwap(nil, e) {
+		return
+	}
+	go e.run()
+	logger.Info("Exporting traces", "endpoint", endpoint)
+}
+
+// Enabled reports whether spans are being recorded
+func Enabled() bool {
+	return current() != nil
+}
+
+// Shutdown exports the spans still queued and stops recording new ones, waiting at most timeout
+func Shutdown(timeout time.Duration) {
+	e := current()
+	if e == nil {
+		return
+	}
+	shutOnce.Do(func() {
+		active.Store(nil)
+		close(e.stop)
+	})
+	select {
+	case <-e.done:
+	case <-time.After(timeout):
+		logger.Warn("Timed out exporting the last traces")
+	}
+}
+
+// enqueue queues a finished span, dropping it if the queue is full so work never waits on the collector
+func (e *exporter) enqueue(s *Span) {
+	select {
+	case e.spans <- s:
+	default:
+		e.dropped.Add(1)
+	}
+}
+
+func (e *exporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	var batch []*Span
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.export(batch); err != nil {
+			logger.LogErr(err, "failed to export traces")
+		}
+		batch = nil
+		if n := e.dropped.Swap(0); n > 0 {
+			logger.Warn("Dropped spans as the export queue was full", "spans", strconv.FormatInt(n, 10))
+		}
+	}
+
+	for {
+		select {
+		case s := <-e.spans:
+			batch = append(batch, s)
+			if len(batch) >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-e.stop:
+			for {
+				select {
+				case s := <-e.spans:
+					batch = append(batch, s)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// export posts spans to the collector as an OTLP ExportTraceServiceRequest
+func (e *exporter) export(spans []*Span) error {
+	otlpSpans := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		otlpSpans = append(otlpSpans, s.otlp())
+	}
+	body, err := json.Marshal(otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: otlpAttrs([]Attr{String("service.name", e.service)})},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: e.service},
+			Spans: otlpSpans,
+		}},
+	}}})
+	if err != nil {
+		return serr.Wrap(err, "failed to marshal spans")
+	}
+
+	resp, err := e.client.Post(e.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return serr.Wrap(err, "failed to send spans")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return serr.New("collector returned " + resp.Status)
+	}
+	return nil
+}
+
+// The OTLP/JSON encoding of spans. IDs are hex and 64-bit integers are strings.
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttr `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []otlpAttr `json:"attributes,omitempty"`
+	Status            otlpStatus `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"` // 2 is an error
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttr struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+const spanKindInternal = 1
+
+func (s *Span) otlp() otlpSpan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	span := otlpSpan{
+		TraceID:           hex.EncodeToString(s.sc.TraceID[:]),
+		SpanID:            hex.EncodeToString(s.sc.SpanID[:]),
+		Name:              s.name,
+		Kind:              spanKindInternal,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		Attributes:        otlpAttrs(s.attrs),
+	}
+	if s.parent != [8]byte{} {
+		span.ParentSpanID = hex.EncodeToString(s.parent[:])
+	}
+	if s.errMsg != "" {
+		span.Status = otlpStatus{Code: 2, Message: s.errMsg}
+	}
+	return span
+}
+
+func otlpAttrs(attrs []Attr) []otlpAttr {
+	out := make([]otlpAttr, 0, len(attrs))
+	for _, a := range attrs {
+		var value map[string]any
+		switch v := a.Value.(type) {
+		case string:
+			value = map[string]any{"stringValue": v}
+		case int:
+			value = map[string]any{"intValue": strconv.Itoa(v)}
+		case int64:
+			value = map[string]any{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]any{"doubleValue": v}
+		case bool:
+			value = map[string]any{"boolValue": v}
+		default:
+			continue
+		}
+		out = append(out, otlpAttr{Key: a.Key, Value: value})
+	}
+	return out
+}
@@ -0,0 +1,158 @@
+// Package tracing records spans of work, such as a session's turn and the model requests,
+// tool calls and database writes in it, and exports them to an OpenTelemetry collector
+// (Jaeger, Tempo and others) over OTLP/HTTP as JSON. Until Init is given an endpoint no
+// spans are recorded: Start returns a nil span, whose methods do nothing.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SpanContext identifies a span and the trace it belongs to
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+}
+
+// IsValid reports whether the IDs are set
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// TraceParent formats the span context as a W3C traceparent header, marked as sampled
+func (sc SpanContext) TraceParent() string {
+	return "00-" + hex.EncodeToString(sc.TraceID[:]) + "-" + hex.EncodeToString(sc.SpanID[:]) + "-01"
+}
+
+// ParseTraceParent reads a W3C traceparent header, as sent by a caller that traces its requests
+func ParseTraceParent(header string) (SpanContext, bool) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, false
+	}
+	return sc, sc.IsValid()
+}
+
+// Attr is an attribute of a span
+type Attr struct {
+	Key   string
+	Value any // A string, int, int64, float64 or bool
+}
+
+// String returns a string attribute
+func String(key, value string) Attr { return Attr{key, value} }
+
+// Int returns an integer attribute
+func Int(key string, value int) Attr { return Attr{key, value} }
+
+// Bool returns a boolean attribute
+func Bool(key string, value bool) Attr { return Attr{key, value} }
+
+// Span is a timed piece of work in a trace. A nil span, as Start returns when tracing is
+// off, may be used like any other.
+type Span struct {
+	name   string
+	sc     SpanContext
+	parent [8]byte
+	start  time.Time
+
+	mu     sync.Mutex
+	end    time.Time
+	attrs  []Attr
+	errMsg string // Set when the work failed
+}
+
+type spanKey struct{}
+
+type remoteKey struct{}
+
+// Start begins a span named for the work, a child of the span in ctx if there is one, and
+// returns a context carrying it. End the span when the work is done.
+func Start(ctx context.Context, name string, attrs ...Attr) (context.Context, *Span) {
+	if current() == nil {
+		return ctx, nil
+	}
+
+	span := &Span{name: name, start: time.Now(), attrs: attrs}
+	if parent := SpanContextFrom(ctx); parent.IsValid() {
+		span.sc.TraceID = parent.TraceID
+		span.parent = parent.SpanID
+	} else {
+		_, _ = rand.Read(span.sc.TraceID[:])
+	}
+	_, _ = rand.Read(span.sc.SpanID[:])
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// WithRemoteParent returns a context whose spans continue a caller's trace
+func WithRemoteParent(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// SpanContextFrom returns the span context of the span in ctx, or of the caller's trace
+// ctx continues. It is not valid when there is neither.
+func SpanContextFrom(ctx context.Context) SpanContext {
+	if span, ok := ctx.Value(spanKey{}).(*Span); ok && span != nil {
+		return span.sc
+	}
+	sc, _ := ctx.Value(remoteKey{}).(SpanContext)
+	return sc
+}
+
+// TraceID returns the span's trace ID in hex, as Jaeger and Tempo show it, or "" for a nil span
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return hex.EncodeToString(s.sc.TraceID[:])
+}
+
+// SetAttributes adds attributes to the span
+func (s *Span) SetAttributes(attrs ...Attr) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attrs = append(s.attrs, attrs...)
+}
+
+// SetError marks the span as failed with err. A nil err leaves it as it is.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errMsg = err.Error()
+}
+
+// End finishes the span and queues it for export. Later calls do nothing.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if !s.end.IsZero() {
+		s.mu.Unlock()
+		return
+	}
+	s.end = time.Now()
+	s.mu.Unlock()
+
+	if e := current(); e != nil {
+		e.enqueue(s)
+	}
+}
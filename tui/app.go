@@ -0,0 +1,538 @@
+// Package tui is a terminal client for the rcode server. It lists sessions, streams
+// their messages and tool activity from the server's events, and answers permission
+// requests, so rcode can be used without a browser. It is a bubbletea program: app is
+// its model, and the server's events reach it as messages.
+package tui
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"rcode/client"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/rohanthewiz/serr"
+)
+
+// Main runs the TUI with the arguments that follow "rcode tui", and returns the exit code
+func Main(args []string) int {
+	flags := flag.NewFlagSet("rcode tui", flag.ContinueOnError)
+	server := flags.String("server", envOr("RCODE_SERVER", "http://localhost:8000"), "URL of the rcode server")
+	token := flags.String("token", os.Getenv("RCODE_API_TOKEN"), "API token, for servers in multi-user mode")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if err := Run(client.New(*server, *token)); err != nil {
+		fmt.Fprintln(os.Stderr, "Error: "+err.Error())
+		return 1
+	}
+	return 0
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// Run shows the TUI until the user quits
+func Run(api *client.Client) error {
+	sessions, err := api.ListSessions(context.Background())
+	if err != nil {
+		return serr.New(fmt.Sprintf("can't reach the rcode server at %s: %v", api.BaseURL(), err))
+	}
+
+	a := &app{
+		client:   api,
+		events:   make(chan tea.Msg, 256),
+		sessions: sessions,
+		width:    80,
+		height:   24,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go a.streamEvents(ctx)
+
+	// The alternate screen leaves the shell's as it was
+	if _, err := tea.NewProgram(a, tea.WithAltScreen()).Run(); err != nil {
+		return serr.Wrap(err, "the TUI failed")
+	}
+	return nil
+}
+
+type screen int
+
+const (
+	screenSessions screen = iota
+	screenChat
+)
+
+type entryKind int
+
+const (
+	entryUser entryKind = iota
+	entryAssistant
+	entryTool
+	entryNotice
+	entryError
+)
+
+// entry is one item of the transcript: a message, a tool's result or a notice
+type entry struct {
+	kind entryKind
+	text string
+}
+
+// permissionPrompt is a tool's request for permission, waiting for the user's answer
+type permissionPrompt struct {
+	sessionID string
+	requestID string
+	toolName  string
+	detail    string
+}
+
+// Messages handled by Update besides bubbletea's own. eventMsg and statusMsg come from
+// the event stream; the others are the results of the commands that call the server.
+type (
+	eventMsg    client.Event
+	statusMsg   string
+	sessionsMsg struct {
+		sessions []client.Session
+		err      error
+	}
+	openedMsg struct {
+		session  client.Session
+		messages []client.ChatMessage
+		err      error
+	}
+	repliedMsg struct {
+		sessionID string
+		reply     *client.MessageReply
+		err       error
+	}
+	errorMsg struct{ err error }
+)
+
+// maxHistoryLines limits how much of a long earlier message is shown, such as the session's initial context
+const maxHistoryLines = 8
+
+type app struct {
+	client *client.Client
+	events chan tea.Msg // From streamEvents, read by waitForEvent
+
+	width, height int
+
+	screen   screen
+	sessions []client.Session
+	selected int
+
+	session    *client.Session
+	entries    []entry
+	streaming  bool // The last entry is the model's reply, still being written
+	busy       bool // Waiting for the model's reply
+	input      []rune
+	scroll     int // Lines scrolled up from the end of the transcript
+	permission *permissionPrompt
+	status     string
+}
+
+// streamEvents follows the server's events, reconnecting when the stream drops
+func (a *app) streamEvents(ctx context.Context) {
+	for ctx.Err() == nil {
+		connected := false
+		err := a.client.StreamEvents(ctx, func(event client.Event) {
+			if !connected {
+				connected = true
+				a.events <- statusMsg("")
+			}
+			a.events <- eventMsg(event)
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		a.events <- statusMsg("Event stream lost (" + err.Error() + "); reconnecting…")
+		time.Sleep(2 * time.Second)
+	}
+}
+
+// waitForEvent is the command that delivers the next message from the event stream.
+// It is issued again each time one is handled.
+func (a *app) waitForEvent() tea.Msg {
+	return <-a.events
+}
+
+func (a *app) Init() tea.Cmd {
+	return a.waitForEvent
+}
+
+func (a *app) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		return a, a.handleKey(msg)
+
+	case tea.WindowSizeMsg:
+		a.width, a.height = msg.Width, msg.Height
+
+	case eventMsg:
+		return a, tea.Batch(a.handleEvent(client.Event(msg)), a.waitForEvent)
+
+	case statusMsg:
+		a.status = string(msg)
+		return a, a.waitForEvent
+
+	case sessionsMsg:
+		if msg.err != nil {
+			a.status = "Failed to load sessions: " + msg.err.Error()
+			break
+		}
+		a.sessions = msg.sessions
+		a.selected = min(a.selected, max(len(a.sessions)-1, 0))
+
+	case openedMsg:
+		if msg.err != nil {
+			a.status = "Failed to open session: " + msg.err.Error()
+			break
+		}
+		session := msg.session
+		a.session = &session
+		a.entries = historyEntries(msg.messages)
+		a.screen = screenChat
+		a.streaming, a.busy, a.scroll, a.input = false, false, 0, nil
+		a.status = ""
+
+	case repliedMsg:
+		if a.session == nil || msg.sessionID != a.session.ID {
+			break
+		}
+		if msg.err == nil && msg.reply.QueueID != "" {
+			a.status = fmt.Sprintf("Queued behind %d message(s); sent when the reply finishes", msg.reply.Ahead)
+			break
+		}
+		a.busy, a.streaming = false, false
+		if msg.err != nil {
+			a.addEntry(entryError, msg.err.Error())
+		} else if msg.reply.Error != "" {
+			a.addEntry(entryError, msg.reply.Error)
+		} else if !msg.reply.Streamed && msg.reply.Content != "" {
+			a.addEntry(entryAssistant, msg.reply.Content) // A slash command's answer
+		}
+
+	case errorMsg:
+		a.status = msg.err.Error()
+	}
+	return a, nil
+}
+
+func (a *app) handleKey(key tea.KeyMsg) tea.Cmd {
+	if key.Type == tea.KeyCtrlC {
+		return tea.Quit
+	}
+
+	if a.permission != nil {
+		return a.answerPermission(key)
+	}
+
+	if a.screen == screenSessions {
+		switch key.String() {
+		case "up", "k":
+			a.selected = max(a.selected-1, 0)
+		case "down", "j":
+			a.selected = min(a.selected+1, max(len(a.sessions)-1, 0))
+		case "enter":
+			if a.selected < len(a.sessions) {
+				return a.openSession(a.sessions[a.selected])
+			}
+		case "n":
+			a.status = "Creating session…"
+			return func() tea.Msg {
+				session, err := a.client.CreateSession(context.Background(), client.CreateSessionRequest{})
+				if err != nil {
+					return openedMsg{err: err}
+				}
+				return openedMsg{session: *session}
+			}
+		case "r":
+			return a.loadSessions
+		case "q", "esc":
+			return tea.Quit
+		}
+		return nil
+	}
+
+	switch key.Type {
+	case tea.KeyEsc:
+		a.screen = screenSessions
+		a.session = nil
+		return a.loadSessions
+	case tea.KeyEnter:
+		return a.send()
+	case tea.KeyBackspace:
+		if len(a.input) > 0 {
+			a.input = a.input[:len(a.input)-1]
+		}
+	case tea.KeyCtrlU:
+		a.input = nil
+	case tea.KeyUp:
+		a.scroll++
+	case tea.KeyDown:
+		a.scroll = max(a.scroll-1, 0)
+	case tea.KeyPgUp:
+		a.scroll += a.pageSize()
+	case tea.KeyPgDown:
+		a.scroll = max(a.scroll-a.pageSize(), 0)
+	case tea.KeyRunes, tea.KeySpace:
+		a.input = append(a.input, key.Runes...)
+	}
+	return nil
+}
+
+// answerPermission answers the pending permission request: y allows, a always allows, n denies
+func (a *app) answerPermission(key tea.KeyMsg) tea.Cmd {
+	var approved, remember bool
+	switch key.String() {
+	case "y":
+		approved = true
+	case "a":
+		approved, remember = true, true
+	case "n", "esc":
+	default:
+		return nil
+	}
+
+	p := a.permission
+	a.permission = nil
+	verb := "Denied"
+	if approved {
+		verb = "Allowed"
+	}
+	if a.session != nil && p.sessionID == a.session.ID {
+		a.addEntry(entryNotice, fmt.Sprintf("%s %s", verb, p.toolName))
+	}
+	return func() tea.Msg {
+		_, err := a.client.RespondToPermission(context.Background(), client.PermissionResponse{
+			RequestID:      p.requestID,
+			SessionID:      p.sessionID,
+			Approved:       approved,
+			RememberChoice: remember,
+		})
+		if err != nil {
+			return errorMsg{serr.New("Failed to answer the permission request: " + err.Error())}
+		}
+		return nil
+	}
+}
+
+// loadSessions is the command that fetches the session list
+func (a *app) loadSessions() tea.Msg {
+	sessions, err := a.client.ListSessions(context.Background())
+	return sessionsMsg{sessions: sessions, err: err}
+}
+
+func (a *app) openSession(session client.Session) tea.Cmd {
+	a.status = "Loading " + session.Title + "…"
+	return func() tea.Msg {
+		messages, err := a.client.ListMessages(context.Background(), session.ID)
+		return openedMsg{session: session, messages: messages, err: err}
+	}
+}
+
+// send sends the typed message; the reply streams in on the event stream. A message sent
+// while the model is replying is queued by the server and sent when the reply finishes.
+func (a *app) send() tea.Cmd {
+	content := strings.TrimSpace(string(a.input))
+	if content == "" || a.session == nil {
+		return nil
+	}
+
+	a.input = nil
+	a.scroll = 0
+	a.busy = true
+	a.addEntry(entryUser, content)
+
+	sessionID := a.session.ID
+	return func() tea.Msg {
+		reply, err := a.client.SendMessage(context.Background(), sessionID, client.MessageRequest{Content: content})
+		return repliedMsg{sessionID: sessionID, reply: reply, err: err}
+	}
+}
+
+func (a *app) addEntry(kind entryKind, text string) {
+	a.entries = append(a.entries, entry{kind: kind, text: text})
+	a.streaming = false
+}
+
+// handleEvent shows an event from the server. Permission requests are answered from any
+// screen; the other events are shown only for the open session.
+func (a *app) handleEvent(event client.Event) tea.Cmd {
+	switch event.Type {
+	case "session_list_updated":
+		return a.loadSessions
+
+	case "permission_request":
+		var data struct {
+			RequestID        string `json:"requestId"`
+			ToolName         string `json:"toolName"`
+			ParameterDisplay string `json:"parameterDisplay"`
+		}
+		if json.Unmarshal(event.Data, &data) == nil {
+			a.permission = &permissionPrompt{
+				sessionID: event.SessionID,
+				requestID: data.RequestID,
+				toolName:  data.ToolName,
+				detail:    data.ParameterDisplay,
+			}
+		}
+		return nil
+
+	case "permission_timeout":
+		var data struct {
+			RequestID string `json:"requestId"`
+		}
+		if json.Unmarshal(event.Data, &data) == nil && a.permission != nil && a.permission.requestID == data.RequestID {
+			a.permission = nil
+			a.status = "The permission request timed out"
+		}
+		return nil
+	}
+
+	if a.session == nil || event.SessionID != a.session.ID {
+		return nil
+	}
+
+	switch event.Type {
+	case "message_start":
+		a.busy = true
+		a.status = ""
+
+	case "rate_limit_wait":
+		var data struct {
+			WaitSeconds int    `json:"waitSeconds"`
+			Reason      string `json:"reason"`
+		}
+		if json.Unmarshal(event.Data, &data) == nil {
+			a.status = fmt.Sprintf("Waiting %ds for the API's rate limits (%s)", data.WaitSeconds, data.Reason)
+		}
+
+	case "model_fallback":
+		var data struct {
+			Model    string `json:"model"`
+			Fallback string `json:"fallback"`
+		}
+		if json.Unmarshal(event.Data, &data) == nil {
+			a.status = fmt.Sprintf("%s is unavailable, falling back to %s", data.Model, data.Fallback)
+		}
+
+	case "message_delta":
+		var data struct {
+			Delta string `json:"delta"`
+		}
+		if json.Unmarshal(event.Data, &data) != nil {
+			return nil
+		}
+		if a.streaming {
+			a.entries[len(a.entries)-1].text += data.Delta
+		} else {
+			a.addEntry(entryAssistant, data.Delta)
+			a.streaming = true
+		}
+
+	case "message_stop":
+		a.streaming = false
+
+	case "queued_message_finished":
+		// A queued message's turn has no reply of its own to end it
+		var data struct {
+			Error string `json:"error"`
+		}
+		a.busy, a.streaming = false, false
+		if json.Unmarshal(event.Data, &data) == nil && data.Error != "" {
+			a.addEntry(entryError, data.Error)
+		}
+
+	case "tool_execution_start":
+		var data struct {
+			ToolName   string                 `json:"toolName"`
+			Parameters map[string]interface{} `json:"parameters"`
+		}
+		if json.Unmarshal(event.Data, &data) == nil {
+			a.status = "Running " + data.ToolName + " " + toolDetail(data.Parameters)
+			a.streaming = false
+		}
+
+	case "tool_execution_complete":
+		var data struct {
+			ToolName string `json:"toolName"`
+			Summary  string `json:"summary"`
+		}
+		if json.Unmarshal(event.Data, &data) == nil {
+			a.addEntry(entryTool, fmt.Sprintf("[%s] %s", data.ToolName, strings.TrimSpace(data.Summary)))
+			a.status = ""
+		}
+	}
+	return nil
+}
+
+// toolDetail picks the parameter that says most about a tool call
+func toolDetail(params map[string]interface{}) string {
+	for _, name := range []string{"path", "command", "pattern", "query", "url"} {
+		if value, ok := params[name].(string); ok && value != "" {
+			return truncate(value, 60)
+		}
+	}
+	return ""
+}
+
+// historyEntries turns a session's messages into transcript entries, leaving out tool results
+func historyEntries(messages []client.ChatMessage) []entry {
+	var entries []entry
+	for _, m := range messages {
+		kind := entryAssistant
+		if m.Role == "user" {
+			kind = entryUser
+		}
+
+		switch content := m.Content.(type) {
+		case string:
+			entries = append(entries, entry{kind: kind, text: clipLines(content, maxHistoryLines)})
+		case []interface{}:
+			for _, block := range content {
+				b, _ := block.(map[string]interface{})
+				switch b["type"] {
+				case "text":
+					if text, _ := b["text"].(string); text != "" {
+						entries = append(entries, entry{kind: kind, text: clipLines(text, maxHistoryLines)})
+					}
+				case "tool_use":
+					input, _ := b["input"].(map[string]interface{})
+					entries = append(entries, entry{kind: entryTool, text: fmt.Sprintf("[%v] %s", b["name"], toolDetail(input))})
+				}
+			}
+		}
+	}
+	return entries
+}
+
+// clipLines shortens text to its first n lines
+func clipLines(text string, n int) string {
+	lines := strings.Split(text, "\n")
+	if len(lines) <= n {
+		return text
+	}
+	return strings.Join(lines[:n], "\n") + fmt.Sprintf("\n… (%d more lines)", len(lines)-n)
+}
+
+func truncate(s string, n int) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,169 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	headerStyle   = lipgloss.NewStyle().Reverse(true).Bold(true)
+	selectedStyle = lipgloss.NewStyle().Reverse(true)
+	dimStyle      = lipgloss.NewStyle().Faint(true)
+	userStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("6"))
+	toolStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("3"))
+	errorStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	promptStyle   = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("3"))
+	cursorStyle   = lipgloss.NewStyle().Reverse(true)
+)
+
+// View lays out the screen
+func (a *app) View() string {
+	if a.screen == screenSessions {
+		return strings.Join(a.viewSessions(a.width, a.height), "\n")
+	}
+	return strings.Join(a.viewChat(a.width, a.height), "\n")
+}
+
+func (a *app) viewSessions(width, height int) []string {
+	lines := []string{header(" rcode — sessions", width), ""}
+
+	listHeight := height - 4
+	first := max(a.selected-listHeight+1, 0)
+	for i := first; i < len(a.sessions) && i < first+listHeight; i++ {
+		session := a.sessions[i]
+		updated := session.UpdatedAt.Local().Format("Jan 2 15:04")
+		title := truncate(session.Title, max(width-len(updated)-6, 10))
+		line := fmt.Sprintf("  %-*s  %s", max(width-len(updated)-6, 10), title, updated)
+		if i == a.selected {
+			line = selectedStyle.Render("›" + line[1:])
+		}
+		lines = append(lines, line)
+	}
+	if len(a.sessions) == 0 {
+		lines = append(lines, dimStyle.Render("  No sessions yet; press n to start one"))
+	}
+
+	for len(lines) < height-1 {
+		lines = append(lines, "")
+	}
+	return append(lines, a.footer("↑/↓ select · Enter open · n new · r refresh · q quit", width))
+}
+
+// viewChat lays out the transcript above a status line and the input line
+func (a *app) viewChat(width, height int) []string {
+	lines := []string{header(" rcode — "+a.session.Title, width)}
+
+	transcript := a.transcriptLines(width)
+	pageHeight := a.pageSize()
+	a.scroll = min(a.scroll, max(len(transcript)-pageHeight, 0))
+	end := len(transcript) - a.scroll
+	start := max(end-pageHeight, 0)
+	lines = append(lines, transcript[start:end]...)
+	for len(lines) < height-2 {
+		lines = append(lines, "")
+	}
+
+	hint := "Enter send · ↑/↓ PgUp/PgDn scroll · Esc sessions · Ctrl+C quit"
+	if a.busy {
+		hint = "Claude is working… · PgUp/PgDn scroll · Esc sessions"
+	}
+	lines = append(lines, a.footer(hint, width))
+
+	if a.permission != nil {
+		p := a.permission
+		prompt := fmt.Sprintf("Allow %s", p.toolName)
+		if p.detail != "" {
+			prompt += " (" + p.detail + ")"
+		}
+		if a.session == nil || p.sessionID != a.session.ID {
+			prompt += " in another session"
+		}
+		prompt = truncate(prompt, max(width-30, 10)) + "? [y]es [a]lways [n]o"
+		return append(lines, promptStyle.Render(prompt))
+	}
+
+	// Show the end of long input, with room for the cursor
+	input := string(a.input)
+	visible := width - 4
+	if n := utf8.RuneCountInString(input); n > visible {
+		input = string(a.input[n-visible:])
+	}
+	return append(lines, userStyle.Render("› ")+input+cursorStyle.Render(" "))
+}
+
+// footer is the status line: the status if there is one, otherwise the key hints
+func (a *app) footer(hint string, width int) string {
+	if a.permission != nil && a.screen == screenSessions {
+		return promptStyle.Render(truncate("Allow "+a.permission.toolName+"? [y]es [a]lways [n]o", width))
+	}
+	if a.status != "" {
+		return toolStyle.Render(truncate(a.status, width))
+	}
+	return dimStyle.Render(truncate(hint, width))
+}
+
+// pageSize is the height of the transcript
+func (a *app) pageSize() int {
+	return max(a.height-3, 1)
+}
+
+// transcriptLines renders the entries wrapped to the width, with a blank line between messages
+func (a *app) transcriptLines(width int) []string {
+	var lines []string
+	for i, e := range a.entries {
+		if i > 0 && !(e.kind == entryTool && a.entries[i-1].kind == entryTool) {
+			lines = append(lines, "")
+		}
+
+		style, prefix := lipgloss.NewStyle(), "  "
+		switch e.kind {
+		case entryUser:
+			style, prefix = userStyle, "› "
+		case entryTool:
+			style = toolStyle
+		case entryNotice:
+			style = dimStyle
+		case entryError:
+			style, prefix = errorStyle, "! "
+		}
+
+		for j, line := range wrap(e.text, max(width-2, 10)) {
+			if j == 0 {
+				lines = append(lines, style.Render(prefix+line))
+			} else {
+				lines = append(lines, style.Render("  "+line))
+			}
+		}
+	}
+	return lines
+}
+
+// wrap breaks text into lines of at most width runes, at spaces where it can
+func wrap(text string, width int) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		line = strings.ReplaceAll(line, "\t", "    ")
+		runes := []rune(line)
+		for len(runes) > width {
+			cut := width
+			if i := strings.LastIndex(string(runes[:width]), " "); i > 0 {
+				cut = utf8.RuneCountInString(string(runes[:width])[:i])
+			}
+			lines = append(lines, string(runes[:cut]))
+			runes = []rune(strings.TrimLeft(string(runes[cut:]), " "))
+		}
+		lines = append(lines, string(runes))
+	}
+	return lines
+}
+
+func header(title string, width int) string {
+	title = truncate(title, width)
+	if pad := width - utf8.RuneCountInString(title); pad > 0 {
+		title += strings.Repeat(" ", pad)
+	}
+	return headerStyle.Render(title)
+}
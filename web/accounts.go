@@ -0,0 +1,92 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"rcode/auth"
+	"rcode/db"
+	"rcode/providers"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/rweb"
+	"github.com/rohanthewiz/serr"
+)
+
+// accountRefreshInterval is how often the tokens about to expire are refreshed
+const accountRefreshInterval = time.Minute
+
+// AccountUpdate sets the Claude account a session uses, "" for the default
+type AccountUpdate struct {
+	Account string `json:"account"`
+}
+
+// AccountRefreshFailure tells the pages an account's token couldn't be refreshed
+type AccountRefreshFailure struct {
+	Account string `json:"account"`
+	Error   string `json:"error"`
+}
+
+// InitAccountRefresh refreshes the accounts' tokens before they expire, telling the pages
+// with an account_refresh_failed event when one can't be
+func InitAccountRefresh() {
+	auth.OnRefreshFailed(func(account string, err error) {
+		sseHub.Broadcast(SSEEvent{
+			Type: "account_refresh_failed",
+			Data: AccountRefreshFailure{Account: account, Error: err.Error()},
+		})
+	})
+
+	go func() {
+		for {
+			auth.RefreshExpiring()
+			time.Sleep(accountRefreshInterval)
+		}
+	}()
+}
+
+// modelsConnected reports whether messages can be sent: a Claude account is logged in, or
+// RCODE_MODEL_BACKENDS sends every model's requests elsewhere, e.g. to a local server
+func modelsConnected() bool {
+	if !providers.NeedsClaudeLogin() {
+		return true
+	}
+	_, err := auth.GetAccessToken()
+	return err == nil
+}
+
+// sessionAccount returns the account a session's requests use: its own, or the default
+// when it has none or its account was removed
+func sessionAccount(session *Session) string {
+	if session.Account == "" || auth.HasAccount(session.Account) {
+		return session.Account
+	}
+	logger.Warn("Session's Claude account is gone, using the default account", "session_id", session.ID, "account", session.Account)
+	return ""
+}
+
+// setSessionAccountHandler sets the Claude account the session's requests use. The session's
+// pages are told with a session_account event.
+func setSessionAccountHandler(c rweb.Context) error {
+	sessionID := c.Request().Param("id")
+
+	var update AccountUpdate
+	if err := json.Unmarshal(c.Request().Body(), &update); err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid request body"), 400)
+	}
+	if update.Account != "" && !auth.HasAccount(update.Account) {
+		return c.WriteError(serr.New(fmt.Sprintf("no Claude account named %q", update.Account)), 400)
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+	if err := database.SetSessionAccount(sessionID, update.Account); err != nil {
+		return c.WriteError(err, 404)
+	}
+
+	BroadcastSessionUpdate(sessionID, "session_account", update)
+	return c.WriteJSON(update)
+}
@@ -0,0 +1,164 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"rcode/db"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/rweb"
+	"github.com/rohanthewiz/serr"
+)
+
+const apiTokenContextKey = "api_token"
+
+// CreateAPITokenRequest is the body of the endpoint that issues an API token
+type CreateAPITokenRequest struct {
+	Name          string `json:"name"`
+	Scope         string `json:"scope"`
+	ExpiresInDays int    `json:"expires_in_days"` // 0 for a token that doesn't expire
+}
+
+// bearerToken returns the token from the request's Authorization header, or an RCode token
+// from its x-api-key header, where Anthropic's SDKs send their key to the messages proxy
+func bearerToken(c rweb.Context) string {
+	header := c.Request().Header("Authorization")
+	if token, ok := strings.CutPrefix(header, "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	if key := strings.TrimSpace(c.Request().Header("x-api-key")); strings.HasPrefix(key, db.APITokenPrefix) {
+		return key
+	}
+	return ""
+}
+
+// requireToken checks the API token of requests that carry one, and limits them to its
+// scope. In multi-user mode it signs the request in as the token's user, so requireUser
+// doesn't look for a login cookie. Requests without a token pass through unchanged.
+func requireToken(c rweb.Context) error {
+	token := bearerToken(c)
+	if token == "" {
+		return c.Next()
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+	apiToken, err := database.GetAPIToken(token)
+	if err != nil {
+		return c.WriteError(err, 500)
+	}
+	if apiToken == nil {
+		return c.WriteError(serr.New("invalid or expired API token"), http.StatusUnauthorized)
+	}
+
+	if multiUser {
+		user, err := database.GetUser(apiToken.UserID)
+		if err != nil {
+			return c.WriteError(err, 500)
+		}
+		if user == nil {
+			return c.WriteError(serr.New("the API token has no user; issue a new one"), http.StatusUnauthorized)
+		}
+		c.Set(userContextKey, user)
+	}
+
+	if !scopeAllows(apiToken.Scope, c.Request().Method(), c.Request().Path()) {
+		return c.WriteError(serr.New("the API token's "+apiToken.Scope+" scope doesn't allow this"), http.StatusForbidden)
+	}
+	c.Set(apiTokenContextKey, apiToken)
+	return c.Next()
+}
+
+// scopeAllows reports whether a token of the scope may make a request. Admin endpoints and
+// token management need the admin scope, and the read scope is limited to GET.
+func scopeAllows(scope, method, path string) bool {
+	if scope == db.ScopeAdmin {
+		return true
+	}
+	if strings.HasPrefix(path, "/api/tokens") {
+		return false
+	}
+	for _, prefix := range adminPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return false
+		}
+	}
+	if scope == db.ScopeRead {
+		return method == http.MethodGet || method == http.MethodHead
+	}
+	return scope == db.ScopeTools
+}
+
+// listAPITokensHandler returns the caller's API tokens, without the tokens themselves
+func listAPITokensHandler(c rweb.Context) error {
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	tokens, err := database.ListAPITokens(currentUserID(c))
+	if err != nil {
+		return c.WriteError(err, 500)
+	}
+	return c.WriteJSON(tokens)
+}
+
+// createAPITokenHandler issues an API token for the caller. The token is in the response
+// only; just a hash of it is kept.
+func createAPITokenHandler(c rweb.Context) error {
+	var req CreateAPITokenRequest
+	if err := json.Unmarshal(c.Request().Body(), &req); err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid request body"), 400)
+	}
+	if req.ExpiresInDays < 0 {
+		return c.WriteError(serr.New("expires_in_days can't be negative"), 400)
+	}
+	if user := currentUser(c); user != nil && req.Scope == db.ScopeAdmin && !user.IsAdmin {
+		return c.WriteError(serr.New("only admins can issue admin tokens"), http.StatusForbidden)
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	duration := time.Duration(req.ExpiresInDays) * 24 * time.Hour
+	token, apiToken, err := database.CreateAPIToken(req.Name, req.Scope, currentUserID(c), duration)
+	if err != nil {
+		return c.WriteError(err, 400)
+	}
+
+	logger.Info("Issued API token", "name", apiToken.Name, "scope", apiToken.Scope, "user", strconv.Itoa(apiToken.UserID))
+	return c.WriteJSON(map[string]interface{}{
+		"token":     token,
+		"api_token": apiToken,
+	})
+}
+
+// deleteAPITokenHandler revokes one of the caller's API tokens
+func deleteAPITokenHandler(c rweb.Context) error {
+	id, err := strconv.Atoi(c.Request().Param("id"))
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid token ID"), 400)
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	if err := database.DeleteAPIToken(id, currentUserID(c)); err != nil {
+		return c.WriteError(err, 404)
+	}
+
+	logger.Info("Revoked API token", "id", strconv.Itoa(id))
+	return c.WriteJSON(map[string]interface{}{
+		"success": true,
+	})
+}
@@ -0,0 +1,48 @@
+package web
+
+import (
+	"net/http"
+	"testing"
+
+	"rcode/db"
+)
+
+func TestScopeAllows(t *testing.T) {
+	type scopeCase struct {
+		scope, method, path string
+		want                bool
+	}
+	tests := []scopeCase{
+		{db.ScopeRead, http.MethodGet, "/api/session", true},
+		{db.ScopeRead, http.MethodHead, "/api/session", true},
+		{db.ScopeRead, http.MethodPost, "/api/session", false},
+		{db.ScopeRead, http.MethodDelete, "/api/session/abc", false},
+		{db.ScopeTools, http.MethodGet, "/api/session", true},
+		{db.ScopeTools, http.MethodPost, "/api/session/abc/message", true},
+		{db.ScopeTools, http.MethodDelete, "/api/session/abc", true},
+		{db.ScopeAdmin, http.MethodPost, "/api/session", true},
+		{"", http.MethodGet, "/api/session", false},
+		{"write", http.MethodPost, "/api/session", false},
+
+		// Managing tokens needs the admin scope
+		{db.ScopeRead, http.MethodGet, "/api/tokens", false},
+		{db.ScopeTools, http.MethodPost, "/api/tokens", false},
+		{db.ScopeTools, http.MethodDelete, "/api/tokens/3", false},
+		{db.ScopeAdmin, http.MethodPost, "/api/tokens", true},
+	}
+	// So do the admin endpoints, even to read them
+	for _, prefix := range adminPrefixes {
+		path := prefix + "x"
+		tests = append(tests,
+			scopeCase{db.ScopeRead, http.MethodGet, path, false},
+			scopeCase{db.ScopeTools, http.MethodGet, path, false},
+			scopeCase{db.ScopeTools, http.MethodPost, path, false},
+			scopeCase{db.ScopeAdmin, http.MethodPost, path, true},
+		)
+	}
+	for _, tt := range tests {
+		if got := scopeAllows(tt.scope, tt.method, tt.path); got != tt.want {
+			t.Errorf("scopeAllows(%q, %s, %s) = %v, want %v", tt.scope, tt.method, tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package web
+
+import (
+	"fmt"
+	"mime"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"rcode/config"
+	"rcode/db"
+	"rcode/tools"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/rweb"
+	"github.com/rohanthewiz/serr"
+)
+
+// artifactPruneInterval is how often the artifacts' retention is applied
+const artifactPruneInterval = 24 * time.Hour
+
+// dbArtifactStore backs the artifact tools with the artifacts table
+type dbArtifactStore struct{}
+
+// ReadArtifact returns the content of the session's artifact, if it is text
+func (dbArtifactStore) ReadArtifact(sessionID string, id int) (string, error) {
+	database, err := db.GetDB()
+	if err != nil {
+		return "", serr.Wrap(err, "failed to get database")
+	}
+	artifact, err := database.GetArtifact(sessionID, id)
+	if err != nil {
+		return "", err
+	}
+	if artifact == nil {
+		return "", serr.New(fmt.Sprintf("artifact %d not found in this session", id))
+	}
+	if !utf8.Valid(artifact.Content) {
+		return "", serr.New(fmt.Sprintf("artifact %d (%s) is binary; the user can download it", id, artifact.ContentType))
+	}
+	return string(artifact.Content), nil
+}
+
+// PublishArtifact stores the artifact and tells the session's pages about it
+func (dbArtifactStore) PublishArtifact(artifact tools.Artifact) (int, error) {
+	database, err := db.GetDB()
+	if err != nil {
+		return 0, serr.Wrap(err, "failed to get database")
+	}
+
+	a := &db.Artifact{
+		SessionID:   artifact.SessionID,
+		PlanID:      artifact.PlanID,
+		Name:        artifact.Name,
+		Kind:        artifact.Kind,
+		Description: artifact.Description,
+		ContentType: artifact.ContentType,
+		Content:     artifact.Content,
+	}
+	if err := database.CreateArtifact(a); err != nil {
+		return 0, err
+	}
+
+	logger.Info("Published artifact", "id", a.ID, "name", a.Name, "session_id", a.SessionID, "plan_id", a.PlanID)
+	BroadcastArtifactPublished(a)
+	return a.ID, nil
+}
+
+// InitArtifactStore gives the artifact tools the database
+func InitArtifactStore() {
+	tools.SetArtifactStore(dbArtifactStore{})
+}
+
+// InitArtifactRetention deletes artifacts older than RCODE_ARTIFACT_RETENTION_DAYS now and once a day
+func InitArtifactRetention() {
+	go func() {
+		for {
+			pruneArtifacts()
+			time.Sleep(artifactPruneInterval)
+		}
+	}()
+}
+
+// pruneArtifacts deletes the artifacts older than the retention setting allows
+func pruneArtifacts() {
+	days := config.Get().ArtifactRetention
+	if days == 0 {
+		return
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		logger.LogErr(err, "failed to get database for artifact retention")
+		return
+	}
+
+	deleted, err := database.PruneArtifacts(time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		logger.LogErr(err, "failed to prune artifacts")
+		return
+	}
+	if deleted > 0 {
+		logger.Info("Pruned artifacts", "deleted", strconv.FormatInt(deleted, 10))
+	}
+}
+
+// truncateToolResult keeps a result longer than RCODE_TOOL_RESULT_MAX whole as an artifact
+// and shortens it to its start and end, with a note telling the model how to read the rest.
+// It returns the artifact's ID, or 0 if the result was left as it is.
+func truncateToolResult(database *db.DB, sessionID string, toolUse tools.ToolUse, result *tools.ToolResult) int {
+	limit := config.Get().ToolResultMax
+	// Reading an artifact never makes another; read_artifact returns at most a page
+	if result == nil || len(result.Content) <= limit || toolUse.Name == "read_artifact" {
+		return 0
+	}
+
+	artifact := &db.Artifact{
+		SessionID:   sessionID,
+		Name:        toolUse.Name + "-output.txt",
+		Kind:        db.ArtifactKindToolOutput,
+		ToolUseID:   toolUse.ID,
+		ContentType: "text/plain; charset=utf-8",
+		Content:     []byte(result.Content),
+	}
+	if err := database.CreateArtifact(artifact); err != nil {
+		// Token usage stays bounded even without a copy to read the rest from
+		logger.LogErr(err, "failed to store tool output, truncating it without a copy", "tool", toolUse.Name)
+		result.Content = tools.TruncateOutput(result.Content, limit, func(lines, bytes int) string {
+			return fmt.Sprintf("[... %d lines (%d bytes) of output omitted ...]", lines, bytes)
+		})
+		return 0
+	}
+
+	result.Content = tools.TruncateOutput(result.Content, limit, func(lines, bytes int) string {
+		return fmt.Sprintf("[... %d lines (%d bytes) omitted. The full output is artifact %d: "+
+			"call read_artifact with id %d and an offset, limit or pattern to read the rest ...]", lines, bytes, artifact.ID, artifact.ID)
+	})
+	return artifact.ID
+}
+
+// listSessionArtifactsHandler lists a session's artifacts, without their content;
+// ?kind= keeps those of one kind
+func listSessionArtifactsHandler(c rweb.Context) error {
+	return listArtifacts(c, db.ArtifactFilter{
+		SessionID: c.Request().Param("id"),
+		Kind:      c.Request().QueryParam("kind"),
+	})
+}
+
+// listPlanArtifactsHandler lists the artifacts a plan's steps published
+func listPlanArtifactsHandler(c rweb.Context) error {
+	return listArtifacts(c, db.ArtifactFilter{
+		PlanID: c.Request().Param("id"),
+		Kind:   c.Request().QueryParam("kind"),
+	})
+}
+
+func listArtifacts(c rweb.Context, filter db.ArtifactFilter) error {
+	if filter.Kind != "" && !db.ValidArtifactKind(filter.Kind) {
+		return c.WriteError(serr.New("unknown artifact kind: "+filter.Kind), 400)
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	artifacts, err := database.ListArtifacts(filter)
+	if err != nil {
+		return c.WriteError(err, 500)
+	}
+	return c.WriteJSON(artifacts)
+}
+
+// downloadArtifactHandler sends an artifact's content as a file
+func downloadArtifactHandler(c rweb.Context) error {
+	id, err := strconv.Atoi(c.Request().Param("artifactId"))
+	if err != nil {
+		return c.WriteError(serr.New("invalid artifact ID"), 400)
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	artifact, err := database.GetArtifact(c.Request().Param("id"), id)
+	if err != nil {
+		return c.WriteError(err, 500)
+	}
+	if artifact == nil {
+		return c.WriteError(serr.New("artifact not found"), 404)
+	}
+
+	if err := rweb.File(c, artifactFileName(artifact), artifact.Content); err != nil {
+		return err
+	}
+	c.Response().SetHeader("Content-Type", artifact.ContentType)
+	return nil
+}
+
+// deleteArtifactHandler deletes one of a session's artifacts
+func deleteArtifactHandler(c rweb.Context) error {
+	id, err := strconv.Atoi(c.Request().Param("artifactId"))
+	if err != nil {
+		return c.WriteError(serr.New("invalid artifact ID"), 400)
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	deleted, err := database.DeleteArtifact(c.Request().Param("id"), id)
+	if err != nil {
+		return c.WriteError(err, 500)
+	}
+	if !deleted {
+		return c.WriteError(serr.New("artifact not found"), 404)
+	}
+	return c.WriteJSON(map[string]bool{"success": true})
+}
+
+// artifactFileName is the name an artifact is downloaded as: its own, without any
+// directories, with an extension for its content type if it has none
+func artifactFileName(artifact *db.Artifact) string {
+	name := filepath.Base(strings.ReplaceAll(artifact.Name, "\\", "/"))
+	if name == "." || name == "/" {
+		name = fmt.Sprintf("artifact-%d", artifact.ID)
+	}
+	if filepath.Ext(name) == "" {
+		if exts, _ := mime.ExtensionsByType(artifact.ContentType); len(exts) > 0 {
+			name += exts[0]
+		}
+	}
+	return name
+}
@@ -8,8 +8,11 @@ class DiffViewer {
         this.currentDiff = null;
         this.diffEditor = null;
         this.viewMode = 'monaco'; // 'monaco', 'side-by-side', 'inline', 'unified'
-        this.theme = 'dark';
-        this.wordWrap = false;
+        // Start from the user's settings; the dialog's own controls change them for this page
+        const editor = window.AppState ? window.AppState.getPreference('editor', {}) : {};
+        this.theme = window.AppState ? window.AppState.getPreference('theme', 'dark') : 'dark';
+        this.wordWrap = !!editor.word_wrap;
+        this.fontSize = editor.font_size || 14;
         this.latestDiffs = new Map(); // path -> diffId mapping
         this.init();
     }
@@ -40,6 +43,7 @@ class DiffViewer {
         // Word wrap checkbox
         const wordWrapCheckbox = document.getElementById('word-wrap');
         if (wordWrapCheckbox) {
+            wordWrapCheckbox.checked = this.wordWrap;
             wordWrapCheckbox.addEventListener('change', (e) => {
                 this.wordWrap = e.target.checked;
                 if (this.diffEditor) {
@@ -51,6 +55,7 @@ class DiffViewer {
         // Theme selector
         const themeSelector = document.getElementById('diff-theme');
         if (themeSelector) {
+            themeSelector.value = this.theme;
             themeSelector.addEventListener('change', (e) => {
                 this.theme = e.target.value;
                 if (this.diffEditor && this.viewMode === 'monaco') {
@@ -128,6 +133,14 @@ class DiffViewer {
         }
     }
 
+    // Show a diff that has already been fetched, e.g. a checkpoint comparison
+    async showDiffData(diffData) {
+        this.currentDiff = diffData;
+        this.modal.classList.add('active');
+        this.updateModalHeader();
+        await this.renderDiff();
+    }
+
     updateModalHeader() {
         if (!this.currentDiff) return;
 
@@ -210,7 +223,7 @@ class DiffViewer {
             scrollBeyondLastLine: false,
             theme: this.theme === 'dark' ? 'vs-dark' : 'vs',
             wordWrap: this.wordWrap ? 'on' : 'off',
-            fontSize: 14,
+            fontSize: this.fontSize,
             renderWhitespace: 'selection',
             scrollbar: {
                 vertical: 'visible',
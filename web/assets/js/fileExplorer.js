@@ -19,13 +19,26 @@ const FileExplorer = (function() {
     let fileTree = [];
     let selectedPath = null;
     let openFolders = new Set();
-    let openFiles = new Map(); // path -> {name, content, language}
+    let openFiles = new Map(); // path -> {name, content, savedContent, hash, language}
     let activeFile = null;
     let fileViewerEditor = null;
+    let editorPath = null; // File whose content is in the editor
     let modifiedFiles = new Set(); // Track files that have been modified
     let newFiles = new Set(); // Track files that have been newly created
     let currentDirectory = '/'; // Track the current directory being viewed
 
+    // editorPreferenceOptions returns Monaco options from the user's editor settings
+    function editorPreferenceOptions() {
+        const editor = window.AppState.getPreference('editor', {});
+        return {
+            theme: window.AppState.getPreference('theme', 'dark') === 'light' ? 'vs' : 'vs-dark',
+            fontSize: editor.font_size || 14,
+            tabSize: editor.tab_size || 4,
+            wordWrap: editor.word_wrap === false ? 'off' : 'on',
+            minimap: { enabled: !!editor.minimap }
+        };
+    }
+
     // Initialize the file explorer
     async function init() {
         // Set up tab switching
@@ -38,6 +51,10 @@ const FileExplorer = (function() {
         if (searchInput) {
             searchInput.addEventListener('input', debounce(handleSearch, 300));
         }
+        const searchContentToggle = document.getElementById('file-search-content');
+        if (searchContentToggle) {
+            searchContentToggle.addEventListener('change', handleSearch);
+        }
 
         // Load initial file tree
         await loadFileTree();
@@ -48,6 +65,10 @@ const FileExplorer = (function() {
             treeContainer.addEventListener('click', handleTreeClick);
             treeContainer.addEventListener('dblclick', handleTreeDoubleClick);
             treeContainer.addEventListener('contextmenu', handleTreeContextMenu);
+            treeContainer.addEventListener('dragstart', handleTreeDragStart);
+            treeContainer.addEventListener('dragover', handleTreeDragOver);
+            treeContainer.addEventListener('dragleave', handleTreeDragLeave);
+            treeContainer.addEventListener('drop', handleTreeDrop);
         }
 
         // Create context menu
@@ -208,6 +229,7 @@ const FileExplorer = (function() {
             
             let html = `
                 <div class="tree-node ${isSelected ? 'selected' : ''} ${fileStatus}" 
+                     draggable="true"
                      data-path="${node.path}" 
                      data-is-dir="${node.isDir}"
                      style="padding-left: ${indent}px">
@@ -229,6 +251,12 @@ const FileExplorer = (function() {
 
     // Handle tree node clicks
     async function handleTreeClick(event) {
+        const searchLine = event.target.closest('.search-line');
+        if (searchLine) {
+            await openSearchLine(searchLine.dataset.path, Number(searchLine.dataset.line));
+            return;
+        }
+
         const node = event.target.closest('.tree-node');
         if (!node) return;
 
@@ -245,6 +273,79 @@ const FileExplorer = (function() {
         selectNode(path);
     }
 
+    // Drag and drop in the tree moves items; holding Ctrl/Alt (Option on macOS) copies them.
+    // Dropping on a file targets the file's directory.
+    function handleTreeDragStart(event) {
+        const node = event.target.closest('.tree-node');
+        if (!node) return;
+        event.dataTransfer.setData('application/x-rcode-path', node.dataset.path);
+        event.dataTransfer.effectAllowed = 'copyMove';
+    }
+
+    function dropTargetDir(node) {
+        if (!node) return '';
+        const path = node.dataset.path;
+        if (node.dataset.isDir === 'true') return path;
+        return path.includes('/') ? path.substring(0, path.lastIndexOf('/')) : '';
+    }
+
+    function handleTreeDragOver(event) {
+        const types = event.dataTransfer.types;
+        const isUpload = types.includes('Files');
+        if (!types.includes('application/x-rcode-path') && !isUpload) return;
+        event.preventDefault();
+        event.dataTransfer.dropEffect = (isUpload || event.ctrlKey || event.altKey) ? 'copy' : 'move';
+
+        document.querySelectorAll('.tree-node.drop-target').forEach(n => n.classList.remove('drop-target'));
+        const node = event.target.closest('.tree-node');
+        if (node && node.dataset.isDir === 'true') {
+            node.classList.add('drop-target');
+        }
+    }
+
+    function handleTreeDragLeave(event) {
+        const node = event.target.closest('.tree-node');
+        if (node) node.classList.remove('drop-target');
+    }
+
+    async function handleTreeDrop(event) {
+        const source = event.dataTransfer.getData('application/x-rcode-path');
+        const droppedFiles = Array.from(event.dataTransfer.files || []);
+        if (!source && droppedFiles.length === 0) return;
+        event.preventDefault();
+        document.querySelectorAll('.tree-node.drop-target').forEach(n => n.classList.remove('drop-target'));
+
+        const targetDir = dropTargetDir(event.target.closest('.tree-node'));
+
+        // Files dragged in from the desktop are uploaded
+        if (!source) {
+            await handleUpload(targetDir, droppedFiles);
+            return;
+        }
+        const sourceDir = source.includes('/') ? source.substring(0, source.lastIndexOf('/')) : '';
+        const copy = event.ctrlKey || event.altKey;
+        if (!copy && (targetDir === sourceDir || targetDir === source || targetDir.startsWith(source + '/'))) {
+            return;
+        }
+
+        try {
+            const destination = targetDir || '.';
+            const result = copy
+                ? await window.FileOperations.copyFile(source, destination)
+                : await window.FileOperations.moveFile(source, destination);
+
+            if (!copy && openFiles.has(source)) {
+                renameOpenFile(source, result.newPath);
+            }
+            await refreshPath(sourceDir);
+            await refreshPath(targetDir);
+        } catch (error) {
+            if (error.message !== 'Cancelled') {
+                showError(`Failed to ${copy ? 'copy' : 'move'} ${source}: ${error.message}`);
+            }
+        }
+    }
+
     // Handle double-click to open files or toggle folders
     async function handleTreeDoubleClick(event) {
         const node = event.target.closest('.tree-node');
@@ -320,6 +421,12 @@ const FileExplorer = (function() {
 
     // Open a file
     async function openFile(path) {
+        // Already open files keep their unsaved edits
+        if (openFiles.has(path)) {
+            switchToFile(path);
+            return;
+        }
+
         try {
             // Use encodeURI instead of encodeURIComponent to preserve slashes
             const response = await fetch(`/api/files/content/${encodeURI(path)}`);
@@ -327,7 +434,7 @@ const FileExplorer = (function() {
             
             const data = await response.json();
             
-            if (data.isBinary) {
+            if (data.isBinary && !data.isImage) {
                 showError('Cannot display binary files');
                 return;
             }
@@ -346,7 +453,11 @@ const FileExplorer = (function() {
             openFiles.set(path, {
                 name: data.name,
                 content: data.content,
-                language: getLanguageFromFilename(data.name)
+                savedContent: data.content,
+                hash: data.hash,
+                language: getLanguageFromFilename(data.name),
+                isImage: !!data.isImage,
+                imageUrl: data.imageUrl
             });
 
             activeFile = path;
@@ -375,17 +486,39 @@ const FileExplorer = (function() {
                 <div class="file-tabs"></div>
                 <div class="file-content">
                     <div id="file-viewer-editor"></div>
+                    <div id="file-viewer-image" class="file-image-preview"></div>
                 </div>
             `;
             chatArea.insertBefore(viewer, chatArea.firstChild);
         }
 
+        // Keep unsaved edits of the file being switched away from
+        stashEditorContent();
+
         // Update tabs
         updateFileTabs();
         
         // Show viewer
         viewer.classList.add('active');
 
+        // Images are shown in place of the editor
+        const editorEl = document.getElementById('file-viewer-editor');
+        const imageEl = document.getElementById('file-viewer-image');
+        if (file.isImage) {
+            editorEl.style.display = 'none';
+            imageEl.style.display = 'flex';
+            imageEl.innerHTML = '';
+            const img = document.createElement('img');
+            img.src = `${file.imageUrl}?thumb=1024`;
+            img.alt = file.name;
+            img.title = 'Open full size';
+            img.addEventListener('click', () => window.open(file.imageUrl, '_blank'));
+            imageEl.appendChild(img);
+            return;
+        }
+        editorEl.style.display = '';
+        imageEl.style.display = 'none';
+
         // Initialize or update Monaco editor
         if (!fileViewerEditor) {
             // Wait for Monaco to be available
@@ -394,17 +527,35 @@ const FileExplorer = (function() {
                 return;
             }
 
-            fileViewerEditor = monaco.editor.create(document.getElementById('file-viewer-editor'), {
+            fileViewerEditor = monaco.editor.create(document.getElementById('file-viewer-editor'), Object.assign({
                 value: file.content,
                 language: file.language,
-                theme: 'vs-dark',
-                readOnly: true,
-                minimap: { enabled: false },
+                readOnly: false,
                 scrollBeyondLastLine: false,
-                fontSize: 14,
                 lineNumbers: 'on',
-                renderWhitespace: 'selection',
-                wordWrap: 'on'
+                renderWhitespace: 'selection'
+            }, editorPreferenceOptions()));
+
+            // Follow changes to the editor settings
+            window.StateEvents.on('preferencesChange', () => {
+                const options = editorPreferenceOptions();
+                monaco.editor.setTheme(options.theme);
+                fileViewerEditor.updateOptions(options);
+                fileViewerEditor.getModel().updateOptions({ tabSize: options.tabSize });
+            });
+
+            // Save with Ctrl/Cmd+S
+            fileViewerEditor.addCommand(monaco.KeyMod.CtrlCmd | monaco.KeyCode.KeyS, () => saveActiveFile());
+
+            // Mark the tab when the content differs from what is saved
+            fileViewerEditor.onDidChangeModelContent(() => {
+                const current = openFiles.get(editorPath);
+                if (!current) return;
+                const wasDirty = current.content !== current.savedContent;
+                current.content = fileViewerEditor.getValue();
+                if (wasDirty !== (current.content !== current.savedContent)) {
+                    updateFileTabs();
+                }
             });
 
             // Handle editor resize
@@ -415,9 +566,11 @@ const FileExplorer = (function() {
             });
         } else {
             // Update existing editor
+            editorPath = null; // Don't record the swap as an edit
             fileViewerEditor.setValue(file.content);
             monaco.editor.setModelLanguage(fileViewerEditor.getModel(), file.language);
         }
+        editorPath = path;
 
         // Layout editor
         setTimeout(() => {
@@ -427,6 +580,78 @@ const FileExplorer = (function() {
         }, 0);
     }
 
+    // Copy the editor's content back to the file it shows
+    function stashEditorContent() {
+        if (fileViewerEditor && editorPath && openFiles.has(editorPath)) {
+            openFiles.get(editorPath).content = fileViewerEditor.getValue();
+        }
+    }
+
+    // Save the active file. The server refuses the save if the file changed on disk
+    // since it was loaded; the user can then overwrite it or reload it.
+    async function saveActiveFile(baseHash) {
+        const path = activeFile;
+        const file = openFiles.get(path);
+        if (!file || file.isImage) return;
+        stashEditorContent();
+
+        const content = file.content;
+        try {
+            const response = await fetch(`/api/files/content/${encodeURI(path)}`, {
+                method: 'PUT',
+                headers: {
+                    'Content-Type': 'application/json',
+                    'If-Match': `"${baseHash || file.hash}"`
+                },
+                body: JSON.stringify({ content, sessionId: window.currentSessionId || '' })
+            });
+
+            if (response.status === 409) {
+                const conflict = await response.json();
+                if (confirm(`${file.name} changed on disk since you opened it. Overwrite it with your version?\n\nCancel reloads the file from disk.`)) {
+                    return saveActiveFile(conflict.current.hash);
+                }
+                return reloadFile(path);
+            }
+            if (!response.ok) throw new Error(await response.text());
+
+            const data = await response.json();
+            file.savedContent = content;
+            file.hash = data.hash;
+            updateFileTabs();
+        } catch (error) {
+            console.error('Error saving file:', error);
+            showError(`Failed to save ${file.name}`);
+        }
+    }
+
+    // Replace an open file's content with what is on disk
+    async function reloadFile(path) {
+        const file = openFiles.get(path);
+        if (!file) return;
+        try {
+            const response = await fetch(`/api/files/content/${encodeURI(path)}`);
+            if (!response.ok) throw new Error('Failed to load file');
+            const data = await response.json();
+
+            if (data.hash === file.hash) return;
+            if (path === editorPath) {
+                editorPath = null; // The editor's content is replaced, not stashed
+            }
+            file.content = data.content;
+            file.savedContent = data.content;
+            file.hash = data.hash;
+            if (path === activeFile) {
+                showFileViewer(path);
+            } else {
+                updateFileTabs();
+            }
+        } catch (error) {
+            console.error('Error reloading file:', error);
+            showError(`Failed to reload ${file.name}`);
+        }
+    }
+
     // Update file tabs
     function updateFileTabs() {
         const tabsContainer = document.querySelector('.file-tabs');
@@ -434,9 +659,10 @@ const FileExplorer = (function() {
 
         const tabsHtml = Array.from(openFiles.entries()).map(([path, file]) => {
             const isActive = path === activeFile;
+            const isDirty = !file.isImage && file.content !== file.savedContent;
             return `
                 <div class="file-tab ${isActive ? 'active' : ''}" data-path="${path}">
-                    <span class="tab-name">${file.name}</span>
+                    <span class="tab-name">${file.name}${isDirty ? ' ●' : ''}</span>
                     <span class="tab-close" data-action="close-file">×</span>
                 </div>
             `;
@@ -464,6 +690,15 @@ const FileExplorer = (function() {
 
     // Close a file
     async function closeFile(path) {
+        stashEditorContent();
+        const file = openFiles.get(path);
+        if (file && !file.isImage && file.content !== file.savedContent &&
+            !confirm(`${file.name} has unsaved changes. Close it anyway?`)) {
+            return;
+        }
+        if (path === editorPath) {
+            editorPath = null;
+        }
         openFiles.delete(path);
         
         // Notify server that file was closed
@@ -496,41 +731,144 @@ const FileExplorer = (function() {
         }
     }
 
-    // Handle file search
-    async function handleSearch(event) {
-        const query = event.target.value.trim();
-        
+    // Handle file search. Name searches are fetched a page at a time; content searches
+    // stream their results as they are found.
+    let searchStream = null; // EventSource of the running content search
+    let searchGeneration = 0; // Bumped by each search so late pages of an old one are dropped
+
+    function handleSearch() {
+        const query = document.getElementById('file-search-input').value.trim();
+        const searchContent = document.getElementById('file-search-content')?.checked;
+
+        searchGeneration++;
+        if (searchStream) {
+            searchStream.close();
+            searchStream = null;
+        }
         if (!query) {
             renderFileTree();
             return;
         }
 
+        const container = document.getElementById('file-tree-container');
+        container.innerHTML = '<div class="file-tree search-results"></div><div class="search-status">Searching...</div>';
+
+        if (searchContent) {
+            streamSearch(query, 0);
+        } else {
+            fetchSearchPage(query, 0);
+        }
+    }
+
+    async function fetchSearchPage(query, offset) {
+        const generation = searchGeneration;
         try {
             const response = await fetch('/api/files/search', {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
-                body: JSON.stringify({ query, searchContent: false })
+                body: JSON.stringify({ query, searchContent: false, offset })
             });
 
             if (!response.ok) throw new Error('Search failed');
-            
+
             const data = await response.json();
-            
-            // Update tree with search results
-            const container = document.getElementById('file-tree-container');
-            if (data.results.length === 0) {
-                container.innerHTML = '<div class="empty-state">No files found</div>';
-            } else {
-                const treeHtml = renderTreeNodes(data.results, 0);
-                container.innerHTML = `<div class="file-tree">${treeHtml}</div>`;
-            }
-            
+            if (generation !== searchGeneration) return;
+            data.results.forEach(appendSearchResult);
+            finishSearch(offset + data.count, data.hasMore, () => fetchSearchPage(query, offset + data.count));
         } catch (error) {
             console.error('Search error:', error);
             showError('Search failed');
         }
     }
 
+    function streamSearch(query, offset) {
+        const params = new URLSearchParams({ query, content: 'true', context: '1', offset: String(offset), limit: '200' });
+        const stream = new EventSource(`/api/files/search/stream?${params}`);
+        searchStream = stream;
+
+        stream.addEventListener('result', (event) => {
+            appendSearchResult(JSON.parse(event.data));
+        });
+        stream.addEventListener('done', (event) => {
+            const data = JSON.parse(event.data);
+            stream.close();
+            searchStream = null;
+            finishSearch(offset + data.count, data.hasMore, () => streamSearch(query, offset + data.count));
+        });
+        stream.addEventListener('error', (event) => {
+            stream.close();
+            if (searchStream !== stream) return;
+            searchStream = null;
+            const message = event.data ? JSON.parse(event.data).error : 'connection lost';
+            const status = document.querySelector('#file-tree-container .search-status');
+            if (status) status.textContent = `Search failed: ${message}`;
+        });
+    }
+
+    // Add a result, with any matching lines, to the search results
+    function appendSearchResult(result) {
+        const list = document.querySelector('#file-tree-container .search-results');
+        if (!list) return;
+
+        let html = renderTreeNodes([result], 0);
+        if (result.lines && result.lines.length > 0) {
+            const lines = result.lines.map(line => `
+                <div class="search-line ${line.match ? 'match' : ''}" data-path="${result.path}" data-line="${line.number}">
+                    <span class="search-line-number">${line.number}</span>
+                    <span class="search-line-text">${highlightRanges(line.text, line.ranges)}</span>
+                </div>
+            `).join('');
+            html += `<div class="search-result-lines">${lines}</div>`;
+        }
+        list.insertAdjacentHTML('beforeend', html);
+    }
+
+    // Wrap the matched ranges of text, given in characters, in <mark>
+    function highlightRanges(text, ranges) {
+        const chars = Array.from(text);
+        if (!ranges || ranges.length === 0) return window.escapeHtml(text);
+
+        let html = '';
+        let at = 0;
+        ranges.forEach(([start, end]) => {
+            if (start < at) return;
+            html += window.escapeHtml(chars.slice(at, start).join(''));
+            html += `<mark>${window.escapeHtml(chars.slice(start, end).join(''))}</mark>`;
+            at = end;
+        });
+        return html + window.escapeHtml(chars.slice(at).join(''));
+    }
+
+    // Show the result count, with a button for the next page when there is one
+    function finishSearch(total, hasMore, loadMore) {
+        const container = document.getElementById('file-tree-container');
+        const status = container.querySelector('.search-status');
+        if (!status) return;
+
+        status.textContent = total === 0 ? 'No files found' : `${total} result${total === 1 ? '' : 's'}`;
+        if (hasMore) {
+            const button = document.createElement('button');
+            button.className = 'search-more';
+            button.textContent = 'Load more';
+            button.addEventListener('click', () => {
+                button.remove();
+                status.textContent = 'Searching...';
+                loadMore();
+            });
+            status.appendChild(button);
+        }
+    }
+
+    // Open a file from a search result at the given line
+    async function openSearchLine(path, line) {
+        await openFile(path);
+        if (fileViewerEditor && activeFile === path) {
+            fileViewerEditor.revealLineInCenter(line);
+            fileViewerEditor.setPosition({ lineNumber: line, column: 1 });
+            fileViewerEditor.focus();
+        }
+    }
+
     // Get language for Monaco from filename
     function getLanguageFromFilename(filename) {
         const ext = filename.split('.').pop().toLowerCase();
@@ -651,11 +989,16 @@ const FileExplorer = (function() {
             unmarkFileNew(path);
         }
         
-        // If the changed file is currently open, show a notification
-        if (openFiles.has(path) && activeFile === path) {
-            // Show notification that file was modified externally
-            console.log(`Open file ${path} was ${changeType} externally`);
-            // Could show a banner or dialog asking if user wants to reload
+        // Reload open files changed elsewhere, unless they have unsaved edits;
+        // saving those is refused until the user chooses which version to keep
+        const changed = openFiles.get(path);
+        if (changed && changeType === 'modified' && !changed.isImage) {
+            stashEditorContent();
+            if (changed.content === changed.savedContent) {
+                reloadFile(path);
+            } else {
+                console.log(`Open file ${path} was ${changeType} externally`);
+            }
         }
         
         // Refresh the parent directory in the tree
@@ -803,6 +1146,12 @@ const FileExplorer = (function() {
                 });
             }
 
+            menuItems.push({
+                label: 'Download',
+                icon: '⬇️',
+                action: () => window.FileOperations.downloadFile(path)
+            });
+
             menuItems.push({ separator: true });
 
             menuItems.push({
@@ -811,6 +1160,12 @@ const FileExplorer = (function() {
                 action: () => handleRename(path, nodeName)
             });
 
+            menuItems.push({
+                label: 'Duplicate',
+                icon: '⧉',
+                action: () => handleDuplicate(path)
+            });
+
             menuItems.push({
                 label: 'Delete',
                 icon: '🗑️',
@@ -847,6 +1202,18 @@ const FileExplorer = (function() {
                 action: () => handleCreateNew(path, 'directory')
             });
 
+            menuItems.push({
+                label: 'Upload Files',
+                icon: '⬆️',
+                action: () => handleUpload(path)
+            });
+
+            menuItems.push({
+                label: 'Download as Zip',
+                icon: '⬇️',
+                action: () => window.FileOperations.downloadFile(path)
+            });
+
             menuItems.push({ separator: true });
 
             menuItems.push({
@@ -855,6 +1222,12 @@ const FileExplorer = (function() {
                 action: () => handleRename(path, nodeName)
             });
 
+            menuItems.push({
+                label: 'Duplicate',
+                icon: '⧉',
+                action: () => handleDuplicate(path)
+            });
+
             menuItems.push({
                 label: 'Delete',
                 icon: '🗑️',
@@ -999,19 +1372,7 @@ const FileExplorer = (function() {
             
             // If the renamed file was open, update it
             if (openFiles.has(path)) {
-                const fileData = openFiles.get(path);
-                openFiles.delete(path);
-                openFiles.set(result.newPath, {
-                    ...fileData,
-                    name: result.newPath.split('/').pop()
-                });
-                
-                // If it was the active file, update that too
-                if (activeFile === path) {
-                    activeFile = result.newPath;
-                }
-                
-                updateFileTabs();
+                renameOpenFile(path, result.newPath);
             }
         } catch (error) {
             if (error.message !== 'Cancelled' && error.message !== 'No change') {
@@ -1020,6 +1381,63 @@ const FileExplorer = (function() {
         }
     }
 
+    // Keep an open file's tab after it is renamed or moved
+    function renameOpenFile(oldPath, newPath) {
+        const fileData = openFiles.get(oldPath);
+        openFiles.delete(oldPath);
+        openFiles.set(newPath, {
+            ...fileData,
+            name: newPath.split('/').pop()
+        });
+
+        // If it was the active file, update that too
+        if (activeFile === oldPath) {
+            activeFile = newPath;
+        }
+        if (editorPath === oldPath) {
+            editorPath = newPath;
+        }
+
+        updateFileTabs();
+    }
+
+    // Upload files into a directory, picking them first if none are given
+    async function handleUpload(dir, files) {
+        if (!files) {
+            const input = document.createElement('input');
+            input.type = 'file';
+            input.multiple = true;
+            files = await new Promise(resolve => {
+                input.addEventListener('change', () => resolve(Array.from(input.files)));
+                input.click();
+            });
+        }
+        if (!files || files.length === 0) return;
+
+        try {
+            await window.FileOperations.uploadFiles(dir, files);
+            await refreshPath(dir);
+        } catch (error) {
+            if (error.message !== 'Cancelled') {
+                showError(`Failed to upload: ${error.message}`);
+            }
+        }
+    }
+
+    // Duplicate a file or directory next to itself
+    async function handleDuplicate(path) {
+        try {
+            const result = await window.FileOperations.copyFile(path);
+            const parentPath = path.substring(0, path.lastIndexOf('/')) || '';
+            await refreshPath(parentPath);
+            selectNode(result.newPath);
+        } catch (error) {
+            if (error.message !== 'Cancelled') {
+                showError(`Failed to duplicate ${path}: ${error.message}`);
+            }
+        }
+    }
+
     // Handle delete
     async function handleDelete(path, name, isDir) {
         if (!window.FileOperations) {
@@ -1051,8 +1469,10 @@ const FileExplorer = (function() {
         init,
         loadFileTree,
         openFile,
+        openFileAtLine: openSearchLine,
         getOpenFiles: () => openFiles,
         getActiveFile: () => activeFile,
+        saveActiveFile,
         refreshTree: () => renderFileTree(),
         handleFileEvent,
         refreshPath,
@@ -42,6 +42,85 @@ const FileOperations = (function() {
         }
     }
     
+    // Copy or move a file or directory. The server answers 409 when the destination
+    // exists; the user is then asked whether to overwrite it.
+    async function transferFile(endpoint, source, destination, overwrite = false) {
+        const response = await fetch(endpoint, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ source, destination, overwrite })
+        });
+
+        if (response.status === 409 && !overwrite) {
+            const target = destination || source;
+            if (!confirm(`"${target}" already has an item named "${source.split('/').pop()}". Replace it?`)) {
+                throw new Error('Cancelled');
+            }
+            return transferFile(endpoint, source, destination, true);
+        }
+        if (!response.ok) {
+            const error = await response.json();
+            throw new Error(error.error || 'Failed to transfer file');
+        }
+
+        return await response.json();
+    }
+
+    async function copyFile(source, destination = '') {
+        try {
+            return await transferFile('/api/files/duplicate', source, destination);
+        } catch (error) {
+            console.error('Copy error:', error);
+            throw error;
+        }
+    }
+
+    async function moveFile(source, destination) {
+        try {
+            return await transferFile('/api/files/move', source, destination);
+        } catch (error) {
+            console.error('Move error:', error);
+            throw error;
+        }
+    }
+    
+    // Upload files into a project directory. Each entry is a File, optionally with
+    // a relative path (for folder uploads) as {file, name}.
+    async function uploadFiles(dir, files, overwrite = false) {
+        const form = new FormData();
+        for (const entry of files) {
+            const file = entry.file || entry;
+            form.append('files', file, entry.name || file.webkitRelativePath || file.name);
+        }
+
+        const params = new URLSearchParams({ path: dir || '.' });
+        if (overwrite) params.set('overwrite', 'true');
+        const response = await fetch(`/api/files/upload?${params}`, { method: 'POST', body: form });
+
+        if (response.status === 409 && !overwrite) {
+            if (!confirm('Some of these files already exist. Replace them?')) {
+                throw new Error('Cancelled');
+            }
+            return uploadFiles(dir, files, true);
+        }
+        if (!response.ok) {
+            const error = await response.json();
+            throw new Error(error.error || 'Failed to upload');
+        }
+
+        return await response.json();
+    }
+
+    // Download a file, or a directory as a zip archive
+    function downloadFile(path) {
+        const link = document.createElement('a');
+        link.href = `/api/files/download/${encodeURI(path)}`;
+        link.download = '';
+        document.body.appendChild(link);
+        link.click();
+        link.remove();
+    }
+    
     async function deleteFile(path) {
         try {
             const response = await fetch('/api/files/delete', {
@@ -332,6 +411,10 @@ const FileOperations = (function() {
     return {
         createFile,
         renameFile,
+        copyFile,
+        moveFile,
+        uploadFiles,
+        downloadFile,
         deleteFile,
         showCreateDialog,
         showRenameDialog,
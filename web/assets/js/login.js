@@ -1,4 +1,6 @@
-window.handleLogin = async function() {
+// handleLogin logs in to Claude, as the named account when given one; the default
+// account otherwise
+window.handleLogin = async function(account) {
   console.log('handleLogin called');
   try {
     const response = await fetch('/auth/anthropic/oauth-url');
@@ -9,7 +11,7 @@ window.handleLogin = async function() {
       // Open OAuth URL in new tab
       window.open(data.url, '_blank');
       // Redirect to callback page
-      window.location.href = '/auth/callback';
+      window.location.href = '/auth/callback' + (account ? '?account=' + encodeURIComponent(account) : '');
     } else {
       alert('Failed to get authorization URL');
     }
@@ -18,3 +20,14 @@ window.handleLogin = async function() {
     alert('Failed to initiate login');
   }
 }
+
+// Sign out of rcode in multi-user mode. Loaded with the login view too, for users
+// waiting for an admin to connect Claude.
+window.signOut = async function() {
+  try {
+    await fetch('/api/logout', { method: 'POST' });
+  } catch (error) {
+    console.error('Sign out failed:', error);
+  }
+  window.location.href = '/login';
+}
@@ -388,10 +388,12 @@ class FileMentionSystem {
       endPosition.column
     );
     
-    // Insert the file path wrapped in backticks for clarity
+    // Insert an @ mention so the server attaches the file's contents;
+    // paths with spaces are quoted. Append :line or :start-end to send only those lines.
+    const mention = /\s/.test(filePath) ? `@"${filePath}"` : `@${filePath}`;
     const edit = {
       range: range,
-      text: `\`${filePath}\` `,
+      text: `${mention} `,
       forceMoveMarkers: true
     };
     
@@ -46,13 +46,35 @@
     }
     
     try {
-      return marked.parse(content);
+      return resolveProjectImages(marked.parse(content));
     } catch (error) {
       console.error('Error processing markdown:', error);
       return escapeHtml(content);
     }
   }
 
+  /**
+   * Point images with project-relative paths at the project image endpoint
+   * so assets referenced in messages display inline
+   * @param {string} html - Rendered HTML
+   * @returns {string} HTML with image sources resolved
+   */
+  function resolveProjectImages(html) {
+    if (!html.includes('<img')) return html;
+
+    const template = document.createElement('template');
+    template.innerHTML = html;
+    template.content.querySelectorAll('img').forEach((img) => {
+      const src = img.getAttribute('src');
+      if (!src || /^([a-z][a-z0-9+.-]*:|\/\/|\/|#)/i.test(src)) return;
+
+      const path = src.replace(/^\.\//, '');
+      img.setAttribute('src', `/api/files/image/${encodeURI(path)}?thumb=800`);
+      img.classList.add('project-image');
+    });
+    return template.innerHTML;
+  }
+
   /**
    * Escape HTML for safe display
    * @param {string} text - Text to escape
@@ -313,12 +313,32 @@
       }
 
       console.log('Making API request with model:', selectedModel);
-      const response = await fetch('/api/session/' + sessionId + '/message', {
+      const postMessage = () => fetch('/api/session/' + sessionId + '/message', {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify(requestBody),
         signal: currentRequestController.signal
       });
+      let response = await postMessage();
+
+      // Messages estimated to cost more than RCODE_CONFIRM_COST are sent once confirmed
+      if (response.status === 409) {
+        const body = await response.clone().json().catch(() => ({}));
+        if (body.estimate) {
+          const estimate = body.estimate;
+          const tokens = window.formatTokenCount ? window.formatTokenCount(estimate.inputTokens) : estimate.inputTokens;
+          if (!confirm(`This message is estimated to send ${tokens} input tokens, costing about $${estimate.cost.toFixed(2)} before the reply. Send it?`)) {
+            removeThinkingIndicator(thinkingId);
+            window.editor.setValue(content);
+            if (window.addSystemMessageToUI) {
+              window.addSystemMessageToUI('Message not sent', 'info');
+            }
+            return;
+          }
+          requestBody.confirm = true;
+          response = await postMessage();
+        }
+      }
 
       console.log('Response status:', response.status);
 
@@ -379,7 +399,16 @@
       
       // Remove thinking indicator
       removeThinkingIndicator(thinkingId);
-      
+
+      // Slash commands are answered by the server directly
+      if (result.command) {
+        addMessageToUI({ role: 'assistant', content: result.content });
+        if (result.model && modelSelector) {
+          modelSelector.value = result.model;
+        }
+        return;
+      }
+
       // Display tool summaries if any
       displayToolSummaries(result.toolSummaries);
 
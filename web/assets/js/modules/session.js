@@ -257,6 +257,91 @@
     }
   }
 
+  let sessionSearchTimer = null;
+
+  /**
+   * Wire up the conversation search box in the sessions sidebar
+   */
+  function setupSessionSearch() {
+    const input = document.getElementById('session-search-input');
+    if (!input) {
+      return;
+    }
+
+    input.addEventListener('input', () => {
+      clearTimeout(sessionSearchTimer);
+      sessionSearchTimer = setTimeout(() => searchSessions(input.value), 300);
+    });
+
+    input.addEventListener('keydown', (e) => {
+      if (e.key === 'Escape') {
+        input.value = '';
+        searchSessions('');
+      }
+    });
+  }
+
+  /**
+   * Search past conversations and render ranked results with highlighted snippets
+   * @param {string} query - Text to search for; empty restores the session list
+   */
+  async function searchSessions(query) {
+    const resultsContainer = document.getElementById('session-search-results');
+    const sessionsList = document.getElementById('sessions-list');
+    if (!resultsContainer || !sessionsList) {
+      return;
+    }
+
+    query = query.trim();
+    if (!query) {
+      resultsContainer.style.display = 'none';
+      resultsContainer.innerHTML = '';
+      sessionsList.style.display = '';
+      return;
+    }
+
+    try {
+      const response = await fetch('/api/search?q=' + encodeURIComponent(query));
+      if (!response.ok) {
+        console.error('Failed to search sessions:', response.status);
+        return;
+      }
+      const data = await response.json();
+
+      resultsContainer.innerHTML = '';
+      sessionsList.style.display = 'none';
+      resultsContainer.style.display = '';
+
+      if (!data.results || data.results.length === 0) {
+        resultsContainer.innerHTML = '<div class="empty-state">No matching conversations</div>';
+        return;
+      }
+
+      data.results.forEach(result => {
+        const item = document.createElement('div');
+        item.className = 'session-item session-search-result';
+        item.onclick = () => (window.selectSession || switchToSession)(result.session.id);
+
+        const title = document.createElement('div');
+        title.className = 'session-title';
+        title.textContent = result.session.title || 'New Session';
+        item.appendChild(title);
+
+        // Snippets are HTML-escaped server side with matches wrapped in <mark>
+        (result.hits || []).slice(0, 2).forEach(hit => {
+          const snippet = document.createElement('div');
+          snippet.className = 'search-snippet';
+          snippet.innerHTML = '<span class="search-hit-source">' + hit.source + '</span> ' + hit.highlighted;
+          item.appendChild(snippet);
+        });
+
+        resultsContainer.appendChild(item);
+      });
+    } catch (error) {
+      console.error('Failed to search sessions:', error);
+    }
+  }
+
   /**
    * Get the current session ID
    */
@@ -281,7 +366,9 @@
     actuallyCreateSession,
     createNewSession,
     getCurrentSessionId,
-    isPendingNewSession
+    isPendingNewSession,
+    setupSessionSearch,
+    searchSessions
   };
 
   // Also expose individual functions for backward compatibility
@@ -290,5 +377,6 @@
   window.deleteSession = deleteSession;
   window.actuallyCreateSession = actuallyCreateSession;
   window.createNewSession = createNewSession;
+  window.setupSessionSearch = setupSessionSearch;
 
 })();
\ No newline at end of file
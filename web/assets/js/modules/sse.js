@@ -333,9 +333,40 @@ function handleServerEvent(evtData) {
       case 'plan_complete':
         handlePlanComplete(evtData);
         break;
+      case 'plan_step_approval_needed':
+      case 'plan_step_approved':
+      case 'plan_step_denied':
+      case 'plan_cancelled':
+      case 'plan_completed':
+      case 'plan_failed':
+        if (typeof window.handlePlanEvent === 'function') {
+          window.handlePlanEvent(evtData.data);
+        }
+        break;
       case 'usage_update':
         handleUsageUpdate(evtData);
         break;
+      case 'rate_limit_wait':
+        handleRateLimitWait(evtData);
+        break;
+      case 'model_fallback':
+        handleModelFallback(evtData);
+        break;
+      case 'artifact_published':
+        handleArtifactPublished(evtData);
+        break;
+      case 'message_queued':
+        handleMessageQueued(evtData);
+        break;
+      case 'message_dequeued':
+        handleMessageDequeued(evtData);
+        break;
+      case 'queued_message_finished':
+        handleQueuedMessageFinished(evtData);
+        break;
+      case 'cost_estimate':
+        handleCostEstimate(evtData);
+        break;
       case 'error':
         handleErrorEvent(evtData);
         break;
@@ -528,6 +559,111 @@ function handleUsageUpdate(evtData) {
   if (window.updateUsageDisplay) {
     window.updateUsageDisplay(evtData.data.usage);
   }
+  // The model that served the message, which is not the one asked for after a fallback
+  if (evtData.data.model) {
+    const modelEl = document.getElementById('current-model');
+    if (modelEl) {
+      modelEl.textContent = evtData.data.model;
+    }
+  }
+}
+
+// The session's next model request is held back until the API's rate limits allow it
+function handleRateLimitWait(evtData) {
+  const data = evtData.data || {};
+  addSystemMessageToUI(`Waiting ${data.waitSeconds}s for the API's rate limits (${data.reason})`, 'warning');
+}
+
+// The API couldn't serve the session's model, so the request went to the next in the chain
+function handleModelFallback(evtData) {
+  const data = evtData.data || {};
+  addSystemMessageToUI(`${data.model} is unavailable, falling back to ${data.fallback}`, 'warning');
+}
+
+// A message waits for the session's turn in progress; it can be cancelled until it is sent
+function handleMessageQueued(evtData) {
+  const data = evtData.data || {};
+  const messagesContainer = document.getElementById('messages');
+  if (!messagesContainer) return;
+
+  const queuedDiv = document.createElement('div');
+  queuedDiv.className = 'system-message info queued-message';
+  queuedDiv.id = `queued-${data.queueId}`;
+  const ahead = data.ahead === 1 ? 'the current reply finishes' : `${data.ahead} messages before it are answered`;
+  queuedDiv.innerHTML = `
+    <span class="system-message-icon">⏳</span>
+    <span class="system-message-text">Queued: "${escapeHtml(data.content || '')}" will be sent when ${ahead}</span>
+    <button class="btn-secondary queued-message-cancel">Cancel</button>
+  `;
+  queuedDiv.querySelector('.queued-message-cancel').addEventListener('click', async () => {
+    const response = await fetch(`/api/session/${encodeURIComponent(evtData.sessionId)}/queue/${data.queueId}`, { method: 'DELETE' });
+    if (!response.ok) {
+      addSystemMessageToUI(escapeHtml(await response.text()), 'warning');
+    }
+  });
+
+  messagesContainer.appendChild(queuedDiv);
+  messagesContainer.scrollTop = messagesContainer.scrollHeight;
+}
+
+// A queued message was sent, when the turn before it finished, or cancelled
+function handleMessageDequeued(evtData) {
+  const data = evtData.data || {};
+  const queuedDiv = document.getElementById(`queued-${data.queueId}`);
+  if (queuedDiv) {
+    queuedDiv.remove();
+  }
+
+  if (data.reason === 'started') {
+    window.AppState.setStateMultiple({ isProcessing: true, isLLMProcessing: true });
+    if (window.toggleStopButton) {
+      window.toggleStopButton(true);
+    }
+    if (window.addThinkingIndicator) {
+      window.addThinkingIndicator('thinking-' + Date.now());
+    }
+  } else if (data.reason === 'cancelled') {
+    addSystemMessageToUI('Queued message cancelled', 'info');
+  }
+}
+
+// A queued message's turn finished; no request waits for it, so its end is shown here
+function handleQueuedMessageFinished(evtData) {
+  const data = evtData.data || {};
+  document.querySelectorAll('.message.thinking').forEach(el => el.remove());
+  if (window.toggleStopButton) {
+    window.toggleStopButton(false);
+  }
+  window.AppState.setStateMultiple({
+    isProcessing: false,
+    isLLMProcessing: false,
+    toolsAnnounced: false
+  });
+
+  if (data.error) {
+    addSystemMessageToUI(escapeHtml(data.error), 'error');
+  }
+  if (window.loadSessions) {
+    window.loadSessions();
+  }
+}
+
+// A tool or plan step published an artifact; link it for download
+function handleArtifactPublished(evtData) {
+  const artifact = evtData.data || {};
+  const href = `/api/session/${encodeURIComponent(evtData.sessionId)}/artifacts/${artifact.id}`;
+  addSystemMessageToUI(`📦 Published ${escapeHtml(artifact.kind)} artifact ` +
+    `<a class="tool-artifact-link" href="${href}" target="_blank">${escapeHtml(artifact.name)}</a>`, 'info');
+}
+
+// What the session's request was estimated to cost, before it was sent
+function handleCostEstimate(evtData) {
+  const data = evtData.data || {};
+  const estimateEl = document.getElementById('session-estimate');
+  if (estimateEl) {
+    const tokens = window.formatTokenCount ? window.formatTokenCount(data.inputTokens) : data.inputTokens;
+    estimateEl.textContent = `~${tokens} in, $${(data.cost || 0).toFixed(4)}`;
+  }
 }
 
 function handleErrorEvent(evtData) {
@@ -3,6 +3,9 @@
  * Manages all global state variables and provides controlled access
  */
 
+// localStorage key for the last copy of the user's settings
+const PREFERENCES_CACHE_KEY = 'rcodePreferences';
+
 // Initialize the state object with all global variables
 function createState() {
   return {
@@ -46,6 +49,9 @@ function createState() {
     activeToolExecutions: new Map(),
     activePermissionRequests: new Map(),
     
+    // User settings from /api/settings (default model, theme, editor options, ...)
+    preferences: loadCachedPreferences(),
+    
     // Reconnection state
     reconnectAttempts: 0,
     reconnectDelay: 1000,
@@ -163,6 +169,78 @@ function setConnectionStatus(status) {
   return true;
 }
 
+// User settings are stored by the server so they follow the user across browsers.
+// The last copy is cached in localStorage, so the page starts with them before the
+// server answers, and keeps them if it doesn't.
+function loadCachedPreferences() {
+  try {
+    const cached = JSON.parse(localStorage.getItem(PREFERENCES_CACHE_KEY));
+    if (cached) {
+      return cached;
+    }
+  } catch (e) {
+    // Fall through to the older per-setting keys
+  }
+  // Settings saved only in this browser before they were stored on the server
+  const legacy = {};
+  if (localStorage.getItem('selectedModel')) {
+    legacy.default_model = localStorage.getItem('selectedModel');
+  }
+  return legacy;
+}
+
+// applyPreferences replaces the settings and tells observers
+function applyPreferences(preferences) {
+  const oldValue = state.preferences;
+  state.preferences = preferences;
+  localStorage.setItem(PREFERENCES_CACHE_KEY, JSON.stringify(preferences));
+  if (window.StateEvents && window.StateEvents.emit) {
+    window.StateEvents.emit('preferencesChange', { oldValue, newValue: preferences });
+  }
+}
+
+// loadPreferences fetches the settings from the server
+async function loadPreferences() {
+  try {
+    const response = await fetch('/api/settings');
+    if (!response.ok) {
+      throw new Error(`Request failed (${response.status})`);
+    }
+    const preferences = await response.json();
+
+    // Carry over a model chosen in this browser before settings were stored on the server
+    if (!preferences.updated_at && state.preferences.default_model && !preferences.default_model) {
+      return await savePreferences({ default_model: state.preferences.default_model });
+    }
+
+    applyPreferences(preferences);
+  } catch (error) {
+    console.warn('Using cached settings:', error.message);
+  }
+  return state.preferences;
+}
+
+// savePreferences stores the given settings, leaving the others as they are
+async function savePreferences(changes) {
+  const response = await fetch('/api/settings', {
+    method: 'PUT',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(changes)
+  });
+  if (!response.ok) {
+    throw new Error((await response.text()) || `Request failed (${response.status})`);
+  }
+  const preferences = await response.json();
+  applyPreferences(preferences);
+  return preferences;
+}
+
+// getPreference returns one setting, e.g. getPreference('editor').font_size
+function getPreference(key, fallback) {
+  const value = state.preferences ? state.preferences[key] : undefined;
+  return value === undefined ? fallback : value;
+}
+
 // Export all functions to global scope using IIFE pattern
 window.AppState = {
   getState,
@@ -171,5 +249,9 @@ window.AppState = {
   resetState,
   incrementReconnectAttempts,
   resetReconnectState,
-  setConnectionStatus
+  setConnectionStatus,
+  loadPreferences,
+  savePreferences,
+  applyPreferences,
+  getPreference
 };
\ No newline at end of file
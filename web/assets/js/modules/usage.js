@@ -165,6 +165,20 @@
           <span class="stat-value">$${daily.totalCost.toFixed(4)}</span>
         </div>
       `;
+
+      // Messages served by a fallback model, by the model that served them
+      if (daily.totalFallbacks > 0) {
+        const servedBy = (daily.byModel || [])
+          .filter(stat => stat.fallbacks > 0)
+          .map(stat => `${stat.model}: ${stat.fallbacks}`)
+          .join(', ');
+        dailyUsageEl.innerHTML += `
+          <div class="stat-item">
+            <span class="stat-label">Fallbacks:</span>
+            <span class="stat-value">${daily.totalFallbacks} (${servedBy})</span>
+          </div>
+        `;
+      }
     }
   }
 
@@ -14,6 +14,7 @@ function toggleStopButton(show) {
   const sendBtn = document.getElementById('send-btn');
   const stopBtn = document.getElementById('stop-btn');
   const createPlanBtn = document.getElementById('create-plan-btn');
+  const planTemplateBtn = document.getElementById('plan-template-btn');
   
   if (!stopBtn) {
     // Create stop button if it doesn't exist
@@ -34,6 +35,7 @@ function toggleStopButton(show) {
   if (show) {
     if (sendBtn) sendBtn.style.display = 'none';
     if (createPlanBtn) createPlanBtn.style.display = 'none';
+    if (planTemplateBtn) planTemplateBtn.style.display = 'none';
     if (stopBtnElement) stopBtnElement.style.display = 'inline-block';
   } else {
     if (sendBtn) sendBtn.style.display = 'inline-block';
@@ -42,6 +44,7 @@ function toggleStopButton(show) {
     const planModeSwitch = document.getElementById('plan-mode-switch');
     if (planModeSwitch && planModeSwitch.checked && createPlanBtn) {
       createPlanBtn.style.display = 'inline-block';
+      if (planTemplateBtn) planTemplateBtn.style.display = 'inline-block';
       if (sendBtn) sendBtn.style.display = 'none';
     }
   }
@@ -371,6 +374,12 @@ async function loadSessions() {
     sessionList.innerHTML = '';
 
     sessions.forEach(session => {
+      if (session.id === currentSessionId) {
+        showReadOnlyMode(session.read_only);
+        showAutoAcceptMode(session.auto_accept);
+        showSessionAccount(session.account);
+        showSessionPackage(session.package);
+      }
       const item = document.createElement('div');
       item.className = 'session-item' + (session.id === currentSessionId ? ' active' : '');
       item.textContent = session.title || 'Session ' + session.id.substring(0, 8);
@@ -388,6 +397,8 @@ function selectSession(sessionId) {
   window.currentSessionId = sessionId; // make sure it is globally available
   pendingNewSession = false; // Clear pending state when selecting existing session
   loadMessages();
+  loadTodos();
+  loadNotes();
   loadSessions(); // Refresh to update active state
 }
 
@@ -485,6 +496,16 @@ function addToolUsageSummaryToUI(evtData) {
     // Single line summary
     toolItem.textContent = toolData.summary;
   }
+
+  // The model was sent part of the output; link the whole of it
+  if (toolData.artifactId) {
+    const link = document.createElement('a');
+    link.className = 'tool-artifact-link';
+    link.href = `/api/session/${encodeURIComponent(evtData.sessionId)}/artifacts/${toolData.artifactId}`;
+    link.target = '_blank';
+    link.textContent = `Full output (artifact ${toolData.artifactId})`;
+    toolItem.appendChild(link);
+  }
   
   toolsList.appendChild(toolItem);
   
@@ -690,7 +711,9 @@ function addThinkingIndicator(id) {
   let modelName = 'Assistant';
   if (modelSelector) {
     const value = modelSelector.value;
-    if (value.includes('opus-4-1')) {
+    if (!value.startsWith('claude-')) {
+      modelName = value; // A local model
+    } else if (value.includes('opus-4-1')) {
       modelName = 'Claude Opus 4.1';
     } else if (value.includes('opus-4')) {
       modelName = 'Claude Opus 4';
@@ -751,7 +774,9 @@ function createStreamingMessage() {
   // Show which model is responding
   const modelSelector = document.getElementById('model-selector');
   let modelName = 'Assistant';
-  if (modelSelector && modelSelector.value) {
+  if (modelSelector && modelSelector.value && !modelSelector.value.startsWith('claude-')) {
+    modelName = modelSelector.value; // A local model
+  } else if (modelSelector && modelSelector.value) {
     // Extract model name from value
     const parts = modelSelector.value.split('-');
     if (parts.length > 1) {
@@ -759,7 +784,7 @@ function createStreamingMessage() {
     }
   }
   
-  header.innerHTML = `<span class="role">${modelName}</span>`;
+  header.innerHTML = `<span class="role">${escapeHtml(modelName)}</span>`;
   
   const content = document.createElement('div');
   content.className = 'message-content';
@@ -931,6 +956,9 @@ async function sendMessage() {
   isProcessing = true;
   isLLMProcessing = true; // Mark LLM as processing
 
+  // Set when the message waits for the session's turn in progress, which is still running
+  let queued = false;
+
   try {
     // Create abort controller for this request
     currentRequestController = new AbortController();
@@ -1008,7 +1036,22 @@ async function sendMessage() {
     
     // Remove thinking indicator when we get the response
     removeThinkingIndicator(thinkingId);
-    
+
+    // The server sends a queued message when the turn in progress finishes
+    if (result.queueId) {
+      queued = true;
+      return;
+    }
+
+    // Slash commands are answered by the server directly
+    if (result.command) {
+      addMessageToUI({ role: 'assistant', content: result.content });
+      if (result.model && modelSelector) {
+        modelSelector.value = result.model;
+      }
+      return;
+    }
+
     // Display tool summaries if any
     if (result.toolSummaries && result.toolSummaries.length > 0) {
       // Create tools summary container
@@ -1049,33 +1092,113 @@ async function sendMessage() {
       });
     }
   } finally {
-    // Reset UI state
-    toggleStopButton(false);
-    isProcessing = false;
-    isLLMProcessing = false; // Reset LLM processing state
-    toolsAnnounced = false; // Reset tools announced flag
-    currentRequestController = null;
-    
-    // Clear any pending thinking return timer
-    if (thinkingReturnTimer) {
-      clearTimeout(thinkingReturnTimer);
-      thinkingReturnTimer = null;
+    // Reset UI state, unless the turn in progress is still running
+    if (!queued) {
+      toggleStopButton(false);
+      isProcessing = false;
+      isLLMProcessing = false; // Reset LLM processing state
+      toolsAnnounced = false; // Reset tools announced flag
+      currentRequestController = null;
+
+      // Clear any pending thinking return timer
+      if (thinkingReturnTimer) {
+        clearTimeout(thinkingReturnTimer);
+        thinkingReturnTimer = null;
+      }
     }
   }
 }
 
 // Actually create a new session in the database
+// Slash command picker: lists the built-in and project commands and inserts the chosen one
+async function toggleCommandPicker() {
+  const picker = document.getElementById('commands-picker');
+  if (!picker) return;
+
+  if (picker.style.display !== 'none') {
+    picker.style.display = 'none';
+    return;
+  }
+
+  try {
+    const response = await fetch('/api/commands');
+    if (!response.ok) throw new Error('Failed to load commands');
+    const data = await response.json();
+    renderCommandPicker(picker, data.commands || []);
+  } catch (error) {
+    console.error('Failed to load commands:', error);
+    picker.innerHTML = '<div class="commands-empty">Could not load commands</div>';
+  }
+  picker.style.display = 'block';
+}
+
+function renderCommandPicker(picker, commands) {
+  if (commands.length === 0) {
+    picker.innerHTML = '<div class="commands-empty">No commands available</div>';
+    return;
+  }
+
+  picker.innerHTML = commands.map(cmd => `
+    <div class="command-item" data-name="${escapeHtml(cmd.name)}">
+      <div>
+        <span class="command-usage">${escapeHtml(cmd.usage)}</span>
+        ${cmd.source ? `<span class="command-source">${escapeHtml(cmd.source)}</span>` : ''}
+      </div>
+      <div class="command-description">${escapeHtml(cmd.description)}</div>
+    </div>
+  `).join('');
+
+  picker.querySelectorAll('.command-item').forEach(item => {
+    item.addEventListener('click', () => {
+      picker.style.display = 'none';
+      if (editor) {
+        editor.setValue('/' + item.dataset.name + ' ');
+        const model = editor.getModel();
+        editor.setPosition({
+          lineNumber: model.getLineCount(),
+          column: model.getLineMaxColumn(model.getLineCount())
+        });
+        editor.focus();
+      }
+    });
+  });
+}
+
 async function actuallyCreateSession() {
   try {
+    // Start from the recap offered, once the last session's is written, if it is kept checked
+    const request = {};
+    if (recapOffer) {
+      const recap = await recapOffer;
+      const include = document.getElementById('recap-offer-include');
+      if (recap && include && include.checked) {
+        request.recap_id = recap.id;
+      }
+      const offer = document.getElementById('recap-offer');
+      if (offer) offer.remove();
+      recapOffer = null;
+    }
+
     const response = await fetch('/api/session', {
       method: 'POST',
-      headers: { 'Content-Type': 'application/json' }
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(request)
     });
 
     const session = await response.json();
     currentSessionId = session.id;
     window.currentSessionId = session.id; // Ensure global is also set
     pendingNewSession = false;
+
+    // Read-only mode chosen before the session existed applies from its first message
+    const readOnlySwitch = document.getElementById('read-only-switch');
+    if (readOnlySwitch && readOnlySwitch.checked) {
+      await setSessionReadOnly(session.id, true);
+    }
+    const autoAcceptSwitch = document.getElementById('auto-accept-switch');
+    if (autoAcceptSwitch && autoAcceptSwitch.checked) {
+      await setSessionAutoAccept(session.id, true);
+    }
     
     // Don't reload sessions immediately - wait for title to be set
     return session;
@@ -1085,8 +1208,14 @@ async function actuallyCreateSession() {
   }
 }
 
+// Recap offered to the session about to be created: a promise of it, or of null when there
+// is none, while the session left is recapped
+let recapOffer = null;
+
 // Prepare UI for new session (called by New Session button)
 async function createNewSession() {
+  const closingSessionId = currentSessionId;
+
   // Just prepare UI for new session
   currentSessionId = null;
   window.currentSessionId = null;
@@ -1094,11 +1223,15 @@ async function createNewSession() {
   
   // Clear messages
   document.getElementById('messages').innerHTML = '';
+  renderTodos([]);
+  renderNotes([]);
   
   // Remove active class from all sessions
   document.querySelectorAll('.session-item').forEach(item => {
     item.classList.remove('active');
   });
+
+  offerSessionRecap(closingSessionId);
   
   // Focus on input
   if (editor) {
@@ -1106,6 +1239,56 @@ async function createNewSession() {
   }
 }
 
+// offerSessionRecap closes the session left, when recaps are on, and offers its recap to the
+// new session; without one, the project's latest recap is offered
+function offerSessionRecap(closingSessionId) {
+  const prefs = window.AppState.getState('preferences') || {};
+  recapOffer = null;
+  if (!prefs.session_recaps) return;
+
+  const offer = document.createElement('div');
+  offer.id = 'recap-offer';
+  offer.className = 'recap-offer';
+  offer.textContent = closingSessionId ? 'Recapping the last session...' : '';
+  document.getElementById('messages').appendChild(offer);
+
+  const closing = closingSessionId
+    ? fetch('/api/session/' + closingSessionId + '/close', { method: 'POST' })
+        .then(response => response.ok ? response.json() : {})
+        .then(closed => closed.recap)
+    : Promise.resolve(null);
+  recapOffer = closing
+    .then(recap => recap || fetch('/api/recaps?limit=1')
+      .then(response => response.ok ? response.json() : [])
+      .then(recaps => recaps[0]))
+    .catch(error => {
+      console.error('Failed to recap session:', error);
+      return null;
+    })
+    .then(recap => {
+      renderRecapOffer(offer, recap);
+      return recap || null;
+    });
+}
+
+function renderRecapOffer(offer, recap) {
+  if (!recap) {
+    offer.remove();
+    return;
+  }
+  const from = recap.session_title ? `"${escapeHtml(recap.session_title)}"` : 'the last session';
+  offer.innerHTML = `
+    <label><input type="checkbox" id="recap-offer-include" checked>
+      Carry on from ${from}, ${new Date(recap.created_at).toLocaleDateString()}</label>
+    <details><summary>Recap</summary><div class="recap-content"></div></details>`;
+  const content = offer.querySelector('.recap-content');
+  if (window.marked) {
+    content.innerHTML = marked.parse(recap.content);
+  } else {
+    content.textContent = recap.content;
+  }
+}
+
 // Update message (for streaming)
 function updateMessage(messageData) {
   // This will be implemented when we add streaming support
@@ -1133,6 +1316,7 @@ function initializePlanMode() {
   const planModeIndicator = document.getElementById('plan-mode-indicator');
   const sendBtn = document.getElementById('send-btn');
   const createPlanBtn = document.getElementById('create-plan-btn');
+  const planTemplateBtn = document.getElementById('plan-template-btn');
   const planExecutionArea = document.getElementById('plan-execution-area');
   const closePlanBtn = document.getElementById('close-plan-btn');
   
@@ -1147,6 +1331,7 @@ function initializePlanMode() {
       planModeIndicator.style.display = 'block';
       sendBtn.style.display = 'none';
       createPlanBtn.style.display = 'inline-block';
+      if (planTemplateBtn) planTemplateBtn.style.display = 'inline-block';
       if (editor) {
         editor.updateOptions({ placeholder: 'Describe a complex task to create a plan...' });
       }
@@ -1154,6 +1339,7 @@ function initializePlanMode() {
       planModeIndicator.style.display = 'none';
       sendBtn.style.display = 'inline-block';
       createPlanBtn.style.display = 'none';
+      if (planTemplateBtn) planTemplateBtn.style.display = 'none';
       if (editor) {
         editor.updateOptions({ placeholder: 'Type a message...' });
       }
@@ -1165,6 +1351,11 @@ function initializePlanMode() {
     createPlanBtn.addEventListener('click', createPlan);
   }
   
+  // Create plan from template button
+  if (planTemplateBtn) {
+    planTemplateBtn.addEventListener('click', createPlanFromTemplate);
+  }
+  
   // Close plan execution area
   if (closePlanBtn) {
     closePlanBtn.addEventListener('click', function() {
@@ -1174,11 +1365,17 @@ function initializePlanMode() {
   }
   
   // Plan control buttons
+  const dryRunPlanBtn = document.getElementById('dry-run-plan-btn');
   const executePlanBtn = document.getElementById('execute-plan-btn');
   const pausePlanBtn = document.getElementById('pause-plan-btn');
+  const cancelPlanBtn = document.getElementById('cancel-plan-btn');
   const rollbackPlanBtn = document.getElementById('rollback-plan-btn');
   const viewMetricsBtn = document.getElementById('view-metrics-btn');
   
+  if (dryRunPlanBtn) {
+    dryRunPlanBtn.addEventListener('click', dryRunPlan);
+  }
+  
   if (executePlanBtn) {
     executePlanBtn.addEventListener('click', executePlan);
   }
@@ -1187,6 +1384,10 @@ function initializePlanMode() {
     pausePlanBtn.addEventListener('click', pausePlan);
   }
   
+  if (cancelPlanBtn) {
+    cancelPlanBtn.addEventListener('click', cancelPlan);
+  }
+  
   if (rollbackPlanBtn) {
     rollbackPlanBtn.addEventListener('click', showRollbackDialog);
   }
@@ -1206,7 +1407,8 @@ async function createPlan() {
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify({ 
         description: content,
-        auto_execute: false 
+        auto_execute: false,
+        use_llm: !!document.getElementById('plan-use-llm')?.checked
       })
     });
     
@@ -1232,6 +1434,72 @@ async function createPlan() {
   }
 }
 
+// Let the user pick a task template, prompt for its variables and instantiate it
+async function createPlanFromTemplate() {
+  if (!currentSessionId) return;
+  
+  try {
+    const listResponse = await fetch('/api/templates');
+    if (!listResponse.ok) {
+      throw new Error('Failed to load templates');
+    }
+    const templates = await listResponse.json();
+    if (!templates || templates.length === 0) {
+      addMessage('assistant', 'No task templates are available.');
+      return;
+    }
+    
+    const choices = templates.map((t, i) => `${i + 1}. ${t.name} - ${t.description}`).join('\n');
+    const choice = window.prompt(`Choose a template:\n\n${choices}`, '1');
+    if (choice === null) return;
+    
+    const template = templates[parseInt(choice, 10) - 1];
+    if (!template) {
+      addMessage('assistant', '❌ Invalid template selection.');
+      return;
+    }
+    
+    // Prompt for each variable, pre-filling defaults
+    const variables = {};
+    for (const v of (template.variables || [])) {
+      const label = `${v.name}${v.required ? ' (required)' : ''}\n${v.description || ''}`;
+      const defaultValue = v.default_value !== undefined && v.default_value !== null ? String(v.default_value) : '';
+      const value = window.prompt(label, defaultValue);
+      if (value === null) return;
+      if (value !== '') {
+        variables[v.name] = value;
+      } else if (v.required) {
+        addMessage('assistant', `❌ Template variable "${v.name}" is required.`);
+        return;
+      }
+    }
+    
+    const response = await fetch(`/api/templates/${template.id}/instantiate`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({
+        session_id: currentSessionId,
+        variables: variables,
+        auto_execute: false
+      })
+    });
+    
+    if (!response.ok) {
+      throw new Error(await response.text() || 'Failed to create plan from template');
+    }
+    
+    const plan = await response.json();
+    currentPlan = plan;
+    
+    addMessage('assistant', `📋 **Task Plan Created from template \`${template.name}\`**\n\nI've created a plan with ${plan.steps.length} steps to: ${plan.description}`);
+    displayPlan(plan);
+    
+  } catch (error) {
+    console.error('Error creating plan from template:', error);
+    addMessage('assistant', '❌ Failed to create plan from template. Please try again.');
+  }
+}
+
 function displayPlan(plan) {
   const planExecutionArea = document.getElementById('plan-execution-area');
   const planStepsContainer = document.getElementById('plan-steps');
@@ -1282,6 +1550,62 @@ function createStepElement(step, number) {
   return stepDiv;
 }
 
+// Preview the current plan without running any tools, then offer to execute it
+async function dryRunPlan() {
+  if (!currentPlan) return;
+  
+  try {
+    const response = await fetch(`/api/plan/${currentPlan.id}/dry-run`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' }
+    });
+    
+    if (!response.ok) {
+      throw new Error('Failed to dry run plan');
+    }
+    
+    const report = await response.json();
+    
+    let summary = `🔍 **Dry Run: ${report.description}**\n\n`;
+    report.steps.forEach((step, i) => {
+      const status = step.valid ? (step.destructive ? '⚠️' : '✅') : '❌';
+      summary += `${i + 1}. ${status} ${step.action}\n`;
+      if (step.error) {
+        summary += `   - Error: ${step.error}\n`;
+      }
+      (step.warnings || []).forEach(w => {
+        summary += `   - Warning: ${w}\n`;
+      });
+    });
+    if (report.files_written.length) {
+      summary += `\n**Files written:** ${report.files_written.join(', ')}`;
+    }
+    if (report.files_deleted.length) {
+      summary += `\n**Files deleted:** ${report.files_deleted.join(', ')}`;
+    }
+    if (report.git_operations.length) {
+      summary += `\n**Git operations:** ${report.git_operations.join('; ')}`;
+    }
+    addMessage('assistant', summary);
+    
+    if (!report.valid) {
+      addMessage('assistant', '❌ Some steps would fail. Edit the plan before executing it.');
+      return;
+    }
+    
+    const prompt = report.destructive
+      ? 'This plan includes destructive steps. Approve and execute it?'
+      : 'Approve and execute this plan?';
+    if (window.confirm(prompt)) {
+      executePlan();
+    }
+    
+  } catch (error) {
+    console.error('Error running plan dry run:', error);
+    addMessage('assistant', '❌ Failed to dry run plan. Please try again.');
+  }
+}
+
 async function executePlan() {
   if (!currentPlan) return;
   
@@ -1298,6 +1622,7 @@ async function executePlan() {
     // Update controls
     document.getElementById('execute-plan-btn').disabled = true;
     document.getElementById('pause-plan-btn').disabled = false;
+    document.getElementById('cancel-plan-btn').disabled = false;
     document.getElementById('rollback-plan-btn').disabled = false;
     
     addMessage('assistant', '🚀 Plan execution started...');
@@ -1313,9 +1638,84 @@ function pausePlan() {
   console.log('Pause plan - not yet implemented');
 }
 
-function showRollbackDialog() {
-  // TODO: Show checkpoint selection dialog
-  console.log('Rollback - not yet implemented');
+async function cancelPlan() {
+  if (!currentPlan) return;
+  
+  if (!window.confirm('Cancel this plan? The running step will be stopped and its file changes rolled back where possible.')) return;
+  
+  try {
+    const response = await fetch(`/api/plan/${currentPlan.id}/cancel`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' }
+    });
+    if (!response.ok) throw new Error(await response.text());
+    
+    document.getElementById('cancel-plan-btn').disabled = true;
+    addMessage('assistant', '⏹️ Cancelling plan...');
+  } catch (error) {
+    console.error('Error cancelling plan:', error);
+    addMessage('assistant', `❌ Failed to cancel plan: ${error.message}`);
+  }
+}
+
+async function showRollbackDialog() {
+  if (!currentPlan) return;
+  
+  try {
+    const cpResponse = await fetch(`/api/plan/${currentPlan.id}/checkpoints`);
+    if (!cpResponse.ok) throw new Error('Failed to load checkpoints');
+    const checkpoints = await cpResponse.json() || [];
+    
+    if (checkpoints.length === 0) {
+      addMessage('assistant', 'ℹ️ This plan has no checkpoints to roll back to.');
+      return;
+    }
+    
+    const choices = checkpoints.map((cp, i) =>
+      `${i + 1}. ${cp.description || cp.step_id} (${new Date(cp.timestamp).toLocaleString()})`).join('\n');
+    const selection = window.prompt(`Roll back to which checkpoint?\n\n${choices}`, String(checkpoints.length));
+    if (selection === null) return;
+    
+    const checkpoint = checkpoints[parseInt(selection, 10) - 1];
+    if (!checkpoint) {
+      addMessage('assistant', '❌ Invalid checkpoint selection.');
+      return;
+    }
+    
+    // Show what a rollback would change before committing to it
+    const diffResponse = await fetch(`/api/plan/${currentPlan.id}/checkpoints/${checkpoint.id}/diff`);
+    if (!diffResponse.ok) throw new Error(await diffResponse.text());
+    const checkpointDiff = await diffResponse.json();
+    
+    const changed = checkpointDiff.files.filter(f => f.status !== 'unchanged');
+    let summary = `⏪ **Rollback Preview** — ${checkpoint.description || checkpoint.id}\n\n`;
+    if (changed.length === 0) {
+      summary += 'No snapshotted files have changed since this checkpoint.\n';
+    } else {
+      changed.forEach(f => {
+        summary += `- ${f.path} (${f.status}, +${f.stats.additions} -${f.stats.deletions})\n`;
+      });
+    }
+    addMessage('assistant', summary);
+    
+    if (changed.length > 0 && window.diffViewer) {
+      await window.diffViewer.showDiffData(changed[0]);
+    }
+    
+    if (!window.confirm(`Roll back ${changed.length} changed file(s) to this checkpoint?`)) return;
+    
+    const response = await fetch(`/api/plan/${currentPlan.id}/rollback`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ checkpoint_id: checkpoint.id })
+    });
+    if (!response.ok) throw new Error(await response.text());
+    
+    addMessage('assistant', '✅ Plan rolled back to checkpoint.');
+  } catch (error) {
+    console.error('Error during rollback:', error);
+    addMessage('assistant', `❌ Rollback failed: ${error.message}`);
+  }
 }
 
 async function viewPlanMetrics() {
@@ -1335,6 +1735,9 @@ async function viewPlanMetrics() {
     if (status.metrics && status.metrics.total_duration) {
       metricsText += `Duration: ${formatDuration(status.metrics.total_duration)}\n`;
     }
+    if (status.metrics && status.metrics.coverage_before != null) {
+      metricsText += `Test Coverage: ${formatCoverage(status.metrics)}\n`;
+    }
     
     addMessage('assistant', metricsText);
     
@@ -1343,26 +1746,40 @@ async function viewPlanMetrics() {
   }
 }
 
+// formatCoverage shows the test coverage a plan measured: "41.2% → 58.9% (+17.7)"
+function formatCoverage(metrics) {
+  const before = metrics.coverage_before;
+  const after = metrics.coverage_after != null ? metrics.coverage_after : before;
+  const delta = after - before;
+  if (delta === 0) return `${before.toFixed(1)}%`;
+  return `${before.toFixed(1)}% → ${after.toFixed(1)}% (${delta > 0 ? '+' : ''}${delta.toFixed(1)})`;
+}
+
 function updatePlanControls(status) {
   const executeBtn = document.getElementById('execute-plan-btn');
   const pauseBtn = document.getElementById('pause-plan-btn');
+  const cancelBtn = document.getElementById('cancel-plan-btn');
   const rollbackBtn = document.getElementById('rollback-plan-btn');
   
   switch (status) {
     case 'pending':
       executeBtn.disabled = false;
       pauseBtn.disabled = true;
+      cancelBtn.disabled = false;
       rollbackBtn.disabled = true;
       break;
     case 'executing':
       executeBtn.disabled = true;
       pauseBtn.disabled = false;
+      cancelBtn.disabled = false;
       rollbackBtn.disabled = false;
       break;
     case 'completed':
     case 'failed':
+    case 'cancelled':
       executeBtn.disabled = true;
       pauseBtn.disabled = true;
+      cancelBtn.disabled = true;
       rollbackBtn.disabled = false;
       break;
   }
@@ -1378,8 +1795,20 @@ function handlePlanEvent(event) {
       handleStepProgress(event.data);
       break;
     case 'plan_completed':
+    case 'plan_failed':
+    case 'plan_cancelled':
       handlePlanCompleted(event.data);
       break;
+    case 'plan_step_approval_needed':
+      // The permission modal handles the decision; mark the step as waiting
+      handleStepProgress({ step_id: event.data.step_id, status: 'awaiting_approval' });
+      break;
+    case 'plan_step_approved':
+      handleStepProgress({ step_id: event.data.step_id, status: 'running' });
+      break;
+    case 'plan_step_denied':
+      handleStepProgress({ step_id: event.data.step_id, status: 'skipped' });
+      break;
   }
 }
 
@@ -1428,6 +1857,8 @@ function handlePlanCompleted(data) {
     addMessage('assistant', '✅ Plan execution completed successfully!');
   } else if (data.status === 'failed') {
     addMessage('assistant', '❌ Plan execution failed. You can try to rollback to a previous checkpoint.');
+  } else if (data.status === 'cancelled') {
+    addMessage('assistant', '⏹️ Plan cancelled. Changes from the interrupted step were rolled back where possible.');
   }
 }
 
@@ -1479,16 +1910,17 @@ document.addEventListener('DOMContentLoaded', function() {
   // Initialize model selector
   const modelSelector = document.getElementById('model-selector');
   if (modelSelector) {
-    // Load saved model preference
-    const savedModel = localStorage.getItem('selectedModel');
+    // Start with the saved default model; user settings may update it once loaded
+    const savedModel = window.AppState.getPreference('default_model');
     if (savedModel) {
       modelSelector.value = savedModel;
     }
 
-    // Save model preference on change
+    // Save model preference on change, so it is the default in every browser
     modelSelector.addEventListener('change', function() {
-      localStorage.setItem('selectedModel', modelSelector.value);
       console.log('Model changed to:', modelSelector.value);
+      window.AppState.savePreferences({ default_model: modelSelector.value })
+        .catch(error => console.error('Failed to save model preference:', error));
     });
   }
 
@@ -1498,6 +1930,11 @@ document.addEventListener('DOMContentLoaded', function() {
   // Load initial data
   loadSessions();
 
+  // Conversation search in the sessions sidebar
+  if (window.setupSessionSearch) {
+    window.setupSessionSearch();
+  }
+
   // Button handlers
   const sendBtn = document.getElementById('send-btn');
   if (sendBtn) {
@@ -1517,6 +1954,11 @@ document.addEventListener('DOMContentLoaded', function() {
     };
   }
 
+  const commandsBtn = document.getElementById('commands-btn');
+  if (commandsBtn) {
+    commandsBtn.onclick = toggleCommandPicker;
+  }
+
   const newSessionBtn = document.getElementById('new-session-btn');
   if (newSessionBtn) {
     newSessionBtn.onclick = createNewSession;
@@ -1626,80 +2068,1225 @@ document.addEventListener('DOMContentLoaded', function() {
   
   // Initialize Plan History
   initializePlanHistory();
+
+  // Initialize the commit dialog
+  initializeCommitDialog();
+  initializeReviewPanel();
+  initializeTerminalPanel();
+  initializeProcessesPanel();
+  initializeToolsPanel();
+  initializeNotifications();
+  initializeReadOnlyMode();
+  initializeAutoAcceptMode();
+  initializeSettingsPanel();
+  initializeUsersPanel();
+  initializeAccounts();
+  initializePackages();
+  initializeTodoPanel();
+  initializeNotesPanel();
 });
 
-// Plan History functionality
-let planHistoryPage = 1;
-let planHistoryLoading = false;
-let planHistorySearch = '';
-let planHistoryStatus = '';
+// Commit dialog: generate a message for the staged changes, edit it, then commit
+function initializeCommitDialog() {
+  const commitBtn = document.getElementById('commit-btn');
+  const generateBtn = document.getElementById('commit-generate');
+  const submitBtn = document.getElementById('commit-submit');
 
-function initializePlanHistory() {
-  const historyBtn = document.getElementById('plan-history-btn');
-  const historyPanel = document.getElementById('plan-history-panel');
-  const closeHistoryBtn = document.getElementById('close-history-btn');
-  const searchInput = document.getElementById('plan-search');
-  const statusFilter = document.getElementById('plan-status-filter');
-  const loadMoreBtn = document.getElementById('load-more-plans');
-  
-  if (!historyBtn || !historyPanel) {
-    console.log('Plan history elements not found');
+  if (!commitBtn || !generateBtn || !submitBtn) {
     return;
   }
-  
-  // Toggle panel
-  historyBtn.addEventListener('click', () => {
-    historyPanel.classList.toggle('open');
-    if (historyPanel.classList.contains('open')) {
-      planHistoryPage = 1;
-      loadPlanHistory(true);
+
+  commitBtn.addEventListener('click', () => {
+    document.getElementById('commit-modal').classList.add('open');
+    if (!document.getElementById('commit-message').value.trim()) {
+      generateCommitMessage();
     }
   });
-  
-  // Close panel
-  closeHistoryBtn.addEventListener('click', () => {
-    historyPanel.classList.remove('open');
-  });
-  
-  // Search functionality
-  let searchTimeout;
-  searchInput.addEventListener('input', (e) => {
-    clearTimeout(searchTimeout);
-    planHistorySearch = e.target.value;
-    searchTimeout = setTimeout(() => {
-      planHistoryPage = 1;
-      loadPlanHistory(true);
-    }, 300);
-  });
-  
-  // Filter functionality
-  statusFilter.addEventListener('change', (e) => {
-    planHistoryStatus = e.target.value;
-    planHistoryPage = 1;
-    loadPlanHistory(true);
-  });
-  
-  // Load more
-  loadMoreBtn.addEventListener('click', () => {
-    planHistoryPage++;
-    loadPlanHistory(false);
+  generateBtn.addEventListener('click', () => generateCommitMessage());
+  submitBtn.addEventListener('click', () => submitCommit());
+}
+
+function setCommitStatus(text, isError) {
+  const status = document.getElementById('commit-status');
+  status.textContent = text;
+  status.classList.toggle('error', !!isError);
+}
+
+// requestCommitMessage posts to the commit message endpoint and returns its JSON reply
+async function requestCommitMessage(body) {
+  const response = await fetch('/api/git/commit-message', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(body)
   });
+  if (!response.ok) {
+    throw new Error((await response.text()) || `Request failed (${response.status})`);
+  }
+  return response.json();
 }
 
-async function loadPlanHistory(reset = false) {
-  if (planHistoryLoading || !currentSessionId) return;
-  
-  planHistoryLoading = true;
-  const historyList = document.getElementById('plan-history-list');
-  const loadMoreBtn = document.getElementById('load-more-plans');
-  
-  if (reset) {
-    historyList.innerHTML = '<div class="loading">Loading plan history...</div>';
+async function generateCommitMessage() {
+  const generateBtn = document.getElementById('commit-generate');
+  const messageEl = document.getElementById('commit-message');
+
+  generateBtn.disabled = true;
+  setCommitStatus('Generating commit message from the staged changes...');
+  try {
+    const data = await requestCommitMessage({ hint: document.getElementById('commit-hint').value.trim() });
+    messageEl.value = data.message;
+    setCommitStatus('Review the message, then commit.');
+  } catch (error) {
+    setCommitStatus(error.message, true);
+  } finally {
+    generateBtn.disabled = false;
   }
-  
+}
+
+async function submitCommit() {
+  const submitBtn = document.getElementById('commit-submit');
+  const message = document.getElementById('commit-message').value.trim();
+  if (!message) {
+    setCommitStatus('Enter or generate a commit message first.', true);
+    return;
+  }
+
+  submitBtn.disabled = true;
+  setCommitStatus('Committing...');
   try {
-    const params = new URLSearchParams({
-      page: planHistoryPage,
+    const data = await requestCommitMessage({ commit: true, message: message });
+    document.getElementById('commit-message').value = '';
+    document.getElementById('commit-hint').value = '';
+    setCommitStatus(data.output || 'Committed.');
+  } catch (error) {
+    setCommitStatus(error.message, true);
+  } finally {
+    submitBtn.disabled = false;
+  }
+}
+
+function closeCommitModal() {
+  document.getElementById('commit-modal').classList.remove('open');
+}
+
+// Code review panel
+let activeReviewId = null;
+
+function initializeReviewPanel() {
+  const reviewBtn = document.getElementById('review-btn');
+  const startBtn = document.getElementById('review-start');
+
+  if (!reviewBtn || !startBtn) {
+    return;
+  }
+
+  reviewBtn.addEventListener('click', () => {
+    document.getElementById('review-modal').classList.add('open');
+    loadReviews();
+  });
+  startBtn.addEventListener('click', () => startReview());
+
+  if (window.SSEEvents) {
+    window.SSEEvents.on('review_progress', (evt) => {
+      setReviewStatus(`Reviewed ${evt.data.done} of ${evt.data.total} parts of the diff...`);
+      if (evt.data.reviewId === activeReviewId) {
+        showReview(activeReviewId);
+      }
+    });
+    window.SSEEvents.on('review_complete', (evt) => {
+      if (evt.data.error) {
+        setReviewStatus('Review failed: ' + evt.data.error, true);
+      } else {
+        setReviewStatus('Review complete.');
+      }
+      loadReviews();
+      if (evt.data.reviewId === activeReviewId) {
+        showReview(activeReviewId);
+      }
+    });
+  }
+}
+
+function setReviewStatus(text, isError) {
+  const status = document.getElementById('review-status');
+  status.textContent = text;
+  status.classList.toggle('error', !!isError);
+}
+
+async function startReview() {
+  const startBtn = document.getElementById('review-start');
+  const body = {
+    base: document.getElementById('review-base').value.trim(),
+    head: document.getElementById('review-head').value.trim(),
+    staged: document.getElementById('review-staged').checked
+  };
+
+  startBtn.disabled = true;
+  setReviewStatus('Starting review...');
+  try {
+    const response = await fetch('/api/reviews', {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(body)
+    });
+    if (!response.ok) {
+      throw new Error((await response.text()) || `Request failed (${response.status})`);
+    }
+    const review = await response.json();
+    setReviewStatus(`Reviewing ${review.head_ref} against ${review.base_ref}...`);
+    activeReviewId = review.id;
+    await loadReviews();
+    showReview(review.id);
+  } catch (error) {
+    setReviewStatus(error.message, true);
+  } finally {
+    startBtn.disabled = false;
+  }
+}
+
+async function loadReviews() {
+  const list = document.getElementById('review-list');
+  try {
+    const response = await fetch('/api/reviews');
+    if (!response.ok) {
+      throw new Error(`Request failed (${response.status})`);
+    }
+    const reviews = await response.json();
+
+    list.innerHTML = '';
+    if (reviews.length === 0) {
+      list.innerHTML = '<div class="review-empty">No reviews yet.</div>';
+      return;
+    }
+    reviews.forEach(review => {
+      const item = document.createElement('div');
+      item.className = 'review-item' + (review.id === activeReviewId ? ' active' : '');
+      item.dataset.reviewId = review.id;
+      item.innerHTML = `
+        <div>${escapeHtml(review.head_ref)} vs ${escapeHtml(review.base_ref)}</div>
+        <div class="review-item-meta">${review.status} · ${review.finding_count} findings · ${new Date(review.created_at).toLocaleString()}</div>
+      `;
+      item.onclick = () => showReview(review.id);
+      list.appendChild(item);
+    });
+  } catch (error) {
+    list.innerHTML = `<div class="review-empty">Failed to load reviews: ${escapeHtml(error.message)}</div>`;
+  }
+}
+
+// showReview renders a review's findings grouped by file
+async function showReview(reviewId) {
+  activeReviewId = reviewId;
+  document.querySelectorAll('.review-item').forEach(item => {
+    item.classList.toggle('active', Number(item.dataset.reviewId) === reviewId);
+  });
+
+  const container = document.getElementById('review-findings');
+  try {
+    const response = await fetch(`/api/reviews/${reviewId}`);
+    if (!response.ok) {
+      throw new Error((await response.text()) || `Request failed (${response.status})`);
+    }
+    const data = await response.json();
+    const review = data.review;
+
+    container.innerHTML = '';
+    const header = document.createElement('div');
+    header.className = 'review-summary';
+    header.textContent = review.error ? 'Review failed: ' + review.error
+      : review.summary || (review.status === 'running' ? 'Review in progress...' : 'No summary.');
+    container.appendChild(header);
+
+    const actions = document.createElement('div');
+    actions.innerHTML = '<button class="btn-secondary">Delete review</button>';
+    actions.querySelector('button').onclick = () => deleteReview(reviewId);
+    container.appendChild(actions);
+
+    const byFile = {};
+    data.findings.forEach(finding => {
+      (byFile[finding.file_path] = byFile[finding.file_path] || []).push(finding);
+    });
+
+    Object.keys(byFile).forEach(path => {
+      const fileDiv = document.createElement('div');
+      fileDiv.className = 'review-file';
+      fileDiv.innerHTML = `<div class="review-file-path">${escapeHtml(path)}</div>`;
+      byFile[path].forEach(finding => fileDiv.appendChild(renderFinding(reviewId, finding)));
+      container.appendChild(fileDiv);
+    });
+    if (data.findings.length === 0 && review.status === 'completed') {
+      container.insertAdjacentHTML('beforeend', '<div class="review-empty">No findings.</div>');
+    }
+  } catch (error) {
+    container.innerHTML = `<div class="review-empty">Failed to load review: ${escapeHtml(error.message)}</div>`;
+  }
+}
+
+function renderFinding(reviewId, finding) {
+  const div = document.createElement('div');
+  div.className = `review-finding ${finding.severity}` + (finding.resolved ? ' resolved' : '');
+
+  const lines = finding.end_line > finding.line ? `${finding.line}-${finding.end_line}` : `${finding.line}`;
+  div.innerHTML = `
+    <div class="review-finding-header">
+      <span class="review-finding-line" title="Open file at this line">L${lines}</span>
+      <span class="review-severity">${escapeHtml(finding.severity)}</span>
+      <span class="review-finding-title">${escapeHtml(finding.title)}</span>
+      <label class="review-staged"><input type="checkbox" ${finding.resolved ? 'checked' : ''}> Resolved</label>
+    </div>
+    <div class="review-finding-message">${escapeHtml(finding.message)}</div>
+    ${finding.suggestion ? `<pre class="review-finding-suggestion">${escapeHtml(finding.suggestion)}</pre>` : ''}
+  `;
+
+  div.querySelector('.review-finding-line').onclick = () => {
+    if (window.FileExplorer && window.FileExplorer.openFileAtLine) {
+      closeReviewModal();
+      window.FileExplorer.openFileAtLine(finding.file_path, finding.line);
+    }
+  };
+  div.querySelector('input[type="checkbox"]').onchange = async (e) => {
+    const resolved = e.target.checked;
+    const response = await fetch(`/api/reviews/${reviewId}/findings/${finding.id}`, {
+      method: 'PUT',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ resolved: resolved })
+    });
+    if (response.ok) {
+      div.classList.toggle('resolved', resolved);
+    } else {
+      e.target.checked = !resolved;
+    }
+  };
+
+  return div;
+}
+
+async function deleteReview(reviewId) {
+  if (!confirm('Delete this review and its findings?')) {
+    return;
+  }
+  const response = await fetch(`/api/reviews/${reviewId}`, { method: 'DELETE' });
+  if (!response.ok) {
+    setReviewStatus('Failed to delete review', true);
+    return;
+  }
+  activeReviewId = null;
+  document.getElementById('review-findings').innerHTML = '<div class="review-empty">Select a review to see its findings.</div>';
+  loadReviews();
+}
+
+function closeReviewModal() {
+  document.getElementById('review-modal').classList.remove('open');
+}
+
+// Terminal panel: interactive shells in the project directory. Output arrives over a
+// per-terminal SSE stream; keystrokes and resizes are posted back.
+const openTerminals = new Map(); // terminal id -> { term, fit, source, view, tab, sessionId, ... }
+let activeTerminalId = null;
+
+function initializeTerminalPanel() {
+  const terminalBtn = document.getElementById('terminal-btn');
+  const newBtn = document.getElementById('terminal-new');
+
+  if (!terminalBtn || !newBtn) {
+    return;
+  }
+
+  terminalBtn.addEventListener('click', () => toggleTerminalPanel());
+  newBtn.addEventListener('click', () => startTerminal());
+
+  const body = document.getElementById('terminal-body');
+  if (window.ResizeObserver) {
+    new ResizeObserver(() => fitActiveTerminal()).observe(body);
+  }
+}
+
+async function toggleTerminalPanel() {
+  const panel = document.getElementById('terminal-panel');
+  if (panel.classList.contains('open')) {
+    closeTerminalPanel();
+    return;
+  }
+  if (!currentSessionId) {
+    alert('Start or select a session first.');
+    return;
+  }
+
+  panel.classList.add('open');
+
+  // Terminals belong to a session; drop views of other sessions' terminals
+  for (const [id, entry] of openTerminals) {
+    if (entry.sessionId !== currentSessionId) {
+      detachTerminal(id);
+    }
+  }
+
+  try {
+    const response = await fetch('/api/session/' + currentSessionId + '/terminals');
+    if (!response.ok) {
+      throw new Error((await response.text()) || `Request failed (${response.status})`);
+    }
+    const running = await response.json();
+    running.forEach((t) => {
+      if (!openTerminals.has(t.id)) {
+        attachTerminal(t);
+      }
+    });
+    if (openTerminals.size === 0) {
+      await startTerminal();
+    } else if (!openTerminals.has(activeTerminalId)) {
+      selectTerminal(openTerminals.keys().next().value);
+    } else {
+      fitActiveTerminal();
+    }
+  } catch (error) {
+    console.error('Failed to load terminals:', error);
+  }
+}
+
+function closeTerminalPanel() {
+  document.getElementById('terminal-panel').classList.remove('open');
+}
+
+async function startTerminal() {
+  if (!currentSessionId) {
+    return;
+  }
+  if (!window.Terminal) {
+    alert('The terminal library failed to load.');
+    return;
+  }
+
+  try {
+    const response = await fetch('/api/session/' + currentSessionId + '/terminals', {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ cols: 80, rows: 24 })
+    });
+    if (!response.ok) {
+      throw new Error((await response.text()) || `Request failed (${response.status})`);
+    }
+    attachTerminal(await response.json());
+  } catch (error) {
+    alert('Failed to start terminal: ' + error.message);
+  }
+}
+
+function attachTerminal(info) {
+  const view = document.createElement('div');
+  view.className = 'terminal-view';
+  document.getElementById('terminal-body').appendChild(view);
+
+  const term = new window.Terminal({
+    cursorBlink: true,
+    fontSize: 13,
+    fontFamily: 'Menlo, Monaco, "Courier New", monospace',
+    theme: { background: '#1e1e1e' }
+  });
+  const fit = new window.FitAddon.FitAddon();
+  term.loadAddon(fit);
+  term.open(view);
+
+  const tab = document.createElement('div');
+  tab.className = 'terminal-tab';
+  const label = document.createElement('span');
+  label.textContent = 'Terminal ' + (openTerminals.size + 1);
+  const closeBtn = document.createElement('button');
+  closeBtn.className = 'terminal-tab-close';
+  closeBtn.title = 'Close terminal';
+  closeBtn.textContent = '×';
+  tab.append(label, closeBtn);
+  document.getElementById('terminal-tabs').appendChild(tab);
+
+  const entry = { term, fit, view, tab, sessionId: info.session_id, pending: '', sending: false, exited: false };
+  openTerminals.set(info.id, entry);
+
+  tab.addEventListener('click', () => selectTerminal(info.id));
+  closeBtn.addEventListener('click', (e) => {
+    e.stopPropagation();
+    closeTerminal(info.id);
+  });
+
+  term.onData((data) => sendTerminalInput(info.id, data));
+  term.onResize(({ cols, rows }) => {
+    if (entry.exited) return;
+    fetch('/api/terminals/' + info.id + '/resize', {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ cols, rows })
+    }).catch((error) => console.error('Failed to resize terminal:', error));
+  });
+
+  const source = new EventSource('/api/terminals/' + info.id + '/stream');
+  source.addEventListener('output', (evt) => {
+    const bytes = Uint8Array.from(atob(evt.data), (c) => c.charCodeAt(0));
+    term.write(bytes);
+  });
+  source.addEventListener('exit', () => markTerminalExited(info.id));
+  source.onerror = () => {
+    // The stream ends when the shell exits; don't let EventSource reconnect
+    if (source.readyState === EventSource.CLOSED || entry.exited) {
+      source.close();
+    }
+  };
+  entry.source = source;
+
+  selectTerminal(info.id);
+}
+
+function selectTerminal(id) {
+  activeTerminalId = id;
+  openTerminals.forEach((entry, key) => {
+    entry.view.classList.toggle('active', key === id);
+    entry.tab.classList.toggle('active', key === id);
+  });
+  fitActiveTerminal();
+  const entry = openTerminals.get(id);
+  if (entry) entry.term.focus();
+}
+
+function fitActiveTerminal() {
+  const entry = openTerminals.get(activeTerminalId);
+  if (!entry || !document.getElementById('terminal-panel').classList.contains('open')) {
+    return;
+  }
+  try {
+    entry.fit.fit();
+  } catch (error) {
+    // The view has no size while hidden
+  }
+}
+
+// sendTerminalInput posts keystrokes in order, batching those typed while a post is in flight
+async function sendTerminalInput(id, data) {
+  const entry = openTerminals.get(id);
+  if (!entry || entry.exited) return;
+
+  entry.pending += data;
+  if (entry.sending) return;
+
+  entry.sending = true;
+  while (entry.pending) {
+    const chunk = entry.pending;
+    entry.pending = '';
+    try {
+      await fetch('/api/terminals/' + id + '/input', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ data: chunk })
+      });
+    } catch (error) {
+      console.error('Failed to send terminal input:', error);
+    }
+  }
+  entry.sending = false;
+}
+
+function markTerminalExited(id) {
+  const entry = openTerminals.get(id);
+  if (!entry) return;
+  entry.exited = true;
+  entry.source.close();
+  entry.tab.classList.add('exited');
+  entry.term.write('\r\n[process exited]\r\n');
+}
+
+async function closeTerminal(id) {
+  const entry = openTerminals.get(id);
+  if (!entry) return;
+
+  if (!entry.exited) {
+    try {
+      await fetch('/api/terminals/' + id, { method: 'DELETE' });
+    } catch (error) {
+      console.error('Failed to close terminal:', error);
+    }
+  }
+  detachTerminal(id);
+
+  if (activeTerminalId === id) {
+    const next = openTerminals.keys().next();
+    if (next.done) {
+      activeTerminalId = null;
+      closeTerminalPanel();
+    } else {
+      selectTerminal(next.value);
+    }
+  }
+}
+
+// detachTerminal removes a terminal's view; the shell keeps running on the server
+function detachTerminal(id) {
+  const entry = openTerminals.get(id);
+  if (!entry) return;
+  if (entry.source) entry.source.close();
+  entry.term.dispose();
+  entry.view.remove();
+  entry.tab.remove();
+  openTerminals.delete(id);
+}
+
+// Processes dialog: background processes started by the model, with their logs and,
+// for dev servers, a preview of the running app through the /preview/:id/ proxy
+let activeProcessId = null;
+
+function initializeProcessesPanel() {
+  const processesBtn = document.getElementById('processes-btn');
+  if (!processesBtn) {
+    return;
+  }
+
+  processesBtn.addEventListener('click', () => {
+    document.getElementById('processes-modal').classList.add('open');
+    loadProcesses();
+  });
+
+  if (window.SSEEvents) {
+    window.SSEEvents.on('process_ports', (evt) => {
+      const proc = evt.data;
+      processesBtn.classList.add('has-preview');
+      processesBtn.title = `${proc.name} is listening on port ${proc.ports.join(', ')}`;
+      refreshOpenProcesses(proc.id);
+    });
+    window.SSEEvents.on('process_exited', (evt) => {
+      refreshOpenProcesses(evt.data.id);
+    });
+  }
+}
+
+// refreshOpenProcesses updates the dialog, if it is open, after a process changes
+function refreshOpenProcesses(processId) {
+  if (!document.getElementById('processes-modal').classList.contains('open')) {
+    return;
+  }
+  loadProcesses();
+  if (processId === activeProcessId) {
+    showProcess(processId);
+  }
+}
+
+async function loadProcesses() {
+  const list = document.getElementById('processes-list');
+  try {
+    const response = await fetch('/api/processes');
+    if (!response.ok) {
+      throw new Error(`Request failed (${response.status})`);
+    }
+    const processes = await response.json();
+
+    list.innerHTML = '';
+    if (processes.length === 0) {
+      list.innerHTML = '<div class="review-empty">No background processes. The model starts them with run_background.</div>';
+      return;
+    }
+    processes.slice().reverse().forEach(proc => {
+      const item = document.createElement('div');
+      item.className = 'review-item' + (proc.id === activeProcessId ? ' active' : '');
+      item.dataset.processId = proc.id;
+      const ports = proc.ports ? ` · port ${proc.ports.join(', ')}` : '';
+      item.innerHTML = `
+        <div>[${proc.id}] ${escapeHtml(proc.name)}</div>
+        <div class="review-item-meta">${proc.status}${ports} · ${new Date(proc.started_at).toLocaleString()}</div>
+      `;
+      item.onclick = () => showProcess(proc.id);
+      list.appendChild(item);
+    });
+  } catch (error) {
+    list.innerHTML = `<div class="review-empty">Failed to load processes: ${escapeHtml(error.message)}</div>`;
+  }
+}
+
+// showProcess renders a process's preview links and recent output
+async function showProcess(processId) {
+  activeProcessId = processId;
+  document.querySelectorAll('#processes-list .review-item').forEach(item => {
+    item.classList.toggle('active', Number(item.dataset.processId) === processId);
+  });
+
+  const container = document.getElementById('process-details');
+  try {
+    const [procsResponse, logsResponse] = await Promise.all([
+      fetch('/api/processes'),
+      fetch(`/api/processes/${processId}/logs?lines=200`)
+    ]);
+    if (!procsResponse.ok || !logsResponse.ok) {
+      throw new Error((await logsResponse.text()) || 'Request failed');
+    }
+    const proc = (await procsResponse.json()).find(p => p.id === processId);
+    const logs = await logsResponse.json();
+    if (!proc) {
+      throw new Error('Process not found');
+    }
+
+    const embedded = container.querySelector('.process-preview-frame');
+    const embeddedSrc = embedded && proc.preview_path ? embedded.getAttribute('src') : null;
+
+    container.innerHTML = `
+      <div class="review-summary"><code>${escapeHtml(proc.command)}</code><br>${escapeHtml(proc.dir)}</div>
+      <div class="process-actions"></div>
+      <div class="process-preview"></div>
+      <pre class="process-logs"></pre>
+    `;
+
+    const actions = container.querySelector('.process-actions');
+    if (proc.preview_path) {
+      const open = document.createElement('a');
+      open.className = 'btn-secondary';
+      open.href = proc.preview_path;
+      open.target = '_blank';
+      open.textContent = 'Open preview';
+      actions.appendChild(open);
+
+      const embed = document.createElement('button');
+      embed.className = 'btn-secondary';
+      embed.textContent = 'Embed';
+      embed.onclick = () => embedProcessPreview(proc.preview_path);
+      actions.appendChild(embed);
+
+      // The app's own server, for apps that don't work under the preview path
+      const direct = document.createElement('a');
+      direct.className = 'btn-secondary';
+      direct.href = `${location.protocol}//${location.hostname}:${proc.ports[0]}/`;
+      direct.target = '_blank';
+      direct.textContent = `Port ${proc.ports[0]}`;
+      actions.appendChild(direct);
+    }
+    if (proc.status === 'running') {
+      const stop = document.createElement('button');
+      stop.className = 'btn-secondary';
+      stop.textContent = 'Stop';
+      stop.onclick = () => stopProcess(processId);
+      actions.appendChild(stop);
+    }
+    if (embeddedSrc) {
+      embedProcessPreview(embeddedSrc);
+    }
+
+    const logsEl = container.querySelector('.process-logs');
+    logsEl.textContent = logs.lines.length ? logs.lines.join('\n') : '(no output yet)';
+    logsEl.scrollTop = logsEl.scrollHeight;
+  } catch (error) {
+    container.innerHTML = `<div class="review-empty">Failed to load process: ${escapeHtml(error.message)}</div>`;
+  }
+}
+
+function embedProcessPreview(path) {
+  const preview = document.querySelector('#process-details .process-preview');
+  if (!preview) return;
+  preview.innerHTML = '';
+  const frame = document.createElement('iframe');
+  frame.className = 'process-preview-frame';
+  frame.src = path;
+  preview.appendChild(frame);
+}
+
+async function stopProcess(processId) {
+  const response = await fetch(`/api/processes/${processId}`, { method: 'DELETE' });
+  if (!response.ok) {
+    alert('Failed to stop process: ' + (await response.text()));
+  }
+  loadProcesses();
+  showProcess(processId);
+}
+
+function closeProcessesModal() {
+  document.getElementById('processes-modal').classList.remove('open');
+  document.getElementById('processes-btn').classList.remove('has-preview');
+}
+
+// Settings dialog: the user's preferences, stored by the server (see AppState.loadPreferences)
+function initializeSettingsPanel() {
+  const settingsBtn = document.getElementById('settings-btn');
+
+  applyPreferencesToPage(window.AppState.getState('preferences'));
+  window.StateEvents.on('preferencesChange', ({ newValue }) => applyPreferencesToPage(newValue));
+  window.AppState.loadPreferences();
+
+  if (window.SSEEvents) {
+    // Settings changed in another browser or tab
+    window.SSEEvents.on('settings_updated', (evt) => window.AppState.applyPreferences(evt.data));
+  }
+
+  if (!settingsBtn) {
+    return;
+  }
+  settingsBtn.addEventListener('click', () => openSettingsModal());
+  document.getElementById('settings-save').addEventListener('click', () => saveSettingsForm());
+  document.getElementById('settings-copy-permissions').addEventListener('click', () => copySessionPermissions());
+  document.getElementById('settings-clear-permissions').addEventListener('click', () => {
+    pendingPermissionDefaults = {};
+    renderPermissionDefaults();
+  });
+}
+
+// applyPreferencesToPage shows the settings that affect the whole page
+function applyPreferencesToPage(preferences) {
+  if (!preferences) return;
+
+  document.documentElement.dataset.theme = preferences.theme || 'dark';
+
+  const modelSelector = document.getElementById('model-selector');
+  if (modelSelector && preferences.default_model && modelSelector.value !== preferences.default_model &&
+      [...modelSelector.options].some(option => option.value === preferences.default_model)) {
+    modelSelector.value = preferences.default_model;
+  }
+}
+
+// Permission defaults being edited, saved with the rest of the form
+let pendingPermissionDefaults = {};
+
+function openSettingsModal() {
+  const prefs = window.AppState.getState('preferences') || {};
+  const editor = prefs.editor || {};
+
+  const modelSelect = document.getElementById('settings-default-model');
+  const modelSelector = document.getElementById('model-selector');
+  if (modelSelector && modelSelect.options.length === 0) {
+    modelSelect.innerHTML = modelSelector.innerHTML;
+  }
+  modelSelect.value = prefs.default_model || (modelSelector ? modelSelector.value : '');
+
+  document.getElementById('settings-theme').value = prefs.theme || 'dark';
+  document.getElementById('settings-auto-compact').checked = !!prefs.auto_compact;
+  document.getElementById('settings-session-recaps').checked = !!prefs.session_recaps;
+  document.getElementById('settings-compact-threshold').value = prefs.compact_threshold || 50000;
+  document.getElementById('settings-font-size').value = editor.font_size || 14;
+  document.getElementById('settings-tab-size').value = editor.tab_size || 4;
+  document.getElementById('settings-word-wrap').checked = editor.word_wrap !== false;
+  document.getElementById('settings-minimap').checked = !!editor.minimap;
+
+  pendingPermissionDefaults = Object.assign({}, prefs.permission_defaults || {});
+  renderPermissionDefaults();
+  const rules = prefs.permission_rules || [];
+  document.getElementById('settings-permission-rules').value = rules.length ? JSON.stringify(rules, null, 2) : '';
+  setSettingsStatus('');
+
+  document.getElementById('settings-modal').classList.add('open');
+}
+
+function renderPermissionDefaults() {
+  const list = document.getElementById('settings-permissions');
+  const tools = Object.keys(pendingPermissionDefaults).sort();
+  list.textContent = tools.length === 0
+    ? 'None: every tool asks first.'
+    : tools.map(tool => `${tool}: ${pendingPermissionDefaults[tool]}`).join(', ');
+}
+
+// copySessionPermissions takes the current session's tool permissions as the defaults
+async function copySessionPermissions() {
+  if (!currentSessionId) {
+    setSettingsStatus('Select a session first.', true);
+    return;
+  }
+  try {
+    const response = await fetch('/api/session/' + currentSessionId + '/tools');
+    if (!response.ok) {
+      throw new Error((await response.text()) || `Request failed (${response.status})`);
+    }
+    const tools = await response.json();
+    pendingPermissionDefaults = {};
+    tools.forEach(tool => {
+      if (!tool.enabled) {
+        pendingPermissionDefaults[tool.name] = 'denied';
+      } else if (tool.mode === 'auto') {
+        pendingPermissionDefaults[tool.name] = 'allowed';
+      }
+    });
+    renderPermissionDefaults();
+  } catch (error) {
+    setSettingsStatus('Failed to load the session\'s tools: ' + error.message, true);
+  }
+}
+
+async function saveSettingsForm() {
+  let permissionRules = [];
+  const rulesText = document.getElementById('settings-permission-rules').value.trim();
+  if (rulesText) {
+    try {
+      permissionRules = JSON.parse(rulesText);
+    } catch (error) {
+      setSettingsStatus('Permission rules are not valid JSON: ' + error.message, true);
+      return;
+    }
+  }
+
+  const changes = {
+    default_model: document.getElementById('settings-default-model').value,
+    theme: document.getElementById('settings-theme').value,
+    auto_compact: document.getElementById('settings-auto-compact').checked,
+    compact_threshold: parseInt(document.getElementById('settings-compact-threshold').value, 10) || 0,
+    session_recaps: document.getElementById('settings-session-recaps').checked,
+    permission_defaults: pendingPermissionDefaults,
+    permission_rules: permissionRules,
+    editor: {
+      font_size: parseInt(document.getElementById('settings-font-size').value, 10) || 0,
+      tab_size: parseInt(document.getElementById('settings-tab-size').value, 10) || 0,
+      word_wrap: document.getElementById('settings-word-wrap').checked,
+      minimap: document.getElementById('settings-minimap').checked
+    }
+  };
+
+  try {
+    await window.AppState.savePreferences(changes);
+    setSettingsStatus('Saved.');
+  } catch (error) {
+    setSettingsStatus(error.message, true);
+  }
+}
+
+function setSettingsStatus(text, isError) {
+  const status = document.getElementById('settings-status');
+  status.textContent = text;
+  status.classList.toggle('error', !!isError);
+}
+
+function closeSettingsModal() {
+  document.getElementById('settings-modal').classList.remove('open');
+}
+
+// User accounts, managed by admins in multi-user mode
+function initializeUsersPanel() {
+  const usersBtn = document.getElementById('users-btn');
+  if (!usersBtn) {
+    return;
+  }
+  usersBtn.addEventListener('click', () => openUsersModal());
+  document.getElementById('users-add').addEventListener('click', () => addUser());
+}
+
+async function openUsersModal() {
+  setUsersStatus('');
+  document.getElementById('users-modal').classList.add('open');
+  await loadUsers();
+}
+
+async function loadUsers() {
+  const list = document.getElementById('users-list');
+  try {
+    const response = await fetch('/api/users');
+    if (!response.ok) {
+      throw new Error((await response.text()) || `Request failed (${response.status})`);
+    }
+    const users = await response.json();
+    list.innerHTML = users.map(user => `
+      <div class="settings-row">
+        <label>${escapeHtml(user.username)}${user.is_admin ? ' (admin)' : ''}</label>
+        <button class="btn-secondary" onclick="deleteUser(${user.id}, '${escapeHtml(user.username)}')">Delete</button>
+      </div>
+    `).join('');
+  } catch (error) {
+    setUsersStatus('Failed to load users: ' + error.message, true);
+  }
+}
+
+async function addUser() {
+  const nameInput = document.getElementById('new-user-name');
+  const passwordInput = document.getElementById('new-user-password');
+  const adminInput = document.getElementById('new-user-admin');
+
+  try {
+    const response = await fetch('/api/users', {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({
+        username: nameInput.value.trim(),
+        password: passwordInput.value,
+        is_admin: adminInput.checked
+      })
+    });
+    if (!response.ok) {
+      throw new Error((await response.text()) || `Request failed (${response.status})`);
+    }
+    const user = await response.json();
+    nameInput.value = '';
+    passwordInput.value = '';
+    adminInput.checked = false;
+    setUsersStatus(`Added ${user.username}.`);
+    await loadUsers();
+  } catch (error) {
+    setUsersStatus(error.message, true);
+  }
+}
+
+async function deleteUser(id, username) {
+  if (!confirm(`Delete ${username}? Their sessions are kept for the admins.`)) {
+    return;
+  }
+  try {
+    const response = await fetch('/api/users/' + id, { method: 'DELETE' });
+    if (!response.ok) {
+      throw new Error((await response.text()) || `Request failed (${response.status})`);
+    }
+    setUsersStatus(`Deleted ${username}.`);
+    await loadUsers();
+  } catch (error) {
+    setUsersStatus(error.message, true);
+  }
+}
+
+function setUsersStatus(text, isError) {
+  const status = document.getElementById('users-status');
+  status.textContent = text;
+  status.classList.toggle('error', !!isError);
+}
+
+function closeUsersModal() {
+  document.getElementById('users-modal').classList.remove('open');
+}
+
+// Claude accounts: the header warns when one's token no longer refreshes, the input area
+// picks the session's account when there are several, and the Accounts dialog logs in
+// to more of them
+let claudeAccounts = [];
+let currentSessionAccount = '';
+const accountPollInterval = 5 * 60 * 1000;
+
+function initializeAccounts() {
+  const selector = document.getElementById('account-selector');
+  if (!selector) {
+    return;
+  }
+
+  selector.addEventListener('change', async () => {
+    if (!currentSessionId) return;
+    const account = selector.value;
+    try {
+      await setSessionAccount(currentSessionId, account);
+      currentSessionAccount = account;
+      addSystemMessageToUI('This session now uses the ' + (account ? escapeHtml(account) : 'default') + ' Claude account', 'info');
+    } catch (error) {
+      selector.value = currentSessionAccount;
+      addSystemMessageToUI('Failed to change the session\'s account: ' + escapeHtml(error.message), 'error');
+    }
+  });
+
+  const accountsBtn = document.getElementById('accounts-btn');
+  if (accountsBtn) {
+    accountsBtn.addEventListener('click', () => openAccountsModal());
+    document.getElementById('accounts-add').addEventListener('click', () => {
+      handleLogin(document.getElementById('new-account-name').value.trim());
+    });
+  }
+
+  if (window.SSEEvents) {
+    // Changed in another tab
+    window.SSEEvents.on('session_account', (evt) => {
+      if (evt.sessionId === currentSessionId) {
+        showSessionAccount(evt.data && evt.data.account);
+      }
+    });
+    window.SSEEvents.on('account_refresh_failed', (evt) => {
+      addSystemMessageToUI(`The token of the Claude account ${escapeHtml(evt.data.account)} could not be refreshed: ` +
+        'log in to it again before it expires', 'error');
+      loadAccounts();
+    });
+  }
+
+  loadAccounts();
+  setInterval(loadAccounts, accountPollInterval);
+}
+
+// loadAccounts fetches the accounts, showing the selector and the warnings they call for
+async function loadAccounts() {
+  try {
+    const response = await fetch('/api/accounts');
+    if (!response.ok) {
+      throw new Error((await response.text()) || `Request failed (${response.status})`);
+    }
+    claudeAccounts = (await response.json()) || [];
+  } catch (error) {
+    console.error('Failed to load Claude accounts:', error);
+    return;
+  }
+
+  const selector = document.getElementById('account-selector');
+  const defaultAccount = claudeAccounts.find(account => account.default);
+  selector.innerHTML = `<option value="">Default${defaultAccount ? ' (' + escapeHtml(defaultAccount.name) + ')' : ''}</option>` +
+    claudeAccounts.map(account => `<option value="${escapeHtml(account.name)}">${escapeHtml(account.name)}</option>`).join('');
+  selector.value = currentSessionAccount;
+  document.getElementById('account-selector-container').style.display = claudeAccounts.length > 1 ? '' : 'none';
+
+  const warning = document.getElementById('account-warning');
+  const failing = claudeAccounts.filter(account => account.status !== 'ok');
+  warning.textContent = failing.map(account => account.status === 'expired'
+    ? `${account.name}: expired, log in again`
+    : `${account.name}: refresh failing, expires ${new Date(account.expires_at).toLocaleTimeString()}`).join('; ');
+  warning.title = failing.map(account => account.refresh_error || '').join('\n');
+  warning.style.display = failing.length > 0 ? '' : 'none';
+
+  if (document.getElementById('accounts-modal')?.classList.contains('open')) {
+    renderAccounts();
+  }
+}
+
+// setSessionAccount sets the Claude account a session's requests use, '' for the default
+async function setSessionAccount(sessionId, account) {
+  const response = await fetch(`/api/session/${sessionId}/account`, {
+    method: 'PUT',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ account: account })
+  });
+  if (!response.ok) {
+    throw new Error((await response.text()) || `Request failed (${response.status})`);
+  }
+}
+
+// showSessionAccount sets the selector to the session's account. An account that was
+// removed shows as the default, which the session falls back to.
+function showSessionAccount(account) {
+  currentSessionAccount = account || '';
+  const selector = document.getElementById('account-selector');
+  if (!selector) return;
+  selector.value = claudeAccounts.some(a => a.name === currentSessionAccount) ? currentSessionAccount : '';
+}
+
+async function openAccountsModal() {
+  setAccountsStatus('');
+  document.getElementById('accounts-modal').classList.add('open');
+  await loadAccounts();
+}
+
+function renderAccounts() {
+  const list = document.getElementById('accounts-list');
+  if (claudeAccounts.length === 0) {
+    list.textContent = 'No accounts are logged in.';
+    return;
+  }
+  list.innerHTML = claudeAccounts.map(account => {
+    const name = escapeHtml(account.name);
+    const status = account.status === 'ok'
+      ? `token expires ${new Date(account.expires_at).toLocaleString()}, refreshed automatically`
+      : account.status === 'expired'
+        ? 'expired: log in again'
+        : `refresh failing, expires ${new Date(account.expires_at).toLocaleString()}`;
+    return `
+      <div class="settings-row">
+        <label title="${escapeHtml(account.refresh_error || '')}">${name}${account.default ? ' (default)' : ''}: ${escapeHtml(status)}</label>
+        ${account.status !== 'ok' ? `<button class="btn-secondary" onclick="handleLogin('${name}')">Log In</button>` : ''}
+        ${account.default ? '' : `<button class="btn-secondary" onclick="makeDefaultAccount('${name}')">Make Default</button>`}
+        <button class="btn-secondary" onclick="removeAccount('${name}')">Remove</button>
+      </div>
+    `;
+  }).join('');
+}
+
+async function makeDefaultAccount(name) {
+  try {
+    const response = await fetch('/api/auth/accounts/default', {
+      method: 'PUT',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ account: name })
+    });
+    if (!response.ok) {
+      throw new Error((await response.text()) || `Request failed (${response.status})`);
+    }
+    setAccountsStatus(`${name} is now the default account.`);
+    await loadAccounts();
+  } catch (error) {
+    setAccountsStatus(error.message, true);
+  }
+}
+
+async function removeAccount(name) {
+  if (!confirm(`Log out of ${name}? Sessions using it go back to the default account.`)) {
+    return;
+  }
+  try {
+    const response = await fetch('/api/auth/accounts/' + encodeURIComponent(name), { method: 'DELETE' });
+    if (!response.ok) {
+      throw new Error((await response.text()) || `Request failed (${response.status})`);
+    }
+    setAccountsStatus(`Logged out of ${name}.`);
+    await loadAccounts();
+    if (claudeAccounts.length === 0) {
+      window.location.reload();
+    }
+  } catch (error) {
+    setAccountsStatus(error.message, true);
+  }
+}
+
+function setAccountsStatus(text, isError) {
+  const status = document.getElementById('accounts-status');
+  status.textContent = text;
+  status.classList.toggle('error', !!isError);
+}
+
+function closeAccountsModal() {
+  document.getElementById('accounts-modal').classList.remove('open');
+}
+
+// Plan History functionality
+let planHistoryPage = 1;
+let planHistoryLoading = false;
+let planHistorySearch = '';
+let planHistoryStatus = '';
+
+function initializePlanHistory() {
+  const historyBtn = document.getElementById('plan-history-btn');
+  const historyPanel = document.getElementById('plan-history-panel');
+  const closeHistoryBtn = document.getElementById('close-history-btn');
+  const searchInput = document.getElementById('plan-search');
+  const statusFilter = document.getElementById('plan-status-filter');
+  const loadMoreBtn = document.getElementById('load-more-plans');
+  
+  if (!historyBtn || !historyPanel) {
+    console.log('Plan history elements not found');
+    return;
+  }
+  
+  // Toggle panel
+  historyBtn.addEventListener('click', () => {
+    historyPanel.classList.toggle('open');
+    if (historyPanel.classList.contains('open')) {
+      planHistoryPage = 1;
+      loadPlanHistory(true);
+    }
+  });
+  
+  // Close panel
+  closeHistoryBtn.addEventListener('click', () => {
+    historyPanel.classList.remove('open');
+  });
+  
+  // Search functionality
+  let searchTimeout;
+  searchInput.addEventListener('input', (e) => {
+    clearTimeout(searchTimeout);
+    planHistorySearch = e.target.value;
+    searchTimeout = setTimeout(() => {
+      planHistoryPage = 1;
+      loadPlanHistory(true);
+    }, 300);
+  });
+  
+  // Filter functionality
+  statusFilter.addEventListener('change', (e) => {
+    planHistoryStatus = e.target.value;
+    planHistoryPage = 1;
+    loadPlanHistory(true);
+  });
+  
+  // Load more
+  loadMoreBtn.addEventListener('click', () => {
+    planHistoryPage++;
+    loadPlanHistory(false);
+  });
+}
+
+async function loadPlanHistory(reset = false) {
+  if (planHistoryLoading || !currentSessionId) return;
+  
+  planHistoryLoading = true;
+  const historyList = document.getElementById('plan-history-list');
+  const loadMoreBtn = document.getElementById('load-more-plans');
+  
+  if (reset) {
+    historyList.innerHTML = '<div class="loading">Loading plan history...</div>';
+  }
+  
+  try {
+    const params = new URLSearchParams({
+      page: planHistoryPage,
       limit: 20
     });
     
@@ -1829,6 +3416,11 @@ function showPlanDetailsModal(data) {
           <div class="metric-value">${plan.steps.length}</div>
           <div class="metric-label">Total Steps</div>
         </div>
+        ${metrics.coverage_before != null ? `
+        <div class="metric-card">
+          <div class="metric-value">${formatCoverage(metrics)}</div>
+          <div class="metric-label">Test Coverage</div>
+        </div>` : ''}
       </div>
     </div>
     
@@ -2192,6 +3784,167 @@ function escapeHtml(text) {
   return div.innerHTML;
 }
 
+// initializeToolsPanel refreshes the tools tab, if it is showing, when the custom tool
+// plugins are reloaded; the session has the new tools from its next message
+function initializeToolsPanel() {
+  if (!window.SSEEvents) {
+    return;
+  }
+  window.SSEEvents.on('tools_updated', () => {
+    const toolsTab = document.getElementById('tools-tab');
+    if (toolsTab && toolsTab.classList.contains('active') && currentSessionId) {
+      loadSessionTools(currentSessionId);
+    }
+  });
+}
+
+// initializeNotifications shows the server's notifications of plans, permission requests
+// and the budget as desktop notifications while the page isn't focused. The browser is asked for permission on
+// the first click in the page, as it only asks in answer to the user.
+function initializeNotifications() {
+  if (!window.SSEEvents || !('Notification' in window)) {
+    return;
+  }
+  if (Notification.permission === 'default') {
+    document.addEventListener('click', () => Notification.requestPermission(), { once: true });
+  }
+
+  const showDesktopNotification = (title, body, sessionId) => {
+    if (Notification.permission !== 'granted' || (!document.hidden && document.hasFocus())) {
+      return false;
+    }
+    const notification = new Notification(title, { body: body, tag: `rcode-${sessionId || 'global'}` });
+    notification.onclick = () => {
+      window.focus();
+      if (sessionId && sessionId !== currentSessionId) {
+        selectSession(sessionId);
+      }
+      notification.close();
+    };
+    return true;
+  };
+
+  window.SSEEvents.on('notification', (evt) => {
+    const n = evt.data || {};
+    // Plans report their end in their own view; the budget has none
+    if (!showDesktopNotification(n.title, n.body, n.session_id) && n.event === 'budget') {
+      addSystemMessageToUI(`${escapeHtml(n.title)}: ${escapeHtml(n.body)}`, 'warning');
+    }
+  });
+}
+
+// initializeReadOnlyMode wires the header's read-only switch to the current session. A new
+// session not created yet takes the switch's state when its first message creates it.
+function initializeReadOnlyMode() {
+  const readOnlySwitch = document.getElementById('read-only-switch');
+  if (!readOnlySwitch) return;
+
+  readOnlySwitch.addEventListener('change', async () => {
+    const readOnly = readOnlySwitch.checked;
+    showReadOnlyMode(readOnly);
+    if (!currentSessionId) return;
+    try {
+      await setSessionReadOnly(currentSessionId, readOnly);
+      addSystemMessageToUI(readOnly
+        ? 'Read-only mode on: tools that change the project are denied, and shell commands ask first'
+        : 'Read-only mode off', 'info');
+    } catch (error) {
+      showReadOnlyMode(!readOnly);
+      addSystemMessageToUI('Failed to change read-only mode: ' + escapeHtml(error.message), 'error');
+    }
+  });
+
+  if (window.SSEEvents) {
+    // Turned on or off in another tab
+    window.SSEEvents.on('session_read_only', (evt) => {
+      if (evt.sessionId === currentSessionId) {
+        showReadOnlyMode(evt.data && evt.data.read_only);
+      }
+    });
+  }
+}
+
+// setSessionReadOnly turns a session's read-only mode on or off
+async function setSessionReadOnly(sessionId, readOnly) {
+  const response = await fetch(`/api/session/${sessionId}/read-only`, {
+    method: 'PUT',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ read_only: readOnly })
+  });
+  if (!response.ok) {
+    throw new Error((await response.text()) || `Request failed (${response.status})`);
+  }
+}
+
+// showReadOnlyMode sets the header's switch to the session's read-only mode, which
+// turns auto-accept mode off
+function showReadOnlyMode(readOnly) {
+  const readOnlySwitch = document.getElementById('read-only-switch');
+  if (readOnlySwitch) {
+    readOnlySwitch.checked = !!readOnly;
+  }
+  document.body.classList.toggle('read-only', !!readOnly);
+  if (readOnly) {
+    showAutoAcceptMode(false);
+  }
+}
+
+// initializeAutoAcceptMode wires the header's auto-accept switch to the current session, as
+// initializeReadOnlyMode does the read-only switch
+function initializeAutoAcceptMode() {
+  const autoAcceptSwitch = document.getElementById('auto-accept-switch');
+  if (!autoAcceptSwitch) return;
+
+  autoAcceptSwitch.addEventListener('change', async () => {
+    const autoAccept = autoAcceptSwitch.checked;
+    showAutoAcceptMode(autoAccept);
+    if (!currentSessionId) return;
+    try {
+      await setSessionAutoAccept(currentSessionId, autoAccept);
+      addSystemMessageToUI(autoAccept
+        ? 'Auto-accept mode on: tools run without asking, and a turn stops when it goes over the limits on what it changes'
+        : 'Auto-accept mode off', 'info');
+    } catch (error) {
+      showAutoAcceptMode(!autoAccept);
+      addSystemMessageToUI('Failed to change auto-accept mode: ' + escapeHtml(error.message), 'error');
+    }
+  });
+
+  if (window.SSEEvents) {
+    // Turned on or off in another tab
+    window.SSEEvents.on('session_auto_accept', (evt) => {
+      if (evt.sessionId === currentSessionId) {
+        showAutoAcceptMode(evt.data && evt.data.auto_accept);
+      }
+    });
+  }
+}
+
+// setSessionAutoAccept turns a session's auto-accept mode on or off
+async function setSessionAutoAccept(sessionId, autoAccept) {
+  const response = await fetch(`/api/session/${sessionId}/auto-accept`, {
+    method: 'PUT',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ auto_accept: autoAccept })
+  });
+  if (!response.ok) {
+    throw new Error((await response.text()) || `Request failed (${response.status})`);
+  }
+}
+
+// showAutoAcceptMode sets the header's switch to the session's auto-accept mode, which
+// turns read-only mode off
+function showAutoAcceptMode(autoAccept) {
+  const autoAcceptSwitch = document.getElementById('auto-accept-switch');
+  if (autoAcceptSwitch) {
+    autoAcceptSwitch.checked = !!autoAccept;
+  }
+  document.body.classList.toggle('auto-accept', !!autoAccept);
+  if (autoAccept) {
+    showReadOnlyMode(false);
+  }
+}
+
 // Export the loadSessionTools function to window so it can be called from fileExplorer.js
 window.loadSessionTools = loadSessionTools;
 
@@ -2726,3 +4479,281 @@ function calculateCostFromUsage(usage) {
     total: inputCost + outputCost
   };
 }
+
+// Task list panel: the checklist the model keeps for the session with todo_write, shown
+// above the chat while it has items
+function initializeTodoPanel() {
+  const header = document.getElementById('todo-header');
+  if (!header) return;
+
+  header.addEventListener('click', () => {
+    document.getElementById('todo-panel').classList.toggle('collapsed');
+  });
+
+  if (window.SSEEvents) {
+    window.SSEEvents.on('todos_updated', (evt) => {
+      if (evt.sessionId === currentSessionId) {
+        renderTodos(evt.data && evt.data.todos);
+      }
+    });
+  }
+}
+
+// loadTodos shows the current session's task list
+async function loadTodos() {
+  if (!currentSessionId) {
+    renderTodos([]);
+    return;
+  }
+  try {
+    const response = await fetch('/api/session/' + currentSessionId + '/todos');
+    if (!response.ok) {
+      throw new Error((await response.text()) || `Request failed (${response.status})`);
+    }
+    const update = await response.json();
+    renderTodos(update.todos);
+  } catch (error) {
+    console.error('Failed to load the task list:', error);
+  }
+}
+
+function renderTodos(todos) {
+  const panel = document.getElementById('todo-panel');
+  if (!panel) return;
+  todos = todos || [];
+  panel.style.display = todos.length > 0 ? '' : 'none';
+
+  const completed = todos.filter(todo => todo.status === 'completed').length;
+  document.getElementById('todo-progress').textContent = `${completed} / ${todos.length}`;
+
+  const marks = { completed: '✓', in_progress: '▶', pending: '' };
+  document.getElementById('todo-list').innerHTML = todos.map(todo => `
+    <div class="todo-item ${escapeHtml(todo.status)}">
+      <span class="todo-mark">${marks[todo.status] || ''}</span>
+      <span class="todo-content">${escapeHtml(todo.content)}</span>
+    </div>`).join('');
+}
+
+// Session notes: a scratchpad the model adds to with add_note and the user edits here.
+// Notes marked in context are sent with every request.
+let pendingNotes = null; // Notes that arrived while one was being edited
+
+function initializeNotesPanel() {
+  const notesBtn = document.getElementById('notes-btn');
+  const panel = document.getElementById('notes-panel');
+  if (!notesBtn || !panel) return;
+
+  notesBtn.addEventListener('click', () => {
+    panel.classList.toggle('open');
+    if (panel.classList.contains('open')) {
+      loadNotes();
+    }
+  });
+  document.getElementById('close-notes-btn').addEventListener('click', () => {
+    panel.classList.remove('open');
+  });
+  document.getElementById('add-note-btn').addEventListener('click', addNote);
+
+  const list = document.getElementById('notes-list');
+  list.addEventListener('change', (e) => {
+    const item = e.target.closest('.note-item');
+    if (!item) return;
+    if (e.target.classList.contains('note-in-context')) {
+      updateNote(item.dataset.id, { in_context: e.target.checked });
+    } else if (e.target.classList.contains('note-text')) {
+      updateNote(item.dataset.id, { content: e.target.value });
+    }
+  });
+  list.addEventListener('click', (e) => {
+    const item = e.target.closest('.note-item');
+    if (item && e.target.classList.contains('note-delete') && confirm('Delete this note?')) {
+      deleteNote(item.dataset.id);
+    }
+  });
+  list.addEventListener('focusout', () => {
+    if (pendingNotes) {
+      // Let the edit's change event go first
+      setTimeout(() => renderNotes(pendingNotes), 0);
+    }
+  });
+
+  if (window.SSEEvents) {
+    window.SSEEvents.on('notes_updated', (evt) => {
+      if (evt.sessionId === currentSessionId) {
+        renderNotes(evt.data && evt.data.notes);
+      }
+    });
+  }
+}
+
+// loadNotes shows the current session's notes
+async function loadNotes() {
+  if (!currentSessionId) {
+    renderNotes([]);
+    return;
+  }
+  try {
+    const response = await fetch('/api/session/' + currentSessionId + '/notes');
+    if (!response.ok) {
+      throw new Error((await response.text()) || `Request failed (${response.status})`);
+    }
+    renderNotes(await response.json());
+  } catch (error) {
+    console.error('Failed to load notes:', error);
+  }
+}
+
+async function addNote() {
+  const input = document.getElementById('note-input');
+  const content = input.value.trim();
+  if (!content) return;
+  if (!currentSessionId) {
+    alert('Start or select a session first.');
+    return;
+  }
+  try {
+    const response = await fetch('/api/session/' + currentSessionId + '/notes', {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ content })
+    });
+    if (!response.ok) {
+      throw new Error((await response.text()) || `Request failed (${response.status})`);
+    }
+    input.value = '';
+  } catch (error) {
+    alert('Failed to add the note: ' + error.message);
+  }
+}
+
+async function updateNote(id, changes) {
+  try {
+    const response = await fetch('/api/session/' + currentSessionId + '/notes/' + id, {
+      method: 'PUT',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(changes)
+    });
+    if (!response.ok) {
+      throw new Error((await response.text()) || `Request failed (${response.status})`);
+    }
+  } catch (error) {
+    alert('Failed to save the note: ' + error.message);
+    loadNotes();
+  }
+}
+
+async function deleteNote(id) {
+  try {
+    const response = await fetch('/api/session/' + currentSessionId + '/notes/' + id, { method: 'DELETE' });
+    if (!response.ok) {
+      throw new Error((await response.text()) || `Request failed (${response.status})`);
+    }
+  } catch (error) {
+    alert('Failed to delete the note: ' + error.message);
+  }
+}
+
+function renderNotes(notes) {
+  const list = document.getElementById('notes-list');
+  if (!list) return;
+  notes = notes || [];
+
+  // Don't pull a note from under the user while they edit it
+  if (list.contains(document.activeElement)) {
+    pendingNotes = notes;
+    return;
+  }
+  pendingNotes = null;
+
+  if (notes.length === 0) {
+    list.innerHTML = '<div class="notes-empty">No notes yet. The model adds them with add_note, or add your own above.</div>';
+    return;
+  }
+  list.innerHTML = notes.map(note => `
+    <div class="note-item${note.in_context ? ' in-context' : ''}" data-id="${note.id}">
+      <textarea class="note-text" rows="${Math.min(12, note.content.split('\n').length + 1)}" spellcheck="false">${escapeHtml(note.content)}</textarea>
+      <div class="note-actions">
+        <label title="Send this note with every request">
+          <input type="checkbox" class="note-in-context"${note.in_context ? ' checked' : ''}> In context
+        </label>
+        <span class="note-date">${escapeHtml(new Date(note.updated_at).toLocaleString())}</span>
+        <button class="btn-secondary note-delete">Delete</button>
+      </div>
+    </div>`).join('');
+}
+
+// Monorepo packages: the input area scopes the session to one package of the project,
+// whose files its context is packed from, or to the whole repository
+let workspacePackages = [];
+let currentSessionPackage = '';
+
+function initializePackages() {
+  const selector = document.getElementById('package-selector');
+  if (!selector) {
+    return;
+  }
+
+  selector.addEventListener('change', async () => {
+    if (!currentSessionId) return;
+    const pkg = selector.value;
+    try {
+      await setSessionPackage(currentSessionId, pkg);
+      currentSessionPackage = pkg;
+      addSystemMessageToUI(pkg ? 'This session is now scoped to the package ' + escapeHtml(pkg)
+        : 'This session now works on the whole repository', 'info');
+    } catch (error) {
+      selector.value = currentSessionPackage;
+      addSystemMessageToUI('Failed to change the session\'s package: ' + escapeHtml(error.message), 'error');
+    }
+  });
+
+  if (window.SSEEvents) {
+    // Changed in another tab
+    window.SSEEvents.on('session_package', (evt) => {
+      if (evt.sessionId === currentSessionId) {
+        showSessionPackage(evt.data && evt.data.package);
+      }
+    });
+  }
+
+  loadPackages();
+}
+
+// loadPackages fetches the project's packages, showing the selector when there are any
+async function loadPackages() {
+  try {
+    const response = await fetch('/api/context');
+    if (!response.ok) return;
+    workspacePackages = (await response.json()).packages || [];
+  } catch (error) {
+    console.error('Failed to load workspace packages:', error);
+    return;
+  }
+
+  const selector = document.getElementById('package-selector');
+  selector.innerHTML = '<option value="">Whole repository</option>' +
+    workspacePackages.map(pkg => `<option value="${escapeHtml(pkg.path)}" title="${escapeHtml(pkg.path)}">${escapeHtml(pkg.name)}</option>`).join('');
+  selector.value = currentSessionPackage;
+  document.getElementById('package-selector-container').style.display = workspacePackages.length > 0 ? '' : 'none';
+}
+
+// setSessionPackage scopes a session to the package at path, '' for the whole repository
+async function setSessionPackage(sessionId, path) {
+  const response = await fetch(`/api/session/${sessionId}/package`, {
+    method: 'PUT',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ package: path })
+  });
+  if (!response.ok) {
+    throw new Error((await response.text()) || `Request failed (${response.status})`);
+  }
+}
+
+// showSessionPackage sets the selector to the session's package. A package that is no
+// longer in the project shows as the whole repository, which the session falls back to.
+function showSessionPackage(path) {
+  currentSessionPackage = path || '';
+  const selector = document.getElementById('package-selector');
+  if (!selector) return;
+  selector.value = workspacePackages.some(pkg => pkg.path === currentSessionPackage) ? currentSessionPackage : '';
+}
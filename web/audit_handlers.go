@@ -0,0 +1,190 @@
+package web
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/rweb"
+	"github.com/rohanthewiz/serr"
+	"rcode/config"
+	"rcode/db"
+)
+
+// auditPruneInterval is how often the audit log's retention is applied
+const auditPruneInterval = 24 * time.Hour
+
+// InitAuditRetention prunes the tool audit log now and once a day, as the retention settings ask
+func InitAuditRetention() {
+	go func() {
+		for {
+			pruneAuditLog()
+			time.Sleep(auditPruneInterval)
+		}
+	}()
+}
+
+// pruneAuditLog deletes the tool calls, and clears the output, older than the retention settings allow
+func pruneAuditLog() {
+	cfg := config.Get()
+	if cfg.AuditRetentionDays == 0 && cfg.AuditOutputDays == 0 {
+		return
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		logger.LogErr(err, "failed to get database for audit log retention")
+		return
+	}
+
+	var entriesBefore, outputsBefore time.Time
+	if cfg.AuditRetentionDays > 0 {
+		entriesBefore = time.Now().AddDate(0, 0, -cfg.AuditRetentionDays)
+	}
+	if cfg.AuditOutputDays > 0 {
+		outputsBefore = time.Now().AddDate(0, 0, -cfg.AuditOutputDays)
+	}
+
+	deleted, cleared, err := database.PruneToolUsage(entriesBefore, outputsBefore)
+	if err != nil {
+		logger.LogErr(err, "failed to prune audit log")
+		return
+	}
+	if deleted > 0 || cleared > 0 {
+		logger.Info("Pruned audit log", "deleted", strconv.FormatInt(deleted, 10), "outputsCleared", strconv.FormatInt(cleared, 10))
+	}
+}
+
+// auditToolsHandler returns the recorded tool calls of every session, newest first. The
+// session, tool, status (success or error), since, until, limit and offset query parameters
+// filter them; since and until take RFC 3339 times or dates, and until includes its date.
+// format=csv or format=json sends all of them that match as a file instead.
+func auditToolsHandler(c rweb.Context) error {
+	req := c.Request()
+	filter := db.ToolUsageFilter{
+		SessionID: req.QueryParam("session"),
+		ToolName:  req.QueryParam("tool"),
+		Status:    req.QueryParam("status"),
+		Limit:     100,
+	}
+	if filter.Status != "" && filter.Status != "success" && filter.Status != "error" {
+		return c.WriteError(serr.New("status must be success or error"), 400)
+	}
+
+	var err error
+	if filter.Since, err = parseAuditTime(req.QueryParam("since"), false); err != nil {
+		return c.WriteError(err, 400)
+	}
+	if filter.Until, err = parseAuditTime(req.QueryParam("until"), true); err != nil {
+		return c.WriteError(err, 400)
+	}
+	if limit, err := strconv.Atoi(req.QueryParam("limit")); err == nil && limit > 0 {
+		filter.Limit = limit
+	}
+	if offset, err := strconv.Atoi(req.QueryParam("offset")); err == nil && offset > 0 {
+		filter.Offset = offset
+	}
+
+	format := req.QueryParam("format")
+	switch format {
+	case "":
+	case "csv", "json":
+		// Exports are of everything the filter selects
+		filter.Limit, filter.Offset = 0, 0
+	default:
+		return c.WriteError(serr.New("format must be csv or json"), 400)
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+	usages, err := database.QueryToolUsage(filter)
+	if err != nil {
+		return c.WriteError(err, 500)
+	}
+
+	name := "tool-audit-" + time.Now().Format("20060102-150405")
+	switch format {
+	case "csv":
+		data, err := toolUsageCSV(usages)
+		if err != nil {
+			return c.WriteError(err, 500)
+		}
+		if err := rweb.File(c, name+".csv", data); err != nil {
+			return err
+		}
+		c.Response().SetHeader("Content-Type", "text/csv")
+		return nil
+	case "json":
+		data, err := json.MarshalIndent(usages, "", "  ")
+		if err != nil {
+			return c.WriteError(serr.Wrap(err, "failed to marshal audit log"), 500)
+		}
+		if err := rweb.File(c, name+".json", data); err != nil {
+			return err
+		}
+		c.Response().SetHeader("Content-Type", "application/json")
+		return nil
+	}
+	return c.WriteJSON(usages)
+}
+
+// parseAuditTime parses a since or until parameter. A date stands for its start or, for
+// an until, its end.
+func parseAuditTime(value string, until bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	day, err := time.ParseInLocation(time.DateOnly, value, time.Local)
+	if err != nil {
+		return time.Time{}, serr.New("times must be RFC 3339 or YYYY-MM-DD: " + value)
+	}
+	if until {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day, nil
+}
+
+// toolUsageCSV writes tool usage records as CSV, with a header row
+func toolUsageCSV(usages []db.ToolUsage) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write([]string{"id", "session_id", "tool_name", "status", "executed_at", "duration_ms", "error", "input", "output"}); err != nil {
+		return nil, serr.Wrap(err, "failed to write CSV header")
+	}
+	for _, u := range usages {
+		input, err := json.Marshal(u.Input)
+		if err != nil {
+			return nil, serr.Wrap(err, "failed to marshal tool input")
+		}
+		status := "success"
+		if u.Error != "" {
+			status = "error"
+		}
+		if err := w.Write([]string{
+			strconv.Itoa(u.ID),
+			u.SessionID,
+			u.ToolName,
+			status,
+			u.ExecutedAt.Format(time.RFC3339),
+			strconv.Itoa(u.DurationMs),
+			u.Error,
+			string(input),
+			u.Output,
+		}); err != nil {
+			return nil, serr.Wrap(err, "failed to write CSV row")
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, serr.Wrap(err, "failed to write CSV")
+	}
+	return buf.Bytes(), nil
+}
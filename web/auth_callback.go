@@ -93,6 +93,7 @@ func generateAuthCallbackHTML() string {
 			b.Div("class", "container").R(
 				b.H1().T("Authorization Required"),
 				b.P().T("After authorizing RCode on Claude.ai, you should see an authorization code. Please copy and paste it below:"),
+				b.P("id", "account-name").R(),
 				b.Input("type", "text", "id", "code-input", "class", "code-input", "placeholder", "Paste authorization code here"),
 				b.Button("id", "submit-btn", "class", "btn", "onclick", "submitCode()").T("Submit Code"),
 				b.Div("id", "error-msg", "class", "error").T("Invalid code. Please try again."),
@@ -107,6 +108,7 @@ func generateAuthCallbackHTML() string {
 				const successMsg = document.getElementById('success-msg');
 				
 				const code = codeInput.value.trim();
+				const account = new URLSearchParams(window.location.search).get('account') || '';
 				if (!code) {
 					return;
 				}
@@ -124,12 +126,12 @@ func generateAuthCallbackHTML() string {
 						headers: {
 							'Content-Type': 'application/json',
 						},
-						body: JSON.stringify({ code: code })
+						body: JSON.stringify({ code: code, account: account })
 					});
 					
 					const data = await response.json();
 					
-					if (response.ok) {
+					if (response.ok && !data.error) {
 						successMsg.style.display = 'block';
 						setTimeout(() => {
 							window.location.href = '/';
@@ -147,6 +149,12 @@ func generateAuthCallbackHTML() string {
 				}
 			}
 			
+			// Name the account being logged in, when it isn't the default one
+			const accountName = new URLSearchParams(window.location.search).get('account');
+			if (accountName) {
+				document.getElementById('account-name').textContent = 'Logging in to the Claude account "' + accountName + '".';
+			}
+			
 			// Allow Enter key to submit
 			document.getElementById('code-input').addEventListener('keypress', function(event) {
 				if (event.key === 'Enter') {
@@ -0,0 +1,163 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"rcode/config"
+	"rcode/db"
+	"rcode/tools"
+
+	"github.com/rohanthewiz/rweb"
+	"github.com/rohanthewiz/serr"
+)
+
+// autoAcceptWrittenInputs are the tool inputs holding what a call writes, counted against
+// RCODE_AUTO_MAX_BYTES
+var autoAcceptWrittenInputs = []string{"content", "new_content", "new_text", "replacement", "diff", "patch"}
+
+// forcePushPattern finds a git push forcing its update, by flag or by a +refspec
+var forcePushPattern = regexp.MustCompile(`\bgit\b[^;&|\n]*\spush\b[^;&|\n]*\s(--force\S*|-[a-zA-Z]*f[a-zA-Z]*\b|\+\S)`)
+
+// rmPattern finds rm commands, capturing their flags
+var rmPattern = regexp.MustCompile(`(?:^|[;&|(\x60]|\s)rm((?:\s+-\S+)+)`)
+
+// quotedFlagPattern finds a quoted flag or +refspec, e.g. "-rf", which the shell unquotes
+var quotedFlagPattern = regexp.MustCompile(`["']([-+][^"'\s]*)["']`)
+
+// autoAcceptBudget holds what a turn's calls in auto-accept mode have changed. Once a call
+// breaks the limits, the budget is spent: the turn's later calls are denied and the turn stops.
+type autoAcceptBudget struct {
+	mu           sync.Mutex
+	maxFiles     int
+	maxBytes     int64
+	files        map[string]bool // Files the turn's calls changed
+	bytesWritten int64
+	stopReason   string // Why the turn stops, once a call broke the limits
+}
+
+// newAutoAcceptBudget returns a turn's budget with the configured limits
+func newAutoAcceptBudget() *autoAcceptBudget {
+	cfg := config.Get()
+	return &autoAcceptBudget{maxFiles: cfg.AutoMaxFiles, maxBytes: cfg.AutoMaxBytes, files: make(map[string]bool)}
+}
+
+// take counts a call against the budget before it runs, returning why it is denied, or ""
+// to let it run
+func (b *autoAcceptBudget) take(toolUse tools.ToolUse) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.stopReason != "" {
+		return b.stopReason
+	}
+	if leavesProjectAlone(toolUse) {
+		return ""
+	}
+
+	if reason := autoAcceptForbidden(toolUse); reason != "" {
+		b.stopReason = reason
+		return reason
+	}
+
+	files := 0
+	for _, path := range toolPaths(toolUse) {
+		if !b.files[path] {
+			files++
+		}
+	}
+	if b.maxFiles > 0 && len(b.files)+files > b.maxFiles {
+		b.stopReason = fmt.Sprintf("Auto-accept mode stopped the turn: it would change more than %d files.", b.maxFiles)
+		return b.stopReason
+	}
+
+	written := int64(0)
+	for _, name := range autoAcceptWrittenInputs {
+		if value, ok := tools.GetString(toolUse.Input, name); ok {
+			written += int64(len(value))
+		}
+	}
+	if b.maxBytes > 0 && b.bytesWritten+written > b.maxBytes {
+		b.stopReason = fmt.Sprintf("Auto-accept mode stopped the turn: it would write more than %d bytes.", b.maxBytes)
+		return b.stopReason
+	}
+
+	for _, path := range toolPaths(toolUse) {
+		b.files[path] = true
+	}
+	b.bytesWritten += written
+	return ""
+}
+
+// stopped returns why the turn stops, or "" while it is within the limits
+func (b *autoAcceptBudget) stopped() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stopReason
+}
+
+// autoAcceptForbidden returns why auto-accept mode never runs a call, or "" if it may:
+// force pushes and recursive removal need the user whatever the limits. Shell commands are
+// checked for them too, for when the user allowed a shell tool outright.
+func autoAcceptForbidden(toolUse tools.ToolUse) string {
+	switch toolUse.Name {
+	case "git_push":
+		if force, _ := toolUse.Input["force"].(bool); force {
+			return "Auto-accept mode stopped the turn: it doesn't force push."
+		}
+		if force, _ := toolUse.Input["force_with_lease"].(bool); force {
+			return "Auto-accept mode stopped the turn: it doesn't force push."
+		}
+	case "remove":
+		if recursive, _ := toolUse.Input["recursive"].(bool); recursive {
+			return "Auto-accept mode stopped the turn: it doesn't remove directories recursively."
+		}
+	}
+
+	if !readOnlyShellTools[toolUse.Name] {
+		return ""
+	}
+	command, _ := tools.GetString(toolUse.Input, "command")
+	command = quotedFlagPattern.ReplaceAllString(command, "$1")
+	if forcePushPattern.MatchString(command) {
+		return "Auto-accept mode stopped the turn: it doesn't force push."
+	}
+	for _, match := range rmPattern.FindAllStringSubmatch(command, -1) {
+		for _, flag := range strings.Fields(match[1]) {
+			if flag == "--recursive" || (!strings.HasPrefix(flag, "--") && strings.ContainsAny(flag, "rR")) {
+				return "Auto-accept mode stopped the turn: it doesn't run rm -r."
+			}
+		}
+	}
+	return ""
+}
+
+// AutoAcceptUpdate turns a session's auto-accept mode on or off
+type AutoAcceptUpdate struct {
+	AutoAccept bool `json:"auto_accept"`
+}
+
+// setSessionAutoAcceptHandler turns the session's auto-accept mode on or off. The session's
+// pages are told with a session_auto_accept event.
+func setSessionAutoAcceptHandler(c rweb.Context) error {
+	sessionID := c.Request().Param("id")
+
+	var update AutoAcceptUpdate
+	if err := json.Unmarshal(c.Request().Body(), &update); err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid request body"), 400)
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+	if err := database.SetSessionAutoAccept(sessionID, update.AutoAccept); err != nil {
+		return c.WriteError(err, 404)
+	}
+
+	BroadcastSessionUpdate(sessionID, "session_auto_accept", update)
+	return c.WriteJSON(update)
+}
@@ -0,0 +1,151 @@
+package web
+
+import (
+	"strings"
+	"testing"
+
+	"rcode/tools"
+)
+
+func TestAutoAcceptForbidden(t *testing.T) {
+	tests := []struct {
+		command string
+		want    string // What the reason mentions, "" for none
+	}{
+		{"git push -f", "force push"},
+		{"git push --force", "force push"},
+		{"git push --force-with-lease origin main", "force push"},
+		{"git push -uf origin feature", "force push"},
+		{"git -C repo push -f origin", "force push"},
+		{"git push origin +main", "force push"},
+		{"git push origin '+main'", "force push"},
+		{`git push "-f"`, "force push"},
+		{"make && git push origin HEAD:main -f", "force push"},
+		{"git push origin main", ""},
+		{"git push --no-verify origin main", ""},
+		{"git commit -m 'push -f'", ""},
+
+		{"rm -r build", "rm -r"},
+		{"rm -Rf build", "rm -r"},
+		{"rm -fr build", "rm -r"},
+		{"rm --recursive build", "rm -r"},
+		{"rm -i -r build", "rm -r"},
+		{"rm -rf 'my dir'", "rm -r"},
+		{`rm "-rf" build`, "rm -r"},
+		{"rm '-r' build", "rm -r"},
+		{`rm -f "-r" build`, "rm -r"},
+		{"sudo rm -r build", "rm -r"},
+		{"cd out && rm -r build", "rm -r"},
+		{"(rm -r build)", "rm -r"},
+		{"$(rm -r build)", "rm -r"},
+		{"find . -name '*.o' | xargs rm -r", "rm -r"},
+		{"rm\t-r build", "rm -r"},
+		{"rm build/out.txt", ""},
+		{"rm -f file-r.txt", ""},
+		{"rm --force build/out.txt", ""},
+		{"go test ./... -run 'TestRm'", ""},
+	}
+	for _, tt := range tests {
+		got := autoAcceptForbidden(tools.ToolUse{Name: "bash", Input: map[string]interface{}{"command": tt.command}})
+		switch {
+		case tt.want == "" && got != "":
+			t.Errorf("%q: forbidden (%s), want allowed", tt.command, got)
+		case tt.want != "" && !strings.Contains(got, tt.want):
+			t.Errorf("%q: got %q, want a reason mentioning %q", tt.command, got, tt.want)
+		}
+	}
+
+	// The tools' own flags
+	calls := []struct {
+		toolUse tools.ToolUse
+		want    bool
+	}{
+		{tools.ToolUse{Name: "git_push", Input: map[string]interface{}{"force": true}}, true},
+		{tools.ToolUse{Name: "git_push", Input: map[string]interface{}{"force_with_lease": true}}, true},
+		{tools.ToolUse{Name: "git_push", Input: map[string]interface{}{}}, false},
+		{tools.ToolUse{Name: "remove", Input: map[string]interface{}{"path": "build", "recursive": true}}, true},
+		{tools.ToolUse{Name: "remove", Input: map[string]interface{}{"path": "out.txt"}}, false},
+		{tools.ToolUse{Name: "write_file", Input: map[string]interface{}{"path": "x", "content": "rm -rf /"}}, false},
+	}
+	for _, tt := range calls {
+		if got := autoAcceptForbidden(tt.toolUse) != ""; got != tt.want {
+			t.Errorf("%s %v: forbidden = %v, want %v", tt.toolUse.Name, tt.toolUse.Input, got, tt.want)
+		}
+	}
+}
+
+func TestAutoAcceptBudget(t *testing.T) {
+	write := func(path, content string) tools.ToolUse {
+		return tools.ToolUse{Name: "write_file", Input: map[string]interface{}{"path": "/repo/" + path, "content": content}}
+	}
+
+	budget := &autoAcceptBudget{maxFiles: 2, maxBytes: 10, files: map[string]bool{}}
+	steps := []struct {
+		toolUse tools.ToolUse
+		denied  bool
+	}{
+		{write("a.go", "1234"), false},
+		{write("a.go", "1234"), false}, // The same file again
+		{tools.ToolUse{Name: "read_file", Input: map[string]interface{}{"path": "/repo/c.go"}}, false},
+		{write("b.go", "12"), false},
+		{write("c.go", ""), true}, // A third file
+		{tools.ToolUse{Name: "read_file", Input: map[string]interface{}{"path": "/repo/a.go"}}, true}, // The turn has stopped
+	}
+	for i, step := range steps {
+		if denied := budget.take(step.toolUse) != ""; denied != step.denied {
+			t.Fatalf("step %d (%s): denied = %v, want %v", i, step.toolUse.Name, denied, step.denied)
+		}
+	}
+	if !strings.Contains(budget.stopped(), "more than 2 files") {
+		t.Errorf("stopped() = %q, want the file limit", budget.stopped())
+	}
+
+	budget = &autoAcceptBudget{maxBytes: 10, files: map[string]bool{}}
+	if reason := budget.take(write("a.go", "123456")); reason != "" {
+		t.Fatalf("first write denied: %s", reason)
+	}
+	if reason := budget.take(write("b.go", "123456")); !strings.Contains(reason, "more than 10 bytes") {
+		t.Errorf("second write: %q, want the byte limit", reason)
+	}
+
+	budget = &autoAcceptBudget{files: map[string]bool{}}
+	if reason := budget.take(tools.ToolUse{Name: "bash", Input: map[string]interface{}{"command": "rm -rf build"}}); reason == "" {
+		t.Error("an allowed bash call's rm -rf wasn't stopped")
+	}
+}
+
+// TestAutoAcceptAsksForShellTools checks that commands still ask in auto-accept mode, as
+// the budget can't count what they change
+func TestAutoAcceptAsksForShellTools(t *testing.T) {
+	session := newTestSession(t, 0)
+	if err := testDB(t).SetSessionAutoAccept(session.ID, true); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"bash", "run_task", "run_snippet"} {
+		var asked, ran bool
+		registry := tools.NewRegistry()
+		registry.Register(tools.Tool{Name: name}, toolFunc(func(map[string]interface{}) (string, error) {
+			ran = true
+			return "", nil
+		}))
+		executor := NewPermissionAwareExecutor(tools.NewContextAwareExecutor(registry, nil), testDB(t))
+		executor.SetAskHandler(func(sessionID, toolName string, params map[string]interface{}) (bool, error) {
+			asked = true
+			return false, nil
+		})
+		toolUse := tools.ToolUse{ID: "call", Name: name, Input: map[string]interface{}{"_sessionId": session.ID, "command": "sed -i s/a/b/ *.go"}}
+		executor.Execute(toolUse)
+		if ran {
+			t.Errorf("%s ran without being approved", name)
+		}
+		if !asked {
+			t.Errorf("%s didn't ask in auto-accept mode", name)
+		}
+	}
+}
+
+// toolFunc is a tool's executor made from a function
+type toolFunc func(input map[string]interface{}) (string, error)
+
+func (f toolFunc) Execute(input map[string]interface{}) (string, error) { return f(input) }
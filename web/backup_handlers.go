@@ -0,0 +1,161 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/rweb"
+	"github.com/rohanthewiz/serr"
+	"rcode/config"
+	"rcode/db"
+	"rcode/platform/backup"
+)
+
+// backupCheckInterval is how often the backup schedule is checked while scheduled backups
+// are off, so that turning them on in the config file takes effect
+const backupCheckInterval = time.Hour
+
+// backupMu keeps a scheduled backup and one asked for from running at once
+var backupMu sync.Mutex
+
+// InitBackups takes a backup whenever the latest is RCODE_BACKUP_HOURS old, and deletes
+// all but the newest RCODE_BACKUP_KEEP after each
+func InitBackups() {
+	go func() {
+		for {
+			time.Sleep(scheduledBackup())
+		}
+	}()
+}
+
+// scheduledBackup takes a backup if one is due, and returns how long until the next is
+func scheduledBackup() time.Duration {
+	cfg := config.Get()
+	if cfg.BackupHours == 0 {
+		return backupCheckInterval
+	}
+	interval := time.Duration(cfg.BackupHours) * time.Hour
+
+	database, err := db.GetDB()
+	if err != nil {
+		logger.LogErr(err, "failed to get database for scheduled backup")
+		return backupCheckInterval
+	}
+	dir := backupDir(database)
+	backups, err := backup.List(dir)
+	if err != nil {
+		logger.LogErr(err, "failed to list backups")
+		return backupCheckInterval
+	}
+	if len(backups) > 0 {
+		if age := time.Since(backups[0].CreatedAt); age < interval {
+			return interval - age
+		}
+	}
+
+	if _, err := createBackup(database); err != nil {
+		logger.LogErr(err, "scheduled backup failed")
+		return backupCheckInterval
+	}
+	return interval
+}
+
+// backupDir returns where backups are kept, RCODE_BACKUP_DIR or backups/ beside the database
+func backupDir(database *db.DB) string {
+	if dir := config.Get().BackupDir; dir != "" {
+		return dir
+	}
+	return filepath.Join(filepath.Dir(database.Location()), "backups")
+}
+
+// createBackup takes a backup and prunes the oldest beyond RCODE_BACKUP_KEEP
+func createBackup(database *db.DB) (*backup.Manifest, error) {
+	backupMu.Lock()
+	defer backupMu.Unlock()
+
+	dir := backupDir(database)
+	manifest, err := backup.Create(database, dir, backup.StateDirs())
+	if err != nil {
+		return nil, err
+	}
+	deleted, err := backup.Prune(dir, config.Get().BackupKeep)
+	if err != nil {
+		logger.LogErr(err, "failed to prune backups")
+	} else if deleted > 0 {
+		logger.Info("Pruned old backups", "deleted", strconv.Itoa(deleted))
+	}
+	return manifest, nil
+}
+
+// listBackupsHandler returns the backups, newest first
+func listBackupsHandler(c rweb.Context) error {
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+	backups, err := backup.List(backupDir(database))
+	if err != nil {
+		return c.WriteError(err, 500)
+	}
+	return c.WriteJSON(backups)
+}
+
+// createBackupHandler takes a backup now
+func createBackupHandler(c rweb.Context) error {
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+	manifest, err := createBackup(database)
+	if err != nil {
+		return c.WriteError(err, 500)
+	}
+	return c.WriteJSON(manifest)
+}
+
+// RestoreRequest names the backup to restore
+type RestoreRequest struct {
+	Name   string `json:"name"`
+	State  bool   `json:"state"`   // Also restore the ~/.rcode and ./.rcode files, now
+	DryRun bool   `json:"dry_run"` // Only verify the backup
+}
+
+// restoreHandler verifies a backup and stages it to replace the database when rcode is next
+// started; the database can't be replaced while it is open
+func restoreHandler(c rweb.Context) error {
+	var req RestoreRequest
+	if err := json.Unmarshal(c.Request().Body(), &req); err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid request body"), 400)
+	}
+	if req.Name == "" {
+		return c.WriteError(serr.New("name is required"), 400)
+	}
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	backupMu.Lock()
+	defer backupMu.Unlock()
+
+	dir := backupDir(database)
+	var manifest *backup.Manifest
+	if req.DryRun {
+		manifest, err = backup.Verify(database, dir, req.Name)
+	} else {
+		manifest, err = backup.Restore(database, dir, req.Name, backup.StateDirs(), req.State)
+	}
+	if err != nil {
+		return c.WriteError(err, http.StatusUnprocessableEntity)
+	}
+	return c.WriteJSON(map[string]interface{}{
+		"backup":           manifest,
+		"verified":         true,
+		"restart_required": !req.DryRun,
+	})
+}
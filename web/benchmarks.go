@@ -0,0 +1,66 @@
+package web
+
+import (
+	"rcode/db"
+	"rcode/tools"
+
+	"github.com/rohanthewiz/rweb"
+	"github.com/rohanthewiz/serr"
+)
+
+// dbBenchStore keeps the bench tool's runs in the session_benchmarks table
+type dbBenchStore struct{}
+
+// SaveBenchRun stores a run, setting its ID and time
+func (dbBenchStore) SaveBenchRun(sessionID string, run *tools.BenchRun) error {
+	database, err := db.GetDB()
+	if err != nil {
+		return serr.Wrap(err, "failed to get database")
+	}
+
+	stored := &db.BenchmarkRun{SessionID: sessionID, Label: run.Label, Packages: run.Packages, Bench: run.Bench,
+		Command: run.Command, Output: run.Output}
+	if err := database.AddBenchmarkRun(stored); err != nil {
+		return err
+	}
+	run.ID, run.CreatedAt = stored.ID, stored.CreatedAt
+	return nil
+}
+
+// ReadBenchRuns returns the session's runs, oldest first
+func (dbBenchStore) ReadBenchRuns(sessionID string) ([]tools.BenchRun, error) {
+	database, err := db.GetDB()
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to get database")
+	}
+	runs, err := database.GetBenchmarkRuns(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]tools.BenchRun, 0, len(runs))
+	for _, run := range runs {
+		result = append(result, tools.BenchRun{ID: run.ID, Label: run.Label, Packages: run.Packages, Bench: run.Bench,
+			Command: run.Command, Output: run.Output, CreatedAt: run.CreatedAt})
+	}
+	return result, nil
+}
+
+// InitBenchStore gives the bench tool the database
+func InitBenchStore() {
+	tools.SetBenchStore(dbBenchStore{})
+}
+
+// listSessionBenchmarksHandler returns a session's benchmark runs, oldest first
+func listSessionBenchmarksHandler(c rweb.Context) error {
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	runs, err := database.GetBenchmarkRuns(c.Request().Param("id"))
+	if err != nil {
+		return c.WriteError(err, 500)
+	}
+	return c.WriteJSON(runs)
+}
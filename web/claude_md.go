@@ -0,0 +1,266 @@
+package web
+
+import (
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"rcode/tools"
+
+	"github.com/rohanthewiz/logger"
+)
+
+const (
+	claudeMDName = "CLAUDE.md"
+
+	// agentsMDName is the instructions file other agents read, which may also be in subdirectories
+	agentsMDName = "AGENTS.md"
+
+	// maxClaudeMDImportDepth is how deep a CLAUDE.md's @imports are followed: the files it
+	// imports, the files they import, ...
+	maxClaudeMDImportDepth = 5
+
+	// maxClaudeMDSize is how much of each file is read
+	maxClaudeMDSize = 64 * 1024
+)
+
+// projectAgentFiles are the project's instruction files for other agents, read after its
+// CLAUDE.md so that projects set up for them work unchanged, with the headers they are sent under
+var projectAgentFiles = []struct{ path, header string }{
+	{agentsMDName, "Agent Instructions (AGENTS.md)"},
+	{".cursorrules", "Cursor Rules (.cursorrules)"},
+	{filepath.Join(".github", "copilot-instructions.md"), "Copilot Instructions (.github/copilot-instructions.md)"},
+}
+
+// claudeMDImport matches an @path import at the start of a line or after whitespace
+var claudeMDImport = regexp.MustCompile(`(?:^|\s)@((?:~/|\.{1,2}/|/)?[\w.\-/]+[\w\-])`)
+
+// claudeMDInlineCode matches inline code, where an @path is text rather than an import
+var claudeMDInlineCode = regexp.MustCompile("`[^`\n]*`")
+
+// claudeMDReader reads instruction files, following their @imports, and leaves out those it
+// has read before, by path or by content, so each is sent once
+type claudeMDReader struct {
+	mu          sync.Mutex
+	root        string // The project root
+	seenPaths   map[string]bool
+	seenContent map[[sha256.Size]byte]bool
+}
+
+func newClaudeMDReader(root string) *claudeMDReader {
+	return &claudeMDReader{root: root, seenPaths: make(map[string]bool), seenContent: make(map[[sha256.Size]byte]bool)}
+}
+
+var (
+	sessionClaudeMDMu sync.Mutex
+	sessionClaudeMD   = make(map[string]*claudeMDReader) // By session ID
+)
+
+// readClaudeMDFiles reads the CLAUDE.md files that apply to the whole project: the global
+// one in $HOME/.claude, those of the project's parent directories, outermost first, and the
+// project's own, each followed by the files it imports, then the project's instruction files
+// for other agents. It returns their combined content
+// with headers, and remembers what it read for the session, so that the CLAUDE.md files of
+// subdirectories sent later leave it out.
+func readClaudeMDFiles(sessionID string) string {
+	reader := newClaudeMDReader(projectRoot())
+	content := reader.readProjectFiles()
+
+	sessionClaudeMDMu.Lock()
+	sessionClaudeMD[sessionID] = reader
+	sessionClaudeMDMu.Unlock()
+	return content
+}
+
+// readProjectFiles reads the global, parent directory and project CLAUDE.md files, and the
+// project's files for other agents
+func (r *claudeMDReader) readProjectFiles() string {
+	var result strings.Builder
+
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		r.read(filepath.Join(homeDir, ".claude", claudeMDName), "User Instructions (Global)", maxClaudeMDImportDepth, &result)
+	}
+
+	var parents []string
+	for dir := filepath.Dir(r.root); ; dir = filepath.Dir(dir) {
+		parents = append(parents, dir)
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	for i := len(parents) - 1; i >= 0; i-- {
+		path := filepath.Join(parents[i], claudeMDName)
+		r.read(path, "Parent Directory Instructions ("+path+")", maxClaudeMDImportDepth, &result)
+	}
+
+	r.read(filepath.Join(r.root, claudeMDName), "Project Context (Local)", maxClaudeMDImportDepth, &result)
+	for _, file := range projectAgentFiles {
+		r.read(filepath.Join(r.root, file.path), file.header, 0, &result)
+	}
+	return result.String()
+}
+
+// read appends the file at path to out under header, then the files it imports, following
+// imports that many levels deep. Files already read, missing or empty are left out.
+func (r *claudeMDReader) read(path, header string, imports int, out *strings.Builder) {
+	path = filepath.Clean(path)
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	if r.seenPaths[path] {
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.LogErr(err, "failed to read instructions file", "path", path)
+		}
+		return
+	}
+	r.seenPaths[path] = true
+
+	content := strings.TrimSpace(string(data))
+	if len(content) > maxClaudeMDSize {
+		content = content[:maxClaudeMDSize] + "\n\n[Truncated]"
+	}
+	sum := sha256.Sum256([]byte(content))
+	if content == "" || r.seenContent[sum] {
+		return
+	}
+	r.seenContent[sum] = true
+
+	out.WriteString("## " + header + "\n")
+	out.WriteString(content)
+	out.WriteString("\n\n")
+	logger.Info("Read instructions file", "path", path, "size", len(content))
+
+	if imports <= 0 {
+		return
+	}
+	for _, imported := range claudeMDImports(content, filepath.Dir(path)) {
+		r.read(imported, "Imported ("+r.displayPath(imported)+")", imports-1, out)
+	}
+}
+
+// displayPath returns path relative to the project when it is within it
+func (r *claudeMDReader) displayPath(path string) string {
+	if rel, err := filepath.Rel(r.root, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// claudeMDImports returns the files content imports with @path, resolved against dir.
+// Imports in fenced code blocks and inline code are text, as are paths that aren't files.
+func claudeMDImports(content, dir string) []string {
+	var imports []string
+	inFence := false
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+
+		line = claudeMDInlineCode.ReplaceAllString(line, "")
+		for _, match := range claudeMDImport.FindAllStringSubmatch(line, -1) {
+			path, err := tools.ExpandPath(match[1])
+			if err != nil {
+				continue
+			}
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(dir, path)
+			}
+			if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+				imports = append(imports, path)
+			}
+		}
+	}
+	return imports
+}
+
+// subdirectoryClaudeMD returns the CLAUDE.md and AGENTS.md files, and their imports, of the
+// project subdirectories holding paths that the session hasn't been sent yet, outermost first.
+// Paths outside the project are ignored.
+func subdirectoryClaudeMD(sessionID string, paths []string) string {
+	sessionClaudeMDMu.Lock()
+	reader := sessionClaudeMD[sessionID]
+	if reader == nil {
+		// Sent at the session's start, before the server last started
+		reader = newClaudeMDReader(projectRoot())
+		reader.readProjectFiles()
+		sessionClaudeMD[sessionID] = reader
+	}
+	sessionClaudeMDMu.Unlock()
+
+	reader.mu.Lock()
+	defer reader.mu.Unlock()
+
+	var result strings.Builder
+	for _, path := range paths {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(reader.root, path)
+		}
+		dir := path
+		if info, err := os.Stat(path); err != nil || !info.IsDir() {
+			dir = filepath.Dir(path)
+		}
+		rel, err := filepath.Rel(reader.root, dir)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+
+		// From the project's first subdirectory down to the file's
+		var dirs []string
+		for d := dir; d != reader.root && d != filepath.Dir(d); d = filepath.Dir(d) {
+			dirs = append(dirs, d)
+		}
+		for i := len(dirs) - 1; i >= 0; i-- {
+			for _, name := range []string{claudeMDName, agentsMDName} {
+				file := filepath.Join(dirs[i], name)
+				imports := maxClaudeMDImportDepth
+				if name != claudeMDName {
+					imports = 0 // Only CLAUDE.md imports
+				}
+				reader.read(file, "Directory Instructions ("+reader.displayPath(file)+", for the files under "+
+					reader.displayPath(dirs[i])+"/)", imports, &result)
+			}
+		}
+	}
+	return result.String()
+}
+
+// toolPaths returns the files a tool call works on, as hooks see them
+func toolPaths(toolUse tools.ToolUse) []string {
+	if toolUse.Name == "apply_patch" {
+		patch, _ := tools.GetString(toolUse.Input, "patch")
+		return tools.PatchPaths(patch)
+	}
+	if toolUse.Name == "generate" {
+		return tools.GeneratePaths(toolUse.Input, projectRoot())
+	}
+	path, ok := tools.GetString(toolUse.Input, "path")
+	if !ok {
+		path, _ = tools.GetString(toolUse.Input, "file_path")
+	}
+	if path == "" {
+		return nil
+	}
+	if expanded, err := tools.ExpandPath(path); err == nil {
+		path = expanded
+	}
+	return []string{path}
+}
+
+// forgetSessionClaudeMD drops what was sent to a deleted session
+func forgetSessionClaudeMD(sessionID string) {
+	sessionClaudeMDMu.Lock()
+	delete(sessionClaudeMD, sessionID)
+	sessionClaudeMDMu.Unlock()
+}
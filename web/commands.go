@@ -0,0 +1,168 @@
+package web
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/rweb"
+	"rcode/db"
+)
+
+// SlashCommandDefinition describes a slash command for help output and the UI
+type SlashCommandDefinition struct {
+	Name        string `json:"name"` // Without the leading slash
+	Description string `json:"description"`
+	Usage       string `json:"usage"`            // e.g. "/memory add <text>"
+	Source      string `json:"source,omitempty"` // "project" for commands from .rcode/commands
+}
+
+// SlashCommand is a command typed into the chat as /name args.
+// Commands run on the server and either answer directly, without involving the model,
+// or expand into a prompt that is sent to the model in place of the typed command.
+type SlashCommand interface {
+	GetDefinition() SlashCommandDefinition
+	Execute(ctx *CommandContext) (*CommandResult, error)
+}
+
+// CommandContext is what a slash command runs against
+type CommandContext struct {
+	SessionID string
+	Session   *db.Session
+	DB        *db.DB
+	Args      []string // Arguments split on whitespace
+	RawArgs   string   // Everything after the command name, trimmed
+}
+
+// CommandResult is the reply shown in the chat for a slash command
+type CommandResult struct {
+	Content string `json:"content"`         // Markdown
+	Model   string `json:"model,omitempty"` // Set when the command changed the session's model
+	Prompt  string `json:"-"`               // When set, sent to the model instead of replying
+}
+
+// CommandRegistry holds the available slash commands
+type CommandRegistry struct {
+	mu       sync.RWMutex
+	commands map[string]SlashCommand
+}
+
+// commandName matches a slash command at the start of a message
+var commandName = regexp.MustCompile(`^/([a-z][a-z0-9_-]*)(?:\s|$)`)
+
+var (
+	slashCommands     *CommandRegistry
+	slashCommandsOnce sync.Once
+)
+
+// NewCommandRegistry creates an empty slash command registry
+func NewCommandRegistry() *CommandRegistry {
+	return &CommandRegistry{commands: make(map[string]SlashCommand)}
+}
+
+// Register adds a command, replacing any command with the same name
+func (r *CommandRegistry) Register(cmd SlashCommand) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commands[cmd.GetDefinition().Name] = cmd
+}
+
+// Unregister removes the command with the given name
+func (r *CommandRegistry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.commands, name)
+}
+
+// Get returns the command with the given name
+func (r *CommandRegistry) Get(name string) (SlashCommand, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	cmd, ok := r.commands[name]
+	return cmd, ok
+}
+
+// Definitions returns the definitions of all commands, sorted by name
+func (r *CommandRegistry) Definitions() []SlashCommandDefinition {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	defs := make([]SlashCommandDefinition, 0, len(r.commands))
+	for _, cmd := range r.commands {
+		defs = append(defs, cmd.GetDefinition())
+	}
+	sort.Slice(defs, func(i, j int) bool {
+		return defs[i].Name < defs[j].Name
+	})
+	return defs
+}
+
+// Parse returns the command a message invokes and its arguments.
+// Only registered names count, so messages that merely start with a path like /etc/hosts go to the model.
+func (r *CommandRegistry) Parse(content string) (SlashCommand, string, bool) {
+	content = strings.TrimSpace(content)
+	match := commandName.FindStringSubmatch(content)
+	if match == nil {
+		return nil, "", false
+	}
+
+	cmd, ok := r.Get(match[1])
+	if !ok {
+		return nil, "", false
+	}
+	return cmd, strings.TrimSpace(content[len(match[1])+1:]), true
+}
+
+// SlashCommands returns the shared registry, with the built-in and project commands registered
+func SlashCommands() *CommandRegistry {
+	slashCommandsOnce.Do(func() {
+		slashCommands = NewCommandRegistry()
+		registerBuiltinCommands(slashCommands)
+		reloadCustomCommands(slashCommands)
+	})
+	return slashCommands
+}
+
+// RegisterSlashCommand adds a command to the shared registry
+func RegisterSlashCommand(cmd SlashCommand) {
+	SlashCommands().Register(cmd)
+}
+
+// runSlashCommand executes a parsed command for a session.
+// Errors are returned as the reply, since they are the user's to fix.
+func runSlashCommand(database *db.DB, session *db.Session, cmd SlashCommand, rawArgs string) *CommandResult {
+	def := cmd.GetDefinition()
+	logger.Info("Running slash command", "session_id", session.ID, "command", def.Name)
+
+	result, err := cmd.Execute(&CommandContext{
+		SessionID: session.ID,
+		Session:   session,
+		DB:        database,
+		Args:      strings.Fields(rawArgs),
+		RawArgs:   rawArgs,
+	})
+	if err != nil {
+		logger.LogErr(err, "slash command failed", "command", def.Name)
+		return &CommandResult{Content: "**/" + def.Name + " failed:** " + err.Error()}
+	}
+	return result
+}
+
+// writeCommandResult replies to a message with a command's result
+func writeCommandResult(c rweb.Context, cmd SlashCommand, result *CommandResult) error {
+	return c.WriteJSON(MessageReply{
+		Role:    "assistant",
+		Command: cmd.GetDefinition().Name,
+		Content: result.Content,
+		Model:   result.Model,
+	})
+}
+
+// listCommandsHandler returns the available slash commands
+func listCommandsHandler(c rweb.Context) error {
+	return c.WriteJSON(map[string]interface{}{
+		"commands": SlashCommands().Definitions(),
+	})
+}
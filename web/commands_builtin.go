@@ -0,0 +1,477 @@
+package web
+
+import (
+	"bytes"
+	stdctx "context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rohanthewiz/serr"
+	"rcode/db"
+	"rcode/platform/logging"
+	"rcode/providers"
+	"rcode/tools"
+)
+
+const (
+	commandOutputLimit = 60 * 1024 // Longer command output keeps its tail
+	testCommandTimeout = 5 * time.Minute
+	logsCommandMax     = 200 // Most entries /logs shows
+	logsFieldLimit     = 200 // Longer field values, such as raw API events, are cut
+)
+
+// modelAliases are the short names /model accepts
+var modelAliases = map[string]string{
+	"opus":       "claude-opus-4-1-20250805",
+	"opus-4":     "claude-opus-4-20250514",
+	"sonnet":     "claude-sonnet-4-20250514",
+	"sonnet-3.7": "claude-3-7-sonnet-20250219",
+	"sonnet-3.5": "claude-3-5-sonnet-20241022",
+	"haiku":      "claude-3-5-haiku-20240701",
+}
+
+// registerBuiltinCommands adds the commands rcode ships with
+func registerBuiltinCommands(registry *CommandRegistry) {
+	registry.Register(&HelpCommand{Registry: registry})
+	registry.Register(&DiffCommand{})
+	registry.Register(&TestCommand{})
+	registry.Register(&PlanCommand{})
+	registry.Register(&CompactCommand{})
+	registry.Register(&ModelCommand{})
+	registry.Register(&SystemCommand{})
+	registry.Register(&MemoryCommand{})
+	registry.Register(&LogsCommand{})
+}
+
+// HelpCommand lists the available commands
+type HelpCommand struct {
+	Registry *CommandRegistry
+}
+
+// GetDefinition returns the command definition
+func (cmd *HelpCommand) GetDefinition() SlashCommandDefinition {
+	return SlashCommandDefinition{Name: "help", Description: "List the available slash commands", Usage: "/help"}
+}
+
+// Execute lists every registered command
+func (cmd *HelpCommand) Execute(ctx *CommandContext) (*CommandResult, error) {
+	var sb strings.Builder
+	sb.WriteString("**Slash commands**\n\n")
+	for _, def := range cmd.Registry.Definitions() {
+		sb.WriteString(fmt.Sprintf("- `%s` - %s\n", def.Usage, def.Description))
+	}
+	return &CommandResult{Content: sb.String()}, nil
+}
+
+// DiffCommand shows uncommitted changes in the project
+type DiffCommand struct{}
+
+// GetDefinition returns the command definition
+func (cmd *DiffCommand) GetDefinition() SlashCommandDefinition {
+	return SlashCommandDefinition{
+		Name:        "diff",
+		Description: "Show uncommitted changes; --staged for the index, --stat for a summary",
+		Usage:       "/diff [--staged] [--stat] [file]",
+	}
+}
+
+// Execute runs git diff in the project root
+func (cmd *DiffCommand) Execute(ctx *CommandContext) (*CommandResult, error) {
+	input := map[string]interface{}{"path": projectRoot()}
+	for _, arg := range ctx.Args {
+		switch arg {
+		case "--staged", "--cached":
+			input["staged"] = true
+		case "--stat":
+			input["stat"] = true
+		default:
+			input["file"] = arg
+		}
+	}
+
+	output, err := (&tools.GitDiffTool{}).Execute(input)
+	if err != nil {
+		return nil, err
+	}
+	if output == "No changes to display." {
+		return &CommandResult{Content: output}, nil
+	}
+	return &CommandResult{Content: "```diff\n" + tailOutput(output) + "\n```"}, nil
+}
+
+// TestCommand runs the project's test suite
+type TestCommand struct{}
+
+// GetDefinition returns the command definition
+func (cmd *TestCommand) GetDefinition() SlashCommandDefinition {
+	return SlashCommandDefinition{
+		Name:        "test",
+		Description: "Run the project's tests (go test, cargo test, npm test or pytest); arguments are passed through",
+		Usage:       "/test [args]",
+	}
+}
+
+// Execute runs the tests and reports the tail of their output
+func (cmd *TestCommand) Execute(ctx *CommandContext) (*CommandResult, error) {
+	root := projectRoot()
+	name, args := testRunner(root, ctx.Args)
+	if name == "" {
+		return nil, serr.New("no test runner found; expected go.mod, Cargo.toml, package.json or a Python project")
+	}
+
+	runCtx, cancel := stdctx.WithTimeout(stdctx.Background(), testCommandTimeout)
+	defer cancel()
+
+	command := exec.CommandContext(runCtx, name, args...)
+	command.Dir = root
+	var output bytes.Buffer
+	command.Stdout = &output
+	command.Stderr = &output
+
+	start := time.Now()
+	err := command.Run()
+	elapsed := time.Since(start).Round(time.Millisecond)
+
+	status := "✅ Passed"
+	switch {
+	case runCtx.Err() == stdctx.DeadlineExceeded:
+		status = fmt.Sprintf("⏱️ Timed out after %s", testCommandTimeout)
+	case err != nil:
+		if _, ok := err.(*exec.ExitError); !ok {
+			return nil, serr.Wrap(err, "failed to run "+name)
+		}
+		status = "❌ Failed (" + err.Error() + ")"
+	}
+
+	return &CommandResult{Content: fmt.Sprintf("**%s** `%s %s` in %s\n\n```\n%s\n```",
+		status, name, strings.Join(args, " "), elapsed, tailOutput(output.String()))}, nil
+}
+
+// testRunner picks the test command for the project at root
+func testRunner(root string, args []string) (string, []string) {
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(root, name))
+		return err == nil
+	}
+
+	switch {
+	case exists("go.mod"):
+		if len(args) == 0 {
+			args = []string{"./..."}
+		}
+		return "go", append([]string{"test"}, args...)
+	case exists("Cargo.toml"):
+		return "cargo", append([]string{"test"}, args...)
+	case exists("package.json"):
+		if len(args) > 0 {
+			args = append([]string{"--"}, args...)
+		}
+		return "npm", append([]string{"test"}, args...)
+	case exists("pyproject.toml"), exists("pytest.ini"), exists("setup.py"):
+		return "pytest", args
+	}
+	return "", nil
+}
+
+// PlanCommand creates a task plan for the session
+type PlanCommand struct{}
+
+// GetDefinition returns the command definition
+func (cmd *PlanCommand) GetDefinition() SlashCommandDefinition {
+	return SlashCommandDefinition{
+		Name:        "plan",
+		Description: "Break a task into a plan of steps to review and run from the plan panel",
+		Usage:       "/plan <task>",
+	}
+}
+
+// Execute creates and saves the plan
+func (cmd *PlanCommand) Execute(ctx *CommandContext) (*CommandResult, error) {
+	if ctx.RawArgs == "" {
+		return nil, serr.New("describe the task, e.g. /plan add a --verbose flag to the CLI")
+	}
+
+	plan, err := createSessionPlan(ctx.SessionID, CreatePlanRequest{Description: ctx.RawArgs})
+	if err != nil {
+		return nil, err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("**Plan created** (%d steps): %s\n\n", len(plan.Steps), plan.Description))
+	for i, step := range plan.Steps {
+		sb.WriteString(fmt.Sprintf("%d. %s", i+1, step.Description))
+		if step.Tool != "" {
+			sb.WriteString(" (`" + step.Tool + "`)")
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\nReview and execute it from the plan panel.")
+	return &CommandResult{Content: sb.String()}, nil
+}
+
+// CompactCommand summarizes older messages to free up context
+type CompactCommand struct{}
+
+// GetDefinition returns the command definition
+func (cmd *CompactCommand) GetDefinition() SlashCommandDefinition {
+	return SlashCommandDefinition{
+		Name:        "compact",
+		Description: "Summarize older messages in this session to save context",
+		Usage:       "/compact",
+	}
+}
+
+// Execute compacts the session with the default options
+func (cmd *CompactCommand) Execute(ctx *CommandContext) (*CommandResult, error) {
+	compactedMsg, err := ctx.DB.CompactSessionMessages(ctx.SessionID, db.DefaultCompactionOptions())
+	if err != nil {
+		errStr := err.Error()
+		if strings.Contains(errStr, "not enough messages") || strings.Contains(errStr, "no messages in compactable range") {
+			return &CommandResult{Content: "Nothing to compact yet: " + errStr}, nil
+		}
+		return nil, serr.Wrap(err, "failed to compact messages")
+	}
+
+	BroadcastSessionUpdate(ctx.SessionID, "session_compacted", map[string]interface{}{
+		"compaction_id": compactedMsg.ID,
+	})
+
+	return &CommandResult{Content: fmt.Sprintf("**Compacted %d messages**, saving about %d tokens.",
+		len(compactedMsg.OriginalMessageIDs), compactedMsg.TokenCountBefore-compactedMsg.TokenCountAfter)}, nil
+}
+
+// ModelCommand shows or switches the session's model
+type ModelCommand struct{}
+
+// GetDefinition returns the command definition
+func (cmd *ModelCommand) GetDefinition() SlashCommandDefinition {
+	return SlashCommandDefinition{
+		Name:        "model",
+		Description: "Show or switch the model for this session (opus, sonnet, haiku, a full model ID or a local model)",
+		Usage:       "/model [name]",
+	}
+}
+
+// Execute reports the current model or stores a new one for the session
+func (cmd *ModelCommand) Execute(ctx *CommandContext) (*CommandResult, error) {
+	if len(ctx.Args) == 0 {
+		current := ctx.Session.ModelPreference
+		if current == "" {
+			current = defaultModel
+		}
+		names := make([]string, 0, len(modelAliases))
+		for alias := range modelAliases {
+			names = append(names, alias)
+		}
+		sort.Strings(names)
+		return &CommandResult{Content: fmt.Sprintf("Current model: `%s`\n\nSwitch with `/model <name>`: %s",
+			current, strings.Join(names, ", "))}, nil
+	}
+
+	model := strings.ToLower(ctx.Args[0])
+	if id, ok := modelAliases[model]; ok {
+		model = id
+	} else if providers.IsLocalModel(ctx.Args[0]) {
+		model = ctx.Args[0]
+	} else if !strings.HasPrefix(model, "claude-") {
+		return nil, serr.New("unknown model " + ctx.Args[0])
+	}
+
+	if err := ctx.DB.UpdateSessionModel(ctx.SessionID, model); err != nil {
+		return nil, err
+	}
+	return &CommandResult{Content: "Switched to `" + model + "`", Model: model}, nil
+}
+
+// SystemCommand shows or switches the session's system prompt
+type SystemCommand struct{}
+
+// GetDefinition returns the command definition
+func (cmd *SystemCommand) GetDefinition() SlashCommandDefinition {
+	return SlashCommandDefinition{
+		Name:        "system",
+		Description: "Show or switch the system prompt for this session; default goes back to the global default",
+		Usage:       "/system [name|default]",
+	}
+}
+
+// Execute reports the session's system prompt or stores another for the session
+func (cmd *SystemCommand) Execute(ctx *CommandContext) (*CommandResult, error) {
+	if len(ctx.Args) == 0 {
+		prompts, err := ctx.DB.GetSystemPrompts()
+		if err != nil {
+			return nil, err
+		}
+		state, err := sessionSystemPromptState(ctx.DB, ctx.SessionID)
+		if err != nil {
+			return nil, err
+		}
+
+		var sb strings.Builder
+		if state.Prompt == nil {
+			sb.WriteString("No system prompt beyond rcode's own.\n")
+		} else {
+			sb.WriteString("System prompt: `" + state.Prompt.Name + "`")
+			if state.ID == nil {
+				sb.WriteString(" (the default)")
+			}
+			sb.WriteString("\n\n> " + strings.ReplaceAll(state.Rendered, "\n", "\n> ") + "\n")
+		}
+		names := make([]string, 0, len(prompts))
+		for _, prompt := range prompts {
+			names = append(names, prompt.Name)
+		}
+		if len(names) > 0 {
+			sb.WriteString("\nSwitch with `/system <name>`: " + strings.Join(names, ", ") + ". Edit them at /prompts.")
+		} else {
+			sb.WriteString("\nAdd system prompts at /prompts.")
+		}
+		return &CommandResult{Content: sb.String()}, nil
+	}
+
+	if ctx.RawArgs == "default" {
+		if err := ctx.DB.SetSessionSystemPrompt(ctx.SessionID, nil); err != nil {
+			return nil, err
+		}
+		return &CommandResult{Content: "Using the default system prompt"}, nil
+	}
+
+	prompt, err := ctx.DB.GetSystemPromptByName(ctx.RawArgs)
+	if err != nil {
+		return nil, err
+	}
+	if prompt == nil {
+		return nil, serr.New("no system prompt named " + ctx.RawArgs)
+	}
+	if err := ctx.DB.SetSessionSystemPrompt(ctx.SessionID, &prompt.ID); err != nil {
+		return nil, err
+	}
+	return &CommandResult{Content: "Switched to system prompt `" + prompt.Name + "`"}, nil
+}
+
+// MemoryCommand adds to or lists the project memory
+type MemoryCommand struct{}
+
+// GetDefinition returns the command definition
+func (cmd *MemoryCommand) GetDefinition() SlashCommandDefinition {
+	return SlashCommandDefinition{
+		Name:        "memory",
+		Description: "Remember a fact about the project, optionally starting with a category (convention, decision, gotcha, note), or list what is remembered",
+		Usage:       "/memory add [category] <text> | /memory list [query]",
+	}
+}
+
+// Execute runs the add or list subcommand
+func (cmd *MemoryCommand) Execute(ctx *CommandContext) (*CommandResult, error) {
+	if len(ctx.Args) == 0 {
+		return nil, serr.New("usage: " + cmd.GetDefinition().Usage)
+	}
+
+	store := newMemoryStore(ctx.DB)
+	rest := strings.TrimSpace(strings.TrimPrefix(ctx.RawArgs, ctx.Args[0]))
+
+	switch ctx.Args[0] {
+	case "add":
+		memory := tools.Memory{Content: rest}
+		if len(ctx.Args) > 2 && db.ValidMemoryCategory(ctx.Args[1]) {
+			memory.Category = ctx.Args[1]
+			memory.Content = strings.TrimSpace(strings.TrimPrefix(rest, ctx.Args[1]))
+		}
+		if memory.Content == "" {
+			return nil, serr.New("nothing to remember; usage: /memory add [category] <text>")
+		}
+
+		id, err := store.Remember(memory, ctx.SessionID)
+		if err != nil {
+			return nil, err
+		}
+		return &CommandResult{Content: fmt.Sprintf("Remembered (#%d): %s", id, memory.Content)}, nil
+
+	case "list":
+		memories, err := store.Recall(rest, "", 20)
+		if err != nil {
+			return nil, err
+		}
+		if len(memories) == 0 {
+			return &CommandResult{Content: "No project memories yet."}, nil
+		}
+		return &CommandResult{Content: "**Project memory**\n\n" + tools.FormatMemories(memories)}, nil
+	}
+
+	return nil, serr.New("unknown subcommand " + ctx.Args[0] + "; usage: " + cmd.GetDefinition().Usage)
+}
+
+// LogsCommand shows the server's latest log entries for the session
+type LogsCommand struct{}
+
+// GetDefinition returns the command definition
+func (cmd *LogsCommand) GetDefinition() SlashCommandDefinition {
+	return SlashCommandDefinition{
+		Name:        "logs",
+		Description: "Show this session's latest server log entries, optionally only those at a level or above",
+		Usage:       "/logs [count] [debug|info|warn|error]",
+	}
+}
+
+// Execute lists the session's entries, oldest first
+func (cmd *LogsCommand) Execute(ctx *CommandContext) (*CommandResult, error) {
+	filter := logging.Filter{SessionID: ctx.SessionID, Limit: 20}
+	for _, arg := range ctx.Args {
+		if n, err := strconv.Atoi(arg); err == nil && n > 0 {
+			filter.Limit = min(n, logsCommandMax)
+			continue
+		}
+		switch arg {
+		case "debug", "info", "warn", "error":
+			filter.Level = arg
+		default:
+			return nil, serr.New("usage: " + cmd.GetDefinition().Usage)
+		}
+	}
+
+	entries := logging.Tail(filter)
+	if len(entries) == 0 {
+		return &CommandResult{Content: "No log entries for this session."}, nil
+	}
+	var sb strings.Builder
+	sb.WriteString("**Session log**\n\n```\n")
+	for _, e := range entries {
+		sb.WriteString(fmt.Sprintf("%s %-5s %s", e.Time.Format("15:04:05.000"), strings.ToUpper(e.Level), e.Message))
+		keys := make([]string, 0, len(e.Fields))
+		for key := range e.Fields {
+			if key != "session_id" {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value := e.Fields[key]
+			if len(value) > logsFieldLimit {
+				value = value[:logsFieldLimit] + "..."
+			}
+			sb.WriteString(" " + key + "=" + strconv.Quote(value))
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("```")
+	return &CommandResult{Content: sb.String()}, nil
+}
+
+// tailOutput keeps the end of long command output, where failures are reported
+func tailOutput(output string) string {
+	output = strings.TrimRight(output, "\n")
+	if len(output) <= commandOutputLimit {
+		return output
+	}
+	cut := output[len(output)-commandOutputLimit:]
+	if i := strings.Index(cut, "\n"); i >= 0 {
+		cut = cut[i+1:]
+	}
+	return "... (earlier output omitted)\n" + cut
+}
@@ -0,0 +1,64 @@
+package web
+
+import (
+	"encoding/json"
+
+	"rcode/tools"
+
+	"github.com/rohanthewiz/rweb"
+	"github.com/rohanthewiz/serr"
+)
+
+// CommitMessageRequest asks for a commit message for the staged changes
+type CommitMessageRequest struct {
+	Hint    string `json:"hint,omitempty"`    // What the change is for
+	Commit  bool   `json:"commit,omitempty"`  // Commit the staged changes too
+	Message string `json:"message,omitempty"` // Commit with this message instead of generating one
+}
+
+// InitCommitMessageModel lets the git_commit_message tool and endpoint ask the model for
+// messages, and generate_tests ask it for tests
+func InitCommitMessageModel() {
+	tools.SetCommitMessageModel(newAnthropicModelClient())
+	tools.SetTestGenerationModel(newAnthropicModelClient())
+}
+
+// commitMessageHandler generates a commit message for the project's staged changes.
+// With commit set it also commits them, using message when the user has edited one.
+func commitMessageHandler(c rweb.Context) error {
+	var req CommitMessageRequest
+	if body := c.Request().Body(); len(body) > 0 {
+		if err := json.Unmarshal(body, &req); err != nil {
+			return c.WriteError(serr.Wrap(err, "invalid request body"), 400)
+		}
+	}
+
+	root := projectRoot()
+	message := req.Message
+	if message == "" {
+		generated, err := tools.GenerateCommitMessage(root, req.Hint)
+		if err != nil {
+			status := 400
+			if tools.IsRetryableError(err) {
+				status = 502
+			}
+			return c.WriteError(err, status)
+		}
+		message = generated
+	}
+
+	response := map[string]interface{}{
+		"message":   message,
+		"committed": false,
+	}
+	if req.Commit {
+		output, err := (&tools.GitCommitTool{}).Execute(map[string]interface{}{"path": root, "message": message})
+		if err != nil {
+			return c.WriteError(serr.Wrap(err, "failed to commit"), 400)
+		}
+		response["committed"] = true
+		response["output"] = output
+	}
+
+	return c.WriteJSON(response)
+}
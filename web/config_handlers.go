@@ -0,0 +1,17 @@
+package web
+
+import (
+	"rcode/config"
+
+	"github.com/rohanthewiz/rweb"
+)
+
+// getConfigHandler returns the effective settings, with tokens masked, where each one
+// came from, and the config files they were read from
+func getConfigHandler(c rweb.Context) error {
+	return c.WriteJSON(map[string]interface{}{
+		"settings": config.Get().Redacted(),
+		"sources":  config.Sources(),
+		"files":    config.Files(),
+	})
+}
@@ -5,7 +5,9 @@ import (
 	"os"
 	"strconv"
 
+	"rcode/config"
 	"rcode/context"
+	"rcode/db"
 	"rcode/tools"
 
 	"github.com/rohanthewiz/logger"
@@ -31,10 +33,81 @@ func GetContextManager() *context.Manager {
 		if _, err := globalContextManager.ScanProject(workDir); err != nil {
 			logger.LogErr(err, "failed to scan project on startup")
 		}
+
+		enableSemanticScoring(globalContextManager)
 	}
 	return globalContextManager
 }
 
+// InitContextScanning applies the configured scan limits and reports scan progress over SSE
+func InitContextScanning() {
+	cfg := config.Get()
+
+	opts := context.DefaultScanOptions()
+	opts.MaxFiles = cfg.ScanMaxFiles
+	opts.MaxFileSize = cfg.ScanMaxFileSize
+	opts.Progress = func(progress context.ScanProgress) {
+		broadcastJSON("context_scan_progress", progress)
+		if progress.Done && progress.Truncated {
+			logger.Warn("Project scan stopped at the file limit", "root", progress.RootPath,
+				"files", progress.FilesFound, "limit", cfg.ScanMaxFiles)
+		}
+	}
+
+	context.SetDefaultScanOptions(opts)
+}
+
+// refreshSemanticIndexHandler embeds new and changed project files for semantic search
+func refreshSemanticIndexHandler(c rweb.Context) error {
+	index := getSemanticIndex()
+	if index == nil {
+		return c.WriteError(serr.New("semantic search is off; set RCODE_EMBEDDINGS_PROVIDER to turn it on"), 503)
+	}
+
+	stats, err := index.Refresh()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to refresh semantic index"), 500)
+	}
+
+	return c.WriteJSON(stats)
+}
+
+// getSessionContextHandler returns the project files packed for a session
+func getSessionContextHandler(c rweb.Context) error {
+	sessionID := c.Request().Param("id")
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	sc, err := database.GetSessionContext(sessionID)
+	if err != nil {
+		return c.WriteError(err, 500)
+	}
+	if sc == nil {
+		return c.WriteJSON(map[string]interface{}{"packed": false})
+	}
+
+	return c.WriteJSON(map[string]interface{}{"packed": true, "context": sc})
+}
+
+// deleteSessionContextHandler discards a session's packed context so the next message packs it again
+func deleteSessionContextHandler(c rweb.Context) error {
+	sessionID := c.Request().Param("id")
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	if err := database.DeleteSessionContext(sessionID); err != nil {
+		return c.WriteError(err, 500)
+	}
+
+	return c.WriteJSON(map[string]bool{"success": true})
+}
+
 // getProjectContextHandler returns the current project context
 func getProjectContextHandler(c rweb.Context) error {
 	cm := GetContextManager()
@@ -59,6 +132,9 @@ func getProjectContextHandler(c rweb.Context) error {
 		"framework":   ctx.Framework,
 		"statistics":  ctx.Statistics,
 		"patterns":    ctx.Patterns,
+		"runtime":     ctx.Runtime,
+		"workspaces":  ctx.Workspaces,
+		"packages":    ctx.Packages,
 		"recent_files": ctx.RecentFiles,
 		"modified_files": func() []string {
 			files := make([]string, 0, len(ctx.ModifiedFiles))
@@ -0,0 +1,114 @@
+package web
+
+import (
+	"rcode/config"
+	"rcode/context"
+	"rcode/db"
+	"rcode/providers"
+
+	"github.com/rohanthewiz/logger"
+)
+
+// System prompt cannot be changed! Packed context is sent in a block after it.
+const systemPrompt = "You are Claude Code, Anthropic's official CLI for Claude."
+
+// sessionSystemPrompt returns the system prompt for a session's requests. The session's system
+// prompt, or the default, follows the identity line in a block of its own, rendered for the project.
+// The first time it is called for a session, the project files most relevant to task are packed
+// within the configured token budget and stored; they are then sent with every request as a cached block.
+// A session scoped to a monorepo package is told so, and its files are packed from that package.
+// The session's notes marked in context come last.
+func sessionSystemPrompt(database *db.DB, sessionID, task string, cm *context.Manager) interface{} {
+	blocks := []providers.SystemBlock{{Type: "text", Text: systemPrompt}}
+
+	session, err := database.GetSession(sessionID)
+	if err != nil {
+		logger.LogErr(err, "failed to get session for its package")
+	}
+	pkg := sessionPackage(session, cm)
+
+	if pkg != nil {
+		blocks = append(blocks, providers.SystemBlock{Type: "text", Text: packageScopePrompt(cm, pkg)})
+	}
+
+	custom, err := database.GetSessionSystemPrompt(sessionID)
+	if err != nil {
+		logger.LogErr(err, "failed to get session system prompt")
+	} else if custom != nil {
+		if text := renderSystemPrompt(database, custom.Content, sessionID, cm); text != "" {
+			blocks = append(blocks, providers.SystemBlock{Type: "text", Text: text})
+		}
+	}
+
+	if packed := sessionPackedContext(database, sessionID, task, cm, pkg); packed != "" {
+		blocks = append(blocks, providers.SystemBlock{Type: "text", Text: packed,
+			CacheControl: &providers.CacheControl{Type: "ephemeral"}})
+	}
+
+	// The notes the user keeps in context change, so they come after the cached blocks
+	if notes := sessionNotesPrompt(database, sessionID); notes != "" {
+		blocks = append(blocks, providers.SystemBlock{Type: "text", Text: notes})
+	}
+
+	if len(blocks) == 1 {
+		return systemPrompt
+	}
+	return blocks
+}
+
+// sessionPackedContext returns the session's packed project files, packing them the first
+// time, from pkg's files when the session is scoped to it; empty when packing is off or found nothing
+func sessionPackedContext(database *db.DB, sessionID, task string, cm *context.Manager, pkg *context.WorkspacePackage) string {
+	budget := config.Get().ContextTokenBudget
+	if budget <= 0 || cm == nil || !cm.IsInitialized() {
+		return ""
+	}
+
+	sc, err := database.GetSessionContext(sessionID)
+	if err != nil {
+		logger.LogErr(err, "failed to get session context")
+		return ""
+	}
+
+	if sc == nil {
+		if sc, err = packSessionContext(database, sessionID, task, budget, cm, pkg); err != nil {
+			logger.LogErr(err, "failed to pack session context")
+			return ""
+		}
+	}
+	return sc.Content
+}
+
+// packSessionContext packs the files relevant to task and stores them for the session.
+// An empty result is stored too, so packing is not retried on every message.
+func packSessionContext(database *db.DB, sessionID, task string, budget int, cm *context.Manager, pkg *context.WorkspacePackage) (*db.SessionContext, error) {
+	scope := ""
+	if pkg != nil {
+		scope = packageDir(cm, pkg)
+	}
+	packed, err := cm.PackContext(task, budget, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]string, 0, len(packed.Files))
+	for _, file := range packed.Files {
+		files = append(files, file.Path)
+	}
+
+	sc := &db.SessionContext{
+		SessionID: sessionID,
+		Task:      task,
+		Content:   packed.Content,
+		Tokens:    packed.Tokens,
+		Budget:    budget,
+		Files:     files,
+	}
+	if err := database.SaveSessionContext(sc); err != nil {
+		return nil, err
+	}
+
+	logger.Info("Packed session context", "session_id", sessionID, "files", len(files),
+		"tokens", packed.Tokens, "budget", budget)
+	return sc, nil
+}
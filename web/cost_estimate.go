@@ -0,0 +1,54 @@
+package web
+
+import (
+	"context"
+	"fmt"
+
+	"rcode/config"
+	"rcode/providers"
+)
+
+// CostEstimate is what a turn's first request is expected to cost, before it is sent
+type CostEstimate struct {
+	Model       string  `json:"model"`
+	InputTokens int     `json:"inputTokens"`
+	Cost        float64 `json:"cost"`                // Of the input; the reply's length isn't known yet
+	Source      string  `json:"source"`              // "api" when counted by the API, "local" when estimated here
+	Threshold   float64 `json:"threshold,omitempty"` // RCODE_CONFIRM_COST, when set
+}
+
+// CostConfirmError is returned for a turn whose estimated cost is above RCODE_CONFIRM_COST
+// and wasn't confirmed. The user's message isn't kept; send it again with confirm to go ahead.
+type CostConfirmError struct {
+	Estimate CostEstimate
+}
+
+func (e *CostConfirmError) Error() string {
+	return fmt.Sprintf("the message is estimated to cost $%.4f (%d input tokens), more than RCODE_CONFIRM_COST ($%.4f); send it with confirm to go ahead",
+		e.Estimate.Cost, e.Estimate.InputTokens, e.Estimate.Threshold)
+}
+
+// estimateCost estimates the input tokens and cost of a request as RCODE_COST_ESTIMATE asks,
+// falling back to the local estimate when the API can't count them. It returns nil when
+// estimates are off.
+func estimateCost(ctx context.Context, client *providers.AnthropicClient, request providers.CreateMessageRequest, log sessionLog) *CostEstimate {
+	cfg := config.Get()
+	if cfg.CostEstimate == "off" {
+		return nil
+	}
+
+	estimate := &CostEstimate{Model: request.Model, Source: "local", Threshold: cfg.ConfirmCost}
+	if cfg.CostEstimate == "api" {
+		tokens, err := client.CountTokens(ctx, request)
+		if err == nil {
+			estimate.InputTokens, estimate.Source = tokens, "api"
+		} else {
+			log.Warn("Couldn't count tokens with the API, estimating them", "error", err.Error())
+		}
+	}
+	if estimate.Source == "local" {
+		estimate.InputTokens = providers.EstimateTokens(request)
+	}
+	estimate.Cost = usageCost(request.Model, estimate.InputTokens, 0)
+	return estimate
+}
@@ -0,0 +1,303 @@
+package web
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/serr"
+	"gopkg.in/yaml.v3"
+	"rcode/providers"
+	"rcode/tools"
+)
+
+// customCommandsDir holds project-defined commands, relative to the project root
+var customCommandsDir = filepath.Join(".rcode", "commands")
+
+// customCommandName is the form a command name must take
+var customCommandName = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+// templateParam matches {{name}} placeholders in command prompts and tool inputs
+var templateParam = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+var (
+	customCommandsMu    sync.Mutex
+	customCommandsNames []string // Names registered by the last reload
+)
+
+// CustomCommandArg is a named argument of a custom command.
+// Arguments are positional; the last one takes the rest of the line.
+type CustomCommandArg struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	Default     string `yaml:"default"`
+	Required    bool   `yaml:"required"`
+}
+
+// CustomCommandStep is one tool call in a custom command's tool sequence
+type CustomCommandStep struct {
+	Tool  string                 `yaml:"tool"`
+	Input map[string]interface{} `yaml:"input"`
+}
+
+// CustomCommand is a slash command defined by a YAML or Markdown file under .rcode/commands.
+// It expands its arguments into a prompt for the model, runs a sequence of tools, or both,
+// in which case the tool output is appended to the prompt.
+type CustomCommand struct {
+	Name        string              `yaml:"name"` // Defaults to the file name
+	Description string              `yaml:"description"`
+	Args        []CustomCommandArg  `yaml:"args"`
+	Prompt      string              `yaml:"prompt"` // The body of a Markdown file
+	Steps       []CustomCommandStep `yaml:"steps"`
+	Path        string              `yaml:"-"`
+}
+
+// GetDefinition returns the command definition
+func (cmd *CustomCommand) GetDefinition() SlashCommandDefinition {
+	usage := "/" + cmd.Name
+	for _, arg := range cmd.Args {
+		if arg.Required {
+			usage += " <" + arg.Name + ">"
+		} else {
+			usage += " [" + arg.Name + "]"
+		}
+	}
+
+	description := cmd.Description
+	if description == "" {
+		description = "Custom command from " + filepath.Base(cmd.Path)
+	}
+	return SlashCommandDefinition{Name: cmd.Name, Description: description, Usage: usage, Source: "project"}
+}
+
+// Execute runs the command's tools, if any, and expands its prompt
+func (cmd *CustomCommand) Execute(ctx *CommandContext) (*CommandResult, error) {
+	values, err := cmd.argValues(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var output strings.Builder
+	if len(cmd.Steps) > 0 {
+		ok := cmd.runSteps(ctx, values, &output)
+		if !ok || cmd.Prompt == "" {
+			return &CommandResult{Content: output.String()}, nil
+		}
+	}
+
+	prompt := expandTemplate(cmd.Prompt, values)
+	if output.Len() > 0 {
+		prompt += "\n\n## Output of /" + cmd.Name + "\n\n" + output.String()
+	}
+	return &CommandResult{Prompt: prompt}, nil
+}
+
+// argValues maps the typed arguments onto the command's declared arguments.
+// {{args}} always holds the full argument text.
+func (cmd *CustomCommand) argValues(ctx *CommandContext) (map[string]string, error) {
+	values := map[string]string{"args": ctx.RawArgs}
+
+	for i, arg := range cmd.Args {
+		value := arg.Default
+		switch {
+		case i == len(cmd.Args)-1 && i < len(ctx.Args):
+			value = strings.Join(ctx.Args[i:], " ")
+		case i < len(ctx.Args):
+			value = ctx.Args[i]
+		}
+		if value == "" && arg.Required {
+			return nil, serr.New("missing " + arg.Name + "; usage: " + cmd.GetDefinition().Usage)
+		}
+		values[arg.Name] = value
+	}
+
+	return values, nil
+}
+
+// runSteps executes the tool sequence in order, writing each result to output.
+// It stops at the first failing step and reports whether every step succeeded.
+func (cmd *CustomCommand) runSteps(ctx *CommandContext, values map[string]string, output *strings.Builder) bool {
+	_, executor := newSessionTools(ctx.DB, providers.NewAnthropicClient())
+
+	for i, step := range cmd.Steps {
+		input, _ := expandValue(step.Input, values).(map[string]interface{})
+		if input == nil {
+			input = make(map[string]interface{})
+		}
+		input["_sessionId"] = ctx.SessionID
+
+		toolUse := tools.ToolUse{
+			ID:    fmt.Sprintf("cmd_%s_%d_%d", cmd.Name, time.Now().UnixNano(), i),
+			Name:  step.Tool,
+			Input: input,
+		}
+		result, err := executor.Execute(toolUse)
+		if err != nil {
+			output.WriteString(fmt.Sprintf("**%s** ❌ %s\n", step.Tool, err.Error()))
+			return false
+		}
+
+		output.WriteString(fmt.Sprintf("**%s**\n```\n%s\n```\n", step.Tool, tailOutput(result.Content)))
+	}
+
+	return true
+}
+
+// expandTemplate replaces {{name}} placeholders that have a value; others are left as typed
+func expandTemplate(text string, values map[string]string) string {
+	return templateParam.ReplaceAllStringFunc(text, func(placeholder string) string {
+		name := templateParam.FindStringSubmatch(placeholder)[1]
+		if value, ok := values[name]; ok {
+			return value
+		}
+		return placeholder
+	})
+}
+
+// expandValue applies expandTemplate to every string within a decoded YAML value
+func expandValue(value interface{}, values map[string]string) interface{} {
+	switch v := value.(type) {
+	case string:
+		return expandTemplate(v, values)
+	case map[string]interface{}:
+		expanded := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			expanded[key] = expandValue(item, values)
+		}
+		return expanded
+	case []interface{}:
+		expanded := make([]interface{}, len(v))
+		for i, item := range v {
+			expanded[i] = expandValue(item, values)
+		}
+		return expanded
+	}
+	return value
+}
+
+// loadCustomCommands reads the command definitions in dir.
+// Files that fail to parse are logged and skipped.
+func loadCustomCommands(dir string) []*CustomCommand {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.LogErr(err, "failed to read custom commands", "dir", dir)
+		}
+		return nil
+	}
+
+	var commands []*CustomCommand
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".md" && ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+
+		cmd, err := parseCustomCommand(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			logger.LogErr(err, "failed to load custom command", "file", entry.Name())
+			continue
+		}
+		commands = append(commands, cmd)
+	}
+
+	return commands
+}
+
+// parseCustomCommand reads one command file. Markdown files hold the prompt in their body,
+// with any other fields in YAML front matter.
+func parseCustomCommand(path string) (*CustomCommand, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to read command file")
+	}
+
+	cmd := &CustomCommand{}
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".md" {
+		frontMatter, body := splitFrontMatter(data)
+		if frontMatter != "" {
+			if err := yaml.Unmarshal([]byte(frontMatter), cmd); err != nil {
+				return nil, serr.Wrap(err, "invalid front matter")
+			}
+		}
+		if strings.TrimSpace(cmd.Prompt) == "" {
+			cmd.Prompt = strings.TrimSpace(body)
+		}
+	} else if err := yaml.Unmarshal(data, cmd); err != nil {
+		return nil, serr.Wrap(err, "invalid command definition")
+	}
+
+	if cmd.Name == "" {
+		cmd.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	cmd.Name = strings.ToLower(cmd.Name)
+	cmd.Path = path
+
+	if !customCommandName.MatchString(cmd.Name) {
+		return nil, serr.New("command name must be lowercase letters, digits, - or _: " + cmd.Name)
+	}
+	if strings.TrimSpace(cmd.Prompt) == "" && len(cmd.Steps) == 0 {
+		return nil, serr.New("command " + cmd.Name + " has neither a prompt nor steps")
+	}
+	for i, step := range cmd.Steps {
+		if step.Tool == "" {
+			return nil, serr.New(fmt.Sprintf("step %d of command %s names no tool", i+1, cmd.Name))
+		}
+	}
+
+	return cmd, nil
+}
+
+// splitFrontMatter separates a leading block fenced by --- lines from the rest of a Markdown file
+func splitFrontMatter(data []byte) (string, string) {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	if !strings.HasPrefix(text, "---\n") {
+		return "", text
+	}
+
+	rest := text[len("---\n"):]
+	if strings.HasPrefix(rest, "---\n") {
+		return "", rest[len("---\n"):]
+	}
+	end := strings.Index(rest, "\n---\n")
+	if end < 0 {
+		if strings.HasSuffix(rest, "\n---") {
+			return rest[:len(rest)-len("---")], ""
+		}
+		return "", text // No closing fence, so it is all body
+	}
+	return rest[:end+1], rest[end+len("\n---\n"):]
+}
+
+// reloadCustomCommands replaces the project commands in registry with those currently
+// under .rcode/commands. Project commands cannot replace built-in ones.
+func reloadCustomCommands(registry *CommandRegistry) {
+	commands := loadCustomCommands(filepath.Join(projectRoot(), customCommandsDir))
+
+	customCommandsMu.Lock()
+	defer customCommandsMu.Unlock()
+
+	for _, name := range customCommandsNames {
+		registry.Unregister(name)
+	}
+	customCommandsNames = nil
+
+	for _, cmd := range commands {
+		if _, exists := registry.Get(cmd.Name); exists {
+			logger.Warn("Custom command has the name of an existing command, skipping", "command", cmd.Name, "file", cmd.Path)
+			continue
+		}
+		registry.Register(cmd)
+		customCommandsNames = append(customCommandsNames, cmd.Name)
+	}
+
+	if len(customCommandsNames) > 0 {
+		logger.Info("Loaded custom commands", "count", len(customCommandsNames))
+	}
+}
@@ -368,9 +368,7 @@ func markDiffViewedHandler(c rweb.Context) error {
 // getDiffPreferencesHandler retrieves user diff preferences.
 // GET /api/diff/preferences
 func getDiffPreferencesHandler(c rweb.Context) error {
-	// For now, use a default user ID
-	// In the future, this would come from auth
-	userID := "default"
+	userID := diffPreferencesUser(c)
 
 	database, err := db.GetDB()
 	if err != nil {
@@ -387,6 +385,15 @@ func getDiffPreferencesHandler(c rweb.Context) error {
 	return c.WriteJSON(prefs)
 }
 
+// diffPreferencesUser returns the user diff preferences are kept for: the signed-in
+// user in multi-user mode, otherwise "default"
+func diffPreferencesUser(c rweb.Context) string {
+	if user := currentUser(c); user != nil {
+		return strconv.Itoa(user.ID)
+	}
+	return "default"
+}
+
 // saveDiffPreferencesHandler saves user diff preferences.
 // POST /api/diff/preferences
 func saveDiffPreferencesHandler(c rweb.Context) error {
@@ -396,8 +403,7 @@ func saveDiffPreferencesHandler(c rweb.Context) error {
 		return c.WriteError(serr.Wrap(err, "invalid request body"), 400)
 	}
 
-	// For now, use a default user ID
-	prefs.UserID = "default"
+	prefs.UserID = diffPreferencesUser(c)
 
 	database, err := db.GetDB()
 	if err != nil {
@@ -1,17 +1,23 @@
 package web
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
-	"io/fs"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"rcode/db"
+	"rcode/ignore"
+	"rcode/tools"
 
 	"github.com/rohanthewiz/logger"
 	"github.com/rohanthewiz/rweb"
@@ -35,7 +41,7 @@ type FileNode struct {
 // FileExplorerService manages file system operations
 type FileExplorerService struct {
 	rootPath       string
-	ignorePatterns []string
+	ignore         *ignore.Matcher
 	cache          map[string]*FileNode
 	cacheMutex     sync.RWMutex
 	cacheTTL       time.Duration
@@ -63,66 +69,25 @@ func NewFileExplorerService(rootPath string) (*FileExplorerService, error) {
 		cache:          make(map[string]*FileNode),
 		cacheTimestamp: make(map[string]time.Time),
 		cacheTTL:       cacheTTL,
-		ignorePatterns: getIgnorePatterns(absPath),
+		ignore:         ignore.NewMatcher(absPath, defaultIgnorePatterns...),
 	}
 
 	return service, nil
 }
 
-// getIgnorePatterns reads .gitignore and .rcodeIgnore files
-func getIgnorePatterns(rootPath string) []string {
-	patterns := []string{
-		".git", ".idea", ".vscode", "node_modules", "__pycache__",
-		"*.pyc", "*.pyo", "*.pyd", ".DS_Store", "Thumbs.db",
-		"*.log", "*.tmp", "*.temp", "*.cache", "*.swp", "*.swo",
-		".env", ".env.local", ".env.*.local",
-	}
-
-	// Read .gitignore
-	gitignorePath := filepath.Join(rootPath, ".gitignore")
-	if data, err := os.ReadFile(gitignorePath); err == nil {
-		lines := strings.Split(string(data), "\n")
-		for _, line := range lines {
-			line = strings.TrimSpace(line)
-			if line != "" && !strings.HasPrefix(line, "#") {
-				patterns = append(patterns, line)
-			}
-		}
-	}
-
-	// Read .rcodeIgnore
-	rcodeIgnorePath := filepath.Join(rootPath, ".rcodeIgnore")
-	if data, err := os.ReadFile(rcodeIgnorePath); err == nil {
-		lines := strings.Split(string(data), "\n")
-		for _, line := range lines {
-			line = strings.TrimSpace(line)
-			if line != "" && !strings.HasPrefix(line, "#") {
-				patterns = append(patterns, line)
-			}
-		}
-	}
-
-	return patterns
+// defaultIgnorePatterns are hidden from the explorer in every project, before the
+// project's .gitignore and .rcodeIgnore files are applied
+var defaultIgnorePatterns = []string{
+	".git", ".idea", ".vscode", "node_modules", "__pycache__",
+	"*.pyc", "*.pyo", "*.pyd", ".DS_Store", "Thumbs.db",
+	"*.log", "*.tmp", "*.temp", "*.cache", "*.swp", "*.swo",
+	".env", ".env.local", ".env.*.local",
 }
 
-// shouldIgnore checks if a path should be ignored
-func (s *FileExplorerService) shouldIgnore(path string) bool {
-	base := filepath.Base(path)
-
-	for _, pattern := range s.ignorePatterns {
-		// Simple pattern matching (can be enhanced with proper glob matching)
-		if strings.Contains(pattern, "*") {
-			// Basic wildcard matching
-			prefix := strings.TrimSuffix(pattern, "*")
-			if strings.HasPrefix(base, prefix) {
-				return true
-			}
-		} else if base == pattern {
-			return true
-		}
-	}
-
-	return false
+// shouldIgnore checks if a path should be ignored. Callers walk the tree and skip
+// ignored directories, so only the path itself is checked.
+func (s *FileExplorerService) shouldIgnore(path string, isDir bool) bool {
+	return s.ignore.MatchEntry(path, isDir)
 }
 
 // GetTree returns the directory tree starting from a given path
@@ -210,7 +175,7 @@ func (s *FileExplorerService) buildTree(path string, maxDepth, currentDepth int)
 			childPath := filepath.Join(path, entry.Name())
 
 			// Skip ignored files
-			if s.shouldIgnore(childPath) {
+			if s.shouldIgnore(childPath, entry.IsDir()) {
 				continue
 			}
 
@@ -329,6 +294,9 @@ func (s *FileExplorerService) GetFileContent(relativePath string) (map[string]in
 	if info.IsDir() {
 		return nil, serr.New("path is a directory, not a file")
 	}
+	if tools.IsSensitivePath(fullPath) {
+		return nil, serr.New("access denied: sensitive file; allow it with RCODE_SENSITIVE_ALLOW")
+	}
 
 	// Check file size (limit to 10MB)
 	if info.Size() > 10*1024*1024 {
@@ -349,10 +317,17 @@ func (s *FileExplorerService) GetFileContent(relativePath string) (map[string]in
 		"name":     filepath.Base(fullPath),
 		"size":     info.Size(),
 		"modTime":  info.ModTime(),
+		"hash":     contentHash(content),
 		"isBinary": isBinary,
 	}
 
-	if isBinary {
+	if contentType := imageContentType(fullPath); contentType != "" {
+		// Images are shown from the image endpoint rather than as text
+		result["isImage"] = true
+		result["mediaType"] = contentType
+		result["imageUrl"] = "/api/files/image/" + filepath.ToSlash(cleanPath)
+		result["content"] = ""
+	} else if isBinary {
 		result["content"] = ""
 		result["error"] = "Binary file"
 	} else {
@@ -362,6 +337,74 @@ func (s *FileExplorerService) GetFileContent(relativePath string) (map[string]in
 	return result, nil
 }
 
+// FileVersion identifies the state of a file a client last saw, so saves over newer changes can be refused
+type FileVersion struct {
+	Hash    string    `json:"hash,omitempty"`
+	ModTime time.Time `json:"modTime,omitempty"`
+}
+
+// FileConflictError is returned by SaveFileContent when the file changed since the client's version
+type FileConflictError struct {
+	Current FileVersion
+}
+
+func (e *FileConflictError) Error() string {
+	return "file changed since it was loaded"
+}
+
+// contentHash returns the hash clients send back to prove which version of a file they edited
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// SaveFileContent overwrites an existing file with content edited in the browser.
+// The save is refused with a *FileConflictError unless base matches the file on disk:
+// by hash when base has one, otherwise by modification time.
+func (s *FileExplorerService) SaveFileContent(relativePath string, content string, base FileVersion) (*FileVersion, error) {
+	cleanPath := filepath.Clean(relativePath)
+	fullPath := filepath.Join(s.rootPath, cleanPath)
+
+	// Security check: ensure path is within root
+	if !strings.HasPrefix(fullPath, s.rootPath) {
+		return nil, serr.New("access denied: path outside project root")
+	}
+	if base.Hash == "" && base.ModTime.IsZero() {
+		return nil, serr.New("the version being edited is required")
+	}
+
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return nil, serr.Wrap(err, "file not found")
+	}
+	if info.IsDir() {
+		return nil, serr.New("path is a directory, not a file")
+	}
+
+	current, err := os.ReadFile(fullPath)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to read file")
+	}
+	currentVersion := FileVersion{Hash: contentHash(current), ModTime: info.ModTime()}
+	if (base.Hash != "" && base.Hash != currentVersion.Hash) ||
+		(base.Hash == "" && !base.ModTime.Equal(currentVersion.ModTime)) {
+		return nil, &FileConflictError{Current: currentVersion}
+	}
+
+	if err := os.WriteFile(fullPath, []byte(content), info.Mode().Perm()); err != nil {
+		return nil, serr.Wrap(err, "failed to write file")
+	}
+
+	info, err = os.Stat(fullPath)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to stat saved file")
+	}
+
+	s.clearCacheForPath(filepath.Dir(cleanPath))
+
+	return &FileVersion{Hash: contentHash([]byte(content)), ModTime: info.ModTime()}, nil
+}
+
 // isBinaryContent checks if content appears to be binary
 func isBinaryContent(content []byte) bool {
 	if len(content) == 0 {
@@ -485,6 +528,137 @@ func (s *FileExplorerService) RenameFile(oldPath, newName string) error {
 	return nil
 }
 
+// ErrDestinationExists is returned by CopyFile and MoveFile when the target exists and overwrite is off
+var ErrDestinationExists = errors.New("destination already exists")
+
+// CopyFile copies a file or directory. When destination is an existing directory the source is
+// copied into it; an empty destination duplicates the source next to itself as "name copy".
+// It returns the path of the copy.
+func (s *FileExplorerService) CopyFile(source, destination string, overwrite bool) (string, error) {
+	if destination == "" {
+		return s.duplicate(source)
+	}
+
+	fullSource, fullDest, cleanDest, err := s.transferPaths(source, destination)
+	if err != nil {
+		return "", err
+	}
+	if err := s.clearDestination(fullDest, overwrite); err != nil {
+		return "", err
+	}
+
+	if err := copyPath(fullSource, fullDest); err != nil {
+		return "", serr.Wrap(err, "failed to copy")
+	}
+
+	s.clearCacheForPath(filepath.Dir(cleanDest))
+	return cleanDest, nil
+}
+
+// MoveFile moves a file or directory, possibly to another directory. When destination is an
+// existing directory the source is moved into it. It returns the new path.
+func (s *FileExplorerService) MoveFile(source, destination string, overwrite bool) (string, error) {
+	fullSource, fullDest, cleanDest, err := s.transferPaths(source, destination)
+	if err != nil {
+		return "", err
+	}
+	if fullSource == fullDest {
+		return cleanDest, nil
+	}
+	if err := s.clearDestination(fullDest, overwrite); err != nil {
+		return "", err
+	}
+
+	if err := os.Rename(fullSource, fullDest); err != nil {
+		// Rename can't cross filesystems; fall back to copying
+		var linkErr *os.LinkError
+		if !errors.As(err, &linkErr) || !errors.Is(linkErr.Err, syscall.EXDEV) {
+			return "", serr.Wrap(err, "failed to move")
+		}
+		if err := copyPath(fullSource, fullDest); err != nil {
+			return "", serr.Wrap(err, "failed to copy across filesystems")
+		}
+		if err := os.RemoveAll(fullSource); err != nil {
+			return "", serr.Wrap(err, "copied but failed to remove the source")
+		}
+	}
+
+	s.clearCacheForPath(filepath.Dir(filepath.Clean(source)))
+	s.clearCacheForPath(filepath.Dir(cleanDest))
+	return cleanDest, nil
+}
+
+// duplicate copies source next to itself, as "name copy.ext", "name copy 2.ext" and so on
+func (s *FileExplorerService) duplicate(source string) (string, error) {
+	cleanSource := filepath.Clean(source)
+	ext := filepath.Ext(cleanSource)
+	if info, err := os.Stat(filepath.Join(s.rootPath, cleanSource)); err == nil && info.IsDir() {
+		ext = ""
+	}
+	base := strings.TrimSuffix(cleanSource, ext)
+
+	for i := 1; i < 1000; i++ {
+		candidate := base + " copy" + ext
+		if i > 1 {
+			candidate = fmt.Sprintf("%s copy %d%s", base, i, ext)
+		}
+		if _, err := os.Stat(filepath.Join(s.rootPath, candidate)); os.IsNotExist(err) {
+			return s.CopyFile(source, candidate, false)
+		}
+	}
+	return "", serr.New("too many copies already exist")
+}
+
+// transferPaths validates the source and destination of a copy or move and resolves
+// a destination directory to the path the source will take inside it
+func (s *FileExplorerService) transferPaths(source, destination string) (fullSource, fullDest, cleanDest string, err error) {
+	cleanSource := filepath.Clean(source)
+	cleanDest = filepath.Clean(destination)
+	fullSource = filepath.Join(s.rootPath, cleanSource)
+	fullDest = filepath.Join(s.rootPath, cleanDest)
+
+	// Security checks
+	if !strings.HasPrefix(fullSource, s.rootPath) || !strings.HasPrefix(fullDest, s.rootPath) {
+		return "", "", "", serr.New("access denied: path outside project root")
+	}
+	if fullSource == s.rootPath {
+		return "", "", "", serr.New("cannot copy or move the project root")
+	}
+
+	sourceInfo, err := os.Stat(fullSource)
+	if err != nil {
+		return "", "", "", serr.Wrap(err, "source file/directory not found")
+	}
+
+	if info, err := os.Stat(fullDest); err == nil && info.IsDir() && fullDest != fullSource {
+		cleanDest = filepath.Join(cleanDest, filepath.Base(cleanSource))
+		fullDest = filepath.Join(s.rootPath, cleanDest)
+	}
+
+	if sourceInfo.IsDir() && strings.HasPrefix(fullDest, fullSource+string(filepath.Separator)) {
+		return "", "", "", serr.New("cannot copy or move a directory into itself")
+	}
+	if _, err := os.Stat(filepath.Dir(fullDest)); err != nil {
+		return "", "", "", serr.Wrap(err, "destination directory not found")
+	}
+
+	return fullSource, fullDest, cleanDest, nil
+}
+
+// clearDestination makes room for a copy or move, refusing unless overwrite is set
+func (s *FileExplorerService) clearDestination(fullDest string, overwrite bool) error {
+	if _, err := os.Lstat(fullDest); err != nil {
+		return nil
+	}
+	if !overwrite {
+		return ErrDestinationExists
+	}
+	if err := os.RemoveAll(fullDest); err != nil {
+		return serr.Wrap(err, "failed to replace destination")
+	}
+	return nil
+}
+
 // DeleteFile deletes a file or directory
 func (s *FileExplorerService) DeleteFile(relativePath string) error {
 	// Validate and clean the path
@@ -559,94 +733,6 @@ func (s *FileExplorerService) clearCacheForPath(relativePath string) {
 	delete(s.cacheTimestamp, s.rootPath)
 }
 
-// SearchFiles searches for files by name or content
-func (s *FileExplorerService) SearchFiles(query string, searchContent bool) ([]FileNode, error) {
-	var results []FileNode
-	query = strings.ToLower(query)
-
-	err := filepath.WalkDir(s.rootPath, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return nil // Skip paths with errors
-		}
-
-		// Skip ignored paths
-		if s.shouldIgnore(path) {
-			if d.IsDir() {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-
-		// Check name match
-		if strings.Contains(strings.ToLower(d.Name()), query) {
-			relPath, _ := filepath.Rel(s.rootPath, path)
-			info, err := d.Info()
-			if err == nil {
-				results = append(results, FileNode{
-					Path:    relPath,
-					Name:    d.Name(),
-					IsDir:   d.IsDir(),
-					Size:    info.Size(),
-					ModTime: info.ModTime(),
-					Icon:    getFileIcon(d.Name(), d.IsDir()),
-				})
-			}
-		}
-
-		// Check content if requested and it's a file
-		if searchContent && !d.IsDir() {
-			content, err := os.ReadFile(path)
-			if err == nil && !isBinaryContent(content) {
-				if strings.Contains(strings.ToLower(string(content)), query) {
-					relPath, _ := filepath.Rel(s.rootPath, path)
-					info, _ := d.Info()
-
-					// Check if already added by name match
-					alreadyAdded := false
-					for _, r := range results {
-						if r.Path == relPath {
-							alreadyAdded = true
-							break
-						}
-					}
-
-					if !alreadyAdded {
-						results = append(results, FileNode{
-							Path:    relPath,
-							Name:    d.Name(),
-							IsDir:   false,
-							Size:    info.Size(),
-							ModTime: info.ModTime(),
-							Icon:    getFileIcon(d.Name(), false),
-						})
-					}
-				}
-			}
-		}
-
-		return nil
-	})
-
-	if err != nil {
-		return nil, serr.Wrap(err, "search failed")
-	}
-
-	// Sort results: directories first, then by name
-	sort.Slice(results, func(i, j int) bool {
-		if results[i].IsDir != results[j].IsDir {
-			return results[i].IsDir
-		}
-		return strings.ToLower(results[i].Name) < strings.ToLower(results[j].Name)
-	})
-
-	// Limit results to 100
-	if len(results) > 100 {
-		results = results[:100]
-	}
-
-	return results, nil
-}
-
 // Global file explorer service instance
 var fileExplorer *FileExplorerService
 
@@ -754,38 +840,82 @@ func getFileContentHandler(c rweb.Context) error {
 	return c.WriteJSON(content)
 }
 
-// searchFilesHandler searches for files
-func searchFilesHandler(c rweb.Context) error {
+// SaveFileRequest is the body of a save from the file viewer
+type SaveFileRequest struct {
+	Content     *string   `json:"content"`
+	BaseHash    string    `json:"baseHash"`
+	BaseModTime time.Time `json:"baseModTime"`
+	SessionID   string    `json:"sessionId"`
+}
+
+// saveFileContentHandler saves content edited in the file viewer.
+// The client names the version it edited with an If-Match header holding the hash from
+// getFileContentHandler, or baseHash / baseModTime in the body; stale saves get a 409
+// with the current version so the client can reload or overwrite.
+func saveFileContentHandler(c rweb.Context) error {
 	if fileExplorer == nil {
 		return c.WriteError(serr.New("file explorer not initialized"), 500)
 	}
 
-	var req struct {
-		Query         string `json:"query"`
-		SearchContent bool   `json:"searchContent"`
+	prefix := "/api/files/content/"
+	path := strings.TrimPrefix(c.Request().Path(), prefix)
+	if path == "" {
+		return c.WriteError(serr.New("path parameter required"), 400)
 	}
 
-	body := c.Request().Body()
-	if err := json.Unmarshal(body, &req); err != nil {
+	var req SaveFileRequest
+	if err := json.Unmarshal(c.Request().Body(), &req); err != nil {
 		return c.WriteError(serr.New("invalid request body"), 400)
 	}
+	if req.Content == nil {
+		return c.WriteError(serr.New("content is required"), 400)
+	}
 
-	if req.Query == "" {
-		return c.WriteError(serr.New("query parameter required"), 400)
+	base := FileVersion{Hash: req.BaseHash, ModTime: req.BaseModTime}
+	if ifMatch := strings.Trim(c.Request().Header("If-Match"), `"`); ifMatch != "" {
+		base.Hash = ifMatch
+	}
+	if base.Hash == "" && base.ModTime.IsZero() {
+		return c.WriteError(serr.New("If-Match or baseHash is required to save"), 428)
 	}
 
-	results, err := fileExplorer.SearchFiles(req.Query, req.SearchContent)
+	version, err := fileExplorer.SaveFileContent(path, *req.Content, base)
 	if err != nil {
-		return c.WriteError(serr.Wrap(err, "search failed"), 500)
+		var conflict *FileConflictError
+		if errors.As(err, &conflict) {
+			c.Response().SetStatus(409)
+			return c.WriteJSON(map[string]interface{}{
+				"error":   "The file changed on disk since it was loaded",
+				"current": conflict.Current,
+			})
+		}
+		return c.WriteError(serr.Wrap(err, "failed to save file"), 400)
 	}
 
+	cleanPath := filepath.Clean(path)
+	if req.SessionID != "" {
+		if database, err := db.GetDB(); err != nil {
+			logger.LogErr(err, "failed to get database")
+		} else if err := database.TrackFileAccess(req.SessionID, cleanPath, "edit"); err != nil {
+			logger.LogErr(err, "failed to record file edit")
+		}
+	}
+
+	BroadcastFileChanged(req.SessionID, cleanPath, "modified")
+
 	return c.WriteJSON(map[string]interface{}{
-		"results": results,
-		"count":   len(results),
-		"query":   req.Query,
+		"status":  "ok",
+		"path":    cleanPath,
+		"hash":    version.Hash,
+		"modTime": version.ModTime,
 	})
 }
 
+// FilePathRequest names the file an endpoint acts on
+type FilePathRequest struct {
+	Path string `json:"path"`
+}
+
 // openFileHandler tracks opened files in session
 func openFileHandler(c rweb.Context) error {
 	sessionId := c.Request().Param("id")
@@ -793,10 +923,7 @@ func openFileHandler(c rweb.Context) error {
 		return c.WriteError(serr.New("session ID required"), 400)
 	}
 
-	var req struct {
-		Path string `json:"path"`
-	}
-
+	var req FilePathRequest
 	body := c.Request().Body()
 	if err := json.Unmarshal(body, &req); err != nil {
 		return c.WriteError(serr.New("invalid request body"), 400)
@@ -864,18 +991,20 @@ func getRecentFilesHandler(c rweb.Context) error {
 	})
 }
 
+// CreateFileRequest is the body of the create endpoint
+type CreateFileRequest struct {
+	Path    string `json:"path"`
+	Type    string `json:"type"` // "file" or "directory"
+	Content string `json:"content,omitempty"`
+}
+
 // createFileHandler creates a new file or directory
 func createFileHandler(c rweb.Context) error {
 	if fileExplorer == nil {
 		return c.WriteError(serr.New("file explorer not initialized"), 500)
 	}
 
-	var req struct {
-		Path    string `json:"path"`
-		Type    string `json:"type"` // "file" or "directory"
-		Content string `json:"content,omitempty"`
-	}
-
+	var req CreateFileRequest
 	body := c.Request().Body()
 	if err := json.Unmarshal(body, &req); err != nil {
 		return c.WriteError(serr.New("invalid request body"), 400)
@@ -910,17 +1039,19 @@ func createFileHandler(c rweb.Context) error {
 	})
 }
 
+// RenameFileRequest is the body of the rename endpoint
+type RenameFileRequest struct {
+	OldPath string `json:"oldPath"`
+	NewName string `json:"newName"`
+}
+
 // renameFileHandler renames a file or directory
 func renameFileHandler(c rweb.Context) error {
 	if fileExplorer == nil {
 		return c.WriteError(serr.New("file explorer not initialized"), 500)
 	}
 
-	var req struct {
-		OldPath string `json:"oldPath"`
-		NewName string `json:"newName"`
-	}
-
+	var req RenameFileRequest
 	body := c.Request().Body()
 	if err := json.Unmarshal(body, &req); err != nil {
 		return c.WriteError(serr.New("invalid request body"), 400)
@@ -949,16 +1080,72 @@ func renameFileHandler(c rweb.Context) error {
 	})
 }
 
-// deleteFileHandler deletes a file or directory
-func deleteFileHandler(c rweb.Context) error {
+// CopyMoveRequest is the body of the copy and move endpoints
+type CopyMoveRequest struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"` // A new path, or an existing directory to put the source in
+	Overwrite   bool   `json:"overwrite"`
+}
+
+// copyFileHandler copies or duplicates a file or directory
+func copyFileHandler(c rweb.Context) error {
+	return transferFileHandler(c, false)
+}
+
+// moveFileHandler moves a file or directory
+func moveFileHandler(c rweb.Context) error {
+	return transferFileHandler(c, true)
+}
+
+// transferFileHandler handles the copy and move endpoints. An existing destination
+// gets a 409 unless overwrite is set.
+func transferFileHandler(c rweb.Context, move bool) error {
 	if fileExplorer == nil {
 		return c.WriteError(serr.New("file explorer not initialized"), 500)
 	}
 
-	var req struct {
-		Path string `json:"path"`
+	var req CopyMoveRequest
+	if err := json.Unmarshal(c.Request().Body(), &req); err != nil {
+		return c.WriteError(serr.New("invalid request body"), 400)
+	}
+	if req.Source == "" || (move && req.Destination == "") {
+		return c.WriteError(serr.New("source and destination parameters required"), 400)
+	}
+
+	var newPath string
+	var err error
+	if move {
+		newPath, err = fileExplorer.MoveFile(req.Source, req.Destination, req.Overwrite)
+	} else {
+		newPath, err = fileExplorer.CopyFile(req.Source, req.Destination, req.Overwrite)
+	}
+	if errors.Is(err, ErrDestinationExists) {
+		return c.WriteError(err, 409)
+	}
+	if err != nil {
+		return c.WriteError(err, 400)
+	}
+
+	// Broadcast file tree update events
+	if move {
+		BroadcastFileTreeUpdate("", filepath.Dir(filepath.Clean(req.Source)))
+	}
+	BroadcastFileTreeUpdate("", filepath.Dir(newPath))
+
+	return c.WriteJSON(map[string]interface{}{
+		"status":  "ok",
+		"source":  req.Source,
+		"newPath": newPath,
+	})
+}
+
+// deleteFileHandler deletes a file or directory
+func deleteFileHandler(c rweb.Context) error {
+	if fileExplorer == nil {
+		return c.WriteError(serr.New("file explorer not initialized"), 500)
 	}
 
+	var req FilePathRequest
 	body := c.Request().Body()
 	if err := json.Unmarshal(body, &req); err != nil {
 		return c.WriteError(serr.New("invalid request body"), 400)
@@ -0,0 +1,254 @@
+package web
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // Registers the GIF decoder for thumbnails
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rohanthewiz/rweb"
+	"github.com/rohanthewiz/serr"
+)
+
+const (
+	maxImageSize       = 20 * 1024 * 1024 // Largest image file served
+	maxThumbnailPixels = 40_000_000       // Larger images are served whole rather than decoded
+	maxThumbnailSize   = 1024             // Largest thumbnail edge in pixels
+	maxCachedThumbs    = 256
+)
+
+// imageContentTypes are the image files served by the image endpoint
+var imageContentTypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".svg":  "image/svg+xml",
+	".bmp":  "image/bmp",
+	".ico":  "image/x-icon",
+}
+
+// ProjectImage is an image file, or a thumbnail of one, ready to serve
+type ProjectImage struct {
+	Data        []byte
+	ContentType string
+	ModTime     time.Time
+	ETag        string
+}
+
+// thumbnailCache keeps recently generated thumbnails, keyed by path, size and modification time
+var thumbnailCache = struct {
+	sync.Mutex
+	images map[string]*ProjectImage
+}{images: make(map[string]*ProjectImage)}
+
+// imageContentType returns the content type of an image file, or "" for other files
+func imageContentType(path string) string {
+	return imageContentTypes[strings.ToLower(filepath.Ext(path))]
+}
+
+// GetImage returns an image in the project. When thumbSize is above zero, PNG, JPEG and GIF
+// images larger than that are scaled down to fit within thumbSize pixels; other formats,
+// which browsers scale themselves, are returned as they are.
+func (s *FileExplorerService) GetImage(relativePath string, thumbSize int) (*ProjectImage, error) {
+	cleanPath := filepath.Clean(relativePath)
+	fullPath := filepath.Join(s.rootPath, cleanPath)
+
+	// Security check: ensure path is within root
+	if !strings.HasPrefix(fullPath, s.rootPath) {
+		return nil, serr.New("access denied: path outside project root")
+	}
+
+	contentType := imageContentType(fullPath)
+	if contentType == "" {
+		return nil, serr.New("not an image file")
+	}
+
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return nil, serr.Wrap(err, "file not found")
+	}
+	if info.IsDir() {
+		return nil, serr.New("path is a directory, not a file")
+	}
+	if info.Size() > maxImageSize {
+		return nil, serr.New(fmt.Sprintf("image too large (max %dMB)", maxImageSize/1024/1024))
+	}
+
+	if thumbSize > maxThumbnailSize {
+		thumbSize = maxThumbnailSize
+	}
+	canThumbnail := contentType == "image/png" || contentType == "image/jpeg" || contentType == "image/gif"
+	if !canThumbnail {
+		thumbSize = 0
+	}
+
+	key := fmt.Sprintf("%s|%d|%d", cleanPath, thumbSize, info.ModTime().UnixNano())
+	if thumbSize > 0 {
+		thumbnailCache.Lock()
+		cached := thumbnailCache.images[key]
+		thumbnailCache.Unlock()
+		if cached != nil {
+			return cached, nil
+		}
+	}
+
+	data, err := os.ReadFile(fullPath)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to read file")
+	}
+
+	img := &ProjectImage{
+		Data:        data,
+		ContentType: contentType,
+		ModTime:     info.ModTime(),
+		ETag:        fmt.Sprintf(`"%x-%x-%d"`, info.ModTime().UnixNano(), info.Size(), thumbSize),
+	}
+	if thumbSize == 0 {
+		return img, nil
+	}
+
+	thumb, thumbType, err := makeThumbnail(data, thumbSize)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to create thumbnail")
+	}
+	if thumb != nil {
+		img.Data, img.ContentType = thumb, thumbType
+	}
+
+	thumbnailCache.Lock()
+	if len(thumbnailCache.images) >= maxCachedThumbs {
+		thumbnailCache.images = make(map[string]*ProjectImage)
+	}
+	thumbnailCache.images[key] = img
+	thumbnailCache.Unlock()
+
+	return img, nil
+}
+
+// makeThumbnail scales an encoded image down to fit within size pixels. It returns nil
+// when the image is already small enough or too large to decode safely.
+// JPEGs stay JPEGs; other formats become PNGs so transparency is kept.
+func makeThumbnail(data []byte, size int) ([]byte, string, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", serr.Wrap(err, "failed to read image header")
+	}
+	if config.Width <= size && config.Height <= size {
+		return nil, "", nil
+	}
+	if config.Width*config.Height > maxThumbnailPixels {
+		return nil, "", nil
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", serr.Wrap(err, "failed to decode image")
+	}
+
+	width, height := size, size
+	if config.Width > config.Height {
+		height = max(1, config.Height*size/config.Width)
+	} else {
+		width = max(1, config.Width*size/config.Height)
+	}
+	dst := scaleImage(src, width, height)
+
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85})
+		return buf.Bytes(), "image/jpeg", err
+	}
+	err = png.Encode(&buf, dst)
+	return buf.Bytes(), "image/png", err
+}
+
+// scaleImage shrinks src to width by height, averaging the source pixels that fall in each
+// destination pixel so fine detail doesn't alias
+func scaleImage(src image.Image, width, height int) *image.NRGBA {
+	bounds := src.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/height
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*bounds.Dy()/height)
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/width
+			x1 := max(x0+1, bounds.Min.X+(x+1)*bounds.Dx()/width)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA() // Alpha-premultiplied
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+			if a == 0 {
+				continue // Fully transparent
+			}
+			dst.SetNRGBA(x, y, color.NRGBA{
+				R: uint8(r * 0xff / a),
+				G: uint8(g * 0xff / a),
+				B: uint8(b * 0xff / a),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+	return dst
+}
+
+// getFileImageHandler serves an image in the project with its content type.
+// Pass ?thumb=N for a thumbnail no larger than N pixels on either side.
+func getFileImageHandler(c rweb.Context) error {
+	if fileExplorer == nil {
+		return c.WriteError(serr.New("file explorer not initialized"), 500)
+	}
+
+	// Get the path from the URL after /api/files/image/
+	prefix := "/api/files/image/"
+	path := strings.TrimPrefix(c.Request().Path(), prefix)
+	if path == "" {
+		return c.WriteError(serr.New("path parameter required"), 400)
+	}
+
+	thumbSize := 0
+	if thumb := c.Request().QueryParam("thumb"); thumb != "" {
+		size, err := strconv.Atoi(thumb)
+		if err != nil || size < 1 {
+			return c.WriteError(serr.New("thumb must be a positive number of pixels"), 400)
+		}
+		thumbSize = size
+	}
+
+	img, err := fileExplorer.GetImage(path, thumbSize)
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get image"), 400)
+	}
+
+	c.Response().SetHeader("ETag", img.ETag)
+	c.Response().SetHeader("Cache-Control", "private, no-cache")
+	if c.Request().Header("If-None-Match") == img.ETag {
+		c.Response().SetStatus(304)
+		return nil
+	}
+
+	c.Response().SetHeader("Content-Type", img.ContentType)
+	c.Response().SetHeader("Last-Modified", img.ModTime.UTC().Format(http.TimeFormat))
+	if img.ContentType == "image/svg+xml" {
+		// SVGs can carry scripts; keep them inert when opened directly
+		c.Response().SetHeader("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	}
+	return c.Bytes(img.Data)
+}
@@ -0,0 +1,652 @@
+package web
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"rcode/tools"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/rweb"
+	"github.com/rohanthewiz/serr"
+)
+
+const (
+	defaultSearchLimit    = 100              // Results in a page when a search sets no limit
+	maxSearchLimit        = 1000             // Most results in a page
+	maxSearchLinesPerFile = 50               // Matching lines kept for each file
+	maxSearchContextLines = 10               // Most context lines allowed around a match
+	maxSearchLineLength   = 500              // Longer lines are cut in results
+	maxSearchFileSize     = 10 * 1024 * 1024 // Larger files are not searched for content
+	searchTimeout         = 30 * time.Second
+)
+
+// Search engines reported with results
+const (
+	searchEngineRipgrep = "ripgrep"
+	searchEngineWalker  = "walker"
+)
+
+// ripgrepPath is the path of the rg binary, or "" when it isn't installed
+var ripgrepPath = sync.OnceValue(func() string {
+	path, err := exec.LookPath("rg")
+	if err != nil {
+		logger.Info("ripgrep not found; file content search will use the built-in walker")
+		return ""
+	}
+	return path
+})
+
+// SearchOptions describes a search of the project. File names always match against the
+// query; contents are searched only when SearchContent is set.
+type SearchOptions struct {
+	Query         string   `json:"query"`
+	SearchContent bool     `json:"searchContent"`
+	Regex         bool     `json:"regex"` // Treat the query as a regular expression rather than text
+	CaseSensitive bool     `json:"caseSensitive"`
+	Path          string   `json:"path"`         // Directory to search in, relative to the project root
+	Globs         []string `json:"globs"`        // Only search files matching these, e.g. "*.go"; a leading ! excludes
+	ContextLines  int      `json:"contextLines"` // Lines to include around each content match
+	Offset        int      `json:"offset"`       // Results to skip, for paging
+	Limit         int      `json:"limit"`        // Results in the page (default 100)
+}
+
+// SearchLine is a line of a file that matched a content search, or one around it
+type SearchLine struct {
+	Number int      `json:"number"`
+	Text   string   `json:"text"`
+	Match  bool     `json:"match,omitempty"`  // False for context lines
+	Ranges [][2]int `json:"ranges,omitempty"` // Start and end of each match in Text, in characters, for highlighting
+}
+
+// SearchResult is a file or directory found by a search
+type SearchResult struct {
+	FileNode
+	Lines []SearchLine `json:"lines,omitempty"` // Content matches with their context, in line order
+}
+
+// SearchPage is one page of search results
+type SearchPage struct {
+	Results []SearchResult `json:"results"`
+	Offset  int            `json:"offset"`
+	Limit   int            `json:"limit"`
+	HasMore bool           `json:"hasMore"` // More results follow this page
+	Engine  string         `json:"engine"`  // What searched the contents: ripgrep or walker
+}
+
+// searchGlobs filters files by name or, for globs containing a slash, by path
+type searchGlobs struct {
+	include []string
+	exclude []string
+}
+
+func newSearchGlobs(globs []string) searchGlobs {
+	var g searchGlobs
+	for _, glob := range globs {
+		glob = strings.TrimSpace(glob)
+		if strings.HasPrefix(glob, "!") {
+			g.exclude = append(g.exclude, glob[1:])
+		} else if glob != "" {
+			g.include = append(g.include, glob)
+		}
+	}
+	return g
+}
+
+func (g searchGlobs) empty() bool {
+	return len(g.include) == 0 && len(g.exclude) == 0
+}
+
+// allows reports whether the file at relPath passes the filters
+func (g searchGlobs) allows(relPath string) bool {
+	matches := func(glob string) bool {
+		target := filepath.Base(relPath)
+		if strings.Contains(glob, "/") {
+			target = filepath.ToSlash(relPath)
+			glob = strings.TrimPrefix(glob, "/")
+		}
+		matched, _ := filepath.Match(glob, target)
+		return matched
+	}
+
+	for _, glob := range g.exclude {
+		if matches(glob) {
+			return false
+		}
+	}
+	if len(g.include) == 0 {
+		return true
+	}
+	for _, glob := range g.include {
+		if matches(glob) {
+			return true
+		}
+	}
+	return false
+}
+
+// search is a prepared search of the project
+type search struct {
+	opts    SearchOptions
+	re      *regexp.Regexp
+	globs   searchGlobs
+	scope   string // Absolute directory searched
+	relDir  string // The scope relative to the root, "." for the whole project
+	engine  string
+	emitted map[string]bool // Paths already found by the content pass
+}
+
+// newSearch validates opts and prepares the search
+func (s *FileExplorerService) newSearch(opts SearchOptions) (*search, error) {
+	if opts.Query == "" {
+		return nil, serr.New("query is required")
+	}
+	opts.ContextLines = min(max(opts.ContextLines, 0), maxSearchContextLines)
+
+	expr := opts.Query
+	if !opts.Regex {
+		expr = regexp.QuoteMeta(expr)
+	}
+	if !opts.CaseSensitive {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, serr.Wrap(err, "invalid search pattern")
+	}
+
+	relDir := filepath.Clean(opts.Path)
+	scope := filepath.Join(s.rootPath, relDir)
+	// Security check: ensure path is within root
+	if !strings.HasPrefix(scope, s.rootPath) {
+		return nil, serr.New("access denied: path outside project root")
+	}
+	if info, err := os.Stat(scope); err != nil || !info.IsDir() {
+		return nil, serr.New("search directory not found: " + opts.Path)
+	}
+
+	engine := searchEngineWalker
+	if opts.SearchContent && ripgrepPath() != "" {
+		engine = searchEngineRipgrep
+	}
+
+	return &search{
+		opts:    opts,
+		re:      re,
+		globs:   newSearchGlobs(opts.Globs),
+		scope:   scope,
+		relDir:  relDir,
+		engine:  engine,
+		emitted: make(map[string]bool),
+	}, nil
+}
+
+// errStopSearch ends a walk once the caller has enough results
+var errStopSearch = errors.New("search stopped")
+
+// searchEach runs a search, calling emit with each result until emit returns false or ctx
+// ends. Files whose contents match come first, with their lines, in path order; then the
+// files and directories matching by name alone.
+func (s *FileExplorerService) searchEach(ctx context.Context, sr *search, emit func(SearchResult) bool) error {
+	send := func(result SearchResult) error {
+		sr.emitted[result.Path] = true
+		if !emit(result) {
+			return errStopSearch
+		}
+		return ctx.Err()
+	}
+
+	var err error
+	if sr.opts.SearchContent {
+		if sr.engine == searchEngineRipgrep {
+			err = s.searchRipgrep(ctx, sr, send)
+		} else {
+			err = s.searchWalk(sr, true, send)
+		}
+	}
+	if err == nil {
+		err = s.searchWalk(sr, false, send)
+	}
+
+	if errors.Is(err, errStopSearch) {
+		return nil
+	}
+	if err != nil && ctx.Err() != nil {
+		return serr.Wrap(ctx.Err(), "search cancelled")
+	}
+	return err
+}
+
+// SearchFiles returns a page of the results of a search. Content search uses ripgrep when
+// it is installed and walks the tree otherwise; both skip what the explorer ignores.
+func (s *FileExplorerService) SearchFiles(opts SearchOptions) (*SearchPage, error) {
+	opts.Offset = max(opts.Offset, 0)
+	if opts.Limit <= 0 {
+		opts.Limit = defaultSearchLimit
+	}
+	opts.Limit = min(opts.Limit, maxSearchLimit)
+
+	sr, err := s.newSearch(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), searchTimeout)
+	defer cancel()
+
+	page := &SearchPage{Results: []SearchResult{}, Offset: opts.Offset, Limit: opts.Limit, Engine: sr.engine}
+	seen := 0
+	err = s.searchEach(ctx, sr, func(result SearchResult) bool {
+		seen++
+		if seen <= opts.Offset {
+			return true
+		}
+		if len(page.Results) == opts.Limit {
+			page.HasMore = true
+			return false
+		}
+		page.Results = append(page.Results, result)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// searchWalk walks the search scope, matching contents when withContent is set and names
+// otherwise. Paths found by an earlier pass are skipped.
+func (s *FileExplorerService) searchWalk(sr *search, withContent bool, send func(SearchResult) error) error {
+	return filepath.WalkDir(sr.scope, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Skip paths with errors
+		}
+
+		if path == sr.scope {
+			return nil
+		}
+		// Skip ignored paths
+		if s.shouldIgnore(path, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		relPath, _ := filepath.Rel(s.rootPath, path)
+		if sr.emitted[relPath] {
+			return nil
+		}
+		if d.IsDir() && (withContent || !sr.globs.empty()) {
+			return nil // Filters apply to files, so no directory matches
+		}
+		if !d.IsDir() && !sr.globs.allows(relPath) {
+			return nil
+		}
+		if withContent && !d.IsDir() && tools.IsSensitivePath(path) {
+			return nil // Their names are found, but not their content
+		}
+
+		var lines []SearchLine
+		if withContent {
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			if lines = searchFileContent(path, sr.re, sr.opts.ContextLines); lines == nil {
+				return nil
+			}
+		} else if !sr.re.MatchString(d.Name()) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		return send(SearchResult{
+			FileNode: FileNode{
+				Path:    relPath,
+				Name:    d.Name(),
+				IsDir:   d.IsDir(),
+				Size:    info.Size(),
+				ModTime: info.ModTime(),
+				Icon:    getFileIcon(d.Name(), d.IsDir()),
+			},
+			Lines: lines,
+		})
+	})
+}
+
+// searchFileContent returns the lines of a text file that match re, with contextLines
+// lines around each, or nil when nothing matches
+func searchFileContent(path string, re *regexp.Regexp, contextLines int) []SearchLine {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > maxSearchFileSize {
+		return nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil || isBinaryContent(content) {
+		return nil
+	}
+
+	fileLines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	var matched []int
+	for i, line := range fileLines {
+		if re.MatchString(line) {
+			matched = append(matched, i)
+			if len(matched) >= maxSearchLinesPerFile {
+				break
+			}
+		}
+	}
+	if len(matched) == 0 {
+		return nil
+	}
+
+	// Each match brings in the lines around it; overlapping context is shown once
+	var lines []SearchLine
+	next := 0 // First line not yet added
+	for m, at := range matched {
+		from := max(at-contextLines, next)
+		to := min(at+contextLines, len(fileLines)-1)
+		if m+1 < len(matched) {
+			to = min(to, matched[m+1]-1)
+		}
+		for i := from; i <= to; i++ {
+			var ranges [][]int
+			if i == at {
+				ranges = re.FindAllStringIndex(fileLines[i], -1)
+			}
+			lines = append(lines, newSearchLine(i+1, fileLines[i], i == at, ranges))
+		}
+		next = to + 1
+	}
+	return lines
+}
+
+// newSearchLine makes a result line, cutting long text and converting the byte offsets of
+// matches to character offsets
+func newSearchLine(number int, text string, match bool, byteRanges [][]int) SearchLine {
+	text = strings.TrimRight(text, "\r\n")
+	line := SearchLine{Number: number, Text: text, Match: match}
+
+	cut := len(text)
+	if len(text) > maxSearchLineLength {
+		cut = maxSearchLineLength
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		line.Text = text[:cut] + "..."
+	}
+
+	for _, r := range byteRanges {
+		if len(r) != 2 || r[0] >= cut || r[1] > len(text) || r[0] > r[1] {
+			continue
+		}
+		start := utf8.RuneCountInString(text[:r[0]])
+		end := start + utf8.RuneCountInString(text[r[0]:min(r[1], cut)])
+		line.Ranges = append(line.Ranges, [2]int{start, end})
+	}
+	return line
+}
+
+// ripgrepEvent is one line of rg --json output
+type ripgrepEvent struct {
+	Type string `json:"type"`
+	Data struct {
+		Path struct {
+			Text string `json:"text"`
+		} `json:"path"`
+		Lines struct {
+			Text string `json:"text"`
+		} `json:"lines"`
+		LineNumber int `json:"line_number"`
+		Submatches []struct {
+			Start int `json:"start"`
+			End   int `json:"end"`
+		} `json:"submatches"`
+	} `json:"data"`
+}
+
+// searchRipgrep searches file contents with rg, sending each file with its matching lines
+func (s *FileExplorerService) searchRipgrep(ctx context.Context, sr *search, send func(SearchResult) error) error {
+	args := []string{
+		"--json",
+		"--sort", "path", // Keep results in a stable order so pages line up
+		"--hidden",         // The explorer shows dot files unless they are ignored
+		"--no-require-git", // Apply .gitignore outside git repositories too
+		"--max-count", strconv.Itoa(maxSearchLinesPerFile),
+		"--max-filesize", strconv.Itoa(maxSearchFileSize),
+		"--context", strconv.Itoa(sr.opts.ContextLines),
+	}
+	if !sr.opts.Regex {
+		args = append(args, "--fixed-strings")
+	}
+	if !sr.opts.CaseSensitive {
+		args = append(args, "--ignore-case")
+	}
+	for _, pattern := range defaultIgnorePatterns {
+		args = append(args, "--glob", "!"+pattern)
+	}
+	for _, glob := range sr.globs.include {
+		args = append(args, "--glob", glob)
+	}
+	for _, glob := range sr.globs.exclude {
+		args = append(args, "--glob", "!"+glob)
+	}
+	args = append(args, "--regexp", sr.opts.Query)
+	if sr.relDir != "." {
+		args = append(args, "--", sr.relDir)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Run from the root so rg reports paths relative to it
+	cmd := exec.CommandContext(ctx, ripgrepPath(), args...)
+	cmd.Dir = s.rootPath
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return serr.Wrap(err, "failed to start ripgrep")
+	}
+	if err := cmd.Start(); err != nil {
+		return serr.Wrap(err, "failed to start ripgrep")
+	}
+
+	var current *SearchResult
+	var sendErr error
+	found := false
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for sendErr == nil && scanner.Scan() {
+		var event ripgrepEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+
+		switch event.Type {
+		case "begin":
+			current = &SearchResult{}
+			current.Path = filepath.Clean(filepath.FromSlash(event.Data.Path.Text))
+		case "match", "context":
+			if current != nil {
+				var ranges [][]int
+				for _, sub := range event.Data.Submatches {
+					ranges = append(ranges, []int{sub.Start, sub.End})
+				}
+				current.Lines = append(current.Lines,
+					newSearchLine(event.Data.LineNumber, event.Data.Lines.Text, event.Type == "match", ranges))
+			}
+		case "end":
+			// rg knows .gitignore but not .rcodeIgnore, so check with the explorer's rules
+			if current != nil && !s.ignore.Match(current.Path, false) && !tools.IsSensitivePath(filepath.Join(s.rootPath, current.Path)) {
+				if result, ok := s.searchResultFor(current); ok {
+					found = true
+					sendErr = send(result)
+				}
+			}
+			current = nil
+		}
+	}
+
+	// Stop rg early when the caller has enough results
+	cancel()
+	err = cmd.Wait()
+	if sendErr != nil {
+		return sendErr
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+		return nil // No matches
+	}
+	if err != nil && !found {
+		return serr.Wrap(err, "ripgrep failed")
+	}
+	return nil
+}
+
+// searchResultFor fills in the file details of a ripgrep result
+func (s *FileExplorerService) searchResultFor(result *SearchResult) (SearchResult, bool) {
+	info, err := os.Stat(filepath.Join(s.rootPath, result.Path))
+	if err != nil {
+		return SearchResult{}, false
+	}
+	result.Name = info.Name()
+	result.Size = info.Size()
+	result.ModTime = info.ModTime()
+	result.Icon = getFileIcon(info.Name(), false)
+	return *result, true
+}
+
+// searchFilesHandler returns a page of search results. The body is a SearchOptions;
+// only query is required.
+func searchFilesHandler(c rweb.Context) error {
+	if fileExplorer == nil {
+		return c.WriteError(serr.New("file explorer not initialized"), 500)
+	}
+
+	var req SearchOptions
+	body := c.Request().Body()
+	if err := json.Unmarshal(body, &req); err != nil {
+		return c.WriteError(serr.New("invalid request body"), 400)
+	}
+
+	if req.Query == "" {
+		return c.WriteError(serr.New("query parameter required"), 400)
+	}
+
+	page, err := fileExplorer.SearchFiles(req)
+	if err != nil {
+		return c.WriteError(err, 400)
+	}
+
+	return c.WriteJSON(map[string]interface{}{
+		"results": page.Results,
+		"count":   len(page.Results),
+		"query":   req.Query,
+		"offset":  page.Offset,
+		"limit":   page.Limit,
+		"hasMore": page.HasMore,
+		"engine":  page.Engine,
+	})
+}
+
+// searchStreamHandler streams search results over SSE as they are found, for EventSource.
+// Query params: query (required), content, regex, case, path, glob (repeatable), context,
+// offset and limit. Each result is sent as a "result" event, then "done" with the count,
+// or "error" if the search fails.
+func searchStreamHandler(c rweb.Context) error {
+	if fileExplorer == nil {
+		return c.WriteError(serr.New("file explorer not initialized"), 500)
+	}
+
+	params, _ := url.ParseQuery(c.Request().Query())
+	opts := SearchOptions{
+		Query:         params.Get("query"),
+		SearchContent: params.Get("content") == "true",
+		Regex:         params.Get("regex") == "true",
+		CaseSensitive: params.Get("case") == "true",
+		Path:          params.Get("path"),
+		Globs:         params["glob"],
+	}
+	opts.ContextLines, _ = strconv.Atoi(params.Get("context"))
+	opts.Offset, _ = strconv.Atoi(params.Get("offset"))
+	opts.Limit, _ = strconv.Atoi(params.Get("limit"))
+	if opts.Limit <= 0 {
+		opts.Limit = maxSearchLimit
+	}
+	opts.Offset = max(opts.Offset, 0)
+
+	sr, err := fileExplorer.newSearch(opts)
+	if err != nil {
+		return c.WriteError(err, 400)
+	}
+
+	events := make(chan any, clientChanCap)
+	abandoned := false
+
+	// publish queues an event, giving up if the client has stopped reading
+	publish := func(eventType string, data interface{}) bool {
+		payload, err := json.Marshal(data)
+		if err != nil {
+			logger.LogErr(err, "failed to marshal search event")
+			return false
+		}
+		select {
+		case events <- rweb.SSEvent{Type: eventType, Data: string(payload)}:
+			return true
+		case <-time.After(searchTimeout):
+			abandoned = true
+			return false
+		}
+	}
+
+	go func() {
+		defer close(events)
+
+		ctx, cancel := context.WithTimeout(context.Background(), searchTimeout)
+		defer cancel()
+
+		seen, count, hasMore := 0, 0, false
+		err := fileExplorer.searchEach(ctx, sr, func(result SearchResult) bool {
+			seen++
+			if seen <= opts.Offset {
+				return true
+			}
+			if count == opts.Limit {
+				hasMore = true
+				return false
+			}
+			count++
+			return publish("result", result)
+		})
+		if abandoned {
+			return
+		}
+		if err != nil {
+			publish("error", map[string]string{"error": err.Error()})
+			return
+		}
+		publish("done", map[string]interface{}{
+			"count":   count,
+			"offset":  opts.Offset,
+			"hasMore": hasMore,
+			"engine":  sr.engine,
+		})
+	}()
+
+	return c.SetSSE(events, "")
+}
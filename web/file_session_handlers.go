@@ -53,10 +53,7 @@ func closeFileInSessionHandler(c rweb.Context) error {
 		return c.WriteError(serr.New("session ID required"), 400)
 	}
 
-	var req struct {
-		Path string `json:"path"`
-	}
-
+	var req FilePathRequest
 	body := c.Request().Body()
 	if err := json.Unmarshal(body, &req); err != nil {
 		return c.WriteError(serr.New("invalid request body"), 400)
@@ -0,0 +1,272 @@
+package web
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"mime/multipart"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"rcode/config"
+	"rcode/tools"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/rweb"
+	"github.com/rohanthewiz/serr"
+)
+
+// errDownloadTooLarge is returned when a directory holds more than the download limit
+var errDownloadTooLarge = errors.New("directory is too large to download")
+
+// UploadedFile is one file of an upload, named relative to the upload directory.
+// Names may contain subdirectories, as when a folder is uploaded.
+type UploadedFile struct {
+	Name string
+	Data []byte
+}
+
+// SaveUploads writes uploaded files into the directory at relativeDir, creating
+// subdirectories as needed. Every name is checked before anything is written, so a bad
+// name or an existing file (unless overwrite is set) leaves the project unchanged.
+// It returns the paths of the written files.
+func (s *FileExplorerService) SaveUploads(relativeDir string, files []UploadedFile, overwrite bool) ([]string, error) {
+	cleanDir := filepath.Clean(relativeDir)
+	fullDir := filepath.Join(s.rootPath, cleanDir)
+
+	// Security check: ensure path is within root
+	if !strings.HasPrefix(fullDir, s.rootPath) {
+		return nil, serr.New("access denied: path outside project root")
+	}
+	if info, err := os.Stat(fullDir); err != nil || !info.IsDir() {
+		return nil, serr.New("upload directory not found: " + relativeDir)
+	}
+
+	paths := make([]string, len(files))
+	for i, file := range files {
+		name := filepath.Clean(filepath.FromSlash(file.Name))
+		if name == "." || filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return nil, serr.New(fmt.Sprintf("invalid file name: %q", file.Name))
+		}
+		fullPath := filepath.Join(fullDir, name)
+		if !strings.HasPrefix(fullPath, s.rootPath+string(filepath.Separator)) {
+			return nil, serr.New("access denied: path outside project root")
+		}
+		if isProtectedPath(fullPath) && !overwrite {
+			return nil, serr.New(fmt.Sprintf("%s is protected; set overwrite to replace it", file.Name))
+		}
+		if info, err := os.Stat(fullPath); err == nil {
+			if info.IsDir() {
+				return nil, serr.New(fmt.Sprintf("%s is a directory", file.Name))
+			}
+			if !overwrite {
+				return nil, ErrDestinationExists
+			}
+		}
+		paths[i] = filepath.Join(cleanDir, name)
+	}
+
+	for i, file := range files {
+		fullPath := filepath.Join(s.rootPath, paths[i])
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			return nil, serr.Wrap(err, "failed to create directories")
+		}
+
+		// Write beside the target and rename so a failed upload never leaves half a file
+		tmpPath := fullPath + ".upload"
+		if err := os.WriteFile(tmpPath, file.Data, 0644); err != nil {
+			return nil, serr.Wrap(err, "failed to write "+file.Name)
+		}
+		if err := os.Rename(tmpPath, fullPath); err != nil {
+			_ = os.Remove(tmpPath)
+			return nil, serr.Wrap(err, "failed to write "+file.Name)
+		}
+		s.clearCacheForPath(filepath.Dir(paths[i]))
+	}
+
+	return paths, nil
+}
+
+// ZipDirectory writes the directory at relativePath to w as a zip archive, skipping what the
+// explorer ignores. It stops with errDownloadTooLarge once the files exceed maxSize bytes.
+func (s *FileExplorerService) ZipDirectory(relativePath string, w io.Writer, maxSize int64) error {
+	cleanPath := filepath.Clean(relativePath)
+	fullPath := filepath.Join(s.rootPath, cleanPath)
+
+	// Security check: ensure path is within root
+	if !strings.HasPrefix(fullPath, s.rootPath) {
+		return serr.New("access denied: path outside project root")
+	}
+
+	zipWriter := zip.NewWriter(w)
+	var total int64
+
+	err := filepath.WalkDir(fullPath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Skip unreadable entries
+		}
+		if path != fullPath && s.shouldIgnore(path, entry.IsDir()) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// Sensitive files, such as .env and private keys, are left out, as the explorer won't show them
+		if !entry.Type().IsRegular() || tools.IsSensitivePath(path) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		total += info.Size()
+		if total > maxSize {
+			return errDownloadTooLarge
+		}
+
+		// Entries are named relative to the directory, inside a folder named after it
+		if err := addFileToZip(zipWriter, path, filepath.Dir(fullPath)); err != nil {
+			logger.LogErr(err, "Failed to add file to download", "file", path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return zipWriter.Close()
+}
+
+// uploadFilesHandler accepts a multipart upload of one or more files in the "files" field.
+// Query parameters: path, the project directory to upload into, and overwrite=true to
+// replace existing files. File names may contain subdirectories.
+func uploadFilesHandler(c rweb.Context) error {
+	if fileExplorer == nil {
+		return c.WriteError(serr.New("file explorer not initialized"), 500)
+	}
+
+	maxSize := config.Get().MaxUploadSize
+	body := c.Request().Body()
+	if int64(len(body)) > maxSize {
+		return c.WriteError(serr.New(fmt.Sprintf("upload too large (max %d bytes)", maxSize)), 413)
+	}
+
+	_, params, err := mime.ParseMediaType(c.Request().Header("Content-Type"))
+	if err != nil || params["boundary"] == "" {
+		return c.WriteError(serr.New("expected a multipart/form-data upload"), 400)
+	}
+
+	var files []UploadedFile
+	reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return c.WriteError(serr.Wrap(err, "invalid multipart body"), 400)
+		}
+		if part.FormName() != "files" || part.FileName() == "" {
+			continue
+		}
+
+		data, err := io.ReadAll(part)
+		if err != nil {
+			return c.WriteError(serr.Wrap(err, "failed to read upload"), 400)
+		}
+		// FileName drops directories, so read the raw name to keep folder uploads intact
+		name := part.FileName()
+		if _, dispParams, err := mime.ParseMediaType(part.Header.Get("Content-Disposition")); err == nil && dispParams["filename"] != "" {
+			name = dispParams["filename"]
+		}
+		files = append(files, UploadedFile{Name: name, Data: data})
+	}
+	if len(files) == 0 {
+		return c.WriteError(serr.New("no files in upload"), 400)
+	}
+
+	dir := c.Request().QueryParam("path")
+	if dir == "" {
+		dir = "."
+	}
+	paths, err := fileExplorer.SaveUploads(dir, files, c.Request().QueryParam("overwrite") == "true")
+	if errors.Is(err, ErrDestinationExists) {
+		return c.WriteError(err, 409)
+	}
+	if err != nil {
+		return c.WriteError(err, 400)
+	}
+
+	for _, path := range paths {
+		BroadcastFileChanged("", path, "created")
+	}
+	BroadcastFileTreeUpdate("", filepath.Clean(dir))
+
+	return c.WriteJSON(map[string]interface{}{
+		"status": "ok",
+		"paths":  paths,
+	})
+}
+
+// downloadFileHandler sends a project file as an attachment, or a directory as a zip archive
+func downloadFileHandler(c rweb.Context) error {
+	if fileExplorer == nil {
+		return c.WriteError(serr.New("file explorer not initialized"), 500)
+	}
+
+	prefix := "/api/files/download/"
+	path := strings.TrimPrefix(c.Request().Path(), prefix)
+	if decoded, err := url.PathUnescape(path); err == nil {
+		path = decoded
+	}
+	if path == "" {
+		path = "."
+	}
+
+	cleanPath := filepath.Clean(path)
+	fullPath := filepath.Join(fileExplorer.rootPath, cleanPath)
+	if !strings.HasPrefix(fullPath, fileExplorer.rootPath) {
+		return c.WriteError(serr.New("access denied: path outside project root"), 400)
+	}
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return c.WriteError(serr.New("file not found: "+path), 404)
+	}
+
+	maxSize := config.Get().MaxDownloadSize
+	if !info.IsDir() {
+		if tools.IsSensitivePath(fullPath) {
+			return c.WriteError(serr.New("access denied: sensitive file; allow it with RCODE_SENSITIVE_ALLOW"), 403)
+		}
+		if info.Size() > maxSize {
+			return c.WriteError(serr.New(fmt.Sprintf("file too large to download (max %d bytes)", maxSize)), 413)
+		}
+		data, err := os.ReadFile(fullPath)
+		if err != nil {
+			return c.WriteError(serr.Wrap(err, "failed to read file"), 500)
+		}
+		return rweb.File(c, filepath.Base(fullPath), data)
+	}
+
+	var buf bytes.Buffer
+	if err := fileExplorer.ZipDirectory(cleanPath, &buf, maxSize); err != nil {
+		if errors.Is(err, errDownloadTooLarge) {
+			return c.WriteError(serr.New(fmt.Sprintf("directory too large to download (max %d bytes)", maxSize)), 413)
+		}
+		return c.WriteError(serr.Wrap(err, "failed to zip directory"), 500)
+	}
+
+	name := filepath.Base(fullPath)
+	if err := rweb.File(c, name+".zip", buf.Bytes()); err != nil {
+		return err
+	}
+	c.Response().SetHeader("Content-Type", "application/zip")
+	return nil
+}
@@ -103,6 +103,10 @@ func (f FileExplorerTabs) Render(b *element.Builder) (x any) {
 	b.Div("class", "sidebar-content").R(
 		// Sessions tab
 		b.Div("class", getTabContentClass("sessions", f.ActiveTab), "id", "sessions-tab").R(
+			b.Div("class", "session-search").R(
+				b.Input("type", "text", "id", "session-search-input", "placeholder", "Search conversations...", "class", "search-input"),
+			),
+			b.Div("id", "session-search-results", "class", "session-search-results", "style", "display: none;").R(),
 			b.Div("id", "sessions-list").R(
 				element.ForEach(f.Sessions, func(session SessionInfo) {
 					b.Div("class", "session-item", "data-session-id", session.ID).R(
@@ -123,6 +127,10 @@ func (f FileExplorerTabs) Render(b *element.Builder) (x any) {
 			),
 			b.Div("class", "file-search").R(
 				b.Input("type", "text", "id", "file-search-input", "placeholder", "Search files...", "class", "search-input"),
+				b.Label("class", "search-content-toggle", "title", "Search inside files as well as their names").R(
+					b.Input("type", "checkbox", "id", "file-search-content"),
+					b.Span().T("Search contents"),
+				),
 			),
 			b.Div("id", "file-tree-container").R(
 				func() (x any) {
@@ -0,0 +1,225 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"rcode/db"
+	"rcode/providers"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/rweb"
+)
+
+// Exit codes of a headless run
+const (
+	ExitOK           = 0
+	ExitFailed       = 1 // The run failed, e.g. the model request or the database
+	ExitUsage        = 2 // The command line was invalid
+	ExitNotConnected = 3 // Claude isn't connected; log in from the web UI first
+)
+
+// headlessChanCap is the event buffer of a headless run. It is larger than a browser's,
+// as a dropped delta would leave a gap in the printed response.
+const headlessChanCap = 4096
+
+// HeadlessOptions configures a run of a single prompt without the web UI
+type HeadlessOptions struct {
+	Prompt     string
+	Model      string // Defaults to the session's model, from the user's settings
+	JSON       bool   // Write the session's events as JSON lines instead of text
+	AllowTools bool   // Run tools that would ask for permission, rather than refusing them
+}
+
+// headlessEvent is an SSE event as a headless run reads it back from the hub
+type headlessEvent struct {
+	Type      string          `json:"type"`
+	SessionId string          `json:"sessionId,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+}
+
+// headlessResult is the last line of a run's JSON output
+type headlessResult struct {
+	Type      string           `json:"type"` // "result"
+	SessionID string           `json:"session_id,omitempty"`
+	Content   string           `json:"content,omitempty"`
+	Model     string           `json:"model,omitempty"`
+	Usage     *providers.Usage `json:"usage,omitempty"`
+	IsError   bool             `json:"is_error"`
+	Error     string           `json:"error,omitempty"`
+}
+
+// RunHeadless creates a session, sends it the prompt and writes the response and tool
+// activity to out as they happen. It returns the exit code for the process. The session
+// is kept, so the run can be looked at in the web UI afterwards.
+func RunHeadless(opts HeadlessOptions, out io.Writer) int {
+	h := &headlessRun{opts: opts, out: out}
+
+	if !modelsConnected() {
+		return h.fail("", ExitNotConnected, "Claude isn't connected; start rcode and log in from the web UI first")
+	}
+
+	// There is no one to ask, so the flag answers for the user
+	askPermission = func(sessionID, toolName string, params map[string]interface{}) (bool, error) {
+		if !opts.AllowTools {
+			logger.Warn("Refused tool that needs permission; use --allow-tools to run it", "tool", toolName)
+		}
+		return opts.AllowTools, nil
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return h.fail("", ExitFailed, "failed to get database: "+err.Error())
+	}
+
+	session, err := createSession(&CreateSessionRequest{ModelPreference: opts.Model})
+	if err != nil {
+		return h.fail("", ExitFailed, "failed to create session: "+err.Error())
+	}
+	BroadcastSessionList()
+
+	// There is no one to confirm a costly prompt with; running it is the confirmation
+	msgReq := MessageRequest{Content: opts.Prompt, Confirm: true}
+	if cmd, rawArgs, ok := SlashCommands().Parse(msgReq.Content); ok {
+		result := runSlashCommand(database, session, cmd, rawArgs)
+		if result.Prompt == "" {
+			h.finish(session.ID, &MessageReply{Content: result.Content, Model: result.Model})
+			return ExitOK
+		}
+		msgReq.Content = result.Prompt
+	}
+
+	// Follow the session's events as the web UI would
+	events := make(chan any, headlessChanCap)
+	sseHub.Register(events, nil)
+	printed := make(chan struct{})
+	go func() {
+		defer close(printed)
+		for event := range events {
+			if sse, ok := event.(rweb.SSEvent); ok {
+				if payload, ok := sse.Data.(string); ok {
+					h.print(session.ID, payload)
+				}
+			}
+		}
+	}()
+
+	reply, err := sendMessage(context.Background(), database, session, msgReq)
+	sseHub.Unregister(events)
+	<-printed
+
+	if err != nil {
+		return h.fail(session.ID, ExitFailed, err.Error())
+	}
+	if reply.Error != "" {
+		return h.fail(session.ID, ExitFailed, reply.Error)
+	}
+	h.finish(session.ID, reply)
+	return ExitOK
+}
+
+// headlessRun writes the output of a headless run
+type headlessRun struct {
+	opts    HeadlessOptions
+	out     io.Writer
+	midLine bool // The streamed text so far doesn't end with a newline
+}
+
+// print writes one of the hub's events, if it is for the run's session
+func (h *headlessRun) print(sessionID, payload string) {
+	var event headlessEvent
+	if err := json.Unmarshal([]byte(payload), &event); err != nil || event.SessionId != sessionID {
+		return
+	}
+
+	if h.opts.JSON {
+		fmt.Fprintln(h.out, payload)
+		return
+	}
+
+	switch event.Type {
+	case "message_delta":
+		var data struct {
+			Delta string `json:"delta"`
+		}
+		if json.Unmarshal(event.Data, &data) == nil && data.Delta != "" {
+			fmt.Fprint(h.out, data.Delta)
+			h.midLine = !strings.HasSuffix(data.Delta, "\n")
+		}
+	case "message_stop":
+		h.endLine()
+	case "rate_limit_wait":
+		var data struct {
+			WaitSeconds int    `json:"waitSeconds"`
+			Reason      string `json:"reason"`
+		}
+		if json.Unmarshal(event.Data, &data) == nil {
+			fmt.Fprintf(os.Stderr, "Waiting %ds for the API's rate limits (%s)\n", data.WaitSeconds, data.Reason)
+		}
+	case "model_fallback":
+		var data struct {
+			Model    string `json:"model"`
+			Fallback string `json:"fallback"`
+		}
+		if json.Unmarshal(event.Data, &data) == nil {
+			fmt.Fprintf(os.Stderr, "%s is unavailable, falling back to %s\n", data.Model, data.Fallback)
+		}
+	case "tool_execution_complete":
+		var data struct {
+			ToolName string `json:"toolName"`
+			Summary  string `json:"summary"`
+		}
+		if json.Unmarshal(event.Data, &data) == nil {
+			h.endLine()
+			fmt.Fprintf(h.out, "[%s] %s\n", data.ToolName, strings.TrimSpace(data.Summary))
+		}
+	}
+}
+
+// endLine ends the streamed text's last line, so what follows starts on its own
+func (h *headlessRun) endLine() {
+	if h.midLine {
+		fmt.Fprintln(h.out)
+		h.midLine = false
+	}
+}
+
+// finish writes the reply: in text mode only a command's reply, as the model's was streamed
+func (h *headlessRun) finish(sessionID string, reply *MessageReply) {
+	if h.opts.JSON {
+		h.writeJSON(headlessResult{
+			Type:      "result",
+			SessionID: sessionID,
+			Content:   reply.Content,
+			Model:     reply.Model,
+			Usage:     reply.Usage,
+		})
+		return
+	}
+	if !reply.Streamed {
+		fmt.Fprintln(h.out, reply.Content)
+	}
+}
+
+// fail reports an error, on stderr in text mode, and returns the exit code
+func (h *headlessRun) fail(sessionID string, code int, msg string) int {
+	if h.opts.JSON {
+		h.writeJSON(headlessResult{Type: "result", SessionID: sessionID, IsError: true, Error: msg})
+	} else {
+		fmt.Fprintln(os.Stderr, "Error: "+msg)
+	}
+	return code
+}
+
+func (h *headlessRun) writeJSON(v interface{}) {
+	line, err := json.Marshal(v)
+	if err != nil {
+		logger.LogErr(err, "failed to marshal headless result")
+		return
+	}
+	fmt.Fprintln(h.out, string(line))
+}
@@ -0,0 +1,137 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/rweb"
+	"github.com/rohanthewiz/serr"
+	"rcode/platform/logging"
+)
+
+const requestIDKey = "request_id"
+
+type requestIDContextKey struct{}
+
+// logRequests gives each request an ID, the caller's X-Request-ID when it sends a usable
+// one, returns it in the reply's X-Request-ID header and logs the request with it once
+// served. Log entries of work the request starts carry the ID as request_id.
+func logRequests(c rweb.Context) error {
+	start := time.Now()
+	req := c.Request()
+	id := req.Header("X-Request-ID")
+	if !validRequestID(id) {
+		id = newRequestID()
+	}
+	c.Set(requestIDKey, id)
+	c.Response().SetHeader("X-Request-ID", id)
+
+	err := c.Next()
+
+	if path := req.Path(); !strings.HasPrefix(path, "/static/") {
+		status := c.Response().Status()
+		if status == 0 {
+			status = 200
+		}
+		logger.Debug("Request served", "request_id", id, "method", req.Method(), "path", path,
+			"status", strconv.Itoa(status), "duration_ms", strconv.FormatInt(time.Since(start).Milliseconds(), 10))
+	}
+	return err
+}
+
+// validRequestID reports whether a caller's request ID can go in the logs as it is
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// requestID returns the request's ID
+func requestID(c rweb.Context) string {
+	id, _ := c.Get(requestIDKey).(string)
+	return id
+}
+
+// withRequestID returns a context carrying the ID of the request work is done for
+func withRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, id)
+}
+
+// requestIDFrom returns the request ID ctx carries, or ""
+func requestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey{}).(string)
+	return id
+}
+
+// sessionLog logs with the fields that tie entries to a session, and to the request that
+// started the work when there is one, so the session's log can be picked out
+type sessionLog []any
+
+func newSessionLog(ctx context.Context, sessionID string) sessionLog {
+	log := sessionLog{"session_id", sessionID}
+	if id := requestIDFrom(ctx); id != "" {
+		log = append(log, "request_id", id)
+	}
+	return log
+}
+
+func (l sessionLog) Debug(msg string, args ...any) { logger.Debug(msg, l.with(args)...) }
+func (l sessionLog) Info(msg string, args ...any)  { logger.Info(msg, l.with(args)...) }
+func (l sessionLog) Warn(msg string, args ...any)  { logger.Warn(msg, l.with(args)...) }
+func (l sessionLog) Error(msg string, args ...any) { logger.Error(msg, l.with(args)...) }
+
+// Err logs an error with a message saying what failed
+func (l sessionLog) Err(err error, msg string, args ...any) {
+	logger.LogErr(err, append([]any{msg}, l.with(args)...)...)
+}
+
+func (l sessionLog) with(args []any) []any {
+	return append(append(make([]any, 0, len(args)+len(l)), args...), l...)
+}
+
+// logsHandler returns the latest log entries, oldest first. The session and request query
+// parameters select a session's or request's entries, level those at it or above, and after
+// those following an entry's seq, to follow the log. limit caps how many, 200 by default.
+func logsHandler(c rweb.Context) error {
+	req := c.Request()
+	filter := logging.Filter{
+		SessionID: req.QueryParam("session"),
+		RequestID: req.QueryParam("request"),
+		Level:     req.QueryParam("level"),
+		Limit:     200,
+	}
+	switch filter.Level {
+	case "", "debug", "info", "warn", "error":
+	default:
+		return c.WriteError(serr.New("level must be debug, info, warn or error"), 400)
+	}
+	if after, err := strconv.ParseInt(req.QueryParam("after"), 10, 64); err == nil && after > 0 {
+		filter.After = after
+	}
+	if limit, err := strconv.Atoi(req.QueryParam("limit")); err == nil && limit > 0 {
+		filter.Limit = limit
+	}
+
+	entries := logging.Tail(filter)
+	if entries == nil {
+		entries = []logging.Entry{}
+	}
+	return c.WriteJSON(entries)
+}
@@ -0,0 +1,170 @@
+package web
+
+import (
+	"rcode/db"
+
+	"github.com/rohanthewiz/element"
+	"github.com/rohanthewiz/rweb"
+	"github.com/rohanthewiz/serr"
+)
+
+// LoginPageHandler serves the sign-in page of multi-user mode. Until there is an account,
+// it creates the first admin instead.
+func LoginPageHandler(c rweb.Context) error {
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	if token := loginToken(c); token != "" {
+		if user, err := database.GetLoginUser(token); err == nil && user != nil {
+			return c.Redirect(302, "/")
+		}
+	}
+
+	count, err := database.CountUsers()
+	if err != nil {
+		return c.WriteError(err, 500)
+	}
+
+	return c.WriteHTML(generateLoginPage(count == 0))
+}
+
+func generateLoginPage(setup bool) string {
+	b := element.NewBuilder()
+
+	title, intro, submit, endpoint := "Sign in to RCode", "", "Sign In", "/api/login"
+	passwordComplete := "current-password"
+	if setup {
+		title = "Set up RCode"
+		intro = "Create the first account. It is an admin, and can add the other users."
+		submit = "Create Admin Account"
+		endpoint = "/api/setup"
+		passwordComplete = "new-password"
+	}
+
+	b.Html().R(
+		b.Head().R(
+			b.Title().T(title),
+			b.Meta("charset", "UTF-8"),
+			b.Meta("name", "viewport", "content", "width=device-width, initial-scale=1.0"),
+			b.Style().T(loginPageCSS),
+		),
+		b.Body().R(
+			b.Form("id", "login-form", "class", "login-card", "data-endpoint", endpoint).R(
+				b.H1().T(title),
+				func() any {
+					if intro != "" {
+						b.P("class", "login-intro").T(intro)
+					}
+					return nil
+				}(),
+				b.Label("for", "username").T("Username"),
+				b.Input("type", "text", "id", "username", "name", "username", "autocomplete", "username", "required", "required", "autofocus", "autofocus"),
+				b.Label("for", "password").T("Password"),
+				b.Input("type", "password", "id", "password", "name", "password", "autocomplete", passwordComplete, "required", "required"),
+				b.Div("id", "login-error", "class", "login-error").R(),
+				b.Button("type", "submit", "class", "btn-primary").T(submit),
+			),
+			b.Script().T(loginPageJS),
+		),
+	)
+
+	return b.String()
+}
+
+const loginPageCSS = `
+	body {
+		font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif;
+		background: #1a1a1a;
+		color: #ffffff;
+		height: 100vh;
+		margin: 0;
+		display: flex;
+		align-items: center;
+		justify-content: center;
+	}
+
+	.login-card {
+		background: #2a2a2a;
+		border: 1px solid #404040;
+		border-radius: 8px;
+		padding: 2rem;
+		width: 320px;
+		display: flex;
+		flex-direction: column;
+		gap: 0.5rem;
+	}
+
+	.login-card h1 {
+		font-size: 1.4rem;
+		margin: 0 0 0.5rem;
+	}
+
+	.login-intro {
+		color: #b0b0b0;
+		font-size: 0.9rem;
+		margin: 0 0 0.5rem;
+	}
+
+	.login-card label {
+		color: #b0b0b0;
+		font-size: 0.85rem;
+	}
+
+	.login-card input {
+		background: #1a1a1a;
+		border: 1px solid #404040;
+		border-radius: 6px;
+		color: #ffffff;
+		padding: 0.5rem;
+		font-size: 0.95rem;
+	}
+
+	.login-error {
+		color: #f44336;
+		font-size: 0.85rem;
+		min-height: 1.2em;
+	}
+
+	.btn-primary {
+		background: #4a9eff;
+		color: white;
+		border: none;
+		border-radius: 6px;
+		padding: 0.6rem 1rem;
+		font-size: 0.95rem;
+		cursor: pointer;
+	}
+
+	.btn-primary:hover {
+		background: #3a8eef;
+	}
+`
+
+const loginPageJS = `
+	document.getElementById('login-form').addEventListener('submit', async function(event) {
+		event.preventDefault();
+		const form = event.target;
+		const errorDiv = document.getElementById('login-error');
+		errorDiv.textContent = '';
+
+		try {
+			const response = await fetch(form.dataset.endpoint, {
+				method: 'POST',
+				headers: { 'Content-Type': 'application/json' },
+				body: JSON.stringify({
+					username: form.username.value,
+					password: form.password.value
+				})
+			});
+			if (!response.ok) {
+				errorDiv.textContent = (await response.text()) || 'Sign-in failed';
+				return;
+			}
+			window.location.href = '/';
+		} catch (error) {
+			errorDiv.textContent = 'Sign-in failed: ' + error.message;
+		}
+	});
+`
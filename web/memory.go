@@ -0,0 +1,90 @@
+package web
+
+import (
+	"os"
+
+	"rcode/db"
+	"rcode/tools"
+
+	"github.com/rohanthewiz/logger"
+)
+
+// Number of project memories injected into the first message of a new session
+const sessionMemoryLimit = 10
+
+// dbMemoryStore backs the memory tools with the project_memories table,
+// scoped to a single project root
+type dbMemoryStore struct {
+	database    *db.DB
+	projectRoot string
+}
+
+// newMemoryStore returns a memory store for the project in the working directory
+func newMemoryStore(database *db.DB) *dbMemoryStore {
+	return &dbMemoryStore{database: database, projectRoot: projectRoot()}
+}
+
+// Remember saves a memory for the project
+func (s *dbMemoryStore) Remember(memory tools.Memory, sessionID string) (int, error) {
+	m := &db.ProjectMemory{
+		ProjectRoot:     s.projectRoot,
+		Category:        memory.Category,
+		Content:         memory.Content,
+		Tags:            memory.Tags,
+		SourceSessionID: sessionID,
+	}
+	if err := s.database.SaveMemory(m); err != nil {
+		return 0, err
+	}
+
+	logger.Info("Stored project memory", "id", m.ID, "category", m.Category)
+	return m.ID, nil
+}
+
+// Recall searches the project's memories
+func (s *dbMemoryStore) Recall(query, category string, limit int) ([]tools.Memory, error) {
+	memories, err := s.database.SearchMemories(s.projectRoot, query, category, limit)
+	if err != nil {
+		return nil, err
+	}
+	return toToolMemories(memories), nil
+}
+
+// memoryPrompt lists the project's top memories for the first message of a new session
+func memoryPrompt(database *db.DB) string {
+	memories, err := database.TopMemories(projectRoot(), sessionMemoryLimit)
+	if err != nil {
+		logger.LogErr(err, "failed to load project memories")
+		return ""
+	}
+	if len(memories) == 0 {
+		return ""
+	}
+
+	return "## Project Memory\n" +
+		"Facts remembered from earlier sessions. Use the recall tool to search for more.\n\n" +
+		tools.FormatMemories(toToolMemories(memories))
+}
+
+func toToolMemories(memories []*db.ProjectMemory) []tools.Memory {
+	result := make([]tools.Memory, 0, len(memories))
+	for _, m := range memories {
+		result = append(result, tools.Memory{
+			ID:       m.ID,
+			Category: m.Category,
+			Content:  m.Content,
+			Tags:     m.Tags,
+		})
+	}
+	return result
+}
+
+// projectRoot returns the directory memories are scoped to
+func projectRoot() string {
+	workDir, err := os.Getwd()
+	if err != nil {
+		logger.LogErr(err, "failed to get working directory for project memory")
+		return "."
+	}
+	return workDir
+}
@@ -0,0 +1,77 @@
+package web
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+
+	"rcode/db"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/rweb"
+	"github.com/rohanthewiz/serr"
+)
+
+// listMemoriesHandler returns the current project's memories, optionally filtered by category
+func listMemoriesHandler(c rweb.Context) error {
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	params, _ := url.ParseQuery(c.Request().Query())
+	category := params.Get("category")
+	if category != "" && !db.ValidMemoryCategory(category) {
+		return c.WriteError(serr.New("invalid memory category"), 400)
+	}
+
+	memories, err := database.ListMemories(projectRoot(), category)
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to list memories"), 500)
+	}
+
+	return c.WriteJSON(memories)
+}
+
+// createMemoryHandler stores a memory for the current project
+func createMemoryHandler(c rweb.Context) error {
+	var memory db.ProjectMemory
+	if err := json.Unmarshal(c.Request().Body(), &memory); err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid request body"), 400)
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	memory.ProjectRoot = projectRoot()
+	if err := database.SaveMemory(&memory); err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to save memory"), 400)
+	}
+
+	logger.Info("Created project memory", "id", memory.ID)
+
+	return c.WriteJSON(memory)
+}
+
+// deleteMemoryHandler removes a memory
+func deleteMemoryHandler(c rweb.Context) error {
+	id, err := strconv.Atoi(c.Request().Param("id"))
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid memory ID"), 400)
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	if err := database.DeleteMemory(id); err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to delete memory"), 404)
+	}
+
+	logger.Info("Deleted project memory", "id", id)
+
+	return c.WriteJSON(map[string]bool{"success": true})
+}
@@ -0,0 +1,147 @@
+package web
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"rcode/providers"
+	"rcode/tools"
+)
+
+const (
+	maxFileMentions     = 10
+	maxMentionFileBytes = 100 * 1024 // Larger files are cut; mention a line range to see the rest
+)
+
+// mentionPattern matches @path and @"path with spaces", either optionally followed by :line or :start-end
+var mentionPattern = regexp.MustCompile(`@(?:"([^"]+)"((?::\d+(?:-\d+)?)?)|([^\s@"]+))`)
+
+// lineRangeSuffix splits a trailing :line or :start-end from a bare mention
+var lineRangeSuffix = regexp.MustCompile(`^(.+?)(?::(\d+)(?:-(\d+))?)?$`)
+
+// resolveFileMentions finds the files mentioned in content and reads them from disk.
+// Paths are relative to root and must stay inside it. Mentions that do not name a file are
+// ignored unless they look like a path, in which case the problem is reported in the mention.
+func resolveFileMentions(content, root string) []providers.FileMention {
+	var mentions []providers.FileMention
+	seen := make(map[string]bool)
+
+	for _, m := range mentionPattern.FindAllStringSubmatchIndex(content, -1) {
+		if len(mentions) >= maxFileMentions {
+			break
+		}
+		// The @ must start the message or follow whitespace, so email addresses are not matched
+		if m[0] > 0 && !unicode.IsSpace(rune(content[m[0]-1])) {
+			continue
+		}
+
+		var token string
+		if m[2] >= 0 {
+			token = content[m[2]:m[3]] + content[m[4]:m[5]]
+		} else {
+			// Sentence punctuation after a bare mention is not part of the path
+			token = strings.TrimRight(content[m[6]:m[7]], ".,;:!?)'`")
+		}
+
+		parts := lineRangeSuffix.FindStringSubmatch(token)
+		if parts == nil {
+			continue
+		}
+
+		mention, ok := resolveFileMention(root, parts[1], parts[2], parts[3])
+		if !ok {
+			continue
+		}
+
+		key := fmt.Sprintf("%s:%d-%d", mention.Path, mention.StartLine, mention.EndLine)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		mentions = append(mentions, mention)
+	}
+
+	return mentions
+}
+
+// resolveFileMention reads one mentioned file, or the requested lines of it.
+// It returns false if path does not name a file and does not look like a path.
+func resolveFileMention(root, path, start, end string) (providers.FileMention, bool) {
+	mention := providers.FileMention{Path: filepath.ToSlash(filepath.Clean(path))}
+	looksLikePath := strings.ContainsAny(path, "/\\.")
+
+	absPath := path
+	if !filepath.IsAbs(absPath) {
+		absPath = filepath.Join(root, path)
+	}
+	absPath = filepath.Clean(absPath)
+
+	rel, err := filepath.Rel(root, absPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		mention.Error = "outside the project"
+		return mention, true
+	}
+	mention.Path = filepath.ToSlash(rel)
+
+	info, err := os.Stat(absPath)
+	if err != nil {
+		mention.Error = "file not found"
+		return mention, looksLikePath
+	}
+	if info.IsDir() {
+		mention.Error = "is a directory; mention a file"
+		return mention, true
+	}
+	if tools.IsSensitivePath(absPath) {
+		mention.Error = "is a sensitive file and can't be read"
+		return mention, true
+	}
+
+	data, err := os.ReadFile(absPath)
+	if err != nil {
+		mention.Error = "could not be read"
+		return mention, true
+	}
+	if !utf8.Valid(data) {
+		mention.Error = "is not a text file"
+		return mention, true
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	mention.TotalLines = len(lines)
+
+	if start != "" {
+		first, _ := strconv.Atoi(start)
+		last := first
+		if end != "" {
+			last, _ = strconv.Atoi(end)
+		}
+		if first < 1 || last < first || first > len(lines) {
+			mention.Error = fmt.Sprintf("line range %d-%d is not within the file's %d lines", first, last, len(lines))
+			return mention, true
+		}
+		if last > len(lines) {
+			last = len(lines)
+		}
+		mention.StartLine, mention.EndLine = first, last
+		lines = lines[first-1 : last]
+	}
+
+	var sb strings.Builder
+	for _, line := range lines {
+		if sb.Len()+len(line)+1 > maxMentionFileBytes {
+			mention.Truncated = true
+			break
+		}
+		sb.WriteString(line + "\n")
+	}
+	mention.Content = sb.String()
+
+	return mention, true
+}
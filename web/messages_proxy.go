@@ -0,0 +1,394 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"rcode/config"
+	"rcode/db"
+	"rcode/providers"
+	"rcode/tools"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/rweb"
+	"github.com/rohanthewiz/serr"
+)
+
+// proxyToolsHeader names RCode tools, comma separated, for the proxy to add to a request and run
+const proxyToolsHeader = "X-Rcode-Tools"
+
+// maxProxyToolTurns bounds the model requests the proxy makes for one request with RCode tools
+const maxProxyToolTurns = 25
+
+// proxyRequest is what the proxy reads of a messages request; the rest is passed on as it is
+type proxyRequest struct {
+	Model    string            `json:"model"`
+	Stream   bool              `json:"stream"`
+	System   json.RawMessage   `json:"system"`
+	Tools    []json.RawMessage `json:"tools"`
+	Messages []json.RawMessage `json:"messages"`
+}
+
+// proxyReply is what the proxy reads of a messages reply
+type proxyReply struct {
+	Content    []providers.Content `json:"content"`
+	StopReason string              `json:"stop_reason"`
+	Usage      providers.Usage     `json:"usage"`
+}
+
+// messagesProxyHandler serves POST /v1/messages, Anthropic's messages API, for other local
+// tools to send their requests through RCode's login. The request goes to the API as it is,
+// with RCode's system prompt before the caller's, and its usage is recorded against the
+// proxy's daily budget. Errors are written in the API's shape, which Anthropic's SDKs read.
+// A streamed reply is passed on once it is complete, as rweb can only end a response it
+// streams by closing the connection, which it leaves open.
+func messagesProxyHandler(c rweb.Context) error {
+	body := c.Request().Body()
+	var fields map[string]json.RawMessage
+	var req proxyRequest
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return writeProxyError(c, http.StatusBadRequest, "invalid_request_error", "the body must be a JSON object")
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return writeProxyError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+	}
+	if req.Model == "" {
+		return writeProxyError(c, http.StatusBadRequest, "invalid_request_error", "model is required")
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return writeProxyError(c, http.StatusInternalServerError, "api_error", "failed to get database")
+	}
+
+	used, budget, err := proxyBudgetUsed(database)
+	if err != nil {
+		logger.LogErr(err, "failed to check the proxy budget", "request_id", requestID(c))
+		return writeProxyError(c, http.StatusInternalServerError, "api_error", "failed to check the proxy's budget")
+	}
+	if budget > 0 && used >= budget {
+		return writeProxyError(c, http.StatusTooManyRequests, "rate_limit_error",
+			fmt.Sprintf("RCode's proxy has used its daily budget of %d tokens", budget))
+	}
+
+	system, err := proxySystemPrompt(req.System)
+	if err != nil {
+		return writeProxyError(c, http.StatusBadRequest, "invalid_request_error", "system must be a string or a list of text blocks")
+	}
+	fields["system"] = system
+
+	client := providers.NewAnthropicClient()
+	proxy := &messagesProxy{c: c, database: database, client: client, caller: proxyCaller(c), model: req.Model}
+
+	if names := proxyToolNames(c); len(names) > 0 {
+		if status, errType, message := checkProxyTools(c, names); status != 0 {
+			return writeProxyError(c, status, errType, message)
+		}
+		if req.Stream {
+			return writeProxyError(c, http.StatusBadRequest, "invalid_request_error", proxyToolsHeader+" needs a request without streaming")
+		}
+		return proxy.runWithTools(fields, req, names)
+	}
+
+	if body, err = json.Marshal(fields); err != nil {
+		return writeProxyError(c, http.StatusInternalServerError, "api_error", "failed to encode the request")
+	}
+	resp, err := client.Forward(body, req.Stream)
+	if err != nil {
+		logger.LogErr(err, "failed to forward proxy request", "request_id", requestID(c))
+		return writeProxyError(c, http.StatusBadGateway, "api_error", err.Error())
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return writeProxyError(c, http.StatusBadGateway, "api_error", "failed to read the API's reply")
+	}
+	if resp.StatusCode == http.StatusOK {
+		if req.Stream {
+			proxy.recordUsage(streamUsage(data))
+		} else {
+			var reply proxyReply
+			if err := json.Unmarshal(data, &reply); err == nil {
+				proxy.recordUsage(&reply.Usage)
+			}
+		}
+	}
+	return proxy.write(resp, data)
+}
+
+// messagesProxy answers one request through the proxy
+type messagesProxy struct {
+	c        rweb.Context
+	database *db.DB
+	client   *providers.AnthropicClient
+	caller   string
+	model    string
+}
+
+// write passes on a reply from the API with the headers that matter to the caller
+func (p *messagesProxy) write(resp *http.Response, data []byte) error {
+	copyProxyHeaders(p.c, resp.Header)
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+		p.c.Response().SetHeader("Content-Type", contentType)
+	}
+	p.c.Response().SetStatus(resp.StatusCode)
+	return p.c.Bytes(data)
+}
+
+// streamUsage adds up the usage a reply's stream of events reports
+func streamUsage(stream []byte) *providers.Usage {
+	usage := &providers.Usage{}
+	for _, line := range strings.Split(string(stream), "\n") {
+		data, ok := strings.CutPrefix(line, "data:")
+		if !ok {
+			continue
+		}
+		var event struct {
+			Type    string `json:"type"`
+			Message struct {
+				Usage providers.Usage `json:"usage"`
+			} `json:"message"`
+			Usage providers.Usage `json:"usage"`
+		}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &event); err != nil {
+			continue
+		}
+		switch event.Type {
+		case "message_start":
+			usage.InputTokens = event.Message.Usage.InputTokens
+			usage.OutputTokens = event.Message.Usage.OutputTokens
+		case "message_delta":
+			// The output tokens so far, not just this event's
+			usage.OutputTokens = event.Usage.OutputTokens
+		}
+	}
+	return usage
+}
+
+// runWithTools answers a request with the named RCode tools added to it. The proxy runs the
+// model's calls to them and sends back the results, until the model replies without calling
+// them, so the caller sees only that last reply. A reply that also calls the caller's own
+// tools is passed on as it is.
+func (p *messagesProxy) runWithTools(fields map[string]json.RawMessage, req proxyRequest, names []string) error {
+	registry, executor := newSessionTools(p.database, p.client)
+	available := make(map[string]tools.Tool)
+	for _, tool := range registry.GetTools() {
+		available[tool.Name] = tool
+	}
+
+	injected := make(map[string]bool)
+	toolDefs := make([]interface{}, 0, len(req.Tools)+len(names))
+	for _, tool := range req.Tools {
+		toolDefs = append(toolDefs, tool)
+	}
+	for _, name := range names {
+		tool, ok := available[name]
+		if !ok {
+			return writeProxyError(p.c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("RCode has no tool %q", name))
+		}
+		injected[name] = true
+		toolDefs = append(toolDefs, tool)
+	}
+
+	var err error
+	if fields["tools"], err = json.Marshal(toolDefs); err != nil {
+		return writeProxyError(p.c, http.StatusInternalServerError, "api_error", "failed to encode the tools")
+	}
+
+	messages := req.Messages
+	for turn := 1; ; turn++ {
+		if fields["messages"], err = json.Marshal(messages); err != nil {
+			return writeProxyError(p.c, http.StatusInternalServerError, "api_error", "failed to encode the messages")
+		}
+		body, err := json.Marshal(fields)
+		if err != nil {
+			return writeProxyError(p.c, http.StatusInternalServerError, "api_error", "failed to encode the request")
+		}
+
+		resp, err := p.client.Forward(body, false)
+		if err != nil {
+			logger.LogErr(err, "failed to forward proxy request", "request_id", requestID(p.c))
+			return writeProxyError(p.c, http.StatusBadGateway, "api_error", err.Error())
+		}
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return writeProxyError(p.c, http.StatusBadGateway, "api_error", "failed to read the API's reply")
+		}
+		if resp.StatusCode != http.StatusOK {
+			return p.write(resp, data)
+		}
+
+		var reply proxyReply
+		var raw struct {
+			Content json.RawMessage `json:"content"`
+		}
+		if err := json.Unmarshal(data, &reply); err != nil {
+			return p.write(resp, data)
+		}
+		_ = json.Unmarshal(data, &raw)
+		p.recordUsage(&reply.Usage)
+
+		var calls []providers.Content
+		callsOwnTools := false
+		for _, content := range reply.Content {
+			if content.Type != "tool_use" {
+				continue
+			}
+			if injected[content.Name] {
+				calls = append(calls, content)
+			} else {
+				callsOwnTools = true
+			}
+		}
+		if reply.StopReason != "tool_use" || len(calls) == 0 || callsOwnTools || turn == maxProxyToolTurns {
+			return p.write(resp, data)
+		}
+
+		results := make([]tools.ToolResult, 0, len(calls))
+		for _, call := range calls {
+			input, _ := call.Input.(map[string]interface{})
+			if input == nil {
+				input = make(map[string]interface{})
+			}
+			logger.Info("Running tool for proxy caller", "caller", p.caller, "tool", call.Name, "request_id", requestID(p.c))
+
+			// Without a session ID the executor doesn't ask for permission; checkProxyTools let
+			// through only tools that leave the project alone, for an admin token
+			start := time.Now()
+			result, err := executor.Execute(tools.ToolUse{Type: "tool_use", ID: call.ID, Name: call.Name, Input: input})
+			observeTool(call.Name, time.Since(start), err)
+			if result == nil {
+				result = &tools.ToolResult{Type: "tool_result", ToolUseID: call.ID, Content: "Tool execution failed"}
+				if err != nil {
+					result.Content += ": " + err.Error()
+				}
+			}
+			results = append(results, *result)
+		}
+
+		assistant, err := json.Marshal(map[string]interface{}{"role": "assistant", "content": raw.Content})
+		if err != nil {
+			return writeProxyError(p.c, http.StatusInternalServerError, "api_error", "failed to encode the reply")
+		}
+		user, err := json.Marshal(map[string]interface{}{"role": "user", "content": results})
+		if err != nil {
+			return writeProxyError(p.c, http.StatusInternalServerError, "api_error", "failed to encode the tool results")
+		}
+		messages = append(messages, assistant, user)
+	}
+}
+
+// recordUsage records tokens the caller used
+func (p *messagesProxy) recordUsage(usage *providers.Usage) {
+	if usage.InputTokens == 0 && usage.OutputTokens == 0 {
+		return
+	}
+	if err := p.database.RecordProxyUsage(p.caller, p.model, usage); err != nil {
+		logger.LogErr(err, "failed to record proxy usage", "request_id", requestID(p.c))
+	}
+	countTokens(p.model, "proxy", usage)
+}
+
+// proxyBudgetUsed returns the tokens used through the proxy today and its daily budget,
+// 0 if it has none
+func proxyBudgetUsed(database *db.DB) (used, budget int, err error) {
+	now := time.Now()
+	input, output, err := database.GetProxyUsageSince(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()))
+	if err != nil {
+		return 0, 0, err
+	}
+	return input + output, config.Get().ProxyDailyTokens, nil
+}
+
+// proxySystemPrompt puts RCode's system prompt, which its login requires, before the caller's
+func proxySystemPrompt(system json.RawMessage) (json.RawMessage, error) {
+	blocks := []interface{}{providers.SystemBlock{Type: "text", Text: systemPrompt}}
+
+	var text string
+	var callerBlocks []json.RawMessage
+	switch {
+	case len(system) == 0 || string(system) == "null":
+		return json.Marshal(systemPrompt)
+	case json.Unmarshal(system, &text) == nil:
+		if text == systemPrompt {
+			return system, nil
+		}
+		blocks = append(blocks, providers.SystemBlock{Type: "text", Text: text})
+	case json.Unmarshal(system, &callerBlocks) == nil:
+		var first providers.SystemBlock
+		if len(callerBlocks) > 0 && json.Unmarshal(callerBlocks[0], &first) == nil && first.Text == systemPrompt {
+			return system, nil
+		}
+		for _, block := range callerBlocks {
+			blocks = append(blocks, block)
+		}
+	default:
+		return nil, serr.New("invalid system prompt")
+	}
+	return json.Marshal(blocks)
+}
+
+// proxyToolNames returns the RCode tools a request asks the proxy to add
+func proxyToolNames(c rweb.Context) []string {
+	var names []string
+	for _, name := range strings.Split(c.Request().Header(proxyToolsHeader), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// checkProxyTools refuses RCode tools to a caller that may not have them run. Their calls run
+// without a session, so without permission rules, read-only mode or asking, which only an
+// admin token may have, and then only for tools that leave the project alone. It returns the
+// status, error type and message of the refusal, or a zero status.
+func checkProxyTools(c rweb.Context, names []string) (int, string, string) {
+	apiToken, ok := c.Get(apiTokenContextKey).(*db.APIToken)
+	if !ok || apiToken.Scope != db.ScopeAdmin {
+		return http.StatusForbidden, "permission_error", proxyToolsHeader + " needs an API token with the admin scope"
+	}
+	for _, name := range names {
+		if !parallelSafeTools[name] {
+			return http.StatusForbidden, "permission_error",
+				fmt.Sprintf("%s can't run %q, only tools that leave the project alone", proxyToolsHeader, name)
+		}
+	}
+	return 0, "", ""
+}
+
+// proxyCaller names who a request is from in the usage records: its API token, otherwise
+// its signed-in user
+func proxyCaller(c rweb.Context) string {
+	if apiToken, ok := c.Get(apiTokenContextKey).(*db.APIToken); ok {
+		return "token:" + apiToken.Name
+	}
+	if user := currentUser(c); user != nil {
+		return "user:" + user.Username
+	}
+	return "local"
+}
+
+// copyProxyHeaders passes on the API's rate limit and request ID headers
+func copyProxyHeaders(c rweb.Context, headers http.Header) {
+	for name, values := range headers {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "anthropic-") || lower == "retry-after" || lower == "request-id" {
+			c.Response().SetHeader(name, values[0])
+		}
+	}
+}
+
+// writeProxyError writes an error in the messages API's shape
+func writeProxyError(c rweb.Context, status int, errType, message string) error {
+	c.Response().SetStatus(status)
+	return c.WriteJSON(map[string]interface{}{
+		"type":  "error",
+		"error": map[string]string{"type": errType, "message": message},
+	})
+}
@@ -0,0 +1,61 @@
+package web
+
+import (
+	"bytes"
+	"strconv"
+	"time"
+
+	"github.com/rohanthewiz/rweb"
+	"rcode/metrics"
+	"rcode/providers"
+)
+
+// sseClients reports the pages and clients connected for server-sent events
+var sseClients = metrics.NewGaugeFunc("rcode_sse_clients",
+	"Clients connected for server-sent events.", func() float64 {
+		sseHub.mu.RLock()
+		defer sseHub.mu.RUnlock()
+		return float64(len(sseHub.clients))
+	})
+
+// observeRequests counts and times every request. Event streams are timed until their
+// handler has set them up, as rweb streams the events after the handler returns.
+func observeRequests(c rweb.Context) error {
+	start := time.Now()
+	err := c.Next()
+
+	method := c.Request().Method()
+	status := c.Response().Status()
+	if status == 0 {
+		status = 200
+	}
+	metrics.HTTPRequests.Inc(method, strconv.Itoa(status))
+	metrics.HTTPRequestDuration.Observe(time.Since(start).Seconds(), method)
+	return err
+}
+
+// metricsHandler serves the metrics in Prometheus' text exposition format
+func metricsHandler(c rweb.Context) error {
+	var buf bytes.Buffer
+	metrics.Write(&buf)
+	c.Response().SetHeader("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	return c.Bytes(buf.Bytes())
+}
+
+// observeTool records how long a tool the model called took, and whether it failed
+func observeTool(name string, duration time.Duration, err error) {
+	status := "success"
+	if err != nil {
+		status = "error"
+	}
+	metrics.ToolDuration.Observe(duration.Seconds(), name, status)
+}
+
+// countTokens adds a reply's token usage to the metrics. source is "session" or "proxy".
+func countTokens(model, source string, usage *providers.Usage) {
+	if usage == nil {
+		return
+	}
+	metrics.Tokens.Add(float64(usage.InputTokens), model, "input", source)
+	metrics.Tokens.Add(float64(usage.OutputTokens), model, "output", source)
+}
@@ -0,0 +1,65 @@
+package web
+
+import (
+	"strings"
+
+	"rcode/providers"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/serr"
+)
+
+// Model used for one-off requests such as planning and commit messages
+const planningModel = "claude-sonnet-4-20250514"
+
+// anthropicModelClient adapts the Anthropic client to completion.Client
+type anthropicModelClient struct {
+	client *providers.AnthropicClient
+}
+
+func newAnthropicModelClient() *anthropicModelClient {
+	return &anthropicModelClient{client: providers.NewAnthropicClient()}
+}
+
+// Complete sends prompt as a single user message and returns the concatenated text reply
+func (m *anthropicModelClient) Complete(prompt string) (string, error) {
+	// The system prompt must stay fixed, so all instructions travel in the user message
+	request := providers.CreateMessageRequest{
+		Model:     planningModel,
+		Messages:  []providers.Message{providers.CreateTextMessage("user", prompt)},
+		MaxTokens: 4096,
+		System:    "You are Claude Code, Anthropic's official CLI for Claude.",
+	}
+
+	// Fall back to the next models in the chain while the API can't serve this one
+	fallbacks := fallbackModels(planningModel)
+	var response *providers.CreateMessageResponse
+	var err error
+	for {
+		request.Retries = 0
+		if len(fallbacks) > 0 {
+			request.Retries = fallbackRetries
+		}
+		response, err = m.client.SendMessageWithRetry(request)
+		if err == nil || len(fallbacks) == 0 || !shouldFallBack(err) {
+			break
+		}
+		logger.Warn("Model unavailable, falling back", "model", request.Model, "fallback", fallbacks[0], "error", err.Error())
+		request.Model, fallbacks = fallbacks[0], fallbacks[1:]
+	}
+	if err != nil {
+		return "", serr.Wrap(err, "failed to send model request")
+	}
+
+	var sb strings.Builder
+	for _, content := range response.Content {
+		if content.Type == "text" {
+			sb.WriteString(content.Text)
+		}
+	}
+
+	if sb.Len() == 0 {
+		return "", serr.New("model returned no text")
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,48 @@
+package web
+
+import (
+	"errors"
+	"strings"
+
+	"rcode/config"
+	"rcode/tools"
+)
+
+// fallbackRetries is how often a request to a model with fallbacks is retried before the
+// next model is tried. The last model in the chain gets the full retry policy.
+const fallbackRetries = 1
+
+// fallbackModels returns the models to try, in order, when the API can't serve model:
+// those after it in the configured chain. Models not in the chain don't fall back.
+func fallbackModels(model string) []string {
+	chain := config.Get().ModelFallbacks
+	for i, name := range chain {
+		if resolveModel(name) != model {
+			continue
+		}
+		var fallbacks []string
+		for _, next := range chain[i+1:] {
+			if id := resolveModel(next); id != model {
+				fallbacks = append(fallbacks, id)
+			}
+		}
+		return fallbacks
+	}
+	return nil
+}
+
+// resolveModel returns the model ID of an alias, or the name as it is
+func resolveModel(name string) string {
+	if id, ok := modelAliases[strings.ToLower(name)]; ok {
+		return id
+	}
+	return name
+}
+
+// shouldFallBack reports whether a failed model request may go to another model: the API
+// was overloaded, rate limited it or had a server error, rather than refusing the request
+func shouldFallBack(err error) bool {
+	var retryable *tools.RetryableError
+	var rateLimit *tools.RateLimitError
+	return errors.As(err, &retryable) || errors.As(err, &rateLimit)
+}
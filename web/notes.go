@@ -0,0 +1,213 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"rcode/db"
+	"rcode/tools"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/rweb"
+	"github.com/rohanthewiz/serr"
+)
+
+// noteTools keep only the session's own notes, so they run without asking unless denied
+var noteTools = map[string]bool{
+	"add_note":   true,
+	"read_notes": true,
+}
+
+// NoteRequest adds a note, or changes one: fields left out keep their value
+type NoteRequest struct {
+	Content   *string `json:"content,omitempty"`
+	InContext *bool   `json:"in_context,omitempty"` // Send the note with every request
+}
+
+// NotesUpdate is a session's notes, as sent to the pages when they change
+type NotesUpdate struct {
+	Notes []*db.SessionNote `json:"notes"`
+}
+
+// dbNoteStore keeps the note tools' notes in the session_notes table
+type dbNoteStore struct{}
+
+// AddNote appends a note, out of context until the user chooses to send it
+func (dbNoteStore) AddNote(sessionID, content string) (int, error) {
+	database, err := db.GetDB()
+	if err != nil {
+		return 0, serr.Wrap(err, "failed to get database")
+	}
+
+	note := &db.SessionNote{SessionID: sessionID, Content: content}
+	if err := database.AddNote(note); err != nil {
+		return 0, err
+	}
+
+	broadcastNotes(database, sessionID)
+	return note.ID, nil
+}
+
+// ReadNotes returns the session's notes
+func (dbNoteStore) ReadNotes(sessionID string) ([]tools.Note, error) {
+	database, err := db.GetDB()
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to get database")
+	}
+	notes, err := database.GetNotes(sessionID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]tools.Note, 0, len(notes))
+	for _, note := range notes {
+		result = append(result, tools.Note{ID: note.ID, Content: note.Content, InContext: note.InContext})
+	}
+	return result, nil
+}
+
+// InitNoteStore gives the note tools the database
+func InitNoteStore() {
+	tools.SetNoteStore(dbNoteStore{})
+}
+
+// broadcastNotes shows the session's notes on its pages
+func broadcastNotes(database *db.DB, sessionID string) {
+	notes, err := database.GetNotes(sessionID, false)
+	if err != nil {
+		logger.LogErr(err, "failed to get notes to broadcast", "session_id", sessionID)
+		return
+	}
+	BroadcastSessionUpdate(sessionID, "notes_updated", NotesUpdate{Notes: notes})
+}
+
+// sessionNotesPrompt returns the session's notes in context, for the system prompt, or ""
+// when it has none
+func sessionNotesPrompt(database *db.DB, sessionID string) string {
+	notes, err := database.GetNotes(sessionID, true)
+	if err != nil {
+		logger.LogErr(err, "failed to get session notes in context")
+		return ""
+	}
+	if len(notes) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("## Session Notes\nNotes kept for this session, which the user chose to keep in context:\n")
+	for _, note := range notes {
+		fmt.Fprintf(&sb, "\n### Note %d\n%s\n", note.ID, note.Content)
+	}
+	return sb.String()
+}
+
+// listSessionNotesHandler returns a session's notes, oldest first
+func listSessionNotesHandler(c rweb.Context) error {
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	notes, err := database.GetNotes(c.Request().Param("id"), false)
+	if err != nil {
+		return c.WriteError(err, 500)
+	}
+	return c.WriteJSON(notes)
+}
+
+// addSessionNoteHandler adds the user's note to a session
+func addSessionNoteHandler(c rweb.Context) error {
+	sessionID := c.Request().Param("id")
+
+	var req NoteRequest
+	if err := json.Unmarshal(c.Request().Body(), &req); err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid request body"), 400)
+	}
+	if req.Content == nil {
+		return c.WriteError(serr.New("note content is required"), 400)
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	note := &db.SessionNote{SessionID: sessionID, Content: *req.Content, InContext: req.InContext != nil && *req.InContext}
+	if err := database.AddNote(note); err != nil {
+		return c.WriteError(err, 400)
+	}
+
+	broadcastNotes(database, sessionID)
+	return c.WriteJSON(note)
+}
+
+// updateSessionNoteHandler edits a session's note, or sets whether it is sent with every request
+func updateSessionNoteHandler(c rweb.Context) error {
+	sessionID := c.Request().Param("id")
+	id, err := strconv.Atoi(c.Request().Param("noteId"))
+	if err != nil {
+		return c.WriteError(serr.New("invalid note ID"), 400)
+	}
+
+	var req NoteRequest
+	if err := json.Unmarshal(c.Request().Body(), &req); err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid request body"), 400)
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	note, err := database.GetNote(sessionID, id)
+	if err != nil {
+		return c.WriteError(err, 500)
+	}
+	if note == nil {
+		return c.WriteError(serr.New("note not found"), 404)
+	}
+	if req.Content != nil {
+		note.Content = *req.Content
+	}
+	if req.InContext != nil {
+		note.InContext = *req.InContext
+	}
+
+	updated, err := database.UpdateNote(note)
+	if err != nil {
+		return c.WriteError(err, 400)
+	}
+	if !updated {
+		return c.WriteError(serr.New("note not found"), 404)
+	}
+
+	broadcastNotes(database, sessionID)
+	return c.WriteJSON(note)
+}
+
+// deleteSessionNoteHandler removes a session's note
+func deleteSessionNoteHandler(c rweb.Context) error {
+	sessionID := c.Request().Param("id")
+	id, err := strconv.Atoi(c.Request().Param("noteId"))
+	if err != nil {
+		return c.WriteError(serr.New("invalid note ID"), 400)
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	deleted, err := database.DeleteNote(sessionID, id)
+	if err != nil {
+		return c.WriteError(err, 500)
+	}
+	if !deleted {
+		return c.WriteError(serr.New("note not found"), 404)
+	}
+
+	broadcastNotes(database, sessionID)
+	return c.WriteJSON(map[string]bool{"success": true})
+}
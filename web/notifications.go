@@ -0,0 +1,209 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"rcode/config"
+	"rcode/db"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/serr"
+)
+
+// Events that notify; RCODE_NOTIFY_EVENTS selects among them
+const (
+	NotifyPlanCompleted     = "plan_completed"
+	NotifyPlanFailed        = "plan_failed"
+	NotifyPermissionRequest = "permission_request"
+	NotifyBudget            = "budget"
+)
+
+// permissionNotifyDelay is how long a permission request waits unanswered before the
+// webhook and Slack hear of it. The pages notify at once, when they aren't focused.
+const permissionNotifyDelay = time.Minute
+
+// budgetLevels are the fractions of RCODE_DAILY_BUDGET whose crossing notifies
+var budgetLevels = []float64{0.5, 0.8, 1}
+
+// notifyClient sends notifications to the webhook and Slack
+var notifyClient = &http.Client{Timeout: 10 * time.Second}
+
+// Notification is something the user is told of outside the chat
+type Notification struct {
+	Event     string    `json:"event"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	SessionID string    `json:"session_id,omitempty"`
+	PlanID    string    `json:"plan_id,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// notify sends a notification to the pages, which show it as a desktop notification,
+// and to the webhook and Slack, if its event is one RCODE_NOTIFY_EVENTS selects
+func notify(n Notification) {
+	if !slices.Contains(config.Get().NotifyEvents, n.Event) {
+		return
+	}
+	n.Time = time.Now()
+
+	broadcastNotification(n)
+	go notifyRemote(n)
+}
+
+// broadcastNotification sends a notification to the pages, which show it while they aren't focused
+func broadcastNotification(n Notification) {
+	sseHub.Broadcast(SSEEvent{
+		Type:      "notification",
+		SessionId: n.SessionID,
+		Data:      n,
+	})
+}
+
+// notifyRemote posts a notification to the webhook and Slack that are set
+func notifyRemote(n Notification) {
+	cfg := config.Get()
+
+	if cfg.NotifyWebhook != "" {
+		if err := postNotification(cfg.NotifyWebhook, n); err != nil {
+			logger.LogErr(err, "failed to send notification to webhook", "event", n.Event)
+		}
+	}
+	if cfg.NotifySlack != "" {
+		// Slack's incoming webhooks take the message as text, in its markdown
+		message := map[string]string{"text": fmt.Sprintf("*%s*\n%s", n.Title, n.Body)}
+		if err := postNotification(cfg.NotifySlack, message); err != nil {
+			logger.LogErr(err, "failed to send notification to Slack", "event", n.Event)
+		}
+	}
+}
+
+// postNotification POSTs a payload as JSON. Any status other than 2xx counts as failure.
+func postNotification(url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return serr.Wrap(err, "failed to marshal notification")
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return serr.Wrap(err, "invalid notification url")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := notifyClient.Do(req)
+	if err != nil {
+		return serr.Wrap(err, "notification request failed")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return serr.New(fmt.Sprintf("notification endpoint returned %s", resp.Status))
+	}
+	return nil
+}
+
+// notifyPlanFinished notifies that a plan completed or failed
+func notifyPlanFinished(sessionID, planID, description string, planErr error) {
+	if planErr != nil {
+		notify(Notification{
+			Event:     NotifyPlanFailed,
+			Title:     "Plan failed",
+			Body:      fmt.Sprintf("%s: %s", description, planErr.Error()),
+			SessionID: sessionID,
+			PlanID:    planID,
+		})
+		return
+	}
+	notify(Notification{
+		Event:     NotifyPlanCompleted,
+		Title:     "Plan completed",
+		Body:      description,
+		SessionID: sessionID,
+		PlanID:    planID,
+	})
+}
+
+// notifyPermissionRequest tells the pages of a permission request at once, and the
+// webhook and Slack if it is still unanswered after permissionNotifyDelay
+func notifyPermissionRequest(request *PermissionRequest) {
+	cfg := config.Get()
+	if !slices.Contains(cfg.NotifyEvents, NotifyPermissionRequest) {
+		return
+	}
+
+	n := Notification{
+		Event:     NotifyPermissionRequest,
+		Title:     "Permission needed",
+		Body:      fmt.Sprintf("%s is waiting for permission to run", request.ToolName),
+		SessionID: request.SessionID,
+		Time:      time.Now(),
+	}
+	broadcastNotification(n)
+	if cfg.NotifyWebhook == "" && cfg.NotifySlack == "" {
+		return
+	}
+
+	time.AfterFunc(permissionNotifyDelay, func() {
+		if _, pending := permissionManager.GetRequest(request.ID); pending {
+			notifyRemote(n)
+		}
+	})
+}
+
+// budgetNotified records the highest budget level notified of today
+var budgetNotified struct {
+	sync.Mutex
+	day   string
+	level float64
+}
+
+// checkDailyBudget notifies when today's model usage crosses one of the budgetLevels of
+// RCODE_DAILY_BUDGET. Each level notifies once a day.
+func checkDailyBudget(database *db.DB) {
+	budget := config.Get().DailyBudget
+	if budget == 0 {
+		return
+	}
+
+	usageByModel, err := database.GetDailyUsage()
+	if err != nil {
+		logger.LogErr(err, "failed to get daily usage for the budget")
+		return
+	}
+	spent := 0.0
+	for model, usage := range usageByModel {
+		spent += usageCost(model, usage.Input, usage.Output)
+	}
+
+	crossed := 0.0
+	for _, level := range budgetLevels {
+		if spent >= budget*level {
+			crossed = level
+		}
+	}
+
+	budgetNotified.Lock()
+	today := time.Now().Format(time.DateOnly)
+	if budgetNotified.day != today {
+		budgetNotified.day, budgetNotified.level = today, 0
+	}
+	if crossed <= budgetNotified.level {
+		budgetNotified.Unlock()
+		return
+	}
+	budgetNotified.level = crossed
+	budgetNotified.Unlock()
+
+	notify(Notification{
+		Event: NotifyBudget,
+		Title: fmt.Sprintf("%.0f%% of the daily budget used", crossed*100),
+		Body:  fmt.Sprintf("$%.2f of today's $%.2f budget has been spent on models", spent, budget),
+	})
+}
@@ -0,0 +1,265 @@
+package web
+
+import (
+	"time"
+
+	"rcode/auth"
+	"rcode/context"
+	"rcode/db"
+	"rcode/openapi"
+	"rcode/planner"
+	"rcode/providers"
+
+	"github.com/rohanthewiz/rweb"
+)
+
+// apiRoute is an endpoint of the documented API
+type apiRoute struct {
+	openapi.Route
+	handler rweb.Handler
+}
+
+// apiRoutes are the routes described by the OpenAPI spec, from which the Go client in
+// client/ is generated. SetupRoutes registers them from here, so the spec can't drift
+// from what the server serves. Regenerate the client after changing them.
+var apiRoutes = []apiRoute{
+	// Sessions
+	{openapi.Route{Method: "GET", Path: "/api/session", Tag: "sessions", Operation: "ListSessions",
+		Summary: "Lists the sessions, most recently used first", Response: []Session{}}, listSessionsHandler},
+	{openapi.Route{Method: "POST", Path: "/api/session", Tag: "sessions", Operation: "CreateSession",
+		Summary: "Starts a session", Request: CreateSessionRequest{}, Response: Session{}}, createSessionHandler},
+	{openapi.Route{Method: "DELETE", Path: "/api/session/:id", Tag: "sessions", Operation: "DeleteSession",
+		Summary: "Deletes a session and its messages", Response: map[string]bool{}}, deleteSessionHandler},
+	{openapi.Route{Method: "POST", Path: "/api/session/:id/fork", Tag: "sessions", Operation: "ForkSession",
+		Summary: "Copies a session's messages, up to message_index, into a new session",
+		Request: ForkSessionRequest{}, Response: Session{}}, forkSessionHandler},
+	{openapi.Route{Method: "POST", Path: "/api/session/:id/close", Tag: "sessions", Operation: "CloseSession",
+		Summary:  "Closes a session, writing a recap of its key changes, open TODOs and decisions that a new session on the project can start from with recap_id",
+		Response: SessionClosed{}}, closeSessionHandler},
+	{openapi.Route{Method: "PUT", Path: "/api/session/:id/account", Tag: "sessions", Operation: "SetSessionAccount",
+		Summary: "Sets the Claude account the session's requests use; an empty account uses the default one",
+		Request: AccountUpdate{}, Response: AccountUpdate{}}, setSessionAccountHandler},
+	{openapi.Route{Method: "PUT", Path: "/api/session/:id/package", Tag: "sessions", Operation: "SetSessionPackage",
+		Summary: "Scopes the session to a monorepo package, by path or name, repacking its context from the package's files; an empty package scopes it to the whole repository",
+		Request: PackageUpdate{}, Response: PackageUpdate{}}, setSessionPackageHandler},
+	{openapi.Route{Method: "GET", Path: "/api/session/:id/todos", Tag: "sessions", Operation: "GetSessionTodos",
+		Summary:  "Returns the task list the model keeps for the session with todo_write; changes are sent over /events as todos_updated",
+		Response: TodosUpdate{}}, getSessionTodosHandler},
+	{openapi.Route{Method: "GET", Path: "/api/session/:id/notes", Tag: "sessions", Operation: "ListSessionNotes",
+		Summary:  "Lists the notes kept for the session apart from its messages, oldest first; changes are sent over /events as notes_updated",
+		Response: []db.SessionNote{}}, listSessionNotesHandler},
+	{openapi.Route{Method: "POST", Path: "/api/session/:id/notes", Tag: "sessions", Operation: "AddSessionNote",
+		Summary: "Adds a note to the session; with in_context, it is sent with every request",
+		Request: NoteRequest{}, Response: db.SessionNote{}}, addSessionNoteHandler},
+	{openapi.Route{Method: "PUT", Path: "/api/session/:id/notes/:noteId", Tag: "sessions", Operation: "UpdateSessionNote",
+		Summary: "Edits a session's note, or sets whether it is sent with every request",
+		Request: NoteRequest{}, Response: db.SessionNote{}}, updateSessionNoteHandler},
+	{openapi.Route{Method: "DELETE", Path: "/api/session/:id/notes/:noteId", Tag: "sessions", Operation: "DeleteSessionNote",
+		Summary: "Deletes a session's note", Response: map[string]bool{}}, deleteSessionNoteHandler},
+	{openapi.Route{Method: "GET", Path: "/api/session/:id/benchmarks", Tag: "sessions", Operation: "ListSessionBenchmarks",
+		Summary:  "Lists the benchmark runs the bench tool kept for the session, oldest first, with their results in Go's benchmark format",
+		Response: []db.BenchmarkRun{}}, listSessionBenchmarksHandler},
+	{openapi.Route{Method: "GET", Path: "/api/accounts", Tag: "sessions", Operation: "ListAccounts",
+		Summary:  "Lists the Claude accounts logged in, with when their tokens expire and whether they still refresh",
+		Response: []auth.AccountInfo{}}, auth.ListAccountsHandler},
+
+	// Messages
+	{openapi.Route{Method: "GET", Path: "/api/session/:id/messages", Tag: "messages", Operation: "ListMessages",
+		Summary: "Returns a session's messages", Response: []providers.ChatMessage{}}, getSessionMessagesHandler},
+	{openapi.Route{Method: "POST", Path: "/api/session/:id/message", Tag: "messages", Operation: "SendMessage",
+		Summary: "Sends a message, or a slash command, and returns once the model has replied. The reply and tool activity stream over /events as they happen. " +
+			"While the session's turn is in progress, the message is queued instead: it returns 202 with the queueId, and is sent when the turn finishes.",
+		Request: MessageRequest{}, Response: MessageReply{}}, sendMessageHandler},
+	{openapi.Route{Method: "GET", Path: "/api/session/:id/queue", Tag: "messages", Operation: "ListQueuedMessages",
+		Summary:  "Lists the messages waiting for the session's turn in progress, in the order they will be sent",
+		Response: []QueuedMessage{}}, listQueuedMessagesHandler},
+	{openapi.Route{Method: "DELETE", Path: "/api/session/:id/queue/:queueId", Tag: "messages", Operation: "CancelQueuedMessage",
+		Summary: "Removes a message from the session's queue before it is sent", Response: map[string]bool{}}, cancelQueuedMessageHandler},
+	{openapi.Route{Method: "GET", Path: "/api/commands", Tag: "messages", Operation: "ListCommands",
+		Summary: "Lists the slash commands", Response: map[string][]SlashCommandDefinition{}}, listCommandsHandler},
+
+	// Tools and their permissions
+	{openapi.Route{Method: "GET", Path: "/api/session/:id/tools", Tag: "tools", Operation: "ListSessionTools",
+		Summary: "Lists the tools with their permissions in a session", Response: []ToolInfo{}}, getSessionToolsHandler},
+	{openapi.Route{Method: "PUT", Path: "/api/session/:id/tools/:tool", Tag: "tools", Operation: "UpdateToolPermission",
+		Summary: "Sets whether a tool may run in a session, and whether it asks first",
+		Request: ToolPermissionUpdate{}, Response: map[string]interface{}{}}, updateToolPermissionHandler},
+	{openapi.Route{Method: "PUT", Path: "/api/session/:id/read-only", Tag: "tools", Operation: "SetSessionReadOnly",
+		Summary: "Turns read-only mode on or off: tools that change the project are denied, and shell commands ask",
+		Request: ReadOnlyUpdate{}, Response: ReadOnlyUpdate{}}, setSessionReadOnlyHandler},
+	{openapi.Route{Method: "PUT", Path: "/api/session/:id/auto-accept", Tag: "tools", Operation: "SetSessionAutoAccept",
+		Summary: "Turns auto-accept mode on or off: tools run without asking, within limits on what a turn changes",
+		Request: AutoAcceptUpdate{}, Response: AutoAcceptUpdate{}}, setSessionAutoAcceptHandler},
+	{openapi.Route{Method: "GET", Path: "/api/session/:id/permission-rules", Tag: "tools", Operation: "ListPermissionRules",
+		Summary:  "Lists the session's rules deciding tool calls by their arguments; the user's own are in their settings",
+		Response: []db.PermissionRule{}}, getPermissionRulesHandler},
+	{openapi.Route{Method: "PUT", Path: "/api/session/:id/permission-rules", Tag: "tools", Operation: "SetPermissionRules",
+		Summary: "Replaces the session's permission rules",
+		Request: []db.PermissionRule{}, Response: []db.PermissionRule{}}, setPermissionRulesHandler},
+	{openapi.Route{Method: "GET", Path: "/api/tasks", Tag: "tools", Operation: "ListProjectTasks",
+		Summary: "Lists the tasks the project defines, which run_task runs: its Makefile targets, justfile recipes and package.json scripts",
+		Query: []openapi.Parameter{
+			queryParam("package", "string", "A monorepo package, by path or name, whose tasks to list (default the project root's)"),
+		},
+		Response: []context.ProjectTask{}}, listProjectTasksHandler},
+	{openapi.Route{Method: "POST", Path: "/api/permission-response", Tag: "tools", Operation: "RespondToPermission",
+		Summary: "Answers a tool's permission request, sent as a permission_request event",
+		Request: PermissionResponse{}, Response: map[string]interface{}{}}, handlePermissionResponseHandler},
+	{openapi.Route{Method: "POST", Path: "/api/permission-abort", Tag: "tools", Operation: "AbortPermission",
+		Summary: "Denies a pending permission request, if given, and tells the model to stop",
+		Request: PermissionAbortRequest{}, Response: map[string]interface{}{}}, handlePermissionAbortHandler},
+
+	// Plans
+	{openapi.Route{Method: "POST", Path: "/api/session/:id/plan", Tag: "plans", Operation: "CreatePlan",
+		Summary: "Breaks a task down into a plan of tool steps", Request: CreatePlanRequest{}, Response: PlanResponse{}}, createPlanHandler},
+	{openapi.Route{Method: "GET", Path: "/api/session/:id/plans", Tag: "plans", Operation: "ListPlans",
+		Summary: "Lists a session's plans", Response: []PlanResponse{}}, listPlansHandler},
+	{openapi.Route{Method: "GET", Path: "/api/session/:id/plans/history", Tag: "plans", Operation: "ListPlanHistory",
+		Summary: "Returns a page of a session's plans, optionally filtered",
+		Query: []openapi.Parameter{
+			queryParam("page", "integer", "The page, from 1"),
+			queryParam("limit", "integer", "Plans per page, up to 100 (default 20)"),
+			queryParam("status", "string", "Only plans with this status"),
+			queryParam("search", "string", "Only plans whose description contains this"),
+		},
+		Response: map[string]interface{}{}}, listPlanHistoryHandler},
+	{openapi.Route{Method: "GET", Path: "/api/plan/:id/full", Tag: "plans", Operation: "GetPlan",
+		Summary: "Returns a plan with its steps, checkpoints and execution statistics", Response: map[string]interface{}{}}, getPlanFullDetailsHandler},
+	{openapi.Route{Method: "GET", Path: "/api/plan/:id/status", Tag: "plans", Operation: "GetPlanStatus",
+		Summary: "Returns a plan's status, executions and metrics", Response: map[string]interface{}{}}, getPlanStatusHandler},
+	{openapi.Route{Method: "POST", Path: "/api/plan/:id/execute", Tag: "plans", Operation: "ExecutePlan",
+		Summary: "Starts executing a plan; progress is sent as plan events", Response: PlanActionResponse{}}, executePlanHandler},
+	{openapi.Route{Method: "POST", Path: "/api/plan/:id/cancel", Tag: "plans", Operation: "CancelPlan",
+		Summary: "Stops an executing plan, or cancels one that hasn't started", Response: PlanActionResponse{}}, cancelPlanHandler},
+	{openapi.Route{Method: "POST", Path: "/api/plan/:id/dry-run", Tag: "plans", Operation: "DryRunPlan",
+		Summary: "Reports what a plan would do, without running it", Response: planner.DryRunReport{}}, dryRunPlanHandler},
+	{openapi.Route{Method: "POST", Path: "/api/plan/:id/rollback", Tag: "plans", Operation: "RollbackPlan",
+		Summary: "Restores the files a plan changed to a checkpoint", Request: RollbackPlanRequest{}, Response: PlanActionResponse{}}, rollbackPlanHandler},
+	{openapi.Route{Method: "GET", Path: "/api/plan/:id/checkpoints", Tag: "plans", Operation: "ListCheckpoints",
+		Summary: "Lists a plan's checkpoints", Response: []planner.Checkpoint{}}, listCheckpointsHandler},
+	{openapi.Route{Method: "POST", Path: "/api/plan/:id/clone", Tag: "plans", Operation: "ClonePlan",
+		Summary: "Copies a plan's steps into a new pending plan", Response: map[string]interface{}{}}, clonePlanHandler},
+	{openapi.Route{Method: "DELETE", Path: "/api/plan/:id", Tag: "plans", Operation: "DeletePlan",
+		Summary: "Deletes a plan", Response: PlanActionResponse{}}, deletePlanHandler},
+	{openapi.Route{Method: "PUT", Path: "/api/plan/:id/steps", Tag: "plans", Operation: "ReorderPlanSteps",
+		Summary: "Rearranges the steps of a pending plan", Request: ReorderStepsRequest{}, Response: PlanResponse{}}, reorderPlanStepsHandler},
+	{openapi.Route{Method: "POST", Path: "/api/plan/:id/steps", Tag: "plans", Operation: "InsertPlanStep",
+		Summary: "Adds a step to a pending plan", Request: InsertStepRequest{}, Response: PlanResponse{}}, insertPlanStepHandler},
+	{openapi.Route{Method: "PUT", Path: "/api/plan/:id/steps/:stepId", Tag: "plans", Operation: "UpdatePlanStep",
+		Summary: "Edits a step of a pending plan", Request: planner.StepEdit{}, Response: PlanResponse{}}, updatePlanStepHandler},
+	{openapi.Route{Method: "DELETE", Path: "/api/plan/:id/steps/:stepId", Tag: "plans", Operation: "DeletePlanStep",
+		Summary: "Removes a step from a pending plan", Response: PlanResponse{}}, deletePlanStepHandler},
+	{openapi.Route{Method: "POST", Path: "/api/plan/:id/steps/:stepId/approval", Tag: "plans", Operation: "ApprovePlanStep",
+		Summary: "Answers a step's request for approval", Request: StepApprovalRequest{}, Response: map[string]interface{}{}}, approvePlanStepHandler},
+
+	// Artifacts: files tools and plans publish, and tool outputs too long to send the model whole.
+	// GET /api/session/:id/artifacts/:artifactId downloads one; it isn't JSON, so isn't in the spec.
+	{openapi.Route{Method: "GET", Path: "/api/session/:id/artifacts", Tag: "artifacts", Operation: "ListArtifacts",
+		Summary: "Lists a session's artifacts, newest first, without their content",
+		Query: []openapi.Parameter{
+			queryParam("kind", "string", "Only artifacts of this kind: tool_output, report, docs, build or other"),
+		},
+		Response: []db.Artifact{}}, listSessionArtifactsHandler},
+	{openapi.Route{Method: "GET", Path: "/api/plan/:id/artifacts", Tag: "artifacts", Operation: "ListPlanArtifacts",
+		Summary: "Lists the artifacts a plan's steps published, newest first",
+		Query: []openapi.Parameter{
+			queryParam("kind", "string", "Only artifacts of this kind"),
+		},
+		Response: []db.Artifact{}}, listPlanArtifactsHandler},
+	{openapi.Route{Method: "DELETE", Path: "/api/session/:id/artifacts/:artifactId", Tag: "artifacts", Operation: "DeleteArtifact",
+		Summary: "Deletes one of a session's artifacts", Response: map[string]bool{}}, deleteArtifactHandler},
+
+	// Files, relative to the project root
+	{openapi.Route{Method: "GET", Path: "/api/files/tree", Tag: "files", Operation: "GetFileTree",
+		Summary: "Returns a directory's tree",
+		Query: []openapi.Parameter{
+			queryParam("path", "string", "The directory (default the project root)"),
+			queryParam("depth", "integer", "Levels to include, up to 5 (default 2)"),
+		},
+		Response: map[string]interface{}{}}, getFileTreeHandler},
+	{openapi.Route{Method: "GET", Path: "/api/files/cwd", Tag: "files", Operation: "GetWorkingDirectory",
+		Summary: "Returns the project root", Response: map[string]string{}}, getCurrentWorkingDirectoryHandler},
+	{openapi.Route{Method: "GET", Path: "/api/files/content/*", Tag: "files", Operation: "GetFileContent",
+		Summary: "Returns a file's content, with the hash to save it with", Response: map[string]interface{}{}}, getFileContentHandler},
+	{openapi.Route{Method: "PUT", Path: "/api/files/content/*", Tag: "files", Operation: "SaveFileContent",
+		Summary: "Saves a file, if it hasn't changed since the version named by baseHash; 409 if it has",
+		Request: SaveFileRequest{}, Response: FileActionResponse{}}, saveFileContentHandler},
+	{openapi.Route{Method: "POST", Path: "/api/files/search", Tag: "files", Operation: "SearchFiles",
+		Summary: "Returns a page of files matching a search by name and, optionally, content",
+		Request: SearchOptions{}, Response: map[string]interface{}{}}, searchFilesHandler},
+	{openapi.Route{Method: "POST", Path: "/api/files/create", Tag: "files", Operation: "CreateFile",
+		Summary: "Creates a file or directory", Request: CreateFileRequest{}, Response: FileActionResponse{}}, createFileHandler},
+	{openapi.Route{Method: "PUT", Path: "/api/files/rename", Tag: "files", Operation: "RenameFile",
+		Summary: "Renames a file or directory", Request: RenameFileRequest{}, Response: FileActionResponse{}}, renameFileHandler},
+	{openapi.Route{Method: "POST", Path: "/api/files/duplicate", Tag: "files", Operation: "CopyFile",
+		Summary: "Copies a file or directory; 409 if the destination exists, unless overwrite is set",
+		Request: CopyMoveRequest{}, Response: FileActionResponse{}}, copyFileHandler},
+	{openapi.Route{Method: "POST", Path: "/api/files/move", Tag: "files", Operation: "MoveFile",
+		Summary: "Moves a file or directory; 409 if the destination exists, unless overwrite is set",
+		Request: CopyMoveRequest{}, Response: FileActionResponse{}}, moveFileHandler},
+	{openapi.Route{Method: "DELETE", Path: "/api/files/delete", Tag: "files", Operation: "DeleteFile",
+		Summary: "Deletes a file or directory", Request: FilePathRequest{}, Response: FileActionResponse{}}, deleteFileHandler},
+	{openapi.Route{Method: "POST", Path: "/api/session/:id/files/open", Tag: "files", Operation: "OpenFile",
+		Summary: "Records that a session opened a file", Request: FilePathRequest{}, Response: FileActionResponse{}}, openFileHandler},
+	{openapi.Route{Method: "POST", Path: "/api/session/:id/files/close", Tag: "files", Operation: "CloseFile",
+		Summary: "Records that a session closed a file", Request: FilePathRequest{}, Response: FileActionResponse{}}, closeFileInSessionHandler},
+	{openapi.Route{Method: "GET", Path: "/api/session/:id/files/open", Tag: "files", Operation: "ListOpenFiles",
+		Summary: "Lists the files a session has open", Response: map[string]interface{}{}}, getSessionOpenFilesHandler},
+	{openapi.Route{Method: "GET", Path: "/api/session/:id/files/recent", Tag: "files", Operation: "ListRecentFiles",
+		Summary: "Lists the files a session used most recently", Response: map[string]interface{}{}}, getRecentFilesHandler},
+}
+
+// PlanActionResponse is the reply to an action on a plan
+type PlanActionResponse struct {
+	Status       string `json:"status"`
+	PlanID       string `json:"plan_id"`
+	CheckpointID string `json:"checkpoint_id,omitempty"` // For a rollback
+}
+
+// FileActionResponse is the reply to a change to a file. Only the fields the action
+// concerns are set, e.g. oldPath and newPath for a rename.
+type FileActionResponse struct {
+	Status  string     `json:"status"`
+	Path    string     `json:"path,omitempty"`
+	Type    string     `json:"type,omitempty"`
+	OldPath string     `json:"oldPath,omitempty"`
+	Source  string     `json:"source,omitempty"`
+	NewPath string     `json:"newPath,omitempty"`
+	Hash    string     `json:"hash,omitempty"`
+	ModTime *time.Time `json:"modTime,omitempty"`
+}
+
+func queryParam(name, typ, description string) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "query", Description: description, Schema: &openapi.Schema{Type: typ}}
+}
+
+// OpenAPI describes the documented API
+func OpenAPI() *openapi.Spec {
+	spec := openapi.New(openapi.Info{
+		Title:   "rcode",
+		Version: "0.1.0",
+		Description: "The API of an rcode server. Progress of messages and plans is sent as " +
+			"server-sent events on GET /events, which isn't described here.",
+	})
+	spec.Tags = []openapi.Tag{
+		{Name: "sessions", Description: "Chat sessions"},
+		{Name: "messages", Description: "Messages to the model and its replies"},
+		{Name: "tools", Description: "Tools and their permissions"},
+		{Name: "plans", Description: "Task plans and their execution"},
+		{Name: "files", Description: "The project's files"},
+	}
+	spec.Components.SecuritySchemes = map[string]*openapi.SecurityScheme{
+		"apiToken": {Type: "http", Scheme: "bearer", Description: "An API token; see /api/tokens"},
+	}
+	spec.Security = []map[string][]string{{"apiToken": {}}, {}} // Optional, unless in multi-user mode
+
+	for _, route := range apiRoutes {
+		spec.AddRoute(route.Route)
+	}
+	return spec
+}
+
+// openAPIHandler serves the spec of the documented API
+func openAPIHandler(c rweb.Context) error {
+	return c.WriteJSON(OpenAPI())
+}
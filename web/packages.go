@@ -0,0 +1,119 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"rcode/context"
+	"rcode/db"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/rweb"
+	"github.com/rohanthewiz/serr"
+)
+
+// Packages of a monorepo listed in a new session's context; the rest are counted
+const maxListedPackages = 30
+
+// PackageUpdate scopes a session to a monorepo package, by path or name; "" for the whole repository
+type PackageUpdate struct {
+	Package string `json:"package"`
+}
+
+// sessionPackage returns the package a session is scoped to, nil when it is scoped to the
+// whole repository or its package is no longer in the project
+func sessionPackage(session *db.Session, cm *context.Manager) *context.WorkspacePackage {
+	if session == nil || session.Package == "" || cm == nil {
+		return nil
+	}
+	ctx := cm.GetContext()
+	if ctx == nil {
+		return nil
+	}
+	pkg := ctx.FindPackage(session.Package)
+	if pkg == nil {
+		logger.Warn("Session's package is gone, using the whole repository", "session_id", session.ID, "package", session.Package)
+	}
+	return pkg
+}
+
+// packageDir returns a package's directory
+func packageDir(cm *context.Manager, pkg *context.WorkspacePackage) string {
+	return filepath.Join(cm.GetProjectRoot(), filepath.FromSlash(pkg.Path))
+}
+
+// describePackage names a package with its path, language and framework:
+// "web at apps/web (typescript, nextjs)"
+func describePackage(pkg context.WorkspacePackage) string {
+	kind := pkg.Language
+	if pkg.Framework != "" {
+		kind += ", " + pkg.Framework
+	}
+	if kind == "" {
+		return fmt.Sprintf("%s at %s", pkg.Name, pkg.Path)
+	}
+	return fmt.Sprintf("%s at %s (%s)", pkg.Name, pkg.Path, kind)
+}
+
+// packageScopePrompt tells the model the session works on one package of the monorepo
+func packageScopePrompt(cm *context.Manager, pkg *context.WorkspacePackage) string {
+	return fmt.Sprintf("This session is scoped to the package %s of the monorepo. Work in %s: read and change its "+
+		"files, and run its build and test commands from that directory. Change other packages only when asked to, "+
+		"or when a change to this one needs it.", describePackage(*pkg), packageDir(cm, pkg))
+}
+
+// packagesPrompt lists a monorepo's packages for a new session's context
+func packagesPrompt(ctx *context.ProjectContext) string {
+	if len(ctx.Packages) == 0 {
+		return ""
+	}
+	listed := make([]string, 0, maxListedPackages)
+	for i, pkg := range ctx.Packages {
+		if i == maxListedPackages {
+			listed = append(listed, fmt.Sprintf("and %d more", len(ctx.Packages)-maxListedPackages))
+			break
+		}
+		listed = append(listed, describePackage(pkg))
+	}
+	return fmt.Sprintf("Monorepo of %d packages (%s): %s", len(ctx.Packages), strings.Join(ctx.Workspaces, ", "), strings.Join(listed, "; "))
+}
+
+// setSessionPackageHandler scopes the session to a package of the monorepo, given by path
+// or name, or to the whole repository with "". The session's packed context is repacked
+// from the package's files with the next message, and its pages are told with a
+// session_package event.
+func setSessionPackageHandler(c rweb.Context) error {
+	sessionID := c.Request().Param("id")
+
+	var update PackageUpdate
+	if err := json.Unmarshal(c.Request().Body(), &update); err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid request body"), 400)
+	}
+	if update.Package != "" {
+		cm := GetContextManager()
+		if cm == nil || !cm.IsInitialized() || cm.GetContext() == nil {
+			return c.WriteError(serr.New("the project hasn't been scanned yet"), 409)
+		}
+		pkg := cm.GetContext().FindPackage(update.Package)
+		if pkg == nil {
+			return c.WriteError(serr.New(fmt.Sprintf("the project has no package %q", update.Package)), 400)
+		}
+		update.Package = pkg.Path
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+	if err := database.SetSessionPackage(sessionID, update.Package); err != nil {
+		return c.WriteError(err, 404)
+	}
+	if err := database.DeleteSessionContext(sessionID); err != nil {
+		logger.LogErr(err, "failed to discard packed context of rescoped session", "session_id", sessionID)
+	}
+
+	BroadcastSessionUpdate(sessionID, "session_package", update)
+	return c.WriteJSON(update)
+}
@@ -0,0 +1,76 @@
+package web
+
+import (
+	"sync"
+
+	"rcode/tools"
+)
+
+// parallelSafeTools only read the project, git, the web or rcode's own data, so calls of
+// them in one reply can run at the same time without any seeing another's work
+var parallelSafeTools = map[string]bool{
+	"read_file":        true,
+	"search":           true,
+	"ripgrep":          true,
+	"semantic_search":  true,
+	"dependency_graph": true,
+	"list_dir":         true,
+	"tree":             true,
+	"git_status":       true,
+	"git_diff":         true,
+	"git_log":          true,
+	"web_fetch":        true,
+	"web_search":       true,
+	"get_issue":        true,
+	"list_issues":      true,
+	"list_processes":   true,
+	"recall":           true,
+	"read_artifact":    true,
+	"todo_read":        true,
+	"read_notes":       true,
+}
+
+// toolBatches groups a reply's tool calls, by index, into batches that run one after
+// another. A run of parallel-safe calls is one batch; any other call is a batch of its own,
+// so it sees what the calls before it did and the calls after it see its work. Nil calls,
+// those whose input couldn't be read, are left out.
+func toolBatches(calls []*tools.ToolUse) [][]int {
+	var batches [][]int
+	parallel := false // Whether the last batch is of parallel-safe calls
+	for i, call := range calls {
+		if call == nil {
+			continue
+		}
+		safe := parallelSafeTools[call.Name]
+		if safe && parallel {
+			batches[len(batches)-1] = append(batches[len(batches)-1], i)
+			continue
+		}
+		batches = append(batches, []int{i})
+		parallel = safe
+	}
+	return batches
+}
+
+// runToolBatch calls run for each call of the batch, at most limit at once
+func runToolBatch(batch []int, limit int, run func(i int)) {
+	if len(batch) == 1 || limit <= 1 {
+		for _, i := range batch {
+			run(i)
+		}
+		return
+	}
+
+	slots := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for _, i := range batch {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			run(i)
+		}()
+	}
+	wg.Wait()
+}
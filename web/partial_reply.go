@@ -0,0 +1,84 @@
+package web
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/serr"
+	"rcode/db"
+	"rcode/providers"
+)
+
+// partialSaveInterval is how often a reply being streamed is saved
+const partialSaveInterval = 2 * time.Second
+
+// partialReply saves an assistant reply as it streams, so that what the model said, and the
+// tools it called, are kept when the server stops before the reply is complete
+type partialReply struct {
+	database  *db.DB
+	sessionID string
+	log       sessionLog
+	id        *int // The reply's message, once first saved
+	savedAt   time.Time
+}
+
+func newPartialReply(database *db.DB, sessionID string, log sessionLog) *partialReply {
+	return &partialReply{database: database, sessionID: sessionID, log: log}
+}
+
+// save stores the reply streamed so far, at most every partialSaveInterval unless forced
+func (p *partialReply) save(text string, toolUses []interface{}, model string, force bool) {
+	if text == "" && len(toolUses) == 0 {
+		return
+	}
+	if !force && p.id != nil && time.Since(p.savedAt) < partialSaveInterval {
+		return
+	}
+	id, err := p.database.SavePartialMessage(p.sessionID, p.id, text, toolUses, model)
+	if err != nil {
+		p.log.Err(err, "failed to save partial reply")
+		return
+	}
+	p.id = id
+	p.savedAt = time.Now()
+}
+
+// finish stores the complete reply, in place of the partial one if it was saved, and
+// returns its message ID
+func (p *partialReply) finish(msg providers.ChatMessage, model string, usage *providers.Usage) (*int, error) {
+	if p.id == nil {
+		return p.database.AddMessageWithID(p.sessionID, msg, model, usage)
+	}
+	if err := p.database.FinalizeMessage(*p.id, msg, model, usage); err != nil {
+		return nil, err
+	}
+	return p.id, nil
+}
+
+// interrupt marks the reply, if it was saved, as interrupted when its stream fails
+func (p *partialReply) interrupt() {
+	if p.id == nil {
+		return
+	}
+	if err := p.database.InterruptMessage(*p.id); err != nil {
+		p.log.Err(err, "failed to mark reply interrupted", "message_id", strconv.Itoa(*p.id))
+	}
+}
+
+// RecoverInterruptedReplies marks the replies that were being streamed when the server last
+// stopped as interrupted, so they are shown and sent to the model as such
+func RecoverInterruptedReplies() error {
+	database, err := db.GetDB()
+	if err != nil {
+		return serr.Wrap(err, "failed to get database")
+	}
+	n, err := database.MarkInterruptedMessages()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		logger.Info("Marked replies interrupted by the last shutdown", "messages", strconv.FormatInt(n, 10))
+	}
+	return nil
+}
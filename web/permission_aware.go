@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 
 	"rcode/db"
 	"rcode/diff"
@@ -18,13 +19,16 @@ type PermissionAwareExecutor struct {
 	executor     *tools.ContextAwareExecutor
 	database     *db.DB
 	onAskHandler func(sessionID, toolName string, params map[string]interface{}) (bool, error)
+	askMu        sync.Mutex        // Held while the user is asked, as the UI shows one request at a time
+	autoAccept   *autoAcceptBudget // What the turn changed in auto-accept mode
 }
 
 // NewPermissionAwareExecutor creates a new permission-aware executor
 func NewPermissionAwareExecutor(executor *tools.ContextAwareExecutor, database *db.DB) *PermissionAwareExecutor {
 	return &PermissionAwareExecutor{
-		executor: executor,
-		database: database,
+		executor:   executor,
+		database:   database,
+		autoAccept: newAutoAcceptBudget(),
 	}
 }
 
@@ -46,15 +50,77 @@ func (e *PermissionAwareExecutor) Execute(toolUse tools.ToolUse) (*tools.ToolRes
 	// Check tool permission
 	permType, scope, err := e.database.CheckToolPermission(sessionID, toolUse.Name)
 	if err != nil {
-		logger.LogErr(err, "failed to check tool permission", "tool", toolUse.Name, "session", sessionID)
+		logger.LogErr(err, "failed to check tool permission", "tool", toolUse.Name, "session_id", sessionID)
 		// On error, default to ask mode
 		permType = db.PermissionAsk
 	}
 
-	logger.Debug("Checking tool permission", "tool", toolUse.Name, "session", sessionID, "permission", permType)
+	session, err := e.database.GetSession(sessionID)
+	if err != nil {
+		logger.LogErr(err, "failed to get session for its permissions", "tool", toolUse.Name, "session_id", sessionID)
+	}
+	if session == nil {
+		session = &db.Session{ID: sessionID}
+	}
+
+	// A permission rule matching the call's arguments decides ahead of the tool's permission
+	rules, err := sessionPermissionRules(e.database, sessionID, session.UserID)
+	if err != nil {
+		logger.LogErr(err, "failed to get permission rules", "tool", toolUse.Name, "session_id", sessionID)
+	}
+	rule := matchPermissionRules(rules, toolUse)
+	if rule != nil {
+		permType = rule.Action
+		logger.Debug("Permission rule matched", "tool", toolUse.Name, "session_id", sessionID, "rule", describePermissionRule(rule))
+	}
+
+	// The task list and notes are the session's own, so keeping them needs no asking
+	if permType == db.PermissionAsk && rule == nil && (todoTools[toolUse.Name] || noteTools[toolUse.Name]) {
+		permType = db.PermissionAllowed
+	}
+
+	// Read-only mode has the last word, over the rules too
+	readOnly := false
+	if session.ReadOnly {
+		permType, readOnly = readOnlyPermission(toolUse, permType)
+	}
+
+	// Auto-accept mode runs what would ask, but not what a rule asks for, within the turn's
+	// limits. Shell commands still ask, as what they change can't be counted against them.
+	if session.AutoAccept && !session.ReadOnly {
+		if permType == db.PermissionAsk && (rule == nil || rule.Action != db.PermissionAsk) && !readOnlyShellTools[toolUse.Name] {
+			permType = db.PermissionAllowed
+		}
+		if permType == db.PermissionAllowed {
+			if reason := e.autoAccept.take(toolUse); reason != "" {
+				logger.Warn("Auto-accept limit reached", "tool", toolUse.Name, "session_id", sessionID, "reason", reason)
+				return &tools.ToolResult{
+					Type:      "tool_result",
+					ToolUseID: toolUse.ID,
+					Content:   fmt.Sprintf("Tool '%s' was not run. %s", toolUse.Name, reason),
+				}, serr.New("tool call over the auto-accept limits")
+			}
+		}
+	}
+
+	logger.Debug("Checking tool permission", "tool", toolUse.Name, "session_id", sessionID, "permission", permType)
 
 	switch permType {
 	case db.PermissionDenied:
+		if readOnly {
+			return &tools.ToolResult{
+				Type:      "tool_result",
+				ToolUseID: toolUse.ID,
+				Content:   fmt.Sprintf("Tool '%s' can change the project, and this session is in read-only mode. Look around with the read-only tools, or ask the user to turn read-only mode off.", toolUse.Name),
+			}, serr.New("tool denied in read-only mode")
+		}
+		if rule != nil {
+			return &tools.ToolResult{
+				Type:      "tool_result",
+				ToolUseID: toolUse.ID,
+				Content:   fmt.Sprintf("Tool '%s' is denied for this call by a permission rule: %s.", toolUse.Name, describePermissionRule(rule)),
+			}, serr.New("tool call denied by permission rule")
+		}
 		// Tool is denied
 		return &tools.ToolResult{
 			Type:      "tool_result",
@@ -65,21 +131,7 @@ func (e *PermissionAwareExecutor) Execute(toolUse tools.ToolUse) (*tools.ToolRes
 	case db.PermissionAsk:
 		// Tool requires confirmation
 		if e.onAskHandler != nil {
-			// Create a copy of params without internal fields
-			cleanParams := make(map[string]interface{})
-			for k, v := range toolUse.Input {
-				if !strings.HasPrefix(k, "_") {
-					cleanParams[k] = v
-				}
-			}
-
-			// Log the parameters being sent for debugging
-			logger.Debug("Asking permission for tool",
-				"tool", toolUse.Name,
-				"originalParams", toolUse.Input,
-				"cleanParams", cleanParams)
-
-			approved, err := e.onAskHandler(sessionID, toolUse.Name, cleanParams)
+			approved, err := e.ask(sessionID, toolUse, rule != nil || readOnly)
 			if err != nil {
 				return &tools.ToolResult{
 					Type:      "tool_result",
@@ -97,12 +149,12 @@ func (e *PermissionAwareExecutor) Execute(toolUse tools.ToolUse) (*tools.ToolRes
 			}
 		} else {
 			// No ask handler configured, log warning and proceed
-			logger.Warn("Tool requires ask permission but no handler configured", "tool", toolUse.Name)
+			logger.Warn("Tool requires ask permission but no handler configured", "tool", toolUse.Name, "session_id", sessionID)
 		}
 
 	case db.PermissionAllowed:
 		// Tool is allowed, proceed with execution
-		logger.Debug("Tool allowed, executing", "tool", toolUse.Name)
+		logger.Debug("Tool allowed, executing", "tool", toolUse.Name, "session_id", sessionID)
 	}
 
 	// Apply scope restrictions if any
@@ -120,6 +172,39 @@ func (e *PermissionAwareExecutor) Execute(toolUse tools.ToolUse) (*tools.ToolRes
 	return e.executor.Execute(toolUse)
 }
 
+// autoAcceptStopped returns why auto-accept mode stopped the turn, or "" if it didn't
+func (e *PermissionAwareExecutor) autoAcceptStopped() string {
+	return e.autoAccept.stopped()
+}
+
+// ask asks the user whether the tool may run. Calls running at once ask one at a time;
+// a choice the user remembered while a call waited its turn answers for it, unless a
+// permission rule or read-only mode asked for the call.
+func (e *PermissionAwareExecutor) ask(sessionID string, toolUse tools.ToolUse, forced bool) (bool, error) {
+	e.askMu.Lock()
+	defer e.askMu.Unlock()
+
+	if permType, _, err := e.database.CheckToolPermission(sessionID, toolUse.Name); err == nil && permType != db.PermissionAsk && !forced {
+		return permType == db.PermissionAllowed, nil
+	}
+
+	// Create a copy of params without internal fields
+	cleanParams := make(map[string]interface{})
+	for k, v := range toolUse.Input {
+		if !strings.HasPrefix(k, "_") {
+			cleanParams[k] = v
+		}
+	}
+
+	// Log the parameters being sent for debugging
+	logger.Debug("Asking permission for tool",
+		"tool", toolUse.Name,
+		"session_id", sessionID,
+		"params", cleanParams)
+
+	return e.onAskHandler(sessionID, toolUse.Name, cleanParams)
+}
+
 // applyScopeRestrictions applies permission scope restrictions to tool parameters
 func (e *PermissionAwareExecutor) applyScopeRestrictions(toolUse tools.ToolUse, scope *db.PermissionScope) error {
 	// Check path restrictions for file tools
@@ -150,7 +235,7 @@ func (e *PermissionAwareExecutor) applyScopeRestrictions(toolUse tools.ToolUse,
 	}
 
 	// Check allowed commands for bash tool
-	if toolUse.Name == "bash" && len(scope.AllowedCmds) > 0 {
+	if (toolUse.Name == "bash" || toolUse.Name == "run_background") && len(scope.AllowedCmds) > 0 {
 		if cmd, ok := tools.GetString(toolUse.Input, "command"); ok {
 			allowed := false
 			for _, allowedCmd := range scope.AllowedCmds {
@@ -175,7 +260,7 @@ func HandleAskPermission(sessionID, toolName string, params map[string]interface
 	var err error
 
 	// Check if this is a file modification tool that needs diff preview
-	if toolName == "write_file" || toolName == "edit_file" {
+	if toolName == "write_file" || toolName == "edit_file" || toolName == "replace_snippet" || toolName == "resolve_conflict" {
 		// Generate diff preview for file modifications
 		diffPreview, err := generateDiffPreview(toolName, params)
 		if err != nil {
@@ -197,6 +282,7 @@ func HandleAskPermission(sessionID, toolName string, params map[string]interface
 
 	// Broadcast the permission request to the frontend
 	BroadcastPermissionRequest(request)
+	notifyPermissionRequest(request)
 
 	// Wait for the response
 	response, err := permissionManager.WaitForResponse(request.ID)
@@ -264,6 +350,37 @@ func generateDiffPreview(toolName string, params map[string]interface{}) (*diff.
 		if err != nil {
 			return nil, serr.Wrap(err, "failed to preview edit operation")
 		}
+
+	case "replace_snippet":
+		content, err := os.ReadFile(expandedPath)
+		if err != nil {
+			return nil, serr.Wrap(err, "failed to read file for snippet preview")
+		}
+		beforeContent = string(content)
+
+		oldText, _ := tools.GetString(params, "old_text")
+		newText, _ := tools.GetString(params, "new_text")
+		replaceAll, _ := params["replace_all"].(bool)
+		replacement, err := tools.ReplaceSnippet(beforeContent, oldText, newText, replaceAll)
+		if err != nil {
+			return nil, serr.Wrap(err, "failed to preview snippet replacement")
+		}
+		afterContent = replacement.Content
+
+	case "resolve_conflict":
+		content, err := os.ReadFile(expandedPath)
+		if err != nil {
+			return nil, serr.Wrap(err, "failed to read file for conflict resolution preview")
+		}
+		beforeContent = string(content)
+
+		resolutions, err := tools.ConflictResolutionsFromInput(params)
+		if err != nil {
+			return nil, err
+		}
+		if afterContent, _, err = tools.ResolveConflicts(beforeContent, resolutions); err != nil {
+			return nil, serr.Wrap(err, "failed to preview conflict resolution")
+		}
 	}
 
 	// Generate the diff preview
@@ -38,14 +38,16 @@ func handlePermissionResponseHandler(c rweb.Context) error {
 	// can approve or deny it, pr
 	if response.SessionID != request.SessionID {
 		logger.Warn("Session mismatch in permission response",
-			"responseSessionID", response.SessionID,
-			"requestSessionID", request.SessionID,
-			"requestID", response.RequestID)
+			"session_id", request.SessionID,
+			"response_session_id", response.SessionID,
+			"permission_request_id", response.RequestID)
 		return c.WriteError(serr.New("unauthorized: session does not own this permission request"), 403)
 	}
 
 	logger.Info("Received permission response",
-		"requestId", response.RequestID,
+		"session_id", request.SessionID,
+		"tool", request.ToolName,
+		"permission_request_id", response.RequestID,
 		"approved", response.Approved,
 		"remember", response.RememberChoice)
 
@@ -64,9 +66,10 @@ func handlePermissionResponseHandler(c rweb.Context) error {
 			// Update the tool permission (no expiration for remembered choices)
 			err = database.SetToolPermission(request.SessionID, request.ToolName, permType, nil, 0)
 			if err != nil {
-				logger.LogErr(err, "failed to update tool permission")
+				logger.LogErr(err, "failed to update tool permission", "session_id", request.SessionID, "tool", request.ToolName)
 			} else {
 				logger.Info("Updated tool permission based on remember choice",
+					"session_id", request.SessionID,
 					"tool", request.ToolName,
 					"permission", permType)
 
@@ -139,8 +142,8 @@ func handlePermissionAbortHandler(c rweb.Context) error {
 				logger.LogErr(err, "failed to cancel permission request")
 			} else {
 				logger.Info("Cancelled permission request via abort",
-					"requestId", abortReq.RequestID,
-					"sessionId", abortReq.SessionID)
+					"session_id", abortReq.SessionID,
+					"permission_request_id", abortReq.RequestID)
 			}
 		}
 	}
@@ -164,8 +167,8 @@ func handlePermissionAbortHandler(c rweb.Context) error {
 	})
 
 	logger.Info("Sent abort message to session",
-		"sessionId", abortReq.SessionID,
-		"requestId", abortReq.RequestID)
+		"session_id", abortReq.SessionID,
+		"permission_request_id", abortReq.RequestID)
 
 	return c.WriteJSON(map[string]interface{}{
 		"success": true,
@@ -2,12 +2,14 @@ package web
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/rohanthewiz/logger"
 	"github.com/rohanthewiz/serr"
+	"rcode/tools"
 )
 
 // PermissionRequest represents a pending tool permission request
@@ -70,8 +72,8 @@ func (pm *PermissionManager) CreateRequest(sessionID, toolName string, parameter
 	pm.requests[request.ID] = request
 
 	logger.Info("Created permission request",
-		"id", request.ID,
-		"session", sessionID,
+		"permission_request_id", request.ID,
+		"session_id", sessionID,
 		"tool", toolName)
 
 	return request, nil
@@ -95,8 +97,8 @@ func (pm *PermissionManager) CreateRequestWithDiff(sessionID, toolName string, p
 	pm.requests[request.ID] = request
 
 	logger.Info("Created permission request with diff",
-		"id", request.ID,
-		"session", sessionID,
+		"permission_request_id", request.ID,
+		"session_id", sessionID,
 		"tool", toolName)
 
 	return request, nil
@@ -140,7 +142,8 @@ func (pm *PermissionManager) HandleResponse(response PermissionResponse) error {
 	select {
 	case request.ResponseCh <- response:
 		logger.Info("Permission response processed",
-			"id", response.RequestID,
+			"session_id", response.SessionID,
+			"permission_request_id", response.RequestID,
 			"approved", response.Approved,
 			"remember", response.RememberChoice)
 		return nil
@@ -181,7 +184,7 @@ func (pm *PermissionManager) cleanupExpiredRequests() {
 
 		for id, request := range pm.requests {
 			if now.Sub(request.Timestamp) > pm.timeout {
-				logger.Info("Cleaning up expired permission request", "id", id)
+				logger.Info("Cleaning up expired permission request", "permission_request_id", id, "session_id", request.SessionID)
 				close(request.ResponseCh)
 				delete(pm.requests, id)
 			}
@@ -232,11 +235,77 @@ func (pm *PermissionManager) CancelSessionRequests(sessionID string) {
 // FormatParametersForDisplay formats tool parameters for user-friendly display
 func FormatParametersForDisplay(toolName string, params map[string]interface{}) string {
 	switch toolName {
-	case "write_file", "edit_file":
+	case "write_file", "edit_file", "replace_snippet", "resolve_conflict":
 		if path, ok := params["path"].(string); ok {
 			return fmt.Sprintf("File: %s", path)
 		}
-	case "bash":
+	case "apply_patch":
+		if patch, ok := params["patch"].(string); ok {
+			return fmt.Sprintf("Files: %s", strings.Join(tools.PatchPaths(patch), ", "))
+		}
+	case "generate":
+		if name, ok := params["template"].(string); ok {
+			if paths := tools.GeneratePaths(params, projectRoot()); len(paths) > 0 {
+				return fmt.Sprintf("Template %s: %s", name, strings.Join(paths, ", "))
+			}
+			return fmt.Sprintf("Template %s", name)
+		}
+	case "rename_symbol":
+		name, _ := params["name"].(string)
+		newName, _ := params["new_name"].(string)
+		path, _ := params["path"].(string)
+		return fmt.Sprintf("Rename %s to %s, from %s", name, newName, path)
+	case "test_coverage":
+		packages, _ := params["packages"].(string)
+		if packages == "" {
+			packages = "./..."
+		}
+		return fmt.Sprintf("Run the tests of %s with coverage", packages)
+	case "generate_tests":
+		packages, _ := params["packages"].(string)
+		if packages == "" {
+			packages = "./..."
+		}
+		return fmt.Sprintf("Write tests raising the coverage of %s", packages)
+	case "bench":
+		if command, _ := params["command"].(string); command != "" {
+			return fmt.Sprintf("Run benchmarks: %s", command)
+		}
+		packages, _ := params["packages"].(string)
+		if packages == "" {
+			packages = "./..."
+		}
+		return fmt.Sprintf("Run the benchmarks of %s", packages)
+	case "outdated_dependencies":
+		if ecosystem, _ := params["ecosystem"].(string); ecosystem != "" {
+			return fmt.Sprintf("List outdated %s dependencies", ecosystem)
+		}
+		return "List outdated dependencies"
+	case "update_dependency":
+		dependencies, _ := params["dependencies"].(string)
+		if version, _ := params["version"].(string); version != "" {
+			dependencies += "@" + version
+		}
+		return fmt.Sprintf("Update %s and run the tests", dependencies)
+	case "security_scan":
+		scan := "Scan dependencies for vulnerabilities"
+		if language, _ := params["language"].(string); language != "" {
+			scan = fmt.Sprintf("Scan %s dependencies for vulnerabilities", language)
+		}
+		if filePlan, _ := params["file_plan"].(bool); filePlan {
+			scan += " and file a plan fixing them"
+		}
+		return scan
+	case "sbom":
+		if format, _ := params["format"].(string); format == "spdx" {
+			return "Publish an SPDX SBOM of the dependencies"
+		}
+		return "Publish a CycloneDX SBOM of the dependencies"
+	case "move_package":
+		source, _ := params["source"].(string)
+		destination, _ := params["destination"].(string)
+		return fmt.Sprintf("Move package: %s → %s", source, destination)
+	case "bash", "run_background":
 		if cmd, ok := params["command"].(string); ok {
 			// Truncate long commands
 			if len(cmd) > 100 {
@@ -244,6 +313,20 @@ func FormatParametersForDisplay(toolName string, params map[string]interface{})
 			}
 			return fmt.Sprintf("Command: %s", cmd)
 		}
+	case "run_task":
+		task, _ := params["task"].(string)
+		if task == "" {
+			return "List the project's tasks"
+		}
+		if args, _ := params["args"].(string); args != "" {
+			task += " " + args
+		}
+		if dir, _ := params["dir"].(string); dir != "" {
+			return fmt.Sprintf("Run the task %s in %s", task, dir)
+		}
+		return fmt.Sprintf("Run the task %s", task)
+	case "stop_process":
+		return fmt.Sprintf("Stop background process %v", params["id"])
 	case "remove":
 		if path, ok := params["path"].(string); ok {
 			return fmt.Sprintf("Delete: %s", path)
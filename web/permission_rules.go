@@ -0,0 +1,134 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"rcode/db"
+	"rcode/tools"
+
+	"github.com/rohanthewiz/rweb"
+	"github.com/rohanthewiz/serr"
+)
+
+// permissionRuleStrength orders the rules' actions: of the rules matching a call, the
+// strongest decides, so a rule denying a call can't be undone by one allowing it
+var permissionRuleStrength = map[db.PermissionType]int{
+	db.PermissionAllowed: 1,
+	db.PermissionAsk:     2,
+	db.PermissionDenied:  3,
+}
+
+// sessionPermissionRules returns the rules that apply in a session: its own, then those
+// in the settings of its owner, userID
+func sessionPermissionRules(database *db.DB, sessionID string, userID int) ([]db.PermissionRule, error) {
+	rules, err := database.GetPermissionRules(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	settings, err := database.GetUserSettings(userID)
+	if err != nil {
+		return rules, err
+	}
+	return append(rules, settings.PermissionRules...), nil
+}
+
+// matchPermissionRules returns the rule deciding a call, the first of the strongest that
+// match it, or nil if none do
+func matchPermissionRules(rules []db.PermissionRule, toolUse tools.ToolUse) *db.PermissionRule {
+	var deciding *db.PermissionRule
+	for i := range rules {
+		rule := &rules[i]
+		if !permissionRuleMatches(*rule, toolUse) {
+			continue
+		}
+		if deciding == nil || permissionRuleStrength[rule.Action] > permissionRuleStrength[deciding.Action] {
+			deciding = rule
+		}
+	}
+	return deciding
+}
+
+// permissionRuleMatches reports whether a call is to the rule's tool with its argument
+// matching the rule's pattern. A call without the argument doesn't match.
+func permissionRuleMatches(rule db.PermissionRule, toolUse tools.ToolUse) bool {
+	if rule.Tool != toolUse.Name {
+		return false
+	}
+	raw, ok := toolUse.Input[rule.Argument]
+	if !ok || raw == nil {
+		return false
+	}
+	value, ok := raw.(string)
+	if !ok {
+		value = fmt.Sprint(raw)
+	}
+
+	if rule.Regex != "" {
+		re, err := regexp.Compile(rule.Regex)
+		return err == nil && re.MatchString(value)
+	}
+	return tools.MatchesPathGlob(rule.Glob, projectRelativePath(value))
+}
+
+// projectRelativePath returns a path as the permission rules' globs see it: relative to
+// the project when it is under the project, and cleaned
+func projectRelativePath(path string) string {
+	if expanded, err := tools.ExpandPath(path); err == nil && expanded != "" {
+		path = expanded
+	}
+	if filepath.IsAbs(path) {
+		if rel, err := filepath.Rel(projectRoot(), path); err == nil && !strings.HasPrefix(rel, "..") {
+			path = rel
+		}
+	}
+	return filepath.ToSlash(filepath.Clean(path))
+}
+
+// describePermissionRule says what a rule matches, for the model and the logs
+func describePermissionRule(rule *db.PermissionRule) string {
+	if rule.Regex != "" {
+		return fmt.Sprintf("%s matches /%s/", rule.Argument, rule.Regex)
+	}
+	return fmt.Sprintf("%s matches %s", rule.Argument, rule.Glob)
+}
+
+// getPermissionRulesHandler returns the session's own permission rules, in order
+func getPermissionRulesHandler(c rweb.Context) error {
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	rules, err := database.GetPermissionRules(c.Request().Param("id"))
+	if err != nil {
+		return c.WriteError(err, 500)
+	}
+	return c.WriteJSON(rules)
+}
+
+// setPermissionRulesHandler replaces the session's permission rules with those in the body
+func setPermissionRulesHandler(c rweb.Context) error {
+	sessionID := c.Request().Param("id")
+
+	var rules []db.PermissionRule
+	if err := json.Unmarshal(c.Request().Body(), &rules); err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid permission rules"), 400)
+	}
+	if rules == nil {
+		rules = []db.PermissionRule{}
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+	if err := database.SetPermissionRules(sessionID, rules); err != nil {
+		return c.WriteError(err, 400)
+	}
+	return c.WriteJSON(rules)
+}
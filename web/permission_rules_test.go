@@ -0,0 +1,86 @@
+package web
+
+import (
+	"path/filepath"
+	"testing"
+
+	"rcode/db"
+	"rcode/tools"
+)
+
+func TestPermissionRuleMatches(t *testing.T) {
+	inProject := filepath.Join(projectRoot(), "src", "main.go")
+
+	tests := []struct {
+		name  string
+		rule  db.PermissionRule
+		tool  string // The call's tool, the rule's if ""
+		input map[string]interface{}
+		want  bool
+	}{
+		{"glob", db.PermissionRule{Tool: "write_file", Argument: "path", Glob: "src/**"}, "", map[string]interface{}{"path": "src/web/app.go"}, true},
+		{"glob elsewhere", db.PermissionRule{Tool: "write_file", Argument: "path", Glob: "src/**"}, "", map[string]interface{}{"path": "docs/app.md"}, false},
+		{"dot slash", db.PermissionRule{Tool: "write_file", Argument: "path", Glob: "src/**"}, "", map[string]interface{}{"path": "./src/app.go"}, true},
+		{"dot dot leaves src", db.PermissionRule{Tool: "write_file", Argument: "path", Glob: "src/**"}, "", map[string]interface{}{"path": "src/../x"}, false},
+		{"dot dot cleaned", db.PermissionRule{Tool: "write_file", Argument: "path", Glob: "x"}, "", map[string]interface{}{"path": "src/../x"}, true},
+		{"absolute in project", db.PermissionRule{Tool: "write_file", Argument: "path", Glob: "src/*.go"}, "", map[string]interface{}{"path": inProject}, true},
+		{"absolute outside project", db.PermissionRule{Tool: "write_file", Argument: "path", Glob: "etc/**"}, "", map[string]interface{}{"path": "/etc/passwd"}, false},
+		{"absolute glob outside project", db.PermissionRule{Tool: "write_file", Argument: "path", Glob: "/etc/**"}, "", map[string]interface{}{"path": "/etc/../etc/passwd"}, true},
+		{"regex", db.PermissionRule{Tool: "bash", Argument: "command", Regex: `^git (status|diff)\b`}, "", map[string]interface{}{"command": "git diff HEAD"}, true},
+		{"regex no match", db.PermissionRule{Tool: "bash", Argument: "command", Regex: `^git (status|diff)\b`}, "", map[string]interface{}{"command": "git push"}, false},
+		{"regex sees the raw value", db.PermissionRule{Tool: "read_file", Argument: "path", Regex: `^src/\.\./`}, "", map[string]interface{}{"path": "src/../x"}, true},
+		{"number", db.PermissionRule{Tool: "bash", Argument: "timeout", Regex: `^600$`}, "", map[string]interface{}{"timeout": 600}, true},
+		{"float", db.PermissionRule{Tool: "bash", Argument: "timeout", Regex: `^1\.5$`}, "", map[string]interface{}{"timeout": 1.5}, true},
+		{"bool", db.PermissionRule{Tool: "bash", Argument: "background", Glob: "true"}, "", map[string]interface{}{"background": true}, true},
+		{"nil argument", db.PermissionRule{Tool: "bash", Argument: "command", Regex: "."}, "", map[string]interface{}{"command": nil}, false},
+		{"missing argument", db.PermissionRule{Tool: "bash", Argument: "command", Regex: "."}, "", map[string]interface{}{"cwd": "src"}, false},
+		{"other tool", db.PermissionRule{Tool: "read_file", Argument: "path", Glob: "**"}, "write_file", map[string]interface{}{"path": "src/app.go"}, false},
+		{"invalid regex", db.PermissionRule{Tool: "bash", Argument: "command", Regex: "("}, "", map[string]interface{}{"command": "("}, false},
+	}
+	for _, tt := range tests {
+		toolName := tt.tool
+		if toolName == "" {
+			toolName = tt.rule.Tool
+		}
+		got := permissionRuleMatches(tt.rule, tools.ToolUse{Name: toolName, Input: tt.input})
+		if got != tt.want {
+			t.Errorf("%s: permissionRuleMatches = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMatchPermissionRules(t *testing.T) {
+	allowSrc := db.PermissionRule{Tool: "write_file", Argument: "path", Glob: "src/**", Action: db.PermissionAllowed}
+	askGo := db.PermissionRule{Tool: "write_file", Argument: "path", Glob: "**/*.go", Action: db.PermissionAsk}
+	denySecrets := db.PermissionRule{Tool: "write_file", Argument: "path", Glob: "src/secrets/**", Action: db.PermissionDenied}
+	allowAll := db.PermissionRule{Tool: "write_file", Argument: "path", Glob: "**", Action: db.PermissionAllowed}
+
+	tests := []struct {
+		name  string
+		rules []db.PermissionRule
+		path  string
+		want  *db.PermissionRule // nil for no rule
+	}{
+		{"no rules", nil, "src/app.go", nil},
+		{"none match", []db.PermissionRule{allowSrc}, "docs/readme.md", nil},
+		{"allow", []db.PermissionRule{allowSrc}, "src/app.js", &allowSrc},
+		{"deny beats allow", []db.PermissionRule{allowSrc, denySecrets}, "src/secrets/key.txt", &denySecrets},
+		{"deny beats a later allow", []db.PermissionRule{denySecrets, allowAll}, "src/secrets/key.txt", &denySecrets},
+		{"ask beats allow", []db.PermissionRule{allowSrc, askGo}, "src/app.go", &askGo},
+		{"deny beats ask", []db.PermissionRule{askGo, denySecrets}, "src/secrets/key.go", &denySecrets},
+		{"first of the strongest", []db.PermissionRule{allowSrc, allowAll}, "src/app.js", &allowSrc},
+		{"dot dot escapes the deny", []db.PermissionRule{allowAll, denySecrets}, "src/secrets/../app.js", &allowAll},
+		{"dot dot into the deny", []db.PermissionRule{allowAll, denySecrets}, "src/public/../secrets/key.txt", &denySecrets},
+	}
+	for _, tt := range tests {
+		got := matchPermissionRules(tt.rules, tools.ToolUse{Name: "write_file", Input: map[string]interface{}{"path": tt.path}})
+		switch {
+		case tt.want == nil && got != nil:
+			t.Errorf("%s: got the %s rule for %s, want none", tt.name, got.Action, got.Glob)
+		case tt.want != nil && got == nil:
+			t.Errorf("%s: got no rule, want the %s rule for %s", tt.name, tt.want.Action, tt.want.Glob)
+		case tt.want != nil && *got != *tt.want:
+			t.Errorf("%s: got the %s rule for %s, want the %s rule for %s", tt.name, got.Action, got.Glob, tt.want.Action, tt.want.Glob)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package web
+
+import (
+	"encoding/json"
+	"sync"
+
+	"rcode/planner"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/rweb"
+	"github.com/rohanthewiz/serr"
+)
+
+// StepApprovalRequest carries the user's decision on a gated plan step
+type StepApprovalRequest struct {
+	Approved bool `json:"approved"`
+}
+
+// pendingStepApprovals maps "planID/stepID" to the permission request awaiting an answer
+var pendingStepApprovals sync.Map
+
+func stepApprovalKey(planID, stepID string) string {
+	return planID + "/" + stepID
+}
+
+// requestPlanStepApproval is the planner's ApprovalFunc. It raises a regular permission
+// request so the existing permission modal is shown, and blocks until it is answered
+// through either the modal or the plan step approval endpoint.
+func requestPlanStepApproval(task *planner.TaskPlanner, step *planner.TaskStep) (bool, error) {
+	var request *PermissionRequest
+	var err error
+
+	if step.Tool == "write_file" || step.Tool == "edit_file" {
+		if diffPreview, diffErr := generateDiffPreview(step.Tool, step.Params); diffErr == nil {
+			request, err = permissionManager.CreateRequestWithDiff(task.SessionID, step.Tool, step.Params, diffPreview)
+		} else {
+			request, err = permissionManager.CreateRequest(task.SessionID, step.Tool, step.Params)
+		}
+	} else {
+		request, err = permissionManager.CreateRequest(task.SessionID, step.Tool, step.Params)
+	}
+	if err != nil {
+		return false, serr.Wrap(err, "failed to create approval request")
+	}
+
+	key := stepApprovalKey(task.ID, step.ID)
+	pendingStepApprovals.Store(key, request.ID)
+	defer pendingStepApprovals.Delete(key)
+
+	broadcastPlanEvent("plan_step_approval_needed", task.SessionID, task.ID, map[string]interface{}{
+		"step_id":     step.ID,
+		"description": step.Description,
+		"tool":        step.Tool,
+		"params":      step.Params,
+		"request_id":  request.ID,
+	})
+	BroadcastPermissionRequest(request)
+	notifyPermissionRequest(request)
+
+	response, err := permissionManager.WaitForResponse(request.ID)
+	if err != nil {
+		return false, err
+	}
+
+	eventType := "plan_step_denied"
+	if response.Approved {
+		eventType = "plan_step_approved"
+	}
+	broadcastPlanEvent(eventType, task.SessionID, task.ID, map[string]interface{}{
+		"step_id": step.ID,
+	})
+
+	return response.Approved, nil
+}
+
+// approvePlanStepHandler approves or denies a plan step waiting at an approval gate
+func approvePlanStepHandler(c rweb.Context) error {
+	planID := c.Request().Param("id")
+	stepID := c.Request().Param("stepId")
+
+	var req StepApprovalRequest
+	if err := json.Unmarshal(c.Request().Body(), &req); err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid request body"), 400)
+	}
+
+	value, ok := pendingStepApprovals.Load(stepApprovalKey(planID, stepID))
+	if !ok {
+		return c.WriteError(serr.New("step is not waiting for approval"), 404)
+	}
+	requestID := value.(string)
+
+	request, exists := permissionManager.GetRequest(requestID)
+	if !exists {
+		return c.WriteError(serr.New("approval request not found or expired"), 404)
+	}
+
+	if err := permissionManager.HandleResponse(PermissionResponse{
+		RequestID: requestID,
+		SessionID: request.SessionID,
+		Approved:  req.Approved,
+	}); err != nil {
+		return c.WriteError(err, 409)
+	}
+
+	logger.Info("Plan step approval answered", "plan_id", planID, "step_id", stepID, "approved", req.Approved)
+
+	return c.WriteJSON(map[string]interface{}{
+		"success":  true,
+		"approved": req.Approved,
+	})
+}
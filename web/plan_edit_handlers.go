@@ -0,0 +1,128 @@
+package web
+
+import (
+	"encoding/json"
+	"strings"
+
+	"rcode/db"
+	"rcode/planner"
+
+	"github.com/rohanthewiz/rweb"
+	"github.com/rohanthewiz/serr"
+)
+
+// ReorderStepsRequest lists every step ID of a plan in the desired order
+type ReorderStepsRequest struct {
+	Order []string `json:"order"`
+}
+
+// InsertStepRequest adds a step to a plan; a nil position appends it
+type InsertStepRequest struct {
+	Step     planner.TaskStep `json:"step"`
+	Position *int             `json:"position,omitempty"`
+}
+
+// reorderPlanStepsHandler rearranges the steps of a pending plan
+func reorderPlanStepsHandler(c rweb.Context) error {
+	var req ReorderStepsRequest
+	if err := json.Unmarshal(c.Request().Body(), &req); err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid request body"), 400)
+	}
+
+	return editPlanSteps(c, func(plan *planner.TaskPlanner) error {
+		return plan.ReorderSteps(req.Order)
+	})
+}
+
+// insertPlanStepHandler inserts a new step into a pending plan
+func insertPlanStepHandler(c rweb.Context) error {
+	var req InsertStepRequest
+	if err := json.Unmarshal(c.Request().Body(), &req); err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid request body"), 400)
+	}
+
+	return editPlanSteps(c, func(plan *planner.TaskPlanner) error {
+		step, err := plan.InsertStep(req.Step, req.Position)
+		if err != nil {
+			return err
+		}
+		return planner.NewStepExecutor().ValidateStep(step)
+	})
+}
+
+// updatePlanStepHandler edits a single step of a pending plan
+func updatePlanStepHandler(c rweb.Context) error {
+	stepID := c.Request().Param("stepId")
+
+	var edit planner.StepEdit
+	if err := json.Unmarshal(c.Request().Body(), &edit); err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid request body"), 400)
+	}
+
+	return editPlanSteps(c, func(plan *planner.TaskPlanner) error {
+		step, err := plan.UpdateStep(stepID, edit)
+		if err != nil {
+			return err
+		}
+		// Only re-check the tool schema when the call itself changed
+		if edit.Tool != nil || edit.Params != nil {
+			return planner.NewStepExecutor().ValidateStep(step)
+		}
+		return nil
+	})
+}
+
+// deletePlanStepHandler removes a step from a pending plan
+func deletePlanStepHandler(c rweb.Context) error {
+	stepID := c.Request().Param("stepId")
+
+	return editPlanSteps(c, func(plan *planner.TaskPlanner) error {
+		return plan.RemoveStep(stepID)
+	})
+}
+
+// editPlanSteps loads the plan named in the route, applies edit to its steps,
+// persists the result and responds with the updated plan
+func editPlanSteps(c rweb.Context, edit func(plan *planner.TaskPlanner) error) error {
+	planID := c.Request().Param("id")
+	if planID == "" {
+		return c.WriteError(serr.New("plan ID required"), 400)
+	}
+
+	taskDB := db.GetTaskPlanDB()
+	dbPlan, err := taskDB.GetPlan(planID)
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get plan"), 404)
+	}
+
+	plan, err := fromDBPlan(dbPlan)
+	if err != nil {
+		return c.WriteError(err, 500)
+	}
+
+	if err := edit(plan); err != nil {
+		status := 400
+		if strings.Contains(err.Error(), "not found") {
+			status = 404
+		} else if strings.Contains(err.Error(), "only be edited while pending") {
+			status = 409
+		}
+		return c.WriteError(err, status)
+	}
+
+	stepsJSON, err := json.Marshal(plan.Steps)
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to marshal steps"), 500)
+	}
+	dbPlan.Steps = stepsJSON
+
+	if err := taskDB.SavePlan(dbPlan); err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to save plan"), 500)
+	}
+
+	broadcastPlanEvent("plan_updated", plan.SessionID, plan.ID, map[string]interface{}{
+		"steps": plan.Steps,
+	})
+
+	return c.WriteJSON(toPlanResponse(plan))
+}
@@ -0,0 +1,49 @@
+package web
+
+import (
+	"rcode/config"
+	"rcode/db"
+	"rcode/planner"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/serr"
+)
+
+// RecoverInterruptedPlans finds plans left executing by a previous run of the server.
+// Each is paused at its first unfinished step, or resumed when RCODE_PLAN_AUTO_RESUME is set.
+func RecoverInterruptedPlans() error {
+	taskDB := db.GetTaskPlanDB()
+	if taskDB == nil {
+		return serr.New("task plan database not initialized")
+	}
+
+	plans, err := taskDB.GetPlansByStatus(db.PlanStatusExecuting)
+	if err != nil {
+		return serr.Wrap(err, "failed to find interrupted plans")
+	}
+
+	autoResume := config.Get().PlanAutoResume
+	for _, dbPlan := range plans {
+		plan, err := fromDBPlan(dbPlan)
+		if err != nil {
+			logger.LogErr(err, "failed to load interrupted plan", "plan_id", dbPlan.ID)
+			continue
+		}
+
+		taskPlanner := newTaskPlanner()
+		action, err := taskPlanner.RecoverPlan(plan, autoResume)
+		if err != nil {
+			logger.LogErr(err, "failed to recover interrupted plan", "plan_id", plan.ID)
+			continue
+		}
+
+		logger.Info("Recovered interrupted plan", "plan_id", plan.ID, "action", string(action),
+			"next_step", plan.CurrentStep+1, "total_steps", len(plan.Steps))
+
+		if action == planner.RecoveryResumed {
+			go runPlan(taskPlanner, plan)
+		}
+	}
+
+	return nil
+}
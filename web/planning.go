@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math/rand"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/rohanthewiz/element"
@@ -13,13 +14,16 @@ import (
 	"github.com/rohanthewiz/serr"
 	"rcode/context"
 	"rcode/db"
+	"rcode/metrics"
 	"rcode/planner"
+	"rcode/tools"
 )
 
 // CreatePlanRequest represents a request to create a task plan
 type CreatePlanRequest struct {
 	Description string `json:"description"`
 	AutoExecute bool   `json:"auto_execute"`
+	UseLLM      bool   `json:"use_llm"` // Decompose the task with the model instead of the heuristic analyzer
 }
 
 // PlanResponse represents a task plan in API responses
@@ -50,50 +54,35 @@ func createPlanHandler(c rweb.Context) error {
 		return c.WriteError(serr.New("description required"), 400)
 	}
 	
-	// Get context manager
-	contextMgr := context.NewManager()
+	plan, err := createSessionPlan(sessionID, req)
+	if err != nil {
+		return c.WriteError(err, 500)
+	}
 	
-	// Create planner instance with context using factory
-	plannerOpts := planner.PlannerOptions{
-		MaxConcurrentSteps: 3,
-		EnableCheckpoints:  true,
-		CheckpointInterval: 5,
-		ContextManager:     contextMgr,
+	return c.WriteJSON(toPlanResponse(plan))
+}
+
+// createSessionPlan creates and saves a plan for a session, starting it if req asks to
+func createSessionPlan(sessionID string, req CreatePlanRequest) (*planner.TaskPlanner, error) {
+	plannerOpts := taskPlannerOptions()
+	if req.UseLLM {
+		plannerOpts.ContextManager = GetContextManager()
+		plannerOpts.ModelClient = newAnthropicModelClient()
 	}
-	factory := planner.NewPlannerFactory()
-	taskPlanner := factory.CreatePlanner(plannerOpts)
+	taskPlanner := newTaskPlannerWithOptions(plannerOpts)
 	
 	// Create plan
 	plan, err := taskPlanner.CreatePlan(req.Description)
 	if err != nil {
-		return c.WriteError(serr.Wrap(err, "failed to create plan"), 500)
+		return nil, serr.Wrap(err, "failed to create plan")
 	}
 	
 	// Associate with session
 	plan.SessionID = sessionID
 	
 	// Save to database
-	taskDB := db.GetTaskPlanDB()
-	dbPlan := &db.TaskPlan{
-		ID:          plan.ID,
-		SessionID:   plan.SessionID,
-		Description: plan.Description,
-		Status:      db.PlanStatus(plan.Status),
-		CreatedAt:   plan.CreatedAt,
-		UpdatedAt:   plan.UpdatedAt,
-	}
-	
-	// Marshal plan details
-	stepsJSON, _ := json.Marshal(plan.Steps)
-	contextJSON, _ := json.Marshal(plan.Context)
-	checkpointsJSON, _ := json.Marshal(plan.Checkpoints)
-	
-	dbPlan.Steps = stepsJSON
-	dbPlan.Context = contextJSON
-	dbPlan.Checkpoints = checkpointsJSON
-	
-	if err := taskDB.SavePlan(dbPlan); err != nil {
-		return c.WriteError(serr.Wrap(err, "failed to save plan"), 500)
+	if err := db.GetTaskPlanDB().SavePlan(toDBPlan(plan)); err != nil {
+		return nil, serr.Wrap(err, "failed to save plan")
 	}
 	
 	// Broadcast plan creation event
@@ -113,8 +102,160 @@ func createPlanHandler(c rweb.Context) error {
 		}()
 	}
 	
-	// Create response
-	response := PlanResponse{
+	return plan, nil
+}
+
+// dbPlanFiler saves the plans tools file, such as security_scan's remediation plans
+type dbPlanFiler struct{}
+
+// FilePlan saves a plan of the steps for the session and announces it to the plan panel
+func (dbPlanFiler) FilePlan(sessionID, description string, steps []tools.PlanStep) (string, error) {
+	taskSteps := make([]planner.TaskStep, 0, len(steps))
+	for _, step := range steps {
+		taskSteps = append(taskSteps, planner.TaskStep{
+			ID:           step.ID,
+			Description:  step.Description,
+			Tool:         step.Tool,
+			Params:       step.Params,
+			Dependencies: step.Dependencies,
+		})
+	}
+
+	plan, err := newTaskPlanner().CreatePlanWithSteps(description, taskSteps)
+	if err != nil {
+		return "", serr.Wrap(err, "failed to create plan")
+	}
+	plan.SessionID = sessionID
+
+	if err := db.GetTaskPlanDB().SavePlan(toDBPlan(plan)); err != nil {
+		return "", serr.Wrap(err, "failed to save plan")
+	}
+
+	broadcastPlanEvent("plan_created", sessionID, plan.ID, map[string]interface{}{
+		"description": plan.Description,
+		"steps":       len(plan.Steps),
+		"status":      plan.Status,
+	})
+	return plan.ID, nil
+}
+
+// InitPlanFiler lets tools file plans for the user to run
+func InitPlanFiler() {
+	tools.SetPlanFiler(dbPlanFiler{})
+}
+
+// newTaskPlanner creates a planner instance with context using the factory.
+// Flagged steps are gated on user approval through the permission modal.
+func newTaskPlanner() *planner.Planner {
+	return newTaskPlannerWithOptions(taskPlannerOptions())
+}
+
+// newTaskPlannerWithOptions creates a planner from customized options
+func newTaskPlannerWithOptions(plannerOpts planner.PlannerOptions) *planner.Planner {
+	taskPlanner := planner.NewPlannerFactory().CreatePlanner(plannerOpts)
+	taskPlanner.SetApprovalFunc(requestPlanStepApproval)
+	return taskPlanner
+}
+
+// taskPlannerOptions returns the options used for web planners
+func taskPlannerOptions() planner.PlannerOptions {
+	plannerOpts := planner.DefaultPlannerOptions()
+	plannerOpts.MaxConcurrentSteps = 3
+	plannerOpts.CheckpointInterval = 5
+	plannerOpts.ContextManager = context.NewManager()
+	return plannerOpts
+}
+
+// toDBPlan converts a planner task plan into its database representation
+func toDBPlan(plan *planner.TaskPlanner) *db.TaskPlan {
+	dbPlan := &db.TaskPlan{
+		ID:          plan.ID,
+		SessionID:   plan.SessionID,
+		Description: plan.Description,
+		Status:      db.PlanStatus(plan.Status),
+		CreatedAt:   plan.CreatedAt,
+		UpdatedAt:   plan.UpdatedAt,
+	}
+
+	// Marshal plan details
+	dbPlan.Steps, _ = json.Marshal(plan.Steps)
+	dbPlan.Context, _ = json.Marshal(plan.Context)
+	dbPlan.Checkpoints, _ = json.Marshal(plan.Checkpoints)
+
+	return dbPlan
+}
+
+// fromDBPlan converts a stored plan back into a planner task plan
+func fromDBPlan(dbPlan *db.TaskPlan) (*planner.TaskPlanner, error) {
+	plan := &planner.TaskPlanner{
+		ID:          dbPlan.ID,
+		SessionID:   dbPlan.SessionID,
+		Description: dbPlan.Description,
+		Status:      planner.TaskStatus(dbPlan.Status),
+		StartTime:   dbPlan.CreatedAt,
+		CreatedAt:   dbPlan.CreatedAt,
+		UpdatedAt:   dbPlan.UpdatedAt,
+		CompletedAt: dbPlan.CompletedAt,
+	}
+
+	if err := json.Unmarshal(dbPlan.Steps, &plan.Steps); err != nil {
+		return nil, serr.Wrap(err, "failed to unmarshal steps")
+	}
+	if len(dbPlan.Checkpoints) > 0 {
+		if err := json.Unmarshal(dbPlan.Checkpoints, &plan.Checkpoints); err != nil {
+			logger.LogErr(err, "failed to unmarshal checkpoints", "plan_id", dbPlan.ID)
+		}
+	}
+	if len(dbPlan.Context) > 0 {
+		if err := json.Unmarshal(dbPlan.Context, &plan.Context); err != nil {
+			logger.LogErr(err, "failed to unmarshal context", "plan_id", dbPlan.ID)
+		}
+	}
+	if plan.Context == nil {
+		plan.Context = &planner.TaskContext{
+			Variables:     make(map[string]interface{}),
+			Environment:   make(map[string]string),
+			Files:         make([]string, 0),
+			ModifiedFiles: make([]string, 0),
+		}
+	}
+
+	return plan, nil
+}
+
+// dryRunPlanHandler previews what each step of a plan would do without executing it
+func dryRunPlanHandler(c rweb.Context) error {
+	planID := c.Request().Param("id")
+	if planID == "" {
+		return c.WriteError(serr.New("plan ID required"), 400)
+	}
+
+	dbPlan, err := db.GetTaskPlanDB().GetPlan(planID)
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get plan"), 404)
+	}
+
+	plan, err := fromDBPlan(dbPlan)
+	if err != nil {
+		return c.WriteError(err, 500)
+	}
+
+	taskPlanner := newTaskPlanner()
+	if err := taskPlanner.LoadPlan(plan); err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to load plan"), 500)
+	}
+
+	report, err := taskPlanner.DryRunPlan(planID)
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to dry run plan"), 500)
+	}
+
+	return c.WriteJSON(report)
+}
+
+// toPlanResponse converts a planner task plan into an API response
+func toPlanResponse(plan *planner.TaskPlanner) PlanResponse {
+	return PlanResponse{
 		ID:          plan.ID,
 		SessionID:   plan.SessionID,
 		Description: plan.Description,
@@ -124,8 +265,6 @@ func createPlanHandler(c rweb.Context) error {
 		UpdatedAt:   plan.UpdatedAt,
 		CompletedAt: plan.CompletedAt,
 	}
-	
-	return c.WriteJSON(response)
 }
 
 // listPlansHandler lists all plans for a session
@@ -179,115 +318,131 @@ func executePlanHandler(c rweb.Context) error {
 		return c.WriteError(serr.Wrap(err, "failed to get plan"), 404)
 	}
 	
+	plan, err := fromDBPlan(dbPlan)
+	if err != nil {
+		return c.WriteError(err, 500)
+	}
+	// Continue a paused plan from its first unfinished step
+	plan.PrepareResume()
+	
 	// Create planner instance using factory
-	contextMgr := context.NewManager()
-	plannerOpts := planner.PlannerOptions{
-		MaxConcurrentSteps: 3,
-		EnableCheckpoints:  true,
-		CheckpointInterval: 5,
-		ContextManager:     contextMgr,
+	taskPlanner := newTaskPlanner()
+	if err := taskPlanner.LoadPlan(plan); err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to load plan into planner"), 500)
 	}
-	factory := planner.NewPlannerFactory()
-	taskPlanner := factory.CreatePlanner(plannerOpts)
 	
 	// Execute plan asynchronously
 	go func() {
-		logger.Info("Starting plan execution", "plan_id", planID)
+		logger.Info("Starting plan execution", "plan_id", planID, "session_id", dbPlan.SessionID)
 		
 		// Update status to executing
 		dbPlan.Status = db.PlanStatusExecuting
 		if err := taskDB.SavePlan(dbPlan); err != nil {
-			logger.LogErr(err, "failed to update plan status", "plan_id", planID)
+			logger.LogErr(err, "failed to update plan status", "plan_id", planID, "session_id", dbPlan.SessionID)
 		}
 		
 		broadcastPlanEvent("plan_executing", dbPlan.SessionID, planID, nil)
 		
-		// Convert DB plan to planner.TaskPlanner
-		var steps []planner.TaskStep
-		if err := json.Unmarshal(dbPlan.Steps, &steps); err != nil {
-			logger.LogErr(err, "failed to unmarshal steps", "plan_id", planID)
-			return
-		}
-		
-		var checkpoints []planner.Checkpoint
-		if dbPlan.Checkpoints != nil {
-			if err := json.Unmarshal(dbPlan.Checkpoints, &checkpoints); err != nil {
-				logger.LogErr(err, "failed to unmarshal checkpoints", "plan_id", planID)
-			}
-		}
-		
-		var ctx *planner.TaskContext
-		if dbPlan.Context != nil {
-			if err := json.Unmarshal(dbPlan.Context, &ctx); err != nil {
-				logger.LogErr(err, "failed to unmarshal context", "plan_id", planID)
-				ctx = &planner.TaskContext{
-					Variables:     make(map[string]interface{}),
-					Environment:   make(map[string]string),
-					Files:         make([]string, 0),
-					ModifiedFiles: make([]string, 0),
-				}
-			}
-		} else {
-			ctx = &planner.TaskContext{
-				Variables:     make(map[string]interface{}),
-				Environment:   make(map[string]string),
-				Files:         make([]string, 0),
-				ModifiedFiles: make([]string, 0),
+		runPlan(taskPlanner, plan)
+	}()
+	
+	return c.WriteJSON(map[string]string{
+		"status": "execution_started",
+		"plan_id": planID,
+	})
+}
+
+// activePlans maps the ID of each executing plan to its planner, so it can be cancelled
+var activePlans sync.Map
+
+// runPlan executes a plan already loaded into taskPlanner and records the outcome.
+// Step progress is journaled by the planner as it runs.
+func runPlan(taskPlanner *planner.Planner, plan *planner.TaskPlanner) {
+	taskDB := db.GetTaskPlanDB()
+
+	activePlans.Store(plan.ID, taskPlanner)
+	defer activePlans.Delete(plan.ID)
+
+	if err := taskPlanner.ExecutePlan(plan.ID); err != nil {
+		if plan.Status == planner.TaskStatusCancelled {
+			logger.Info("Plan execution cancelled", "plan_id", plan.ID, "session_id", plan.SessionID)
+			if err := taskDB.SavePlan(toDBPlan(plan)); err != nil {
+				logger.LogErr(err, "failed to update plan status", "plan_id", plan.ID, "session_id", plan.SessionID)
 			}
-		}
-		
-		// Create planner.TaskPlanner from DB data
-		plan := &planner.TaskPlanner{
-			ID:          dbPlan.ID,
-			SessionID:   dbPlan.SessionID,
-			Description: dbPlan.Description,
-			Status:      planner.TaskStatus(dbPlan.Status),
-			Steps:       steps,
-			CurrentStep: 0,
-			Checkpoints: checkpoints,
-			Context:     ctx,
-			StartTime:   dbPlan.CreatedAt, // Use CreatedAt as StartTime
-			CreatedAt:   dbPlan.CreatedAt,
-			UpdatedAt:   dbPlan.UpdatedAt,
-			CompletedAt: dbPlan.CompletedAt,
-		}
-		
-		// Load the plan into the planner's memory
-		if err := taskPlanner.LoadPlan(plan); err != nil {
-			logger.LogErr(err, "failed to load plan into planner", "plan_id", planID)
+			broadcastPlanEvent("plan_cancelled", plan.SessionID, plan.ID, map[string]string{"status": "cancelled"})
+			metrics.Plans.Inc("cancelled")
 			return
 		}
-		
-		// Execute the plan
-		if err := taskPlanner.ExecutePlan(planID); err != nil {
-			logger.LogErr(err, "plan execution failed", "plan_id", planID)
-			
-			// Update status to failed
-			dbPlan.Status = db.PlanStatusFailed
-			now := time.Now()
-			dbPlan.CompletedAt = &now
-			if err := taskDB.SavePlan(dbPlan); err != nil {
-				logger.LogErr(err, "failed to update plan status", "plan_id", planID)
-			}
-			
-			broadcastPlanEvent("plan_failed", dbPlan.SessionID, planID, map[string]interface{}{
-				"error": err.Error(),
-			})
-		} else {
-			// Update status to completed
-			dbPlan.Status = db.PlanStatusCompleted
-			now := time.Now()
-			dbPlan.CompletedAt = &now
-			if err := taskDB.SavePlan(dbPlan); err != nil {
-				logger.LogErr(err, "failed to update plan status", "plan_id", planID)
-			}
-			
-			broadcastPlanEvent("plan_completed", dbPlan.SessionID, planID, nil)
+
+		logger.LogErr(err, "plan execution failed", "plan_id", plan.ID, "session_id", plan.SessionID)
+
+		// Update status to failed
+		dbPlan := toDBPlan(plan)
+		dbPlan.Status = db.PlanStatusFailed
+		if err := taskDB.SavePlan(dbPlan); err != nil {
+			logger.LogErr(err, "failed to update plan status", "plan_id", plan.ID, "session_id", plan.SessionID)
 		}
-	}()
-	
+
+		broadcastPlanEvent("plan_failed", plan.SessionID, plan.ID, map[string]interface{}{
+			"status": "failed",
+			"error":  err.Error(),
+		})
+		notifyPlanFinished(plan.SessionID, plan.ID, plan.Description, err)
+		metrics.Plans.Inc("failed")
+		return
+	}
+
+	// Update status to completed
+	dbPlan := toDBPlan(plan)
+	dbPlan.Status = db.PlanStatusCompleted
+	if err := taskDB.SavePlan(dbPlan); err != nil {
+		logger.LogErr(err, "failed to update plan status", "plan_id", plan.ID, "session_id", plan.SessionID)
+	}
+
+	broadcastPlanEvent("plan_completed", plan.SessionID, plan.ID, map[string]string{"status": "completed"})
+	notifyPlanFinished(plan.SessionID, plan.ID, plan.Description, nil)
+	metrics.Plans.Inc("completed")
+}
+
+// cancelPlanHandler stops an executing plan, interrupting its current step,
+// or cancels a plan that has not started
+func cancelPlanHandler(c rweb.Context) error {
+	planID := c.Request().Param("id")
+	if planID == "" {
+		return c.WriteError(serr.New("plan ID required"), 400)
+	}
+
+	if value, running := activePlans.Load(planID); running {
+		if err := value.(*planner.Planner).CancelPlan(planID); err != nil {
+			return c.WriteError(serr.Wrap(err, "failed to cancel plan"), 500)
+		}
+		// runPlan records the outcome once the current step has stopped
+		return c.WriteJSON(map[string]string{
+			"status":  "cancelling",
+			"plan_id": planID,
+		})
+	}
+
+	taskDB := db.GetTaskPlanDB()
+	dbPlan, err := taskDB.GetPlan(planID)
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get plan"), 404)
+	}
+
+	switch dbPlan.Status {
+	case db.PlanStatusCompleted, db.PlanStatusFailed, db.PlanStatusCancelled:
+		return c.WriteError(serr.New(fmt.Sprintf("plan is already %s", dbPlan.Status)), 409)
+	}
+
+	dbPlan.Status = db.PlanStatusCancelled
+	if err := taskDB.SavePlan(dbPlan); err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to save plan"), 500)
+	}
+
+	broadcastPlanEvent("plan_cancelled", dbPlan.SessionID, planID, map[string]string{"status": "cancelled"})
+
 	return c.WriteJSON(map[string]string{
-		"status": "execution_started",
+		"status":  "cancelled",
 		"plan_id": planID,
 	})
 }
@@ -332,6 +487,11 @@ func getPlanStatusHandler(c rweb.Context) error {
 	return c.WriteJSON(response)
 }
 
+// RollbackPlanRequest names the checkpoint to roll a plan back to
+type RollbackPlanRequest struct {
+	CheckpointID string `json:"checkpoint_id"`
+}
+
 // rollbackPlanHandler rolls back a plan to a checkpoint
 func rollbackPlanHandler(c rweb.Context) error {
 	planID := c.Request().Param("id")
@@ -339,9 +499,7 @@ func rollbackPlanHandler(c rweb.Context) error {
 		return c.WriteError(serr.New("plan ID required"), 400)
 	}
 	
-	var req struct {
-		CheckpointID string `json:"checkpoint_id"`
-	}
+	var req RollbackPlanRequest
 	if err := json.Unmarshal(c.Request().Body(), &req); err != nil {
 		return c.WriteError(serr.Wrap(err, "invalid request body"), 400)
 	}
@@ -459,6 +617,48 @@ func listCheckpointsHandler(c rweb.Context) error {
 	return c.WriteJSON(checkpoints)
 }
 
+// checkpointDiffHandler returns unified diffs between the files snapshotted at a
+// checkpoint and their current content, so a rollback can be reviewed first
+func checkpointDiffHandler(c rweb.Context) error {
+	planID := c.Request().Param("id")
+	checkpointID := c.Request().Param("checkpointId")
+	if planID == "" || checkpointID == "" {
+		return c.WriteError(serr.New("plan ID and checkpoint ID required"), 400)
+	}
+
+	taskDB := db.GetTaskPlanDB()
+	plan, err := taskDB.GetPlan(planID)
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get plan"), 404)
+	}
+
+	var checkpoints []planner.Checkpoint
+	if len(plan.Checkpoints) > 0 {
+		if err := json.Unmarshal(plan.Checkpoints, &checkpoints); err != nil {
+			return c.WriteError(serr.Wrap(err, "failed to unmarshal checkpoints"), 500)
+		}
+	}
+
+	found := false
+	for _, cp := range checkpoints {
+		if cp.ID == checkpointID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return c.WriteError(serr.New("checkpoint not found"), 404)
+	}
+
+	snapshots := planner.NewSnapshotManager(planner.NewSnapshotStoreAdapter(taskDB))
+	result, err := snapshots.DiffCheckpoint(planID, checkpointID)
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to diff checkpoint"), 500)
+	}
+
+	return c.WriteJSON(result)
+}
+
 // broadcastPlanEvent broadcasts a plan-related event via SSE
 func broadcastPlanEvent(eventType, sessionID, planID string, data interface{}) {
 	event := map[string]interface{}{
@@ -472,8 +672,12 @@ func broadcastPlanEvent(eventType, sessionID, planID string, data interface{}) {
 		event["data"] = data
 	}
 	
-	// Use existing SSE broadcast function
-	broadcastJSON(eventType, event)
+	// Tag the event with its session so the frontend routes it to the session's plan view
+	sseHub.Broadcast(SSEEvent{
+		Type:      eventType,
+		SessionId: sessionID,
+		Data:      event,
+	})
 }
 
 // analyzePlanHandler analyzes the parallelizability of a plan
@@ -0,0 +1,262 @@
+package web
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"rcode/background"
+	"rcode/db"
+	"rcode/platform/shutdown"
+	"rcode/tools"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/rweb"
+	"github.com/rohanthewiz/serr"
+)
+
+// ProcessView is a background process as shown in the UI
+type ProcessView struct {
+	background.Process
+	PreviewPath string `json:"preview_path,omitempty"` // Set while the process listens on a port
+}
+
+func newProcessView(p background.Process) ProcessView {
+	view := ProcessView{Process: p}
+	if len(p.Ports) > 0 {
+		view.PreviewPath = background.PreviewPath(p.ID)
+	}
+	return view
+}
+
+// InitBackgroundProcesses stops the model's background processes on shutdown,
+// so dev servers don't outlive the server that started them, and tells the UI
+// when a process starts listening on a port or exits
+func InitBackgroundProcesses() {
+	supervisor := tools.BackgroundProcesses()
+	supervisor.OnPorts(func(p background.Process) {
+		BroadcastSessionUpdate(p.SessionID, "process_ports", newProcessView(p))
+	})
+	supervisor.OnExit(func(p background.Process) {
+		BroadcastSessionUpdate(p.SessionID, "process_exited", newProcessView(p))
+	})
+
+	shutdown.RegisterHook(func(_ time.Duration) error {
+		supervisor.StopAll()
+		return nil
+	})
+}
+
+// listProcessesHandler returns the background processes, oldest first. In multi-user
+// mode, only those started in the user's sessions are listed.
+func listProcessesHandler(c rweb.Context) error {
+	user := currentUser(c)
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	procs := tools.BackgroundProcesses().List()
+	views := make([]ProcessView, 0, len(procs))
+	for _, p := range procs {
+		if user != nil {
+			if owner, err := processSessionOwner(database, p); err != nil || !user.CanAccess(owner) {
+				continue
+			}
+		}
+		views = append(views, newProcessView(p))
+	}
+	return c.WriteJSON(views)
+}
+
+// processLogsHandler returns a process's recent output
+func processLogsHandler(c rweb.Context) error {
+	id, err := strconv.Atoi(c.Request().Param("id"))
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid process ID"), 400)
+	}
+	n, _ := strconv.Atoi(c.Request().QueryParam("lines"))
+	if n <= 0 {
+		n = 200
+	}
+
+	lines, total, err := tools.BackgroundProcesses().Logs(id, n)
+	if err != nil {
+		return c.WriteError(err, 404)
+	}
+
+	return c.WriteJSON(map[string]interface{}{
+		"lines": lines,
+		"total": total,
+	})
+}
+
+// stopProcessHandler stops a background process
+func stopProcessHandler(c rweb.Context) error {
+	id, err := strconv.Atoi(c.Request().Param("id"))
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid process ID"), 400)
+	}
+
+	proc, err := tools.BackgroundProcesses().Stop(id)
+	if err != nil {
+		return c.WriteError(err, 404)
+	}
+
+	return c.WriteJSON(newProcessView(proc))
+}
+
+// previewClient doesn't follow redirects, so they reach the browser with their Location rewritten
+var previewClient = &http.Client{
+	Timeout: 60 * time.Second,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// Headers that describe a single connection and are not forwarded
+var hopHeaders = map[string]bool{
+	"connection":          true,
+	"keep-alive":          true,
+	"proxy-authenticate":  true,
+	"proxy-authorization": true,
+	"te":                  true,
+	"trailer":             true,
+	"transfer-encoding":   true,
+	"upgrade":             true,
+}
+
+// previewProxyHandler forwards /preview/:id/... to the web server a background process
+// listens on, so the UI can link to or embed the running app
+func previewProxyHandler(c rweb.Context) error {
+	id, err := strconv.Atoi(c.Request().Param("id"))
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid process ID"), 400)
+	}
+	proc, err := tools.BackgroundProcesses().Get(id)
+	if err != nil {
+		return c.WriteError(err, 404)
+	}
+	if proc.Status != background.StatusRunning {
+		return c.WriteError(serr.New(fmt.Sprintf("process %d (%s) is not running", proc.ID, proc.Name)), http.StatusServiceUnavailable)
+	}
+	if len(proc.Ports) == 0 {
+		return c.WriteError(serr.New(fmt.Sprintf("process %d (%s) is not listening on a port yet", proc.ID, proc.Name)), http.StatusServiceUnavailable)
+	}
+
+	prefix := strings.TrimSuffix(background.PreviewPath(id), "/")
+	origin := fmt.Sprintf("http://localhost:%d", proc.Ports[0])
+	path := "/" + strings.TrimPrefix(c.Request().Param("path"), "/")
+
+	resp, err := previewRequest(c, origin, path, prefix)
+	if err != nil {
+		logger.LogErr(err, "preview request failed", "process", id, "path", path)
+		return c.WriteError(serr.Wrap(err, fmt.Sprintf("could not reach %s (%s)", proc.Name, origin)), http.StatusBadGateway)
+	}
+	// rweb drops trailing slashes from request paths, so a redirect that only adds one
+	// would loop; fetch the page it points to instead
+	if isRedirect(resp.StatusCode) && resp.Header.Get("Location") != "" && !strings.HasSuffix(path, "/") &&
+		strings.TrimPrefix(resp.Header.Get("Location"), origin) == path+"/" && (c.Request().Method() == http.MethodGet || c.Request().Method() == http.MethodHead) {
+		resp.Body.Close()
+		path += "/"
+		if resp, err = previewRequest(c, origin, path, prefix); err != nil {
+			return c.WriteError(serr.Wrap(err, fmt.Sprintf("could not reach %s (%s)", proc.Name, origin)), http.StatusBadGateway)
+		}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to read preview response"), http.StatusBadGateway)
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		body = rewritePreviewHTML(body, prefix, path)
+	}
+
+	if err := c.Bytes(body); err != nil {
+		return err
+	}
+	c.Response().SetStatus(resp.StatusCode)
+	for key, vals := range resp.Header {
+		switch {
+		case hopHeaders[strings.ToLower(key)], strings.EqualFold(key, "Content-Length"):
+			continue
+		case strings.EqualFold(key, "X-Frame-Options"):
+			continue // The preview is embedded in the chat UI
+		case strings.EqualFold(key, "Location"):
+			c.Response().SetHeader(key, previewLocation(vals[0], origin, prefix))
+		default:
+			c.Response().SetHeader(key, strings.Join(vals, ","))
+		}
+	}
+	return nil
+}
+
+// previewRequest sends the browser's request on to the app's server
+func previewRequest(c rweb.Context, origin, path, prefix string) (*http.Response, error) {
+	target := origin + path
+	if query := c.Request().Query(); query != "" {
+		target += "?" + query
+	}
+
+	req, err := http.NewRequest(c.Request().Method(), target, bytes.NewReader(c.Request().Body()))
+	if err != nil {
+		return nil, serr.Wrap(err, "invalid preview request")
+	}
+	for _, hdr := range c.Request().Headers() {
+		// Accept-Encoding is left to the client, which decompresses so HTML can be rewritten
+		if hopHeaders[strings.ToLower(hdr.Key)] || strings.EqualFold(hdr.Key, "Host") || strings.EqualFold(hdr.Key, "Accept-Encoding") {
+			continue
+		}
+		req.Header.Add(hdr.Key, hdr.Value)
+	}
+	req.Header.Set("X-Forwarded-Host", c.Request().Host())
+	req.Header.Set("X-Forwarded-Prefix", prefix)
+
+	return previewClient.Do(req)
+}
+
+func isRedirect(status int) bool {
+	return status == http.StatusMovedPermanently || status == http.StatusFound || status == http.StatusSeeOther ||
+		status == http.StatusTemporaryRedirect || status == http.StatusPermanentRedirect
+}
+
+// previewLocation rewrites a redirect to the app's own server so it stays under the preview prefix
+func previewLocation(location, origin, prefix string) string {
+	location = strings.TrimPrefix(location, origin)
+	if strings.HasPrefix(location, "/") && !strings.HasPrefix(location, "//") {
+		return prefix + location
+	}
+	return location
+}
+
+var (
+	// Root-relative URLs in HTML attributes, e.g. src="/assets/app.js"
+	rootURLPattern = regexp.MustCompile(`(?i)(\s(?:src|href|action)=["'])/([^/"'][^"']*|)(["'])`)
+	baseTagPattern = regexp.MustCompile(`(?i)<base[\s>]`)
+	headTagPattern = regexp.MustCompile(`(?i)<head(?:\s[^>]*)?>`)
+)
+
+// rewritePreviewHTML points a page's URLs under the preview prefix. Root-relative URLs in
+// attributes are rewritten, and a base element makes relative URLs resolve against the
+// page's path on the app's server, since the browser's URL may have lost its trailing slash.
+// URLs built by scripts are not rewritten; apps that need them should be served
+// with the prefix as their base path.
+func rewritePreviewHTML(html []byte, prefix, path string) []byte {
+	html = rootURLPattern.ReplaceAll(html, []byte("${1}"+prefix+"/${2}${3}"))
+	if baseTagPattern.Match(html) {
+		return html
+	}
+
+	dir := path[:strings.LastIndex(path, "/")+1]
+	base := []byte(fmt.Sprintf(`<base href="%s%s">`, prefix, dir))
+	if loc := headTagPattern.FindIndex(html); loc != nil {
+		return append(html[:loc[1]:loc[1]], append(base, html[loc[1]:]...)...)
+	}
+	return append(base, html...)
+}
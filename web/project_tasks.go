@@ -0,0 +1,83 @@
+package web
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"rcode/context"
+
+	"github.com/rohanthewiz/rweb"
+	"github.com/rohanthewiz/serr"
+)
+
+// Tasks listed in a new session's context; the rest are counted
+const maxListedTasks = 30
+
+// tasksDir returns the directory whose tasks to list: the project root, or a monorepo
+// package's directory, by path or name
+func tasksDir(pkgName string) (string, error) {
+	cm := GetContextManager()
+	root := cm.GetProjectRoot()
+	if root == "" {
+		var err error
+		if root, err = os.Getwd(); err != nil {
+			return "", serr.Wrap(err, "failed to get working directory")
+		}
+	}
+	if pkgName == "" {
+		return root, nil
+	}
+
+	ctx := cm.GetContext()
+	if ctx == nil {
+		return "", serr.New("the project hasn't been scanned yet")
+	}
+	pkg := ctx.FindPackage(pkgName)
+	if pkg == nil {
+		return "", serr.New(fmt.Sprintf("the project has no package %q", pkgName))
+	}
+	return packageDir(cm, pkg), nil
+}
+
+// listProjectTasksHandler lists the tasks the project defines, the catalog run_task runs
+// from: its Makefile targets, justfile recipes and package.json scripts
+func listProjectTasksHandler(c rweb.Context) error {
+	dir, err := tasksDir(strings.TrimSpace(c.Request().QueryParam("package")))
+	if err != nil {
+		return c.WriteError(err, 400)
+	}
+	tasks := context.DiscoverTasks(dir)
+	if tasks == nil {
+		tasks = []context.ProjectTask{}
+	}
+	return c.WriteJSON(tasks)
+}
+
+// tasksPrompt points a new session to run_task for the project's tasks, listing its justfile
+// recipes and package.json scripts; the make targets are described with the runtime
+func tasksPrompt(root string) string {
+	tasks := context.DiscoverTasks(root)
+	if len(tasks) == 0 {
+		return ""
+	}
+
+	var listed []string
+	count := 0
+	for _, task := range tasks {
+		if task.Runner == "make" {
+			continue
+		}
+		if count++; count <= maxListedTasks {
+			listed = append(listed, task.Run)
+		}
+	}
+	prompt := "Run the project's tasks with the run_task tool rather than raw commands"
+	if count == 0 {
+		return prompt
+	}
+	if count > maxListedTasks {
+		listed = append(listed, fmt.Sprintf("and %d more", count-maxListedTasks))
+	}
+	return prompt + "; besides any make targets, they are: " + strings.Join(listed, ", ")
+}
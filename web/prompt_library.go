@@ -0,0 +1,315 @@
+package web
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"rcode/db"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/rweb"
+	"github.com/rohanthewiz/serr"
+	"gopkg.in/yaml.v3"
+)
+
+// projectPromptsDir holds the project's initial prompts, relative to the project root
+var projectPromptsDir = filepath.Join(".rcode", "prompts")
+
+// promptSetVersion is the version of the prompt set format that export writes
+const promptSetVersion = 1
+
+// PromptSet is the format initial prompts are exported in and imported from, and that
+// the YAML files under .rcode/prompts are written in
+type PromptSet struct {
+	Version int              `yaml:"version" json:"version"`
+	Prompts []PromptSetEntry `yaml:"prompts" json:"prompts"`
+}
+
+// PromptSetEntry is one prompt of a prompt set. A Markdown file under .rcode/prompts holds
+// one prompt, its content in the body and its other fields in YAML front matter.
+type PromptSetEntry struct {
+	Name                string                 `yaml:"name" json:"name"` // Defaults to the file name in a Markdown file
+	Description         string                 `yaml:"description,omitempty" json:"description,omitempty"`
+	Tags                []string               `yaml:"tags,omitempty" json:"tags,omitempty"`
+	Default             bool                   `yaml:"default,omitempty" json:"default,omitempty"` // Applied to new sessions
+	Active              *bool                  `yaml:"active,omitempty" json:"active,omitempty"`   // True when left out
+	IncludesPermissions bool                   `yaml:"includes_permissions,omitempty" json:"includes_permissions,omitempty"`
+	PermissionTemplate  map[string]interface{} `yaml:"permission_template,omitempty" json:"permission_template,omitempty"`
+	Content             string                 `yaml:"content" json:"content"`
+}
+
+// prompt returns the entry as an initial prompt, checking it has a name and content
+func (entry PromptSetEntry) prompt() (*db.InitialPrompt, error) {
+	name := strings.TrimSpace(entry.Name)
+	if name == "" || strings.TrimSpace(entry.Content) == "" {
+		return nil, serr.New("a prompt needs a name and content")
+	}
+	return &db.InitialPrompt{
+		Name:                name,
+		Description:         entry.Description,
+		Content:             strings.TrimSpace(entry.Content),
+		IncludesPermissions: entry.IncludesPermissions,
+		PermissionTemplate:  entry.PermissionTemplate,
+		IsActive:            entry.Active == nil || *entry.Active,
+		IsDefault:           entry.Default,
+		Tags:                entry.Tags,
+	}, nil
+}
+
+// promptSetEntry returns a prompt as a prompt set entry
+func promptSetEntry(prompt *db.InitialPrompt) PromptSetEntry {
+	entry := PromptSetEntry{
+		Name:                prompt.Name,
+		Description:         prompt.Description,
+		Tags:                prompt.Tags,
+		Default:             prompt.IsDefault,
+		IncludesPermissions: prompt.IncludesPermissions,
+		Content:             prompt.Content,
+	}
+	if len(prompt.PermissionTemplate) > 0 {
+		entry.PermissionTemplate = prompt.PermissionTemplate
+	}
+	if !prompt.IsActive {
+		inactive := false
+		entry.Active = &inactive
+	}
+	return entry
+}
+
+// parsePromptSet reads a prompt set in YAML or JSON, or a list of its prompts
+func parsePromptSet(data []byte) ([]*db.InitialPrompt, error) {
+	var set PromptSet
+	if err := yaml.Unmarshal(data, &set); err != nil {
+		var entries []PromptSetEntry
+		if yaml.Unmarshal(data, &entries) != nil {
+			return nil, serr.Wrap(err, "invalid prompt set")
+		}
+		set.Prompts = entries
+	}
+	if set.Version > promptSetVersion {
+		return nil, serr.New("the prompt set is version " + strconv.Itoa(set.Version) + ", newer than this rcode reads")
+	}
+
+	prompts := make([]*db.InitialPrompt, 0, len(set.Prompts))
+	for i, entry := range set.Prompts {
+		prompt, err := entry.prompt()
+		if err != nil {
+			return nil, serr.Wrap(err, "prompt "+strconv.Itoa(i+1))
+		}
+		prompts = append(prompts, prompt)
+	}
+	return prompts, nil
+}
+
+// parsePromptFile reads a prompt set file, or a Markdown file holding one prompt
+func parsePromptFile(path string) ([]*db.InitialPrompt, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to read prompt file")
+	}
+	if strings.ToLower(filepath.Ext(path)) != ".md" {
+		return parsePromptSet(data)
+	}
+
+	var entry PromptSetEntry
+	frontMatter, body := splitFrontMatter(data)
+	if frontMatter != "" {
+		if err := yaml.Unmarshal([]byte(frontMatter), &entry); err != nil {
+			return nil, serr.Wrap(err, "invalid front matter")
+		}
+	}
+	if entry.Name == "" {
+		entry.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if strings.TrimSpace(entry.Content) == "" {
+		entry.Content = body
+	}
+	prompt, err := entry.prompt()
+	if err != nil {
+		return nil, err
+	}
+	return []*db.InitialPrompt{prompt}, nil
+}
+
+// loadProjectPrompts reads the prompts in the files of dir, in file name order, each with
+// its file, relative to root, as its Source. Files that fail to parse are logged and skipped,
+// as are prompts repeating a name.
+func loadProjectPrompts(root, dir string) []*db.InitialPrompt {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.LogErr(err, "failed to read project prompts", "dir", dir)
+		}
+		return nil
+	}
+
+	var prompts []*db.InitialPrompt
+	names := make(map[string]string)
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".md" && ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		filePrompts, err := parsePromptFile(path)
+		if err != nil {
+			logger.LogErr(err, "failed to load project prompts", "file", entry.Name())
+			continue
+		}
+
+		source, err := filepath.Rel(root, path)
+		if err != nil {
+			source = path
+		}
+		for _, prompt := range filePrompts {
+			if other, ok := names[prompt.Name]; ok {
+				logger.Warn("Project prompt repeats a name, skipping", "prompt", prompt.Name, "file", source, "first_file", other)
+				continue
+			}
+			names[prompt.Name] = source
+			prompt.Source = filepath.ToSlash(source)
+			prompts = append(prompts, prompt)
+		}
+	}
+	return prompts
+}
+
+// syncProjectPrompts brings the library's project prompts up to date with .rcode/prompts
+func syncProjectPrompts(database *db.DB) {
+	root := projectRoot()
+	if err := database.SyncProjectPrompts(loadProjectPrompts(root, filepath.Join(root, projectPromptsDir))); err != nil {
+		logger.LogErr(err, "failed to sync project prompts")
+	}
+}
+
+// filterPromptsByTag keeps the prompts with the tag, or all of them when it is empty
+func filterPromptsByTag(prompts []*db.InitialPrompt, tag string) []*db.InitialPrompt {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if tag == "" {
+		return prompts
+	}
+	filtered := make([]*db.InitialPrompt, 0, len(prompts))
+	for _, prompt := range prompts {
+		for _, t := range prompt.Tags {
+			if t == tag {
+				filtered = append(filtered, prompt)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// listPromptTagsHandler returns the tags of the initial prompts, with how many prompts have each
+func listPromptTagsHandler(c rweb.Context) error {
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	prompts, err := database.GetAllInitialPrompts(false)
+	if err != nil {
+		return c.WriteError(err, 500)
+	}
+
+	counts := make(map[string]int)
+	for _, prompt := range prompts {
+		for _, tag := range prompt.Tags {
+			counts[tag]++
+		}
+	}
+	type tagCount struct {
+		Tag     string `json:"tag"`
+		Prompts int    `json:"prompts"`
+	}
+	tags := make([]tagCount, 0, len(counts))
+	for tag, n := range counts {
+		tags = append(tags, tagCount{Tag: tag, Prompts: n})
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Tag < tags[j].Tag })
+	return c.WriteJSON(tags)
+}
+
+// exportPromptsHandler downloads initial prompts as a YAML prompt set: those with the tag
+// parameter, those whose IDs the ids parameter lists, or all of them
+func exportPromptsHandler(c rweb.Context) error {
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	prompts, err := database.GetAllInitialPrompts(false)
+	if err != nil {
+		return c.WriteError(err, 500)
+	}
+	prompts = filterPromptsByTag(prompts, c.Request().QueryParam("tag"))
+
+	if ids := c.Request().QueryParam("ids"); ids != "" {
+		wanted := make(map[int]bool)
+		for _, field := range strings.Split(ids, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil {
+				return c.WriteError(serr.New("ids must be a comma-separated list of prompt IDs"), 400)
+			}
+			wanted[id] = true
+		}
+		var selected []*db.InitialPrompt
+		for _, prompt := range prompts {
+			if wanted[prompt.ID] {
+				selected = append(selected, prompt)
+			}
+		}
+		prompts = selected
+	}
+
+	set := PromptSet{Version: promptSetVersion, Prompts: make([]PromptSetEntry, 0, len(prompts))}
+	for _, prompt := range prompts {
+		set.Prompts = append(set.Prompts, promptSetEntry(prompt))
+	}
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(set); err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to marshal prompts"), 500)
+	}
+	encoder.Close()
+	data := buf.Bytes()
+
+	if err := rweb.File(c, "rcode-prompts.yaml", data); err != nil {
+		return err
+	}
+	c.Response().SetHeader("Content-Type", "application/yaml")
+	return nil
+}
+
+// importPromptsHandler adds the prompts of a YAML or JSON prompt set in the body to the
+// library, replacing those of the same name
+func importPromptsHandler(c rweb.Context) error {
+	prompts, err := parsePromptSet(c.Request().Body())
+	if err != nil {
+		return c.WriteError(err, 400)
+	}
+	if len(prompts) == 0 {
+		return c.WriteError(serr.New("the prompt set has no prompts"), 400)
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	result, err := database.ImportInitialPrompts(prompts)
+	if err != nil {
+		logger.LogErr(err, "Failed to import prompts")
+		return c.WriteError(err, 500)
+	}
+
+	logger.Info("Imported prompts", "created", len(result.Created), "updated", len(result.Updated),
+		"skipped", len(result.Skipped))
+	return c.WriteJSON(result)
+}
@@ -10,8 +10,8 @@ import (
 // PromptManagerHandler serves the prompt management interface
 func PromptManagerHandler(c rweb.Context) error {
 	// Check if user is authenticated
-	_, err := auth.GetAccessToken()
-	if err != nil {
+	if !modelsConnected() {
+		_, err := auth.GetAccessToken()
 		return c.WriteError(err, 401)
 	}
 
@@ -32,13 +32,26 @@ func generatePromptManagerUI() string {
 			b.Div("id", "prompt-manager").R(
 				b.Header().R(
 					b.Div("class", "header-content").R(
-						b.H1().T("Initial Prompt Manager"),
+						b.H1().T("Prompt Manager"),
 						b.Button("id", "close-btn", "class", "btn-secondary", "onclick", "window.close()").T("Close"),
 					),
 				),
 				b.Main().R(
+					b.Div("class", "tabs").R(
+						b.Button("id", "tab-initial", "class", "tab active", "onclick", "switchPromptKind('initial')").T("Initial Prompts"),
+						b.Button("id", "tab-system", "class", "tab", "onclick", "switchPromptKind('system')").T("System Prompts"),
+					),
+					b.P("id", "system-prompts-help", "class", "kind-help hidden").T(
+						"A system prompt is sent with every request of a session, after rcode's own first line. "+
+							"The default is used by sessions that haven't picked one with /system."),
 					b.Div("class", "toolbar").R(
 						b.Button("id", "add-prompt-btn", "class", "btn-primary", "onclick", "showAddPromptForm()").T("+ New Prompt"),
+						b.Select("id", "tag-filter", "class", "tag-filter initial-only", "onchange", "loadPrompts()").R(
+							b.Option("value", "").T("All tags"),
+						),
+						b.Button("class", "btn-secondary initial-only", "onclick", "exportPrompts()").T("Export"),
+						b.Button("class", "btn-secondary initial-only", "onclick", "document.getElementById('import-file').click()").T("Import"),
+						b.Input("type", "file", "id", "import-file", "class", "hidden", "accept", ".yaml,.yml,.json", "onchange", "importPrompts(this)"),
 						b.Button("id", "back-to-chat-btn", "class", "btn-secondary", "onclick", "window.location.href='/'").T("Back to Chat"),
 					),
 					b.Div("id", "prompts-list", "class", "prompts-list").T("Loading prompts..."),
@@ -53,17 +66,22 @@ func generatePromptManagerUI() string {
 								b.Label("for", "prompt-description").T("Description"),
 								b.Input("type", "text", "id", "prompt-description", "name", "description", "placeholder", "Brief description of this prompt"),
 							),
+							b.Div("class", "form-group initial-only").R(
+								b.Label("for", "prompt-tags").T("Tags"),
+								b.Input("type", "text", "id", "prompt-tags", "name", "tags", "placeholder", "Comma-separated, e.g. go, style"),
+							),
 							b.Div("class", "form-group").R(
 								b.Label("for", "prompt-content").T("Content"),
 								b.TextArea("id", "prompt-content", "name", "content", "required", "required", "rows", "4", "placeholder", "The actual prompt text...").R(),
+								b.Div("id", "prompt-variables", "class", "prompt-variables hidden").R(),
 							),
-							b.Div("class", "form-group checkbox-group").R(
+							b.Div("class", "form-group checkbox-group initial-only").R(
 								b.Label().R(
 									b.Input("type", "checkbox", "id", "prompt-includes-permissions", "name", "includes_permissions"),
 									b.Span().T("Includes Permissions"),
 								),
 							),
-							b.Div("class", "form-group checkbox-group").R(
+							b.Div("class", "form-group checkbox-group initial-only").R(
 								b.Label().R(
 									b.Input("type", "checkbox", "id", "prompt-is-active", "name", "is_active", "checked", "checked"),
 									b.Span().T("Active"),
@@ -72,7 +90,7 @@ func generatePromptManagerUI() string {
 							b.Div("class", "form-group checkbox-group").R(
 								b.Label().R(
 									b.Input("type", "checkbox", "id", "prompt-is-default", "name", "is_default"),
-									b.Span().T("Default (automatically applied to new sessions)"),
+									b.Span("id", "prompt-default-label").T("Default (automatically applied to new sessions)"),
 								),
 							),
 							b.Div("class", "form-actions").R(
@@ -185,6 +203,46 @@ func generatePromptManagerCSS() string {
 			background: var(--border);
 		}
 
+		.tabs {
+			display: flex;
+			gap: 0.5rem;
+			margin-bottom: 1rem;
+			border-bottom: 1px solid var(--border);
+		}
+
+		.tab {
+			padding: 0.5rem 1rem;
+			background: none;
+			border: none;
+			border-bottom: 2px solid transparent;
+			color: var(--text-secondary);
+			cursor: pointer;
+			font-size: 0.95rem;
+		}
+
+		.tab.active {
+			color: var(--text-primary);
+			border-bottom-color: var(--accent);
+		}
+
+		.kind-help, .prompt-variables {
+			color: var(--text-secondary);
+			font-size: 0.85rem;
+			margin-bottom: 1rem;
+		}
+
+		.prompt-variables {
+			margin: 0.5rem 0 0;
+		}
+
+		.prompt-variables code {
+			color: var(--accent);
+		}
+
+		.hidden {
+			display: none;
+		}
+
 		.prompts-list {
 			display: grid;
 			gap: 1rem;
@@ -242,6 +300,31 @@ func generatePromptManagerCSS() string {
 			color: var(--text-secondary);
 		}
 
+		.badge-tag {
+			background: var(--bg-tertiary);
+			color: var(--accent);
+			cursor: pointer;
+		}
+
+		.badge-project {
+			background: var(--warning);
+			color: white;
+		}
+
+		.tag-filter {
+			background: var(--bg-tertiary);
+			color: var(--text-primary);
+			border: 1px solid var(--border);
+			border-radius: 6px;
+			padding: 0.5rem;
+		}
+
+		.prompt-card-source {
+			color: var(--text-secondary);
+			font-size: 0.8rem;
+			align-self: center;
+		}
+
 		.prompt-card-description {
 			color: var(--text-secondary);
 			font-size: 0.9rem;
@@ -344,6 +427,31 @@ func generatePromptManagerCSS() string {
 func generatePromptManagerJS() string {
 	return `
 		let editingPromptId = null;
+		let promptKind = 'initial'; // 'initial' or 'system'
+
+		function promptsAPI() {
+			return promptKind === 'system' ? '/api/system-prompts' : '/api/prompts';
+		}
+
+		function switchPromptKind(kind) {
+			promptKind = kind;
+			document.getElementById('tab-initial').classList.toggle('active', kind === 'initial');
+			document.getElementById('tab-system').classList.toggle('active', kind === 'system');
+			document.getElementById('system-prompts-help').classList.toggle('hidden', kind !== 'system');
+			document.getElementById('prompt-variables').classList.toggle('hidden', kind !== 'system');
+			document.querySelectorAll('.initial-only').forEach(el => el.classList.toggle('hidden', kind === 'system'));
+			document.getElementById('prompt-default-label').textContent = kind === 'system'
+				? 'Default (used by sessions without a system prompt of their own)'
+				: 'Default (automatically applied to new sessions)';
+			hidePromptForm();
+			loadPrompts();
+		}
+
+		function displayVariables(variables) {
+			const names = Object.keys(variables || {}).sort();
+			document.getElementById('prompt-variables').innerHTML = 'Template variables: ' +
+				names.map(name => '<code title="' + escapeHtml(variables[name]) + '">{' + name + '}</code>').join(', ');
+		}
 
 		// Load prompts on page load
 		document.addEventListener('DOMContentLoaded', function() {
@@ -352,15 +460,78 @@ func generatePromptManagerJS() string {
 
 		async function loadPrompts() {
 			try {
-				const response = await fetch('/api/prompts');
-				const prompts = await response.json();
-				displayPrompts(prompts);
+				let url = promptsAPI();
+				const tag = document.getElementById('tag-filter').value;
+				if (promptKind === 'initial') {
+					loadTags(tag);
+					if (tag) url += '?tag=' + encodeURIComponent(tag);
+				}
+				const response = await fetch(url);
+				let prompts = await response.json();
+				if (promptKind === 'system') {
+					displayVariables(prompts.variables);
+					prompts = prompts.prompts;
+				}
+				displayPrompts(prompts || []);
 			} catch (error) {
 				console.error('Failed to load prompts:', error);
 				document.getElementById('prompts-list').innerHTML = '<p>Failed to load prompts</p>';
 			}
 		}
 
+		async function loadTags(selected) {
+			try {
+				const response = await fetch('/api/prompts/tags');
+				const tags = await response.json();
+				document.getElementById('tag-filter').innerHTML = '<option value="">All tags</option>' +
+					tags.map(t => '<option value="' + escapeHtml(t.tag) + '"' + (t.tag === selected ? ' selected' : '') + '>' +
+						escapeHtml(t.tag) + ' (' + t.prompts + ')</option>').join('');
+			} catch (error) {
+				console.error('Failed to load tags:', error);
+			}
+		}
+
+		function filterByTag(tag) {
+			const filter = document.getElementById('tag-filter');
+			if (![...filter.options].some(o => o.value === tag)) {
+				filter.add(new Option(tag, tag));
+			}
+			filter.value = tag;
+			loadPrompts();
+		}
+
+		function exportPrompts() {
+			const tag = document.getElementById('tag-filter').value;
+			window.location.href = '/api/prompts/export' + (tag ? '?tag=' + encodeURIComponent(tag) : '');
+		}
+
+		async function importPrompts(input) {
+			const file = input.files[0];
+			input.value = '';
+			if (!file) return;
+
+			try {
+				const response = await fetch('/api/prompts/import', {
+					method: 'POST',
+					headers: { 'Content-Type': 'application/yaml' },
+					body: await file.text()
+				});
+				if (!response.ok) {
+					throw new Error(await response.text() || 'Failed to import prompts');
+				}
+				const result = await response.json();
+				let message = 'Created ' + result.created.length + ', updated ' + result.updated.length + ' prompts.';
+				if (result.skipped.length > 0) {
+					message += '\nSkipped project prompts: ' + result.skipped.join(', ');
+				}
+				alert(message);
+				loadPrompts();
+			} catch (error) {
+				console.error('Failed to import prompts:', error);
+				alert('Failed to import prompts: ' + error.message);
+			}
+		}
+
 		function displayPrompts(prompts) {
 			const container = document.getElementById('prompts-list');
 			
@@ -374,6 +545,15 @@ func generatePromptManagerJS() string {
 				if (prompt.is_default) badges.push('<span class="badge badge-default">Default</span>');
 				if (prompt.includes_permissions) badges.push('<span class="badge badge-permissions">Permissions</span>');
 				if (!prompt.is_active) badges.push('<span class="badge badge-inactive">Inactive</span>');
+				if (prompt.source) badges.push('<span class="badge badge-project">Project</span>');
+				(prompt.tags || []).forEach(tag => badges.push('<span class="badge badge-tag" onclick="filterByTag(\'' +
+					escapeHtml(tag) + '\')">#' + escapeHtml(tag) + '</span>'));
+
+				// Project prompts are changed in their files under .rcode/prompts
+				const actions = prompt.source
+					? '<span class="prompt-card-source">From ' + escapeHtml(prompt.source) + '</span>'
+					: ` + "`" + `<button class="btn-secondary btn-small" onclick="editPrompt(${prompt.id})">Edit</button>
+						<button class="btn-danger btn-small" onclick="deletePrompt(${prompt.id}, '${escapeHtml(prompt.name)}')">Delete</button>` + "`" + `;
 
 				return ` + "`" + `
 					<div class="prompt-card">
@@ -383,10 +563,7 @@ func generatePromptManagerJS() string {
 						</div>
 						${prompt.description ? ` + "`" + `<div class="prompt-card-description">${escapeHtml(prompt.description)}</div>` + "`" + ` : ''}
 						<div class="prompt-card-content">${escapeHtml(prompt.content)}</div>
-						<div class="prompt-card-actions">
-							<button class="btn-secondary btn-small" onclick="editPrompt(${prompt.id})">Edit</button>
-							<button class="btn-danger btn-small" onclick="deletePrompt(${prompt.id}, '${escapeHtml(prompt.name)}')">Delete</button>
-						</div>
+						<div class="prompt-card-actions">${actions}</div>
 					</div>
 				` + "`" + `;
 			}).join('');
@@ -396,7 +573,7 @@ func generatePromptManagerJS() string {
 			editingPromptId = null;
 			document.getElementById('form-title').textContent = 'Add New Prompt';
 			document.getElementById('prompt-form').classList.remove('hidden');
-			document.getElementById('prompt-form').reset();
+			document.querySelector('#prompt-form form').reset();
 			document.getElementById('prompt-is-active').checked = true;
 		}
 
@@ -407,7 +584,7 @@ func generatePromptManagerJS() string {
 
 		async function editPrompt(id) {
 			try {
-				const response = await fetch('/api/prompts/' + id);
+				const response = await fetch(promptsAPI() + '/' + id);
 				const prompt = await response.json();
 				
 				editingPromptId = id;
@@ -418,6 +595,7 @@ func generatePromptManagerJS() string {
 				document.getElementById('prompt-includes-permissions').checked = prompt.includes_permissions;
 				document.getElementById('prompt-is-active').checked = prompt.is_active;
 				document.getElementById('prompt-is-default').checked = prompt.is_default;
+				document.getElementById('prompt-tags').value = (prompt.tags || []).join(', ');
 				
 				document.getElementById('prompt-form').classList.remove('hidden');
 			} catch (error) {
@@ -436,11 +614,12 @@ func generatePromptManagerJS() string {
 				content: formData.get('content'),
 				includes_permissions: formData.get('includes_permissions') === 'on',
 				is_active: formData.get('is_active') === 'on',
-				is_default: formData.get('is_default') === 'on'
+				is_default: formData.get('is_default') === 'on',
+				tags: (formData.get('tags') || '').split(',').map(t => t.trim()).filter(t => t)
 			};
 
 			try {
-				const url = editingPromptId ? '/api/prompts/' + editingPromptId : '/api/prompts';
+				const url = editingPromptId ? promptsAPI() + '/' + editingPromptId : promptsAPI();
 				const method = editingPromptId ? 'PUT' : 'POST';
 				
 				const response = await fetch(url, {
@@ -469,7 +648,7 @@ func generatePromptManagerJS() string {
 			}
 
 			try {
-				const response = await fetch('/api/prompts/' + id, {
+				const response = await fetch(promptsAPI() + '/' + id, {
 					method: 'DELETE'
 				});
 
@@ -26,6 +26,9 @@ func listPromptsHandler(c rweb.Context) error {
 	params, _ := url.ParseQuery(queryString)
 	activeOnly := params.Get("active") == "true"
 
+	// Pick up project prompts added or changed under .rcode/prompts
+	syncProjectPrompts(database)
+
 	// List prompts from database
 	prompts, err := database.GetAllInitialPrompts(activeOnly)
 	if err != nil {
@@ -33,7 +36,7 @@ func listPromptsHandler(c rweb.Context) error {
 		return c.WriteError(serr.Wrap(err, "failed to list prompts"), 500)
 	}
 
-	return c.WriteJSON(prompts)
+	return c.WriteJSON(filterPromptsByTag(prompts, params.Get("tag")))
 }
 
 // getPromptHandler returns a single prompt by ID
@@ -81,7 +84,8 @@ func createPromptHandler(c rweb.Context) error {
 		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
 	}
 
-	// Create prompt in database
+	// Create prompt in database; project prompts come only from their files
+	prompt.Source = ""
 	err = database.CreateInitialPrompt(&prompt)
 	if err != nil {
 		logger.LogErr(err, "Failed to create prompt", "name", prompt.Name)
@@ -119,6 +123,7 @@ func updatePromptHandler(c rweb.Context) error {
 		IncludesPermissions: getBoolFromMap(requestData, "includes_permissions"),
 		IsActive:            getBoolFromMap(requestData, "is_active"),
 		IsDefault:           getBoolFromMap(requestData, "is_default"),
+		Tags:                getStringsFromMap(requestData, "tags"),
 	}
 
 	// Handle permission template if provided
@@ -133,6 +138,10 @@ func updatePromptHandler(c rweb.Context) error {
 		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
 	}
 
+	if status, err := checkLibraryPrompt(database, id); err != nil {
+		return c.WriteError(err, status)
+	}
+
 	// Update prompt in database
 	err = database.UpdateInitialPrompt(&prompt)
 	if err != nil {
@@ -169,6 +178,30 @@ func getBoolFromMap(m map[string]interface{}, key string) bool {
 	return false
 }
 
+func getStringsFromMap(m map[string]interface{}, key string) []string {
+	items, _ := m[key].([]interface{})
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		if v, ok := item.(string); ok {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// checkLibraryPrompt checks that a prompt can be changed in rcode rather than only in its
+// project file, returning the status to reply with if not
+func checkLibraryPrompt(database *db.DB, id int) (int, error) {
+	prompt, err := database.GetInitialPrompt(id)
+	if err != nil {
+		return 404, err
+	}
+	if prompt.Source != "" {
+		return 409, serr.New("prompt " + prompt.Name + " is read from " + prompt.Source + "; change it there")
+	}
+	return 0, nil
+}
+
 // deletePromptHandler deletes an initial prompt
 func deletePromptHandler(c rweb.Context) error {
 	// Get prompt ID from URL
@@ -186,6 +219,10 @@ func deletePromptHandler(c rweb.Context) error {
 		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
 	}
 
+	if status, err := checkLibraryPrompt(database, id); err != nil {
+		return c.WriteError(err, status)
+	}
+
 	// Delete prompt from database
 	err = database.DeleteInitialPrompt(id)
 	if err != nil {
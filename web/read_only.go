@@ -0,0 +1,93 @@
+package web
+
+import (
+	"encoding/json"
+
+	"rcode/db"
+	"rcode/tools"
+
+	"github.com/rohanthewiz/rweb"
+	"github.com/rohanthewiz/serr"
+)
+
+// readOnlyModeTools leave the project alone, like parallelSafeTools, but aren't safe to run
+// at once. They, and parallelSafeTools, run as usual in read-only mode.
+var readOnlyModeTools = map[string]bool{
+	"git_conflicts":         true,
+	"git_commit_message":    true, // Unless it commits; see leavesProjectAlone
+	"remember":              true, // rcode's memory, not the project
+	"publish_artifact":      true, // Stored in rcode's database
+	"todo_write":            true, // The session's own task list
+	"add_note":              true, // The session's own notes
+	"outdated_dependencies": true, // Looks versions up, into the module cache at most
+	"sbom":                  true, // Stored in rcode's database, like publish_artifact
+}
+
+// readOnlyShellTools can do anything, so read-only mode has them ask: the user can let
+// through the commands that only look
+var readOnlyShellTools = map[string]bool{
+	"bash":           true,
+	"run_background": true,
+	"run_snippet":    true,
+	"run_task":       true, // Runs what the project's Makefile, justfile or scripts say
+	"db_query":       true, // Writes to the databases that allow them
+	"test_coverage":  true, // Runs the project's tests
+	"bench":          true, // Runs the project's benchmarks, or a command
+	"security_scan":  true, // Runs the language's vulnerability scanner
+}
+
+// leavesProjectAlone reports whether a tool call leaves the project alone, so read-only mode
+// runs it as usual and auto-accept mode doesn't count it. git_commit_message only does when
+// it doesn't commit the message too.
+func leavesProjectAlone(toolUse tools.ToolUse) bool {
+	if toolUse.Name == "git_commit_message" {
+		if commit, _ := toolUse.Input["commit"].(bool); commit {
+			return false
+		}
+	}
+	return parallelSafeTools[toolUse.Name] || readOnlyModeTools[toolUse.Name]
+}
+
+// readOnlyPermission returns the permission a tool call gets in a read-only session, reporting
+// whether read-only mode changed it. Calls not known to leave the project alone, custom
+// and HTTP tools among them, are denied.
+func readOnlyPermission(toolUse tools.ToolUse, permType db.PermissionType) (db.PermissionType, bool) {
+	switch {
+	case leavesProjectAlone(toolUse):
+		return permType, false
+	case readOnlyShellTools[toolUse.Name]:
+		if permType == db.PermissionDenied {
+			return permType, false
+		}
+		return db.PermissionAsk, permType != db.PermissionAsk
+	default:
+		return db.PermissionDenied, permType != db.PermissionDenied
+	}
+}
+
+// ReadOnlyUpdate turns a session's read-only mode on or off
+type ReadOnlyUpdate struct {
+	ReadOnly bool `json:"read_only"`
+}
+
+// setSessionReadOnlyHandler turns the session's read-only mode on or off. The session's
+// pages are told with a session_read_only event.
+func setSessionReadOnlyHandler(c rweb.Context) error {
+	sessionID := c.Request().Param("id")
+
+	var update ReadOnlyUpdate
+	if err := json.Unmarshal(c.Request().Body(), &update); err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid request body"), 400)
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+	if err := database.SetSessionReadOnly(sessionID, update.ReadOnly); err != nil {
+		return c.WriteError(err, 404)
+	}
+
+	BroadcastSessionUpdate(sessionID, "session_read_only", update)
+	return c.WriteJSON(update)
+}
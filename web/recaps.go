@@ -0,0 +1,264 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"rcode/db"
+	"rcode/providers"
+	"rcode/tools"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/rweb"
+	"github.com/rohanthewiz/serr"
+)
+
+// Bounds on what of a session the model reads to recap it, in characters
+const (
+	recapTranscriptLimit = 60000 // The transcript's latest part is kept
+	recapPartLimit       = 1500  // Each text, tool input or tool result
+)
+
+// recapInstructions ask for a recap of the transcript that follows them
+const recapInstructions = `Write a recap of this coding session, for the developer and the assistant who pick up the work on the project in the next session. Use these sections, each a short list of bullets, and leave out a section with nothing in it:
+
+### Key changes
+What was changed, with the files, functions and commands named exactly.
+
+### Open TODOs
+What was left unfinished, failing or planned but not done.
+
+### Decisions
+What was decided and why, including approaches ruled out.
+
+Write only the recap, without a title or preamble.
+
+Session transcript:
+
+`
+
+// SessionClosed is the reply to closing a session
+type SessionClosed struct {
+	Recap *db.ProjectRecap `json:"recap,omitempty"` // Nil when the session had nothing to recap
+}
+
+// closeSessionHandler closes a session: it writes the session's recap, offered to the next
+// session on the project
+func closeSessionHandler(c rweb.Context) error {
+	sessionID := c.Request().Param("id")
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+	session, err := database.GetSession(sessionID)
+	if err != nil || session == nil {
+		return c.WriteError(serr.New("session not found"), 404)
+	}
+
+	recap, err := recapSession(database, session)
+	if err != nil {
+		status := 500
+		if tools.IsRetryableError(err) {
+			status = 502
+		}
+		return c.WriteError(err, status)
+	}
+
+	return c.WriteJSON(SessionClosed{Recap: recap})
+}
+
+// recapSession has the model recap a session and stores the recap for its project. It
+// returns nil when the session holds no work to recap.
+func recapSession(database *db.DB, session *db.Session) (*db.ProjectRecap, error) {
+	messages, err := database.GetMessages(session.ID)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to get session messages")
+	}
+	transcript := recapTranscript(messages)
+	if transcript == "" {
+		return nil, nil
+	}
+
+	content, err := newAnthropicModelClient().Complete(recapInstructions + transcript)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to recap session")
+	}
+
+	recap := &db.ProjectRecap{
+		ProjectRoot:  projectRoot(),
+		SessionID:    session.ID,
+		SessionTitle: session.Title,
+		Content:      content,
+		UserID:       session.UserID,
+	}
+	if err := database.SaveRecap(recap); err != nil {
+		return nil, err
+	}
+
+	logger.Info("Recapped session", "session_id", session.ID, "recap_id", recap.ID)
+	return recap, nil
+}
+
+// recapTranscript returns a session's messages as text for the model to recap: what the
+// user asked, what the assistant said, and the tools it called with their results. The
+// setup context a session starts with is left out, as is a session with no reply.
+func recapTranscript(messages []providers.ChatMessage) string {
+	// The setup context of createSession comes first and goes unanswered
+	if len(messages) > 1 && messages[0].Role == "user" && messages[1].Role == "user" {
+		messages = messages[1:]
+	}
+
+	var parts []string
+	replied := false
+	for _, msg := range messages {
+		if msg.Role == "assistant" {
+			replied = true
+		}
+		parts = append(parts, recapParts(msg)...)
+	}
+	if !replied {
+		return ""
+	}
+
+	transcript := strings.Join(parts, "\n\n")
+	if len(transcript) > recapTranscriptLimit {
+		cut := len(transcript) - recapTranscriptLimit
+		if i := strings.Index(transcript[cut:], "\n\n"); i >= 0 {
+			cut += i + 2
+		}
+		transcript = "[Earlier messages left out]\n\n" + transcript[cut:]
+	}
+	return transcript
+}
+
+// recapParts returns a message's text, tool calls and tool results as lines of a transcript
+func recapParts(msg providers.ChatMessage) []string {
+	speaker := "User"
+	if msg.Role == "assistant" {
+		speaker = "Assistant"
+	}
+
+	blocks, ok := msg.Content.([]interface{})
+	if !ok {
+		if text := strings.TrimSpace(fmt.Sprint(msg.Content)); text != "" {
+			return []string{speaker + ": " + recapExcerpt(text)}
+		}
+		return nil
+	}
+
+	var parts []string
+	for _, block := range blocks {
+		b, ok := block.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		switch b["type"] {
+		case "text":
+			if text := strings.TrimSpace(fmt.Sprint(b["text"])); text != "" {
+				parts = append(parts, speaker+": "+recapExcerpt(text))
+			}
+		case "tool_use":
+			input, _ := json.Marshal(b["input"])
+			parts = append(parts, fmt.Sprintf("Assistant called %v: %s", b["name"], recapExcerpt(string(input))))
+		case "tool_result":
+			label := "Tool result"
+			if isError, _ := b["is_error"].(bool); isError {
+				label = "Tool error"
+			}
+			parts = append(parts, label+": "+recapExcerpt(toolResultText(b["content"])))
+		}
+	}
+	return parts
+}
+
+// toolResultText returns the text of a stored tool result's content, a string or text blocks
+func toolResultText(content interface{}) string {
+	blocks, ok := content.([]interface{})
+	if !ok {
+		return fmt.Sprint(content)
+	}
+	var texts []string
+	for _, block := range blocks {
+		if b, ok := block.(map[string]interface{}); ok && b["type"] == "text" {
+			texts = append(texts, fmt.Sprint(b["text"]))
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// recapExcerpt cuts a part of the transcript to recapPartLimit characters
+func recapExcerpt(text string) string {
+	runes := []rune(text)
+	if len(runes) <= recapPartLimit {
+		return text
+	}
+	return string(runes[:recapPartLimit]) + " [...]"
+}
+
+// recapPrompt returns a recap chosen for the first message of a new session, or "" when it
+// isn't one of the project's or isn't the user's
+func recapPrompt(database *db.DB, recapID, userID int) string {
+	recap, err := database.GetRecap(recapID)
+	if err != nil {
+		logger.LogErr(err, "failed to load recap for new session", "recap_id", recapID)
+		return ""
+	}
+	if recap.ProjectRoot != projectRoot() {
+		logger.Warn("Recap is of another project, leaving it out", "recap_id", recapID, "project_root", recap.ProjectRoot)
+		return ""
+	}
+	if recap.UserID != userID {
+		logger.Warn("Recap is of another user's session, leaving it out", "recap_id", recapID)
+		return ""
+	}
+
+	from := "the last session"
+	if recap.SessionTitle != "" {
+		from = fmt.Sprintf("the session %q", recap.SessionTitle)
+	}
+	return fmt.Sprintf("## Last Session Recap\nWhere %s on this project left off, on %s:\n\n%s",
+		from, recap.CreatedAt.Format("Jan 2, 2006"), recap.Content)
+}
+
+// listRecapsHandler returns the user's recaps on the current project, the latest first
+func listRecapsHandler(c rweb.Context) error {
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	params, _ := url.ParseQuery(c.Request().Query())
+	limit, _ := strconv.Atoi(params.Get("limit"))
+
+	recaps, err := database.ListRecaps(projectRoot(), currentUserID(c), limit)
+	if err != nil {
+		return c.WriteError(err, 500)
+	}
+
+	return c.WriteJSON(recaps)
+}
+
+// deleteRecapHandler removes a recap, so it is no longer offered
+func deleteRecapHandler(c rweb.Context) error {
+	id, err := strconv.Atoi(c.Request().Param("id"))
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid recap ID"), 400)
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	if err := database.DeleteRecap(id, currentUserID(c)); err != nil {
+		return c.WriteError(err, 404)
+	}
+
+	logger.Info("Deleted recap", "id", id)
+
+	return c.WriteJSON(map[string]bool{"success": true})
+}
@@ -0,0 +1,166 @@
+package web
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"rcode/db"
+	"rcode/review"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/rweb"
+	"github.com/rohanthewiz/serr"
+)
+
+// CreateReviewRequest picks the changes to review
+type CreateReviewRequest struct {
+	Base   string `json:"base,omitempty"`   // Ref to compare against (defaults to the default branch)
+	Head   string `json:"head,omitempty"`   // Ref with the changes (defaults to HEAD)
+	Staged bool   `json:"staged,omitempty"` // Review the staged changes instead of a ref range
+}
+
+// createReviewHandler starts a model review of a branch or diff range. The review runs
+// in the background; progress and completion are broadcast as review_progress and
+// review_complete events.
+func createReviewHandler(c rweb.Context) error {
+	var req CreateReviewRequest
+	if body := c.Request().Body(); len(body) > 0 {
+		if err := json.Unmarshal(body, &req); err != nil {
+			return c.WriteError(serr.Wrap(err, "invalid request body"), 400)
+		}
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	head := req.Head
+	if req.Staged {
+		head = review.StagedRef
+	}
+
+	reviewer := review.NewReviewer(database, newAnthropicModelClient(), projectRoot())
+	codeReview, diff, err := reviewer.Start(req.Base, head)
+	if err != nil {
+		return c.WriteError(err, 400)
+	}
+
+	logger.Info("Started code review", "id", codeReview.ID, "base", codeReview.BaseRef, "head", codeReview.HeadRef)
+
+	go func() {
+		err := reviewer.Run(codeReview, diff, func(done, total int, findings []db.ReviewFinding) {
+			broadcastJSON("review_progress", map[string]interface{}{
+				"reviewId": codeReview.ID,
+				"done":     done,
+				"total":    total,
+				"findings": len(findings),
+			})
+		})
+		if err != nil {
+			logger.LogErr(err, "code review failed")
+		}
+
+		data := map[string]interface{}{"reviewId": codeReview.ID, "status": db.ReviewStatusCompleted}
+		if err != nil {
+			data["status"] = db.ReviewStatusFailed
+			data["error"] = err.Error()
+		}
+		broadcastJSON("review_complete", data)
+	}()
+
+	return c.WriteJSON(codeReview)
+}
+
+// listReviewsHandler returns the current project's reviews, newest first
+func listReviewsHandler(c rweb.Context) error {
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	reviews, err := database.ListReviews(projectRoot(), 50)
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to list reviews"), 500)
+	}
+
+	return c.WriteJSON(reviews)
+}
+
+// getReviewHandler returns a review with its findings
+func getReviewHandler(c rweb.Context) error {
+	id, err := strconv.Atoi(c.Request().Param("id"))
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid review ID"), 400)
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	codeReview, err := database.GetReview(id)
+	if err != nil {
+		return c.WriteError(err, 404)
+	}
+	findings, err := database.ListReviewFindings(id)
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to list review findings"), 500)
+	}
+
+	return c.WriteJSON(map[string]interface{}{
+		"review":   codeReview,
+		"findings": findings,
+	})
+}
+
+// updateFindingHandler marks a finding resolved or open again
+func updateFindingHandler(c rweb.Context) error {
+	id, err := strconv.Atoi(c.Request().Param("id"))
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid review ID"), 400)
+	}
+	findingID, err := strconv.Atoi(c.Request().Param("findingId"))
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid finding ID"), 400)
+	}
+
+	var req struct {
+		Resolved bool `json:"resolved"`
+	}
+	if err := json.Unmarshal(c.Request().Body(), &req); err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid request body"), 400)
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	if err := database.SetFindingResolved(id, findingID, req.Resolved); err != nil {
+		return c.WriteError(err, 404)
+	}
+
+	return c.WriteJSON(map[string]bool{"success": true})
+}
+
+// deleteReviewHandler removes a review and its findings
+func deleteReviewHandler(c rweb.Context) error {
+	id, err := strconv.Atoi(c.Request().Param("id"))
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid review ID"), 400)
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	if err := database.DeleteReview(id); err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to delete review"), 500)
+	}
+
+	logger.Info("Deleted code review", "id", id)
+
+	return c.WriteJSON(map[string]bool{"success": true})
+}
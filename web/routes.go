@@ -4,6 +4,7 @@ import (
 	"embed"
 	"net/http"
 	"rcode/auth"
+	"rcode/config"
 	"strings"
 
 	"github.com/rohanthewiz/rweb"
@@ -16,6 +17,32 @@ var assetsFS embed.FS
 
 // SetupRoutes configures all HTTP routes for the server
 func SetupRoutes(s *rweb.Server) {
+	// Requests from scripts and CI carry an API token instead of a login cookie
+	multiUser = config.Get().MultiUser
+	s.Use(observeRequests)
+	s.Use(logRequests)
+	s.Use(traceRequests)
+	s.Use(requireToken)
+	s.Get("/api/tokens", listAPITokensHandler)
+	s.Post("/api/tokens", createAPITokenHandler)
+	s.Delete("/api/tokens/:id", deleteAPITokenHandler)
+
+	// Multi-user mode signs every request in, and checks what it may reach
+	if multiUser {
+		s.Use(requireUser)
+
+		s.Get("/login", LoginPageHandler)
+		s.Post("/api/login", loginHandler)
+		s.Post("/api/setup", setupHandler)
+		s.Post("/api/logout", logoutHandler)
+		s.Get("/api/me", currentUserHandler)
+
+		// Account management, for admins
+		s.Get("/api/users", listUsersHandler)
+		s.Post("/api/users", createUserHandler)
+		s.Delete("/api/users/:id", deleteUserHandler)
+	}
+
 	// Root endpoint - serves the main web UI
 	s.Get("/", rootHandler)
 
@@ -59,29 +86,58 @@ func SetupRoutes(s *rweb.Server) {
 	// Logout endpoint
 	s.Post("/api/auth/logout", auth.LogoutHandler)
 
+	// Claude accounts; listing them is part of the documented API
+	s.Put("/api/auth/accounts/default", auth.SetDefaultAccountHandler)
+	s.Delete("/api/auth/accounts/:name", auth.RemoveAccountHandler)
+
+	// Effective configuration
+	s.Get("/api/config", getConfigHandler)
+
+	// User settings, shared by all browsers
+	s.Get("/api/settings", getSettingsHandler)
+	s.Put("/api/settings", updateSettingsHandler)
+
+	// Sessions, messages, tools, plans and files: the documented API, described by
+	// /api/openapi.json (see openapi.go)
+	for _, route := range apiRoutes {
+		s.AddMethod(route.Method, route.Path, route.handler)
+	}
+	s.Get("/api/openapi.json", openAPIHandler)
+
 	// API endpoints
 	s.Get("/api/app", appInfoHandler)
-	s.Get("/api/session", listSessionsHandler)
-	s.Post("/api/session", createSessionHandler)
-	s.Delete("/api/session/:id", deleteSessionHandler)
-	s.Post("/api/session/:id/message", sendMessageHandler)
-	s.Get("/api/session/:id/messages", getSessionMessagesHandler)
 	s.Get("/api/session/:id/prompts", getSessionPromptsHandler)
+	s.Get("/api/session/:id/context", getSessionContextHandler)
+	s.Get("/api/session/:id/system-prompt", getSessionSystemPromptHandler)
+	s.Put("/api/session/:id/system-prompt", setSessionSystemPromptHandler)
+	s.Delete("/api/session/:id/context", deleteSessionContextHandler)
+	s.Get("/api/search", searchConversationsHandler)
 
 	// Prompt management endpoints
 	s.Get("/api/prompts", listPromptsHandler)
+	s.Get("/api/prompts/tags", listPromptTagsHandler)
+	s.Get("/api/prompts/export", exportPromptsHandler)
+	s.Post("/api/prompts/import", importPromptsHandler)
 	s.Get("/api/prompts/:id", getPromptHandler)
 	s.Post("/api/prompts", createPromptHandler)
 	s.Put("/api/prompts/:id", updatePromptHandler)
 	s.Delete("/api/prompts/:id", deletePromptHandler)
 
-	// Tool permissions endpoints
-	s.Get("/api/session/:id/tools", getSessionToolsHandler)
-	s.Put("/api/session/:id/tools/:tool", updateToolPermissionHandler)
+	// System prompt endpoints
+	s.Get("/api/system-prompts", listSystemPromptsHandler)
+	s.Get("/api/system-prompts/:id", getSystemPromptHandler)
+	s.Post("/api/system-prompts", createSystemPromptHandler)
+	s.Put("/api/system-prompts/:id", updateSystemPromptHandler)
+	s.Delete("/api/system-prompts/:id", deleteSystemPromptHandler)
+
+	// Project memory endpoints
+	s.Get("/api/memories", listMemoriesHandler)
+	s.Post("/api/memories", createMemoryHandler)
+	s.Delete("/api/memories/:id", deleteMemoryHandler)
 
-	// Permission response endpoints
-	s.Post("/api/permission-response", handlePermissionResponseHandler)
-	s.Post("/api/permission-abort", handlePermissionAbortHandler)
+	// Project recaps, written when sessions are closed
+	s.Get("/api/recaps", listRecapsHandler)
+	s.Delete("/api/recaps/:id", deleteRecapHandler)
 
 	// Context management endpoints
 	s.Get("/api/context", getProjectContextHandler)
@@ -90,27 +146,44 @@ func SetupRoutes(s *rweb.Server) {
 	s.Get("/api/context/changes", getChangeTrackingHandler)
 	s.Get("/api/context/stats", getContextStatsHandler)
 	s.Post("/api/context/suggest-tools", suggestToolsHandler)
+	s.Post("/api/context/semantic-index/refresh", refreshSemanticIndexHandler)
 
 	// Usage tracking endpoints
 	s.Get("/api/session/:id/usage", GetSessionUsageHandler)
 	s.Get("/api/usage/daily", GetDailyUsageHandler)
 	s.Get("/api/usage/global", GetGlobalUsageHandler)
+	s.Get("/api/usage/analytics", usageAnalyticsHandler)
+	s.Get("/api/usage/top-sessions", topSessionsHandler)
+
+	// Prometheus metrics (see metrics.go)
+	s.Get("/metrics", metricsHandler)
+
+	// Tool audit log, across sessions (admins only in multi-user mode)
+	s.Get("/api/audit/tools", auditToolsHandler)
+
+	// Recent server log entries, by session or request (admins only in multi-user mode)
+	s.Get("/api/logs", logsHandler)
+
+	// Database and .rcode state backups (admins only in multi-user mode, see backup_handlers.go)
+	s.Get("/api/admin/backup", listBackupsHandler)
+	s.Post("/api/admin/backup", createBackupHandler)
+	s.Post("/api/admin/restore", restoreHandler)
+
+	// Anthropic's messages API, for other local tools to use RCode's login (see messages_proxy.go)
+	s.Post("/v1/messages", messagesProxyHandler)
 
 	// Task planning endpoints
-	s.Post("/api/session/:id/plan", createPlanHandler)
-	s.Get("/api/session/:id/plans", listPlansHandler)
-	s.Post("/api/plan/:id/execute", executePlanHandler)
-	s.Get("/api/plan/:id/status", getPlanStatusHandler)
-	s.Post("/api/plan/:id/rollback", rollbackPlanHandler)
-	s.Get("/api/plan/:id/checkpoints", listCheckpointsHandler)
+	s.Get("/api/plan/:id/checkpoints/:checkpointId/diff", checkpointDiffHandler)
 	s.Get("/api/plan/:id/analyze", analyzePlanHandler)
 	s.Get("/api/plan/:id/git-operations", getGitOperationsHandler)
 
-	// Plan history endpoints
-	s.Get("/api/session/:id/plans/history", listPlanHistoryHandler)
-	s.Get("/api/plan/:id/full", getPlanFullDetailsHandler)
-	s.Post("/api/plan/:id/clone", clonePlanHandler)
-	s.Delete("/api/plan/:id", deletePlanHandler)
+	// Task template endpoints
+	s.Get("/api/templates", listTaskTemplatesHandler)
+	s.Get("/api/templates/:id", getTaskTemplateHandler)
+	s.Post("/api/templates", createTaskTemplateHandler)
+	s.Put("/api/templates/:id", updateTaskTemplateHandler)
+	s.Delete("/api/templates/:id", deleteTaskTemplateHandler)
+	s.Post("/api/templates/:id/instantiate", instantiateTaskTemplateHandler)
 
 	// SSE endpoint for streaming events
 	s.Get("/events",
@@ -118,7 +191,7 @@ func SetupRoutes(s *rweb.Server) {
 
 			// Create client channel
 			clientChan := make(chan any, clientChanCap)
-			sseHub.Register(clientChan)
+			sseHub.Register(clientChan, currentUser(c))
 
 			// We cannot unregister here become the conn is long-lived
 			// // Ensure cleanup on disconnect
@@ -136,17 +209,10 @@ func SetupRoutes(s *rweb.Server) {
 	s.Get("/prompts", PromptManagerHandler)
 
 	// File Explorer endpoints
-	s.Get("/api/files/tree", getFileTreeHandler)
-	s.Get("/api/files/cwd", getCurrentWorkingDirectoryHandler)
-	s.Get("/api/files/content/*", getFileContentHandler)
-	s.Post("/api/files/search", searchFilesHandler)
-	s.Post("/api/files/create", createFileHandler)
-	s.Put("/api/files/rename", renameFileHandler)
-	s.Delete("/api/files/delete", deleteFileHandler)
-	s.Post("/api/session/:id/files/open", openFileHandler)
-	s.Post("/api/session/:id/files/close", closeFileInSessionHandler)
-	s.Get("/api/session/:id/files/recent", getRecentFilesHandler)
-	s.Get("/api/session/:id/files/open", getSessionOpenFilesHandler)
+	s.Get("/api/files/image/*", getFileImageHandler)
+	s.Get("/api/files/search/stream", searchStreamHandler)
+	s.Post("/api/files/upload", uploadFilesHandler)
+	s.Get("/api/files/download/*", downloadFileHandler)
 
 	// File management endpoints
 	s.Get("/api/files", ListFilesHandler)
@@ -163,6 +229,42 @@ func SetupRoutes(s *rweb.Server) {
 	s.Post("/api/diff/snapshot", createSnapshotHandler)
 	s.Post("/api/diff/generate", generateDiffHandler)
 
+	// Commit message generation
+	s.Post("/api/git/commit-message", commitMessageHandler)
+
+	// Code review endpoints
+	s.Get("/api/reviews", listReviewsHandler)
+	s.Post("/api/reviews", createReviewHandler)
+	s.Get("/api/reviews/:id", getReviewHandler)
+	s.Put("/api/reviews/:id/findings/:findingId", updateFindingHandler)
+	s.Delete("/api/reviews/:id", deleteReviewHandler)
+
+	// Terminal endpoints
+	s.Get("/api/session/:id/terminals", listTerminalsHandler)
+	s.Post("/api/session/:id/terminals", startTerminalHandler)
+	s.Get("/api/terminals/:id/stream", terminalStreamHandler)
+	s.Post("/api/terminals/:id/input", terminalInputHandler)
+	s.Post("/api/terminals/:id/resize", resizeTerminalHandler)
+	s.Delete("/api/terminals/:id", closeTerminalHandler)
+	s.Get("/api/session/:id/tool-usage", getToolUsageHandler)
+	s.Get("/api/session/:id/artifacts/:artifactId", downloadArtifactHandler)
+
+	// Background process endpoints
+	s.Get("/api/processes", listProcessesHandler)
+	s.Get("/api/processes/:id/logs", processLogsHandler)
+	s.Delete("/api/processes/:id", stopProcessHandler)
+
+	// Preview of web servers started by background processes
+	for _, route := range []string{"/preview/:id", "/preview/:id/*path"} {
+		s.Get(route, previewProxyHandler)
+		s.Post(route, previewProxyHandler)
+		s.Put(route, previewProxyHandler)
+		s.Patch(route, previewProxyHandler)
+		s.Delete(route, previewProxyHandler)
+		s.Head(route, previewProxyHandler)
+		s.Options(route, previewProxyHandler)
+	}
+
 	// Conversation compaction endpoints
 	s.Post("/api/session/:id/compact", compactSessionHandler)
 	s.Get("/api/session/:id/compaction/stats", getCompactionStatsHandler)
@@ -0,0 +1,56 @@
+package web
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"rcode/db"
+
+	"github.com/rohanthewiz/rweb"
+	"github.com/rohanthewiz/serr"
+)
+
+// searchConversationsHandler searches past sessions by content, tool name or touched file path
+// Query params: q (required), scope (all|content|tool|file), limit
+func searchConversationsHandler(c rweb.Context) error {
+	params, _ := url.ParseQuery(c.Request().Query())
+
+	query := strings.TrimSpace(params.Get("q"))
+	if query == "" {
+		return c.WriteError(serr.New("query parameter 'q' is required"), 400)
+	}
+
+	opts := db.SearchOptions{
+		Query: query,
+		Scope: params.Get("scope"),
+		User:  currentUser(c),
+	}
+	if limitStr := params.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 {
+			return c.WriteError(serr.New("invalid limit"), 400)
+		}
+		opts.Limit = limit
+	}
+
+	// Get database instance
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	results, err := database.SearchConversations(opts)
+	if err != nil {
+		if strings.Contains(err.Error(), "invalid search scope") {
+			return c.WriteError(err, 400)
+		}
+		return c.WriteError(serr.Wrap(err, "failed to search conversations"), 500)
+	}
+
+	return c.WriteJSON(map[string]interface{}{
+		"query":   query,
+		"results": results,
+		"count":   len(results),
+	})
+}
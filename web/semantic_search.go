@@ -0,0 +1,108 @@
+package web
+
+import (
+	"path/filepath"
+	"sync"
+
+	"rcode/config"
+	"rcode/context"
+	"rcode/db"
+	"rcode/embeddings"
+	"rcode/tools"
+
+	"github.com/rohanthewiz/logger"
+)
+
+var (
+	semanticIndexOnce sync.Once
+	semanticIndex     *embeddings.Index // nil if semantic search is unavailable
+)
+
+// getSemanticIndex returns the embeddings index of the project in the working directory
+func getSemanticIndex() *embeddings.Index {
+	semanticIndexOnce.Do(func() {
+		database, err := db.GetDB()
+		if err != nil {
+			logger.LogErr(err, "failed to get database for semantic index")
+			return
+		}
+
+		embedder, err := embeddings.NewEmbedder(config.Get())
+		if err != nil {
+			logger.LogErr(err, "failed to create embedder, semantic search disabled")
+			return
+		}
+		if embedder == nil {
+			return // Off unless RCODE_EMBEDDINGS_PROVIDER chooses a provider
+		}
+
+		semanticIndex = embeddings.NewIndex(database, embedder, projectRoot(), func() []string {
+			return GetContextManager().CodeFiles()
+		})
+		logger.Info("Semantic index ready", "model", embedder.Model())
+	})
+	return semanticIndex
+}
+
+// InitSemanticIndex brings the embeddings index up to date in the background
+func InitSemanticIndex() {
+	index := getSemanticIndex()
+	if index == nil {
+		return
+	}
+
+	go func() {
+		if _, err := index.Refresh(); err != nil {
+			logger.LogErr(err, "failed to build semantic index")
+		}
+	}()
+}
+
+// enableSemanticScoring adds embedding similarity to a context manager's file prioritization,
+// when the embeddings are a model's; hashed words would only repeat its keyword scores
+func enableSemanticScoring(cm *context.Manager) {
+	if index := getSemanticIndex(); index != nil && index.Semantic() {
+		cm.SetSemanticScorer(index)
+	}
+}
+
+// registerSemanticSearchTool adds the semantic_search tool if the index is available
+func registerSemanticSearchTool(registry *tools.Registry) {
+	if index := getSemanticIndex(); index != nil {
+		tools.RegisterSemanticSearchTool(registry, indexSearcher{index: index}, !index.Semantic())
+	}
+}
+
+// indexSearcher adapts the embeddings index to the semantic_search tool
+type indexSearcher struct {
+	index *embeddings.Index
+}
+
+// SearchCode refreshes the index if it may be out of date, then searches it
+func (s indexSearcher) SearchCode(query string, limit int) ([]tools.CodeMatch, error) {
+	if err := s.index.RefreshIfStale(); err != nil {
+		logger.LogErr(err, "failed to refresh semantic index, searching existing entries")
+	}
+
+	matches, err := s.index.Search(query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	root := projectRoot()
+	result := make([]tools.CodeMatch, 0, len(matches))
+	for _, m := range matches {
+		path := m.FilePath
+		if rel, err := filepath.Rel(root, path); err == nil {
+			path = rel
+		}
+		result = append(result, tools.CodeMatch{
+			Path:      path,
+			StartLine: m.StartLine,
+			EndLine:   m.EndLine,
+			Score:     m.Similarity,
+			Content:   m.Content,
+		})
+	}
+	return result, nil
+}
@@ -1,16 +1,21 @@
 package web
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
+	"rcode/config"
 	"rcode/db"
 	"rcode/providers"
 	"rcode/tools"
+	"rcode/tracing"
 
 	"github.com/rohanthewiz/logger"
 	"github.com/rohanthewiz/rweb"
@@ -20,46 +25,9 @@ import (
 // Session represents a chat session (alias for db.Session for backward compatibility)
 type Session = db.Session
 
-// readClaudeMDFiles reads CLAUDE.md files from both the global and project locations
-// and returns their combined content with appropriate headers
-func readClaudeMDFiles() string {
-	var result strings.Builder
-
-	// Read global CLAUDE.md from $HOME/.claude/
-	homeDir, err := os.UserHomeDir()
-	if err == nil {
-		globalPath := filepath.Join(homeDir, ".claude", "CLAUDE.md")
-		if content, err := os.ReadFile(globalPath); err == nil {
-			result.WriteString("## User Instructions (Global)\n")
-			result.WriteString(string(content))
-			result.WriteString("\n\n")
-			logger.Info("Read global CLAUDE.md", "path", globalPath, "size", len(content))
-		} else if !os.IsNotExist(err) {
-			// Log errors other than file not existing
-			logger.LogErr(err, "failed to read global CLAUDE.md", "path", globalPath)
-		}
-	}
-
-	// Read project CLAUDE.md from current working directory
-	workDir, err := os.Getwd()
-	if err == nil {
-		projectPath := filepath.Join(workDir, "CLAUDE.md")
-		if content, err := os.ReadFile(projectPath); err == nil {
-			result.WriteString("## Project Context (Local)\n")
-			result.WriteString(string(content))
-			result.WriteString("\n\n")
-			logger.Info("Read project CLAUDE.md", "path", projectPath, "size", len(content))
-		} else if !os.IsNotExist(err) {
-			// Log errors other than file not existing
-			logger.LogErr(err, "failed to read project CLAUDE.md", "path", projectPath)
-		}
-	}
-
-	return result.String()
-}
-
-// getContextPrompt returns context information as an initial prompt
-func getContextPrompt() string {
+// getContextPrompt returns context information as an initial prompt, for a session scoped
+// to the monorepo package at packagePath when it is set
+func getContextPrompt(packagePath string) string {
 	cm := GetContextManager()
 	if cm == nil || !cm.IsInitialized() {
 		return ""
@@ -94,6 +62,24 @@ func getContextPrompt() string {
 		}
 	}
 
+	// Build and run commands from the Makefile, Dockerfiles and compose file, to use over guessed ones
+	if runtime := ctx.Runtime.Summary(); runtime != "" {
+		contextInfo.WriteString("\n- " + strings.ReplaceAll(runtime, "\n", "\n- "))
+	}
+
+	// The tasks of the Makefile, justfile and package.json, for run_task
+	if tasks := tasksPrompt(ctx.RootPath); tasks != "" {
+		contextInfo.WriteString("\n- " + tasks)
+	}
+
+	// The packages of a monorepo, and the one the session works on
+	if packages := packagesPrompt(ctx); packages != "" {
+		contextInfo.WriteString("\n- " + packages)
+	}
+	if pkg := ctx.FindPackage(packagePath); packagePath != "" && pkg != nil {
+		contextInfo.WriteString("\n- Scoped to the package " + describePackage(*pkg))
+	}
+
 	return contextInfo.String()
 }
 
@@ -102,6 +88,9 @@ type CreateSessionRequest struct {
 	Title            string `json:"title,omitempty"`
 	InitialPromptIDs []int  `json:"initial_prompt_ids,omitempty"`
 	ModelPreference  string `json:"model_preference,omitempty"`
+	RecapID          int    `json:"recap_id,omitempty"` // Recap of an earlier session on the project to start from
+	Package          string `json:"package,omitempty"`  // Monorepo package to scope the session to, by path or name
+	UserID           int    `json:"-"`                  // Owner in multi-user mode, the signed-in user
 }
 
 // createSession creates a new chat session in the database
@@ -112,22 +101,67 @@ func createSession(req *CreateSessionRequest) (*Session, error) {
 		return nil, serr.Wrap(err, "failed to get database")
 	}
 
+	// New sessions start with the user's defaults
+	settings, err := database.GetUserSettings(req.UserID)
+	if err != nil {
+		logger.LogErr(err, "failed to load user settings for new session")
+	}
+	if req.ModelPreference == "" {
+		req.ModelPreference = settings.DefaultModel
+	}
+
+	// A session scoped to a package keeps its path, which outlasts renames in package.json
+	if req.Package != "" {
+		ctx := GetContextManager().GetContext()
+		if ctx == nil || ctx.FindPackage(req.Package) == nil {
+			return nil, serr.New(fmt.Sprintf("the project has no package %q", req.Package))
+		}
+		req.Package = ctx.FindPackage(req.Package).Path
+	}
+
 	// Prepare session options
 	opts := db.SessionOptions{
 		Title:            req.Title,
 		InitialPromptIDs: req.InitialPromptIDs,
 		ModelPreference:  req.ModelPreference,
+		AutoCompact:      settings.AutoCompact,
+		CompactThreshold: settings.CompactThreshold,
+		UserID:           req.UserID,
 	}
 
 	// If no title provided, it will default to "New Chat" in CreateSession
 	// If no prompt IDs provided, it will use default prompts
 
+	// Pick up project prompts added or changed since the last session, as the project's defaults apply
+	syncProjectPrompts(database)
+
 	// Create session (this will handle loading prompts and permissions)
 	session, err := database.CreateSession(opts)
 	if err != nil {
 		return nil, err
 	}
 
+	if req.Package != "" {
+		if err := database.SetSessionPackage(session.ID, req.Package); err != nil {
+			logger.LogErr(err, "failed to scope new session to package", "session_id", session.ID)
+		} else {
+			session.Package = req.Package
+		}
+	}
+
+	// Default permissions apply to tools the session's prompts gave no permission for
+	for toolName, permType := range settings.PermissionDefaults {
+		if perm, err := database.GetToolPermission(session.ID, toolName); err != nil || perm != nil {
+			continue
+		}
+		if err := database.SetToolPermission(session.ID, toolName, permType, nil, 0); err != nil {
+			logger.LogErr(err, "failed to set default tool permission", "tool", toolName, "session_id", session.ID)
+		}
+	}
+
+	// Pick up project commands added or changed since the last session
+	reloadCustomCommands(SlashCommands())
+
 	// Build the initial message with all context
 	var initialContent strings.Builder
 
@@ -137,7 +171,7 @@ func createSession(req *CreateSessionRequest) (*Session, error) {
 	}
 
 	// Add CLAUDE.md files content
-	claudeMDContent := readClaudeMDFiles()
+	claudeMDContent := readClaudeMDFiles(session.ID)
 	if claudeMDContent != "" {
 		if initialContent.Len() > 0 {
 			initialContent.WriteString("\n\n")
@@ -145,8 +179,26 @@ func createSession(req *CreateSessionRequest) (*Session, error) {
 		initialContent.WriteString(claudeMDContent)
 	}
 
+	// Add facts remembered from earlier sessions on this project
+	if memoryContent := memoryPrompt(database); memoryContent != "" {
+		if initialContent.Len() > 0 {
+			initialContent.WriteString("\n\n")
+		}
+		initialContent.WriteString(memoryContent)
+	}
+
+	// Add where the session chosen to carry on from left off
+	if req.RecapID > 0 {
+		if recapContent := recapPrompt(database, req.RecapID, req.UserID); recapContent != "" {
+			if initialContent.Len() > 0 {
+				initialContent.WriteString("\n\n")
+			}
+			initialContent.WriteString(recapContent)
+		}
+	}
+
 	// Add context information if available
-	contextInfo := getContextPrompt()
+	contextInfo := getContextPrompt(session.Package)
 	if contextInfo != "" {
 		if initialContent.Len() > 0 {
 			initialContent.WriteString("\n\n## System Context\n")
@@ -161,18 +213,37 @@ func createSession(req *CreateSessionRequest) (*Session, error) {
 			Content: initialContent.String(),
 		}, "", nil)
 		if err != nil {
-			logger.LogErr(err, "failed to add initial message")
+			logger.LogErr(err, "failed to add initial message", "session_id", session.ID)
 		}
 	}
 
 	return session, nil
 }
 
+// defaultModel is used when neither the message nor the session names a model
+const defaultModel = "claude-sonnet-4-20250514"
+
 // MessageRequest represents a request to send a message
 type MessageRequest struct {
 	Content string      `json:"content"`
 	Model   string      `json:"model,omitempty"`
-	Images  []ImageData `json:"images,omitempty"` // Optional images from clipboard or upload
+	Images  []ImageData `json:"images,omitempty"`  // Optional images from clipboard or upload
+	Confirm bool        `json:"confirm,omitempty"` // Send even when estimated to cost more than RCODE_CONFIRM_COST
+}
+
+// MessageReply is the reply to a message. The model's reply was already streamed to the
+// session's clients as it was written; a slash command's reply is only sent here.
+type MessageReply struct {
+	Role       string                   `json:"role"`
+	Content    string                   `json:"content"`
+	Streamed   bool                     `json:"streamed"`
+	Command    string                   `json:"command,omitempty"` // The slash command that answered
+	Model      string                   `json:"model,omitempty"`
+	Usage      *providers.Usage         `json:"usage,omitempty"`
+	RateLimits *providers.RateLimitInfo `json:"rateLimits,omitempty"`
+	Error      string                   `json:"error,omitempty"`
+	QueueID    string                   `json:"queueId,omitempty"` // Set, with status 202, when the message waits for the session's turn in progress
+	Ahead      int                      `json:"ahead,omitempty"`   // Turns running or waiting before a queued message
 }
 
 // ImageData represents image data in a message
@@ -227,6 +298,17 @@ func listSessionsHandler(c rweb.Context) error {
 		return c.WriteError(serr.Wrap(err, "failed to list sessions"), 500)
 	}
 
+	// In multi-user mode, list only the user's own sessions
+	if currentUser(c) != nil {
+		visible := make([]*db.Session, 0, len(sessions))
+		for _, session := range sessions {
+			if canAccessSession(c, session) {
+				visible = append(visible, session)
+			}
+		}
+		sessions = visible
+	}
+
 	return c.WriteJSON(sessions)
 }
 
@@ -241,12 +323,13 @@ func createSessionHandler(c rweb.Context) error {
 		}
 	}
 
+	req.UserID = currentUserID(c)
 	session, err := createSession(&req)
 	if err != nil {
 		return c.WriteError(err, 500)
 	}
 
-	logger.F("Created new session: %s", session.ID)
+	newSessionLog(requestContext(c), session.ID).Info("Created new session")
 
 	// Broadcast session list update
 	BroadcastSessionList()
@@ -268,6 +351,7 @@ func deleteSessionHandler(c rweb.Context) error {
 	if err != nil {
 		return c.WriteError(serr.Wrap(err, "failed to delete session"), 500)
 	}
+	forgetSessionClaudeMD(sessionID)
 
 	// Broadcast session list update
 	BroadcastSessionList()
@@ -275,9 +359,62 @@ func deleteSessionHandler(c rweb.Context) error {
 	return c.WriteJSON(map[string]bool{"success": true})
 }
 
+// ForkSessionRequest represents a request to fork a session
+type ForkSessionRequest struct {
+	MessageIndex *int   `json:"message_index,omitempty"` // Last message (0-based) to copy; all when omitted
+	Title        string `json:"title,omitempty"`
+}
+
+// forkSessionHandler copies a session's messages up to a chosen index into a new session
+func forkSessionHandler(c rweb.Context) error {
+	sessionID := c.Request().Param("id")
+
+	var req ForkSessionRequest
+	body := c.Request().Body()
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &req); err != nil {
+			return c.WriteError(serr.Wrap(err, "invalid request body"), 400)
+		}
+	}
+
+	uptoIndex := -1
+	if req.MessageIndex != nil {
+		if *req.MessageIndex < 0 {
+			return c.WriteError(serr.New("message_index must be non-negative"), 400)
+		}
+		uptoIndex = *req.MessageIndex
+	}
+
+	// Get database instance
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	session, err := database.ForkSession(sessionID, uptoIndex, req.Title)
+	if err != nil {
+		errStr := err.Error()
+		if strings.Contains(errStr, "session not found") {
+			return c.WriteError(err, 404)
+		}
+		if strings.Contains(errStr, "out of range") {
+			return c.WriteError(err, 400)
+		}
+		return c.WriteError(serr.Wrap(err, "failed to fork session"), 500)
+	}
+
+	newSessionLog(requestContext(c), session.ID).Info("Forked session", "source_session_id", sessionID)
+
+	// Broadcast session list update
+	BroadcastSessionList()
+
+	return c.WriteJSON(session)
+}
+
 func sendMessageHandler(c rweb.Context) error {
 	sessionID := c.Request().Param("id")
-	logger.Info("Sending message to session: " + sessionID)
+	log := newSessionLog(requestContext(c), sessionID)
+	log.Info("Sending message to session")
 
 	// Get database instance
 	database, err := db.GetDB()
@@ -291,7 +428,7 @@ func sendMessageHandler(c rweb.Context) error {
 		return c.WriteError(serr.Wrap(err, "failed to get session"), 500)
 	}
 	if session == nil {
-		logger.Info("Session not found for message: " + sessionID)
+		log.Info("Session not found for message")
 		return c.WriteError(serr.New("session not found"), 404)
 	}
 
@@ -302,6 +439,67 @@ func sendMessageHandler(c rweb.Context) error {
 		return c.WriteError(serr.Wrap(err, "invalid request body"), 400)
 	}
 
+	// Slash commands are answered here, unless they expand into a prompt for the model
+	if cmd, rawArgs, ok := SlashCommands().Parse(msgReq.Content); ok {
+		result := runSlashCommand(database, session, cmd, rawArgs)
+		if result.Prompt == "" {
+			return writeCommandResult(c, cmd, result)
+		}
+		msgReq.Content = result.Prompt
+	}
+
+	job := newMessageTurn(requestContext(c), database, session, msgReq)
+	if ahead := queueTurn(session.ID, job); ahead > 0 {
+		// The message is sent when the session's turn in progress finishes; its reply streams to the session's pages
+		go awaitQueuedTurn(session.ID, job)
+		c.Response().SetStatus(202)
+		return c.WriteJSON(MessageReply{Role: "user", QueueID: job.ID, Ahead: ahead})
+	}
+
+	<-job.done
+	reply, err := job.reply, job.err
+	if err != nil {
+		var confirm *CostConfirmError
+		if errors.As(err, &confirm) {
+			c.Response().SetStatus(409)
+			return c.WriteJSON(map[string]interface{}{
+				"error":    err.Error(),
+				"estimate": confirm.Estimate,
+			})
+		}
+		return c.WriteError(err, 500)
+	}
+	return c.WriteJSON(reply)
+}
+
+// sendMessage adds a user message to a session and runs the conversation until the model
+// replies with text, executing the tools it asks for along the way. Progress is broadcast
+// to the session's clients as it happens. It waits for the session's earlier turns to finish.
+func sendMessage(ctx context.Context, database *db.DB, session *Session, msgReq MessageRequest) (*MessageReply, error) {
+	job := newMessageTurn(ctx, database, session, msgReq)
+	queueTurn(session.ID, job)
+	<-job.done
+	return job.reply, job.err
+}
+
+// newMessageTurn returns the turn that answers a message, for its session's worker.
+// The turn is traced under ctx's span.
+func newMessageTurn(ctx context.Context, database *db.DB, session *Session, msgReq MessageRequest) *turnJob {
+	return newTurnJob(msgReq.Content, func() (*MessageReply, error) {
+		ctx, turn, endTurn := traceTurn(ctx, session.ID)
+		defer endTurn()
+
+		reply, err := runTurn(ctx, turn, database, session, msgReq)
+		turn.SetError(err)
+		return reply, err
+	})
+}
+
+// runTurn does sendMessage's work, adding the model to the turn's span once it is chosen
+func runTurn(ctx context.Context, turn *tracing.Span, database *db.DB, session *Session, msgReq MessageRequest) (*MessageReply, error) {
+	sessionID := session.ID
+	log := newSessionLog(ctx, sessionID)
+
 	// Create user message with optional images
 	var userMsg providers.ChatMessage
 	if len(msgReq.Images) > 0 {
@@ -323,32 +521,28 @@ func sendMessageHandler(c rweb.Context) error {
 			Content: msgReq.Content,
 		}
 	}
-	err = database.AddMessage(sessionID, userMsg, "", nil)
-	if err != nil {
-		return c.WriteError(serr.Wrap(err, "failed to add user message"), 500)
+
+	// Attach the current content of files mentioned with @path
+	if mentions := resolveFileMentions(msgReq.Content, projectRoot()); len(mentions) > 0 {
+		if userMsg.Metadata == nil {
+			userMsg.Metadata = make(map[string]interface{})
+		}
+		userMsg.Metadata["fileMentions"] = mentions
+		log.Info("Resolved file mentions", "count", len(mentions))
 	}
 
-	// Check if this is the first user message (after initial prompt)
-	// and update session title if needed
-	messageCount, err := database.GetMessageCount(sessionID)
+	_, dbSpan := tracing.Start(ctx, "db.add_message")
+	userMsgID, err := database.AddMessageWithID(sessionID, userMsg, "", nil)
+	dbSpan.SetError(err)
+	dbSpan.End()
 	if err != nil {
-		logger.LogErr(err, "failed to get message count")
-	} else if messageCount == 2 && session.Title == "New Chat" {
-		// This is the first real user message, generate a title
-		newTitle := generateSessionTitle(msgReq.Content)
-		if err := database.UpdateSession(sessionID, newTitle, session.Metadata); err != nil {
-			logger.LogErr(err, "failed to update session title")
-		} else {
-			logger.Info("Updated session title", "session_id", sessionID, "title", newTitle)
-			// Broadcast session list update so UI refreshes
-			BroadcastSessionList()
-		}
+		return nil, serr.Wrap(err, "failed to add user message")
 	}
 
 	// Get all messages for context (including compacted summaries)
 	messages, err := database.GetMessagesWithCompaction(sessionID)
 	if err != nil {
-		return c.WriteError(serr.Wrap(err, "failed to get messages"), 500)
+		return nil, serr.Wrap(err, "failed to get messages")
 	}
 
 	// Create Anthropic client
@@ -358,42 +552,31 @@ func sendMessageHandler(c rweb.Context) error {
 	if !client.GetContextManager().IsInitialized() {
 		workDir, err := os.Getwd()
 		if err != nil {
-			logger.LogErr(err, "failed to get working directory")
+			log.Err(err, "failed to get working directory")
 			workDir = "."
 		}
 		if err := client.InitializeContext(workDir); err != nil {
-			logger.LogErr(err, "failed to initialize context")
+			log.Err(err, "failed to initialize context")
 		}
 	}
 
-	// Create tool registry with custom tools support
-	workDir, err := os.Getwd()
-	if err != nil {
-		logger.LogErr(err, "failed to get working directory for tools")
-		workDir = "."
-	}
-	toolRegistry, err := tools.DefaultRegistryWithPlugins(workDir)
-	if err != nil {
-		logger.LogErr(err, "failed to create tool registry with plugins")
-		// Fall back to default registry
-		toolRegistry = tools.DefaultRegistry()
-	}
-
-	// Create context-aware tool executor
-	contextExecutor := tools.NewContextAwareExecutor(toolRegistry, client.GetContextManager())
+	// Create the session's tools, run with context tracking and permission checks
+	toolRegistry, permissionExecutor := newSessionTools(database, client)
 
-	// Wrap with permission-aware executor
-	permissionExecutor := NewPermissionAwareExecutor(contextExecutor, database)
-	// Set up ask handler for tools that require confirmation
-	permissionExecutor.SetAskHandler(HandleAskPermission)
+	// Build errors from the tools' edits are fed back to the model with the tool results
+	diagnostics := newDiagnosticsCollector()
 
-	// Use the model from the request, or default to Claude Sonnet 4
+	// Use the model from the request, then the session's choice, then the default
 	model := msgReq.Model
 	if model == "" {
-		model = "claude-sonnet-4-20250514"
+		model = session.ModelPreference
+	}
+	if model == "" {
+		model = defaultModel
 	}
 
-	logger.Info("Requesting model", "model", model)
+	log.Info("Requesting model", "model", model)
+	turn.SetAttributes(tracing.String("model", model))
 
 	// Get available tools
 	allTools := toolRegistry.GetTools()
@@ -407,21 +590,51 @@ func sendMessageHandler(c rweb.Context) error {
 		}
 	}
 
-	// System prompt cannot be changed!
-	const systemPrompt = "You are Claude Code, Anthropic's official CLI for Claude."
-
-	// Prepare request with tools
+	// Prepare request with tools, then the messages that fit in the model's context window
 	request := providers.CreateMessageRequest{
 		Model:     model,
-		Messages:  providers.ConvertToAPIMessages(messages),
 		MaxTokens: 4096,
 		Stream:    false,
-		System:    systemPrompt,
+		System:    sessionSystemPrompt(database, sessionID, msgReq.Content, client.GetContextManager()),
 		Tools:     availableTools,
+		SessionID: sessionID,
+		Account:   sessionAccount(session),
+	}
+	request.Messages = providers.ConvertToAPIMessages(messages, request)
+
+	// Estimate what the request will cost, and leave it unsent for the user to confirm when
+	// that is more than they set
+	if estimate := estimateCost(ctx, client, request, log); estimate != nil {
+		BroadcastCostEstimate(sessionID, *estimate)
+		if estimate.Threshold > 0 && estimate.Cost > estimate.Threshold && !msgReq.Confirm {
+			log.Info("Message awaits confirmation of its cost", "cost", estimate.Cost, "input_tokens", estimate.InputTokens)
+			if err := database.DeleteMessage(*userMsgID); err != nil {
+				log.Err(err, "failed to remove unconfirmed message")
+			}
+			return nil, &CostConfirmError{Estimate: *estimate}
+		}
+	}
+
+	// Check if this is the first user message (after initial prompt)
+	// and update session title if needed
+	messageCount, err := database.GetMessageCount(sessionID)
+	if err != nil {
+		log.Err(err, "failed to get message count")
+	} else if messageCount == 2 && session.Title == "New Chat" {
+		// This is the first real user message, generate a title
+		newTitle := generateSessionTitle(msgReq.Content)
+		if err := database.UpdateSession(sessionID, newTitle, session.Metadata); err != nil {
+			log.Err(err, "failed to update session title")
+		} else {
+			log.Info("Updated session title", "title", newTitle)
+			// Broadcast session list update so UI refreshes
+			BroadcastSessionList()
+		}
 	}
 
 	// Variables that persist across iterations
 	var streamingStarted bool
+	loopGuard := newToolLoopGuard()
 
 	// Keep trying until we get a final response (not a tool use)
 	for {
@@ -435,6 +648,7 @@ func sendMessageHandler(c rweb.Context) error {
 		var assistantModel string
 		var usage *providers.Usage
 		var rateLimits *providers.RateLimitInfo
+		partial := newPartialReply(database, sessionID, log)
 
 		// Only broadcast message start on first iteration
 		if !streamingStarted {
@@ -443,32 +657,30 @@ func sendMessageHandler(c rweb.Context) error {
 		}
 
 		// Handle streaming response
-		rateLimits, err = client.StreamMessageWithRetry(request, func(event providers.StreamEvent) error {
+		onEvent := func(event providers.StreamEvent) error {
 			// logger.Info("Stream event received", "type", event.Type, "hasMessage", len(event.Message) > 0, "hasDelta", len(event.Delta) > 0, "index", event.Index)
 
 			// For content_block_start, try to log the raw event
 			if event.Type == "content_block_start" {
 				eventJSON, _ := json.Marshal(event)
-				logger.Info("Full content_block_start event", "raw", string(eventJSON))
+				log.Debug("Full content_block_start event", "raw", string(eventJSON))
 			}
 
 			switch event.Type {
 			case "message_start":
-				// Parse message start to get model info
+				// Parse message start to get the model that serves the message
 				var msgStart struct {
-					Message struct {
-						Model string           `json:"model"`
-						Usage *providers.Usage `json:"usage"`
-					} `json:"message"`
+					Model string           `json:"model"`
+					Usage *providers.Usage `json:"usage"`
 				}
 				if err := json.Unmarshal(event.Message, &msgStart); err == nil {
-					assistantModel = msgStart.Message.Model
-					usage = msgStart.Message.Usage
+					assistantModel = msgStart.Model
+					usage = msgStart.Usage
 				}
 
 			case "content_block_start":
 				// Log raw message for debugging
-				logger.Info("Raw content_block_start", "message", string(event.Message))
+				log.Debug("Raw content_block_start", "message", string(event.Message))
 
 				// Parse the content block from the message
 				var contentBlock struct {
@@ -478,9 +690,9 @@ func sendMessageHandler(c rweb.Context) error {
 				}
 
 				if err := json.Unmarshal(event.Message, &contentBlock); err != nil {
-					logger.LogErr(err, "Failed to parse content block", "message", string(event.Message))
+					log.Err(err, "Failed to parse content block", "message", string(event.Message))
 				} else {
-					logger.Info("Content block start", "type", contentBlock.Type, "name", contentBlock.Name, "id", contentBlock.ID)
+					log.Debug("Content block start", "type", contentBlock.Type, "tool", contentBlock.Name, "tool_use_id", contentBlock.ID)
 
 					// On the FIRST content block of ANY iteration, remove thinking indicator
 					// Check if this is the first content block for a text response
@@ -498,7 +710,7 @@ func sendMessageHandler(c rweb.Context) error {
 							"input":      make(map[string]interface{}),
 							"input_json": "", // Initialize for accumulation
 						})
-						logger.Info("Tool use started", "name", contentBlock.Name, "id", contentBlock.ID)
+						log.Info("Tool use started", "tool", contentBlock.Name, "tool_use_id", contentBlock.ID)
 					}
 				}
 
@@ -513,13 +725,14 @@ func sendMessageHandler(c rweb.Context) error {
 					Input string `json:"partial_json"`
 				}
 				if err := json.Unmarshal(event.Delta, &delta); err != nil {
-					logger.LogErr(err, "Failed to parse content delta", "raw", string(event.Delta))
+					log.Err(err, "Failed to parse content delta", "raw", string(event.Delta))
 				} else {
 					// logger.Info("Content delta parsed", "type", delta.Type, "text", delta.Text)
 					if delta.Type == "text_delta" {
 						// Accumulate text and broadcast delta
 						streamingContent += delta.Text
 						BroadcastMessageDelta(sessionID, delta.Text)
+						partial.save(streamingContent, currentToolUses, assistantModel, false)
 					} else if delta.Type == "input_json_delta" {
 						if len(currentToolUses) > 0 {
 							// Accumulate tool input JSON
@@ -531,7 +744,7 @@ func sendMessageHandler(c rweb.Context) error {
 								}
 							}
 						} else {
-							logger.Warn("Received input_json_delta but no tool use initialized")
+							log.Warn("Received input_json_delta but no tool use initialized")
 						}
 					}
 				}
@@ -544,19 +757,19 @@ func sendMessageHandler(c rweb.Context) error {
 							// Parse the accumulated JSON
 							var input map[string]interface{}
 							if err := json.Unmarshal([]byte(inputJSON), &input); err != nil {
-								logger.LogErr(err, "Failed to parse tool input JSON", "json", inputJSON)
+								log.Err(err, "Failed to parse tool input JSON", "json", inputJSON)
 								// Mark tool as having invalid input
 								toolUse["input"] = nil
 								toolUse["parse_error"] = err.Error()
 							} else {
 								toolUse["input"] = input
-								logger.Info("Tool input parsed", "toolName", toolUse["name"], "input", input)
+								log.Debug("Tool input parsed", "tool", toolUse["name"], "input", input)
 							}
 							delete(toolUse, "input_json")
 						} else {
 							// No input_json accumulated - this shouldn't happen in normal flow
 							// Mark as having no input rather than empty map
-							logger.Warn("Tool use completed with no input JSON", "toolName", toolUse["name"])
+							log.Warn("Tool use completed with no input JSON", "tool", toolUse["name"])
 							toolUse["input"] = nil
 						}
 					}
@@ -577,19 +790,49 @@ func sendMessageHandler(c rweb.Context) error {
 				// Message streaming complete
 				streamComplete = true
 				BroadcastMessageStop(sessionID)
+				// Keep the tool calls while they run, in case the server stops before they finish
+				partial.save(streamingContent, currentToolUses, assistantModel, true)
 			}
 
 			return nil
-		})
+		}
+
+		// Try the model, then the ones it falls back to while the API can't serve it
+		request.Model = model
+		fallbacks := fallbackModels(model)
+		for {
+			request.Retries = 0
+			if len(fallbacks) > 0 {
+				request.Retries = fallbackRetries
+			}
+			_, streamSpan := tracing.Start(ctx, "anthropic.stream",
+				tracing.String("model", request.Model), tracing.Int("retries", request.Retries))
+			rateLimits, err = client.StreamMessageWithRetry(request, onEvent)
+			if usage != nil {
+				streamSpan.SetAttributes(tracing.Int("tokens.input", usage.InputTokens), tracing.Int("tokens.output", usage.OutputTokens))
+			}
+			streamSpan.SetError(err)
+			streamSpan.End()
+			if err == nil || len(fallbacks) == 0 || !shouldFallBack(err) || streamingContent != "" || len(currentToolUses) > 0 {
+				break
+			}
+			log.Warn("Model unavailable, falling back", "model", request.Model, "fallback", fallbacks[0], "error", err.Error())
+			BroadcastModelFallback(sessionID, request.Model, fallbacks[0], err)
+			request.Model, fallbacks = fallbacks[0], fallbacks[1:]
+		}
 
 		if err != nil {
-			logger.LogErr(err, "failed to stream message from Claude")
-			return c.WriteError(err, 500)
+			log.Err(err, "failed to stream message from Claude")
+			partial.interrupt()
+			return nil, err
+		}
+		if assistantModel == "" {
+			assistantModel = request.Model
 		}
 
 		// Process the accumulated response
 		if streamComplete {
-			logger.Info("Stream complete", "contentLength", len(streamingContent), "toolUses", len(currentToolUses))
+			log.Info("Stream complete", "content_length", len(streamingContent), "tool_uses", len(currentToolUses))
 			// Check if we have tool uses
 			if len(currentToolUses) > 0 {
 				// Broadcast that tool use is starting (removes thinking indicator)
@@ -598,10 +841,15 @@ func sendMessageHandler(c rweb.Context) error {
 					streamingStarted = true
 				}
 
-				// Process tool uses (similar to existing logic)
+				// Process tool uses: read each call's input, then run the calls in batches,
+				// where runs of read-only calls run at once (see toolBatches)
 				var toolResults []interface{}
+				var touchedPaths []string // Files the tools that succeeded worked on
+				var touchedMu sync.Mutex
+				results := make([]interface{}, len(currentToolUses))
+				calls := make([]*tools.ToolUse, len(currentToolUses))
 
-				for _, toolUseData := range currentToolUses {
+				for i, toolUseData := range currentToolUses {
 					toolUseMap := toolUseData.(map[string]interface{})
 
 					// Check if tool has valid input before attempting execution
@@ -622,7 +870,7 @@ func sendMessageHandler(c rweb.Context) error {
 						if errMsg, ok := toolUseMap["parse_error"].(string); ok {
 							parseError = errMsg
 						}
-						logger.Error("Skipping tool execution due to invalid input",
+						log.Error("Skipping tool execution due to invalid input",
 							"tool", toolName, "error", parseError)
 
 						// Broadcast tool execution failure
@@ -634,11 +882,11 @@ func sendMessageHandler(c rweb.Context) error {
 						BroadcastToolExecutionComplete(sessionID, toolName, toolID, "failed", summary, 0, metrics)
 
 						// Add error result
-						toolResults = append(toolResults, tools.ToolResult{
+						results[i] = tools.ToolResult{
 							Type:      "tool_result",
 							ToolUseID: toolID,
 							Content:   fmt.Sprintf("Tool execution failed: %s", parseError),
-						})
+						}
 						continue
 					}
 
@@ -655,7 +903,7 @@ func sendMessageHandler(c rweb.Context) error {
 							toolID = id
 						}
 
-						logger.Error("Tool input is not a map", "tool", toolName, "inputType", fmt.Sprintf("%T", inputRaw))
+						log.Error("Tool input is not a map", "tool", toolName, "input_type", fmt.Sprintf("%T", inputRaw))
 
 						// Broadcast tool execution failure
 						BroadcastToolExecutionStart(sessionID, toolID, toolName, nil)
@@ -666,11 +914,11 @@ func sendMessageHandler(c rweb.Context) error {
 						BroadcastToolExecutionComplete(sessionID, toolName, toolID, "failed", summary, 0, metrics)
 
 						// Add error result
-						toolResults = append(toolResults, tools.ToolResult{
+						results[i] = tools.ToolResult{
 							Type:      "tool_result",
 							ToolUseID: toolID,
 							Content:   "Tool execution failed: Invalid input format",
-						})
+						}
 						continue
 					}
 
@@ -680,8 +928,14 @@ func sendMessageHandler(c rweb.Context) error {
 						Name:  toolUseMap["name"].(string),
 						Input: inputMap,
 					}
+					calls[i] = &toolUse
+				}
+				stopReason := loopGuard.next(calls)
 
-					logger.Info("Executing tool", "name", toolUse.Name)
+				runTool := func(i int) {
+					toolUse := *calls[i]
+
+					log.Info("Executing tool", "tool", toolUse.Name, "tool_use_id", toolUse.ID)
 
 					// Add session ID to tool input for diff tracking
 					toolUse.Input["_sessionId"] = sessionID
@@ -692,9 +946,15 @@ func sendMessageHandler(c rweb.Context) error {
 					// Broadcast tool execution start
 					BroadcastToolExecutionStart(sessionID, toolUse.ID, toolUse.Name, toolUse.Input)
 
-					// Execute the tool with permission and context awareness
+					// Execute the tool with permission and context awareness. Its span
+					// includes any wait for the user's permission.
+					_, toolSpan := tracing.Start(ctx, "tool "+toolUse.Name,
+						tracing.String("tool.name", toolUse.Name), tracing.String("tool.id", toolUse.ID))
 					result, err := permissionExecutor.Execute(toolUse)
+					toolSpan.SetError(err)
+					toolSpan.End()
 					durationMs := int(time.Since(startTime).Milliseconds())
+					observeTool(toolUse.Name, time.Since(startTime), err)
 
 					// Prepare execution metrics
 					metrics := map[string]interface{}{
@@ -713,7 +973,7 @@ func sendMessageHandler(c rweb.Context) error {
 
 					// For edit tools, also broadcast the diff separately
 					// TODO validate this block
-					if (toolUse.Name == "edit_file" || toolUse.Name == "smart_edit") && err == nil {
+					if (toolUse.Name == "edit_file" || toolUse.Name == "smart_edit" || toolUse.Name == "replace_snippet") && err == nil {
 						if path, ok := tools.GetString(toolUse.Input, "path"); ok {
 							var diffContent string
 							if toolUse.Name == "edit_file" {
@@ -730,6 +990,8 @@ func sendMessageHandler(c rweb.Context) error {
 								if diffContent == "" {
 									diffContent = generateSmartEditDiff(toolUse.Input, result.Content)
 								}
+							} else if toolUse.Name == "replace_snippet" {
+								diffContent = generateSnippetDiff(toolUse.Input)
 							}
 
 							if diffContent != "" {
@@ -739,23 +1001,52 @@ func sendMessageHandler(c rweb.Context) error {
 						}
 					}
 
+					// A patch is shown as one diff covering all of its files
+					if toolUse.Name == "apply_patch" && err == nil {
+						if patch, ok := tools.GetString(toolUse.Input, "patch"); ok {
+							BroadcastFileDiff(sessionID, strings.Join(tools.PatchPaths(patch), ", "), toolUse.Name, patch)
+						}
+					}
+
+					// A long result goes to the model truncated, with the whole of it kept as an artifact
+					output := result.Content
+					artifactID := truncateToolResult(database, sessionID, toolUse, result)
+					if artifactID > 0 {
+						metrics["artifactId"] = artifactID
+					}
+
 					// Broadcast tool execution complete
 					BroadcastToolExecutionComplete(sessionID, toolUse.Name, toolUse.ID, status, summary, int64(durationMs), metrics)
 
-					// TODO: Log tool usage to database (separate from token usage tracking)
-					// if logErr := database.LogToolUsage(sessionID, toolUse.Name, toolUse.Input, result.Content, durationMs, err); logErr != nil {
-					// 	logger.LogErr(logErr, "failed to log tool usage")
-					// }
+					// Record the call for auditing (separate from token usage tracking)
+					_, dbSpan := tracing.Start(ctx, "db.log_tool_usage")
+					logErr := database.LogToolUsage(sessionID, toolUse.Name, toolUse.Input, output, durationMs, err)
+					dbSpan.SetError(logErr)
+					dbSpan.End()
+					if logErr != nil {
+						log.Err(logErr, "failed to log tool usage", "tool", toolUse.Name)
+					}
 
 					if err != nil {
-						logger.LogErr(err, "tool execution failed")
+						log.Err(err, "tool execution failed", "tool", toolUse.Name)
+					} else {
+						touchedMu.Lock()
+						touchedPaths = append(touchedPaths, toolPaths(toolUse)...)
+						touchedMu.Unlock()
+						if diagnostics != nil {
+							diagnostics.TrackToolUse(toolUse)
+						}
 					}
-					logger.Info("Broadcasting tool usage", "tool", toolUse.Name, "summary", summary)
-					BroadcastToolUsage(sessionID, toolUse.Name, summary)
+					log.Debug("Broadcasting tool usage", "tool", toolUse.Name, "summary", summary)
+					BroadcastToolUsage(sessionID, toolUse.Name, summary, artifactID)
 
 					// Add tool result to results
-					toolResults = append(toolResults, result)
+					results[i] = result
+				}
+				for _, batch := range toolBatches(calls) {
+					runToolBatch(batch, config.Get().ParallelTools, runTool)
 				}
+				toolResults = append(toolResults, results...)
 
 				// Clean up tool uses before saving - remove input_json field
 				// that was used for streaming accumulation but should not be saved
@@ -777,18 +1068,41 @@ func sendMessageHandler(c rweb.Context) error {
 					Role:    "assistant",
 					Content: cleanedToolUses,
 				}
-				msgID, err := database.AddMessageWithID(sessionID, assistantMsg, assistantModel, usage)
+				_, dbSpan := tracing.Start(ctx, "db.add_message")
+				msgID, err := partial.finish(assistantMsg, assistantModel, usage)
+				dbSpan.SetError(err)
+				dbSpan.End()
 				if err != nil {
-					logger.LogErr(err, "failed to add assistant message with tool use")
+					log.Err(err, "failed to add assistant message with tool use")
 				}
 
 				// Record usage with rate limits
 				if usage != nil || rateLimits != nil {
-					if recordErr := database.RecordUsage(sessionID, msgID, assistantModel, usage, rateLimits); recordErr != nil {
-						logger.LogErr(recordErr, "failed to record usage")
+					_, dbSpan := tracing.Start(ctx, "db.record_usage")
+					recordErr := database.RecordUsage(sessionID, msgID, assistantModel, model, usage, rateLimits)
+					dbSpan.SetError(recordErr)
+					dbSpan.End()
+					if recordErr != nil {
+						log.Err(recordErr, "failed to record usage")
 					}
+					countTokens(assistantModel, "session", usage)
 					// Broadcast usage update
-					BroadcastUsageUpdate(sessionID, usage, rateLimits)
+					BroadcastUsageUpdate(sessionID, assistantModel, usage, rateLimits)
+					checkDailyBudget(database)
+				}
+
+				// Add the instructions of the subdirectories the tools worked in, the first time
+				if instructions := subdirectoryClaudeMD(sessionID, touchedPaths); instructions != "" {
+					toolResults = append(toolResults, providers.TextContent{Type: "text",
+						Text: "Instructions for the directories of the files just used:\n\n" + strings.TrimSpace(instructions)})
+				}
+
+				// Add what the project's checks found since the model last heard from them
+				if diagnostics != nil {
+					if report := diagnostics.Collect(config.Get().DiagnosticsWait); report != "" {
+						toolResults = append(toolResults, providers.TextContent{Type: "text", Text: report})
+						BroadcastToolUsage(sessionID, "diagnostics", diagnosticsSummary(report), 0)
+					}
 				}
 
 				// Add tool results as user message
@@ -796,19 +1110,31 @@ func sendMessageHandler(c rweb.Context) error {
 					Role:    "user",
 					Content: toolResults,
 				}
+				_, dbSpan = tracing.Start(ctx, "db.add_message")
 				err = database.AddMessage(sessionID, toolResultMsg, "", nil)
+				dbSpan.SetError(err)
+				dbSpan.End()
 				if err != nil {
-					logger.LogErr(err, "failed to add tool result message")
+					log.Err(err, "failed to add tool result message")
+				}
+
+				// End the turn, after its calls have their results, if the model is looping or
+				// went over the auto-accept limits
+				if reason := permissionExecutor.autoAcceptStopped(); reason != "" {
+					stopReason = reason
+				}
+				if stopReason != "" {
+					return stopToolLoop(database, sessionID, stopReason, assistantModel, log), nil
 				}
 
 				// Get updated messages and continue with new request
 				messages, err = database.GetMessagesWithCompaction(sessionID)
 				if err != nil {
-					return c.WriteError(serr.Wrap(err, "failed to get updated messages"), 500)
+					return nil, serr.Wrap(err, "failed to get updated messages")
 				}
 
 				// Update request with new messages and make another call
-				request.Messages = providers.ConvertToAPIMessages(messages)
+				request.Messages = providers.ConvertToAPIMessages(messages, request)
 				// Reset for next iteration
 				streamingContent = ""
 				currentToolUses = nil
@@ -822,50 +1148,144 @@ func sendMessageHandler(c rweb.Context) error {
 					Role:    "assistant",
 					Content: streamingContent,
 				}
-				msgID, err := database.AddMessageWithID(sessionID, assistantMsg, assistantModel, usage)
+				_, dbSpan := tracing.Start(ctx, "db.add_message")
+				msgID, err := partial.finish(assistantMsg, assistantModel, usage)
+				dbSpan.SetError(err)
+				dbSpan.End()
 				if err != nil {
-					logger.LogErr(err, "failed to add assistant message")
+					log.Err(err, "failed to add assistant message")
 				}
 
 				// Record usage with rate limits
 				if usage != nil || rateLimits != nil {
-					if recordErr := database.RecordUsage(sessionID, msgID, assistantModel, usage, rateLimits); recordErr != nil {
-						logger.LogErr(recordErr, "failed to record usage")
+					_, dbSpan := tracing.Start(ctx, "db.record_usage")
+					recordErr := database.RecordUsage(sessionID, msgID, assistantModel, model, usage, rateLimits)
+					dbSpan.SetError(recordErr)
+					dbSpan.End()
+					if recordErr != nil {
+						log.Err(recordErr, "failed to record usage")
 					}
+					countTokens(assistantModel, "session", usage)
 					// Broadcast usage update
-					BroadcastUsageUpdate(sessionID, usage, rateLimits)
+					BroadcastUsageUpdate(sessionID, assistantModel, usage, rateLimits)
+					checkDailyBudget(database)
 				}
 
 				// Message already streamed via deltas - no need to broadcast complete message
 
 				// Return response metadata (content already streamed via deltas)
-				return c.WriteJSON(map[string]interface{}{
-					"role":       "assistant",
-					"streamed":   true,
-					"usage":      usage,
-					"model":      assistantModel,
-					"rateLimits": rateLimits,
-				})
+				return &MessageReply{
+					Role:       "assistant",
+					Content:    streamingContent,
+					Streamed:   true,
+					Usage:      usage,
+					Model:      assistantModel,
+					RateLimits: rateLimits,
+				}, nil
 			} else {
 				// No tool use and no text content - this shouldn't happen
-				logger.Error("Stream completed with no content or tool uses")
+				log.Error("Stream completed with no content or tool uses")
 				// Continue the loop to see if more content comes
 				continue
 			}
 		}
 
 		// If we reach here with no content and no tools, there was an issue
-		logger.Error("Unexpected: exited streaming loop without processing response")
+		log.Error("Unexpected: exited streaming loop without processing response")
 		break
 	}
 
 	// Should not reach here
-	logger.Error("Reached end of sendMessageHandler without proper response")
-	return c.WriteJSON(map[string]interface{}{
-		"role":    "assistant",
-		"content": "",
-		"error":   "No response received from streaming",
-	})
+	log.Error("Reached end of sendMessage without proper response")
+	return &MessageReply{
+		Role:  "assistant",
+		Error: "No response received from streaming",
+	}, nil
+}
+
+// askPermission asks the user to approve a tool call. Headless runs, which have no
+// browser to ask, answer for the user instead.
+var askPermission = HandleAskPermission
+
+// newSessionTools builds the tool registry for a session, with custom tools when enabled,
+// and the executor that runs them with context tracking and permission checks
+func newSessionTools(database *db.DB, client *providers.AnthropicClient) (*tools.Registry, *PermissionAwareExecutor) {
+	workDir, err := os.Getwd()
+	if err != nil {
+		logger.LogErr(err, "failed to get working directory for tools")
+		workDir = "."
+	}
+	toolRegistry, err := tools.DefaultRegistryWithPlugins(workDir)
+	if err != nil {
+		logger.LogErr(err, "failed to create tool registry with plugins")
+		// Fall back to default registry
+		toolRegistry = tools.DefaultRegistry()
+	}
+	tools.RegisterMemoryTools(toolRegistry, newMemoryStore(database))
+	registerSemanticSearchTool(toolRegistry)
+	tools.RegisterDependencyGraphTool(toolRegistry, client.GetContextManager())
+	tools.RegisterSBOMTool(toolRegistry, client.GetContextManager())
+	registerHTTPTools(toolRegistry, workDir)
+	registerDatabaseTool(toolRegistry, workDir)
+	registerGenerateTool(toolRegistry, workDir)
+	enableSemanticScoring(client.GetContextManager())
+
+	// Limits are the built-in ones, then the user's, then the project's .rcode/tool_limits.json
+	limits, err := tools.LoadToolLimits(config.Get().ToolLimitsConfig, filepath.Join(workDir, ".rcode", "tool_limits.json"))
+	if err != nil {
+		logger.LogErr(err, "failed to load tool limits, using the built-in ones")
+	} else {
+		toolRegistry.SetLimits(limits)
+	}
+
+	// Create context-aware tool executor
+	contextExecutor := tools.NewContextAwareExecutor(toolRegistry, client.GetContextManager())
+
+	// Hooks come from the user's config, then the project's .rcode/hooks.json
+	hooks, err := tools.LoadHooks(workDir, config.Get().HooksConfig, filepath.Join(workDir, ".rcode", "hooks.json"))
+	if err != nil {
+		logger.LogErr(err, "failed to load tool hooks")
+	} else if hooks.Len() > 0 {
+		contextExecutor.SetHooks(hooks)
+	}
+
+	if cfg := config.Get(); cfg.FormatOnWrite {
+		contextExecutor.SetFormatPipeline(tools.NewFormatPipeline(workDir, cfg.LintOnWrite))
+	}
+
+	// Wrap with permission-aware executor
+	permissionExecutor := NewPermissionAwareExecutor(contextExecutor, database)
+	// Set up ask handler for tools that require confirmation
+	permissionExecutor.SetAskHandler(askPermission)
+
+	return toolRegistry, permissionExecutor
+}
+
+// newDiagnosticsCollector creates the collector for the project's build and lint checks,
+// or returns nil when diagnostics are disabled or there is nothing to check
+func newDiagnosticsCollector() *tools.DiagnosticsCollector {
+	if !config.Get().DiagnosticsEnabled {
+		return nil
+	}
+	workDir := projectRoot()
+	collector, err := tools.NewDiagnosticsCollector(workDir, filepath.Join(workDir, ".rcode", "diagnostics.json"))
+	if err != nil {
+		logger.LogErr(err, "failed to set up diagnostics")
+		return nil
+	}
+	if collector.Len() == 0 {
+		return nil
+	}
+	return collector
+}
+
+// diagnosticsSummary describes a diagnostics report in one line for the tool usage list
+func diagnosticsSummary(report string) string {
+	failed := strings.Count(report, "failed after your changes")
+	if failed == 0 {
+		return "✓ Checks pass"
+	}
+	return fmt.Sprintf("❌ %d check(s) failed; errors sent to the model", failed)
 }
 
 // createToolSummary creates a concise summary of tool usage
@@ -897,6 +1317,75 @@ func createToolSummary(toolName string, input map[string]interface{}, result str
 			return fmt.Sprintf("✓ Edited %s", filepath.Base(path))
 		}
 
+	case "replace_snippet":
+		if path, ok := tools.GetString(input, "path"); ok {
+			// The result names the lines that were replaced
+			if i := strings.LastIndex(result, " at line "); i >= 0 {
+				where, _, _ := strings.Cut(result[i+len(" at "):], " (")
+				return fmt.Sprintf("✓ Edited %s (%s)", filepath.Base(path), where)
+			}
+			return fmt.Sprintf("✓ Edited %s", filepath.Base(path))
+		}
+
+	case "resolve_conflict":
+		if path, ok := tools.GetString(input, "path"); ok {
+			if strings.Contains(result, "no conflicts remain") {
+				return fmt.Sprintf("✓ Resolved conflicts in %s", filepath.Base(path))
+			}
+			return fmt.Sprintf("✓ Resolved some conflicts in %s", filepath.Base(path))
+		}
+
+	case "apply_patch":
+		// The first line of the result counts the files and lines changed
+		summary, _, _ := strings.Cut(result, "\n")
+		return "✓ " + summary
+
+	case "generate":
+		// The first line of the result counts the files and lines generated
+		summary, _, _ := strings.Cut(result, "\n")
+		return "✓ " + summary
+
+	case "rename_symbol", "move_package":
+		// The first line of the result counts the changes and the files they are in
+		summary, _, _ := strings.Cut(result, "\n")
+		return "✓ " + summary
+
+	case "test_coverage", "generate_tests":
+		// The first line of the result gives the coverage, before and after for generate_tests
+		summary, _, _ := strings.Cut(result, "\n")
+		return "✓ " + summary
+
+	case "bench":
+		// The first line says what ran and what it was compared with; the next sums up the timings
+		lines := strings.SplitN(result, "\n", 3)
+		if len(lines) > 1 && lines[1] != "" {
+			return "✓ " + lines[0] + ": " + lines[1]
+		}
+		return "✓ " + lines[0]
+
+	case "outdated_dependencies", "update_dependency", "security_scan", "sbom":
+		// The first line counts the dependencies outdated, updated and failed, vulnerable, or in the SBOM
+		summary, _, _ := strings.Cut(result, "\n")
+		return "✓ " + summary
+
+	case "publish_artifact":
+		// The result names the artifact and its size
+		return "✓ " + result
+
+	case "todo_write":
+		// The result's first line counts the tasks completed
+		summary, _, _ := strings.Cut(result, "\n")
+		return "✓ " + summary
+
+	case "add_note":
+		// The result names the note added
+		return "✓ " + result
+
+	case "read_artifact":
+		if id, ok := tools.GetInt(input, "id"); ok {
+			return fmt.Sprintf("✓ Read artifact %d", id)
+		}
+
 	case "read_file":
 		if path, ok := tools.GetString(input, "path"); ok {
 			// Extract line count from result if available
@@ -916,6 +1405,38 @@ func createToolSummary(toolName string, input map[string]interface{}, result str
 			return fmt.Sprintf("✓ Ran: %s", cmd)
 		}
 
+	case "run_task":
+		// The result's first line names the command, the file defining it and how long it took,
+		// or counts the tasks listed
+		summary, _, _ := strings.Cut(result, "\n")
+		return "✓ " + summary
+
+	case "run_snippet":
+		// The result's first line gives the language and exit code
+		summary, _, _ := strings.Cut(result, "\n")
+		return "✓ " + summary
+
+	case "db_query":
+		// The result's first line counts the rows
+		summary, _, _ := strings.Cut(result, "\n")
+		if name, ok := tools.GetString(input, "database"); ok {
+			return fmt.Sprintf("✓ Queried %s: %s", name, strings.TrimSuffix(summary, ":"))
+		}
+		return "✓ Queried: " + strings.TrimSuffix(summary, ":")
+
+	case "run_background":
+		if cmd, ok := tools.GetString(input, "command"); ok {
+			if len(cmd) > 50 {
+				cmd = cmd[:47] + "..."
+			}
+			return fmt.Sprintf("✓ Started in background: %s", cmd)
+		}
+
+	case "stop_process":
+		if id, ok := tools.GetInt(input, "id"); ok {
+			return fmt.Sprintf("✓ Stopped background process %d", id)
+		}
+
 	case "search":
 		if pattern, ok := tools.GetString(input, "pattern"); ok {
 			// Count matches in result
@@ -1016,8 +1537,8 @@ func createToolSummary(toolName string, input map[string]interface{}, result str
 // Add a handler to get messages for a session
 func getSessionMessagesHandler(c rweb.Context) error {
 	sessionID := c.Request().Param("id")
-
-	logger.Info("Getting messages for session: " + sessionID)
+	log := newSessionLog(requestContext(c), sessionID)
+	log.Debug("Getting messages for session")
 
 	// Get database instance
 	database, err := db.GetDB()
@@ -1028,12 +1549,12 @@ func getSessionMessagesHandler(c rweb.Context) error {
 	// Get messages from database (including compacted summaries)
 	messages, err := database.GetMessagesWithCompaction(sessionID)
 	if err != nil {
-		logger.LogErr(err, "failed to get messages")
+		log.Err(err, "failed to get messages")
 		// Return empty array instead of error to avoid breaking the UI
 		return c.WriteJSON([]providers.ChatMessage{})
 	}
 
-	logger.F("Found session with messages: %d", len(messages))
+	log.Debug("Found session messages", "count", len(messages))
 	return c.WriteJSON(messages)
 }
 
@@ -1242,6 +1763,25 @@ func generateSmartEditDiff(input map[string]interface{}, result string) string {
 	return diffContent.String()
 }
 
+// generateSnippetDiff shows the text replace_snippet removed and what replaced it
+func generateSnippetDiff(input map[string]interface{}) string {
+	path, _ := tools.GetString(input, "path")
+	oldText, _ := tools.GetString(input, "old_text")
+	newText, _ := tools.GetString(input, "new_text")
+
+	var diffContent strings.Builder
+	diffContent.WriteString(fmt.Sprintf("--- %s\n+++ %s\n", path, path))
+	for _, line := range strings.Split(strings.TrimRight(oldText, "\n"), "\n") {
+		diffContent.WriteString("-" + line + "\n")
+	}
+	if newText != "" {
+		for _, line := range strings.Split(strings.TrimRight(newText, "\n"), "\n") {
+			diffContent.WriteString("+" + line + "\n")
+		}
+	}
+	return diffContent.String()
+}
+
 // min returns the minimum of two integers
 func min(a, b int) int {
 	if a < b {
@@ -0,0 +1,69 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"rcode/db"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/rweb"
+	"github.com/rohanthewiz/serr"
+)
+
+// getSettingsHandler returns the user's settings
+func getSettingsHandler(c rweb.Context) error {
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	settings, err := database.GetUserSettings(currentUserID(c))
+	if err != nil {
+		return c.WriteError(err, 500)
+	}
+
+	return c.WriteJSON(settings)
+}
+
+// updateSettingsHandler changes the settings given in the body and leaves the others,
+// so each part of the UI can save just its own. Open pages are told of the change.
+func updateSettingsHandler(c rweb.Context) error {
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	userID := currentUserID(c)
+	settings, err := database.GetUserSettings(userID)
+	if err != nil {
+		return c.WriteError(err, 500)
+	}
+
+	// Decoding over the current settings replaces only the fields present, and merges
+	// nested objects such as editor. permission_defaults, a map, and permission_rules are
+	// replaced when given.
+	permissionDefaults, permissionRules := settings.PermissionDefaults, settings.PermissionRules
+	settings.PermissionDefaults, settings.PermissionRules = nil, nil
+	decoder := json.NewDecoder(bytes.NewReader(c.Request().Body()))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&settings); err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid settings"), 400)
+	}
+	if settings.PermissionDefaults == nil {
+		settings.PermissionDefaults = permissionDefaults
+	}
+	if settings.PermissionRules == nil {
+		settings.PermissionRules = permissionRules
+	}
+
+	settings, err = database.SaveUserSettings(userID, settings)
+	if err != nil {
+		return c.WriteError(err, 400)
+	}
+
+	logger.Info("Updated user settings")
+	sseHub.Broadcast(SSEEvent{Type: "settings_updated", Data: settings, UserID: userID})
+
+	return c.WriteJSON(settings)
+}
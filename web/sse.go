@@ -3,10 +3,13 @@ package web
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"strings"
 	"sync"
 	"time"
 
+	"rcode/db"
+
 	"github.com/rohanthewiz/logger"
 	"github.com/rohanthewiz/rweb"
 )
@@ -18,24 +21,56 @@ type SSEEvent struct {
 	Type      string      `json:"type"`
 	SessionId string      `json:"sessionId,omitempty"`
 	Data      interface{} `json:"data"`
+	UserID    int         `json:"-"` // In multi-user mode, send only to this user's pages
 }
 
 // SSEHub manages SSE connections
 type SSEHub struct {
 	mu      sync.RWMutex
-	clients map[chan any]bool
+	clients map[chan any]*db.User // The signed-in user of each client; nil in single-user mode
 }
 
 // Global SSE hub
 var sseHub = &SSEHub{
-	clients: make(map[chan any]bool),
+	clients: make(map[chan any]*db.User),
 }
 
-// Register adds a new SSE client
-func (h *SSEHub) Register(client chan any) {
+// sessionOwners caches the owner of each session events are sent for, as sessions don't change hands
+var sessionOwners sync.Map
+
+// Register adds a new SSE client for the signed-in user, nil in single-user mode
+func (h *SSEHub) Register(client chan any, user *db.User) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
-	h.clients[client] = true
+	h.clients[client] = user
+}
+
+// eventOwner returns the user an event is for in multi-user mode. Events for a session
+// go to its owner; events that are not scoped go to every client.
+func eventOwner(event SSEEvent) (ownerID int, scoped bool) {
+	if !multiUser {
+		return 0, false
+	}
+	if event.UserID != 0 {
+		return event.UserID, true
+	}
+	if event.SessionId == "" {
+		return 0, false
+	}
+	if owner, ok := sessionOwners.Load(event.SessionId); ok {
+		return owner.(int), true
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return 0, true
+	}
+	// A session that can't be found is left to the admins, like one without an owner
+	owner, err := database.SessionOwner(event.SessionId)
+	if err == nil {
+		sessionOwners.Store(event.SessionId, owner)
+	}
+	return owner, true
 }
 
 // Unregister removes an SSE client
@@ -52,7 +87,7 @@ func (h *SSEHub) Broadcast(event SSEEvent) {
 	defer h.mu.RUnlock()
 
 	if event.Type != "message_delta" {
-		logger.F("Broadcasting SSE event: type=%s, sessionID=%s, nbrOfClients=%d", event.Type, event.SessionId, len(h.clients))
+		logger.Debug("Broadcasting SSE event", "type", event.Type, "session_id", event.SessionId, "clients", len(h.clients))
 	}
 
 	// Prepare the payload
@@ -61,6 +96,10 @@ func (h *SSEHub) Broadcast(event SSEEvent) {
 		"sessionId": event.SessionId,
 		"data":      event.Data,
 	}
+	// Events of a turn in progress carry its trace ID, to look the turn up in the tracing backend
+	if traceID := sessionTraceID(event.SessionId); traceID != "" {
+		data["traceId"] = traceID
+	}
 
 	bytPayload, err := json.Marshal(data)
 	if err != nil {
@@ -73,7 +112,12 @@ func (h *SSEHub) Broadcast(event SSEEvent) {
 		Data: string(bytPayload),
 	}
 
-	for client := range h.clients {
+	ownerID, scoped := eventOwner(event)
+
+	for client, user := range h.clients {
+		if scoped && (user == nil || !user.CanAccess(ownerID)) {
+			continue
+		}
 		select {
 		case client <- rEvent:
 		default:
@@ -108,16 +152,20 @@ func BroadcastSessionList() {
 }
 
 // BroadcastToolUsage broadcasts a tool usage summary event
-func BroadcastToolUsage(sessionID string, toolName string, summary string) {
+func BroadcastToolUsage(sessionID string, toolName string, summary string, artifactID int) {
+	data := map[string]interface{}{
+		"tool":    toolName,
+		"summary": summary,
+	}
+	if artifactID > 0 {
+		data["artifactId"] = artifactID // The full output, when the model was sent part of it
+	}
 	event := SSEEvent{
 		Type:      "tool_usage",
 		SessionId: sessionID,
-		Data: map[string]interface{}{
-			"tool":    toolName,
-			"summary": summary,
-		},
+		Data:      data,
 	}
-	logger.Info("BroadcastToolUsage", "sessionID", sessionID, "tool", toolName, "summary", summary)
+	logger.Debug("BroadcastToolUsage", "session_id", sessionID, "tool", toolName, "summary", summary)
 	sseHub.Broadcast(event)
 }
 
@@ -196,6 +244,68 @@ func BroadcastToolPermissionUpdate(sessionID string, toolName string, enabled bo
 	sseHub.Broadcast(event)
 }
 
+// BroadcastToolsUpdated broadcasts when the custom tool plugins were reloaded, with the
+// plugins' tool names; sessions have the new tools from their next message
+func BroadcastToolsUpdated(toolNames []string) {
+	broadcastJSON("tools_updated", map[string]interface{}{
+		"customTools": toolNames,
+	})
+}
+
+// BroadcastArtifactPublished broadcasts when a tool or plan step publishes an artifact to a session
+func BroadcastArtifactPublished(artifact *db.Artifact) {
+	event := SSEEvent{
+		Type:      "artifact_published",
+		SessionId: artifact.SessionID,
+		Data:      artifact,
+	}
+	sseHub.Broadcast(event)
+}
+
+// BroadcastMessageQueued broadcasts that a message waits for the session's turn in progress
+func BroadcastMessageQueued(sessionID string, message QueuedMessage, ahead int) {
+	event := SSEEvent{
+		Type:      "message_queued",
+		SessionId: sessionID,
+		Data: map[string]interface{}{
+			"queueId": message.ID,
+			"content": message.Content,
+			"ahead":   ahead, // Turns of the session running or waiting before it
+		},
+	}
+	sseHub.Broadcast(event)
+}
+
+// BroadcastMessageDequeued broadcasts that a queued message was sent or cancelled
+func BroadcastMessageDequeued(sessionID, queueID, reason string) {
+	event := SSEEvent{
+		Type:      "message_dequeued",
+		SessionId: sessionID,
+		Data: map[string]interface{}{
+			"queueId": queueID,
+			"reason":  reason,
+		},
+	}
+	sseHub.Broadcast(event)
+}
+
+// BroadcastQueuedMessageFinished broadcasts that the turn of a queued message, whose
+// sender was answered when it was queued, finished, with its error if it failed
+func BroadcastQueuedMessageFinished(sessionID, queueID string, err error) {
+	data := map[string]interface{}{
+		"queueId": queueID,
+	}
+	if err != nil {
+		data["error"] = err.Error()
+	}
+	event := SSEEvent{
+		Type:      "queued_message_finished",
+		SessionId: sessionID,
+		Data:      data,
+	}
+	sseHub.Broadcast(event)
+}
+
 // BroadcastMessageStart broadcasts when a message starts streaming
 func BroadcastMessageStart(sessionID string) {
 	event := SSEEvent{
@@ -353,21 +463,63 @@ func BroadcastFileDiff(sessionID string, filePath string, toolName string, diffC
 			"timestamp": time.Now().Unix(),
 		},
 	}
-	logger.Info("BroadcastFileDiff", "sessionID", sessionID, "filePath", filePath, "toolName", toolName)
+	logger.Debug("BroadcastFileDiff", "session_id", sessionID, "path", filePath, "tool", toolName)
 	sseHub.Broadcast(event)
 }
 
 // BroadcastUsageUpdate broadcasts token usage and rate limit updates
-func BroadcastUsageUpdate(sessionID string, usage interface{}, rateLimits interface{}) {
+func BroadcastUsageUpdate(sessionID, model string, usage interface{}, rateLimits interface{}) {
 	event := SSEEvent{
 		Type:      "usage_update",
 		SessionId: sessionID,
 		Data: map[string]interface{}{
+			"model":      model, // The model that served the message
 			"usage":      usage,
 			"rateLimits": rateLimits,
 			"timestamp":  time.Now().Unix(),
 		},
 	}
-	logger.Info("BroadcastUsageUpdate", "sessionID", sessionID)
+	logger.Debug("BroadcastUsageUpdate", "session_id", sessionID)
+	sseHub.Broadcast(event)
+}
+
+// BroadcastCostEstimate tells a session's pages what its next request is estimated to cost,
+// before it is sent
+func BroadcastCostEstimate(sessionID string, estimate CostEstimate) {
+	event := SSEEvent{
+		Type:      "cost_estimate",
+		SessionId: sessionID,
+		Data:      estimate,
+	}
+	sseHub.Broadcast(event)
+}
+
+// BroadcastModelFallback tells a session's pages that its request is going to another model,
+// because the API couldn't serve the one asked for
+func BroadcastModelFallback(sessionID, model, fallback string, err error) {
+	event := SSEEvent{
+		Type:      "model_fallback",
+		SessionId: sessionID,
+		Data: map[string]interface{}{
+			"model":    model,
+			"fallback": fallback,
+			"error":    err.Error(),
+		},
+	}
+	sseHub.Broadcast(event)
+}
+
+// BroadcastRateLimitWait tells a session's pages that its next model request is waiting
+// for the API's rate limits
+func BroadcastRateLimitWait(sessionID string, wait time.Duration, reason string) {
+	event := SSEEvent{
+		Type:      "rate_limit_wait",
+		SessionId: sessionID,
+		Data: map[string]interface{}{
+			"waitSeconds": int(math.Ceil(wait.Seconds())),
+			"reason":      reason,
+			"until":       time.Now().Add(wait).Unix(),
+		},
+	}
 	sseHub.Broadcast(event)
 }
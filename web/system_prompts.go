@@ -0,0 +1,263 @@
+package web
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"rcode/context"
+	"rcode/db"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/rweb"
+	"github.com/rohanthewiz/serr"
+)
+
+// systemPromptVar matches a template variable, such as {project_language}, in a system prompt
+var systemPromptVar = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// SystemPromptVariables describes the template variables a system prompt can use, for the UI
+var SystemPromptVariables = map[string]string{
+	"project_name":      "Name of the project's directory",
+	"project_root":      "Path of the project",
+	"project_language":  "Main language of the project, such as go",
+	"project_framework": "Framework the project uses, when one is detected",
+	"session_title":     "Title of the session",
+	"date":              "Today's date, as 2006-01-02",
+	"os":                "Operating system rcode runs on",
+}
+
+// renderSystemPrompt fills in content's template variables for the session and its project.
+// Unknown variables, and those without a value, are left as written.
+func renderSystemPrompt(database *db.DB, content, sessionID string, cm *context.Manager) string {
+	values := map[string]string{
+		"date": time.Now().Format("2006-01-02"),
+		"os":   runtime.GOOS,
+	}
+	if wd, err := os.Getwd(); err == nil {
+		values["project_root"] = wd
+	}
+	if cm != nil && cm.IsInitialized() {
+		pc := cm.GetContext()
+		values["project_root"] = pc.RootPath
+		values["project_language"] = pc.Language
+		values["project_framework"] = pc.Framework
+	}
+	if root := values["project_root"]; root != "" {
+		values["project_name"] = filepath.Base(root)
+	}
+	if strings.Contains(content, "{session_title}") {
+		if session, err := database.GetSession(sessionID); err == nil {
+			values["session_title"] = session.Title
+		}
+	}
+
+	rendered := systemPromptVar.ReplaceAllStringFunc(content, func(match string) string {
+		if value := values[match[1:len(match)-1]]; value != "" {
+			return value
+		}
+		return match
+	})
+	return strings.TrimSpace(rendered)
+}
+
+// listSystemPromptsHandler returns the system prompts and the template variables they can use
+func listSystemPromptsHandler(c rweb.Context) error {
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	prompts, err := database.GetSystemPrompts()
+	if err != nil {
+		logger.LogErr(err, "Failed to list system prompts")
+		return c.WriteError(err, 500)
+	}
+	if prompts == nil {
+		prompts = []*db.SystemPrompt{}
+	}
+
+	return c.WriteJSON(map[string]interface{}{"prompts": prompts, "variables": SystemPromptVariables})
+}
+
+// getSystemPromptHandler returns a system prompt by ID
+func getSystemPromptHandler(c rweb.Context) error {
+	id, err := strconv.Atoi(c.Request().Param("id"))
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid system prompt ID"), 400)
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	prompt, err := database.GetSystemPrompt(id)
+	if err != nil {
+		return c.WriteError(err, 404)
+	}
+	return c.WriteJSON(prompt)
+}
+
+// systemPromptFromRequest reads a system prompt from the request body and checks it has a name and content
+func systemPromptFromRequest(c rweb.Context) (*db.SystemPrompt, error) {
+	var prompt db.SystemPrompt
+	if err := json.Unmarshal(c.Request().Body(), &prompt); err != nil {
+		return nil, serr.Wrap(err, "invalid request body")
+	}
+	prompt.Name = strings.TrimSpace(prompt.Name)
+	if prompt.Name == "" || strings.TrimSpace(prompt.Content) == "" {
+		return nil, serr.New("a system prompt needs a name and content")
+	}
+	return &prompt, nil
+}
+
+// createSystemPromptHandler creates a system prompt
+func createSystemPromptHandler(c rweb.Context) error {
+	prompt, err := systemPromptFromRequest(c)
+	if err != nil {
+		return c.WriteError(err, 400)
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	if err := database.CreateSystemPrompt(prompt); err != nil {
+		logger.LogErr(err, "Failed to create system prompt", "name", prompt.Name)
+		return c.WriteError(err, 500)
+	}
+
+	logger.Info("Created system prompt", "id", prompt.ID, "name", prompt.Name, "default", prompt.IsDefault)
+	return c.WriteJSON(prompt)
+}
+
+// updateSystemPromptHandler updates a system prompt
+func updateSystemPromptHandler(c rweb.Context) error {
+	id, err := strconv.Atoi(c.Request().Param("id"))
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid system prompt ID"), 400)
+	}
+	prompt, err := systemPromptFromRequest(c)
+	if err != nil {
+		return c.WriteError(err, 400)
+	}
+	prompt.ID = id // The ID in the URL, not the body
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	if err := database.UpdateSystemPrompt(prompt); err != nil {
+		logger.LogErr(err, "Failed to update system prompt", "id", id)
+		return c.WriteError(err, 500)
+	}
+
+	updated, err := database.GetSystemPrompt(id)
+	if err != nil {
+		return c.WriteError(err, 500)
+	}
+
+	logger.Info("Updated system prompt", "id", id, "name", updated.Name, "default", updated.IsDefault)
+	return c.WriteJSON(updated)
+}
+
+// deleteSystemPromptHandler deletes a system prompt; the sessions using it go back to the default
+func deleteSystemPromptHandler(c rweb.Context) error {
+	id, err := strconv.Atoi(c.Request().Param("id"))
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid system prompt ID"), 400)
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	if err := database.DeleteSystemPrompt(id); err != nil {
+		logger.LogErr(err, "Failed to delete system prompt", "id", id)
+		return c.WriteError(err, 500)
+	}
+
+	logger.Info("Deleted system prompt", "id", id)
+	return c.WriteJSON(map[string]bool{"success": true})
+}
+
+// SessionSystemPrompt is a session's system prompt, as its API returns it
+type SessionSystemPrompt struct {
+	ID       *int             `json:"id"`     // The session's own prompt, nil when it uses the default
+	Prompt   *db.SystemPrompt `json:"prompt"` // The prompt sent, nil when there is none
+	Rendered string           `json:"rendered,omitempty"`
+}
+
+// sessionSystemPromptState returns the session's system prompt, and the text sent for it
+func sessionSystemPromptState(database *db.DB, sessionID string) (*SessionSystemPrompt, error) {
+	id, err := database.GetSessionSystemPromptID(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	prompt, err := database.GetSessionSystemPrompt(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &SessionSystemPrompt{ID: id, Prompt: prompt}
+	if prompt != nil {
+		state.Rendered = renderSystemPrompt(database, prompt.Content, sessionID, GetContextManager())
+	}
+	return state, nil
+}
+
+// getSessionSystemPromptHandler returns the system prompt a session's requests are sent with
+func getSessionSystemPromptHandler(c rweb.Context) error {
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	state, err := sessionSystemPromptState(database, c.Request().Param("id"))
+	if err != nil {
+		return c.WriteError(err, 404)
+	}
+	return c.WriteJSON(state)
+}
+
+// setSessionSystemPromptHandler sets a session's own system prompt from {"id": N}, or with
+// {"id": null}, has it use the default
+func setSessionSystemPromptHandler(c rweb.Context) error {
+	sessionID := c.Request().Param("id")
+
+	var req struct {
+		ID *int `json:"id"`
+	}
+	if err := json.Unmarshal(c.Request().Body(), &req); err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid request body"), 400)
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	if req.ID != nil {
+		if _, err := database.GetSystemPrompt(*req.ID); err != nil {
+			return c.WriteError(err, 404)
+		}
+	}
+	if err := database.SetSessionSystemPrompt(sessionID, req.ID); err != nil {
+		return c.WriteError(err, 404)
+	}
+
+	state, err := sessionSystemPromptState(database, sessionID)
+	if err != nil {
+		return c.WriteError(err, 500)
+	}
+	return c.WriteJSON(state)
+}
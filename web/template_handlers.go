@@ -0,0 +1,295 @@
+package web
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"rcode/db"
+	"rcode/planner"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/rweb"
+	"github.com/rohanthewiz/serr"
+)
+
+// InstantiateTemplateRequest represents a request to create a plan from a template
+type InstantiateTemplateRequest struct {
+	SessionID   string                 `json:"session_id"`
+	Variables   map[string]interface{} `json:"variables"`
+	AutoExecute bool                   `json:"auto_execute"`
+}
+
+// InitTaskTemplates seeds the built-in task templates that are not yet stored
+func InitTaskTemplates() error {
+	database, err := db.GetDB()
+	if err != nil {
+		return serr.Wrap(err, "failed to get database")
+	}
+
+	for _, tmpl := range planner.BuiltinTemplates() {
+		existing, err := database.GetTaskTemplateByName(tmpl.Name)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			continue
+		}
+
+		record, err := toDBTaskTemplate(tmpl)
+		if err != nil {
+			return err
+		}
+		record.IsBuiltin = true
+
+		if err := database.CreateTaskTemplate(record); err != nil {
+			return serr.Wrap(err, "failed to seed task template", "name", tmpl.Name)
+		}
+		logger.Info("Seeded built-in task template", "name", tmpl.Name)
+	}
+
+	return nil
+}
+
+// listTaskTemplatesHandler returns all task templates, optionally filtered by ?category=
+func listTaskTemplatesHandler(c rweb.Context) error {
+	params, _ := url.ParseQuery(c.Request().Query())
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	templates, err := database.ListTaskTemplates(params.Get("category"))
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to list task templates"), 500)
+	}
+
+	return c.WriteJSON(templates)
+}
+
+// getTaskTemplateHandler returns a single task template
+func getTaskTemplateHandler(c rweb.Context) error {
+	id, err := strconv.Atoi(c.Request().Param("id"))
+	if err != nil {
+		return c.WriteError(serr.New("invalid template ID"), 400)
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	tmpl, err := database.GetTaskTemplate(id)
+	if err != nil {
+		return c.WriteError(err, templateErrorStatus(err))
+	}
+
+	return c.WriteJSON(tmpl)
+}
+
+// createTaskTemplateHandler stores a new user-defined task template
+func createTaskTemplateHandler(c rweb.Context) error {
+	var tmpl planner.TaskTemplate
+	if err := json.Unmarshal(c.Request().Body(), &tmpl); err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid request body"), 400)
+	}
+	if err := tmpl.Validate(); err != nil {
+		return c.WriteError(err, 400)
+	}
+
+	record, err := toDBTaskTemplate(&tmpl)
+	if err != nil {
+		return c.WriteError(err, 400)
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	if err := database.CreateTaskTemplate(record); err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to create task template"), 500)
+	}
+
+	return c.WriteJSON(record)
+}
+
+// updateTaskTemplateHandler replaces a user-defined task template
+func updateTaskTemplateHandler(c rweb.Context) error {
+	id, err := strconv.Atoi(c.Request().Param("id"))
+	if err != nil {
+		return c.WriteError(serr.New("invalid template ID"), 400)
+	}
+
+	var tmpl planner.TaskTemplate
+	if err := json.Unmarshal(c.Request().Body(), &tmpl); err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid request body"), 400)
+	}
+	if err := tmpl.Validate(); err != nil {
+		return c.WriteError(err, 400)
+	}
+
+	record, err := toDBTaskTemplate(&tmpl)
+	if err != nil {
+		return c.WriteError(err, 400)
+	}
+	record.ID = id
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	if err := database.UpdateTaskTemplate(record); err != nil {
+		return c.WriteError(err, templateErrorStatus(err))
+	}
+
+	return c.WriteJSON(record)
+}
+
+// deleteTaskTemplateHandler deletes a user-defined task template
+func deleteTaskTemplateHandler(c rweb.Context) error {
+	id, err := strconv.Atoi(c.Request().Param("id"))
+	if err != nil {
+		return c.WriteError(serr.New("invalid template ID"), 400)
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	if err := database.DeleteTaskTemplate(id); err != nil {
+		return c.WriteError(err, templateErrorStatus(err))
+	}
+
+	return c.WriteJSON(map[string]interface{}{
+		"success": true,
+		"message": "Template deleted successfully",
+	})
+}
+
+// instantiateTaskTemplateHandler creates a plan for a session from a stored template
+func instantiateTaskTemplateHandler(c rweb.Context) error {
+	id, err := strconv.Atoi(c.Request().Param("id"))
+	if err != nil {
+		return c.WriteError(serr.New("invalid template ID"), 400)
+	}
+
+	var req InstantiateTemplateRequest
+	if err := json.Unmarshal(c.Request().Body(), &req); err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid request body"), 400)
+	}
+	if req.SessionID == "" {
+		return c.WriteError(serr.New("session_id required"), 400)
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	record, err := database.GetTaskTemplate(id)
+	if err != nil {
+		return c.WriteError(err, templateErrorStatus(err))
+	}
+
+	tmpl, err := fromDBTaskTemplate(record)
+	if err != nil {
+		return c.WriteError(err, 500)
+	}
+
+	taskPlanner := newTaskPlanner()
+	if err := taskPlanner.LoadTemplate(tmpl); err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to load template"), 500)
+	}
+
+	plan, err := taskPlanner.CreatePlanFromTemplate(tmpl.Name, req.Variables)
+	if err != nil {
+		// Missing variables are the caller's problem
+		return c.WriteError(serr.Wrap(err, "failed to create plan from template"), 400)
+	}
+	plan.SessionID = req.SessionID
+
+	if err := db.GetTaskPlanDB().SavePlan(toDBPlan(plan)); err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to save plan"), 500)
+	}
+
+	broadcastPlanEvent("plan_created", req.SessionID, plan.ID, map[string]interface{}{
+		"description": plan.Description,
+		"steps":       len(plan.Steps),
+		"status":      plan.Status,
+		"template":    tmpl.Name,
+	})
+
+	if req.AutoExecute {
+		go func() {
+			logger.Info("Starting auto-execution of template plan", "plan_id", plan.ID, "template", tmpl.Name)
+			if err := taskPlanner.ExecutePlan(plan.ID); err != nil {
+				logger.LogErr(err, "auto-execution failed", "plan_id", plan.ID)
+			}
+		}()
+	}
+
+	return c.WriteJSON(toPlanResponse(plan))
+}
+
+// toDBTaskTemplate converts a planner template into its stored form
+func toDBTaskTemplate(tmpl *planner.TaskTemplate) (*db.TaskTemplate, error) {
+	stepsJSON, err := json.Marshal(tmpl.Steps)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to marshal template steps")
+	}
+
+	variables := tmpl.Variables
+	if variables == nil {
+		variables = []planner.VariableDefinition{}
+	}
+	variablesJSON, err := json.Marshal(variables)
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to marshal template variables")
+	}
+
+	return &db.TaskTemplate{
+		Name:        tmpl.Name,
+		Description: tmpl.Description,
+		Category:    tmpl.Category,
+		Steps:       stepsJSON,
+		Variables:   variablesJSON,
+	}, nil
+}
+
+// fromDBTaskTemplate converts a stored template back into a planner template
+func fromDBTaskTemplate(record *db.TaskTemplate) (*planner.TaskTemplate, error) {
+	tmpl := &planner.TaskTemplate{
+		Name:        record.Name,
+		Description: record.Description,
+		Category:    record.Category,
+	}
+
+	if err := json.Unmarshal(record.Steps, &tmpl.Steps); err != nil {
+		return nil, serr.Wrap(err, "failed to unmarshal template steps", "template", record.Name)
+	}
+	if len(record.Variables) > 0 {
+		if err := json.Unmarshal(record.Variables, &tmpl.Variables); err != nil {
+			return nil, serr.Wrap(err, "failed to unmarshal template variables", "template", record.Name)
+		}
+	}
+
+	return tmpl, nil
+}
+
+// templateErrorStatus maps task template db errors to HTTP status codes
+func templateErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"):
+		return 404
+	case strings.Contains(msg, "built-in"):
+		return 403
+	default:
+		return 500
+	}
+}
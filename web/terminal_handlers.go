@@ -0,0 +1,205 @@
+package web
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"rcode/db"
+	"rcode/platform/shutdown"
+	"rcode/terminal"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/rweb"
+	"github.com/rohanthewiz/serr"
+)
+
+// terminalToolName is the tool name terminal recordings are stored under in tool usage
+const terminalToolName = "terminal"
+
+// terminalSendTimeout is how long output waits for a stalled client before the stream is dropped
+const terminalSendTimeout = 30 * time.Second
+
+var terminalManager = terminal.NewManager(recordTerminal)
+
+// InitTerminals ends open terminals on shutdown, so their recordings are stored
+func InitTerminals() {
+	shutdown.RegisterHook(func(_ time.Duration) error {
+		terminalManager.CloseAll()
+		return nil
+	})
+}
+
+// recordTerminal stores a finished terminal's recording with the session's tool usage
+func recordTerminal(rec terminal.Recording) {
+	database, err := db.GetDB()
+	if err != nil {
+		logger.LogErr(err, "failed to get database for terminal recording")
+		return
+	}
+
+	input := map[string]interface{}{
+		"terminal_id": rec.TerminalID,
+		"shell":       rec.Shell,
+		"dir":         rec.Dir,
+		"started_at":  rec.StartedAt,
+		"input_bytes": rec.InputBytes,
+		"truncated":   rec.Truncated,
+	}
+	if err := database.LogToolUsage(rec.SessionID, terminalToolName, input, rec.Output, int(rec.Duration.Milliseconds()), rec.ExitError); err != nil {
+		logger.LogErr(err, "failed to store terminal recording", "terminal_id", rec.TerminalID)
+	}
+}
+
+// TerminalSizeRequest sets a terminal's size in character cells
+type TerminalSizeRequest struct {
+	Cols int `json:"cols"`
+	Rows int `json:"rows"`
+}
+
+// startTerminalHandler opens a terminal in the project directory for a session
+func startTerminalHandler(c rweb.Context) error {
+	sessionID := c.Request().Param("id")
+
+	var req TerminalSizeRequest
+	if body := c.Request().Body(); len(body) > 0 {
+		if err := json.Unmarshal(body, &req); err != nil {
+			return c.WriteError(serr.Wrap(err, "invalid request body"), 400)
+		}
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+	session, err := database.GetSession(sessionID)
+	if err != nil {
+		return c.WriteError(err, 500)
+	}
+	if session == nil {
+		// Recordings are stored against the session, so it must exist
+		return c.WriteError(serr.New("session not found"), 404)
+	}
+
+	t, err := terminalManager.Start(sessionID, projectRoot(), req.Cols, req.Rows)
+	if err != nil {
+		return c.WriteError(err, 500)
+	}
+
+	return c.WriteJSON(t)
+}
+
+// listTerminalsHandler returns a session's open terminals
+func listTerminalsHandler(c rweb.Context) error {
+	return c.WriteJSON(terminalManager.List(c.Request().Param("id")))
+}
+
+// terminalStreamHandler streams a terminal's output over SSE, starting with its recent
+// output. Output events carry base64 data, since terminal output need not be valid UTF-8;
+// an exit event follows when the shell ends.
+func terminalStreamHandler(c rweb.Context) error {
+	t, err := terminalManager.Get(c.Request().Param("id"))
+	if err != nil {
+		return c.WriteError(err, 404)
+	}
+
+	scrollback, output, cancel := t.Subscribe()
+	events := make(chan any, clientChanCap)
+
+	// publish queues an event, giving up if the client has stopped reading
+	publish := func(eventType, data string) bool {
+		select {
+		case events <- rweb.SSEvent{Type: eventType, Data: data}:
+			return true
+		case <-time.After(terminalSendTimeout):
+			return false
+		}
+	}
+
+	go func() {
+		defer close(events)
+		defer cancel()
+
+		if len(scrollback) > 0 && !publish("output", base64.StdEncoding.EncodeToString(scrollback)) {
+			return
+		}
+		for chunk := range output {
+			if !publish("output", base64.StdEncoding.EncodeToString(chunk)) {
+				return
+			}
+		}
+
+		<-t.Done()
+		publish("exit", t.ID)
+	}()
+
+	return c.SetSSE(events, "")
+}
+
+// terminalInputHandler sends keystrokes to a terminal
+func terminalInputHandler(c rweb.Context) error {
+	t, err := terminalManager.Get(c.Request().Param("id"))
+	if err != nil {
+		return c.WriteError(err, 404)
+	}
+
+	var req struct {
+		Data string `json:"data"`
+	}
+	if err := json.Unmarshal(c.Request().Body(), &req); err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid request body"), 400)
+	}
+
+	if err := t.Write([]byte(req.Data)); err != nil {
+		return c.WriteError(err, 500)
+	}
+
+	return c.WriteJSON(map[string]bool{"success": true})
+}
+
+// resizeTerminalHandler resizes a terminal to fit its view
+func resizeTerminalHandler(c rweb.Context) error {
+	t, err := terminalManager.Get(c.Request().Param("id"))
+	if err != nil {
+		return c.WriteError(err, 404)
+	}
+
+	var req TerminalSizeRequest
+	if err := json.Unmarshal(c.Request().Body(), &req); err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid request body"), 400)
+	}
+
+	if err := t.Resize(req.Cols, req.Rows); err != nil {
+		return c.WriteError(err, 400)
+	}
+
+	return c.WriteJSON(map[string]bool{"success": true})
+}
+
+// closeTerminalHandler ends a terminal once its recording is stored
+func closeTerminalHandler(c rweb.Context) error {
+	id := c.Request().Param("id")
+	if err := terminalManager.Close(id); err != nil {
+		return c.WriteError(err, 404)
+	}
+
+	logger.Info("Closed terminal", "terminal_id", id)
+
+	return c.WriteJSON(map[string]bool{"success": true})
+}
+
+// getToolUsageHandler returns a session's recorded tool calls and terminal sessions.
+// The tool query parameter limits them to one tool, e.g. tool=terminal.
+func getToolUsageHandler(c rweb.Context) error {
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	usages, err := database.ListToolUsage(c.Request().Param("id"), c.Request().QueryParam("tool"), 100)
+	if err != nil {
+		return c.WriteError(err, 500)
+	}
+
+	return c.WriteJSON(usages)
+}
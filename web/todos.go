@@ -0,0 +1,80 @@
+package web
+
+import (
+	"rcode/db"
+	"rcode/tools"
+
+	"github.com/rohanthewiz/rweb"
+	"github.com/rohanthewiz/serr"
+)
+
+// todoTools keep only the session's own task list, so they run without asking unless denied
+var todoTools = map[string]bool{
+	"todo_write": true,
+	"todo_read":  true,
+}
+
+// TodosUpdate is a session's task list, as sent to the pages when the model changes it
+type TodosUpdate struct {
+	Todos []db.Todo `json:"todos"`
+}
+
+// dbTodoStore keeps the todo tools' task lists in the session_todos table
+type dbTodoStore struct{}
+
+// ReadTodos returns the session's task list
+func (dbTodoStore) ReadTodos(sessionID string) ([]tools.Todo, error) {
+	database, err := db.GetDB()
+	if err != nil {
+		return nil, serr.Wrap(err, "failed to get database")
+	}
+	todos, err := database.GetTodos(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]tools.Todo, 0, len(todos))
+	for _, todo := range todos {
+		result = append(result, tools.Todo{Content: todo.Content, Status: todo.Status})
+	}
+	return result, nil
+}
+
+// WriteTodos replaces the session's task list and shows it on the session's pages
+func (dbTodoStore) WriteTodos(sessionID string, todos []tools.Todo) error {
+	database, err := db.GetDB()
+	if err != nil {
+		return serr.Wrap(err, "failed to get database")
+	}
+
+	list := make([]db.Todo, 0, len(todos))
+	for _, todo := range todos {
+		list = append(list, db.Todo{Content: todo.Content, Status: todo.Status})
+	}
+	if err := database.SetTodos(sessionID, list); err != nil {
+		return err
+	}
+
+	BroadcastSessionUpdate(sessionID, "todos_updated", TodosUpdate{Todos: list})
+	return nil
+}
+
+// InitTodoStore gives the todo tools the database
+func InitTodoStore() {
+	tools.SetTodoStore(dbTodoStore{})
+}
+
+// getSessionTodosHandler returns a session's task list
+func getSessionTodosHandler(c rweb.Context) error {
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	todos, err := database.GetTodos(c.Request().Param("id"))
+	if err != nil {
+		return c.WriteError(err, 500)
+	}
+
+	return c.WriteJSON(TodosUpdate{Todos: todos})
+}
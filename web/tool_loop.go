@@ -0,0 +1,97 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"rcode/config"
+	"rcode/db"
+	"rcode/providers"
+	"rcode/tools"
+)
+
+// toolLoopGuard stops a turn whose model keeps calling tools: after RCODE_MAX_TOOL_ROUNDS
+// rounds of calls, or when it makes the same calls RCODE_TOOL_REPEAT_LIMIT rounds in a row
+type toolLoopGuard struct {
+	maxRounds   int
+	repeatLimit int
+	rounds      int
+	lastCalls   string // The previous round's calls, as JSON
+	repeats     int    // Rounds in a row that made lastCalls
+}
+
+// newToolLoopGuard returns a guard with the configured limits
+func newToolLoopGuard() *toolLoopGuard {
+	cfg := config.Get()
+	return &toolLoopGuard{maxRounds: cfg.MaxToolRounds, repeatLimit: cfg.ToolRepeatLimit}
+}
+
+// next records a round of tool calls, before they run, and returns why the turn should stop
+// once they have, or "" for it to go on
+func (g *toolLoopGuard) next(calls []*tools.ToolUse) string {
+	g.rounds++
+
+	signature := toolCallsSignature(calls)
+	if signature == g.lastCalls {
+		g.repeats++
+	} else {
+		g.lastCalls, g.repeats = signature, 1
+	}
+
+	if g.repeatLimit > 0 && g.repeats >= g.repeatLimit {
+		return fmt.Sprintf("Stopped after making the same tool calls (%s) %d times in a row.", toolCallNames(calls), g.repeats)
+	}
+	if g.maxRounds > 0 && g.rounds >= g.maxRounds {
+		return fmt.Sprintf("Stopped after %d tool rounds.", g.rounds)
+	}
+	return ""
+}
+
+// toolCallsSignature returns a round's calls as JSON, which is the same for the same tools
+// with the same input. Calls whose input couldn't be read are left out.
+func toolCallsSignature(calls []*tools.ToolUse) string {
+	type call struct {
+		Name  string                 `json:"name"`
+		Input map[string]interface{} `json:"input"`
+	}
+	signature := make([]call, 0, len(calls))
+	for _, c := range calls {
+		if c != nil {
+			signature = append(signature, call{Name: c.Name, Input: c.Input})
+		}
+	}
+	data, _ := json.Marshal(signature) // Map keys are sorted, so equal input marshals the same
+	return string(data)
+}
+
+// toolCallNames lists the tools a round called
+func toolCallNames(calls []*tools.ToolUse) string {
+	var names []string
+	for _, c := range calls {
+		if c != nil {
+			names = append(names, c.Name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// stopToolLoop ends a turn the toolLoopGuard stopped with an assistant message saying why,
+// which follows the last tool results so the conversation can go on from it
+func stopToolLoop(database *db.DB, sessionID, reason, model string, log sessionLog) *MessageReply {
+	content := reason + " Send a message to let me continue, or to change the approach."
+	log.Warn("Tool loop stopped", "reason", reason)
+
+	BroadcastMessageDelta(sessionID, content)
+	BroadcastMessageStop(sessionID)
+	if err := database.AddMessage(sessionID, providers.ChatMessage{Role: "assistant", Content: content}, model, nil); err != nil {
+		log.Err(err, "failed to add tool loop stop message")
+	}
+
+	return &MessageReply{
+		Role:     "assistant",
+		Content:  content,
+		Streamed: true,
+		Model:    model,
+	}
+}
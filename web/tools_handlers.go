@@ -2,8 +2,11 @@ package web
 
 import (
 	"encoding/json"
+	"path/filepath"
 	
+	"rcode/config"
 	"rcode/db"
+	"rcode/scaffold"
 	"rcode/tools"
 	
 	"github.com/rohanthewiz/logger"
@@ -11,6 +14,49 @@ import (
 	"github.com/rohanthewiz/serr"
 )
 
+// InitPluginWatcher reloads the custom tool plugins when the plugin directories change,
+// and tells the UI, when custom tools are enabled
+func InitPluginWatcher() {
+	if !config.Get().CustomToolsEnabled {
+		return
+	}
+	tools.WatchPlugins(BroadcastToolsUpdated)
+}
+
+// registerHTTPTools adds the HTTP tools from the user's config file, then the project's
+// .rcode/http_tools.json, to the registry
+func registerHTTPTools(registry *tools.Registry, workDir string) {
+	httpTools, err := tools.LoadHTTPTools(config.Get().HTTPToolsConfig, filepath.Join(workDir, ".rcode", "http_tools.json"))
+	if err != nil {
+		logger.LogErr(err, "failed to load HTTP tools")
+		return
+	}
+	tools.RegisterHTTPTools(registry, httpTools)
+}
+
+// registerDatabaseTool adds db_query, for the databases in the user's config file and the
+// project's .rcode/databases.json, to the registry
+func registerDatabaseTool(registry *tools.Registry, workDir string) {
+	databases, err := tools.LoadDatabases(config.Get().DatabasesConfig, filepath.Join(workDir, ".rcode", "databases.json"))
+	if err != nil {
+		logger.LogErr(err, "failed to load database connections")
+		return
+	}
+	tools.RegisterDatabaseTool(registry, databases, workDir)
+}
+
+// registerGenerateTool adds generate, for the built-in templates, the user's and the
+// project's .rcode/templates, to the registry. When the user's or the project's templates
+// can't be loaded, only the built-in ones are offered.
+func registerGenerateTool(registry *tools.Registry, workDir string) {
+	templates, err := tools.LoadTemplates(workDir)
+	if err != nil {
+		logger.LogErr(err, "failed to load templates")
+		templates = scaffold.Builtin()
+	}
+	tools.RegisterGenerateTool(registry, templates, workDir)
+}
+
 // ToolInfo represents tool information with permission status
 type ToolInfo struct {
 	Name        string `json:"name"`
@@ -58,8 +104,19 @@ func getSessionToolsHandler(c rweb.Context) error {
 		permMap[perm.ToolName] = perm
 	}
 	
-	// Get tool registry
-	registry := tools.DefaultRegistry()
+	// Get tool registry, with the custom tools the session's next message will have
+	registry, err := tools.DefaultRegistryWithPlugins(projectRoot())
+	if err != nil {
+		logger.LogErr(err, "failed to create tool registry with plugins")
+		registry = tools.DefaultRegistry()
+	}
+	tools.RegisterMemoryTools(registry, newMemoryStore(database))
+	registerSemanticSearchTool(registry)
+	registerHTTPTools(registry, projectRoot())
+	registerDatabaseTool(registry, projectRoot())
+	registerGenerateTool(registry, projectRoot())
+	tools.RegisterDependencyGraphTool(registry, GetContextManager())
+	tools.RegisterSBOMTool(registry, GetContextManager())
 	availableTools := registry.GetTools()
 	
 	// Build tool info list
@@ -145,10 +202,15 @@ func updateToolPermissionHandler(c rweb.Context) error {
 func categorizeTools(toolName string) string {
 	categories := map[string]string{
 		// File operations
-		"read_file":  "File Operations",
-		"write_file": "File Operations",
-		"edit_file":  "File Operations",
-		"search":     "File Operations",
+		"read_file":       "File Operations",
+		"write_file":      "File Operations",
+		"edit_file":       "File Operations",
+		"replace_snippet": "File Operations",
+		"apply_patch":     "File Operations",
+		"generate":        "File Operations",
+		"search":          "File Operations",
+		"semantic_search": "File Operations",
+		"dependency_graph": "File Operations",
 		
 		// Directory operations
 		"list_dir": "Directory Operations",
@@ -156,25 +218,76 @@ func categorizeTools(toolName string) string {
 		"make_dir": "Directory Operations",
 		"remove":   "Directory Operations",
 		"move":     "Directory Operations",
+
+		// Refactoring
+		"rename_symbol": "Refactoring",
+		"move_package":  "Refactoring",
+
+		// Testing
+		"test_coverage":  "Testing",
+		"generate_tests": "Testing",
+		"bench":          "Testing",
+
+		// Dependencies
+		"outdated_dependencies": "Dependencies",
+		"update_dependency":     "Dependencies",
+		"security_scan":         "Dependencies",
+		"sbom":                  "Dependencies",
 		
 		// Git operations
-		"git_status":   "Git Operations",
-		"git_diff":     "Git Operations",
-		"git_log":      "Git Operations",
-		"git_branch":   "Git Operations",
-		"git_add":      "Git Operations",
-		"git_commit":   "Git Operations",
-		"git_push":     "Git Operations",
-		"git_pull":     "Git Operations",
-		"git_checkout": "Git Operations",
-		"git_merge":    "Git Operations",
+		"git_status":         "Git Operations",
+		"git_diff":           "Git Operations",
+		"git_log":            "Git Operations",
+		"git_branch":         "Git Operations",
+		"git_add":            "Git Operations",
+		"git_commit":         "Git Operations",
+		"git_push":           "Git Operations",
+		"git_pull":           "Git Operations",
+		"git_checkout":       "Git Operations",
+		"git_merge":          "Git Operations",
+		"git_remote":         "Git Operations",
+		"git_fetch":          "Git Operations",
+		"git_tag":            "Git Operations",
+		"git_cherry_pick":    "Git Operations",
+		"git_revert":         "Git Operations",
+		"git_commit_message": "Git Operations",
+		"git_conflicts":      "Git Operations",
+		"resolve_conflict":   "Git Operations",
+
+		// Forge operations
+		"create_pull_request":  "Forge Operations",
+		"list_issues":          "Forge Operations",
+		"get_issue":            "Forge Operations",
+		"comment_pull_request": "Forge Operations",
 		
 		// System operations
-		"bash": "System Operations",
+		"bash":           "System Operations",
+		"run_snippet":    "System Operations",
+		"run_task":       "System Operations",
+		"db_query":       "System Operations",
+		"run_background": "System Operations",
+		"list_processes": "System Operations",
+		"stop_process":   "System Operations",
 		
 		// Web operations
 		"web_search": "Web Operations",
 		"web_fetch":  "Web Operations",
+
+		// Project memory
+		"remember": "Project Memory",
+		"recall":   "Project Memory",
+
+		// Artifacts
+		"read_artifact":    "Artifacts",
+		"publish_artifact": "Artifacts",
+
+		// Task list
+		"todo_write": "Task List",
+		"todo_read":  "Task List",
+
+		// Session notes
+		"add_note":   "Notes",
+		"read_notes": "Notes",
 	}
 	
 	if category, exists := categories[toolName]; exists {
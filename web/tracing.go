@@ -0,0 +1,104 @@
+package web
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rohanthewiz/rweb"
+	"github.com/rohanthewiz/serr"
+	"rcode/config"
+	"rcode/platform/shutdown"
+	"rcode/tracing"
+)
+
+const traceContextKey = "trace_context"
+
+// InitTracing exports spans to the configured OTLP endpoint, if there is one, and flushes
+// them on shutdown
+func InitTracing() {
+	tracing.Init(config.Get().TracingEndpoint, "rcode")
+	shutdown.RegisterHook(func(timeout time.Duration) error {
+		tracing.Shutdown(timeout)
+		return nil
+	})
+}
+
+// traceRequests gives each request a span, continuing the caller's trace when it sends a
+// traceparent header. Handlers start their spans under it with requestContext.
+func traceRequests(c rweb.Context) error {
+	if !tracing.Enabled() {
+		return c.Next()
+	}
+
+	req := c.Request()
+	ctx := context.Background()
+	if parent, ok := tracing.ParseTraceParent(req.Header("traceparent")); ok {
+		ctx = tracing.WithRemoteParent(ctx, parent)
+	}
+	ctx, span := tracing.Start(ctx, req.Method()+" "+req.Path(),
+		tracing.String("http.method", req.Method()), tracing.String("http.target", req.Path()))
+	if id := requestID(c); id != "" {
+		span.SetAttributes(tracing.String("request.id", id))
+	}
+	defer span.End()
+	c.Set(traceContextKey, ctx)
+
+	err := c.Next()
+
+	status := c.Response().Status()
+	if status == 0 {
+		status = 200
+	}
+	span.SetAttributes(tracing.Int("http.status_code", status))
+	if err != nil {
+		span.SetError(err)
+	} else if status >= 500 {
+		span.SetError(serr.New("HTTP " + strconv.Itoa(status)))
+	}
+	return err
+}
+
+// requestContext returns the context for work a request starts: it carries the request's
+// span and ID
+func requestContext(c rweb.Context) context.Context {
+	ctx, ok := c.Get(traceContextKey).(context.Context)
+	if !ok {
+		ctx = context.Background()
+	}
+	if id := requestID(c); id != "" {
+		ctx = withRequestID(ctx, id)
+	}
+	return ctx
+}
+
+// turnTraces holds the trace ID of each session's turn in progress, which is added to the
+// session's SSE events so a slow turn seen in the UI can be found in the tracing backend
+var turnTraces sync.Map // session ID -> trace ID
+
+// traceTurn starts the span of a session's turn and tags the session's events with its
+// trace. Call the returned function, which ends the span, when the turn is over.
+func traceTurn(ctx context.Context, sessionID string) (context.Context, *tracing.Span, func()) {
+	ctx, span := tracing.Start(ctx, "turn", tracing.String("session.id", sessionID))
+	traceID := span.TraceID()
+	if traceID != "" {
+		turnTraces.Store(sessionID, traceID)
+	}
+	return ctx, span, func() {
+		if traceID != "" {
+			turnTraces.CompareAndDelete(sessionID, traceID)
+		}
+		span.End()
+	}
+}
+
+// sessionTraceID returns the trace ID of the session's turn in progress, or ""
+func sessionTraceID(sessionID string) string {
+	if sessionID == "" {
+		return ""
+	}
+	traceID, _ := turnTraces.Load(sessionID)
+	id, _ := traceID.(string)
+	return id
+}
@@ -0,0 +1,187 @@
+package web
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/rweb"
+	"github.com/rohanthewiz/serr"
+)
+
+// How a queued message left the queue, in message_dequeued events
+const (
+	dequeuedStarted   = "started"
+	dequeuedCancelled = "cancelled"
+)
+
+// QueuedMessage is a message waiting for its session's turn in progress to finish
+type QueuedMessage struct {
+	ID       string    `json:"id"`
+	Content  string    `json:"content"`
+	QueuedAt time.Time `json:"queuedAt"`
+}
+
+// turnJob is a turn answering a message, run by its session's worker
+type turnJob struct {
+	QueuedMessage
+	run       func() (*MessageReply, error)
+	queued    bool // It waited for earlier turns
+	cancelled bool // It was removed from the queue before its turn
+	reply     *MessageReply
+	err       error
+	done      chan struct{}
+}
+
+// turnWorker runs a session's turns one at a time, in the order they were sent
+type turnWorker struct {
+	queue   []*turnJob
+	running bool
+}
+
+// turnWorkers holds the worker of each session with a turn running or waiting. Sessions'
+// turns run at the same time; a worker stops when its session has no more turns.
+var turnWorkers = struct {
+	sync.Mutex
+	bySession map[string]*turnWorker
+}{bySession: make(map[string]*turnWorker)}
+
+// newTurnJob returns a turn answering a message with run
+func newTurnJob(content string, run func() (*MessageReply, error)) *turnJob {
+	return &turnJob{
+		QueuedMessage: QueuedMessage{ID: uuid.New().String(), Content: content, QueuedAt: time.Now()},
+		run:           run,
+		done:          make(chan struct{}),
+	}
+}
+
+// queueTurn adds a turn to its session's worker, after the session's earlier turns, so
+// that it sees their messages. It returns how many turns are running or waiting before it;
+// the turn's reply and error are set when job.done is closed.
+func queueTurn(sessionID string, job *turnJob) (ahead int) {
+	turnWorkers.Lock()
+	worker, ok := turnWorkers.bySession[sessionID]
+	if !ok {
+		worker = &turnWorker{}
+		turnWorkers.bySession[sessionID] = worker
+		go worker.work(sessionID)
+	}
+	ahead = len(worker.queue)
+	if worker.running {
+		ahead++
+	}
+	job.queued = ahead > 0
+	worker.queue = append(worker.queue, job)
+	turnWorkers.Unlock()
+
+	if job.queued {
+		logger.Info("Message queued", "session_id", sessionID, "queue_id", job.ID, "ahead", ahead)
+		BroadcastMessageQueued(sessionID, job.QueuedMessage, ahead)
+	}
+	return ahead
+}
+
+// work runs the session's queued turns until there are none
+func (w *turnWorker) work(sessionID string) {
+	for {
+		turnWorkers.Lock()
+		if len(w.queue) == 0 {
+			delete(turnWorkers.bySession, sessionID)
+			turnWorkers.Unlock()
+			return
+		}
+		job := w.queue[0]
+		w.queue = w.queue[1:]
+		w.running = true
+		turnWorkers.Unlock()
+
+		if job.queued {
+			BroadcastMessageDequeued(sessionID, job.ID, dequeuedStarted)
+		}
+		job.reply, job.err = runTurnJob(sessionID, job)
+		close(job.done)
+
+		turnWorkers.Lock()
+		w.running = false
+		turnWorkers.Unlock()
+	}
+}
+
+// runTurnJob runs a turn, making a panic its error so the session's later turns still run
+func runTurnJob(sessionID string, job *turnJob) (reply *MessageReply, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = serr.New(fmt.Sprintf("turn failed: %v", r))
+			logger.LogErr(err, "turn panicked", "session_id", sessionID, "stack", string(debug.Stack()))
+		}
+	}()
+	return job.run()
+}
+
+// awaitQueuedTurn tells the session's pages when a queued message's turn finishes, as its
+// sender was answered when it was queued
+func awaitQueuedTurn(sessionID string, job *turnJob) {
+	<-job.done
+	if !job.cancelled {
+		BroadcastQueuedMessageFinished(sessionID, job.ID, job.err)
+	}
+}
+
+// cancelQueuedTurn removes a message from its session's queue, reporting whether it was
+// waiting there. A turn that has started can't be cancelled this way.
+func cancelQueuedTurn(sessionID, id string) bool {
+	turnWorkers.Lock()
+	worker, ok := turnWorkers.bySession[sessionID]
+	if !ok {
+		turnWorkers.Unlock()
+		return false
+	}
+	var job *turnJob
+	for i, queued := range worker.queue {
+		if queued.ID == id {
+			job = queued
+			worker.queue = append(worker.queue[:i], worker.queue[i+1:]...)
+			break
+		}
+	}
+	turnWorkers.Unlock()
+
+	if job == nil {
+		return false
+	}
+	job.cancelled = true
+	job.err = serr.New("queued message cancelled")
+	close(job.done)
+	BroadcastMessageDequeued(sessionID, id, dequeuedCancelled)
+	return true
+}
+
+// queuedMessages returns the messages waiting in a session's queue, in the order they will be sent
+func queuedMessages(sessionID string) []QueuedMessage {
+	turnWorkers.Lock()
+	defer turnWorkers.Unlock()
+
+	messages := []QueuedMessage{}
+	if worker, ok := turnWorkers.bySession[sessionID]; ok {
+		for _, job := range worker.queue {
+			messages = append(messages, job.QueuedMessage)
+		}
+	}
+	return messages
+}
+
+// listQueuedMessagesHandler lists the messages waiting for the session's turn in progress
+func listQueuedMessagesHandler(c rweb.Context) error {
+	return c.WriteJSON(queuedMessages(c.Request().Param("id")))
+}
+
+// cancelQueuedMessageHandler removes a message from the session's queue before it is sent
+func cancelQueuedMessageHandler(c rweb.Context) error {
+	if !cancelQueuedTurn(c.Request().Param("id"), c.Request().Param("queueId")) {
+		return c.WriteError(serr.New("queued message not found; it may have been sent"), 404)
+	}
+	return c.WriteJSON(map[string]bool{"success": true})
+}
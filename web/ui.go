@@ -7,7 +7,8 @@ import (
 	"os"
 	"strings"
 
-	"rcode/auth"
+	"rcode/config"
+	"rcode/db"
 
 	"github.com/rohanthewiz/element"
 	"github.com/rohanthewiz/rweb"
@@ -101,13 +102,15 @@ var fileOperationsCSS string
 // UIHandler serves the main chat interface using element package
 func UIHandler(c rweb.Context) error {
 	// Check if user is authenticated
-	_, err := auth.GetAccessToken()
-	isAuthenticated := err == nil
+	isAuthenticated := modelsConnected()
 
-	return c.WriteHTML(generateMainUI(isAuthenticated))
+	return c.WriteHTML(generateMainUI(isAuthenticated, currentUser(c)))
 }
 
-func generateMainUI(isAuthenticated bool) string {
+// generateMainUI renders the app. user is the signed-in user in multi-user mode, where
+// only admins connect or disconnect the Claude account, and nil otherwise.
+func generateMainUI(isAuthenticated bool, user *db.User) string {
+	isAdmin := user == nil || user.IsAdmin
 	b := element.NewBuilder()
 
 	b.Html().R(
@@ -121,6 +124,10 @@ func generateMainUI(isAuthenticated bool) string {
 			// Highlight.js for code syntax highlighting
 			b.Link("rel", "stylesheet", "href", "https://cdnjs.cloudflare.com/ajax/libs/highlight.js/11.9.0/styles/github-dark.min.css"),
 			b.Script("src", "https://cdnjs.cloudflare.com/ajax/libs/highlight.js/11.9.0/highlight.min.js").R(),
+			// xterm.js for the terminal panel
+			b.Link("rel", "stylesheet", "href", "https://cdn.jsdelivr.net/npm/@xterm/xterm@5.5.0/css/xterm.css"),
+			b.Script("src", "https://cdn.jsdelivr.net/npm/@xterm/xterm@5.5.0/lib/xterm.js").R(),
+			b.Script("src", "https://cdn.jsdelivr.net/npm/@xterm/addon-fit@0.10.0/lib/addon-fit.js").R(),
 			// Monaco Editor CSS
 			b.Link("rel", "stylesheet", "href", "https://cdnjs.cloudflare.com/ajax/libs/monaco-editor/0.52.2/min/vs/editor/editor.main.min.css"),
 			// Our custom styles
@@ -144,6 +151,22 @@ func generateMainUI(isAuthenticated bool) string {
 										),
 										b.Label("for", "plan-mode-switch", "class", "plan-mode-label").T("Plan Mode"),
 									)
+									// Read-only Toggle: the session's tools look but don't touch
+									b.Div("class", "plan-mode-toggle read-only-toggle", "title", "Deny the tools that change the project; shell commands ask first").R(
+										b.Label("class", "switch").R(
+											b.Input("type", "checkbox", "id", "read-only-switch"),
+											b.Span("class", "slider round").R(),
+										),
+										b.Label("for", "read-only-switch", "class", "plan-mode-label").T("Read-only"),
+									)
+									// Auto-accept Toggle: the session's tools run without asking, within limits
+									b.Div("class", "plan-mode-toggle auto-accept-toggle", "title", "Run tools without asking, stopping the turn at the limits on what it changes").R(
+										b.Label("class", "switch").R(
+											b.Input("type", "checkbox", "id", "auto-accept-switch"),
+											b.Span("class", "slider round").R(),
+										),
+										b.Label("for", "auto-accept-switch", "class", "plan-mode-label").T("Auto-accept"),
+									)
 								}
 								return nil
 							}(),
@@ -152,13 +175,34 @@ func generateMainUI(isAuthenticated bool) string {
 							func() any {
 								if isAuthenticated {
 									b.Span("class", "auth-status").T("Connected to Claude")
+									b.Span("id", "account-warning", "class", "account-warning", "style", "display: none").R()
 									b.Span("id", "connection-status", "class", "connection-status").R()
 									b.Button("id", "plan-history-btn", "class", "btn-secondary").T("Plan History")
+									b.Button("id", "notes-btn", "class", "btn-secondary", "title", "The session's notes, and which of them are sent with every request").T("Notes")
+									b.Button("id", "commit-btn", "class", "btn-secondary", "title", "Generate a commit message for the staged changes").T("Commit")
+									b.Button("id", "review-btn", "class", "btn-secondary", "title", "Review a branch or the staged changes").T("Review")
+									b.Button("id", "terminal-btn", "class", "btn-secondary", "title", "Open a terminal in the project directory").T("Terminal")
+									b.Button("id", "processes-btn", "class", "btn-secondary", "title", "Background processes and previews of running apps").T("Processes")
+									b.Button("id", "settings-btn", "class", "btn-secondary", "title", "Preferences shared by all your browsers").T("Settings")
+									if user == nil || isAdmin {
+										b.Button("id", "accounts-btn", "class", "btn-secondary", "title", "Claude accounts and when their tokens expire").T("Accounts")
+									}
 									b.Button("class", "btn-secondary", "onclick", "window.open('/prompts', '_blank')").T("Manage Prompts")
 									b.Button("id", "usage-toggle-btn", "class", "btn-secondary").T("Usage")
-									b.Button("id", "logout-btn", "class", "btn-secondary").T("Logout")
-								} else {
+									if user == nil {
+										b.Button("id", "logout-btn", "class", "btn-secondary").T("Logout")
+									} else if isAdmin {
+										b.Button("id", "users-btn", "class", "btn-secondary", "title", "Add and remove user accounts").T("Users")
+										b.Button("id", "logout-btn", "class", "btn-secondary", "title", "Disconnect the Claude account all users share").T("Disconnect Claude")
+									}
+								} else if isAdmin {
 									b.Button("class", "btn-primary", "onclick", "handleLogin()").T("Login with Claude Pro/Max")
+								} else {
+									b.Span("class", "auth-status").T("Waiting for an admin to connect Claude")
+								}
+								if user != nil {
+									b.Span("class", "user-name").T(user.Username)
+									b.Button("id", "signout-btn", "class", "btn-secondary", "onclick", "signOut()").T("Sign Out")
 								}
 								return nil
 							}(),
@@ -186,6 +230,10 @@ func generateMainUI(isAuthenticated bool) string {
 											b.Span("class", "stat-label").T("Cost:"),
 											b.Span("id", "session-cost", "class", "stat-value").T("$0.00"),
 										),
+										b.Div("class", "stat-item").R(
+											b.Span("class", "stat-label").T("Last request:"),
+											b.Span("id", "session-estimate", "class", "stat-value", "title", "Estimated input tokens and cost, before it was sent").T("--"),
+										),
 									),
 								),
 								// Rate Limits
@@ -258,6 +306,13 @@ func generateMainUI(isAuthenticated bool) string {
 					b.Section("id", "chat-area").R(
 						func() any {
 							if !isAuthenticated {
+								if !isAdmin {
+									b.Div("class", "auth-prompt").R(
+										b.H2().T("Welcome to RCode"),
+										b.P().T("An admin needs to connect the Claude account before you can start coding."),
+									)
+									return nil
+								}
 								b.Div("class", "auth-prompt").R(
 									b.H2().T("Welcome to RCode"),
 									b.P().T("Please login with your Claude Pro/Max account to start coding."),
@@ -278,12 +333,22 @@ func generateMainUI(isAuthenticated bool) string {
 									),
 									b.Div("id", "plan-steps", "class", "plan-steps").R(),
 									b.Div("class", "plan-controls").R(
+										b.Button("id", "dry-run-plan-btn", "class", "btn-secondary").T("Dry Run"),
 										b.Button("id", "execute-plan-btn", "class", "btn-primary").T("Execute Plan"),
 										b.Button("id", "pause-plan-btn", "class", "btn-secondary", "disabled", "disabled").T("Pause"),
+										b.Button("id", "cancel-plan-btn", "class", "btn-warning", "disabled", "disabled").T("Cancel"),
 										b.Button("id", "rollback-plan-btn", "class", "btn-warning", "disabled", "disabled").T("Rollback"),
 										b.Button("id", "view-metrics-btn", "class", "btn-secondary").T("View Metrics"),
 									),
 								)
+								// Task list the model keeps with todo_write (hidden while empty)
+								b.Div("id", "todo-panel", "class", "todo-panel", "style", "display: none;").R(
+									b.Div("id", "todo-header", "class", "todo-header", "title", "Show or hide the tasks").R(
+										b.Span("class", "todo-title").T("Tasks"),
+										b.Span("id", "todo-progress", "class", "todo-progress").R(),
+									),
+									b.Div("id", "todo-list", "class", "todo-list").R(),
+								)
 								// Messages container
 								b.Div("id", "messages", "class", "messages").R()
 								// Input area
@@ -299,8 +364,25 @@ func generateMainUI(isAuthenticated bool) string {
 												b.Option("value", "claude-3-7-sonnet-20250219").T("3.7 Sonnet"),
 												b.Option("value", "claude-3-5-sonnet-20241022").T("3.5 Sonnet"),
 												b.Option("value", "claude-3-5-haiku-20240701").T("3.5 Haiku (Fast)"),
+												func() any {
+													// Models of the local server, from RCODE_LOCAL_MODELS
+													for _, model := range config.Get().LocalModels {
+														b.Option("value", model).T(model + " (Local)")
+													}
+													return nil
+												}(),
 											),
 										),
+										// Account selector, shown when more than one Claude account is logged in
+										b.Div("id", "account-selector-container", "class", "model-selector-container", "style", "display: none").R(
+											b.Label("for", "account-selector", "class", "model-label").T("Account:"),
+											b.Select("id", "account-selector", "class", "model-selector").R(),
+										),
+										// Package selector, shown when the project is a monorepo
+										b.Div("id", "package-selector-container", "class", "model-selector-container", "style", "display: none").R(
+											b.Label("for", "package-selector", "class", "model-label").T("Package:"),
+											b.Select("id", "package-selector", "class", "model-selector").R(),
+										),
 										b.DivClass("tool-use-widget hidden", "id", "tool-use-widget").R(
 											b.DivClass("widget-label").T("TOOLS"),
 											b.DivClass("tool-cards-container").R(),
@@ -310,12 +392,19 @@ func generateMainUI(isAuthenticated bool) string {
 									b.Div("id", "plan-mode-indicator", "class", "plan-mode-indicator", "style", "display: none;").R(
 										b.Span("class", "plan-icon").T("📋"),
 										b.Span().T("Plan Mode Active - Describe a complex task to create a plan"),
+										b.Label("class", "plan-llm-option", "title", "Let the model break the task into steps").R(
+											b.Input("type", "checkbox", "id", "plan-use-llm"),
+											b.T(" AI planning"),
+										),
 									),
 									// Monaco editor container
 									b.Div("id", "monaco-container", "style", "height: 150px; border: 1px solid var(--border); border-radius: 4px; margin-bottom: 1rem;").R(),
 									b.Div("class", "input-controls").R(
 										b.Button("id", "send-btn", "class", "btn-primary").T("Send"),
 										b.Button("id", "create-plan-btn", "class", "btn-primary", "style", "display: none;").T("Create Plan"),
+										b.Button("id", "plan-template-btn", "class", "btn-secondary", "style", "display: none;").T("From Template"),
+										b.Button("id", "commands-btn", "class", "btn-secondary", "title", "Slash commands").T("/"),
+										b.Div("id", "commands-picker", "class", "commands-picker", "style", "display: none;").R(),
 										b.Button("id", "clear-btn", "class", "btn-secondary").T("Clear"),
 									),
 								)
@@ -350,6 +439,18 @@ func generateMainUI(isAuthenticated bool) string {
 					b.Button("id", "load-more-plans", "class", "btn-secondary", "style", "display: none;").T("Load More"),
 				),
 			),
+			// Session Notes Panel: notes kept apart from the session's messages
+			b.Div("id", "notes-panel", "class", "notes-panel").R(
+				b.Div("class", "panel-header").R(
+					b.H3().T("Session Notes"),
+					b.Button("id", "close-notes-btn", "class", "btn-close").T("×"),
+				),
+				b.Div("class", "panel-controls").R(
+					b.TextArea("id", "note-input", "class", "note-text", "rows", "3", "placeholder", "Add a note...").R(),
+					b.Button("id", "add-note-btn", "class", "btn-primary").T("Add Note"),
+				),
+				b.Div("id", "notes-list", "class", "notes-list").R(),
+			),
 			// Plan Details Modal
 			b.Div("id", "plan-details-modal", "class", "modal").R(
 				b.Div("class", "modal-content").R(
@@ -360,6 +461,217 @@ func generateMainUI(isAuthenticated bool) string {
 					b.Div("id", "plan-details-content", "class", "modal-body").R(),
 				),
 			),
+			// Commit Message Modal
+			b.Div("id", "commit-modal", "class", "modal").R(
+				b.Div("class", "modal-content commit-dialog").R(
+					b.Div("class", "modal-header").R(
+						b.H3().T("Commit Staged Changes"),
+						b.Button("class", "btn-close", "onclick", "closeCommitModal()").T("×"),
+					),
+					b.Div("class", "modal-body").R(
+						b.Div("class", "commit-hint-row").R(
+							b.Input("type", "text", "id", "commit-hint", "placeholder", "What is this change for? (optional)"),
+							b.Button("id", "commit-generate", "class", "btn-secondary").T("Generate commit message"),
+						),
+						b.TextArea("id", "commit-message", "rows", "10", "placeholder", "Commit message", "spellcheck", "false").R(),
+						b.Div("id", "commit-status", "class", "commit-status").R(),
+					),
+					b.Div("class", "modal-footer").R(
+						b.Button("class", "btn-secondary", "onclick", "closeCommitModal()").T("Cancel"),
+						b.Button("id", "commit-submit", "class", "btn-primary").T("Commit"),
+					),
+				),
+			),
+			// Code Review Modal
+			b.Div("id", "review-modal", "class", "modal").R(
+				b.Div("class", "modal-content review-dialog").R(
+					b.Div("class", "modal-header").R(
+						b.H3().T("Code Review"),
+						b.Button("class", "btn-close", "onclick", "closeReviewModal()").T("×"),
+					),
+					b.Div("class", "modal-body").R(
+						b.Div("class", "review-target-row").R(
+							b.Input("type", "text", "id", "review-base", "placeholder", "Base (default branch)"),
+							b.Input("type", "text", "id", "review-head", "placeholder", "Head (HEAD)"),
+							b.Label("class", "review-staged").R(
+								b.Input("type", "checkbox", "id", "review-staged"),
+								b.T(" Staged changes"),
+							),
+							b.Button("id", "review-start", "class", "btn-primary").T("Start review"),
+						),
+						b.Div("id", "review-status", "class", "review-status").R(),
+						b.Div("class", "review-layout").R(
+							b.Div("id", "review-list", "class", "review-list").R(),
+							b.Div("id", "review-findings", "class", "review-findings").R(
+								b.Div("class", "review-empty").T("Select a review to see its findings."),
+							),
+						),
+					),
+				),
+			),
+			// Settings Modal
+			b.Div("id", "settings-modal", "class", "modal").R(
+				b.Div("class", "modal-content settings-dialog").R(
+					b.Div("class", "modal-header").R(
+						b.H3().T("Settings"),
+						b.Button("class", "btn-close", "onclick", "closeSettingsModal()").T("×"),
+					),
+					b.Div("class", "modal-body").R(
+						b.H4().T("General"),
+						b.Div("class", "settings-row").R(
+							b.Label("for", "settings-default-model").T("Default model"),
+							b.Select("id", "settings-default-model").R(),
+						),
+						b.Div("class", "settings-row").R(
+							b.Label("for", "settings-theme").T("Theme"),
+							b.Select("id", "settings-theme").R(
+								b.Option("value", "dark").T("Dark"),
+								b.Option("value", "light").T("Light"),
+							),
+						),
+						b.H4().T("New sessions"),
+						b.Div("class", "settings-row").R(
+							b.Label("for", "settings-auto-compact").T("Auto-compact"),
+							b.Input("type", "checkbox", "id", "settings-auto-compact"),
+						),
+						b.Div("class", "settings-row").R(
+							b.Label("for", "settings-compact-threshold").T("Compact after (tokens)"),
+							b.Input("type", "number", "id", "settings-compact-threshold", "min", "1000", "step", "1000"),
+						),
+						b.Div("class", "settings-row").R(
+							b.Label("for", "settings-session-recaps", "title", "Recap a session's key changes, open TODOs and decisions when you start a new one, and offer the recap to it").T("Recap the last session"),
+							b.Input("type", "checkbox", "id", "settings-session-recaps"),
+						),
+						b.Div("class", "settings-row").R(
+							b.Label().T("Tool permissions"),
+							b.Div("class", "settings-permissions").R(
+								b.Div("id", "settings-permissions").R(),
+								b.Button("id", "settings-copy-permissions", "class", "btn-secondary", "title", "Use the tool permissions of the current session").T("Copy from this session"),
+								b.Button("id", "settings-clear-permissions", "class", "btn-secondary").T("Clear"),
+							),
+						),
+						b.H4().T("Permission rules"),
+						b.Div("class", "settings-row").R(
+							b.Label("for", "settings-permission-rules", "title", "Decide tool calls by their arguments, in every session; a rule that denies wins").T("Rules (JSON)"),
+							b.TextArea("id", "settings-permission-rules", "class", "settings-rules", "rows", "5", "spellcheck", "false",
+								"placeholder", `[{"tool": "bash", "argument": "command", "regex": "\\bsudo\\b", "action": "denied"}]`).R(),
+						),
+						b.H4().T("Editor"),
+						b.Div("class", "settings-row").R(
+							b.Label("for", "settings-font-size").T("Font size"),
+							b.Input("type", "number", "id", "settings-font-size", "min", "8", "max", "32"),
+						),
+						b.Div("class", "settings-row").R(
+							b.Label("for", "settings-tab-size").T("Tab size"),
+							b.Input("type", "number", "id", "settings-tab-size", "min", "1", "max", "8"),
+						),
+						b.Div("class", "settings-row").R(
+							b.Label("for", "settings-word-wrap").T("Word wrap"),
+							b.Input("type", "checkbox", "id", "settings-word-wrap"),
+						),
+						b.Div("class", "settings-row").R(
+							b.Label("for", "settings-minimap").T("Minimap"),
+							b.Input("type", "checkbox", "id", "settings-minimap"),
+						),
+						b.Div("id", "settings-status", "class", "commit-status").R(),
+					),
+					b.Div("class", "modal-footer").R(
+						b.Button("class", "btn-secondary", "onclick", "closeSettingsModal()").T("Close"),
+						b.Button("id", "settings-save", "class", "btn-primary").T("Save"),
+					),
+				),
+			),
+			// Claude Accounts Modal (admins in multi-user mode)
+			func() any {
+				if user != nil && !isAdmin {
+					return nil
+				}
+				b.Div("id", "accounts-modal", "class", "modal").R(
+					b.Div("class", "modal-content settings-dialog").R(
+						b.Div("class", "modal-header").R(
+							b.H3().T("Claude Accounts"),
+							b.Button("class", "btn-close", "onclick", "closeAccountsModal()").T("×"),
+						),
+						b.Div("class", "modal-body").R(
+							b.Div("id", "accounts-list", "class", "users-list").R(),
+							b.H4().T("Log in to another account"),
+							b.Div("class", "settings-row").R(
+								b.Label("for", "new-account-name").T("Name"),
+								b.Input("type", "text", "id", "new-account-name", "autocomplete", "off", "placeholder", "e.g. work"),
+							),
+							b.Div("id", "accounts-status", "class", "commit-status").R(),
+						),
+						b.Div("class", "modal-footer").R(
+							b.Button("class", "btn-secondary", "onclick", "closeAccountsModal()").T("Close"),
+							b.Button("id", "accounts-add", "class", "btn-primary").T("Log In"),
+						),
+					),
+				)
+				return nil
+			}(),
+			// User Accounts Modal (admins in multi-user mode)
+			func() any {
+				if user == nil || !isAdmin {
+					return nil
+				}
+				b.Div("id", "users-modal", "class", "modal").R(
+					b.Div("class", "modal-content settings-dialog").R(
+						b.Div("class", "modal-header").R(
+							b.H3().T("Users"),
+							b.Button("class", "btn-close", "onclick", "closeUsersModal()").T("×"),
+						),
+						b.Div("class", "modal-body").R(
+							b.Div("id", "users-list", "class", "users-list").R(),
+							b.H4().T("Add a user"),
+							b.Div("class", "settings-row").R(
+								b.Label("for", "new-user-name").T("Username"),
+								b.Input("type", "text", "id", "new-user-name", "autocomplete", "off"),
+							),
+							b.Div("class", "settings-row").R(
+								b.Label("for", "new-user-password").T("Password"),
+								b.Input("type", "password", "id", "new-user-password", "autocomplete", "new-password"),
+							),
+							b.Div("class", "settings-row").R(
+								b.Label("for", "new-user-admin").T("Admin"),
+								b.Input("type", "checkbox", "id", "new-user-admin"),
+							),
+							b.Div("id", "users-status", "class", "commit-status").R(),
+						),
+						b.Div("class", "modal-footer").R(
+							b.Button("class", "btn-secondary", "onclick", "closeUsersModal()").T("Close"),
+							b.Button("id", "users-add", "class", "btn-primary").T("Add User"),
+						),
+					),
+				)
+				return nil
+			}(),
+			// Background Processes Modal
+			b.Div("id", "processes-modal", "class", "modal").R(
+				b.Div("class", "modal-content review-dialog").R(
+					b.Div("class", "modal-header").R(
+						b.H3().T("Background Processes"),
+						b.Button("class", "btn-close", "onclick", "closeProcessesModal()").T("×"),
+					),
+					b.Div("class", "modal-body").R(
+						b.Div("class", "review-layout").R(
+							b.Div("id", "processes-list", "class", "review-list").R(),
+							b.Div("id", "process-details", "class", "review-findings").R(
+								b.Div("class", "review-empty").T("Select a process to see its output and preview."),
+							),
+						),
+					),
+				),
+			),
+			// Terminal Panel (docked at the bottom, hidden by default)
+			b.Div("id", "terminal-panel", "class", "terminal-panel").R(
+				b.Div("class", "terminal-panel-header").R(
+					b.Span("class", "terminal-panel-title").T("Terminal"),
+					b.Div("id", "terminal-tabs", "class", "terminal-tabs").R(),
+					b.Button("id", "terminal-new", "class", "btn-secondary", "title", "New terminal").T("+"),
+					b.Button("class", "btn-close", "onclick", "closeTerminalPanel()", "title", "Hide (terminals keep running)").T("×"),
+				),
+				b.Div("id", "terminal-body", "class", "terminal-body").R(),
+			),
 			// Diff Viewer Modal
 			b.Div("id", "diff-modal", "class", "modal").R(
 				b.Div("class", "modal-content diff-viewer-content").R(
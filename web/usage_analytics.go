@@ -0,0 +1,156 @@
+package web
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/rohanthewiz/rweb"
+	"github.com/rohanthewiz/serr"
+	"rcode/db"
+)
+
+// UsageModelStats is the usage of a group with one model
+type UsageModelStats struct {
+	Model        string  `json:"model"`
+	InputTokens  int     `json:"inputTokens"`
+	OutputTokens int     `json:"outputTokens"`
+	Cost         float64 `json:"cost"`
+	Messages     int     `json:"messages"`
+	Fallbacks    int     `json:"fallbacks"`
+}
+
+// UsageStats is the usage of a day, model, session or tool, or the total of them
+type UsageStats struct {
+	Key          string            `json:"key,omitempty"`
+	Title        string            `json:"title,omitempty"` // The session's, when grouped by session
+	InputTokens  int               `json:"inputTokens"`
+	OutputTokens int               `json:"outputTokens"`
+	TotalTokens  int               `json:"totalTokens"`
+	Cost         float64           `json:"cost"`
+	Messages     int               `json:"messages"`
+	Fallbacks    int               `json:"fallbacks"`
+	ByModel      []UsageModelStats `json:"byModel,omitempty"`
+}
+
+// add counts a model's usage toward the stats
+func (s *UsageStats) add(g db.UsageGroup) {
+	cost := usageCost(g.Model, g.Input, g.Output)
+	s.InputTokens += g.Input
+	s.OutputTokens += g.Output
+	s.TotalTokens += g.Input + g.Output
+	s.Cost += cost
+	s.Messages += g.Messages
+	s.Fallbacks += g.Fallbacks
+	s.ByModel = append(s.ByModel, UsageModelStats{
+		Model: g.Model, InputTokens: g.Input, OutputTokens: g.Output,
+		Cost: cost, Messages: g.Messages, Fallbacks: g.Fallbacks,
+	})
+}
+
+// UsageAnalytics is the usage over a range, grouped by a dimension
+type UsageAnalytics struct {
+	By     string       `json:"by"`
+	Since  string       `json:"since,omitempty"`
+	Until  string       `json:"until,omitempty"`
+	Total  UsageStats   `json:"total"`
+	Groups []UsageStats `json:"groups"`
+}
+
+// usageAnalytics groups the usage the request's since and until select by a dimension.
+// Days are in date order, and the rest most expensive first.
+func usageAnalytics(c rweb.Context, by string) (*UsageAnalytics, int, error) {
+	req := c.Request()
+	filter := db.UsageFilter{User: currentUser(c)}
+	var err error
+	if filter.Since, err = parseAuditTime(req.QueryParam("since"), false); err != nil {
+		return nil, 400, err
+	}
+	if filter.Until, err = parseAuditTime(req.QueryParam("until"), true); err != nil {
+		return nil, 400, err
+	}
+	switch by {
+	case db.UsageByDay, db.UsageByModel, db.UsageBySession, db.UsageByTool:
+	default:
+		return nil, 400, serr.New("by must be day, model, session or tool")
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return nil, 500, serr.Wrap(err, "failed to get database")
+	}
+	groups, err := database.GetUsageGroups(by, filter)
+	if err != nil {
+		return nil, 500, err
+	}
+
+	analytics := &UsageAnalytics{By: by, Since: req.QueryParam("since"), Until: req.QueryParam("until"), Groups: []UsageStats{}}
+	index := make(map[string]int)
+	for _, g := range groups {
+		i, ok := index[g.Key]
+		if !ok {
+			i = len(analytics.Groups)
+			index[g.Key] = i
+			analytics.Groups = append(analytics.Groups, UsageStats{Key: g.Key, Title: g.Title})
+		}
+		analytics.Groups[i].add(g)
+		if by != db.UsageByTool {
+			analytics.Total.add(g)
+		}
+	}
+	analytics.Total.ByModel = nil
+
+	if by == db.UsageByTool {
+		// A reply calling several tools counts toward each, so the total is the replies'
+		totalGroups, err := database.GetUsageGroups(db.UsageByModel, filter)
+		if err != nil {
+			return nil, 500, err
+		}
+		for _, g := range totalGroups {
+			analytics.Total.add(g)
+		}
+		analytics.Total.ByModel = nil
+	}
+
+	if by == db.UsageByDay {
+		sort.SliceStable(analytics.Groups, func(i, j int) bool { return analytics.Groups[i].Key < analytics.Groups[j].Key })
+	} else {
+		sort.SliceStable(analytics.Groups, func(i, j int) bool { return analytics.Groups[i].Cost > analytics.Groups[j].Cost })
+	}
+	return analytics, 200, nil
+}
+
+// usageAnalyticsHandler returns token usage and cost grouped by day, model, session or
+// tool (the by query parameter, day by default), between the since and until parameters
+func usageAnalyticsHandler(c rweb.Context) error {
+	by := c.Request().QueryParam("by")
+	if by == "" {
+		by = db.UsageByDay
+	}
+	analytics, code, err := usageAnalytics(c, by)
+	if err != nil {
+		return c.WriteError(err, code)
+	}
+	return c.WriteJSON(analytics)
+}
+
+// topSessionsHandler returns the most expensive sessions between the since and until query
+// parameters; limit says how many, 10 by default
+func topSessionsHandler(c rweb.Context) error {
+	limit := 10
+	if value := c.Request().QueryParam("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return c.WriteError(serr.New("limit must be a positive number"), 400)
+		}
+		limit = n
+	}
+
+	analytics, code, err := usageAnalytics(c, db.UsageBySession)
+	if err != nil {
+		return c.WriteError(err, code)
+	}
+	if len(analytics.Groups) > limit {
+		analytics.Groups = analytics.Groups[:limit]
+	}
+	return c.WriteJSON(analytics)
+}
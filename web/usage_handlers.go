@@ -7,8 +7,14 @@ import (
 	"github.com/rohanthewiz/rweb"
 	"github.com/rohanthewiz/serr"
 	"rcode/db"
+	"rcode/providers"
 )
 
+// InitRequestScheduler tells sessions' pages when their model requests wait for the rate limits
+func InitRequestScheduler() {
+	providers.AnthropicScheduler().OnWait(BroadcastRateLimitWait)
+}
+
 // GetSessionUsageHandler returns usage statistics for a session
 func GetSessionUsageHandler(c rweb.Context) error {
 	sessionID := c.Request().Param("id")
@@ -68,31 +74,15 @@ func GetDailyUsageHandler(c rweb.Context) error {
 	totalInput := 0
 	totalOutput := 0
 	totalCost := 0.0
+	totalFallbacks := 0
 
 	modelStats := make([]map[string]interface{}, 0)
 	for model, usage := range usageByModel {
 		totalInput += usage.Input
 		totalOutput += usage.Output
+		totalFallbacks += usage.Fallbacks
 
-		// Calculate cost based on model
-		var inputRate, outputRate float64
-		switch {
-		case contains(model, "opus"):
-			inputRate = 0.000015
-			outputRate = 0.000075
-		case contains(model, "sonnet"):
-			inputRate = 0.000003
-			outputRate = 0.000015
-		case contains(model, "haiku"):
-			inputRate = 0.00000025
-			outputRate = 0.00000125
-		default:
-			// Default to Sonnet pricing
-			inputRate = 0.000003
-			outputRate = 0.000015
-		}
-
-		modelCost := float64(usage.Input)*inputRate + float64(usage.Output)*outputRate
+		modelCost := usageCost(model, usage.Input, usage.Output)
 		totalCost += modelCost
 
 		modelStats = append(modelStats, map[string]interface{}{
@@ -101,6 +91,7 @@ func GetDailyUsageHandler(c rweb.Context) error {
 			"outputTokens": usage.Output,
 			"totalTokens":  usage.Input + usage.Output,
 			"cost":         modelCost,
+			"fallbacks":    usage.Fallbacks,
 		})
 	}
 
@@ -110,10 +101,22 @@ func GetDailyUsageHandler(c rweb.Context) error {
 			"totalOutputTokens": totalOutput,
 			"totalTokens":       totalInput + totalOutput,
 			"totalCost":         totalCost,
+			"totalFallbacks":    totalFallbacks,
 			"byModel":           modelStats,
 		},
 	}
 
+	// Tokens used through the messages proxy, which has its own budget
+	proxyUsed, proxyBudget, err := proxyBudgetUsed(database)
+	if err != nil {
+		logger.LogErr(err, "failed to get proxy usage")
+	} else {
+		response["proxy"] = map[string]interface{}{
+			"totalTokens": proxyUsed,
+			"budget":      proxyBudget,
+		}
+	}
+
 	return c.WriteJSON(response)
 }
 
@@ -134,31 +137,15 @@ func GetGlobalUsageHandler(c rweb.Context) error {
 	totalInput := 0
 	totalOutput := 0
 	totalCost := 0.0
+	totalFallbacks := 0
 
 	modelStats := make([]map[string]interface{}, 0)
 	for model, usage := range usageByModel {
 		totalInput += usage.Input
 		totalOutput += usage.Output
+		totalFallbacks += usage.Fallbacks
 
-		// Calculate cost based on model
-		var inputRate, outputRate float64
-		switch {
-		case contains(model, "opus"):
-			inputRate = 0.000015
-			outputRate = 0.000075
-		case contains(model, "sonnet"):
-			inputRate = 0.000003
-			outputRate = 0.000015
-		case contains(model, "haiku"):
-			inputRate = 0.00000025
-			outputRate = 0.00000125
-		default:
-			// Default to Sonnet pricing
-			inputRate = 0.000003
-			outputRate = 0.000015
-		}
-
-		modelCost := float64(usage.Input)*inputRate + float64(usage.Output)*outputRate
+		modelCost := usageCost(model, usage.Input, usage.Output)
 		totalCost += modelCost
 
 		modelStats = append(modelStats, map[string]interface{}{
@@ -167,6 +154,7 @@ func GetGlobalUsageHandler(c rweb.Context) error {
 			"outputTokens": usage.Output,
 			"totalTokens":  usage.Input + usage.Output,
 			"cost":         modelCost,
+			"fallbacks":    usage.Fallbacks,
 		})
 	}
 
@@ -203,6 +191,7 @@ func GetGlobalUsageHandler(c rweb.Context) error {
 			"totalOutputTokens": totalOutput,
 			"totalTokens":       totalInput + totalOutput,
 			"totalCost":         totalCost,
+			"totalFallbacks":    totalFallbacks,
 			"byModel":           modelStats,
 		},
 		"rateLimits": rateLimits,
@@ -214,6 +203,29 @@ func GetGlobalUsageHandler(c rweb.Context) error {
 	return c.WriteJSON(response)
 }
 
+// usageCost returns what tokens of a model cost, in dollars; nothing for a local model
+func usageCost(model string, input, output int) float64 {
+	var inputRate, outputRate float64
+	switch {
+	case providers.IsLocalModel(model):
+		return 0
+	case contains(model, "opus"):
+		inputRate = 0.000015
+		outputRate = 0.000075
+	case contains(model, "sonnet"):
+		inputRate = 0.000003
+		outputRate = 0.000015
+	case contains(model, "haiku"):
+		inputRate = 0.00000025
+		outputRate = 0.00000125
+	default:
+		// Default to Sonnet pricing
+		inputRate = 0.000003
+		outputRate = 0.000015
+	}
+	return float64(input)*inputRate + float64(output)*outputRate
+}
+
 // Helper function to check if string contains substring
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && s[:len(substr)] == substr ||
@@ -0,0 +1,196 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"rcode/config"
+	"rcode/db"
+
+	"github.com/rohanthewiz/logger"
+	"github.com/rohanthewiz/rweb"
+	"github.com/rohanthewiz/serr"
+)
+
+// LoginRequest is the body of the login and setup endpoints
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// CreateUserRequest is the body of the endpoint that adds a user
+type CreateUserRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	IsAdmin  bool   `json:"is_admin"`
+}
+
+// loginHandler signs a user in with their username and password
+func loginHandler(c rweb.Context) error {
+	var req LoginRequest
+	if err := json.Unmarshal(c.Request().Body(), &req); err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid request body"), 400)
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	user, err := database.AuthenticateUser(req.Username, req.Password)
+	if errors.Is(err, db.ErrInvalidLogin) {
+		logger.Warn("Failed sign-in", "username", req.Username)
+		return c.WriteError(err, http.StatusUnauthorized)
+	}
+	if err != nil {
+		return c.WriteError(err, 500)
+	}
+
+	return signIn(c, database, user)
+}
+
+// setupHandler creates the first account, an admin, and signs it in. It is refused once
+// there is an account; admins add the others.
+func setupHandler(c rweb.Context) error {
+	var req LoginRequest
+	if err := json.Unmarshal(c.Request().Body(), &req); err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid request body"), 400)
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	count, err := database.CountUsers()
+	if err != nil {
+		return c.WriteError(err, 500)
+	}
+	if count > 0 {
+		return c.WriteError(serr.New("setup is done; ask an admin for an account"), http.StatusForbidden)
+	}
+
+	user, err := database.CreateUser(req.Username, req.Password, true)
+	if err != nil {
+		return c.WriteError(err, 400)
+	}
+	logger.Info("Created the first admin", "username", user.Username)
+
+	return signIn(c, database, user)
+}
+
+// signIn starts a login for the user and gives the browser its cookie
+func signIn(c rweb.Context, database *db.DB, user *db.User) error {
+	duration := time.Duration(config.Get().LoginHours) * time.Hour
+	token, _, err := database.CreateLogin(user.ID, duration)
+	if err != nil {
+		return c.WriteError(err, 500)
+	}
+
+	setLoginCookie(c, token, int(duration.Seconds()))
+	logger.Info("User signed in", "username", user.Username)
+	return c.WriteJSON(user)
+}
+
+// logoutHandler signs the browser out of rcode. Disconnecting Claude is /api/auth/logout.
+func logoutHandler(c rweb.Context) error {
+	if token := loginToken(c); token != "" {
+		database, err := db.GetDB()
+		if err != nil {
+			return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+		}
+		if err := database.DeleteLogin(token); err != nil {
+			return c.WriteError(err, 500)
+		}
+	}
+
+	setLoginCookie(c, "", -1)
+	return c.WriteJSON(map[string]interface{}{
+		"success": true,
+	})
+}
+
+// setLoginCookie sets the login cookie, or removes it when maxAge is negative. It can't be
+// read by scripts, and isn't sent with requests from other sites.
+func setLoginCookie(c rweb.Context, token string, maxAge int) {
+	cookie := &http.Cookie{
+		Name:     loginCookieName,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   config.Get().TLSEnabled,
+		SameSite: http.SameSiteLaxMode,
+	}
+	c.Response().SetHeader("Set-Cookie", cookie.String())
+}
+
+// currentUserHandler returns the signed-in user
+func currentUserHandler(c rweb.Context) error {
+	return c.WriteJSON(currentUser(c))
+}
+
+// listUsersHandler returns the accounts
+func listUsersHandler(c rweb.Context) error {
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	users, err := database.ListUsers()
+	if err != nil {
+		return c.WriteError(err, 500)
+	}
+	return c.WriteJSON(users)
+}
+
+// createUserHandler adds an account
+func createUserHandler(c rweb.Context) error {
+	var req CreateUserRequest
+	if err := json.Unmarshal(c.Request().Body(), &req); err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid request body"), 400)
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	user, err := database.CreateUser(req.Username, req.Password, req.IsAdmin)
+	if err != nil {
+		return c.WriteError(err, 400)
+	}
+
+	logger.Info("Created user", "username", user.Username, "admin", fmt.Sprintf("%t", user.IsAdmin), "by", currentUser(c).Username)
+	return c.WriteJSON(user)
+}
+
+// deleteUserHandler removes an account and signs it out everywhere
+func deleteUserHandler(c rweb.Context) error {
+	id, err := strconv.Atoi(c.Request().Param("id"))
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "invalid user ID"), 400)
+	}
+	if id == currentUserID(c) {
+		return c.WriteError(serr.New("you can't delete your own account"), 400)
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+	}
+
+	if err := database.DeleteUser(id); err != nil {
+		return c.WriteError(err, 404)
+	}
+	sessionOwners.Clear() // The user's sessions now belong to the admins
+
+	logger.Info("Deleted user", "id", strconv.Itoa(id), "by", currentUser(c).Username)
+	return c.WriteJSON(map[string]interface{}{
+		"success": true,
+	})
+}
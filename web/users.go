@@ -0,0 +1,213 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"rcode/background"
+	"rcode/db"
+	"rcode/tools"
+
+	"github.com/rohanthewiz/rweb"
+	"github.com/rohanthewiz/serr"
+)
+
+const (
+	loginCookieName = "rcode_login"
+	userContextKey  = "user"
+)
+
+// multiUser is set at startup from the multi_user setting. Turning it on or off takes a restart,
+// as the routes and middleware depend on it.
+var multiUser bool
+
+// Paths reachable without signing in
+var publicPaths = map[string]bool{
+	"/login":      true,
+	"/api/login":  true,
+	"/api/setup":  true,
+	"/api/logout": true,
+}
+
+// Path prefixes only admins can reach: the Claude account, shared by all users, the server's config,
+// the audit log of every user's tool calls, the server's log and its backups
+var adminPrefixes = []string{"/auth/", "/api/auth/", "/api/config", "/api/users", "/api/audit", "/api/logs", "/api/admin/"}
+
+// currentUser returns the signed-in user, or nil in single-user mode
+func currentUser(c rweb.Context) *db.User {
+	user, _ := c.Get(userContextKey).(*db.User)
+	return user
+}
+
+// currentUserID returns the signed-in user's ID, or 0 in single-user mode
+func currentUserID(c rweb.Context) int {
+	if user := currentUser(c); user != nil {
+		return user.ID
+	}
+	return 0
+}
+
+// canAccessSession reports whether the request may reach a session. In single-user mode every session is reachable.
+func canAccessSession(c rweb.Context, session *db.Session) bool {
+	user := currentUser(c)
+	return user == nil || user.CanAccess(session.UserID)
+}
+
+// loginToken returns the login token from the request's cookie
+func loginToken(c rweb.Context) string {
+	cookies, err := http.ParseCookie(c.Request().Header("Cookie"))
+	if err != nil {
+		return ""
+	}
+	for _, cookie := range cookies {
+		if cookie.Name == loginCookieName {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+// requireUser is the middleware of multi-user mode. It signs the request in from its login
+// cookie, unless requireToken did from its API token, sends those without either to the
+// login page, and checks that the user may reach what the request is for, so handlers
+// don't each need to.
+func requireUser(c rweb.Context) error {
+	path := c.Request().Path()
+	if publicPaths[path] || strings.HasPrefix(path, "/static/") {
+		return c.Next()
+	}
+
+	user := currentUser(c) // Signed in by requireToken
+	if token := loginToken(c); user == nil && token != "" {
+		database, err := db.GetDB()
+		if err != nil {
+			return c.WriteError(serr.Wrap(err, "failed to get database"), 500)
+		}
+		if user, err = database.GetLoginUser(token); err != nil {
+			return c.WriteError(err, 500)
+		}
+	}
+	if user == nil {
+		if c.Request().Method() == http.MethodGet && !strings.HasPrefix(path, "/api/") && path != "/events" {
+			return c.Redirect(http.StatusFound, "/login")
+		}
+		return c.WriteError(serr.New("sign in required"), http.StatusUnauthorized)
+	}
+	c.Set(userContextKey, user)
+
+	if status, err := authorize(c, user, path); err != nil {
+		return c.WriteError(err, status)
+	}
+	return c.Next()
+}
+
+// authorize checks that the user may reach the sessions, plans, diffs, terminals, processes
+// and permission requests a request names in its path or JSON body, returning the status to
+// reply with if not. Files, memories, prompts, templates and reviews belong to the project,
+// which all users share.
+func authorize(c rweb.Context, user *db.User, path string) (int, error) {
+	for _, prefix := range adminPrefixes {
+		if strings.HasPrefix(path, prefix) && !user.IsAdmin {
+			return http.StatusForbidden, serr.New("only admins can do this")
+		}
+	}
+
+	database, err := db.GetDB()
+	if err != nil {
+		return 500, serr.Wrap(err, "failed to get database")
+	}
+
+	var owners []func() (int, error)
+	session := func(id string) func() (int, error) {
+		return func() (int, error) { return database.SessionOwner(id) }
+	}
+
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) >= 2 && parts[0] == "preview" {
+		owners = append(owners, func() (int, error) { return processOwner(database, parts[1]) })
+	}
+	if len(parts) >= 3 && parts[0] == "api" {
+		id := parts[2]
+		switch parts[1] {
+		case "session":
+			owners = append(owners, session(id))
+		case "plan":
+			owners = append(owners, func() (int, error) { return database.PlanOwner(id) })
+		case "diff":
+			if diffID, err := strconv.ParseInt(id, 10, 64); err == nil {
+				owners = append(owners, func() (int, error) { return database.DiffOwner(diffID) })
+			} else if id != "snapshot" && id != "generate" && id != "preferences" && id != "apply" {
+				owners = append(owners, session(id)) // /api/diff/:sessionId/:path
+			}
+		case "terminals":
+			owners = append(owners, func() (int, error) {
+				t, err := terminalManager.Get(id)
+				if err != nil {
+					return 0, err
+				}
+				return database.SessionOwner(t.SessionID)
+			})
+		case "processes":
+			owners = append(owners, func() (int, error) { return processOwner(database, id) })
+		case "recaps":
+			if recapID, err := strconv.Atoi(id); err == nil {
+				owners = append(owners, func() (int, error) { return database.RecapOwner(recapID) })
+			}
+		}
+	}
+
+	// Sessions and permission requests named in the body, e.g. by the diff and permission endpoints
+	var body struct {
+		SessionID  string `json:"sessionId"`
+		SessionID2 string `json:"session_id"`
+		RequestID  string `json:"requestId"`
+		RequestID2 string `json:"request_id"`
+	}
+	if raw := c.Request().Body(); len(raw) > 0 && json.Unmarshal(raw, &body) == nil {
+		for _, id := range []string{body.SessionID, body.SessionID2} {
+			if id != "" {
+				owners = append(owners, session(id))
+			}
+		}
+		for _, id := range []string{body.RequestID, body.RequestID2} {
+			if request, ok := permissionManager.GetRequest(id); ok {
+				owners = append(owners, session(request.SessionID))
+			}
+		}
+	}
+
+	for _, owner := range owners {
+		ownerID, err := owner()
+		if err != nil {
+			return http.StatusNotFound, err
+		}
+		// Others' data is reported as missing, so IDs can't be probed
+		if !user.CanAccess(ownerID) {
+			return http.StatusNotFound, serr.New("not found")
+		}
+	}
+	return 0, nil
+}
+
+// processOwner returns the owner of the session that started a background process
+func processOwner(database *db.DB, id string) (int, error) {
+	procID, err := strconv.Atoi(id)
+	if err != nil {
+		return 0, serr.Wrap(err, "invalid process ID")
+	}
+	proc, err := tools.BackgroundProcesses().Get(procID)
+	if err != nil {
+		return 0, err
+	}
+	return processSessionOwner(database, proc)
+}
+
+// processSessionOwner returns the owner of a process's session, or 0 if it was started outside one
+func processSessionOwner(database *db.DB, proc background.Process) (int, error) {
+	if proc.SessionID == "" {
+		return 0, nil
+	}
+	return database.SessionOwner(proc.SessionID)
+}
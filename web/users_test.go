@@ -0,0 +1,327 @@
+package web
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"rcode/db"
+)
+
+// unownedRoutes are the routes authorize lets any signed-in user reach, and why. A new
+// route that names a user's data in its path needs a case in authorize instead.
+var unownedRoutes = map[string]string{
+	"/":                                    "the web UI",
+	"/prompts":                             "the prompt manager UI",
+	"/events":                              "the event hub sends users only their sessions' events",
+	"/metrics":                             "server-wide counters",
+	"/v1/messages":                         "the messages proxy checks its token's scope itself",
+	"/api/app":                             "about the server",
+	"/api/openapi.json":                    "the API's spec",
+	"/api/me":                              "the user themself",
+	"/api/accounts":                        "the shared Claude accounts' names",
+	"/api/commands":                        "the project's slash commands",
+	"/api/tasks":                           "the project's build and run commands",
+	"/api/tokens":                          "the handlers list and create the user's own tokens",
+	"/api/tokens/:id":                      "the handler deletes only the user's own tokens",
+	"/api/settings":                        "the handlers read and write the user's own settings",
+	"/api/session":                         "the handlers list and create the user's own sessions",
+	"/api/search":                          "the handler searches the user's own sessions",
+	"/api/recaps":                          "the handler lists the user's own recaps",
+	"/api/processes":                       "the handler lists the processes of the user's own sessions",
+	"/api/usage/daily":                     "token usage is shared",
+	"/api/usage/global":                    "token usage is shared",
+	"/api/usage/analytics":                 "the handler groups the user's own sessions' usage",
+	"/api/usage/top-sessions":              "the handler groups the user's own sessions' usage",
+	"/api/diff/preferences":                "how diffs are shown",
+	"/api/git/commit-message":              "the project's checkout",
+	"/api/context":                         "the project's context",
+	"/api/context/changes":                 "the project's context",
+	"/api/context/initialize":              "the project's context",
+	"/api/context/relevant-files":          "the project's context",
+	"/api/context/semantic-index/refresh":  "the project's context",
+	"/api/context/stats":                   "the project's context",
+	"/api/context/suggest-tools":           "the project's context",
+	"/api/files":                           "the project's files",
+	"/api/files/clipboard":                 "the project's files",
+	"/api/files/clipboard/clear":           "the project's files",
+	"/api/files/content/*":                 "the project's files",
+	"/api/files/copy":                      "the project's files",
+	"/api/files/create":                    "the project's files",
+	"/api/files/cut":                       "the project's files",
+	"/api/files/cwd":                       "the project's files",
+	"/api/files/delete":                    "the project's files",
+	"/api/files/download/*":                "the project's files",
+	"/api/files/duplicate":                 "the project's files",
+	"/api/files/image/*":                   "the project's files",
+	"/api/files/move":                      "the project's files",
+	"/api/files/paste":                     "the project's files",
+	"/api/files/rename":                    "the project's files",
+	"/api/files/search":                    "the project's files",
+	"/api/files/search/stream":             "the project's files",
+	"/api/files/tree":                      "the project's files",
+	"/api/files/upload":                    "the project's files",
+	"/api/files/zip":                       "the project's files",
+	"/api/memories":                        "the project's memories",
+	"/api/memories/:id":                    "the project's memories",
+	"/api/prompts":                         "the project's prompts",
+	"/api/prompts/:id":                     "the project's prompts",
+	"/api/prompts/export":                  "the project's prompts",
+	"/api/prompts/import":                  "the project's prompts",
+	"/api/prompts/tags":                    "the project's prompts",
+	"/api/system-prompts":                  "the project's prompts",
+	"/api/system-prompts/:id":              "the project's prompts",
+	"/api/templates":                       "the project's task templates",
+	"/api/templates/:id":                   "the project's task templates",
+	"/api/templates/:id/instantiate":       "the project's task templates",
+	"/api/reviews":                         "the project's reviews",
+	"/api/reviews/:id":                     "the project's reviews",
+	"/api/reviews/:id/findings/:findingId": "the project's reviews",
+}
+
+// bodyOwnedRoutes name the session or permission request they act on in their JSON body
+var bodyOwnedRoutes = map[string]bool{
+	"/api/diff/apply":          true,
+	"/api/diff/generate":       true,
+	"/api/diff/snapshot":       true,
+	"/api/permission-abort":    true,
+	"/api/permission-response": true,
+}
+
+// routePaths returns the paths SetupRoutes registers, read from routes.go so that a route
+// added there is checked here too
+func routePaths(t *testing.T) []string {
+	t.Helper()
+	file, err := parser.ParseFile(token.NewFileSet(), "routes.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paths := map[string]bool{}
+	for _, route := range apiRoutes {
+		paths[route.Path] = true
+	}
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Name.Name != "SetupRoutes" {
+			continue
+		}
+		ast.Inspect(fn.Body, func(node ast.Node) bool {
+			var lit *ast.BasicLit
+			switch node := node.(type) {
+			case *ast.CallExpr: // s.Get("/path", handler)
+				sel, ok := node.Fun.(*ast.SelectorExpr)
+				if !ok || len(node.Args) == 0 {
+					return true
+				}
+				if x, ok := sel.X.(*ast.Ident); !ok || x.Name != "s" {
+					return true
+				}
+				lit, _ = node.Args[0].(*ast.BasicLit)
+			case *ast.RangeStmt: // for _, route := range []string{"/path", ...}
+				if list, ok := node.X.(*ast.CompositeLit); ok {
+					for _, elt := range list.Elts {
+						if lit, ok := elt.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+							path, _ := strconv.Unquote(lit.Value)
+							paths[path] = true
+						}
+					}
+				}
+			}
+			if lit != nil && lit.Kind == token.STRING {
+				path, _ := strconv.Unquote(lit.Value)
+				paths[path] = true
+			}
+			return true
+		})
+	}
+
+	list := make([]string, 0, len(paths))
+	for path := range paths {
+		list = append(list, path)
+	}
+	sort.Strings(list)
+	if len(list) < 100 {
+		t.Fatalf("found only %d routes in routes.go", len(list))
+	}
+	return list
+}
+
+var routeParam = regexp.MustCompile(`:\w+|\*\w*`)
+
+// TestRoutesAuthorized checks that authorize stops a user reaching each route for
+// something that isn't theirs, unless the route is for admins, public or listed as unowned
+func TestRoutesAuthorized(t *testing.T) {
+	user := newTestUser(t, false)
+	for _, route := range routePaths(t) {
+		// An ID that names nothing, as another user's data is reported as missing
+		path := routeParam.ReplaceAllString(route, "404404")
+
+		var want int
+		var body string
+		switch {
+		case publicPaths[route] || strings.HasPrefix(route, "/static/"):
+			continue // Reached without signing in, see TestRequireUser
+		case isAdminPath(route):
+			want = http.StatusForbidden
+		case bodyOwnedRoutes[route]:
+			body, want = `{"sessionId": "404404"}`, http.StatusNotFound
+		case unownedRoutes[route] != "":
+			want = 0
+		default:
+			want = http.StatusNotFound
+		}
+
+		status, _ := authorize(newTestContext(http.MethodGet, path, body), user, path)
+		if status != want {
+			if want == http.StatusNotFound {
+				t.Errorf("%s: authorize returned %d; check its owner in authorize or list it in unownedRoutes", route, status)
+			} else {
+				t.Errorf("%s: authorize returned %d, want %d", route, status, want)
+			}
+		}
+	}
+
+	// An unowned route's body still names sessions that must be the user's
+	other := newTestSession(t, newTestUser(t, false).ID)
+	c := newTestContext(http.MethodPost, "/api/files/create", `{"sessionId": "`+other.ID+`"}`)
+	if status, _ := authorize(c, user, "/api/files/create"); status != http.StatusNotFound {
+		t.Errorf("another user's session in the body: authorize returned %d, want 404", status)
+	}
+}
+
+// TestUnownedRoutesExist keeps unownedRoutes and bodyOwnedRoutes to routes that are registered
+func TestUnownedRoutesExist(t *testing.T) {
+	registered := map[string]bool{}
+	for _, path := range routePaths(t) {
+		registered[path] = true
+	}
+	for route := range unownedRoutes {
+		if !registered[route] {
+			t.Errorf("unownedRoutes lists %s, which SetupRoutes doesn't register", route)
+		}
+	}
+	for route := range bodyOwnedRoutes {
+		if !registered[route] {
+			t.Errorf("bodyOwnedRoutes lists %s, which SetupRoutes doesn't register", route)
+		}
+	}
+}
+
+func isAdminPath(path string) bool {
+	for _, prefix := range adminPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestAuthorize(t *testing.T) {
+	alice := newTestUser(t, false)
+	bob := newTestUser(t, false)
+	admin := newTestUser(t, true)
+	alices := newTestSession(t, alice.ID)
+	unowned := newTestSession(t, 0) // From before multi-user mode
+
+	tests := []struct {
+		name string
+		user *db.User
+		path string
+		body string
+		want int
+	}{
+		{"own session", alice, "/api/session/" + alices.ID + "/messages", "", 0},
+		{"another's session", bob, "/api/session/" + alices.ID + "/messages", "", http.StatusNotFound},
+		{"admin, another's session", admin, "/api/session/" + alices.ID + "/messages", "", http.StatusNotFound},
+		{"admin, unowned session", admin, "/api/session/" + unowned.ID, "", 0},
+		{"unowned session", alice, "/api/session/" + unowned.ID, "", http.StatusNotFound},
+		{"missing session", alice, "/api/session/session-0/messages", "", http.StatusNotFound},
+		{"own session in the body", alice, "/api/diff/snapshot", `{"sessionId": "` + alices.ID + `"}`, 0},
+		{"another's session in the body", bob, "/api/diff/snapshot", `{"session_id": "` + alices.ID + `"}`, http.StatusNotFound},
+		{"body that isn't JSON", alice, "/api/diff/snapshot", "sessionId=" + alices.ID, 0},
+		{"admin path", alice, "/api/users", "", http.StatusForbidden},
+		{"admin path as admin", admin, "/api/users", "", 0},
+		{"admin prefix", alice, "/api/admin/backup", "", http.StatusForbidden},
+		{"shared path", bob, "/api/prompts", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, err := authorize(newTestContext(http.MethodGet, tt.path, tt.body), tt.user, tt.path)
+			if status != tt.want {
+				t.Errorf("authorize(%s) = %d (%v), want %d", tt.path, status, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireUser(t *testing.T) {
+	alice := newTestUser(t, false)
+	bob := newTestUser(t, false)
+	alices := newTestSession(t, alice.ID)
+	login, _, err := testDB(t).CreateLogin(alice.ID, time.Hour)
+	if err != nil {
+		t.Fatalf("CreateLogin: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		cookie   string
+		tokenFor *db.User // Signed in by requireToken
+		want     int      // 0 when the request is passed on
+		redirect string
+	}{
+		{"public path", http.MethodPost, "/api/login", "", nil, 0, ""},
+		{"static file", http.MethodGet, "/static/css/base.css", "", nil, 0, ""},
+		{"page without a login", http.MethodGet, "/", "", nil, http.StatusFound, "/login"},
+		{"API without a login", http.MethodGet, "/api/session", "", nil, http.StatusUnauthorized, ""},
+		{"events without a login", http.MethodGet, "/events", "", nil, http.StatusUnauthorized, ""},
+		{"unknown login", http.MethodGet, "/api/session", loginCookieName + "=nope", nil, http.StatusUnauthorized, ""},
+		{"login cookie", http.MethodGet, "/api/session/" + alices.ID, "theme=dark; " + loginCookieName + "=" + login, nil, 0, ""},
+		{"API token", http.MethodGet, "/api/session/" + alices.ID, "", alice, 0, ""},
+		{"another's session", http.MethodGet, "/api/session/" + alices.ID, "", bob, http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestContext(tt.method, tt.path, "")
+			if tt.cookie != "" {
+				c.request.headers["Cookie"] = tt.cookie
+			}
+			if tt.tokenFor != nil {
+				c.Set(userContextKey, tt.tokenFor)
+			}
+
+			if err := requireUser(c); err != nil {
+				t.Fatalf("requireUser: %v", err)
+			}
+			if tt.want == 0 {
+				if !c.nextCalled {
+					t.Errorf("the request wasn't passed on: %d %v", c.status, c.err)
+				}
+				return
+			}
+			if c.nextCalled || c.status != tt.want || c.redirect != tt.redirect {
+				t.Errorf("status %d, redirect %q, passed on %v; want %d, %q", c.status, c.redirect, c.nextCalled, tt.want, tt.redirect)
+			}
+		})
+	}
+
+	// The login signs the request in as its user
+	c := newTestContext(http.MethodGet, "/api/me", "")
+	c.request.headers["Cookie"] = loginCookieName + "=" + login
+	if err := requireUser(c); err != nil {
+		t.Fatalf("requireUser: %v", err)
+	}
+	if user := currentUser(c); user == nil || user.ID != alice.ID {
+		t.Errorf("currentUser = %+v, want %s", user, alice.Username)
+	}
+}
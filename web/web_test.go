@@ -0,0 +1,115 @@
+package web
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"rcode/db"
+
+	"github.com/rohanthewiz/rweb"
+)
+
+// TestMain keeps the tests' database and config out of the user's home
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "rcode-web-test")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Setenv("HOME", dir)
+	os.Setenv("RCODE_DB_URL", filepath.Join(dir, "rcode.db"))
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// testContext is the part of rweb.Context the middleware and authorize use. It records
+// what the handler replied instead of writing it.
+type testContext struct {
+	rweb.Context
+	request *testRequest
+	data    map[string]any
+
+	nextCalled bool
+	status     int
+	err        error
+	redirect   string
+}
+
+func newTestContext(method, path, body string) *testContext {
+	return &testContext{
+		request: &testRequest{method: method, path: path, body: []byte(body), headers: map[string]string{}},
+		data:    map[string]any{},
+	}
+}
+
+func (c *testContext) Request() rweb.ItfRequest { return c.request }
+func (c *testContext) Get(key string) any       { return c.data[key] }
+func (c *testContext) Set(key string, value any) {
+	c.data[key] = value
+}
+
+func (c *testContext) Next() error {
+	c.nextCalled = true
+	return nil
+}
+
+func (c *testContext) WriteError(err error, status int) error {
+	c.err, c.status = err, status
+	return nil
+}
+
+func (c *testContext) Redirect(status int, url string) error {
+	c.status, c.redirect = status, url
+	return nil
+}
+
+type testRequest struct {
+	rweb.ItfRequest
+	method, path string
+	body         []byte
+	headers      map[string]string
+}
+
+func (r *testRequest) Method() string            { return r.method }
+func (r *testRequest) Path() string              { return r.path }
+func (r *testRequest) Body() []byte              { return r.body }
+func (r *testRequest) Header(name string) string { return r.headers[name] }
+
+var testUsers atomic.Int64
+
+// newTestUser adds a user to the tests' database
+func newTestUser(t *testing.T, isAdmin bool) *db.User {
+	t.Helper()
+	database := testDB(t)
+	name := fmt.Sprintf("user%d", testUsers.Add(1))
+	user, err := database.CreateUser(name, "password123", isAdmin)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	return user
+}
+
+// newTestSession adds a session owned by userID, 0 for none
+func newTestSession(t *testing.T, userID int) *db.Session {
+	t.Helper()
+	session, err := testDB(t).CreateSession(db.SessionOptions{Title: strings.ReplaceAll(t.Name(), "/", " "), UserID: userID})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	return session
+}
+
+func testDB(t *testing.T) *db.DB {
+	t.Helper()
+	database, err := db.GetDB()
+	if err != nil {
+		t.Fatalf("GetDB: %v", err)
+	}
+	return database
+}
@@ -2,7 +2,6 @@ package web
 
 import (
 	"archive/zip"
-	"bufio"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -11,6 +10,8 @@ import (
 	"strings"
 	"time"
 
+	"rcode/ignore"
+
 	"github.com/rohanthewiz/logger"
 	"github.com/rohanthewiz/rweb"
 	"github.com/rohanthewiz/serr"
@@ -24,135 +25,6 @@ type ZipRequest struct {
 	UseGitignore    bool     `json:"useGitignore"`    // Respect .gitignore rules
 }
 
-// GitignoreParser handles .gitignore pattern matching
-type GitignoreParser struct {
-	patterns []gitignorePattern
-	root     string
-}
-
-type gitignorePattern struct {
-	pattern  string
-	isNegate bool
-	isDir    bool
-}
-
-// NewGitignoreParser creates a parser from .gitignore file
-func NewGitignoreParser(root string) (*GitignoreParser, error) {
-	parser := &GitignoreParser{
-		patterns: []gitignorePattern{},
-		root:     root,
-	}
-
-	gitignorePath := filepath.Join(root, ".gitignore")
-	file, err := os.Open(gitignorePath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			// No .gitignore file, return empty parser
-			return parser, nil
-		}
-		return nil, serr.Wrap(err, "failed to open .gitignore")
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-
-		// Skip empty lines and comments
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-
-		pattern := gitignorePattern{
-			pattern: line,
-		}
-
-		// Check for negation
-		if strings.HasPrefix(line, "!") {
-			pattern.isNegate = true
-			pattern.pattern = line[1:]
-		}
-
-		// Check if pattern is directory-specific
-		if strings.HasSuffix(pattern.pattern, "/") {
-			pattern.isDir = true
-			pattern.pattern = strings.TrimSuffix(pattern.pattern, "/")
-		}
-
-		parser.patterns = append(parser.patterns, pattern)
-	}
-
-	return parser, scanner.Err()
-}
-
-// ShouldIgnore checks if a path should be ignored based on gitignore rules
-func (g *GitignoreParser) ShouldIgnore(path string) bool {
-	// Convert to relative path from root
-	relPath, err := filepath.Rel(g.root, path)
-	if err != nil {
-		return false
-	}
-
-	// Normalize path separators
-	relPath = filepath.ToSlash(relPath)
-
-	ignored := false
-	for _, pattern := range g.patterns {
-		if g.matchPattern(relPath, pattern) {
-			if pattern.isNegate {
-				ignored = false
-			} else {
-				ignored = true
-			}
-		}
-	}
-
-	return ignored
-}
-
-// matchPattern checks if a path matches a gitignore pattern
-func (g *GitignoreParser) matchPattern(path string, pattern gitignorePattern) bool {
-	// Simple pattern matching - can be enhanced with proper glob matching
-	patternStr := pattern.pattern
-
-	// Handle directory-only patterns
-	if pattern.isDir {
-		info, err := os.Stat(filepath.Join(g.root, path))
-		if err != nil || !info.IsDir() {
-			return false
-		}
-	}
-
-	// Handle patterns starting with /
-	if strings.HasPrefix(patternStr, "/") {
-		patternStr = patternStr[1:]
-		// Must match from root
-		return strings.HasPrefix(path, patternStr)
-	}
-
-	// Handle wildcards (simplified)
-	if strings.Contains(patternStr, "*") {
-		// Convert simple wildcards to work with filepath.Match
-		patternStr = strings.ReplaceAll(patternStr, "**", "*")
-		matched, _ := filepath.Match(patternStr, path)
-		if matched {
-			return true
-		}
-		// Also check if any parent directory matches
-		parts := strings.Split(path, "/")
-		for i := range parts {
-			subPath := strings.Join(parts[i:], "/")
-			if matched, _ := filepath.Match(patternStr, subPath); matched {
-				return true
-			}
-		}
-		return false
-	}
-
-	// Check if path contains the pattern
-	return strings.Contains(path, patternStr)
-}
-
 // ZipFilesHandler handles requests to zip files
 func ZipFilesHandler(c rweb.Context) error {
 	var req ZipRequest
@@ -180,15 +52,10 @@ func ZipFilesHandler(c rweb.Context) error {
 	// Get project root
 	projectRoot, _ := os.Getwd()
 
-	// Create gitignore parser if needed
-	var gitignoreParser *GitignoreParser
+	// Match against the project's ignore files if requested
+	var ignored *ignore.Matcher
 	if req.UseGitignore {
-		parser, err := NewGitignoreParser(projectRoot)
-		if err != nil {
-			logger.LogErr(err, "Failed to parse .gitignore, continuing without it")
-		} else {
-			gitignoreParser = parser
-		}
+		ignored = ignore.NewMatcher(projectRoot)
 	}
 
 	// Create output zip file
@@ -242,7 +109,7 @@ func ZipFilesHandler(c rweb.Context) error {
 				}
 
 				// Check exclusion rules
-				if shouldExcludeFile(filePath, req.ExcludeDotFiles, gitignoreParser, projectRoot) {
+				if shouldExcludeFile(filePath, req.ExcludeDotFiles, ignored) {
 					filesSkipped++
 					return nil
 				}
@@ -263,7 +130,7 @@ func ZipFilesHandler(c rweb.Context) error {
 			}
 		} else {
 			// Single file
-			if shouldExcludeFile(absPath, req.ExcludeDotFiles, gitignoreParser, projectRoot) {
+			if shouldExcludeFile(absPath, req.ExcludeDotFiles, ignored) {
 				filesSkipped++
 				continue
 			}
@@ -303,7 +170,7 @@ func ZipFilesHandler(c rweb.Context) error {
 }
 
 // shouldExcludeFile checks if a file should be excluded from the zip
-func shouldExcludeFile(path string, excludeDotFiles bool, gitignoreParser *GitignoreParser, projectRoot string) bool {
+func shouldExcludeFile(path string, excludeDotFiles bool, ignored *ignore.Matcher) bool {
 	fileName := filepath.Base(path)
 
 	// Never include the zip file itself or git directory
@@ -317,7 +184,7 @@ func shouldExcludeFile(path string, excludeDotFiles bool, gitignoreParser *Gitig
 	}
 
 	// Check gitignore
-	if gitignoreParser != nil && gitignoreParser.ShouldIgnore(path) {
+	if ignored != nil && ignored.Match(path, false) {
 		return true
 	}
 